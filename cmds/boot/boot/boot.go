@@ -8,7 +8,7 @@
 
 //
 // Synopsis:
-//	boot [-v][-no-load][-no-exec]
+//	boot [-v][-no-load][-no-exec][-profiles FILE][-remove PARAMS][-reuse PARAMS][-append PARAMS]
 //
 // Description:
 //	If returns to u-root shell, the code didn't found a local bootable option
@@ -16,6 +16,11 @@
 //      -v prints messages
 //      -no-load prints the boot image paths it was going to load, but doesn't load + exec them
 //      -no-exec loads the boot image, but doesn't exec it
+//      -profiles selects the images and kernel parameters by the SMBIOS
+//                fields of the machine with the rules in FILE, see pkg/boot/profile
+//      -remove, -reuse and -append merge the kernel parameters of the images
+//                with those of the running kernel: -reuse, e.g. console,ip,rd.*,
+//                passes those of the running kernel on, see cmdline.Policy
 //
 // Notes:
 //	The code is looking for boot/grub/grub.cfg file as to identify the
@@ -31,15 +36,16 @@ package main
 import (
 	"flag"
 	"log"
-	"strings"
 
 	"github.com/u-root/u-root/pkg/boot"
 	"github.com/u-root/u-root/pkg/boot/bootcmd"
 	"github.com/u-root/u-root/pkg/boot/localboot"
 	"github.com/u-root/u-root/pkg/boot/menu"
+	"github.com/u-root/u-root/pkg/boot/profile"
 	"github.com/u-root/u-root/pkg/cmdline"
 	"github.com/u-root/u-root/pkg/mount"
 	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/smbios"
 	"github.com/u-root/u-root/pkg/ulog"
 )
 
@@ -49,17 +55,49 @@ var (
 	noExec  = flag.Bool("no-exec", false, "load boot configuration, but do not exec it")
 
 	removeCmdlineItem = flag.String("remove", "console", "comma separated list of kernel params value to remove from parsed kernel configuration (default to console)")
-	reuseCmdlineItem  = flag.String("reuse", "console", "comma separated list of kernel params value to reuse from current kernel (default to console)")
+	reuseCmdlineItem  = flag.String("reuse", "console", "comma separated list of kernel params value to reuse from current kernel, e.g. console,ip,rd.* (default to console)")
 	appendCmdline     = flag.String("append", "", "Additional kernel params")
 	blockList         = flag.String("block", "", "comma separated list of pci vendor and device ids to ignore (format vendor:device). E.g. 0x8086:0x1234,0x8086:0xabcd")
+	profiles          = flag.String("profiles", "", "rules file to select boot images and kernel params by SMBIOS fields")
 )
 
-// updateBootCmdline get the kernel command line parameters and filter it:
-// it removes parameters listed in 'remove' and append extra parameters from
-// the 'append' and 'reuse' flags
-func updateBootCmdline(cl string) string {
-	f := cmdline.NewUpdateFilter(*appendCmdline, strings.Split(*removeCmdlineItem, ","), strings.Split(*reuseCmdlineItem, ","))
-	return f.Update(cmdline.NewCmdLine(), cl)
+// applyProfile applies the first rule of the profiles file that matches the
+// SMBIOS fields of the machine. Images are booted unchanged if there is none.
+func applyProfile(images []boot.OSImage) []boot.OSImage {
+	rules, err := profile.Load(*profiles)
+	if err != nil {
+		log.Printf("Not using boot profiles: %v", err)
+		return images
+	}
+	si, err := smbios.FromSysfs()
+	if err != nil {
+		log.Printf("Not using boot profiles: %v", err)
+		return images
+	}
+	r := profile.Select(rules, si)
+	if r == nil {
+		log.Printf("No boot profile matches this machine")
+		return images
+	}
+	log.Printf("Using boot profile %v", r)
+	selected, err := r.Apply(images)
+	if err != nil {
+		log.Printf("Not using boot profile: %v", err)
+		return images
+	}
+	return selected
+}
+
+// cmdlinePolicy returns how the kernel command lines of images are merged
+// with that of the running kernel: the parameters of the 'remove' flag are
+// removed, those of the 'reuse' flag are inherited, and the 'append' flag is
+// appended.
+func cmdlinePolicy() *cmdline.Policy {
+	return &cmdline.Policy{
+		Inherit: cmdline.SplitList(*reuseCmdlineItem),
+		Remove:  cmdline.SplitList(*removeCmdlineItem),
+		Append:  *appendCmdline,
+	}
 }
 
 func main() {
@@ -95,12 +133,16 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	p, running := cmdlinePolicy(), cmdline.NewCmdLine()
 	for _, img := range images {
 		// Make changes to the kernel command line based on our cmdline.
 		if li, ok := img.(*boot.LinuxImage); ok {
-			li.Cmdline = updateBootCmdline(li.Cmdline)
+			boot.EditCmdlines(p, running, li)
 		}
 	}
+	if *profiles != "" {
+		images = applyProfile(images)
+	}
 
 	menuEntries := menu.OSImages(*verbose, images...)
 	menuEntries = append(menuEntries, menu.Reboot{})
@@ -17,14 +17,77 @@
 //
 //   - a pxelinux.0, in which case we will ignore the pxelinux and try to parse
 //     pxelinux.cfg/<files>
+//
+// With -mdns, the boot server is discovered with mDNS as a DNS-SD service
+// instead, e.g. an _https._tcp service with a "path=/boot.ipxe" TXT record,
+// for lab setups without control over the DHCP server. The lease is still used
+// to configure the interface.
+//
+// With -sign, or $CURL_SIGN, HTTP and HTTPS requests are signed so that
+// kernels and initrds can be fetched from private artifact stores such as S3
+// buckets; see curl.ParseSigner.
+//
+// With -cacert, -cert and -key, and -pin, HTTPS boot servers are verified with
+// a private CA, get a client certificate, and must have a pinned public key;
+// see curl.TLSOptions.
+//
+// With -resolve, a comma separated list of HOST=ADDRESS, the host names of
+// HTTP and HTTPS boot servers are resolved to the given addresses. Other host
+// names are resolved by the DNS server at -dns-server, or with DNS-over-HTTPS
+// at -doh-url, rather than by the resolver of the lease, which may not be
+// trusted; see curl.ResolverOptions.
+//
+// With -fetch-family 4 or 6, HTTP and HTTPS boot servers are only connected to
+// over IPv4 or IPv6. With -fetch-interface, connections go through that
+// interface, e.g. the provisioning network of a machine whose default route
+// is another, and with -fetch-bind-address, they are made from that address;
+// see curl.DialOptions.
+//
+// HTTP and HTTPS boot files are fetched through the proxies in $HTTP_PROXY,
+// $HTTPS_PROXY and $NO_PROXY, which may be SOCKS5 proxies, or through -proxy.
+// TFTP files are not proxied.
+//
+// With -mirrors, boot configurations can refer to mirror:///PATH, which is
+// fetched from the first of the mirrors that has PATH, failing over to the
+// next, or from the fastest of -mirror-race at a time. Mirrors with weights
+// are tried in a random order, weighted.
+//
+// With -cache, boot files are kept in a directory, e.g. on a local disk, and
+// fetched from there on the next boot, after checking them for corruption.
+// -cache-size keeps the directory under a size, removing the least recently
+// used files.
+//
+// TFTP boot files are fetched in blocks of 1450 bytes, 64 at a time, if the
+// server supports it. -tftp tunes this, e.g. "blksize=1468,windowsize=16" for
+// lossy networks; see curl.ParseTFTPOptions.
+//
+// HTTP and HTTPS boot files share kept-alive connections to each server. With
+// -segments, boot files of 4MiB or more, like large initrds, are fetched with
+// that many range requests in parallel, from servers that support ranges; see
+// curl.HTTPClient.Segmented.
+//
+// With -inherit, kernel parameters of the running kernel, e.g. console,ip,rd.*,
+// are passed on to the booted kernel, replacing those of the boot
+// configuration. -remove removes parameters, and -cmd appends to them; see
+// cmdline.Policy.
+//
+// With -timing, how long DHCP, fetching the boot configuration, downloading,
+// verifying and kexec'ing took is printed before kexec'ing, and with
+// -timing-url, it is POSTed there as JSON, to track boot times of a fleet; see
+// the timing package.
 package main
 
 import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
 	"strings"
 	"time"
 
@@ -32,11 +95,15 @@ import (
 	"github.com/u-root/u-root/pkg/boot/bootcmd"
 	"github.com/u-root/u-root/pkg/boot/menu"
 	"github.com/u-root/u-root/pkg/boot/netboot"
+	"github.com/u-root/u-root/pkg/boot/timing"
+	"github.com/u-root/u-root/pkg/cmdline"
 	"github.com/u-root/u-root/pkg/curl"
 	"github.com/u-root/u-root/pkg/dhclient"
+	"github.com/u-root/u-root/pkg/mdns"
 	"github.com/u-root/u-root/pkg/sh"
 	"github.com/u-root/u-root/pkg/ulog"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/insomniacslk/dhcp/dhcpv4"
 )
 
@@ -52,6 +119,32 @@ var (
 	cmdAppend   = flag.String("cmd", "", "Kernel command to append for each image")
 	bootfile    = flag.String("file", "", "Boot file name (default tftp) or full URI to use instead of DHCP.")
 	server      = flag.String("server", "0.0.0.0", "Server IP (Requires -file for effect)")
+	sign        = flag.String("sign", os.Getenv("CURL_SIGN"), "Sign HTTP requests: aws-sigv4[:REGION[:SERVICE]] or hmac[:KEYID]")
+	mdnsService = flag.String("mdns", "", "Discover the boot server with mDNS as this DNS-SD service, e.g. _https._tcp, instead of using the DHCP boot file")
+	mdnsTimeout = flag.Duration("mdns-timeout", 3*time.Second, "How long to discover boot servers with mDNS")
+	fetchTries  = flag.Int("fetch-tries", 1, "How many times to try fetching each boot file, retrying timeouts and server errors with backoff")
+	progress    = flag.Bool("progress", false, "Print the progress and transfer rate of boot file downloads")
+	caCert      = flag.String("cacert", "", "PEM file of the CAs to verify HTTPS boot servers with, instead of the system's")
+	cert        = flag.String("cert", "", "PEM file of the client certificate to present to HTTPS boot servers, and maybe its key")
+	key         = flag.String("key", "", "PEM file of the key of the client certificate")
+	pins        = flag.String("pin", "", "Comma separated sha256//BASE64 hashes of the public keys HTTPS boot servers may have")
+	mirrors     = flag.String("mirrors", "", "Comma separated base URLs of mirrors, each optionally =WEIGHT, to fetch mirror:///PATH boot files from")
+	mirrorRace  = flag.Int("mirror-race", 1, "How many mirrors to try at the same time")
+	proxy       = flag.String("proxy", "", "Fetch HTTP and HTTPS boot files through a proxy: socks5://[USER:PASSWORD@]HOST:PORT or http://HOST:PORT")
+	cacheDir    = flag.String("cache", "", "Directory to keep boot files in, and fetch them from on the next boot")
+	cacheMax    = flag.String("cache-size", "", "Size the -cache directory is kept under, e.g. 2GiB")
+	inherit     = flag.String("inherit", "", "Comma separated kernel parameters of the running kernel to pass on to each image, e.g. console,ip,rd.*")
+	remove      = flag.String("remove", "", "Comma separated kernel parameters to remove from each image")
+	resolve     = flag.String("resolve", "", "Comma separated HOST=ADDRESS to resolve the host names of HTTP and HTTPS boot servers to")
+	dnsAddr     = flag.String("dns-server", "", "HOST[:PORT] of the DNS server to resolve the host names of HTTP and HTTPS boot servers with")
+	dohURL      = flag.String("doh-url", "", "URL of a DNS-over-HTTPS endpoint to resolve the host names of HTTP and HTTPS boot servers with")
+	tftpOpts    = flag.String("tftp", "", "TFTP options to negotiate: blksize=BYTES,windowsize=BLOCKS,timeout=DURATION,retransmit=TRIES")
+	segments    = flag.Int("segments", 1, "Fetch large HTTP and HTTPS boot files with this many range requests in parallel")
+	fetchFamily = flag.Int("fetch-family", 0, "IP version, 4 or 6, to connect to HTTP and HTTPS boot servers with, or 0 for either")
+	fetchIface  = flag.String("fetch-interface", "", "Network interface to connect to HTTP and HTTPS boot servers through")
+	fetchAddr   = flag.String("fetch-bind-address", "", "Source address of connections to HTTP and HTTPS boot servers")
+	showTiming  = flag.Bool("timing", false, "Print how long each phase of the boot took before kexec'ing")
+	timingURL   = flag.String("timing-url", "", "URL to POST how long each phase of the boot took to as JSON before kexec'ing")
 )
 
 const (
@@ -59,9 +152,119 @@ const (
 	dhcpTries   = 3
 )
 
+// fetchSchemes returns the schemes to fetch boot files with, which sign HTTP
+// requests if -sign is given, go through -proxy if given, connect as of
+// -fetch-family, -fetch-interface and -fetch-bind-address, use the TLS options
+// of -cacert, -cert, -key and -pin, resolve host names with -resolve,
+// -dns-server or -doh-url, fetch large files in -segments, negotiate the TFTP
+// options of -tftp, fetch mirror:// URLs from -mirrors, keep boot files in
+// -cache, print their progress if -progress is given, and retry if
+// -fetch-tries is more than 1.
+func fetchSchemes() (curl.Schemes, error) {
+	s, err := curl.ParseSigner(*sign, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	t := curl.TLSOptions{CAFile: *caCert, CertFile: *cert, KeyFile: *key}
+	if *pins != "" {
+		t.Pins = strings.Split(*pins, ",")
+	}
+	hosts, err := curl.ParseHosts(*resolve)
+	if err != nil {
+		return nil, err
+	}
+	r := curl.ResolverOptions{Hosts: hosts, Server: *dnsAddr, DoH: *dohURL}
+	d := curl.DialOptions{Family: *fetchFamily, Interface: *fetchIface}
+	if *fetchAddr != "" {
+		if d.LocalAddr = net.ParseIP(*fetchAddr); d.LocalAddr == nil {
+			return nil, fmt.Errorf("invalid -fetch-bind-address %q", *fetchAddr)
+		}
+	}
+	schemes := curl.DefaultSchemes
+	if s != nil || !t.IsZero() || *proxy != "" || !r.IsZero() || !d.IsZero() || *segments > 1 {
+		client := http.DefaultClient
+		if *proxy != "" {
+			p, err := curl.ParseProxy(*proxy)
+			if err != nil {
+				return nil, err
+			}
+			client = curl.ProxyClient(p)
+		}
+		if !d.IsZero() {
+			if client, err = curl.DialerClient(client, d); err != nil {
+				return nil, err
+			}
+		}
+		if !t.IsZero() {
+			cfg, err := t.Config()
+			if err != nil {
+				return nil, err
+			}
+			client = curl.TLSClient(client, cfg)
+		}
+		if !r.IsZero() {
+			resolver, err := r.Resolver()
+			if err != nil {
+				return nil, err
+			}
+			client = curl.ResolverClient(client, resolver)
+		}
+		h := curl.NewSignedHTTPClient(curl.PooledClient(client, 0), s)
+		if *segments > 1 {
+			h = h.Segmented(*segments, 0)
+		}
+		schemes = schemes.WithHTTPClient(h)
+	}
+	if *tftpOpts != "" {
+		o, err := curl.ParseTFTPOptions(*tftpOpts)
+		if err != nil {
+			return nil, err
+		}
+		c, err := curl.NewTFTPClientWithOptions(o)
+		if err != nil {
+			return nil, err
+		}
+		schemes = schemes.WithTFTPClient(c)
+	}
+	if *mirrors != "" {
+		m, w, err := curl.ParseMirrors(*mirrors)
+		if err != nil {
+			return nil, err
+		}
+		// Copied, not to register mirror in curl.DefaultSchemes.
+		withMirror := curl.Schemes{"mirror": &curl.MirrorScheme{Schemes: schemes, Mirrors: m, Weights: w, Race: *mirrorRace}}
+		for scheme, fs := range schemes {
+			withMirror[scheme] = fs
+		}
+		schemes = withMirror
+	}
+	if *cacheDir != "" {
+		c := &curl.DiskCache{Dir: *cacheDir}
+		if *cacheMax != "" {
+			n, err := humanize.ParseBytes(*cacheMax)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -cache-size: %v", err)
+			}
+			c.MaxSize = int64(n)
+		}
+		schemes = schemes.WithDiskCache(c)
+	}
+	if *progress {
+		schemes = schemes.WithProgress(func(u *url.URL) curl.ProgressFunc {
+			return curl.TextProgress(os.Stderr, path.Base(u.Path))
+		})
+	}
+	if *fetchTries > 1 {
+		p := curl.DefaultRetryPolicy
+		p.MaxAttempts = *fetchTries
+		schemes = schemes.WithRetries(p)
+	}
+	return schemes, nil
+}
+
 // NetbootImages requests DHCP on every ifaceNames interface, and parses
-// netboot images from the DHCP leases. Returns bootable OSes.
-func NetbootImages(ifaceNames string) ([]boot.OSImage, error) {
+// netboot images from the DHCP leases using schemes. Returns bootable OSes.
+func NetbootImages(ifaceNames string, schemes curl.Schemes) ([]boot.OSImage, error) {
 	filteredIfs, err := dhclient.Interfaces(ifaceNames)
 	if err != nil {
 		return nil, err
@@ -81,22 +284,27 @@ func NetbootImages(ifaceNames string) ([]boot.OSImage, error) {
 	if *verbose {
 		c.LogLevel = dhclient.LogSummary
 	}
+	leased := timing.Begin(timing.DHCP)
 	r := dhclient.SendRequests(ctx, filteredIfs, *ipv4, *ipv6, c, 30*time.Second)
 
 	for {
 		select {
 		case <-ctx.Done():
+			leased(ctx.Err())
 			return nil, ctx.Err()
 
 		case result, ok := <-r:
 			if !ok {
-				return nil, fmt.Errorf("nothing bootable found, all interfaces are configured or timed out")
+				err := fmt.Errorf("nothing bootable found, all interfaces are configured or timed out")
+				leased(err)
+				return nil, err
 			}
 			iname := result.Interface.Attrs().Name
 			if result.Err != nil {
 				log.Printf("Could not configure %s for %s: %v", iname, result.Protocol, result.Err)
 				continue
 			}
+			leased(nil)
 
 			if *noNetConfig {
 				log.Printf("Skipping configuring %s with lease %s", iname, result.Lease)
@@ -108,8 +316,21 @@ func NetbootImages(ifaceNames string) ([]boot.OSImage, error) {
 				// ip/ipv6 address.
 			}
 
+			lease := result.Lease
+			if *mdnsService != "" {
+				u, err := discoverBootServer(iname)
+				if err != nil {
+					log.Printf("Could not discover a boot server on %s: %v", iname, err)
+					continue
+				}
+				if lease, err = bootFromURL(lease, u); err != nil {
+					log.Printf("Failed to boot %s from %s: %v", iname, u, err)
+					continue
+				}
+			}
+
 			// Don't use the other context, as it's for the DHCP timeout.
-			imgs, err := netboot.BootImages(context.Background(), ulog.Log, curl.DefaultSchemes, result.Lease)
+			imgs, err := bootImages(lease, schemes)
 			if err != nil {
 				log.Printf("Failed to boot lease %v: %v", result.Lease, err)
 				continue
@@ -120,6 +341,62 @@ func NetbootImages(ifaceNames string) ([]boot.OSImage, error) {
 	}
 }
 
+// bootImages fetches and parses the boot configuration of lease l.
+func bootImages(l dhclient.Lease, schemes curl.Schemes) ([]boot.OSImage, error) {
+	fetched := timing.Begin(timing.Config)
+	imgs, err := netboot.BootImages(context.Background(), ulog.Log, schemes, l)
+	fetched(err)
+	return imgs, err
+}
+
+// discoverBootServer returns the URL of the first -mdns service discovered on
+// interface iname.
+func discoverBootServer(iname string) (*url.URL, error) {
+	iface, err := net.InterfaceByName(iname)
+	if err != nil {
+		return nil, err
+	}
+	c, err := mdns.NewClient(iface)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *mdnsTimeout)
+	defer cancel()
+	services, err := c.Browse(ctx, *mdnsService)
+	if err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no %s service found", *mdnsService)
+	}
+	if *verbose {
+		for _, s := range services {
+			log.Printf("Found %s", s)
+		}
+	}
+	return services[0].URL(), nil
+}
+
+// bootFromURL returns a copy of lease l that boots from u instead of its boot
+// file.
+func bootFromURL(l dhclient.Lease, u *url.URL) (dhclient.Lease, error) {
+	var d *dhcpv4.DHCPv4
+	var err error
+	if p4, ok := l.(*dhclient.Packet4); ok {
+		d, err = dhcpv4.FromBytes(p4.P.ToBytes())
+	} else {
+		d, err = dhcpv4.New()
+	}
+	if err != nil {
+		return nil, err
+	}
+	delete(d.Options, dhcpv4.OptionBootfileName.Code())
+	d.BootFileName = u.String()
+	return dhclient.NewPacket4(l.Link(), d), nil
+}
+
 func newManualLease() (dhclient.Lease, error) {
 	filteredIfs, err := dhclient.Interfaces(ifName)
 	if err != nil {
@@ -148,6 +425,10 @@ func dumpNetDebugInfo() {
 	}
 }
 
+func init() {
+	flag.Var(ulog.TraceFlag{}, "trace", ulog.TraceUsage)
+}
+
 func main() {
 	flag.Parse()
 	if len(flag.Args()) > 1 {
@@ -157,10 +438,19 @@ func main() {
 		ifName = flag.Args()[0]
 	}
 
+	schemes, err := fetchSchemes()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var timingOut io.Writer
+	if *showTiming {
+		timingOut = os.Stderr
+	}
+	timing.SetOutput(timingOut, *timingURL)
+
 	var images []boot.OSImage
-	var err error
 	if *bootfile == "" {
-		images, err = NetbootImages(ifName)
+		images, err = NetbootImages(ifName, schemes)
 		if err != nil {
 			dumpNetDebugInfo()
 		}
@@ -169,7 +459,7 @@ func main() {
 		var l dhclient.Lease
 		l, err = newManualLease()
 		if err == nil {
-			images, err = netboot.BootImages(context.Background(), ulog.Log, curl.DefaultSchemes, l)
+			images, err = bootImages(l, schemes)
 		}
 	}
 
@@ -177,11 +467,12 @@ func main() {
 		log.Printf("Netboot failed: %v", err)
 	}
 
-	for _, img := range images {
-		img.Edit(func(cmdline string) string {
-			return cmdline + " " + *cmdAppend
-		})
+	p := &cmdline.Policy{
+		Inherit: cmdline.SplitList(*inherit),
+		Remove:  cmdline.SplitList(*remove),
+		Append:  *cmdAppend,
 	}
+	boot.EditCmdlines(p, cmdline.NewCmdLine(), images...)
 
 	menuEntries := menu.OSImages(*verbose, images...)
 	menuEntries = append(menuEntries, menu.Reboot{})
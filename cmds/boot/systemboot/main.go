@@ -5,11 +5,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"strings"
 	"time"
@@ -26,6 +27,7 @@ var (
 	doQuiet          = flag.Bool("q", false, fmt.Sprintf("Disable verbose output. If not specified, read it from VPD var '%s'. Default false", vpdSystembootLogLevel))
 	interval         = flag.Int("I", 1, "Interval in seconds before looping to the next boot command")
 	noDefaultBoot    = flag.Bool("nodefault", false, "Do not attempt default boot entries if regular ones fail")
+	chainFile        = flag.String("chain", "/etc/systemboot/chain.json", "JSON file of the boot chain to try if regular boot entries fail, instead of the default one")
 )
 
 const (
@@ -44,9 +46,28 @@ func isFlagPassed(name string) bool {
 	return found
 }
 
-var defaultBootsequence = [][]string{
-	{"fbnetboot", "-userclass", "linuxboot"},
-	{"localboot", "-grub"},
+// defaultChain is tried if the regular boot entries fail and there is no
+// chain file.
+var defaultChain = systembooter.Chain{
+	Stages: []systembooter.Stage{
+		{Name: "fbnetboot", Command: []string{"fbnetboot", "-userclass", "linuxboot"}, DebugArgs: []string{"-d"}, Attempts: 1},
+		{Name: "localboot", Command: []string{"localboot", "-grub"}, DebugArgs: []string{"-d"}, Attempts: 1},
+	},
+}
+
+// bootChain returns the boot chain of the chain file, or the default chain
+// if there is none.
+func bootChain(sleepInterval time.Duration) *systembooter.Chain {
+	c, err := systembooter.ReadChainFile(*chainFile)
+	if err == nil {
+		return c
+	}
+	if !errors.Is(err, os.ErrNotExist) || isFlagPassed("chain") {
+		log.Printf("Error reading the boot chain, using the default one: %v", err)
+	}
+	c = &defaultChain
+	c.Interval = systembooter.Duration(sleepInterval)
+	return c
 }
 
 // VPD variable for enabling IPMI BMC overriding boot order, default is not set
@@ -57,8 +78,6 @@ var bmcBootOverride bool
 // Product list for running IPMI OEM commands
 var productList = [5]string{"Tioga Pass", "Mono Lake", "Delta Lake", "Crater Lake", "S9S"}
 
-var selRecorded bool
-
 func isMatched(productName string) bool {
 	for _, v := range productList {
 		if strings.HasPrefix(productName, v) {
@@ -335,29 +354,18 @@ func main() {
 	log.Printf("Boot entries failed")
 
 	if !*noDefaultBoot {
-		log.Print("Falling back to the default boot sequence")
-		for {
-			for _, bootcmd := range defaultBootsequence {
-				if debugEnabled {
-					bootcmd = append(bootcmd, "-d")
-				}
-				log.Printf("Running boot command: %v", bootcmd)
-				cmd := exec.Command(bootcmd[0], bootcmd[1:]...)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				if err := cmd.Run(); err != nil {
-					log.Printf("Error executing %v: %v", cmd, err)
-					if !selRecorded {
-						addSEL(bootcmd[0])
-					}
-				}
+		log.Print("Falling back to the boot chain")
+		c := bootChain(sleepInterval)
+		// SEL entries are only recorded for the first failure of each stage.
+		recorded := map[string]bool{}
+		c.OnFailure = func(s systembooter.Stage, err error) {
+			if err != nil && !recorded[s.Name] {
+				recorded[s.Name] = true
+				addSEL(s.Command[0])
 			}
-			selRecorded = true
-
-			if debugEnabled {
-				log.Printf("Sleeping %v before attempting next boot command", sleepInterval)
-			}
-			time.Sleep(sleepInterval)
+		}
+		if err := c.Run(context.Background(), debugEnabled); err != nil {
+			log.Printf("Boot chain failed: %v", err)
 		}
 	}
 }
@@ -5,41 +5,53 @@
 // base64 - encode and decode base64 from stdin or file to stdout
 
 // Synopsis:
-//     base64 [-d] [FILE]
+//     base64 [-d [-i]] [-w COLS] [FILE]
 
 // Description:
 //    Encode or decode a file to or from base64 encoding.
 //    -d   decode data (default is to encode)
+//    -i   when decoding, ignore characters that are not base64
+//    -w   break encoded lines after COLS characters, 76 by default;
+//         0 does not break them
 //    For stdin, on standard Unix systems, you can use /dev/stdin
+//    Decoding ignores line breaks, and accepts data without its padding.
+//    basenc has the URL safe base64 alphabet.
 
 package main
 
 import (
-	"encoding/base64"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+
+	"github.com/u-root/u-root/pkg/basenc"
 )
 
 var (
 	decode      = flag.Bool("d", false, "Decode")
-	errBadUsage = errors.New("usage: base64 [-d] [file]")
+	ignore      = flag.Bool("i", false, "When decoding, ignore characters that are not base64")
+	wrap        = flag.Int("w", 76, "Break encoded lines after `COLS` characters, or not with 0")
+	errBadUsage = errors.New("usage: base64 [-d [-i]] [-w COLS] [file]")
 )
 
 func do(r io.Reader, w io.Writer, decode bool) error {
-	op := "decoding"
 	if decode {
-		r = base64.NewDecoder(base64.RawStdEncoding, r)
-	} else {
-		op = "encoding"
-		w = base64.NewEncoder(base64.RawStdEncoding, w)
+		if _, err := io.Copy(w, basenc.NewDecoder(basenc.Base64, r, *ignore)); err != nil {
+			return fmt.Errorf("error decoding the data: %w", err)
+		}
+		return nil
 	}
 
-	if _, err := io.Copy(w, r); err != nil {
-		return fmt.Errorf("error %s the data: %w", op, err)
+	e := basenc.NewEncoder(basenc.Base64, w, *wrap)
+	_, err := io.Copy(e, r)
+	if err == nil {
+		err = e.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("error encoding the data: %w", err)
 	}
 	return nil
 }
@@ -49,6 +61,9 @@ func do(r io.Reader, w io.Writer, decode bool) error {
 // allows us, should we wish, in future, to go with using
 // names[1] as out. base64 commands are very nonstandard.
 func run(stdin io.Reader, stdout io.Writer, decode bool, names ...string) error {
+	if *wrap < 0 {
+		return errBadUsage
+	}
 	switch len(names) {
 	case 0:
 	case 1:
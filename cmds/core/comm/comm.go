@@ -6,7 +6,7 @@
 //
 // Synopsis:
 //
-//	comm [-123h] FILE1 FILE2
+//	comm [-123h] [-output-delimiter STRING] FILE1 FILE2
 //
 // Descrption:
 //
@@ -21,6 +21,7 @@
 //	-2: suppress printing of column 2
 //	-3: suppress printing of column 3
 //	-h: print this help message and exit
+//	-output-delimiter: separate the columns with STRING (default tab)
 package main
 
 import (
@@ -39,6 +40,7 @@ var (
 	s2   = flag.Bool("2", false, "suppress printing of column 2")
 	s3   = flag.Bool("3", false, "suppress printing of column 3")
 	help = flag.Bool("h", false, "print this help message and exit")
+	sep  = flag.String("output-delimiter", "\t", "separate the columns with STRING")
 
 	// ErrUsage is the error for incorrect usage.
 	ErrUsage = errors.New("comm: comm [-123h] file1 file2")
@@ -90,6 +92,17 @@ func outer(c1, c2 chan string, c chan out) {
 	close(c)
 }
 
+func open(name string) (*os.File, error) {
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("can't open %s: %v", name, err)
+	}
+	return f, nil
+}
+
 func comm(w io.Writer, args ...string) error {
 	if len(args) != 2 || *help {
 		return ErrUsage
@@ -99,14 +112,23 @@ func comm(w io.Writer, args ...string) error {
 	c2 := make(chan string, 100)
 	c := make(chan out, 100)
 
-	f1, err := os.Open(args[0])
+	if args[0] == "-" && args[1] == "-" {
+		return errors.New("only one file can be the standard input")
+	}
+	f1, err := open(args[0])
 	if err != nil {
-		return fmt.Errorf("can't open %s: %v", args[0], err)
+		return err
+	}
+	if f1 != os.Stdin {
+		defer f1.Close()
 	}
 
-	f2, err := os.Open(args[1])
+	f2, err := open(args[1])
 	if err != nil {
-		return fmt.Errorf("can't open %s: %v", args[1], err)
+		return err
+	}
+	if f2 != os.Stdin {
+		defer f2.Close()
 	}
 	go reader(f1, c1)
 	go reader(f2, c2)
@@ -122,16 +144,19 @@ func comm(w io.Writer, args ...string) error {
 		if !*s1 {
 			line += out.s1
 		}
-		line += "\t"
+		line += *sep
 		if !*s2 {
 			line += out.s2
 		}
-		line += "\t"
+		line += *sep
 		if !*s3 {
 			line += out.s3
 		}
-		if line != "\t\t" {
-			fmt.Fprintln(w, strings.TrimRight(line, "\t")) // the unix comm utility does this
+		if line != *sep+*sep {
+			for *sep != "" && strings.HasSuffix(line, *sep) { // the unix comm utility does this
+				line = strings.TrimSuffix(line, *sep)
+			}
+			fmt.Fprintln(w, line)
 		}
 	}
 	return nil
@@ -22,6 +22,7 @@ func TestComm(t *testing.T) {
 		s2    bool
 		s3    bool
 		help  bool
+		sep   string
 		want  string
 	}{
 		{
@@ -83,6 +84,19 @@ func TestComm(t *testing.T) {
 			s3:    true,
 			want:  "\tline3\nline4\n",
 		},
+		{
+			name:  "comm output delimiter",
+			args:  []string{filepath.Join(tmpdir, "file1"), filepath.Join(tmpdir, "file2")},
+			file1: "line1\nline2\nline4\n",
+			file2: "line1\nline2\nline3\n",
+			sep:   ", ",
+			want:  ", , line1\n, , line2\n, line3\nline4\n",
+		},
+		{
+			name: "comm both stdin",
+			args: []string{"-", "-"},
+			want: "only one file can be the standard input",
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create files
@@ -109,6 +123,10 @@ func TestComm(t *testing.T) {
 			*s2 = tt.s2
 			*s3 = tt.s3
 			*help = tt.help
+			*sep = "\t"
+			if tt.sep != "" {
+				*sep = tt.sep
+			}
 
 			buf := &bytes.Buffer{}
 			if got := comm(buf, tt.args...); got != nil {
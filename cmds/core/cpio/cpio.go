@@ -16,6 +16,7 @@
 //	o: output an archive to stdout given a pattern
 //	i: output files from a stdin stream
 //	t: print table of contents
+//	fromtar: convert a tar archive on stdin to a cpio archive on stdout
 //	-v: debug prints
 //
 // Bugs: in i mode, it can't use non-seekable stdin, i.e. a pipe. Yep, this sucks.
@@ -43,7 +44,7 @@ var (
 	d      = flag.Bool("v", false, "Debug prints")
 	format = flag.String("H", "newc", "format")
 
-	errInvalidArgs = errors.New("Usage of the command:\ncpio o < name-list [> archive]\ncpio i [< archive]\ncpio p destination-directory < name-list\nOptions: -H format (default: newc) -v Debug prints ")
+	errInvalidArgs = errors.New("Usage of the command:\ncpio o < name-list [> archive]\ncpio i [< archive]\ncpio p destination-directory < name-list\ncpio fromtar < tar-archive [> archive]\nOptions: -H format (default: newc) -v Debug prints ")
 )
 
 func usage() error {
@@ -166,6 +167,15 @@ func run(args []string, stdin *os.File, stdout io.Writer, d bool, format string)
 			fmt.Println(rec)
 		}
 
+	case "fromtar":
+		rw := archiver.Writer(stdout)
+		if err := cpio.FromTar(rw, stdin); err != nil {
+			return fmt.Errorf("Converting tar archive failed: %w", err)
+		}
+		if err := cpio.WriteTrailer(rw); err != nil {
+			return fmt.Errorf("Error writing trailer record: %w", err)
+		}
+
 	default:
 		return usage()
 	}
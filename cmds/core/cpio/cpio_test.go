@@ -5,12 +5,16 @@
 package main
 
 import (
+	"archive/tar"
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+
+	"github.com/u-root/u-root/pkg/cpio"
 )
 
 type dirEnt struct {
@@ -180,3 +184,37 @@ func TestDirectoryHardLink(t *testing.T) {
 	}
 
 }
+
+func TestFromTar(t *testing.T) {
+	tarFile, err := os.Create(filepath.Join(t.TempDir(), "archive.tar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tarFile.Close()
+	tw := tar.NewWriter(tarFile)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "init", Mode: 0o755, Size: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tarFile.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	if err := run([]string{"fromtar"}, tarFile, out, true, "newc"); err != nil {
+		t.Fatalf("run(fromtar) = %v", err)
+	}
+	recs, err := cpio.ReadAllRecords(cpio.Newc.Reader(bytes.NewReader(out.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []cpio.Record{cpio.StaticFile("init", "hello", 0o755)}
+	if !cpio.AllEqual(recs, want) {
+		t.Errorf("run(fromtar) wrote %v, want %v", recs, want)
+	}
+}
@@ -11,11 +11,24 @@
 //
 //	dhclient [OPTIONS...]
 //
+// Description:
+//
+//	DNS settings of each lease are merged with those of the kernel command
+//	line (ip= and nameserver=) and any static ones in /etc/resolv.conf.d
+//	into /etc/resolv.conf.
+//
+//	The host name is set from the first lease, using the -hostname
+//	template. ${hostname} is the name the DHCPv4 server sent (option 12);
+//	${mac}, ${ip} and ${iface} describe the lease. The host name is not
+//	changed if the template refers to a value the lease lacks.
+//
 // Options:
 //
-//	-timeout:  lease timeout in seconds
-//	-renewals: number of DHCP renewals before exiting
-//	-verbose:  verbose output
+//	-timeout:     lease timeout in seconds
+//	-renewals:    number of DHCP renewals before exiting
+//	-verbose:     verbose output
+//	-hostname:    host name template (default ${hostname}, "" to keep the host name)
+//	-cmdline-dns: use the DNS servers of the kernel command line (default true)
 package main
 
 import (
@@ -27,7 +40,9 @@ import (
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/u-root/u-root/pkg/cmdline"
 	"github.com/u-root/u-root/pkg/dhclient"
+	"github.com/u-root/u-root/pkg/ulog"
 	"github.com/vishvananda/netlink"
 )
 
@@ -45,8 +60,15 @@ var (
 	v6Server = flag.String("v6-server", "ff02::1:2", "DHCPv6 server address to send to (multicast or unicast)")
 
 	v4Port = flag.Int("v4-port", dhcpv4.ServerPort, "DHCPv4 server port to send to")
+
+	hostname   = flag.String("hostname", "${hostname}", "Host name template; empty to keep the host name")
+	cmdlineDNS = flag.Bool("cmdline-dns", true, "Use the DNS servers of the kernel command line")
 )
 
+func init() {
+	flag.Var(ulog.TraceFlag{}, "trace", ulog.TraceUsage)
+}
+
 func main() {
 	flag.Parse()
 	if len(flag.Args()) > 1 {
@@ -65,6 +87,25 @@ func main() {
 	configureAll(filteredIfs)
 }
 
+// setHostname sets the host name for l, and reports whether the template was
+// complete for l.
+func setHostname(l dhclient.Lease) bool {
+	name, err := dhclient.ExpandHostname(*hostname, l)
+	if err != nil {
+		log.Printf("%v", err)
+		return true
+	}
+	if name == "" {
+		return false
+	}
+	if err := dhclient.SetHostname(name); err != nil {
+		log.Printf("Could not set host name: %v", err)
+	} else {
+		log.Printf("Set host name to %s", name)
+	}
+	return true
+}
+
 func configureAll(ifs []netlink.Link) {
 	packetTimeout := time.Duration(*timeout) * time.Second
 
@@ -86,8 +127,16 @@ func configureAll(ifs []netlink.Link) {
 	if *vverbose {
 		c.LogLevel = dhclient.LogDebug
 	}
+	if *cmdlineDNS && !*dryRun {
+		dns, _ := dhclient.DNSFromCmdline(cmdline.FullCmdLine())
+		if err := dhclient.DefaultResolver.Set("cmdline", dhclient.PriorityCmdline, dns); err != nil {
+			log.Printf("Could not add DNS settings of the kernel command line: %v", err)
+		}
+	}
+
 	r := dhclient.SendRequests(context.Background(), ifs, *ipv4, *ipv6, c, 30*time.Second)
 
+	named := *hostname == ""
 	for result := range r {
 		if result.Err != nil {
 			log.Printf("Could not configure %s for %s: %v", result.Interface.Attrs().Name, result.Protocol, result.Err)
@@ -97,6 +146,9 @@ func configureAll(ifs []netlink.Link) {
 			log.Printf("Could not configure %s for %s: %v", result.Interface.Attrs().Name, result.Protocol, err)
 		} else {
 			log.Printf("Configured %s with %s", result.Interface.Attrs().Name, result.Lease)
+			if !named {
+				named = setHostname(result.Lease)
+			}
 		}
 	}
 	log.Printf("Finished trying to configure all interfaces.")
@@ -7,19 +7,42 @@
 // Synopsis:
 //
 //	gpgv [-v] KEY SIG CONTENT
+//	gpgv [--keyring FILE]... [-q] [--status-fd N] [-o FILE] SIG [DATA...]
 //
 // Description:
 //
-//	It prints "OK\n" to stdout if the check succeeds and exits with 0. It
-//	prints an error message and exits with non-0 otherwise.
+//	The first form checks the detached signature SIG of CONTENT against the
+//	single public key in KEY. It prints "OK" to stdout if the check succeeds
+//	and exits with 0. It prints an error message and exits with non-0
+//	otherwise.
 //
 //	The openpgp package ReadKeyRing function does not completely implement
 //	RFC4880 in that it can't use a PublicSigningKey with 0 signatures. We
-//	use one from Eric Grosse instead.
+//	use one from Eric Grosse instead. Key rings of the second form may hold
+//	such keys too.
+//
+//	The second form works like GnuPG's gpgv, so scripts written for it run
+//	unchanged. Signatures are checked against the key rings given with
+//	--keyring, which may be binary or ASCII armored; names without a slash
+//	are looked up in $GNUPGHOME, by default ~/.gnupg. Without --keyring,
+//	$GNUPGHOME/trustedkeys.gpg is used. The exit code is 0 for a good
+//	signature, 1 for a bad one and 2 for any other error, such as a
+//	signature by an unknown key. It is used whenever --keyring is given or
+//	there are not exactly three arguments.
+//
+//	SIG may be a detached signature of the DATA files, which are
+//	concatenated; "-" is stdin. Without DATA, the data of a detached
+//	signature is SIG without its .sig, .asc or .sign suffix. SIG may also be
+//	a clear-signed or signed message, whose contents are written to the
+//	-o file if it is given.
 //
 // Options:
 //
-//	-v: verbose
+//	-v:          verbose
+//	--keyring:   key ring to check signatures against, may be repeated
+//	-q:          do not report good signatures on stderr
+//	--status-fd: write machine-readable status lines to this fd
+//	-o:          write the contents of a signed message to FILE
 //
 // Author:
 //
@@ -27,6 +50,7 @@
 package main
 
 import (
+	"bytes"
 	"crypto"
 	"errors"
 	"flag"
@@ -34,7 +58,12 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/u-root/u-root/pkg/vfile"
+	"golang.org/x/crypto/openpgp"
 	gpgerror "golang.org/x/crypto/openpgp/errors"
 	"golang.org/x/crypto/openpgp/packet"
 )
@@ -43,10 +72,36 @@ var (
 	verbose  bool
 	debug    = func(string, ...interface{}) {}
 	errUsage = errors.New("usage: boot-verify [-v] key sig content")
+
+	errKeyringUsage = errors.New("usage: gpgv [--keyring FILE]... [-q] [--status-fd N] [-o FILE] SIG [DATA...]")
+)
+
+type keyrings []string
+
+func (k *keyrings) String() string {
+	return strings.Join(*k, ",")
+}
+
+func (k *keyrings) Set(s string) error {
+	*k = append(*k, s)
+	return nil
+}
+
+var (
+	rings    keyrings
+	quiet    bool
+	statusFD int
+	output   string
 )
 
 func init() {
 	flag.BoolVar(&verbose, "v", false, "verbose")
+	flag.Var(&rings, "keyring", "key ring to check signatures against, may be repeated")
+	flag.BoolVar(&quiet, "q", false, "do not report good signatures")
+	flag.BoolVar(&quiet, "quiet", false, "do not report good signatures")
+	flag.IntVar(&statusFD, "status-fd", -1, "write status lines to this file descriptor")
+	flag.StringVar(&output, "o", "", "write the contents of a signed message to `file`")
+	flag.StringVar(&output, "output", "", "write the contents of a signed message to `file`")
 }
 
 func readPublicSigningKey(keyf io.Reader) (*packet.PublicKey, error) {
@@ -128,12 +183,195 @@ func runGPGV(w io.Writer, verbose bool, keyfile, sigfile, datafile string) error
 	return nil
 }
 
+// gnupgHome returns the GnuPG home directory.
+func gnupgHome() string {
+	if h := os.Getenv("GNUPGHOME"); h != "" {
+		return h
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gnupg")
+}
+
+func readKeyRings(names []string) (openpgp.EntityList, error) {
+	if len(names) == 0 {
+		names = []string{"trustedkeys.gpg"}
+	}
+	var el openpgp.EntityList
+	for _, name := range names {
+		if !strings.ContainsRune(name, '/') {
+			name = filepath.Join(gnupgHome(), name)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		ring, err := vfile.ReadKeyRing(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("keyring %s: %v", name, err)
+		}
+		el = append(el, ring...)
+	}
+	return el, nil
+}
+
+func readFileOrStdin(stdin io.Reader, name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// signedData returns the name of the data file of a detached signature.
+func signedData(sig string) (string, error) {
+	for _, ext := range []string{".sig", ".asc", ".sign"} {
+		if strings.HasSuffix(sig, ext) && len(sig) > len(ext) {
+			return strings.TrimSuffix(sig, ext), nil
+		}
+	}
+	return "", fmt.Errorf("no signed data for %s", sig)
+}
+
+// userID returns the primary user ID of e.
+func userID(e *openpgp.Entity) string {
+	var names []string
+	for name, id := range e.Identities {
+		if id.SelfSignature != nil && id.SelfSignature.IsPrimaryId != nil && *id.SelfSignature.IsPrimaryId {
+			return name
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "[no user ID]"
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// report describes the result of a signature check on stderr and in the
+// status lines, as gpgv does.
+func report(stderr, status io.Writer, ring openpgp.EntityList, s *vfile.Signature, err error) {
+	if s == nil {
+		return
+	}
+	if status == nil {
+		status = io.Discard
+	}
+	fmt.Fprintf(status, "[GNUPG:] NEWSIG\n")
+	if !quiet {
+		fmt.Fprintf(stderr, "gpgv: Signature made %s\n", s.CreationTime.UTC().Format("Mon Jan _2 15:04:05 2006 MST"))
+		fmt.Fprintf(stderr, "gpgv:                using key %016X\n", s.KeyID)
+	}
+	var bad vfile.ErrBadSignature
+	switch {
+	case s.Signer == nil:
+		fmt.Fprintf(status, "[GNUPG:] NO_PUBKEY %016X\n", s.KeyID)
+		fmt.Fprintf(stderr, "gpgv: Can't check signature: No public key\n")
+	case errors.As(err, &bad):
+		fmt.Fprintf(status, "[GNUPG:] BADSIG %016X %s\n", s.KeyID, userID(s.Signer))
+		fmt.Fprintf(stderr, "gpgv: BAD signature from %q\n", userID(s.Signer))
+	case err == nil:
+		fmt.Fprintf(status, "[GNUPG:] GOODSIG %016X %s\n", s.KeyID, userID(s.Signer))
+		fpr := s.Signer.PrimaryKey.Fingerprint
+		if keys := ring.KeysById(s.KeyID); len(keys) > 0 {
+			fpr = keys[0].PublicKey.Fingerprint
+		}
+		fmt.Fprintf(status, "[GNUPG:] VALIDSIG %X %s %d\n", fpr, s.CreationTime.UTC().Format("2006-01-02"), s.CreationTime.Unix())
+		if !quiet {
+			fmt.Fprintf(stderr, "gpgv: Good signature from %q\n", userID(s.Signer))
+		}
+	}
+}
+
+// runKeyring checks the signature in the gpgv way. Bad signatures are
+// reported with a vfile.ErrBadSignature.
+func runKeyring(stdin io.Reader, stdout, stderr, status io.Writer, ringNames []string, args []string) error {
+	if len(args) == 0 {
+		return errKeyringUsage
+	}
+	ring, err := readKeyRings(ringNames)
+	if err != nil {
+		return err
+	}
+	sig, err := readFileOrStdin(stdin, args[0])
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	var s *vfile.Signature
+	switch {
+	case vfile.IsClearSigned(sig):
+		content, s, err = vfile.VerifyClearSigned(ring, sig)
+	case vfile.IsDetachedSignature(sig):
+		files := args[1:]
+		if len(files) == 0 {
+			name, err := signedData(args[0])
+			if err != nil {
+				return err
+			}
+			files = []string{name}
+		}
+		var data bytes.Buffer
+		for _, name := range files {
+			b, err := readFileOrStdin(stdin, name)
+			if err != nil {
+				return err
+			}
+			data.Write(b)
+		}
+		s, err = vfile.VerifyDetachedSignature(ring, &data, bytes.NewReader(sig))
+	default:
+		content, s, err = vfile.VerifySignedMessage(ring, bytes.NewReader(sig))
+	}
+	report(stderr, status, ring, s, err)
+	if err != nil {
+		return err
+	}
+
+	switch output {
+	case "":
+	case "-":
+		_, err = stdout.Write(content)
+	default:
+		err = os.WriteFile(output, content, 0o644)
+	}
+	return err
+}
+
+func statusWriter(fd int) io.Writer {
+	switch fd {
+	case -1:
+		return nil
+	case 1:
+		return os.Stdout
+	case 2:
+		return os.Stderr
+	}
+	return os.NewFile(uintptr(fd), "status")
+}
+
 func main() {
 	flag.Parse()
-	if len(flag.Args()) != 3 {
-		log.Fatal(errUsage)
+	if len(rings) == 0 && len(flag.Args()) == 3 {
+		if err := runGPGV(os.Stdout, verbose, flag.Args()[0], flag.Args()[1], flag.Args()[2]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	if err := runGPGV(os.Stdout, verbose, flag.Args()[0], flag.Args()[1], flag.Args()[2]); err != nil {
-		log.Fatal(err)
+
+	err := runKeyring(os.Stdin, os.Stdout, os.Stderr, statusWriter(statusFD), rings, flag.Args())
+	var bad vfile.ErrBadSignature
+	var wrong vfile.ErrWrongSigner
+	switch {
+	case err == nil:
+	case errors.As(err, &bad):
+		os.Exit(1)
+	case errors.As(err, &wrong):
+		// The missing key has been reported.
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "gpgv: %v\n", err)
+		os.Exit(2)
 	}
 }
@@ -7,8 +7,15 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/u-root/u-root/pkg/vfile"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
 )
 
 func TestRunGPGV(t *testing.T) {
@@ -95,3 +102,98 @@ func TestRunGPGV(t *testing.T) {
 		})
 	}
 }
+
+func TestRunKeyring(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile("testdata/datafile.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := os.ReadFile("testdata/datafile.sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"data":        data,
+		"data.sig":    sig,
+		"evil":        append([]byte("evil "), data...),
+		"evil.sig":    sig,
+		"unknown.asc": sig,
+	}
+
+	// A clear-signed copy of the data, made with the test key.
+	b, err := os.ReadFile("testdata/private.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cs bytes.Buffer
+	w, err := clearsign.Encode(&cs, key.PrivateKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	w.Close()
+	files["data.txt.asc"] = cs.Bytes()
+
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ring, err := filepath.Abs("testdata/key.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// GNUPGHOME has no trustedkeys.gpg.
+	t.Setenv("GNUPGHOME", dir)
+
+	for _, tt := range []struct {
+		name       string
+		rings      []string
+		args       []string
+		stdin      string
+		wantStatus string
+		wantErr    interface{}
+		wantOutput string
+	}{
+		{name: "good", rings: []string{ring}, args: []string{"data.sig", "data"}, wantStatus: "GOODSIG 160EB556147B7F4E Uroot Test <uroot@uroot.org>"},
+		{name: "implied data", rings: []string{ring}, args: []string{"data.sig"}, wantStatus: "VALIDSIG E11A987AD79D07BB4B03B00D160EB556147B7F4E"},
+		{name: "stdin", rings: []string{ring}, args: []string{"data.sig", "-"}, stdin: string(data), wantStatus: "GOODSIG"},
+		{name: "bad", rings: []string{ring}, args: []string{"evil.sig"}, wantStatus: "BADSIG 160EB556147B7F4E", wantErr: &vfile.ErrBadSignature{}},
+		{name: "clear-signed", rings: []string{ring}, args: []string{"data.txt.asc"}, wantStatus: "GOODSIG", wantOutput: string(data) + "\n"},
+		{name: "no data", rings: []string{ring}, args: []string{"unknown"}, wantErr: new(*os.PathError)},
+		{name: "default keyring", args: []string{"data.sig"}, wantErr: new(*os.PathError)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			wd, _ := os.Getwd()
+			if err := os.Chdir(dir); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(wd)
+
+			output = ""
+			if tt.wantOutput != "" {
+				output = "-"
+			}
+			var stdout, stderr, status bytes.Buffer
+			err := runKeyring(strings.NewReader(tt.stdin), &stdout, &stderr, &status, tt.rings, tt.args)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.As(err, tt.wantErr) {
+				t.Fatalf("runKeyring = %v, want %T", err, tt.wantErr)
+			}
+			if !strings.Contains(status.String(), tt.wantStatus) {
+				t.Errorf("status = %q, want %q", status.String(), tt.wantStatus)
+			}
+			if stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+
+	if err := runKeyring(nil, nil, nil, nil, []string{ring}, nil); err != errKeyringUsage {
+		t.Errorf("runKeyring without SIG = %v, want %v", err, errKeyringUsage)
+	}
+}
@@ -6,7 +6,32 @@
 //
 // Synopsis:
 //
-//	hostname [HOSTNAME]
+//	hostname [-f | -s | -d]
+//	hostname [-F FILE | HOSTNAME]
+//
+// Description:
+//
+//	Without HOSTNAME, hostname prints the hostname. With HOSTNAME, or
+//	FILE, it sets it.
+//
+//	The fully qualified domain name is the canonical name of the
+//	hostname in /etc/hosts, if it has a domain, or else the hostname with
+//	the domain of /etc/resolv.conf, or the first domain of its search
+//	list, added.
+//
+// Options:
+//
+//	-f, --fqdn:   print the fully qualified domain name
+//	-s, --short:  print the hostname up to the first dot
+//	-d, --domain: print the domain of the fully qualified domain name
+//	-F, --file:   set the hostname to the first line of FILE that is not
+//	              empty or a comment, as in /etc/hostname
+//
+// Example:
+//
+//	$ hostname -F /etc/hostname
+//	$ hostname -f
+//	node1.lab.example.com
 //
 // Author:
 //
@@ -14,25 +39,141 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/hosts"
 )
 
-func main() {
-	a := os.Args
-	switch len(a) {
-	case 2:
-		if err := Sethostname(a[1]); err != nil {
-			log.Fatalf("could not set hostname: %v", err)
+var (
+	fqdnFlag   = flag.BoolP("fqdn", "f", false, "print the fully qualified domain name")
+	shortFlag  = flag.BoolP("short", "s", false, "print the hostname up to the first dot")
+	domainFlag = flag.BoolP("domain", "d", false, "print the domain of the fully qualified domain name")
+	fileFlag   = flag.StringP("file", "F", "", "set the hostname from `FILE`")
+)
+
+var errUsage = errors.New("usage: hostname [-f | -s | -d] | [-F FILE | HOSTNAME]")
+
+// The files that names are resolved with, and how the hostname is gotten and
+// set. Tests change them.
+var (
+	hostsFile   = hosts.DefaultPath
+	resolvConf  = "/etc/resolv.conf"
+	getHostname = os.Hostname
+	setHostname = Sethostname
+)
+
+// resolvDomain returns the domain of the resolv.conf file path: that of the
+// domain line, or the first of the search line, or "".
+func resolvDomain(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	var domain, search string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
 		}
-	case 1:
-		hostname, err := os.Hostname()
-		if err != nil {
-			log.Fatalf("could not obtain hostname: %v", err)
+		switch fields[0] {
+		case "domain":
+			domain = fields[1]
+		case "search":
+			search = fields[1]
 		}
-		fmt.Println(hostname)
-	default:
-		log.Fatalf("usage: hostname [HOSTNAME]")
+	}
+	if domain != "" {
+		return domain
+	}
+	return search
+}
+
+// fqdn returns the fully qualified domain name of name.
+func fqdn(name string) (string, error) {
+	f, err := hosts.ReadFile(hostsFile)
+	if err != nil {
+		return "", err
+	}
+	if c := f.Canonical(name); strings.Contains(c, ".") {
+		return c, nil
+	}
+	if strings.Contains(name, ".") {
+		return name, nil
+	}
+	if d := strings.Trim(resolvDomain(resolvConf), "."); d != "" {
+		return name + "." + d, nil
+	}
+	return name, nil
+}
+
+// readHostname reads the hostname of the file path.
+func readHostname(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, l := range strings.Split(string(b), "\n") {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "#") {
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no hostname", path)
+}
+
+func run(out io.Writer, args []string) error {
+	printing := *fqdnFlag || *shortFlag || *domainFlag
+	setting := len(args) == 1 || *fileFlag != ""
+	if len(args) > 1 || setting && (printing || len(args) == 1 && *fileFlag != "") {
+		return errUsage
+	}
+
+	if setting {
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		} else {
+			var err error
+			if name, err = readHostname(*fileFlag); err != nil {
+				return err
+			}
+		}
+		if err := setHostname(name); err != nil {
+			return fmt.Errorf("could not set hostname: %v", err)
+		}
+		return nil
+	}
+
+	name, err := getHostname()
+	if err != nil {
+		return fmt.Errorf("could not obtain hostname: %v", err)
+	}
+	switch {
+	case *shortFlag:
+		name, _, _ = strings.Cut(name, ".")
+	case *fqdnFlag, *domainFlag:
+		if name, err = fqdn(name); err != nil {
+			return err
+		}
+		if *domainFlag {
+			_, name, _ = strings.Cut(name, ".")
+		}
+	}
+	fmt.Fprintln(out, name)
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if err := run(os.Stdout, flag.Args()); err != nil {
+		log.Fatal(err)
 	}
 }
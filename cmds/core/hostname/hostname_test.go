@@ -0,0 +1,76 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	hostsFile = write("hosts", "127.0.0.1 localhost\n127.0.1.1 node1.lab.example.com node1\n")
+	resolvConf = write("resolv.conf", "nameserver 10.0.0.1\nsearch example.com corp.example.com\n")
+	hostnameFile := write("hostname", "# set at provisioning\n\n  node3  \n")
+	emptyFile := write("empty", "# none\n")
+
+	var name string
+	getHostname = func() (string, error) { return name, nil }
+	setHostname = func(n string) error { name = n; return nil }
+
+	for _, tt := range []struct {
+		hostname string
+		fqdn     bool
+		short    bool
+		domain   bool
+		file     string
+		args     []string
+		want     string
+		wantName string
+		wantErr  bool
+	}{
+		{hostname: "node1", want: "node1\n"},
+		{hostname: "node1", fqdn: true, want: "node1.lab.example.com\n"},
+		{hostname: "node1", domain: true, want: "lab.example.com\n"},
+		{hostname: "node2", fqdn: true, want: "node2.example.com\n"},
+		{hostname: "node2.corp", fqdn: true, want: "node2.corp\n"},
+		{hostname: "node2.corp", short: true, want: "node2\n"},
+		{hostname: "localhost", fqdn: true, want: "localhost.example.com\n"},
+		{args: []string{"node4"}, wantName: "node4"},
+		{file: hostnameFile, wantName: "node3"},
+		{file: emptyFile, wantErr: true},
+		{args: []string{"node4"}, fqdn: true, wantErr: true},
+		{args: []string{"node4"}, file: hostnameFile, wantErr: true},
+		{args: []string{"node4", "node5"}, wantErr: true},
+	} {
+		name = tt.hostname
+		*fqdnFlag, *shortFlag, *domainFlag, *fileFlag = tt.fqdn, tt.short, tt.domain, tt.file
+		var out bytes.Buffer
+		err := run(&out, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%+v: run = %v, want error %t", tt, err, tt.wantErr)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%+v: printed %q, want %q", tt, out.String(), tt.want)
+		}
+		if tt.wantName != "" && name != tt.wantName {
+			t.Errorf("%+v: hostname set to %q, want %q", tt, name, tt.wantName)
+		}
+	}
+
+	write("resolv.conf", "search corp.example.com\ndomain example.org\n")
+	if got := resolvDomain(resolvConf); got != "example.org" {
+		t.Errorf("resolvDomain = %q, want the domain line's example.org", got)
+	}
+}
@@ -23,6 +23,13 @@ import (
 // the init process after some initial setup.
 type initCmds struct {
 	cmds []*exec.Cmd
+
+	// shells, if set, run after cmds only if onFailure is not called.
+	shells []*exec.Cmd
+
+	// onFailure, if set, is called when the last of cmds fails, or when
+	// none of them exists.
+	onFailure func(error)
 }
 
 var (
@@ -62,9 +69,17 @@ func main() {
 	// to be used in the rest of init.
 	ic := osInitGo()
 
-	cmdCount := libinit.RunCommands(debug, ic.cmds...)
+	cmdCount, err := libinit.RunCommandsStatus(debug, ic.cmds...)
+	if cmdCount == 0 {
+		err = fmt.Errorf("no suitable executable found in %v", ic.cmds)
+	}
+	if err != nil && ic.onFailure != nil {
+		ic.onFailure(err)
+	} else {
+		cmdCount += libinit.RunCommands(debug, ic.shells...)
+	}
 	if cmdCount == 0 {
-		log.Printf("No suitable executable found in %v", ic.cmds)
+		log.Printf("No suitable executable found in %v", append(ic.cmds, ic.shells...))
 	}
 
 	// We need to reap all children before exiting.
@@ -5,6 +5,8 @@
 package main
 
 import (
+	"context"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -34,6 +36,25 @@ func osInitGo() *initCmds {
 		log.Printf("Deprecation warning: use UROOT_NOHWRNG=1 on kernel cmdline instead of uroot.nohwrng")
 	}
 
+	// With a failure policy, keep the log of init for the failure report.
+	failOpts, onFailure := libinit.FailureOptsFromCmdline(cmdline.NewCmdLine())
+	var initLog *libinit.LogBuffer
+	if onFailure {
+		initLog = libinit.NewLogBuffer(64 << 10)
+		log.SetOutput(io.MultiWriter(os.Stderr, initLog))
+	}
+
+	// Mirror the console early, so that boards with a broken BMC serial
+	// can still be watched over the network. The last 64KiB of output are
+	// kept until the network is up.
+	if opts, ok := libinit.RemoteConsoleOptsFromCmdline(cmdline.NewCmdLine()); ok {
+		if err := libinit.StartRemoteConsole(opts); err != nil {
+			log.Printf("Remote console: %v", err)
+		} else {
+			log.Printf("Remote console: mirroring to %s", opts.URL)
+		}
+	}
+
 	// Turn off job control when test mode is on.
 	ctty := libinit.WithTTYControl(!*test)
 
@@ -42,6 +63,35 @@ func osInitGo() *initCmds {
 		log.Println(err)
 	}
 
+	// Mount the directories shared by the hypervisor, e.g. with qemu's
+	// -virtfs, once the 9pnet_virtio and virtiofs modules are loaded.
+	if opts, ok := libinit.ShareOptsFromCmdline(cmdline.NewCmdLine()); ok {
+		libinit.MountShares(opts)
+	}
+
+	// Block until the clock is plausible, so that TLS certificate checks
+	// in uinit don't fail with "certificate not yet valid".
+	if opts, ok := libinit.TimeSyncOptsFromCmdline(cmdline.NewCmdLine()); ok {
+		switch src, err := libinit.SyncTime(opts); {
+		case err != nil:
+			log.Printf("Time sync: %v", err)
+		case src == libinit.TimeSourceSystem:
+			log.Printf("Time sync: clock is already plausible")
+		default:
+			log.Printf("Time sync: clock set from %s", src)
+		}
+	}
+
+	// Give the machine a stable identity in /etc/machine-id and
+	// $MACHINE_ID before anything that logs or boots with it runs.
+	if opts, ok := libinit.MachineIDOptsFromCmdline(cmdline.NewCmdLine()); ok {
+		if id, src, err := libinit.SetupMachineID(opts); err != nil {
+			log.Printf("Machine ID: %v", err)
+		} else {
+			log.Printf("Machine ID: %s from %s", id, src)
+		}
+	}
+
 	// systemd is "special". If we are supposed to run systemd, we're
 	// going to exec, and if we're going to exec, we're done here.
 	// systemd uber alles.
@@ -69,21 +119,55 @@ func osInitGo() *initCmds {
 	}
 	uinitArgs := libinit.WithArguments(args...)
 
+	uinits := []*exec.Cmd{
+		libinit.Command("/bbin/uinit", ctty, uinitArgs),
+		libinit.Command("/bin/uinit", ctty, uinitArgs),
+		libinit.Command("/buildbin/uinit", ctty, uinitArgs),
+	}
+
+	// A site-specific uinit may be fetched from uroot.uinitbundle=URL. It
+	// must be signed by a key in /etc/uinit.keyring, otherwise the local
+	// uinit runs. As with NTP, the network must already be up.
+	if opts, ok := libinit.BundleOptsFromCmdline(cmdline.NewCmdLine()); ok {
+		if path, err := libinit.FetchBundle(context.Background(), opts); err != nil {
+			log.Printf("uinit bundle: %v", err)
+		} else {
+			log.Printf("uinit bundle: running %s from %s", path, opts.URL)
+			uinits = []*exec.Cmd{libinit.Command(path, ctty, uinitArgs)}
+		}
+	}
+
+	cmds := []*exec.Cmd{
+		// inito is (optionally) created by the u-root command when the
+		// u-root initramfs is merged with an existing initramfs that
+		// has a /init. The name inito means "original /init" There may
+		// be an inito if we are building on an existing initramfs. All
+		// initos need their own pid space.
+		libinit.Command("/inito", libinit.WithCloneFlags(syscall.CLONE_NEWPID), ctty),
+	}
+	cmds = append(cmds, uinits...)
+	shells := []*exec.Cmd{
+		libinit.Command("/bin/defaultsh", ctty),
+		libinit.Command("/bin/sh", ctty),
+	}
+	if !onFailure {
+		return &initCmds{cmds: append(cmds, shells...)}
+	}
+
+	// uroot.onfailure=shell|reboot|poweroff: if uinit fails or there is
+	// none, write a report of the failure with the logs of init and the
+	// kernel to /run/failure, POST it to uroot.failurereport=URL if set,
+	// and run an emergency shell or reboot. The emergency shell asks for
+	// the password whose SHA-256 is in /etc/emergency.sha256, if it
+	// exists, and reboots after uroot.failuretimeout (5m by default).
 	return &initCmds{
-		cmds: []*exec.Cmd{
-			// inito is (optionally) created by the u-root command when the
-			// u-root initramfs is merged with an existing initramfs that
-			// has a /init. The name inito means "original /init" There may
-			// be an inito if we are building on an existing initramfs. All
-			// initos need their own pid space.
-			libinit.Command("/inito", libinit.WithCloneFlags(syscall.CLONE_NEWPID), ctty),
-
-			libinit.Command("/bbin/uinit", ctty, uinitArgs),
-			libinit.Command("/bin/uinit", ctty, uinitArgs),
-			libinit.Command("/buildbin/uinit", ctty, uinitArgs),
-
-			libinit.Command("/bin/defaultsh", ctty),
-			libinit.Command("/bin/sh", ctty),
+		cmds:   cmds,
+		shells: shells,
+		onFailure: func(err error) {
+			log.Printf("Boot failure: %v", err)
+			if err := libinit.HandleFailure(failOpts, err, initLog); err != nil {
+				log.Printf("Boot failure: %v", err)
+			}
 		},
 	}
 }
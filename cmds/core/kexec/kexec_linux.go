@@ -16,11 +16,13 @@
 //  -d, --debug                Print debug info (default true)
 //  -e, --exec                 Execute a currently loaded kernel
 //  -x, --extra string         Add a cpio containing extra files
+//      --inherit string       Comma separated parameters of the running kernel to pass on, e.g. console,ip,rd.*
 //      --initramfs string     Use file as the kernel's initial ramdisk
 //  -i, --initrd string        Use file as the kernel's initial ramdisk
 //  -l, --load                 Load the new kernel into the current kernel
 //  -L, --loadsyscall          Use the kexec load syscall (not file_load) (default true)
 //      --module stringArray   Load multiboot module with command line args (e.g --module="mod arg1")
+//      --no-efi               Boot the kernel without EFI, with kexec_load (x86 only)
 //  -p, --purgatory string     pick a purgatory, use '-p xyz' to get a list (default "default")
 //      --reuse-cmdline        Use the kernel command line from running system
 
@@ -45,6 +47,7 @@ import (
 
 type options struct {
 	cmdline      string
+	inherit      string
 	debug        bool
 	dtb          string
 	exec         bool
@@ -55,6 +58,7 @@ type options struct {
 	mmapInitrd   bool
 	mmapKernel   bool
 	modules      []string
+	noEFI        bool
 	purgatory    string
 	reuseCmdline bool
 }
@@ -63,6 +67,7 @@ func registerFlags() *options {
 	o := &options{}
 	flag.StringVarP(&o.cmdline, "cmdline", "c", "", "Append to the kernel command line")
 	flag.StringVar(&o.cmdline, "append", "", "Append to the kernel command line")
+	flag.StringVar(&o.inherit, "inherit", "", "Comma separated parameters of the running kernel to pass on, e.g. console,ip,rd.*")
 	flag.BoolVarP(&o.debug, "debug", "d", false, "Print debug info")
 	flag.StringVar(&o.dtb, "dtb", "", "FILE used as the flatten device tree blob")
 	flag.BoolVarP(&o.exec, "exec", "e", false, "Execute a currently loaded kernel")
@@ -71,9 +76,10 @@ func registerFlags() *options {
 	flag.StringVar(&o.initramfs, "initramfs", "", "Use file as the kernel's initial ramdisk")
 	flag.BoolVarP(&o.load, "load", "l", false, "Load the new kernel into the current kernel")
 	flag.BoolVarP(&o.loadSyscall, "loadsyscall", "L", false, "Use the kexec_load syscall (not kexec_file_load)")
-	flag.BoolVar(&o.mmapInitrd, "mmap-initrd", true, "Mmap initrd file into virtual buffer, other than directly reading it (Only supported in Arm64 classic load mode for now)")
+	flag.BoolVar(&o.mmapInitrd, "mmap-initrd", true, "Mmap initrd file into virtual buffer, other than directly reading it (Only supported in classic load mode for now)")
 	flag.BoolVar(&o.mmapKernel, "mmap-kernel", true, "Mmap kernel file into virtual buffer, other than directly reading it (Only supported in Arm64 classi load mode for now)")
 	flag.StringArrayVar(&o.modules, "module", nil, `Load multiboot module with command line args (e.g --module="mod arg1")`)
+	flag.BoolVar(&o.noEFI, "no-efi", false, "Boot the kernel without EFI, with kexec_load (x86 only)")
 
 	// This is broken out as it is almost never to be used. But it is valueable, nonetheless.
 	flag.StringVarP(&o.purgatory, "purgatory", "p", "default", "picks a purgatory only if loading a Linux kernel with kexec_load, use '-p xyz' to get a list")
@@ -162,14 +168,19 @@ func main() {
 				Kernel:      uio.NewLazyFile(kernelpath),
 				Initrd:      i,
 				Cmdline:     newCmdline,
-				LoadSyscall: opts.loadSyscall,
+				LoadSyscall: opts.loadSyscall || opts.noEFI,
 				KexecOpts: linux.KexecOptions{
 					DTB:        dtb,
 					MmapKernel: opts.mmapKernel,
 					MmapRamfs:  opts.mmapInitrd,
+					NoEFI:      opts.noEFI,
 				},
 			}
 		}
+		if opts.inherit != "" {
+			p := &cmdline.Policy{Inherit: cmdline.SplitList(opts.inherit)}
+			boot.EditCmdlines(p, cmdline.NewCmdLine(), image)
+		}
 		if err := image.Load(opts.debug); err != nil {
 			log.Fatal(err)
 		}
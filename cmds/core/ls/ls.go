@@ -11,6 +11,7 @@
 // Options:
 //
 //	-l: long form
+//	-n: long form, with the uid and gid instead of the owner and group names
 //	-Q: quoted
 //	-R: equivalent to findutil's find
 //	-F: append indicator (one of */=>@|) to entries
@@ -39,6 +40,7 @@ var (
 	human     = flag.BoolP("human-readable", "h", false, "human readable sizes")
 	directory = flag.BoolP("directory", "d", false, "list directories but not their contents")
 	long      = flag.BoolP("long", "l", false, "long form")
+	numeric   = flag.BoolP("numeric-uid-gid", "n", false, "long form, with numeric user and group ids")
 	quoted    = flag.BoolP("quote-name", "Q", false, "quoted")
 	recurse   = flag.BoolP("recursive", "R", false, "equivalent to findutil's find")
 	classify  = flag.BoolP("classify", "F", false, "append indicator (one of */=>@|) to entries")
@@ -184,8 +186,8 @@ func list(w io.Writer, names []string) error {
 	if *quoted {
 		s = ls.QuotedStringer{}
 	}
-	if *long {
-		s = ls.LongStringer{Human: *human, Name: s}
+	if *long || *numeric {
+		s = ls.LongStringer{Human: *human, Numeric: *numeric, Name: s}
 	}
 	// Is a name a directory? If so, list it in its own section.
 	prefix := len(names) > 1
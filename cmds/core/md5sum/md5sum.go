@@ -3,6 +3,26 @@
 // license that can be found in the LICENSE file.
 
 // md5sum prints an md5 hash generated from file contents.
+//
+// Synopsis:
+//
+//	md5sum [FILE...]
+//	md5sum -c [OPTIONS] [MANIFEST...]
+//
+// Description:
+//
+//	With -c, the files listed in the MANIFESTs, or stdin, are checked, and
+//	md5sum exits with 1 if any of them does not match. Files are hashed in
+//	parallel.
+//
+// Options:
+//
+//	-c, --check:      check the files listed in the manifests
+//	--ignore-missing: with -c, do not fail for missing files
+//	--quiet:          with -c, do not print OK files
+//	--status:         with -c, print nothing; the exit status tells
+//	--strict:         with -c, fail for improperly formatted lines
+//	-w, --warn:       with -c, warn about improperly formatted lines
 package main
 
 import (
@@ -13,13 +33,27 @@ import (
 	"log"
 	"os"
 
+	"github.com/u-root/u-root/pkg/hashsum"
 	"github.com/u-root/u-root/pkg/uroot/util"
 )
 
-var usage = "md5sum: md5sum <File Name>"
+var usage = "md5sum: md5sum [-c [--ignore-missing] [--quiet] [--status] [--strict] [-w]] <File Name>..."
+
+var (
+	check     bool
+	checkOpts hashsum.CheckOpts
+)
 
 func init() {
 	flag.Usage = util.Usage(flag.Usage, usage)
+	flag.BoolVar(&check, "c", false, "check the files listed in the manifests")
+	flag.BoolVar(&check, "check", false, "check the files listed in the manifests")
+	flag.BoolVar(&checkOpts.IgnoreMissing, "ignore-missing", false, "do not fail for missing files")
+	flag.BoolVar(&checkOpts.Quiet, "quiet", false, "do not print OK files")
+	flag.BoolVar(&checkOpts.Status, "status", false, "print nothing; the exit status tells")
+	flag.BoolVar(&checkOpts.Strict, "strict", false, "fail for improperly formatted lines")
+	flag.BoolVar(&checkOpts.Warn, "w", false, "warn about improperly formatted lines")
+	flag.BoolVar(&checkOpts.Warn, "warn", false, "warn about improperly formatted lines")
 }
 
 func calculateMd5Sum(r io.Reader) ([]byte, error) {
@@ -31,8 +65,6 @@ func calculateMd5Sum(r io.Reader) ([]byte, error) {
 }
 
 func md5Sum(w io.Writer, r io.Reader, args ...string) error {
-	var err error
-
 	if len(args) == 0 {
 		h, err := calculateMd5Sum(r)
 		if err != nil {
@@ -40,30 +72,41 @@ func md5Sum(w io.Writer, r io.Reader, args ...string) error {
 			return err
 		}
 		_, err = fmt.Fprintf(w, "%x\n", h)
+		return err
+	}
+
+	var err error
+	h := &hashsum.Hasher{New: md5.New, Stdin: r}
+	h.Sum(args, func(res hashsum.Result) {
 		if err != nil {
-			return err
-		}
-	} else {
-		fileDesc, err := os.Open(args[0])
-		if err != nil {
-			return err
-		}
-		defer fileDesc.Close()
-		h, err := calculateMd5Sum(fileDesc)
-		if err != nil {
-			return err
+			return
 		}
-		_, err = fmt.Fprintf(w, "%x %s\n", h, args[0])
-		if err != nil {
-			return err
+		if err = res.Err; err == nil {
+			_, err = fmt.Fprintf(w, "%x %s\n", res.Sum, res.Name)
 		}
-	}
+	})
 	return err
 }
 
+func checkMd5Sums(w, stderr io.Writer, r io.Reader, manifests ...string) error {
+	if len(manifests) == 0 {
+		manifests = []string{"-"}
+	}
+	h := &hashsum.Hasher{New: md5.New, Stdin: r}
+	return h.CheckFiles(w, stderr, manifests, checkOpts)
+}
+
 func main() {
 	flag.Parse()
-	if err := md5Sum(os.Stdout, os.Stdin, flag.Args()...); err != nil {
+	run := md5Sum
+	if check {
+		run = func(w io.Writer, r io.Reader, args ...string) error {
+			return checkMd5Sums(w, os.Stderr, r, args...)
+		}
+	}
+	if err := run(os.Stdout, os.Stdin, flag.Args()...); err == hashsum.ErrSilent {
+		os.Exit(1)
+	} else if err != nil {
 		log.Fatal(err)
 	}
 }
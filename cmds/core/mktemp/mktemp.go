@@ -11,6 +11,10 @@
 //	Create  a  temporary  file or directory, safely, and print its name.  TEMPLATE must contain at least 3 consecutive 'X's in last component.  If TEMPLATE is not specified, use tmp.XXXXXXXXXX, and --tmpdir is implied.  Files are
 //	created u+rw, and directories u+rwx, minus umask restrictions.
 //
+//	A TEMPLATE without a slash is relative to the temporary directory; one
+//	with a slash is used as it is, unless -p or -t is given. Text after the
+//	last X's of TEMPLATE is kept as a suffix.
+//
 //	-d, --directory
 //	       create a directory, not a file
 //
@@ -26,12 +30,18 @@
 //	-p DIR, --tmpdir[=DIR]
 //	       interpret TEMPLATE relative to DIR; if DIR is not specified, use $TMPDIR if set, else /tmp.  With this option, TEMPLATE must not be an absolute name; unlike with -t, TEMPLATE may contain  slashes,  but  mktemp  creates
 //	       only the final component
+//
+//	-t
+//	       interpret TEMPLATE as a single file name component, relative to the temporary directory
 package main
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	flag "github.com/spf13/pflag"
@@ -41,7 +51,7 @@ type mktempflags struct {
 	d      bool
 	u      bool
 	q      bool
-	v      bool
+	t      bool
 	prefix string
 	suffix string
 	dir    string
@@ -52,9 +62,10 @@ var flags mktempflags
 func init() {
 	flag.BoolVarP(&flags.d, "directory", "d", false, "Make a directory")
 	flag.BoolVarP(&flags.u, "dry-run", "u", false, "Do everything save the actual create")
-	flag.BoolVarP(&flags.v, "quiet", "q", false, "Quiet: show no errors")
+	flag.BoolVarP(&flags.q, "quiet", "q", false, "Quiet: show no errors")
+	flag.BoolVarP(&flags.t, "t", "t", false, "Make TEMPLATE, a single file name component, in the tmp directory")
 	flag.StringVarP(&flags.prefix, "prefix", "s", "", "add a prefix -- the s flag is for compatibility with GNU mktemp")
-	flag.StringVarP(&flags.suffix, "suffix", "", "", "add a suffix to the prefix (rather than the end of the mktemp file)")
+	flag.StringVarP(&flags.suffix, "suffix", "", "", "add a suffix to the end of the mktemp file")
 	flag.StringVarP(&flags.dir, "tmpdir", "p", "", "Tmp directory to use. If this is not set, TMPDIR is used, else /tmp")
 }
 
@@ -62,24 +73,95 @@ func usage() {
 	log.Fatalf("Usage: mktemp [options] [template]\n%v", flag.CommandLine.FlagUsages())
 }
 
-func mktemp() (string, error) {
-	if flags.dir == "" {
-		flags.dir = os.Getenv("TMPDIR")
+// The characters that replace the X's of a template.
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// split splits template into the text before its last X's, their number,
+// and the text after them.
+func split(template string) (prefix string, x int, suffix string, err error) {
+	base := template[strings.LastIndex(template, "/")+1:]
+	end := strings.LastIndex(base, "X") + 1
+	start := strings.LastIndexFunc(base[:end], func(r rune) bool { return r != 'X' }) + 1
+	if end-start < 3 {
+		return "", 0, "", fmt.Errorf("too few X's in template %q", template)
 	}
+	i := len(template) - len(base)
+	return template[:i+start], end - start, base[end:], nil
+}
 
-	if flags.u {
-		if !flags.q {
-			log.Printf("Not doing anything but dry-run is an inherently unsafe concept")
-		}
-		return "", nil
+// path returns the template of the file to make, with its directory.
+func path(template string) (string, error) {
+	if strings.Contains(flags.suffix, "/") {
+		return "", fmt.Errorf("invalid suffix %q, contains directory separator", flags.suffix)
+	}
+	inTmp := flags.dir != "" || flags.t || template == ""
+	if template == "" {
+		template = "tmp.XXXXXXXXXX"
+	}
+	template = flags.prefix + template + flags.suffix
+	if !inTmp && strings.Contains(template, "/") {
+		return template, nil
+	}
+	if flags.t && strings.Contains(template, "/") {
+		return "", fmt.Errorf("invalid template %q with -t, contains directory separator", template)
+	}
+	if filepath.IsAbs(template) {
+		return "", fmt.Errorf("invalid template %q with --tmpdir, template must not be absolute", template)
+	}
+	dir := flags.dir
+	if dir == "" {
+		dir = os.Getenv("TMPDIR")
+	}
+	if dir == "" {
+		dir = "/tmp"
+	}
+	return filepath.Join(dir, template), nil
+}
+
+func mktemp(template string) (string, error) {
+	template, err := path(template)
+	if err != nil {
+		return "", err
+	}
+	prefix, x, suffix, err := split(template)
+	if err != nil {
+		return "", err
 	}
 
-	if flags.d {
-		d, err := os.MkdirTemp(flags.dir, flags.prefix)
-		return d, err
+	if flags.u && !flags.q {
+		log.Printf("Not doing anything but dry-run is an inherently unsafe concept")
 	}
-	f, err := os.CreateTemp(flags.dir, flags.prefix)
-	return f.Name(), err
+	b := make([]byte, x)
+	for try := 0; try < 100; try++ {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for i := range b {
+			b[i] = letters[int(b[i])%len(letters)]
+		}
+		name := prefix + string(b) + suffix
+		switch {
+		case flags.u:
+			_, err = os.Lstat(name)
+			if os.IsNotExist(err) {
+				return name, nil
+			}
+			if err == nil {
+				err = os.ErrExist
+			}
+		case flags.d:
+			err = os.Mkdir(name, 0o700)
+		default:
+			var f *os.File
+			if f, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600); err == nil {
+				err = f.Close()
+			}
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return name, err
+		}
+	}
+	return "", fmt.Errorf("cannot make a unique name from template %q", template)
 }
 
 func main() {
@@ -87,20 +169,21 @@ func main() {
 
 	args := flag.Args()
 
+	var template string
 	switch len(args) {
 	case 1:
-		// To make this work, we strip the trailing X's, since the Go runtime doesn't work
-		// as old school mktemp(3) does. Just split on the first X.
-		// If they also specified a suffix, well, add that to the prefix I guess.
-		flags.prefix = flags.prefix + strings.Split(args[0], "X")[0] + flags.suffix
+		template = args[0]
 	case 0:
 	default:
 		usage()
 	}
 
-	fileName, err := mktemp()
-	if err != nil && !flags.q {
-		log.Fatalf("%v", err)
+	fileName, err := mktemp(template)
+	if err != nil {
+		if !flags.q {
+			log.Printf("%v", err)
+		}
+		os.Exit(1)
 	}
 	fmt.Println(fileName)
 }
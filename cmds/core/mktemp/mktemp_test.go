@@ -7,6 +7,8 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -16,6 +18,7 @@ import (
 type test struct {
 	flags      []string
 	out        string
+	outSuffix  string
 	stdErr     string
 	exitStatus int
 }
@@ -42,7 +45,8 @@ func TestMkTemp(t *testing.T) {
 		},
 		{
 			flags:      []string{"foo.XXXXX", "--suffix", "baz"},
-			out:        "/tmp/foo.baz",
+			out:        "/tmp/foo.",
+			outSuffix:  "baz\n",
 			stdErr:     "",
 			exitStatus: 0,
 		},
@@ -67,6 +71,10 @@ func TestMkTemp(t *testing.T) {
 				t.Errorf("stdout got:\n%s\nwant starting with:\n%s", out.String(), tt.out)
 			}
 
+			if !strings.HasSuffix(out.String(), tt.outSuffix) {
+				t.Errorf("stdout got:\n%s\nwant ending with:\n%s", out.String(), tt.outSuffix)
+			}
+
 			if !strings.HasPrefix(stdErr.String(), tt.stdErr) {
 				t.Errorf("stderr got:\n%s\nwant starting with:\n%s", stdErr.String(), tt.stdErr)
 			}
@@ -78,6 +86,60 @@ func TestMkTemp(t *testing.T) {
 	}
 }
 
+func TestTemplate(t *testing.T) {
+	dir := t.TempDir()
+	// mktemp only makes the last component.
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		flags    mktempflags
+		template string
+		prefix   string
+		suffix   string
+		wantErr  bool
+	}{
+		{template: dir + "/fooXXX", prefix: dir + "/foo"},
+		{template: dir + "/a.XXXX.txt", prefix: dir + "/a.", suffix: ".txt"},
+		{template: "sub/XXXXXX", flags: mktempflags{dir: dir}, prefix: dir + "/sub/"},
+		{template: "XXXXXX", flags: mktempflags{dir: dir, prefix: "p.", suffix: ".s"}, prefix: dir + "/p.", suffix: ".s"},
+		{flags: mktempflags{dir: dir}, prefix: dir + "/tmp."},
+		{template: "fooXX", flags: mktempflags{dir: dir}, wantErr: true},
+		{template: dir + "/XXXfoo/bar", wantErr: true},
+		{template: "/abs/XXXX", flags: mktempflags{dir: dir}, wantErr: true},
+		{template: "a/XXXX", flags: mktempflags{t: true}, wantErr: true},
+		{template: "XXXX", flags: mktempflags{dir: dir, suffix: "a/b"}, wantErr: true},
+	} {
+		flags = tt.flags
+		name, err := mktemp(tt.template)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("mktemp(%q, %+v) = %q, want an error", tt.template, tt.flags, name)
+			}
+			continue
+		}
+		if err != nil || !strings.HasPrefix(name, tt.prefix) || !strings.HasSuffix(name, tt.suffix) || len(name) < len(tt.prefix)+len(tt.suffix)+3 {
+			t.Errorf("mktemp(%q, %+v) = %q, %v, want %q...%q", tt.template, tt.flags, name, err, tt.prefix, tt.suffix)
+			continue
+		}
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("mktemp(%q, %+v) did not make the file: %v", tt.template, tt.flags, err)
+		}
+	}
+
+	flags = mktempflags{d: true}
+	name, err := mktemp(dir + "/d.XXXXXX")
+	if fi, serr := os.Stat(name); err != nil || serr != nil || !fi.IsDir() || fi.Mode().Perm() != 0o700 {
+		t.Errorf("mktemp -d = %q, %v, want a directory", name, err)
+	}
+	flags = mktempflags{u: true, q: true}
+	name, err = mktemp(dir + "/u.XXXXXX")
+	if _, serr := os.Stat(name); err != nil || !os.IsNotExist(serr) {
+		t.Errorf("mktemp -u = %q, %v, want a name that does not exist", name, err)
+	}
+	flags = mktempflags{}
+}
+
 func TestMain(m *testing.M) {
 	testutil.Run(m, main)
 }
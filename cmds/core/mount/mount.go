@@ -11,6 +11,16 @@
 //
 //	mount [-r] [-o options] [-t FSTYPE] DEV PATH
 //
+// Description:
+//
+//	For NFS (DEV is host:/export) and CIFS (DEV is //host/share), the
+//	server is resolved and passed to the kernel as addr= or ip=. NFSv4
+//	mounts also get clientaddr=, and NFSv2 and v3 mounts get nolock
+//	unless lock is given, since there is no rpc.statd. For CIFS,
+//	credentials=FILE and user=NAME%PASSWORD are expanded, and $PASSWD is
+//	used as the password if none is given. The type is inferred from DEV
+//	if -t is not given.
+//
 // Options:
 //
 //	-r: read only
@@ -25,6 +35,7 @@ import (
 
 	"github.com/u-root/u-root/pkg/mount"
 	"github.com/u-root/u-root/pkg/mount/loop"
+	"github.com/u-root/u-root/pkg/ulog"
 	"golang.org/x/sys/unix"
 )
 
@@ -49,6 +60,7 @@ var (
 
 func init() {
 	flag.Var(&options, "o", "Comma separated list of mount options")
+	flag.Var(ulog.TraceFlag{}, "trace", ulog.TraceUsage)
 }
 
 func loopSetup(filename string) (loopDevice string, err error) {
@@ -136,6 +148,12 @@ func main() {
 	if *ro {
 		flags |= unix.MS_RDONLY
 	}
+	if *fsType == "" {
+		*fsType = guessNetFS(dev)
+	}
+	if dev, data, err = netFSOptions(*fsType, dev, data); err != nil {
+		log.Fatalf("mount: %v", err)
+	}
 	if *fsType == "" {
 		if _, err := mount.TryMount(dev, path, strings.Join(data, ","), flags); err != nil {
 			log.Fatalf("%v", err)
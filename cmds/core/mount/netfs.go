@@ -0,0 +1,213 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// The kernel NFS and CIFS clients do not resolve host names or read
+// credential files; mount.nfs and mount.cifs do that before calling
+// mount(2). These helpers do the same work.
+
+var (
+	lookupIP = net.LookupIP
+
+	// localAddr returns the address the kernel would use to reach ip.
+	// No packets are sent.
+	localAddr = func(ip net.IP) (net.IP, error) {
+		c, err := net.Dial("udp", net.JoinHostPort(ip.String(), "2049"))
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		return c.LocalAddr().(*net.UDPAddr).IP, nil
+	}
+)
+
+// guessNetFS returns the file system type implied by a source such as
+// host:/export or //host/share, or "" if there is none.
+func guessNetFS(dev string) string {
+	switch {
+	case strings.HasPrefix(dev, "//"):
+		return "cifs"
+	case strings.Contains(dev, ":/"):
+		return "nfs"
+	}
+	return ""
+}
+
+func resolve(host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	ips, err := lookupIP(host)
+	if err != nil {
+		return nil, fmt.Errorf("resolving server %q: %v", host, err)
+	}
+	// Prefer IPv4, which every NFS and SMB server speaks.
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip, nil
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("resolving server %q: no addresses", host)
+	}
+	return ips[0], nil
+}
+
+// hasOption reports whether any of names is set in data.
+func hasOption(data []string, names ...string) bool {
+	for _, d := range data {
+		k := strings.SplitN(d, "=", 2)[0]
+		for _, n := range names {
+			if k == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nfsOptions checks an NFS source of the form host:/export or
+// [v6addr]:/export and adds the addr= and clientaddr= options the kernel
+// requires. Since there is no rpc.statd, NFSv2 and v3 mounts get nolock
+// unless locking was asked for.
+func nfsOptions(fsType, dev string, data []string) (string, []string, error) {
+	i := strings.LastIndex(dev, ":/")
+	if i <= 0 {
+		return "", nil, fmt.Errorf("NFS source %q is not of the form host:/path", dev)
+	}
+	host, export := dev[:i], dev[i+1:]
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	ip, err := resolve(host)
+	if err != nil {
+		return "", nil, err
+	}
+	if !hasOption(data, "addr") {
+		data = append(data, "addr="+ip.String())
+	}
+
+	v4 := fsType == "nfs4"
+	for _, d := range data {
+		if strings.HasPrefix(d, "vers=4") || strings.HasPrefix(d, "nfsvers=4") {
+			v4 = true
+		}
+	}
+	if v4 {
+		// The server calls back to clientaddr to recall delegations.
+		if !hasOption(data, "clientaddr") {
+			local, err := localAddr(ip)
+			if err != nil {
+				return "", nil, fmt.Errorf("finding local address for %v: %v", ip, err)
+			}
+			data = append(data, "clientaddr="+local.String())
+		}
+	} else if !hasOption(data, "lock", "nolock") {
+		data = append(data, "nolock")
+	}
+
+	// The kernel wants brackets around IPv6 addresses in the source.
+	if ip.To4() == nil {
+		host = "[" + ip.String() + "]"
+	}
+	return host + ":" + export, data, nil
+}
+
+// readCredentials reads a mount.cifs credentials file, with lines of the
+// form username=, password= and domain=.
+func readCredentials(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var data []string
+	for _, l := range strings.Split(string(b), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s: bad line %q", path, l)
+		}
+		switch k := strings.TrimSpace(kv[0]); k {
+		case "username", "user":
+			data = append(data, "username="+kv[1])
+		case "password", "pass":
+			data = append(data, "password="+kv[1])
+		case "domain", "dom", "workgroup":
+			data = append(data, "domain="+kv[1])
+		default:
+			return nil, fmt.Errorf("%s: unknown key %q", path, k)
+		}
+	}
+	return data, nil
+}
+
+// cifsOptions checks a CIFS source of the form //host/share[/path],
+// expands credentials= and user=name%password, and adds the ip= option the
+// kernel requires. Without a password option, $PASSWD is used if set.
+func cifsOptions(dev string, data []string) (string, []string, error) {
+	unc := strings.TrimPrefix(strings.ReplaceAll(dev, `\`, "/"), "//")
+	parts := strings.SplitN(unc, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", nil, fmt.Errorf("CIFS source %q is not of the form //host/share", dev)
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(parts[0], "["), "]")
+
+	var out []string
+	for _, d := range data {
+		kv := strings.SplitN(d, "=", 2)
+		switch {
+		case kv[0] == "credentials" && len(kv) == 2:
+			creds, err := readCredentials(kv[1])
+			if err != nil {
+				return "", nil, err
+			}
+			out = append(out, creds...)
+		case (kv[0] == "user" || kv[0] == "username") && len(kv) == 2:
+			if name, pass, ok := strings.Cut(kv[1], "%"); ok {
+				out = append(out, "username="+name, "password="+pass)
+			} else {
+				out = append(out, "username="+kv[1])
+			}
+		default:
+			out = append(out, d)
+		}
+	}
+	if p, ok := os.LookupEnv("PASSWD"); ok && hasOption(out, "username") && !hasOption(out, "password", "guest") {
+		out = append(out, "password="+p)
+	}
+	if !hasOption(out, "ip", "addr") {
+		ip, err := resolve(host)
+		if err != nil {
+			return "", nil, err
+		}
+		out = append(out, "ip="+ip.String())
+	}
+	return "//" + unc, out, nil
+}
+
+// netFSOptions adjusts the source and options of NFS and CIFS mounts. Other
+// file systems are returned as they are.
+func netFSOptions(fsType, dev string, data []string) (string, []string, error) {
+	switch fsType {
+	case "nfs", "nfs4":
+		return nfsOptions(fsType, dev, data)
+	case "cifs", "smb3":
+		return cifsOptions(dev, data)
+	}
+	return dev, data, nil
+}
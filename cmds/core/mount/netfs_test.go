@@ -0,0 +1,90 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNetFSOptions(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "filer":
+			return []net.IP{net.ParseIP("2001:db8::2"), net.ParseIP("192.0.2.2")}, nil
+		case "v6filer":
+			return []net.IP{net.ParseIP("2001:db8::3")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	localAddr = func(net.IP) (net.IP, error) { return net.ParseIP("192.0.2.100"), nil }
+	t.Setenv("PASSWD", "")
+	os.Unsetenv("PASSWD")
+
+	creds := filepath.Join(t.TempDir(), "creds")
+	if err := os.WriteFile(creds, []byte("# boot share\nusername=boot\npassword=s3cret\ndomain=LAB\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		fsType, dev string
+		data        []string
+		wantDev     string
+		wantData    []string
+		wantErr     bool
+	}{
+		{fsType: "ext4", dev: "/dev/sda1", data: []string{"discard"}, wantDev: "/dev/sda1", wantData: []string{"discard"}},
+		{fsType: "nfs", dev: "filer:/srv/root", wantDev: "filer:/srv/root", wantData: []string{"addr=192.0.2.2", "nolock"}},
+		{fsType: "nfs", dev: "192.0.2.9:/srv", data: []string{"vers=3", "lock"}, wantDev: "192.0.2.9:/srv", wantData: []string{"vers=3", "lock", "addr=192.0.2.9"}},
+		{fsType: "nfs", dev: "filer:/srv", data: []string{"vers=4.2"}, wantDev: "filer:/srv", wantData: []string{"vers=4.2", "addr=192.0.2.2", "clientaddr=192.0.2.100"}},
+		{fsType: "nfs4", dev: "v6filer:/", wantDev: "[2001:db8::3]:/", wantData: []string{"addr=2001:db8::3", "clientaddr=192.0.2.100"}},
+		{fsType: "nfs4", dev: "[2001:db8::4]:/srv", data: []string{"addr=2001:db8::5", "clientaddr=10.0.0.1"}, wantDev: "[2001:db8::4]:/srv", wantData: []string{"addr=2001:db8::5", "clientaddr=10.0.0.1"}},
+		{fsType: "nfs", dev: "filer", wantErr: true},
+		{fsType: "nfs", dev: "unknown:/srv", wantErr: true},
+		{fsType: "cifs", dev: "//filer/boot", data: []string{"credentials=" + creds, "vers=3.0"}, wantDev: "//filer/boot", wantData: []string{"username=boot", "password=s3cret", "domain=LAB", "vers=3.0", "ip=192.0.2.2"}},
+		{fsType: "cifs", dev: `\\filer\boot\images`, data: []string{"user=boot%pw", "ip=192.0.2.7"}, wantDev: "//filer/boot/images", wantData: []string{"username=boot", "password=pw", "ip=192.0.2.7"}},
+		{fsType: "cifs", dev: "//192.0.2.8/pub", data: []string{"guest"}, wantDev: "//192.0.2.8/pub", wantData: []string{"guest", "ip=192.0.2.8"}},
+		{fsType: "cifs", dev: "//filer", wantErr: true},
+		{fsType: "cifs", dev: "//filer/boot", data: []string{"credentials=/does/not/exist"}, wantErr: true},
+	} {
+		dev, data, err := netFSOptions(tt.fsType, tt.dev, tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("netFSOptions(%q, %q, %q) = %v, want error %v", tt.fsType, tt.dev, tt.data, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if dev != tt.wantDev || !reflect.DeepEqual(data, tt.wantData) {
+			t.Errorf("netFSOptions(%q, %q, %q) = %q, %q, want %q, %q", tt.fsType, tt.dev, tt.data, dev, data, tt.wantDev, tt.wantData)
+		}
+	}
+
+	t.Setenv("PASSWD", "fromenv")
+	_, data, err := netFSOptions("cifs", "//filer/boot", []string{"username=boot"})
+	if want := []string{"username=boot", "password=fromenv", "ip=192.0.2.2"}; err != nil || !reflect.DeepEqual(data, want) {
+		t.Errorf("netFSOptions with $PASSWD = %q, %v, want %q", data, err, want)
+	}
+}
+
+func TestGuessNetFS(t *testing.T) {
+	for dev, want := range map[string]string{
+		"/dev/sda1":     "",
+		"filer:/srv":    "nfs",
+		"[::1]:/srv":    "nfs",
+		"//filer/share": "cifs",
+	} {
+		if got := guessNetFS(dev); got != want {
+			t.Errorf("guessNetFS(%q) = %q, want %q", dev, got, want)
+		}
+	}
+}
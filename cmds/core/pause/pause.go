@@ -0,0 +1,122 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+// Wait for a signal.
+//
+// Synopsis:
+//
+//	pause [-s SIGNALS] [-t DURATION]
+//
+// Description:
+//
+//	pause waits until it receives one of SIGNALS, prints its name and
+//	exits successfully, like sigwait. Scripts can wait for events this
+//	way instead of polling.
+//
+// Options:
+//
+//	-s: comma separated list of signals to wait for, by name or number
+//	    (default HUP,INT,TERM,USR1,USR2)
+//	-t: give up after DURATION and exit with 1 (default: wait forever)
+//
+// Examples:
+//
+//	sig=$(pause -s USR1,USR2)
+//	pause -s TERM -t 30s
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	signals = flag.String("s", "HUP,INT,TERM,USR1,USR2", "comma separated list of signals to wait for")
+	timeout = flag.Duration("t", 0, "give up after this long (default: wait forever)")
+
+	errTimeout = errors.New("timed out")
+)
+
+// parseSignals parses a comma separated list of signal names, with or
+// without SIG, or numbers.
+func parseSignals(s string) ([]os.Signal, error) {
+	var sigs []os.Signal
+	for _, f := range strings.Split(s, ",") {
+		if n, err := strconv.Atoi(f); err == nil && n > 0 {
+			sigs = append(sigs, syscall.Signal(n))
+			continue
+		}
+		name := strings.ToUpper(f)
+		if !strings.HasPrefix(name, "SIG") {
+			name = "SIG" + name
+		}
+		sig := unix.SignalNum(name)
+		if sig == 0 {
+			return nil, fmt.Errorf("invalid signal %q", f)
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// signalName returns the name of sig without SIG, e.g. USR1, or its number
+// if it has no name.
+func signalName(sig os.Signal) string {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return sig.String()
+	}
+	if name := unix.SignalName(s); name != "" {
+		return strings.TrimPrefix(name, "SIG")
+	}
+	return strconv.Itoa(int(s))
+}
+
+// pause waits for a signal on c, and prints its name to w. It returns
+// errTimeout if none came in timeout, unless timeout is 0.
+func pause(w io.Writer, c <-chan os.Signal, timeout time.Duration) error {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case sig := <-c:
+		_, err := fmt.Fprintln(w, signalName(sig))
+		return err
+	case <-expired:
+		return errTimeout
+	}
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		log.Fatal("Usage: pause [-s SIGNALS] [-t DURATION]")
+	}
+	sigs, err := parseSignals(*signals)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	if err := pause(os.Stdout, c, *timeout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSignals(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    []os.Signal
+		wantErr bool
+	}{
+		{in: "USR1", want: []os.Signal{syscall.SIGUSR1}},
+		{in: "sigterm,hup,10", want: []os.Signal{syscall.SIGTERM, syscall.SIGHUP, syscall.Signal(10)}},
+		{in: "NOSUCH", wantErr: true},
+		{in: "", wantErr: true},
+	} {
+		got, err := parseSignals(tt.in)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSignals(%q) = %v, %v, want %v, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPause(t *testing.T) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR2)
+	defer signal.Stop(c)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := pause(&b, c, 10*time.Second); err != nil {
+		t.Fatalf("pause = %v, want nil", err)
+	}
+	if got, want := b.String(), "USR2\n"; got != want {
+		t.Errorf("pause printed %q, want %q", got, want)
+	}
+
+	b.Reset()
+	if err := pause(&b, make(chan os.Signal), 10*time.Millisecond); err != errTimeout {
+		t.Errorf("pause = %v, want %v", err, errTimeout)
+	}
+	if b.Len() != 0 {
+		t.Errorf("pause printed %q after timing out, want nothing", b.String())
+	}
+}
@@ -2,24 +2,45 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// poweroff turns the system off, without delay. There are no options.
+// poweroff turns the system off, without delay.
 //
 // Synopsis:
 //
-//	poweroff
+//	poweroff [-f] [-t TIMEOUT]
 //
 // Description:
 //
-//	poweroff calls the kernel to power off the systems.
+//	poweroff tears the system down, like shutdown, and calls the kernel
+//	to power off the system.
+//
+// Options:
+//
+//	-f: do not tear the system down
+//	-t: timeout of the teardown (default 30s)
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"time"
 
+	"github.com/u-root/u-root/pkg/teardown"
 	"golang.org/x/sys/unix"
 )
 
+var (
+	force   = flag.Bool("f", false, "do not tear the system down")
+	timeout = flag.Duration("t", 30*time.Second, "timeout of the teardown")
+)
+
 func main() {
+	flag.Parse()
+	if !*force {
+		for _, err := range teardown.Run(context.Background(), *timeout, teardown.Defaults("poweroff")...) {
+			log.Printf("teardown: %v", err)
+		}
+	}
 	if err := unix.Reboot(unix.LINUX_REBOOT_CMD_POWER_OFF); err != nil {
 		log.Fatal(err)
 	}
@@ -8,9 +8,17 @@
 //
 //	readlink [OPTIONS] [FILE...]
 //
+// Description:
+//
+//	readlink prints the target of each symbolic link FILE. With -f, -e or
+//	-m, it prints the absolute path of FILE with every symbolic link
+//	resolved instead, which needs FILE to be no link at all.
+//
 // Options:
 //
-//	-f: follow
+//	-e: canonicalize, all components must exist
+//	-f: canonicalize, all components but the last must exist
+//	-m: canonicalize, components may be missing
 //	-n: nonewline
 //	-v: verbose
 package main
@@ -20,13 +28,16 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+
+	"github.com/u-root/u-root/pkg/upath"
 )
 
-const cmd = "readlink [-fnv] FILE"
+const cmd = "readlink [-efmnv] FILE"
 
 var (
-	follow    = flag.Bool("f", false, "follow recursively")
+	follow    = flag.Bool("f", false, "canonicalize, all components but the last must exist")
+	existing  = flag.Bool("e", false, "canonicalize, all components must exist")
+	missing   = flag.Bool("m", false, "canonicalize, components may be missing")
 	noNewLine = flag.Bool("n", false, "do not output trailing newline")
 	verbose   = flag.Bool("v", false, "report error messages")
 )
@@ -40,22 +51,29 @@ func init() {
 }
 
 func readLink(stdout io.Writer, file string) error {
-	path, err := os.Readlink(file)
+	var path string
+	var err error
+	switch {
+	case *existing:
+		path, err = upath.Canonical(file, upath.NoneMissing)
+	case *missing:
+		path, err = upath.Canonical(file, upath.AnyMissing)
+	case *follow:
+		path, err = upath.Canonical(file, upath.LastMissing)
+	default:
+		path, err = os.Readlink(file)
+	}
 	if err != nil {
 		return err
 	}
 
-	if *follow {
-		path, err = filepath.EvalSymlinks(file)
-	}
-
 	delimiter := "\n"
 	if *noNewLine {
 		delimiter = ""
 	}
 
 	fmt.Fprintf(stdout, "%s%s", path, delimiter)
-	return err
+	return nil
 }
 
 func run(stdout io.Writer, stderr io.Writer, args []string) error {
@@ -82,7 +100,7 @@ func run(stdout io.Writer, stderr io.Writer, args []string) error {
 
 func main() {
 	flag.Parse()
-	if err := run(os.Stdin, os.Stderr, flag.Args()); err != nil {
+	if err := run(os.Stdout, os.Stderr, flag.Args()); err != nil {
 		os.Exit(1)
 	}
 }
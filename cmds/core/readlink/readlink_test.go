@@ -18,6 +18,8 @@ type test struct {
 	stdErr    string
 	hasError  bool
 	follow    bool
+	existing  bool
+	missing   bool
 	noNewLine bool
 	verbose   bool
 }
@@ -35,6 +37,10 @@ func TestReadlink(t *testing.T) {
 	if err := os.Chdir(testDir); err != nil {
 		t.Error(err)
 	}
+	realDir, err := filepath.EvalSymlinks(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	tests := []test{
 		{
@@ -58,12 +64,35 @@ func TestReadlink(t *testing.T) {
 			verbose:  true,
 		},
 		{
-			args:     []string{"f2"},
-			out:      "",
-			stdErr:   "",
+			args:   []string{"f2"},
+			out:    realDir + "/f2\n",
+			follow: true,
+		},
+		{
+			args:   []string{"multilinks"},
+			out:    realDir + "/f1\n",
+			follow: true,
+		},
+		{
+			args:   []string{"nofile"},
+			out:    realDir + "/nofile\n",
+			follow: true,
+		},
+		{
+			args:     []string{"nofile/x"},
 			hasError: true,
 			follow:   true,
 		},
+		{
+			args:     []string{"nofile"},
+			hasError: true,
+			existing: true,
+		},
+		{
+			args:    []string{"nofile/x/../y"},
+			out:     realDir + "/nofile/y\n",
+			missing: true,
+		},
 		{
 			args:     []string{"f1symlink"},
 			out:      "f1\n",
@@ -131,6 +160,8 @@ func TestReadlink(t *testing.T) {
 		*verbose = tt.verbose
 		*noNewLine = tt.noNewLine
 		*follow = tt.follow
+		*existing = tt.existing
+		*missing = tt.missing
 
 		var out, stdErr bytes.Buffer
 		err := run(&out, &stdErr, tt.args)
@@ -8,6 +8,11 @@
 //
 //	seq [-f FORMAT] [-w] [-s SEPARATOR] [START [STEP [END]]]
 //
+// Description:
+//
+//	STEP may be negative, to count down. Nothing is printed if the
+//	sequence is empty.
+//
 // Examples:
 //
 //	% seq -s=' ' 3
@@ -16,6 +21,8 @@
 //	2 3 4
 //	% seq -s=' ' 3 2 7
 //	3 5 7
+//	% seq -s=' ' 3 -1 1
+//	3 2 1
 //
 // Options:
 //
@@ -25,6 +32,7 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"flag"
 	"fmt"
@@ -99,16 +107,25 @@ func seq(w io.Writer, args []string) error {
 		width = len(fmt.Sprintf(format, 0, end))
 	}
 
-	defer fmt.Fprint(w, "\n") // last char is always '\n'
-	for stt <= end {
-		fmt.Fprintf(w, format, width, stt)
-		stt += stp
-		if stt <= end { // print only between the values
-			fmt.Fprint(w, flags.separator)
+	// Compute each value, rather than add up steps, which would add up
+	// rounding errors too; and allow for them at the end.
+	last := end + stp*1e-9
+	bw := bufio.NewWriter(w)
+	var i int
+	for ; ; i++ {
+		v := stt + float64(i)*stp
+		if stp > 0 && v > last || stp < 0 && v < last {
+			break
+		}
+		if i > 0 { // print only between the values
+			bw.WriteString(flags.separator)
 		}
+		fmt.Fprintf(bw, format, width, v)
 	}
-
-	return nil
+	if i > 0 { // last char is always '\n'
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
 }
 
 func main() {
@@ -9,7 +9,6 @@ package main
 import (
 	"bytes"
 	"io"
-	"reflect"
 	"testing"
 )
 
@@ -35,7 +34,7 @@ func testseq(tests []test, t *testing.T) {
 		got := b.Bytes()
 		want := []byte(tst.expect)
 
-		if !reflect.DeepEqual(got, want) {
+		if !bytes.Equal(got, want) {
 			t.Logf("Got: \n%v\n", string(got))
 			t.Logf("Expect: \n%v\n", tst.expect)
 			t.Error("Mismatching output")
@@ -54,6 +53,18 @@ func TestSeqDefault(t *testing.T) {
 			[]string{"1", "0.5", "3"},
 			"1.0\n1.5\n2.0\n2.5\n3.0\n",
 		},
+		{
+			[]string{"3", "-1", "1"},
+			"3\n2\n1\n",
+		},
+		{
+			[]string{"0", "0.1", "0.3"},
+			"0.0\n0.1\n0.2\n0.3\n",
+		},
+		{
+			[]string{"3", "1"},
+			"",
+		},
 	}
 
 	testseq(tests, t)
@@ -2,6 +2,28 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+// shasum prints or checks SHA-1 or SHA-256 sums.
+//
+// Synopsis:
+//
+//	shasum [-a 1|256] [FILE...]
+//	shasum -c [-a 1|256] [OPTIONS] [MANIFEST...]
+//
+// Description:
+//
+//	With -c, the files listed in the MANIFESTs, or stdin, are checked, and
+//	shasum exits with 1 if any of them does not match. Files are hashed in
+//	parallel.
+//
+// Options:
+//
+//	-a:               SHA algorithm, 1 or 256
+//	-c, --check:      check the files listed in the manifests
+//	--ignore-missing: with -c, do not fail for missing files
+//	--quiet:          with -c, do not print OK files
+//	--status:         with -c, print nothing; the exit status tells
+//	--strict:         with -c, fail for improperly formatted lines
+//	-w, --warn:       with -c, warn about improperly formatted lines
 package main
 
 import (
@@ -15,32 +37,51 @@ import (
 	"os"
 
 	"github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/hashsum"
 )
 
 var (
-	algorithm = pflag.IntP("algorithmhttps://github.com/u-root/u-root/pull/2319", "a", 1, "SHA algorithm, valid args are 1 and 256")
+	algorithm = pflag.IntP("algorithm", "a", 1, "SHA algorithm, valid args are 1 and 256")
 	help      = pflag.BoolP("help", "h", false, "Show this help and exit")
+	check     = pflag.BoolP("check", "c", false, "check the files listed in the manifests")
+	checkOpts hashsum.CheckOpts
 )
-var usage = "Usage:\nshasum -a <algorithm> <File Name>"
+var usage = "Usage:\nshasum -a <algorithm> <File Name>\nshasum -c -a <algorithm> <Manifest>"
+
+func init() {
+	pflag.BoolVar(&checkOpts.IgnoreMissing, "ignore-missing", false, "do not fail for missing files")
+	pflag.BoolVar(&checkOpts.Quiet, "quiet", false, "do not print OK files")
+	pflag.BoolVar(&checkOpts.Status, "status", false, "print nothing; the exit status tells")
+	pflag.BoolVar(&checkOpts.Strict, "strict", false, "fail for improperly formatted lines")
+	pflag.BoolVarP(&checkOpts.Warn, "warn", "w", false, "warn about improperly formatted lines")
+}
 
 func helpPrinter() {
 	fmt.Println(usage)
 	pflag.PrintDefaults()
 }
 
+// newHash returns the hash function of algo, which is expected to be 1 for
+// SHA1 and 256 for SHA256.
+func newHash(algo int) (func() hash.Hash, error) {
+	switch algo {
+	case 1:
+		return sha1.New, nil
+	case 256:
+		return sha256.New, nil
+	}
+	return nil, fmt.Errorf("invalid algorithm, only 1 or 256 are valid")
+}
+
 // shaPrinter prints sha1/sha256 of given data. The
 // value of algorithm is expected to be 1 for SHA1
 // and 256 for SHA256
 func shaGenerator(w io.Writer, r io.Reader, algo int) ([]byte, error) {
-	var h hash.Hash
-	switch algo {
-	case 1:
-		h = sha1.New()
-	case 256:
-		h = sha256.New()
-	default:
-		return nil, fmt.Errorf("invalid algorithm, only 1 or 256 are valid")
+	newH, err := newHash(algo)
+	if err != nil {
+		return nil, err
 	}
+	h := newH()
 	if _, err := io.Copy(h, r); err != nil {
 		return nil, err
 	}
@@ -62,23 +103,54 @@ func shasum(w io.Writer, r io.Reader, args ...string) error {
 		fmt.Fprintf(w, "%x -\n", hashbytes)
 		return nil
 	}
-	for _, arg := range args {
-		file, err := os.Open(arg)
+	newH, err := newHash(*algorithm)
+	if err != nil {
+		// Files that cannot be opened are reported before a bad
+		// algorithm.
+		for _, arg := range args {
+			f, oerr := os.Open(arg)
+			if oerr != nil {
+				return oerr
+			}
+			f.Close()
+		}
+		return err
+	}
+	h := &hashsum.Hasher{New: newH, Stdin: r}
+	h.Sum(args, func(res hashsum.Result) {
 		if err != nil {
-			return err
+			return
 		}
-		defer file.Close()
-		if hashbytes, err = shaGenerator(w, file, *algorithm); err != nil {
-			return err
+		if err = res.Err; err == nil {
+			fmt.Fprintf(w, "%x %s\n", res.Sum, res.Name)
 		}
-		fmt.Fprintf(w, "%x %s\n", hashbytes, arg)
+	})
+	return err
+}
+
+func checkShasums(w, stderr io.Writer, r io.Reader, manifests ...string) error {
+	newH, err := newHash(*algorithm)
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		manifests = []string{"-"}
 	}
-	return nil
+	h := &hashsum.Hasher{New: newH, Stdin: r}
+	return h.CheckFiles(w, stderr, manifests, checkOpts)
 }
 
 func main() {
 	pflag.Parse()
-	if err := shasum(os.Stdout, os.Stdin, pflag.Args()...); err != nil {
+	run := shasum
+	if *check && !*help {
+		run = func(w io.Writer, r io.Reader, args ...string) error {
+			return checkShasums(w, os.Stderr, r, args...)
+		}
+	}
+	if err := run(os.Stdout, os.Stdin, pflag.Args()...); err == hashsum.ErrSilent {
+		os.Exit(1)
+	} else if err != nil {
 		log.Fatal(err)
 	}
 }
@@ -6,7 +6,7 @@
 //
 // Synopsis:
 //
-//	shutdown [<-h|-r|-s|halt|reboot|suspend> [time [message...]]]
+//	shutdown [-f] [-t seconds] [<-h|-r|-s|halt|reboot|suspend> [time [message...]]]
 //
 // Description:
 //
@@ -14,8 +14,15 @@
 //	If no operation is specified halt is assumed.
 //	If a time is given, an opcode is not optional.
 //
+//	Before halting or rebooting, the system is torn down: the services
+//	are stopped, the executables in /etc/shutdown.d are run with the
+//	operation as argument, the BMC is told, and the disks are synced and
+//	unmounted. Teardown that takes longer than the timeout is cut short.
+//
 // Options:
 //
+//	-f:		do not tear the system down.
+//	-t seconds:	timeout of the teardown (default 30).
 //	-r|reboot:	reboot the machine.
 //	-h|halt:		halt the machine.
 //	-s|suspend:	suspend the machine.
@@ -26,15 +33,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/u-root/u-root/pkg/teardown"
 	"golang.org/x/sys/unix"
 )
 
-const usageMessage = "shutdown [<-h|-r|-s|halt|reboot|suspend> [time [message...]]]"
+const usageMessage = "shutdown [-f] [-t seconds] [<-h|-r|-s|halt|reboot|suspend> [time [message...]]]"
+
+const defaultTimeout = 30 * time.Second
 
 var (
 	opcodes = map[string]uint{
@@ -45,14 +57,36 @@ var (
 		"suspend": unix.LINUX_REBOOT_CMD_SW_SUSPEND,
 		"-s":      unix.LINUX_REBOOT_CMD_SW_SUSPEND,
 	}
+
+	// names are the operations the teardown executables are told of.
+	names = map[uint]string{
+		unix.LINUX_REBOOT_CMD_POWER_OFF: "halt",
+		unix.LINUX_REBOOT_CMD_RESTART:   "reboot",
+	}
 )
 
 // shutdown calls unix.Reboot, with the type of shutdown defined in args, currently
 // halt, reboot, or suspend. A time may be specified as "now",
 // a future time parseable by time.ParseDuration, or in
-// RFC3339 format. If dryrun is chosen, shutdown returns the opcode it
+// RFC3339 format. Unless -f is given, the system is torn down first, except
+// to suspend. If dryrun is chosen, shutdown returns the opcode it
 // would have used and an error, if any.
 func shutdown(dryrun bool, args ...string) (uint, error) {
+	force, timeout := false, defaultTimeout
+	for len(args) > 0 && (args[0] == "-f" || args[0] == "-t") {
+		if args[0] == "-f" {
+			force, args = true, args[1:]
+			continue
+		}
+		if len(args) < 2 {
+			return 0, fmt.Errorf(usageMessage)
+		}
+		secs, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("timeout %q: %w", args[1], err)
+		}
+		timeout, args = time.Duration(secs)*time.Second, args[2:]
+	}
 	if len(args) == 0 {
 		args = append(args, "halt")
 	}
@@ -86,6 +120,11 @@ func shutdown(dryrun bool, args ...string) (uint, error) {
 	if !dryrun {
 		time.Sleep(time.Until(when))
 	}
+	if name, ok := names[op]; ok && !dryrun && !force {
+		for _, err := range teardown.Run(context.Background(), timeout, teardown.Defaults(name)...) {
+			log.Printf("teardown: %v", err)
+		}
+	}
 	if !dryrun {
 		if err := unix.Reboot(int(op)); err != nil {
 			return 0, err
@@ -117,6 +117,30 @@ func TestShutdown(t *testing.T) {
 			dryrun: true,
 			want:   unix.LINUX_REBOOT_CMD_SW_SUSPEND,
 		},
+		{
+			name:   "-f reboot",
+			args:   []string{"-f", "reboot"},
+			dryrun: true,
+			want:   unix.LINUX_REBOOT_CMD_RESTART,
+		},
+		{
+			name:   "-t 60 -f",
+			args:   []string{"-t", "60", "-f"},
+			dryrun: true,
+			want:   unix.LINUX_REBOOT_CMD_POWER_OFF,
+		},
+		{
+			name:    "-t",
+			args:    []string{"-t"},
+			dryrun:  true,
+			wantErr: "shutdown [-f]",
+		},
+		{
+			name:    "-t a",
+			args:    []string{"-t", "a", "halt"},
+			dryrun:  true,
+			wantErr: "invalid syntax",
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := shutdown(tt.dryrun, tt.args...)
@@ -0,0 +1,17 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build plan9 || windows
+// +build plan9 windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func parseSignals(string) ([]os.Signal, error) {
+	return nil, errors.New("-w is not supported on this system")
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// parseSignals parses a comma separated list of signal names, with or
+// without SIG, or numbers.
+func parseSignals(s string) ([]os.Signal, error) {
+	var sigs []os.Signal
+	for _, f := range strings.Split(s, ",") {
+		if n, err := strconv.Atoi(f); err == nil && n > 0 {
+			sigs = append(sigs, syscall.Signal(n))
+			continue
+		}
+		name := strings.ToUpper(f)
+		if !strings.HasPrefix(name, "SIG") {
+			name = "SIG" + name
+		}
+		sig := unix.SignalNum(name)
+		if sig == 0 {
+			return nil, fmt.Errorf("invalid signal %q", f)
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
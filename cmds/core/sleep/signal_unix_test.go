@@ -0,0 +1,33 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package main
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestParseSignals(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    []os.Signal
+		wantErr bool
+	}{
+		{in: "USR1", want: []os.Signal{syscall.SIGUSR1}},
+		{in: "sigterm,hup,10", want: []os.Signal{syscall.SIGTERM, syscall.SIGHUP, syscall.Signal(10)}},
+		{in: "NOSUCH", wantErr: true},
+		{in: "USR1,", wantErr: true},
+	} {
+		got, err := parseSignals(tt.in)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSignals(%q) = %v, %v, want %v, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
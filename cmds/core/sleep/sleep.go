@@ -6,19 +6,27 @@
 //
 // Synopsis:
 //
-//	sleep DURATION
+//	sleep [-w SIGNALS] DURATION...
 //
 // Description:
 //
 //	If no units are given, the duration is assumed to be measured in
 //	seconds, otherwise any format parsed by Go's `time.ParseDuration` is
-//	accepted.
+//	accepted, as well as fractional days with a d suffix. Several
+//	durations are added up. infinity sleeps forever.
+//
+// Options:
+//
+//	-w: comma separated list of signals, by name or number, that end the
+//	    sleep early, successfully
 //
 // Examples:
 //
 //	sleep 2.5
 //	sleep 300ms
 //	sleep 2h45m
+//	sleep 1m 30s
+//	sleep -w USR1,HUP infinity
 //
 // Bugs:
 //
@@ -30,12 +38,33 @@ import (
 	"errors"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var errDuration = errors.New("invalid duration")
+var (
+	errDuration = errors.New("invalid duration")
+
+	wake = flag.String("w", "", "comma separated list of signals that end the sleep early")
+)
+
+// infinity is a duration that is long enough to be forever.
+const infinity = time.Duration(1<<63 - 1)
 
 func parseDuration(s string) (time.Duration, error) {
+	if s == "infinity" {
+		return infinity, nil
+	}
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		f, err := strconv.ParseFloat(days, 64)
+		if err != nil || f < 0 || f*24 > infinity.Hours() {
+			return time.Duration(0), errDuration
+		}
+		return time.Duration(f * float64(24*time.Hour)), nil
+	}
 	d, err := time.ParseDuration(s)
 	if err != nil {
 		d, err = time.ParseDuration(s + "s")
@@ -46,17 +75,51 @@ func parseDuration(s string) (time.Duration, error) {
 	return d, nil
 }
 
+// parseDurations adds up the durations args, up to infinity.
+func parseDurations(args []string) (time.Duration, error) {
+	var total time.Duration
+	for _, a := range args {
+		d, err := parseDuration(a)
+		if err != nil {
+			return 0, err
+		}
+		if total += d; total < d {
+			total = infinity
+		}
+	}
+	return total, nil
+}
+
+// sleep sleeps for d, or until a signal is received on wake.
+func sleep(d time.Duration, wake <-chan os.Signal) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-wake:
+	}
+}
+
 func main() {
 	flag.Parse()
 
-	if flag.NArg() != 1 {
+	if flag.NArg() < 1 {
 		log.Fatal("Incorrect number of arguments")
 	}
 
-	d, err := parseDuration(flag.Arg(0))
+	d, err := parseDurations(flag.Args())
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	time.Sleep(d)
+	var c chan os.Signal
+	if *wake != "" {
+		sigs, err := parseSignals(*wake)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c = make(chan os.Signal, 1)
+		signal.Notify(c, sigs...)
+	}
+	sleep(d, c)
 }
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"os"
 	"testing"
 	"time"
 )
@@ -23,6 +24,10 @@ func TestParseDuration(t *testing.T) {
 		{"2.5s", time.Duration(2500 * time.Millisecond), nil},
 		{"300m", time.Duration(300 * time.Minute), nil},
 		{"2h45m", time.Duration(2*time.Hour + 45*time.Minute), nil},
+		{"1.5d", time.Duration(36 * time.Hour), nil},
+		{"-1d", time.Duration(0), errDuration},
+		{"xd", time.Duration(0), errDuration},
+		{"infinity", infinity, nil},
 	}
 
 	// Table-driven testing
@@ -34,3 +39,42 @@ func TestParseDuration(t *testing.T) {
 		}
 	}
 }
+
+func TestParseDurations(t *testing.T) {
+	for _, tt := range []struct {
+		in   []string
+		want time.Duration
+		err  error
+	}{
+		{in: []string{"1m", "30"}, want: 90 * time.Second},
+		{in: []string{"1", "infinity"}, want: infinity},
+		{in: []string{"infinity", "infinity"}, want: infinity},
+		{in: []string{"1", "x"}, err: errDuration},
+	} {
+		got, err := parseDurations(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("parseDurations(%q) = %v, %v; want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestSleepWake(t *testing.T) {
+	c := make(chan os.Signal, 1)
+	c <- os.Interrupt
+	done := make(chan struct{})
+	go func() {
+		sleep(infinity, c)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("sleep was not woken by a signal")
+	}
+
+	start := time.Now()
+	sleep(10*time.Millisecond, nil)
+	if d := time.Since(start); d < 10*time.Millisecond {
+		t.Errorf("sleep(10ms) returned after %v", d)
+	}
+}
@@ -15,6 +15,7 @@
 //	   tar -cvf x.tar file1 file2 ...    # create
 //	   tar -tvf x.tar                    # list
 //	   tar -xvf x.tar directory/         # extract
+//	   tar -c --from-cpio -f x.tar x.cpio # convert a cpio archive
 //
 // Options:
 //
@@ -23,15 +24,24 @@
 //	-v: verbose, print each filename (optional)
 //	-f: tar filename (required)
 //	-t: list the contents of an archive
+//	--from-cpio: with -c, archive the files of the given cpio archive
+//	--owner: with -c, archive the files as owned by this user name or uid
+//	--group: with -c, archive the files as owned by this group name or gid
+//	--numeric-owner: archive, or extract with, the user and group ids only
+//	--same-owner: with -x, give the files the owner and group of the
+//	              archive, by name if they are found, or else by id
 //
 // TODO: The arguments deviates slightly from gnu tar.
 package main
 
 import (
+	"archive/tar"
 	"log"
 	"os"
 
 	flag "github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/cpio"
+	"github.com/u-root/u-root/pkg/nss"
 	"github.com/u-root/u-root/pkg/tarutil"
 )
 
@@ -39,11 +49,48 @@ var (
 	create      = flag.BoolP("create", "c", false, "create a new tar archive from the given directory")
 	extract     = flag.BoolP("extract", "x", false, "extract a tar archive from the given directory")
 	file        = flag.StringP("file", "f", "", "tar file")
+	fromCpio    = flag.Bool("from-cpio", false, "with -c, archive the files of the given newc cpio archive")
 	list        = flag.BoolP("list", "t", false, "list the contents of an archive")
 	noRecursion = flag.Bool("no-recursion", false, "do not automatically recurse into directories")
+	owner       = flag.String("owner", "", "with -c, archive the files as owned by `USER`")
+	group       = flag.String("group", "", "with -c, archive the files as owned by `GROUP`")
+	numeric     = flag.Bool("numeric-owner", false, "archive or extract with the user and group ids only")
+	sameOwner   = flag.Bool("same-owner", false, "with -x, give the files the owner and group of the archive")
 	verbose     = flag.BoolP("verbose", "v", false, "print each filename")
 )
 
+// ownerFilter returns a filter that sets the owner of files to user, and
+// their group to group, given by name or id, unless they are "".
+func ownerFilter(user, group string) (tarutil.Filter, error) {
+	var uid, gid uint32
+	var err error
+	if user != "" {
+		if uid, err = nss.UserID(user); err != nil {
+			return nil, err
+		}
+	}
+	if group != "" {
+		if gid, err = nss.GroupID(group); err != nil {
+			return nil, err
+		}
+	}
+	return func(hdr *tar.Header) bool {
+		if user != "" {
+			hdr.Uid, hdr.Uname = int(uid), ""
+			if u, err := nss.LookupUID(uid); err == nil && !*numeric {
+				hdr.Uname = u.Name
+			}
+		}
+		if group != "" {
+			hdr.Gid, hdr.Gname = int(gid), ""
+			if g, err := nss.LookupGID(gid); err == nil && !*numeric {
+				hdr.Gname = g.Name
+			}
+		}
+		return true
+	}, nil
+}
+
 func main() {
 	flag.Parse()
 
@@ -60,13 +107,47 @@ func main() {
 	}
 
 	opts := &tarutil.Opts{
-		NoRecursion: *noRecursion,
+		NoRecursion:  *noRecursion,
+		SameOwner:    *sameOwner,
+		NumericOwner: *numeric,
+	}
+	if *owner != "" || *group != "" {
+		f, err := ownerFilter(*owner, *group)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Filters = append(opts.Filters, f)
 	}
 	if *verbose {
-		opts.Filters = []tarutil.Filter{tarutil.VerboseFilter}
+		opts.Filters = append(opts.Filters, tarutil.VerboseFilter)
 	}
 
 	switch {
+	case *create && *fromCpio:
+		if flag.NArg() != 1 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		in, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer in.Close()
+		rr, err := cpio.Newc.NewFileReader(in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f, err := os.Create(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := cpio.ToTar(f, rr); err != nil {
+			f.Close()
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
 	case *create:
 		f, err := os.Create(*file)
 		if err != nil {
@@ -7,15 +7,22 @@
 //
 // Synopsis:
 //
-//	tee [-ai] FILES...
+//	tee [-aip] FILES...
+//
+// Description:
+//
+//	If a file cannot be opened or written, tee says so and goes on with
+//	the others, and fails at the end.
 //
 // Options:
 //
 //	-a, --append: append the output to the files rather than rewriting them
 //	-i, --ignore-interrupts: ignore the SIGINT signal
+//	-p, --output-error: do not fail when the standard output is a closed pipe
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -26,17 +33,25 @@ import (
 )
 
 var (
-	cat    = flag.BoolP("append", "a", false, "append the output to the files rather than rewriting them")
-	ignore = flag.BoolP("ignore-interrupts", "i", false, "ignore the SIGINT signal")
+	cat        = flag.BoolP("append", "a", false, "append the output to the files rather than rewriting them")
+	ignore     = flag.BoolP("ignore-interrupts", "i", false, "ignore the SIGINT signal")
+	ignorePipe = flag.BoolP("output-error", "p", false, "do not fail when the standard output is a closed pipe")
 )
 
 type command struct {
-	stdin  io.Reader
-	stdout io.Writer
-	stderr io.Writer
-	args   []string
-	cat    bool
-	ignore bool
+	stdin      io.Reader
+	stdout     io.Writer
+	stderr     io.Writer
+	args       []string
+	cat        bool
+	ignore     bool
+	ignorePipe bool
+}
+
+// output is a file written to.
+type output struct {
+	name string
+	w    io.Writer
 }
 
 func newCommand(cat, ignore bool, args []string) *command {
@@ -51,44 +66,82 @@ func newCommand(cat, ignore bool, args []string) *command {
 }
 
 func (c *command) run() error {
-	oflags := os.O_WRONLY | os.O_CREATE
+	oflags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
 	if c.cat {
-		oflags |= os.O_APPEND
+		oflags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
 	}
 
 	if c.ignore {
 		signal.Ignore(os.Interrupt)
 	}
+	if c.ignorePipe {
+		ignoreSIGPIPE()
+	}
 
+	var failed bool
 	files := make([]*os.File, 0, len(c.args))
-	writers := make([]io.Writer, 0, len(c.args)+1)
+	outputs := make([]output, 0, len(c.args)+1)
 	for _, fname := range c.args {
 		f, err := os.OpenFile(fname, oflags, 0o666)
 		if err != nil {
-			return fmt.Errorf("error opening %s: %v", fname, err)
+			fmt.Fprintf(c.stderr, "tee: error opening %s: %v\n", fname, err)
+			failed = true
+			continue
 		}
 		files = append(files, f)
-		writers = append(writers, f)
+		outputs = append(outputs, output{name: fname, w: f})
 	}
-	writers = append(writers, c.stdout)
+	outputs = append(outputs, output{name: "standard output", w: c.stdout})
 
-	mw := io.MultiWriter(writers...)
-	if _, err := io.Copy(mw, c.stdin); err != nil {
-		return fmt.Errorf("error: %v", err)
+	// Write each block to every output that has not failed yet.
+	buf := make([]byte, 32*1024)
+	var readErr error
+	for len(outputs) > 0 {
+		n, err := c.stdin.Read(buf)
+		if n > 0 {
+			live := outputs[:0]
+			for _, o := range outputs {
+				if _, err := o.w.Write(buf[:n]); err != nil {
+					if !(c.ignorePipe && o.w == c.stdout && isBrokenPipe(err)) {
+						fmt.Fprintf(c.stderr, "tee: error writing %s: %v\n", o.name, err)
+						failed = true
+					}
+					continue
+				}
+				live = append(live, o)
+			}
+			outputs = live
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
 	}
 
 	for _, f := range files {
 		if err := f.Close(); err != nil {
 			fmt.Fprintf(c.stderr, "tee: error closing file %q: %v\n", f.Name(), err)
+			failed = true
 		}
 	}
 
+	if readErr != nil {
+		return fmt.Errorf("error: %v", readErr)
+	}
+	if failed {
+		return errors.New("some files could not be written")
+	}
 	return nil
 }
 
 func main() {
 	flag.Parse()
-	if err := newCommand(*cat, *ignore, flag.Args()).run(); err != nil {
+	c := newCommand(*cat, *ignore, flag.Args())
+	c.ignorePipe = *ignorePipe
+	if err := c.run(); err != nil {
 		log.Fatalf("tee: %v", err)
 	}
 }
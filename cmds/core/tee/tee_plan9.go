@@ -0,0 +1,17 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build plan9
+// +build plan9
+
+package main
+
+import "strings"
+
+// ignoreSIGPIPE does nothing; Plan 9 has notes, not signals.
+func ignoreSIGPIPE() {}
+
+func isBrokenPipe(err error) bool {
+	return strings.Contains(err.Error(), "write on closed pipe")
+}
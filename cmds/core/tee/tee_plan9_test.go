@@ -0,0 +1,15 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "errors"
+
+func brokenPipeTests(file string) []errorTest {
+	errPipe := errors.New("write on closed pipe")
+	return []errorTest{
+		{name: "broken pipe", stdout: errWriter{errPipe}, args: []string{file}, wantErr: true, wantStderr: "write on closed pipe"},
+		{name: "broken pipe ignored", stdout: errWriter{errPipe}, ignorePipe: true, args: []string{file}},
+	}
+}
@@ -6,9 +6,11 @@ package main
 
 import (
 	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -85,3 +87,52 @@ func TestTee(t *testing.T) {
 		})
 	}
 }
+
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+type errorTest struct {
+	name       string
+	stdout     io.Writer
+	ignorePipe bool
+	args       []string
+	wantErr    bool
+	wantStderr string
+}
+
+func TestTeeErrors(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good")
+	// Files are rewritten, not written over.
+	if err := os.WriteFile(good, []byte("a much longer old content"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range append([]errorTest{
+		{name: "missing directory", stdout: &bytes.Buffer{}, args: []string{filepath.Join(dir, "no/such"), good}, wantErr: true, wantStderr: "error opening"},
+		{name: "stdout fails", stdout: errWriter{syscall.EIO}, args: []string{good}, wantErr: true, wantStderr: "error writing standard output"},
+	}, brokenPipeTests(good)...) {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			cmd := newCommand(false, false, tt.args)
+			cmd.stdin = strings.NewReader("new")
+			cmd.stdout = tt.stdout
+			cmd.stderr = &stderr
+			cmd.ignorePipe = tt.ignorePipe
+			if err := cmd.run(); (err != nil) != tt.wantErr {
+				t.Errorf("run = %v, want error %v", err, tt.wantErr)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) || tt.wantStderr == "" && stderr.Len() > 0 {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantStderr)
+			}
+			// The other files are still written.
+			if b, err := os.ReadFile(good); err != nil || string(b) != "new" {
+				t.Errorf("%s = %q, %v, want %q", good, b, err, "new")
+			}
+		})
+	}
+}
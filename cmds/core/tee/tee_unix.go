@@ -0,0 +1,24 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package main
+
+import (
+	"errors"
+	"os/signal"
+	"syscall"
+)
+
+// ignoreSIGPIPE makes writes to closed pipes return EPIPE rather than kill
+// tee.
+func ignoreSIGPIPE() {
+	signal.Ignore(syscall.SIGPIPE)
+}
+
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,17 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package main
+
+import "syscall"
+
+func brokenPipeTests(file string) []errorTest {
+	return []errorTest{
+		{name: "broken pipe", stdout: errWriter{syscall.EPIPE}, args: []string{file}, wantErr: true, wantStderr: "broken pipe"},
+		{name: "broken pipe ignored", stdout: errWriter{syscall.EPIPE}, ignorePipe: true, args: []string{file}},
+	}
+}
@@ -5,21 +5,26 @@
 // Run a command and kill it if it runs more than a specified duration
 //
 // Synopsis:
-//	timeout [-t duration-string] command [args...]
+//	timeout [-t duration-string] [-s signal] [-k duration-string] command [args...]
 //
 // Description:
 //	timeout will run the command until it succeeds or too much time has passed.
 //	The default timeout is 30s.
 //	If no args are given, it will print a usage error.
+//	When the time has passed, the command is sent a signal, KILL by default.
+//	If -k is given and the command is still running that much later, it is
+//	killed. timeout then exits with 124, or 137 if it had to kill the command.
+//	On Plan 9, KILL kills the command and any other signal is posted to it
+//	as a note.
 //
 // Example:
 //	$ timeout echo hi
 //	hi
 //	$
 //	$./timeout -t 5s bash -c 'sleep 40'
-//	$ 2022/03/31 14:47:32 signal: killed
-//	$./timeout  -t 5s bash -c 'sleep 40'
-//	$ 2022/03/31 14:47:40 signal: killed
+//	$ 2022/03/31 14:47:32 timeout(5s):timed out
+//	$./timeout  -t 5s -s INT -k 1s bash -c 'trap "" INT; sleep 40'
+//	$ 2022/03/31 14:47:40 timeout(5s):timed out
 //	$./timeout  -t 5s bash -c 'sleep 1'
 //	$
 
@@ -29,7 +34,6 @@
 package main
 
 import (
-	"context"
 	"errors"
 	"flag"
 	"log"
@@ -38,20 +42,39 @@ import (
 	"time"
 )
 
+// exitKilled is the exit status after a kill, 128 + SIGKILL as in GNU timeout.
+const exitKilled = 137
+
 type cmd struct {
 	args         []string
 	timeout      time.Duration
+	killAfter    time.Duration
+	signal       os.Signal
 	in, out, err *os.File
 }
 
 var (
-	timeout   = flag.Duration("t", 30*time.Second, "Timeout for command")
-	errNoArgs = errors.New("Need at least a command to run")
+	timeout    = flag.Duration("t", 30*time.Second, "Timeout for command")
+	killAfter  time.Duration
+	signalName string
+	errNoArgs  = errors.New("Need at least a command to run")
+	errTimeout = errors.New("timed out")
 )
 
+func init() {
+	flag.StringVar(&signalName, "s", "KILL", "Signal to send when the time has passed")
+	flag.StringVar(&signalName, "signal", "KILL", "Signal to send when the time has passed")
+	flag.DurationVar(&killAfter, "k", 0, "Kill the command if it is still running this long after the signal")
+	flag.DurationVar(&killAfter, "kill-after", 0, "Kill the command if it is still running this long after the signal")
+}
+
 func main() {
 	flag.Parse()
-	c := &cmd{args: flag.Args(), in: os.Stdin, out: os.Stdout, err: os.Stderr, timeout: *timeout}
+	sig, err := parseSignal(signalName)
+	if err != nil {
+		log.Fatalf("timeout: %v", err)
+	}
+	c := &cmd{args: flag.Args(), in: os.Stdin, out: os.Stdout, err: os.Stderr, timeout: *timeout, killAfter: killAfter, signal: sig}
 	if errno, err := c.run(); err != nil || errno != 0 {
 		log.Printf("timeout(%v):%v", *timeout, err)
 		os.Exit(errno)
@@ -62,12 +85,37 @@ func (c *cmd) run() (int, error) {
 	if len(c.args) == 0 {
 		return 1, errNoArgs
 	}
-	ctx := context.Background()
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
-	defer cancel()
-	proc := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	proc := exec.Command(c.args[0], c.args[1:]...)
 	proc.Stdin, proc.Stdout, proc.Stderr = c.in, c.out, c.err
-	if err := proc.Run(); err != nil {
+	if err := proc.Start(); err != nil {
+		return 1, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- proc.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(c.timeout):
+		sig := c.signal
+		if sig == nil {
+			sig = os.Kill
+		}
+		proc.Process.Signal(sig)
+		var kill <-chan time.Time
+		if c.killAfter > 0 {
+			kill = time.After(c.killAfter)
+		}
+		select {
+		case <-done:
+			return 124, errTimeout
+		case <-kill:
+			proc.Process.Kill()
+			<-done
+			return exitKilled, errTimeout
+		}
+	}
+	if err != nil {
 		errno := 1
 		var e *exec.ExitError
 		if errors.As(err, &e) {
@@ -0,0 +1,41 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignals(t *testing.T) {
+	for s, want := range map[string]syscall.Signal{"TERM": syscall.SIGTERM, "sigint": syscall.SIGINT, "SIGKILL": syscall.SIGKILL, "1": syscall.SIGHUP} {
+		if sig, err := parseSignal(s); err != nil || sig != want {
+			t.Errorf("parseSignal(%q) = %v, %v, want %v", s, sig, err, want)
+		}
+	}
+	for _, s := range []string{"", "NOSUCH", "-1"} {
+		if _, err := parseSignal(s); err == nil {
+			t.Errorf("parseSignal(%q) succeeded", s)
+		}
+	}
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skipf("Skipping this test as sh is not in the path")
+	}
+	// The command ignores the signal, so it must be killed.
+	c := cmd{args: []string{"sh", "-c", `trap "" INT; sleep 5`}, timeout: 200 * time.Millisecond, signal: syscall.SIGINT, killAfter: 200 * time.Millisecond}
+	if errno, err := c.run(); errno != 137 || err != errTimeout {
+		t.Errorf("run %v: got (%d, %v), want (137, %v)", c, errno, err, errTimeout)
+	}
+	c = cmd{args: []string{"sh", "-c", "sleep 5"}, timeout: 200 * time.Millisecond, killAfter: time.Minute}
+	if errno, err := c.run(); errno != 124 || err != errTimeout {
+		t.Errorf("run %v: got (%d, %v), want (124, %v)", c, errno, err, errTimeout)
+	}
+}
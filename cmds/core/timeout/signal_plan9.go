@@ -0,0 +1,31 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !test
+// +build !test
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// parseSignal parses a signal name, with or without SIG. Plan 9 has notes,
+// not signals: KILL kills the command, and any other name is posted to it
+// as a note.
+func parseSignal(s string) (os.Signal, error) {
+	name := strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	switch name {
+	case "":
+		return nil, fmt.Errorf("invalid signal %q", s)
+	case "KILL":
+		return os.Kill, nil
+	case "INT":
+		return os.Interrupt, nil
+	}
+	return syscall.Note(strings.ToLower(name)), nil
+}
@@ -11,10 +11,14 @@
 //     Translate, squeeze, and/or delete characters from standard input, writing
 //     to standard output.
 //
+//     -c, --complement: use the characters that are not in SET1
 //     -d, --delete: delete characters in SET1, do not translate
+//     -s, --squeeze-repeats: replace each run of a character in the last SET
+//         with one of it
 //
 // SETs  are  specified  as  strings of characters. Most represent themselves.
 // Interpreted sequences are:
+//     \NNN      the character with octal value NNN
 //     \\        backslash
 //     \a        audible BEL
 //     \b        backspace
@@ -31,6 +35,12 @@
 //     [:lower:] all lower case letters
 //     [:upper:] all upper case letters
 //     [:space:] all whitespaces
+//     [:punct:] all punctuation characters
+//     C1-C2     all characters from C1 to C2
+//     [C*N]     N copies of C in SET2; [C*] is as many as SET1 needs
+//
+// If SET2 is shorter than SET1, its last character is repeated. Classes in
+// a SET with other characters are taken from the first 256 characters.
 
 package main
 
@@ -40,12 +50,18 @@ import (
 	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"unicode"
 
 	flag "github.com/spf13/pflag"
 )
 
-var delete = flag.BoolP("delete", "d", false, "delete characters in SET1, do not translate")
+var (
+	delete     = flag.BoolP("delete", "d", false, "delete characters in SET1, do not translate")
+	squeeze    = flag.BoolP("squeeze-repeats", "s", false, "replace each run of a character in the last SET with one of it")
+	complement = flag.BoolP("complement", "c", false, "use the characters that are not in SET1")
+)
 
 const name = "tr"
 
@@ -91,6 +107,8 @@ var sets = map[Set]func(r rune) bool{
 
 type transformer struct {
 	transform func(r rune) rune
+	// squeeze, if set, reports whether runs of r are written as one r.
+	squeeze func(r rune) bool
 }
 
 func setToRune(s Set, outRune rune) *transformer {
@@ -147,6 +165,8 @@ func (t *transformer) run(r io.Reader, w io.Writer) error {
 
 	defer out.Flush()
 
+	var last rune
+	var written bool
 	for {
 		inRune, size, err := in.ReadRune()
 		if inRune == unicode.ReplacementChar {
@@ -163,7 +183,9 @@ func (t *transformer) run(r io.Reader, w io.Writer) error {
 				return fmt.Errorf("write error: %v", err)
 			}
 		} else if size > 0 {
-			if outRune := t.transform(inRune); outRune != unicode.ReplacementChar {
+			outRune := t.transform(inRune)
+			if outRune != unicode.ReplacementChar && !(written && outRune == last && t.squeeze != nil && t.squeeze(outRune)) {
+				last, written = outRune, true
 				if _, err := out.WriteRune(outRune); err != nil {
 					return fmt.Errorf("write error: %v", err)
 				}
@@ -179,37 +201,216 @@ func (t *transformer) run(r io.Reader, w io.Writer) error {
 	}
 }
 
-func parse() (*transformer, error) {
-	flag.Parse()
+// element is a part of a SET: a class, or characters.
+type element struct {
+	class Set
+	runes []rune
+	// fill is set for [C*], which is repeated to the length of SET1.
+	fill bool
+}
+
+// classRunes are the characters that classes expand to.
+const classRunes = 256
+
+// parseSet parses the classes, repeats and ranges of s.
+func parseSet(s Set) ([]element, error) {
+	var elems []element
+	literal := func(text string) error {
+		rs, err := unescape(Set(text))
+		if err != nil {
+			return err
+		}
+		var out []rune
+		for i := 0; i < len(rs); i++ {
+			if i+2 < len(rs) && rs[i+1] == '-' {
+				if rs[i+2] < rs[i] {
+					return fmt.Errorf("range-endpoints of '%c-%c' are in reverse collating sequence order", rs[i], rs[i+2])
+				}
+				for r := rs[i]; r <= rs[i+2]; r++ {
+					out = append(out, r)
+				}
+				i += 2
+				continue
+			}
+			out = append(out, rs[i])
+		}
+		elems = append(elems, element{runes: out})
+		return nil
+	}
+
+	str := string(s)
+	start := 0
+	for i := 0; i < len(str); i++ {
+		if str[i] == '\\' {
+			i++
+			continue
+		}
+		if str[i] != '[' {
+			continue
+		}
+		rest := str[i:]
+		var e element
+		var n int
+		if j := strings.Index(rest, ":]"); strings.HasPrefix(rest, "[:") && j > 0 {
+			e.class, n = Set(rest[:j+2]), j+2
+			if _, ok := sets[e.class]; !ok {
+				return nil, fmt.Errorf("invalid character class %q", e.class)
+			}
+		} else if j := strings.IndexByte(rest, ']'); j > 2 && rest[2] == '*' || j > 3 && rest[1] == '\\' && rest[3] == '*' {
+			star := strings.IndexByte(rest, '*')
+			c, err := unescape(Set(rest[1:star]))
+			if err != nil || len(c) != 1 {
+				continue
+			}
+			count := rest[star+1 : j]
+			if count == "" {
+				e.runes, e.fill = c, true
+			} else {
+				// A leading 0 means octal.
+				m, err := strconv.ParseUint(count, 0, 16)
+				if err != nil {
+					return nil, fmt.Errorf("invalid repeat count %q in [c*n] construct", count)
+				}
+				e.runes = []rune(strings.Repeat(string(c), int(m)))
+				if m == 0 {
+					e.runes, e.fill = c, true
+				}
+			}
+			n = j + 1
+		} else {
+			continue
+		}
+		if err := literal(str[start:i]); err != nil {
+			return nil, err
+		}
+		elems = append(elems, e)
+		i += n - 1
+		start = i + 1
+	}
+	if err := literal(str[start:]); err != nil {
+		return nil, err
+	}
+	return elems, nil
+}
+
+// contains returns a function that reports whether r is in the set of elems.
+func contains(elems []element) func(r rune) bool {
+	in := map[rune]bool{}
+	var classes []func(rune) bool
+	for _, e := range elems {
+		if e.class != "" {
+			classes = append(classes, sets[e.class])
+		}
+		for _, r := range e.runes {
+			in[r] = true
+		}
+	}
+	return func(r rune) bool {
+		if in[r] {
+			return true
+		}
+		for _, c := range classes {
+			if c(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
 
-	narg := flag.NArg()
-	args := flag.Args()
+// expand returns the runes of elems, filling [C*] to n runes.
+func expand(elems []element, n int) []rune {
+	var out []rune
+	fill := -1
+	for _, e := range elems {
+		switch {
+		case e.class != "":
+			check := sets[e.class]
+			for r := rune(0); r < classRunes; r++ {
+				if check(r) {
+					out = append(out, r)
+				}
+			}
+		case e.fill && fill < 0:
+			fill = len(out)
+			out = append(out, e.runes[:1]...)
+		default:
+			out = append(out, e.runes...)
+		}
+	}
+	if fill >= 0 && len(out) < n {
+		more := make([]rune, n-len(out))
+		for i := range more {
+			more[i] = out[fill]
+		}
+		out = append(out[:fill+1], append(more, out[fill+1:]...)...)
+	}
+	return out
+}
+
+func identity(r rune) rune {
+	return r
+}
+
+func parse(del, sqz, compl bool, args []string) (*transformer, error) {
+	narg := len(args)
 	switch {
-	case narg == 0 || (narg == 1 && !*delete):
+	case narg == 0 || (narg == 1 && !del && !sqz):
 		return nil, fmt.Errorf("missing operand")
-	case narg > 1 && *delete:
+	case narg > 1 && del && !sqz:
 		return nil, fmt.Errorf("extra operand after %q", args[0])
 	case narg > 2:
 		return nil, fmt.Errorf("extra operand after %q", args[1])
 	}
 
 	set1 := Set(args[0])
-	arg1, err := unescape(set1)
+	elems1, err := parseSet(set1)
 	if err != nil {
 		return nil, err
 	}
+	in1 := contains(elems1)
+	if compl {
+		in := in1
+		in1 = func(r rune) bool { return !in(r) }
+	}
+
+	var t *transformer
+	switch {
+	case del:
+		t = &transformer{transform: func(r rune) rune {
+			if in1(r) {
+				return unicode.ReplacementChar
+			}
+			return r
+		}}
+	case narg == 1:
+		t = &transformer{transform: identity}
+	default:
+		if t, err = translate(set1, Set(args[1]), elems1, in1, compl); err != nil {
+			return nil, err
+		}
+	}
 
-	var set2 Set
-	if *delete {
-		set2 = Set(unicode.ReplacementChar)
-	} else {
-		set2 = Set(args[1])
+	if sqz {
+		t.squeeze = in1
+		if narg == 2 {
+			elems2, err := parseSet(Set(args[1]))
+			if err != nil {
+				return nil, err
+			}
+			t.squeeze = contains(elems2)
+		}
 	}
+	return t, nil
+}
 
-	if set1 == LOWER && set2 == UPPER {
+// translate returns the transformer that translates set1, parsed as
+// elems1 with the members in1, to set2.
+func translate(set1, set2 Set, elems1 []element, in1 func(rune) bool, compl bool) (*transformer, error) {
+	if !compl && set1 == LOWER && set2 == UPPER {
 		return lowerToUpper(), nil
 	}
-	if set1 == UPPER && set2 == LOWER {
+	if !compl && set1 == UPPER && set2 == LOWER {
 		return upperToLower(), nil
 	}
 
@@ -218,17 +419,30 @@ func parse() (*transformer, error) {
 		return nil, fmt.Errorf("misaligned [:upper:] and/or [:lower:] construct")
 	}
 
-	if _, ok := sets[set2]; ok {
-		return nil, fmt.Errorf(`the only character classes that may appear in SET2 are 'upper' and 'lower'`)
-	}
-
-	arg2, err := unescape(set2)
+	elems2, err := parseSet(set2)
 	if err != nil {
 		return nil, err
 	}
+	for _, e := range elems2 {
+		if e.class != "" {
+			return nil, fmt.Errorf(`the only character classes that may appear in SET2 are 'upper' and 'lower'`)
+		}
+	}
+
+	arg1 := expand(elems1, 0)
+	arg2 := expand(elems2, len(arg1))
 	if len(arg2) == 0 {
 		return nil, fmt.Errorf("SET2 must be non-empty")
 	}
+	if compl {
+		last := arg2[len(arg2)-1]
+		return &transformer{transform: func(r rune) rune {
+			if in1(r) {
+				return last
+			}
+			return r
+		}}, nil
+	}
 	if _, ok := sets[set1]; ok {
 		return setToRune(set1, arg2[0]), nil
 	}
@@ -238,7 +452,19 @@ func parse() (*transformer, error) {
 func unescape(s Set) ([]rune, error) {
 	var out []rune
 	var escape bool
-	for _, r := range s {
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		if escape && r >= '0' && r <= '7' {
+			v := r - '0'
+			for j := 0; j < 2 && i+1 < len(rs) && rs[i+1] >= '0' && rs[i+1] <= '7'; j++ {
+				i++
+				v = v*8 + rs[i] - '0'
+			}
+			out = append(out, v)
+			escape = false
+			continue
+		}
 		if escape {
 			v, ok := escapeChars[r]
 			if !ok {
@@ -260,7 +486,8 @@ func unescape(s Set) ([]rune, error) {
 }
 
 func main() {
-	t, err := parse()
+	flag.Parse()
+	t, err := parse(*delete, *squeeze, *complement, flag.Args())
 	if err != nil {
 		log.Fatalf("%s: %v\n", name, err)
 	}
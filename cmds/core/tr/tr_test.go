@@ -123,6 +123,61 @@ func TestTR(t *testing.T) {
 	}
 }
 
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		args   []string
+		del    bool
+		sqz    bool
+		compl  bool
+		input  string
+		output string
+	}{
+		{name: "range", args: []string{"a-z", "A-Z"}, input: "hello, World", output: "HELLO, WORLD"},
+		{name: "rot13", args: []string{"A-Za-z", "N-ZA-Mn-za-m"}, input: "Hello", output: "Uryyb"},
+		{name: "class and chars", args: []string{"[:digit:]_", "#"}, input: "a_1b2", output: "a##b#"},
+		{name: "octal", args: []string{"\\015\\012", "xy"}, input: "a\r\n", output: "axy"},
+		{name: "repeat", args: []string{"a-e", "[x*2]yz"}, input: "abcde", output: "xxyzz"},
+		{name: "fill", args: []string{"a-e", "[x*]z"}, input: "abcde", output: "xxxxz"},
+		{name: "delete range", args: []string{"0-9"}, del: true, input: "a1b22c", output: "abc"},
+		{name: "delete complement", args: []string{"a-z\\n"}, del: true, compl: true, input: "Hi there!\n", output: "ithere\n"},
+		{name: "squeeze", args: []string{" "}, sqz: true, input: "a   b  c d", output: "a b c d"},
+		{name: "squeeze class", args: []string{"[:space:]"}, sqz: true, input: "a \t\n\nb", output: "a \t\nb"},
+		{name: "translate and squeeze", args: []string{"[:alpha:]", "x"}, sqz: true, input: "ab cd1", output: "x x1"},
+		{name: "complement translate", args: []string{"[:alnum:]", "\\n"}, compl: true, sqz: true, input: "one, two  three", output: "one\ntwo\nthree"},
+		{name: "delete and squeeze", args: []string{"0-9", " "}, del: true, sqz: true, input: "a 1 2 b", output: "a b"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tr, err := parse(test.del, test.sqz, test.compl, test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := &bytes.Buffer{}
+			if err := tr.run(bytes.NewBufferString(test.input), out); err != nil {
+				t.Fatal(err)
+			}
+			if res := out.String(); test.output != res {
+				t.Errorf("run() want %q, got %q", test.output, res)
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"a"},
+		{"z-a", "b"},
+		{"[:foo:]", "b"},
+		{"a", "[:digit:]"},
+		{"a", "[x*z]"},
+		{"a", ""},
+		{"a", "b", "c"},
+	} {
+		if _, err := parse(false, false, false, args); err == nil {
+			t.Errorf("parse(%q) succeeded", args)
+		}
+	}
+}
+
 func TestMain(m *testing.M) {
 	testutil.Run(m, main)
 }
@@ -8,6 +8,14 @@
 //
 //	truncate [OPTIONS] [FILE]...
 //
+// Description:
+//
+//	The size given with -s may have a unit suffix such as K, M or G, and
+//	it may be relative to the current size: +N extends by N bytes, -N
+//	shrinks by N bytes, <N shrinks to at most N bytes, >N extends to at
+//	least N bytes, /N rounds down to a multiple of N bytes and %N rounds
+//	up to a multiple of N bytes. Files are extended sparsely.
+//
 // Options:
 //
 //	-s: size in bytes
@@ -24,6 +32,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/rck/unit"
 	"github.com/u-root/u-root/pkg/uroot/util"
@@ -35,13 +44,73 @@ var (
 	create = flag.Bool("c", false, "Do not create files.")
 	size   = unit.MustNewUnit(unit.DefaultUnits).MustNewValue(1, unit.None)
 	rfile  = flag.String("r", "", "Reference file for size")
+
+	// sizeOp is the prefix <, >, / or % of the size, or 0.
+	sizeOp byte
 )
 
+// sizeFlag parses -s: the prefixes + and - are handled by unit, and the
+// others are kept in sizeOp.
+type sizeFlag struct{}
+
+func (sizeFlag) String() string {
+	if sizeOp == 0 {
+		return size.String()
+	}
+	return string(sizeOp) + size.String()
+}
+
+func (sizeFlag) Set(s string) error {
+	sizeOp, size.ExplicitSign = 0, unit.None
+	if len(s) > 0 && strings.IndexByte("<>/%", s[0]) >= 0 {
+		sizeOp, s = s[0], s[1:]
+	}
+	if err := size.Set(s); err != nil {
+		return err
+	}
+	if sizeOp != 0 && size.ExplicitSign != unit.None {
+		return fmt.Errorf("%c cannot be combined with a sign", sizeOp)
+	}
+	if (sizeOp == '/' || sizeOp == '%') && size.Value == 0 {
+		return fmt.Errorf("division by zero")
+	}
+	return nil
+}
+
 func init() {
-	flag.Var(size, "s", "Size in bytes, prefixes +/- are allowed")
+	flag.Var(sizeFlag{}, "s", "Size in bytes, prefixes +, -, <, >, / and % are allowed")
 	flag.Usage = util.Usage(flag.Usage, usage)
 }
 
+// newSize returns the size of a file of size cur after applying -s.
+func newSize(cur int64) int64 {
+	v := size.Value
+	switch sizeOp {
+	case '<':
+		if cur < v {
+			return cur
+		}
+		return v
+	case '>':
+		if cur > v {
+			return cur
+		}
+		return v
+	case '/':
+		return cur / v * v
+	case '%':
+		return (cur + v - 1) / v * v
+	}
+	final := v // base case
+	if size.ExplicitSign != unit.None {
+		final += cur // in case of '-', size.Value is already negative
+	}
+	if final < 0 {
+		final = 0
+	}
+	return final
+}
+
 func truncate(args ...string) error {
 	if !size.IsSet && *rfile == "" {
 		return fmt.Errorf("you need to specify size via -s <number> or -r <rfile>")
@@ -57,13 +126,18 @@ func truncate(args ...string) error {
 
 		var final int64
 		st, err := os.Stat(fname)
-		if os.IsNotExist(err) && !*create {
+		if os.IsNotExist(err) {
+			if *create {
+				continue
+			}
 			if err = os.WriteFile(fname, []byte{}, 0o644); err != nil {
 				return fmt.Errorf("%v", err)
 			}
 			if st, err = os.Stat(fname); err != nil {
 				return fmt.Errorf("could not stat newly created file: %v", err)
 			}
+		} else if err != nil {
+			return err
 		}
 		if *rfile != "" {
 			if st, err = os.Stat(*rfile); err != nil {
@@ -71,13 +145,7 @@ func truncate(args ...string) error {
 			}
 			final = st.Size()
 		} else if size.IsSet {
-			final = size.Value // base case
-			if size.ExplicitSign != unit.None {
-				final += st.Size() // in case of '-', size.Value is already negative
-			}
-			if final < 0 {
-				final = 0
-			}
+			final = newSize(st.Size())
 		}
 
 		// intentionally ignore, like GNU truncate
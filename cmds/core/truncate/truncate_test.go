@@ -96,3 +96,49 @@ func TestTruncate(t *testing.T) {
 		})
 	}
 }
+
+func TestSizeOps(t *testing.T) {
+	// TestTruncate leaves a size without units behind.
+	*size = *unit.MustNewUnit(unit.DefaultUnits).MustNewValue(1, unit.None)
+	defer func() { sizeOp = 0 }()
+	for _, tt := range []struct {
+		s    string
+		cur  int64
+		want int64
+	}{
+		{"4K", 10, 4096},
+		{"+1K", 10, 1034},
+		{"-20", 10, 0},
+		{"<100", 10, 10},
+		{"<100", 1000, 100},
+		{">100", 10, 100},
+		{">100", 1000, 1000},
+		{"/512", 1000, 512},
+		{"%512", 1000, 1024},
+		{"%512", 1024, 1024},
+	} {
+		if err := (sizeFlag{}).Set(tt.s); err != nil {
+			t.Errorf("-s %s: %v", tt.s, err)
+			continue
+		}
+		if got := newSize(tt.cur); got != tt.want {
+			t.Errorf("-s %s of %d bytes = %d, want %d", tt.s, tt.cur, got, tt.want)
+		}
+	}
+	for _, bad := range []string{"/0", "<+5", "x"} {
+		if err := (sizeFlag{}).Set(bad); err == nil {
+			t.Errorf("-s %s succeeded", bad)
+		}
+	}
+
+	// -c skips missing files.
+	*create, *rfile = true, ""
+	defer func() { *create = false }()
+	name := filepath.Join(t.TempDir(), "missing")
+	if err := truncate(name); err != nil {
+		t.Errorf("truncate -c %s: %v", name, err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("truncate -c created %s", name)
+	}
+}
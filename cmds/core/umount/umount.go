@@ -9,12 +9,23 @@
 //
 // Synopsis:
 //
-//	umount [-f | -l] PATH
+//	umount [-f | -l] [-R] PATH...
+//	umount -a [-f | -l] [-t TYPES]
+//
+// Description:
+//
+//	-a unmounts every file system but the root, and proc, devfs, devpts,
+//	sysfs, rpc_pipefs and nfsd, newest first. TYPES is a comma separated
+//	list of the file system types to unmount; a type with a "no" prefix,
+//	such as noproc, is left alone instead.
 //
 // Options:
 //
+//	-a: unmount all file systems
 //	-f: force unmount
 //	-l: lazy unmount
+//	-R: also unmount everything mounted below PATH, deepest first
+//	-t: with -a, only unmount these file system types
 package main
 
 import "log"
@@ -7,21 +7,128 @@ package main
 import (
 	"errors"
 	"flag"
+	"log"
+	"path/filepath"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/mount"
 )
 
 var (
-	force = flag.Bool("f", false, "Force unmount")
-	lazy  = flag.Bool("l", false, "Lazy unmount")
+	force     = flag.Bool("f", false, "Force unmount")
+	lazy      = flag.Bool("l", false, "Lazy unmount")
+	recursive = flag.Bool("R", false, "Recursively unmount everything mounted below PATH")
+	all       = flag.Bool("a", false, "Unmount all file systems")
+	types     = flag.String("t", "", "With -a, only unmount file systems of these types")
 )
 
+// skipped are the file systems that -a leaves alone unless -t names them.
+var skipped = map[string]bool{
+	"proc":       true,
+	"devfs":      true,
+	"devpts":     true,
+	"sysfs":      true,
+	"rpc_pipefs": true,
+	"nfsd":       true,
+}
+
+// typeFilter returns whether a file system type is in the comma-separated
+// list of types, where a "no" prefix excludes a type instead.
+func typeFilter(list string) func(string) bool {
+	if list == "" {
+		return func(t string) bool { return !skipped[t] }
+	}
+	include := map[string]bool{}
+	exclude := map[string]bool{}
+	for _, t := range strings.Split(list, ",") {
+		if strings.HasPrefix(t, "no") {
+			exclude[t[2:]] = true
+		} else {
+			include[t] = true
+		}
+	}
+	return func(t string) bool {
+		if len(include) > 0 {
+			return include[t]
+		}
+		return !exclude[t]
+	}
+}
+
+// selectAll returns the mounts that -a unmounts, in the order to unmount
+// them in. The root is never unmounted.
+func selectAll(mounts []*mount.MountInfo, list string) []*mount.MountInfo {
+	match := typeFilter(list)
+	var l []*mount.MountInfo
+	for i := len(mounts) - 1; i >= 0; i-- {
+		if m := mounts[i]; m.Path != "/" && match(m.FSType) {
+			l = append(l, m)
+		}
+	}
+	return l
+}
+
+func unmountAll(mounts []*mount.MountInfo) error {
+	var failed error
+	for _, m := range mounts {
+		if err := mount.Unmount(m.Path, *force, *lazy); err != nil {
+			log.Print(err)
+			failed = errors.New("some file systems could not be unmounted")
+		}
+	}
+	return failed
+}
+
 func umount() error {
 	flag.Parse()
 	a := flag.Args()
-	if len(a) != 1 {
-		return errors.New("usage: umount [-f | -l] path")
+	if *all {
+		if len(a) != 0 {
+			return errors.New("usage: umount -a [-f | -l] [-t TYPES]")
+		}
+		mounts, err := mount.ReadMountInfo()
+		if err != nil {
+			return err
+		}
+		return unmountAll(selectAll(mounts, *types))
+	}
+	if *types != "" {
+		return errors.New("-t only works with -a")
+	}
+	if len(a) == 0 {
+		return errors.New("usage: umount [-f | -l] [-R] PATH...")
+	}
+	var mounts []*mount.MountInfo
+	if *recursive {
+		var err error
+		if mounts, err = mount.ReadMountInfo(); err != nil {
+			return err
+		}
+	}
+	var failed error
+	for _, path := range a {
+		if !*recursive {
+			if err := mount.Unmount(path, *force, *lazy); err != nil {
+				log.Print(err)
+				failed = errors.New("some file systems could not be unmounted")
+			}
+			continue
+		}
+		p, err := filepath.Abs(path)
+		if err == nil {
+			p, err = filepath.EvalSymlinks(p)
+		}
+		var m *mount.MountInfo
+		if err == nil {
+			m, err = mount.FindMount(mounts, p)
+		}
+		if err == nil {
+			err = unmountAll(m.Submounts())
+		}
+		if err != nil {
+			log.Print(err)
+			failed = errors.New("some file systems could not be unmounted")
+		}
 	}
-	path := a[0]
-	return mount.Unmount(path, *force, *lazy)
+	return failed
 }
@@ -0,0 +1,43 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount"
+)
+
+func TestSelectAll(t *testing.T) {
+	mounts, err := mount.ParseMountInfo(strings.NewReader(`1 1 0:2 / / rw - rootfs rootfs rw
+22 1 0:20 / /proc rw - proc proc rw
+25 1 0:6 / /dev rw - devtmpfs devtmpfs rw
+27 25 0:25 / /dev/pts rw - devpts devpts rw
+30 1 8:1 / /mnt rw - ext4 /dev/sda1 rw
+31 30 0:31 / /mnt/tmp rw - tmpfs tmpfs rw
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		types string
+		want  []string
+	}{
+		{"", []string{"/mnt/tmp", "/mnt", "/dev"}},
+		{"tmpfs,ext4", []string{"/mnt/tmp", "/mnt"}},
+		{"proc", []string{"/proc"}},
+		{"notmpfs,noproc", []string{"/mnt", "/dev/pts", "/dev"}},
+	} {
+		var got []string
+		for _, m := range selectAll(mounts, tt.types) {
+			got = append(got, m.Path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectAll(%q) = %q, want %q", tt.types, got, tt.want)
+		}
+	}
+}
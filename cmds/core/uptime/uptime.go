@@ -2,62 +2,146 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Get the time the machine has been up
+// Get the time the machine has been up, and the load average.
+//
 // Synopsis:
 //
-//	uptime
+//	uptime [-p] [-s]
+//
+// Description:
+//
+//	uptime prints the time, how long the system has been up, and the
+//	average number of runnable and uninterruptible tasks over the last 1,
+//	5 and 15 minutes, as in
+//
+//	 15:04:05 up 3 days,  4:05,  load average: 0.60, 0.70, 0.74
+//
+// Options:
+//
+//	-p: print only how long the system has been up, in words
+//	-s: print only the time the system came up
 package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
 	"time"
 )
 
+var (
+	pretty = flag.Bool("p", false, "print only how long the system has been up, in words")
+	since  = flag.Bool("s", false, "print only the time the system came up")
+)
+
+// Files the uptime and load average are read from. Tests change them.
+var (
+	procUptime  = "/proc/uptime"
+	procLoadavg = "/proc/loadavg"
+)
+
+var errUsage = errors.New("usage: uptime [-p] [-s]")
+
 // loadavg takes in the contents of proc/loadavg,it then extracts and returns the three load averages as a string
 func loadavg(contents string) (loadaverage string, err error) {
 	loadavg := strings.Fields(contents)
 	if len(loadavg) < 3 {
 		return "", fmt.Errorf("error:invalid contents:the contents of proc/loadavg we are trying to process contain less than the required 3 loadavgs")
 	}
-	return loadavg[0] + "," + loadavg[1] + "," + loadavg[2], nil
+	return loadavg[0] + ", " + loadavg[1] + ", " + loadavg[2], nil
 }
 
-// uptime takes in the contents of proc/uptime it then extracts and returns the uptime in the format Days , Hours , Minutes ,Seconds
-func uptime(contents string) (*time.Time, error) {
+// uptime takes in the contents of proc/uptime it then extracts and returns the uptime
+func uptime(contents string) (time.Duration, error) {
 	uptimeArray := strings.Fields(contents)
 	if len(uptimeArray) == 0 {
-		return nil, errors.New("error:the contents of proc/uptime we are trying to read are empty")
+		return 0, errors.New("error:the contents of proc/uptime we are trying to read are empty")
 	}
-	uptimeDuration, err := time.ParseDuration(string(uptimeArray[0]) + "s")
-	if err != nil {
-		return nil, err
+	return time.ParseDuration(uptimeArray[0] + "s")
+}
+
+// plural returns n and the unit, in the plural unless n is 1.
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
 	}
-	uptime := time.Time{}.Add(uptimeDuration)
+	return fmt.Sprintf("%d %ss", n, unit)
+}
 
-	return &uptime, nil
+// up formats the uptime d as "3 days,  4:05", or "5 min" in the first hour.
+func up(d time.Duration) string {
+	days, hours, mins := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	var s string
+	if days > 0 {
+		s = plural(days, "day") + ", "
+	}
+	if hours > 0 {
+		return s + fmt.Sprintf("%2d:%02d", hours, mins)
+	}
+	return s + fmt.Sprintf("%d min", mins)
 }
 
-func main() {
-	procUptimeOutput, err := os.ReadFile("/proc/uptime")
+// upPretty formats the uptime d as "up 3 days, 4 hours, 5 minutes".
+func upPretty(d time.Duration) string {
+	var parts []string
+	for _, u := range []struct {
+		name string
+		n    int
+	}{
+		{"week", int(d / (7 * 24 * time.Hour))},
+		{"day", int(d/(24*time.Hour)) % 7},
+		{"hour", int(d/time.Hour) % 24},
+		{"minute", int(d/time.Minute) % 60},
+	} {
+		if u.n > 0 {
+			parts = append(parts, plural(u.n, u.name))
+		}
+	}
+	if len(parts) == 0 {
+		parts = []string{"0 minutes"}
+	}
+	return "up " + strings.Join(parts, ", ")
+}
+
+func run(out io.Writer, now time.Time) error {
+	if flag.NArg() != 0 || (*pretty && *since) {
+		return errUsage
+	}
+	procUptimeOutput, err := os.ReadFile(procUptime)
 	if err != nil {
-		log.Fatalf("error reading /proc/uptime: %v \n", err)
+		return fmt.Errorf("error reading %s: %v", procUptime, err)
 	}
-	uptimeTime, err := uptime(string(procUptimeOutput))
+	d, err := uptime(string(procUptimeOutput))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	procLoadAvgOutput, err := os.ReadFile("/proc/loadavg")
+	switch {
+	case *pretty:
+		_, err := fmt.Fprintln(out, upPretty(d))
+		return err
+	case *since:
+		_, err := fmt.Fprintln(out, now.Add(-d).Format("2006-01-02 15:04:05"))
+		return err
+	}
+	procLoadAvgOutput, err := os.ReadFile(procLoadavg)
 	if err != nil {
-		log.Fatalf("error reading /proc/loadavg: %v \n", err)
+		return fmt.Errorf("error reading %s: %v", procLoadavg, err)
 	}
 	loadAverage, err := loadavg(string(procLoadAvgOutput))
 	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, " %s up %s,  load average: %s\n", now.Format("15:04:05"), up(d), loadAverage)
+	return err
+}
+
+func main() {
+	flag.Parse()
+	if err := run(os.Stdout, time.Now()); err != nil {
 		log.Fatal(err)
 	}
-	// Subtracted one from time.Day() because time.Add(Duration) starts counting at 1 day instead of zero days.
-	fmt.Printf(" %s up %d days, %d hours , %d min ,loadaverage: %s \n", time.Now().Format("15:04:05"), (uptimeTime.Day() - 1), uptimeTime.Hour(), uptimeTime.Minute(), loadAverage)
 }
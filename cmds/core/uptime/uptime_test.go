@@ -5,11 +5,14 @@
 package main
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
-var testTime = time.Date(0o001, 1, 15, 5, 35, 49, 0, time.UTC)
+var testUptime = 14*24*time.Hour + 5*time.Hour + 35*time.Minute + 49*time.Second
 
 func invalidDurationError(d string) string {
 	_, err := time.ParseDuration(d)
@@ -20,26 +23,24 @@ func TestUptime(t *testing.T) {
 	tests := []struct {
 		name   string
 		input  string
-		uptime *time.Time
+		uptime time.Duration
 		err    string
 	}{
 		{
 			name:   "goodInput",
 			input:  "1229749 1422244",
-			uptime: &testTime,
+			uptime: testUptime,
 			err:    "",
 		},
 		{
-			name:   "badDataInput",
-			input:  "string",
-			uptime: nil,
-			err:    invalidDurationError("strings"),
+			name:  "badDataInput",
+			input: "string",
+			err:   invalidDurationError("strings"),
 		},
 		{
-			name:   "emptyDataInput",
-			input:  "",
-			uptime: nil,
-			err:    "error:the contents of proc/uptime we are trying to read are empty",
+			name:  "emptyDataInput",
+			input: "",
+			err:   "error:the contents of proc/uptime we are trying to read are empty",
 		},
 	}
 
@@ -51,12 +52,8 @@ func TestUptime(t *testing.T) {
 			} else if err != nil && err.Error() != test.err {
 				t.Errorf("uptime(%q) err = %q, want %q", test.input, err.Error(), test.err)
 			}
-			if gotUptime == nil && test.uptime != nil {
-				t.Errorf("uptime(%q) = nil, want %v", test.input, *test.uptime)
-			} else if gotUptime != nil && test.uptime != nil && *gotUptime != *test.uptime {
-				t.Errorf("uptime(%q) = %v, want %v", test.input, *gotUptime, *test.uptime)
-			} else if gotUptime != nil && test.uptime == nil {
-				t.Errorf("uptime(%q) = %v, want nil", test.input, *gotUptime)
+			if gotUptime != test.uptime {
+				t.Errorf("uptime(%q) = %v, want %v", test.input, gotUptime, test.uptime)
 			}
 		})
 	}
@@ -69,7 +66,7 @@ func TestLoadAverage(t *testing.T) {
 		loadAverage string
 		err         string
 	}{
-		{"goodInput", "0.60 0.70 0.74", "0.60,0.70,0.74", ""},
+		{"goodInput", "0.60 0.70 0.74", "0.60, 0.70, 0.74", ""},
 		{"badDataInput", "1.00 2.00", "", "error:invalid contents:the contents of proc/loadavg we are trying to process contain less than the required 3 loadavgs"},
 	}
 
@@ -91,3 +88,58 @@ func TestLoadAverage(t *testing.T) {
 		})
 	}
 }
+
+func TestUp(t *testing.T) {
+	for _, tt := range []struct {
+		d      time.Duration
+		up     string
+		pretty string
+	}{
+		{d: 30 * time.Second, up: "0 min", pretty: "up 0 minutes"},
+		{d: 5 * time.Minute, up: "5 min", pretty: "up 5 minutes"},
+		{d: time.Hour + time.Minute, up: " 1:01", pretty: "up 1 hour, 1 minute"},
+		{d: 24*time.Hour + 3*time.Minute, up: "1 day, 3 min", pretty: "up 1 day, 3 minutes"},
+		{d: testUptime, up: "14 days,  5:35", pretty: "up 2 weeks, 5 hours, 35 minutes"},
+		{d: 400 * 24 * time.Hour, up: "400 days, 0 min", pretty: "up 57 weeks, 1 day"},
+	} {
+		if got := up(tt.d); got != tt.up {
+			t.Errorf("up(%v) = %q, want %q", tt.d, got, tt.up)
+		}
+		if got := upPretty(tt.d); got != tt.pretty {
+			t.Errorf("upPretty(%v) = %q, want %q", tt.d, got, tt.pretty)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	defer func(u, l string) { procUptime, procLoadavg = u, l }(procUptime, procLoadavg)
+	procUptime, procLoadavg = filepath.Join(dir, "uptime"), filepath.Join(dir, "loadavg")
+	if err := os.WriteFile(procUptime, []byte("1229749.52 1422244.01\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(procLoadavg, []byte("0.60 0.70 0.74 1/123 4567\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2022, 3, 4, 15, 4, 5, 0, time.UTC)
+
+	for _, tt := range []struct {
+		pretty, since bool
+		want          string
+	}{
+		{want: " 15:04:05 up 14 days,  5:35,  load average: 0.60, 0.70, 0.74\n"},
+		{pretty: true, want: "up 2 weeks, 5 hours, 35 minutes\n"},
+		{since: true, want: "2022-02-18 09:28:15\n"},
+	} {
+		*pretty, *since = tt.pretty, tt.since
+		var out bytes.Buffer
+		if err := run(&out, now); err != nil || out.String() != tt.want {
+			t.Errorf("run(-p=%v, -s=%v) = %q, %v, want %q", tt.pretty, tt.since, out.String(), err, tt.want)
+		}
+	}
+	*pretty, *since = true, true
+	if err := run(&bytes.Buffer{}, now); err != errUsage {
+		t.Errorf("run(-p -s) = %v, want %v", err, errUsage)
+	}
+	*pretty, *since = false, false
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// linkRE matches the links of HTML pages, in the first group that matched.
+var linkRE = regexp.MustCompile(`(?is)<(?:a|link|img|script|source)\b[^>]*?\s(?:href|src)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// pageLink is a page or file to download when mirroring, at depth links from
+// the first page.
+type pageLink struct {
+	u     *url.URL
+	depth int
+}
+
+// mirror downloads the page at rawURL, and the pages and files it links to,
+// recursively, as -l, -np, -A and -R say, into HOST/PATH.
+func (f *fetcher) mirror(rawURL string) error {
+	root, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if root.Scheme != "http" && root.Scheme != "https" {
+		return fmt.Errorf("-r takes HTTP and HTTPS URLs, not %v", rawURL)
+	}
+	root.Fragment = ""
+
+	queue := []pageLink{{u: root}}
+	seen := map[string]bool{root.String(): true}
+	var done, failed, code int
+	for len(queue) > 0 {
+		l := queue[0]
+		queue = queue[1:]
+
+		p := mirrorPath(l.u)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := f.download(l.u.String(), p); err != nil {
+			log.Print(err)
+			failed++
+			code = worse(code, exitCode(err))
+			continue
+		}
+		done++
+
+		if (*depth == 0 || l.depth < *depth) && (looksHTML(l.u) || isHTML(p)) {
+			links, err := readLinks(p, l.u)
+			if err != nil {
+				return err
+			}
+			for _, u := range links {
+				if seen[u.String()] || !follow(root, u) {
+					continue
+				}
+				seen[u.String()] = true
+				// Pages are downloaded even if rejected, to find
+				// the files they link to.
+				if accepted(u) || looksHTML(u) {
+					queue = append(queue, pageLink{u: u, depth: l.depth + 1})
+				}
+			}
+		}
+		if !accepted(l.u) {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+		}
+	}
+	if failed > 0 {
+		return exitError{fmt.Errorf("mirroring %v: %d of %d downloads failed", rawURL, failed, done+failed), code}
+	}
+	return nil
+}
+
+// mirrorPath returns the path to mirror u into: HOST/PATH, with index.html
+// for directories, in -P.
+func mirrorPath(u *url.URL) string {
+	p := path.Clean("/" + u.Path)
+	if strings.HasSuffix(u.Path, "/") || p == "/" {
+		p = path.Join(p, "index.html")
+	}
+	return filepath.Join(*prefix, u.Host, filepath.FromSlash(p))
+}
+
+// looksHTML returns whether u looks like an HTML page from its path.
+func looksHTML(u *url.URL) bool {
+	switch ext := strings.ToLower(path.Ext(u.Path)); {
+	case u.Path == "", strings.HasSuffix(u.Path, "/"):
+		return true
+	case ext == ".html", ext == ".htm":
+		return true
+	}
+	return false
+}
+
+// isHTML returns whether the file at p is an HTML page from its content.
+func isHTML(p string) bool {
+	f, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	b := make([]byte, 512)
+	n, _ := io.ReadFull(f, b)
+	return strings.HasPrefix(http.DetectContentType(b[:n]), "text/html")
+}
+
+// readLinks returns the links of the HTML page at p, downloaded from base,
+// without their fragments.
+func readLinks(p string, base *url.URL) ([]*url.URL, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var links []*url.URL
+	for _, m := range linkRE.FindAllSubmatch(b, -1) {
+		ref := string(m[1]) + string(m[2]) + string(m[3])
+		u, err := base.Parse(html.UnescapeString(strings.TrimSpace(ref)))
+		if err != nil {
+			continue
+		}
+		u.Fragment = ""
+		links = append(links, u)
+	}
+	return links, nil
+}
+
+// follow returns whether to follow the link u found mirroring root: to the
+// same server, under the directory of root with -np, and without a query,
+// like the links that sort autoindex pages.
+func follow(root, u *url.URL) bool {
+	if u.Scheme != root.Scheme || u.Host != root.Host || u.RawQuery != "" {
+		return false
+	}
+	if *noParent {
+		dir := root.Path[:strings.LastIndex(root.Path, "/")+1]
+		return strings.HasPrefix(u.Path, dir)
+	}
+	return true
+}
+
+// accepted returns whether the file at u is kept, as -A and -R say.
+func accepted(u *url.URL) bool {
+	name := path.Base(mirrorPath(u))
+	return (*accept == "" || matchAny(*accept, name)) && (*reject == "" || !matchAny(*reject, name))
+}
+
+// matchAny returns whether name matches one of the comma separated patterns:
+// shell patterns if they have wildcards, or else suffixes, as with GNU wget.
+func matchAny(patterns, name string) bool {
+	for _, p := range strings.Split(patterns, ",") {
+		if strings.ContainsAny(p, "*?[") {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		} else if p != "" && strings.HasSuffix(name, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,42 +2,312 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Wget reads one file from a url and writes to stdout.
+// Wget downloads files from URLs.
 //
 // Synopsis:
 //
-//	wget URL
+//	wget [-O FILE | -i FILE] [-P DIR] [-c | -N] [-q | -v | -nv] [-sign SIGNER]
+//	     [-t TRIES] [-T SECONDS] [-waitretry SECONDS] [-retry-connrefused]
+//	     [-proxy PROXY] [-cacert FILE] [-cert FILE [-key FILE]] [-pin PINS]
+//	     [-cache DIR [-cache-size SIZE]] [-limit-rate RATE] [-tftp OPTIONS]
+//	     [-resolve HOSTS] [-dns-server ADDR | -doh-url URL] [-segments N]
+//	     [-4 | -6] [-interface IFACE] [-bind-address ADDR]
+//	     [-r | -m [-l DEPTH] [-np] [-A LIST] [-R LIST]] [-header HEADER]...
+//	     [-user USER [-password PASSWORD]] [-user-agent AGENT]
+//	     [-method METHOD] [-post-data DATA | -post-file FILE]
+//	     [-sha256 HEX] [-pgp-keyring FILE] [-spider] [-S] [URL...]
 //
 // Description:
 //
-//	Returns a non-zero code on failure.
+//	Each URL is downloaded into the last element of its path, or into
+//	index.html, or into FILE with -O, which takes only one URL, or to
+//	stdout if FILE is -. With -i,
+//	the URLs in FILE, or stdin if FILE is -, are downloaded too: one per
+//	line, but for empty lines and lines starting with #. A name that was
+//	downloaded into before gets a .1, .2... suffix. With -P, or
+//	-directory-prefix, files are downloaded into DIR rather than into the
+//	current directory.
+//
+//	Files are downloaded next to where they go, and only renamed there
+//	once complete, so that an interrupted download never leaves a
+//	truncated file that later steps mistake for a complete one. Devices,
+//	e.g. -O /dev/sda, are written in place.
+//
+//	Returns a non-zero code if any download failed, after trying all of
+//	them, as GNU wget does, so that scripts can tell failures apart:
+//
+//	  1  generic error
+//	  2  invalid flags, or files they name
+//	  3  file I/O error, e.g. writing the file
+//	  4  network failure, e.g. a refused connection or a timeout
+//	  5  TLS verification failure
+//	  6  authentication failure: HTTP 401 or 407
+//	  7  protocol error, e.g. an unexpected HTTP code
+//	  8  the server returned an error, e.g. HTTP 404 or 503
+//
+//	If downloads fail with different codes, the lowest but 1 is returned.
+//
+//	Besides HTTP and HTTPS, URL may be tftp://, nfs://, ftp://, sftp://,
+//	with the keys and known hosts in ~/.ssh, or file://.
+//
+//	With -r, or -recursive, each URL is mirrored: the HTML page at URL is
+//	downloaded into HOST/PATH, with index.html for directories, and then
+//	the pages and files it links to on the same server, recursively, up to
+//	-l links away (5 by default, 0 for no limit). Links with a query, like
+//	the links that sort autoindex pages, are not followed. With -np, or
+//	-no-parent, only links under the directory of URL are followed, e.g. to
+//	mirror a release directory of an artifact server. With -A, only the
+//	files whose names match LIST are kept, and with -R, those that match
+//	are not: LIST is comma separated suffixes, or shell patterns, e.g.
+//	.img,*.sig. Pages are downloaded anyway to find the files they link
+//	to, and removed if not kept. -m, or -mirror, is -r -N -l 0: repeated
+//	mirrors only download what changed.
+//
+//	With -header, e.g. -header 'Authorization: Bearer TOKEN', HEADER is
+//	added to HTTP requests; it may be repeated. With -user, requests are
+//	sent with basic authentication, with -password or $WGET_PASSWORD, which
+//	keeps the password off the command line. With -user-agent, or -U, AGENT
+//	replaces the User-Agent of Go.
+//
+//	With -post-data or -post-file, requests are POSTs of DATA, or of the
+//	content of FILE, as application/x-www-form-urlencoded unless -header
+//	sets a Content-Type. With -method, requests use METHOD, e.g. PUT. Only
+//	GET requests are continued with -c or fetched in -segments.
+//
+//	With -sha256, the file at URL must have the SHA-256 digest HEX, and
+//	with -pgp-keyring, a detached OpenPGP signature at URL.sig by a key in
+//	FILE. The file is downloaded next to where it goes, and only moved
+//	there once it verifies: a file that does not verify is removed, and
+//	wget fails. -sha256 takes only one URL, and neither can be used with
+//	-c, -N or -r.
+//
+//	With -spider, HTTP and HTTPS URLs are only checked to exist, with HEAD
+//	requests, rather than downloaded, e.g. to probe that artifacts are
+//	available: wget fails if one is not, with the exit codes below. With
+//	-S, or -server-response, the status line and header of every HTTP
+//	response, redirects included, are printed to stderr, e.g. to see where
+//	a URL redirects to.
+//
+//	With -sign, or $CURL_SIGN, HTTP requests are signed for private
+//	artifact stores: aws-sigv4[:REGION[:SERVICE]] with the credentials in
+//	$AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY, e.g. for S3, or
+//	hmac[:KEYID] with the key in $CURL_HMAC_KEY.
+//
+//	With -proxy, HTTP requests go through PROXY, e.g. a Tor or other SOCKS
+//	gateway: socks5://[USER:PASSWORD@]HOST:PORT, or http://HOST:PORT. The
+//	proxy resolves host names. Otherwise the proxies in $HTTP_PROXY,
+//	$HTTPS_PROXY and $NO_PROXY are used, which may be SOCKS5 proxies too and
+//	keep the password off the command line.
+//
+//	With -resolve, a comma separated list of HOST=ADDRESS, host names are
+//	resolved to the given addresses, like with /etc/hosts. Other host names
+//	are resolved by the DNS server at -dns-server, HOST[:PORT], or with
+//	DNS-over-HTTPS at -doh-url, e.g. https://1.1.1.1/dns-query, rather than
+//	with /etc/resolv.conf, which may be missing or not trusted in early
+//	boot.
+//
+//	With -4 or -6, HTTP servers are only connected to over IPv4 or IPv6.
+//	With -interface, connections go through IFACE, whatever the routes
+//	say, e.g. on machines with more than one network interface where the
+//	default route is not the one to the server. This needs CAP_NET_RAW.
+//	With -bind-address, connections are made from ADDR, one of the
+//	addresses of the interface to go through.
+//
+//	With -cacert, HTTPS servers are verified with the CAs in FILE instead
+//	of the system's. With -cert, the client certificate in FILE is
+//	presented to servers that ask for one, with the key in -key, or in the
+//	same file. With -pin, a comma separated list of sha256//BASE64 hashes
+//	of public keys, the server must have one of the keys, in its
+//	certificate or an intermediate, as with curl --pinnedpubkey.
+//
+//	With -c, or -continue, a partial download of an HTTP or HTTPS URL in
+//	FILE is continued, unless the file changed on the server since, or the
+//	server does not support ranges, when it is downloaded again. The
+//	modification time of FILE is set to that of the URL, to tell. With -t
+//	too, the download is also continued after the connection breaks, e.g.
+//	to pull multi-GB images over flaky links.
+//
+//	With -N, or -timestamping, an HTTP or HTTPS URL is only downloaded if
+//	it was modified since the file it is downloaded into was, as its
+//	Last-Modified says, or if their sizes differ, and the modification time
+//	of the file is set to that of the URL. This makes repeated runs, e.g.
+//	of provisioning scripts, only download what changed. The file is only
+//	replaced once the download is complete.
+//
+//	With -t, or -tries, failures that may go away by themselves, like
+//	timeouts and server errors, are retried with exponential backoff, up
+//	to TRIES attempts in all, or forever if TRIES is 0. The wait between
+//	retries grows up to -waitretry seconds, 10 by default. Refused
+//	connections are only retried with -retry-connrefused, e.g. to wait for
+//	a server that is still starting.
+//
+//	With -T, or -timeout, resolving and connecting to HTTP servers, and
+//	then each read, time out after SECONDS, which may be fractional, so
+//	that a stalled server fails, and is retried with -t, rather than hangs.
+//
+//	With -cache, files are kept in DIR, and fetched from there the next
+//	time, rather than downloaded again. They are checked for corruption
+//	on the way out. With -cache-size, e.g. 2GiB, the least recently used
+//	files are removed to keep DIR under SIZE. The file at a URL is assumed
+//	not to change.
+//
+//	With -limit-rate, the download is read at most at RATE bytes per
+//	second, e.g. 500KiB or 2MB, not to starve other traffic.
+//
+//	With -tftp, the options of tftp:// downloads are negotiated with the
+//	server as OPTIONS, a comma separated list of blksize=BYTES,
+//	windowsize=BLOCKS, timeout=DURATION and retransmit=TRIES. Blocks of
+//	1450 bytes, 64 at a time, are asked for by default, which is many
+//	times faster than plain TFTP: on lossy networks, smaller windows may
+//	be faster.
+//
+//	With -segments, HTTP files of 4MiB or more are fetched with N range
+//	requests in parallel, e.g. from servers or CDNs that limit the rate of
+//	each connection, if the server supports ranges.
+//
+//	When stderr is a terminal, or with -v, -verbose or -progress, the
+//	percentage, size, rate and time left of each download are printed to
+//	stderr as it goes, e.g. to tell a hung download from a slow one on the
+//	console. With -nv, or -no-verbose, only a line with the size, time
+//	taken and rate of each download is printed once it is done, e.g. for
+//	logs. With -q, or -quiet, only errors are printed.
+//
+//	With -trace=curl, or $UROOT_TRACE=curl, every fetch, retry and HTTP
+//	response is traced to stderr.
 //
 // Notes:
 //
 //	There are a few differences with GNU wget:
-//	- Upon error, the return value is always 1.
 //	- The protocol (http/https) is mandatory.
+//	- Only one try is made by default, rather than 20.
 //
 // Example:
 //
 //	wget -O google.txt http://google.com/
+//	wget -i images.txt -c -t 5
+//	wget -N -P /var/cache/images https://artifacts.example.com/boot.img
+//	wget -r -np -A .img,.sig https://artifacts.example.com/releases/v1.2/
+//	wget -header "Authorization: Bearer $TOKEN" https://artifacts.example.com/boot.img
+//	wget -pgp-keyring /etc/keys.asc https://artifacts.example.com/boot.img
+//	wget -spider -S https://artifacts.example.com/latest/boot.img
+//	wget -O - https://artifacts.example.com/boot.cfg | grep kernel
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"math"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/u-root/u-root/pkg/curl"
 	"github.com/u-root/u-root/pkg/uio"
+	"github.com/u-root/u-root/pkg/ulog"
+	"github.com/u-root/u-root/pkg/vfile"
+	"golang.org/x/term"
+)
+
+var (
+	outPath   = flag.String("O", "", "output file")
+	prefix    = flag.String("P", "", "directory to download files into")
+	newer     = flag.Bool("N", false, "only download HTTP files modified since the files they are downloaded into")
+	inputFile = flag.String("i", "", "file to read URLs from, one per line, or - for stdin")
+	sign      = flag.String("sign", os.Getenv("CURL_SIGN"), "sign requests: aws-sigv4[:REGION[:SERVICE]] or hmac[:KEYID]")
+	proxy     = flag.String("proxy", "", "send requests through a proxy: socks5://[USER:PASSWORD@]HOST:PORT or http://HOST:PORT")
+	tries     = flag.Int("t", 1, "number of attempts, retrying timeouts and server errors, or 0 for no limit")
+	timeout   = flag.Float64("T", 0, "seconds to wait to connect, and then for each read, or 0 for no limit")
+	waitRetry = flag.Float64("waitretry", 10, "longest seconds to wait between retries")
+	refused   = flag.Bool("retry-connrefused", false, "retry refused connections too")
+	resume    = flag.Bool("c", false, "continue a partial download (HTTP and HTTPS only)")
+	verbose   = flag.Bool("v", false, "print the progress of each download to stderr, even if it is not a terminal")
+	noVerbose = flag.Bool("nv", false, "print one line per download to stderr once it is done")
+	quiet     = flag.Bool("q", false, "print only errors")
+	caCert    = flag.String("cacert", "", "PEM file of the CAs to verify HTTPS servers with, instead of the system's")
+	cert      = flag.String("cert", "", "PEM file of the client certificate, and maybe its key")
+	key       = flag.String("key", "", "PEM file of the key of the client certificate")
+	pins      = flag.String("pin", "", "comma separated sha256//BASE64 hashes of the public keys HTTPS servers may have")
+	cacheDir  = flag.String("cache", "", "directory to keep downloaded files in, and fetch them from the next time")
+	cacheMax  = flag.String("cache-size", "", "size the -cache directory is kept under, e.g. 2GiB")
+	rate      = flag.String("limit-rate", "", "bytes per second to download at most, e.g. 500KiB")
+	resolve   = flag.String("resolve", "", "comma separated HOST=ADDRESS to resolve host names to")
+	dnsAddr   = flag.String("dns-server", "", "HOST[:PORT] of the DNS server to resolve host names with")
+	dohURL    = flag.String("doh-url", "", "URL of a DNS-over-HTTPS endpoint to resolve host names with")
+	tftpOpts  = flag.String("tftp", "", "TFTP options: blksize=BYTES,windowsize=BLOCKS,timeout=DURATION,retransmit=TRIES")
+	segments  = flag.Int("segments", 1, "fetch large HTTP files with N range requests in parallel")
+	ipv4      = flag.Bool("4", false, "connect over IPv4 only")
+	ipv6      = flag.Bool("6", false, "connect over IPv6 only")
+	iface     = flag.String("interface", "", "network interface to connect through")
+	bindAddr  = flag.String("bind-address", "", "source address of connections")
+	recursive = flag.Bool("r", false, "mirror the pages and files linked to from each URL, recursively")
+	mirrorAll = flag.Bool("m", false, "same as -r -N -l 0")
+	depth     = flag.Int("l", 5, "with -r, how many links away to follow, 0 for no limit")
+	noParent  = flag.Bool("np", false, "with -r, only follow links under the directory of the URL")
+	accept    = flag.String("A", "", "with -r, comma separated suffixes or patterns of the names of the files to keep")
+	reject    = flag.String("R", "", "with -r, comma separated suffixes or patterns of the names of the files not to keep")
+	user      = flag.String("user", "", "user to send HTTP requests with basic authentication as")
+	password  = flag.String("password", os.Getenv("WGET_PASSWORD"), "password of -user")
+	userAgent = flag.String("user-agent", "", "User-Agent of HTTP requests")
+	method    = flag.String("method", "", "method of HTTP requests, GET or POST by default")
+	postData  = flag.String("post-data", "", "send HTTP requests as POSTs of DATA")
+	postFile  = flag.String("post-file", "", "send HTTP requests as POSTs of the content of FILE")
+	sha256Hex = flag.String("sha256", "", "SHA-256 the file must have, in hex")
+	keyRing   = flag.String("pgp-keyring", "", "OpenPGP keys one of which must have signed the file, in URL.sig")
+	spider    = flag.Bool("spider", false, "only check that HTTP files exist, with HEAD requests")
+	responses = flag.Bool("S", false, "print the status line and header of HTTP responses to stderr")
+	headers   headerList
 )
 
-var outPath = flag.String("O", "", "output file")
+// headerList is the HTTP headers of -header.
+type headerList []string
+
+func (h *headerList) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerList) Set(s string) error {
+	if name, _, ok := strings.Cut(s, ":"); !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%q is not NAME: VALUE", s)
+	}
+	*h = append(*h, s)
+	return nil
+}
+
+func init() {
+	flag.Var(ulog.TraceFlag{}, "trace", ulog.TraceUsage)
+	flag.BoolVar(resume, "continue", false, "same as -c")
+	flag.StringVar(prefix, "directory-prefix", "", "same as -P")
+	flag.BoolVar(newer, "timestamping", false, "same as -N")
+	flag.BoolVar(recursive, "recursive", false, "same as -r")
+	flag.BoolVar(mirrorAll, "mirror", false, "same as -m")
+	flag.BoolVar(noParent, "no-parent", false, "same as -np")
+	flag.Var(&headers, "header", "HTTP header to add to requests, NAME: VALUE; may be repeated")
+	flag.StringVar(userAgent, "U", "", "same as -user-agent")
+	flag.BoolVar(verbose, "verbose", false, "same as -v")
+	flag.BoolVar(verbose, "progress", false, "same as -v")
+	flag.BoolVar(noVerbose, "no-verbose", false, "same as -nv")
+	flag.BoolVar(quiet, "quiet", false, "same as -q")
+	flag.IntVar(tries, "tries", 1, "same as -t")
+	flag.Float64Var(timeout, "timeout", 0, "same as -T")
+	flag.BoolVar(responses, "server-response", false, "same as -S")
+}
 
 func usage() {
 	log.Printf("Usage: %s [ARGS] URL\n", os.Args[0])
@@ -45,54 +315,773 @@ func usage() {
 	os.Exit(2)
 }
 
-func run() (reterr error) {
+func run() error {
 	log.SetPrefix("wget: ")
+	flag.Parse()
 
-	if flag.Parse(); flag.NArg() != 1 {
+	urls := flag.Args()
+	if *mirrorAll {
+		*recursive, *newer = true, true
+		depthSet := false
+		flag.Visit(func(f *flag.Flag) { depthSet = depthSet || f.Name == "l" })
+		if !depthSet {
+			*depth = 0
+		}
+	}
+	if *inputFile != "" {
+		more, err := readURLs(*inputFile)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, more...)
+	}
+	if len(urls) == 0 {
 		usage()
 	}
+	if *outPath != "" && len(urls) > 1 {
+		return exitError{errors.New("-O takes only one URL"), exitParse}
+	}
+	if *outPath != "" && *recursive {
+		return exitError{errors.New("-O and -r are exclusive"), exitParse}
+	}
+	if *resume && *newer {
+		return exitError{errors.New("-c and -N are exclusive"), exitParse}
+	}
+	if *sha256Hex != "" && len(urls) > 1 {
+		return exitError{errors.New("-sha256 takes only one URL"), exitParse}
+	}
+	if (*sha256Hex != "" || *keyRing != "") && (*resume || *newer || *recursive) {
+		return exitError{errors.New("-sha256 and -pgp-keyring cannot be used with -c, -N or -r"), exitParse}
+	}
+	if *spider && (*resume || *newer || *recursive || *sha256Hex != "" || *keyRing != "") {
+		return exitError{errors.New("-spider cannot be used with -c, -N, -r, -sha256 or -pgp-keyring"), exitParse}
+	}
+	if *outPath == "-" && (*resume || *newer) {
+		return exitError{errors.New("-c and -N cannot download to stdout"), exitParse}
+	}
+	if n := btoi(*quiet) + btoi(*verbose) + btoi(*noVerbose); n > 1 {
+		return exitError{errors.New("-q, -v and -nv are exclusive"), exitParse}
+	}
+	if *tries < 0 || *timeout < 0 || *waitRetry < 0 {
+		return exitError{errors.New("-t, -T and -waitretry cannot be negative"), exitParse}
+	}
 
-	argURL := flag.Arg(0)
-	if argURL == "" {
+	if *prefix != "" {
+		if err := os.MkdirAll(*prefix, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := newFetcher()
+	if err != nil {
+		return exitError{err, exitParse}
+	}
+	if *recursive {
+		var failed, code int
+		for _, u := range urls {
+			if err := f.mirror(u); err != nil {
+				log.Print(err)
+				failed++
+				code = worse(code, exitCode(err))
+			}
+		}
+		if failed > 0 {
+			return exitError{fmt.Errorf("%d of %d mirrors failed", failed, len(urls)), code}
+		}
+		return nil
+	}
+	if len(urls) == 1 {
+		return f.download(urls[0], outputPath(urls[0], nil))
+	}
+	used := make(map[string]bool)
+	var failed, code int
+	for _, u := range urls {
+		if err := f.download(u, outputPath(u, used)); err != nil {
+			log.Print(err)
+			failed++
+			code = worse(code, exitCode(err))
+		}
+	}
+	if failed > 0 {
+		return exitError{fmt.Errorf("%d of %d downloads failed", failed, len(urls)), code}
+	}
+	return nil
+}
+
+// readURLs reads the URLs in path, or stdin if path is "-": one per line,
+// but for empty lines and comments, starting with #.
+func readURLs(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var urls []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if l := strings.TrimSpace(s.Text()); l != "" && !strings.HasPrefix(l, "#") {
+			urls = append(urls, l)
+		}
+	}
+	return urls, s.Err()
+}
+
+// outputPath returns the path to download rawURL into: -O, or the last
+// element of its path, or index.html, in -P. Paths in used, the paths of the
+// downloads before, get a .1, .2... suffix, as with GNU wget.
+func outputPath(rawURL string, used map[string]bool) string {
+	if *outPath != "" {
+		return *outPath
+	}
+	p := "index.html"
+	if u, err := url.Parse(rawURL); err == nil && u.Path != "" && u.Path[len(u.Path)-1] != '/' {
+		p = path.Base(u.Path)
+	}
+	p = filepath.Join(*prefix, p)
+	if used == nil {
+		return p
+	}
+	name := p
+	for i := 1; used[p]; i++ {
+		p = fmt.Sprintf("%s.%d", name, i)
+	}
+	used[p] = true
+	return p
+}
+
+// fetcher downloads files as the flags say.
+type fetcher struct {
+	schemes    curl.Schemes
+	httpClient *curl.HTTPClient
+	limiter    *curl.RateLimiter
+
+	// get is whether HTTP requests are GETs, which can be continued.
+	get bool
+
+	// verify is how downloads are verified, if they are.
+	verify *curl.VerifyOpts
+}
+
+// newFetcher returns the fetcher of the flags.
+func newFetcher() (*fetcher, error) {
+	signer, err := curl.ParseSigner(*sign, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	client := http.DefaultClient
+	if *proxy != "" {
+		p, err := curl.ParseProxy(*proxy)
+		if err != nil {
+			return nil, err
+		}
+		client = curl.ProxyClient(p)
+	}
+	if d, err := dialOptions(); err != nil {
+		return nil, err
+	} else if !d.IsZero() {
+		if client, err = curl.DialerClient(client, d); err != nil {
+			return nil, err
+		}
+	}
+	if t := tlsOptions(); !t.IsZero() {
+		cfg, err := t.Config()
+		if err != nil {
+			return nil, err
+		}
+		client = curl.TLSClient(client, cfg)
+	}
+	if *resolve != "" || *dnsAddr != "" || *dohURL != "" {
+		hosts, err := curl.ParseHosts(*resolve)
+		if err != nil {
+			return nil, err
+		}
+		r, err := curl.ResolverOptions{Hosts: hosts, Server: *dnsAddr, DoH: *dohURL}.Resolver()
+		if err != nil {
+			return nil, err
+		}
+		client = curl.ResolverClient(client, r)
+	}
+	req, err := requestOptions()
+	if err != nil {
+		return nil, err
+	}
+	httpClient := curl.NewSignedHTTPClient(responseClient(client), signer).WithRequestOptions(req)
+
+	var limiter *curl.RateLimiter
+	if *rate != "" {
+		n, err := humanize.ParseBytes(*rate)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid -limit-rate %q", *rate)
+		}
+		limiter = curl.NewRateLimiter(int64(n))
+	}
+
+	// curl.DefaultSchemes doesn't support HTTPS by default.
+	schemes := curl.DefaultSchemes.WithHTTPClient(httpClient)
+	if *segments > 1 {
+		h := curl.NewSignedHTTPClient(responseClient(curl.PooledClient(client, *segments)), signer).WithRequestOptions(req)
+		schemes = schemes.WithHTTPClient(h.Segmented(*segments, 0))
+	}
+	if *tftpOpts != "" {
+		o, err := curl.ParseTFTPOptions(*tftpOpts)
+		if err != nil {
+			return nil, err
+		}
+		c, err := curl.NewTFTPClientWithOptions(o)
+		if err != nil {
+			return nil, err
+		}
+		schemes = schemes.WithTFTPClient(c)
+	}
+	if limiter != nil {
+		schemes = schemes.WithRateLimit(limiter)
+	}
+	if *cacheDir != "" {
+		c := &curl.DiskCache{Dir: *cacheDir}
+		if *cacheMax != "" {
+			n, err := humanize.ParseBytes(*cacheMax)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -cache-size: %v", err)
+			}
+			c.MaxSize = int64(n)
+		}
+		schemes = schemes.WithDiskCache(c)
+	}
+	schemes = schemes.WithProgress(func(u *url.URL) curl.ProgressFunc {
+		return progressFunc(outputPath(u.String(), nil))
+	})
+	if *tries != 1 {
+		schemes = schemes.WithRetries(retryPolicy())
+	}
+
+	get := (req.Method == "" || req.Method == http.MethodGet) && req.Body == nil
+	verify, err := verifyOptions()
+	if err != nil {
+		return nil, err
+	}
+	return &fetcher{schemes: schemes, httpClient: httpClient, limiter: limiter, get: get, verify: verify}, nil
+}
+
+// verifyOptions returns how to verify downloads as the flags say, or nil not
+// to.
+func verifyOptions() (*curl.VerifyOpts, error) {
+	if *sha256Hex == "" && *keyRing == "" {
+		return nil, nil
+	}
+	o := &curl.VerifyOpts{}
+	if *sha256Hex != "" {
+		sum, err := hex.DecodeString(*sha256Hex)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid -sha256 %q", *sha256Hex)
+		}
+		o.SHA256 = sum
+	}
+	if *keyRing != "" {
+		k, err := vfile.GetKeyRing(*keyRing)
+		if err != nil {
+			return nil, err
+		}
+		o.KeyRing = k
+	}
+	return o, nil
+}
+
+// download downloads the file at rawURL into path.
+func (f *fetcher) download(rawURL, path string) error {
+	if rawURL == "" {
 		return errors.New("Empty URL")
 	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if *spider {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("-spider takes HTTP and HTTPS URLs, not %v", rawURL)
+		}
+		head := func() error {
+			_, err := f.httpClient.Head(context.Background(), u)
+			return err
+		}
+		if err := withRetries(u, retryPolicy(), head); err != nil {
+			return fmt.Errorf("Failed to check %v: %w", rawURL, err)
+		}
+		if !*quiet {
+			log.Printf("%v exists", rawURL)
+		}
+		return nil
+	}
+	if (*resume || *newer) && f.get && (u.Scheme == "http" || u.Scheme == "https") {
+		fn := func() error { return resumeInto(f.httpClient, u, path, f.limiter) }
+		if *newer {
+			fn = func() error { return downloadIfModified(f.httpClient, u, path, f.limiter) }
+		}
+		if err := withRetries(u, retryPolicy(), fn); err != nil {
+			return fmt.Errorf("Failed to download %v: %w", rawURL, err)
+		}
+		return nil
+	}
+
+	if f.verify != nil {
+		if err := f.downloadVerified(u, path); err != nil {
+			return fmt.Errorf("Failed to download %v: %w", rawURL, err)
+		}
+		return nil
+	}
 
-	url, err := url.Parse(argURL)
+	reader, err := f.schemes.FetchWithoutCache(context.Background(), u)
 	if err != nil {
+		return fmt.Errorf("Failed to download %v: %w", rawURL, err)
+	}
+	if path == "-" {
+		_, err := io.Copy(os.Stdout, reader)
 		return err
 	}
+	return writeFile(reader, path)
+}
 
-	if *outPath == "" {
-		if url.Path != "" && url.Path[len(url.Path)-1] != '/' {
-			*outPath = path.Base(url.Path)
-		} else {
-			*outPath = "index.html"
+// writeFile reads all from r into path, atomically, keeping the mode of the
+// file at path, if any, unless it is a device or another special file, which
+// is written in place.
+func writeFile(r io.Reader, path string) error {
+	o := uio.AtomicOpts{}
+	if fi, err := os.Stat(path); err == nil {
+		if !fi.Mode().IsRegular() {
+			return uio.ReadIntoFile(r, path)
 		}
+		o.Mode = fi.Mode().Perm()
 	}
+	// Replace the file a symlink points to, not the symlink.
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+	return uio.WriteFileAtomic(r, path, o)
+}
 
-	schemes := curl.Schemes{
-		"tftp": curl.DefaultTFTPClient,
-		"http": curl.DefaultHTTPClient,
+// downloadVerified downloads u next to path, and moves it to path only if it
+// verifies.
+func (f *fetcher) downloadVerified(u *url.URL, path string) error {
+	o := *f.verify
+	o.TempDir = filepath.Dir(path)
+	if path == "-" {
+		o.TempDir = ""
+	}
+	v, err := f.schemes.FetchAndVerify(context.Background(), u, o)
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		defer v.Close()
+		size, err := v.Size()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(os.Stdout, io.NewSectionReader(v, 0, size))
+		return err
+	}
+	if err := os.Chmod(v.Name(), 0o644); err != nil {
+		v.Close()
+		return err
+	}
+	return v.Keep(path)
+}
 
-		// curl.DefaultSchemes doesn't support HTTPS by default.
-		"https": curl.DefaultHTTPClient,
-		"file":  &curl.LocalFileClient{},
+// responseClient returns a copy of c that prints HTTP responses to stderr if
+// -S says so, or c.
+func responseClient(c *http.Client) *http.Client {
+	if !*responses || *quiet {
+		return c
 	}
+	rt := c.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	nc := *c
+	nc.Transport = responsePrinter{rt, os.Stderr}
+	return &nc
+}
 
-	reader, err := schemes.FetchWithoutCache(context.Background(), url)
+// responsePrinter prints the status line and header of every response of rt
+// to w, indented, as GNU wget does.
+type responsePrinter struct {
+	rt http.RoundTripper
+	w  io.Writer
+}
+
+func (p responsePrinter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := p.rt.RoundTrip(req)
 	if err != nil {
-		return fmt.Errorf("Failed to download %v: %v", argURL, err)
+		return nil, err
 	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %s %s\n", resp.Proto, resp.Status)
+	keys := make([]string, 0, len(resp.Header))
+	for k := range resp.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range resp.Header[k] {
+			fmt.Fprintf(&b, "  %s: %s\n", k, v)
+		}
+	}
+	// Written at once, as segments are fetched in parallel.
+	io.WriteString(p.w, b.String())
+	return resp, nil
+}
 
-	if err := uio.ReadIntoFile(reader, *outPath); err != nil {
-		return err
+// requestOptions returns the HTTP request options of the flags.
+func requestOptions() (curl.RequestOptions, error) {
+	o := curl.RequestOptions{
+		Method:    strings.ToUpper(*method),
+		User:      *user,
+		Password:  *password,
+		UserAgent: *userAgent,
+	}
+	if len(headers) > 0 {
+		o.Header = make(http.Header)
+		for _, h := range headers {
+			name, value, _ := strings.Cut(h, ":")
+			o.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	switch {
+	case *postData != "" && *postFile != "":
+		return o, errors.New("-post-data and -post-file are exclusive")
+	case *postData != "":
+		o.Body = []byte(*postData)
+	case *postFile != "":
+		b, err := os.ReadFile(*postFile)
+		if err != nil {
+			return o, err
+		}
+		o.Body = b
 	}
+	if o.Body != nil {
+		if o.Method == "" {
+			o.Method = http.MethodPost
+		}
+		if o.Header.Get("Content-Type") == "" {
+			if o.Header == nil {
+				o.Header = make(http.Header)
+			}
+			o.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	return o, nil
+}
+
+// retryPolicy returns the retry policy of -t, -waitretry and
+// -retry-connrefused.
+func retryPolicy() curl.RetryPolicy {
+	p := curl.DefaultRetryPolicy
+	p.MaxAttempts = *tries
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = math.MaxInt32
+	}
+	p.Max = time.Duration(*waitRetry * float64(time.Second))
+	if p.Base > p.Max {
+		p.Base = p.Max
+	}
+	if !*refused {
+		p.RetryOn &^= curl.RetryOnRefused
+	}
+	return p
+}
+
+// dialOptions returns the dial options of the flags.
+func dialOptions() (curl.DialOptions, error) {
+	d := curl.DialOptions{Interface: *iface, Timeout: time.Duration(*timeout * float64(time.Second))}
+	switch {
+	case *ipv4 && *ipv6:
+		return d, errors.New("-4 and -6 are exclusive")
+	case *ipv4:
+		d.Family = 4
+	case *ipv6:
+		d.Family = 6
+	}
+	if *bindAddr != "" {
+		if d.LocalAddr = net.ParseIP(*bindAddr); d.LocalAddr == nil {
+			return d, fmt.Errorf("invalid -bind-address %q", *bindAddr)
+		}
+	}
+	return d, nil
+}
 
+// tlsOptions returns the TLS options of the flags.
+func tlsOptions() curl.TLSOptions {
+	t := curl.TLSOptions{CAFile: *caCert, CertFile: *cert, KeyFile: *key}
+	if *pins != "" {
+		t.Pins = strings.Split(*pins, ",")
+	}
+	return t
+}
+
+// btoi returns 1 if b, and 0 otherwise.
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// progressFunc returns the function to report the progress of the download
+// into name with, as -q, -v and -nv say, or nil not to.
+func progressFunc(name string) curl.ProgressFunc {
+	switch {
+	case *quiet:
+		return nil
+	case *noVerbose:
+		return curl.SummaryProgress(os.Stderr, name)
+	case *verbose, term.IsTerminal(int(os.Stderr.Fd())):
+		return curl.TextProgress(os.Stderr, name)
+	}
 	return nil
 }
 
+// resumeInto continues downloading u into path, at the rate of l if not nil,
+// and sets the modification time of path to that of u, to resume it only from
+// the same version.
+func resumeInto(c *curl.HTTPClient, u *url.URL, path string, l *curl.RateLimiter) (err error) {
+	var (
+		offset int64
+		v      curl.Validator
+	)
+	if fi, err := os.Stat(path); err == nil {
+		offset, v.LastModified = fi.Size(), fi.ModTime()
+	}
+	r, err := c.FetchRange(context.Background(), u, offset, v)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	// Even an interrupted download gets the modification time, so that
+	// it can be continued.
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if t := r.Validator.LastModified; !t.IsZero() {
+			if terr := os.Chtimes(path, time.Now(), t); err == nil {
+				err = terr
+			}
+		}
+	}()
+	if err := f.Truncate(r.Offset); err != nil {
+		return err
+	}
+	if _, err := f.Seek(r.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	size := r.Size
+	if size >= 0 {
+		size -= r.Offset
+	}
+	_, err = io.Copy(f, responseBody(r.Body, size, path, l))
+	return err
+}
+
+// downloadIfModified downloads u into path, at the rate of l if not nil,
+// unless path was modified since u was and has its size, and sets the
+// modification time of path to that of u.
+func downloadIfModified(c *curl.HTTPClient, u *url.URL, path string, l *curl.RateLimiter) error {
+	var since time.Time
+	size := int64(-1)
+	if fi, err := os.Stat(path); err == nil {
+		since, size = fi.ModTime(), fi.Size()
+	}
+	r, err := c.FetchIfModified(context.Background(), u, since)
+	if errors.Is(err, curl.ErrNotModified) {
+		upToDate(path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	// Servers that ignore If-Modified-Since send the file anyway.
+	if t := r.Validator.LastModified; !since.IsZero() && !t.IsZero() && !t.After(since) && r.Size == size {
+		upToDate(path)
+		return nil
+	}
+
+	// The file is downloaded next to path, and renamed to it once
+	// complete, not to leave a partial file more recent than u.
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, responseBody(r.Body, r.Size, path, l)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if t := r.Validator.LastModified; !t.IsZero() {
+		if err := os.Chtimes(f.Name(), time.Now(), t); err != nil {
+			return err
+		}
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// upToDate says that path is up to date, unless -q.
+func upToDate(path string) {
+	if !*quiet {
+		log.Printf("%s is up to date", path)
+	}
+}
+
+// responseBody returns a reader of the body of a response downloaded into
+// path, of size bytes or -1 if not known, at the rate of l if not nil, that
+// reports its progress as the flags say.
+func responseBody(body io.Reader, size int64, path string, l *curl.RateLimiter) io.Reader {
+	body = bodyReader{body}
+	if l != nil {
+		body = l.Reader(context.Background(), body)
+	}
+	if fn := progressFunc(path); fn != nil {
+		body = curl.NewProgressReader(body, size, fn)
+	}
+	return body
+}
+
+// readError is an error reading the file from the server, rather than
+// writing it, after which the download can be continued.
+type readError struct {
+	error
+}
+
+func (e readError) Unwrap() error {
+	return e.error
+}
+
+// bodyReader returns the errors of r but io.EOF as readErrors.
+type bodyReader struct {
+	r io.Reader
+}
+
+func (b bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = readError{err}
+	}
+	return n, err
+}
+
+// withRetries calls download, which downloads u, and again after the
+// failures p retries and after the connection breaks, e.g. continuing the
+// download where it stopped with resumeInto, until p gives up.
+func withRetries(u *url.URL, p curl.RetryPolicy, download func() error) error {
+	retry, b := p.DoRetry(), p.BackOff()
+	for {
+		err := download()
+		var rerr readError
+		if err == nil || !(errors.As(err, &rerr) || retry(u, err)) {
+			return err
+		}
+		d := b.NextBackOff()
+		if d == backoff.Stop {
+			return err
+		}
+		if !*quiet {
+			log.Printf("Continuing in %v after: %v", d.Round(time.Millisecond), err)
+		}
+		time.Sleep(d)
+	}
+}
+
+// Exit codes of GNU wget.
+const (
+	exitGeneric  = 1
+	exitParse    = 2
+	exitIO       = 3
+	exitNetwork  = 4
+	exitTLS      = 5
+	exitAuth     = 6
+	exitProtocol = 7
+	exitServer   = 8
+)
+
+// exitError is an error to exit with code after.
+type exitError struct {
+	error
+	code int
+}
+
+func (e exitError) Unwrap() error {
+	return e.error
+}
+
+// exitCode returns the code to exit with after err, as GNU wget would.
+func exitCode(err error) int {
+	var (
+		eerr      exitError
+		herr      *curl.HTTPClientCodeError
+		authErr   x509.UnknownAuthorityError
+		hostErr   x509.HostnameError
+		certErr   x509.CertificateInvalidError
+		rerr      readError
+		opErr     *net.OpError
+		dnsErr    *net.DNSError
+		nerr      net.Error
+		pathError *fs.PathError
+	)
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &eerr):
+		return eerr.code
+	case errors.As(err, &herr):
+		switch c := herr.HTTPCode; {
+		case c == http.StatusUnauthorized, c == http.StatusProxyAuthRequired:
+			return exitAuth
+		case c >= 400:
+			return exitServer
+		}
+		return exitProtocol
+	case errors.As(err, &authErr), errors.As(err, &hostErr), errors.As(err, &certErr), errors.Is(err, curl.ErrPinMismatch):
+		return exitTLS
+	case errors.As(err, &rerr), errors.As(err, &opErr), errors.As(err, &dnsErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &nerr) && nerr.Timeout():
+		return exitNetwork
+	case errors.As(err, &pathError):
+		return exitIO
+	}
+	return exitGeneric
+}
+
+// worse returns the code of GNU wget to exit with after failures with the exit
+// codes a and b: the lowest but 1, and 0 for no failure.
+func worse(a, b int) int {
+	switch {
+	case a == 0, a == exitGeneric && b != 0:
+		return b
+	case b == 0, b == exitGeneric:
+		return a
+	case a < b:
+		return a
+	}
+	return b
+}
+
 func main() {
 	if err := run(); err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		os.Exit(exitCode(err))
 	}
 }
@@ -9,15 +9,27 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/u-root/u-root/pkg/testutil"
+	"github.com/u-root/u-root/pkg/vfile"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
 )
 
 const content = "Very simple web server"
@@ -31,6 +43,12 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(content))
 	case "/302":
 		http.Redirect(w, r, "/200", http.StatusFound /* 302 */)
+	case "/401":
+		w.Header().Set("WWW-Authenticate", `Basic realm="boot"`)
+		w.WriteHeader(401)
+	case "/slow":
+		time.Sleep(time.Second)
+		w.Write([]byte(content))
 	case "/500":
 		w.WriteHeader(500)
 		w.Write([]byte(content))
@@ -81,21 +99,49 @@ var tests = []struct {
 		flags:   []string{},
 		url:     "http://localhost:%[1]d/404",
 		content: "",
-		retCode: 1,
+		retCode: 8,
 	},
 	{
 		name:    "5xx error",
 		flags:   []string{},
 		url:     "http://localhost:%[1]d/500",
 		content: "",
-		retCode: 1,
+		retCode: 8,
+	},
+	{
+		name:    "unauthorized",
+		flags:   []string{},
+		url:     "http://localhost:%[1]d/401",
+		content: "",
+		retCode: 6,
+	},
+	{
+		name:    "timeout",
+		flags:   []string{"-T", "0.1"},
+		url:     "http://localhost:%[1]d/slow",
+		content: "",
+		retCode: 4,
 	},
 	{
 		name:    "no server",
 		flags:   []string{},
 		url:     "http://localhost:%[2]d/200",
 		content: "",
-		retCode: 1,
+		retCode: 4,
+	},
+	{
+		name:    "limit rate",
+		flags:   []string{"-limit-rate", "1KiB"},
+		url:     "http://localhost:%[1]d/200",
+		content: content,
+		retCode: 0,
+	},
+	{
+		name:    "invalid rate",
+		flags:   []string{"-limit-rate", "fast"},
+		url:     "http://localhost:%[1]d/200",
+		content: "",
+		retCode: 2,
 	},
 }
 
@@ -165,6 +211,684 @@ func TestWget(t *testing.T) {
 	}
 }
 
+func TestWgetResume(t *testing.T) {
+	modTime := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", modTime, strings.NewReader(content))
+	})}
+	l, port := getListener(t)
+	defer l.Close()
+	go s.Serve(l)
+	url := fmt.Sprintf("http://localhost:%d/file", port)
+
+	for _, tt := range []struct {
+		name    string
+		partial string
+		modTime time.Time
+		want    string
+	}{
+		{name: "new file", want: content},
+		// Only the rest of the file is fetched.
+		{name: "partial", partial: "VERY ", modTime: modTime, want: "VERY simple web server"},
+		{name: "complete", partial: content, modTime: modTime, want: content},
+		{name: "changed", partial: "VERY ", modTime: modTime.Add(-time.Hour), want: content},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			if tt.partial != "" {
+				if err := os.WriteFile(path, []byte(tt.partial), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, tt.modTime, tt.modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			output, err := testutil.Command(t, "-c", "-O", path, url).CombinedOutput()
+			if err != nil {
+				t.Fatalf("wget -c = %v, output: %s", err, output)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			b, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("file = %q, want %q", b, tt.want)
+			}
+			fi, err := f.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !fi.ModTime().Equal(modTime) {
+				t.Errorf("modification time = %v, want %v", fi.ModTime(), modTime)
+			}
+		})
+	}
+}
+
+func TestWgetTimestamping(t *testing.T) {
+	modTime := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	l, port := getListener(t)
+	defer l.Close()
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ignores-if-modified-since" {
+			r.Header.Del("If-Modified-Since")
+		}
+		http.ServeContent(w, r, "file", modTime, strings.NewReader(content))
+	}))
+
+	for _, tt := range []struct {
+		name    string
+		path    string
+		local   string
+		modTime time.Time
+		want    string
+	}{
+		{name: "new file", path: "/file", want: content},
+		{name: "up to date", path: "/file", local: "local", modTime: modTime, want: "local"},
+		{name: "more recent", path: "/file", local: "local", modTime: modTime.Add(time.Hour), want: "local"},
+		{name: "older", path: "/file", local: "local", modTime: modTime.Add(-time.Hour), want: content},
+		{name: "If-Modified-Since ignored", path: "/ignores-if-modified-since", local: "VERY simple web server", modTime: modTime, want: "VERY simple web server"},
+		{name: "If-Modified-Since ignored, size differs", path: "/ignores-if-modified-since", local: "local", modTime: modTime, want: content},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "images")
+			path := filepath.Join(dir, filepath.Base(tt.path))
+			if tt.local != "" {
+				if err := os.Mkdir(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.local), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, tt.modTime, tt.modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			url := fmt.Sprintf("http://localhost:%d%s", port, tt.path)
+			if output, err := testutil.Command(t, "-N", "-P", dir, url).CombinedOutput(); err != nil {
+				t.Fatalf("wget -N = %v, output: %s", err, output)
+			}
+			b, err := os.ReadFile(path)
+			if err != nil || string(b) != tt.want {
+				t.Errorf("file = %q, %v, want %q", b, err, tt.want)
+			}
+			if tt.want == content {
+				fi, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !fi.ModTime().Equal(modTime) {
+					t.Errorf("modification time = %v, want %v", fi.ModTime(), modTime)
+				}
+			}
+			if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+				t.Errorf("%s has %d entries, %v, want only %s", dir, len(entries), err, filepath.Base(path))
+			}
+		})
+	}
+}
+
+func TestWgetResumeBrokenConnection(t *testing.T) {
+	modTime := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	var requests int32
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The connection breaks halfway through the first response.
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+			io.WriteString(w, content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "file", modTime, strings.NewReader(content))
+	})}
+	l, port := getListener(t)
+	defer l.Close()
+	go s.Serve(l)
+	url := fmt.Sprintf("http://localhost:%d/file", port)
+
+	path := filepath.Join(t.TempDir(), "file")
+	output, err := testutil.Command(t, "-continue", "-t", "2", "-O", path, url).CombinedOutput()
+	if err != nil {
+		t.Fatalf("wget -continue -t 2 = %v, output: %s", err, output)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Errorf("file = %q, want %q", b, content)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+}
+
+func TestWgetAtomic(t *testing.T) {
+	l, port := getListener(t)
+	defer l.Close()
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if r.URL.Path == "/broken" {
+			io.WriteString(w, content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		io.WriteString(w, content)
+	}))
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.Command(t, "-O", path, fmt.Sprintf("http://localhost:%d/broken", port)).Run(); err == nil {
+		t.Errorf("wget of a broken download succeeded")
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "old" {
+		t.Errorf("after a broken download, file = %q, %v, want it untouched", b, err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("%s has %d entries, %v, want only the file", dir, len(entries), err)
+	}
+
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("file", link); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := testutil.Command(t, "-O", link, fmt.Sprintf("http://localhost:%d/file", port)).CombinedOutput(); err != nil {
+		t.Fatalf("wget = %v, output: %s", err, output)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != content {
+		t.Errorf("file = %q, %v, want %q", b, err, content)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("%s is no longer a symlink: %v, %v", link, fi.Mode(), err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %v, %v, want it kept at 0600", fi.Mode(), err)
+	}
+
+	if output, err := testutil.Command(t, "-O", os.DevNull, fmt.Sprintf("http://localhost:%d/file", port)).CombinedOutput(); err != nil {
+		t.Errorf("wget -O %s = %v, output: %s", os.DevNull, err, output)
+	}
+}
+
+func TestWgetMultiple(t *testing.T) {
+	l, port := getListener(t)
+	defer l.Close()
+	go http.Serve(l, handler{})
+	base := fmt.Sprintf("http://localhost:%d", port)
+
+	dir := t.TempDir()
+	list := filepath.Join(dir, "urls.txt")
+	if err := os.WriteFile(list, []byte("# Boot files\n"+base+"/200\n\n  "+base+"/404  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := testutil.Command(t, "-i", list, base+"/200", base+"/302")
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	// The 404 fails, after the others were downloaded.
+	if err := testutil.IsExitCode(err, 8); err != nil {
+		t.Errorf("exit code: %v, output: %s", err, output)
+	}
+	for _, name := range []string{"200", "302", "200.1"} {
+		if b, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(b) != content {
+			t.Errorf("%s = %q, %v, want %q", name, b, err, content)
+		}
+	}
+
+	if err := testutil.IsExitCode(testutil.Command(t, "-O", filepath.Join(dir, "out"), base+"/200", base+"/302").Run(), 2); err != nil {
+		t.Errorf("wget -O with two URLs: %v", err)
+	}
+}
+
+func TestWgetExitCodes(t *testing.T) {
+	l, port := getListener(t)
+	defer l.Close()
+	go http.Serve(l, handler{})
+	base := fmt.Sprintf("http://localhost:%d", port)
+	ul, unusedPort := getListener(t)
+	ul.Close()
+	refused := fmt.Sprintf("http://localhost:%d/200", unusedPort)
+
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "no directory", args: []string{"-O", filepath.Join(dir, "missing", "out"), base + "/200"}, want: 3},
+		// Network failures take precedence over server errors.
+		{name: "lowest", args: []string{"-P", dir, base + "/404", refused}, want: 4},
+		{name: "negative tries", args: []string{"-t", "-1", base + "/200"}, want: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := testutil.Command(t, tt.args...).CombinedOutput()
+			if err := testutil.IsExitCode(err, tt.want); err != nil {
+				t.Errorf("exit code: %v, output: %s", err, output)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name  string
+		flags []string
+		want  int
+	}{
+		{name: "refused", flags: []string{"-tries", "20", "-waitretry", "0.1"}, want: 4},
+		{name: "retry refused", flags: []string{"-tries", "20", "-waitretry", "0.1", "-retry-connrefused"}, want: 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// The server only starts after a while.
+			l, port := getListener(t)
+			l.Close()
+			done := make(chan struct{})
+			defer func() { <-done }()
+			go func() {
+				defer close(done)
+				time.Sleep(500 * time.Millisecond)
+				l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+				if err != nil {
+					t.Errorf("listening again on port %d: %v", port, err)
+					return
+				}
+				defer l.Close()
+				go http.Serve(l, handler{})
+				time.Sleep(2 * time.Second)
+			}()
+
+			out := filepath.Join(t.TempDir(), "out")
+			args := append(tt.flags, "-O", out, fmt.Sprintf("http://localhost:%d/200", port))
+			output, err := testutil.Command(t, args...).CombinedOutput()
+			if err := testutil.IsExitCode(err, tt.want); err != nil {
+				t.Errorf("exit code: %v, output: %s", err, output)
+			}
+		})
+	}
+}
+
+func TestWorse(t *testing.T) {
+	for _, tt := range []struct {
+		a, b, want int
+	}{
+		{0, 8, 8},
+		{8, 0, 8},
+		{1, 8, 8},
+		{8, 1, 8},
+		{1, 0, 1},
+		{8, 4, 4},
+		{3, 8, 3},
+	} {
+		if got := worse(tt.a, tt.b); got != tt.want {
+			t.Errorf("worse(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestWgetRecursive(t *testing.T) {
+	srv := t.TempDir()
+	for name, data := range map[string]string{
+		"releases/v1/index.html": `<!DOCTYPE html>
+<a href="a.img">a.img</a> <A HREF='a.img.sig'>sig</A>
+<a href="sub/">sub/</a> <a href="?C=N;O=D">Name</a> <a href="#top">top</a>
+<a href="../../other/x.img">x</a> <a href="http://192.0.2.1/y.img">y</a>`,
+		"releases/v1/a.img":     "a",
+		"releases/v1/a.img.sig": "sig",
+		"releases/v1/sub/c.img": "c",
+		"other/x.img":           "x",
+	} {
+		p := filepath.Join(srv, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, port := getListener(t)
+	defer l.Close()
+	go http.Serve(l, http.FileServer(http.Dir(srv)))
+	host := fmt.Sprintf("localhost:%d", port)
+
+	for _, tt := range []struct {
+		name  string
+		flags []string
+		want  []string
+	}{
+		{
+			name:  "all",
+			flags: []string{"-r"},
+			want:  []string{"releases/v1/a.img", "releases/v1/a.img.sig", "releases/v1/index.html", "releases/v1/sub/c.img", "releases/v1/sub/index.html", "other/x.img"},
+		},
+		{
+			name:  "no parent",
+			flags: []string{"-r", "-np"},
+			want:  []string{"releases/v1/a.img", "releases/v1/a.img.sig", "releases/v1/index.html", "releases/v1/sub/c.img", "releases/v1/sub/index.html"},
+		},
+		{
+			name:  "accept",
+			flags: []string{"-recursive", "-no-parent", "-A", ".img"},
+			want:  []string{"releases/v1/a.img", "releases/v1/sub/c.img"},
+		},
+		{
+			name:  "reject",
+			flags: []string{"-r", "-np", "-R", "*.sig,index.html"},
+			want:  []string{"releases/v1/a.img", "releases/v1/sub/c.img"},
+		},
+		{
+			name:  "mirror",
+			flags: []string{"-m", "-np"},
+			want:  []string{"releases/v1/a.img", "releases/v1/a.img.sig", "releases/v1/index.html", "releases/v1/sub/c.img", "releases/v1/sub/index.html"},
+		},
+		{
+			name:  "depth",
+			flags: []string{"-r", "-np", "-l", "1"},
+			want:  []string{"releases/v1/a.img", "releases/v1/a.img.sig", "releases/v1/index.html", "releases/v1/sub/index.html"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cmd := testutil.Command(t, append(tt.flags, "http://"+host+"/releases/v1/")...)
+			cmd.Dir = dir
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("wget: %v, output: %s", err, output)
+			}
+			var got []string
+			filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+				if err == nil && !fi.IsDir() {
+					rel, _ := filepath.Rel(filepath.Join(dir, host), p)
+					got = append(got, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			sort.Strings(got)
+			want := append([]string(nil), tt.want...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("files = %q, want %q", got, want)
+			}
+		})
+	}
+
+	if err := testutil.IsExitCode(testutil.Command(t, "-r", "-O", "out", "http://"+host+"/").Run(), 2); err != nil {
+		t.Errorf("wget -r -O: %v", err)
+	}
+}
+
+func TestWgetRequestOptions(t *testing.T) {
+	l, port := getListener(t)
+	defer l.Close()
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		b, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s:%s %s %s %s", r.Method, r.Header.Get("Authorization"), user, password, r.UserAgent(), r.Header.Get("Content-Type"), b)
+	}))
+	u := fmt.Sprintf("http://localhost:%d/echo", port)
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	if err := os.WriteFile(data, []byte(`{"id":1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name  string
+		flags []string
+		env   string
+		want  string
+	}{
+		{
+			name:  "header",
+			flags: []string{"-header", "Authorization: Bearer TOKEN", "-U", "provisioner/1.0"},
+			want:  "GET Bearer TOKEN : provisioner/1.0  ",
+		},
+		{
+			name:  "basic auth",
+			flags: []string{"-user", "admin", "-password", "secret"},
+			want:  "GET Basic YWRtaW46c2VjcmV0 admin:secret Go-http-client/1.1  ",
+		},
+		{
+			name:  "password from the environment",
+			flags: []string{"-user", "admin"},
+			env:   "WGET_PASSWORD=secret",
+			want:  "GET Basic YWRtaW46c2VjcmV0 admin:secret Go-http-client/1.1  ",
+		},
+		{
+			name:  "post data",
+			flags: []string{"--post-data", "a=1&b=2"},
+			want:  "POST  : Go-http-client/1.1 application/x-www-form-urlencoded a=1&b=2",
+		},
+		{
+			name:  "post file",
+			flags: []string{"-post-file", data, "-method", "put", "-header", "Content-Type: application/json"},
+			want:  `PUT  : Go-http-client/1.1 application/json {"id":1}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			cmd := testutil.Command(t, append(tt.flags, "-O", out, u)...)
+			if tt.env != "" {
+				cmd.Env = append(cmd.Env, tt.env)
+			}
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("wget: %v, output: %s", err, output)
+			}
+			if b, err := os.ReadFile(out); err != nil || string(b) != tt.want {
+				t.Errorf("response = %q, %v, want %q", b, err, tt.want)
+			}
+		})
+	}
+
+	for _, flags := range [][]string{
+		{"-header", "no colon"},
+		{"-post-data", "a", "-post-file", data},
+	} {
+		if err := testutil.Command(t, append(flags, "-O", filepath.Join(dir, "out"), u)...).Run(); err == nil {
+			t.Errorf("wget %q = nil, want error", flags)
+		}
+	}
+}
+
+func TestWgetVerbosity(t *testing.T) {
+	l, port := getListener(t)
+	defer l.Close()
+	go http.Serve(l, handler{})
+	u := fmt.Sprintf("http://localhost:%d/200", port)
+
+	for _, tt := range []struct {
+		name  string
+		flags []string
+		want  string
+	}{
+		{name: "default", want: ""},
+		{name: "quiet", flags: []string{"-q"}, want: ""},
+		{name: "no verbose", flags: []string{"-nv"}, want: "out: 22 B in "},
+		{name: "verbose", flags: []string{"-v"}, want: "out: 100% 22 B of 22 B"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			output, err := testutil.Command(t, append(tt.flags, "-O", out, u)...).CombinedOutput()
+			if err != nil {
+				t.Fatalf("wget: %v, output: %s", err, output)
+			}
+			if tt.want == "" && len(output) != 0 || !strings.Contains(string(output), tt.want) {
+				t.Errorf("output = %q, want it to contain %q", output, tt.want)
+			}
+		})
+	}
+
+	if err := testutil.Command(t, "-q", "-v", "-O", filepath.Join(t.TempDir(), "out"), u).Run(); err == nil {
+		t.Errorf("wget -q -v = nil, want error")
+	}
+}
+
+func TestWgetSpider(t *testing.T) {
+	l, port := getListener(t)
+	defer l.Close()
+	go http.Serve(l, handler{})
+	base := fmt.Sprintf("http://localhost:%d", port)
+
+	for _, tt := range []struct {
+		name     string
+		flags    []string
+		path     string
+		retCode  int
+		contains []string
+	}{
+		{name: "exists", flags: []string{"-spider"}, path: "/200", contains: []string{"/200 exists"}},
+		{name: "missing", flags: []string{"-spider"}, path: "/404", retCode: 8},
+		{
+			name:     "server response",
+			flags:    []string{"-spider", "-S"},
+			path:     "/302",
+			contains: []string{"  HTTP/1.1 302 Found\n", "  Location: /200\n", "  HTTP/1.1 200 OK\n", "/302 exists"},
+		},
+		{name: "quiet", flags: []string{"-spider", "-server-response", "-q"}, path: "/302"},
+		{name: "not HTTP", flags: []string{"-spider"}, path: "file:///etc/hostname", retCode: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			u := base + tt.path
+			if !strings.HasPrefix(tt.path, "/") {
+				u = tt.path
+			}
+			cmd := testutil.Command(t, append(tt.flags, u)...)
+			cmd.Dir = dir
+			output, err := cmd.CombinedOutput()
+			if err := testutil.IsExitCode(err, tt.retCode); err != nil {
+				t.Fatalf("exit code: %v, output: %s", err, output)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(string(output), s) {
+					t.Errorf("output = %q, want it to contain %q", output, s)
+				}
+			}
+			if len(tt.contains) == 0 && tt.retCode == 0 && len(output) != 0 {
+				t.Errorf("output = %q, want none", output)
+			}
+			if files, err := os.ReadDir(dir); err != nil || len(files) != 0 {
+				t.Errorf("files = %v, %v, want none", files, err)
+			}
+		})
+	}
+
+	if err := testutil.IsExitCode(testutil.Command(t, "-spider", "-c", base+"/200").Run(), 2); err != nil {
+		t.Errorf("wget -spider -c: %v", err)
+	}
+}
+
+func TestWgetStdout(t *testing.T) {
+	l, port := getListener(t)
+	defer l.Close()
+	go http.Serve(l, handler{})
+	u := fmt.Sprintf("http://localhost:%d/302", port)
+
+	dir := t.TempDir()
+	sum := sha256.Sum256([]byte(content))
+	for _, flags := range [][]string{
+		{"-O", "-"},
+		{"-O", "-", "-sha256", hex.EncodeToString(sum[:])},
+	} {
+		var stderr bytes.Buffer
+		cmd := testutil.Command(t, append(flags, u)...)
+		cmd.Dir, cmd.Stderr = dir, &stderr
+		out, err := cmd.Output()
+		if err != nil || string(out) != content {
+			t.Errorf("wget %v = %q, %v, want %q; stderr: %s", flags, out, err, content, stderr.String())
+		}
+	}
+	if files, err := os.ReadDir(dir); err != nil || len(files) != 0 {
+		t.Errorf("files = %v, %v, want none", files, err)
+	}
+
+	if err := testutil.IsExitCode(testutil.Command(t, "-c", "-O", "-", u).Run(), 2); err != nil {
+		t.Errorf("wget -c -O -: %v", err)
+	}
+}
+
+func TestWgetVerify(t *testing.T) {
+	conf := &packet.Config{RSABits: 1024}
+	key, err := openpgp.NewEntity("images", "", "images@example.com", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sig bytes.Buffer
+	if err := vfile.DetachSign(&sig, []*openpgp.Entity{key}, []byte(content), false, conf); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keyRing := filepath.Join(dir, "keys")
+	var pub bytes.Buffer
+	if err := key.Serialize(&pub); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyRing, pub.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, port := getListener(t)
+	defer l.Close()
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file.sig":
+			w.Write(sig.Bytes())
+		case "/tampered.sig":
+			w.Write(sig.Bytes())
+		case "/tampered":
+			io.WriteString(w, "Very evil web server")
+		default:
+			io.WriteString(w, content)
+		}
+	}))
+	base := fmt.Sprintf("http://localhost:%d", port)
+	sum := sha256.Sum256([]byte(content))
+	wrong := sha256.Sum256([]byte("other"))
+
+	for _, tt := range []struct {
+		name  string
+		flags []string
+		path  string
+		ok    bool
+	}{
+		{name: "digest", flags: []string{"-sha256", hex.EncodeToString(sum[:])}, path: "/file", ok: true},
+		{name: "wrong digest", flags: []string{"-sha256", hex.EncodeToString(wrong[:])}, path: "/file"},
+		{name: "signature", flags: []string{"-pgp-keyring", keyRing}, path: "/file", ok: true},
+		{name: "bad signature", flags: []string{"-pgp-keyring", keyRing}, path: "/tampered"},
+		{name: "no signature", flags: []string{"-pgp-keyring", keyRing}, path: "/unsigned"},
+		{name: "with -c", flags: []string{"-c", "-sha256", hex.EncodeToString(sum[:])}, path: "/file"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			out := filepath.Join(dir, "out")
+			output, err := testutil.Command(t, append(tt.flags, "-O", out, base+tt.path)...).CombinedOutput()
+			if (err == nil) != tt.ok {
+				t.Fatalf("wget = %v, output: %s, want ok %v", err, output, tt.ok)
+			}
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok {
+				if len(files) != 0 {
+					t.Errorf("files left after a failed verification: %v", files)
+				}
+				return
+			}
+			if b, err := os.ReadFile(out); err != nil || string(b) != content {
+				t.Errorf("file = %q, %v, want %q", b, err, content)
+			}
+			if len(files) != 1 {
+				t.Errorf("files = %v, want only out", files)
+			}
+		})
+	}
+}
+
 func TestMain(m *testing.M) {
 	testutil.Run(m, main)
 }
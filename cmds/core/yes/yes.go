@@ -6,30 +6,40 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"io"
 	"log"
 	"os"
 	"strings"
 )
 
+// bufSize is the size of the blocks that yes writes.
+const bufSize = 8192
+
 func runYes(w io.Writer, count uint64, args ...string) error {
-	yes := "y"
+	yes := "y\n"
 	if len(args) > 0 {
-		yes = strings.Join(args, " ")
+		yes = strings.Join(args, " ") + "\n"
+	}
+	// Write many lines at once; a write per line is very slow.
+	n := bufSize / len(yes)
+	if n == 0 {
+		n = 1
 	}
+	buf := []byte(strings.Repeat(yes, n))
 	// If count == 0 this loop runs to infinity, every other value of count
-	// will iterate the loop for exactly "count" times before it breaks out.
+	// will write exactly "count" lines.
 	// The standard behavior is achieved with count = 0 as done in main()
 	// Sadly this is required to make it testable with the recommended pattern.
-	for {
-		if _, err := fmt.Fprintf(w, "%s\n", yes); err != nil {
-			return err
+	for infinite := count == 0; infinite || count > 0; {
+		b := buf
+		if !infinite {
+			if count < uint64(n) {
+				b = buf[:count*uint64(len(yes))]
+			}
+			count -= uint64(len(b) / len(yes))
 		}
-		if count > 1 {
-			count--
-		} else if count == 1 {
-			break
+		if _, err := w.Write(b); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -6,6 +6,7 @@ package main
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/u-root/u-root/pkg/testutil"
@@ -50,3 +51,23 @@ func TestYes(t *testing.T) {
 	}
 
 }
+
+func TestYesBlocks(t *testing.T) {
+	long := strings.Repeat("x", bufSize+1)
+	for _, tt := range []struct {
+		line  string
+		count uint64
+	}{
+		{"y", bufSize/2 + 3},
+		{"y", bufSize / 2},
+		{long, 3},
+	} {
+		var buf bytes.Buffer
+		if err := runYes(&buf, tt.count, tt.line); err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.Repeat(tt.line+"\n", int(tt.count)); buf.String() != want {
+			t.Errorf("yes %d lines of %d bytes: got %d bytes, want %d", tt.count, len(tt.line), buf.Len(), len(want))
+		}
+	}
+}
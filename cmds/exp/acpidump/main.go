@@ -0,0 +1,110 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// acpidump dumps ACPI tables, in the text format of the ACPICA acpidump, or
+// to binary files.
+//
+// Synopsis:
+//
+//	acpidump [-s SOURCE] [-n SIG] [-b] [-o FILE]
+//
+// Description:
+//
+//	The tables are read from the source, by default the files of
+//	/sys/firmware/acpi/tables, and printed as hex dumps, which acpixtract
+//	extracts them from.
+//
+//	With -b, the tables are written to NAME.dat files in the current
+//	directory instead, where NAME is the signature in lower case,
+//	numbered if more than one table has it, e.g. ssdt1.dat.
+//
+// Options:
+//
+//	-s: source of the tables: files or ebda (default files)
+//	-n: only dump the tables with signature SIG
+//	-b: write the tables to binary files
+//	-o: write the dump to FILE instead of stdout
+//
+// Example:
+//
+//	acpidump -o acpi.txt
+//	acpidump -b -n SSDT
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/acpi"
+)
+
+var (
+	source = flag.String("s", acpi.DefaultMethod, "source of the tables: "+strings.Join(acpi.MethodNames(), " or "))
+	sig    = flag.String("n", "", "only dump the tables with signature SIG")
+	binary = flag.Bool("b", false, "write the tables to binary files")
+	output = flag.String("o", "", "write the dump to FILE instead of stdout")
+)
+
+var errUsage = errors.New("usage: acpidump [-s SOURCE] [-n SIG] [-b] [-o FILE]")
+
+func run(out io.Writer) error {
+	if flag.NArg() != 0 || (*binary && *output != "") {
+		return errUsage
+	}
+	tabs, err := acpi.ReadTables(*source)
+	if err != nil {
+		return err
+	}
+	// Tables are named before they are selected, so that e.g. the
+	// second SSDT is ssdt2.dat however they are selected.
+	names := acpi.FileNames(tabs)
+	var sel []acpi.Table
+	for i, t := range tabs {
+		if *sig != "" && t.Sig() != *sig {
+			continue
+		}
+		if *binary {
+			if err := os.WriteFile(names[i]+".dat", t.Data(), 0o644); err != nil {
+				return err
+			}
+		}
+		sel = append(sel, t)
+	}
+	switch {
+	case len(tabs) == 0:
+		return fmt.Errorf("%s: no tables read", *source)
+	case len(sel) == 0:
+		return fmt.Errorf("%s: no %s table", *source, *sig)
+	}
+	if *binary {
+		return nil
+	}
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		if err := acpi.WriteDump(f, sel...); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return acpi.WriteDump(out, sel...)
+}
+
+func main() {
+	log.SetPrefix("acpidump: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// acpixtract extracts ACPI tables from the output of acpidump.
+//
+// Synopsis:
+//
+//	acpixtract [-a | -s SIG] [-l] [-c FILE] [FILE]
+//
+// Description:
+//
+//	The DSDT and SSDTs, all tables with -a, or those with signature SIG,
+//	of the dump in FILE, or stdin, are written to NAME.dat files in the
+//	current directory, where NAME is the signature in lower case,
+//	numbered if more than one table has it, e.g. ssdt1.dat.
+//
+//	With -c, they are written to FILE instead, as an uncompressed cpio
+//	archive of kernel/firmware/acpi/NAME.aml files. Linux, if built with
+//	CONFIG_ACPI_TABLE_UPGRADE, overrides the firmware's tables with those
+//	of the archive, if it is the first of the initramfs.
+//
+//	With -l, the tables are listed instead, with their size and OEM
+//	table ID.
+//
+// Options:
+//
+//	-a: extract all tables
+//	-s: extract the tables with signature SIG
+//	-l: list the tables
+//	-c: write the tables to FILE as a table override archive
+//
+// Example:
+//
+//	acpixtract -s SSDT acpi.txt
+//	acpixtract -s DSDT -c dsdt.cpio acpi.txt
+//	cat dsdt.cpio initramfs.cpio.gz > initramfs.img
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/u-root/u-root/pkg/acpi"
+)
+
+var (
+	all      = flag.Bool("a", false, "extract all tables")
+	sig      = flag.String("s", "", "extract the tables with signature SIG")
+	list     = flag.Bool("l", false, "list the tables")
+	override = flag.String("c", "", "write the tables to FILE as a table override archive")
+)
+
+var errUsage = errors.New("usage: acpixtract [-a | -s SIG] [-l] [-c FILE] [FILE]")
+
+func selected(t acpi.Table) bool {
+	switch {
+	case *all:
+		return true
+	case *sig != "":
+		return t.Sig() == *sig
+	}
+	return t.Sig() == "DSDT" || t.Sig() == "SSDT"
+}
+
+func run(in io.Reader, out io.Writer) error {
+	if flag.NArg() > 1 || (*all && *sig != "") || (*list && *override != "") {
+		return errUsage
+	}
+	if flag.NArg() == 1 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	tabs, err := acpi.ReadDump(in)
+	if err != nil {
+		return err
+	}
+
+	// Tables are named before they are selected, so that e.g. the
+	// second SSDT is ssdt2 however they are selected, as in acpidump.
+	names := acpi.FileNames(tabs)
+	var sel []acpi.Table
+	for i, t := range tabs {
+		if !*list && !selected(t) {
+			continue
+		}
+		switch {
+		case *list:
+			id := ""
+			if len(t.Data()) >= 36 {
+				id = t.OEMTableID()
+			}
+			if _, err := fmt.Fprintf(out, "%-10s %8d  %s\n", names[i], t.Len(), id); err != nil {
+				return err
+			}
+		case *override == "":
+			if err := os.WriteFile(names[i]+".dat", t.Data(), 0o644); err != nil {
+				return err
+			}
+		}
+		sel = append(sel, t)
+	}
+	if len(sel) == 0 {
+		return errors.New("no tables extracted")
+	}
+	if *override == "" {
+		return nil
+	}
+
+	f, err := os.Create(*override)
+	if err != nil {
+		return err
+	}
+	if err := acpi.WriteOverride(f, sel...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func main() {
+	log.SetPrefix("acpixtract: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/acpi"
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func table(sig, id string) []byte {
+	b := make([]byte, 40)
+	copy(b, sig)
+	b[4] = byte(len(b))
+	copy(b[16:], id)
+	return b
+}
+
+func TestACPIXtract(t *testing.T) {
+	var blob []byte
+	for _, tab := range [][]byte{table("FACP", "FACP"), table("DSDT", "BXPC"), table("SSDT", "CpuSsdt"), table("SSDT", "NvmeSsdt")} {
+		blob = append(blob, tab...)
+	}
+	tabs, err := acpi.NewRaw(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump bytes.Buffer
+	if err := acpi.WriteDump(&dump, tabs...); err != nil {
+		t.Fatal(err)
+	}
+	dumpFile := filepath.Join(t.TempDir(), "acpi.txt")
+	if err := os.WriteFile(dumpFile, dump.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		args  []string
+		stdin bool
+		files []string
+		want  string
+		code  int
+	}{
+		{name: "default", args: []string{dumpFile}, files: []string{"dsdt.dat", "ssdt1.dat", "ssdt2.dat"}},
+		{name: "stdin", stdin: true, files: []string{"dsdt.dat", "ssdt1.dat", "ssdt2.dat"}},
+		{name: "all", args: []string{"-a", dumpFile}, files: []string{"dsdt.dat", "facp.dat", "ssdt1.dat", "ssdt2.dat"}},
+		{name: "signature", args: []string{"-s", "FACP", dumpFile}, files: []string{"facp.dat"}},
+		{name: "override", args: []string{"-s", "SSDT", "-c", "override.cpio", dumpFile}, files: []string{"override.cpio"}},
+		{
+			name: "list",
+			args: []string{"-l", dumpFile},
+			want: "facp             40  \"FACP\\x00\\x00\\x00\\x00\"\n" +
+				"dsdt             40  \"BXPC\\x00\\x00\\x00\\x00\"\n" +
+				"ssdt1            40  \"CpuSsdt\\x00\"\n" +
+				"ssdt2            40  \"NvmeSsdt\"\n",
+		},
+		{name: "no table", args: []string{"-s", "MADT", dumpFile}, code: 1},
+		{name: "-a and -s", args: []string{"-a", "-s", "SSDT", dumpFile}, code: 1},
+		{name: "no file", args: []string{filepath.Join(t.TempDir(), "none.txt")}, code: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var stdout bytes.Buffer
+			cmd := testutil.Command(t, tt.args...)
+			cmd.Dir = dir
+			cmd.Stdout = &stdout
+			if tt.stdin {
+				cmd.Stdin = bytes.NewReader(dump.Bytes())
+			}
+			err := cmd.Run()
+			if err := testutil.IsExitCode(err, tt.code); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("acpixtract %v printed %q, want %q", tt.args, stdout.String(), tt.want)
+			}
+			ents, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var files []string
+			for _, e := range ents {
+				files = append(files, e.Name())
+			}
+			sort.Strings(files)
+			if len(files) != len(tt.files) {
+				t.Fatalf("acpixtract %v wrote %q, want %q", tt.args, files, tt.files)
+			}
+			for i := range files {
+				if files[i] != tt.files[i] {
+					t.Fatalf("acpixtract %v wrote %q, want %q", tt.args, files, tt.files)
+				}
+			}
+		})
+	}
+
+	// The tables extracted are those dumped.
+	dir := t.TempDir()
+	cmd := testutil.Command(t, "-s", "SSDT", dumpFile)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("acpixtract: %v, %s", err, out)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "ssdt2.dat"))
+	if err != nil || !bytes.Equal(b, tabs[3].Data()) {
+		t.Errorf("ssdt2.dat = %q, %v, want %q", b, err, tabs[3].Data())
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
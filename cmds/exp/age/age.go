@@ -0,0 +1,338 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// age encrypts and decrypts files in the age format, and decrypts OpenPGP
+// messages.
+//
+// Synopsis:
+//
+//	age [-r RECIPIENT]... [-R FILE] [-p] [-passfile FILE] [-o OUTPUT] [INPUT]
+//	age -d [-i FILE] [-passfile FILE] [-o OUTPUT] [INPUT]
+//	age -d -pgp -i KEYRING [-passfile FILE] [-o OUTPUT] [INPUT]
+//	age -keygen [-o OUTPUT]
+//
+// Description:
+//
+//	age handles secrets fetched during provisioning, such as disk keys and
+//	join tokens. INPUT and OUTPUT default to stdin and stdout.
+//
+//	Passphrases are read from the first line of the -passfile file, since
+//	there is usually no terminal to prompt on. With -pgp, the passphrase
+//	unlocks the secret key, or decrypts a symmetrically encrypted message.
+//
+//	When decrypting to a file, the file is removed if the input turns out to
+//	be truncated or tampered with.
+//
+// Options:
+//
+//	-d:        decrypt
+//	-r:        encrypt to an age1... recipient, may be repeated
+//	-R:        encrypt to the recipients listed in FILE, one per line
+//	-p:        encrypt with the passphrase from -passfile
+//	-i:        age identity file, or OpenPGP keyring with -pgp
+//	-pgp:      decrypt an OpenPGP message, armored or binary
+//	-passfile: file holding the passphrase
+//	-keygen:   generate an identity; its recipient is printed to stderr
+//	-o:        output file
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/age"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+type recipientList []string
+
+func (r *recipientList) String() string {
+	return strings.Join(*r, ",")
+}
+
+func (r *recipientList) Set(v string) error {
+	*r = append(*r, v)
+	return nil
+}
+
+type options struct {
+	decrypt        bool
+	pgp            bool
+	keygen         bool
+	passphrase     bool
+	recipients     recipientList
+	recipientsFile string
+	identity       string
+	passFile       string
+	output         string
+}
+
+var opts options
+
+func init() {
+	flag.BoolVar(&opts.decrypt, "d", false, "decrypt")
+	flag.BoolVar(&opts.pgp, "pgp", false, "decrypt an OpenPGP message")
+	flag.BoolVar(&opts.keygen, "keygen", false, "generate an identity")
+	flag.BoolVar(&opts.passphrase, "p", false, "encrypt with a passphrase")
+	flag.Var(&opts.recipients, "r", "recipient, may be repeated")
+	flag.StringVar(&opts.recipientsFile, "R", "", "file with recipients")
+	flag.StringVar(&opts.identity, "i", "", "identity file or OpenPGP keyring")
+	flag.StringVar(&opts.passFile, "passfile", "", "file holding the passphrase")
+	flag.StringVar(&opts.output, "o", "", "output file")
+}
+
+var errUsage = errors.New("usage: age [-d] [-pgp] [-r RECIPIENT]... [-R FILE] [-p] [-i FILE] [-passfile FILE] [-o OUTPUT] [INPUT] | age -keygen [-o OUTPUT]")
+
+func readPassphrase(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("a passphrase requires -passfile")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.SplitN(string(b), "\n", 2)[0], nil
+}
+
+func keygen(w, status io.Writer) error {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(status, "Public key: %s\n", id.Recipient())
+	_, err = fmt.Fprintf(w, "# created: %s\n# public key: %s\n%s\n",
+		time.Now().Format(time.RFC3339), id.Recipient(), id)
+	return err
+}
+
+func recipients(o options) ([]age.Recipient, error) {
+	names := append([]string{}, o.recipients...)
+	if o.recipientsFile != "" {
+		b, err := os.ReadFile(o.recipientsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range strings.Split(string(b), "\n") {
+			if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "#") {
+				names = append(names, l)
+			}
+		}
+	}
+	var rs []age.Recipient
+	for _, n := range names {
+		r, err := age.ParseX25519Recipient(n)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	if o.passphrase {
+		p, err := readPassphrase(o.passFile)
+		if err != nil {
+			return nil, err
+		}
+		r, err := age.NewScryptRecipient(p)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	if len(rs) == 0 {
+		return nil, errors.New("no recipients, use -r, -R or -p")
+	}
+	return rs, nil
+}
+
+func encrypt(dst io.Writer, src io.Reader, o options) error {
+	rs, err := recipients(o)
+	if err != nil {
+		return err
+	}
+	w, err := age.Encrypt(dst, rs...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func decryptAge(dst io.Writer, src io.Reader, o options) error {
+	var ids []age.Identity
+	if o.identity != "" {
+		f, err := os.Open(o.identity)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if ids, err = age.ParseIdentities(f); err != nil {
+			return fmt.Errorf("%s: %v", o.identity, err)
+		}
+	}
+	if o.passFile != "" {
+		p, err := readPassphrase(o.passFile)
+		if err != nil {
+			return err
+		}
+		id, err := age.NewScryptIdentity(p)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return errors.New("no identities, use -i or -passfile")
+	}
+	r, err := age.Decrypt(src, ids...)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, r)
+	return err
+}
+
+// maybeArmored decodes r if it is ASCII armored.
+func maybeArmored(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if b, _ := br.Peek(10); !bytes.Equal(b, []byte("-----BEGIN")) {
+		return br, nil
+	}
+	blk, err := armor.Decode(br)
+	if err != nil {
+		return nil, err
+	}
+	return blk.Body, nil
+}
+
+func readKeyRing(path string) (openpgp.EntityList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := maybeArmored(f)
+	if err != nil {
+		return nil, err
+	}
+	return openpgp.ReadKeyRing(r)
+}
+
+func decryptPGP(dst io.Writer, src io.Reader, o options) error {
+	if o.identity == "" && o.passFile == "" {
+		return errors.New("no keyring, use -i or -passfile")
+	}
+	var keyring openpgp.EntityList
+	if o.identity != "" {
+		var err error
+		if keyring, err = readKeyRing(o.identity); err != nil {
+			return fmt.Errorf("%s: %v", o.identity, err)
+		}
+	}
+	var pass []byte
+	if o.passFile != "" {
+		p, err := readPassphrase(o.passFile)
+		if err != nil {
+			return err
+		}
+		pass = []byte(p)
+	}
+
+	// ReadMessage calls prompt until it succeeds; there is only one
+	// passphrase to offer.
+	tried := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if tried || pass == nil {
+			return nil, errors.New("no usable key or passphrase")
+		}
+		tried = true
+		if symmetric {
+			return pass, nil
+		}
+		for _, k := range keys {
+			if k.PrivateKey != nil && k.PrivateKey.Encrypted {
+				k.PrivateKey.Decrypt(pass)
+			}
+		}
+		return nil, nil
+	}
+
+	r, err := maybeArmored(src)
+	if err != nil {
+		return err
+	}
+	md, err := openpgp.ReadMessage(r, keyring, prompt, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, md.UnverifiedBody); err != nil {
+		return err
+	}
+	// The body must be read in full before the MDC and signature are
+	// checked.
+	if md.IsSigned && md.SignedBy != nil && md.SignatureError != nil {
+		return fmt.Errorf("bad signature: %v", md.SignatureError)
+	}
+	return nil
+}
+
+func run(stdin io.Reader, stdout, stderr io.Writer, o options, args []string) (err error) {
+	if len(args) > 1 || (o.keygen && (o.decrypt || len(args) > 0)) || (o.pgp && !o.decrypt) {
+		return errUsage
+	}
+
+	in := stdin
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	out := stdout
+	if o.output != "" {
+		f, ferr := os.OpenFile(o.output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if ferr != nil {
+			return ferr
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			// Don't leave unauthenticated plaintext behind.
+			if err != nil {
+				os.Remove(o.output)
+			}
+		}()
+		out = f
+	}
+
+	switch {
+	case o.keygen:
+		return keygen(out, stderr)
+	case o.decrypt && o.pgp:
+		return decryptPGP(out, in, o)
+	case o.decrypt:
+		return decryptAge(out, in, o)
+	default:
+		return encrypt(out, in, o)
+	}
+}
+
+func main() {
+	flag.Parse()
+	if err := run(os.Stdin, os.Stdout, os.Stderr, opts, flag.Args()); err != nil {
+		log.Fatalf("age: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestAge(t *testing.T) {
+	dir := t.TempDir()
+	key := filepath.Join(dir, "key.txt")
+	var status bytes.Buffer
+	if err := run(nil, nil, &status, options{keygen: true, output: key}, nil); err != nil {
+		t.Fatal(err)
+	}
+	recipient := strings.TrimSpace(strings.TrimPrefix(status.String(), "Public key: "))
+
+	var enc bytes.Buffer
+	if err := run(strings.NewReader("join token"), &enc, nil, options{recipients: recipientList{recipient}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var dec bytes.Buffer
+	if err := run(&enc, &dec, nil, options{decrypt: true, identity: key}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if dec.String() != "join token" {
+		t.Errorf("decrypted %q, want %q", dec.String(), "join token")
+	}
+
+	if err := run(nil, nil, nil, options{}, nil); err == nil {
+		t.Errorf("encrypt without recipients succeeded")
+	}
+}
+
+func TestAgePassphrase(t *testing.T) {
+	dir := t.TempDir()
+	pass := filepath.Join(dir, "pass")
+	if err := os.WriteFile(pass, []byte("hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	enc := filepath.Join(dir, "secret.age")
+	if err := run(strings.NewReader("disk key"), nil, nil, options{passphrase: true, passFile: pass, output: enc}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var dec bytes.Buffer
+	if err := run(nil, &dec, nil, options{decrypt: true, passFile: pass}, []string{enc}); err != nil {
+		t.Fatal(err)
+	}
+	if dec.String() != "disk key" {
+		t.Errorf("decrypted %q, want %q", dec.String(), "disk key")
+	}
+
+	// A tampered file must not leave plaintext behind.
+	b, _ := os.ReadFile(enc)
+	b[len(b)-1] ^= 1
+	os.WriteFile(enc, b, 0o600)
+	out := filepath.Join(dir, "out")
+	if err := run(nil, nil, nil, options{decrypt: true, passFile: pass, output: out}, []string{enc}); err == nil {
+		t.Errorf("decrypting tampered file succeeded")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("output of failed decryption was kept: %v", err)
+	}
+}
+
+func TestPGP(t *testing.T) {
+	dir := t.TempDir()
+	// The default hash used to sign the identity, RIPEMD160, is not
+	// linked in.
+	conf := &packet.Config{DefaultHash: crypto.SHA256}
+	e, err := openpgp.NewEntity("node", "", "node@example.com", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ring bytes.Buffer
+	aw, _ := armor.Encode(&ring, openpgp.PrivateKeyType, nil)
+	if err := e.SerializePrivate(aw, nil); err != nil {
+		t.Fatal(err)
+	}
+	aw.Close()
+	keyring := filepath.Join(dir, "secring.asc")
+	if err := os.WriteFile(keyring, ring.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg bytes.Buffer
+	w, err := openpgp.Encrypt(&msg, []*openpgp.Entity{e}, nil, nil, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("pgp join token"))
+	w.Close()
+
+	var dec bytes.Buffer
+	if err := run(&msg, &dec, nil, options{decrypt: true, pgp: true, identity: keyring}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if dec.String() != "pgp join token" {
+		t.Errorf("decrypted %q, want %q", dec.String(), "pgp join token")
+	}
+
+	// Symmetrically encrypted, armored.
+	pass := filepath.Join(dir, "pass")
+	os.WriteFile(pass, []byte("hunter2"), 0o600)
+	msg.Reset()
+	aw, _ = armor.Encode(&msg, "PGP MESSAGE", nil)
+	w, err = openpgp.SymmetricallyEncrypt(aw, []byte("hunter2"), nil, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("symmetric"))
+	w.Close()
+	aw.Close()
+	dec.Reset()
+	if err := run(&msg, &dec, nil, options{decrypt: true, pgp: true, passFile: pass}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if dec.String() != "symmetric" {
+		t.Errorf("decrypted %q, want %q", dec.String(), "symmetric")
+	}
+
+	if err := run(nil, nil, nil, options{pgp: true}, nil); err != errUsage {
+		t.Errorf("-pgp without -d = %v, want %v", err, errUsage)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// arp shows and changes the IPv4 neighbour table.
+//
+// Synopsis:
+//
+//	arp [-n] [-i IFACE] [HOST]
+//	arp -s HOST MAC [-i IFACE] [-temp]
+//	arp -d HOST [-i IFACE]
+//
+// Description:
+//
+//	Without -s or -d, the entries for HOST, or all entries, are listed.
+//	Incomplete and failed entries are included, since they are what an
+//	unreachable boot server looks like.
+//
+//	Without -i, the interface for -s and -d is the one the route to HOST
+//	uses.
+//
+// Options:
+//
+//	-n:    don't resolve host names
+//	-i:    only use this interface
+//	-s:    add a permanent entry, or a reachable one with -temp
+//	-temp: with -s, let the entry expire
+//	-d:    delete the entry
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vishvananda/netlink"
+)
+
+var (
+	numeric = flag.Bool("n", false, "don't resolve host names")
+	iface   = flag.String("i", "", "interface")
+	set     = flag.Bool("s", false, "add an entry")
+	temp    = flag.Bool("temp", false, "with -s, let the entry expire")
+	del     = flag.Bool("d", false, "delete an entry")
+)
+
+var errUsage = errors.New("usage: arp [-n] [-i IFACE] [HOST] | arp -s HOST MAC [-i IFACE] [-temp] | arp -d HOST [-i IFACE]")
+
+// neighbours is the part of netlink the command uses.
+type neighbours interface {
+	list() ([]netlink.Neigh, error)
+	set(n *netlink.Neigh) error
+	del(n *netlink.Neigh) error
+	route(ip net.IP) (int, error)
+	name(index int) string
+}
+
+type kernel struct{}
+
+func (kernel) list() ([]netlink.Neigh, error) { return netlink.NeighList(0, netlink.FAMILY_V4) }
+func (kernel) set(n *netlink.Neigh) error     { return netlink.NeighSet(n) }
+func (kernel) del(n *netlink.Neigh) error     { return netlink.NeighDel(n) }
+
+func (kernel) route(ip net.IP) (int, error) {
+	routes, err := netlink.RouteGet(ip)
+	if err != nil {
+		return 0, err
+	}
+	if len(routes) == 0 {
+		return 0, fmt.Errorf("no route to %v", ip)
+	}
+	return routes[0].LinkIndex, nil
+}
+
+func (kernel) name(index int) string {
+	if ifc, err := net.InterfaceByIndex(index); err == nil {
+		return ifc.Name
+	}
+	return fmt.Sprint(index)
+}
+
+var lookupAddr = net.LookupAddr
+
+var states = []struct {
+	state int
+	name  string
+}{
+	{netlink.NUD_PERMANENT, "PERM"},
+	{netlink.NUD_NOARP, "NOARP"},
+	{netlink.NUD_REACHABLE, "REACHABLE"},
+	{netlink.NUD_STALE, "STALE"},
+	{netlink.NUD_DELAY, "DELAY"},
+	{netlink.NUD_PROBE, "PROBE"},
+	{netlink.NUD_INCOMPLETE, "INCOMPLETE"},
+	{netlink.NUD_FAILED, "FAILED"},
+}
+
+func stateName(s int) string {
+	var names []string
+	for _, st := range states {
+		if s&st.state != 0 {
+			names = append(names, st.name)
+		}
+	}
+	if len(names) == 0 {
+		return "NONE"
+	}
+	return strings.Join(names, ",")
+}
+
+func parseHost(h string) (net.IP, error) {
+	if ip := net.ParseIP(h).To4(); ip != nil {
+		return ip, nil
+	}
+	ips, err := net.LookupIP(h)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4, nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no IPv4 address", h)
+}
+
+// index returns the interface -i names, or the one the route to ip uses.
+func index(k neighbours, ip net.IP, name string) (int, error) {
+	if name == "" {
+		return k.route(ip)
+	}
+	ifc, err := net.InterfaceByName(name)
+	if err != nil {
+		return 0, err
+	}
+	return ifc.Index, nil
+}
+
+func show(w io.Writer, k neighbours, host net.IP, ifindex int, numeric bool) error {
+	ns, err := k.list()
+	if err != nil {
+		return fmt.Errorf("can't list neighbours: %v", err)
+	}
+	sort.Slice(ns, func(i, j int) bool {
+		if ns[i].LinkIndex != ns[j].LinkIndex {
+			return ns[i].LinkIndex < ns[j].LinkIndex
+		}
+		return strings.Compare(string(ns[i].IP.To16()), string(ns[j].IP.To16())) < 0
+	})
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "Address\tHWaddress\tState\tIface")
+	found := false
+	for _, n := range ns {
+		if host != nil && !n.IP.Equal(host) || ifindex != 0 && n.LinkIndex != ifindex {
+			continue
+		}
+		found = true
+		addr := n.IP.String()
+		if !numeric {
+			if names, err := lookupAddr(addr); err == nil && len(names) > 0 {
+				addr = strings.TrimSuffix(names[0], ".") + " (" + addr + ")"
+			}
+		}
+		mac := "(incomplete)"
+		if len(n.HardwareAddr) != 0 {
+			mac = n.HardwareAddr.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", addr, mac, stateName(n.State), k.name(n.LinkIndex))
+	}
+	if host != nil && !found {
+		return fmt.Errorf("%v: no entry", host)
+	}
+	return tw.Flush()
+}
+
+func run(w io.Writer, k neighbours, args []string) error {
+	switch {
+	case *set && *del, *temp && !*set:
+		return errUsage
+	case *set:
+		if len(args) != 2 {
+			return errUsage
+		}
+		ip, err := parseHost(args[0])
+		if err != nil {
+			return err
+		}
+		mac, err := net.ParseMAC(args[1])
+		if err != nil {
+			return err
+		}
+		idx, err := index(k, ip, *iface)
+		if err != nil {
+			return err
+		}
+		state := netlink.NUD_PERMANENT
+		if *temp {
+			state = netlink.NUD_REACHABLE
+		}
+		return k.set(&netlink.Neigh{LinkIndex: idx, Family: netlink.FAMILY_V4, State: state, IP: ip, HardwareAddr: mac})
+	case *del:
+		if len(args) != 1 {
+			return errUsage
+		}
+		ip, err := parseHost(args[0])
+		if err != nil {
+			return err
+		}
+		idx, err := index(k, ip, *iface)
+		if err != nil {
+			return err
+		}
+		return k.del(&netlink.Neigh{LinkIndex: idx, Family: netlink.FAMILY_V4, IP: ip})
+	}
+
+	if len(args) > 1 {
+		return errUsage
+	}
+	var host net.IP
+	if len(args) == 1 {
+		var err error
+		if host, err = parseHost(args[0]); err != nil {
+			return err
+		}
+	}
+	var idx int
+	if *iface != "" {
+		ifc, err := net.InterfaceByName(*iface)
+		if err != nil {
+			return err
+		}
+		idx = ifc.Index
+	}
+	return show(w, k, host, idx, *numeric)
+}
+
+func main() {
+	flag.Parse()
+	if err := run(os.Stdout, kernel{}, flag.Args()); err != nil {
+		log.Fatalf("arp: %v", err)
+	}
+}
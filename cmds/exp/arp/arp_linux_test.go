@@ -0,0 +1,96 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+type fakeKernel struct {
+	neighs []netlink.Neigh
+	added  *netlink.Neigh
+	gone   *netlink.Neigh
+}
+
+func (k *fakeKernel) list() ([]netlink.Neigh, error) { return k.neighs, nil }
+func (k *fakeKernel) set(n *netlink.Neigh) error     { k.added = n; return nil }
+func (k *fakeKernel) del(n *netlink.Neigh) error     { k.gone = n; return nil }
+func (k *fakeKernel) route(net.IP) (int, error)      { return 2, nil }
+func (k *fakeKernel) name(i int) string              { return map[int]string{2: "eth0", 3: "eth1"}[i] }
+
+func TestShow(t *testing.T) {
+	lookupAddr = func(addr string) ([]string, error) {
+		if addr == "192.0.2.1" {
+			return []string{"gw.example."}, nil
+		}
+		return nil, errors.New("not found")
+	}
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+	k := &fakeKernel{neighs: []netlink.Neigh{
+		{LinkIndex: 3, IP: net.IPv4(192, 0, 2, 9), State: netlink.NUD_FAILED},
+		{LinkIndex: 2, IP: net.IPv4(192, 0, 2, 1), HardwareAddr: mac, State: netlink.NUD_REACHABLE},
+	}}
+
+	var out bytes.Buffer
+	if err := show(&out, k, nil, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("show printed %q, want a header and 2 entries", out.String())
+	}
+	for i, want := range [][]string{
+		{"Address", "HWaddress", "State", "Iface"},
+		{"gw.example", "(192.0.2.1)", "52:54:00:12:34:56", "REACHABLE", "eth0"},
+		{"192.0.2.9", "(incomplete)", "FAILED", "eth1"},
+	} {
+		if got := strings.Fields(lines[i]); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("line %d = %q, want %q", i, got, want)
+		}
+	}
+
+	out.Reset()
+	if err := show(&out, k, net.IPv4(192, 0, 2, 9), 0, true); err != nil || strings.Count(out.String(), "\n") != 2 {
+		t.Errorf("show(192.0.2.9) = %v, %q", err, out.String())
+	}
+	if err := show(&out, k, net.IPv4(192, 0, 2, 9), 2, true); err == nil {
+		t.Errorf("show(192.0.2.9 on eth0) succeeded, want no entry")
+	}
+}
+
+func TestSetDelete(t *testing.T) {
+	defer func() { *set, *del, *temp = false, false, false }()
+	k := &fakeKernel{}
+
+	*set = true
+	if err := run(nil, k, []string{"192.0.2.5", "52:54:00:00:00:05"}); err != nil {
+		t.Fatal(err)
+	}
+	if k.added == nil || k.added.LinkIndex != 2 || k.added.State != netlink.NUD_PERMANENT || !k.added.IP.Equal(net.IPv4(192, 0, 2, 5)) {
+		t.Errorf("arp -s set %+v", k.added)
+	}
+	*temp = true
+	if err := run(nil, k, []string{"192.0.2.5", "52:54:00:00:00:05"}); err != nil || k.added.State != netlink.NUD_REACHABLE {
+		t.Errorf("arp -s -temp = %v, state %#x", err, k.added.State)
+	}
+	if err := run(nil, k, []string{"192.0.2.5", "bogus"}); err == nil {
+		t.Errorf("arp -s with a bad MAC succeeded")
+	}
+
+	*set, *temp, *del = false, false, true
+	if err := run(nil, k, []string{"192.0.2.5"}); err != nil || k.gone == nil || !k.gone.IP.Equal(net.IPv4(192, 0, 2, 5)) {
+		t.Errorf("arp -d = %v, deleted %+v", err, k.gone)
+	}
+	*set = true
+	if err := run(nil, k, []string{"192.0.2.5"}); err != errUsage {
+		t.Errorf("arp -s -d = %v, want %v", err, errUsage)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// arping sends ARP requests to a neighbour.
+//
+// Synopsis:
+//
+//	arping [-D | -U] [-f] [-c COUNT] [-w DEADLINE] [-i INTERVAL] [-I IFACE] [-s SOURCE] DESTINATION
+//
+// Description:
+//
+//	arping finds the MAC address of DESTINATION, or with -D checks that no
+//	other host uses it. Replies are printed as they arrive.
+//
+//	Without -I, the interface is the one the route to DESTINATION uses, and
+//	without -s, the source address is the route's preferred source.
+//
+//	The exit status is 0 if a reply was received, or with -D if none was.
+//	With -U it is always 0.
+//
+// Options:
+//
+//	-I: interface to send on
+//	-s: source IP address
+//	-c: stop after COUNT requests (0: until the deadline or interrupted)
+//	-w: stop after DEADLINE
+//	-i: interval between requests (default 1s)
+//	-f: stop at the first reply
+//	-D: duplicate address detection: send from 0.0.0.0 and stop at the first reply
+//	-U: unsolicited ARP: announce to all hosts that DESTINATION is ours
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	arpRequest = 1
+	arpReply   = 2
+
+	htypeEthernet = 1
+	ptypeIPv4     = 0x0800
+)
+
+var (
+	errUsage   = errors.New("usage: arping [-D | -U] [-f] [-c COUNT] [-w DEADLINE] [-i INTERVAL] [-I IFACE] [-s SOURCE] DESTINATION")
+	errNoReply = errors.New("no reply")
+	errDup     = errors.New("address is in use")
+
+	broadcast = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+// packet is an Ethernet/IPv4 ARP packet (RFC 826).
+type packet struct {
+	op        uint16
+	senderMAC net.HardwareAddr
+	senderIP  net.IP
+	targetMAC net.HardwareAddr
+	targetIP  net.IP
+}
+
+func (p *packet) marshal() []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, []uint16{htypeEthernet, ptypeIPv4})
+	b.Write([]byte{6, 4})
+	binary.Write(&b, binary.BigEndian, p.op)
+	b.Write(p.senderMAC)
+	b.Write(p.senderIP.To4())
+	b.Write(p.targetMAC)
+	b.Write(p.targetIP.To4())
+	return b.Bytes()
+}
+
+func parsePacket(b []byte) (*packet, error) {
+	if len(b) < 28 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(b[0:]) != htypeEthernet || binary.BigEndian.Uint16(b[2:]) != ptypeIPv4 || b[4] != 6 || b[5] != 4 {
+		return nil, errors.New("not an Ethernet/IPv4 ARP packet")
+	}
+	return &packet{
+		op:        binary.BigEndian.Uint16(b[6:]),
+		senderMAC: net.HardwareAddr(append([]byte{}, b[8:14]...)),
+		senderIP:  net.IP(append([]byte{}, b[14:18]...)),
+		targetMAC: net.HardwareAddr(append([]byte{}, b[18:24]...)),
+		targetIP:  net.IP(append([]byte{}, b[24:28]...)),
+	}, nil
+}
+
+// conn sends and receives ARP packets on one interface.
+type conn interface {
+	send(b []byte, dst net.HardwareAddr) error
+	// recv returns os.ErrDeadlineExceeded when the deadline passed.
+	recv(deadline time.Time) ([]byte, error)
+	Close() error
+}
+
+type options struct {
+	count       int
+	deadline    time.Duration
+	interval    time.Duration
+	first       bool
+	dad         bool
+	unsolicited bool
+}
+
+// replies prints the replies from target that arrive before deadline, and
+// returns how many there were. If first is set, it returns at the first one.
+func replies(w io.Writer, c conn, mac net.HardwareAddr, target net.IP, start, deadline time.Time, first bool) (int, error) {
+	n := 0
+	for {
+		b, err := c.recv(deadline)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		p, err := parsePacket(b)
+		if err != nil || p.op != arpReply || !p.senderIP.Equal(target) || bytes.Equal(p.senderMAC, mac) {
+			continue
+		}
+		n++
+		fmt.Fprintf(w, "Unicast reply from %v [%v]  %.3fms\n", p.senderIP, p.senderMAC, float64(time.Since(start).Microseconds())/1000)
+		if first {
+			return n, nil
+		}
+	}
+}
+
+// arping sends requests for target from src on c and prints the replies.
+func arping(w io.Writer, c conn, mac net.HardwareAddr, src, target net.IP, o options) error {
+	req := &packet{op: arpRequest, senderMAC: mac, senderIP: src, targetMAC: make(net.HardwareAddr, 6), targetIP: target}
+	switch {
+	case o.dad:
+		req.senderIP = net.IPv4zero
+		o.first = true
+	case o.unsolicited:
+		// A gratuitous ARP names the announced address as both sender
+		// and target.
+		req.senderIP = target
+	}
+
+	var end time.Time
+	if o.deadline > 0 {
+		end = time.Now().Add(o.deadline)
+	}
+	sent, received := 0, 0
+	for o.count == 0 || sent < o.count {
+		if err := c.send(req.marshal(), broadcast); err != nil {
+			return err
+		}
+		sent++
+		start := time.Now()
+		next := start.Add(o.interval)
+		if !end.IsZero() && end.Before(next) {
+			next = end
+		}
+		if !o.unsolicited {
+			n, err := replies(w, c, mac, target, start, next, o.first)
+			if err != nil {
+				return err
+			}
+			received += n
+		}
+		if o.first && received > 0 || !end.IsZero() && !time.Now().Before(end) {
+			break
+		}
+		time.Sleep(time.Until(next))
+	}
+	fmt.Fprintf(w, "Sent %d probe(s), received %d response(s)\n", sent, received)
+
+	switch {
+	case o.unsolicited:
+		return nil
+	case o.dad && received > 0:
+		return errDup
+	case o.dad:
+		return nil
+	case received == 0:
+		return errNoReply
+	}
+	return nil
+}
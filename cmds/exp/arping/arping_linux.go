@@ -0,0 +1,171 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	iface       = flag.String("I", "", "interface to send on")
+	source      = flag.String("s", "", "source IP address")
+	count       = flag.Int("c", 0, "stop after this many requests")
+	deadline    = flag.Duration("w", 0, "stop after this long")
+	interval    = flag.Duration("i", time.Second, "interval between requests")
+	first       = flag.Bool("f", false, "stop at the first reply")
+	dad         = flag.Bool("D", false, "duplicate address detection")
+	unsolicited = flag.Bool("U", false, "unsolicited ARP")
+)
+
+// packetConn is an AF_PACKET datagram socket; the kernel adds the Ethernet
+// header.
+type packetConn struct {
+	fd    int
+	index int
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+func listen(ifindex int) (*packetConn, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, int(htons(unix.ETH_P_ARP)))
+	if err != nil {
+		return nil, fmt.Errorf("packet socket: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ARP), Ifindex: ifindex}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bind: %w", err)
+	}
+	return &packetConn{fd: fd, index: ifindex}, nil
+}
+
+func (c *packetConn) send(b []byte, dst net.HardwareAddr) error {
+	sa := &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ARP), Ifindex: c.index, Halen: uint8(len(dst))}
+	copy(sa.Addr[:], dst)
+	return unix.Sendto(c.fd, b, 0, sa)
+}
+
+func (c *packetConn) recv(deadline time.Time) ([]byte, error) {
+	b := make([]byte, 128)
+	for {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return nil, os.ErrDeadlineExceeded
+		}
+		fds := []unix.PollFd{{Fd: int32(c.fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int(d.Milliseconds())+1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			continue
+		}
+		n, _, err = unix.Recvfrom(c.fd, b, 0)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+
+func (c *packetConn) Close() error {
+	return unix.Close(c.fd)
+}
+
+// route finds the interface and source address used to reach dst.
+func route(dst net.IP) (string, net.IP, error) {
+	routes, err := netlink.RouteGet(dst)
+	if err != nil || len(routes) == 0 {
+		return "", nil, fmt.Errorf("no route to %v: %v", dst, err)
+	}
+	l, err := netlink.LinkByIndex(routes[0].LinkIndex)
+	if err != nil {
+		return "", nil, err
+	}
+	return l.Attrs().Name, routes[0].Src, nil
+}
+
+func run(args []string) error {
+	if len(args) != 1 || *dad && *unsolicited || *count < 0 {
+		return errUsage
+	}
+	target := net.ParseIP(args[0]).To4()
+	if target == nil {
+		ips, err := net.LookupIP(args[0])
+		if err != nil {
+			return err
+		}
+		for _, ip := range ips {
+			if target = ip.To4(); target != nil {
+				break
+			}
+		}
+		if target == nil {
+			return fmt.Errorf("%s has no IPv4 address", args[0])
+		}
+	}
+
+	name, src := *iface, net.IP(nil)
+	if name == "" {
+		var err error
+		if name, src, err = route(target); err != nil {
+			return err
+		}
+	}
+	if *source != "" {
+		if src = net.ParseIP(*source).To4(); src == nil {
+			return fmt.Errorf("bad source address %q", *source)
+		}
+	}
+	if src == nil {
+		src = net.IPv4zero
+	}
+	ifc, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	if len(ifc.HardwareAddr) != 6 {
+		return fmt.Errorf("%s is not an Ethernet interface", name)
+	}
+
+	c, err := listen(ifc.Index)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	from := src
+	if *dad {
+		from = net.IPv4zero
+	}
+	fmt.Printf("ARPING %v from %v %s\n", target, from, name)
+	return arping(os.Stdout, c, ifc.HardwareAddr, src, target, options{
+		count:       *count,
+		deadline:    *deadline,
+		interval:    *interval,
+		first:       *first,
+		dad:         *dad,
+		unsolicited: *unsolicited,
+	})
+}
+
+func main() {
+	flag.Parse()
+	if err := run(flag.Args()); err != nil {
+		log.Fatalf("arping: %v", err)
+	}
+}
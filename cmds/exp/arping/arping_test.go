@@ -0,0 +1,121 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	ourMAC   = net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	theirMAC = net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	ourIP    = net.IPv4(192, 0, 2, 1).To4()
+	theirIP  = net.IPv4(192, 0, 2, 2).To4()
+)
+
+// fakeConn answers every request for an address in hosts.
+type fakeConn struct {
+	hosts   map[string]net.HardwareAddr
+	sent    []*packet
+	pending [][]byte
+}
+
+func (c *fakeConn) send(b []byte, dst net.HardwareAddr) error {
+	p, err := parsePacket(b)
+	if err != nil {
+		return err
+	}
+	c.sent = append(c.sent, p)
+	// Our own broadcast is looped back, as on a real interface.
+	c.pending = append(c.pending, b)
+	if mac, ok := c.hosts[p.targetIP.String()]; ok && p.op == arpRequest {
+		r := &packet{op: arpReply, senderMAC: mac, senderIP: p.targetIP, targetMAC: p.senderMAC, targetIP: p.senderIP}
+		c.pending = append(c.pending, r.marshal())
+	}
+	return nil
+}
+
+func (c *fakeConn) recv(time.Time) ([]byte, error) {
+	if len(c.pending) == 0 {
+		return nil, os.ErrDeadlineExceeded
+	}
+	b := c.pending[0]
+	c.pending = c.pending[1:]
+	return b, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func TestPacket(t *testing.T) {
+	p := &packet{op: arpRequest, senderMAC: ourMAC, senderIP: ourIP, targetMAC: make(net.HardwareAddr, 6), targetIP: theirIP}
+	b := p.marshal()
+	if len(b) != 28 {
+		t.Fatalf("len(marshal()) = %d, want 28", len(b))
+	}
+	q, err := parsePacket(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.op != p.op || !bytes.Equal(q.senderMAC, ourMAC) || !q.senderIP.Equal(ourIP) || !q.targetIP.Equal(theirIP) {
+		t.Errorf("parsePacket(marshal(%+v)) = %+v", p, q)
+	}
+	if _, err := parsePacket(b[:27]); err == nil {
+		t.Errorf("parsePacket(short) succeeded")
+	}
+	b[1] = 6
+	if _, err := parsePacket(b); err == nil {
+		t.Errorf("parsePacket(non-Ethernet) succeeded")
+	}
+}
+
+func TestArping(t *testing.T) {
+	hosts := map[string]net.HardwareAddr{theirIP.String(): theirMAC}
+	for _, tt := range []struct {
+		name     string
+		target   net.IP
+		o        options
+		wantErr  error
+		wantSent int
+		wantSrc  net.IP
+		wantOut  string
+	}{
+		{name: "reply", target: theirIP, o: options{count: 2}, wantSent: 2, wantSrc: ourIP, wantOut: "Unicast reply from 192.0.2.2 [02:00:00:00:00:02]"},
+		{name: "first", target: theirIP, o: options{count: 5, first: true}, wantSent: 1, wantSrc: ourIP, wantOut: "received 1 response"},
+		{name: "no reply", target: net.IPv4(192, 0, 2, 9), o: options{count: 2}, wantErr: errNoReply, wantSent: 2, wantSrc: ourIP},
+		{name: "dad free", target: net.IPv4(192, 0, 2, 9), o: options{count: 3, dad: true}, wantSent: 3, wantSrc: net.IPv4zero},
+		{name: "dad in use", target: theirIP, o: options{count: 3, dad: true}, wantErr: errDup, wantSent: 1, wantSrc: net.IPv4zero},
+		{name: "unsolicited", target: ourIP, o: options{count: 2, unsolicited: true}, wantSent: 2, wantSrc: ourIP},
+		{name: "deadline", target: net.IPv4(192, 0, 2, 9), o: options{deadline: 30 * time.Millisecond, interval: 10 * time.Millisecond}, wantErr: errNoReply, wantSrc: ourIP},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeConn{hosts: hosts}
+			var out bytes.Buffer
+			err := arping(&out, c, ourMAC, ourIP, tt.target, tt.o)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("arping = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantSent != 0 && len(c.sent) != tt.wantSent {
+				t.Errorf("sent %d requests, want %d", len(c.sent), tt.wantSent)
+			}
+			for _, p := range c.sent {
+				if !p.senderIP.Equal(tt.wantSrc) || !p.targetIP.Equal(tt.target) {
+					t.Errorf("sent request from %v for %v, want from %v for %v", p.senderIP, p.targetIP, tt.wantSrc, tt.target)
+				}
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("output %q does not contain %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
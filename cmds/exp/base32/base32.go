@@ -0,0 +1,82 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// base32 encodes or decodes base32 data.
+//
+// Synopsis:
+//
+//	base32 [-d [-i]] [-w COLS] [FILE]
+//
+// Description:
+//
+//	base32 encodes FILE, or stdin if there is no FILE or it is -, in
+//	standard base32 to stdout, or decodes it with -d. Data is streamed,
+//	and decoding ignores line breaks and accepts data without its final
+//	padding.
+//
+//	basenc has the other encodings.
+//
+// Options:
+//
+//	-d, --decode:         decode data
+//	-i, --ignore-garbage: when decoding, ignore characters not of base32
+//	-w, --wrap:           break encoded lines after COLS characters, 76 by
+//	                      default; 0 does not break them
+//
+// Example:
+//
+//	$ echo -n u-root | base32
+//	OUWXE33POQ======
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	flag "github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/basenc"
+)
+
+var (
+	decode = flag.BoolP("decode", "d", false, "decode data")
+	ignore = flag.BoolP("ignore-garbage", "i", false, "when decoding, ignore characters not of base32")
+	wrap   = flag.IntP("wrap", "w", 76, "break encoded lines after `COLS` characters, or not with 0")
+)
+
+var errUsage = errors.New("usage: base32 [-d [-i]] [-w COLS] [FILE]")
+
+func run(stdin io.Reader, stdout io.Writer, args []string) error {
+	if len(args) > 1 || *wrap < 0 {
+		return errUsage
+	}
+	if len(args) == 1 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stdin = f
+	}
+
+	if *decode {
+		_, err := io.Copy(stdout, basenc.NewDecoder(basenc.Base32, stdin, *ignore))
+		return err
+	}
+	w := basenc.NewEncoder(basenc.Base32, stdout, *wrap)
+	if _, err := io.Copy(w, stdin); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func main() {
+	log.SetPrefix("base32: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdin, os.Stdout, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		decode bool
+		ignore bool
+		wrap   int
+		args   []string
+		in     string
+		want   string
+		err    error
+	}{
+		{wrap: 76, in: "u-root", want: "OUWXE33POQ======\n"},
+		{wrap: 8, in: "u-root", want: "OUWXE33P\nOQ======\n"},
+		{decode: true, in: "OUWXE33P\nOQ", want: "u-root"},
+		{decode: true, ignore: true, in: "OUWX-E33P-OQ", want: "u-root"},
+		{wrap: -1, err: errUsage},
+		{args: []string{"a", "b"}, err: errUsage},
+	} {
+		*decode, *ignore, *wrap = tt.decode, tt.ignore, tt.wrap
+		var out bytes.Buffer
+		if err := run(strings.NewReader(tt.in), &out, tt.args); err != tt.err {
+			t.Errorf("%+v: run = %v, want %v", tt, err, tt.err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%+v: printed %q, want %q", tt, out.String(), tt.want)
+		}
+	}
+}
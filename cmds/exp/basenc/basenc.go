@@ -0,0 +1,123 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// basenc encodes or decodes base64, base32 or base16 data.
+//
+// Synopsis:
+//
+//	basenc ENCODING [-d [-i]] [-w COLS] [FILE]
+//
+// Description:
+//
+//	basenc encodes FILE, or stdin if there is no FILE or it is -, in
+//	ENCODING to stdout, or decodes it with -d. Data is streamed, so it
+//	may be of any size, e.g. a disk image or a blob of a metadata
+//	service.
+//
+//	Decoding ignores line breaks, and accepts data that has its final
+//	padding left out, as base64url often has in tokens and on kernel
+//	command lines.
+//
+// Options:
+//
+//	--base64:    standard base64, as base64 has
+//	--base64url: base64 with the URL and file name safe alphabet, - and _
+//	--base32:    standard base32, as base32 has
+//	--base32hex: base32 with the extended hex alphabet
+//	--base16:    upper case hexadecimal
+//	--hex:       lower case hexadecimal; decoding accepts either case
+//	-d, --decode:         decode data
+//	-i, --ignore-garbage: when decoding, ignore characters not of ENCODING
+//	-w, --wrap:           break encoded lines after COLS characters, 76 by
+//	                      default; 0 does not break them
+//
+// Example:
+//
+//	$ echo -n 'u-root' | basenc --base64url
+//	dS1yb290
+//	$ basenc --base64url -d <<< dS1yb290
+//	u-root
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	flag "github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/basenc"
+)
+
+var (
+	decode = flag.BoolP("decode", "d", false, "decode data")
+	ignore = flag.BoolP("ignore-garbage", "i", false, "when decoding, ignore characters not of ENCODING")
+	wrap   = flag.IntP("wrap", "w", 76, "break encoded lines after `COLS` characters, or not with 0")
+)
+
+// encodings are the options of the encodings, by name.
+var encodings = map[string]*bool{}
+
+func init() {
+	for _, e := range basenc.Encodings {
+		encodings[e.Name] = flag.Bool(e.Name, false, fmt.Sprintf("use %s", e.Name))
+	}
+}
+
+var errUsage = errors.New("usage: basenc --base64|--base64url|--base32|--base32hex|--base16|--hex [-d [-i]] [-w COLS] [FILE]")
+
+// encoding returns the encoding of the options.
+func encoding() (*basenc.Encoding, error) {
+	var enc *basenc.Encoding
+	for _, e := range basenc.Encodings {
+		if *encodings[e.Name] {
+			if enc != nil {
+				return nil, errUsage
+			}
+			enc = e
+		}
+	}
+	if enc == nil {
+		return nil, errUsage
+	}
+	return enc, nil
+}
+
+func run(stdin io.Reader, stdout io.Writer, args []string) error {
+	enc, err := encoding()
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 || *wrap < 0 {
+		return errUsage
+	}
+	if len(args) == 1 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stdin = f
+	}
+
+	if *decode {
+		_, err := io.Copy(stdout, basenc.NewDecoder(enc, stdin, *ignore))
+		return err
+	}
+	w := basenc.NewEncoder(enc, stdout, *wrap)
+	if _, err := io.Copy(w, stdin); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func main() {
+	log.SetPrefix("basenc: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdin, os.Stdout, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "in")
+	if err := os.WriteFile(file, []byte("dS1yb290"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		encodings []string
+		decode    bool
+		ignore    bool
+		wrap      int
+		args      []string
+		in        string
+		want      string
+		err       error
+	}{
+		{encodings: []string{"base64"}, wrap: 76, in: "u-root", want: "dS1yb290\n"},
+		{encodings: []string{"base64url"}, wrap: 4, in: "\xfb\xff\xfe?", want: "-__-\nPw==\n"},
+		{encodings: []string{"base64url"}, decode: true, args: []string{file}, want: "u-root"},
+		{encodings: []string{"base64url"}, decode: true, args: []string{"-"}, in: "Pw", want: "?"},
+		{encodings: []string{"hex"}, decode: true, ignore: true, in: "de:ad:be:ef", want: "\xde\xad\xbe\xef"},
+		{encodings: []string{"base32"}, wrap: 0, in: "u-root", want: "OUWXE33POQ======\n"},
+		{encodings: []string{"base32", "hex"}, err: errUsage},
+		{err: errUsage},
+		{encodings: []string{"hex"}, wrap: -1, err: errUsage},
+		{encodings: []string{"hex"}, args: []string{file, file}, err: errUsage},
+	} {
+		for _, b := range encodings {
+			*b = false
+		}
+		for _, e := range tt.encodings {
+			*encodings[e] = true
+		}
+		*decode, *ignore, *wrap = tt.decode, tt.ignore, tt.wrap
+		var out bytes.Buffer
+		if err := run(strings.NewReader(tt.in), &out, tt.args); err != tt.err {
+			t.Errorf("%+v: run = %v, want %v", tt, err, tt.err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%+v: printed %q, want %q", tt, out.String(), tt.want)
+		}
+	}
+}
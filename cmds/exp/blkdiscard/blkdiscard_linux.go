@@ -0,0 +1,201 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// blkdiscard discards the sectors of a block device, e.g. to TRIM an SSD
+// before it is reused.
+//
+// Synopsis:
+//
+//	blkdiscard [-o OFFSET] [-l LENGTH] [-p STEP] [-s | -z] [-f] [-v] DEVICE
+//
+// Description:
+//
+//	All data on DEVICE, or on LENGTH bytes from OFFSET, is discarded.
+//	OFFSET and LENGTH, which may have units like 1MiB or 1GB, must be
+//	multiples of the sector size. Discarded sectors may read back as
+//	zeroes, or as the old data: use -z to be sure they are zeroes, or -s,
+//	if the device supports it, for the old data to be gone from the
+//	device too, e.g. from its spare blocks.
+//
+//	Devices in use, e.g. mounted, are not discarded, unless -f is given.
+//
+// Options:
+//
+//	-o, --offset:  offset in bytes to discard from (default 0)
+//	-l, --length:  bytes to discard (default to the end of the device)
+//	-p, --step:    bytes to discard at a time, e.g. to show progress with -v
+//	-s, --secure:  secure discard
+//	-z, --zeroout: zero-fill rather than discard
+//	-f, --force:   discard even devices that are in use
+//	-v, --verbose: print what is discarded
+//
+// Example:
+//
+//	blkdiscard -v /dev/nvme0n1
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"unsafe"
+
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/spf13/pflag"
+	"golang.org/x/sys/unix"
+)
+
+// The discard ioctls are _IO(0x12, NR), as BLKRRPART is, so they are derived
+// from it to get the direction bits of the architecture right.
+const (
+	blkDiscard    = unix.BLKRRPART - 95 + 119
+	blkSecDiscard = unix.BLKRRPART - 95 + 125
+	blkZeroOut    = unix.BLKRRPART - 95 + 127
+)
+
+var (
+	offset  = flag.StringP("offset", "o", "0", "offset in bytes to discard from")
+	length  = flag.StringP("length", "l", "", "bytes to discard (default to the end of the device)")
+	step    = flag.StringP("step", "p", "", "bytes to discard at a time")
+	secure  = flag.BoolP("secure", "s", false, "secure discard")
+	zeroOut = flag.BoolP("zeroout", "z", false, "zero-fill rather than discard")
+	force   = flag.BoolP("force", "f", false, "discard even devices that are in use")
+	verbose = flag.BoolP("verbose", "v", false, "print what is discarded")
+)
+
+// byteRange is a range of bytes to discard, as the ioctls take it.
+type byteRange struct {
+	off, len uint64
+}
+
+// ranges splits the bytes to discard of a device of size bytes, with
+// sectors of sector bytes, into ranges of at most step bytes. A length of 0
+// is to the end of the device, and a step of 0 is all at once.
+func ranges(off, length, step, size, sector uint64) ([]byteRange, error) {
+	if off%sector != 0 {
+		return nil, fmt.Errorf("offset %d is not a multiple of the %d byte sectors", off, sector)
+	}
+	if length%sector != 0 {
+		return nil, fmt.Errorf("length %d is not a multiple of the %d byte sectors", length, sector)
+	}
+	if step%sector != 0 {
+		return nil, fmt.Errorf("step %d is not a multiple of the %d byte sectors", step, sector)
+	}
+	if off > size {
+		return nil, fmt.Errorf("offset %d is past the end of the device, at %d", off, size)
+	}
+	if length == 0 || length > size-off {
+		length = size - off
+	}
+	if step == 0 {
+		step = length
+	}
+	var r []byteRange
+	for end := off + length; off < end; off += step {
+		n := step
+		if n > end-off {
+			n = end - off
+		}
+		r = append(r, byteRange{off: off, len: n})
+	}
+	return r, nil
+}
+
+// parseSize parses a size in bytes, maybe with units, or "" as 0.
+func parseSize(name, s string) (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := humanize.ParseBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", name, s, err)
+	}
+	return n, nil
+}
+
+func run(name string) error {
+	if *secure && *zeroOut {
+		return errors.New("-s and -z are exclusive")
+	}
+	off, err := parseSize("offset", *offset)
+	if err != nil {
+		return err
+	}
+	length, err := parseSize("length", *length)
+	if err != nil {
+		return err
+	}
+	stepSize, err := parseSize("step", *step)
+	if err != nil {
+		return err
+	}
+
+	mode := os.O_WRONLY
+	// Block devices that are mounted, or otherwise in use, cannot be
+	// opened exclusively.
+	if !*force {
+		mode |= unix.O_EXCL
+	}
+	f, err := os.OpenFile(name, mode, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err != nil {
+		return err
+	} else if fi.Mode()&os.ModeDevice == 0 || fi.Mode()&os.ModeCharDevice != 0 {
+		return fmt.Errorf("%s is not a block device", name)
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	sector, err := unix.IoctlGetInt(int(f.Fd()), unix.BLKSSZGET)
+	if err != nil {
+		return fmt.Errorf("%s: getting the sector size: %v", name, err)
+	}
+	rs, err := ranges(off, length, stepSize, uint64(size), uint64(sector))
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	req, what := uintptr(blkDiscard), "Discarded"
+	switch {
+	case *secure:
+		req, what = blkSecDiscard, "Securely discarded"
+	case *zeroOut:
+		req, what = blkZeroOut, "Zero-filled"
+	}
+	for _, r := range rs {
+		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(&r))); errno != 0 {
+			if errno == unix.EOPNOTSUPP {
+				return fmt.Errorf("%s: %s is not supported by the device", name, what)
+			}
+			return fmt.Errorf("%s: %v", name, errno)
+		}
+		if *verbose {
+			fmt.Printf("%s: %s %d bytes from offset %d\n", name, what, r.len, r.off)
+		}
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("blkdiscard: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: blkdiscard [-o OFFSET] [-l LENGTH] [-p STEP] [-s | -z] [-f] [-v] DEVICE\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
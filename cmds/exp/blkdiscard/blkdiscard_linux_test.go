@@ -0,0 +1,68 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRanges(t *testing.T) {
+	const size = 1 << 20
+	for _, tt := range []struct {
+		name              string
+		off, length, step uint64
+		sector            uint64
+		want              []byteRange
+		wantErr           bool
+	}{
+		{name: "whole device", sector: 512, want: []byteRange{{0, size}}},
+		{name: "offset", off: 4096, sector: 512, want: []byteRange{{4096, size - 4096}}},
+		{name: "length", off: 4096, length: 8192, sector: 4096, want: []byteRange{{4096, 8192}}},
+		{name: "past the end", off: size - 512, length: 4096, sector: 512, want: []byteRange{{size - 512, 512}}},
+		{
+			name:   "steps",
+			off:    512,
+			length: 2560,
+			step:   1024,
+			sector: 512,
+			want:   []byteRange{{512, 1024}, {1536, 1024}, {2560, 512}},
+		},
+		{name: "offset at the end", off: size, sector: 512},
+		{name: "unaligned offset", off: 100, sector: 512, wantErr: true},
+		{name: "unaligned length", length: 512, sector: 4096, wantErr: true},
+		{name: "unaligned step", step: 1000, sector: 512, wantErr: true},
+		{name: "offset past the end", off: size + 512, sector: 512, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ranges(tt.off, tt.length, tt.step, size, tt.sector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ranges = %v, want error %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ranges = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tt := range []struct {
+		s       string
+		want    uint64
+		wantErr bool
+	}{
+		{s: "", want: 0},
+		{s: "4096", want: 4096},
+		{s: "1MiB", want: 1 << 20},
+		{s: "1GB", want: 1000 * 1000 * 1000},
+		{s: "lots", wantErr: true},
+	} {
+		got, err := parseSize("length", tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d, error %t", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
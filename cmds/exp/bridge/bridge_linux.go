@@ -0,0 +1,434 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// bridge shows and changes the forwarding database and VLANs of bridges.
+//
+// Synopsis:
+//
+//	bridge fdb [show] [dev DEV] [br BRIDGE]
+//	bridge fdb {add|append|replace|del} LLADDR dev DEV [dst IP] [vlan VID]
+//		[self] [master] [router] [extern_learn] [permanent|static|dynamic]
+//	bridge vlan [show] [dev DEV]
+//	bridge vlan {add|del} dev DEV vid VID[-VID] [pvid] [untagged] [self] [master]
+//
+// Description:
+//
+//	bridge is a subset of the iproute2 command of the same name.
+//
+//	fdb entries are added to the device itself (self) unless master is
+//	given, and are permanent unless static or dynamic is given.
+//
+//	VLANs are added to the bridge port. With self, they are added to the
+//	bridge device itself.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// links looks up links by name.
+type links func(name string) (netlink.Link, error)
+
+// args is a cursor over the arguments of a command.
+type args struct {
+	a []string
+}
+
+func (a *args) more() bool {
+	return len(a.a) > 0
+}
+
+// next returns the next argument, or an error saying what is missing.
+func (a *args) next(what string) (string, error) {
+	if len(a.a) == 0 {
+		return "", fmt.Errorf("missing %s", what)
+	}
+	s := a.a[0]
+	a.a = a.a[1:]
+	return s, nil
+}
+
+// link returns the link named by the next argument.
+func (a *args) link(l links, what string) (netlink.Link, error) {
+	name, err := a.next(what)
+	if err != nil {
+		return nil, err
+	}
+	return l(name)
+}
+
+// op returns the operation: the next argument if it is one of ops, or
+// "show" if there are no more arguments or the next one is not an operation.
+func (a *args) op(ops ...string) string {
+	if a.more() {
+		for _, op := range ops {
+			if a.a[0] == op {
+				a.a = a.a[1:]
+				return op
+			}
+		}
+	}
+	if a.more() && a.a[0] == "show" {
+		a.a = a.a[1:]
+	}
+	return "show"
+}
+
+// fdbCmd is a parsed fdb command.
+type fdbCmd struct {
+	op    string
+	neigh netlink.Neigh
+}
+
+func parseFdb(argv []string, l links) (*fdbCmd, error) {
+	a := &args{a: argv}
+	c := &fdbCmd{
+		op:    a.op("add", "append", "replace", "del", "delete"),
+		neigh: netlink.Neigh{Family: unix.AF_BRIDGE},
+	}
+	if c.op == "delete" {
+		c.op = "del"
+	}
+	if c.op != "show" {
+		mac, err := a.next("LLADDR")
+		if err != nil {
+			return nil, err
+		}
+		if c.neigh.HardwareAddr, err = net.ParseMAC(mac); err != nil {
+			return nil, err
+		}
+		c.neigh.State = netlink.NUD_NOARP
+	}
+
+	hasDev := false
+	for a.more() {
+		w, _ := a.next("")
+		// Entries are shown by dev and br, and br is not an entry's.
+		if show := c.op == "show"; show && w != "dev" && w != "br" || !show && w == "br" {
+			return nil, fmt.Errorf("unknown fdb %s argument %q", c.op, w)
+		}
+		var err error
+		switch {
+		case w == "dev":
+			var dev netlink.Link
+			if dev, err = a.link(l, "device name"); err == nil {
+				c.neigh.LinkIndex, hasDev = dev.Attrs().Index, true
+			}
+		case w == "br":
+			var br netlink.Link
+			if br, err = a.link(l, "bridge name"); err == nil {
+				c.neigh.MasterIndex = br.Attrs().Index
+			}
+		case w == "dst":
+			var s string
+			if s, err = a.next("destination address"); err == nil {
+				if c.neigh.IP = net.ParseIP(s); c.neigh.IP == nil {
+					err = fmt.Errorf("invalid destination address %q", s)
+				}
+			}
+		case w == "vlan":
+			var s string
+			if s, err = a.next("VLAN ID"); err == nil {
+				c.neigh.Vlan, err = parseVID(s)
+			}
+		case w == "self":
+			c.neigh.Flags |= netlink.NTF_SELF
+		case w == "master":
+			c.neigh.Flags |= netlink.NTF_MASTER
+		case w == "router":
+			c.neigh.Flags |= netlink.NTF_ROUTER
+		case w == "extern_learn":
+			c.neigh.Flags |= netlink.NTF_EXT_LEARNED
+		case w == "permanent":
+			c.neigh.State |= netlink.NUD_PERMANENT
+		case w == "static" || w == "temp":
+			c.neigh.State |= netlink.NUD_REACHABLE
+		case w == "dynamic":
+			c.neigh.State = c.neigh.State&^netlink.NUD_NOARP | netlink.NUD_REACHABLE
+		default:
+			err = fmt.Errorf("unknown fdb %s argument %q", c.op, w)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.op == "show" {
+		return c, nil
+	}
+	if !hasDev {
+		return nil, errors.New("missing dev DEV")
+	}
+	// Like iproute2, assume self.
+	if c.neigh.Flags&(netlink.NTF_SELF|netlink.NTF_MASTER) == 0 {
+		c.neigh.Flags |= netlink.NTF_SELF
+	}
+	return c, nil
+}
+
+func parseVID(s string) (int, error) {
+	v, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || v < 1 || v > 4094 {
+		return 0, fmt.Errorf("invalid VLAN ID %q", s)
+	}
+	return int(v), nil
+}
+
+// vlanCmd is a parsed vlan command.
+type vlanCmd struct {
+	op                           string
+	link                         netlink.Link
+	vids                         []uint16
+	pvid, untagged, self, master bool
+}
+
+func parseVlan(argv []string, l links) (*vlanCmd, error) {
+	a := &args{a: argv}
+	c := &vlanCmd{op: a.op("add", "del", "delete")}
+	if c.op == "delete" {
+		c.op = "del"
+	}
+	for a.more() {
+		w, _ := a.next("")
+		if c.op == "show" && w != "dev" {
+			return nil, fmt.Errorf("unknown vlan show argument %q", w)
+		}
+		var err error
+		switch {
+		case w == "dev":
+			c.link, err = a.link(l, "device name")
+		case w == "vid":
+			var s string
+			if s, err = a.next("VLAN ID"); err == nil {
+				c.vids, err = parseVIDs(s)
+			}
+		case w == "pvid":
+			c.pvid = true
+		case w == "untagged":
+			c.untagged = true
+		case w == "self":
+			c.self = true
+		case w == "master":
+			c.master = true
+		default:
+			err = fmt.Errorf("unknown vlan %s argument %q", c.op, w)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.op == "show" {
+		return c, nil
+	}
+	if c.link == nil {
+		return nil, errors.New("missing dev DEV")
+	}
+	if c.vids == nil {
+		return nil, errors.New("missing vid VID")
+	}
+	if c.pvid && len(c.vids) > 1 {
+		return nil, errors.New("a range of VLANs cannot be the PVID")
+	}
+	return c, nil
+}
+
+// parseVIDs parses a VLAN ID or a range of them, like 10-20.
+func parseVIDs(s string) ([]uint16, error) {
+	from, to, isRange := strings.Cut(s, "-")
+	start, err := parseVID(from)
+	if err != nil {
+		return nil, err
+	}
+	end := start
+	if isRange {
+		if end, err = parseVID(to); err != nil {
+			return nil, err
+		}
+		if end < start {
+			return nil, fmt.Errorf("invalid VLAN range %q", s)
+		}
+	}
+	var vids []uint16
+	for v := start; v <= end; v++ {
+		vids = append(vids, uint16(v))
+	}
+	return vids, nil
+}
+
+// linkNames returns the names of links by index.
+func linkNames() (map[int]string, error) {
+	ls, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string)
+	for _, l := range ls {
+		names[l.Attrs().Index] = l.Attrs().Name
+	}
+	return names, nil
+}
+
+// formatNeigh formats an fdb entry like iproute2.
+func formatNeigh(n netlink.Neigh, names map[int]string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s dev %s", n.HardwareAddr, names[n.LinkIndex])
+	if len(n.IP) > 0 && !n.IP.IsUnspecified() {
+		fmt.Fprintf(&sb, " dst %s", n.IP)
+	}
+	if n.Vlan != 0 {
+		fmt.Fprintf(&sb, " vlan %d", n.Vlan)
+	}
+	if n.Flags&netlink.NTF_SELF != 0 {
+		sb.WriteString(" self")
+	}
+	if n.MasterIndex != 0 {
+		fmt.Fprintf(&sb, " master %s", names[n.MasterIndex])
+	}
+	if n.Flags&netlink.NTF_ROUTER != 0 {
+		sb.WriteString(" router")
+	}
+	if n.Flags&netlink.NTF_EXT_LEARNED != 0 {
+		sb.WriteString(" extern_learn")
+	}
+	if n.Flags&netlink.NTF_OFFLOADED != 0 {
+		sb.WriteString(" offload")
+	}
+	switch {
+	case n.State&netlink.NUD_PERMANENT != 0:
+		sb.WriteString(" permanent")
+	case n.State&netlink.NUD_NOARP != 0:
+		sb.WriteString(" static")
+	}
+	return sb.String()
+}
+
+// formatVlans formats the VLANs of a port like iproute2.
+func formatVlans(name string, vlans []*nl.BridgeVlanInfo) string {
+	var sb strings.Builder
+	for i, v := range vlans {
+		if i == 0 {
+			fmt.Fprintf(&sb, "%-16s %d", name, v.Vid)
+		} else {
+			fmt.Fprintf(&sb, "%-16s %d", "", v.Vid)
+		}
+		if v.PortVID() {
+			sb.WriteString(" PVID")
+		}
+		if v.EngressUntag() {
+			sb.WriteString(" Egress Untagged")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func fdb(w io.Writer, argv []string) error {
+	c, err := parseFdb(argv, netlink.LinkByName)
+	if err != nil {
+		return err
+	}
+	switch c.op {
+	case "add":
+		return netlink.NeighAdd(&c.neigh)
+	case "append":
+		return netlink.NeighAppend(&c.neigh)
+	case "replace":
+		return netlink.NeighSet(&c.neigh)
+	case "del":
+		return netlink.NeighDel(&c.neigh)
+	}
+
+	neighs, err := netlink.NeighList(c.neigh.LinkIndex, unix.AF_BRIDGE)
+	if err != nil {
+		return err
+	}
+	names, err := linkNames()
+	if err != nil {
+		return err
+	}
+	for _, n := range neighs {
+		if c.neigh.MasterIndex != 0 && n.MasterIndex != c.neigh.MasterIndex {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, formatNeigh(n, names)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func vlan(w io.Writer, argv []string) error {
+	c, err := parseVlan(argv, netlink.LinkByName)
+	if err != nil {
+		return err
+	}
+	switch c.op {
+	case "add", "del":
+		modify := netlink.BridgeVlanAdd
+		if c.op == "del" {
+			modify = netlink.BridgeVlanDel
+		}
+		for _, vid := range c.vids {
+			if err := modify(c.link, vid, c.pvid, c.untagged, c.self, c.master); err != nil {
+				return fmt.Errorf("VLAN %d: %w", vid, err)
+			}
+		}
+		return nil
+	}
+
+	vlans, err := netlink.BridgeVlanList()
+	if err != nil {
+		return err
+	}
+	names, err := linkNames()
+	if err != nil {
+		return err
+	}
+	var indexes []int
+	for i := range vlans {
+		if c.link == nil || int(i) == c.link.Attrs().Index {
+			indexes = append(indexes, int(i))
+		}
+	}
+	sort.Ints(indexes)
+	if _, err := fmt.Fprintf(w, "%-16s %s\n", "port", "vlan ids"); err != nil {
+		return err
+	}
+	for _, i := range indexes {
+		if _, err := io.WriteString(w, formatVlans(names[i], vlans[int32(i)])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func run(w io.Writer, argv []string) error {
+	if len(argv) == 0 {
+		return errors.New("usage: bridge {fdb|vlan} ...")
+	}
+	switch argv[0] {
+	case "fdb":
+		return fdb(w, argv[1:])
+	case "vlan":
+		return vlan(w, argv[1:])
+	}
+	return fmt.Errorf("unknown object %q, want fdb or vlan", argv[0])
+}
+
+func main() {
+	if err := run(os.Stdout, os.Args[1:]); err != nil {
+		log.Fatalf("bridge: %v", err)
+	}
+}
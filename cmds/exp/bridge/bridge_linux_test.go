@@ -0,0 +1,118 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+func fakeLinks(name string) (netlink.Link, error) {
+	for i, n := range []string{"lo", "eth0", "br0", "vxlan0"} {
+		if n == name {
+			return &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: n, Index: i + 1}}, nil
+		}
+	}
+	return nil, fmt.Errorf("no link %q", name)
+}
+
+func TestParseFdb(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+	for _, tt := range []struct {
+		args string
+		want *fdbCmd
+	}{
+		{args: "", want: &fdbCmd{op: "show", neigh: netlink.Neigh{Family: unix.AF_BRIDGE}}},
+		{args: "show br br0", want: &fdbCmd{op: "show", neigh: netlink.Neigh{Family: unix.AF_BRIDGE, MasterIndex: 3}}},
+		{args: "dev eth0", want: &fdbCmd{op: "show", neigh: netlink.Neigh{Family: unix.AF_BRIDGE, LinkIndex: 2}}},
+		{
+			args: "add 52:54:00:12:34:56 dev eth0 master vlan 10",
+			want: &fdbCmd{op: "add", neigh: netlink.Neigh{
+				Family: unix.AF_BRIDGE, LinkIndex: 2, HardwareAddr: mac, Vlan: 10,
+				Flags: netlink.NTF_MASTER, State: netlink.NUD_NOARP,
+			}},
+		},
+		{
+			args: "append 52:54:00:12:34:56 dev vxlan0 dst 192.0.2.7 permanent",
+			want: &fdbCmd{op: "append", neigh: netlink.Neigh{
+				Family: unix.AF_BRIDGE, LinkIndex: 4, HardwareAddr: mac, IP: net.ParseIP("192.0.2.7"),
+				Flags: netlink.NTF_SELF, State: netlink.NUD_NOARP | netlink.NUD_PERMANENT,
+			}},
+		},
+		{
+			args: "delete 52:54:00:12:34:56 dev eth0 self dynamic",
+			want: &fdbCmd{op: "del", neigh: netlink.Neigh{
+				Family: unix.AF_BRIDGE, LinkIndex: 2, HardwareAddr: mac,
+				Flags: netlink.NTF_SELF, State: netlink.NUD_REACHABLE,
+			}},
+		},
+		{args: "add 52:54:00:12:34:56"},
+		{args: "add 52:54:00:12:34 dev eth0"},
+		{args: "add 52:54:00:12:34:56 dev eth1"},
+		{args: "add 52:54:00:12:34:56 dev eth0 vlan 4095"},
+		{args: "add 52:54:00:12:34:56 dev eth0 br br0"},
+		{args: "show vlan 10"},
+		{args: "show dev"},
+	} {
+		got, err := parseFdb(strings.Fields(tt.args), fakeLinks)
+		if (err != nil) != (tt.want == nil) {
+			t.Errorf("parseFdb(%q) = %v, want error %v", tt.args, err, tt.want == nil)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFdb(%q) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParseVlan(t *testing.T) {
+	eth0, _ := fakeLinks("eth0")
+	br0, _ := fakeLinks("br0")
+	for _, tt := range []struct {
+		args string
+		want *vlanCmd
+	}{
+		{args: "show", want: &vlanCmd{op: "show"}},
+		{args: "dev eth0", want: &vlanCmd{op: "show", link: eth0}},
+		{args: "add dev eth0 vid 10 pvid untagged", want: &vlanCmd{op: "add", link: eth0, vids: []uint16{10}, pvid: true, untagged: true}},
+		{args: "del dev br0 vid 20-22 self", want: &vlanCmd{op: "del", link: br0, vids: []uint16{20, 21, 22}, self: true}},
+		{args: "add vid 10"},
+		{args: "add dev eth0"},
+		{args: "add dev eth0 vid 10-12 pvid"},
+		{args: "add dev eth0 vid 12-10"},
+		{args: "add dev eth0 vid 0"},
+		{args: "show vid 10"},
+	} {
+		got, err := parseVlan(strings.Fields(tt.args), fakeLinks)
+		if (err != nil) != (tt.want == nil) {
+			t.Errorf("parseVlan(%q) = %v, want error %v", tt.args, err, tt.want == nil)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseVlan(%q) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+	names := map[int]string{2: "eth0", 3: "br0"}
+	n := netlink.Neigh{LinkIndex: 2, MasterIndex: 3, HardwareAddr: mac, Vlan: 10, Flags: netlink.NTF_MASTER, State: netlink.NUD_NOARP | netlink.NUD_PERMANENT}
+	if got, want := formatNeigh(n, names), "52:54:00:12:34:56 dev eth0 vlan 10 master br0 permanent"; got != want {
+		t.Errorf("formatNeigh = %q, want %q", got, want)
+	}
+
+	vlans := []*nl.BridgeVlanInfo{
+		{Vid: 1, Flags: nl.BRIDGE_VLAN_INFO_PVID | nl.BRIDGE_VLAN_INFO_UNTAGGED},
+		{Vid: 10},
+	}
+	want := "eth0             1 PVID Egress Untagged\n                 10\n"
+	if got := formatVlans("eth0", vlans); got != want {
+		t.Errorf("formatVlans = %q, want %q", got, want)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"reflect"
 	"text/tabwriter"
 
+	"github.com/u-root/u-root/pkg/coreboot"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 )
@@ -437,7 +438,18 @@ func cbMem(w io.Writer) error {
 		DumpMem(f, cbmem, hexdump, os.Stdout)
 	}
 	if console && cbmem.MemConsole != nil {
-		fmt.Fprintf(w, "%s%s", cbmem.MemConsole.Data[cbmem.MemConsole.Cursor:], cbmem.MemConsole.Data[0:cbmem.MemConsole.Cursor])
+		m := coreboot.NewMem(f)
+		t, err := coreboot.FindTable(m)
+		if err != nil {
+			return err
+		}
+		c, err := t.Console(m)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(c); err != nil {
+			return err
+		}
 	}
 	return err
 }
@@ -0,0 +1,521 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// certtool inspects certificates and creates keys, CSRs and self-signed
+// certificates.
+//
+// Synopsis:
+//
+//	certtool show FILE...
+//	certtool fetch [-servername NAME] HOST:PORT
+//	certtool verify -ca FILE [-name HOST] FILE
+//	certtool genkey [-type ecdsa|rsa|ed25519] [-bits N] [-out FILE]
+//	certtool csr -key FILE -subject DN [-dns NAMES] [-ip ADDRS] [-out FILE]
+//	certtool selfsign -key FILE -subject DN [-days N] [-ca] [-dns NAMES] [-ip ADDRS] [-out FILE]
+//
+// Description:
+//
+//	show prints PEM or DER certificates and CSRs. fetch connects to a TLS
+//	server and prints the chain it presents, without verifying it. verify
+//	checks a certificate chain against the given roots.
+//
+//	Subjects are written as comma separated attributes, e.g.
+//	"CN=node1,O=Example,OU=Fleet,C=US". NAMES and ADDRS are comma
+//	separated lists.
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `usage:
+	certtool show FILE...
+	certtool fetch [-servername NAME] HOST:PORT
+	certtool verify -ca FILE [-name HOST] FILE
+	certtool genkey [-type ecdsa|rsa|ed25519] [-bits N] [-out FILE]
+	certtool csr -key FILE -subject DN [-dns NAMES] [-ip ADDRS] [-out FILE]
+	certtool selfsign -key FILE -subject DN [-days N] [-ca] [-dns NAMES] [-ip ADDRS] [-out FILE]`
+
+var errUsage = errors.New(usage)
+
+// parseSubject parses "CN=a,O=b" style distinguished names.
+func parseSubject(s string) (pkix.Name, error) {
+	var n pkix.Name
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return n, fmt.Errorf("bad subject attribute %q", part)
+		}
+		k, v := strings.ToUpper(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		switch k {
+		case "CN":
+			n.CommonName = v
+		case "O":
+			n.Organization = append(n.Organization, v)
+		case "OU":
+			n.OrganizationalUnit = append(n.OrganizationalUnit, v)
+		case "C":
+			n.Country = append(n.Country, v)
+		case "ST":
+			n.Province = append(n.Province, v)
+		case "L":
+			n.Locality = append(n.Locality, v)
+		case "SERIALNUMBER":
+			n.SerialNumber = v
+		default:
+			return n, fmt.Errorf("unsupported subject attribute %q", k)
+		}
+	}
+	return n, nil
+}
+
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func parseIPs(s string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, v := range splitList(s) {
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, fmt.Errorf("bad IP address %q", v)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+func generateKey(typ string, bits int) (crypto.Signer, error) {
+	switch typ {
+	case "ecdsa":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "rsa":
+		return rsa.GenerateKey(rand.Reader, bits)
+	case "ed25519":
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		return k, err
+	}
+	return nil, fmt.Errorf("unknown key type %q", typ)
+}
+
+func encodeKey(k crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func loadKey(path string) (crypto.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, _ := pem.Decode(b)
+	if p == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	var k interface{}
+	switch p.Type {
+	case "RSA PRIVATE KEY":
+		k, err = x509.ParsePKCS1PrivateKey(p.Bytes)
+	case "EC PRIVATE KEY":
+		k, err = x509.ParseECPrivateKey(p.Bytes)
+	default:
+		k, err = x509.ParsePKCS8PrivateKey(p.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	s, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: %T is not a signing key", path, k)
+	}
+	return s, nil
+}
+
+// decodeAll returns all PEM blocks in b, or b itself as a single DER
+// certificate block.
+func decodeAll(b []byte) []*pem.Block {
+	var blocks []*pem.Block
+	for {
+		var p *pem.Block
+		p, b = pem.Decode(b)
+		if p == nil {
+			break
+		}
+		blocks = append(blocks, p)
+	}
+	if len(blocks) == 0 && len(b) > 0 {
+		blocks = append(blocks, &pem.Block{Type: "CERTIFICATE", Bytes: b})
+	}
+	return blocks
+}
+
+func loadCerts(path string) ([]*x509.Certificate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for _, p := range decodeAll(b) {
+		if p.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: no certificates", path)
+	}
+	return certs, nil
+}
+
+func keyDesc(pub interface{}) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d bits", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return fmt.Sprintf("%T", pub)
+}
+
+func printCert(w io.Writer, c *x509.Certificate, now time.Time) {
+	fmt.Fprintf(w, "Subject:     %s\n", c.Subject)
+	fmt.Fprintf(w, "Issuer:      %s\n", c.Issuer)
+	fmt.Fprintf(w, "Serial:      %s\n", c.SerialNumber.Text(16))
+	fmt.Fprintf(w, "Not before:  %s\n", c.NotBefore.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "Not after:   %s\n", c.NotAfter.UTC().Format(time.RFC3339))
+	switch {
+	case now.Before(c.NotBefore):
+		fmt.Fprintf(w, "Validity:    NOT YET VALID (clock is %s)\n", now.UTC().Format(time.RFC3339))
+	case now.After(c.NotAfter):
+		fmt.Fprintf(w, "Validity:    EXPIRED\n")
+	default:
+		fmt.Fprintf(w, "Validity:    valid\n")
+	}
+	fmt.Fprintf(w, "Public key:  %s\n", keyDesc(c.PublicKey))
+	fmt.Fprintf(w, "Signature:   %s\n", c.SignatureAlgorithm)
+	fmt.Fprintf(w, "CA:          %t\n", c.IsCA)
+	if len(c.DNSNames) > 0 {
+		fmt.Fprintf(w, "DNS names:   %s\n", strings.Join(c.DNSNames, ", "))
+	}
+	if len(c.IPAddresses) > 0 {
+		var ips []string
+		for _, ip := range c.IPAddresses {
+			ips = append(ips, ip.String())
+		}
+		fmt.Fprintf(w, "IP addrs:    %s\n", strings.Join(ips, ", "))
+	}
+	s1 := sha1.Sum(c.Raw)
+	s256 := sha256.Sum256(c.Raw)
+	fmt.Fprintf(w, "SHA-1:       %s\n", hex.EncodeToString(s1[:]))
+	fmt.Fprintf(w, "SHA-256:     %s\n", hex.EncodeToString(s256[:]))
+}
+
+func printCSR(w io.Writer, r *x509.CertificateRequest) {
+	fmt.Fprintf(w, "Request subject: %s\n", r.Subject)
+	fmt.Fprintf(w, "Public key:      %s\n", keyDesc(r.PublicKey))
+	if len(r.DNSNames) > 0 {
+		fmt.Fprintf(w, "DNS names:       %s\n", strings.Join(r.DNSNames, ", "))
+	}
+	if err := r.CheckSignature(); err != nil {
+		fmt.Fprintf(w, "Signature:       BAD: %v\n", err)
+	} else {
+		fmt.Fprintf(w, "Signature:       ok\n")
+	}
+}
+
+func show(w io.Writer, files []string) error {
+	if len(files) == 0 {
+		return errUsage
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		for i, p := range decodeAll(b) {
+			if i > 0 || len(files) > 1 {
+				fmt.Fprintf(w, "\n")
+			}
+			switch p.Type {
+			case "CERTIFICATE":
+				c, err := x509.ParseCertificate(p.Bytes)
+				if err != nil {
+					return fmt.Errorf("%s: %v", f, err)
+				}
+				printCert(w, c, time.Now())
+			case "CERTIFICATE REQUEST":
+				r, err := x509.ParseCertificateRequest(p.Bytes)
+				if err != nil {
+					return fmt.Errorf("%s: %v", f, err)
+				}
+				printCSR(w, r)
+			default:
+				fmt.Fprintf(w, "%s: skipping %s block\n", f, p.Type)
+			}
+		}
+	}
+	return nil
+}
+
+func fetch(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	serverName := fs.String("servername", "", "SNI server name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	addr := fs.Arg(0)
+	name := *serverName
+	if name == "" {
+		name, _, _ = net.SplitHostPort(addr)
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, &tls.Config{
+		ServerName: name,
+		// We want to see what the server presents, trust or not.
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for i, c := range conn.ConnectionState().PeerCertificates {
+		fmt.Fprintf(w, "Certificate %d:\n", i)
+		printCert(w, c, time.Now())
+		fmt.Fprintf(w, "\n")
+	}
+	return nil
+}
+
+func verify(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	ca := fs.String("ca", "", "file with trusted root certificates")
+	name := fs.String("name", "", "host name to verify")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *ca == "" {
+		return errUsage
+	}
+	roots, err := loadCerts(*ca)
+	if err != nil {
+		return err
+	}
+	chain, err := loadCerts(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       *name,
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, c := range roots {
+		opts.Roots.AddCert(c)
+	}
+	for _, c := range chain[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	chains, err := chain[0].Verify(opts)
+	if err != nil {
+		return err
+	}
+	for _, c := range chains[0] {
+		fmt.Fprintf(w, "%s\n", c.Subject)
+	}
+	fmt.Fprintf(w, "OK\n")
+	return nil
+}
+
+func writeOut(stdout io.Writer, path string, b []byte, mode os.FileMode) error {
+	if path == "" || path == "-" {
+		_, err := stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(path, b, mode)
+}
+
+func genkey(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("genkey", flag.ContinueOnError)
+	typ := fs.String("type", "ecdsa", "key type: ecdsa, rsa or ed25519")
+	bits := fs.Int("bits", 2048, "RSA key size")
+	out := fs.String("out", "", "output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	k, err := generateKey(*typ, *bits)
+	if err != nil {
+		return err
+	}
+	b, err := encodeKey(k)
+	if err != nil {
+		return err
+	}
+	return writeOut(w, *out, b, 0o600)
+}
+
+// certFlags are shared between csr and selfsign.
+type certFlags struct {
+	key, subject, dns, ip, out string
+}
+
+func (c *certFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.key, "key", "", "private key file")
+	fs.StringVar(&c.subject, "subject", "", "subject, e.g. CN=node1,O=Example")
+	fs.StringVar(&c.dns, "dns", "", "comma separated DNS subject alternative names")
+	fs.StringVar(&c.ip, "ip", "", "comma separated IP subject alternative names")
+	fs.StringVar(&c.out, "out", "", "output file (default stdout)")
+}
+
+func (c *certFlags) parse() (crypto.Signer, pkix.Name, []net.IP, error) {
+	if c.key == "" {
+		return nil, pkix.Name{}, nil, errUsage
+	}
+	k, err := loadKey(c.key)
+	if err != nil {
+		return nil, pkix.Name{}, nil, err
+	}
+	subj, err := parseSubject(c.subject)
+	if err != nil {
+		return nil, pkix.Name{}, nil, err
+	}
+	ips, err := parseIPs(c.ip)
+	if err != nil {
+		return nil, pkix.Name{}, nil, err
+	}
+	return k, subj, ips, nil
+}
+
+func csr(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("csr", flag.ContinueOnError)
+	var cf certFlags
+	cf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	k, subj, ips, err := cf.parse()
+	if err != nil {
+		return err
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     subj,
+		DNSNames:    splitList(cf.dns),
+		IPAddresses: ips,
+	}, k)
+	if err != nil {
+		return err
+	}
+	return writeOut(w, cf.out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), 0o644)
+}
+
+func selfsign(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("selfsign", flag.ContinueOnError)
+	var cf certFlags
+	cf.register(fs)
+	days := fs.Int("days", 365, "validity in days")
+	isCA := fs.Bool("ca", false, "mark the certificate as a CA")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	k, subj, ips, err := cf.parse()
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subj,
+		DNSNames:              splitList(cf.dns),
+		IPAddresses:           ips,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Duration(*days) * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  *isCA,
+	}
+	if *isCA {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, k.Public(), k)
+	if err != nil {
+		return err
+	}
+	return writeOut(w, cf.out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+}
+
+func run(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "show":
+		return show(w, args)
+	case "fetch":
+		return fetch(w, args)
+	case "verify":
+		return verify(w, args)
+	case "genkey":
+		return genkey(w, args)
+	case "csr":
+		return csr(w, args)
+	case "selfsign":
+		return selfsign(w, args)
+	}
+	return errUsage
+}
+
+func main() {
+	if err := run(os.Stdout, os.Args[1:]); err != nil {
+		log.Fatalf("certtool: %v", err)
+	}
+}
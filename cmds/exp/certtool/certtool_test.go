@@ -0,0 +1,59 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSubject(t *testing.T) {
+	n, err := parseSubject("CN=node1, O=Example,OU=Fleet,C=US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.CommonName != "node1" || n.Organization[0] != "Example" || n.OrganizationalUnit[0] != "Fleet" || n.Country[0] != "US" {
+		t.Errorf("parseSubject = %+v", n)
+	}
+	for _, bad := range []string{"CN", "XX=1"} {
+		if _, err := parseSubject(bad); err == nil {
+			t.Errorf("parseSubject(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	key := filepath.Join(dir, "key.pem")
+	cert := filepath.Join(dir, "cert.pem")
+	req := filepath.Join(dir, "req.pem")
+
+	for _, typ := range []string{"ecdsa", "ed25519"} {
+		var out bytes.Buffer
+		for _, args := range [][]string{
+			{"genkey", "-type", typ, "-out", key},
+			{"selfsign", "-key", key, "-subject", "CN=node1,O=Example", "-dns", "node1.example.com", "-ip", "10.0.0.1", "-ca", "-out", cert},
+			{"csr", "-key", key, "-subject", "CN=node1", "-dns", "node1.example.com", "-out", req},
+			{"show", cert, req},
+			{"verify", "-ca", cert, "-name", "node1.example.com", cert},
+		} {
+			if err := run(&out, args); err != nil {
+				t.Fatalf("%s: %v: %v", typ, args, err)
+			}
+		}
+		for _, want := range []string{"Subject:     CN=node1,O=Example", "DNS names:   node1.example.com", "IP addrs:    10.0.0.1", "Request subject: CN=node1", "Signature:       ok", "OK"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s: output lacks %q:\n%s", typ, want, out.String())
+			}
+		}
+
+		var discard bytes.Buffer
+		if err := run(&discard, []string{"verify", "-ca", cert, "-name", "other.example.com", cert}); err == nil {
+			t.Errorf("%s: verify with wrong name succeeded", typ)
+		}
+	}
+}
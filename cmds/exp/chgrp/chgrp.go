@@ -0,0 +1,103 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// chgrp changes the group of files.
+//
+// Synopsis:
+//
+//	chgrp [-R] [-h] GROUP FILE...
+//
+// Description:
+//
+//	chgrp sets the group of the FILEs to GROUP, a name of /etc/group or a
+//	gid. Owners may give their files to the groups they are members of.
+//
+// Options:
+//
+//	-R: change the files of directories, recursively, without following
+//	    symbolic links
+//	-h: change symbolic links rather than the files they point to
+//
+// Example:
+//
+//	$ chgrp -R www /var/www
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/u-root/u-root/pkg/nss"
+)
+
+var (
+	recursive = flag.Bool("R", false, "change the files of directories recursively")
+	noDeref   = flag.Bool("h", false, "change symbolic links rather than the files they point to")
+)
+
+var errUsage = errors.New("usage: chgrp [-R] [-h] GROUP FILE...")
+
+// chgrp changes the group of path, and of the files in it with -R, and
+// reports whether it could change them all.
+func chgrp(path string, gid int) bool {
+	ok := true
+	report := func(err error) {
+		if err != nil {
+			log.Print(err)
+			ok = false
+		}
+	}
+	change := os.Chown
+	if *noDeref {
+		change = os.Lchown
+	}
+	report(change(path, -1, gid))
+	if !*recursive {
+		return ok
+	}
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		switch {
+		case p == path && d == nil:
+			// path could not be changed, which was reported.
+		case err != nil:
+			report(err)
+		case p != path:
+			report(os.Lchown(p, -1, gid))
+		}
+		return nil
+	})
+	return ok
+}
+
+func run(args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	gid, err := nss.GroupID(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid group %q", args[0])
+	}
+	ok := true
+	for _, path := range args[1:] {
+		ok = chgrp(path, int(gid)) && ok
+	}
+	if !ok {
+		return errors.New("could not change the group of all files")
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("chgrp: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
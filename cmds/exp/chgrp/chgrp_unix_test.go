@@ -0,0 +1,56 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/nss"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	nss.GroupPath = filepath.Join(dir, "group")
+	if err := os.WriteFile(nss.GroupPath, []byte(fmt.Sprintf("mine:x:%d:\n", os.Getgid())), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f := filepath.Join(dir, "d", "f")
+	if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(f, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	*recursive = true
+	defer func() { *recursive = false }()
+	if err := run([]string{"mine", filepath.Dir(f)}); err != nil {
+		t.Errorf("chgrp -R mine = %v, want nil", err)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(f, &st); err != nil || int(st.Gid) != os.Getgid() {
+		t.Errorf("group of f is %d, %v, want %d", st.Gid, err, os.Getgid())
+	}
+
+	for _, tt := range []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"nogroup", f}, err: `invalid group "nogroup"`},
+		{args: []string{"mine", filepath.Join(dir, "missing")}, err: "could not change the group of all files"},
+		{args: []string{"mine"}, err: errUsage.Error()},
+	} {
+		if err := run(tt.args); err == nil || err.Error() != tt.err {
+			t.Errorf("chgrp %q = %v, want %s", tt.args, err, tt.err)
+		}
+	}
+}
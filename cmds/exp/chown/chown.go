@@ -0,0 +1,142 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// chown changes the owner and group of files.
+//
+// Synopsis:
+//
+//	chown [-R] [-h] [OWNER][:[GROUP]] FILE...
+//
+// Description:
+//
+//	chown sets the owner of the FILEs to OWNER, and their group to GROUP,
+//	each a name of /etc/passwd or /etc/group, or an id. Without GROUP,
+//	the group is left as it is, or, after a colon, set to the login group
+//	of OWNER. Without OWNER, only the group is changed.
+//
+// Options:
+//
+//	-R: change the files of directories, recursively, without following
+//	    symbolic links
+//	-h: change symbolic links rather than the files they point to
+//
+// Example:
+//
+//	$ chown -R www:www /var/www
+//	$ chown :wheel /usr/local/bin/tool
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/nss"
+)
+
+var (
+	recursive = flag.Bool("R", false, "change the files of directories recursively")
+	noDeref   = flag.Bool("h", false, "change symbolic links rather than the files they point to")
+)
+
+var errUsage = errors.New("usage: chown [-R] [-h] [OWNER][:[GROUP]] FILE...")
+
+// parseOwner returns the uid and gid of spec, OWNER[:[GROUP]], which are
+// -1 to be left as they are.
+func parseOwner(spec string) (int, int, error) {
+	owner, group, colon := strings.Cut(spec, ":")
+	uid, gid := -1, -1
+	if owner == "" && group == "" {
+		if colon {
+			return uid, gid, nil
+		}
+		return 0, 0, errUsage
+	}
+	if owner != "" {
+		id, err := nss.UserID(owner)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid user %q", owner)
+		}
+		uid = int(id)
+		if colon && group == "" {
+			u, err := nss.LookupUID(id)
+			if err != nil {
+				return 0, 0, fmt.Errorf("user %q has no login group", owner)
+			}
+			gid = int(u.GID)
+		}
+	}
+	if group != "" {
+		id, err := nss.GroupID(group)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid group %q", group)
+		}
+		gid = int(id)
+	}
+	return uid, gid, nil
+}
+
+// chown changes the owner of path, and of the files in it with -R, and
+// reports whether it could change them all.
+func chown(path string, uid, gid int) bool {
+	ok := true
+	report := func(err error) {
+		if err != nil {
+			log.Print(err)
+			ok = false
+		}
+	}
+	change := os.Chown
+	if *noDeref {
+		change = os.Lchown
+	}
+	report(change(path, uid, gid))
+	if !*recursive {
+		return ok
+	}
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		switch {
+		case p == path && d == nil:
+			// path could not be changed, which was reported.
+		case err != nil:
+			report(err)
+		case p != path:
+			report(os.Lchown(p, uid, gid))
+		}
+		return nil
+	})
+	return ok
+}
+
+func run(args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	uid, gid, err := parseOwner(args[0])
+	if err != nil {
+		return err
+	}
+	ok := true
+	for _, path := range args[1:] {
+		ok = chown(path, uid, gid) && ok
+	}
+	if !ok {
+		return errors.New("could not change the owner of all files")
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("chown: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/nss"
+)
+
+func setup(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	nss.PasswdPath = filepath.Join(dir, "passwd")
+	nss.GroupPath = filepath.Join(dir, "group")
+	passwd := fmt.Sprintf("www:x:33:34::/var/www:/bin/false\nme:x:%d:%d::/:/bin/sh\n", os.Getuid(), os.Getgid())
+	if err := os.WriteFile(nss.PasswdPath, []byte(passwd), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(nss.GroupPath, []byte("wheel:x:10:\nwww:x:34:\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseOwner(t *testing.T) {
+	setup(t)
+	for _, tt := range []struct {
+		spec     string
+		uid, gid int
+		wantErr  bool
+	}{
+		{spec: "www", uid: 33, gid: -1},
+		{spec: "www:", uid: 33, gid: 34},
+		{spec: "www:wheel", uid: 33, gid: 10},
+		{spec: ":wheel", uid: -1, gid: 10},
+		{spec: "1000:1000", uid: 1000, gid: 1000},
+		{spec: ":", uid: -1, gid: -1},
+		{spec: "1000:", wantErr: true},
+		{spec: "nobody", wantErr: true},
+		{spec: "www:nogroup", wantErr: true},
+		{spec: "", wantErr: true},
+	} {
+		uid, gid, err := parseOwner(tt.spec)
+		if (err != nil) != tt.wantErr || !tt.wantErr && (uid != tt.uid || gid != tt.gid) {
+			t.Errorf("parseOwner(%q) = %d, %d, %v, want %d, %d, error %t", tt.spec, uid, gid, err, tt.uid, tt.gid, tt.wantErr)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	setup(t)
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a", "b", "f"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("missing", filepath.Join(dir, "a", "l")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Users may give their files to themselves.
+	*recursive = true
+	defer func() { *recursive = false }()
+	if err := run([]string{"me:", filepath.Join(dir, "a")}); err != nil {
+		t.Errorf("chown -R me: = %v, want nil", err)
+	}
+	*recursive = false
+	if err := run([]string{"me", filepath.Join(dir, "a", "l")}); err == nil {
+		t.Errorf("chown of a dangling symbolic link = nil, want an error")
+	}
+	*noDeref = true
+	defer func() { *noDeref = false }()
+	if err := run([]string{"me", filepath.Join(dir, "a", "l")}); err != nil {
+		t.Errorf("chown -h of a dangling symbolic link = %v, want nil", err)
+	}
+	if err := run([]string{"me"}); err != errUsage {
+		t.Errorf("chown me = %v, want %v", err, errUsage)
+	}
+}
@@ -0,0 +1,347 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// chronyd keeps the system clock synchronized with NTP servers.
+//
+// Synopsis:
+//
+//	chronyd [-f FILE] [-q] [-minpoll N] [-maxpoll N] [-v] [SERVER...]
+//
+// Description:
+//
+//	Unlike ntpdate, which sets the clock once, chronyd keeps disciplining
+//	it, for systems that stay up, e.g. provisioning appliances. Every poll
+//	interval, the NTP servers are queried, and the median of their offsets
+//	is corrected by slewing the clock, i.e. making it run slightly faster or
+//	slower, over the next interval. The frequency error of the clock, its
+//	drift, is estimated from the offsets, and corrected too. The poll
+//	interval grows from 2^minpoll to 2^maxpoll seconds while the estimate
+//	of the drift is stable.
+//
+//	If the offset is more than the makestep threshold, the clock is stepped
+//	instead, but only in the first makestep limit updates, as clocks should
+//	only be stepped at boot.
+//
+//	A small subset of chrony.conf of chrony is read:
+//
+//	  server HOST        an NTP server; options are ignored
+//	  pool HOST          a pool of NTP servers, of which up to 4 are used
+//	  makestep SECONDS LIMIT
+//	                     step the clock by more than SECONDS in the first
+//	                     LIMIT updates, or in any if LIMIT is -1
+//	  driftfile PATH     keep the drift in PATH, to start with it
+//
+//	Servers may be given as arguments too. time.google.com is used if no
+//	server is.
+//
+// Options:
+//
+//	-f: chrony.conf to read, instead of /etc/chrony.conf if it exists
+//	-q: set the clock once, stepping it whatever the offset, and exit
+//	-minpoll: log2 of the shortest poll interval in seconds (default 6)
+//	-maxpoll: log2 of the longest poll interval in seconds (default 10)
+//	-v: log every update
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/ntp"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultConfig = "/etc/chrony.conf"
+	fallback      = "time.google.com"
+)
+
+var (
+	configFile = flag.String("f", "", "chrony.conf to read, instead of "+defaultConfig+" if it exists")
+	once       = flag.Bool("q", false, "set the clock once, stepping it whatever the offset, and exit")
+	minPoll    = flag.Int("minpoll", 6, "log2 of the shortest poll interval in seconds")
+	maxPoll    = flag.Int("maxpoll", 10, "log2 of the longest poll interval in seconds")
+	verbose    = flag.Bool("v", false, "log every update")
+)
+
+// config is what chronyd uses of a chrony.conf.
+type config struct {
+	servers []string
+	pools   []string
+
+	// The clock is stepped by more than threshold in the first limit
+	// updates, or in any if limit is negative. threshold 0 never steps.
+	threshold time.Duration
+	limit     int
+
+	driftFile string
+}
+
+// parseConfig parses the chrony.conf in r. Unknown directives are ignored.
+func parseConfig(r io.Reader) (*config, error) {
+	c := &config{}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") || strings.HasPrefix(f[0], "!") {
+			continue
+		}
+		switch f[0] {
+		case "server", "pool":
+			if len(f) < 2 {
+				return nil, fmt.Errorf("line %d: %s needs a host", n, f[0])
+			}
+			if f[0] == "server" {
+				c.servers = append(c.servers, f[1])
+			} else {
+				c.pools = append(c.pools, f[1])
+			}
+		case "makestep":
+			if len(f) != 3 {
+				return nil, fmt.Errorf("line %d: makestep needs a threshold and a limit", n)
+			}
+			t, err := strconv.ParseFloat(f[1], 64)
+			if err != nil || t < 0 {
+				return nil, fmt.Errorf("line %d: invalid makestep threshold %q", n, f[1])
+			}
+			l, err := strconv.Atoi(f[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid makestep limit %q", n, f[2])
+			}
+			c.threshold, c.limit = time.Duration(t*float64(time.Second)), l
+		case "driftfile":
+			if len(f) != 2 {
+				return nil, fmt.Errorf("line %d: driftfile needs a path", n)
+			}
+			c.driftFile = f[1]
+		}
+	}
+	return c, s.Err()
+}
+
+// maxPoolSources is how many servers of a pool are used.
+const maxPoolSources = 4
+
+// measure queries the servers, and the first servers of the pools, and
+// returns the median of their offsets: how much the clock is behind.
+func measure(servers, pools []string, opt ntp.QueryOptions) (time.Duration, error) {
+	hosts := append([]string(nil), servers...)
+	for _, p := range pools {
+		addrs, err := net.LookupHost(p)
+		if err != nil {
+			log.Printf("Resolving pool %s: %v", p, err)
+			continue
+		}
+		if len(addrs) > maxPoolSources {
+			addrs = addrs[:maxPoolSources]
+		}
+		hosts = append(hosts, addrs...)
+	}
+
+	var offsets []time.Duration
+	for _, h := range hosts {
+		r, err := ntp.QueryWithOptions(h, opt)
+		if err == nil {
+			err = r.Validate()
+		}
+		if err != nil {
+			log.Printf("Querying %s: %v", h, err)
+			continue
+		}
+		if *verbose {
+			log.Printf("%s: offset %v, RTT %v, stratum %d", h, r.ClockOffset, r.RTT, r.Stratum)
+		}
+		offsets = append(offsets, r.ClockOffset)
+	}
+	if len(offsets) == 0 {
+		return 0, fmt.Errorf("no time from any of %v", hosts)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	m := len(offsets) / 2
+	if len(offsets)%2 == 0 {
+		return (offsets[m-1] + offsets[m]) / 2, nil
+	}
+	return offsets[m], nil
+}
+
+// readDrift returns the drift in ppm kept in the chrony driftfile path.
+func readDrift(path string) (float64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	f := strings.Fields(string(b))
+	if len(f) == 0 {
+		return 0, fmt.Errorf("%s: no drift", path)
+	}
+	return strconv.ParseFloat(f[0], 64)
+}
+
+// writeDrift keeps the drift and its skew, in ppm, in the chrony driftfile
+// path.
+func writeDrift(path string, freq, skew float64) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%20.6f %20.6f\n", freq, skew)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// pollInterval returns 2^n seconds.
+func pollInterval(n int) time.Duration {
+	return time.Duration(1<<uint(n)) * time.Second
+}
+
+func run(c *config, opt ntp.QueryOptions) error {
+	if *minPoll < 0 || *maxPoll < *minPoll || *maxPoll > 17 {
+		return fmt.Errorf("invalid -minpoll %d and -maxpoll %d", *minPoll, *maxPoll)
+	}
+	if *once {
+		offset, err := measure(c.servers, c.pools, opt)
+		if err != nil {
+			return err
+		}
+		if err := (kernelClock{}).Step(offset); err != nil {
+			return err
+		}
+		log.Printf("System clock stepped by %v", offset)
+		return nil
+	}
+
+	d := &discipline{clock: kernelClock{}, threshold: c.threshold, limit: c.limit}
+	if c.driftFile != "" {
+		if freq, err := readDrift(c.driftFile); err == nil {
+			if err := d.setDrift(freq); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Reading the drift: %v", err)
+		}
+	}
+	if !d.known {
+		freq, err := d.clock.Frequency()
+		if err != nil {
+			return err
+		}
+		d.applied = freq
+	}
+
+	start := time.Now()
+	poll := *minPoll
+	for {
+		offset, err := measure(c.servers, c.pools, opt)
+		if err != nil {
+			log.Print(err)
+			poll = *minPoll
+		} else {
+			drift := d.freq
+			stepped, err := d.update(time.Since(start), offset, pollInterval(poll))
+			switch {
+			case err != nil:
+				return err
+			case stepped:
+				log.Printf("System clock wrong by %v, stepped", offset)
+			case *verbose:
+				log.Printf("Offset %v, drift %.3f ppm, frequency %.3f ppm", offset, d.freq, d.applied)
+			}
+			if stepped || math.Abs(d.freq-drift) > 1 {
+				poll = *minPoll
+			} else if poll < *maxPoll {
+				poll++
+			}
+			if d.known && c.driftFile != "" {
+				if err := writeDrift(c.driftFile, d.freq, d.skew); err != nil {
+					log.Printf("Keeping the drift: %v", err)
+				}
+			}
+		}
+		time.Sleep(pollInterval(poll))
+	}
+}
+
+func main() {
+	log.SetPrefix("chronyd: ")
+	log.SetFlags(0)
+	flag.Parse()
+
+	c := &config{}
+	path := *configFile
+	if path == "" {
+		path = defaultConfig
+	}
+	f, err := os.Open(path)
+	switch {
+	case err == nil:
+		c, err = parseConfig(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+	case *configFile != "" || !errors.Is(err, os.ErrNotExist):
+		log.Fatal(err)
+	}
+	c.servers = append(flag.Args(), c.servers...)
+	if len(c.servers) == 0 && len(c.pools) == 0 {
+		c.servers = []string{fallback}
+	}
+	if err := run(c, ntp.QueryOptions{}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Modes of adjtimex(2).
+const (
+	adjFrequency = 0x0002
+	adjSetOffset = 0x0100
+)
+
+// kernelClock is the system clock.
+type kernelClock struct{}
+
+func (kernelClock) Step(offset time.Duration) error {
+	tx := unix.Timex{Modes: adjSetOffset, Time: unix.NsecToTimeval(offset.Nanoseconds())}
+	if _, err := unix.Adjtimex(&tx); err != nil {
+		return fmt.Errorf("stepping the clock: %v", err)
+	}
+	return nil
+}
+
+// setInt sets the field of unix.Timex at p, whose type depends on the
+// architecture, to v.
+func setInt[T int32 | int64](p *T, v int64) {
+	*p = T(v)
+}
+
+// The frequency of adjtimex(2) is in ppm with a 16 bit fraction.
+
+func (kernelClock) Frequency() (float64, error) {
+	var tx unix.Timex
+	if _, err := unix.Adjtimex(&tx); err != nil {
+		return 0, fmt.Errorf("reading the frequency of the clock: %v", err)
+	}
+	return float64(tx.Freq) / 65536, nil
+}
+
+func (kernelClock) SetFrequency(ppm float64) error {
+	tx := unix.Timex{Modes: adjFrequency}
+	setInt(&tx.Freq, int64(ppm*65536))
+	if _, err := unix.Adjtimex(&tx); err != nil {
+		return fmt.Errorf("setting the frequency of the clock: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+func TestParseConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		conf    string
+		want    *config
+		wantErr bool
+	}{
+		{
+			name: "chrony.conf",
+			conf: `# Use public servers.
+pool 2.pool.ntp.org iburst
+server ntp1.example.com iburst minpoll 4
+! server ntp2.example.com
+makestep 1.5 3
+driftfile /var/lib/chrony/drift
+rtcsync
+`,
+			want: &config{
+				servers:   []string{"ntp1.example.com"},
+				pools:     []string{"2.pool.ntp.org"},
+				threshold: 1500 * time.Millisecond,
+				limit:     3,
+				driftFile: "/var/lib/chrony/drift",
+			},
+		},
+		{name: "empty", conf: "\n", want: &config{}},
+		{name: "no host", conf: "server\n", wantErr: true},
+		{name: "invalid threshold", conf: "makestep -1 3\n", wantErr: true},
+		{name: "no limit", conf: "makestep 1\n", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConfig(strings.NewReader(tt.conf))
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseConfig = %+v, %v, want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// simClock is a clock that drifts by drift ppm.
+type simClock struct {
+	drift   float64
+	applied float64
+	// offset is how much the clock is behind.
+	offset time.Duration
+	steps  int
+}
+
+func (c *simClock) Step(offset time.Duration) error {
+	c.offset -= offset
+	c.steps++
+	return nil
+}
+
+func (c *simClock) Frequency() (float64, error) {
+	return c.applied, nil
+}
+
+func (c *simClock) SetFrequency(ppm float64) error {
+	c.applied = ppm
+	return nil
+}
+
+// run runs the clock for d.
+func (c *simClock) run(d time.Duration) {
+	c.offset += time.Duration((c.drift - c.applied) * 1e-6 * float64(d))
+}
+
+func TestDiscipline(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		drift     float64
+		offset    time.Duration
+		threshold time.Duration
+		limit     int
+		steps     int
+	}{
+		{name: "slow", drift: 23.5, offset: 30 * time.Millisecond, threshold: time.Second, limit: 3},
+		{name: "fast", drift: -61, offset: -5 * time.Millisecond},
+		{name: "stepped", drift: 10, offset: 42 * time.Second, threshold: time.Second, limit: 3, steps: 1},
+		{name: "not stepped after the limit", drift: 10, offset: 10 * time.Millisecond, threshold: time.Nanosecond, limit: 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &simClock{drift: tt.drift, offset: tt.offset}
+			d := &discipline{clock: c, threshold: tt.threshold, limit: tt.limit}
+			const poll = 64 * time.Second
+			var now time.Duration
+			for i := 0; i < 40; i++ {
+				if _, err := d.update(now, c.offset, poll); err != nil {
+					t.Fatal(err)
+				}
+				c.run(poll)
+				now += poll
+			}
+			if c.steps != tt.steps {
+				t.Errorf("clock stepped %d times, want %d", c.steps, tt.steps)
+			}
+			if math.Abs(d.freq-tt.drift) > 0.1 {
+				t.Errorf("drift estimated %.3f ppm, want %.3f ppm", d.freq, tt.drift)
+			}
+			if abs(c.offset) > 100*time.Microsecond {
+				t.Errorf("clock is off by %v, want less than 100µs", c.offset)
+			}
+		})
+	}
+}
+
+func TestDrift(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "drift")
+	if err := writeDrift(path, -12.25, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readDrift(path)
+	if err != nil || got != -12.25 {
+		t.Errorf("readDrift = %v, %v, want -12.25, nil", got, err)
+	}
+
+	c := &simClock{}
+	d := &discipline{clock: c}
+	if err := d.setDrift(got); err != nil || c.applied != got || !d.known {
+		t.Errorf("setDrift(%v) = %v, applying %v ppm", got, err, c.applied)
+	}
+}
+
+// serveNTP answers NTP queries on a local port, with a clock ahead by offset,
+// and returns the port.
+func serveNTP(t *testing.T, offset time.Duration) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	ntpTime := func(t time.Time) uint64 {
+		d := t.Sub(time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC))
+		sec := uint64(d / time.Second)
+		frac := uint64(d%time.Second) << 32 / uint64(time.Second)
+		return sec<<32 | frac
+	}
+	go func() {
+		b := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			now := time.Now().Add(offset)
+			r := make([]byte, 48)
+			r[0], r[1] = 4<<3|4, 1
+			copy(r[12:16], "GPS\x00")
+			binary.BigEndian.PutUint64(r[16:], ntpTime(now.Add(-time.Minute)))
+			copy(r[24:32], b[40:48])
+			binary.BigEndian.PutUint64(r[32:], ntpTime(now))
+			binary.BigEndian.PutUint64(r[40:], ntpTime(now))
+			conn.WriteTo(r, addr)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestMeasure(t *testing.T) {
+	const offset = 3 * time.Second
+	opt := ntp.QueryOptions{Port: serveNTP(t, offset), Timeout: time.Second}
+
+	got, err := measure([]string{"127.0.0.1", "127.0.0.1"}, nil, opt)
+	if err != nil {
+		t.Fatalf("measure = %v", err)
+	}
+	if abs(got-offset) > 50*time.Millisecond {
+		t.Errorf("measure = %v, want %v", got, offset)
+	}
+
+	if _, err := measure(nil, nil, opt); err == nil {
+		t.Errorf("measure with no servers = nil, want error")
+	}
+}
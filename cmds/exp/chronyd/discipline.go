@@ -0,0 +1,115 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// maxFrequency is the largest frequency correction, in ppm, of the kernel.
+const maxFrequency = 500
+
+// clock is a clock that can be disciplined.
+type clock interface {
+	// Step moves the clock forward by offset, or back if it is negative.
+	Step(offset time.Duration) error
+
+	// Frequency returns how much faster the clock is made to run, in ppm.
+	Frequency() (float64, error)
+
+	// SetFrequency makes the clock run faster by ppm, or slower if it is
+	// negative.
+	SetFrequency(ppm float64) error
+}
+
+// discipline corrects the offset and the drift of a clock with the offsets
+// measured by NTP.
+//
+// The offset o measured at a time is corrected by making the clock run faster
+// by o over the next poll interval. As the frequency applied, a, is known,
+// the drift, d, of the clock is estimated from how the offset changed in the
+// interval t: the offset of a clock that runs a-d ppm faster than it should
+// changes by (d-a)t.
+type discipline struct {
+	clock clock
+
+	// Offsets of more than threshold are stepped in the first limit
+	// updates, or in any if limit is negative. threshold 0 never steps.
+	threshold time.Duration
+	limit     int
+	updates   int
+
+	// freq is the estimated drift, and skew how much it varies, in ppm,
+	// if known.
+	freq  float64
+	skew  float64
+	known bool
+
+	// applied is the frequency correction applied to the clock, in ppm.
+	applied float64
+
+	// last is when the last offset, lastOffset, was measured.
+	last       time.Duration
+	lastOffset time.Duration
+	sampled    bool
+}
+
+// setDrift corrects a drift of freq ppm, e.g. kept from a previous run.
+func (d *discipline) setDrift(freq float64) error {
+	freq = clamp(freq)
+	if err := d.clock.SetFrequency(freq); err != nil {
+		return err
+	}
+	d.freq, d.known, d.applied = freq, true, freq
+	return nil
+}
+
+// update corrects offset, measured at now, e.g. on a monotonic clock, over
+// the poll interval, and returns whether the clock was stepped.
+func (d *discipline) update(now, offset, poll time.Duration) (bool, error) {
+	d.updates++
+	if d.threshold > 0 && abs(offset) > d.threshold && (d.limit < 0 || d.updates <= d.limit) {
+		if err := d.clock.Step(offset); err != nil {
+			return false, err
+		}
+		d.last, d.lastOffset, d.sampled = now, 0, true
+		return true, nil
+	}
+
+	if d.sampled && now > d.last {
+		drift := d.applied + (offset-d.lastOffset).Seconds()/(now-d.last).Seconds()*1e6
+		if d.known {
+			d.skew += (math.Abs(drift-d.freq) - d.skew) / 4
+			d.freq += (drift - d.freq) / 4
+		} else {
+			d.freq, d.known = drift, true
+		}
+		d.freq = clamp(d.freq)
+	}
+	d.last, d.lastOffset, d.sampled = now, offset, true
+
+	a := clamp(d.freq + offset.Seconds()/poll.Seconds()*1e6)
+	if err := d.clock.SetFrequency(a); err != nil {
+		return false, err
+	}
+	d.applied = a
+	return false, nil
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// clamp returns ppm limited to what the kernel can correct.
+func clamp(ppm float64) float64 {
+	return math.Max(-maxFrequency, math.Min(maxFrequency, ppm))
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// cmdline queries and edits kernel command lines.
+//
+// Synopsis:
+//
+//	cmdline [-f FILE] [list]
+//	cmdline [-f FILE] get KEY
+//	cmdline [-f FILE] getall KEY
+//	cmdline [-f FILE] has KEY...
+//	cmdline [-f FILE] module NAME
+//	cmdline [-f FILE] edit EDIT...
+//
+// Description:
+//
+//	cmdline parses the command line of the running kernel, or FILE, as
+//	the kernel does, with double quoted values and '-' and '_' equivalent
+//	in names, so that scripts need not grep it.
+//
+//	Without a command, it prints the command line. list prints a
+//	parameter a line. get prints the value of KEY, the last one if it is
+//	given several times, and getall all of them, a line each. has prints
+//	nothing. module prints the parameters of kernel module NAME, given as
+//	NAME.PARAM=VALUE, as insmod takes them.
+//
+//	edit prints the command line with the EDITs made, in order:
+//
+//	KEY=VALUE:  set KEY to VALUE, replacing all of its values
+//	KEY:        set KEY without a value
+//	+KEY=VALUE: add KEY with VALUE, keeping its other values
+//	-KEY:       remove KEY
+//
+//	The exit status is 1 if a KEY is not on the command line, and 2 on
+//	errors.
+//
+// Options:
+//
+//	-f: read the command line from FILE, or stdin for -, instead of
+//	    /proc/cmdline
+//
+// Example:
+//
+//	if cmdline has uroot.nohwrng; then ...
+//	console=$(cmdline get console)
+//	kexec -c "$(cmdline edit -quiet console=ttyS0,115200 +rd.break)" vmlinuz
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+var file = flag.String("f", "", "read the command line from `FILE`, or stdin for -, instead of /proc/cmdline")
+
+var errUsage = errors.New("usage: cmdline [-f FILE] [list | get KEY | getall KEY | has KEY... | module NAME | edit EDIT...]")
+
+// errAbsent is returned for keys the command line does not have.
+var errAbsent = errors.New("not on the command line")
+
+// read returns the command line in file, stdin for -, or of the running
+// kernel if file is empty.
+func read(in io.Reader, file string) (string, error) {
+	if file == "" {
+		c := cmdline.NewCmdLine()
+		return c.Raw, c.Err
+	}
+	var b []byte
+	var err error
+	if file == "-" {
+		b, err = io.ReadAll(in)
+	} else {
+		b, err = os.ReadFile(file)
+	}
+	return strings.TrimRight(string(b), "\n"), err
+}
+
+// edit makes an edit of the edit command.
+func edit(ps *cmdline.Params, e string) error {
+	switch {
+	case strings.HasPrefix(e, "-"):
+		ps.Delete(e[1:])
+	case strings.HasPrefix(e, "+"):
+		k, v, ok := strings.Cut(e[1:], "=")
+		if !ok {
+			return fmt.Errorf("%q: adding a key needs a value", e)
+		}
+		ps.Add(k, v)
+	default:
+		if k, v, ok := strings.Cut(e, "="); ok {
+			ps.Set(k, v)
+		} else {
+			ps.SetFlag(e)
+		}
+	}
+	return nil
+}
+
+func run(out io.Writer, ps cmdline.Params, args []string) error {
+	if len(args) == 0 {
+		_, err := fmt.Fprintln(out, ps)
+		return err
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "list":
+		if len(args) != 0 {
+			return errUsage
+		}
+		for _, p := range ps {
+			fmt.Fprintln(out, p)
+		}
+	case "get", "getall":
+		if len(args) != 1 {
+			return errUsage
+		}
+		v := ps.GetAll(args[0])
+		if len(v) == 0 {
+			return errAbsent
+		}
+		if cmd == "get" {
+			v = v[len(v)-1:]
+		}
+		for _, s := range v {
+			fmt.Fprintln(out, s)
+		}
+	case "has":
+		if len(args) == 0 {
+			return errUsage
+		}
+		for _, k := range args {
+			if !ps.Has(k) {
+				return errAbsent
+			}
+		}
+	case "module":
+		if len(args) != 1 {
+			return errUsage
+		}
+		fmt.Fprintln(out, ps.Module(args[0]))
+	case "edit":
+		for _, e := range args {
+			if err := edit(&ps, e); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(out, ps)
+	default:
+		return errUsage
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("cmdline: ")
+	log.SetFlags(0)
+	flag.Parse()
+	s, err := read(os.Stdin, *file)
+	if err == nil {
+		err = run(os.Stdout, cmdline.ParseParams(s), flag.Args())
+	}
+	if errors.Is(err, errAbsent) {
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func TestRun(t *testing.T) {
+	const line = `console=tty0 ro nvme.io-timeout=4 dyndbg="file foo.c +p" console=ttyS0,115200`
+	for _, tt := range []struct {
+		args []string
+		want string
+		err  error
+	}{
+		{args: nil, want: line + "\n"},
+		{args: []string{"list"}, want: "console=tty0\nro\nnvme.io-timeout=4\ndyndbg=\"file foo.c +p\"\nconsole=ttyS0,115200\n"},
+		{args: []string{"get", "console"}, want: "ttyS0,115200\n"},
+		{args: []string{"get", "dyndbg"}, want: "file foo.c +p\n"},
+		{args: []string{"get", "ro"}, want: "\n"},
+		{args: []string{"get", "root"}, err: errAbsent},
+		{args: []string{"getall", "console"}, want: "tty0\nttyS0,115200\n"},
+		{args: []string{"has", "ro", "nvme.io_timeout"}},
+		{args: []string{"has", "ro", "quiet"}, err: errAbsent},
+		{args: []string{"module", "nvme"}, want: "io_timeout=4\n"},
+		{args: []string{"module", "e1000"}, want: "\n"},
+		{
+			args: []string{"edit", "-console", "+console=hvc0", "root=/dev/sda1", "rw", "+dyndbg=module nvme +p"},
+			want: `ro nvme.io-timeout=4 dyndbg="file foo.c +p" console=hvc0 root=/dev/sda1 rw dyndbg="module nvme +p"` + "\n",
+		},
+		{args: []string{"edit", "+quiet"}, err: errors.New(`"+quiet": adding a key needs a value`)},
+		{args: []string{"get"}, err: errUsage},
+		{args: []string{"list", "x"}, err: errUsage},
+		{args: []string{"grep"}, err: errUsage},
+	} {
+		var out bytes.Buffer
+		err := run(&out, cmdline.ParseParams(line), tt.args)
+		if (err == nil) != (tt.err == nil) || err != nil && err.Error() != tt.err.Error() {
+			t.Errorf("cmdline %q = %v, want %v", tt.args, err, tt.err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("cmdline %q printed %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "cmdline")
+	if err := os.WriteFile(f, []byte("root=/dev/vda quiet\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := testutil.Command(t, "-f", f, "get", "root").Output(); err != nil || string(out) != "/dev/vda\n" {
+		t.Errorf("cmdline get root = %q, %v, want /dev/vda", out, err)
+	}
+
+	c := testutil.Command(t, "-f", "-", "edit", "-quiet")
+	c.Stdin = strings.NewReader("root=/dev/vda quiet\n")
+	if out, err := c.Output(); err != nil || string(out) != "root=/dev/vda\n" {
+		t.Errorf("cmdline edit -quiet = %q, %v", out, err)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{args: []string{"-f", f, "has", "rw"}, code: 1},
+		{args: []string{"-f", f, "bogus"}, code: 2},
+		{args: []string{"-f", filepath.Join(t.TempDir(), "none")}, code: 2},
+	} {
+		if err := testutil.IsExitCode(testutil.Command(t, tt.args...).Run(), tt.code); err != nil {
+			t.Errorf("cmdline %q: %v", tt.args, err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// column lays out lines in columns.
+//
+// Synopsis:
+//
+//	column [-t] [-s SEPARATORS] [-o SEPARATOR] [-c WIDTH] [-x] [FILE]...
+//
+// Description:
+//
+//	column reads the lines of each FILE, or stdin if there is none or a
+//	FILE is "-", and prints them in as many columns as fit in WIDTH,
+//	filling each column before the next one. Empty lines are skipped.
+//
+//	With -t, each line is split into fields, which are printed as a
+//	table whose columns are as wide as their widest field.
+//
+// Options:
+//
+//	-c: the width of the output (default $COLUMNS, or 80)
+//	-o: the separator between the columns of a table (default two spaces)
+//	-s: the characters that separate fields (default blanks)
+//	-t: print a table
+//	-x: fill rows before columns
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type options struct {
+	table  bool
+	seps   string
+	outSep string
+	width  int
+	across bool
+}
+
+// readLines returns the non-empty lines of files.
+func readLines(stdin io.Reader, files []string) ([]string, error) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var lines []string
+	for _, name := range files {
+		r := stdin
+		if name != "-" {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		s := bufio.NewScanner(r)
+		s.Buffer(nil, 1<<20)
+		for s.Scan() {
+			if l := s.Text(); strings.TrimSpace(l) != "" {
+				lines = append(lines, l)
+			}
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+func (o *options) fields(line string) []string {
+	if o.seps == "" {
+		return strings.Fields(line)
+	}
+	return strings.FieldsFunc(line, func(r rune) bool {
+		return strings.ContainsRune(o.seps, r)
+	})
+}
+
+func pad(w *bufio.Writer, s string, width int) {
+	w.WriteString(s)
+	w.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(s)))
+}
+
+func (o *options) printTable(w *bufio.Writer, lines []string) {
+	var rows [][]string
+	var widths []int
+	for _, l := range lines {
+		row := o.fields(l)
+		for i, f := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(f); n > widths[i] {
+				widths[i] = n
+			}
+		}
+		rows = append(rows, row)
+	}
+	for _, row := range rows {
+		for i, f := range row {
+			if i == len(row)-1 {
+				w.WriteString(f)
+				break
+			}
+			pad(w, f, widths[i])
+			w.WriteString(o.outSep)
+		}
+		w.WriteString("\n")
+	}
+}
+
+func (o *options) printColumns(w *bufio.Writer, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	// Columns are as wide as the longest line, rounded up to a tab stop.
+	max := 0
+	for _, l := range lines {
+		if n := utf8.RuneCountInString(l); n > max {
+			max = n
+		}
+	}
+	colWidth := (max + 8) &^ 7
+	cols := o.width / colWidth
+	if cols < 1 {
+		cols = 1
+	}
+	rows := (len(lines) + cols - 1) / cols
+	if !o.across {
+		cols = (len(lines) + rows - 1) / rows
+	}
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			i := c*rows + r
+			if o.across {
+				i = r*cols + c
+			}
+			if i >= len(lines) {
+				break
+			}
+			last := c == cols-1 || i+rows >= len(lines) && !o.across || i+1 >= len(lines) && o.across
+			if last {
+				w.WriteString(lines[i])
+				break
+			}
+			pad(w, lines[i], colWidth)
+		}
+		w.WriteString("\n")
+	}
+}
+
+func run(stdin io.Reader, stdout io.Writer, o *options, files []string) error {
+	lines, err := readLines(stdin, files)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(stdout)
+	if o.table {
+		o.printTable(w, lines)
+	} else {
+		o.printColumns(w, lines)
+	}
+	return w.Flush()
+}
+
+func main() {
+	o := &options{}
+	width := 80
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		width = n
+	}
+	flag.BoolVar(&o.table, "t", false, "print a table")
+	flag.StringVar(&o.seps, "s", "", "the characters that separate fields")
+	flag.StringVar(&o.outSep, "o", "  ", "the separator between the columns of a table")
+	flag.IntVar(&o.width, "c", width, "the width of the output")
+	flag.BoolVar(&o.across, "x", false, "fill rows before columns")
+	flag.Parse()
+	if o.width < 1 {
+		log.Fatalf("column: %v", fmt.Errorf("invalid width %d", o.width))
+	}
+	if err := run(os.Stdin, os.Stdout, o, flag.Args()); err != nil {
+		log.Fatalf("column: %v", err)
+	}
+}
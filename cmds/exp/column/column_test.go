@@ -0,0 +1,61 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestColumn(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		o    options
+		in   string
+		want string
+	}{
+		{
+			name: "table",
+			o:    options{table: true, outSep: "  "},
+			in:   "a bb ccc\ndddd e\n\nf\n",
+			want: "a     bb  ccc\ndddd  e\nf\n",
+		},
+		{
+			name: "table separators",
+			o:    options{table: true, seps: ":", outSep: "|"},
+			in:   "root:x:0\nnobody:x:65534\n",
+			want: "root  |x|0\nnobody|x|65534\n",
+		},
+		{
+			name: "columns",
+			o:    options{width: 24},
+			in:   "a\nb\nc\nd\ne\n",
+			want: "a       c       e\nb       d\n",
+		},
+		{
+			name: "across",
+			o:    options{width: 20, across: true},
+			in:   "a\nb\nc\nd\ne\n",
+			want: "a       b\nc       d\ne\n",
+		},
+		{
+			name: "narrow",
+			o:    options{width: 4},
+			in:   "abcdef\ng\n",
+			want: "abcdef\ng\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := run(strings.NewReader(tt.in), &b, &tt.o, nil); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("column = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
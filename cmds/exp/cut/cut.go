@@ -0,0 +1,246 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// cut prints selected parts of lines.
+//
+// Synopsis:
+//
+//	cut -b LIST | -c LIST | -f LIST [-d DELIM] [-s] [-complement] [-output-delimiter STRING] [FILE]...
+//
+// Description:
+//
+//	cut prints the selected bytes, characters or fields of each line of
+//	each FILE, or stdin if there is none or a FILE is "-". LIST is a comma
+//	separated list of numbers, counting from 1, and ranges N-M, N- and -M.
+//	Lines without the delimiter are printed whole with -f, unless -s is
+//	given.
+//
+// Options:
+//
+//	-b:                select these bytes
+//	-c:                select these characters
+//	-complement:       select what is not in LIST instead
+//	-d:                the field delimiter (default tab)
+//	-f:                select these fields
+//	-output-delimiter: what to print between fields (default the delimiter)
+//	-s:                skip lines without the delimiter
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// span is a range of items, counting from 1.
+type span struct {
+	lo, hi int
+}
+
+type list []span
+
+// parseList parses a LIST argument.
+func parseList(s string) (list, error) {
+	var l list
+	for _, r := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(r, "-")
+		sp := span{1, math.MaxInt}
+		var err error
+		if lo != "" {
+			if sp.lo, err = strconv.Atoi(lo); err != nil || sp.lo < 1 {
+				return nil, fmt.Errorf("invalid list %q", s)
+			}
+		}
+		switch {
+		case !isRange:
+			if lo == "" {
+				return nil, fmt.Errorf("invalid list %q", s)
+			}
+			sp.hi = sp.lo
+		case hi != "":
+			if sp.hi, err = strconv.Atoi(hi); err != nil || sp.hi < sp.lo {
+				return nil, fmt.Errorf("invalid list %q", s)
+			}
+		case lo == "":
+			return nil, fmt.Errorf("invalid list %q", s)
+		}
+		l = append(l, sp)
+	}
+	return l, nil
+}
+
+// has tells whether the list selects item i, counting from 1.
+func (l list) has(i int) bool {
+	for _, sp := range l {
+		if i >= sp.lo && i <= sp.hi {
+			return true
+		}
+	}
+	return false
+}
+
+type mode int
+
+const (
+	bytesMode mode = iota
+	charsMode
+	fieldsMode
+)
+
+type cutter struct {
+	mode       mode
+	list       list
+	complement bool
+	delim      string
+	outDelim   string
+	onlyDelim  bool
+}
+
+func (c *cutter) selected(i int) bool {
+	return c.list.has(i) != c.complement
+}
+
+func (c *cutter) cut(line string) (string, bool) {
+	var b strings.Builder
+	switch c.mode {
+	case bytesMode:
+		for i := 0; i < len(line); i++ {
+			if c.selected(i + 1) {
+				b.WriteByte(line[i])
+			}
+		}
+	case charsMode:
+		i := 0
+		for _, r := range line {
+			if i++; c.selected(i) {
+				b.WriteRune(r)
+			}
+		}
+	case fieldsMode:
+		if !strings.Contains(line, c.delim) {
+			return line, !c.onlyDelim
+		}
+		first := true
+		for i, f := range strings.Split(line, c.delim) {
+			if !c.selected(i + 1) {
+				continue
+			}
+			if !first {
+				b.WriteString(c.outDelim)
+			}
+			first = false
+			b.WriteString(f)
+		}
+	}
+	return b.String(), true
+}
+
+func (c *cutter) run(w *bufio.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			if s, ok := c.cut(strings.TrimSuffix(line, "\n")); ok {
+				w.WriteString(s)
+				w.WriteString("\n")
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func run(stdin io.Reader, stdout io.Writer, c *cutter, files []string) error {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	w := bufio.NewWriter(stdout)
+	var failed error
+	for _, name := range files {
+		if name == "-" {
+			if err := c.run(w, stdin); err != nil {
+				return err
+			}
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			log.Printf("cut: %v", err)
+			failed = errors.New("some files could not be read")
+			continue
+		}
+		err = c.run(w, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return failed
+}
+
+// parse builds a cutter from the flags.
+func parse(b, ch, f, d string, s, complement bool, outDelim *string) (*cutter, error) {
+	c := &cutter{delim: d, onlyDelim: s, complement: complement}
+	var l string
+	n := 0
+	for m, v := range map[mode]string{bytesMode: b, charsMode: ch, fieldsMode: f} {
+		if v != "" {
+			c.mode, l = m, v
+			n++
+		}
+	}
+	if n != 1 {
+		return nil, errors.New("exactly one of -b, -c and -f is needed")
+	}
+	if c.mode != fieldsMode && s {
+		return nil, errors.New("-s only works with -f")
+	}
+	if utf8.RuneCountInString(d) != 1 {
+		return nil, errors.New("the delimiter must be one character")
+	}
+	c.outDelim = d
+	if outDelim != nil {
+		c.outDelim = *outDelim
+	}
+	var err error
+	c.list, err = parseList(l)
+	return c, err
+}
+
+func main() {
+	b := flag.String("b", "", "select these bytes")
+	ch := flag.String("c", "", "select these characters")
+	f := flag.String("f", "", "select these fields")
+	d := flag.String("d", "\t", "the field delimiter")
+	s := flag.Bool("s", false, "skip lines without the delimiter")
+	complement := flag.Bool("complement", false, "select what is not in LIST instead")
+	var outDelim *string
+	flag.Func("output-delimiter", "what to print between fields (default the delimiter)", func(v string) error {
+		outDelim = &v
+		return nil
+	})
+	flag.Parse()
+	c, err := parse(*b, *ch, *f, *d, *s, *complement, outDelim)
+	if err != nil {
+		log.Fatalf("cut: %v", err)
+	}
+	if err := run(os.Stdin, os.Stdout, c, flag.Args()); err != nil {
+		log.Fatalf("cut: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCut(t *testing.T) {
+	comma := ","
+	for _, tt := range []struct {
+		name       string
+		b, c, f, d string
+		s, compl   bool
+		outDelim   *string
+		in         string
+		want       string
+	}{
+		{name: "bytes", b: "1,3-4", d: "\t", in: "abcdef\nxy\n", want: "acd\nx\n"},
+		{name: "chars", c: "2-", d: "\t", in: "héllo", want: "éllo\n"},
+		{name: "to", c: "-2", d: "\t", in: "héllo\n", want: "hé\n"},
+		{name: "fields", f: "1,3", d: ":", in: "a:b:c:d\nnone\n", want: "a:c\nnone\n"},
+		{name: "only delimited", f: "2", d: ":", s: true, in: "a:b\nnone\n", want: "b\n"},
+		{name: "complement", f: "2", d: ":", compl: true, in: "a:b:c\n", want: "a:c\n"},
+		{name: "output delimiter", f: "1-", d: ":", outDelim: &comma, in: "a:b:c\n", want: "a,b,c\n"},
+		{name: "empty fields", f: "2-3", d: ",", in: "a,,c\n", want: ",c\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parse(tt.b, tt.c, tt.f, tt.d, tt.s, tt.compl, tt.outDelim)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := run(strings.NewReader(tt.in), &b, c, nil); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("cut = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		b, c, f, d string
+		s          bool
+	}{
+		{name: "no list", d: "\t"},
+		{name: "two lists", b: "1", c: "1", d: "\t"},
+		{name: "zero", f: "0", d: "\t"},
+		{name: "backwards", f: "3-2", d: "\t"},
+		{name: "dash", f: "-", d: "\t"},
+		{name: "delimiter", f: "1", d: "ab"},
+		{name: "s without f", b: "1", d: "\t", s: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parse(tt.b, tt.c, tt.f, tt.d, tt.s, false, nil); err == nil {
+				t.Errorf("parse succeeded")
+			}
+		})
+	}
+}
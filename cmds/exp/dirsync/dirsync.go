@@ -0,0 +1,334 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// dirsync keeps a directory in sync with a signed manifest of files.
+//
+// Synopsis:
+//
+//	dirsync [-k KEYRING | -insecure] [-j N] [-delete] [-n] [-q] MANIFEST-URL DIR
+//
+// Description:
+//
+//	dirsync fetches the manifest at MANIFEST-URL, verifies its detached
+//	OpenPGP signature at MANIFEST-URL.sig with the keys in KEYRING, and
+//	makes DIR hold the files it lists: those missing from DIR, or whose
+//	SHA-256 differs, are fetched from their path relative to MANIFEST-URL,
+//	N at a time, e.g. to keep a local cache of boot assets current
+//	without downloading what did not change.
+//
+//	The manifest has the format of sha256sum: a line per file with its
+//	SHA-256 in hex, two spaces, or a space and *, and its path, relative
+//	and slash separated. Empty lines and lines starting with # are
+//	skipped.
+//
+//	Files are verified as they are fetched, next to where they go, and
+//	only renamed there if their SHA-256 matches, so that DIR never holds a
+//	file that is not in the manifest as listed. With -delete, files in DIR
+//	that the manifest does not list are removed.
+//
+//	URLs may be of any scheme pkg/curl supports.
+//
+// Options:
+//
+//	-k:        file of the OpenPGP keys the manifest must be signed by
+//	-insecure: do not verify the signature of the manifest
+//	-j:        number of files to fetch at a time (default 4)
+//	-delete:   remove files the manifest does not list
+//	-n:        only print what would be done
+//	-q:        only print errors
+//
+// Example:
+//
+//	dirsync -k /etc/boot-keys.asc -delete https://assets.example.com/boot/MANIFEST /var/cache/boot
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/u-root/u-root/pkg/curl"
+	"github.com/u-root/u-root/pkg/vfile"
+	"golang.org/x/crypto/openpgp"
+)
+
+var (
+	keyRing  = flag.String("k", "", "file of the OpenPGP keys the manifest must be signed by")
+	insecure = flag.Bool("insecure", false, "do not verify the signature of the manifest")
+	jobs     = flag.Int("j", 4, "number of files to fetch at a time")
+	remove   = flag.Bool("delete", false, "remove files the manifest does not list")
+	dryRun   = flag.Bool("n", false, "only print what would be done")
+	quiet    = flag.Bool("q", false, "only print errors")
+)
+
+var errUsage = errors.New("usage: dirsync [-k KEYRING | -insecure] [-j N] [-delete] [-n] [-q] MANIFEST-URL DIR")
+
+// entry is a file listed in a manifest.
+type entry struct {
+	path string
+	sum  []byte
+}
+
+// parseManifest parses a manifest in the format of sha256sum.
+func parseManifest(r io.Reader) ([]entry, error) {
+	var es []entry
+	seen := map[string]bool{}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		l := s.Text()
+		if strings.TrimSpace(l) == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		sum, p, ok := strings.Cut(l, " ")
+		if !ok || p == "" || (p[0] != ' ' && p[0] != '*') {
+			return nil, fmt.Errorf("manifest line %d: want SHA256  PATH, got %q", n, l)
+		}
+		p = p[1:]
+		b, err := hex.DecodeString(sum)
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("manifest line %d: invalid SHA-256 %q", n, sum)
+		}
+		// Paths must stay in the directory.
+		if !fs.ValidPath(p) || p == "." {
+			return nil, fmt.Errorf("manifest line %d: invalid path %q", n, p)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("manifest line %d: %s is listed twice", n, p)
+		}
+		seen[p] = true
+		es = append(es, entry{path: p, sum: b})
+	}
+	return es, s.Err()
+}
+
+// fileSum returns the SHA-256 of the file at p.
+func fileSum(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// syncer syncs a directory with a manifest.
+type syncer struct {
+	schemes curl.Schemes
+	// keyRing verifies the manifest, unless it is nil.
+	keyRing openpgp.KeyRing
+	dir     string
+	jobs    int
+	remove  bool
+	dryRun  bool
+	out     io.Writer
+}
+
+func (s *syncer) printf(format string, args ...interface{}) {
+	if s.out != nil {
+		fmt.Fprintf(s.out, format, args...)
+	}
+}
+
+// manifest fetches and verifies the manifest at u.
+func (s *syncer) manifest(ctx context.Context, u *url.URL) ([]entry, error) {
+	if s.keyRing == nil {
+		f, err := s.schemes.FetchWithoutCache(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := f.(io.Closer); ok {
+			defer c.Close()
+		}
+		return parseManifest(f)
+	}
+	f, err := s.schemes.FetchVerified(ctx, u, curl.VerifyOpts{KeyRing: s.keyRing})
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(io.NewSectionReader(f, 0, math.MaxInt64))
+}
+
+// fetch fetches e from base into the directory.
+func (s *syncer) fetch(ctx context.Context, base *url.URL, e entry) error {
+	u := base.ResolveReference(&url.URL{Path: e.path})
+	dst := filepath.Join(s.dir, filepath.FromSlash(e.path))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	v, err := s.schemes.FetchAndVerify(ctx, u, curl.VerifyOpts{SHA256: e.sum, TempDir: filepath.Dir(dst)})
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(v.Name(), 0o644); err != nil {
+		v.Close()
+		return err
+	}
+	return v.Keep(dst)
+}
+
+// sync makes the directory hold the files of the manifest at u.
+func (s *syncer) sync(ctx context.Context, u *url.URL) error {
+	es, err := s.manifest(ctx, u)
+	if err != nil {
+		return err
+	}
+
+	var stale []entry
+	for _, e := range es {
+		sum, err := fileSum(filepath.Join(s.dir, filepath.FromSlash(e.path)))
+		if err == nil && bytes.Equal(sum, e.sum) {
+			continue
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		stale = append(stale, e)
+	}
+
+	var (
+		mu     sync.Mutex
+		failed int
+		wg     sync.WaitGroup
+		work   = make(chan entry)
+	)
+	n := s.jobs
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range work {
+				if s.dryRun {
+					s.printf("would fetch %s\n", e.path)
+					continue
+				}
+				if err := s.fetch(ctx, u, e); err != nil {
+					log.Printf("%s: %v", e.path, err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+					continue
+				}
+				s.printf("fetched %s\n", e.path)
+			}
+		}()
+	}
+	for _, e := range stale {
+		work <- e
+	}
+	close(work)
+	wg.Wait()
+
+	removed := 0
+	if s.remove {
+		if removed, err = s.removeUnlisted(es); err != nil {
+			return err
+		}
+	}
+	if !s.dryRun {
+		s.printf("%d files up to date, %d fetched, %d removed\n", len(es)-len(stale), len(stale)-failed, removed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to fetch", failed, len(stale))
+	}
+	return nil
+}
+
+// removeUnlisted removes the files in the directory that are not in es, and
+// returns how many.
+func (s *syncer) removeUnlisted(es []entry) (int, error) {
+	listed := map[string]bool{}
+	for _, e := range es {
+		listed[e.path] = true
+	}
+	var unlisted []string
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		if !listed[filepath.ToSlash(rel)] {
+			unlisted = append(unlisted, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(unlisted)
+	for _, p := range unlisted {
+		rel, _ := filepath.Rel(s.dir, p)
+		if s.dryRun {
+			s.printf("would remove %s\n", filepath.ToSlash(rel))
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return 0, err
+		}
+		s.printf("removed %s\n", filepath.ToSlash(rel))
+	}
+	return len(unlisted), nil
+}
+
+func run(out io.Writer, args []string) error {
+	if len(args) != 2 || (*keyRing == "") == !*insecure || *jobs < 1 {
+		return errUsage
+	}
+	u, err := url.Parse(args[0])
+	if err != nil {
+		return err
+	}
+	s := &syncer{
+		schemes: curl.DefaultSchemes.WithRetries(curl.DefaultRetryPolicy),
+		dir:     args[1],
+		jobs:    *jobs,
+		remove:  *remove,
+		dryRun:  *dryRun,
+	}
+	if !*quiet {
+		s.out = out
+	}
+	if !*insecure {
+		if s.keyRing, err = vfile.GetKeyRing(*keyRing); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	return s.sync(context.Background(), u)
+}
+
+func main() {
+	log.SetPrefix("dirsync: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdout, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
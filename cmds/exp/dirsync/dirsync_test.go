@@ -0,0 +1,170 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/curl"
+	"github.com/u-root/u-root/pkg/testutil"
+	"github.com/u-root/u-root/pkg/vfile"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func sum(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+func TestParseManifest(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		manifest string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "good",
+			manifest: "# boot assets\n\n" + sum("a") + "  vmlinuz\n" + sum("b") + " *initramfs/base.cpio\n",
+			want:     []string{"vmlinuz", "initramfs/base.cpio"},
+		},
+		{name: "one space", manifest: sum("a") + " vmlinuz\n", wantErr: true},
+		{name: "short sum", manifest: "abcd  vmlinuz\n", wantErr: true},
+		{name: "absolute", manifest: sum("a") + "  /etc/passwd\n", wantErr: true},
+		{name: "dot dot", manifest: sum("a") + "  boot/../../etc/passwd\n", wantErr: true},
+		{name: "twice", manifest: sum("a") + "  vmlinuz\n" + sum("b") + "  vmlinuz\n", wantErr: true},
+	} {
+		es, err := parseManifest(strings.NewReader(tt.manifest))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseManifest = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		var got []string
+		for _, e := range es {
+			got = append(got, e.path)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: parseManifest = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	conf := &packet.Config{RSABits: 1024}
+	key, err := openpgp.NewEntity("boot", "", "boot@example.com", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := sum("new kernel") + "  vmlinuz\n" +
+		sum("initramfs") + "  initramfs/base.cpio\n" +
+		sum("config") + "  boot.cfg\n"
+	var sig bytes.Buffer
+	if err := vfile.DetachSign(&sig, []*openpgp.Entity{key}, []byte(manifest), false, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	m := curl.NewMockScheme("http")
+	m.Add("assets", "/boot/MANIFEST", manifest)
+	m.Add("assets", "/boot/MANIFEST.sig", sig.String())
+	m.Add("assets", "/boot/vmlinuz", "new kernel")
+	m.Add("assets", "/boot/initramfs/base.cpio", "initramfs")
+	// The server has the wrong file, which must not be kept.
+	m.Add("assets", "/boot/boot.cfg", "tampered config")
+	u := &url.URL{Scheme: "http", Host: "assets", Path: "/boot/MANIFEST"}
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"vmlinuz":             "old kernel",
+		"initramfs/base.cpio": "initramfs",
+		"stale":               "stale",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	s := &syncer{
+		schemes: curl.Schemes{"http": m},
+		keyRing: openpgp.EntityList{key},
+		dir:     dir,
+		jobs:    1,
+		dryRun:  true,
+		remove:  true,
+		out:     &out,
+	}
+	if err := s.sync(context.Background(), u); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if want := "would fetch vmlinuz\nwould fetch boot.cfg\nwould remove stale\n"; out.String() != want {
+		t.Errorf("dry run printed %q, want %q", out.String(), want)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "vmlinuz")); string(b) != "old kernel" {
+		t.Errorf("dry run changed vmlinuz to %q", b)
+	}
+
+	out.Reset()
+	s.dryRun = false
+	if err := s.sync(context.Background(), u); err == nil {
+		t.Errorf("sync with a tampered file succeeded")
+	}
+	if want := "fetched vmlinuz\nremoved stale\n1 files up to date, 1 fetched, 1 removed\n"; out.String() != want {
+		t.Errorf("sync printed %q, want %q", out.String(), want)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "vmlinuz")); string(b) != "new kernel" {
+		t.Errorf("vmlinuz is %q, want %q", b, "new kernel")
+	}
+	for _, name := range []string{"boot.cfg", "stale"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s: got %v, want it not to exist", name, err)
+		}
+	}
+	// Files that did not change are not fetched.
+	if n := m.NumCalled(u.ResolveReference(&url.URL{Path: "initramfs/base.cpio"})); n != 0 {
+		t.Errorf("initramfs/base.cpio was fetched %d times, want 0", n)
+	}
+	// Nor is anything left behind from the file that failed.
+	if ents, err := os.ReadDir(dir); err != nil || len(ents) != 2 {
+		t.Errorf("ReadDir = %v, %v, want vmlinuz and initramfs", ents, err)
+	}
+
+	other, err := openpgp.NewEntity("other", "", "other@example.com", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.keyRing = openpgp.EntityList{other}
+	if err := s.sync(context.Background(), u); err == nil {
+		t.Errorf("sync with a manifest signed by another key succeeded")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"http://assets/MANIFEST", "dir"},
+		{"-insecure", "-k", "keys", "http://assets/MANIFEST", "dir"},
+		{"-insecure", "http://assets/MANIFEST"},
+		{"-insecure", "-j", "0", "http://assets/MANIFEST", "dir"},
+	} {
+		if err := testutil.IsExitCode(testutil.Command(t, args...).Run(), 1); err != nil {
+			t.Errorf("dirsync %q: %v", args, err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// domainname prints or changes the system's NIS domain name.
+//
+// Synopsis:
+//
+//	domainname [DOMAINNAME]
+//
+// Description:
+//
+//	Without DOMAINNAME, domainname prints the NIS domain name of the
+//	kernel, which is (none) until it is set. With DOMAINNAME, it sets it.
+//
+//	The NIS domain name is not the DNS domain of the host, which
+//	hostname -d prints.
+//
+// Example:
+//
+//	$ domainname lab
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func main() {
+	log.SetPrefix("domainname: ")
+	log.SetFlags(0)
+	switch len(os.Args) {
+	case 1:
+		var u unix.Utsname
+		if err := unix.Uname(&u); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(unix.ByteSliceToString(u.Domainname[:]))
+	case 2:
+		if err := unix.Setdomainname([]byte(os.Args[1])); err != nil {
+			log.Fatalf("could not set domain name: %v", err)
+		}
+	default:
+		log.Fatal("usage: domainname [DOMAINNAME]")
+	}
+}
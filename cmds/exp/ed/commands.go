@@ -65,6 +65,12 @@ var cmds = map[byte]Command{
 	'#': func(*Context) (e error) { return },
 }
 
+// The global commands run other commands, so they can't be in the initializer
+func init() {
+	cmds['g'] = cmdGlobal
+	cmds['v'] = cmdGlobal
+}
+
 //////////////////////
 // Command handlers /
 ////////////////////
@@ -591,14 +597,11 @@ func cmdSub(ctx *Context) (e error) {
 			oLin = m[1]
 		}
 		fLin += l[oLin:]
-		if e = buffer.Delete([2]int{ln, ln}); e != nil {
-			return
-		}
-		if e = buffer.Insert(ln, []string{fLin}); e != nil {
+		if e = buffer.Replace(r[0]+ln, fLin); e != nil {
 			return
 		}
 		last = fLin
-		lastN = ln
+		lastN = r[0] + ln
 	}
 	if nMatch == 0 {
 		e = fmt.Errorf("no match")
@@ -616,6 +619,67 @@ func cmdSub(ctx *Context) (e error) {
 	return
 }
 
+// cmdGlobal runs a command on every line that matches (g) or doesn't match (v) a regexp.
+// The command defaults to p, and all changes are undone together.
+func cmdGlobal(ctx *Context) (e error) {
+	cmd := ctx.cmd[ctx.cmdOffset+1:]
+	if len(cmd) == 0 {
+		return fmt.Errorf("no regular expression")
+	}
+	del := cmd[0]
+	if del == ' ' || del == '\\' {
+		return fmt.Errorf("invalid pattern delimiter")
+	}
+	// find the closing delimiter, skipping escaped characters
+	end := len(cmd)
+	for i := 1; i < len(cmd); i++ {
+		if cmd[i] == '\\' {
+			i++
+			continue
+		}
+		if cmd[i] == del {
+			end = i
+			break
+		}
+	}
+	mat := cmd[1:end]
+	gcmd := "p"
+	if end < len(cmd)-1 {
+		gcmd = cmd[end+1:]
+	}
+	if c := gcmd[wsOffset(gcmd):]; len(c) > 0 && (c[0] == 'g' || c[0] == 'v') {
+		return fmt.Errorf("cannot nest global commands")
+	}
+
+	var r [2]int
+	if ctx.cmdOffset == 0 {
+		r = [2]int{0, buffer.Len() - 1}
+	} else if r, e = buffer.AddrRangeOrLine(ctx.addrs); e != nil {
+		return
+	}
+	var rx *regexp.Regexp
+	if rx, e = regexp.Compile(mat); e != nil {
+		return
+	}
+	var bls []int
+	if bls, e = buffer.Select(r, rx, ctx.cmd[ctx.cmdOffset] == 'v'); e != nil {
+		return
+	}
+	for _, bl := range bls {
+		l, ok := buffer.Find(bl)
+		if !ok {
+			continue // deleted by a previous command
+		}
+		if e = buffer.SetAddr(l); e != nil {
+			return
+		}
+		if e = run(gcmd, ctx.out); e != nil {
+			return
+		}
+	}
+	return
+}
+
 func cmdUndo(ctx *Context) (e error) {
 	buffer.Rewind()
 	return
@@ -13,10 +13,13 @@
 //
 // The following has been implemented:
 // - Full line address parsing (including RE and markings)
-// - Implmented commands: !, #, =, E, H, P, Q, W, a, c, d, e, f, h, i, j, k, l, m, n, p, q, r, s, t, u, w, x, y, z
+// - Implmented commands: !, #, =, E, H, P, Q, W, a, c, d, e, f, g, h, i, j, k, l, m, n, p, q, r, s, t, u, v, w, x, y, z
+// - Multi-level undo: unlike GNU Ed, `u` does not undo itself, but goes back one more command each time (up to 100)
+// - `g` and `v` only take a single command, which may not read input
+// - Large files and long lines (up to 16MiB)
 //
 // The following has *not* yet been implemented, but will be eventually:
-// - Unimplemented commands: g, G, v, V
+// - Unimplemented commands: G, V
 // - does not (yet) support "loose" mode
 // - does not (yet) support "restricted" mod
 package main
@@ -61,7 +64,18 @@ var state struct {
 }
 
 // Parse input and execute command
+// Each command is a transaction, which can be undone.
 func execute(cmd string, output io.Writer) (e error) {
+	buffer.Start()
+	if e = run(cmd, output); e != nil {
+		return
+	}
+	buffer.End()
+	return
+}
+
+// Parse input and run command, as part of the current transaction
+func run(cmd string, output io.Writer) (e error) {
 	ctx := &Context{
 		cmd: cmd,
 		out: output,
@@ -74,11 +88,7 @@ func execute(cmd string, output io.Writer) (e error) {
 		ctx.cmd += "p"
 	}
 	if exe, ok := cmds[ctx.cmd[ctx.cmdOffset]]; ok {
-		buffer.Start()
-		if e = exe(ctx); e != nil {
-			return
-		}
-		buffer.End()
+		e = exe(ctx)
 	} else {
 		return fmt.Errorf("invalid command: %v", cmd[ctx.cmdOffset])
 	}
@@ -148,13 +148,48 @@ func TestEdCommandsNonInput(t *testing.T) {
 		{
 			name:    "CmdSub_You_We_in_line2_3_n",
 			cmd:     "2 s/(We)/You/n\nu\nq\n",
-			wantOut: "1\tYou learn something new every day.\nexit\n",
+			wantOut: "2\tYou learn something new every day.\nexit\n",
 		},
 		{
 			name:    "CmdSub_You_We_in_line2_3_g",
 			cmd:     "2 s/(We)/You/g\np\nu\nq\n",
 			wantOut: "You learn something new every day.\nexit\n",
 		},
+		{
+			name:    "CmdSub_range",
+			cmd:     "1,2 s/e/E/g\n1,2 p\nq\nQ\n",
+			wantOut: "To bE fair, this is just random wEirdo stuff going on.\nWE lEarn somEthing nEw EvEry day.\nwarning: file modified\nexit\n",
+		},
+		{
+			name:    "CmdUndo_multiple",
+			cmd:     "1 d\n1 d\nu\nu\n1,$ p\nq\n",
+			wantOut: "To be fair, this is just random weirdo stuff going on.\nWe learn something new every day.\nexit\n",
+		},
+		{
+			name:    "CmdGlobal",
+			cmd:     "g/fair/n\nq\n",
+			wantOut: "1\tTo be fair, this is just random weirdo stuff going on.\nexit\n",
+		},
+		{
+			name:    "CmdGlobal_default_print",
+			cmd:     "v/fair/\nq\n",
+			wantOut: "We learn something new every day.\nexit\n",
+		},
+		{
+			name:    "CmdGlobal_sub_undo",
+			cmd:     "g/e/s/e/E/\n1,$ p\nu\n1,$ p\nq\n",
+			wantOut: "To bE fair, this is just random weirdo stuff going on.\nWE learn something new every day.\nTo be fair, this is just random weirdo stuff going on.\nWe learn something new every day.\nexit\n",
+		},
+		{
+			name:    "CmdGlobal_delete",
+			cmd:     "v/fair/d\n1,$ p\nq\nQ\n",
+			wantOut: "To be fair, this is just random weirdo stuff going on.\nwarning: file modified\nexit\n",
+		},
+		{
+			name:    "CmdGlobal_nested",
+			cmd:     "g/e/g/e/p\nq\n",
+			wantOut: "cannot nest global commands\nexit\n",
+		},
 		{
 			name:    "CmdSub_invalidAddr",
 			cmd:     "4 s/(We)/You/n\nu\nq\n",
@@ -163,7 +198,7 @@ func TestEdCommandsNonInput(t *testing.T) {
 		{
 			name:    "CmdQuit_Buffer_dirty",
 			cmd:     "2 s/(We)/You/n\nq\nu\nq",
-			wantOut: "1\tYou learn something new every day.\nwarning: file modified\nexit\n",
+			wantOut: "2\tYou learn something new every day.\nwarning: file modified\nexit\n",
 		},
 		{
 			name:    "CmdEdit_undo",
@@ -188,7 +223,7 @@ func TestEdCommandsNonInput(t *testing.T) {
 		{
 			name:    "CmdDump",
 			cmd:     "D\nq\n",
-			wantOut: "&{[] [To be fair, this is just random weirdo stuff going on. We learn something new every day.] [0 1] [] [0 1] false false false false 1 0 1 map[]}\nexit\n",
+			wantOut: "&{[] [To be fair, this is just random weirdo stuff going on. We learn something new every day.] [0 1] [] {[0 1] 1 false} true map[] false false 1 map[]}\nexit\n",
 		},
 	} {
 		t.Run("Command:"+tt.cmd, func(t *testing.T) {
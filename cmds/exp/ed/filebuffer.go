@@ -10,26 +10,42 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 )
 
+// maxUndo is the number of commands that can be undone
+const maxUndo = 100
+
+// maxLineLen is the longest line we can read
+const maxLineLen = 16 << 20
+
+// A snapshot is the state of the file before a command, used for undo
+type snapshot struct {
+	file  []int
+	addr  int
+	dirty bool
+}
+
 // A FileBuffer manages a file being edited.
 // A FileBuffer never deletes/modifies anything directly until it is replaced.
 // It keeps a map of known lines to the current buffer.
 // Note: FileBuffer is 0-addressed lines, so off-by-one from what `ed` expects.
+//
+// Snapshots for undo share the file slice; it is only copied the first time
+// a command modifies it, so commands that don't modify anything are cheap,
+// even for large files.
 type FileBuffer struct {
-	cbuf      []string // cut buffer
-	buffer    []string // all lines we know about, they never get delited
-	file      []int    // sequence of buffer lines
-	lastFile  []int    // used for undo capability
-	tmpFile   []int    // used for undo capability
-	dirty     bool     // tracks if the file has been modifed
-	lastDirty bool     // used for undo capability
-	tmpDirty  bool     // used for undo capability
-	mod       bool     // mod is like dirty, but can be reset for transactions
-	addr      int      // current file address
-	lastAddr  int      // last address (for undo)
-	tmpAddr   int      // last address (for undo)
-	marks     map[byte]int
+	cbuf   []string    // cut buffer
+	buffer []string    // all lines we know about, they never get delited
+	file   []int       // sequence of buffer lines
+	undo   []snapshot  // used for undo capability, most recent last
+	tmp    snapshot    // used for undo capability
+	shared bool        // file is shared with a snapshot, copy before modifying
+	lines  map[int]int // file line of each buffer line, built on demand
+	dirty  bool        // tracks if the file has been modifed
+	mod    bool        // mod is like dirty, but can be reset for transactions
+	addr   int         // current file address
+	marks  map[byte]int
 }
 
 // NewFileBuffer creats a new FileBuffer object
@@ -101,23 +117,23 @@ func (f *FileBuffer) Paste(line int) (e error) {
 	return
 }
 
+// own makes sure we can modify file without changing a snapshot
+func (f *FileBuffer) own() {
+	if f.shared {
+		f.file = append([]int(nil), f.file...)
+		f.shared = false
+	}
+}
+
 // Delete unmaps lines from the file
 func (f *FileBuffer) Delete(r [2]int) (e error) {
-	blines := []int{}
-	for l := r[0]; l <= r[1]; l++ {
-		if f.OOB(l) {
-			return ErrOOB
-		}
-		blines = append(blines, f.file[l])
+	if r[0] <= r[1] && (f.OOB(r[0]) || f.OOB(r[1])) {
+		return ErrOOB
 	}
 	f.cbuf, _ = f.Get(r) // this shouldn't fail here, if it does we've got a bigger problem
-	for _, b := range blines {
-		for i, l := range f.file {
-			if l == b {
-				f.file = append(f.file[:i], f.file[i+1:]...)
-				break
-			}
-		}
+	if r[0] <= r[1] {
+		f.own()
+		f.file = append(f.file[:r[0]], f.file[r[1]+1:]...)
 	}
 	f.Touch()
 	f.addr = r[0] + 1
@@ -141,12 +157,26 @@ func (f *FileBuffer) Insert(line int, nlines []string) (e error) {
 	for i := first; i < len(f.buffer); i++ {
 		nf = append(nf, i)
 	}
+	f.own()
 	f.file = append(f.file[:line], append(nf, f.file[line:]...)...)
 	f.Touch()
 	f.addr = line + len(nlines) - 1
 	return
 }
 
+// Replace replaces line with s
+func (f *FileBuffer) Replace(line int, s string) (e error) {
+	if f.OOB(line) {
+		return ErrOOB
+	}
+	f.buffer = append(f.buffer, s)
+	f.own()
+	f.file[line] = len(f.buffer) - 1
+	f.Touch()
+	f.addr = line
+	return
+}
+
 // Len returns the current file length
 func (f *FileBuffer) Len() int {
 	return len(f.file)
@@ -172,11 +202,12 @@ func (f *FileBuffer) SetAddr(i int) (e error) {
 }
 
 // Clean resets the dirty flag
+// Undoing after this makes the file dirty again.
 func (f *FileBuffer) Clean() {
 	f.dirty = false
-	f.lastDirty = false
-	f.lastFile = []int{}
-	f.lastAddr = 0
+	for i := range f.undo {
+		f.undo[i].dirty = true
+	}
 }
 
 // FileToBuffer reads a file and creates a new FileBuffer from it
@@ -205,15 +236,39 @@ func (f *FileBuffer) GetMark(c byte) (l int, e error) {
 	if !ok {
 		return -1, fmt.Errorf("no such mark: %c", c)
 	}
-	for i := 0; i < f.Len(); i++ {
-		if f.file[i] == bl {
-			l = i
-			return
-		}
+	if l, ok = f.Find(bl); ok {
+		return
 	}
 	return -1, fmt.Errorf("mark was cleared: %c", c)
 }
 
+// Select returns the buffer lines in r that match rx, or that don't if invert is set.
+// Unlike file lines, buffer lines stay the same as the file is modified.
+func (f *FileBuffer) Select(r [2]int, rx *regexp.Regexp, invert bool) (bl []int, e error) {
+	if f.OOB(r[0]) || f.OOB(r[1]) {
+		e = ErrOOB
+		return
+	}
+	for l := r[0]; l <= r[1]; l++ {
+		if rx.MatchString(f.buffer[f.file[l]]) != invert {
+			bl = append(bl, f.file[l])
+		}
+	}
+	return
+}
+
+// Find returns the file line of buffer line bl, if it is still in the file
+func (f *FileBuffer) Find(bl int) (l int, ok bool) {
+	if f.lines == nil {
+		f.lines = make(map[int]int, len(f.file))
+		for i, b := range f.file {
+			f.lines[b] = i
+		}
+	}
+	l, ok = f.lines[bl]
+	return
+}
+
 // Size return the size (in bytes) of the current file buffer
 func (f *FileBuffer) Size() (s int) {
 	for _, i := range f.file {
@@ -226,9 +281,13 @@ func (f *FileBuffer) Size() (s int) {
 func (f *FileBuffer) Read(line int, r io.Reader) (e error) {
 	b := []string{}
 	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxLineLen)
 	for s.Scan() {
 		b = append(b, s.Text())
 	}
+	if e = s.Err(); e != nil {
+		return
+	}
 	e = f.Insert(line, b)
 	return
 }
@@ -249,33 +308,41 @@ func (f *FileBuffer) ReadFile(line int, file string) (e error) {
 // Start a transaction
 func (f *FileBuffer) Start() {
 	f.mod = false
-	f.tmpFile = make([]int, len(f.file))
-	copy(f.tmpFile, f.file)
-	f.tmpAddr = f.addr
-	f.tmpDirty = f.dirty
+	f.tmp = snapshot{file: f.file, addr: f.addr, dirty: f.dirty}
+	f.shared = true
 }
 
 // End a transaction
+// If the file was modified, the state at the start can be undone to.
 func (f *FileBuffer) End() {
 	if f.mod {
-		f.lastFile = f.tmpFile
-		f.lastAddr = f.tmpAddr
-		f.lastDirty = f.tmpDirty
+		if len(f.undo) == maxUndo {
+			f.undo = append(f.undo[:0], f.undo[1:]...)
+		}
+		f.undo = append(f.undo, f.tmp)
 	}
 }
 
-// Rewind restores the previous file
+// Rewind restores the file before the last modifying transaction.
+// Repeated calls go further back.
 func (f *FileBuffer) Rewind() {
-	if f.Dirty() || f.lastDirty {
-		f.addr = f.lastAddr
-		f.file = f.lastFile
-		f.dirty = f.lastDirty
-		f.mod = true
+	if len(f.undo) == 0 {
+		return
 	}
+	s := f.undo[len(f.undo)-1]
+	f.undo = f.undo[:len(f.undo)-1]
+	f.file = s.file
+	f.addr = s.addr
+	f.dirty = s.dirty
+	f.shared = true
+	f.lines = nil
+	// the undo itself is not something to undo
+	f.mod = false
 }
 
 // Touch is the correct way (even internally) to set the dirty & modified bits
 func (f *FileBuffer) Touch() {
 	f.dirty = true
 	f.mod = true
+	f.lines = nil
 }
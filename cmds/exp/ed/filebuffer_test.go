@@ -10,6 +10,7 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -112,26 +113,38 @@ var testTable = []struct {
 	},
 	{
 		name:   "Test Start",
-		in:     &FileBuffer{mod: true, tmpFile: []int{}, file: []int{0, 1, 2, 3}, tmpAddr: 0, addr: 10, tmpDirty: false, dirty: true},
-		exp:    &FileBuffer{mod: false, tmpFile: []int{0, 1, 2, 3}, file: []int{0, 1, 2, 3}, tmpAddr: 10, addr: 10, tmpDirty: true, dirty: true},
+		in:     &FileBuffer{mod: true, file: []int{0, 1, 2, 3}, addr: 10, dirty: true},
+		exp:    &FileBuffer{mod: false, tmp: snapshot{file: []int{0, 1, 2, 3}, addr: 10, dirty: true}, shared: true, file: []int{0, 1, 2, 3}, addr: 10, dirty: true},
 		method: (*FileBuffer).Start,
 	},
 	{
 		name:   "Test End",
-		in:     &FileBuffer{mod: true, lastFile: []int{}, tmpFile: []int{0, 1, 2, 3}, lastAddr: 0, tmpAddr: 10, lastDirty: false, tmpDirty: true},
-		exp:    &FileBuffer{mod: true, lastFile: []int{0, 1, 2, 3}, tmpFile: []int{0, 1, 2, 3}, lastAddr: 10, tmpAddr: 10, lastDirty: true, tmpDirty: true},
+		in:     &FileBuffer{mod: true, undo: []snapshot{{file: []int{}}}, tmp: snapshot{file: []int{0, 1, 2, 3}, addr: 10, dirty: true}},
+		exp:    &FileBuffer{mod: true, undo: []snapshot{{file: []int{}}, {file: []int{0, 1, 2, 3}, addr: 10, dirty: true}}, tmp: snapshot{file: []int{0, 1, 2, 3}, addr: 10, dirty: true}},
+		method: (*FileBuffer).End,
+	},
+	{
+		name:   "Test End: not modified",
+		in:     &FileBuffer{mod: false, tmp: snapshot{file: []int{0, 1, 2, 3}, addr: 10, dirty: true}},
+		exp:    &FileBuffer{mod: false, tmp: snapshot{file: []int{0, 1, 2, 3}, addr: 10, dirty: true}},
 		method: (*FileBuffer).End,
 	},
 	{
 		name:   "Test Rewind",
-		in:     &FileBuffer{mod: false, file: []int{}, lastFile: []int{0, 1, 2, 3}, addr: 0, lastAddr: 10, dirty: true, lastDirty: false},
-		exp:    &FileBuffer{mod: true, file: []int{0, 1, 2, 3}, lastFile: []int{0, 1, 2, 3}, addr: 10, lastAddr: 10, dirty: false, lastDirty: false},
+		in:     &FileBuffer{mod: true, file: []int{}, undo: []snapshot{{file: []int{0}, addr: 0, dirty: false}, {file: []int{0, 1, 2, 3}, addr: 10, dirty: true}}, addr: 0, dirty: true},
+		exp:    &FileBuffer{mod: false, file: []int{0, 1, 2, 3}, undo: []snapshot{{file: []int{0}, addr: 0, dirty: false}}, shared: true, addr: 10, dirty: true},
+		method: (*FileBuffer).Rewind,
+	},
+	{
+		name:   "Test Rewind: nothing to undo",
+		in:     &FileBuffer{file: []int{0, 1, 2, 3}, addr: 2, dirty: true},
+		exp:    &FileBuffer{file: []int{0, 1, 2, 3}, addr: 2, dirty: true},
 		method: (*FileBuffer).Rewind,
 	},
 	{
 		name:   "Test Clean",
-		in:     &FileBuffer{dirty: true, lastDirty: true, lastFile: []int{0, 1, 2, 3}, lastAddr: 10},
-		exp:    &FileBuffer{dirty: false, lastDirty: false, lastFile: []int{}, lastAddr: 0},
+		in:     &FileBuffer{dirty: true, undo: []snapshot{{file: []int{0, 1, 2, 3}, addr: 10, dirty: false}}},
+		exp:    &FileBuffer{dirty: false, undo: []snapshot{{file: []int{0, 1, 2, 3}, addr: 10, dirty: true}}},
 		method: (*FileBuffer).Clean,
 	},
 }
@@ -385,6 +398,52 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestReplace(t *testing.T) {
+	f := NewFileBuffer([]string{"0", "1", "2", "3"})
+	if err := f.Replace(4, "4"); err != ErrOOB {
+		t.Errorf("Replace(4) = %v, want %v", err, ErrOOB)
+	}
+	if err := f.Replace(2, "two"); err != nil {
+		t.Fatalf("Replace(2) = %v, want nil", err)
+	}
+	got, _ := f.Get([2]int{0, 3})
+	if want := []string{"0", "1", "two", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Replace(2): file = %q, want %q", got, want)
+	}
+	if !f.Dirty() {
+		t.Errorf("Replace(2): file is not dirty")
+	}
+}
+
+func TestUndo(t *testing.T) {
+	f := NewFileBuffer([]string{"0", "1", "2", "3"})
+	for _, r := range [][2]int{{0, 0}, {1, 2}} {
+		f.Start()
+		if err := f.Delete(r); err != nil {
+			t.Fatalf("Delete(%v) = %v, want nil", r, err)
+		}
+		f.End()
+	}
+	// commands that don't modify anything can't be undone
+	f.Start()
+	f.Get([2]int{0, 0})
+	f.End()
+	f.Clean()
+
+	for _, want := range [][]string{{"1", "2", "3"}, {"0", "1", "2", "3"}, {"0", "1", "2", "3"}} {
+		f.Start()
+		f.Rewind()
+		f.End()
+		got, _ := f.Get([2]int{0, f.Len() - 1})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Rewind: file = %q, want %q", got, want)
+		}
+		if !f.Dirty() {
+			t.Errorf("Rewind: file is not dirty after a write")
+		}
+	}
+}
+
 // Test SetAddr
 var testTableSetAddr = []struct {
 	name     string
@@ -531,6 +590,13 @@ var testTableRead = []struct {
 		methodin1: 0,
 		methodin2: &bytes.Buffer{},
 	},
+	{
+		name:      "Test Read: long line",
+		in:        &FileBuffer{},
+		err:       nil,
+		methodin1: 0,
+		methodin2: strings.NewReader(strings.Repeat("x", 1<<20) + "\n"),
+	},
 }
 
 func TestRead(t *testing.T) {
@@ -0,0 +1,205 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// efikeys reads and provisions the UEFI Secure Boot key databases.
+//
+// Synopsis:
+//
+//	efikeys [-vars DIR] read [PK|KEK|db|dbx]...
+//	efikeys [-vars DIR] [-owner GUID] enroll PK|KEK|db|dbx FILE
+//	efikeys [-vars DIR] [-owner GUID] append KEK|db|dbx FILE
+//
+// Description:
+//
+//	read prints the signature lists of the key databases, all of them
+//	by default, with the subject and issuer of certificates and the
+//	value of hashes.
+//
+//	enroll replaces a key database with FILE, append adds FILE to it.
+//	FILE is a signed update, as made by sign-efi-sig-list, an EFI
+//	signature list, or a PEM or DER X.509 certificate. The firmware
+//	checks the signature of signed updates against the key database
+//	above it. Unsigned files are only accepted in setup mode, which
+//	ends when PK is enrolled, so enroll PK last.
+//
+// Options:
+//
+//	-vars:  efivarfs mount point (default /sys/firmware/efi/efivars/)
+//	-owner: owner GUID of entries made from certificates
+//
+// Example:
+//
+//	efikeys enroll db db.crt
+//	efikeys enroll KEK KEK.esl
+//	efikeys enroll PK PK.auth
+//	efikeys append dbx dbxupdate.auth
+package main
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	guid "github.com/google/uuid"
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+var (
+	vars  = flag.String("vars", efivarfs.DefaultVarFS, "efivarfs mount point")
+	owner = flag.String("owner", "", "owner GUID of entries made from certificates, random by default")
+
+	errUsage = errors.New("usage: efikeys read [PK|KEK|db|dbx]... | enroll VAR FILE | append VAR FILE")
+)
+
+// signatureTypes names the signature types in the output of read.
+var signatureTypes = map[guid.UUID]string{
+	efivarfs.CertX509GUID:   "X509",
+	efivarfs.CertSHA256GUID: "SHA256",
+}
+
+func read(out io.Writer, e efivarfs.EFIVar, names []string) error {
+	if len(names) == 0 {
+		names = efivarfs.KeyDatabases
+	}
+	for _, name := range names {
+		desc, err := efivarfs.KeyDatabase(name)
+		if err != nil {
+			return err
+		}
+		_, data, err := e.Get(desc)
+		if errors.Is(err, efivarfs.ErrVarNotExist) || err == nil && len(data) == 0 {
+			fmt.Fprintf(out, "Variable %s has no entries\n", name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", name, err)
+		}
+		lists, err := efivarfs.ParseSignatureLists(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fmt.Fprintf(out, "Variable %s, length %d\n", name, len(data))
+		for i, l := range lists {
+			typ, ok := signatureTypes[l.Type]
+			if !ok {
+				typ = l.Type.String()
+			}
+			fmt.Fprintf(out, "%s: List %d, type %s\n", name, i, typ)
+			for j, s := range l.Signatures {
+				fmt.Fprintf(out, "    Signature %d, size %d, owner %v\n", j, 16+len(s.Data), s.Owner)
+				switch l.Type {
+				case efivarfs.CertX509GUID:
+					c, err := x509.ParseCertificate(s.Data)
+					if err != nil {
+						fmt.Fprintf(out, "        Invalid certificate: %v\n", err)
+						continue
+					}
+					fmt.Fprintf(out, "        Subject: %v\n        Issuer: %v\n", c.Subject, c.Issuer)
+				case efivarfs.CertSHA256GUID:
+					fmt.Fprintf(out, "        Hash: %s\n", hex.EncodeToString(s.Data))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// update returns the file contents b as an update of a key database.
+func update(e efivarfs.EFIVar, b []byte, owner guid.UUID, now time.Time) ([]byte, error) {
+	if payload, ok := efivarfs.SplitAuthenticated(b); ok {
+		if _, err := efivarfs.ParseSignatureLists(payload); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
+	setup, err := efivarfs.SetupMode(e)
+	if err != nil {
+		return nil, fmt.Errorf("reading SetupMode: %w", err)
+	}
+	if !setup {
+		return nil, fmt.Errorf("not in setup mode, the firmware only accepts signed updates")
+	}
+	if p, _ := pem.Decode(b); p != nil && p.Type == "CERTIFICATE" {
+		b = p.Bytes
+	}
+	if _, err := x509.ParseCertificate(b); err == nil {
+		b, err = efivarfs.MarshalSignatureLists([]efivarfs.SignatureList{{
+			Type:       efivarfs.CertX509GUID,
+			Signatures: []efivarfs.Signature{{Owner: owner, Data: b}},
+		}})
+		if err != nil {
+			return nil, err
+		}
+	} else if _, err := efivarfs.ParseSignatureLists(b); err != nil {
+		return nil, fmt.Errorf("neither a signed update, a certificate nor a signature list: %w", err)
+	}
+	return efivarfs.Unsigned(now, b), nil
+}
+
+func write(e efivarfs.EFIVar, op, name, file string, owner guid.UUID, now time.Time) error {
+	desc, err := efivarfs.KeyDatabase(name)
+	if err != nil {
+		return err
+	}
+	attrs := efivarfs.KeyDatabaseAttributes
+	if op == "append" {
+		if name == "PK" {
+			return fmt.Errorf("PK holds one key and can not be appended to")
+		}
+		attrs |= efivarfs.AttributeAppendWrite
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if b, err = update(e, b, owner, now); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	if err := e.Set(desc, attrs, b); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
+
+func run(out io.Writer, e efivarfs.EFIVar, owner guid.UUID, now time.Time, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "read":
+		return read(out, e, args[1:])
+	case "enroll", "append":
+		if len(args) != 3 {
+			return errUsage
+		}
+		return write(e, args[0], args[1], args[2], owner, now)
+	}
+	return errUsage
+}
+
+func main() {
+	flag.Parse()
+	o := guid.New()
+	if *owner != "" {
+		var err error
+		if o, err = guid.Parse(*owner); err != nil {
+			log.Fatalf("efikeys: owner: %v", err)
+		}
+	}
+	e, err := efivarfs.NewPath(*vars)
+	if err != nil {
+		log.Fatalf("efikeys: %v", err)
+	}
+	if err := run(os.Stdout, e, o, time.Now(), flag.Args()); err != nil {
+		log.Fatalf("efikeys: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	guid "github.com/google/uuid"
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+type fakeVars map[efivarfs.VariableDescriptor][]byte
+
+func (f fakeVars) Get(desc efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	b, ok := f[desc]
+	if !ok {
+		return 0, nil, efivarfs.ErrVarNotExist
+	}
+	return efivarfs.KeyDatabaseAttributes, b, nil
+}
+
+// Set stores the payload of updates like the firmware would.
+func (f fakeVars) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	if payload, ok := efivarfs.SplitAuthenticated(data); ok {
+		data = payload
+	}
+	if attrs&efivarfs.AttributeAppendWrite != 0 {
+		data = append(f[desc], data...)
+	}
+	f[desc] = data
+	return nil
+}
+
+func (f fakeVars) Remove(desc efivarfs.VariableDescriptor) error {
+	delete(f, desc)
+	return nil
+}
+
+func (f fakeVars) List() ([]efivarfs.VariableDescriptor, error) {
+	return nil, nil
+}
+
+var (
+	setupMode = efivarfs.VariableDescriptor{Name: "SetupMode", GUID: efivarfs.GlobalVariableGUID}
+	testOwner = guid.MustParse("bc54d3fb-ed45-462d-9df8-b9f736228350")
+)
+
+func testCert(t *testing.T) []byte {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test db"}}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestEnrollAndRead(t *testing.T) {
+	dir := t.TempDir()
+	der := testCert(t)
+	pemFile := filepath.Join(dir, "db.crt")
+	if err := os.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := efivarfs.MarshalSignatureLists([]efivarfs.SignatureList{{
+		Type:       efivarfs.CertSHA256GUID,
+		Signatures: []efivarfs.Signature{{Owner: testOwner, Data: bytes.Repeat([]byte{0xab}, 32)}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := filepath.Join(dir, "dbx.auth")
+	if err := os.WriteFile(signed, efivarfs.Unsigned(time.Now(), hash), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	garbage := filepath.Join(dir, "garbage")
+	if err := os.WriteFile(garbage, []byte("not a key"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := fakeVars{setupMode: {1}}
+	for _, args := range []string{"enroll db " + pemFile, "append db " + pemFile, "append dbx " + signed} {
+		if err := run(nil, e, testOwner, time.Now(), strings.Fields(args)); err != nil {
+			t.Fatalf("efikeys %s: %v", args, err)
+		}
+	}
+	for _, args := range []string{"enroll db " + garbage, "append PK " + pemFile, "enroll MokList " + pemFile, "enroll db", "delete db"} {
+		if err := run(nil, e, testOwner, time.Now(), strings.Fields(args)); err == nil {
+			t.Errorf("efikeys %s = nil, want error", args)
+		}
+	}
+
+	// Out of setup mode, only signed updates are written.
+	e[setupMode] = []byte{0}
+	if err := run(nil, e, testOwner, time.Now(), []string{"append", "db", pemFile}); err == nil {
+		t.Errorf("efikeys append db %s in user mode = nil, want error", pemFile)
+	}
+	if err := run(nil, e, testOwner, time.Now(), []string{"append", "dbx", signed}); err != nil {
+		t.Errorf("efikeys append dbx %s in user mode = %v", signed, err)
+	}
+
+	var out bytes.Buffer
+	if err := run(&out, e, testOwner, time.Now(), []string{"read"}); err != nil {
+		t.Fatal(err)
+	}
+	cert := "    Signature 0, size " + strconv.Itoa(16+len(der)) + ", owner " + testOwner.String() + "\n" +
+		"        Subject: CN=test db\n        Issuer: CN=test db\n"
+	hashLine := "    Signature 0, size 48, owner " + testOwner.String() + "\n" +
+		"        Hash: " + strings.Repeat("ab", 32) + "\n"
+	want := "Variable PK has no entries\n" +
+		"Variable KEK has no entries\n" +
+		"Variable db, length " + strconv.Itoa(2*(28+16+len(der))) + "\n" +
+		"db: List 0, type X509\n" + cert +
+		"db: List 1, type X509\n" + cert +
+		"Variable dbx, length 152\n" +
+		"dbx: List 0, type SHA256\n" + hashLine +
+		"dbx: List 1, type SHA256\n" + hashLine
+	if out.String() != want {
+		t.Errorf("efikeys read = \n%s, want\n%s", out.String(), want)
+	}
+}
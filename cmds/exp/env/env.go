@@ -0,0 +1,143 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// env runs a command in a modified environment.
+//
+// Synopsis:
+//
+//	env [-i] [-u NAME]... [NAME=VALUE]... [COMMAND [ARG]...]
+//
+// Description:
+//
+//	env sets each NAME to VALUE in its environment, and runs COMMAND in
+//	it. Without COMMAND, it prints the environment.
+//
+//	If COMMAND cannot be run, env exits with 126, or 127 if it is not
+//	found.
+//
+// Options:
+//
+//	-i: start with an empty environment; "-" alone is the same
+//	-u: remove NAME from the environment
+//	-0: end the lines of the printed environment with NUL, not newline
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+type unsetFlag []string
+
+func (u *unsetFlag) String() string {
+	return strings.Join(*u, ",")
+}
+
+func (u *unsetFlag) Set(s string) error {
+	if s == "" || strings.Contains(s, "=") {
+		return fmt.Errorf("cannot unset %q", s)
+	}
+	*u = append(*u, s)
+	return nil
+}
+
+var (
+	ignore = flag.Bool("i", false, "start with an empty environment")
+	null   = flag.Bool("0", false, "end the printed lines with NUL")
+	unset  unsetFlag
+)
+
+func init() {
+	flag.Var(&unset, "u", "remove `NAME` from the environment")
+}
+
+// environ returns environ modified by the options and the NAME=VALUE
+// arguments of args, and the command that follows them.
+func environ(environ []string, ignore bool, unset []string, args []string) ([]string, []string) {
+	if len(args) > 0 && args[0] == "-" {
+		ignore, args = true, args[1:]
+	}
+	if ignore {
+		environ = nil
+	}
+	vars := map[string]int{}
+	var env []string
+	set := func(kv string) {
+		name, _, _ := strings.Cut(kv, "=")
+		if i, ok := vars[name]; ok {
+			env[i] = kv
+			return
+		}
+		vars[name] = len(env)
+		env = append(env, kv)
+	}
+	for _, kv := range environ {
+		set(kv)
+	}
+	for _, name := range unset {
+		if i, ok := vars[name]; ok {
+			env[i] = ""
+		}
+	}
+	for ; len(args) > 0 && strings.Contains(args[0], "="); args = args[1:] {
+		set(args[0])
+	}
+	var out []string
+	for _, kv := range env {
+		if kv != "" {
+			out = append(out, kv)
+		}
+	}
+	return out, args
+}
+
+func printEnv(w io.Writer, env []string, null bool) {
+	end := "\n"
+	if null {
+		end = "\x00"
+	}
+	for _, kv := range env {
+		fmt.Fprint(w, kv, end)
+	}
+}
+
+func main() {
+	flag.Parse()
+	env, args := environ(os.Environ(), *ignore, unset, flag.Args())
+	if len(args) == 0 {
+		printEnv(os.Stdout, env, *null)
+		return
+	}
+	if *null {
+		log.Fatalf("env: cannot use -0 with a command")
+	}
+
+	// Look the command up in the PATH of the new environment.
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			os.Setenv("PATH", kv[len("PATH="):])
+		}
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		log.Printf("env: %v", err)
+		if errors.Is(err, exec.ErrNotFound) {
+			os.Exit(127)
+		}
+		os.Exit(126)
+	}
+	err = syscall.Exec(path, args, env)
+	log.Printf("env: %s: %v", args[0], err)
+	if errors.Is(err, os.ErrNotExist) {
+		os.Exit(127)
+	}
+	os.Exit(126)
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEnviron(t *testing.T) {
+	base := []string{"A=1", "B=2", "C=3"}
+	for _, tt := range []struct {
+		name     string
+		ignore   bool
+		unset    []string
+		args     []string
+		wantEnv  []string
+		wantArgs []string
+	}{
+		{name: "unchanged", args: []string{"ls", "-l"}, wantEnv: base, wantArgs: []string{"ls", "-l"}},
+		{name: "set", args: []string{"B=x", "D=4", "cmd", "E=5"}, wantEnv: []string{"A=1", "B=x", "C=3", "D=4"}, wantArgs: []string{"cmd", "E=5"}},
+		{name: "unset", unset: []string{"A", "Z"}, args: []string{"C=", "B=y"}, wantEnv: []string{"B=y", "C="}},
+		{name: "ignore", ignore: true, args: []string{"X=1"}, wantEnv: []string{"X=1"}},
+		{name: "dash", args: []string{"-", "cmd"}, wantArgs: []string{"cmd"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			env, args := environ(base, tt.ignore, tt.unset, tt.args)
+			if len(args) == 0 {
+				args = nil
+			}
+			if !reflect.DeepEqual(env, tt.wantEnv) || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("environ = %q, %q, want %q, %q", env, args, tt.wantEnv, tt.wantArgs)
+			}
+		})
+	}
+
+	var b bytes.Buffer
+	printEnv(&b, base[:2], true)
+	if b.String() != "A=1\x00B=2\x00" {
+		t.Errorf("printEnv -0 = %q", b.String())
+	}
+	var u unsetFlag
+	if err := u.Set("A=B"); err == nil {
+		t.Errorf("-u A=B succeeded")
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// ethtool queries and changes network interface settings.
+//
+// Synopsis:
+//
+//	ethtool IFACE
+//	ethtool -s IFACE [speed N] [duplex half|full] [autoneg on|off]
+//	ethtool -i IFACE
+//	ethtool -g IFACE
+//	ethtool -G IFACE [rx N] [rx-mini N] [rx-jumbo N] [tx N]
+//	ethtool -S IFACE
+//	ethtool -k IFACE
+//	ethtool -K IFACE FEATURE on|off...
+//
+// Description:
+//
+//	Without options, the link settings and state are shown.
+//
+//	Features may be given by their kernel names, as listed by -k, or by the
+//	short names sg, tso, gso, gro, lro, rx, tx, rxvlan, txvlan and rxhash.
+//
+// Options:
+//
+//	-s: change the link settings
+//	-i: show driver and firmware versions
+//	-g: show ring sizes
+//	-G: change ring sizes
+//	-S: show driver statistics
+//	-k: show offload features
+//	-K: change offload features
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/safchain/ethtool"
+)
+
+var (
+	setLink     = flag.Bool("s", false, "change the link settings")
+	driver      = flag.Bool("i", false, "show driver information")
+	rings       = flag.Bool("g", false, "show ring sizes")
+	setRings    = flag.Bool("G", false, "change ring sizes")
+	stats       = flag.Bool("S", false, "show driver statistics")
+	features    = flag.Bool("k", false, "show offload features")
+	setFeatures = flag.Bool("K", false, "change offload features")
+)
+
+var errUsage = errors.New("usage: ethtool [-s|-i|-g|-G|-S|-k|-K] IFACE [ARGS...]")
+
+// ringParam is struct ethtool_ringparam.
+type ringParam struct {
+	Cmd            uint32
+	RxMaxPending   uint32
+	RxMiniMax      uint32
+	RxJumboMax     uint32
+	TxMaxPending   uint32
+	RxPending      uint32
+	RxMiniPending  uint32
+	RxJumboPending uint32
+	TxPending      uint32
+}
+
+// nic is the set of ethtool requests the command makes.
+type nic interface {
+	settings(iface string) (*ethtool.EthtoolCmd, error)
+	setSettings(iface string, c *ethtool.EthtoolCmd) error
+	link(iface string) (bool, error)
+	driverInfo(iface string) (ethtool.DrvInfo, error)
+	rings(iface string) (*ringParam, error)
+	setRings(iface string, r *ringParam) error
+	stats(iface string) (map[string]uint64, error)
+	features(iface string) (map[string]bool, error)
+	setFeatures(iface string, f map[string]bool) error
+}
+
+// Advertised and supported link modes, from uapi/linux/ethtool.h.
+var linkModes = []struct {
+	bit  uint32
+	name string
+}{
+	{1 << 0, "10baseT/Half"},
+	{1 << 1, "10baseT/Full"},
+	{1 << 2, "100baseT/Half"},
+	{1 << 3, "100baseT/Full"},
+	{1 << 4, "1000baseT/Half"},
+	{1 << 5, "1000baseT/Full"},
+	{1 << 12, "10000baseT/Full"},
+	{1 << 15, "2500baseX/Full"},
+	{1 << 17, "1000baseKX/Full"},
+	{1 << 18, "10000baseKX4/Full"},
+	{1 << 19, "10000baseKR/Full"},
+	{1 << 23, "40000baseKR4/Full"},
+	{1 << 24, "40000baseCR4/Full"},
+	{1 << 25, "40000baseSR4/Full"},
+	{1 << 26, "40000baseLR4/Full"},
+}
+
+var ports = map[uint8]string{
+	0x00: "Twisted Pair",
+	0x01: "AUI",
+	0x02: "MII",
+	0x03: "FIBRE",
+	0x04: "BNC",
+	0x05: "Directly Attached Copper",
+	0xef: "None",
+	0xff: "Other",
+}
+
+func modes(m uint32) string {
+	var s []string
+	for _, l := range linkModes {
+		if m&l.bit != 0 {
+			s = append(s, l.name)
+		}
+	}
+	if len(s) == 0 {
+		return "Not reported"
+	}
+	return strings.Join(s, " ")
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func parseOnOff(s string) (bool, error) {
+	switch s {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("want on or off, got %q", s)
+}
+
+func showSettings(w io.Writer, n nic, iface string) error {
+	c, err := n.settings(iface)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Settings for %s:\n", iface)
+	fmt.Fprintf(w, "\tSupported link modes:   %s\n", modes(c.Supported))
+	fmt.Fprintf(w, "\tAdvertised link modes:  %s\n", modes(c.Advertising))
+	speed := uint32(c.Speed_hi)<<16 | uint32(c.Speed)
+	if speed == 0 || speed == math.MaxUint16 || speed == math.MaxUint32 {
+		fmt.Fprintf(w, "\tSpeed: Unknown!\n")
+	} else {
+		fmt.Fprintf(w, "\tSpeed: %dMb/s\n", speed)
+	}
+	duplex := map[uint8]string{0: "Half", 1: "Full"}[c.Duplex]
+	if duplex == "" {
+		duplex = "Unknown!"
+	}
+	fmt.Fprintf(w, "\tDuplex: %s\n", duplex)
+	port := ports[c.Port]
+	if port == "" {
+		port = "Unknown!"
+	}
+	fmt.Fprintf(w, "\tPort: %s\n", port)
+	fmt.Fprintf(w, "\tAuto-negotiation: %s\n", onOff(c.Autoneg != 0))
+	if up, err := n.link(iface); err == nil {
+		fmt.Fprintf(w, "\tLink detected: %s\n", map[bool]string{true: "yes", false: "no"}[up])
+	}
+	return nil
+}
+
+func changeSettings(n nic, iface string, args []string) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return errUsage
+	}
+	// SSET replaces all settings, so start from the current ones.
+	c, err := n.settings(iface)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(args); i += 2 {
+		k, v := args[i], args[i+1]
+		switch k {
+		case "speed":
+			s, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return fmt.Errorf("speed: %v", err)
+			}
+			c.Speed, c.Speed_hi = uint16(s), uint16(s>>16)
+		case "duplex":
+			switch v {
+			case "half":
+				c.Duplex = 0
+			case "full":
+				c.Duplex = 1
+			default:
+				return fmt.Errorf("duplex: want half or full, got %q", v)
+			}
+		case "autoneg":
+			on, err := parseOnOff(v)
+			if err != nil {
+				return fmt.Errorf("autoneg: %v", err)
+			}
+			c.Autoneg = 0
+			if on {
+				c.Autoneg = 1
+			}
+		default:
+			return fmt.Errorf("unknown setting %q", k)
+		}
+	}
+	return n.setSettings(iface, c)
+}
+
+func showDriver(w io.Writer, n nic, iface string) error {
+	d, err := n.driverInfo(iface)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "driver: %s\nversion: %s\nfirmware-version: %s\nexpansion-rom-version: %s\nbus-info: %s\n",
+		d.Driver, d.Version, d.FwVersion, d.EromVersion, d.BusInfo)
+	return nil
+}
+
+func showRings(w io.Writer, n nic, iface string) error {
+	r, err := n.rings(iface)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Ring parameters for %s:\n", iface)
+	fmt.Fprintf(w, "Pre-set maximums:\nRX:\t\t%d\nRX Mini:\t%d\nRX Jumbo:\t%d\nTX:\t\t%d\n", r.RxMaxPending, r.RxMiniMax, r.RxJumboMax, r.TxMaxPending)
+	fmt.Fprintf(w, "Current hardware settings:\nRX:\t\t%d\nRX Mini:\t%d\nRX Jumbo:\t%d\nTX:\t\t%d\n", r.RxPending, r.RxMiniPending, r.RxJumboPending, r.TxPending)
+	return nil
+}
+
+func changeRings(n nic, iface string, args []string) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return errUsage
+	}
+	r, err := n.rings(iface)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(args); i += 2 {
+		v, err := strconv.ParseUint(args[i+1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("%s: %v", args[i], err)
+		}
+		var cur *uint32
+		var max uint32
+		switch args[i] {
+		case "rx":
+			cur, max = &r.RxPending, r.RxMaxPending
+		case "rx-mini":
+			cur, max = &r.RxMiniPending, r.RxMiniMax
+		case "rx-jumbo":
+			cur, max = &r.RxJumboPending, r.RxJumboMax
+		case "tx":
+			cur, max = &r.TxPending, r.TxMaxPending
+		default:
+			return fmt.Errorf("unknown ring %q", args[i])
+		}
+		if uint32(v) > max {
+			return fmt.Errorf("%s: %d is more than the maximum of %d", args[i], v, max)
+		}
+		*cur = uint32(v)
+	}
+	return n.setRings(iface, r)
+}
+
+func showStats(w io.Writer, n nic, iface string) error {
+	s, err := n.stats(iface)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(s))
+	for k := range s {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "NIC statistics:\n")
+	for _, k := range names {
+		fmt.Fprintf(w, "     %s: %d\n", k, s[k])
+	}
+	return nil
+}
+
+func showFeatures(w io.Writer, n nic, iface string) error {
+	f, err := n.features(iface)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(f))
+	for k := range f {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "Features for %s:\n", iface)
+	for _, k := range names {
+		fmt.Fprintf(w, "%s: %s\n", k, onOff(f[k]))
+	}
+	return nil
+}
+
+// featureAliases are the short names of ethtool -K.
+var featureAliases = map[string][]string{
+	"rx":     {"rx-checksum"},
+	"tx":     {"tx-checksum-ipv4", "tx-checksum-ip-generic", "tx-checksum-ipv6", "tx-checksum-fcoe-crc", "tx-checksum-sctp"},
+	"sg":     {"tx-scatter-gather"},
+	"tso":    {"tx-tcp-segmentation", "tx-tcp-ecn-segmentation", "tx-tcp-mangleid-segmentation", "tx-tcp6-segmentation"},
+	"gso":    {"tx-generic-segmentation"},
+	"gro":    {"rx-gro"},
+	"lro":    {"rx-lro"},
+	"rxvlan": {"rx-vlan-hw-parse"},
+	"txvlan": {"tx-vlan-hw-insert"},
+	"rxhash": {"rx-hashing"},
+}
+
+func changeFeatures(n nic, iface string, args []string) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return errUsage
+	}
+	known, err := n.features(iface)
+	if err != nil {
+		return err
+	}
+	change := map[string]bool{}
+	for i := 0; i < len(args); i += 2 {
+		on, err := parseOnOff(args[i+1])
+		if err != nil {
+			return fmt.Errorf("%s: %v", args[i], err)
+		}
+		if _, ok := known[args[i]]; ok {
+			change[args[i]] = on
+			continue
+		}
+		names, ok := featureAliases[args[i]]
+		if !ok {
+			return fmt.Errorf("unknown feature %q", args[i])
+		}
+		// Only the features of an alias that the device has are set.
+		found := false
+		for _, k := range names {
+			if _, ok := known[k]; ok {
+				change[k] = on
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s does not support %s", iface, args[i])
+		}
+	}
+	return n.setFeatures(iface, change)
+}
+
+func run(w io.Writer, n nic, args []string) error {
+	mode := ""
+	for _, m := range []struct {
+		set  bool
+		name string
+	}{{*setLink, "s"}, {*driver, "i"}, {*rings, "g"}, {*setRings, "G"}, {*stats, "S"}, {*features, "k"}, {*setFeatures, "K"}} {
+		if m.set {
+			if mode != "" {
+				return errUsage
+			}
+			mode = m.name
+		}
+	}
+	if len(args) < 1 {
+		return errUsage
+	}
+	iface, rest := args[0], args[1:]
+	if len(rest) > 0 && mode != "s" && mode != "G" && mode != "K" {
+		return errUsage
+	}
+
+	switch mode {
+	case "s":
+		return changeSettings(n, iface, rest)
+	case "i":
+		return showDriver(w, n, iface)
+	case "g":
+		return showRings(w, n, iface)
+	case "G":
+		return changeRings(n, iface, rest)
+	case "S":
+		return showStats(w, n, iface)
+	case "k":
+		return showFeatures(w, n, iface)
+	case "K":
+		return changeFeatures(n, iface, rest)
+	}
+	return showSettings(w, n, iface)
+}
+
+func main() {
+	flag.Parse()
+	k, err := newKernel()
+	if err != nil {
+		log.Fatalf("ethtool: %v", err)
+	}
+	err = run(os.Stdout, k, flag.Args())
+	k.Close()
+	if err != nil {
+		log.Fatalf("ethtool: %v", err)
+	}
+}
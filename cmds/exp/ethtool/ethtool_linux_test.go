@@ -0,0 +1,135 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/safchain/ethtool"
+)
+
+type fakeNIC struct {
+	cmd   ethtool.EthtoolCmd
+	ring  ringParam
+	feats map[string]bool
+	set   map[string]bool
+}
+
+func (f *fakeNIC) settings(string) (*ethtool.EthtoolCmd, error) { c := f.cmd; return &c, nil }
+func (f *fakeNIC) setSettings(_ string, c *ethtool.EthtoolCmd) error {
+	f.cmd = *c
+	return nil
+}
+func (f *fakeNIC) link(string) (bool, error) { return true, nil }
+func (f *fakeNIC) driverInfo(string) (ethtool.DrvInfo, error) {
+	return ethtool.DrvInfo{Driver: "e1000e", Version: "5.10", FwVersion: "0.13-4", BusInfo: "0000:00:19.0"}, nil
+}
+func (f *fakeNIC) rings(string) (*ringParam, error) { r := f.ring; return &r, nil }
+func (f *fakeNIC) setRings(_ string, r *ringParam) error {
+	f.ring = *r
+	return nil
+}
+func (f *fakeNIC) stats(string) (map[string]uint64, error) {
+	return map[string]uint64{"tx_packets": 7, "rx_crc_errors": 3}, nil
+}
+func (f *fakeNIC) features(string) (map[string]bool, error) { return f.feats, nil }
+func (f *fakeNIC) setFeatures(_ string, m map[string]bool) error {
+	f.set = m
+	return nil
+}
+
+func newFake() *fakeNIC {
+	return &fakeNIC{
+		cmd:   ethtool.EthtoolCmd{Supported: 0x3f, Advertising: 0x20, Speed: 1000, Duplex: 1, Autoneg: 1},
+		ring:  ringParam{RxMaxPending: 4096, TxMaxPending: 4096, RxPending: 256, TxPending: 256},
+		feats: map[string]bool{"rx-checksum": true, "tx-checksum-ipv4": true, "tx-checksum-ipv6": true, "rx-gro": true},
+	}
+}
+
+func runMode(t *testing.T, mode *bool, n nic, args ...string) (string, error) {
+	t.Helper()
+	if mode != nil {
+		*mode = true
+		defer func() { *mode = false }()
+	}
+	var b bytes.Buffer
+	err := run(&b, n, args)
+	return b.String(), err
+}
+
+func TestShow(t *testing.T) {
+	for _, tt := range []struct {
+		mode *bool
+		want []string
+	}{
+		{nil, []string{"Speed: 1000Mb/s", "Duplex: Full", "Auto-negotiation: on", "Advertised link modes:  1000baseT/Full", "Link detected: yes"}},
+		{driver, []string{"driver: e1000e", "firmware-version: 0.13-4", "bus-info: 0000:00:19.0"}},
+		{rings, []string{"RX:\t\t4096", "TX:\t\t256"}},
+		{stats, []string{"     rx_crc_errors: 3\n     tx_packets: 7\n"}},
+		{features, []string{"rx-checksum: on", "rx-gro: on"}},
+	} {
+		out, err := runMode(t, tt.mode, newFake(), "eth0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(out, w) {
+				t.Errorf("output %q does not contain %q", out, w)
+			}
+		}
+	}
+}
+
+func TestChange(t *testing.T) {
+	f := newFake()
+	if _, err := runMode(t, setLink, f, "eth0", "speed", "100", "duplex", "half", "autoneg", "off"); err != nil {
+		t.Fatal(err)
+	}
+	if f.cmd.Speed != 100 || f.cmd.Duplex != 0 || f.cmd.Autoneg != 0 || f.cmd.Supported != 0x3f {
+		t.Errorf("-s set %+v", f.cmd)
+	}
+	if _, err := runMode(t, setLink, f, "eth0", "duplex", "quarter"); err == nil {
+		t.Errorf("-s duplex quarter succeeded")
+	}
+
+	if _, err := runMode(t, setRings, f, "eth0", "rx", "1024", "tx", "512"); err != nil {
+		t.Fatal(err)
+	}
+	if f.ring.RxPending != 1024 || f.ring.TxPending != 512 || f.ring.RxMaxPending != 4096 {
+		t.Errorf("-G set %+v", f.ring)
+	}
+	if _, err := runMode(t, setRings, f, "eth0", "rx", "8192"); err == nil {
+		t.Errorf("-G rx above the maximum succeeded")
+	}
+
+	if _, err := runMode(t, setFeatures, f, "eth0", "tx", "off", "rx-gro", "off"); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"tx-checksum-ipv4": false, "tx-checksum-ipv6": false, "rx-gro": false}; !reflect.DeepEqual(f.set, want) {
+		t.Errorf("-K set %v, want %v", f.set, want)
+	}
+	for _, args := range [][]string{{"eth0", "tso", "off"}, {"eth0", "bogus", "on"}, {"eth0", "gro", "maybe"}} {
+		if _, err := runMode(t, setFeatures, f, args...); err == nil {
+			t.Errorf("-K %q succeeded", args)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	if _, err := runMode(t, nil, newFake()); err != errUsage {
+		t.Errorf("no interface = %v, want %v", err, errUsage)
+	}
+	if _, err := runMode(t, driver, newFake(), "eth0", "extra"); err != errUsage {
+		t.Errorf("-i with extra arguments = %v, want %v", err, errUsage)
+	}
+	*rings = true
+	defer func() { *rings = false }()
+	if _, err := runMode(t, stats, newFake(), "eth0"); err != errUsage {
+		t.Errorf("-g -S = %v, want %v", err, errUsage)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"unsafe"
+
+	"github.com/safchain/ethtool"
+	"golang.org/x/sys/unix"
+)
+
+// kernel makes ethtool requests with the SIOCETHTOOL ioctl.
+type kernel struct {
+	*ethtool.Ethtool
+	fd int
+}
+
+func newKernel() (*kernel, error) {
+	e, err := ethtool.NewEthtool()
+	if err != nil {
+		return nil, err
+	}
+	// The ethtool package has no ring requests, so they need a socket of
+	// their own.
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		e.Close()
+		return nil, err
+	}
+	return &kernel{Ethtool: e, fd: fd}, nil
+}
+
+func (k *kernel) Close() {
+	k.Ethtool.Close()
+	unix.Close(k.fd)
+}
+
+type ifreq struct {
+	name [unix.IFNAMSIZ]byte
+	data uintptr
+}
+
+func (k *kernel) ioctl(iface string, data unsafe.Pointer) error {
+	ifr := ifreq{data: uintptr(data)}
+	copy(ifr.name[:unix.IFNAMSIZ-1], iface)
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(k.fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (k *kernel) settings(iface string) (*ethtool.EthtoolCmd, error) {
+	var c ethtool.EthtoolCmd
+	if _, err := k.CmdGet(&c, iface); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (k *kernel) setSettings(iface string, c *ethtool.EthtoolCmd) error {
+	_, err := k.CmdSet(c, iface)
+	return err
+}
+
+func (k *kernel) link(iface string) (bool, error) {
+	s, err := k.LinkState(iface)
+	return s != 0, err
+}
+
+func (k *kernel) driverInfo(iface string) (ethtool.DrvInfo, error) {
+	return k.DriverInfo(iface)
+}
+
+func (k *kernel) rings(iface string) (*ringParam, error) {
+	r := &ringParam{Cmd: unix.ETHTOOL_GRINGPARAM}
+	if err := k.ioctl(iface, unsafe.Pointer(r)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (k *kernel) setRings(iface string, r *ringParam) error {
+	r.Cmd = unix.ETHTOOL_SRINGPARAM
+	return k.ioctl(iface, unsafe.Pointer(r))
+}
+
+func (k *kernel) stats(iface string) (map[string]uint64, error) {
+	return k.Stats(iface)
+}
+
+func (k *kernel) features(iface string) (map[string]bool, error) {
+	return k.Features(iface)
+}
+
+func (k *kernel) setFeatures(iface string, f map[string]bool) error {
+	return k.Change(iface, f)
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// expect runs a program on a pseudo terminal and interacts with it as a
+// script says, to automate interactive tools such as RAID configurators and
+// firmware flashers.
+//
+// Synopsis:
+//
+//	expect [-f SCRIPT] [-t TIMEOUT] [-q] [-i] CMD [ARG]...
+//
+// Description:
+//
+//	The script, SCRIPT or stdin, is a subset of expect-lite. Each line is
+//	a step:
+//	  >TEXT   send TEXT and Enter
+//	  >>TEXT  send TEXT
+//	  <RE     wait for output matching the regular expression RE
+//	  <<TEXT  wait for output containing TEXT
+//	  @DUR    wait at most DUR for output from now on, e.g. 30s or 30
+//	  :DUR    sleep for DUR
+//	  # ...   comment
+//	TEXT may have the escapes \r, \n, \t, \e (escape), \xHH and \\, e.g.
+//	\x03 for Ctrl-C. Output is matched after what earlier steps matched.
+//
+//	After the script, expect waits for the program to exit, and fails if it
+//	fails. With -i, the terminal is handed over to the user instead.
+//
+//	expect fails if output does not match within the timeout, 10s by
+//	default, or the program exits first.
+//
+// Options:
+//
+//	-f: script file
+//	-t: default timeout
+//	-q: do not copy the output of the program to stdout
+//	-i: interact with the program after the script
+//
+// Example:
+//
+//	cat > flash.exp <<EOF
+//	@60
+//	<Continue\? \[y/N\]
+//	>y
+//	<Flash complete
+//	EOF
+//	expect -f flash.exp flashtool -w bios.bin
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/u-root/u-root/pkg/pty"
+	"github.com/u-root/u-root/pkg/termios"
+)
+
+var (
+	scriptFile = flag.String("f", "", "script file, instead of stdin")
+	timeout    = flag.Duration("t", 10*time.Second, "default timeout")
+	quiet      = flag.Bool("q", false, "do not copy the output of the program to stdout")
+	interact   = flag.Bool("i", false, "interact with the program after the script")
+)
+
+// spawn starts argv on a new pseudo terminal, and returns its session. Its
+// output is copied to echo.
+func spawn(argv []string, echo io.Writer) (*session, *exec.Cmd, error) {
+	ptm, pts, err := pty.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer pts.Close()
+	var ws termios.Winsize
+	ws.Row, ws.Col = 24, 80
+	termios.SetWinSize(pts.Fd(), &ws)
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = pts, pts, pts
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		ptm.Close()
+		return nil, nil, err
+	}
+
+	out := make(chan []byte, 64)
+	go func() {
+		defer close(out)
+		for {
+			b := make([]byte, 4096)
+			// Reads fail with EIO once the program, and everything
+			// it started on the terminal, has exited.
+			n, err := ptm.Read(b)
+			if n > 0 {
+				echo.Write(b[:n])
+				out <- b[:n]
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return &session{in: ptm, out: out, timeout: *timeout}, cmd, nil
+}
+
+// relay copies stdin to the program in raw mode until it exits.
+func relay(s *session) error {
+	in := os.Stdin
+	if termios.IsTerminal(in.Fd()) {
+		r, err := termios.SetMode(in.Fd(), termios.MakeRaw)
+		if err != nil {
+			return err
+		}
+		defer r.Restore()
+	}
+	go io.Copy(s.in, in)
+	for range s.out {
+	}
+	s.eof = true
+	return nil
+}
+
+func run(argv []string) error {
+	script := io.Reader(os.Stdin)
+	if *scriptFile != "" {
+		f, err := os.Open(*scriptFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		script = f
+	} else if *interact {
+		return errors.New("-i needs the script in -f, stdin is the user's")
+	}
+	steps, err := parse(script)
+	if err != nil {
+		return err
+	}
+
+	echo := io.Writer(os.Stdout)
+	if *quiet {
+		echo = io.Discard
+	}
+	s, cmd, err := spawn(argv, echo)
+	if err != nil {
+		return err
+	}
+	if err := s.run(steps); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	if *interact {
+		err = relay(s)
+	} else {
+		err = s.wait()
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
+
+func main() {
+	log.SetPrefix("expect: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: expect [-f SCRIPT] [-t TIMEOUT] [-q] [-i] CMD [ARG]...\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if err := run(flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSpawn(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	steps, err := parse(strings.NewReader(`>read -p 'Name? ' n; echo "hello $n"; exit 3
+<Name\? $
+>world
+<hello world`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	s, cmd, err := spawn([]string{"/bin/sh"}, &out)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skipf("No pseudo terminals here: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.run(steps); err != nil {
+		cmd.Process.Kill()
+		t.Fatalf("run = %v, output %q", err, out.String())
+	}
+	if err := s.wait(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err == nil || cmd.ProcessState.ExitCode() != 3 {
+		t.Errorf("exit = %v, want exit status 3", err)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBuffered is how much output is kept to match, at most.
+const maxBuffered = 64 << 10
+
+// step is a line of a script.
+type step struct {
+	line int
+	text string
+
+	send    []byte
+	match   func([]byte) (end int, ok bool)
+	timeout time.Duration
+	sleep   time.Duration
+}
+
+// parse parses a script.
+func parse(r io.Reader) ([]step, error) {
+	var steps []step
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		st, err := parseStep(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		st.line, st.text = n, line
+		steps = append(steps, st)
+	}
+	return steps, s.Err()
+}
+
+func parseStep(line string) (step, error) {
+	switch {
+	case strings.HasPrefix(line, ">>"):
+		b, err := unescape(line[2:])
+		return step{send: b}, err
+
+	case strings.HasPrefix(line, ">"):
+		b, err := unescape(line[1:])
+		// Enter is a carriage return, also for programs in raw mode.
+		return step{send: append(b, '\r')}, err
+
+	case strings.HasPrefix(line, "<<"):
+		b, err := unescape(line[2:])
+		if err != nil {
+			return step{}, err
+		}
+		return step{match: func(out []byte) (int, bool) {
+			if i := bytes.Index(out, b); i >= 0 {
+				return i + len(b), true
+			}
+			return 0, false
+		}}, nil
+
+	case strings.HasPrefix(line, "<"):
+		re, err := regexp.Compile(line[1:])
+		if err != nil {
+			return step{}, err
+		}
+		return step{match: func(out []byte) (int, bool) {
+			if loc := re.FindIndex(out); loc != nil {
+				return loc[1], true
+			}
+			return 0, false
+		}}, nil
+
+	case strings.HasPrefix(line, "@"):
+		d, err := parseDuration(line[1:])
+		return step{timeout: d}, err
+
+	case strings.HasPrefix(line, ":"):
+		d, err := parseDuration(line[1:])
+		return step{sleep: d}, err
+	}
+	return step{}, fmt.Errorf("unknown step %q", line)
+}
+
+// parseDuration parses a duration, or a number of seconds.
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		s = fmt.Sprintf("%gs", f)
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("duration %q is not positive", s)
+	}
+	return d, err
+}
+
+// unescape replaces the escapes \r, \n, \t, \e (escape), \xHH and \\ in s.
+func unescape(s string) ([]byte, error) {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return nil, fmt.Errorf("%q ends with \\", s)
+		}
+		switch s[i] {
+		case 'r':
+			b = append(b, '\r')
+		case 'n':
+			b = append(b, '\n')
+		case 't':
+			b = append(b, '\t')
+		case 'e':
+			b = append(b, 0x1b)
+		case '\\':
+			b = append(b, '\\')
+		case 'x':
+			if i+2 >= len(s) {
+				return nil, fmt.Errorf("%q: short \\x escape", s)
+			}
+			c, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("%q: bad \\x escape", s)
+			}
+			b = append(b, byte(c))
+			i += 2
+		default:
+			return nil, fmt.Errorf("%q: unknown escape \\%c", s, s[i])
+		}
+	}
+	return b, nil
+}
+
+// session is a program that a script interacts with.
+type session struct {
+	// in is the input of the program.
+	in io.Writer
+	// out gets the output of the program, and is closed when it ends.
+	out <-chan []byte
+
+	timeout time.Duration
+	buf     []byte
+	eof     bool
+}
+
+// run runs the steps of a script.
+func (s *session) run(steps []step) error {
+	for _, st := range steps {
+		var err error
+		switch {
+		case st.send != nil:
+			_, err = s.in.Write(st.send)
+		case st.match != nil:
+			err = s.expect(st.match)
+		case st.timeout > 0:
+			s.timeout = st.timeout
+		case st.sleep > 0:
+			time.Sleep(st.sleep)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", st.line, st.text, err)
+		}
+	}
+	return nil
+}
+
+// errTimeout is returned when the output does not match in time.
+var errTimeout = errors.New("timed out")
+
+// expect waits for the output to match, and drops it up to the end of the
+// match, so the next step matches what comes after.
+func (s *session) expect(match func([]byte) (int, bool)) error {
+	t := time.NewTimer(s.timeout)
+	defer t.Stop()
+	for {
+		if end, ok := match(s.buf); ok {
+			s.buf = s.buf[end:]
+			return nil
+		}
+		if s.eof {
+			return io.EOF
+		}
+		select {
+		case b, ok := <-s.out:
+			if !ok {
+				s.eof = true
+				continue
+			}
+			s.buf = append(s.buf, b...)
+			if len(s.buf) > maxBuffered {
+				s.buf = s.buf[len(s.buf)-maxBuffered:]
+			}
+		case <-t.C:
+			return fmt.Errorf("%w after %v", errTimeout, s.timeout)
+		}
+	}
+}
+
+// wait waits for the output to end.
+func (s *session) wait() error {
+	t := time.NewTimer(s.timeout)
+	defer t.Stop()
+	for !s.eof {
+		select {
+		case _, ok := <-s.out:
+			s.eof = !ok
+		case <-t.C:
+			return fmt.Errorf("program did not exit: %w after %v", errTimeout, s.timeout)
+		}
+	}
+	return nil
+}
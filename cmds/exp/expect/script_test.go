@@ -0,0 +1,165 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnescape(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: `plain`, want: "plain"},
+		{in: `a\r\n\tb`, want: "a\r\n\tb"},
+		{in: `\e[A\x03\\`, want: "\x1b[A\x03\\"},
+		{in: `\x0`, err: true},
+		{in: `\xzz`, err: true},
+		{in: `\q`, err: true},
+		{in: `trailing\`, err: true},
+	} {
+		got, err := unescape(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("unescape(%q) = %v, want error %t", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && string(got) != tt.want {
+			t.Errorf("unescape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	steps, err := parse(strings.NewReader(`
+# log in
+@30
+<login: *$
+>root
+>>\x03
+<<#
+:0.5
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 6 {
+		t.Fatalf("%d steps, want 6", len(steps))
+	}
+	if steps[0].timeout != 30*time.Second || steps[0].line != 3 {
+		t.Errorf("step 0 = %+v, want timeout 30s on line 3", steps[0])
+	}
+	if string(steps[2].send) != "root\r" || string(steps[3].send) != "\x03" {
+		t.Errorf("sends = %q, %q, want %q, %q", steps[2].send, steps[3].send, "root\r", "\x03")
+	}
+	if steps[5].sleep != 500*time.Millisecond {
+		t.Errorf("sleep = %v, want 500ms", steps[5].sleep)
+	}
+
+	for _, bad := range []string{"hello", "<(", "@soon", ":-1", `>\q`} {
+		if _, err := parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("parse(%q) = nil, want error", bad)
+		}
+	}
+}
+
+// fake is a program that replies to its input as replies says. It exits on
+// other input, or when its input is closed.
+func fake(t *testing.T, banner string, replies map[string]string) *session {
+	t.Helper()
+	out := make(chan []byte, 16)
+	ir, iw := io.Pipe()
+	out <- []byte(banner)
+	go func() {
+		defer close(out)
+		b := make([]byte, 64)
+		for {
+			n, err := ir.Read(b)
+			if err != nil {
+				return
+			}
+			r, ok := replies[string(b[:n])]
+			if !ok {
+				return
+			}
+			out <- []byte(r)
+		}
+	}()
+	t.Cleanup(func() { iw.Close() })
+	return &session{in: iw, out: out, timeout: time.Second}
+}
+
+func TestSession(t *testing.T) {
+	replies := map[string]string{
+		"admin\r":  "Password: ",
+		"secret\r": "Welcome\r\n> ",
+		"flash\r":  "Continue? [y/N] ",
+		"y":        "Flashing...\r\nFlash complete\r\n",
+	}
+	for _, tt := range []struct {
+		name   string
+		script string
+		err    error
+	}{
+		{
+			name: "ok",
+			script: `<ogin: $
+>admin
+<<Password:
+>secret
+<<> 
+>flash
+<Continue\? \[y/N\]
+>>y
+<Flash (complete|failed)
+`,
+		},
+		{
+			name:   "timeout",
+			script: "@0.1\n<never",
+			err:    errTimeout,
+		},
+		{
+			name:   "exited",
+			script: ">bogus\n<never",
+			err:    io.EOF,
+		},
+		{
+			// The banner was matched, so it does not match again.
+			name:   "matched once",
+			script: "@0.1\n<<login\n<<login",
+			err:    errTimeout,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := parse(strings.NewReader(tt.script))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := fake(t, "login: ", replies)
+			if err := s.run(steps); !errors.Is(err, tt.err) {
+				t.Fatalf("run = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil {
+				s.in.(io.Closer).Close()
+				if err := s.wait(); err != nil {
+					t.Errorf("wait = %v", err)
+				}
+				if !bytes.Contains(s.buf, []byte("\r\n")) {
+					t.Errorf("rest of output = %q, want the end of the line", s.buf)
+				}
+			}
+		})
+	}
+}
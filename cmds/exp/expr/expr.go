@@ -0,0 +1,450 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// expr evaluates an expression.
+//
+// Synopsis:
+//
+//	expr EXPRESSION
+//
+// Description:
+//
+//	expr prints the value of EXPRESSION, whose operators and operands are
+//	separate arguments. It exits with 0 if the value is neither empty nor
+//	0, 1 if it is, 2 if EXPRESSION is invalid and 3 for other errors.
+//
+//	The operators, from the lowest precedence to the highest, are:
+//
+//	A | B       A if it is neither empty nor 0, else B if it is not, else 0
+//	A & B       A if neither A nor B is empty or 0, else 0
+//	A < B       1 if A is less than B, else 0; as numbers if both are
+//	            integers, else as strings; also <=, =, !=, >= and >
+//	A + B       the sum of the integers A and B; also -
+//	A * B       the product of the integers A and B; also / and %
+//	A : RE      the text that the first \(...\) of the basic regular
+//	            expression RE matches at the start of A, or the length of
+//	            the match if there is none
+//	match A RE  the same as A : RE
+//	substr A POS LENGTH
+//	            LENGTH characters of A from POS, counting from 1
+//	index A CHARS
+//	            the position of the first character of A that is in CHARS,
+//	            or 0
+//	length A    the length of A
+//	+ TOKEN     TOKEN, even if it is an operator
+//	( EXPRESSION )
+//
+//	Shells expand some of the operators, which must be quoted.
+//
+// Examples:
+//
+//	% expr 3 + 4 \* 2
+//	11
+//	% expr abc.tar.gz : '\(.*\)\.tar\.gz'
+//	abc
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// syntaxError is an invalid expression.
+type syntaxError struct {
+	msg string
+}
+
+func (e *syntaxError) Error() string {
+	return "syntax error: " + e.msg
+}
+
+type parser struct {
+	args []string
+}
+
+func (p *parser) peek() (string, bool) {
+	if len(p.args) == 0 {
+		return "", false
+	}
+	return p.args[0], true
+}
+
+func (p *parser) next() (string, error) {
+	if len(p.args) == 0 {
+		return "", &syntaxError{"missing argument"}
+	}
+	a := p.args[0]
+	p.args = p.args[1:]
+	return a, nil
+}
+
+// accept takes the next argument if it is one of ops.
+func (p *parser) accept(ops ...string) (string, bool) {
+	a, ok := p.peek()
+	if !ok {
+		return "", false
+	}
+	for _, op := range ops {
+		if a == op {
+			p.args = p.args[1:]
+			return a, true
+		}
+	}
+	return "", false
+}
+
+func isNull(s string) bool {
+	if s == "" {
+		return true
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && n == 0
+}
+
+func integer(s string) (int64, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
+
+func boolean(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func (p *parser) or() (string, error) {
+	l, err := p.and()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, ok := p.accept("|"); !ok {
+			return l, nil
+		}
+		r, err := p.and()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case !isNull(l):
+		case !isNull(r):
+			l = r
+		default:
+			l = "0"
+		}
+	}
+}
+
+func (p *parser) and() (string, error) {
+	l, err := p.compare()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, ok := p.accept("&"); !ok {
+			return l, nil
+		}
+		r, err := p.compare()
+		if err != nil {
+			return "", err
+		}
+		if isNull(l) || isNull(r) {
+			l = "0"
+		}
+	}
+}
+
+func (p *parser) compare() (string, error) {
+	l, err := p.add()
+	if err != nil {
+		return "", err
+	}
+	for {
+		op, ok := p.accept("<", "<=", "=", "==", "!=", ">=", ">")
+		if !ok {
+			return l, nil
+		}
+		r, err := p.add()
+		if err != nil {
+			return "", err
+		}
+		var c int
+		a, aok := integer(l)
+		b, bok := integer(r)
+		switch {
+		case aok && bok && a < b:
+			c = -1
+		case aok && bok && a > b:
+			c = 1
+		case aok && bok:
+		default:
+			c = strings.Compare(l, r)
+		}
+		switch op {
+		case "<":
+			l = boolean(c < 0)
+		case "<=":
+			l = boolean(c <= 0)
+		case "=", "==":
+			l = boolean(c == 0)
+		case "!=":
+			l = boolean(c != 0)
+		case ">=":
+			l = boolean(c >= 0)
+		case ">":
+			l = boolean(c > 0)
+		}
+	}
+}
+
+// arith applies the arithmetic operator op.
+func arith(op, l, r string) (string, error) {
+	a, aok := integer(l)
+	b, bok := integer(r)
+	if !aok || !bok {
+		return "", errors.New("non-integer argument")
+	}
+	var n int64
+	switch op {
+	case "+":
+		n = a + b
+	case "-":
+		n = a - b
+	case "*":
+		n = a * b
+	case "/", "%":
+		if b == 0 {
+			return "", errors.New("division by zero")
+		}
+		if n = a / b; op == "%" {
+			n = a % b
+		}
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+func (p *parser) add() (string, error) {
+	l, err := p.mul()
+	if err != nil {
+		return "", err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return l, nil
+		}
+		r, err := p.mul()
+		if err != nil {
+			return "", err
+		}
+		if l, err = arith(op, l, r); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (p *parser) mul() (string, error) {
+	l, err := p.match()
+	if err != nil {
+		return "", err
+	}
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return l, nil
+		}
+		r, err := p.match()
+		if err != nil {
+			return "", err
+		}
+		if l, err = arith(op, l, r); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (p *parser) match() (string, error) {
+	l, err := p.primary()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, ok := p.accept(":"); !ok {
+			return l, nil
+		}
+		r, err := p.primary()
+		if err != nil {
+			return "", err
+		}
+		if l, err = match(l, r); err != nil {
+			return "", err
+		}
+	}
+}
+
+// bre translates the basic regular expression re into a Go one.
+func bre(re string) string {
+	var b strings.Builder
+	for i := 0; i < len(re); i++ {
+		c := re[i]
+		switch {
+		case c == '\\' && i+1 < len(re):
+			i++
+			switch c := re[i]; c {
+			case '(', ')', '{', '}', '+', '?', '|':
+				b.WriteByte(c)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			}
+		case strings.IndexByte("(){}+?|", c) >= 0:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '*' && (i == 0 || re[i-1] == '^' && i == 1):
+			// A leading * is literal.
+			b.WriteString(`\*`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func match(s, re string) (string, error) {
+	r, err := regexp.Compile("^(?:" + bre(strings.TrimPrefix(re, "^")) + ")")
+	if err != nil {
+		return "", fmt.Errorf("invalid regular expression %q: %v", re, err)
+	}
+	// Basic regular expressions match the longest text.
+	r.Longest()
+	m := r.FindStringSubmatchIndex(s)
+	if r.NumSubexp() > 0 {
+		if m == nil || m[2] < 0 {
+			return "", nil
+		}
+		return s[m[2]:m[3]], nil
+	}
+	if m == nil {
+		return "0", nil
+	}
+	return strconv.Itoa(len([]rune(s[:m[1]]))), nil
+}
+
+func (p *parser) primary() (string, error) {
+	a, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	switch a {
+	case "(":
+		v, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return "", &syntaxError{"expecting ')'"}
+		}
+		return v, nil
+	case "+":
+		return p.next()
+	case "match":
+		s, re, err := p.two()
+		if err != nil {
+			return "", err
+		}
+		return match(s, re)
+	case "substr":
+		s, pos, err := p.two()
+		if err != nil {
+			return "", err
+		}
+		length, err := p.primary()
+		if err != nil {
+			return "", err
+		}
+		r := []rune(s)
+		i, iok := integer(pos)
+		n, nok := integer(length)
+		if !iok || !nok || i < 1 || n < 1 || i > int64(len(r)) {
+			return "", nil
+		}
+		if i-1+n > int64(len(r)) {
+			n = int64(len(r)) - i + 1
+		}
+		return string(r[i-1 : i-1+n]), nil
+	case "index":
+		s, chars, err := p.two()
+		if err != nil {
+			return "", err
+		}
+		for i, c := range []rune(s) {
+			if strings.ContainsRune(chars, c) {
+				return strconv.Itoa(i + 1), nil
+			}
+		}
+		return "0", nil
+	case "length":
+		s, err := p.primary()
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(len([]rune(s))), nil
+	case ")":
+		return "", &syntaxError{"unexpected ')'"}
+	}
+	return a, nil
+}
+
+func (p *parser) two() (string, string, error) {
+	a, err := p.primary()
+	if err != nil {
+		return "", "", err
+	}
+	b, err := p.primary()
+	if err != nil {
+		return "", "", err
+	}
+	return a, b, nil
+}
+
+func eval(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", &syntaxError{"missing operand"}
+	}
+	p := &parser{args: args}
+	v, err := p.or()
+	if err != nil {
+		return "", err
+	}
+	if a, ok := p.peek(); ok {
+		return "", &syntaxError{fmt.Sprintf("unexpected argument %q", a)}
+	}
+	return v, nil
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	v, err := eval(args)
+	if err != nil {
+		log.Printf("expr: %v", err)
+		var e *syntaxError
+		if errors.As(err, &e) {
+			os.Exit(2)
+		}
+		os.Exit(3)
+	}
+	fmt.Println(v)
+	if isNull(v) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	for _, tt := range []struct {
+		expr string
+		want string
+	}{
+		{"3 + 4 * 2", "11"},
+		{"( 3 + 4 ) * 2", "14"},
+		{"10 / 3", "3"},
+		{"-10 % 3", "-1"},
+		{"5 - 7 - 1", "-3"},
+		{"2 < 10", "1"},
+		{"a < b", "1"},
+		{"2 < 10a", "0"},
+		{"abc = abc", "1"},
+		{"abc != abc", "0"},
+		{"3 >= 3", "1"},
+		{"0 | b", "b"},
+		{"a | b", "a"},
+		{"0 | 0", "0"},
+		{"a & b", "a"},
+		{"a & 0", "0"},
+		{"abc.tar.gz : \\(.*\\)\\.tar\\.gz", "abc"},
+		{"abcd : ab", "2"},
+		{"abcd : b", "0"},
+		{"abcd : x\\(.*\\)", ""},
+		{"aaa : a\\{2\\}", "2"},
+		{"a+b : a+", "2"},
+		{"match foo f\\(o*\\)", "oo"},
+		{"substr hello 2 3", "ell"},
+		{"substr hello 4 10", "lo"},
+		{"substr hello 0 2", ""},
+		{"index hello lo", "3"},
+		{"index hello z", "0"},
+		{"length hello", "5"},
+		{"length + length", "6"},
+		{"+ match", "match"},
+		{"= : =", "1"},
+	} {
+		got, err := eval(strings.Fields(tt.expr))
+		if err != nil || got != tt.want {
+			t.Errorf("expr %s = %q, %v, want %q", tt.expr, got, err, tt.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tt := range []struct {
+		expr   string
+		syntax bool
+	}{
+		{"", true},
+		{"1 +", true},
+		{"( 1 + 2", true},
+		{"1 2", true},
+		{")", true},
+		{"substr a 1", true},
+		{"a + 1", false},
+		{"1 / 0", false},
+		{"1 % 0", false},
+		{"a : \\(", false},
+	} {
+		_, err := eval(strings.Fields(tt.expr))
+		var e *syntaxError
+		if err == nil || errors.As(err, &e) != tt.syntax {
+			t.Errorf("expr %s = %v, want an error, syntax error: %v", tt.expr, err, tt.syntax)
+		}
+	}
+}
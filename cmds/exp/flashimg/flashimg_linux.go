@@ -0,0 +1,281 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// flashimg writes a disk image from a URL to a block device.
+//
+// Synopsis:
+//
+//	flashimg [-sha256 HEX | -sha512 HEX] [-z auto|none|gz|xz|zst] [-sparse]
+//	         [-grow] [-t TRIES] [-progress] URL DEVICE
+//
+// Description:
+//
+//	flashimg replaces wget | gunzip | dd pipelines: the image at URL is
+//	fetched, decompressed on the fly, written to DEVICE and synced, and
+//	then read back, bypassing the page cache, to check that DEVICE holds
+//	what was written. The regions that do not read back are reported.
+//
+//	URL may be any URL wget takes, e.g. https://, tftp:// or file://.
+//	Images compressed with gzip, xz or zstd are decompressed, as told by
+//	their first bytes, or as -z says.
+//
+//	With -sha256 or -sha512, the file at URL, compressed or not, must have
+//	the digest HEX. As the image is written while it is fetched, DEVICE
+//	holds an unverified image if it does not: flashimg then fails.
+//
+//	With -sparse, the blocks of zeros of the image are not written, which
+//	is faster for images of mostly empty file systems. DEVICE must read as
+//	zeros there, e.g. after blkdiscard on devices that zero discarded
+//	blocks: the verification fails otherwise.
+//
+//	With -grow, the last partition of the GPT of the image is grown to
+//	the end of DEVICE, and the backup GPT moved there, for images smaller
+//	than the disk. Its file system is then grown with e2fsck and resize2fs,
+//	if they are installed, otherwise that is left to do.
+//
+// Options:
+//
+//	-sha256:   SHA-256 of the file at URL, in hex
+//	-sha512:   SHA-512 of the file at URL, in hex
+//	-z:        compression of the image (default auto)
+//	-sparse:   do not write the blocks of zeros of the image
+//	-grow:     grow the last partition to the end of DEVICE
+//	-t:        number of attempts to fetch URL
+//	-progress: print the progress of the download to stderr
+//
+// Example:
+//
+//	flashimg -sha256 9f86d0...0a08 -grow -progress https://images.example.com/node.img.zst /dev/nvme0n1
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"unicode"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/u-root/u-root/pkg/curl"
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/uio"
+	"github.com/u-root/u-root/pkg/vfile"
+	"github.com/ulikunitz/xz"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	sha256Hex   = flag.String("sha256", "", "SHA-256 of the file at URL, in hex")
+	sha512Hex   = flag.String("sha512", "", "SHA-512 of the file at URL, in hex")
+	compression = flag.String("z", "auto", "compression of the image: auto, none, gz, xz or zst")
+	sparse      = flag.Bool("sparse", false, "do not write the blocks of zeros of the image")
+	grow        = flag.Bool("grow", false, "grow the last partition to the end of DEVICE")
+	tries       = flag.Int("t", 1, "number of attempts to fetch URL, retrying timeouts and server errors")
+	progress    = flag.Bool("progress", false, "print the progress of the download to stderr")
+
+	errUsage = errors.New("usage: flashimg [options] URL DEVICE")
+)
+
+// magics are the first bytes of compressed images.
+var magics = []struct {
+	name  string
+	magic []byte
+}{
+	{"gz", []byte{0x1f, 0x8b}},
+	{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0}},
+	{"zst", []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// decompress returns the image in r, decompressed as z says, or as its first
+// bytes say if z is auto.
+func decompress(r io.Reader, z string) (io.Reader, error) {
+	if z == "auto" {
+		br := bufio.NewReader(r)
+		r, z = br, "none"
+		for _, m := range magics {
+			if b, err := br.Peek(len(m.magic)); err == nil && bytes.Equal(b, m.magic) {
+				z = m.name
+				break
+			}
+		}
+	}
+	switch z {
+	case "none":
+		return r, nil
+	case "gz":
+		return pgzip.NewReader(r)
+	case "xz":
+		return xz.NewReader(r)
+	case "zst":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unknown compression %q, want auto, none, gz, xz or zst", z)
+}
+
+// verifying returns r, which returns an error rather than io.EOF if it does
+// not have the digests of the flags.
+func verifying(r io.Reader, name string) (io.Reader, error) {
+	for _, d := range []struct {
+		flag string
+		hex  string
+		h    crypto.Hash
+	}{
+		{"-sha256", *sha256Hex, crypto.SHA256},
+		{"-sha512", *sha512Hex, crypto.SHA512},
+	} {
+		if d.hex == "" {
+			continue
+		}
+		want, err := hex.DecodeString(d.hex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", d.flag, err)
+		}
+		if r, err = vfile.NewVerifyingReader(r, name, d.h, want); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// fetch starts fetching the file at rawURL.
+func fetch(rawURL string) (io.Reader, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	schemes := curl.DefaultSchemes.WithHTTPClient(curl.DefaultHTTPClient)
+	if *progress {
+		schemes = schemes.WithProgress(func(*url.URL) curl.ProgressFunc {
+			return curl.TextProgress(os.Stderr, filepath.Base(u.Path))
+		})
+	}
+	if *tries > 1 {
+		p := curl.DefaultRetryPolicy
+		p.MaxAttempts = *tries
+		schemes = schemes.WithRetries(p)
+	}
+	return schemes.FetchWithoutCache(context.Background(), u)
+}
+
+// sectorSize returns the logical sector size of f, or 512 if it is not a
+// block device.
+func sectorSize(f *os.File) uint64 {
+	if n, err := unix.IoctlGetInt(int(f.Fd()), unix.BLKSSZGET); err == nil && n > 0 {
+		return uint64(n)
+	}
+	return 512
+}
+
+// partitionPath returns the path of partition n, from 1, of the disk at dev:
+// sda3, or nvme0n1p3 for disks whose names end with a digit.
+func partitionPath(dev string, n int) string {
+	if r := []rune(dev); len(r) > 0 && unicode.IsDigit(r[len(r)-1]) {
+		return fmt.Sprintf("%sp%d", dev, n)
+	}
+	return fmt.Sprintf("%s%d", dev, n)
+}
+
+// growFS grows the ext2, ext3 or ext4 file system of the partition at part
+// to the end of the partition, if e2fsck and resize2fs are installed.
+func growFS(part string) error {
+	for _, tool := range []string{"e2fsck", "resize2fs"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			log.Printf("%s is not installed: grow the file system of %s", tool, part)
+			return nil
+		}
+	}
+	for _, args := range [][]string{{"e2fsck", "-f", "-p", part}, {"resize2fs", part}} {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %v", args[0], err)
+		}
+	}
+	return nil
+}
+
+// growDevice grows the last partition of the disk at dev, and its file
+// system.
+func growDevice(dev string) error {
+	f, err := os.OpenFile(dev, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, _, err := block.GrowGPTPartition(f, sectorSize(f), 0)
+	if err == block.ErrNoChange {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Grew partition %d to the end of %s", n, dev)
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeDevice == 0 {
+		return err
+	}
+	if err := unix.IoctlSetInt(int(f.Fd()), unix.BLKRRPART, 0); err != nil {
+		return fmt.Errorf("re-reading the partition table of %s: %v", dev, err)
+	}
+	return growFS(partitionPath(dev, n))
+}
+
+func run(rawURL, dev string) error {
+	r, err := fetch(rawURL)
+	if err != nil {
+		return err
+	}
+	if r, err = verifying(r, rawURL); err != nil {
+		return err
+	}
+	img, err := decompress(r, *compression)
+	if err != nil {
+		return err
+	}
+	sum, n, err := uio.WriteAndVerify(img, uio.WriteVerifyOpts{Sparse: *sparse}, dev)
+	if err != nil {
+		return err
+	}
+	// The digest is checked once the whole file was read.
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return fmt.Errorf("%s holds an unverified image: %v", dev, err)
+	}
+	log.Printf("Wrote %d bytes to %s, SHA-256 %x", n, dev, sum)
+	if *grow {
+		return growDevice(dev)
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("flashimg: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, errUsage)
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), flag.Arg(1)); err != nil {
+		log.Fatal(err)
+	}
+}
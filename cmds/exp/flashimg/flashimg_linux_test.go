@@ -0,0 +1,130 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func compress(t *testing.T, z string, b []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch z {
+	case "none":
+		return b
+	case "gz":
+		w = gzip.NewWriter(&buf)
+	case "xz":
+		w, err = xz.NewWriter(&buf)
+	case "zst":
+		w, err = zstd.NewWriter(&buf)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	img := bytes.Repeat([]byte("u-root image "), 1000)
+	for _, z := range []string{"none", "gz", "xz", "zst"} {
+		t.Run(z, func(t *testing.T) {
+			for _, flag := range []string{"auto", z} {
+				r, err := decompress(bytes.NewReader(compress(t, z, img)), flag)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, img) {
+					t.Errorf("decompress(-z %s) = %d bytes, want the %d bytes of the image", flag, len(got), len(img))
+				}
+			}
+		})
+	}
+	if _, err := decompress(bytes.NewReader(img), "bz2"); err == nil {
+		t.Errorf("decompress(-z bz2) = nil, want error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	img := append(bytes.Repeat([]byte{0xaa}, 1<<20), make([]byte, 3<<20)...)
+	src := filepath.Join(dir, "disk.img.xz")
+	if err := os.WriteFile(src, compress(t, "xz", img), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(b)
+
+	for _, tt := range []struct {
+		name   string
+		sha256 string
+		sparse bool
+		ok     bool
+	}{
+		{name: "digest", sha256: hex.EncodeToString(sum[:]), ok: true},
+		{name: "sparse", sparse: true, ok: true},
+		{name: "bad digest", sha256: hex.EncodeToString(make([]byte, sha256.Size))},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			*sha256Hex, *sparse = tt.sha256, tt.sparse
+			defer func() { *sha256Hex, *sparse = "", false }()
+
+			dev := filepath.Join(t.TempDir(), "disk")
+			if err := os.WriteFile(dev, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := run("file://"+src, dev)
+			if (err == nil) != tt.ok {
+				t.Fatalf("run = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			got, err := os.ReadFile(dev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, img) {
+				t.Errorf("%s holds %d bytes, not the %d bytes of the image", dev, len(got), len(img))
+			}
+		})
+	}
+}
+
+func TestPartitionPath(t *testing.T) {
+	for dev, want := range map[string]string{
+		"/dev/sda":     "/dev/sda3",
+		"/dev/nvme0n1": "/dev/nvme0n1p3",
+		"/dev/mmcblk0": "/dev/mmcblk0p3",
+	} {
+		if got := partitionPath(dev, 3); got != want {
+			t.Errorf("partitionPath(%q, 3) = %q, want %q", dev, got, want)
+		}
+	}
+}
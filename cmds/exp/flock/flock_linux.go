@@ -0,0 +1,205 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// flock runs a command with a file lock held.
+//
+// Synopsis:
+//
+//	flock [OPTIONS] FILE|DIRECTORY COMMAND [ARG]...
+//	flock [OPTIONS] FILE|DIRECTORY -c COMMAND
+//	flock [OPTIONS] FD
+//
+// Description:
+//
+//	flock locks FILE, which is made if it does not exist, or DIRECTORY with
+//	flock(2), runs COMMAND, and unlocks it when COMMAND exits. COMMAND
+//	inherits the lock, so commands that it starts in the background hold
+//	it until they exit too, unless -o is given. flock exits with the exit
+//	status of COMMAND.
+//
+//	In the third form, flock locks the file open as FD, which is how
+//	shell scripts lock a part of themselves:
+//
+//	(
+//		flock -n 9 || exit 1
+//		...
+//	) 9>/var/lock/script
+//
+//	With -n, flock runs COMMAND only if no other command holds the lock,
+//	which keeps two copies of a hook script from running at once.
+//
+// Options:
+//
+//	-x, -e: take an exclusive lock (the default)
+//	-s:     take a shared lock
+//	-u:     unlock; only useful with FD
+//	-n:     fail, rather than wait, if the lock is held
+//	-w:     fail if the lock cannot be taken within this many seconds
+//	-E:     exit with this status when the lock cannot be taken (default 1)
+//	-o:     do not let COMMAND inherit the lock
+//	-c:     run COMMAND with sh -c
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var errUsage = errors.New("usage: flock [OPTIONS] FILE|DIRECTORY COMMAND [ARG]... | FILE|DIRECTORY -c COMMAND | FD")
+
+// errConflict means that the lock is held by another.
+var errConflict = errors.New("failed to get lock")
+
+type options struct {
+	how      int
+	nonblock bool
+	timeout  time.Duration
+	closeFD  bool
+}
+
+// pollInterval is how often a lock is tried with a timeout.
+var pollInterval = 50 * time.Millisecond
+
+// lock locks fd as o says.
+func lock(fd int, o options) error {
+	how := o.how
+	if o.nonblock || o.timeout > 0 {
+		how |= unix.LOCK_NB
+	}
+	deadline := time.Now().Add(o.timeout)
+	for {
+		err := unix.Flock(fd, how)
+		switch {
+		case err == nil:
+			return nil
+		case err == unix.EINTR:
+			continue
+		case err != unix.EWOULDBLOCK:
+			return err
+		case o.nonblock || !time.Now().Before(deadline):
+			return errConflict
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// open opens name for locking, making it if need be.
+func open(name string) (*os.File, error) {
+	f, err := os.OpenFile(name, os.O_RDONLY|os.O_CREATE|unix.O_NOCTTY, 0o666)
+	if err != nil {
+		// Directories cannot be opened with O_CREATE, and read-only files
+		// that exist need not be.
+		if f, err2 := os.Open(name); err2 == nil {
+			return f, nil
+		}
+	}
+	return f, err
+}
+
+// run locks the file of args, and runs the command of args. It returns the
+// exit status of the command.
+func run(o options, args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, errUsage
+	}
+	if len(args) == 1 {
+		fd, err := strconv.Atoi(args[0])
+		if err != nil || fd < 0 {
+			return 0, errUsage
+		}
+		return 0, lock(fd, o)
+	}
+
+	if o.how == unix.LOCK_UN {
+		return 0, fmt.Errorf("-u needs an FD")
+	}
+	command := args[1:]
+	if command[0] == "-c" {
+		if len(command) != 2 {
+			return 0, errUsage
+		}
+		command = []string{"sh", "-c", command[1]}
+	}
+	f, err := open(args[0])
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := lock(int(f.Fd()), o); err != nil {
+		return 0, err
+	}
+
+	c := exec.Command(command[0], command[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if !o.closeFD {
+		c.ExtraFiles = []*os.File{f}
+	}
+	if err := c.Run(); err != nil {
+		var e *exec.ExitError
+		if errors.As(err, &e) {
+			if code := e.ExitCode(); code >= 0 {
+				return code, nil
+			}
+			return 1, err
+		}
+		return 0, err
+	}
+	return 0, nil
+}
+
+func main() {
+	var o options
+	var shared, exclusive, unlock bool
+	var wait float64
+	flag.BoolVar(&shared, "s", false, "take a shared lock")
+	flag.BoolVar(&exclusive, "x", false, "take an exclusive lock (the default)")
+	flag.BoolVar(&exclusive, "e", false, "take an exclusive lock (the default)")
+	flag.BoolVar(&unlock, "u", false, "unlock")
+	flag.BoolVar(&o.nonblock, "n", false, "fail if the lock is held")
+	flag.Float64Var(&wait, "w", 0, "fail if the lock cannot be taken within this many seconds")
+	conflict := flag.Int("E", 1, "exit with this status when the lock cannot be taken")
+	flag.BoolVar(&o.closeFD, "o", false, "do not let the command inherit the lock")
+	flag.Parse()
+
+	o.how = unix.LOCK_EX
+	switch {
+	case unlock:
+		o.how = unix.LOCK_UN
+	case shared:
+		o.how = unix.LOCK_SH
+	}
+	switch {
+	case wait < 0:
+		log.Fatalf("flock: invalid timeout %v", wait)
+	case wait > 0:
+		o.timeout = time.Duration(wait * float64(time.Second))
+	case isSet("w"):
+		o.nonblock = true
+	}
+
+	code, err := run(o, flag.Args())
+	if err == errConflict {
+		os.Exit(*conflict)
+	}
+	if err != nil {
+		log.Fatalf("flock: %v", err)
+	}
+	os.Exit(code)
+}
+
+func isSet(name string) bool {
+	var set bool
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLock(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "lock")
+	held, err := open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	if err := lock(int(held.Fd()), options{how: unix.LOCK_SH}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Locks of other open files conflict, even in the same process.
+	f, err := open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fd := int(f.Fd())
+	if err := lock(fd, options{how: unix.LOCK_EX, nonblock: true}); err != errConflict {
+		t.Errorf("exclusive lock of a shared lock = %v, want %v", err, errConflict)
+	}
+	start := time.Now()
+	if err := lock(fd, options{how: unix.LOCK_EX, timeout: 200 * time.Millisecond}); err != errConflict || time.Since(start) < 200*time.Millisecond {
+		t.Errorf("lock with timeout = %v after %v, want %v after the timeout", err, time.Since(start), errConflict)
+	}
+	if err := lock(fd, options{how: unix.LOCK_SH, nonblock: true}); err != nil {
+		t.Errorf("shared lock of a shared lock: %v", err)
+	}
+	lock(fd, options{how: unix.LOCK_UN})
+
+	// The lock is taken once the holder lets go.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		lock(int(held.Fd()), options{how: unix.LOCK_UN})
+	}()
+	if err := lock(fd, options{how: unix.LOCK_EX, timeout: 10 * time.Second}); err != nil {
+		t.Errorf("lock after unlock: %v", err)
+	}
+
+	// The FD form.
+	if _, err := run(options{how: unix.LOCK_UN}, []string{strconv.Itoa(fd)}); err != nil {
+		t.Errorf("flock -u %d: %v", fd, err)
+	}
+	if _, err := run(options{how: unix.LOCK_EX, nonblock: true}, []string{strconv.Itoa(int(held.Fd()))}); err != nil {
+		t.Errorf("flock -n %d: %v", held.Fd(), err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	dir := t.TempDir()
+	name := filepath.Join(dir, "lock")
+
+	if code, err := run(options{how: unix.LOCK_EX}, []string{name, "-c", "exit 3"}); code != 3 || err != nil {
+		t.Errorf("flock -c 'exit 3' = %d, %v, want 3, nil", code, err)
+	}
+	if _, err := os.Stat(name); err != nil {
+		t.Errorf("lock file was not made: %v", err)
+	}
+	// Directories can be locked too.
+	if code, err := run(options{how: unix.LOCK_SH}, []string{dir, "true"}); code != 0 || err != nil {
+		t.Errorf("flock DIR true = %d, %v", code, err)
+	}
+
+	// Only one copy runs at a time.
+	held, err := open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	if err := lock(int(held.Fd()), options{how: unix.LOCK_EX}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(options{how: unix.LOCK_EX, nonblock: true}, []string{name, "true"}); err != errConflict {
+		t.Errorf("flock -n of a held lock = %v, want %v", err, errConflict)
+	}
+
+	// The command inherits the lock as fd 3, unless -o is given.
+	if code, _ := run(options{how: unix.LOCK_SH}, []string{dir, "-c", "test -e /proc/self/fd/3"}); code != 0 {
+		t.Errorf("the command did not inherit the lock")
+	}
+	if code, _ := run(options{how: unix.LOCK_SH, closeFD: true}, []string{dir, "-c", "test -e /proc/self/fd/3"}); code == 0 {
+		t.Errorf("the command inherited the lock with -o")
+	}
+
+	for _, args := range [][]string{nil, {"x"}, {name, "-c"}, {name, "-c", "a", "b"}} {
+		if _, err := run(options{how: unix.LOCK_EX}, args); err != errUsage {
+			t.Errorf("run(%q) = %v, want %v", args, err, errUsage)
+		}
+	}
+	if _, err := run(options{how: unix.LOCK_UN}, []string{name, "true"}); err == nil {
+		t.Errorf("flock -u FILE COMMAND succeeded")
+	}
+}
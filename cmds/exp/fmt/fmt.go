@@ -0,0 +1,155 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// fmt fills and wraps paragraphs of text.
+//
+// Synopsis:
+//
+//	fmt [-s] [-w WIDTH] [FILE]...
+//
+// Description:
+//
+//	fmt joins the lines of each paragraph of each FILE, or stdin if there
+//	is none or a FILE is "-", and breaks them again so that no line is
+//	wider than WIDTH columns, unless a word is. Paragraphs end at blank
+//	lines and where the indentation changes; their indentation is kept.
+//	Words are separated by one space.
+//
+// Options:
+//
+//	-s: only break long lines, do not join short ones
+//	-w: the width (default 75)
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+type formatter struct {
+	width int
+	split bool
+}
+
+// indentation returns the leading blanks of line.
+func indentation(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// columns returns the width of s, with tabs to multiples of 8.
+func columns(s string) int {
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			col += 8 - col%8
+		} else {
+			col++
+		}
+	}
+	return col
+}
+
+// fill writes words as lines with indent no wider than f.width.
+func (f *formatter) fill(w *bufio.Writer, indent string, words []string) {
+	if len(words) == 0 {
+		return
+	}
+	start := columns(indent)
+	col := 0
+	for i, word := range words {
+		n := utf8.RuneCountInString(word)
+		switch {
+		case i == 0:
+			w.WriteString(indent)
+			col = start
+		case col+1+n > f.width:
+			w.WriteString("\n")
+			w.WriteString(indent)
+			col = start
+		default:
+			w.WriteString(" ")
+			col++
+		}
+		w.WriteString(word)
+		col += n
+	}
+	w.WriteString("\n")
+}
+
+func (f *formatter) format(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	var indent string
+	var words []string
+	flush := func() {
+		f.fill(bw, indent, words)
+		words = nil
+	}
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t")
+		if line == "" {
+			flush()
+			bw.WriteString("\n")
+			continue
+		}
+		if in := indentation(line); in != indent || f.split {
+			flush()
+			indent = in
+		}
+		words = append(words, strings.Fields(line)...)
+	}
+	flush()
+	if err := s.Err(); err != nil {
+		bw.Flush()
+		return err
+	}
+	return bw.Flush()
+}
+
+func run(stdin io.Reader, stdout io.Writer, f *formatter, files []string) error {
+	if f.width < 1 {
+		return fmt.Errorf("invalid width %d", f.width)
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var failed error
+	for _, name := range files {
+		if name == "-" {
+			if err := f.format(stdout, stdin); err != nil {
+				return err
+			}
+			continue
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			log.Printf("fmt: %v", err)
+			failed = fmt.Errorf("some files could not be read")
+			continue
+		}
+		err = f.format(stdout, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return failed
+}
+
+func main() {
+	f := &formatter{}
+	flag.BoolVar(&f.split, "s", false, "only break long lines, do not join short ones")
+	flag.IntVar(&f.width, "w", 75, "the width")
+	flag.Parse()
+	if err := run(os.Stdin, os.Stdout, f, flag.Args()); err != nil {
+		log.Fatalf("fmt: %v", err)
+	}
+}
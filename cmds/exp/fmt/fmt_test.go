@@ -0,0 +1,64 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFmt(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		f    formatter
+		in   string
+		want string
+	}{
+		{
+			name: "join",
+			f:    formatter{width: 20},
+			in:   "the quick\nbrown fox   jumps\nover the lazy dog\n",
+			want: "the quick brown fox\njumps over the lazy\ndog\n",
+		},
+		{
+			name: "paragraphs",
+			f:    formatter{width: 20},
+			in:   "one\ntwo\n\n\nthree\n",
+			want: "one two\n\n\nthree\n",
+		},
+		{
+			name: "indentation",
+			f:    formatter{width: 12},
+			in:   "text here\n    indented text\n    goes on\n",
+			want: "text here\n    indented\n    text\n    goes on\n",
+		},
+		{
+			name: "long word",
+			f:    formatter{width: 5},
+			in:   "a verylongword b\n",
+			want: "a\nverylongword\nb\n",
+		},
+		{
+			name: "split",
+			f:    formatter{width: 10, split: true},
+			in:   "short\none two three four\n",
+			want: "short\none two\nthree four\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := run(strings.NewReader(tt.in), &b, &tt.f, nil); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("fmt = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+	if err := run(nil, nil, &formatter{}, nil); err == nil {
+		t.Errorf("fmt -w 0 succeeded")
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// fold wraps long lines.
+//
+// Synopsis:
+//
+//	fold [-b] [-s] [-w WIDTH] [FILE]...
+//
+// Description:
+//
+//	fold breaks the lines of each FILE, or stdin if there is none or a
+//	FILE is "-", so that none is wider than WIDTH columns. Tabs go to the
+//	next multiple of 8 columns, backspaces go back one and carriage
+//	returns go back to the start.
+//
+// Options:
+//
+//	-b: count bytes rather than columns
+//	-s: break after the last blank that fits, if there is one
+//	-w: the width (default 80)
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"unicode"
+	"unicode/utf8"
+)
+
+type folder struct {
+	width  int
+	bytes  bool
+	spaces bool
+}
+
+// advance returns the column after r at column col.
+func (f *folder) advance(col int, r rune, size int) int {
+	switch {
+	case f.bytes:
+		return col + size
+	case r == '\t':
+		return col + 8 - col%8
+	case r == '\b':
+		if col > 0 {
+			return col - 1
+		}
+		return 0
+	case r == '\r':
+		return 0
+	}
+	return col + 1
+}
+
+// foldLine folds line, which has no newline.
+func (f *folder) foldLine(w *bufio.Writer, line []byte) {
+	col := 0
+	start := 0
+	// blank is just after the last blank of the current piece, or -1.
+	blank := -1
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		next := f.advance(col, r, size)
+		if next > f.width && i > start && r != '\b' && r != '\r' {
+			end := i
+			if f.spaces && blank > start {
+				end = blank
+			}
+			w.Write(line[start:end])
+			w.WriteByte('\n')
+			start, blank, col = end, -1, 0
+			// Recount the columns of what was carried over.
+			for j := start; j < i; {
+				r, size := utf8.DecodeRune(line[j:])
+				col = f.advance(col, r, size)
+				j += size
+			}
+			continue
+		}
+		col = next
+		i += size
+		if unicode.IsSpace(r) {
+			blank = i
+		}
+	}
+	w.Write(line[start:])
+}
+
+func (f *folder) fold(w io.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	for {
+		line, err := br.ReadBytes('\n')
+		nl := len(line) > 0 && line[len(line)-1] == '\n'
+		if nl {
+			line = line[:len(line)-1]
+		}
+		f.foldLine(bw, line)
+		if nl {
+			bw.WriteByte('\n')
+		}
+		if err == io.EOF {
+			return bw.Flush()
+		}
+		if err != nil {
+			bw.Flush()
+			return err
+		}
+	}
+}
+
+func run(stdin io.Reader, stdout io.Writer, f *folder, files []string) error {
+	if f.width < 1 {
+		return fmt.Errorf("invalid width %d", f.width)
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var failed error
+	for _, name := range files {
+		if name == "-" {
+			if err := f.fold(stdout, stdin); err != nil {
+				return err
+			}
+			continue
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			log.Printf("fold: %v", err)
+			failed = fmt.Errorf("some files could not be read")
+			continue
+		}
+		err = f.fold(stdout, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return failed
+}
+
+func main() {
+	f := &folder{}
+	flag.BoolVar(&f.bytes, "b", false, "count bytes rather than columns")
+	flag.BoolVar(&f.spaces, "s", false, "break after the last blank that fits")
+	flag.IntVar(&f.width, "w", 80, "the width")
+	flag.Parse()
+	if err := run(os.Stdin, os.Stdout, f, flag.Args()); err != nil {
+		log.Fatalf("fold: %v", err)
+	}
+}
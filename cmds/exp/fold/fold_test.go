@@ -0,0 +1,46 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		f    folder
+		in   string
+		want string
+	}{
+		{name: "short", f: folder{width: 10}, in: "short\nlines\n", want: "short\nlines\n"},
+		{name: "long", f: folder{width: 4}, in: "abcdefghij\n", want: "abcd\nefgh\nij\n"},
+		{name: "no newline", f: folder{width: 4}, in: "abcdef", want: "abcd\nef"},
+		{name: "spaces", f: folder{width: 10, spaces: true}, in: "the quick brown fox\n", want: "the quick \nbrown fox\n"},
+		{name: "no space", f: folder{width: 4, spaces: true}, in: "abcdef gh\n", want: "abcd\nef \ngh\n"},
+		{name: "tab", f: folder{width: 10}, in: "ab\tcdef\n", want: "ab\tcd\nef\n"},
+		{name: "bytes", f: folder{width: 4, bytes: true}, in: "ab\tcdef\n", want: "ab\tc\ndef\n"},
+		{name: "utf8", f: folder{width: 3}, in: "äöüßé\n", want: "äöü\nßé\n"},
+		{name: "backspace", f: folder{width: 3}, in: "a\bb\bcd\n", want: "a\bb\bcd\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := run(strings.NewReader(tt.in), &b, &tt.f, nil); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("fold = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+	if err := run(nil, nil, &folder{}, nil); err == nil {
+		t.Errorf("fold -w 0 succeeded")
+	}
+	if err := run(nil, &bytes.Buffer{}, &folder{width: 1}, []string{"/no/such/file"}); err == nil {
+		t.Errorf("fold of a missing file succeeded")
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// fpdt prints how long the phases of the firmware boot took.
+//
+// Synopsis:
+//
+//	fpdt [-j]
+//
+// Description:
+//
+//	UEFI firmware records in the ACPI Firmware Performance Data Table
+//	(FPDT) when, since reset, it started to run, loaded and started the
+//	OS loader, and when the OS loader called ExitBootServices and it
+//	returned. fpdt prints those times, and how long the phases between
+//	them took, so that the boot time of the firmware can be accounted for
+//	along with that of the kernel and userland.
+//
+//	The times are read from /sys/firmware/acpi/fpdt/boot, or, for kernels
+//	before 5.12, from the table the FPDT points to in /dev/mem. Times the
+//	firmware did not record are printed as -.
+//
+// Options:
+//
+//	-j: print JSON
+//
+// Example:
+//
+//	$ fpdt
+//	reset end                 1.204s
+//	OS loader load image      4.981s
+//	OS loader start image     5.302s
+//	ExitBootServices entry    6.913s
+//	ExitBootServices exit     6.921s
+//
+//	firmware                  3.777s
+//	loading the OS loader     321ms
+//	OS loader                 1.611s
+//	ExitBootServices          8ms
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/acpi"
+)
+
+var jsonOut = flag.Bool("j", false, "print JSON")
+
+// Where the times are read from. Tests change them.
+var (
+	sysfsDir  = "/sys/firmware/acpi/fpdt/boot"
+	fpdtTable = "/sys/firmware/acpi/tables/FPDT"
+	readFBPT  = acpi.ReadFBPT
+)
+
+// fromSysfs reads the times the kernel exports from the FBPT in dir.
+func fromSysfs(dir string) (*acpi.BootPerformance, error) {
+	var p acpi.BootPerformance
+	for _, f := range []struct {
+		name string
+		t    *time.Duration
+	}{
+		{"reset_end", &p.ResetEnd},
+		{"load_image", &p.LoadImage},
+		{"start_image", &p.StartImage},
+		{"exitbootservice_start", &p.ExitBootServicesEntry},
+		{"exitbootservice_end", &p.ExitBootServicesExit},
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, f.name))
+		if err != nil {
+			return nil, err
+		}
+		ns, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.name, err)
+		}
+		*f.t = time.Duration(ns)
+	}
+	return &p, nil
+}
+
+// bootPerformance reads the times from sysfs, or the FBPT the FPDT points
+// to.
+func bootPerformance() (*acpi.BootPerformance, error) {
+	p, err := fromSysfs(sysfsDir)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return p, err
+	}
+	tabs, err := acpi.RawFromName(fpdtTable)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("the firmware has no FPDT")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(tabs) == 0 {
+		return nil, fmt.Errorf("%s is empty", fpdtTable)
+	}
+	f, err := acpi.ParseFPDT(tabs[0])
+	if err != nil {
+		return nil, err
+	}
+	if f.FBPT == 0 {
+		return nil, fmt.Errorf("the FPDT has no boot performance table")
+	}
+	return readFBPT(f.FBPT)
+}
+
+// phase is a time between two of the times of a boot.
+type phase struct {
+	name       string
+	start, end time.Duration
+}
+
+func phases(p *acpi.BootPerformance) []phase {
+	return []phase{
+		{"firmware", p.ResetEnd, p.LoadImage},
+		{"loading the OS loader", p.LoadImage, p.StartImage},
+		{"OS loader", p.StartImage, p.ExitBootServicesEntry},
+		{"ExitBootServices", p.ExitBootServicesEntry, p.ExitBootServicesExit},
+	}
+}
+
+func formatTime(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+func report(out io.Writer, p *acpi.BootPerformance) error {
+	if *jsonOut {
+		ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+		v := map[string]float64{
+			"reset_end_ms":                ms(p.ResetEnd),
+			"load_image_ms":               ms(p.LoadImage),
+			"start_image_ms":              ms(p.StartImage),
+			"exit_boot_services_entry_ms": ms(p.ExitBootServicesEntry),
+			"exit_boot_services_exit_ms":  ms(p.ExitBootServicesExit),
+		}
+		e := json.NewEncoder(out)
+		e.SetIndent("", "  ")
+		return e.Encode(v)
+	}
+
+	for _, t := range []struct {
+		name string
+		t    time.Duration
+	}{
+		{"reset end", p.ResetEnd},
+		{"OS loader load image", p.LoadImage},
+		{"OS loader start image", p.StartImage},
+		{"ExitBootServices entry", p.ExitBootServicesEntry},
+		{"ExitBootServices exit", p.ExitBootServicesExit},
+	} {
+		fmt.Fprintf(out, "%-25s %s\n", t.name, formatTime(t.t))
+	}
+	fmt.Fprintln(out)
+	for _, ph := range phases(p) {
+		d := "-"
+		// Without a reset end, the firmware phase starts at reset.
+		if (ph.start != 0 || ph.name == "firmware") && ph.end != 0 && ph.end >= ph.start {
+			d = (ph.end - ph.start).Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(out, "%-25s %s\n", ph.name, d)
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("fpdt: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() != 0 {
+		log.Fatal("usage: fpdt [-j]")
+	}
+	p, err := bootPerformance()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := report(os.Stdout, p); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/acpi"
+)
+
+func TestBootPerformance(t *testing.T) {
+	dir := t.TempDir()
+	defer func(s, f string) { sysfsDir, fpdtTable, readFBPT = s, f, acpi.ReadFBPT }(sysfsDir, fpdtTable)
+	sysfsDir, fpdtTable = filepath.Join(dir, "boot"), filepath.Join(dir, "FPDT")
+
+	if _, err := bootPerformance(); err == nil || err.Error() != "the firmware has no FPDT" {
+		t.Errorf("bootPerformance without an FPDT = %v", err)
+	}
+
+	// An FPDT pointing to an FBPT at 0x7ffe0000.
+	fpdt := make([]byte, 36+16)
+	copy(fpdt, "FPDT")
+	binary.LittleEndian.PutUint32(fpdt[4:], uint32(len(fpdt)))
+	fpdt[36+2] = 16
+	binary.LittleEndian.PutUint64(fpdt[36+8:], 0x7ffe0000)
+	if err := os.WriteFile(fpdtTable, fpdt, 0o444); err != nil {
+		t.Fatal(err)
+	}
+	want := &acpi.BootPerformance{LoadImage: time.Second, StartImage: 2 * time.Second, ExitBootServicesEntry: 3 * time.Second, ExitBootServicesExit: 4 * time.Second}
+	var addr int64
+	readFBPT = func(a int64) (*acpi.BootPerformance, error) {
+		addr = a
+		return want, nil
+	}
+	if p, err := bootPerformance(); err != nil || addr != 0x7ffe0000 || p != want {
+		t.Errorf("bootPerformance from the FPDT = %+v, %v, read FBPT at %#x, want %+v at 0x7ffe0000", p, err, addr, want)
+	}
+
+	if err := os.Mkdir(sysfsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range map[string]string{
+		"reset_end":             "1204000000\n",
+		"load_image":            "4981000000\n",
+		"start_image":           "5302000000\n",
+		"exitbootservice_start": "6913000000\n",
+		"exitbootservice_end":   "6921000000\n",
+	} {
+		if err := os.WriteFile(filepath.Join(sysfsDir, name), []byte(v), 0o444); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := bootPerformance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &acpi.BootPerformance{
+		ResetEnd:              1204 * time.Millisecond,
+		LoadImage:             4981 * time.Millisecond,
+		StartImage:            5302 * time.Millisecond,
+		ExitBootServicesEntry: 6913 * time.Millisecond,
+		ExitBootServicesExit:  6921 * time.Millisecond,
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("bootPerformance from sysfs = %+v, want %+v", p, want)
+	}
+
+	var out bytes.Buffer
+	if err := report(&out, p); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"reset end                 1.204s\n", "firmware                  3.777s\n", "loading the OS loader     321ms\n", "ExitBootServices          8ms\n"} {
+		if !strings.Contains(out.String(), l) {
+			t.Errorf("report printed %q, want it to include %q", out.String(), l)
+		}
+	}
+
+	out.Reset()
+	if err := report(&out, &acpi.BootPerformance{LoadImage: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"reset end                 -\n", "firmware                  1s\n", "OS loader                 -\n"} {
+		if !strings.Contains(out.String(), l) {
+			t.Errorf("report of few times printed %q, want it to include %q", out.String(), l)
+		}
+	}
+
+	*jsonOut = true
+	defer func() { *jsonOut = false }()
+	out.Reset()
+	var v map[string]float64
+	if err := report(&out, p); err != nil || json.Unmarshal(out.Bytes(), &v) != nil || v["exit_boot_services_exit_ms"] != 6921 {
+		t.Errorf("report -j = %v, printed %q", err, out.String())
+	}
+}
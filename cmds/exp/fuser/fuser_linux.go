@@ -0,0 +1,331 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// fuser finds the processes that use files, file systems or sockets.
+//
+// Synopsis:
+//
+//	fuser [-k] [-s SIGNAL] [-m] [-n tcp|udp] [-v] NAME...
+//
+// Description:
+//
+//	fuser prints, for each NAME, the processes that use it and how:
+//
+//	c  as the current directory
+//	e  as the executable
+//	f  as an open file
+//	m  as a memory mapped file or shared library
+//	r  as the root directory
+//
+//	With -m, NAME is a file on, or the block device of, a file system,
+//	and every process that uses any file on it is printed, which shows
+//	what keeps a file system busy. With -n, or if NAME is PORT/tcp or
+//	PORT/udp, NAME is the local port of a socket. fuser exits with 1 if
+//	no NAME is used.
+//
+// Options:
+//
+//	-k: send SIGNAL to the processes, except fuser itself
+//	-m: NAME is a file system
+//	-n: NAME is a port of this protocol
+//	-s: the signal to send with -k (default KILL)
+//	-v: print the command of each process too
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// A file is a device and inode number.
+type file struct {
+	dev, ino uint64
+}
+
+// target is something to look for.
+type target struct {
+	name string
+	// file, or every file on dev if mount is set.
+	file  file
+	mount bool
+	// sockets are the inodes of the sockets, as in socket:[INODE] links.
+	sockets map[string]bool
+}
+
+func (t *target) matches(f file) bool {
+	if t.mount {
+		return f.dev == t.file.dev
+	}
+	return f == t.file
+}
+
+type fuser struct {
+	proc    string
+	mount   bool
+	proto   string
+	kill    bool
+	signal  syscall.Signal
+	verbose bool
+}
+
+func stat(path string) (file, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return file{}, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	return file{dev: st.Dev, ino: st.Ino}, nil
+}
+
+// sockets returns the inodes of the sockets of proto on port.
+func (f *fuser) sockets(proto string, port uint64) (map[string]bool, error) {
+	inodes := map[string]bool{}
+	found := false
+	for _, name := range []string{proto, proto + "6"} {
+		b, err := os.ReadFile(filepath.Join(f.proc, "net", name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		s := bufio.NewScanner(strings.NewReader(string(b)))
+		s.Scan() // The heading.
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if len(fields) < 10 {
+				continue
+			}
+			_, p, ok := strings.Cut(fields[1], ":")
+			if n, err := strconv.ParseUint(p, 16, 16); !ok || err != nil || n != port {
+				continue
+			}
+			inodes[fields[9]] = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no %s sockets in %s", proto, f.proc)
+	}
+	return inodes, nil
+}
+
+func (f *fuser) target(name string) (*target, error) {
+	proto := f.proto
+	port := name
+	if p, n, ok := strings.Cut(name, "/"); ok && (n == "tcp" || n == "udp") && proto == "" {
+		if _, err := strconv.ParseUint(p, 10, 16); err == nil {
+			proto, port = n, p
+		}
+	}
+	if proto != "" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", port)
+		}
+		s, err := f.sockets(proto, n)
+		if err != nil {
+			return nil, err
+		}
+		return &target{name: name, sockets: s}, nil
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(name, &st); err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+	t := &target{name: name, file: file{dev: st.Dev, ino: st.Ino}, mount: f.mount}
+	if f.mount && st.Mode&unix.S_IFMT == unix.S_IFBLK {
+		t.file.dev = st.Rdev
+	}
+	return t, nil
+}
+
+// maps returns the files that process dir has mapped into memory.
+func maps(dir string) []file {
+	b, err := os.ReadFile(filepath.Join(dir, "maps"))
+	if err != nil {
+		return nil
+	}
+	var files []file
+	for _, l := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(l)
+		if len(fields) < 6 {
+			continue
+		}
+		maj, min, ok := strings.Cut(fields[3], ":")
+		ma, err1 := strconv.ParseUint(maj, 16, 32)
+		mi, err2 := strconv.ParseUint(min, 16, 32)
+		ino, err3 := strconv.ParseUint(fields[4], 10, 64)
+		if !ok || err1 != nil || err2 != nil || err3 != nil || ino == 0 {
+			continue
+		}
+		files = append(files, file{dev: unix.Mkdev(uint32(ma), uint32(mi)), ino: ino})
+	}
+	return files
+}
+
+// uses returns how process pid uses each of targets, as access letters.
+func (f *fuser) uses(pid string, targets []*target) []string {
+	dir := filepath.Join(f.proc, pid)
+	access := make([]string, len(targets))
+	mark := func(link string, letter byte) {
+		l, err := os.Readlink(link)
+		if err != nil {
+			return
+		}
+		var fi file
+		socket := strings.HasPrefix(l, "socket:[")
+		if !socket {
+			if fi, err = stat(link); err != nil {
+				return
+			}
+		}
+		for i, t := range targets {
+			var ok bool
+			if socket {
+				ok = t.sockets[strings.TrimSuffix(strings.TrimPrefix(l, "socket:["), "]")]
+			} else {
+				ok = t.sockets == nil && t.matches(fi)
+			}
+			if ok && strings.IndexByte(access[i], letter) < 0 {
+				access[i] += string(letter)
+			}
+		}
+	}
+	mark(filepath.Join(dir, "cwd"), 'c')
+	mark(filepath.Join(dir, "exe"), 'e')
+	fds, _ := os.ReadDir(filepath.Join(dir, "fd"))
+	for _, fd := range fds {
+		mark(filepath.Join(dir, "fd", fd.Name()), 'f')
+	}
+	for _, m := range maps(dir) {
+		for i, t := range targets {
+			if t.sockets == nil && t.matches(m) && strings.IndexByte(access[i], 'm') < 0 {
+				access[i] += "m"
+			}
+		}
+	}
+	mark(filepath.Join(dir, "root"), 'r')
+	return access
+}
+
+func command(dir string) string {
+	b, err := os.ReadFile(filepath.Join(dir, "comm"))
+	if err != nil {
+		return "?"
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func (f *fuser) run(stdout io.Writer, names []string) (bool, error) {
+	if len(names) == 0 {
+		return false, errors.New("usage: fuser [-k] [-s SIGNAL] [-m] [-n tcp|udp] [-v] NAME...")
+	}
+	var targets []*target
+	for _, n := range names {
+		t, err := f.target(n)
+		if err != nil {
+			return false, err
+		}
+		targets = append(targets, t)
+	}
+	entries, err := os.ReadDir(f.proc)
+	if err != nil {
+		return false, err
+	}
+	type user struct {
+		pid    int
+		access string
+	}
+	users := make([][]user, len(targets))
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		for i, a := range f.uses(e.Name(), targets) {
+			if a != "" {
+				users[i] = append(users[i], user{pid, a})
+			}
+		}
+	}
+
+	found := false
+	var killErr error
+	for i, t := range targets {
+		sort.Slice(users[i], func(a, b int) bool { return users[i][a].pid < users[i][b].pid })
+		if f.verbose {
+			fmt.Fprintf(stdout, "%s:\n", t.name)
+		} else {
+			fmt.Fprintf(stdout, "%s:", t.name)
+		}
+		for _, u := range users[i] {
+			found = true
+			if f.verbose {
+				fmt.Fprintf(stdout, "%8d %-6s %s\n", u.pid, u.access, command(filepath.Join(f.proc, strconv.Itoa(u.pid))))
+			} else {
+				fmt.Fprintf(stdout, " %d%s", u.pid, u.access)
+			}
+			if f.kill && u.pid != os.Getpid() {
+				if err := unix.Kill(u.pid, f.signal); err != nil {
+					killErr = fmt.Errorf("killing %d: %v", u.pid, err)
+				}
+			}
+		}
+		if !f.verbose {
+			fmt.Fprintln(stdout)
+		}
+	}
+	return found, killErr
+}
+
+func parseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig := unix.SignalNum(name); sig != 0 {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("invalid signal %q", s)
+}
+
+func main() {
+	f := &fuser{proc: "/proc"}
+	flag.BoolVar(&f.kill, "k", false, "send SIGNAL to the processes, except fuser itself")
+	flag.BoolVar(&f.mount, "m", false, "NAME is a file system")
+	flag.StringVar(&f.proto, "n", "", "NAME is a port of this protocol")
+	sig := flag.String("s", "KILL", "the signal to send with -k")
+	flag.BoolVar(&f.verbose, "v", false, "print the command of each process too")
+	flag.Parse()
+
+	var err error
+	if f.signal, err = parseSignal(*sig); err != nil {
+		log.Fatalf("fuser: %v", err)
+	}
+	if f.proto != "" && f.proto != "tcp" && f.proto != "udp" {
+		log.Fatalf("fuser: invalid protocol %q", f.proto)
+	}
+	found, err := f.run(os.Stdout, flag.Args())
+	if err != nil {
+		log.Fatalf("fuser: %v", err)
+	}
+	if !found {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFuser(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "f")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	unused := filepath.Join(dir, "unused")
+	if err := os.WriteFile(unused, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	me := strconv.Itoa(os.Getpid())
+	for _, tt := range []struct {
+		name  string
+		f     fuser
+		names []string
+		// want is the access letter of this process, if it is found.
+		want  string
+		found bool
+	}{
+		{name: "file", names: []string{name}, want: "f", found: true},
+		{name: "unused", names: []string{unused}},
+		{name: "mount", f: fuser{mount: true}, names: []string{unused}, want: "f", found: true},
+		{name: "port", names: []string{fmt.Sprintf("%d/tcp", port)}, want: "f", found: true},
+		{name: "protocol", f: fuser{proto: "tcp"}, names: []string{fmt.Sprint(port)}, want: "f", found: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.f.proc = "/proc"
+			var b bytes.Buffer
+			found, err := tt.f.run(&b, tt.names)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != tt.found {
+				t.Errorf("found = %v, want %v", found, tt.found)
+			}
+			if !strings.HasPrefix(b.String(), tt.names[0]+":") {
+				t.Errorf("output %q does not start with %q", b.String(), tt.names[0]+":")
+			}
+			if tt.want == "" {
+				return
+			}
+			for _, u := range strings.Fields(b.String())[1:] {
+				if access := strings.TrimLeft(u, "0123456789"); u[:len(u)-len(access)] == me {
+					if !strings.Contains(access, tt.want) {
+						t.Errorf("access of %s = %q, want %q", me, access, tt.want)
+					}
+					return
+				}
+			}
+			t.Errorf("output %q does not have %s", b.String(), me)
+		})
+	}
+}
+
+func TestFuserErrors(t *testing.T) {
+	f := &fuser{proc: "/proc"}
+	var b bytes.Buffer
+	for _, names := range [][]string{nil, {"/does/not/exist"}, {"70000/tcp"}} {
+		if _, err := f.run(&b, names); err == nil {
+			t.Errorf("fuser %q succeeded", names)
+		}
+	}
+}
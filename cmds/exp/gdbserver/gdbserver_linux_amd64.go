@@ -0,0 +1,577 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// gdbserver is a minimal remote debug stub speaking a subset of the GDB
+// remote serial protocol.
+//
+// Synopsis:
+//
+//	gdbserver [-l ADDR] COMMAND [ARGS...]
+//
+// Description:
+//
+//	gdbserver starts COMMAND stopped under ptrace and waits for a single
+//	debugger connection on ADDR. From a development machine, connect with
+//
+//	    (gdb) target remote HOST:1234
+//
+//	Supported are register and memory reads and writes, continue and single
+//	step with or without a signal, interrupting with ^C, software
+//	breakpoints, kill and detach. Only a single thread is traced. If the
+//	debugger connection is lost, the process is killed.
+//
+// Options:
+//
+//	-l: address to listen on (default :1234)
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+var listen = flag.String("l", ":1234", "address to listen on")
+
+// packetSize is the largest packet we accept, as advertised in qSupported.
+// Memory is sent hex encoded, so reads are limited to half of it.
+const (
+	packetSize = 0x4000
+	maxMemRead = packetSize / 2
+)
+
+// target is a single ptrace'd process.
+type target struct {
+	pid int
+
+	// breakpoints maps addresses to the original byte replaced by int3.
+	breakpoints map[uint64]byte
+
+	// stopPending is set when a SIGSTOP sent to interrupt the target
+	// has not stopped it yet.
+	stopPending bool
+}
+
+func (t *target) wait() (syscall.WaitStatus, error) {
+	var ws syscall.WaitStatus
+	_, err := syscall.Wait4(t.pid, &ws, 0, nil)
+	return ws, err
+}
+
+// stopReply encodes a wait status as a stop reply packet.
+func stopReply(ws syscall.WaitStatus) string {
+	switch {
+	case ws.Exited():
+		return fmt.Sprintf("W%02x", ws.ExitStatus())
+	case ws.Signaled():
+		return fmt.Sprintf("X%02x", byte(ws.Signal()))
+	case ws.Stopped():
+		return fmt.Sprintf("S%02x", byte(ws.StopSignal()))
+	}
+	return "S05"
+}
+
+// gdbRegs is the order in which GDB expects the general purpose registers of
+// amd64. The last seven are 32 bits wide.
+func gdbRegs(r *syscall.PtraceRegs) []*uint64 {
+	return []*uint64{
+		&r.Rax, &r.Rbx, &r.Rcx, &r.Rdx, &r.Rsi, &r.Rdi, &r.Rbp, &r.Rsp,
+		&r.R8, &r.R9, &r.R10, &r.R11, &r.R12, &r.R13, &r.R14, &r.R15,
+		&r.Rip,
+		&r.Eflags, &r.Cs, &r.Ss, &r.Ds, &r.Es, &r.Fs, &r.Gs,
+	}
+}
+
+const wideRegs = 17
+
+func (t *target) readRegs() (string, error) {
+	var r syscall.PtraceRegs
+	if err := syscall.PtraceGetRegs(t.pid, &r); err != nil {
+		return "", err
+	}
+	var b []byte
+	for i, reg := range gdbRegs(&r) {
+		if i < wideRegs {
+			b = binary.LittleEndian.AppendUint64(b, *reg)
+		} else {
+			b = binary.LittleEndian.AppendUint32(b, uint32(*reg))
+		}
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (t *target) writeRegs(s string) error {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	var r syscall.PtraceRegs
+	if err := syscall.PtraceGetRegs(t.pid, &r); err != nil {
+		return err
+	}
+	for i, reg := range gdbRegs(&r) {
+		switch {
+		case i < wideRegs && len(b) >= 8:
+			*reg, b = binary.LittleEndian.Uint64(b), b[8:]
+		case i >= wideRegs && len(b) >= 4:
+			*reg, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		}
+	}
+	return syscall.PtraceSetRegs(t.pid, &r)
+}
+
+func (t *target) readMem(addr, length uint64) ([]byte, error) {
+	b := make([]byte, length)
+	n, err := syscall.PtracePeekData(t.pid, uintptr(addr), b)
+	if err != nil && n == 0 {
+		return nil, err
+	}
+	b = b[:n]
+	// Hide our own breakpoints from the debugger.
+	for a, orig := range t.breakpoints {
+		if a >= addr && a < addr+uint64(len(b)) {
+			b[a-addr] = orig
+		}
+	}
+	return b, nil
+}
+
+func (t *target) writeMem(addr uint64, b []byte) error {
+	_, err := syscall.PtracePokeData(t.pid, uintptr(addr), b)
+	return err
+}
+
+func (t *target) setBreakpoint(addr uint64) error {
+	if _, ok := t.breakpoints[addr]; ok {
+		return nil
+	}
+	orig := make([]byte, 1)
+	if _, err := syscall.PtracePeekData(t.pid, uintptr(addr), orig); err != nil {
+		return err
+	}
+	if err := t.writeMem(addr, []byte{0xcc}); err != nil {
+		return err
+	}
+	t.breakpoints[addr] = orig[0]
+	return nil
+}
+
+func (t *target) clearBreakpoint(addr uint64) error {
+	orig, ok := t.breakpoints[addr]
+	if !ok {
+		return nil
+	}
+	delete(t.breakpoints, addr)
+	return t.writeMem(addr, []byte{orig})
+}
+
+// stepOverBreakpoint single steps the original instruction, delivering
+// sig if it is not zero, if the target is stopped on a breakpoint, so that
+// resuming does not trap again immediately. It reports whether it stepped,
+// and how the target stopped.
+func (t *target) stepOverBreakpoint(sig syscall.Signal) (bool, syscall.WaitStatus, error) {
+	var r syscall.PtraceRegs
+	if err := syscall.PtraceGetRegs(t.pid, &r); err != nil {
+		return false, 0, err
+	}
+	orig, ok := t.breakpoints[r.Rip]
+	if !ok {
+		return false, 0, nil
+	}
+	if err := t.writeMem(r.Rip, []byte{orig}); err != nil {
+		return false, 0, err
+	}
+	step := func() error { return ptraceStep(t.pid, 0) }
+	if err := ptraceStep(t.pid, sig); err != nil {
+		return false, 0, err
+	}
+	ws, err := t.waitStop(step)
+	if err != nil {
+		return true, ws, err
+	}
+	if ws.Exited() || ws.Signaled() {
+		return true, ws, nil
+	}
+	return true, ws, t.writeMem(r.Rip, []byte{0xcc})
+}
+
+// ptraceStep is PtraceSingleStep delivering sig, which package syscall does
+// not offer.
+func ptraceStep(pid int, sig syscall.Signal) error {
+	_, _, errno := syscall.Syscall6(syscall.SYS_PTRACE, syscall.PTRACE_SINGLESTEP, uintptr(pid), 0, uintptr(sig), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// waitStop waits for the target to stop after it was resumed. A SIGSTOP
+// left over from an interrupt that crossed another stop is not reported:
+// the target is resumed again with restart.
+func (t *target) waitStop(restart func() error) (syscall.WaitStatus, error) {
+	for {
+		ws, err := t.wait()
+		if err != nil || !t.stopPending || !ws.Stopped() || ws.StopSignal() != syscall.SIGSTOP {
+			return ws, err
+		}
+		t.stopPending = false
+		if err := restart(); err != nil {
+			return ws, err
+		}
+	}
+}
+
+// waitRunning is waitStop that also watches the connection c for a ^C
+// while the target runs, and then stops the target with SIGSTOP. The stop
+// is reported as SIGINT, as GDB expects of an interrupt.
+func (t *target) waitRunning(c *rsp, restart func() error) (syscall.WaitStatus, error) {
+	var interrupted atomic.Bool
+	done := c.watchInterrupt(func() {
+		interrupted.Store(true)
+		syscall.Kill(t.pid, syscall.SIGSTOP)
+	})
+	ws, err := t.waitStop(restart)
+	done()
+	if interrupted.Load() && err == nil && ws.Stopped() {
+		if ws.StopSignal() == syscall.SIGSTOP {
+			ws = syscall.WaitStatus(syscall.SIGINT)<<8 | 0x7f
+		} else {
+			// The target stopped by itself before the SIGSTOP
+			// arrived, which is still pending.
+			t.stopPending = true
+		}
+	}
+	return ws, err
+}
+
+// resume continues or single steps the target, delivering sig if it is not
+// zero, and waits for it to stop. A ^C from c while the target runs stops
+// it.
+func (t *target) resume(c *rsp, step bool, sig syscall.Signal) (syscall.WaitStatus, error) {
+	stepped, ws, err := t.stepOverBreakpoint(sig)
+	if err != nil {
+		return ws, err
+	}
+	if stepped {
+		// Stepping over the breakpoint was a single step, and
+		// delivered sig. Report it unless the target is to continue
+		// and merely finished the step.
+		if step || !ws.Stopped() || ws.StopSignal() != syscall.SIGTRAP {
+			return ws, nil
+		}
+		sig = 0
+	}
+	restart := func() error {
+		if step {
+			return ptraceStep(t.pid, 0)
+		}
+		return syscall.PtraceCont(t.pid, 0)
+	}
+	if step {
+		err = ptraceStep(t.pid, sig)
+	} else {
+		err = syscall.PtraceCont(t.pid, int(sig))
+	}
+	if err != nil {
+		return 0, err
+	}
+	ws, err = t.waitRunning(c, restart)
+	if err != nil {
+		return ws, err
+	}
+	if ws.Stopped() && ws.StopSignal() == syscall.SIGTRAP && !step {
+		// Rewind past the int3 so that the PC points at the breakpoint.
+		var r syscall.PtraceRegs
+		if err := syscall.PtraceGetRegs(t.pid, &r); err == nil {
+			if _, ok := t.breakpoints[r.Rip-1]; ok {
+				r.Rip--
+				if err := syscall.PtraceSetRegs(t.pid, &r); err != nil {
+					return ws, err
+				}
+			}
+		}
+	}
+	return ws, nil
+}
+
+// clearBreakpoints removes all breakpoints from the target.
+func (t *target) clearBreakpoints() {
+	for addr := range t.breakpoints {
+		t.clearBreakpoint(addr)
+	}
+}
+
+// kill kills the target and reaps it.
+func (t *target) kill() {
+	t.clearBreakpoints()
+	syscall.Kill(t.pid, syscall.SIGKILL)
+	// The pending stop must be consumed before the exit status is
+	// reported.
+	for {
+		ws, err := t.wait()
+		if err != nil || ws.Exited() || ws.Signaled() {
+			return
+		}
+	}
+}
+
+// parseResume parses the optional signal argument of the C and S packets,
+// "sig[;addr]". Resuming at a different address is not supported.
+func parseResume(args string) (syscall.Signal, error) {
+	if args == "" {
+		return 0, nil
+	}
+	if i := strings.IndexByte(args, ';'); i >= 0 {
+		return 0, fmt.Errorf("resuming at an address is not supported")
+	}
+	b, err := hex.DecodeString(args)
+	if err != nil || len(b) != 1 {
+		return 0, fmt.Errorf("malformed signal %q", args)
+	}
+	return syscall.Signal(b[0]), nil
+}
+
+// errDone ends the session.
+var errDone = errors.New("session done")
+
+// handle processes a single packet and returns the reply.
+func (t *target) handle(c *rsp, pkt string, last *syscall.WaitStatus) (string, error) {
+	if pkt == "" {
+		return "", nil
+	}
+	switch cmd, args := pkt[0], pkt[1:]; cmd {
+	case '?':
+		return stopReply(*last), nil
+
+	case 'g':
+		s, err := t.readRegs()
+		if err != nil {
+			return errorReply(1), nil
+		}
+		return s, nil
+
+	case 'G':
+		if err := t.writeRegs(args); err != nil {
+			return errorReply(1), nil
+		}
+		return "OK", nil
+
+	case 'm':
+		addr, length, err := parseAddrLen(args)
+		if err != nil || length > maxMemRead {
+			return errorReply(1), nil
+		}
+		b, err := t.readMem(addr, length)
+		if err != nil {
+			return errorReply(14), nil
+		}
+		return hex.EncodeToString(b), nil
+
+	case 'M':
+		i := strings.IndexByte(args, ':')
+		if i < 0 {
+			return errorReply(1), nil
+		}
+		addr, _, err := parseAddrLen(args[:i])
+		if err != nil {
+			return errorReply(1), nil
+		}
+		b, err := hex.DecodeString(args[i+1:])
+		if err != nil {
+			return errorReply(1), nil
+		}
+		if err := t.writeMem(addr, b); err != nil {
+			return errorReply(14), nil
+		}
+		return "OK", nil
+
+	case 'c', 's', 'C', 'S':
+		// c and s resume without a signal, C and S with the one
+		// the debugger decided to pass on, usually the last stop
+		// signal.
+		var sig syscall.Signal
+		if cmd == 'C' || cmd == 'S' {
+			var err error
+			if sig, err = parseResume(args); err != nil {
+				return errorReply(1), nil
+			}
+		}
+		ws, err := t.resume(c, cmd == 's' || cmd == 'S', sig)
+		if err != nil {
+			return errorReply(1), nil
+		}
+		*last = ws
+		if ws.Exited() || ws.Signaled() {
+			return stopReply(ws), errDone
+		}
+		return stopReply(ws), nil
+
+	case 'Z', 'z':
+		// Only software breakpoints (type 0) are supported.
+		if !strings.HasPrefix(args, "0,") {
+			return "", nil
+		}
+		addr, _, err := parseAddrLen(args[2:])
+		if err != nil {
+			return errorReply(1), nil
+		}
+		if cmd == 'Z' {
+			err = t.setBreakpoint(addr)
+		} else {
+			err = t.clearBreakpoint(addr)
+		}
+		if err != nil {
+			return errorReply(14), nil
+		}
+		return "OK", nil
+
+	case 'k':
+		t.kill()
+		return "", errDone
+
+	case 'D':
+		t.clearBreakpoints()
+		if err := syscall.PtraceDetach(t.pid); err != nil {
+			return errorReply(1), errDone
+		}
+		return "OK", errDone
+
+	case 'q':
+		switch {
+		case strings.HasPrefix(args, "Supported"):
+			return fmt.Sprintf("PacketSize=%x;QStartNoAckMode+", packetSize), nil
+		case args == "Attached":
+			return "0", nil
+		case args == "C":
+			return fmt.Sprintf("QC%x", t.pid), nil
+		case args == "fThreadInfo":
+			return fmt.Sprintf("m%x", t.pid), nil
+		case args == "sThreadInfo":
+			return "l", nil
+		}
+
+	case 'Q':
+		if args == "StartNoAckMode" {
+			if err := c.writePacket("OK"); err != nil {
+				return "", err
+			}
+			c.noAck = true
+			return "", errNoReply
+		}
+
+	case 'H', 'T':
+		// Thread selection: there is only one thread.
+		return "OK", nil
+	}
+
+	// The empty reply means "unsupported".
+	return "", nil
+}
+
+// errNoReply signals that handle already sent its reply.
+var errNoReply = errors.New("reply sent")
+
+// serve handles packets until the session ends. If the connection is lost
+// instead, the target, which we started, is killed.
+func serve(t *target, c *rsp, ws syscall.WaitStatus) (err error) {
+	defer func() {
+		if err != nil {
+			t.kill()
+		}
+	}()
+	for {
+		pkt, err := c.readPacket()
+		if err == errInterrupt {
+			// While the target runs, inside handle, ^C is read by
+			// waitRunning. This one crossed the stop reply it was
+			// meant to get, so there is nothing left to do.
+			continue
+		}
+		if err == errPacketTooLong {
+			if err := c.writePacket(errorReply(1)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		reply, err := t.handle(c, pkt, &ws)
+		if err == errNoReply {
+			continue
+		}
+		if werr := c.writePacket(reply); werr != nil {
+			return werr
+		}
+		if err == errDone {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func run(addr string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: gdbserver [-l ADDR] COMMAND [ARGS...]")
+	}
+
+	// ptrace requests must all come from the tracing thread.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Ptrace: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	t := &target{
+		pid:         cmd.Process.Pid,
+		breakpoints: make(map[uint64]byte),
+	}
+	// The child stops with SIGTRAP on exec.
+	ws, err := t.wait()
+	if err != nil {
+		return err
+	}
+	log.Printf("Process %s created; pid = %d", args[0], t.pid)
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.kill()
+		return err
+	}
+	defer l.Close()
+	log.Printf("Listening on %s", l.Addr())
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.kill()
+		return err
+	}
+	defer conn.Close()
+	log.Printf("Remote debugging from host %s", conn.RemoteAddr())
+
+	return serve(t, newRSP(conn), ws)
+}
+
+func main() {
+	flag.Parse()
+	if err := run(*listen, flag.Args()); err != nil {
+		log.Fatalf("gdbserver: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleLimits(t *testing.T) {
+	tg := &target{pid: -1, breakpoints: map[uint64]byte{}}
+	c := newRSP(&loopback{in: strings.NewReader("")})
+	var ws syscall.WaitStatus
+	for _, tt := range []struct {
+		pkt  string
+		want string
+	}{
+		{"m0,ffffffffffffffff", "E01"},
+		{"m0,7fffffff", "E01"},
+		{"m0,2001", "E01"},
+		{"Czz", "E01"},
+		{"C0b;1000", "E01"},
+		{"qSupported:multiprocess+", "PacketSize=4000;QStartNoAckMode+"},
+		{"vMustReplyEmpty", ""},
+	} {
+		got, err := tg.handle(c, tt.pkt, &ws)
+		if err != nil || got != tt.want {
+			t.Errorf("handle(%q) = %q, %v, want %q", tt.pkt, got, err, tt.want)
+		}
+	}
+}
+
+// startTracee starts sleep stopped under ptrace. The caller must have locked
+// its OS thread.
+func startTracee(t *testing.T) (*target, syscall.WaitStatus) {
+	t.Helper()
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Ptrace: true}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("can't start a traced process: %v", err)
+	}
+	tg := &target{pid: cmd.Process.Pid, breakpoints: map[uint64]byte{}}
+	ws, err := tg.wait()
+	if err != nil || !ws.Stopped() {
+		tg.kill()
+		t.Skipf("process did not stop under ptrace: %v, %v", ws, err)
+	}
+	return tg, ws
+}
+
+func TestHandleTracee(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	tg, ws := startTracee(t)
+	c := newRSP(&loopback{in: strings.NewReader("")})
+
+	regs, err := tg.handle(c, "g", &ws)
+	if err != nil || len(regs) != 2*(8*17+4*7) {
+		t.Fatalf("handle(g) = %q, %v", regs, err)
+	}
+	if got, _ := tg.handle(c, "m0,1", &ws); got != "E0e" {
+		t.Errorf("handle(m0,1) = %q, want E0e", got)
+	}
+
+	// The signal given to C must reach the tracee.
+	got, err := tg.handle(c, "C0f", &ws)
+	if err != errDone || got != "X0f" {
+		t.Errorf("handle(C0f) = %q, %v, want X0f, %v", got, err, errDone)
+	}
+}
+
+func TestServeConnectionLost(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	tg, ws := startTracee(t)
+	// Reading the first packet fails with EOF.
+	if err := serve(tg, newRSP(&loopback{in: strings.NewReader("")}), ws); err == nil {
+		t.Errorf("serve on closed connection succeeded")
+	}
+	if err := syscall.Kill(tg.pid, 0); err != syscall.ESRCH {
+		tg.kill()
+		t.Errorf("tracee still exists after the connection was lost: %v", err)
+	}
+}
+
+// pc returns the program counter of the stopped tracee.
+func pc(t *testing.T, tg *target) uint64 {
+	t.Helper()
+	var r syscall.PtraceRegs
+	if err := syscall.PtraceGetRegs(tg.pid, &r); err != nil {
+		t.Fatal(err)
+	}
+	return r.Rip
+}
+
+func TestStepAtBreakpoint(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	c := newRSP(&loopback{in: strings.NewReader("")})
+
+	// How far a single step goes from the entry point. The tracees are
+	// loaded at different addresses, but run the same code.
+	plain, ws := startTracee(t)
+	defer plain.kill()
+	start := pc(t, plain)
+	if got, err := plain.handle(c, "s", &ws); err != nil || got != "S05" {
+		t.Fatalf("handle(s) = %q, %v, want S05", got, err)
+	}
+	want := pc(t, plain) - start
+
+	tg, ws := startTracee(t)
+	defer tg.kill()
+	start = pc(t, tg)
+	if got, err := tg.handle(c, fmt.Sprintf("Z0,%x,1", start), &ws); err != nil || got != "OK" {
+		t.Fatalf("handle(Z0) = %q, %v, want OK", got, err)
+	}
+	if got, err := tg.handle(c, "s", &ws); err != nil || got != "S05" {
+		t.Fatalf("handle(s) at a breakpoint = %q, %v, want S05", got, err)
+	}
+	if got := pc(t, tg) - start; got != want {
+		t.Errorf("step at a breakpoint went %#x bytes, want the %#x of one instruction", got, want)
+	}
+}
+
+func TestSignalAtBreakpoint(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	c := newRSP(&loopback{in: strings.NewReader("")})
+
+	tg, ws := startTracee(t)
+	if got, err := tg.handle(c, fmt.Sprintf("Z0,%x,1", pc(t, tg)), &ws); err != nil || got != "OK" {
+		tg.kill()
+		t.Fatalf("handle(Z0) = %q, %v, want OK", got, err)
+	}
+	// The signal must be delivered by the step over the breakpoint.
+	got, err := tg.handle(c, "C0f", &ws)
+	if err != errDone || got != "X0f" {
+		tg.kill()
+		t.Errorf("handle(C0f) at a breakpoint = %q, %v, want X0f, %v", got, err, errDone)
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	tg, ws := startTracee(t)
+	defer tg.kill()
+	server, client := net.Pipe()
+	defer client.Close()
+	c := newRSP(server)
+
+	for i := 0; i < 2; i++ {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			client.Write([]byte{0x03})
+		}()
+		got, err := tg.handle(c, "c", &ws)
+		if err != nil || got != "S02" {
+			t.Fatalf("handle(c) interrupted by ^C = %q, %v, want S02", got, err)
+		}
+	}
+}
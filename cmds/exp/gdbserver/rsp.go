@@ -0,0 +1,235 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && amd64
+// +build linux,amd64
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	// errInterrupt is returned by readPacket when the client sent a ^C.
+	errInterrupt = errors.New("interrupt")
+
+	// errPacketTooLong is returned by readPacket for a packet of more
+	// than packetSize bytes, which is dropped.
+	errPacketTooLong = errors.New("packet too long")
+)
+
+// rsp implements the framing of the GDB remote serial protocol.
+//
+// Packets look like $<data>#<2 hex digit checksum>. Every packet is
+// acknowledged with a '+' (or '-' to request retransmission) until the client
+// negotiates QStartNoAckMode.
+type rsp struct {
+	r     *bufio.Reader
+	w     io.Writer
+	noAck bool
+}
+
+func newRSP(rw io.ReadWriter) *rsp {
+	return &rsp{
+		r: bufio.NewReader(rw),
+		w: rw,
+	}
+}
+
+func checksum(data string) byte {
+	var sum byte
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	return sum
+}
+
+// readPacket returns the payload of the next valid packet.
+func (c *rsp) readPacket() (string, error) {
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '$':
+		case 0x03:
+			return "", errInterrupt
+		default:
+			// Acks and line noise outside of packets are ignored.
+			continue
+		}
+
+		data, sum, err := c.readData()
+		if err != nil && err != errPacketTooLong {
+			return "", err
+		}
+		long := err == errPacketTooLong
+
+		var digits [2]byte
+		if _, err := io.ReadFull(c.r, digits[:]); err != nil {
+			return "", err
+		}
+		want, err := strconv.ParseUint(string(digits[:]), 16, 8)
+		if err != nil || byte(want) != sum {
+			if !c.noAck {
+				if _, err := c.w.Write([]byte{'-'}); err != nil {
+					return "", err
+				}
+			}
+			continue
+		}
+		if !c.noAck {
+			if _, err := c.w.Write([]byte{'+'}); err != nil {
+				return "", err
+			}
+		}
+		if long {
+			return "", errPacketTooLong
+		}
+		return unescape(data), nil
+	}
+}
+
+// readData reads the data of a packet up to the '#' that ends it, and
+// returns it with its checksum. Past packetSize bytes, the data is dropped
+// and the error is errPacketTooLong.
+func (c *rsp) readData() (string, byte, error) {
+	var (
+		data []byte
+		sum  byte
+		long bool
+	)
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", 0, err
+		}
+		switch {
+		case b == '#' && long:
+			return "", sum, errPacketTooLong
+		case b == '#':
+			return string(data), sum, nil
+		case len(data) == packetSize:
+			data, long = nil, true
+		case !long:
+			data = append(data, b)
+		}
+		sum += b
+	}
+}
+
+// watchInterrupt reads the connection while the target runs, which may only
+// bring a ^C, and calls interrupt when it does. interrupt is also called if
+// the connection is lost, so that it is noticed. The returned function ends
+// the watch. Connections without read deadlines are not watched.
+func (c *rsp) watchInterrupt(interrupt func()) func() {
+	d, ok := c.w.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return func() {}
+	}
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		b, err := c.r.Peek(1)
+		switch {
+		case errors.Is(err, os.ErrDeadlineExceeded):
+		case err != nil:
+			interrupt()
+		case b[0] == 0x03:
+			c.r.ReadByte()
+			interrupt()
+		}
+		// Anything else is left for readPacket.
+	}()
+	return func() {
+		d.SetReadDeadline(time.Now())
+		<-exited
+		d.SetReadDeadline(time.Time{})
+	}
+}
+
+// writePacket frames and sends data, waiting for the client's ack.
+func (c *rsp) writePacket(data string) error {
+	pkt := fmt.Sprintf("$%s#%02x", escape(data), checksum(escape(data)))
+	for {
+		if _, err := io.WriteString(c.w, pkt); err != nil {
+			return err
+		}
+		if c.noAck {
+			return nil
+		}
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == '+' {
+			return nil
+		}
+	}
+}
+
+// escape escapes the characters that may not appear verbatim in a packet.
+func escape(s string) string {
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '#', '$', '}', '*':
+			out = append(out, '}', s[i]^0x20)
+		default:
+			out = append(out, s[i])
+		}
+	}
+	return string(out)
+}
+
+func unescape(s string) string {
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '}' && i+1 < len(s) {
+			i++
+			out = append(out, s[i]^0x20)
+			continue
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
+
+// parseAddrLen parses the "addr,length" argument used by the m, M, Z and z
+// packets.
+func parseAddrLen(s string) (uint64, uint64, error) {
+	var addr, length string
+	for i := 0; i < len(s); i++ {
+		if s[i] == ',' {
+			addr, length = s[:i], s[i+1:]
+			break
+		}
+	}
+	if addr == "" || length == "" {
+		return 0, 0, fmt.Errorf("malformed address %q", s)
+	}
+	a, err := strconv.ParseUint(addr, 16, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	l, err := strconv.ParseUint(length, 16, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return a, l, nil
+}
+
+// errorReply returns an Exx error packet.
+func errorReply(code byte) string {
+	return "E" + hex.EncodeToString([]byte{code})
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && amd64
+// +build linux,amd64
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// loopback reads from in and records everything written to it in out.
+type loopback struct {
+	in  io.Reader
+	out bytes.Buffer
+}
+
+func (l *loopback) Read(b []byte) (int, error) {
+	return l.in.Read(b)
+}
+
+func (l *loopback) Write(b []byte) (int, error) {
+	return l.out.Write(b)
+}
+
+func TestReadPacket(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want string
+		ack  string
+	}{
+		{in: "$g#67", want: "g", ack: "+"},
+		{in: "+$m1000,4#8e", want: "m1000,4", ack: "+"},
+		{in: "$g#00$?#3f", want: "?", ack: "-+"},
+		{in: "$X}\x03#d8", want: "X#", ack: "+"},
+	} {
+		l := &loopback{in: strings.NewReader(tt.in)}
+		got, err := newRSP(l).readPacket()
+		if err != nil {
+			t.Errorf("readPacket(%q) = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("readPacket(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if l.out.String() != tt.ack {
+			t.Errorf("readPacket(%q) acked %q, want %q", tt.in, l.out.String(), tt.ack)
+		}
+	}
+}
+
+func TestReadPacketTooLong(t *testing.T) {
+	long := strings.Repeat("a", packetSize+1)
+	in := fmt.Sprintf("$%s#%02x$g#67", long, checksum(long))
+	l := &loopback{in: strings.NewReader(in)}
+	c := newRSP(l)
+	if _, err := c.readPacket(); err != errPacketTooLong {
+		t.Errorf("readPacket of %d bytes = %v, want %v", len(long), err, errPacketTooLong)
+	}
+	if got, err := c.readPacket(); err != nil || got != "g" {
+		t.Errorf("readPacket after a long packet = %q, %v, want g", got, err)
+	}
+	if l.out.String() != "++" {
+		t.Errorf("readPacket acked %q, want ++", l.out.String())
+	}
+
+	max := strings.Repeat("a", packetSize)
+	l = &loopback{in: strings.NewReader(fmt.Sprintf("$%s#%02x", max, checksum(max)))}
+	if got, err := newRSP(l).readPacket(); err != nil || got != max {
+		t.Errorf("readPacket of %d bytes = %d bytes, %v, want all of them", len(max), len(got), err)
+	}
+}
+
+func TestWritePacket(t *testing.T) {
+	l := &loopback{in: strings.NewReader("-+")}
+	if err := newRSP(l).writePacket("OK"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := l.out.String(), "$OK#9a$OK#9a"; got != want {
+		t.Errorf("writePacket sent %q, want %q", got, want)
+	}
+}
+
+func TestParseAddrLen(t *testing.T) {
+	a, l, err := parseAddrLen("7fff0010,20")
+	if err != nil || a != 0x7fff0010 || l != 0x20 {
+		t.Errorf("parseAddrLen = %#x, %#x, %v", a, l, err)
+	}
+	if _, _, err := parseAddrLen("zz"); err == nil {
+		t.Errorf("parseAddrLen(zz) succeeded, want error")
+	}
+}
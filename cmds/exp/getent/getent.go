@@ -0,0 +1,195 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// getent prints entries of the passwd, group and hosts databases.
+//
+// Synopsis:
+//
+//	getent DATABASE [KEY...]
+//
+// Description:
+//
+//	getent looks the KEYs up in DATABASE, which is passwd, group or
+//	hosts, and prints their entries, or all its entries without KEYs.
+//
+//	Users and groups are looked up by name, or by id if KEY is a number,
+//	in /etc/passwd and /etc/group; hosts by name, or by address, in
+//	/etc/hosts. Only the files are read, as u-root commands resolve
+//	names with them.
+//
+//	The exit status is 1 on errors, and 2 if a KEY was not found.
+//
+// Example:
+//
+//	$ getent passwd root
+//	root:x:0:0:root:/root:/bin/sh
+//	$ getent hosts localhost
+//	127.0.0.1	localhost
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/hosts"
+	"github.com/u-root/u-root/pkg/nss"
+)
+
+var (
+	errUsage  = errors.New("usage: getent passwd|group|hosts [KEY...]")
+	errAbsent = errors.New("not found")
+)
+
+// hostsFile is the file hosts are looked up in. Tests change it.
+var hostsFile = hosts.DefaultPath
+
+// isID reports whether key is a uid or gid rather than a name.
+func isID(key string) (uint32, bool) {
+	n, err := strconv.ParseUint(key, 10, 32)
+	return uint32(n), err == nil
+}
+
+func passwd(out io.Writer, keys []string) error {
+	if len(keys) == 0 {
+		users, err := nss.Users()
+		for _, u := range users {
+			fmt.Fprintln(out, u)
+		}
+		return err
+	}
+	var absent bool
+	for _, k := range keys {
+		var u *nss.Passwd
+		var err error
+		if id, ok := isID(k); ok {
+			u, err = nss.LookupUID(id)
+		} else {
+			u, err = nss.LookupUser(k)
+		}
+		if errors.Is(err, nss.ErrNotFound) {
+			absent = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, u)
+	}
+	if absent {
+		return errAbsent
+	}
+	return nil
+}
+
+func group(out io.Writer, keys []string) error {
+	if len(keys) == 0 {
+		groups, err := nss.Groups()
+		for _, g := range groups {
+			fmt.Fprintln(out, g)
+		}
+		return err
+	}
+	var absent bool
+	for _, k := range keys {
+		var g *nss.Group
+		var err error
+		if id, ok := isID(k); ok {
+			g, err = nss.LookupGID(id)
+		} else {
+			g, err = nss.LookupGroup(k)
+		}
+		if errors.Is(err, nss.ErrNotFound) {
+			absent = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, g)
+	}
+	if absent {
+		return errAbsent
+	}
+	return nil
+}
+
+func hostEntries(out io.Writer, keys []string) error {
+	f, err := hosts.ReadFile(hostsFile)
+	if err != nil {
+		return err
+	}
+	entries := f.Entries()
+	if len(keys) == 0 {
+		for _, e := range entries {
+			fmt.Fprintln(out, e)
+		}
+		return nil
+	}
+	var absent bool
+	for _, k := range keys {
+		match := func(e hosts.Entry) bool { return hasName(e, k) }
+		if ip := net.ParseIP(k); ip != nil {
+			match = func(e hosts.Entry) bool { return ip.Equal(net.ParseIP(e.Addr)) }
+		}
+		found := false
+		for _, e := range entries {
+			if match(e) {
+				fmt.Fprintln(out, e)
+				found = true
+				break
+			}
+		}
+		absent = absent || !found
+	}
+	if absent {
+		return errAbsent
+	}
+	return nil
+}
+
+// hasName reports whether e has name, which is not case sensitive.
+func hasName(e hosts.Entry, name string) bool {
+	for _, n := range e.Names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func run(out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch db, keys := args[0], args[1:]; db {
+	case "passwd":
+		return passwd(out, keys)
+	case "group":
+		return group(out, keys)
+	case "hosts":
+		return hostEntries(out, keys)
+	default:
+		return fmt.Errorf("unknown database %q: %w", db, errUsage)
+	}
+}
+
+func main() {
+	log.SetPrefix("getent: ")
+	log.SetFlags(0)
+	flag.Parse()
+	err := run(os.Stdout, flag.Args())
+	if errors.Is(err, errAbsent) {
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
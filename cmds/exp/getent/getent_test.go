@@ -0,0 +1,75 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/nss"
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	nss.PasswdPath = write("passwd", "root:x:0:0:root:/root:/bin/sh\nuser:x:1000:100::/home/user:/bin/sh\n")
+	nss.GroupPath = write("group", "root:x:0:\nusers:x:100:user,admin\n")
+	hostsFile = write("hosts", "127.0.0.1 localhost\n::1 localhost ip6-localhost\n10.0.0.1 build.lab build\n")
+
+	for _, tt := range []struct {
+		args []string
+		want string
+		err  error
+	}{
+		{args: []string{"passwd", "user"}, want: "user:x:1000:100::/home/user:/bin/sh\n"},
+		{args: []string{"passwd", "0", "nobody"}, want: "root:x:0:0:root:/root:/bin/sh\n", err: errAbsent},
+		{args: []string{"passwd"}, want: "root:x:0:0:root:/root:/bin/sh\nuser:x:1000:100::/home/user:/bin/sh\n"},
+		{args: []string{"group", "100"}, want: "users:x:100:user,admin\n"},
+		{args: []string{"group", "root"}, want: "root:x:0:\n"},
+		{args: []string{"group", "wheel"}, err: errAbsent},
+		{args: []string{"hosts", "BUILD"}, want: "10.0.0.1\tbuild.lab build\n"},
+		{args: []string{"hosts", "0:0::1"}, want: "::1\tlocalhost ip6-localhost\n"},
+		{args: []string{"hosts", "10.0.0.2"}, err: errAbsent},
+		{args: []string{"shadow", "root"}, err: errUsage},
+		{err: errUsage},
+	} {
+		var out bytes.Buffer
+		if err := run(&out, tt.args); !errors.Is(err, tt.err) || (err == nil) != (tt.err == nil) {
+			t.Errorf("getent %q = %v, want %v", tt.args, err, tt.err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("getent %q printed %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{args: []string{"passwd", "no-such-user-here"}, code: 2},
+		{args: []string{"shadow"}, code: 1},
+	} {
+		err := testutil.Command(t, tt.args...).Run()
+		if err := testutil.IsExitCode(err, tt.code); err != nil {
+			t.Errorf("getent %q: %v", tt.args, err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
@@ -13,6 +13,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/u-root/prompt"
 	"github.com/u-root/prompt/completer"
@@ -55,8 +56,32 @@ func main() {
 	}
 }
 
+// builtins are the commands gosh runs itself, other than those of the
+// interpreter, by name.
+var builtins = map[string]func(hc interp.HandlerContext, args []string) error{}
+
+// execHandler runs the builtins, and the other commands with next. A builtin
+// that fails prints its error, and exits with status 1.
+func execHandler(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(ctx context.Context, args []string) error {
+		b, ok := builtins[args[0]]
+		if !ok {
+			return next(ctx, args)
+		}
+		hc := interp.HandlerCtx(ctx)
+		if err := b(hc, args[1:]); err != nil {
+			fmt.Fprintf(hc.Stderr, "%s: %v\n", args[0], err)
+			return interp.NewExitStatus(1)
+		}
+		return nil
+	}
+}
+
 func (s shell) runAll(narg int) error {
-	r, err := interp.New(interp.StdIO(os.Stdin, os.Stdout, os.Stderr))
+	r, err := interp.New(
+		interp.StdIO(os.Stdin, os.Stdout, os.Stderr),
+		interp.ExecHandler(execHandler(interp.DefaultExecHandler(2*time.Second))),
+	)
 	if err != nil {
 		return err
 	}
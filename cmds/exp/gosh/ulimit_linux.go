@@ -0,0 +1,124 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/u-root/u-root/pkg/rlimit"
+	"mvdan.cc/sh/v3/interp"
+)
+
+var errUlimitUsage = errors.New("usage: ulimit [-SH] [-a | -RESOURCE... [LIMIT]]")
+
+func init() {
+	builtins["ulimit"] = ulimit
+}
+
+// ulimit prints or sets the resource limits of the shell, which the
+// commands it runs inherit, like the ulimit of bash:
+//
+//	ulimit [-SH] [-a | -RESOURCE... [LIMIT]]
+//
+// -S and -H are the soft and hard limits; both are set unless one is given,
+// and the soft one is printed. The RESOURCE options are those of
+// rlimit.Resources, -f if none is given. LIMIT may be unlimited, or soft or
+// hard for the current soft or hard limit. Limits in bytes are in KiB.
+func ulimit(hc interp.HandlerContext, args []string) error {
+	var (
+		soft, hard, all bool
+		res             []rlimit.Resource
+		limit           string
+	)
+	for _, a := range args {
+		if limit != "" {
+			return errUlimitUsage
+		}
+		if len(a) < 2 || a[0] != '-' {
+			limit = a
+			continue
+		}
+		for _, o := range []byte(a[1:]) {
+			switch o {
+			case 'S':
+				soft = true
+			case 'H':
+				hard = true
+			case 'a':
+				all = true
+			default:
+				r, ok := rlimit.ByOption(o)
+				if !ok {
+					return fmt.Errorf("-%c: invalid option; %v", o, errUlimitUsage)
+				}
+				res = append(res, r)
+			}
+		}
+	}
+	if all {
+		if limit != "" || len(res) > 0 {
+			return errUlimitUsage
+		}
+		res = rlimit.Resources
+	}
+	if len(res) == 0 {
+		r, _ := rlimit.ByOption('f')
+		res = append(res, r)
+	}
+
+	if limit != "" {
+		if len(res) > 1 {
+			return errUlimitUsage
+		}
+		r := res[0]
+		l, err := r.Get(0)
+		if err != nil {
+			return err
+		}
+		var v uint64
+		switch limit {
+		case "soft":
+			v = l.Cur
+		case "hard":
+			v = l.Max
+		default:
+			if v, err = rlimit.ParseValue(limit, r.Scale); err != nil {
+				return err
+			}
+		}
+		if !soft && !hard {
+			soft, hard = true, true
+		}
+		if soft {
+			l.Cur = v
+		}
+		if hard {
+			l.Max = v
+		}
+		return r.Set(0, l)
+	}
+
+	for _, r := range res {
+		l, err := r.Get(0)
+		if err != nil {
+			return err
+		}
+		v := l.Cur
+		if hard && !soft {
+			v = l.Max
+		}
+		if len(res) == 1 {
+			fmt.Fprintln(hc.Stdout, rlimit.FormatValue(v, r.Scale))
+			continue
+		}
+		opt := fmt.Sprintf("-%c", r.Option)
+		if r.Scale == 1024 {
+			opt = "kbytes, " + opt
+		}
+		fmt.Fprintf(hc.Stdout, "%-36s (%s) %s\n", r.Description, opt, rlimit.FormatValue(v, r.Scale))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/rlimit"
+	"mvdan.cc/sh/v3/interp"
+)
+
+func TestUlimit(t *testing.T) {
+	core, _ := rlimit.ByName("core")
+	l, err := core.Get(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer core.Set(0, l)
+
+	for _, tt := range []struct {
+		script string
+		want   string
+	}{
+		{script: "ulimit -S -c 0; ulimit -c", want: "0\n"},
+		{script: "ulimit -Sc 8; ulimit -c; grep 'Max core' /proc/self/limits | tr -s ' ' | cut -d' ' -f5", want: "8\n8192\n"},
+		{script: "ulimit -Sc hard; ulimit -Hc; ulimit -c", want: rlimit.FormatValue(l.Max, 1024) + "\n" + rlimit.FormatValue(l.Max, 1024) + "\n"},
+		{script: "ulimit -c x || echo failed", want: "ulimit: invalid limit \"x\"\nfailed\n"},
+		{script: "ulimit -z || echo failed", want: "ulimit: -z: invalid option; " + errUlimitUsage.Error() + "\nfailed\n"},
+		{script: "ulimit -a -c || echo failed", want: "ulimit: " + errUlimitUsage.Error() + "\nfailed\n"},
+	} {
+		var out bytes.Buffer
+		r, err := interp.New(
+			interp.StdIO(nil, &out, &out),
+			interp.ExecHandler(execHandler(interp.DefaultExecHandler(2*time.Second))),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := (shell{}).run(r, strings.NewReader(tt.script), ""); err != nil {
+			t.Errorf("%q: %v", tt.script, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%q printed %q, want %q", tt.script, out.String(), tt.want)
+		}
+	}
+
+	var out bytes.Buffer
+	r, err := interp.New(interp.StdIO(nil, &out, &out), interp.ExecHandler(execHandler(interp.DefaultExecHandler(0))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (shell{}).run(r, strings.NewReader("ulimit -a"), ""); err != nil || strings.Count(out.String(), "\n") != len(rlimit.Resources) ||
+		!strings.Contains(out.String(), "(kbytes, -c)") || !strings.Contains(out.String(), "(-n)") {
+		t.Errorf("ulimit -a = %v, printed %q", err, out.String())
+	}
+}
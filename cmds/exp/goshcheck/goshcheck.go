@@ -0,0 +1,366 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// goshcheck checks scripts for gosh, so that their errors are caught when
+// the image is built rather than when they run on a machine.
+//
+// Synopsis:
+//
+//	goshcheck [-env NAME,...] [FILE...]
+//
+// Description:
+//
+//	goshcheck parses each FILE, or stdin, with the parser of gosh, and
+//	prints what it finds as FILE:LINE:COL: MESSAGE. It exits with status 1
+//	if it finds anything.
+//
+//	It flags:
+//
+//	- syntax errors;
+//	- variables that are used but never assigned in the script, unless
+//	  they are well-known, like HOME or PATH, in -env, or used as
+//	  ${NAME:-default} and the like;
+//	- bad redirects: >& to something not a file descriptor, 2>&1 before
+//	  >FILE, and a FILE read and written by the same command;
+//	- what the interpreter of gosh does not run, or runs differently
+//	  from bash: coprocesses, select, extended globs, ;& and ;;&,
+//	  redirects of file descriptors other than 0, 1 and 2, <>, <&, >|,
+//	  umask, fg, bg, wait with arguments, trap of signals other than EXIT
+//	  and ERR, read options other than -r and -p, and bash builtins it
+//	  lacks.
+//
+// Options:
+//
+//	-env: comma-separated names of variables set in the environment of
+//	      the scripts
+//
+// Example:
+//
+//	goshcheck -env SERVER,TOKEN provision.sh
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+var env = flag.String("env", "", "comma-separated names of variables set in the environment of the scripts")
+
+// knownVars are set by the shell or the environment of every script.
+var knownVars = map[string]bool{
+	"HOME": true, "PATH": true, "PWD": true, "OLDPWD": true, "IFS": true,
+	"UID": true, "EUID": true, "GID": true, "PPID": true, "USER": true,
+	"SHELL": true, "TERM": true, "HOSTNAME": true, "RANDOM": true,
+	"LINENO": true, "SECONDS": true, "OPTIND": true, "OPTARG": true,
+	"REPLY": true, "DIRSTACK": true, "PS1": true, "PS2": true, "PS4": true,
+	"TMPDIR": true, "LANG": true,
+}
+
+// missingBuiltins are bash builtins that gosh does not have.
+var missingBuiltins = map[string]bool{
+	"caller": true, "disown": true, "hash": true, "jobs": true,
+	"mapfile": true, "readarray": true, "times": true,
+}
+
+// finding is a problem found in a script.
+type finding struct {
+	pos syntax.Pos
+	msg string
+}
+
+// checker checks a script.
+type checker struct {
+	env      map[string]bool
+	assigned map[string]bool
+	used     map[string]bool
+	findings []finding
+}
+
+func (c *checker) addf(pos syntax.Pos, format string, args ...interface{}) {
+	c.findings = append(c.findings, finding{pos: pos, msg: fmt.Sprintf(format, args...)})
+}
+
+// check returns what is found in the script read from r.
+func check(name string, r io.Reader, env []string) ([]finding, error) {
+	f, err := syntax.NewParser().Parse(r, name)
+	if err != nil {
+		var perr syntax.ParseError
+		var lerr syntax.LangError
+		switch {
+		case errors.As(err, &perr):
+			return []finding{{pos: perr.Pos, msg: perr.Text}}, nil
+		case errors.As(err, &lerr):
+			return []finding{{pos: lerr.Pos, msg: lerr.Feature + " is not supported"}}, nil
+		}
+		return nil, err
+	}
+	c := &checker{
+		env:      map[string]bool{},
+		assigned: map[string]bool{},
+		used:     map[string]bool{},
+	}
+	for _, v := range env {
+		c.env[v] = true
+	}
+	// Functions may run before or after the assignments they rely on, so
+	// a variable is only undefined if it is not assigned anywhere.
+	syntax.Walk(f, c.assignments)
+	syntax.Walk(f, c.check)
+	sort.SliceStable(c.findings, func(i, j int) bool {
+		return c.findings[i].pos.Offset() < c.findings[j].pos.Offset()
+	})
+	return c.findings, nil
+}
+
+// assignments records the variables assigned by node.
+func (c *checker) assignments(node syntax.Node) bool {
+	switch x := node.(type) {
+	case *syntax.Assign:
+		if x.Name != nil {
+			c.assigned[x.Name.Value] = true
+		}
+	case *syntax.WordIter:
+		c.assigned[x.Name.Value] = true
+	case *syntax.ParamExp:
+		if x.Exp != nil && (x.Exp.Op == syntax.AssignUnset || x.Exp.Op == syntax.AssignUnsetOrNull) {
+			c.assigned[x.Param.Value] = true
+		}
+	case *syntax.BinaryArithm:
+		switch x.Op {
+		case syntax.Assgn, syntax.AddAssgn, syntax.SubAssgn, syntax.MulAssgn,
+			syntax.QuoAssgn, syntax.RemAssgn, syntax.AndAssgn, syntax.OrAssgn,
+			syntax.XorAssgn, syntax.ShlAssgn, syntax.ShrAssgn:
+			if w, ok := x.X.(*syntax.Word); ok {
+				c.assigned[w.Lit()] = true
+			}
+		}
+	case *syntax.UnaryArithm:
+		if x.Op == syntax.Inc || x.Op == syntax.Dec {
+			if w, ok := x.X.(*syntax.Word); ok {
+				c.assigned[w.Lit()] = true
+			}
+		}
+	case *syntax.CallExpr:
+		if len(x.Args) == 0 {
+			break
+		}
+		args := x.Args[1:]
+		switch x.Args[0].Lit() {
+		case "read":
+			for i := 0; i < len(args); i++ {
+				switch a := args[i].Lit(); {
+				case a == "-p":
+					i++
+				case strings.HasPrefix(a, "-"):
+				default:
+					c.assigned[a] = true
+				}
+			}
+		case "getopts":
+			if len(args) >= 2 {
+				c.assigned[args[1].Lit()] = true
+			}
+		}
+	}
+	return true
+}
+
+// check records the problems of node.
+func (c *checker) check(node syntax.Node) bool {
+	switch x := node.(type) {
+	case *syntax.ParamExp:
+		c.checkParam(x)
+	case *syntax.Stmt:
+		c.checkRedirects(x.Redirs)
+	case *syntax.CallExpr:
+		c.checkCall(x)
+	case *syntax.CoprocClause:
+		c.addf(x.Pos(), "coproc is not supported by gosh")
+	case *syntax.TestDecl:
+		c.addf(x.Pos(), "@test is not supported by gosh")
+	case *syntax.ForClause:
+		if x.Select {
+			c.addf(x.Pos(), "select is not supported by gosh")
+		}
+	case *syntax.ExtGlob:
+		c.addf(x.Pos(), "extended glob %s is not supported by gosh", x.Op)
+	case *syntax.CaseItem:
+		if x.Op != syntax.Break {
+			c.addf(x.OpPos, "%s in case is run as ;; by gosh", x.Op)
+		}
+	}
+	return true
+}
+
+// checkParam flags the use of variables that are never assigned.
+func (c *checker) checkParam(x *syntax.ParamExp) {
+	if x.Param == nil || x.Names != 0 {
+		return
+	}
+	name := x.Param.Value
+	if !syntax.ValidName(name) || knownVars[name] || c.env[name] || c.assigned[name] || c.used[name] {
+		// Special parameters, like $1 or $?, are not valid names.
+		return
+	}
+	if x.Exp != nil {
+		switch x.Exp.Op {
+		case syntax.AlternateUnset, syntax.AlternateUnsetOrNull,
+			syntax.DefaultUnset, syntax.DefaultUnsetOrNull,
+			syntax.ErrorUnset, syntax.ErrorUnsetOrNull:
+			return
+		}
+	}
+	c.used[name] = true
+	c.addf(x.Pos(), "%s is used but never assigned", name)
+}
+
+// checkRedirects flags bad and unsupported redirects of a statement.
+func (c *checker) checkRedirects(redirs []*syntax.Redirect) {
+	stderrToStdout := false
+	reads := map[string]bool{}
+	for _, rd := range redirs {
+		fd := "1"
+		if rd.Op == syntax.RdrIn || rd.Op == syntax.DplIn || rd.Op == syntax.RdrInOut {
+			fd = "0"
+		}
+		if rd.N != nil {
+			fd = rd.N.Value
+			if fd != "0" && fd != "1" && fd != "2" {
+				c.addf(rd.Pos(), "gosh only redirects file descriptors 0, 1 and 2, not %s", fd)
+				continue
+			}
+		}
+		target := ""
+		if rd.Word != nil {
+			target = rd.Word.Lit()
+		}
+		switch rd.Op {
+		case syntax.RdrInOut, syntax.DplIn, syntax.ClbOut:
+			c.addf(rd.OpPos, "%s is not supported by gosh", rd.Op)
+		case syntax.DplOut:
+			switch target {
+			case "1", "2":
+				if fd == "2" && target == "1" {
+					stderrToStdout = true
+				}
+			case "-":
+				c.addf(rd.OpPos, "closing file descriptors with >&- is not supported by gosh")
+			case "":
+				c.addf(rd.OpPos, ">& needs a file descriptor; use &> to redirect stdout and stderr to a file")
+			default:
+				if strings.Trim(target, "0123456789") == "" {
+					c.addf(rd.OpPos, "gosh only redirects to file descriptors 1 and 2, not %s", target)
+				} else {
+					c.addf(rd.OpPos, ">& needs a file descriptor; use &> to redirect stdout and stderr to a file")
+				}
+			}
+		case syntax.RdrIn:
+			if target != "" {
+				reads[target] = true
+			}
+		case syntax.RdrOut, syntax.AppOut, syntax.RdrAll, syntax.AppAll:
+			if fd == "1" && stderrToStdout && (rd.Op == syntax.RdrOut || rd.Op == syntax.AppOut) {
+				c.addf(rd.OpPos, "2>&1 before %s%s sends stderr to the old stdout, not to %s; put 2>&1 last", rd.Op, target, target)
+			}
+			if rd.Op != syntax.AppOut && rd.Op != syntax.AppAll && reads[target] {
+				c.addf(rd.OpPos, "%s is truncated before it is read", target)
+			}
+		}
+	}
+}
+
+// checkCall flags builtins, and uses of them, that gosh does not run.
+func (c *checker) checkCall(x *syntax.CallExpr) {
+	if len(x.Args) == 0 {
+		return
+	}
+	name := x.Args[0].Lit()
+	args := x.Args[1:]
+	switch {
+	case name == "umask", name == "fg", name == "bg":
+		c.addf(x.Pos(), "%s is not supported by gosh", name)
+	case missingBuiltins[name]:
+		c.addf(x.Pos(), "%s is a bash builtin that gosh does not have", name)
+	case name == "wait" && len(args) > 0:
+		c.addf(x.Pos(), "wait with arguments is not supported by gosh")
+	case name == "read":
+		for i := 0; i < len(args); i++ {
+			switch a := args[i].Lit(); {
+			case a == "-p":
+				i++
+			case a == "-r":
+			case strings.HasPrefix(a, "-"):
+				c.addf(args[i].Pos(), "read %s is not supported by gosh, only -r and -p", a)
+			}
+		}
+	case name == "trap":
+		if len(args) > 0 && (args[0].Lit() == "-l" || args[0].Lit() == "-p") {
+			c.addf(args[0].Pos(), "trap %s is not supported by gosh", args[0].Lit())
+			return
+		}
+		if len(args) < 2 {
+			return
+		}
+		for _, a := range args[1:] {
+			if s := a.Lit(); s != "EXIT" && s != "ERR" {
+				c.addf(a.Pos(), "gosh only traps EXIT and ERR, not %s", s)
+			}
+		}
+	}
+}
+
+func run(stdin io.Reader, stdout io.Writer, env []string, files []string) (int, error) {
+	var n int
+	report := func(name string, r io.Reader) error {
+		findings, err := check(name, r, env)
+		if err != nil {
+			return err
+		}
+		for _, f := range findings {
+			fmt.Fprintf(stdout, "%s:%d:%d: %s\n", name, f.pos.Line(), f.pos.Col(), f.msg)
+		}
+		n += len(findings)
+		return nil
+	}
+	if len(files) == 0 {
+		return n, report("<stdin>", stdin)
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return n, err
+		}
+		err = report(name, f)
+		f.Close()
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func main() {
+	log.SetPrefix("goshcheck: ")
+	log.SetFlags(0)
+	flag.Parse()
+	var names []string
+	if *env != "" {
+		names = strings.Split(*env, ",")
+	}
+	n, err := run(os.Stdin, os.Stdout, names, flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if n > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		script string
+		env    []string
+		want   []string
+	}{
+		{
+			name: "clean",
+			script: `#!/bin/gosh
+set -e
+server=${SERVER:-http://192.0.2.1}
+for f in a b; do
+	echo "$f" "$server" "$HOME" "$1" "$#" "$?"
+done
+i=0
+: $((i++)) $((j += 2)) ${k:=3}
+echo "$j" "$k"
+read -r line
+getopts ab opt
+echo "$line $opt" >out 2>&1
+cat <in >>in
+trap 'rm -f out' EXIT
+`,
+		},
+		{
+			name: "undefined",
+			script: `f() {
+	echo "$later $TOKEN"
+}
+later=1
+echo $undefined ${undefined} ${#other}
+`,
+			env: []string{"TOKEN"},
+			want: []string{
+				"5:6: undefined is used but never assigned",
+				"5:30: other is used but never assigned",
+			},
+		},
+		{
+			name: "redirects",
+			script: `cmd 2>&1 >log
+cmd >&log
+cmd 2>&3
+cmd >&-
+cmd 3>file
+cmd <file >file
+cmd <>file
+cmd <&0
+cmd >|file
+`,
+			want: []string{
+				"1:10: 2>&1 before >log sends stderr to the old stdout, not to log; put 2>&1 last",
+				"2:5: >& needs a file descriptor; use &> to redirect stdout and stderr to a file",
+				"3:6: gosh only redirects to file descriptors 1 and 2, not 3",
+				"4:5: closing file descriptors with >&- is not supported by gosh",
+				"5:5: gosh only redirects file descriptors 0, 1 and 2, not 3",
+				"6:11: file is truncated before it is read",
+				"7:5: <> is not supported by gosh",
+				"8:5: <& is not supported by gosh",
+				"9:5: >| is not supported by gosh",
+			},
+		},
+		{
+			name: "unsupported",
+			script: `coproc cat
+select x in a b; do echo $x; done
+case a in @(a|b)) echo a ;& *) echo b ;; esac
+umask 022
+wait $!
+mapfile lines
+read -n 1 c
+trap 'echo int' INT
+echo $lines $c
+`,
+			want: []string{
+				"1:1: coproc is not supported by gosh",
+				"2:1: select is not supported by gosh",
+				"3:11: extended glob @( is not supported by gosh",
+				"3:26: ;& in case is run as ;; by gosh",
+				"4:1: umask is not supported by gosh",
+				"5:1: wait with arguments is not supported by gosh",
+				"6:1: mapfile is a bash builtin that gosh does not have",
+				"7:6: read -n is not supported by gosh, only -r and -p",
+				"8:17: gosh only traps EXIT and ERR, not INT",
+				"9:6: lines is used but never assigned",
+			},
+		},
+		{
+			name:   "syntax error",
+			script: "if true; then\necho\n",
+			want:   []string{`1:1: if statement must end with "fi"`},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := check("script", strings.NewReader(tt.script), tt.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.pos.String()+": "+f.msg)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.sh")
+	bad := filepath.Join(dir, "bad.sh")
+	if err := os.WriteFile(good, []byte("echo $PATH\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte("echo $nope\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	n, err := run(nil, &out, nil, []string{good, bad})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := bad + ":1:6: nope is used but never assigned\n"; n != 1 || out.String() != want {
+		t.Errorf("run = %d, %q, want 1, %q", n, out.String(), want)
+	}
+
+	out.Reset()
+	n, err = run(strings.NewReader("echo $nope\n"), &out, []string{"nope"}, nil)
+	if err != nil || n != 0 || out.Len() != 0 {
+		t.Errorf("run on stdin with -env nope = %d, %q, %v, want 0, \"\", nil", n, out.String(), err)
+	}
+
+	if _, err := run(nil, &out, nil, []string{filepath.Join(dir, "missing.sh")}); err == nil {
+		t.Errorf("run on a missing file = nil, want error")
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// gptpart lists and changes the names, GUIDs and attributes of GPT
+// partitions, e.g. the A/B boot attributes.
+//
+// Synopsis:
+//
+//	gptpart DEVICE
+//	gptpart -i N [-name NAME] [-type GUID] [-id GUID] [-attrs ATTRS]
+//	        [-priority P] [-tries T] [-successful=true|false] DEVICE
+//
+// Description:
+//
+//	Without -i, the partitions of DEVICE, a whole disk, are listed with
+//	their number, name, type and unique GUIDs, attributes in hex, and A/B
+//	boot attributes.
+//
+//	With -i, partition N, counting from 1, is changed as the other flags
+//	say, in both the primary and the backup GPT. -attrs sets all 64
+//	attribute bits, before -priority, -tries and -successful set the A/B
+//	boot attributes in bits 48 to 56, as ChromeOS kernel partitions have
+//	them.
+//
+// Example:
+//
+//	gptpart -i 2 -priority 2 -tries 6 -successful=false /dev/sda
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/rekby/gpt"
+	"github.com/u-root/u-root/pkg/mount/block"
+)
+
+var (
+	index      = flag.Int("i", 0, "number of the partition to change, from 1")
+	name       = flag.String("name", "", "set the name of the partition")
+	typ        = flag.String("type", "", "set the type GUID of the partition")
+	id         = flag.String("id", "", "set the unique GUID of the partition")
+	attrs      = flag.String("attrs", "", "set the attribute bits of the partition, e.g. 0x1")
+	priority   = flag.Int("priority", 0, "set the A/B boot priority of the partition, 0 to 15")
+	tries      = flag.Int("tries", 0, "set the A/B boot tries of the partition, 0 to 15")
+	successful = flag.Bool("successful", false, "set whether the partition booted successfully")
+)
+
+// edit is a change to a partition. Nil fields are not changed.
+type edit struct {
+	name       *string
+	typ        *string
+	id         *string
+	attrs      *string
+	priority   *int
+	tries      *int
+	successful *bool
+}
+
+// apply changes p as e says.
+func (e edit) apply(p *gpt.Partition) error {
+	if e.name != nil {
+		if err := block.SetPartName(p, *e.name); err != nil {
+			return err
+		}
+	}
+	if e.typ != nil {
+		g, err := gpt.StringToGuid(*e.typ)
+		if err != nil {
+			return fmt.Errorf("invalid type GUID %q: %v", *e.typ, err)
+		}
+		p.Type = gpt.PartType(g)
+	}
+	if e.id != nil {
+		g, err := gpt.StringToGuid(*e.id)
+		if err != nil {
+			return fmt.Errorf("invalid GUID %q: %v", *e.id, err)
+		}
+		p.Id = g
+	}
+	a := block.PartAttrs(*p)
+	if e.attrs != nil {
+		n, err := strconv.ParseUint(*e.attrs, 0, 64)
+		if err != nil {
+			return fmt.Errorf("invalid attributes %q: %v", *e.attrs, err)
+		}
+		a = block.PartitionAttrs(n)
+	}
+	b := a.Boot()
+	if e.priority != nil {
+		b.Priority = *e.priority
+	}
+	if e.tries != nil {
+		b.Tries = *e.tries
+	}
+	if e.successful != nil {
+		b.Successful = *e.successful
+	}
+	a, err := a.WithBoot(b)
+	if err != nil {
+		return err
+	}
+	block.SetPartAttrs(p, a)
+	return nil
+}
+
+// list prints the partitions of t to w.
+func list(w io.Writer, t *gpt.Table) {
+	for i, p := range t.Partitions {
+		if p.IsEmpty() {
+			continue
+		}
+		a := block.PartAttrs(p)
+		b := a.Boot()
+		fmt.Fprintf(w, "%d\t%q\ttype=%v id=%v attrs=%#x priority=%d tries=%d successful=%t\n",
+			i+1, p.Name(), p.Type, p.Id, uint64(a), b.Priority, b.Tries, b.Successful)
+	}
+}
+
+func run(dev string, e edit) error {
+	d, err := block.Device(dev)
+	if err != nil {
+		return err
+	}
+	t, err := d.GPTTable()
+	if err != nil {
+		return fmt.Errorf("reading GPT of %s: %v", dev, err)
+	}
+	if *index == 0 {
+		list(os.Stdout, t)
+		return nil
+	}
+	if *index < 1 || *index > len(t.Partitions) {
+		return fmt.Errorf("partition %d is not between 1 and %d", *index, len(t.Partitions))
+	}
+	p := &t.Partitions[*index-1]
+	if p.IsEmpty() && e.typ == nil {
+		return fmt.Errorf("partition %d is empty", *index)
+	}
+	if err := e.apply(p); err != nil {
+		return err
+	}
+	return d.WriteGPTTable(t)
+}
+
+func main() {
+	log.SetPrefix("gptpart: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: gptpart [-i N [options]] DEVICE\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	var e edit
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			e.name = name
+		case "type":
+			e.typ = typ
+		case "id":
+			e.id = id
+		case "attrs":
+			e.attrs = attrs
+		case "priority":
+			e.priority = priority
+		case "tries":
+			e.tries = tries
+		case "successful":
+			e.successful = successful
+		}
+	})
+	if err := run(flag.Arg(0), e); err != nil {
+		log.Fatal(err)
+	}
+}
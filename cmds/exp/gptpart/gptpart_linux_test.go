@@ -0,0 +1,85 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rekby/gpt"
+	"github.com/u-root/u-root/pkg/mount/block"
+)
+
+func TestEdit(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	yes := true
+
+	const (
+		linuxFS = "0FC63DAF-8483-4772-8E79-3D69D8477DE4"
+		someID  = "0D1DD2E4-0D1B-4A3C-9DB4-2C5A2D3F9B61"
+	)
+	for _, tt := range []struct {
+		name    string
+		e       edit
+		want    func(p gpt.Partition) bool
+		wantErr bool
+	}{
+		{
+			name: "name",
+			e:    edit{name: str("ROOT-B")},
+			want: func(p gpt.Partition) bool { return p.Name() == "ROOT-B" },
+		},
+		{
+			name: "guids",
+			e:    edit{typ: str(linuxFS), id: str(strings.ToLower(someID))},
+			want: func(p gpt.Partition) bool { return p.Type.String() == linuxFS && p.Id.String() == someID },
+		},
+		{
+			name: "boot",
+			e:    edit{priority: num(2), tries: num(6)},
+			// The partition was successful, which stays.
+			want: func(p gpt.Partition) bool {
+				return block.PartAttrs(p).Boot() == block.BootAttrs{Priority: 2, Tries: 6, Successful: true}
+			},
+		},
+		{
+			name: "attrs then boot",
+			e:    edit{attrs: str("0x4"), successful: &yes},
+			want: func(p gpt.Partition) bool { return block.PartAttrs(p) == 0x0100_0000_0000_0004 },
+		},
+		{name: "bad GUID", e: edit{id: str("not-a-guid")}, wantErr: true},
+		{name: "bad attrs", e: edit{attrs: str("x")}, wantErr: true},
+		{name: "bad tries", e: edit{tries: num(16)}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var p gpt.Partition
+			block.SetPartAttrs(&p, 0x0100_0000_0000_0000)
+			err := tt.e.apply(&p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apply = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && !tt.want(p) {
+				t.Errorf("apply made partition %q type=%v id=%v attrs=%#x", p.Name(), p.Type, p.Id, block.PartAttrs(p))
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	table := gpt.NewTable(1<<20, nil)
+	p := &table.Partitions[1]
+	p.Type = gpt.PartType(block.SystemPartitionGUID)
+	block.SetPartName(p, "EFI")
+	a, _ := block.PartitionAttrs(0).WithBoot(block.BootAttrs{Priority: 1, Tries: 2})
+	block.SetPartAttrs(p, a)
+
+	var out strings.Builder
+	list(&out, &table)
+	want := `2	"EFI"	type=C12A7328-F81F-11D2-BA4B-00A0C93EC93B id=00000000-0000-0000-0000-000000000000 attrs=0x21000000000000 priority=1 tries=2 successful=false` + "\n"
+	if out.String() != want {
+		t.Errorf("list = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// growpart grows a GPT partition to fill the free space after it.
+//
+// Synopsis:
+//
+//	growpart [-N] DISK PARTITION
+//
+// Description:
+//
+//	Partition PARTITION, counting from 1, of DISK is grown up to the next
+//	partition or the end of the disk, and the backup GPT is moved to the
+//	end of the disk, e.g. after writing a golden image smaller than the
+//	disk. The kernel is told, even if the partition is mounted, and the
+//	file system can then be grown, e.g. with resize2fs.
+//
+//	As with growpart of cloud-utils, a line starting with CHANGED: or
+//	NOCHANGE: is printed, and the exit code is 0 if the partition was
+//	grown, 1 if it could not be, and 2 on errors.
+//
+// Options:
+//
+//	-N: print what would be done, but do not do it
+//
+// Example:
+//
+//	growpart /dev/nvme0n1 3 && resize2fs /dev/nvme0n1p3
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/rekby/gpt"
+	"github.com/u-root/u-root/pkg/mount/block"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	dryRun = flag.Bool("N", false, "print what would be done, but do not do it")
+
+	errUsage = errors.New("usage: growpart [-N] DISK PARTITION")
+)
+
+// readOnly is a disk that is not written to, for -N.
+type readOnly struct {
+	io.ReadSeeker
+}
+
+func (readOnly) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// sectorSize returns the logical sector size of f, or 512 if it is not a
+// block device.
+func sectorSize(f *os.File) uint64 {
+	if n, err := unix.IoctlGetInt(int(f.Fd()), unix.BLKSSZGET); err == nil && n > 0 {
+		return uint64(n)
+	}
+	return 512
+}
+
+// grow grows partition n of disk, says what it did on w, and returns whether
+// the partition was grown.
+func grow(w io.Writer, disk string, n int, dryRun bool) (bool, error) {
+	flags := os.O_RDWR
+	if dryRun {
+		flags = os.O_RDONLY
+	}
+	f, err := os.OpenFile(disk, flags, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	ss := sectorSize(f)
+	if _, err := f.Seek(int64(ss), io.SeekStart); err != nil {
+		return false, err
+	}
+	t, err := gpt.ReadTable(f, ss)
+	if err != nil {
+		return false, fmt.Errorf("reading the GPT of %s: %v", disk, err)
+	}
+	if n < 1 || n > len(t.Partitions) || t.Partitions[n-1].IsEmpty() {
+		return false, fmt.Errorf("%s has no partition %d", disk, n)
+	}
+	old := t.Partitions[n-1]
+
+	var rw io.ReadWriteSeeker = f
+	if dryRun {
+		rw = readOnly{f}
+	}
+	_, nt, err := block.GrowGPTPartition(rw, ss, n)
+	if err == block.ErrNoChange {
+		fmt.Fprintf(w, "NOCHANGE: partition %d is size %d. it cannot be grown\n", n, old.LastLBA-old.FirstLBA+1)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	p := nt.Partitions[n-1]
+	fmt.Fprintf(w, "CHANGED: partition=%d start=%d old: size=%d end=%d new: size=%d end=%d\n",
+		n, p.FirstLBA, old.LastLBA-old.FirstLBA+1, old.LastLBA+1, p.LastLBA-p.FirstLBA+1, p.LastLBA+1)
+	if dryRun {
+		return true, nil
+	}
+	if err := f.Sync(); err != nil {
+		return true, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeDevice == 0 {
+		return true, err
+	}
+	// The partition table cannot be re-read while partitions are in use,
+	// but the kernel can be told of the new size of the partition.
+	if err := unix.IoctlSetInt(int(f.Fd()), unix.BLKRRPART, 0); err != nil {
+		start, length := int64(p.FirstLBA*ss), int64((p.LastLBA-p.FirstLBA+1)*ss)
+		if err := block.ResizePartition(f, n, start, length); err != nil {
+			return true, fmt.Errorf("telling the kernel of the new size of partition %d: %v", n, err)
+		}
+	}
+	return true, nil
+}
+
+func main() {
+	log.SetPrefix("growpart: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() != 2 {
+		log.Print(errUsage)
+		os.Exit(2)
+	}
+	n, err := strconv.Atoi(flag.Arg(1))
+	if err != nil {
+		log.Printf("invalid partition %q", flag.Arg(1))
+		os.Exit(2)
+	}
+	grown, err := grow(os.Stdout, flag.Arg(0), n, *dryRun)
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+	if !grown {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rekby/gpt"
+	"github.com/u-root/u-root/pkg/mount/block"
+)
+
+func TestGrow(t *testing.T) {
+	const size = 4 << 20
+	disk := filepath.Join(t.TempDir(), "disk")
+	f, err := os.Create(disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	tbl := gpt.NewTable(size, &gpt.NewTableArgs{SectorSize: 512})
+	first := tbl.Header.FirstUsableLBA
+	for i, n := range []uint64{2048, 1024} {
+		tbl.Partitions[i].Type = gpt.PartType{0xaf, 0x3d, 0xc6, 0x0f, 0x83, 0x84, 0x72, 0x47, 0x8e, 0x79, 0x3d, 0x69, 0xd8, 0x47, 0x7d, 0xe4}
+		tbl.Partitions[i].FirstLBA = first
+		tbl.Partitions[i].LastLBA = first + n - 1
+		first += n
+	}
+	if err := block.WriteGPT(f, &tbl); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(2 * size); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	image, err := os.ReadFile(disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		n       int
+		dryRun  bool
+		want    string
+		grown   bool
+		wantErr bool
+	}{
+		{
+			name:   "dry run",
+			n:      2,
+			dryRun: true,
+			want:   "CHANGED: partition=2 start=2082 old: size=1024 end=3106 new: size=14269 end=16351\n",
+			grown:  true,
+		},
+		{
+			name:  "grow",
+			n:     2,
+			want:  "CHANGED: partition=2 start=2082 old: size=1024 end=3106 new: size=14269 end=16351\n",
+			grown: true,
+		},
+		{
+			name: "next partition",
+			n:    1,
+			want: "NOCHANGE: partition 1 is size 2048. it cannot be grown\n",
+		},
+		{
+			name:    "no partition",
+			n:       3,
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(disk, image, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			grown, err := grow(&out, disk, tt.n, tt.dryRun)
+			if (err != nil) != tt.wantErr || grown != tt.grown || out.String() != tt.want {
+				t.Fatalf("grow = %v, %v, printing %q, want %v, error %v, printing %q", grown, err, out.String(), tt.grown, tt.wantErr, tt.want)
+			}
+			b, err := os.ReadFile(disk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := !bytes.Equal(b, image); changed != (tt.grown && !tt.dryRun) {
+				t.Errorf("disk changed = %v, want %v", changed, tt.grown && !tt.dryRun)
+			}
+		})
+	}
+}
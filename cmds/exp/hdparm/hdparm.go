@@ -19,12 +19,33 @@
 // Synopsis:
 //
 //	hdparm [--i] [--security-unlock[=password]] [--user-master|--timeout] [device ...]
+//	hdparm [--security-set-pass=password|--security-disable=password] [--user-master] [device ...]
+//	hdparm [--security-erase=password|--security-erase-enhanced=password] [--user-master] [device ...]
+//
+// Description:
+//
+//	--security-erase erases all user data on the drive, which must have a
+//	password set with --security-set-pass first, and disables security.
+//	It waits for as long as the drive estimates the erase takes, or 12
+//	hours, rather than --timeout. --security-erase-enhanced also erases
+//	reallocated sectors, or changes the encryption key of self-encrypting
+//	drives.
+//
+//	Frozen drives, see --i, do not take security commands. Most firmware
+//	freezes drives at boot; suspending and resuming the machine usually
+//	unfreezes them.
+//
+// Example:
+//
+//	hdparm --security-set-pass=secret /dev/sda
+//	hdparm --security-erase-enhanced=secret /dev/sda
 package main
 
 import (
 	"flag"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/u-root/u-root/pkg/mount/scuzz"
@@ -39,33 +60,50 @@ var (
 	verbose         = flag.Bool("v", false, "verbose log")
 	debug           = func(string, ...interface{}) {}
 	unlock          = flag.String("security-unlock", "", "Unlock the drive with a password")
+	setPass         = flag.String("security-set-pass", "", "Set the password of the drive")
+	disable         = flag.String("security-disable", "", "Disable security of the drive with a password")
+	erase           = flag.String("security-erase", "", "Erase the drive with a password")
+	eraseEnhanced   = flag.String("security-erase-enhanced", "", "Erase the drive, and its reallocated sectors, with a password")
 	identify        = flag.Bool("i", false, "Get drive identifying information")
-	admin           = flag.Bool("user-master", false, "Unlock admin (true) or user (false)")
+	admin           = flag.Bool("user-master", false, "Use the admin (true) or user (false) password")
 	timeoutDuration = flag.String("timeout", "15s", "Timeout for operations expressed as a Go duration (e.g. 15s)")
-	verbs           = []string{"security-unlock", "i"}
+	verbs           = map[string]op{
+		"security-unlock":         unlockop,
+		"security-set-pass":       setpassop,
+		"security-disable":        disableop,
+		"security-erase":          eraseop,
+		"security-erase-enhanced": eraseop,
+		"i":                       identifyop,
+	}
 )
 
 // The hdparm switches can conflict. This function returns nil if there is no conflict, and a (hopefully)
 // helpful error message otherwise. As a side effect it assigns verb.
-// TODO: use the visitor pattern to make this better. The next flag that's added will include that fix.
 func checkVerbs() (op, error) {
 	var v []string
 	var verb op
 
-	if len(*unlock) > 0 {
-		verb = unlockop
-		v = append(v, "security-unlock")
-	}
-	if *identify {
-		verb = identifyop
-		v = append(v, "i")
-	}
+	flag.Visit(func(f *flag.Flag) {
+		// Empty passwords, or -i=false, invoke nothing.
+		if s := f.Value.String(); s == "" || s == "false" {
+			return
+		}
+		if o, ok := verbs[f.Name]; ok {
+			verb = o
+			v = append(v, f.Name)
+		}
+	})
 
 	if len(v) > 1 {
 		return nil, fmt.Errorf("%v verbs were invoked and only one is allowed", v)
 	}
 	if len(v) < 1 {
-		return nil, fmt.Errorf("no verbs were invoked and one of %v is required", verbs)
+		var names []string
+		for n := range verbs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("no verbs were invoked and one of %v is required", names)
 	}
 	return verb, nil
 }
@@ -74,6 +112,21 @@ func unlockop(d scuzz.Disk) (string, error) {
 	return "", d.Unlock(*unlock, *admin)
 }
 
+func setpassop(d scuzz.Disk) (string, error) {
+	return "", d.SetPassword(*setPass, *admin)
+}
+
+func disableop(d scuzz.Disk) (string, error) {
+	return "", d.DisablePassword(*disable, *admin)
+}
+
+func eraseop(d scuzz.Disk) (string, error) {
+	if len(*eraseEnhanced) > 0 {
+		return "", d.SecurityErase(*eraseEnhanced, *admin, true)
+	}
+	return "", d.SecurityErase(*erase, *admin, false)
+}
+
 func identifyop(d scuzz.Disk) (string, error) {
 	i, err := d.Identify()
 	if err != nil {
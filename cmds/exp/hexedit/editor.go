@@ -0,0 +1,438 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const bytesPerRow = 16
+
+// Keys that are not a byte.
+const (
+	keyUp = -1 - iota
+	keyDown
+	keyLeft
+	keyRight
+	keyPgUp
+	keyPgDn
+	keyHome
+	keyEnd
+	keyEsc
+)
+
+// Control keys.
+const (
+	ctrlC     = 0x03
+	ctrlG     = 0x07
+	ctrlU     = 0x15
+	ctrlW     = 0x17
+	ctrlX     = 0x18
+	tab       = '\t'
+	enter     = '\r'
+	backspace = 0x7f
+)
+
+var escapes = map[string]int{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"OA": keyUp, "OB": keyDown, "OC": keyRight, "OD": keyLeft,
+	"[5~": keyPgUp, "[6~": keyPgDn,
+	"[H": keyHome, "[F": keyEnd, "OH": keyHome, "OF": keyEnd,
+	"[1~": keyHome, "[4~": keyEnd,
+}
+
+// readKey reads a key, decoding the escape sequences of special keys. An
+// escape that is not followed by more input at once is the Escape key.
+func readKey(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0x1b || r.Buffered() == 0 {
+		if b == 0x1b {
+			return keyEsc, nil
+		}
+		return int(b), nil
+	}
+	var seq []byte
+	for r.Buffered() > 0 && len(seq) < 4 {
+		c, _ := r.ReadByte()
+		seq = append(seq, c)
+		if k, ok := escapes[string(seq)]; ok {
+			return k, nil
+		}
+		// The sequence ends with a letter or ~.
+		if len(seq) > 1 && (c == '~' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			break
+		}
+	}
+	return keyEsc, nil
+}
+
+// file is what is edited, *os.File is one.
+type file interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// editor is the state of a hex editor, that draws to a terminal and takes
+// keys from it.
+type editor struct {
+	f        file
+	name     string
+	size     int64
+	readOnly bool
+
+	// changes are the bytes changed since the last save.
+	changes map[int64]byte
+
+	cur  int64 // offset of the cursor
+	top  int64 // offset of the first row shown
+	rows int   // number of rows of bytes shown
+
+	ascii  bool // editing the ASCII column rather than the hex one
+	nibble bool // the high nibble of the byte at cur was typed
+
+	// prompt is shown with input while reading an offset to go to.
+	prompt string
+	input  []byte
+
+	msg      string // shown in the status line until the next key
+	quitting bool   // ctrl-C was typed once with unsaved changes
+	done     bool
+}
+
+func newEditor(f file, name string, size int64, readOnly bool, rows int) *editor {
+	if rows < 1 {
+		rows = 1
+	}
+	return &editor{f: f, name: name, size: size, readOnly: readOnly, changes: map[int64]byte{}, rows: rows}
+}
+
+// read returns the bytes from off, at most n, with the changes applied.
+func (e *editor) read(off int64, n int) ([]byte, error) {
+	if off+int64(n) > e.size {
+		n = int(e.size - off)
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	b := make([]byte, n)
+	if _, err := e.f.ReadAt(b, off); err != nil && err != io.EOF {
+		return nil, err
+	}
+	for i := range b {
+		if c, ok := e.changes[off+int64(i)]; ok {
+			b[i] = c
+		}
+	}
+	return b, nil
+}
+
+// move moves the cursor to off, within the file, and scrolls to it.
+func (e *editor) move(off int64) {
+	if off >= e.size {
+		off = e.size - 1
+	}
+	if off < 0 {
+		off = 0
+	}
+	e.cur = off
+	e.nibble = false
+	page := int64(e.rows * bytesPerRow)
+	if e.cur < e.top {
+		e.top = e.cur - e.cur%bytesPerRow
+	}
+	if e.cur >= e.top+page {
+		e.top = e.cur - e.cur%bytesPerRow - page + bytesPerRow
+	}
+}
+
+// set changes the byte at the cursor to b, and returns whether it could.
+func (e *editor) set(b byte) bool {
+	if e.readOnly {
+		e.msg = "read-only"
+		return false
+	}
+	if e.size == 0 {
+		return false
+	}
+	e.changes[e.cur] = b
+	return true
+}
+
+// parseOffset parses an offset to go to: a decimal, 0x hexadecimal or 0
+// octal number, relative to the cursor if it starts with + or -.
+func (e *editor) parseOffset(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	rel := strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-")
+	off, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad offset %q", s)
+	}
+	if rel {
+		off += e.cur
+	}
+	if off < 0 || off >= e.size {
+		return 0, fmt.Errorf("offset %#x is outside the file", off)
+	}
+	return off, nil
+}
+
+// key handles a key.
+func (e *editor) key(k int) error {
+	e.msg = ""
+	if k != ctrlC {
+		e.quitting = false
+	}
+
+	if e.prompt != "" {
+		switch k {
+		case enter:
+			off, err := e.parseOffset(string(e.input))
+			if err != nil {
+				e.msg = err.Error()
+			} else {
+				e.move(off)
+			}
+			e.prompt, e.input = "", nil
+		case keyEsc, ctrlC, ctrlG:
+			e.prompt, e.input = "", nil
+		case backspace, '\b':
+			if len(e.input) > 0 {
+				e.input = e.input[:len(e.input)-1]
+			}
+		default:
+			if k >= 0x20 && k < 0x7f {
+				e.input = append(e.input, byte(k))
+			}
+		}
+		return nil
+	}
+
+	page := int64(e.rows * bytesPerRow)
+	switch k {
+	case keyUp:
+		if e.cur >= bytesPerRow {
+			e.move(e.cur - bytesPerRow)
+		}
+	case keyDown:
+		if e.cur+bytesPerRow < e.size {
+			e.move(e.cur + bytesPerRow)
+		}
+	case keyLeft:
+		e.move(e.cur - 1)
+	case keyRight:
+		e.move(e.cur + 1)
+	case keyPgUp:
+		e.top -= page
+		if e.top < 0 {
+			e.top = 0
+		}
+		e.move(e.cur - page)
+	case keyPgDn:
+		if e.top+page < e.size {
+			e.top += page
+		}
+		e.move(e.cur + page)
+	case keyHome:
+		e.move(0)
+	case keyEnd:
+		e.move(e.size - 1)
+	case tab:
+		e.ascii = !e.ascii
+		e.nibble = false
+	case ctrlG:
+		e.prompt = "goto offset: "
+	case ctrlU:
+		delete(e.changes, e.cur)
+		e.nibble = false
+	case ctrlW, ctrlX:
+		if err := e.save(); err != nil {
+			e.msg = err.Error()
+			return nil
+		}
+		if k == ctrlX {
+			e.done = true
+		}
+	case ctrlC:
+		if len(e.changes) > 0 && !e.quitting {
+			e.quitting = true
+			e.msg = "unsaved changes, ^C again to quit without saving"
+			return nil
+		}
+		e.done = true
+	default:
+		if k < 0 {
+			return nil
+		}
+		if e.ascii {
+			if k >= 0x20 && k < 0x7f && e.set(byte(k)) {
+				e.move(e.cur + 1)
+			}
+			return nil
+		}
+		d, err := strconv.ParseUint(string(rune(k)), 16, 8)
+		if err != nil {
+			return nil
+		}
+		b, err := e.read(e.cur, 1)
+		if err != nil {
+			return err
+		}
+		if len(b) == 0 {
+			return nil
+		}
+		if !e.nibble {
+			e.nibble = e.set(byte(d)<<4 | b[0]&0x0f)
+		} else if e.set(b[0]&0xf0 | byte(d)) {
+			e.move(e.cur + 1)
+		}
+	}
+	return nil
+}
+
+// save writes the changes to the file.
+func (e *editor) save() error {
+	if len(e.changes) == 0 {
+		e.msg = "no changes"
+		return nil
+	}
+	if e.readOnly {
+		return fmt.Errorf("read-only")
+	}
+	offs := make([]int64, 0, len(e.changes))
+	for off := range e.changes {
+		offs = append(offs, off)
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+	// Write runs of changed bytes at once.
+	for i := 0; i < len(offs); {
+		j := i + 1
+		for j < len(offs) && offs[j] == offs[j-1]+1 {
+			j++
+		}
+		run := make([]byte, j-i)
+		for n := range run {
+			run[n] = e.changes[offs[i+n]]
+		}
+		if _, err := e.f.WriteAt(run, offs[i]); err != nil {
+			return fmt.Errorf("writing %d bytes at %#x: %v", len(run), offs[i], err)
+		}
+		i = j
+	}
+	if s, ok := e.f.(interface{ Sync() error }); ok {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+	}
+	e.msg = fmt.Sprintf("wrote %d bytes", len(e.changes))
+	e.changes = map[int64]byte{}
+	return nil
+}
+
+// Terminal attributes.
+const (
+	attrReset   = "\x1b[0m"
+	attrCursor  = "\x1b[7m"
+	attrOther   = "\x1b[4m"
+	attrChanged = "\x1b[1;31m"
+)
+
+// draw draws the screen.
+func (e *editor) draw(w io.Writer) error {
+	b, err := e.read(e.top, e.rows*bytesPerRow)
+	if err != nil {
+		return err
+	}
+	width := len(strconv.FormatInt(e.size, 16))
+	if width < 8 {
+		width = 8
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H\x1b[2J")
+	attr := func(off int64, ascii bool) string {
+		var a string
+		if _, ok := e.changes[off]; ok {
+			a = attrChanged
+		}
+		if off == e.cur {
+			if ascii == e.ascii {
+				a += attrCursor
+			} else {
+				a += attrOther
+			}
+		}
+		return a
+	}
+	for row := 0; row < e.rows; row++ {
+		off := e.top + int64(row*bytesPerRow)
+		if row*bytesPerRow >= len(b) {
+			buf.WriteString("~\r\n")
+			continue
+		}
+		line := b[row*bytesPerRow:]
+		if len(line) > bytesPerRow {
+			line = line[:bytesPerRow]
+		}
+		fmt.Fprintf(&buf, "%0*x  ", width, off)
+		for i := 0; i < bytesPerRow; i++ {
+			if i == bytesPerRow/2 {
+				buf.WriteByte(' ')
+			}
+			if i >= len(line) {
+				buf.WriteString("   ")
+				continue
+			}
+			if a := attr(off+int64(i), false); a != "" {
+				fmt.Fprintf(&buf, "%s%02x%s ", a, line[i], attrReset)
+			} else {
+				fmt.Fprintf(&buf, "%02x ", line[i])
+			}
+		}
+		buf.WriteString(" |")
+		for i, c := range line {
+			if c < 0x20 || c >= 0x7f {
+				c = '.'
+			}
+			if a := attr(off+int64(i), true); a != "" {
+				fmt.Fprintf(&buf, "%s%c%s", a, c, attrReset)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+		buf.WriteString("|\r\n")
+	}
+
+	if e.prompt != "" {
+		fmt.Fprintf(&buf, "%s%s", e.prompt, e.input)
+	} else {
+		buf.WriteString(e.status())
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// status returns the status line.
+func (e *editor) status() string {
+	s := fmt.Sprintf("%s  %#x/%#x", e.name, e.cur, e.size)
+	if len(e.changes) > 0 {
+		s += fmt.Sprintf("  %d changed", len(e.changes))
+	}
+	if e.readOnly {
+		s += "  [read-only]"
+	}
+	if e.msg != "" {
+		return s + "  " + e.msg
+	}
+	return s + "  ^G goto  ^W save  ^X save+quit  ^C quit  Tab hex/ascii"
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeFile is a file in memory that counts its writes.
+type fakeFile struct {
+	b      []byte
+	writes int
+}
+
+func (f *fakeFile) ReadAt(b []byte, off int64) (int, error) {
+	n := copy(b, f.b[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *fakeFile) WriteAt(b []byte, off int64) (int, error) {
+	f.writes++
+	return copy(f.b[off:], b), nil
+}
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a\x1b[A\x1b[6~\x1bOH\r\x1b[Z\x1b"))
+	var got []int
+	for {
+		k, err := readKey(r)
+		if err != nil {
+			break
+		}
+		got = append(got, k)
+	}
+	want := []int{'a', keyUp, keyPgDn, keyHome, enter, keyEsc, keyEsc}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readKey = %v, want %v", got, want)
+	}
+}
+
+func keys(s string, k ...int) []int {
+	var ks []int
+	for _, c := range []byte(s) {
+		ks = append(ks, int(c))
+	}
+	return append(ks, k...)
+}
+
+func TestEditor(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		readOnly bool
+		keys     []int
+		want     []byte
+		writes   int
+		cur      int64
+		done     bool
+	}{
+		{
+			name:   "hex",
+			keys:   keys("4142", ctrlW),
+			want:   []byte("AB\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f\x10\x11\x12\x13"),
+			writes: 1,
+			cur:    2,
+		},
+		{
+			name:   "ascii and runs",
+			keys:   keys("\tzy", keyRight, 'x', ctrlX),
+			want:   []byte("zy\x02x\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f\x10\x11\x12\x13"),
+			writes: 2,
+			cur:    4,
+			done:   true,
+		},
+		{
+			name: "half a byte",
+			keys: keys("f", ctrlX),
+			// The cursor stays on the byte.
+			want:   []byte("\xf0\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f\x10\x11\x12\x13"),
+			writes: 1,
+			done:   true,
+		},
+		{
+			name: "undo",
+			keys: keys("ff", keyLeft, ctrlU, ctrlW),
+		},
+		{
+			name: "goto and edit",
+			keys: append(keys("", ctrlG), keys("0x11\rff", keyDown, keyDown, ctrlW)...),
+			// There is no row below.
+			want:   []byte("\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f\x10\xff\x12\x13"),
+			writes: 1,
+			cur:    18,
+		},
+		{
+			name: "goto relative",
+			keys: append(append(keys("", keyRight, keyRight, ctrlG), keys("+16\r")...), keyDown),
+			cur:  18,
+		},
+		{
+			name: "goto outside",
+			keys: append(keys("", ctrlG), keys("100\r")...),
+		},
+		{
+			name: "moves stop at the ends",
+			keys: keys("", keyLeft, keyUp, keyEnd, keyRight, keyDown, keyPgDn),
+			cur:  19,
+		},
+		{
+			name: "quit with changes",
+			keys: keys("00", ctrlC),
+			cur:  1,
+		},
+		{
+			name: "quit twice",
+			keys: keys("00", ctrlC, ctrlC),
+			cur:  1,
+			done: true,
+		},
+		{
+			name:     "read-only",
+			readOnly: true,
+			keys:     keys("4142\tz", ctrlX),
+			done:     true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := make([]byte, 20)
+			for i := range orig {
+				orig[i] = byte(i)
+			}
+			f := &fakeFile{b: append([]byte(nil), orig...)}
+			e := newEditor(f, "test", int64(len(orig)), tt.readOnly, 4)
+			for _, k := range tt.keys {
+				if err := e.key(k); err != nil {
+					t.Fatal(err)
+				}
+				// Drawing never fails, whatever the state.
+				if err := e.draw(io.Discard); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := tt.want
+			if want == nil {
+				want = orig
+			}
+			if !bytes.Equal(f.b, want) {
+				t.Errorf("file = %q, want %q", f.b, want)
+			}
+			if f.writes != tt.writes || e.cur != tt.cur || e.done != tt.done {
+				t.Errorf("writes, cursor, done = %d, %d, %v, want %d, %d, %v", f.writes, e.cur, e.done, tt.writes, tt.cur, tt.done)
+			}
+		})
+	}
+}
+
+func TestDraw(t *testing.T) {
+	f := &fakeFile{b: []byte("hello, world\x00\x01\x02\x03\xff Z")}
+	e := newEditor(f, "test", int64(len(f.b)), false, 3)
+	e.move(16)
+	e.key('4')
+	e.key('1')
+	var b bytes.Buffer
+	if err := e.draw(&b); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"00000000  68 65 6c 6c 6f 2c 20 77  6f 72 6c 64 00 01 02 03  |hello, world....|\r\n",
+		"00000010  " + attrChanged + "41" + attrReset + " " + attrCursor + "20" + attrReset + " 5a ",
+		"|" + attrChanged + "A" + attrReset + attrOther + " " + attrReset + "Z|\r\n~\r\n",
+		"test  0x11/0x13  1 changed",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("draw = %q, want it to contain %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// hexedit edits files and block devices byte by byte, e.g. to patch a
+// superblock or firmware blob.
+//
+// Synopsis:
+//
+//	hexedit [-r] [-o OFFSET] FILE
+//
+// Description:
+//
+//	hexedit shows FILE as rows of offset, hex and ASCII. Typing hex digits
+//	changes the byte under the cursor, or printable characters after Tab
+//	switched to the ASCII column. Changed bytes are highlighted and only
+//	written when saved. The size of FILE does not change.
+//
+//	Keys:
+//	  arrows, PgUp, PgDn, Home, End  move
+//	  Tab  switch between the hex and ASCII columns
+//	  ^G   go to an offset, e.g. 0x400, 1024 or +16
+//	  ^U   undo the change of the byte under the cursor
+//	  ^W   save
+//	  ^X   save and quit
+//	  ^C   quit, twice if there are unsaved changes
+//
+// Options:
+//
+//	-r: open read-only
+//	-o: offset to start at
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/u-root/u-root/pkg/termios"
+)
+
+var (
+	readOnly = flag.Bool("r", false, "open read-only")
+	offset   = flag.String("o", "0", "offset to start at")
+)
+
+func run(path string, t *termios.TTYIO) error {
+	ro := *readOnly
+	flags := os.O_RDWR
+	if ro {
+		flags = os.O_RDONLY
+	}
+	f, err := os.OpenFile(path, flags, 0)
+	if errors.Is(err, os.ErrPermission) && !ro {
+		ro = true
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// The size of a block device is only known by seeking.
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	rows := 24
+	if w, err := t.GetWinSize(); err == nil && w.Row > 1 {
+		rows = int(w.Row)
+	}
+	e := newEditor(f, path, size, ro, rows-1)
+	if ro && !*readOnly {
+		e.msg = "no write permission, opened read-only"
+	}
+	off, err := e.parseOffset(*offset)
+	if err != nil && size > 0 {
+		return err
+	}
+	e.move(off)
+
+	old, err := t.Raw()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		t.Set(old)
+		fmt.Fprint(t, "\x1b[H\x1b[2J")
+	}()
+
+	in := bufio.NewReader(t)
+	for !e.done {
+		if err := e.draw(t); err != nil {
+			return err
+		}
+		k, err := readKey(in)
+		if err != nil {
+			return err
+		}
+		if err := e.key(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatalf("usage: hexedit [-r] [-o OFFSET] FILE")
+	}
+	t, err := termios.New()
+	if err != nil {
+		log.Fatalf("hexedit: %v", err)
+	}
+	if err := run(flag.Arg(0), t); err != nil {
+		log.Fatalf("hexedit: %v", err)
+	}
+}
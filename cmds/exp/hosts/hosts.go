@@ -0,0 +1,106 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// hosts queries and edits /etc/hosts.
+//
+// Synopsis:
+//
+//	hosts [-f FILE] [list]
+//	hosts [-f FILE] get NAME
+//	hosts [-f FILE] set ADDRESS NAME...
+//	hosts [-f FILE] rm NAME...
+//
+// Description:
+//
+//	hosts edits /etc/hosts, or FILE, idempotently, so that scripts can
+//	make sure of its entries on every boot, e.g. that the host name
+//	resolves, which sshd and loggers need.
+//
+//	list prints the entries. get prints the addresses of NAME, a line
+//	each. set maps the NAMEs to ADDRESS, and to no other address, adding
+//	them to the entry of ADDRESS. rm removes the NAMEs, and entries left
+//	without names. The file is only written if it changes, and the lines
+//	that do not change are kept as they are, comments included.
+//
+//	The exit status is 1 if NAME has no address, and 2 on errors.
+//
+// Options:
+//
+//	-f: edit FILE instead of /etc/hosts
+//
+// Example:
+//
+//	hosts set 127.0.0.1 localhost
+//	hosts set 127.0.1.1 $(hostname -f) $(hostname -s)
+//	hosts rm old-build-server
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/hosts"
+)
+
+var file = flag.String("f", hosts.DefaultPath, "edit `FILE` instead of /etc/hosts")
+
+var (
+	errUsage  = errors.New("usage: hosts [-f FILE] [list | get NAME | set ADDRESS NAME... | rm NAME...]")
+	errAbsent = errors.New("no address")
+)
+
+func run(out io.Writer, path string, args []string) error {
+	f, err := hosts.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	changed := false
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "list" && len(args) == 0:
+		for _, e := range f.Entries() {
+			fmt.Fprintln(out, e)
+		}
+	case cmd == "get" && len(args) == 1:
+		addrs := f.Lookup(args[0])
+		if len(addrs) == 0 {
+			return errAbsent
+		}
+		fmt.Fprintln(out, strings.Join(addrs, "\n"))
+	case cmd == "set" && len(args) >= 2:
+		if changed, err = f.Set(args[0], args[1:]...); err != nil {
+			return err
+		}
+	case cmd == "rm" && len(args) >= 1:
+		changed = f.Remove(args...)
+	default:
+		return errUsage
+	}
+	if !changed {
+		return nil
+	}
+	return f.WriteFile(path)
+}
+
+func main() {
+	log.SetPrefix("hosts: ")
+	log.SetFlags(0)
+	flag.Parse()
+	err := run(os.Stdout, *file, flag.Args())
+	if errors.Is(err, errAbsent) {
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte("# lab\n127.0.0.1 localhost\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		args []string
+		want string
+		err  error
+	}{
+		{args: []string{"set", "127.0.1.1", "node1.lab", "node1"}},
+		{args: nil, want: "127.0.0.1\tlocalhost\n127.0.1.1\tnode1.lab node1\n"},
+		{args: []string{"get", "NODE1"}, want: "127.0.1.1\n"},
+		{args: []string{"get", "node2"}, err: errAbsent},
+		{args: []string{"set", "127.0.1.1", "node1"}},
+		{args: []string{"rm", "node1.lab", "node1"}},
+		{args: []string{"list"}, want: "127.0.0.1\tlocalhost\n"},
+		{args: []string{"set", "127.0.1.1"}, err: errUsage},
+		{args: []string{"add", "127.0.1.1", "node1"}, err: errUsage},
+	} {
+		var out bytes.Buffer
+		if err := run(&out, path, tt.args); err != tt.err {
+			t.Errorf("hosts %q = %v, want %v", tt.args, err, tt.err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("hosts %q printed %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "# lab\n127.0.0.1 localhost\n" {
+		t.Errorf("hosts file is %q, %v, want it as it was", b, err)
+	}
+
+	// Edits that change nothing do not write the file.
+	old := time.Unix(1e9, 0)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(&bytes.Buffer{}, path, []string{"set", "127.0.0.1", "localhost"}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || !fi.ModTime().Equal(old) {
+		t.Errorf("Stat = %v, %v, want the file untouched", fi, err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{args: []string{"-f", path, "set", "10.0.0.1", "build"}},
+		{args: []string{"-f", path, "get", "build"}},
+		{args: []string{"-f", path, "get", "test"}, code: 1},
+		{args: []string{"-f", path, "set", "10.0.0.256", "build"}, code: 2},
+	} {
+		err := testutil.Command(t, tt.args...).Run()
+		if tt.code == 0 && err != nil {
+			t.Errorf("hosts %q = %v, want nil", tt.args, err)
+		} else if tt.code != 0 {
+			if err := testutil.IsExitCode(err, tt.code); err != nil {
+				t.Errorf("hosts %q: %v", tt.args, err)
+			}
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
@@ -0,0 +1,303 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// indicate signals progress with the chassis identify light, keyboard LEDs
+// and PC speaker beeps, for machines without a display.
+//
+// Synopsis:
+//
+//	indicate [-ipmi N] identify on|off|SECONDS
+//	indicate [-tty PATH] leds [num] [caps] [scroll] | off | restore
+//	indicate [-tty PATH] [-f HZ] [-l MS] [-r N] [-d MS] beep
+//	indicate [-ipmi N] [-tty PATH] status progress|ok|fail
+//
+// Description:
+//
+//	identify turns the chassis identify light of the BMC on until it is
+//	turned off, off, or on for SECONDS.
+//
+//	leds turns on the named keyboard LEDs and the others off, restore
+//	makes them show the keyboard state again.
+//
+//	beep beeps the PC speaker. The pcspkr driver must be loaded.
+//
+//	status signals a state with all of them that are available:
+//	  progress: a short beep, the scroll lock LED, identify for 15s
+//	  ok:       two rising beeps, keyboard LEDs restored, identify off
+//	  fail:     three long low beeps, all keyboard LEDs, identify on
+//
+// Options:
+//
+//	-ipmi: IPMI device number (default 0)
+//	-tty:  console for the keyboard LEDs and speaker (default /dev/tty0)
+//	-f:    beep frequency in Hz (default 440)
+//	-l:    beep length in ms (default 200)
+//	-r:    number of beeps (default 1)
+//	-d:    delay between beeps in ms (default 100)
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ipmi"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ipmiDev = flag.Int("ipmi", 0, "IPMI device number")
+	tty     = flag.String("tty", "/dev/tty0", "console for the keyboard LEDs and speaker")
+	freq    = flag.Int("f", 440, "beep frequency in Hz")
+	length  = flag.Int("l", 200, "beep length in ms")
+	repeats = flag.Int("r", 1, "number of beeps")
+	delay   = flag.Int("d", 100, "delay between beeps in ms")
+
+	errUsage = errors.New("usage: indicate identify on|off|SECONDS | leds [num] [caps] [scroll]|off|restore | beep | status progress|ok|fail")
+)
+
+// Console ioctls, see console_ioctl(2).
+const (
+	kiocsound = 0x4b2f
+	kdsetled  = 0x4b32
+
+	// The PC speaker divides this clock to make its tone.
+	pitClock = 1193180
+)
+
+// Keyboard LEDs of KDSETLED. A value above them makes the LEDs show the
+// keyboard state again.
+const (
+	ledScroll = 1 << iota
+	ledNum
+	ledCaps
+
+	ledRestore = 0xff
+)
+
+var ledNames = map[string]int{"scroll": ledScroll, "num": ledNum, "caps": ledCaps}
+
+// chassis is the BMC, *ipmi.IPMI is one.
+type chassis interface {
+	ChassisIdentify(interval uint8, force bool) error
+}
+
+// console drives the keyboard LEDs and speaker.
+type console interface {
+	setLEDs(leds int) error
+	// sound starts a tone, 0 stops it.
+	sound(hz int) error
+}
+
+type tone struct {
+	hz     int
+	length time.Duration
+}
+
+type status struct {
+	tones    []tone
+	leds     int
+	identify uint8
+	force    bool
+}
+
+var statuses = map[string]status{
+	"progress": {tones: []tone{{880, 100 * time.Millisecond}}, leds: ledScroll, identify: 15},
+	"ok":       {tones: []tone{{880, 100 * time.Millisecond}, {1320, 100 * time.Millisecond}}, leds: ledRestore},
+	"fail": {
+		tones: []tone{{220, 500 * time.Millisecond}, {220, 500 * time.Millisecond}, {220, 500 * time.Millisecond}},
+		leds:  ledScroll | ledNum | ledCaps,
+		force: true,
+	},
+}
+
+type vt struct {
+	f *os.File
+}
+
+func openConsole(path string) (console, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return vt{f}, nil
+}
+
+func (v vt) setLEDs(leds int) error {
+	return unix.IoctlSetInt(int(v.f.Fd()), kdsetled, leds)
+}
+
+func (v vt) sound(hz int) error {
+	if hz != 0 {
+		hz = pitClock / hz
+	}
+	return unix.IoctlSetInt(int(v.f.Fd()), kiocsound, hz)
+}
+
+func openChassis(dev int) (chassis, error) {
+	i, err := ipmi.Open(dev)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+type indicator struct {
+	chassis func() (chassis, error)
+	console func() (console, error)
+	sleep   func(time.Duration)
+}
+
+func (ind *indicator) identify(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	var interval uint8
+	var force bool
+	switch args[0] {
+	case "on":
+		force = true
+	case "off":
+	default:
+		s, err := strconv.ParseUint(args[0], 10, 8)
+		if err != nil {
+			return fmt.Errorf("identify: %q is not on, off or up to 255 seconds", args[0])
+		}
+		interval = uint8(s)
+	}
+	c, err := ind.chassis()
+	if err != nil {
+		return err
+	}
+	return c.ChassisIdentify(interval, force)
+}
+
+func parseLEDs(args []string) (int, error) {
+	if len(args) == 1 {
+		switch args[0] {
+		case "off":
+			return 0, nil
+		case "restore":
+			return ledRestore, nil
+		}
+	}
+	if len(args) == 0 {
+		return 0, errUsage
+	}
+	var leds int
+	for _, a := range args {
+		l, ok := ledNames[a]
+		if !ok {
+			return 0, fmt.Errorf("leds: unknown LED %q, want num, caps or scroll", a)
+		}
+		leds |= l
+	}
+	return leds, nil
+}
+
+func (ind *indicator) beep(c console, tones []tone, gap time.Duration) error {
+	for i, t := range tones {
+		if i > 0 {
+			ind.sleep(gap)
+		}
+		if err := c.sound(t.hz); err != nil {
+			return err
+		}
+		ind.sleep(t.length)
+		if err := c.sound(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// status signals s with every indicator that works, it fails only if none
+// does.
+func (ind *indicator) status(s status) error {
+	var worked int
+	if c, err := ind.chassis(); err != nil {
+		log.Printf("indicate: identify: %v", err)
+	} else if err := c.ChassisIdentify(s.identify, s.force); err != nil {
+		log.Printf("indicate: identify: %v", err)
+	} else {
+		worked++
+	}
+	if c, err := ind.console(); err != nil {
+		log.Printf("indicate: console: %v", err)
+	} else {
+		if err := c.setLEDs(s.leds); err != nil {
+			log.Printf("indicate: leds: %v", err)
+		} else {
+			worked++
+		}
+		if err := ind.beep(c, s.tones, 200*time.Millisecond); err != nil {
+			log.Printf("indicate: beep: %v", err)
+		} else {
+			worked++
+		}
+	}
+	if worked == 0 {
+		return fmt.Errorf("no indicator works")
+	}
+	return nil
+}
+
+func (ind *indicator) run(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "identify":
+		return ind.identify(args[1:])
+	case "leds":
+		leds, err := parseLEDs(args[1:])
+		if err != nil {
+			return err
+		}
+		c, err := ind.console()
+		if err != nil {
+			return err
+		}
+		return c.setLEDs(leds)
+	case "beep":
+		if len(args) != 1 || *freq <= 0 || *repeats < 1 {
+			return errUsage
+		}
+		c, err := ind.console()
+		if err != nil {
+			return err
+		}
+		tones := make([]tone, *repeats)
+		for i := range tones {
+			tones[i] = tone{*freq, time.Duration(*length) * time.Millisecond}
+		}
+		return ind.beep(c, tones, time.Duration(*delay)*time.Millisecond)
+	case "status":
+		if len(args) != 2 {
+			return errUsage
+		}
+		s, ok := statuses[args[1]]
+		if !ok {
+			return errUsage
+		}
+		return ind.status(s)
+	}
+	return errUsage
+}
+
+func main() {
+	flag.Parse()
+	ind := &indicator{
+		chassis: func() (chassis, error) { return openChassis(*ipmiDev) },
+		console: func() (console, error) { return openConsole(*tty) },
+		sleep:   time.Sleep,
+	}
+	if err := ind.run(flag.Args()); err != nil {
+		log.Fatalf("indicate: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fake records what the indicators do.
+type fake struct {
+	events []string
+}
+
+func (f *fake) ChassisIdentify(interval uint8, force bool) error {
+	f.events = append(f.events, fmt.Sprintf("identify %d %v", interval, force))
+	return nil
+}
+
+func (f *fake) setLEDs(leds int) error {
+	f.events = append(f.events, fmt.Sprintf("leds %#x", leds))
+	return nil
+}
+
+func (f *fake) sound(hz int) error {
+	f.events = append(f.events, fmt.Sprintf("sound %d", hz))
+	return nil
+}
+
+func (f *fake) indicator(chassisErr, consoleErr error) *indicator {
+	return &indicator{
+		chassis: func() (chassis, error) { return f, chassisErr },
+		console: func() (console, error) { return f, consoleErr },
+		sleep:   func(d time.Duration) { f.events = append(f.events, "sleep "+d.String()) },
+	}
+}
+
+func TestRun(t *testing.T) {
+	noDev := errors.New("no device")
+	for _, tt := range []struct {
+		args       string
+		chassisErr error
+		consoleErr error
+		want       []string
+		err        bool
+	}{
+		{args: "identify on", want: []string{"identify 0 true"}},
+		{args: "identify 30", want: []string{"identify 30 false"}},
+		{args: "identify off", want: []string{"identify 0 false"}},
+		{args: "identify 300", err: true},
+		{args: "identify on", chassisErr: noDev, err: true},
+		{args: "leds num caps", want: []string{"leds 0x6"}},
+		{args: "leds restore", want: []string{"leds 0xff"}},
+		{args: "leds off", want: []string{"leds 0x0"}},
+		{args: "leds shift", err: true},
+		{args: "leds", err: true},
+		{args: "beep", want: []string{"sound 440", "sleep 200ms", "sound 0"}},
+		{
+			args: "status fail", chassisErr: noDev,
+			want: []string{"leds 0x7", "sound 220", "sleep 500ms", "sound 0", "sleep 200ms", "sound 220", "sleep 500ms", "sound 0", "sleep 200ms", "sound 220", "sleep 500ms", "sound 0"},
+		},
+		{args: "status ok", consoleErr: noDev, want: []string{"identify 0 false"}},
+		{args: "status progress", chassisErr: noDev, consoleErr: noDev, err: true},
+		{args: "status done", err: true},
+		{args: "blink", err: true},
+	} {
+		f := &fake{}
+		err := f.indicator(tt.chassisErr, tt.consoleErr).run(strings.Fields(tt.args))
+		if (err != nil) != tt.err {
+			t.Errorf("indicate %s = %v, want error %v", tt.args, err, tt.err)
+		}
+		if !reflect.DeepEqual(f.events, tt.want) {
+			t.Errorf("indicate %s did %q, want %q", tt.args, f.events, tt.want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// install copies files and sets their attributes.
+//
+// Synopsis:
+//
+//	install [OPTIONS] [-T] SOURCE DEST
+//	install [OPTIONS] SOURCE... DIRECTORY
+//	install [OPTIONS] -t DIRECTORY SOURCE...
+//	install [OPTIONS] -d DIRECTORY...
+//
+// Description:
+//
+//	install copies each SOURCE to DEST, or into DIRECTORY, replacing
+//	existing files, and sets the mode, and optionally the owner and group,
+//	of the copies. With -d, it makes each DIRECTORY and sets its
+//	attributes instead.
+//
+//	There is no strip program in u-root, so -s is accepted and ignored.
+//
+// Options:
+//
+//	-m, --mode:                set the mode, in octal (default 0755)
+//	-o, --owner:               set the owner, by name or number
+//	-g, --group:               set the group, by name or number
+//	-d, --directory:           make directories instead of copying files
+//	-D:                        make the missing directories of DEST, or of
+//	                           DIRECTORY with -t
+//	-t, --target-directory:    copy the SOURCEs into DIRECTORY
+//	-T, --no-target-directory: take DEST as a file, even if it is a directory
+//	-p, --preserve-timestamps: keep the modification time of SOURCE
+//	-v, --verbose:             print each file or directory as it is made
+//	-s, --strip:               ignored
+//	-c:                        ignored, for compatibility
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	flag "github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/cp"
+	"github.com/u-root/u-root/pkg/nss"
+)
+
+var errUsage = errors.New("usage: install [OPTIONS] [-T] SOURCE DEST | SOURCE... DIRECTORY | -t DIRECTORY SOURCE... | -d DIRECTORY...")
+
+type options struct {
+	mode     os.FileMode
+	uid, gid int
+	dirs     bool
+	leading  bool
+	target   string
+	noTarget bool
+	preserve bool
+	verbose  io.Writer
+	dirMode  os.FileMode
+}
+
+// id returns the number of the user or group s.
+func id(s string, lookup func(string) (string, error)) (int, error) {
+	if n, err := strconv.ParseUint(s, 10, 31); err == nil {
+		return int(n), nil
+	}
+	id, err := lookup(s)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// setAttrs sets the mode and owner of name.
+func (o *options) setAttrs(name string, mode os.FileMode) error {
+	if o.uid != -1 || o.gid != -1 {
+		if err := os.Chown(name, o.uid, o.gid); err != nil {
+			return err
+		}
+	}
+	// Chmod after chown, which may clear the setuid and setgid bits.
+	return os.Chmod(name, mode)
+}
+
+// mkdirAll makes dir and its missing parents with the default mode,
+// printing each with -v.
+func (o *options) mkdirAll(dir string) error {
+	if fi, err := os.Stat(dir); err == nil {
+		if !fi.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := o.mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(dir, o.dirMode); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if o.verbose != nil {
+		fmt.Fprintf(o.verbose, "install: creating directory '%s'\n", dir)
+	}
+	return os.Chmod(dir, o.dirMode)
+}
+
+// installDir makes dir with the attributes of o.
+func (o *options) installDir(dir string) error {
+	if err := o.mkdirAll(dir); err != nil {
+		return err
+	}
+	return o.setAttrs(dir, o.mode)
+}
+
+// installFile copies src to the file dst with the attributes of o.
+func (o *options) installFile(src, dst string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("omitting directory %q", src)
+	}
+	if dfi, err := os.Stat(dst); err == nil {
+		if os.SameFile(fi, dfi) {
+			return fmt.Errorf("%q and %q are the same file", src, dst)
+		}
+		// Remove dst rather than write over it, so that running binaries
+		// and hard links to it are left alone.
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
+	if err := cp.Copy(src, dst); err != nil {
+		return err
+	}
+	if o.verbose != nil {
+		fmt.Fprintf(o.verbose, "'%s' -> '%s'\n", src, dst)
+	}
+	if err := o.setAttrs(dst, o.mode); err != nil {
+		return err
+	}
+	if o.preserve {
+		return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+	}
+	return nil
+}
+
+func isDir(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.IsDir()
+}
+
+func run(o *options, args []string) error {
+	if o.dirs {
+		if len(args) == 0 || o.target != "" {
+			return errUsage
+		}
+		for _, d := range args {
+			if err := o.installDir(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	target := o.target
+	switch {
+	case target != "":
+		if len(args) == 0 || o.noTarget {
+			return errUsage
+		}
+	case len(args) < 2:
+		return errUsage
+	case o.noTarget || len(args) == 2 && !isDir(args[1]):
+		if len(args) != 2 {
+			return errUsage
+		}
+		dst := args[1]
+		if o.leading {
+			if err := o.mkdirAll(filepath.Dir(dst)); err != nil {
+				return err
+			}
+		}
+		return o.installFile(args[0], dst)
+	default:
+		target, args = args[len(args)-1], args[:len(args)-1]
+	}
+
+	if o.leading && o.target != "" {
+		if err := o.mkdirAll(target); err != nil {
+			return err
+		}
+	}
+	if !isDir(target) {
+		return fmt.Errorf("target %q is not a directory", target)
+	}
+	for _, src := range args {
+		if err := o.installFile(src, filepath.Join(target, filepath.Base(src))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parse(stdout io.Writer, args []string) (*options, []string, error) {
+	f := flag.NewFlagSet("install", flag.ContinueOnError)
+	var mode, owner, group string
+	var verbose, ignored bool
+	o := &options{uid: -1, gid: -1, dirMode: 0o755}
+	f.StringVarP(&mode, "mode", "m", "755", "set the mode, in octal")
+	f.StringVarP(&owner, "owner", "o", "", "set the owner")
+	f.StringVarP(&group, "group", "g", "", "set the group")
+	f.BoolVarP(&o.dirs, "directory", "d", false, "make directories instead of copying files")
+	f.BoolVarP(&o.leading, "D", "D", false, "make the missing directories of the destination")
+	f.StringVarP(&o.target, "target-directory", "t", "", "copy the sources into this directory")
+	f.BoolVarP(&o.noTarget, "no-target-directory", "T", false, "take the destination as a file")
+	f.BoolVarP(&o.preserve, "preserve-timestamps", "p", false, "keep the modification time of the sources")
+	f.BoolVarP(&verbose, "verbose", "v", false, "print each file or directory as it is made")
+	f.BoolVarP(&ignored, "strip", "s", false, "ignored; there is no strip program")
+	f.BoolVarP(&ignored, "c", "c", false, "ignored")
+	if err := f.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > 0o7777 {
+		return nil, nil, fmt.Errorf("invalid mode %q", mode)
+	}
+	o.mode = os.FileMode(m).Perm()
+	for bit, fm := range map[uint64]os.FileMode{0o4000: os.ModeSetuid, 0o2000: os.ModeSetgid, 0o1000: os.ModeSticky} {
+		if m&bit != 0 {
+			o.mode |= fm
+		}
+	}
+	if owner != "" {
+		if o.uid, err = id(owner, lookupUID); err != nil {
+			return nil, nil, fmt.Errorf("invalid user %q: %v", owner, err)
+		}
+	}
+	if group != "" {
+		if o.gid, err = id(group, lookupGID); err != nil {
+			return nil, nil, fmt.Errorf("invalid group %q: %v", group, err)
+		}
+	}
+	if verbose {
+		o.verbose = stdout
+	}
+	return o, f.Args(), nil
+}
+
+func lookupUID(name string) (string, error) {
+	u, err := nss.LookupUser(name)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(u.UID), nil
+}
+
+func lookupGID(name string) (string, error) {
+	g, err := nss.LookupGroup(name)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(g.GID), nil
+}
+
+func main() {
+	o, args, err := parse(os.Stdout, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err == nil {
+		err = run(o, args)
+	}
+	if err != nil {
+		log.Fatalf("install: %v", err)
+	}
+}
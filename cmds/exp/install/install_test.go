@@ -0,0 +1,127 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func install(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	o, args, err := parse(&out, args)
+	if err != nil {
+		return "", err
+	}
+	err = run(o, args)
+	return out.String(), err
+}
+
+func checkFile(t *testing.T, name, content string, mode os.FileMode) {
+	t.Helper()
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != mode {
+		t.Errorf("mode of %s = %v, want %v", name, fi.Mode(), mode)
+	}
+	if content == "" {
+		return
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Errorf("%s = %q, want %q", name, b, content)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "prog")
+	if err := os.WriteFile(src, []byte("binary"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(src, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// Default mode, replacing a file.
+	dst := filepath.Join(dir, "copy")
+	if err := os.WriteFile(dst, []byte("old contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := install(t, "-s", src, dst); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, dst, "binary", 0o755)
+
+	// Leading directories, as in "install -Dm644".
+	deep := filepath.Join(dir, "usr/share/prog/prog.conf")
+	out, err := install(t, "-Dvm644", "-p", src, deep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, deep, "binary", 0o644)
+	checkFile(t, filepath.Join(dir, "usr/share"), "", os.ModeDir|0o755)
+	if fi, err := os.Stat(deep); err != nil || !fi.ModTime().Equal(old) {
+		t.Errorf("-p: modification time = %v, %v, want %v", fi.ModTime(), err, old)
+	}
+	if n := strings.Count(out, "creating directory"); n != 3 || !strings.Contains(out, "-> '"+deep+"'") {
+		t.Errorf("-v output = %q, want 3 directories and the file", out)
+	}
+
+	// Into a directory, with -t and as the last argument.
+	bin := filepath.Join(dir, "bin")
+	if _, err := install(t, "-D", "-t", bin, src, dst); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, filepath.Join(bin, "prog"), "binary", 0o755)
+	checkFile(t, filepath.Join(bin, "copy"), "binary", 0o755)
+	if _, err := install(t, "-m", "4750", src, bin); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, filepath.Join(bin, "prog"), "binary", os.ModeSetuid|0o750)
+
+	// Directories.
+	if _, err := install(t, "-d", "-m", "0700", filepath.Join(dir, "a/b"), filepath.Join(dir, "c")); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, filepath.Join(dir, "a"), "", os.ModeDir|0o755)
+	checkFile(t, filepath.Join(dir, "a/b"), "", os.ModeDir|0o700)
+	checkFile(t, filepath.Join(dir, "c"), "", os.ModeDir|0o700)
+
+	// Owner and group, which may be set to our own.
+	uid, gid := strconv.Itoa(os.Getuid()), strconv.Itoa(os.Getgid())
+	if _, err := install(t, "-o", uid, "-g", gid, src, dst); err != nil {
+		t.Errorf("install -o %s -g %s: %v", uid, gid, err)
+	}
+
+	for _, args := range [][]string{
+		{src},
+		{"-d"},
+		{"-m", "999", src, dst},
+		{"-m", "17777", src, dst},
+		{"-o", "no such user", src, dst},
+		{"-T", src, dst, bin},
+		{src, dst, filepath.Join(dir, "missing")},
+		{dir, filepath.Join(dir, "dircopy")},
+		{src, src},
+		{filepath.Join(dir, "missing"), dst},
+	} {
+		if _, err := install(t, args...); err == nil {
+			t.Errorf("install %q succeeded", args)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// ionice gets or sets the I/O scheduling class and priority of processes.
+//
+// Synopsis:
+//
+//	ionice [-c CLASS] [-n LEVEL] [-t] -p PID...
+//	ionice [-c CLASS] [-n LEVEL] [-t] COMMAND [ARG]...
+//
+// Description:
+//
+//	ionice sets the I/O scheduling class, and the priority within it, of
+//	the processes PID, or runs COMMAND with them. Without -c or -n, it
+//	prints them for each PID, or for itself if there is none.
+//
+//	The classes are 0 or none, 1 or realtime, 2 or best-effort, and 3 or
+//	idle. Realtime and best-effort have priorities from 0, the highest, to
+//	7, and only root may use realtime. Processes of the idle class only do
+//	I/O when nothing else does.
+//
+// Options:
+//
+//	-c: the scheduling class, by name or number (default best-effort)
+//	-n: the priority within the class (default 4)
+//	-p: act on these running processes
+//	-t: ignore failures to set the class and priority
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioPrioMask   = 1<<ioprioClassShift - 1
+)
+
+var classes = []string{"none", "realtime", "best-effort", "idle"}
+
+var (
+	class   = flag.String("c", "", "the scheduling `CLASS`, by name or number")
+	level   = flag.Int("n", -1, "the priority within the class, from 0 to 7")
+	pids    = flag.Bool("p", false, "act on the running processes given as arguments")
+	ignore  = flag.Bool("t", false, "ignore failures to set the class and priority")
+	errArgs = errors.New("usage: ionice [-c CLASS] [-n LEVEL] [-t] -p PID... | COMMAND [ARG]...")
+)
+
+func parseClass(s string) (int, error) {
+	for i, c := range classes {
+		if s == c || s == strconv.Itoa(i) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scheduling class %q", s)
+}
+
+// ioprio returns the I/O priority for class and level, with the defaults of
+// ionice for those that are not set.
+func ioprio(class string, level int) (int, error) {
+	c := 2
+	if class != "" {
+		var err error
+		if c, err = parseClass(class); err != nil {
+			return 0, err
+		}
+	}
+	switch {
+	case level == -1:
+		level = 4
+		if c == 0 || c == 3 {
+			level = 0
+		}
+	case level < 0 || level > 7:
+		return 0, fmt.Errorf("priority %d is not between 0 and 7", level)
+	}
+	return c<<ioprioClassShift | level, nil
+}
+
+func format(prio int) string {
+	c, level := prio>>ioprioClassShift, prio&ioprioPrioMask
+	switch {
+	case c == 3:
+		return "idle"
+	case c < len(classes):
+		return fmt.Sprintf("%s: prio %d", classes[c], level)
+	}
+	return fmt.Sprintf("unknown: prio %d", level)
+}
+
+func get(pid int) (int, error) {
+	r, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(pid), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
+
+// set sets the I/O priority of pid, where 0 is the calling thread.
+func set(pid, prio int) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// run gets or sets the I/O priorities of pids.
+func run(w io.Writer, setting bool, prio int, pids []int) error {
+	for _, pid := range pids {
+		if setting {
+			if err := set(pid, prio); err != nil && !*ignore {
+				return fmt.Errorf("cannot set the I/O priority of %d: %v", pid, err)
+			}
+			continue
+		}
+		p, err := get(pid)
+		if err != nil {
+			return fmt.Errorf("cannot get the I/O priority of %d: %v", pid, err)
+		}
+		if len(pids) > 1 {
+			fmt.Fprintf(w, "%d: ", pid)
+		}
+		fmt.Fprintln(w, format(p))
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	setting := *class != "" || *level != -1
+	prio, err := ioprio(*class, *level)
+	if err != nil {
+		log.Fatalf("ionice: %v", err)
+	}
+
+	args := flag.Args()
+	if *pids || len(args) == 0 {
+		var ps []int
+		for _, a := range args {
+			pid, err := strconv.Atoi(a)
+			if err != nil {
+				log.Fatalf("ionice: invalid PID %q", a)
+			}
+			ps = append(ps, pid)
+		}
+		if len(ps) == 0 {
+			if *pids || setting {
+				log.Fatalf("ionice: %v", errArgs)
+			}
+			ps = []int{0}
+		}
+		if err := run(os.Stdout, setting, prio, ps); err != nil {
+			log.Fatalf("ionice: %v", err)
+		}
+		return
+	}
+
+	// The priority is set for a thread, which must be the one to exec.
+	runtime.LockOSThread()
+	if err := set(0, prio); err != nil && !*ignore {
+		log.Fatalf("ionice: cannot set the I/O priority: %v", err)
+	}
+	path, err := exec.LookPath(args[0])
+	if err == nil {
+		err = syscall.Exec(path, args, os.Environ())
+	}
+	log.Printf("ionice: %v", err)
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		os.Exit(127)
+	}
+	os.Exit(126)
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"testing"
+)
+
+func TestIOPrio(t *testing.T) {
+	for _, tt := range []struct {
+		class string
+		level int
+		want  string
+	}{
+		{"", -1, "best-effort: prio 4"},
+		{"1", 2, "realtime: prio 2"},
+		{"idle", -1, "idle"},
+		{"none", -1, "none: prio 0"},
+		{"best-effort", 7, "best-effort: prio 7"},
+	} {
+		p, err := ioprio(tt.class, tt.level)
+		if err != nil || format(p) != tt.want {
+			t.Errorf("ioprio(%q, %d) = %s, %v, want %s", tt.class, tt.level, format(p), err, tt.want)
+		}
+	}
+	if _, err := ioprio("fast", -1); err == nil {
+		t.Errorf("unknown class succeeded")
+	}
+	if _, err := ioprio("", 8); err == nil {
+		t.Errorf("priority 8 succeeded")
+	}
+}
+
+func TestRun(t *testing.T) {
+	c := exec.Command("sleep", "10")
+	if err := c.Start(); err != nil {
+		t.Skipf("cannot run sleep: %v", err)
+	}
+	defer c.Wait()
+	defer c.Process.Kill()
+
+	pid := c.Process.Pid
+	prio, err := ioprio("idle", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run(nil, true, prio, []int{pid}); err != nil {
+		t.Skipf("cannot set the I/O priority: %v", err)
+	}
+	var b bytes.Buffer
+	if err := run(&b, false, 0, []int{pid, pid}); err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%d: idle\n%[1]d: idle\n", pid); b.String() != want {
+		t.Errorf("ionice -p = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// ipcalc does CIDR math on IPv4 and IPv6 addresses.
+//
+// Synopsis:
+//
+//	ipcalc [-j] ADDRESS[/PREFIX] [NETMASK]
+//	ipcalc [-j] -host N ADDRESS/PREFIX
+//	ipcalc [-j] -split PREFIX ADDRESS/PREFIX
+//	ipcalc [-j] -contains ADDRESS[/PREFIX] ADDRESS/PREFIX
+//
+// Description:
+//
+//	ipcalc prints the network, netmask, broadcast address, host range and
+//	number of hosts of a network. An IPv4 network may also be given as an
+//	address and a netmask, an address without prefix is a single host.
+//
+//	With -host, ipcalc prints the address N of the network, with its
+//	prefix: 10.0.0.0/24 has 10.0.0.10/24 as host 10, and 10.0.0.255/24 as
+//	host -1. Init scripts use it to derive static addresses from a
+//	template, e.g. with the rack's network and the slot number.
+//
+//	With -split, ipcalc prints the subnets of the network with the longer
+//	PREFIX.
+//
+//	With -contains, ipcalc exits with status 1 if the address or network
+//	is not in the network.
+//
+// Options:
+//
+//	-j:        print JSON
+//	-host:     print address N of the network
+//	-split:    print the subnets with prefix length PREFIX
+//	-contains: check whether the network contains an address or network
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// maxSubnets is the most subnets -split prints.
+const maxSubnets = 1 << 16
+
+var errNotContained = errors.New("not contained")
+
+type params struct {
+	json     bool
+	host     string
+	split    int
+	contains string
+}
+
+// info describes a network.
+type info struct {
+	Address   string   `json:"address"`
+	Network   string   `json:"network"`
+	Netmask   string   `json:"netmask"`
+	Prefix    int      `json:"prefix"`
+	Broadcast string   `json:"broadcast,omitempty"`
+	HostMin   string   `json:"hostmin"`
+	HostMax   string   `json:"hostmax"`
+	Hosts     *big.Int `json:"hosts"`
+	Version   int      `json:"version"`
+}
+
+// parse parses an address with an optional prefix length, and an optional
+// IPv4 netmask as the next argument. The address is not masked.
+func parse(args []string) (netip.Prefix, error) {
+	switch len(args) {
+	case 1:
+		if strings.Contains(args[0], "/") {
+			return netip.ParsePrefix(args[0])
+		}
+		a, err := netip.ParseAddr(args[0])
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(a, a.BitLen()), nil
+
+	case 2:
+		a, err := netip.ParseAddr(args[0])
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		m := net.ParseIP(args[1]).To4()
+		ones, bits := net.IPMask(m).Size()
+		if !a.Is4() || m == nil || bits == 0 {
+			return netip.Prefix{}, fmt.Errorf("invalid IPv4 address %v and netmask %q", a, args[1])
+		}
+		return netip.PrefixFrom(a, ones), nil
+
+	default:
+		return netip.Prefix{}, errors.New("want an address, and an optional netmask")
+	}
+}
+
+func toInt(a netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(a.AsSlice())
+}
+
+// fromInt returns i as an address of the same version as like, or false if
+// it does not fit.
+func fromInt(i *big.Int, like netip.Addr) (netip.Addr, bool) {
+	if i.Sign() < 0 || i.BitLen() > like.BitLen() {
+		return netip.Addr{}, false
+	}
+	b := i.FillBytes(make([]byte, like.BitLen()/8))
+	a, _ := netip.AddrFromSlice(b)
+	return a, true
+}
+
+// size returns the number of addresses in p.
+func size(p netip.Prefix) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+}
+
+// last returns the last address of p.
+func last(p netip.Prefix) netip.Addr {
+	i := toInt(p.Masked().Addr())
+	a, _ := fromInt(i.Add(i, size(p)).Sub(i, big.NewInt(1)), p.Addr())
+	return a
+}
+
+// hostN returns address n of p, counting back from the last address if n is
+// negative.
+func hostN(p netip.Prefix, n *big.Int) (netip.Addr, error) {
+	i := toInt(p.Masked().Addr())
+	if n.Sign() < 0 {
+		i = toInt(last(p))
+		i.Add(i, big.NewInt(1))
+	}
+	a, ok := fromInt(i.Add(i, n), p.Addr())
+	if !ok || !p.Masked().Contains(a) {
+		return netip.Addr{}, fmt.Errorf("%v has no host %v", p.Masked(), n)
+	}
+	return a, nil
+}
+
+func describe(p netip.Prefix) info {
+	network, bcast := p.Masked().Addr(), last(p)
+	mask := net.CIDRMask(p.Bits(), p.Addr().BitLen())
+	in := info{
+		Address: p.Addr().String(),
+		Network: p.Masked().String(),
+		Netmask: net.IP(mask).String(),
+		Prefix:  p.Bits(),
+		HostMin: network.String(),
+		HostMax: bcast.String(),
+		Hosts:   size(p),
+		Version: 6,
+	}
+	if p.Addr().Is4() {
+		in.Version = 4
+		in.Broadcast = bcast.String()
+		// /31 and /32 networks have no network and broadcast addresses,
+		// RFC 3021.
+		if p.Bits() < 31 {
+			in.HostMin = network.Next().String()
+			in.HostMax = bcast.Prev().String()
+			in.Hosts.Sub(in.Hosts, big.NewInt(2))
+		}
+	}
+	return in
+}
+
+// split returns the subnets of p with prefix length bits.
+func split(p netip.Prefix, bits int) ([]netip.Prefix, error) {
+	if bits < p.Bits() || bits > p.Addr().BitLen() {
+		return nil, fmt.Errorf("cannot split %v into /%d networks", p.Masked(), bits)
+	}
+	if bits-p.Bits() > 16 {
+		return nil, fmt.Errorf("splitting %v into /%d networks gives more than %d networks", p.Masked(), bits, maxSubnets)
+	}
+	n := 1 << (bits - p.Bits())
+	step := new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-bits))
+	i := toInt(p.Masked().Addr())
+	subnets := make([]netip.Prefix, 0, n)
+	for j := 0; j < n; j++ {
+		a, _ := fromInt(i, p.Addr())
+		subnets = append(subnets, netip.PrefixFrom(a, bits))
+		i.Add(i, step)
+	}
+	return subnets, nil
+}
+
+// contains returns whether p contains the address or network s.
+func contains(p netip.Prefix, s string) (bool, error) {
+	q, err := parse([]string{s})
+	if err != nil {
+		return false, err
+	}
+	return q.Bits() >= p.Bits() && p.Contains(q.Addr()), nil
+}
+
+func output(w io.Writer, asJSON bool, v interface{}, text string) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(v)
+	}
+	_, err := io.WriteString(w, text)
+	return err
+}
+
+func run(stdout io.Writer, args []string, p params) error {
+	prefix, err := parse(args)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case p.host != "":
+		n, ok := new(big.Int).SetString(p.host, 10)
+		if !ok {
+			return fmt.Errorf("invalid host number %q", p.host)
+		}
+		a, err := hostN(prefix, n)
+		if err != nil {
+			return err
+		}
+		h := netip.PrefixFrom(a, prefix.Bits()).String()
+		return output(stdout, p.json, map[string]string{"address": a.String(), "cidr": h}, h+"\n")
+
+	case p.split > 0:
+		subnets, err := split(prefix, p.split)
+		if err != nil {
+			return err
+		}
+		var sb strings.Builder
+		for _, s := range subnets {
+			fmt.Fprintln(&sb, s)
+		}
+		return output(stdout, p.json, subnets, sb.String())
+
+	case p.contains != "":
+		ok, err := contains(prefix, p.contains)
+		if err != nil {
+			return err
+		}
+		if err := output(stdout, p.json, map[string]bool{"contains": ok}, ""); err != nil {
+			return err
+		}
+		if !ok {
+			return errNotContained
+		}
+		return nil
+	}
+
+	in := describe(prefix)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Address:   %s\n", in.Address)
+	fmt.Fprintf(&sb, "Network:   %s\n", in.Network)
+	fmt.Fprintf(&sb, "Netmask:   %s = %d\n", in.Netmask, in.Prefix)
+	if in.Broadcast != "" {
+		fmt.Fprintf(&sb, "Broadcast: %s\n", in.Broadcast)
+	}
+	fmt.Fprintf(&sb, "HostMin:   %s\n", in.HostMin)
+	fmt.Fprintf(&sb, "HostMax:   %s\n", in.HostMax)
+	fmt.Fprintf(&sb, "Hosts/Net: %s\n", in.Hosts)
+	return output(stdout, p.json, in, sb.String())
+}
+
+func main() {
+	var p params
+	flag.BoolVar(&p.json, "j", false, "print JSON")
+	flag.StringVar(&p.host, "host", "", "print address `N` of the network, counting back from the last address if negative")
+	flag.IntVar(&p.split, "split", 0, "print the subnets with prefix length `PREFIX`")
+	flag.StringVar(&p.contains, "contains", "", "check whether the network contains an address or network")
+	flag.Parse()
+	if err := run(os.Stdout, flag.Args(), p); errors.Is(err, errNotContained) {
+		os.Exit(1)
+	} else if err != nil {
+		log.Fatalf("ipcalc: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		p    params
+		want string
+		err  error
+	}{
+		{
+			name: "ipv4",
+			args: []string{"10.1.2.3/24"},
+			want: "Address:   10.1.2.3\nNetwork:   10.1.2.0/24\nNetmask:   255.255.255.0 = 24\nBroadcast: 10.1.2.255\n" +
+				"HostMin:   10.1.2.1\nHostMax:   10.1.2.254\nHosts/Net: 254\n",
+		},
+		{
+			name: "netmask",
+			args: []string{"192.168.7.9", "255.255.255.252"},
+			p:    params{json: true},
+			want: `{"address":"192.168.7.9","network":"192.168.7.8/30","netmask":"255.255.255.252","prefix":30,"broadcast":"192.168.7.11","hostmin":"192.168.7.9","hostmax":"192.168.7.10","hosts":2,"version":4}` + "\n",
+		},
+		{
+			name: "point to point",
+			args: []string{"10.0.0.0/31"},
+			p:    params{json: true},
+			want: `{"address":"10.0.0.0","network":"10.0.0.0/31","netmask":"255.255.255.254","prefix":31,"broadcast":"10.0.0.1","hostmin":"10.0.0.0","hostmax":"10.0.0.1","hosts":2,"version":4}` + "\n",
+		},
+		{
+			name: "ipv6",
+			args: []string{"2001:db8::1/64"},
+			p:    params{json: true},
+			want: `{"address":"2001:db8::1","network":"2001:db8::/64","netmask":"ffff:ffff:ffff:ffff::","prefix":64,"hostmin":"2001:db8::","hostmax":"2001:db8::ffff:ffff:ffff:ffff","hosts":18446744073709551616,"version":6}` + "\n",
+		},
+		{name: "host", args: []string{"10.0.4.0/22"}, p: params{host: "300"}, want: "10.0.5.44/22\n"},
+		{name: "host from the end", args: []string{"10.0.4.0/22"}, p: params{host: "-2", json: true}, want: `{"address":"10.0.7.254","cidr":"10.0.7.254/22"}` + "\n"},
+		{name: "host ipv6", args: []string{"fd00:1::/64"}, p: params{host: "18446744073709551615"}, want: "fd00:1::ffff:ffff:ffff:ffff/64\n"},
+		{name: "no such host", args: []string{"10.0.0.0/24"}, p: params{host: "256"}, err: errAny},
+		{name: "no such host from the end", args: []string{"10.0.0.0/24"}, p: params{host: "-257"}, err: errAny},
+		{name: "split", args: []string{"10.0.0.0/24"}, p: params{split: 26}, want: "10.0.0.0/26\n10.0.0.64/26\n10.0.0.128/26\n10.0.0.192/26\n"},
+		{name: "split json", args: []string{"2001:db8::/32"}, p: params{split: 33, json: true}, want: `["2001:db8::/33","2001:db8:8000::/33"]` + "\n"},
+		{name: "split shorter", args: []string{"10.0.0.0/24"}, p: params{split: 16}, err: errAny},
+		{name: "split too many", args: []string{"10.0.0.0/8"}, p: params{split: 30}, err: errAny},
+		{name: "contains", args: []string{"10.0.0.0/16"}, p: params{contains: "10.0.1.0/25"}},
+		{name: "contains json", args: []string{"10.0.0.0/16"}, p: params{contains: "10.0.1.7", json: true}, want: `{"contains":true}` + "\n"},
+		{name: "not contained", args: []string{"10.0.0.0/16"}, p: params{contains: "10.1.0.0"}, err: errNotContained},
+		{name: "larger network", args: []string{"10.0.0.0/16"}, p: params{contains: "10.0.0.0/8"}, err: errNotContained},
+		{name: "other version", args: []string{"10.0.0.0/16"}, p: params{contains: "::1"}, err: errNotContained},
+		{name: "bad address", args: []string{"10.0.0/16"}, err: errAny},
+		{name: "bad netmask", args: []string{"10.0.0.1", "255.0.255.0"}, err: errAny},
+		{name: "ipv6 netmask", args: []string{"::1", "255.255.255.0"}, err: errAny},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := run(&out, tt.args, tt.p)
+			if (err != nil) != (tt.err != nil) || (tt.err != errAny && !errors.Is(err, tt.err)) {
+				t.Fatalf("run = %v, want %v", err, tt.err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("run = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+// errAny is any error.
+var errAny = errors.New("any error")
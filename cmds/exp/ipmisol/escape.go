@@ -0,0 +1,54 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// action is what a key typed after the escape character does.
+type action int
+
+const (
+	actNone action = iota
+	actQuit
+	actBreak
+	actHelp
+)
+
+// escapeChar starts commands at the beginning of a line, as in ipmitool and
+// ssh.
+const escapeChar = '~'
+
+// escaper finds commands in what the user types: the escape character at the
+// beginning of a line, and then a key.
+type escaper struct {
+	// midLine is whether keys were typed since the last newline.
+	midLine bool
+	pending bool
+}
+
+// key handles the key c, and returns what to send to the console for it, if
+// anything, and what to do.
+func (e *escaper) key(c byte) ([]byte, action) {
+	if e.pending {
+		e.pending = false
+		switch c {
+		case '.':
+			return nil, actQuit
+		case 'B':
+			return nil, actBreak
+		case '?':
+			return nil, actHelp
+		case escapeChar:
+			e.midLine = true
+			return []byte{c}, actNone
+		}
+		e.midLine = c != '\r' && c != '\n'
+		return []byte{escapeChar, c}, actNone
+	}
+	if c == escapeChar && !e.midLine {
+		e.pending = true
+		return nil, actNone
+	}
+	e.midLine = c != '\r' && c != '\n'
+	return []byte{c}, actNone
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEscaper(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		keys string
+		send string
+		acts []action
+	}{
+		{name: "plain", keys: "ls ~\r", send: "ls ~\r"},
+		{name: "quit", keys: "~.", acts: []action{actQuit}},
+		{name: "quit after a line", keys: "ls\r~.", send: "ls\r", acts: []action{actQuit}},
+		{name: "not at the beginning of a line", keys: "cd ~.", send: "cd ~."},
+		{name: "literal escape", keys: "~~.", send: "~."},
+		{name: "break and help", keys: "~B~?", acts: []action{actBreak, actHelp}},
+		{name: "unknown command", keys: "~x\r~.", send: "~x\r", acts: []action{actQuit}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				e    escaper
+				send []byte
+				acts []action
+			)
+			for _, c := range []byte(tt.keys) {
+				b, act := e.key(c)
+				send = append(send, b...)
+				if act != actNone {
+					acts = append(acts, act)
+				}
+			}
+			if string(send) != tt.send {
+				t.Errorf("sent %q, want %q", send, tt.send)
+			}
+			if !reflect.DeepEqual(acts, tt.acts) {
+				t.Errorf("actions %v, want %v", acts, tt.acts)
+			}
+		})
+	}
+}
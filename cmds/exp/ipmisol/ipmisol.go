@@ -0,0 +1,202 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// ipmisol connects the terminal to the serial console of another machine,
+// with IPMI Serial over LAN to its BMC.
+//
+// Synopsis:
+//
+//	ipmisol [-U USER] [-P PASSWORD] [-L LEVEL] [-k KG] [-deactivate] HOST[:PORT]
+//
+// Description:
+//
+//	A session is opened with the BMC at HOST with IPMI v2.0 over LAN, as
+//	the lanplus interface of ipmitool does, with cipher suite 3, and SOL
+//	is activated in it. What is typed is sent to the serial port of the
+//	machine, and what it sends is shown. Commands are typed at the
+//	beginning of a line as ~ and then:
+//	  .  exit
+//	  B  send a break, e.g. for SysRq
+//	  ?  list the commands
+//	  ~  send ~
+//
+// Options:
+//
+//	-U: user name
+//	-P: password, $IPMI_PASSWORD by default, as with ipmitool -E
+//	-L: privilege level: USER, OPERATOR or ADMINISTRATOR (default ADMINISTRATOR)
+//	-k: key of the BMC, Kg, if it has one
+//	-deactivate: deactivate SOL first, e.g. if another session left it active
+//
+// Example:
+//
+//	IPMI_PASSWORD=secret ipmisol -U admin bmc-node1.example.com
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ipmi/lan"
+	"github.com/u-root/u-root/pkg/termios"
+)
+
+var (
+	user       = flag.String("U", "", "user name")
+	password   = flag.String("P", "", "password, $IPMI_PASSWORD by default")
+	level      = flag.String("L", "ADMINISTRATOR", "privilege level: USER, OPERATOR or ADMINISTRATOR")
+	kg         = flag.String("k", "", "key of the BMC, Kg, if it has one")
+	deactivate = flag.Bool("deactivate", false, "deactivate SOL first, e.g. if another session left it active")
+)
+
+const help = "\r\n*** ~. exit, ~B break, ~? help, ~~ send ~\r\n"
+
+// keepAlive is how often the session is kept alive, as BMCs close idle
+// sessions, usually after a minute.
+const keepAlive = 20 * time.Second
+
+var (
+	// errQuit is returned by session.run when the user quits.
+	errQuit = errors.New("quit")
+
+	errDeactivated = errors.New("SOL was deactivated")
+)
+
+// session connects a terminal to a SOL console.
+type session struct {
+	sol *lan.SOL
+	in  io.Reader
+	out io.Writer
+	esc escaper
+}
+
+// input sends what is typed to the console, and runs commands.
+func (s *session) input() error {
+	b := make([]byte, 256)
+	for {
+		n, err := s.in.Read(b)
+		var send []byte
+		for _, c := range b[:n] {
+			k, act := s.esc.key(c)
+			send = append(send, k...)
+			if act == actNone {
+				continue
+			}
+			if _, err := s.sol.Write(send); err != nil {
+				return err
+			}
+			send = send[:0]
+			switch act {
+			case actQuit:
+				return errQuit
+			case actBreak:
+				if err := s.sol.Break(); err != nil {
+					fmt.Fprintf(s.out, "\r\n*** break: %v\r\n", err)
+				}
+			case actHelp:
+				fmt.Fprint(s.out, help)
+			}
+		}
+		if _, err := s.sol.Write(send); err != nil {
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// run relays between the terminal and the console until the user quits, or
+// either fails.
+func (s *session) run(ls *lan.Session) error {
+	errc := make(chan error, 3)
+	go func() {
+		_, err := io.Copy(s.out, s.sol)
+		if err == nil {
+			err = errDeactivated
+		}
+		errc <- err
+	}()
+	go func() {
+		errc <- s.input()
+	}()
+	go func() {
+		t := time.NewTicker(keepAlive)
+		defer t.Stop()
+		for range t.C {
+			if err := ls.KeepAlive(); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+	return <-errc
+}
+
+func run(host string) error {
+	priv, err := lan.ParsePrivilege(*level)
+	if err != nil {
+		return err
+	}
+	c := lan.Config{User: *user, Password: *password, Privilege: priv}
+	if c.Password == "" {
+		c.Password = os.Getenv("IPMI_PASSWORD")
+	}
+	if *kg != "" {
+		c.Kg = []byte(*kg)
+	}
+	ls, err := lan.Dial(host, c)
+	if err != nil {
+		return err
+	}
+	defer ls.Close()
+
+	if *deactivate {
+		if err := ls.DeactivateSOL(); err != nil {
+			return err
+		}
+	}
+	sol, err := ls.ActivateSOL()
+	if errors.Is(err, lan.ErrSOLActive) {
+		return fmt.Errorf("%w; deactivate it with -deactivate", err)
+	}
+	if err != nil {
+		return err
+	}
+	defer sol.Close()
+
+	if termios.IsTerminal(os.Stdin.Fd()) {
+		tr, err := termios.SetMode(os.Stdin.Fd(), termios.MakeRaw)
+		if err != nil {
+			return err
+		}
+		defer tr.Restore()
+	}
+	fmt.Fprintf(os.Stdout, "*** SOL of %s; ~? for help\r\n", host)
+	s := &session{sol: sol, in: os.Stdin, out: os.Stdout}
+	if err := s.run(ls); err != errQuit {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "\r\n")
+	return nil
+}
+
+func main() {
+	log.SetPrefix("ipmisol: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: ipmisol [-U USER] [-P PASSWORD] [-L LEVEL] [-k KG] [-deactivate] HOST[:PORT]\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// join joins the lines of two files on a common field.
+//
+// Synopsis:
+//
+//	join [-i] [-t CHAR] [-1 FIELD] [-2 FIELD] [-j FIELD] [-a 1|2]... [-v 1|2]... [-e EMPTY] [-o LIST] FILE1 FILE2
+//
+// Description:
+//
+//	join prints a line for each pair of lines of FILE1 and FILE2 whose
+//	join fields are the same: the join field, then the other fields of
+//	the line of FILE1, then those of FILE2. Both files must be sorted on
+//	their join fields. A FILE "-" is stdin.
+//
+//	Fields are separated by blanks, and printed with one space between
+//	them, unless -t is given.
+//
+// Options:
+//
+//	-1: the join field of FILE1 (default 1)
+//	-2: the join field of FILE2 (default 1)
+//	-a: also print the lines of file 1 or 2 that do not pair
+//	-e: print EMPTY for missing fields of -o
+//	-i: ignore case when comparing fields
+//	-j: the join field of both files
+//	-o: print the fields of LIST, separated by commas or blanks, as
+//	    FILE.FIELD or 0 for the join field
+//	-t: the field separator
+//	-v: print only the lines of file 1 or 2 that do not pair
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// field is a field of the output, from file 1 or 2, or the join field if
+// file is 0.
+type field struct {
+	file, n int
+}
+
+type joiner struct {
+	sep      string
+	key      [2]int
+	unpaired [2]bool
+	paired   bool
+	empty    string
+	format   []field
+	fold     bool
+}
+
+func (j *joiner) split(line string) []string {
+	if j.sep == "" {
+		return strings.Fields(line)
+	}
+	return strings.Split(line, j.sep)
+}
+
+func (j *joiner) outSep() string {
+	if j.sep == "" {
+		return " "
+	}
+	return j.sep
+}
+
+func (j *joiner) compare(a, b string) int {
+	if j.fold {
+		a, b = strings.ToLower(a), strings.ToLower(b)
+	}
+	return strings.Compare(a, b)
+}
+
+// file reads the groups of lines with the same join field.
+type file struct {
+	s    *bufio.Scanner
+	key  int
+	next []string
+	j    *joiner
+	done bool
+}
+
+func (f *file) keyOf(fields []string) string {
+	if f.key < len(fields) {
+		return fields[f.key]
+	}
+	return ""
+}
+
+func (f *file) read() {
+	if !f.s.Scan() {
+		f.next, f.done = nil, true
+		return
+	}
+	f.next = f.j.split(f.s.Text())
+}
+
+// group returns the lines that have the join field of the next line.
+func (f *file) group() [][]string {
+	if f.done {
+		return nil
+	}
+	g := [][]string{f.next}
+	k := f.keyOf(f.next)
+	for f.read(); !f.done && f.j.compare(f.keyOf(f.next), k) == 0; f.read() {
+		g = append(g, f.next)
+	}
+	return g
+}
+
+func (j *joiner) print(w *bufio.Writer, l [2][]string) {
+	var key string
+	for i := range l {
+		if l[i] != nil && j.key[i] < len(l[i]) {
+			key = l[i][j.key[i]]
+			break
+		}
+	}
+	var out []string
+	if j.format == nil {
+		out = append(out, key)
+		for i := range l {
+			for n, f := range l[i] {
+				if n != j.key[i] {
+					out = append(out, f)
+				}
+			}
+		}
+	}
+	for _, f := range j.format {
+		v := j.empty
+		switch {
+		case f.file == 0:
+			if v = key; key == "" {
+				v = j.empty
+			}
+		case l[f.file-1] != nil && f.n < len(l[f.file-1]):
+			v = l[f.file-1][f.n]
+		}
+		out = append(out, v)
+	}
+	w.WriteString(strings.Join(out, j.outSep()))
+	w.WriteString("\n")
+}
+
+func (j *joiner) join(w *bufio.Writer, r1, r2 io.Reader) error {
+	var f [2]*file
+	for i, r := range []io.Reader{r1, r2} {
+		s := bufio.NewScanner(r)
+		s.Buffer(nil, 1<<20)
+		f[i] = &file{s: s, key: j.key[i], j: j}
+		f[i].read()
+	}
+	g := [2][][]string{f[0].group(), f[1].group()}
+	for g[0] != nil || g[1] != nil {
+		c := 0
+		switch {
+		case g[0] == nil:
+			c = 1
+		case g[1] == nil:
+			c = -1
+		default:
+			c = j.compare(f[0].keyOf(g[0][0]), f[1].keyOf(g[1][0]))
+		}
+		switch {
+		case c < 0:
+			if j.unpaired[0] {
+				for _, l := range g[0] {
+					j.print(w, [2][]string{l, nil})
+				}
+			}
+			g[0] = f[0].group()
+		case c > 0:
+			if j.unpaired[1] {
+				for _, l := range g[1] {
+					j.print(w, [2][]string{nil, l})
+				}
+			}
+			g[1] = f[1].group()
+		default:
+			if j.paired {
+				for _, l1 := range g[0] {
+					for _, l2 := range g[1] {
+						j.print(w, [2][]string{l1, l2})
+					}
+				}
+			}
+			g = [2][][]string{f[0].group(), f[1].group()}
+		}
+	}
+	for _, fi := range f {
+		if err := fi.s.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseFormat parses the -o LIST.
+func parseFormat(s string) ([]field, error) {
+	var fields []field
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		if f == "0" {
+			fields = append(fields, field{})
+			continue
+		}
+		file, n, ok := strings.Cut(f, ".")
+		i, err := strconv.Atoi(n)
+		if !ok || (file != "1" && file != "2") || err != nil || i < 1 {
+			return nil, fmt.Errorf("invalid field %q", f)
+		}
+		fields = append(fields, field{file: int(file[0] - '0'), n: i - 1})
+	}
+	return fields, nil
+}
+
+// fileNumbers collects the 1 and 2 arguments of -a and -v.
+type fileNumbers [2]bool
+
+func (f *fileNumbers) String() string {
+	return ""
+}
+
+func (f *fileNumbers) Set(s string) error {
+	switch s {
+	case "1", "2":
+		f[s[0]-'1'] = true
+		return nil
+	}
+	return fmt.Errorf("invalid file number %q", s)
+}
+
+func open(name string, stdin io.Reader) (io.Reader, func() error, error) {
+	if name == "-" {
+		return stdin, func() error { return nil }, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+func run(stdin io.Reader, stdout io.Writer, j *joiner, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: join [OPTION]... FILE1 FILE2")
+	}
+	if args[0] == "-" && args[1] == "-" {
+		return errors.New("only one FILE can be stdin")
+	}
+	r1, close1, err := open(args[0], stdin)
+	if err != nil {
+		return err
+	}
+	defer close1()
+	r2, close2, err := open(args[1], stdin)
+	if err != nil {
+		return err
+	}
+	defer close2()
+	w := bufio.NewWriter(stdout)
+	if err := j.join(w, r1, r2); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func main() {
+	j := &joiner{paired: true}
+	var a, v fileNumbers
+	f1 := flag.Int("1", 1, "the join field of FILE1")
+	f2 := flag.Int("2", 1, "the join field of FILE2")
+	both := flag.Int("j", 0, "the join field of both files")
+	flag.Var(&a, "a", "also print the lines of file 1 or 2 that do not pair")
+	flag.Var(&v, "v", "print only the lines of file 1 or 2 that do not pair")
+	flag.StringVar(&j.empty, "e", "", "print EMPTY for missing fields of -o")
+	flag.BoolVar(&j.fold, "i", false, "ignore case when comparing fields")
+	format := flag.String("o", "", "print the fields of LIST")
+	flag.StringVar(&j.sep, "t", "", "the field separator")
+	flag.Parse()
+
+	if *both != 0 {
+		*f1, *f2 = *both, *both
+	}
+	if *f1 < 1 || *f2 < 1 {
+		log.Fatalf("join: invalid field number")
+	}
+	j.key = [2]int{*f1 - 1, *f2 - 1}
+	j.unpaired = a
+	if v[0] || v[1] {
+		j.unpaired, j.paired = v, false
+	}
+	if *format != "" {
+		var err error
+		if j.format, err = parseFormat(*format); err != nil {
+			log.Fatalf("join: %v", err)
+		}
+	}
+	if err := run(os.Stdin, os.Stdout, j, flag.Args()); err != nil {
+		log.Fatalf("join: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJoin(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name   string
+		j      joiner
+		f1, f2 string
+		want   string
+	}{
+		{
+			name: "default",
+			j:    joiner{paired: true},
+			f1:   "a 1\nb 2\nc 3\n",
+			f2:   "a x\nc y\nd z\n",
+			want: "a 1 x\nc 3 y\n",
+		},
+		{
+			name: "duplicates",
+			j:    joiner{paired: true},
+			f1:   "a 1\na 2\n",
+			f2:   "a x\na y\n",
+			want: "a 1 x\na 1 y\na 2 x\na 2 y\n",
+		},
+		{
+			name: "unpaired",
+			j:    joiner{paired: true, unpaired: [2]bool{true, true}},
+			f1:   "a 1\nb 2\n",
+			f2:   "a x\nc y\n",
+			want: "a 1 x\nb 2\nc y\n",
+		},
+		{
+			name: "only unpaired",
+			j:    joiner{unpaired: [2]bool{true, false}},
+			f1:   "a 1\nb 2\n",
+			f2:   "a x\nc y\n",
+			want: "b 2\n",
+		},
+		{
+			name: "separator and fields",
+			j:    joiner{paired: true, sep: ",", key: [2]int{1, 0}},
+			f1:   "host1,00:11,rack1\nhost2,00:22,rack2\n",
+			f2:   "00:11,ok\n00:22,failed\n",
+			want: "00:11,host1,rack1,ok\n00:22,host2,rack2,failed\n",
+		},
+		{
+			name: "format",
+			j:    joiner{paired: true, unpaired: [2]bool{true, false}, empty: "-", format: []field{{0, 0}, {2, 1}, {1, 1}}},
+			f1:   "a 1\nb 2\n",
+			f2:   "a x\n",
+			want: "a x 1\nb - 2\n",
+		},
+		{
+			name: "ignore case",
+			j:    joiner{paired: true, fold: true},
+			f1:   "A 1\n",
+			f2:   "a x\n",
+			want: "A 1 x\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f1 := filepath.Join(dir, "f1")
+			if err := os.WriteFile(f1, []byte(tt.f1), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := run(strings.NewReader(tt.f2), &b, &tt.j, []string{f1, "-"}); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("join = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := parseFormat("0,1.2 2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []field{{0, 0}, {1, 1}, {2, 0}}; !reflect.DeepEqual(f, want) {
+		t.Errorf("parseFormat = %v, want %v", f, want)
+	}
+	for _, s := range []string{"3.1", "1.0", "1", "x.y"} {
+		if _, err := parseFormat(s); err == nil {
+			t.Errorf("parseFormat(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,540 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Dump levels, as in makedumpfile: each bit excludes a kind of page from
+// the dump.
+const (
+	// excludeZero is accepted for compatibility. Zero pages are stored
+	// as holes or compressed anyway.
+	excludeZero = 1 << iota
+	excludeCache
+	excludeCachePrivate
+	excludeUser
+	excludeFree
+
+	maxDumpLevel = 1<<iota - 1
+)
+
+const (
+	// sectionHasMemMap is set in mem_section.section_mem_map of the
+	// sections that have a mem_map.
+	sectionHasMemMap = 1 << 1
+	// sectionMapMask masks the flags in the low bits of section_mem_map.
+	// The mem_map it encodes is aligned beyond them.
+	sectionMapMask = ^uint64(1<<6 - 1)
+
+	// pageMappingAnon is set in page.mapping of anonymous pages.
+	pageMappingAnon = 1
+
+	// startKernelMap is where x86-64 kernels map their image.
+	startKernelMap = 0xffffffff80000000
+)
+
+// vmcoreInfo is the VMCOREINFO note of the crashed kernel, which describes
+// the layout of its memory management structures.
+type vmcoreInfo map[string]string
+
+func parseVmcoreInfo(b []byte) vmcoreInfo {
+	vi := vmcoreInfo{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok {
+			vi[k] = v
+		}
+	}
+	return vi
+}
+
+// number returns an integer entry of vi, e.g. NUMBER(PG_lru) or
+// SIZE(page).
+func (vi vmcoreInfo) number(key string) (int64, error) {
+	s, ok := vi[key]
+	if !ok {
+		return 0, fmt.Errorf("VMCOREINFO lacks %s", key)
+	}
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("VMCOREINFO %s: %v", key, err)
+	}
+	return n, nil
+}
+
+// symbol returns the address of SYMBOL(name), which is in hex.
+func (vi vmcoreInfo) symbol(name string) (uint64, error) {
+	key := "SYMBOL(" + name + ")"
+	s, ok := vi[key]
+	if !ok {
+		return 0, fmt.Errorf("VMCOREINFO lacks %s", key)
+	}
+	n, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("VMCOREINFO %s: %v", key, err)
+	}
+	return n, nil
+}
+
+// readVmcoreInfo returns the VMCOREINFO note of the core f.
+func readVmcoreInfo(f *elf.File) (vmcoreInfo, error) {
+	align := func(n uint32) uint64 { return (uint64(n) + 3) &^ 3 }
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_NOTE {
+			continue
+		}
+		b, err := io.ReadAll(p.Open())
+		if err != nil {
+			return nil, err
+		}
+		for len(b) >= 12 {
+			namesz, descsz := f.ByteOrder.Uint32(b), f.ByteOrder.Uint32(b[4:])
+			b = b[12:]
+			name, desc := align(namesz), align(descsz)
+			if uint64(len(b)) < name+desc {
+				break
+			}
+			if strings.TrimRight(string(b[:namesz]), "\x00") == "VMCOREINFO" {
+				return parseVmcoreInfo(b[name : name+uint64(descsz)]), nil
+			}
+			b = b[name+desc:]
+		}
+	}
+	return nil, errors.New("no VMCOREINFO note in the core")
+}
+
+// segment is a PT_LOAD segment of the core: size bytes of physical memory
+// at paddr, mapped at vaddr, at off in the core.
+type segment struct {
+	off, vaddr, paddr, size uint64
+
+	// excluded is a bitmap of the pages excluded from the dump, counted
+	// from the one at paddr.
+	excluded []uint64
+}
+
+// memory reads the crashed kernel's memory from the core.
+type memory struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+	segs  []*segment
+
+	// pgd is the physical address of the top page table of an x86-64
+	// kernel, if known, and l5 whether it has 5 levels.
+	pgd     uint64
+	l5      bool
+	smeMask uint64
+
+	// page caches the physical page at pageAddr.
+	page     []byte
+	pageAddr uint64
+
+	// The last page table walk mapped size bytes at virt to phys.
+	virt, phys, size uint64
+}
+
+func newMemory(r io.ReaderAt, f *elf.File) *memory {
+	m := &memory{r: r, order: f.ByteOrder, page: make([]byte, pageSize), pageAddr: ^uint64(0)}
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD {
+			m.segs = append(m.segs, &segment{off: p.Off, vaddr: p.Vaddr, paddr: p.Paddr, size: p.Filesz})
+		}
+	}
+	return m
+}
+
+// initX86 finds the page tables of an x86-64 kernel, which map vmemmap.
+func (m *memory) initX86(vi vmcoreInfo) error {
+	pgd, err := vi.symbol("init_top_pgt")
+	if err != nil {
+		if pgd, err = vi.symbol("init_level4_pgt"); err != nil {
+			return err
+		}
+	}
+	p, err := m.translate(pgd)
+	if err != nil {
+		base, perr := vi.number("NUMBER(phys_base)")
+		if perr != nil {
+			return err
+		}
+		p = pgd - startKernelMap + uint64(base)
+	}
+	m.pgd = p
+	if l5, err := vi.number("NUMBER(pgtable_l5_enabled)"); err == nil {
+		m.l5 = l5 != 0
+	}
+	if sme, err := vi.number("NUMBER(sme_mask)"); err == nil {
+		m.smeMask = uint64(sme)
+	}
+	return nil
+}
+
+// readPhys reads b from physical address p. b must not cross a page.
+func (m *memory) readPhys(p uint64, b []byte) error {
+	page := p &^ (pageSize - 1)
+	if page != m.pageAddr {
+		s := m.segment(page)
+		if s == nil || page+pageSize-s.paddr > s.size {
+			// The page is not all in one segment.
+			if s = m.segment(p); s == nil || p+uint64(len(b))-s.paddr > s.size {
+				return fmt.Errorf("physical address %#x is not in the core", p)
+			}
+			_, err := m.r.ReadAt(b, int64(s.off+p-s.paddr))
+			return err
+		}
+		if _, err := m.r.ReadAt(m.page, int64(s.off+page-s.paddr)); err != nil {
+			return err
+		}
+		m.pageAddr = page
+	}
+	copy(b, m.page[p-page:])
+	return nil
+}
+
+func (m *memory) segment(p uint64) *segment {
+	for _, s := range m.segs {
+		if p >= s.paddr && p-s.paddr < s.size {
+			return s
+		}
+	}
+	return nil
+}
+
+// translate returns the physical address of the kernel virtual address v.
+func (m *memory) translate(v uint64) (uint64, error) {
+	for _, s := range m.segs {
+		if s.vaddr != 0 && v >= s.vaddr && v-s.vaddr < s.size {
+			return s.paddr + v - s.vaddr, nil
+		}
+	}
+	if v-m.virt < m.size {
+		return m.phys + v - m.virt, nil
+	}
+	if m.pgd == 0 {
+		return 0, fmt.Errorf("cannot translate address %#x", v)
+	}
+	return m.walkX86(v)
+}
+
+// walkX86 translates v with the x86-64 page tables at pgd.
+func (m *memory) walkX86(v uint64) (uint64, error) {
+	const (
+		present  = 1 << 0
+		huge     = 1 << 7
+		addrMask = 0x000ffffffffff000
+	)
+	shifts := []uint{39, 30, 21, 12}
+	if m.l5 {
+		shifts = []uint{48, 39, 30, 21, 12}
+	}
+	table := m.pgd
+	b := make([]byte, 8)
+	for _, shift := range shifts {
+		if err := m.readPhys(table+(v>>shift&0x1ff)*8, b); err != nil {
+			return 0, err
+		}
+		e := m.order.Uint64(b) &^ m.smeMask
+		if e&present == 0 {
+			return 0, fmt.Errorf("address %#x is not mapped", v)
+		}
+		size := uint64(1) << shift
+		if shift == 12 || (shift <= 30 && e&huge != 0) {
+			m.virt, m.phys, m.size = v&^(size-1), e&addrMask&^(size-1), size
+			return m.phys + v - m.virt, nil
+		}
+		table = e & addrMask
+	}
+	return 0, fmt.Errorf("address %#x is not mapped", v)
+}
+
+// readVirt reads b from kernel virtual address v.
+func (m *memory) readVirt(v uint64, b []byte) error {
+	for len(b) > 0 {
+		p, err := m.translate(v)
+		if err != nil {
+			return err
+		}
+		n := pageSize - v%pageSize
+		if n > uint64(len(b)) {
+			n = uint64(len(b))
+		}
+		if err := m.readPhys(p, b[:n]); err != nil {
+			return err
+		}
+		v, b = v+n, b[n:]
+	}
+	return nil
+}
+
+func (m *memory) readPointer(v uint64) (uint64, error) {
+	b := make([]byte, 8)
+	if err := m.readVirt(v, b); err != nil {
+		return 0, err
+	}
+	return m.order.Uint64(b), nil
+}
+
+// memMap finds the struct page of a page frame of a SPARSEMEM_EXTREME
+// kernel, as all 64-bit kernels are.
+type memMap struct {
+	// roots is the address of the array of pointers to the roots of
+	// mem_section.
+	roots            uint64
+	nroots           uint64
+	sectionsPerRoot  uint64
+	sectionSize      uint64
+	sectionMemMap    uint64
+	pfnSectionShift  uint
+	pageStructSize   uint64
+	lastRoot, rootAt uint64
+}
+
+func newMemMap(vi vmcoreInfo) (*memMap, error) {
+	roots, err := vi.symbol("mem_section")
+	if err != nil {
+		return nil, fmt.Errorf("only SPARSEMEM kernels are supported: %v", err)
+	}
+	mm := &memMap{roots: roots, lastRoot: ^uint64(0)}
+	for _, f := range []struct {
+		key string
+		n   *uint64
+	}{
+		{"LENGTH(mem_section)", &mm.nroots},
+		{"SIZE(mem_section)", &mm.sectionSize},
+		{"OFFSET(mem_section.section_mem_map)", &mm.sectionMemMap},
+		{"SIZE(page)", &mm.pageStructSize},
+	} {
+		n, err := vi.number(f.key)
+		if err != nil {
+			return nil, err
+		}
+		*f.n = uint64(n)
+	}
+	if mm.sectionSize == 0 || mm.sectionSize > pageSize {
+		return nil, fmt.Errorf("bad SIZE(mem_section) %d", mm.sectionSize)
+	}
+	mm.sectionsPerRoot = pageSize / mm.sectionSize
+	bits, err := vi.number("NUMBER(SECTION_SIZE_BITS)")
+	if err != nil {
+		return nil, err
+	}
+	if bits < 12 || bits > 63 {
+		return nil, fmt.Errorf("bad SECTION_SIZE_BITS %d", bits)
+	}
+	mm.pfnSectionShift = uint(bits) - 12
+	return mm, nil
+}
+
+// page returns the address of the struct page of pfn, or false if it has
+// none.
+func (mm *memMap) page(m *memory, pfn uint64) (uint64, bool, error) {
+	nr := pfn >> mm.pfnSectionShift
+	root := nr / mm.sectionsPerRoot
+	if root >= mm.nroots {
+		return 0, false, nil
+	}
+	if root != mm.lastRoot {
+		at, err := m.readPointer(mm.roots + root*8)
+		if err != nil {
+			return 0, false, fmt.Errorf("reading mem_section root %d: %v", root, err)
+		}
+		mm.lastRoot, mm.rootAt = root, at
+	}
+	if mm.rootAt == 0 {
+		return 0, false, nil
+	}
+	smm, err := m.readPointer(mm.rootAt + nr%mm.sectionsPerRoot*mm.sectionSize + mm.sectionMemMap)
+	if err != nil {
+		return 0, false, fmt.Errorf("reading mem_section %d: %v", nr, err)
+	}
+	if smm&sectionHasMemMap == 0 {
+		return 0, false, nil
+	}
+	return smm&sectionMapMask + pfn*mm.pageStructSize, true, nil
+}
+
+// pageLayout is where the fields of struct page that tell what a page is
+// used for are, and how to read them.
+type pageLayout struct {
+	level int
+	buf   []byte
+
+	flags, mapping, mapcount, private int
+	lru, privateBit, swapcache        uint
+	buddy                             uint32
+}
+
+func newPageLayout(vi vmcoreInfo, level int) (*pageLayout, error) {
+	size, err := vi.number("SIZE(page)")
+	if err != nil {
+		return nil, err
+	}
+	pl := &pageLayout{level: level, buf: make([]byte, size)}
+	type field struct {
+		key string
+		n   *int
+	}
+	fields := []field{
+		{"OFFSET(page.flags)", &pl.flags},
+		{"OFFSET(page.mapping)", &pl.mapping},
+	}
+	if level&excludeFree != 0 {
+		fields = append(fields,
+			field{"OFFSET(page._mapcount)", &pl.mapcount},
+			field{"OFFSET(page.private)", &pl.private})
+		buddy, err := vi.number("NUMBER(PAGE_BUDDY_MAPCOUNT_VALUE)")
+		if err != nil {
+			return nil, err
+		}
+		pl.buddy = uint32(buddy)
+	}
+	for _, f := range fields {
+		n, err := vi.number(f.key)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || n+8 > size {
+			return nil, fmt.Errorf("%s %d is outside of struct page", f.key, n)
+		}
+		*f.n = int(n)
+	}
+	if level&(excludeCache|excludeCachePrivate) != 0 {
+		for _, f := range []struct {
+			key string
+			n   *uint
+		}{
+			{"NUMBER(PG_lru)", &pl.lru},
+			{"NUMBER(PG_private)", &pl.privateBit},
+			{"NUMBER(PG_swapcache)", &pl.swapcache},
+		} {
+			n, err := vi.number(f.key)
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 || n > 63 {
+				return nil, fmt.Errorf("bad %s %d", f.key, n)
+			}
+			*f.n = uint(n)
+		}
+	}
+	return pl, nil
+}
+
+// excluded reads the struct page at addr, and returns how many pages from
+// its own on are excluded at the dump level: more than one for a block of
+// free pages.
+func (pl *pageLayout) excluded(m *memory, addr uint64) (uint64, error) {
+	if err := m.readVirt(addr, pl.buf); err != nil {
+		return 0, err
+	}
+	flags := m.order.Uint64(pl.buf[pl.flags:])
+	mapping := m.order.Uint64(pl.buf[pl.mapping:])
+	anon := mapping&pageMappingAnon != 0
+	cache := flags&(1<<pl.lru|1<<pl.swapcache) != 0 && !anon
+
+	switch {
+	case pl.level&excludeFree != 0 && m.order.Uint32(pl.buf[pl.mapcount:]) == pl.buddy:
+		// The first page of a free block has its order in private.
+		if order := m.order.Uint64(pl.buf[pl.private:]); order < 32 {
+			return 1 << order, nil
+		}
+		return 0, nil
+	case pl.level&excludeCache != 0 && cache && flags&(1<<pl.privateBit) == 0:
+		return 1, nil
+	case pl.level&excludeCachePrivate != 0 && cache:
+		return 1, nil
+	case pl.level&excludeUser != 0 && anon:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// pageFilter finds the pages of the core r to exclude at the dump level
+// from the crashed kernel's struct pages. It returns whether the page at
+// a page aligned offset of the core is excluded, and how many pages are.
+func pageFilter(r io.ReaderAt, level int) (func(off int64) bool, uint64, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if f.Class != elf.ELFCLASS64 {
+		return nil, 0, fmt.Errorf("filtering %v cores is not supported", f.Class)
+	}
+	vi, err := readVmcoreInfo(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	if size, err := vi.number("PAGESIZE"); err != nil {
+		return nil, 0, err
+	} else if size != pageSize {
+		return nil, 0, fmt.Errorf("filtering pages of %d bytes is not supported", size)
+	}
+	m := newMemory(r, f)
+	if f.Machine == elf.EM_X86_64 {
+		if err := m.initX86(vi); err != nil {
+			return nil, 0, fmt.Errorf("finding the page tables: %v", err)
+		}
+	}
+	mm, err := newMemMap(vi)
+	if err != nil {
+		return nil, 0, err
+	}
+	pl, err := newPageLayout(vi, level)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total uint64
+	for _, s := range m.segs {
+		base := s.paddr / pageSize
+		end := (s.paddr + s.size) / pageSize
+		s.excluded = make([]uint64, (end-base+63)/64)
+		for pfn := (s.paddr + pageSize - 1) / pageSize; pfn < end; {
+			addr, ok, err := mm.page(m, pfn)
+			if err != nil {
+				return nil, 0, err
+			}
+			n := uint64(0)
+			if ok {
+				if n, err = pl.excluded(m, addr); err != nil {
+					return nil, 0, fmt.Errorf("reading the struct page of pfn %#x: %v", pfn, err)
+				}
+			}
+			for i := uint64(0); i < n && pfn+i < end; i++ {
+				j := pfn + i - base
+				s.excluded[j/64] |= 1 << (j % 64)
+				total++
+			}
+			if n == 0 {
+				n = 1
+			}
+			pfn += n
+		}
+	}
+
+	exclude := func(off int64) bool {
+		o := uint64(off)
+		for _, s := range m.segs {
+			if o < s.off || o-s.off >= s.size {
+				continue
+			}
+			p := s.paddr + o - s.off
+			if p%pageSize != 0 || o-s.off+pageSize > s.size {
+				return false
+			}
+			i := p/pageSize - s.paddr/pageSize
+			return s.excluded[i/64]&(1<<(i%64)) != 0
+		}
+		return false
+	}
+	return exclude, total, nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const (
+	testPageOffset = 0xffff888000000000
+	testPages      = 16
+	// testCoreOff is where the memory of the test core starts.
+	testCoreOff = 2 * pageSize
+)
+
+// testCore returns an x86-64 core with testPages pages of memory at
+// physical address 0, mapped at testPageOffset. Page 0 holds the roots of
+// mem_section, page 1 the only section, and page 2 its mem_map, whose
+// struct pages describe:
+//
+//	4: a page cache page
+//	5: a page cache page with private data
+//	6: an anonymous user page
+//	8: a free block of order 1
+//	10: a kernel page
+//
+// The page tables are never walked, as the segment maps all of the memory.
+func testCore(t *testing.T) []byte {
+	t.Helper()
+	le := binary.LittleEndian
+	mem := make([]byte, testPages*pageSize)
+	for i := 3 * pageSize; i < len(mem); i++ {
+		mem[i] = 0xaa
+	}
+	le.PutUint64(mem, testPageOffset+pageSize)
+	le.PutUint64(mem[pageSize:], testPageOffset+2*pageSize|sectionHasMemMap)
+	const (
+		lru     = 1 << 4
+		private = 1 << 13
+		// A page cache page's mapping is its struct address_space.
+		cacheMapping = testPageOffset + 0x1000
+		buddy        = 0xffffff7f
+	)
+	for pfn := 0; pfn < testPages; pfn++ {
+		var flags, mapping, order uint64
+		mc := uint32(0xffffffff)
+		switch pfn {
+		case 4:
+			flags, mapping = lru, cacheMapping
+		case 5:
+			flags, mapping = lru|private, cacheMapping
+		case 6:
+			flags, mapping = lru, cacheMapping|pageMappingAnon
+		case 8:
+			mc, order = buddy, 1
+		}
+		page := mem[2*pageSize+pfn*64:]
+		le.PutUint64(page, flags)
+		le.PutUint64(page[24:], mapping)
+		le.PutUint64(page[40:], order)
+		le.PutUint32(page[48:], mc)
+	}
+
+	info := fmt.Sprintf(`OSRELEASE=5.15.0
+PAGESIZE=4096
+SYMBOL(init_top_pgt)=%x
+SYMBOL(mem_section)=%x
+LENGTH(mem_section)=2048
+SIZE(mem_section)=16
+OFFSET(mem_section.section_mem_map)=0
+NUMBER(SECTION_SIZE_BITS)=27
+SIZE(page)=64
+OFFSET(page.flags)=0
+OFFSET(page.mapping)=24
+OFFSET(page.private)=40
+OFFSET(page._mapcount)=48
+NUMBER(PG_lru)=4
+NUMBER(PG_private)=13
+NUMBER(PG_swapcache)=10
+NUMBER(PAGE_BUDDY_MAPCOUNT_VALUE)=-129
+`, uint64(testPageOffset+pageSize*(testPages-1)), uint64(testPageOffset))
+	var note bytes.Buffer
+	for _, n := range []struct {
+		name, desc string
+	}{
+		{"CORE", "prstatus"},
+		{"VMCOREINFO", info},
+	} {
+		binary.Write(&note, le, [3]uint32{uint32(len(n.name) + 1), uint32(len(n.desc)), 0})
+		note.WriteString(n.name + "\x00")
+		note.Write(make([]byte, (4-(len(n.name)+1)%4)%4))
+		note.WriteString(n.desc)
+		note.Write(make([]byte, (4-len(n.desc)%4)%4))
+	}
+
+	var b bytes.Buffer
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_CORE),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: 56,
+		Phnum:     2,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	binary.Write(&b, le, hdr)
+	binary.Write(&b, le, elf.Prog64{
+		Type:   uint32(elf.PT_NOTE),
+		Off:    pageSize,
+		Filesz: uint64(note.Len()),
+	})
+	binary.Write(&b, le, elf.Prog64{
+		Type:   uint32(elf.PT_LOAD),
+		Off:    testCoreOff,
+		Vaddr:  testPageOffset,
+		Filesz: uint64(len(mem)),
+		Memsz:  uint64(len(mem)),
+	})
+	b.Write(make([]byte, pageSize-b.Len()))
+	b.Write(note.Bytes())
+	b.Write(make([]byte, testCoreOff-b.Len()))
+	b.Write(mem)
+	return b.Bytes()
+}
+
+func TestPageFilter(t *testing.T) {
+	core := testCore(t)
+	for _, tt := range []struct {
+		level int
+		want  []int
+	}{
+		{level: excludeZero},
+		{level: excludeCache, want: []int{4}},
+		{level: excludeCachePrivate, want: []int{4, 5}},
+		{level: excludeUser, want: []int{6}},
+		{level: excludeFree, want: []int{8, 9}},
+		{level: maxDumpLevel, want: []int{4, 5, 6, 8, 9}},
+	} {
+		exclude, n, err := pageFilter(bytes.NewReader(core), tt.level)
+		if err != nil {
+			t.Fatalf("pageFilter(level %d) = %v", tt.level, err)
+		}
+		var got []int
+		for pfn := 0; pfn < testPages; pfn++ {
+			if exclude(testCoreOff + int64(pfn)*pageSize) {
+				got = append(got, pfn)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) || n != uint64(len(tt.want)) {
+			t.Errorf("pageFilter(level %d) excludes %d pages %v, want %v", tt.level, n, got, tt.want)
+		}
+	}
+
+	// Pages outside of the memory are never excluded.
+	exclude, _, err := pageFilter(bytes.NewReader(core), maxDumpLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, off := range []int64{0, pageSize, testCoreOff + testPages*pageSize} {
+		if exclude(off) {
+			t.Errorf("page at %#x is excluded", off)
+		}
+	}
+}
+
+func TestPageFilterErrors(t *testing.T) {
+	core := testCore(t)
+	// Drop SIZE(page), which every level needs.
+	i := bytes.Index(core, []byte("SIZE(page)"))
+	copy(core[i:], "SIZE(gage)")
+	if _, _, err := pageFilter(bytes.NewReader(core), excludeFree); err == nil {
+		t.Errorf("pageFilter without SIZE(page) succeeded")
+	}
+}
+
+func TestCopyCoreFiltered(t *testing.T) {
+	core := testCore(t)
+	exclude, _, err := pageFilter(bytes.NewReader(core), excludeUser|excludeFree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	st, err := copyCore(&b, nil, nil, bytes.NewReader(core), exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.filtered != 3*pageSize {
+		t.Errorf("filtered %d bytes, want %d", st.filtered, 3*pageSize)
+	}
+	want := append([]byte(nil), core...)
+	for _, pfn := range []int{6, 8, 9} {
+		copy(want[testCoreOff+pfn*pageSize:], make([]byte, pageSize))
+	}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("filtered copy differs from the core with pages 6, 8 and 9 zeroed")
+	}
+}
+
+func TestWalkX86(t *testing.T) {
+	const (
+		vmemmap = 0xffffea0000000000
+		present = 1
+		huge    = 1 << 7
+	)
+	le := binary.LittleEndian
+	mem := make([]byte, 4*pageSize)
+	le.PutUint64(mem[(vmemmap>>39&0x1ff)*8:], pageSize|present)
+	le.PutUint64(mem[pageSize:], 2*pageSize|present)
+	le.PutUint64(mem[2*pageSize:], 0x200000|huge|present)
+	le.PutUint64(mem[2*pageSize+8:], 3*pageSize|present)
+	le.PutUint64(mem[3*pageSize:], 0x5000|present)
+
+	m := &memory{
+		r:        bytes.NewReader(mem),
+		order:    le,
+		segs:     []*segment{{size: uint64(len(mem))}},
+		page:     make([]byte, pageSize),
+		pageAddr: ^uint64(0),
+	}
+	for _, tt := range []struct {
+		v, want uint64
+		ok      bool
+	}{
+		{v: vmemmap + 0x1234, want: 0x201234, ok: true},
+		{v: vmemmap + 0x1fffff, want: 0x3fffff, ok: true},
+		{v: vmemmap + 0x200010, want: 0x5010, ok: true},
+		{v: vmemmap + 0x201000},
+		{v: vmemmap + 0x400000},
+	} {
+		got, err := m.walkX86(tt.v)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("walkX86(%#x) = %#x, %v, want %#x, ok %v", tt.v, got, err, tt.want, tt.ok)
+		}
+	}
+}
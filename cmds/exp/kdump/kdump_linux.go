@@ -0,0 +1,293 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// kdump saves the memory image of a crashed kernel.
+//
+// Synopsis:
+//
+//	kdump [-i /proc/vmcore] [-d LEVEL] [-z] [-sparse=false] [-reboot] DEST
+//
+// Description:
+//
+//	When booted as the crash kernel, the previous kernel's memory is
+//	exposed as an ELF core file in /proc/vmcore. kdump copies it to DEST,
+//	which is either a local path or an http(s) URL the dump is PUT to.
+//
+//	Pages that are entirely zero are stored as holes, which leaves a sparse
+//	local file that reads back byte for byte identical to the input. With
+//	-z the dump is gzip compressed instead, and zero pages cost next to
+//	nothing.
+//
+//	With -d, pages that are not needed to debug the kernel are filtered
+//	out as by makedumpfile. LEVEL is the sum of
+//
+//	 2: page cache pages without private data
+//	 4: all page cache pages
+//	 8: user process pages
+//	16: free pages
+//
+//	1, zero pages, is accepted and changes nothing. The pages are found
+//	from the crashed kernel's struct pages, which its VMCOREINFO describes;
+//	this needs a SPARSEMEM kernel, and walks its page tables on x86-64.
+//	Filtered pages are written as zero pages, so the dump keeps the layout
+//	of the input and its filtered pages cost as little as zero pages.
+//
+//	A SHA-256 digest of the written dump is printed, and stored in
+//	DEST.sha256 for local destinations.
+//
+// Options:
+//
+//	-i:      input core file (default /proc/vmcore)
+//	-d:      dump LEVEL, pages to filter out (default 0)
+//	-z:      gzip compress the dump
+//	-sparse: skip zero pages in uncompressed local dumps (default true)
+//	-reboot: reboot once the dump has been saved
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/klauspost/pgzip"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	input    = flag.String("i", "/proc/vmcore", "input core file")
+	level    = flag.Int("d", 0, "dump `LEVEL`, pages to filter out: the sum of 2 (cache), 4 (cache and private cache), 8 (user) and 16 (free)")
+	compress = flag.Bool("z", false, "gzip compress the dump")
+	sparse   = flag.Bool("sparse", true, "skip zero pages in uncompressed local dumps")
+	reboot   = flag.Bool("reboot", false, "reboot once the dump has been saved")
+)
+
+const pageSize = 4096
+
+// stats describes a finished copy.
+type stats struct {
+	total int64
+	// holes is the number of zero bytes stored as holes.
+	holes int64
+	// filtered is the number of bytes written as zeros.
+	filtered int64
+}
+
+// copyCore copies the core from r to w page by page. If exclude is non-nil,
+// the pages at the offsets it excludes are replaced by zero pages. If ws is
+// non-nil zero pages are skipped by seeking ws instead of writing them. If h
+// is non-nil, it sees all of the copy, including the zero pages not written
+// to w.
+func copyCore(w io.Writer, ws io.WriteSeeker, h io.Writer, r io.Reader, exclude func(int64) bool) (stats, error) {
+	var (
+		st   stats
+		page = make([]byte, pageSize)
+		zero = make([]byte, pageSize)
+		hole int64
+	)
+	for {
+		n, err := io.ReadFull(r, page)
+		if n > 0 {
+			if exclude != nil && n == pageSize && exclude(st.total) {
+				copy(page, zero)
+				st.filtered += int64(n)
+			}
+			st.total += int64(n)
+			if h != nil {
+				h.Write(page[:n])
+			}
+			if ws != nil && n == pageSize && bytes.Equal(page, zero) {
+				hole += int64(n)
+				st.holes += int64(n)
+			} else {
+				if hole > 0 {
+					if _, err := ws.Seek(hole, io.SeekCurrent); err != nil {
+						return st, err
+					}
+					hole = 0
+				}
+				if _, err := w.Write(page[:n]); err != nil {
+					return st, err
+				}
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return st, err
+		}
+	}
+	if hole > 0 {
+		// Extend the file over the trailing hole.
+		if _, err := ws.Seek(hole-1, io.SeekCurrent); err != nil {
+			return st, err
+		}
+		if _, err := w.Write([]byte{0}); err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// checkCore makes sure the input looks like an ELF core before we spend
+// minutes copying it.
+func checkCore(path string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s is not an ELF core: %v", path, err)
+	}
+	defer f.Close()
+	if f.Type != elf.ET_CORE {
+		return fmt.Errorf("%s is %v, want ET_CORE", path, f.Type)
+	}
+	return nil
+}
+
+func isURL(dest string) bool {
+	return strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://")
+}
+
+func dumpToURL(dest string, in io.Reader, exclude func(int64) bool, gz bool) (string, stats, error) {
+	pr, pw := io.Pipe()
+	h := sha256.New()
+	var st stats
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var w io.Writer = io.MultiWriter(pw, h)
+		var zw *pgzip.Writer
+		if gz {
+			zw = pgzip.NewWriter(w)
+			w = zw
+		}
+		var err error
+		st, err = copyCore(w, nil, nil, in, exclude)
+		if err == nil && zw != nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest(http.MethodPut, dest, pr)
+	if err != nil {
+		return "", st, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	// Unblock the copy if the request ended early.
+	pr.Close()
+	<-done
+	if err != nil {
+		return "", st, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", st, fmt.Errorf("%s: %s", dest, resp.Status)
+	}
+	return hex.EncodeToString(h.Sum(nil)), st, nil
+}
+
+func dumpToFile(dest string, in io.Reader, exclude func(int64) bool, gz, sparse bool) (string, stats, error) {
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", stats{}, err
+	}
+	defer f.Close()
+
+	var (
+		st stats
+		h  = sha256.New()
+	)
+	if gz {
+		zw := pgzip.NewWriter(io.MultiWriter(f, h))
+		if st, err = copyCore(zw, nil, nil, in, exclude); err != nil {
+			return "", st, err
+		}
+		if err := zw.Close(); err != nil {
+			return "", st, err
+		}
+	} else {
+		var ws io.WriteSeeker
+		if sparse {
+			ws = f
+		}
+		if st, err = copyCore(f, ws, h, in, exclude); err != nil {
+			return "", st, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return "", st, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err := os.WriteFile(dest+".sha256", []byte(sum+"  "+dest+"\n"), 0o644); err != nil {
+		return sum, st, err
+	}
+	return sum, st, nil
+}
+
+func run(in, dest string, level int, gz, sparse bool) error {
+	if level < 0 || level > maxDumpLevel {
+		return fmt.Errorf("dump level %d is not in [0, %d]", level, maxDumpLevel)
+	}
+	if err := checkCore(in); err != nil {
+		return err
+	}
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var exclude func(int64) bool
+	if level&^excludeZero != 0 {
+		var pages uint64
+		if exclude, pages, err = pageFilter(f, level); err != nil {
+			return fmt.Errorf("filtering at dump level %d: %v", level, err)
+		}
+		log.Printf("Filtering %d pages at dump level %d", pages, level)
+	}
+
+	var (
+		sum string
+		st  stats
+	)
+	if isURL(dest) {
+		sum, st, err = dumpToURL(dest, f, exclude, gz)
+	} else {
+		sum, st, err = dumpToFile(dest, f, exclude, gz, sparse)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Saved %d bytes (%d filtered, %d zero bytes stored as holes) to %s, sha256 %s", st.total, st.filtered, st.holes, dest, sum)
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal(errors.New("usage: kdump [-i /proc/vmcore] [-d LEVEL] [-z] [-sparse=false] [-reboot] DEST"))
+	}
+	err := run(*input, flag.Arg(0), *level, *compress, *sparse)
+	if err != nil {
+		log.Printf("kdump: %v", err)
+	}
+	if *reboot {
+		unix.Sync()
+		if err := unix.Reboot(unix.LINUX_REBOOT_CMD_RESTART); err != nil {
+			log.Fatalf("kdump: reboot: %v", err)
+		}
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyCoreSparse(t *testing.T) {
+	core := make([]byte, 4*pageSize+100)
+	copy(core, "\x7fELF")
+	core[2*pageSize+7] = 0xaa
+
+	out := filepath.Join(t.TempDir(), "vmcore")
+	f, err := os.Create(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.New()
+	st, err := copyCore(f, f, h, bytes.NewReader(core), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if st.total != int64(len(core)) || st.holes != 2*pageSize {
+		t.Errorf("stats = %+v, want total %d, holes %d", st, len(core), 2*pageSize)
+	}
+	if want := sha256.Sum256(core); !bytes.Equal(h.Sum(nil), want[:]) {
+		t.Errorf("digest %x, want %x", h.Sum(nil), want)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, core) {
+		t.Errorf("sparse copy differs from input")
+	}
+}
+
+func TestCopyCoreTrailingHole(t *testing.T) {
+	core := make([]byte, 3*pageSize)
+	core[0] = 1
+
+	out := filepath.Join(t.TempDir(), "vmcore")
+	f, err := os.Create(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := copyCore(f, f, nil, bytes.NewReader(core), nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, core) {
+		t.Errorf("copy with trailing hole has length %d, want %d", len(got), len(core))
+	}
+}
+
+func TestCheckCore(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	if err := checkCore(exe); err == nil {
+		t.Errorf("checkCore(%s) succeeded for an executable, want error", exe)
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// logfwd forwards the kernel log and command output to a remote collector,
+// and keeps it across reboots.
+//
+// Synopsis:
+//
+//	logfwd [-n ADDR] [-P udp|tcp|http] [-kmsg=false] [-b N] [-i DURATION] [-host TEMPLATE]
+//	       [-efi-pstore] [-part DEV] [-file PATH [-esp DEV]] [-- COMMAND [ARGS...]]
+//
+// Description:
+//
+//	logfwd reads /dev/kmsg from the start of the ring buffer and forwards
+//	each record to ADDR. If COMMAND is given, its stdout and stderr lines
+//	are forwarded too, tagged with the command name, and logfwd exits with
+//	the command's status once it finishes and the backlog is flushed.
+//
+//	Messages that cannot be delivered are buffered and retried every
+//	interval, so logs from before the network came up still arrive.
+//
+//	Records carry the host name, or TEMPLATE with ${hostname} and
+//	${machine_id} expanded, e.g. "${hostname}-${machine_id}", so that the
+//	collector can tell apart machines that share a host name.
+//
+//	So that the log can be read after a failure that also kept it from
+//	being forwarded, e.g. of the network, it can be kept in efi-pstore
+//	records, which the next kernel shows in the pstore file system, on a
+//	dedicated partition, which pstore partition DEV prints, or in a file,
+//	e.g. on the EFI system partition. -n may be left out if it is kept.
+//	efi-pstore records are written as they fill, and when logfwd exits;
+//	the partition and the file on every message.
+//
+// Options:
+//
+//	-n:          remote collector address; a URL for -P http
+//	-P:          remote protocol: udp, tcp or http (default udp)
+//	-kmsg:       forward /dev/kmsg (default true)
+//	-b:          number of undelivered messages to buffer
+//	-i:          retry interval
+//	-host:       template for the host name of records
+//	-efi-pstore: keep the log in efi-pstore records
+//	-part:       keep the log on the log partition DEV, replacing the last one
+//	-file:       append the log to PATH
+//	-esp:        mount the EFI system partition DEV, and keep the -file PATH on it
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+	"github.com/u-root/u-root/pkg/machineid"
+	"github.com/u-root/u-root/pkg/syslog"
+	"github.com/u-root/u-root/pkg/ulog/persist"
+)
+
+var (
+	remote   = flag.String("n", "", "remote collector address")
+	proto    = flag.String("P", "udp", "remote protocol: udp, tcp or http")
+	kmsg     = flag.Bool("kmsg", true, "forward /dev/kmsg")
+	bufSize  = flag.Int("b", syslog.DefaultBufferSize, "number of undelivered messages to buffer")
+	interval = flag.Duration("i", 10*time.Second, "retry interval")
+	hostTmpl = flag.String("host", "", "template for the host name of records, with ${hostname} and ${machine_id}")
+
+	efiPstore = flag.Bool("efi-pstore", false, "keep the log in efi-pstore records")
+	part      = flag.String("part", "", "keep the log on the log partition `DEV`")
+	file      = flag.String("file", "", "append the log to `PATH`")
+	esp       = flag.String("esp", "", "mount the EFI system partition `DEV`, and keep the -file on it")
+)
+
+// keeper writes messages to the logs they are kept in across reboots.
+type keeper struct {
+	mu   sync.Mutex
+	logs []io.WriteCloser
+}
+
+// openLogs opens the logs given by the flags.
+func openLogs() (*keeper, error) {
+	k := &keeper{}
+	if *efiPstore {
+		v, err := efivarfs.New()
+		if err != nil {
+			return nil, fmt.Errorf("efi-pstore: %v", err)
+		}
+		k.logs = append(k.logs, persist.NewEFIPstore(v))
+	}
+	if *part != "" {
+		p, err := persist.OpenPartition(*part)
+		if err != nil {
+			k.Close()
+			return nil, err
+		}
+		k.logs = append(k.logs, p)
+	}
+	if *file != "" {
+		var f *persist.File
+		var err error
+		if *esp != "" {
+			f, err = persist.OpenESPFile(*esp, *file)
+		} else {
+			f, err = persist.OpenFile(*file)
+		}
+		if err != nil {
+			k.Close()
+			return nil, err
+		}
+		k.logs = append(k.logs, f)
+	}
+	return k, nil
+}
+
+// send writes m to the logs, dropping those that fail.
+func (k *keeper) send(m syslog.Message) {
+	line := []byte(m.RFC3164() + "\n")
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for i := 0; i < len(k.logs); i++ {
+		if _, err := k.logs[i].Write(line); err != nil {
+			log.Printf("logfwd: no longer keeping the log: %v", err)
+			k.logs[i].Close()
+			k.logs = append(k.logs[:i], k.logs[i+1:]...)
+			i--
+		}
+	}
+}
+
+// Close flushes and closes the logs.
+func (k *keeper) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var err error
+	for _, l := range k.logs {
+		if cerr := l.Close(); err == nil {
+			err = cerr
+		}
+	}
+	k.logs = nil
+	return err
+}
+
+// hostname expands the host name template tmpl.
+func hostname(tmpl, machineIDFile string) (string, error) {
+	if tmpl == "" {
+		return syslog.Hostname(), nil
+	}
+	if strings.Contains(tmpl, "${machine_id}") {
+		id, err := machineid.Read(machineIDFile)
+		if err != nil {
+			return "", err
+		}
+		tmpl = machineid.Expand(tmpl, id)
+	}
+	return strings.ReplaceAll(tmpl, "${hostname}", syslog.Hostname()), nil
+}
+
+// bootTime derives the wall clock time of boot from /proc/uptime.
+func bootTime() time.Time {
+	b, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return time.Now()
+	}
+	f := strings.Fields(string(b))
+	if len(f) == 0 {
+		return time.Now()
+	}
+	up, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Now().Add(-time.Duration(up * float64(time.Second)))
+}
+
+// forwardKmsg forwards records from r, which reads /dev/kmsg.
+//
+// Each read of /dev/kmsg returns exactly one record.
+func forwardKmsg(r io.Reader, send func(syslog.Message), host string, boot time.Time) error {
+	buf := make([]byte, 8192)
+	for {
+		n, err := r.Read(buf)
+		if errors.Is(err, syscall.EPIPE) {
+			// Records were overwritten before we read them.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		m, err := syslog.ParseKmsg(string(buf[:n]), boot)
+		if err != nil {
+			continue
+		}
+		m.Hostname = host
+		send(m)
+	}
+}
+
+// forwardLines forwards each line read from r with the given priority.
+func forwardLines(r io.Reader, send func(syslog.Message), host, tag string, pri int) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		send(syslog.Message{
+			Priority: pri,
+			Time:     time.Now(),
+			Hostname: host,
+			Tag:      tag,
+			Text:     s.Text(),
+		})
+	}
+	return s.Err()
+}
+
+func runCommand(args []string, send func(syslog.Message), host string) error {
+	c := exec.Command(args[0], args[1:]...)
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := c.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := c.Start(); err != nil {
+		return err
+	}
+	tag := filepath.Base(args[0])
+	var wg sync.WaitGroup
+	wg.Add(2)
+	// user.info and user.err.
+	go func() { defer wg.Done(); forwardLines(io.TeeReader(stdout, os.Stdout), send, host, tag, 14) }()
+	go func() { defer wg.Done(); forwardLines(io.TeeReader(stderr, os.Stderr), send, host, tag, 11) }()
+	wg.Wait()
+	return c.Wait()
+}
+
+func run(args []string) error {
+	if *esp != "" && *file == "" {
+		return fmt.Errorf("-esp given without -file")
+	}
+	k, err := openLogs()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := k.Close(); err != nil {
+			log.Printf("logfwd: %v", err)
+		}
+	}()
+	if *remote == "" && len(k.logs) == 0 {
+		return fmt.Errorf("no collector address given with -n, and no log to keep")
+	}
+	var f *syslog.Forwarder
+	if *remote != "" {
+		f = syslog.NewForwarder(*proto, *remote)
+		f.BufferSize = *bufSize
+		f.RetryInterval = *interval
+	}
+	// Send only queues; the forwarder delivers and retries in the
+	// background, so a dead collector never stalls the command.
+	send := func(m syslog.Message) {
+		k.send(m)
+		if f != nil {
+			f.Send(m)
+		}
+	}
+	host, err := hostname(*hostTmpl, machineid.Path)
+	if err != nil {
+		return err
+	}
+
+	if *kmsg {
+		k, err := os.Open("/dev/kmsg")
+		if err != nil {
+			return err
+		}
+		defer k.Close()
+		boot := bootTime()
+		go func() {
+			if err := forwardKmsg(k, send, host, boot); err != nil {
+				log.Printf("logfwd: reading /dev/kmsg: %v", err)
+			}
+		}()
+	}
+
+	if len(args) == 0 {
+		// Run until told to stop, e.g. by shutdown, to flush the logs.
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		return nil
+	}
+
+	cerr := runCommand(args, send, host)
+	if f == nil {
+		return cerr
+	}
+	// Give the collector a last chance before exiting.
+	deadline := time.Now().Add(*interval)
+	for f.Flush() != nil && time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+	}
+	if n, dropped := f.Pending(); n > 0 || dropped > 0 {
+		log.Printf("logfwd: %d messages undelivered, %d dropped", n, dropped)
+	}
+	return cerr
+}
+
+func main() {
+	flag.Parse()
+	if err := run(flag.Args()); err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			os.Exit(ee.ExitCode())
+		}
+		log.Fatalf("logfwd: %v", err)
+	}
+}
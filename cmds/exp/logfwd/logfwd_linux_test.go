@@ -0,0 +1,107 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/u-root/u-root/pkg/syslog"
+	"github.com/u-root/u-root/pkg/ulog/persist"
+)
+
+// records returns a reader that yields one kmsg record per Read.
+type records []string
+
+func (r *records) Read(b []byte) (int, error) {
+	if len(*r) == 0 {
+		return 0, iotest.ErrTimeout
+	}
+	n := copy(b, (*r)[0])
+	*r = (*r)[1:]
+	return n, nil
+}
+
+func TestForwardKmsg(t *testing.T) {
+	r := &records{"6,1,1000000,-;first", "junk", "3,2,2000000,-;second\n DEVICE=+pci"}
+	var got []syslog.Message
+	boot := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := forwardKmsg(r, func(m syslog.Message) { got = append(got, m) }, "h", boot); err != iotest.ErrTimeout {
+		t.Fatalf("forwardKmsg = %v, want %v", err, iotest.ErrTimeout)
+	}
+	if len(got) != 2 || got[1].Text != "second" || got[1].Priority != 3 || got[0].Hostname != "h" || !got[1].Time.Equal(boot.Add(2*time.Second)) {
+		t.Errorf("forwarded %+v", got)
+	}
+}
+
+func TestForwardLines(t *testing.T) {
+	var got []string
+	send := func(m syslog.Message) { got = append(got, m.Tag+":"+m.Text) }
+	if err := forwardLines(strings.NewReader("a\nb\n"), send, "h", "dd", 14); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "dd:a,dd:b" {
+		t.Errorf("forwarded %v", got)
+	}
+}
+
+func TestHostname(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "machine-id")
+	if err := os.WriteFile(file, []byte("4c4c4544003957108052b4c04f384833\n"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	host := syslog.Hostname()
+	for _, tt := range []struct {
+		tmpl string
+		file string
+		want string
+		err  bool
+	}{
+		{tmpl: "", want: host},
+		{tmpl: "${hostname}", want: host},
+		{tmpl: "node-${machine_id}", file: file, want: "node-4c4c4544003957108052b4c04f384833"},
+		{tmpl: "${machine_id}", file: filepath.Join(t.TempDir(), "missing"), err: true},
+	} {
+		got, err := hostname(tt.tmpl, tt.file)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("hostname(%q) = %q, %v, want %q", tt.tmpl, got, err, tt.want)
+		}
+	}
+}
+
+func TestKeeper(t *testing.T) {
+	dir := t.TempDir()
+	dev := filepath.Join(dir, "part")
+	if err := os.WriteFile(dev, make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { *part, *file = "", "" }()
+	*part, *file = dev, filepath.Join(dir, "log")
+
+	k, err := openLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.send(syslog.Message{Priority: 14, Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), Hostname: "h", Tag: "init", Text: "up"})
+	if err := k.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "<14>Jan  1 00:00:00 h init: up\n"
+	if b, err := os.ReadFile(*file); err != nil || string(b) != want {
+		t.Errorf("kept %q, %v in the file, want %q", b, err, want)
+	}
+	if _, b, err := persist.ReadPartition(dev); err != nil || string(b) != want {
+		t.Errorf("kept %q, %v on the partition, want %q", b, err, want)
+	}
+
+	*part = filepath.Join(dir, "nonexistent")
+	if _, err := openLogs(); err == nil {
+		t.Errorf("openLogs with a nonexistent partition succeeded")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// logger writes messages to the kernel log or a remote syslog collector.
+//
+// Synopsis:
+//
+//	logger [-p PRIORITY] [-t TAG] [-s] [-n ADDR [-P udp|tcp|http]] [MESSAGE...]
+//
+// Description:
+//
+//	Without MESSAGE, each line of standard input is logged. u-root runs no
+//	syslog daemon, so messages go to /dev/kmsg unless -n is given.
+//
+// Options:
+//
+//	-p: priority as facility.severity (default user.notice)
+//	-t: tag (default: the user name)
+//	-s: also write the message to stderr
+//	-n: remote collector address; a URL for -P http
+//	-P: remote protocol: udp, tcp or http (default udp)
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/syslog"
+)
+
+var (
+	priority = flag.String("p", "user.notice", "priority as facility.severity")
+	tag      = flag.String("t", os.Getenv("USER"), "tag")
+	stderr   = flag.Bool("s", false, "also write the message to stderr")
+	remote   = flag.String("n", "", "remote collector address")
+	proto    = flag.String("P", "udp", "remote protocol: udp, tcp or http")
+)
+
+// sender delivers a single message.
+type sender func(m syslog.Message) error
+
+func kmsgSender(w io.Writer) sender {
+	return func(m syslog.Message) error {
+		t := m.Tag
+		if t != "" {
+			t += ": "
+		}
+		_, err := fmt.Fprintf(w, "<%d>%s%s\n", m.Priority, t, m.Text)
+		return err
+	}
+}
+
+func run(stdin io.Reader, errw io.Writer, send sender, pri int, tag string, echo bool, args []string) error {
+	host := syslog.Hostname()
+	logOne := func(text string) error {
+		if echo {
+			fmt.Fprintf(errw, "%s: %s\n", tag, text)
+		}
+		return send(syslog.Message{
+			Priority: pri,
+			Time:     time.Now(),
+			Hostname: host,
+			Tag:      tag,
+			Text:     text,
+		})
+	}
+	if len(args) > 0 {
+		return logOne(strings.Join(args, " "))
+	}
+	s := bufio.NewScanner(stdin)
+	for s.Scan() {
+		if s.Text() == "" {
+			continue
+		}
+		if err := logOne(s.Text()); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+func main() {
+	flag.Parse()
+	pri, err := syslog.ParsePriority(*priority)
+	if err != nil {
+		log.Fatalf("logger: %v", err)
+	}
+
+	var send sender
+	if *remote != "" {
+		f := syslog.NewForwarder(*proto, *remote)
+		defer f.Close()
+		send = f.Send
+	} else {
+		k, err := os.OpenFile("/dev/kmsg", os.O_WRONLY, 0)
+		if err != nil {
+			log.Fatalf("logger: %v", err)
+		}
+		defer k.Close()
+		send = kmsgSender(k)
+	}
+
+	if err := run(os.Stdin, os.Stderr, send, pri, *tag, *stderr, flag.Args()); err != nil {
+		log.Fatalf("logger: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var kmsg, errw bytes.Buffer
+	in := strings.NewReader("first\n\nsecond\n")
+	if err := run(in, &errw, kmsgSender(&kmsg), 13, "prov", true, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := kmsg.String(), "<13>prov: first\n<13>prov: second\n"; got != want {
+		t.Errorf("kmsg = %q, want %q", got, want)
+	}
+	if got, want := errw.String(), "prov: first\nprov: second\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+
+	kmsg.Reset()
+	if err := run(nil, &errw, kmsgSender(&kmsg), 11, "", false, []string{"disk", "failed"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := kmsg.String(), "<11>disk failed\n"; got != want {
+		t.Errorf("kmsg = %q, want %q", got, want)
+	}
+}
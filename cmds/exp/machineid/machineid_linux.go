@@ -0,0 +1,93 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// machineid prints the machine ID, deriving it from the hardware if needed.
+//
+// Synopsis:
+//
+//	machineid [-f FILE] [-s SOURCES] [-d] [-w] [-v]
+//
+// Description:
+//
+//	machineid prints the machine ID in FILE. If there is none, an ID is
+//	derived from the first of SOURCES that has one:
+//
+//	smbios  the system UUID of the SMBIOS tables
+//	tpm     a hash of the public endorsement key of the TPM
+//	mac     a hash of the lowest permanent MAC address of the NICs
+//
+//	The same hardware always gives the same ID, so an initramfs without
+//	persistent storage keeps its identity across boots. init does the same
+//	with uroot.machineid on the kernel command line.
+//
+// Options:
+//
+//	-f: the machine ID file (default /etc/machine-id)
+//	-s: sources to derive the ID from, in order (default smbios,tpm,mac)
+//	-d: derive the ID even if FILE has one
+//	-w: write a derived ID to FILE
+//	-v: also print where the ID came from
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/u-root/u-root/pkg/machineid"
+)
+
+type params struct {
+	file    string
+	sources string
+	derive  bool
+	write   bool
+	verbose bool
+}
+
+func run(stdout io.Writer, p params) error {
+	sources, err := machineid.ParseSources(p.sources)
+	if err != nil {
+		return err
+	}
+	id, src := machineid.ID{}, machineid.SourceFile
+	if !p.derive {
+		id, err = machineid.Read(p.file)
+	}
+	if p.derive || err != nil {
+		if id, src, err = machineid.Derive(machineid.Opts{Sources: sources}); err != nil {
+			return err
+		}
+		if p.write {
+			if err := machineid.Write(p.file, id); err != nil {
+				return err
+			}
+		}
+	}
+	if p.verbose {
+		_, err = fmt.Fprintf(stdout, "%s %s\n", id, src)
+	} else {
+		_, err = fmt.Fprintln(stdout, id)
+	}
+	return err
+}
+
+func main() {
+	var p params
+	flag.StringVar(&p.file, "f", machineid.Path, "the machine ID file")
+	flag.StringVar(&p.sources, "s", "smbios,tpm,mac", "sources to derive the ID from, in order")
+	flag.BoolVar(&p.derive, "d", false, "derive the ID even if the file has one")
+	flag.BoolVar(&p.write, "w", false, "write a derived ID to the file")
+	flag.BoolVar(&p.verbose, "v", false, "also print where the ID came from")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(os.Stdout, p); err != nil {
+		log.Fatalf("machineid: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "machine-id")
+	if err := os.WriteFile(file, []byte("4C4C4544003957108052B4C04F384833\n"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		p       params
+		want    string
+		wantErr bool
+	}{
+		{name: "file", p: params{file: file, sources: "smbios"}, want: "4c4c4544003957108052b4c04f384833\n"},
+		{name: "verbose", p: params{file: file, sources: "smbios", verbose: true}, want: "4c4c4544003957108052b4c04f384833 file\n"},
+		{name: "bad source", p: params{file: file, sources: "dmi"}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := run(&out, tt.p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run = %v, want error %v", err, tt.wantErr)
+			}
+			if out.String() != tt.want {
+				t.Errorf("run = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
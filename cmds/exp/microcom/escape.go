@@ -0,0 +1,54 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+// action is what a key typed after the escape character does.
+type action int
+
+const (
+	actNone action = iota
+	actQuit
+	actBreak
+	actHelp
+)
+
+// ctrl returns the control character of the letter c, e.g. 1 for a.
+func ctrl(c byte) byte {
+	return c & 0x1f
+}
+
+// escaper finds commands in what the user types: the escape character, and
+// then a key.
+type escaper struct {
+	esc     byte
+	pending bool
+}
+
+// key handles the key c, and returns what to send to the port for it, if
+// anything, and what to do.
+func (e *escaper) key(c byte) ([]byte, action) {
+	if !e.pending {
+		if c == e.esc {
+			e.pending = true
+			return nil, actNone
+		}
+		return []byte{c}, actNone
+	}
+	e.pending = false
+	switch c {
+	case e.esc:
+		return []byte{c}, actNone
+	case 'x', ctrl('x'), 'q', ctrl('q'):
+		return nil, actQuit
+	case 'b', ctrl('b'), ctrl('\\'):
+		return nil, actBreak
+	case 'h', ctrl('h'), '?':
+		return nil, actHelp
+	}
+	return nil, actNone
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEscaper(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		keys  string
+		send  string
+		acts  []action
+		after bool
+	}{
+		{name: "plain", keys: "ls\r", send: "ls\r"},
+		{name: "quit", keys: "ab\x01\x18", send: "ab", acts: []action{actQuit}},
+		{name: "quit letter", keys: "\x01x", acts: []action{actQuit}},
+		{name: "literal escape", keys: "\x01\x01c", send: "\x01c"},
+		{name: "break and help", keys: "\x01b\x01?", acts: []action{actBreak, actHelp}},
+		{name: "unknown command", keys: "\x01zy", send: "y"},
+		{name: "pending", keys: "q\x01", send: "q", after: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &escaper{esc: ctrl('a')}
+			var (
+				send []byte
+				acts []action
+			)
+			for _, c := range []byte(tt.keys) {
+				b, act := e.key(c)
+				send = append(send, b...)
+				if act != actNone {
+					acts = append(acts, act)
+				}
+			}
+			if string(send) != tt.send {
+				t.Errorf("sent %q, want %q", send, tt.send)
+			}
+			if !reflect.DeepEqual(acts, tt.acts) {
+				t.Errorf("actions %v, want %v", acts, tt.acts)
+			}
+			if e.pending != tt.after {
+				t.Errorf("pending = %t, want %t", e.pending, tt.after)
+			}
+		})
+	}
+}
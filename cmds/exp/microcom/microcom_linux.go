@@ -0,0 +1,227 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// microcom connects the terminal to a serial port, e.g. to reach the console
+// of another machine.
+//
+// Synopsis:
+//
+//	microcom [-b BAUD] [-f n|x|h] [-l LOG] [-e C] DEVICE
+//
+// Description:
+//
+//	What is typed is sent to DEVICE, and what DEVICE sends is shown, and
+//	appended to LOG if it is set. Commands are typed as the escape
+//	character, Ctrl-A by default, and then:
+//	  Ctrl-X or x  exit
+//	  Ctrl-B or b  send a break, e.g. for SysRq
+//	  Ctrl-H or h  list the commands
+//	  Ctrl-A       send Ctrl-A
+//
+// Options:
+//
+//	-b: baud rate (default 115200)
+//	-f: flow control: n for none, x for XON/XOFF, h for RTS/CTS (default n)
+//	-l: file to append what DEVICE sends to
+//	-e: escape character, as the letter of its Ctrl key (default a)
+//
+// Example:
+//
+//	microcom -b 9600 -l /tmp/bmc.log /dev/ttyUSB0
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	baud    = flag.Int("b", 115200, "baud rate")
+	flow    = flag.String("f", "n", "flow control: n for none, x for XON/XOFF, h for RTS/CTS")
+	logFile = flag.String("l", "", "file to append what the device sends to")
+	escape  = flag.String("e", "a", "escape character, as the letter of its Ctrl key")
+)
+
+const help = "\r\n*** Ctrl-%[1]c then: Ctrl-X exit, Ctrl-B break, Ctrl-H help, Ctrl-%[1]c send Ctrl-%[1]c\r\n"
+
+// errQuit is returned by session.run when the user quits.
+var errQuit = errors.New("quit")
+
+// configure returns t set up for a serial port at baud, with flow control
+// flow: raw, 8N1, and ignoring modem control lines.
+func configure(t *termios.Termios, baud int, flow string) (*termios.Termios, error) {
+	s, err := termios.MakeSerialBaud(termios.MakeRaw(t), baud)
+	if err != nil {
+		return nil, err
+	}
+	s.Cflag |= unix.CLOCAL | unix.CREAD
+	s.Cflag &^= unix.CRTSCTS | unix.CSTOPB
+	s.Iflag &^= unix.IXON | unix.IXOFF | unix.IXANY
+	switch flow {
+	case "n":
+	case "x":
+		s.Iflag |= unix.IXON | unix.IXOFF
+	case "h":
+		s.Cflag |= unix.CRTSCTS
+	default:
+		return nil, fmt.Errorf("flow control %q is not one of n, x or h", flow)
+	}
+	return s, nil
+}
+
+// openPort opens and sets up the serial port dev. Restore undoes the set up.
+func openPort(dev string, baud int, flow string) (*os.File, *termios.Restorer, error) {
+	// Without O_NONBLOCK, opening waits for carrier detect.
+	fd, err := unix.Open(dev, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "open", Path: dev, Err: err}
+	}
+	f := os.NewFile(uintptr(fd), dev)
+	t, err := termios.GetTermios(f.Fd())
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", dev, err)
+	}
+	s, err := configure(t, baud, flow)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	r, err := termios.SetMode(f.Fd(), func(*termios.Termios) *termios.Termios { return s })
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, r, nil
+}
+
+// session connects a terminal to a port.
+type session struct {
+	port io.ReadWriter
+	in   io.Reader
+	out  io.Writer
+	esc  escaper
+	// brk sends a break.
+	brk func() error
+}
+
+// input sends what is typed to the port, and runs commands.
+func (s *session) input() error {
+	b := make([]byte, 256)
+	for {
+		n, err := s.in.Read(b)
+		var send []byte
+		for _, c := range b[:n] {
+			k, act := s.esc.key(c)
+			send = append(send, k...)
+			if act == actNone {
+				continue
+			}
+			if _, err := s.port.Write(send); err != nil {
+				return err
+			}
+			send = send[:0]
+			switch act {
+			case actQuit:
+				return errQuit
+			case actBreak:
+				if err := s.brk(); err != nil {
+					fmt.Fprintf(s.out, "\r\n*** break: %v\r\n", err)
+				}
+			case actHelp:
+				fmt.Fprintf(s.out, help, s.esc.esc+'A'-1)
+			}
+		}
+		if _, err := s.port.Write(send); err != nil {
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// run relays between the terminal and the port until the user quits, or
+// either fails.
+func (s *session) run() error {
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(s.out, s.port)
+		if err == nil {
+			err = io.EOF
+		}
+		errc <- err
+	}()
+	go func() {
+		errc <- s.input()
+	}()
+	return <-errc
+}
+
+func run(dev string) error {
+	if len(*escape) != 1 || *escape < "a" || *escape > "z" {
+		return fmt.Errorf("escape character %q is not a letter", *escape)
+	}
+	port, r, err := openPort(dev, *baud, *flow)
+	if err != nil {
+		return err
+	}
+	defer port.Close()
+	defer r.Restore()
+
+	out := io.Writer(os.Stdout)
+	if *logFile != "" {
+		l, err := os.OpenFile(*logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		out = io.MultiWriter(os.Stdout, l)
+	}
+
+	if termios.IsTerminal(os.Stdin.Fd()) {
+		tr, err := termios.SetMode(os.Stdin.Fd(), termios.MakeRaw)
+		if err != nil {
+			return err
+		}
+		defer tr.Restore()
+	}
+	esc := ctrl((*escape)[0])
+	fmt.Fprintf(os.Stdout, "*** %s at %d baud; Ctrl-%c Ctrl-H for help\r\n", dev, *baud, esc+'A'-1)
+	s := &session{
+		port: port,
+		in:   os.Stdin,
+		out:  out,
+		esc:  escaper{esc: esc},
+		brk: func() error {
+			return unix.IoctlSetInt(int(port.Fd()), unix.TCSBRK, 0)
+		},
+	}
+	if err := s.run(); err != errQuit {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "\r\n")
+	return nil
+}
+
+func main() {
+	log.SetPrefix("microcom: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: microcom [-b BAUD] [-f n|x|h] [-l LOG] [-e C] DEVICE\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
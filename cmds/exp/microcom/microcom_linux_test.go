@@ -0,0 +1,95 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/pty"
+	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
+)
+
+func TestConfigure(t *testing.T) {
+	orig := &termios.Termios{}
+	orig.Cflag = unix.CRTSCTS | unix.CSTOPB
+	orig.Iflag = unix.IXANY | unix.ICRNL
+
+	for _, tt := range []struct {
+		flow      string
+		cflag     uint32
+		iflag     uint32
+		noCflag   uint32
+		noIflag   uint32
+		wantError bool
+	}{
+		{flow: "n", cflag: unix.CLOCAL | unix.CREAD | unix.B9600, noCflag: unix.CRTSCTS | unix.CSTOPB, noIflag: unix.IXON | unix.IXOFF | unix.IXANY | unix.ICRNL},
+		{flow: "x", iflag: unix.IXON | unix.IXOFF, noCflag: unix.CRTSCTS},
+		{flow: "h", cflag: unix.CRTSCTS, noIflag: unix.IXON | unix.IXOFF},
+		{flow: "rts", wantError: true},
+	} {
+		s, err := configure(orig, 9600, tt.flow)
+		if (err != nil) != tt.wantError {
+			t.Errorf("configure(%q) = %v, want error %t", tt.flow, err, tt.wantError)
+		}
+		if err != nil {
+			continue
+		}
+		if s.Cflag&tt.cflag != tt.cflag || s.Cflag&tt.noCflag != 0 {
+			t.Errorf("configure(%q) cflag = %#x, want %#x set and %#x clear", tt.flow, s.Cflag, tt.cflag, tt.noCflag)
+		}
+		if s.Iflag&tt.iflag != tt.iflag || s.Iflag&tt.noIflag != 0 {
+			t.Errorf("configure(%q) iflag = %#x, want %#x set and %#x clear", tt.flow, s.Iflag, tt.iflag, tt.noIflag)
+		}
+	}
+	if _, err := configure(orig, 1234, "n"); err == nil {
+		t.Errorf("configure at 1234 baud = nil, want error")
+	}
+}
+
+func TestSession(t *testing.T) {
+	ptm, pts, err := pty.Open()
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skipf("No pseudo terminals here: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer ptm.Close()
+	defer pts.Close()
+	// The pseudo terminal stands in for a serial port.
+	r, err := termios.SetMode(pts.Fd(), termios.MakeRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Restore()
+
+	breaks := 0
+	var out strings.Builder
+	s := &session{
+		port: pts,
+		in:   strings.NewReader("uname\r\x01b\x01\x01\x01x ignored"),
+		out:  &out,
+		esc:  escaper{esc: ctrl('a')},
+		brk: func() error {
+			breaks++
+			return nil
+		},
+	}
+	if err := s.input(); err != errQuit {
+		t.Fatalf("input = %v, want %v", err, errQuit)
+	}
+	if breaks != 1 {
+		t.Errorf("sent %d breaks, want 1", breaks)
+	}
+	want := "uname\r\x01"
+	b := make([]byte, len(want))
+	if _, err := io.ReadFull(ptm, b); err != nil || string(b) != want {
+		t.Errorf("port got %q, %v, want %q", b, err, want)
+	}
+}
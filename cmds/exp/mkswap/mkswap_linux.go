@@ -0,0 +1,139 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// mkswap sets up a file or partition as swap space.
+//
+// Synopsis:
+//
+//	mkswap [-L LABEL] [-U UUID] [-p PAGESIZE] DEVICE [SIZE]
+//
+// Description:
+//
+//	mkswap writes a swap header to DEVICE, which may be a regular file or
+//	a block device, so that swapon can use it. SIZE is the size of the
+//	swap space in KiB; it defaults to the size of DEVICE.
+//
+// Options:
+//
+//	-L: the label of the swap space
+//	-U: the UUID of the swap space (default random)
+//	-p: the page size (default that of the system)
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/u-root/u-root/pkg/ubinary"
+)
+
+const (
+	signature = "SWAPSPACE2"
+	// The header follows the boot block at the start of the first page.
+	headerOffset = 1024
+	version      = 1
+	// minPages is the smallest swap space that is worth having.
+	minPages = 10
+)
+
+type options struct {
+	label    string
+	uuid     uuid.UUID
+	pageSize int
+}
+
+// header returns the first page of a swap space of pages pages.
+func header(o *options, pages int64) []byte {
+	b := make([]byte, o.pageSize)
+	h := b[headerOffset:]
+	ubinary.NativeEndian.PutUint32(h[0:], version)
+	ubinary.NativeEndian.PutUint32(h[4:], uint32(pages-1))
+	// No bad pages.
+	copy(h[12:28], o.uuid[:])
+	copy(h[28:44], o.label)
+	copy(b[o.pageSize-len(signature):], signature)
+	return b
+}
+
+func mkswap(stdout io.Writer, o *options, device string, kib int64) error {
+	if o.pageSize < 4096 || o.pageSize&(o.pageSize-1) != 0 {
+		return fmt.Errorf("invalid page size %d", o.pageSize)
+	}
+	if len(o.label) > 16 {
+		return fmt.Errorf("label %q is longer than 16 bytes", o.label)
+	}
+	f, err := os.OpenFile(device, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Seeking to the end works for block devices too.
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	switch {
+	case kib == 0:
+		kib = end / 1024
+	case kib*1024 > end:
+		return fmt.Errorf("%s is only %d KiB", device, end/1024)
+	}
+	pages := kib * 1024 / int64(o.pageSize)
+	if pages < minPages {
+		return fmt.Errorf("%s is too small for swap space, it needs at least %d KiB", device, minPages*o.pageSize/1024)
+	}
+	if pages-1 > 1<<32-1 {
+		pages = 1 << 32
+	}
+	if _, err := f.WriteAt(header(o, pages), 0); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Setting up swapspace version %d, size = %d KiB (%d bytes)\n", version, (pages-1)*int64(o.pageSize)/1024, (pages-1)*int64(o.pageSize))
+	if o.label != "" {
+		fmt.Fprintf(stdout, "LABEL=%s, UUID=%s\n", o.label, o.uuid)
+	} else {
+		fmt.Fprintf(stdout, "no label, UUID=%s\n", o.uuid)
+	}
+	return f.Close()
+}
+
+func main() {
+	o := &options{}
+	flag.StringVar(&o.label, "L", "", "the label of the swap space")
+	id := flag.String("U", "", "the UUID of the swap space (default random)")
+	flag.IntVar(&o.pageSize, "p", os.Getpagesize(), "the page size")
+	flag.Parse()
+
+	a := flag.Args()
+	if len(a) < 1 || len(a) > 2 {
+		log.Fatal(errors.New("usage: mkswap [-L LABEL] [-U UUID] [-p PAGESIZE] DEVICE [SIZE]"))
+	}
+	o.uuid = uuid.New()
+	if *id != "" {
+		var err error
+		if o.uuid, err = uuid.Parse(*id); err != nil {
+			log.Fatalf("mkswap: invalid UUID %q: %v", *id, err)
+		}
+	}
+	var kib int64
+	if len(a) == 2 {
+		var err error
+		if kib, err = strconv.ParseInt(a[1], 10, 64); err != nil || kib <= 0 {
+			log.Fatalf("mkswap: invalid size %q", a[1])
+		}
+	}
+	if err := mkswap(os.Stdout, o, a[0], kib); err != nil {
+		log.Fatalf("mkswap: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/u-root/u-root/pkg/ubinary"
+)
+
+func TestMkswap(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "swap")
+	if err := os.WriteFile(name, make([]byte, 64*4096), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	o := &options{label: "recovery", uuid: uuid.MustParse("01234567-89ab-cdef-0123-456789abcdef"), pageSize: 4096}
+	var out bytes.Buffer
+	if err := mkswap(&out, o, name, 32*4); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 64*4096 {
+		t.Errorf("mkswap changed the size to %d", len(b))
+	}
+	if got := string(b[4096-10 : 4096]); got != "SWAPSPACE2" {
+		t.Errorf("signature = %q, want SWAPSPACE2", got)
+	}
+	h := b[1024:]
+	if v := ubinary.NativeEndian.Uint32(h); v != 1 {
+		t.Errorf("version = %d, want 1", v)
+	}
+	if last := ubinary.NativeEndian.Uint32(h[4:]); last != 31 {
+		t.Errorf("last page = %d, want 31", last)
+	}
+	if !bytes.Equal(h[12:28], o.uuid[:]) {
+		t.Errorf("UUID = %x, want %x", h[12:28], o.uuid[:])
+	}
+	if !bytes.Equal(h[28:44], []byte("recovery\x00\x00\x00\x00\x00\x00\x00\x00")) {
+		t.Errorf("label = %q, want recovery", h[28:44])
+	}
+	want := "Setting up swapspace version 1, size = 124 KiB (126976 bytes)\nLABEL=recovery, UUID=01234567-89ab-cdef-0123-456789abcdef\n"
+	if out.String() != want {
+		t.Errorf("mkswap printed %q, want %q", out.String(), want)
+	}
+}
+
+func TestMkswapErrors(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "swap")
+	if err := os.WriteFile(name, make([]byte, 8*4096), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		o    options
+		kib  int64
+	}{
+		{name: "too small", o: options{pageSize: 4096}},
+		{name: "too big", o: options{pageSize: 4096}, kib: 1024},
+		{name: "page size", o: options{pageSize: 1000}},
+		{name: "label", o: options{pageSize: 4096, label: "a label that is too long"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := mkswap(io.Discard, &tt.o, name, tt.kib); err == nil {
+				t.Errorf("mkswap succeeded")
+			}
+		})
+	}
+}
@@ -8,6 +8,12 @@
 //
 //	modprobe [-n] modulename [parameters...]
 //	modprobe [-n] -a modulename...
+//	modprobe [-n] -i image.squashfs modulename [parameters...]
+//
+// Description:
+//
+//	With -i, modules and modules.dep are read from a squashfs or erofs
+//	module image, which is mounted read-only for the duration of the command.
 //
 // Author:
 //
@@ -16,6 +22,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"strings"
@@ -31,6 +38,7 @@ var (
 	verboseAll = flag.Bool("va", false, "Insert all module names on the command line.")
 	rootDir    = flag.String("d", "/", "Root directory for modules")
 	kernelVer  = flag.String("S", "", "Set kernel version instead of using uname")
+	image      = flag.String("i", "", "Load modules from a squashfs or erofs module image")
 )
 
 func init() {
@@ -41,19 +49,19 @@ func init() {
 	}
 }
 
-func main() {
-	flag.Parse()
-
-	if flag.NArg() == 0 {
-		log.Println("Usage: ERROR: one module and optional module options.")
-		flag.Usage()
-		os.Exit(1)
-	}
-
+func run() error {
 	opts := kmodule.ProbeOpts{
 		RootDir: *rootDir,
 		KVer:    *kernelVer,
 	}
+	if *image != "" {
+		img, err := kmodule.OpenModuleImage(*image, "")
+		if err != nil {
+			return err
+		}
+		defer img.Close()
+		opts = img.ProbeOpts(opts)
+	}
 	if *dryRun {
 		log.Println("Unique dependencies in load order, already loaded ones get skipped:")
 		opts.DryRunCB = func(modPath string) {
@@ -70,13 +78,28 @@ func main() {
 				log.Printf("modprobe: Could not load module %q: %v", modName, err)
 			}
 		}
-		os.Exit(0)
+		return nil
 	}
 
 	modName := flag.Args()[0]
 	modOptions := strings.Join(flag.Args()[1:], " ")
 
 	if err := kmodule.ProbeOptions(modName, modOptions, opts); err != nil {
-		log.Fatalf("modprobe: Could not load module %q: %v", modName, err)
+		return fmt.Errorf("Could not load module %q: %v", modName, err)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		log.Println("Usage: ERROR: one module and optional module options.")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if err := run(); err != nil {
+		log.Fatalf("modprobe: %v", err)
 	}
 }
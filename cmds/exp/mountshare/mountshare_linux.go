@@ -0,0 +1,131 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// mountshare mounts directories that a hypervisor shares with a virtual
+// machine over virtio-9p or virtio-fs, and verifies them.
+//
+// Synopsis:
+//
+//	mountshare -l
+//	mountshare [-r] [-m MSIZE] [-k KEYRING [-M MANIFEST]] TAG [DIR]
+//
+// Description:
+//
+//	mountshare -l lists the tags of the shares, e.g. as qemu's -virtfs
+//	mount_tag= sets them, and whether they are 9p or virtiofs.
+//
+//	Otherwise, mountshare mounts the share tagged TAG at DIR, by default
+//	/mnt/TAG, which is created if it does not exist. TAG * mounts every
+//	share at /mnt/TAG.
+//
+//	With -k, the share must have a manifest of file sums, e.g. a
+//	SHA256SUMS file, that is clear-signed or has a detached signature by a
+//	key in KEYRING. Every file listed in it is verified, and the share is
+//	unmounted again if one does not verify. Files are not verified again
+//	when they are read later.
+//
+//	init mounts shares by itself with uroot.share=TAG[:DIR],... on the
+//	kernel command line, see libinit.ShareOptsFromCmdline.
+//
+// Options:
+//
+//	-l: list the shares
+//	-r: mount read-only
+//	-m: the largest 9p message size (default 512KiB)
+//	-k: verify the share with the OpenPGP keys in KEYRING
+//	-M: the manifest to verify against, relative to the share (default SHA256SUMS)
+//
+// Example:
+//
+//	$ mountshare -l
+//	tmpdir 9p
+//	$ mountshare -k /etc/share.keyring tmpdir /tmp/shared
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/u-root/u-root/pkg/libinit"
+	"github.com/u-root/u-root/pkg/mount"
+)
+
+var (
+	list     = flag.Bool("l", false, "list the shares")
+	readOnly = flag.Bool("r", false, "mount read-only")
+	msize    = flag.Uint("m", mount.Default9PMsize, "the largest 9p message `size`")
+	keyRing  = flag.String("k", "", "verify the share with the OpenPGP keys in `KEYRING`")
+	manifest = flag.String("M", libinit.DefaultShareManifest, "the `MANIFEST` to verify against, relative to the share")
+
+	errUsage = errors.New("usage: mountshare -l | mountshare [-r] [-m MSIZE] [-k KEYRING [-M MANIFEST]] TAG [DIR]")
+
+	// mountShare is replaced in tests.
+	mountShare = libinit.MountShare
+)
+
+func run(out io.Writer, args []string) error {
+	if *list {
+		if len(args) != 0 {
+			return errUsage
+		}
+		ss, err := mount.Shares()
+		if err != nil {
+			return err
+		}
+		for _, s := range ss {
+			fmt.Fprintf(out, "%s %s\n", s.Tag, s.FSType)
+		}
+		return nil
+	}
+
+	if len(args) < 1 || len(args) > 2 || *msize > 1<<32-1 {
+		return errUsage
+	}
+	m := libinit.ShareMount{Tag: args[0]}
+	if len(args) == 2 {
+		if m.Tag == libinit.AllShares {
+			return errUsage
+		}
+		m.Dir = args[1]
+	}
+	opts := libinit.ShareOpts{
+		Shares:   []libinit.ShareMount{m},
+		Msize:    uint32(*msize),
+		ReadOnly: *readOnly,
+		KeyRing:  *keyRing,
+		Manifest: *manifest,
+	}
+	mounts, err := opts.Mounts()
+	if err != nil {
+		return err
+	}
+
+	var failed bool
+	for _, m := range mounts {
+		mp, err := mountShare(m, opts)
+		if err != nil {
+			log.Print(err)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(out, "mounted %s at %s\n", m.Tag, mp.Path)
+	}
+	if failed {
+		return errors.New("not all shares were mounted")
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("mountshare: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdout, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
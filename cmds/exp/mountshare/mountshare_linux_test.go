@@ -0,0 +1,75 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/libinit"
+	"github.com/u-root/u-root/pkg/mount"
+)
+
+func TestRun(t *testing.T) {
+	defer func(f func(libinit.ShareMount, libinit.ShareOpts) (*mount.MountPoint, error)) {
+		mountShare = f
+		*list, *readOnly, *keyRing = false, false, ""
+	}(mountShare)
+
+	var (
+		gotMount libinit.ShareMount
+		gotOpts  libinit.ShareOpts
+	)
+	mountShare = func(m libinit.ShareMount, opts libinit.ShareOpts) (*mount.MountPoint, error) {
+		gotMount, gotOpts = m, opts
+		if m.Tag == "bad" {
+			return nil, errors.New("share bad does not verify")
+		}
+		dir := m.Dir
+		if dir == "" {
+			dir = filepath.Join(libinit.DefaultShareDir, m.Tag)
+		}
+		return &mount.MountPoint{Path: dir, Device: m.Tag, FSType: "9p"}, nil
+	}
+
+	for _, tt := range []struct {
+		desc     string
+		list     bool
+		readOnly bool
+		keyRing  string
+		args     []string
+		want     string
+		wantErr  error
+	}{
+		{desc: "no tag", wantErr: errUsage},
+		{desc: "too many", args: []string{"a", "b", "c"}, wantErr: errUsage},
+		{desc: "list with tag", list: true, args: []string{"a"}, wantErr: errUsage},
+		{desc: "all with dir", args: []string{"*", "/mnt/x"}, wantErr: errUsage},
+		{desc: "default dir", args: []string{"tmpdir"}, want: "mounted tmpdir at /mnt/tmpdir\n"},
+		{desc: "dir", readOnly: true, keyRing: "/etc/share.keyring", args: []string{"kernel", "/boot"}, want: "mounted kernel at /boot\n"},
+		{desc: "does not verify", keyRing: "/etc/share.keyring", args: []string{"bad"}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			*list, *readOnly, *keyRing = tt.list, tt.readOnly, tt.keyRing
+			var out bytes.Buffer
+			err := run(&out, tt.args)
+			if tt.want == "" {
+				if err == nil || (tt.wantErr != nil && err != tt.wantErr) {
+					t.Errorf("run(%q) = %v, want %v", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || out.String() != tt.want {
+				t.Fatalf("run(%q) = %q, %v, want %q", tt.args, out.String(), err, tt.want)
+			}
+			if gotMount.Tag != tt.args[0] || gotOpts.ReadOnly != tt.readOnly || gotOpts.KeyRing != tt.keyRing ||
+				gotOpts.Msize != mount.Default9PMsize || gotOpts.Manifest != libinit.DefaultShareManifest {
+				t.Errorf("mounted %+v with %+v", gotMount, gotOpts)
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// mtr probes the path to a host continuously, combining traceroute and ping.
+//
+// Synopsis:
+//
+//	mtr [-6] [-n] [-r] [-j] [-c COUNT] [-i INTERVAL] [-m MAXHOPS] [-t TIMEOUT] HOST
+//
+// Description:
+//
+//	Each round, mtr sends an ICMP echo request to HOST with every TTL from
+//	1 up, and the routers on the way answer those that expire with them.
+//	The table of hops, with the loss and round trip times of each, is
+//	redrawn after every round, which shows where on the path packets are
+//	lost or delayed, even if it happens only now and then.
+//
+//	The table is redrawn in place on a terminal, and printed again after
+//	each round otherwise. With -r, only the table after the last round is
+//	printed. With -j, the table is printed as a JSON object, one per line.
+//
+// Options:
+//
+//	-6: use IPv6
+//	-n: do not resolve addresses to host names
+//	-r: report mode: print only the final table; needs -c
+//	-j: print the table as JSON
+//	-c: stop after COUNT rounds (0: until interrupted)
+//	-i: interval between rounds (default 1s)
+//	-m: maximum number of hops (default 30)
+//	-t: how long to wait for a reply (default 2s)
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+const (
+	icmpEchoReply    = 0
+	icmpUnreachable  = 3
+	icmpEchoRequest  = 8
+	icmpTimeExceeded = 11
+
+	icmp6Unreachable  = 1
+	icmp6TimeExceeded = 3
+	icmp6EchoRequest  = 128
+	icmp6EchoReply    = 129
+
+	payloadSize = 56
+)
+
+// reply is what a received ICMP message says about a probe.
+type reply int
+
+const (
+	// replyNone is not about one of our probes.
+	replyNone reply = iota
+	// replyHop is a router on the way, where the TTL ran out.
+	replyHop
+	// replyDest is the destination, or the last router before it.
+	replyDest
+)
+
+func cksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 != 0 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+// echo returns an ICMP echo request. The kernel computes ICMPv6 checksums.
+func echo(v6 bool, id, seq uint16) []byte {
+	b := make([]byte, 8+payloadSize)
+	b[0] = icmpEchoRequest
+	if v6 {
+		b[0] = icmp6EchoRequest
+	}
+	binary.BigEndian.PutUint16(b[4:], id)
+	binary.BigEndian.PutUint16(b[6:], seq)
+	if !v6 {
+		binary.BigEndian.PutUint16(b[2:], cksum(b))
+	}
+	return b
+}
+
+// parseReply parses ICMP message b, without an IP header, for a reply to an
+// echo request with identifier id, and returns the sequence number of the
+// request.
+//
+// Errors carry the IP header and the start of the request that caused them.
+func parseReply(b []byte, v6 bool, id uint16) (uint16, reply) {
+	if len(b) < 8 {
+		return 0, replyNone
+	}
+	kind := replyNone
+	inner := -1
+	switch t := b[0]; {
+	case !v6 && t == icmpEchoReply, v6 && t == icmp6EchoReply:
+		kind, inner = replyDest, 0
+	case !v6 && t == icmpTimeExceeded, v6 && t == icmp6TimeExceeded:
+		kind = replyHop
+	case !v6 && t == icmpUnreachable, v6 && t == icmp6Unreachable:
+		kind = replyDest
+	default:
+		return 0, replyNone
+	}
+	if inner < 0 {
+		inner = 8 + 40
+		if !v6 {
+			if len(b) < 8+20 {
+				return 0, replyNone
+			}
+			inner = 8 + int(b[8]&0x0f)*4
+		}
+		if len(b) < inner+8 || b[inner] != icmpEchoRequest && b[inner] != icmp6EchoRequest {
+			return 0, replyNone
+		}
+	}
+	if binary.BigEndian.Uint16(b[inner+4:]) != id {
+		return 0, replyNone
+	}
+	return binary.BigEndian.Uint16(b[inner+6:]), kind
+}
+
+// conn sends probes and receives the replies to them.
+type conn interface {
+	send(ttl int, seq uint16) error
+	// recv returns a reply and its source, or os.ErrDeadlineExceeded.
+	recv(deadline time.Time) (net.IP, uint16, reply, error)
+}
+
+// icmpConn is a conn over a raw ICMP socket.
+type icmpConn struct {
+	c   *net.IPConn
+	dst *net.IPAddr
+	id  uint16
+	v6  bool
+}
+
+func dial(dst *net.IPAddr, v6 bool) (*icmpConn, error) {
+	network, laddr := "ip4:icmp", net.IPv4zero
+	if v6 {
+		network, laddr = "ip6:ipv6-icmp", net.IPv6unspecified
+	}
+	c, err := net.ListenIP(network, &net.IPAddr{IP: laddr})
+	if err != nil {
+		return nil, err
+	}
+	return &icmpConn{c: c, dst: dst, id: uint16(os.Getpid()), v6: v6}, nil
+}
+
+func (c *icmpConn) send(ttl int, seq uint16) error {
+	rc, err := c.c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		if c.v6 {
+			serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, ttl)
+		} else {
+			serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TTL, ttl)
+		}
+	}); err != nil {
+		return err
+	}
+	if serr != nil {
+		return serr
+	}
+	_, err = c.c.WriteTo(echo(c.v6, c.id, seq), c.dst)
+	return err
+}
+
+func (c *icmpConn) recv(deadline time.Time) (net.IP, uint16, reply, error) {
+	if err := c.c.SetReadDeadline(deadline); err != nil {
+		return nil, 0, replyNone, err
+	}
+	b := make([]byte, 1500)
+	for {
+		n, from, err := c.c.ReadFrom(b)
+		if err != nil {
+			return nil, 0, replyNone, err
+		}
+		if seq, kind := parseReply(b[:n], c.v6, c.id); kind != replyNone {
+			return from.(*net.IPAddr).IP, seq, kind, nil
+		}
+	}
+}
+
+// addr is an address that answered for a hop.
+type addr struct {
+	IP   string `json:"ip"`
+	Name string `json:"name,omitempty"`
+}
+
+func (a addr) String() string {
+	if a.Name == "" {
+		return a.IP
+	}
+	return a.Name + " (" + a.IP + ")"
+}
+
+// hop is what is known about one TTL.
+type hop struct {
+	TTL int `json:"ttl"`
+	// Addrs has more than one address if the path changes, e.g. with
+	// equal-cost multipath routing.
+	Addrs []addr `json:"addrs"`
+	Sent  int    `json:"sent"`
+	Recv  int    `json:"recv"`
+	// Loss is in percent.
+	Loss float64 `json:"loss"`
+	// The times are in milliseconds.
+	Last  float64 `json:"last"`
+	Avg   float64 `json:"avg"`
+	Best  float64 `json:"best"`
+	Worst float64 `json:"worst"`
+	StDev float64 `json:"stdev"`
+
+	sumSq float64
+}
+
+func (h *hop) addr(ip string, resolve func(string) string) {
+	for _, a := range h.Addrs {
+		if a.IP == ip {
+			return
+		}
+	}
+	h.Addrs = append(h.Addrs, addr{IP: ip, Name: resolve(ip)})
+}
+
+func (h *hop) add(rtt time.Duration) {
+	ms := float64(rtt) / float64(time.Millisecond)
+	if h.Recv == 0 || ms < h.Best {
+		h.Best = ms
+	}
+	if ms > h.Worst {
+		h.Worst = ms
+	}
+	h.Last = ms
+	h.Avg = (h.Avg*float64(h.Recv) + ms) / float64(h.Recv+1)
+	h.sumSq += ms * ms
+	h.Recv++
+	if h.Recv > 1 {
+		n := float64(h.Recv)
+		h.StDev = math.Sqrt(math.Max(0, (h.sumSq-n*h.Avg*h.Avg)/(n-1)))
+	}
+}
+
+// snapshot is the state of the path after a round.
+type snapshot struct {
+	Host  string    `json:"host"`
+	Dest  string    `json:"dest"`
+	Round int       `json:"round"`
+	Time  time.Time `json:"time"`
+	Hops  []hop     `json:"hops"`
+}
+
+type probe struct {
+	ttl  int
+	sent time.Time
+}
+
+// tracer runs the probes and keeps the statistics.
+type tracer struct {
+	c        conn
+	host     string
+	dest     string
+	maxHops  int
+	interval time.Duration
+	timeout  time.Duration
+	resolve  func(string) string
+	now      func() time.Time
+
+	hops    []hop
+	pending map[uint16]probe
+	seq     uint16
+	// last is the TTL that reaches the destination, or 0 if none has yet.
+	last  int
+	round int
+}
+
+func newTracer(c conn, host, dest string, maxHops int) *tracer {
+	t := &tracer{
+		c:        c,
+		host:     host,
+		dest:     dest,
+		maxHops:  maxHops,
+		interval: time.Second,
+		timeout:  2 * time.Second,
+		resolve:  func(string) string { return "" },
+		now:      time.Now,
+		hops:     make([]hop, maxHops),
+		pending:  make(map[uint16]probe),
+	}
+	for i := range t.hops {
+		t.hops[i].TTL = i + 1
+	}
+	return t
+}
+
+// runRound sends a probe for each TTL and collects replies for an interval.
+func (t *tracer) runRound() error {
+	t.round++
+	start := t.now()
+	n := t.maxHops
+	if t.last > 0 {
+		n = t.last
+	}
+	for ttl := 1; ttl <= n; ttl++ {
+		t.seq++
+		if err := t.c.send(ttl, t.seq); err != nil {
+			return err
+		}
+		t.pending[t.seq] = probe{ttl: ttl, sent: t.now()}
+		t.hops[ttl-1].Sent++
+	}
+
+	deadline := start.Add(t.interval)
+	for {
+		from, seq, kind, err := t.c.recv(deadline)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		p, ok := t.pending[seq]
+		if !ok {
+			continue
+		}
+		delete(t.pending, seq)
+		h := &t.hops[p.ttl-1]
+		h.addr(from.String(), t.resolve)
+		h.add(t.now().Sub(p.sent))
+		if kind == replyDest && (t.last == 0 || p.ttl < t.last) {
+			t.last = p.ttl
+		}
+	}
+
+	for seq, p := range t.pending {
+		if t.now().Sub(p.sent) >= t.timeout {
+			delete(t.pending, seq)
+		}
+	}
+	for i := range t.hops {
+		if h := &t.hops[i]; h.Sent > 0 {
+			// Probes still in flight are not lost yet.
+			inFlight := 0
+			for _, p := range t.pending {
+				if p.ttl == h.TTL {
+					inFlight++
+				}
+			}
+			if done := h.Sent - inFlight; done > 0 {
+				h.Loss = 100 * float64(done-h.Recv) / float64(done)
+			}
+		}
+	}
+	return nil
+}
+
+// snapshot returns the hops up to the destination, or otherwise up to the
+// last one that answered.
+func (t *tracer) snapshot() snapshot {
+	n := t.last
+	if n == 0 {
+		for i, h := range t.hops {
+			if h.Recv > 0 {
+				n = i + 1
+			}
+		}
+	}
+	return snapshot{
+		Host:  t.host,
+		Dest:  t.dest,
+		Round: t.round,
+		Time:  t.now(),
+		Hops:  append([]hop(nil), t.hops[:n]...),
+	}
+}
+
+// table prints s like mtr does, and returns the number of lines printed.
+func (s snapshot) table(w io.Writer) (int, error) {
+	names := make([]string, len(s.Hops))
+	width := len("Host")
+	for i, h := range s.Hops {
+		names[i] = "???"
+		if len(h.Addrs) > 0 {
+			names[i] = h.Addrs[0].String()
+			if len(h.Addrs) > 1 {
+				names[i] += fmt.Sprintf(" +%d", len(h.Addrs)-1)
+			}
+		}
+		if len(names[i]) > width {
+			width = len(names[i])
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "HOST: %s (%s), round %d\n", s.Host, s.Dest, s.Round)
+	fmt.Fprintf(&b, "     %-*s  Loss%%   Snt   Last    Avg   Best   Wrst  StDev\n", width, "Host")
+	for i, h := range s.Hops {
+		fmt.Fprintf(&b, "%3d. %-*s %5.1f%% %5d %6.1f %6.1f %6.1f %6.1f %6.1f\n",
+			h.TTL, width, names[i], h.Loss, h.Sent, h.Last, h.Avg, h.Best, h.Worst, h.StDev)
+	}
+	_, err := io.WriteString(w, b.String())
+	return 2 + len(s.Hops), err
+}
+
+type params struct {
+	v6       bool
+	numeric  bool
+	report   bool
+	json     bool
+	count    int
+	interval time.Duration
+	maxHops  int
+	timeout  time.Duration
+}
+
+// display prints the snapshots as they come.
+type display struct {
+	w      io.Writer
+	json   bool
+	redraw bool
+	lines  int
+}
+
+func (d *display) show(s snapshot) error {
+	if d.json {
+		return json.NewEncoder(d.w).Encode(s)
+	}
+	if d.redraw && d.lines > 0 {
+		// Move up and clear to the end of the screen.
+		fmt.Fprintf(d.w, "\x1b[%dA\x1b[J", d.lines)
+	} else if d.lines > 0 {
+		fmt.Fprintln(d.w)
+	}
+	n, err := s.table(d.w)
+	d.lines = n
+	return err
+}
+
+func run(t *tracer, w io.Writer, p params, redraw bool) error {
+	d := &display{w: w, json: p.json, redraw: redraw}
+	for i := 0; p.count == 0 || i < p.count; i++ {
+		if err := t.runRound(); err != nil {
+			return err
+		}
+		if !p.report {
+			if err := d.show(t.snapshot()); err != nil {
+				return err
+			}
+		}
+	}
+	if p.report {
+		return d.show(t.snapshot())
+	}
+	return nil
+}
+
+func main() {
+	var p params
+	flag.BoolVar(&p.v6, "6", false, "use IPv6")
+	flag.BoolVar(&p.numeric, "n", false, "do not resolve addresses to host names")
+	flag.BoolVar(&p.report, "r", false, "report mode: print only the final table")
+	flag.BoolVar(&p.json, "j", false, "print the table as JSON")
+	flag.IntVar(&p.count, "c", 0, "stop after COUNT rounds (0: until interrupted)")
+	flag.DurationVar(&p.interval, "i", time.Second, "interval between rounds")
+	flag.IntVar(&p.maxHops, "m", 30, "maximum number of hops")
+	flag.DurationVar(&p.timeout, "t", 2*time.Second, "how long to wait for a reply")
+	flag.Parse()
+	if flag.NArg() != 1 || p.maxHops < 1 || p.maxHops > 255 || p.report && p.count == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	host := flag.Arg(0)
+
+	network := "ip4"
+	if p.v6 {
+		network = "ip6"
+	}
+	dst, err := net.ResolveIPAddr(network, host)
+	if err != nil {
+		log.Fatalf("mtr: %v", err)
+	}
+	c, err := dial(dst, p.v6)
+	if err != nil {
+		log.Fatalf("mtr: %v", err)
+	}
+
+	t := newTracer(c, host, dst.String(), p.maxHops)
+	t.interval, t.timeout = p.interval, p.timeout
+	if !p.numeric {
+		names := map[string]string{}
+		t.resolve = func(a string) string {
+			if n, ok := names[a]; ok {
+				return n
+			}
+			var n string
+			if ns, err := net.LookupAddr(a); err == nil && len(ns) > 0 {
+				n = strings.TrimSuffix(ns[0], ".")
+			}
+			names[a] = n
+			return n
+		}
+	}
+	if err := run(t, os.Stdout, p, term.IsTerminal(1) && !p.json); err != nil {
+		log.Fatalf("mtr: %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// icmpError returns an ICMP error of type typ about request req.
+func icmpError(typ byte, v6 bool, req []byte) []byte {
+	b := make([]byte, 8)
+	b[0] = typ
+	if v6 {
+		b = append(b, make([]byte, 40)...)
+	} else {
+		b = append(b, 0x45)
+		b = append(b, make([]byte, 19)...)
+	}
+	return append(b, req[:8]...)
+}
+
+func TestParseReply(t *testing.T) {
+	req := echo(false, 0x1234, 7)
+	if cksum(req) != 0 {
+		t.Errorf("echo request checksum is wrong")
+	}
+	req6 := echo(true, 0x1234, 7)
+	rep := append([]byte(nil), req...)
+	rep[0] = icmpEchoReply
+	reply6 := append([]byte(nil), req6...)
+	reply6[0] = icmp6EchoReply
+	other := echo(false, 0x4321, 7)
+	other[0] = icmpEchoReply
+
+	for _, tt := range []struct {
+		name string
+		b    []byte
+		v6   bool
+		seq  uint16
+		kind reply
+	}{
+		{name: "echo reply", b: rep, seq: 7, kind: replyDest},
+		{name: "time exceeded", b: icmpError(icmpTimeExceeded, false, req), seq: 7, kind: replyHop},
+		{name: "unreachable", b: icmpError(icmpUnreachable, false, req), seq: 7, kind: replyDest},
+		{name: "echo reply v6", b: reply6, v6: true, seq: 7, kind: replyDest},
+		{name: "time exceeded v6", b: icmpError(icmp6TimeExceeded, true, req6), v6: true, seq: 7, kind: replyHop},
+		{name: "someone else's ping", b: other, kind: replyNone},
+		{name: "someone else's error", b: icmpError(icmpTimeExceeded, false, other), kind: replyNone},
+		{name: "own request", b: req, kind: replyNone},
+		{name: "short", b: icmpError(icmpTimeExceeded, false, req)[:20], kind: replyNone},
+	} {
+		seq, kind := parseReply(tt.b, tt.v6, 0x1234)
+		if seq != tt.seq || kind != tt.kind {
+			t.Errorf("%s: parseReply = %d, %d, want %d, %d", tt.name, seq, kind, tt.seq, tt.kind)
+		}
+	}
+}
+
+type fakeReply struct {
+	from net.IP
+	seq  uint16
+	kind reply
+	at   time.Time
+}
+
+// path is a fake network of routers with a host at the end.
+type path struct {
+	now     time.Time
+	routers []string
+	// lose says whether the next probe with ttl is lost.
+	lose    func(ttl int) bool
+	replies []fakeReply
+}
+
+func (p *path) clock() time.Time { return p.now }
+
+func (p *path) send(ttl int, seq uint16) error {
+	if p.lose != nil && p.lose(ttl) {
+		return nil
+	}
+	kind := replyHop
+	if ttl >= len(p.routers) {
+		ttl, kind = len(p.routers), replyDest
+	}
+	// Each hop adds 2ms.
+	p.replies = append(p.replies, fakeReply{
+		from: net.ParseIP(p.routers[ttl-1]),
+		seq:  seq,
+		kind: kind,
+		at:   p.now.Add(time.Duration(ttl) * 2 * time.Millisecond),
+	})
+	return nil
+}
+
+func (p *path) recv(deadline time.Time) (net.IP, uint16, reply, error) {
+	if len(p.replies) == 0 {
+		p.now = deadline
+		return nil, 0, replyNone, os.ErrDeadlineExceeded
+	}
+	r := p.replies[0]
+	p.replies = p.replies[1:]
+	if r.at.After(p.now) {
+		p.now = r.at
+	}
+	return r.from, r.seq, r.kind, nil
+}
+
+func TestTracer(t *testing.T) {
+	n := 0
+	p := &path{
+		now:     time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+		routers: []string{"10.0.0.1", "192.0.2.1", "198.51.100.7"},
+		// Hop 2 loses the probes of rounds 1 and 3.
+		lose: func(ttl int) bool {
+			if ttl != 2 {
+				return false
+			}
+			n++
+			return n%2 == 1
+		},
+	}
+	tr := newTracer(p, "boot.example", "198.51.100.7", 30)
+	tr.now = p.clock
+
+	var out bytes.Buffer
+	if err := run(tr, &out, params{count: 4, report: true}, false); err != nil {
+		t.Fatal(err)
+	}
+	s := tr.snapshot()
+	if len(s.Hops) != 3 || tr.last != 3 {
+		t.Fatalf("got %d hops, destination at %d, want 3", len(s.Hops), tr.last)
+	}
+	for i, want := range []struct {
+		ip         string
+		sent, recv int
+		loss       float64
+	}{
+		{"10.0.0.1", 4, 4, 0},
+		{"192.0.2.1", 4, 2, 50},
+		{"198.51.100.7", 4, 4, 0},
+	} {
+		h := s.Hops[i]
+		if len(h.Addrs) != 1 || h.Addrs[0].IP != want.ip || h.Sent != want.sent || h.Recv != want.recv || h.Loss != want.loss {
+			t.Errorf("hop %d = %+v, want %s sent %d recv %d loss %v", i+1, h, want.ip, want.sent, want.recv, want.loss)
+		}
+	}
+	if h := s.Hops[2]; h.Best != 6 || h.Worst != 6 || h.Avg != 6 || h.StDev != 0 {
+		t.Errorf("hop 3 times = %+v, want 6ms", h)
+	}
+
+	want := "HOST: boot.example (198.51.100.7), round 4\n" +
+		"     Host          Loss%   Snt   Last    Avg   Best   Wrst  StDev\n" +
+		"  1. 10.0.0.1       0.0%     4    2.0    2.0    2.0    2.0    0.0\n" +
+		"  2. 192.0.2.1     50.0%     4    4.0    4.0    4.0    4.0    0.0\n" +
+		"  3. 198.51.100.7   0.0%     4    6.0    6.0    6.0    6.0    0.0\n"
+	if out.String() != want {
+		t.Errorf("report:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	p := &path{
+		now:     time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+		routers: []string{"10.0.0.1", "10.0.0.9"},
+	}
+	tr := newTracer(p, "10.0.0.9", "10.0.0.9", 5)
+	tr.now = p.clock
+	tr.resolve = func(a string) string {
+		if a == "10.0.0.1" {
+			return "gw.lan"
+		}
+		return ""
+	}
+
+	var out bytes.Buffer
+	if err := run(tr, &out, params{count: 2, json: true}, false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d JSON lines, want one per round:\n%s", len(lines), out.String())
+	}
+	var s snapshot
+	if err := json.Unmarshal([]byte(lines[1]), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Round != 2 || len(s.Hops) != 2 || s.Hops[0].Addrs[0] != (addr{IP: "10.0.0.1", Name: "gw.lan"}) || s.Hops[1].Recv != 2 {
+		t.Errorf("snapshot = %+v", s)
+	}
+}
+
+func TestHopStats(t *testing.T) {
+	h := hop{TTL: 1}
+	h.add(3 * time.Millisecond)
+	h.add(5 * time.Millisecond)
+	if h.Best != 3 || h.Worst != 5 || h.Avg != 4 || h.Last != 5 {
+		t.Errorf("hop = %+v", h)
+	}
+	if d := h.StDev - 1.4142135; d > 1e-6 || d < -1e-6 {
+		t.Errorf("StDev = %v, want 1.414", h.StDev)
+	}
+}
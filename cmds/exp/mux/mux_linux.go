@@ -0,0 +1,320 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// mux is a minimal terminal multiplexer: sessions outlive the terminal they
+// were started on, e.g. a serial console or ssh connection that drops, and
+// can be reattached from another one.
+//
+// Synopsis:
+//
+//	mux [-S NAME] [CMD [ARG]...]
+//	mux -r [-S NAME]
+//	mux -ls
+//
+// Description:
+//
+//	mux starts a session running CMD, $SHELL or /bin/sh by default, and
+//	attaches to it. -r attaches to a running session, detaching it
+//	from wherever it is attached. Without -S, it is the only session.
+//
+//	The session ends when the last window does. Keys after Ctrl-A:
+//	  d    detach
+//	  c    new window, running CMD
+//	  n p  next and previous window
+//	  0-9  window by number
+//	  w    list windows
+//	  a    a literal Ctrl-A
+//
+// Options:
+//
+//	-S:  session name, by default the process ID
+//	-r:  reattach
+//	-ls: list sessions
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	name     = flag.String("S", "", "session name")
+	reattach = flag.Bool("r", false, "reattach")
+	list     = flag.Bool("ls", false, "list sessions")
+	serverF  = flag.Bool("server", false, "run the session server (internal)")
+)
+
+const prefix = 0x01 // Ctrl-A
+
+// keys splits what is typed into input and commands.
+type keys struct {
+	escaped bool
+}
+
+type msg struct {
+	typ  byte
+	data []byte
+}
+
+// feed returns the messages for b, and whether to detach.
+func (k *keys) feed(b []byte) ([]msg, bool) {
+	var msgs []msg
+	var in []byte
+	flush := func() {
+		if len(in) > 0 {
+			msgs = append(msgs, msg{msgInput, in})
+			in = nil
+		}
+	}
+	for _, c := range b {
+		if !k.escaped {
+			if c == prefix {
+				k.escaped = true
+			} else {
+				in = append(in, c)
+			}
+			continue
+		}
+		k.escaped = false
+		switch {
+		case c == 'd':
+			flush()
+			return msgs, true
+		case c == 'a' || c == prefix:
+			in = append(in, prefix)
+		case strings.IndexByte("cnpw0123456789", c) >= 0:
+			flush()
+			msgs = append(msgs, msg{msgCommand, []byte{c}})
+		}
+	}
+	flush()
+	return msgs, false
+}
+
+func sockDir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("mux-%d", os.Getuid()))
+}
+
+// sessions returns the names of the running sessions, and removes the
+// sockets of those that are gone.
+func sessions(dir string) ([]string, error) {
+	ents, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range ents {
+		p := filepath.Join(dir, e.Name())
+		c, err := net.Dial("unix", p)
+		if err != nil {
+			os.Remove(p)
+			continue
+		}
+		c.Close()
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+// size returns the data of msgResize for the terminal fd, nil if it has
+// no size.
+func size(fd uintptr) []byte {
+	w, err := termios.GetWinSize(fd)
+	if err != nil || w.Row == 0 {
+		return nil
+	}
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b, w.Row)
+	binary.BigEndian.PutUint16(b[2:], w.Col)
+	return b
+}
+
+// attach relays the terminal to the session at sock until it ends or is
+// detached.
+func attach(sock string, in *os.File, out io.Writer) error {
+	c, err := net.Dial("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if termios.IsTerminal(in.Fd()) {
+		r, err := termios.SetMode(in.Fd(), termios.MakeRaw)
+		if err != nil {
+			return err
+		}
+		defer r.Restore()
+	}
+	if err := writeMsg(c, msgAttach, size(in.Fd())); err != nil {
+		return err
+	}
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, unix.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for range winch {
+			if b := size(in.Fd()); b != nil {
+				writeMsg(c, msgResize, b)
+			}
+		}
+	}()
+
+	detached := make(chan struct{})
+	go func() {
+		var k keys
+		b := make([]byte, 1024)
+		for {
+			n, err := in.Read(b)
+			if err != nil {
+				c.Close()
+				return
+			}
+			msgs, detach := k.feed(b[:n])
+			for _, m := range msgs {
+				if err := writeMsg(c, m.typ, m.data); err != nil {
+					return
+				}
+			}
+			if detach {
+				close(detached)
+				c.Close()
+				return
+			}
+		}
+	}()
+	io.Copy(out, c)
+	select {
+	case <-detached:
+		fmt.Fprintf(out, "\r\n[mux: detached from %s]\r\n", filepath.Base(sock))
+	default:
+	}
+	return nil
+}
+
+// startServer starts the server of a new session in the background, by
+// running this command again without a terminal.
+func startServer(sock string, argv []string) error {
+	cmd := exec.Command("/proc/self/exe", append([]string{"-server", "-S", filepath.Base(sock), "--"}, argv...)...)
+	cmd.Args[0] = os.Args[0]
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	cmd.Process.Release()
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(sock); err == nil {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("session %s did not start", filepath.Base(sock))
+}
+
+func serve(sock string, argv []string) error {
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sock)
+	s, err := newServer(filepath.Base(sock), l, argv)
+	if err != nil {
+		l.Close()
+		return err
+	}
+	s.serve()
+	<-s.done
+	return nil
+}
+
+func run(args []string) error {
+	dir := sockDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if *list {
+		names, err := sessions(dir)
+		if err != nil {
+			return err
+		}
+		for _, n := range names {
+			fmt.Println(n)
+		}
+		return nil
+	}
+
+	n := *name
+	if *reattach {
+		if len(args) != 0 {
+			return fmt.Errorf("-r takes no command")
+		}
+		if n == "" {
+			names, err := sessions(dir)
+			if err != nil {
+				return err
+			}
+			if len(names) != 1 {
+				return fmt.Errorf("%d sessions are running, name one with -S", len(names))
+			}
+			n = names[0]
+		}
+		return attach(filepath.Join(dir, n), os.Stdin, os.Stdout)
+	}
+
+	if len(args) == 0 {
+		sh := os.Getenv("SHELL")
+		if sh == "" {
+			sh = "/bin/sh"
+		}
+		args = []string{sh}
+	}
+	if *serverF {
+		return serve(filepath.Join(dir, n), args)
+	}
+	if n == "" {
+		n = strconv.Itoa(os.Getpid())
+	}
+	if strings.ContainsRune(n, '/') {
+		return fmt.Errorf("session name %q contains a /", n)
+	}
+	sock := filepath.Join(dir, n)
+	existing, err := sessions(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range existing {
+		if e == n {
+			return fmt.Errorf("session %s is running, attach with -r -S %s", n, n)
+		}
+	}
+	if err := startServer(sock, args); err != nil {
+		return err
+	}
+	return attach(sock, os.Stdin, os.Stdout)
+}
+
+func main() {
+	flag.Parse()
+	if err := run(flag.Args()); err != nil {
+		log.Fatalf("mux: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFeed(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		in     []string
+		want   []msg
+		detach bool
+	}{
+		{
+			name: "input",
+			in:   []string{"ls\r"},
+			want: []msg{{msgInput, []byte("ls\r")}},
+		},
+		{
+			name:   "detach",
+			in:     []string{"x\x01d"},
+			want:   []msg{{msgInput, []byte("x")}},
+			detach: true,
+		},
+		{
+			name: "command",
+			in:   []string{"a\x01cb"},
+			want: []msg{{msgInput, []byte("a")}, {msgCommand, []byte("c")}, {msgInput, []byte("b")}},
+		},
+		{
+			name: "prefix split across reads",
+			in:   []string{"\x01", "2"},
+			want: []msg{{msgCommand, []byte("2")}},
+		},
+		{
+			name: "literal prefix",
+			in:   []string{"\x01a\x01\x01"},
+			want: []msg{{msgInput, []byte("\x01\x01")}},
+		},
+		{
+			name: "unknown key",
+			in:   []string{"\x01zq"},
+			want: []msg{{msgInput, []byte("q")}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var k keys
+			var got []msg
+			var detach bool
+			for _, in := range tt.in {
+				m, d := k.feed([]byte(in))
+				got = append(got, m...)
+				detach = d
+			}
+			if !reflect.DeepEqual(got, tt.want) || detach != tt.detach {
+				t.Errorf("feed(%q) = %q, %v, want %q, %v", tt.in, got, detach, tt.want, tt.detach)
+			}
+		})
+	}
+}
+
+func TestMsg(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeMsg(&b, msgResize, []byte{0, 24, 0, 80}); err != nil {
+		t.Fatal(err)
+	}
+	writeMsg(&b, msgInput, nil)
+	for _, want := range []msg{{msgResize, []byte{0, 24, 0, 80}}, {msgInput, []byte{}}} {
+		typ, data, err := readMsg(&b)
+		if err != nil || typ != want.typ || !bytes.Equal(data, want.data) {
+			t.Errorf("readMsg = %d, %v, %v, want %d, %v", typ, data, err, want.typ, want.data)
+		}
+	}
+	if _, _, err := readMsg(&b); err == nil {
+		t.Errorf("readMsg of nothing succeeded")
+	}
+}
+
+// waitFor reads r until it has read want.
+func waitFor(t *testing.T, c net.Conn, r *bufio.Reader, want string) {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var got []byte
+	for !bytes.Contains(got, []byte(want)) {
+		b, err := r.ReadByte()
+		if err != nil {
+			t.Fatalf("waiting for %q, got %q: %v", want, got, err)
+		}
+		got = append(got, b)
+	}
+}
+
+func dial(t *testing.T, sock string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	c, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeMsg(c, msgAttach, []byte{0, 24, 0, 80}); err != nil {
+		t.Fatal(err)
+	}
+	return c, bufio.NewReader(c)
+}
+
+func TestServer(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newServer("test", l, []string{"cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.serve()
+
+	// Listing does not detach.
+	names, err := sessions(filepath.Dir(sock))
+	if err != nil || !reflect.DeepEqual(names, []string{"test"}) {
+		t.Errorf("sessions = %q, %v, want [test]", names, err)
+	}
+
+	c1, r1 := dial(t, sock)
+	defer c1.Close()
+	writeMsg(c1, msgInput, []byte("abc\r"))
+	waitFor(t, c1, r1, "abc")
+
+	// A new window, and back to the first with its scrollback.
+	writeMsg(c1, msgCommand, []byte("c"))
+	waitFor(t, c1, r1, "mux test:1")
+	writeMsg(c1, msgCommand, []byte("w"))
+	waitFor(t, c1, r1, "0 cat  1* cat")
+	writeMsg(c1, msgInput, []byte("\x04"))
+	waitFor(t, c1, r1, "mux test:0")
+	waitFor(t, c1, r1, "abc")
+
+	c2, r2 := dial(t, sock)
+	defer c2.Close()
+	waitFor(t, c1, r1, "attached elsewhere")
+	waitFor(t, c2, r2, "abc")
+
+	writeMsg(c2, msgInput, []byte("\x04"))
+	waitFor(t, c2, r2, "session test ended")
+	select {
+	case <-s.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("session did not end")
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/u-root/u-root/pkg/pty"
+	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
+)
+
+// Messages from the client to the server. Each is a type byte, a 2 byte
+// big endian length and the data. The server sends the terminal output of
+// the current window as is.
+const (
+	msgAttach  byte = iota // first message of a client, data as for msgResize
+	msgInput               // data is typed into the current window
+	msgResize              // data is the rows and columns, 2 bytes each
+	msgCommand             // data is one command key, see the package doc
+)
+
+const (
+	maxWindows = 10
+	scrollback = 16 << 10
+)
+
+func writeMsg(w io.Writer, typ byte, data []byte) error {
+	b := append([]byte{typ, 0, 0}, data...)
+	binary.BigEndian.PutUint16(b[1:], uint16(len(data)))
+	_, err := w.Write(b)
+	return err
+}
+
+func readMsg(r io.Reader) (byte, []byte, error) {
+	var hdr [3]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint16(hdr[1:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], data, nil
+}
+
+type window struct {
+	num        int
+	ptm        *os.File
+	cmd        *exec.Cmd
+	scrollback []byte
+}
+
+// server runs the windows of a session and relays the current one to the
+// attached client. There is at most one, attaching detaches the last.
+type server struct {
+	name string
+	argv []string
+	l    net.Listener
+
+	mu      sync.Mutex
+	windows []*window // by num
+	cur     *window
+	client  net.Conn
+	ws      unix.Winsize
+	done    chan struct{}
+}
+
+func newServer(name string, l net.Listener, argv []string) (*server, error) {
+	s := &server{name: name, argv: argv, l: l, ws: unix.Winsize{Row: 24, Col: 80}, done: make(chan struct{})}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.newWindow(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// serve accepts clients until the last window is gone.
+func (s *server) serve() {
+	for {
+		c, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveClient(c)
+	}
+}
+
+// serveClient attaches c if it starts with msgAttach, and serves it until
+// it detaches or another client attaches.
+func (s *server) serveClient(c net.Conn) {
+	typ, data, err := readMsg(c)
+	if err != nil || typ != msgAttach {
+		c.Close()
+		return
+	}
+	s.mu.Lock()
+	if s.client != nil {
+		io.WriteString(s.client, "\r\n[mux: attached elsewhere]\r\n")
+		s.client.Close()
+	}
+	s.client = c
+	s.resize(data)
+	s.show(s.cur)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if s.client == c {
+			s.client = nil
+		}
+		s.mu.Unlock()
+		c.Close()
+	}()
+	for {
+		typ, data, err := readMsg(c)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		w := s.cur
+		switch typ {
+		case msgResize:
+			s.resize(data)
+		case msgCommand:
+			if len(data) == 1 {
+				s.command(data[0])
+			}
+		}
+		s.mu.Unlock()
+		// Not under the lock: the window may not read its input before
+		// its output is relayed.
+		if typ == msgInput && w != nil {
+			w.ptm.Write(data)
+		}
+	}
+}
+
+// resize sets the size of all windows. s.mu is held.
+func (s *server) resize(data []byte) {
+	if len(data) != 4 {
+		return
+	}
+	s.ws = unix.Winsize{Row: binary.BigEndian.Uint16(data), Col: binary.BigEndian.Uint16(data[2:])}
+	for _, w := range s.windows {
+		termios.SetWinSize(w.ptm.Fd(), &termios.Winsize{Winsize: s.ws})
+	}
+}
+
+// command runs a command key. s.mu is held.
+func (s *server) command(key byte) {
+	i := s.index(s.cur)
+	switch {
+	case key == 'c':
+		if err := s.newWindow(); err != nil {
+			s.status("%v", err)
+		}
+	case key == 'n':
+		s.show(s.windows[(i+1)%len(s.windows)])
+	case key == 'p':
+		s.show(s.windows[(i+len(s.windows)-1)%len(s.windows)])
+	case key >= '0' && key <= '9':
+		for _, w := range s.windows {
+			if w.num == int(key-'0') {
+				s.show(w)
+				return
+			}
+		}
+		s.status("no window %c", key)
+	case key == 'w':
+		var list []string
+		for _, w := range s.windows {
+			mark := ""
+			if w == s.cur {
+				mark = "*"
+			}
+			list = append(list, fmt.Sprintf("%d%s %s", w.num, mark, w.cmd.Args[0]))
+		}
+		s.status("%s", strings.Join(list, "  "))
+	}
+}
+
+func (s *server) index(w *window) int {
+	for i, x := range s.windows {
+		if x == w {
+			return i
+		}
+	}
+	return 0
+}
+
+// status tells the client something. s.mu is held.
+func (s *server) status(format string, v ...interface{}) {
+	if s.client != nil {
+		fmt.Fprintf(s.client, "\r\n[mux: "+format+"]\r\n", v...)
+	}
+}
+
+// show makes w the current window and redraws it from its scrollback.
+// s.mu is held.
+func (s *server) show(w *window) {
+	s.cur = w
+	if s.client == nil || w == nil {
+		return
+	}
+	fmt.Fprintf(s.client, "\x1b]0;mux %s:%d\x07\x1b[H\x1b[2J", s.name, w.num)
+	s.client.Write(w.scrollback)
+}
+
+// newWindow starts argv in a new window and shows it. s.mu is held.
+func (s *server) newWindow() error {
+	if len(s.windows) == maxWindows {
+		return fmt.Errorf("no more than %d windows", maxWindows)
+	}
+	num := 0
+	for _, w := range s.windows {
+		if w.num == num {
+			num++
+		}
+	}
+	ptm, pts, err := pty.Open()
+	if err != nil {
+		return err
+	}
+	defer pts.Close()
+	termios.SetWinSize(pts.Fd(), &termios.Winsize{Winsize: s.ws})
+
+	cmd := exec.Command(s.argv[0], s.argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = pts, pts, pts
+	cmd.Env = append(os.Environ(), fmt.Sprintf("MUX=%s:%d", s.name, num))
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		ptm.Close()
+		return err
+	}
+	w := &window{num: num, ptm: ptm, cmd: cmd}
+	s.windows = append(s.windows, w)
+	sort.Slice(s.windows, func(i, j int) bool { return s.windows[i].num < s.windows[j].num })
+	s.show(w)
+	go s.relay(w)
+	return nil
+}
+
+// relay keeps the scrollback of w and sends its output to the client while
+// it is current, until its command exits.
+func (s *server) relay(w *window) {
+	b := make([]byte, 4096)
+	for {
+		n, err := w.ptm.Read(b)
+		if err != nil {
+			break
+		}
+		s.mu.Lock()
+		w.scrollback = append(w.scrollback, b[:n]...)
+		if len(w.scrollback) > scrollback {
+			w.scrollback = w.scrollback[len(w.scrollback)-scrollback:]
+		}
+		if w == s.cur && s.client != nil {
+			s.client.Write(b[:n])
+		}
+		s.mu.Unlock()
+	}
+	w.cmd.Wait()
+	w.ptm.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(w)
+	s.windows = append(s.windows[:i], s.windows[i+1:]...)
+	if len(s.windows) == 0 {
+		s.cur = nil
+		s.status("session %s ended", s.name)
+		if s.client != nil {
+			s.client.Close()
+		}
+		s.l.Close()
+		close(s.done)
+		return
+	}
+	if w == s.cur {
+		s.show(s.windows[i%len(s.windows)])
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+// namei follows a path to its end.
+//
+// Synopsis:
+//
+//	namei [-m] [-n] PATH...
+//
+// Description:
+//
+//	namei prints each component of each PATH on a line of its own, with
+//	its type: d for a directory, D for a directory that is a mount point,
+//	l for a symbolic link, - for a regular file, and b, c, p and s for
+//	devices, pipes and sockets. The components of the targets of symbolic
+//	links are printed below them, indented.
+//
+// Options:
+//
+//	-m: print the permissions too
+//	-n: do not follow symbolic links
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// maxLinks is how many symbolic links a path may go through.
+const maxLinks = 40
+
+type namei struct {
+	w        io.Writer
+	modes    bool
+	noFollow bool
+	links    int
+}
+
+func device(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev)
+	}
+	return 0
+}
+
+func typ(m os.FileMode) byte {
+	switch {
+	case m.IsDir():
+		return 'd'
+	case m&os.ModeSymlink != 0:
+		return 'l'
+	case m&os.ModeCharDevice != 0:
+		return 'c'
+	case m&os.ModeDevice != 0:
+		return 'b'
+	case m&os.ModeNamedPipe != 0:
+		return 'p'
+	case m&os.ModeSocket != 0:
+		return 's'
+	}
+	return '-'
+}
+
+func (n *namei) print(level int, fi os.FileInfo, mount bool, name string) {
+	t := typ(fi.Mode())
+	if mount {
+		t = 'D'
+	}
+	kind := string(t)
+	if n.modes {
+		kind += fi.Mode().Perm().String()[1:]
+	}
+	fmt.Fprintf(n.w, "%s %s %s\n", strings.Repeat("  ", level), kind, name)
+}
+
+// walk prints the components of p, relative to dir, and returns the
+// path p resolves to.
+func (n *namei) walk(dir, p string, level int) (string, error) {
+	indent := strings.Repeat("  ", level)
+	if filepath.IsAbs(p) {
+		dir = "/"
+		fi, err := os.Lstat(dir)
+		if err != nil {
+			return "", err
+		}
+		n.print(level, fi, true, "/")
+	}
+	for _, c := range strings.Split(p, "/") {
+		if c == "" {
+			continue
+		}
+		next := filepath.Join(dir, c)
+		fi, err := os.Lstat(next)
+		if err != nil {
+			msg := err
+			var pe *os.PathError
+			if errors.As(err, &pe) {
+				msg = pe.Err
+			}
+			fmt.Fprintf(n.w, "%s   %s - %v\n", indent, c, msg)
+			return "", err
+		}
+		parent, err := os.Stat(dir)
+		if err != nil {
+			return "", err
+		}
+		mount := fi.IsDir() && device(fi) != device(parent)
+		if fi.Mode()&os.ModeSymlink == 0 {
+			n.print(level, fi, mount, c)
+			dir = next
+			continue
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		n.print(level, fi, false, c+" -> "+target)
+		if n.noFollow {
+			dir = next
+			continue
+		}
+		if n.links++; n.links > maxLinks {
+			return "", fmt.Errorf("%s: too many levels of symbolic links", next)
+		}
+		if dir, err = n.walk(dir, target, level+1); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+func (n *namei) run(paths []string) error {
+	if len(paths) == 0 {
+		return errors.New("usage: namei [-m] [-n] PATH...")
+	}
+	var failed error
+	for _, p := range paths {
+		fmt.Fprintf(n.w, "f: %s\n", p)
+		n.links = 0
+		if _, err := n.walk(".", p, 0); err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+func main() {
+	n := &namei{w: os.Stdout}
+	flag.BoolVar(&n.modes, "m", false, "print the permissions too")
+	flag.BoolVar(&n.noFollow, "n", false, "do not follow symbolic links")
+	flag.Parse()
+	if err := n.run(flag.Args()); err != nil {
+		log.Fatalf("namei: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNamei(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "d"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "d", "f"), nil, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{"l": "d", "ll": "./l", "loop": "loop"} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name    string
+		n       namei
+		paths   []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "links",
+			paths: []string{"ll/f"},
+			want:  "f: ll/f\n l ll -> ./l\n   d .\n   l l -> d\n     d d\n - f\n",
+		},
+		{
+			name:  "no follow",
+			n:     namei{noFollow: true},
+			paths: []string{"l"},
+			want:  "f: l\n l l -> d\n",
+		},
+		{
+			name:  "modes",
+			n:     namei{modes: true},
+			paths: []string{"d/f"},
+			want:  "f: d/f\n drwxr-x--- d\n -rw-r----- f\n",
+		},
+		{
+			name:    "missing",
+			paths:   []string{"d/x", "d"},
+			want:    "f: d/x\n d d\n   x - no such file or directory\nf: d\n d d\n",
+			wantErr: true,
+		},
+		{
+			name:    "loop",
+			paths:   []string{"loop"},
+			wantErr: true,
+		},
+		{
+			name:    "none",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			tt.n.w = &b
+			err := tt.n.run(tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("namei = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.want != "" && b.String() != tt.want {
+				t.Errorf("namei = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNameiRoot(t *testing.T) {
+	var b bytes.Buffer
+	n := &namei{w: &b}
+	if err := n.run([]string{"/"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "f: /\n D /\n"; b.String() != want {
+		t.Errorf("namei / = %q, want %q", b.String(), want)
+	}
+}
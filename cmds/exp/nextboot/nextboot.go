@@ -0,0 +1,278 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// nextboot selects the boot entry of the next boot, once, and blesses it, for
+// update flows to trial-boot a new kernel and fall back to the old one if it
+// does not come up.
+//
+// Synopsis:
+//
+//	nextboot [-vars DIR | -grubenv FILE] [show]
+//	nextboot [-vars DIR | -grubenv FILE] [-f] once ENTRY
+//	nextboot [-vars DIR | -grubenv FILE] [-f] bless [ENTRY]
+//	nextboot [-vars DIR | -grubenv FILE] clear
+//
+// Description:
+//
+//	By default, the entries are those of systemd-boot, or of another boot
+//	loader that implements the Boot Loader Interface, in EFI variables.
+//	With -grubenv, they are those of GRUB, in its environment block.
+//
+//	show prints the entry of the next boot, the default entry and, with
+//	systemd-boot, the entry booted now.
+//
+//	once boots ENTRY the next time, and only then: the entry after is the
+//	default entry again. It sets LoaderEntryOneShot, or next_entry in
+//	FILE, which grub.cfg made by grub-mkconfig honors. If ENTRY does not
+//	come up, the next reset boots the default entry.
+//
+//	bless makes ENTRY the default entry, by default the entry booted now
+//	with systemd-boot, once it came up. It sets LoaderEntryDefault, or
+//	saved_entry in FILE, which GRUB boots with default=saved.
+//
+//	clear removes the entry of the next boot.
+//
+//	With systemd-boot, ENTRY must be one of the entries it found at boot,
+//	in LoaderEntries, unless -f is given.
+//
+// Options:
+//
+//	-vars:    efivarfs mount point (default /sys/firmware/efi/efivars/)
+//	-grubenv: GRUB environment block, e.g. /boot/grub/grubenv
+//	-f:       do not check that ENTRY is a boot entry
+//
+// Example:
+//
+//	nextboot once linux-6.1.conf && reboot
+//	nextboot bless
+//	nextboot -grubenv /boot/grub/grubenv once "Debian GNU/Linux, with Linux 6.1"
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/u-root/u-root/pkg/boot/grub"
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+var (
+	vars    = flag.String("vars", efivarfs.DefaultVarFS, "efivarfs mount point")
+	grubenv = flag.String("grubenv", "", "GRUB environment block to use rather than EFI variables")
+	force   = flag.Bool("f", false, "do not check that ENTRY is a boot entry")
+
+	errUsage = errors.New("usage: nextboot [show] | once ENTRY | bless [ENTRY] | clear")
+)
+
+// store is where a boot loader keeps its variables.
+type store interface {
+	// get returns the value of a variable, or "" if it is not set.
+	get(name string) (string, error)
+	// set sets a variable, or removes it if value is "".
+	set(name, value string) error
+}
+
+// loader is a boot loader, and the names of its variables. Empty names are
+// variables the boot loader does not have.
+type loader struct {
+	store
+	oneShot  string
+	def      string
+	selected string
+	entries  func() ([]string, error)
+}
+
+// efiVars are the variables of the Boot Loader Interface.
+type efiVars struct {
+	e efivarfs.EFIVar
+}
+
+func (v efiVars) get(name string) (string, error) {
+	s, err := efivarfs.ReadLoaderString(v.e, name)
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return "", nil
+	}
+	return s, err
+}
+
+func (v efiVars) set(name, value string) error {
+	return efivarfs.WriteLoaderString(v.e, name, value)
+}
+
+// systemdBoot returns the loader of the Boot Loader Interface of systemd-boot.
+func systemdBoot(e efivarfs.EFIVar) *loader {
+	return &loader{
+		store:    efiVars{e: e},
+		oneShot:  "LoaderEntryOneShot",
+		def:      "LoaderEntryDefault",
+		selected: "LoaderEntrySelected",
+		entries: func() ([]string, error) {
+			entries, err := efivarfs.ReadLoaderStrings(e, "LoaderEntries")
+			if errors.Is(err, efivarfs.ErrVarNotExist) {
+				return nil, nil
+			}
+			return entries, err
+		},
+	}
+}
+
+// grubEnv is a GRUB environment block.
+type grubEnv string
+
+func (path grubEnv) read() (*grub.EnvFile, error) {
+	f, err := os.Open(string(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return grub.ParseSavedEnvFile(f)
+}
+
+func (path grubEnv) get(name string) (string, error) {
+	env, err := path.read()
+	if err != nil {
+		return "", err
+	}
+	return env.Vars[name], nil
+}
+
+// set rewrites the environment block in place, as GRUB does, rather than
+// replacing it: GRUB writes to the sectors the block was in.
+func (path grubEnv) set(name, value string) error {
+	env, err := path.read()
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		delete(env.Vars, name)
+	} else {
+		env.Vars[name] = value
+	}
+	f, err := os.OpenFile(string(path), os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	n, err := env.WriteTo(f)
+	if err == nil {
+		err = f.Truncate(n)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// grubLoader returns the loader of GRUB with the environment block path.
+func grubLoader(path string) *loader {
+	return &loader{
+		store:   grubEnv(path),
+		oneShot: "next_entry",
+		def:     "saved_entry",
+	}
+}
+
+// check returns an error if entry is not a boot entry of l, if l knows them.
+func (l *loader) check(entry string) error {
+	if l.entries == nil {
+		return nil
+	}
+	entries, err := l.entries()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	for _, e := range entries {
+		if e == entry {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a boot entry, one of %q", entry, entries)
+}
+
+func run(out io.Writer, l *loader, force bool, args []string) error {
+	cmd := "show"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+	switch {
+	case cmd == "show" && len(args) == 0:
+		for _, v := range []struct{ what, name string }{
+			{"Next boot", l.oneShot},
+			{"Default", l.def},
+			{"Booted", l.selected},
+		} {
+			if v.name == "" {
+				continue
+			}
+			entry, err := l.get(v.name)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", v.name, err)
+			}
+			if entry == "" {
+				entry = "(none)"
+			}
+			fmt.Fprintf(out, "%s: %s\n", v.what, entry)
+		}
+		return nil
+
+	case cmd == "once" && len(args) == 1 && args[0] != "":
+		if !force {
+			if err := l.check(args[0]); err != nil {
+				return err
+			}
+		}
+		return l.set(l.oneShot, args[0])
+
+	case cmd == "bless" && len(args) <= 1:
+		var entry string
+		if len(args) == 1 {
+			entry = args[0]
+		} else if l.selected != "" {
+			var err error
+			if entry, err = l.get(l.selected); err != nil {
+				return fmt.Errorf("reading %s: %w", l.selected, err)
+			}
+		}
+		if entry == "" {
+			return errors.New("no entry to bless: the entry booted now is not known, give ENTRY")
+		}
+		if !force {
+			if err := l.check(entry); err != nil {
+				return err
+			}
+		}
+		return l.set(l.def, entry)
+
+	case cmd == "clear" && len(args) == 0:
+		return l.set(l.oneShot, "")
+	}
+	return errUsage
+}
+
+func main() {
+	log.SetPrefix("nextboot: ")
+	log.SetFlags(0)
+	flag.Parse()
+	var l *loader
+	if *grubenv != "" {
+		l = grubLoader(*grubenv)
+	} else {
+		e, err := efivarfs.NewPath(*vars)
+		if err != nil {
+			log.Fatal(err)
+		}
+		l = systemdBoot(e)
+	}
+	if err := run(os.Stdout, l, *force, flag.Args()); err != nil {
+		if err == errUsage {
+			fmt.Fprintln(os.Stderr, err)
+			flag.PrintDefaults()
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
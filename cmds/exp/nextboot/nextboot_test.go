@@ -0,0 +1,145 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+type fakeVars map[efivarfs.VariableDescriptor][]byte
+
+func (f fakeVars) Get(desc efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	b, ok := f[desc]
+	if !ok {
+		return 0, nil, efivarfs.ErrVarNotExist
+	}
+	return efivarfs.LoaderAttributes, b, nil
+}
+
+func (f fakeVars) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	f[desc] = data
+	return nil
+}
+
+func (f fakeVars) Remove(desc efivarfs.VariableDescriptor) error {
+	if _, ok := f[desc]; !ok {
+		return efivarfs.ErrVarNotExist
+	}
+	delete(f, desc)
+	return nil
+}
+
+func (f fakeVars) List() ([]efivarfs.VariableDescriptor, error) {
+	return nil, nil
+}
+
+// utf16 encodes NUL terminated ASCII strings as the Boot Loader Interface
+// does.
+func utf16(s ...string) []byte {
+	var b []byte
+	for _, c := range []byte(strings.Join(s, "\x00") + "\x00") {
+		b = append(b, c, 0)
+	}
+	return b
+}
+
+func TestSystemdBoot(t *testing.T) {
+	f := fakeVars{
+		efivarfs.Loader("LoaderEntries"):       utf16("linux-6.0.conf", "linux-6.1.conf"),
+		efivarfs.Loader("LoaderEntryDefault"):  utf16("linux-6.0.conf"),
+		efivarfs.Loader("LoaderEntrySelected"): utf16("linux-6.0.conf"),
+	}
+	l := systemdBoot(f)
+	for _, tt := range []struct {
+		args    []string
+		force   bool
+		want    string
+		wantErr bool
+	}{
+		{want: "Next boot: (none)\nDefault: linux-6.0.conf\nBooted: linux-6.0.conf\n"},
+		{args: []string{"once", "linux-6.2.conf"}, wantErr: true},
+		{args: []string{"once", "linux-6.1.conf"}},
+		{args: []string{"show"}, want: "Next boot: linux-6.1.conf\nDefault: linux-6.0.conf\nBooted: linux-6.0.conf\n"},
+		{args: []string{"clear"}},
+		{args: []string{"clear"}},
+		{args: []string{"show"}, want: "Next boot: (none)\nDefault: linux-6.0.conf\nBooted: linux-6.0.conf\n"},
+		{args: []string{"once", "linux-6.2.conf"}, force: true},
+		{args: []string{"show"}, want: "Next boot: linux-6.2.conf\nDefault: linux-6.0.conf\nBooted: linux-6.0.conf\n"},
+		{args: []string{"bless", "linux-6.1.conf"}},
+		{args: []string{"show"}, want: "Next boot: linux-6.2.conf\nDefault: linux-6.1.conf\nBooted: linux-6.0.conf\n"},
+		{args: []string{"bless"}},
+		{args: []string{"show"}, want: "Next boot: linux-6.2.conf\nDefault: linux-6.0.conf\nBooted: linux-6.0.conf\n"},
+		{args: []string{"once"}, wantErr: true},
+		{args: []string{"reboot"}, wantErr: true},
+	} {
+		var out strings.Builder
+		err := run(&out, l, tt.force, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("nextboot %q = %v, want error %t", tt.args, err, tt.wantErr)
+		}
+		if out.String() != tt.want {
+			t.Errorf("nextboot %q printed %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+	if got, want := f[efivarfs.Loader("LoaderEntryOneShot")], utf16("linux-6.2.conf"); string(got) != string(want) {
+		t.Errorf("LoaderEntryOneShot = % x, want % x", got, want)
+	}
+
+	// Without LoaderEntrySelected, there is nothing to bless.
+	delete(f, efivarfs.Loader("LoaderEntrySelected"))
+	if err := run(&strings.Builder{}, l, false, []string{"bless"}); err == nil {
+		t.Errorf("nextboot bless without a booted entry = nil, want error")
+	}
+}
+
+func TestGRUB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grubenv")
+	// As grub-mkconfig's save_env leaves it after a one-shot boot.
+	env := "# GRUB Environment Block\nnext_entry=\nsaved_entry=gnulinux-6.0\n"
+	env += strings.Repeat("#", 1024-len(env))
+	if err := os.WriteFile(path, []byte(env), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l := grubLoader(path)
+	for _, tt := range []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{want: "Next boot: (none)\nDefault: gnulinux-6.0\n"},
+		{args: []string{"once", "gnulinux-6.1"}},
+		{args: []string{"show"}, want: "Next boot: gnulinux-6.1\nDefault: gnulinux-6.0\n"},
+		// There is no entry booted now.
+		{args: []string{"bless"}, wantErr: true},
+		{args: []string{"bless", "gnulinux-6.1"}},
+		{args: []string{"clear"}},
+		{args: []string{"show"}, want: "Next boot: (none)\nDefault: gnulinux-6.1\n"},
+	} {
+		var out strings.Builder
+		err := run(&out, l, false, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("nextboot %q = %v, want error %t", tt.args, err, tt.wantErr)
+		}
+		if out.String() != tt.want {
+			t.Errorf("nextboot %q printed %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 1024 || !strings.HasPrefix(string(b), "# GRUB Environment Block\nsaved_entry=gnulinux-6.1\n#") {
+		t.Errorf("grubenv is %d bytes, %q, want 1024 bytes with saved_entry=gnulinux-6.1", len(b), b)
+	}
+
+	if err := run(&strings.Builder{}, grubLoader(filepath.Join(t.TempDir(), "grubenv")), false, nil); err == nil {
+		t.Errorf("nextboot with no grubenv = nil, want error")
+	}
+}
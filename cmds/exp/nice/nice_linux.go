@@ -0,0 +1,90 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// nice runs a command with a different scheduling priority.
+//
+// Synopsis:
+//
+//	nice [-n ADJUSTMENT] [COMMAND [ARG]...]
+//
+// Description:
+//
+//	nice adds ADJUSTMENT to the niceness of COMMAND, which is between -20,
+//	the most favorable scheduling, and 19, the least. Only root may make
+//	it lower. Without COMMAND, nice prints its niceness.
+//
+//	nice exits with 125 if it fails, 126 if COMMAND cannot be run and
+//	127 if it is not found.
+//
+// Options:
+//
+//	-n: add ADJUSTMENT to the niceness (default 10)
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var adjustment = flag.Int("n", 10, "add `ADJUSTMENT` to the niceness")
+
+// niceness returns the niceness of the calling thread.
+func niceness() (int, error) {
+	// The system call returns 20 - niceness, to keep clear of -1.
+	p, err := unix.Getpriority(unix.PRIO_PROCESS, 0)
+	return 20 - p, err
+}
+
+// setNiceness adds adj to the niceness of the calling thread.
+func setNiceness(adj int) error {
+	n, err := niceness()
+	if err != nil {
+		return err
+	}
+	n += adj
+	if n < -20 {
+		n = -20
+	}
+	if n > 19 {
+		n = 19
+	}
+	return unix.Setpriority(unix.PRIO_PROCESS, 0, n)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		n, err := niceness()
+		if err != nil {
+			log.Printf("nice: %v", err)
+			os.Exit(125)
+		}
+		fmt.Println(n)
+		return
+	}
+
+	// The niceness is set for a thread, which must be the one to exec.
+	runtime.LockOSThread()
+	if err := setNiceness(*adjustment); err != nil {
+		log.Printf("nice: cannot set niceness: %v", err)
+	}
+	args := flag.Args()
+	path, err := exec.LookPath(args[0])
+	if err == nil {
+		err = syscall.Exec(path, args, os.Environ())
+	}
+	log.Printf("nice: %v", err)
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		os.Exit(127)
+	}
+	os.Exit(126)
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func TestNice(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	out, err := testutil.Command(t).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatalf("nice printed %q, want a number", out)
+	}
+	if n > 16 {
+		t.Skipf("niceness %d is too high to test", n)
+	}
+
+	// The 19th field of stat is the niceness.
+	out, err = testutil.Command(t, "-n", "3", "sh", "-c", "cut -d' ' -f19 /proc/self/stat").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(out)), fmt.Sprint(n+3); got != want {
+		t.Errorf("niceness = %s, want %s", got, want)
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// nohup runs a command that keeps running after the terminal hangs up.
+//
+// Synopsis:
+//
+//	nohup COMMAND [ARG]...
+//
+// Description:
+//
+//	nohup runs COMMAND with the hangup signal ignored. If stdin is a
+//	terminal, it is read from /dev/null instead. If stdout is a terminal,
+//	it is appended to nohup.out in the current directory, or in $HOME if
+//	that fails. If stderr is a terminal, it goes where stdout does.
+//
+//	nohup exits with 125 if it fails, 126 if COMMAND cannot be run and
+//	127 if it is not found.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// openOutput opens nohup.out for appending in the first directory of dirs
+// where that works.
+func openOutput(dirs ...string) (*os.File, error) {
+	var err error
+	for _, d := range dirs {
+		var f *os.File
+		if f, err = os.OpenFile(filepath.Join(d, "nohup.out"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600); err == nil {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot open nohup.out: %v", err)
+}
+
+// redirect moves the standard files of nohup away from the terminal.
+func redirect() error {
+	if term.IsTerminal(0) {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			return err
+		}
+		if err := unix.Dup3(int(f.Fd()), 0, 0); err != nil {
+			return err
+		}
+		f.Close()
+	}
+	if term.IsTerminal(1) {
+		dirs := []string{"."}
+		if home := os.Getenv("HOME"); home != "" {
+			dirs = append(dirs, home)
+		}
+		f, err := openOutput(dirs...)
+		if err != nil {
+			return err
+		}
+		log.Printf("nohup: ignoring input and appending output to %q", f.Name())
+		if err := unix.Dup3(int(f.Fd()), 1, 0); err != nil {
+			return err
+		}
+		f.Close()
+	}
+	if term.IsTerminal(2) {
+		return unix.Dup3(1, 2, 0)
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Print("usage: nohup COMMAND [ARG]...")
+		os.Exit(125)
+	}
+	// Ignored signals stay ignored across exec.
+	signal.Ignore(syscall.SIGHUP)
+	if err := redirect(); err != nil {
+		log.Printf("nohup: %v", err)
+		os.Exit(125)
+	}
+
+	path, err := exec.LookPath(os.Args[1])
+	if err == nil {
+		err = syscall.Exec(path, os.Args[1:], os.Environ())
+	}
+	log.Printf("nohup: %v", err)
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		os.Exit(127)
+	}
+	os.Exit(126)
+}
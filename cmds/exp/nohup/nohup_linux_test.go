@@ -0,0 +1,47 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func TestOpenOutput(t *testing.T) {
+	dir := t.TempDir()
+	f, err := openOutput(filepath.Join(dir, "missing"), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if want := filepath.Join(dir, "nohup.out"); f.Name() != want {
+		t.Errorf("openOutput = %q, want %q", f.Name(), want)
+	}
+	if _, err := openOutput(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("openOutput of a missing directory succeeded")
+	}
+}
+
+func TestNohup(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	out, err := testutil.Command(t, "sh", "-c", "kill -HUP $$; echo alive").Output()
+	if err != nil || string(out) != "alive\n" {
+		t.Errorf("nohup sh = %q, %v, want it to survive SIGHUP", out, err)
+	}
+
+	c := testutil.Command(t, "/no/such/command")
+	if err := c.Run(); err == nil || c.ProcessState.ExitCode() != 127 {
+		t.Errorf("nohup of a missing command = %v, want exit 127", err)
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
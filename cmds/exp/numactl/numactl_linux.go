@@ -0,0 +1,426 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// numactl runs a command with a NUMA policy for its memory and CPUs.
+//
+// Synopsis:
+//
+//	numactl [-i NODES | -p NODE | -m NODES | -l] [-N NODES | -C CPUS] COMMAND [ARG]...
+//	numactl -H
+//	numactl -s
+//
+// Description:
+//
+//	numactl runs COMMAND with its memory allocated from, and its threads
+//	run on, the given NUMA nodes or CPUs. NODES and CPUS are lists like
+//	0-3,7, and NODES may be all.
+//
+// Options:
+//
+//	-H, --hardware:    show the NUMA nodes, their CPUs, memory and distances
+//	-s, --show:        show the NUMA policy numactl runs with
+//	-i, --interleave:  interleave memory on NODES
+//	-p, --preferred:   allocate memory on NODE if possible
+//	-m, --membind:     allocate memory only on NODES
+//	-l, --localalloc:  allocate memory on the node the thread runs on
+//	-N, --cpunodebind: run on the CPUs of NODES
+//	-C, --physcpubind: run on CPUS
+//
+// Example:
+//
+//	numactl -N 1 -m 1 ./benchmark
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	flag "github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/cpuset"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	hardware    = flag.BoolP("hardware", "H", false, "show the NUMA nodes, their CPUs, memory and distances")
+	show        = flag.BoolP("show", "s", false, "show the NUMA policy numactl runs with")
+	interleave  = flag.StringP("interleave", "i", "", "interleave memory on NODES")
+	preferred   = flag.StringP("preferred", "p", "", "allocate memory on NODE if possible")
+	membind     = flag.StringP("membind", "m", "", "allocate memory only on NODES")
+	localalloc  = flag.BoolP("localalloc", "l", false, "allocate memory on the node the thread runs on")
+	cpunodebind = flag.StringP("cpunodebind", "N", "", "run on the CPUs of NODES")
+	physcpubind = flag.StringP("physcpubind", "C", "", "run on CPUS")
+)
+
+var errUsage = errors.New("usage: numactl [-i NODES | -p NODE | -m NODES | -l] [-N NODES | -C CPUS] COMMAND [ARG]... | -H | -s")
+
+// nodeDir describes the NUMA nodes. Tests change it.
+var nodeDir = "/sys/devices/system/node"
+
+// Memory policies of set_mempolicy.
+const (
+	mpolDefault = iota
+	mpolPreferred
+	mpolBind
+	mpolInterleave
+	mpolLocal
+)
+
+var policies = []string{"default", "preferred", "bind", "interleave", "local"}
+
+// maxNodes is the number of nodes of the masks of the memory policies.
+const maxNodes = 1024
+
+// nodeMask is a mask of nodes, as set_mempolicy takes it.
+type nodeMask [maxNodes / 64]uint64
+
+func readList(name ...string) ([]int, error) {
+	b, err := os.ReadFile(filepath.Join(append([]string{nodeDir}, name...)...))
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(string(b)) == "" {
+		return nil, nil
+	}
+	return cpuset.ParseList(string(b))
+}
+
+// nodes returns the online nodes.
+func nodes() ([]int, error) {
+	return readList("online")
+}
+
+func nodeName(node int) string {
+	return "node" + strconv.Itoa(node)
+}
+
+// nodeCPUs returns the CPUs of nodes.
+func nodeCPUs(nodes []int) ([]int, error) {
+	var cpus []int
+	for _, n := range nodes {
+		c, err := readList(nodeName(n), "cpulist")
+		if err != nil {
+			return nil, err
+		}
+		cpus = append(cpus, c...)
+	}
+	return cpus, nil
+}
+
+// nodeMemory returns the total and free memory of node, in KiB.
+func nodeMemory(node int) (total, free uint64, err error) {
+	b, err := os.ReadFile(filepath.Join(nodeDir, nodeName(node), "meminfo"))
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, l := range strings.Split(string(b), "\n") {
+		// Node 0 MemTotal:        6148948 kB
+		f := strings.Fields(l)
+		if len(f) < 4 {
+			continue
+		}
+		switch f[2] {
+		case "MemTotal:":
+			total, err = strconv.ParseUint(f[3], 10, 64)
+		case "MemFree:":
+			free, err = strconv.ParseUint(f[3], 10, 64)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return total, free, nil
+}
+
+// parseNodes parses a list of nodes, or all.
+func parseNodes(s string) ([]int, error) {
+	if s == "all" {
+		return nodes()
+	}
+	ns, err := cpuset.ParseList(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(ns) > 0 && ns[len(ns)-1] >= maxNodes {
+		return nil, fmt.Errorf("node %d is more than numactl supports", ns[len(ns)-1])
+	}
+	return ns, nil
+}
+
+func printList(out io.Writer, name string, ids []int) {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
+	}
+	fmt.Fprintf(out, "%s: %s\n", name, strings.Join(s, " "))
+}
+
+// showHardware prints the nodes, their CPUs and memory, and the distances
+// between them.
+func showHardware(out io.Writer) error {
+	ns, err := nodes()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "available: %d nodes (%s)\n", len(ns), cpuset.FormatList(ns))
+	for _, n := range ns {
+		cpus, err := nodeCPUs([]int{n})
+		if err != nil {
+			return err
+		}
+		printList(out, fmt.Sprintf("node %d cpus", n), cpus)
+		total, free, err := nodeMemory(n)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "node %d size: %d MB\n", n, total/1024)
+		fmt.Fprintf(out, "node %d free: %d MB\n", n, free/1024)
+	}
+	fmt.Fprintln(out, "node distances:")
+	fmt.Fprintf(out, "node ")
+	for _, n := range ns {
+		fmt.Fprintf(out, "%4d", n)
+	}
+	fmt.Fprintln(out)
+	for _, n := range ns {
+		b, err := os.ReadFile(filepath.Join(nodeDir, nodeName(n), "distance"))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%3d: ", n)
+		for _, d := range strings.Fields(string(b)) {
+			fmt.Fprintf(out, "%4s", d)
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+func setMempolicy(mode int, nodes []int) error {
+	var mask nodeMask
+	for _, n := range nodes {
+		mask[n/64] |= 1 << (n % 64)
+	}
+	// The kernel reads one bit less than maxnode.
+	if _, _, errno := unix.Syscall(unix.SYS_SET_MEMPOLICY, uintptr(mode), uintptr(unsafe.Pointer(&mask)), maxNodes+1); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func getMempolicy() (int, []int, error) {
+	var (
+		mode int32
+		mask nodeMask
+	)
+	if _, _, errno := unix.Syscall6(unix.SYS_GET_MEMPOLICY, uintptr(unsafe.Pointer(&mode)), uintptr(unsafe.Pointer(&mask)), maxNodes+1, 0, 0, 0); errno != 0 {
+		return 0, nil, errno
+	}
+	var nodes []int
+	for n := 0; n < maxNodes; n++ {
+		if mask[n/64]&(1<<(n%64)) != 0 {
+			nodes = append(nodes, n)
+		}
+	}
+	return int(mode), nodes, nil
+}
+
+// showPolicy prints the memory policy and CPUs of numactl.
+func showPolicy(out io.Writer) error {
+	mode, mnodes, err := getMempolicy()
+	if err != nil {
+		return fmt.Errorf("get_mempolicy: %v", err)
+	}
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		return err
+	}
+	var cpus []int
+	for i := 0; i < len(set)*64; i++ {
+		if set.IsSet(i) {
+			cpus = append(cpus, i)
+		}
+	}
+	ns, err := nodes()
+	if err != nil {
+		return err
+	}
+	// A node is bound if numactl may run on any of its CPUs.
+	var bound []int
+	for _, n := range ns {
+		nc, err := nodeCPUs([]int{n})
+		if err != nil {
+			return err
+		}
+		for _, c := range nc {
+			if set.IsSet(c) {
+				bound = append(bound, n)
+				break
+			}
+		}
+	}
+
+	policy := "unknown"
+	if mode >= 0 && mode < len(policies) {
+		policy = policies[mode]
+	}
+	fmt.Fprintf(out, "policy: %s\n", policy)
+	switch mode {
+	case mpolPreferred:
+		printList(out, "preferred node", mnodes)
+	case mpolInterleave:
+		printList(out, "interleavemask", mnodes)
+	default:
+		fmt.Fprintln(out, "preferred node: current")
+	}
+	printList(out, "physcpubind", cpus)
+	printList(out, "cpubind", bound)
+	if mode == mpolBind {
+		printList(out, "membind", mnodes)
+	} else {
+		printList(out, "membind", ns)
+	}
+	return nil
+}
+
+// policy is what numactl runs a command with.
+type policy struct {
+	mode  int
+	nodes []int
+	// cpus are those to run on, or all if nil.
+	cpus []int
+}
+
+// parse returns the policy of the flags.
+func parse() (*policy, error) {
+	p := &policy{mode: -1}
+	var n int
+	for _, m := range []struct {
+		mode  int
+		nodes string
+	}{
+		{mpolInterleave, *interleave},
+		{mpolPreferred, *preferred},
+		{mpolBind, *membind},
+	} {
+		if m.nodes == "" {
+			continue
+		}
+		ns, err := parseNodes(m.nodes)
+		if err != nil {
+			return nil, err
+		}
+		if m.mode == mpolPreferred && len(ns) != 1 {
+			return nil, fmt.Errorf("--preferred takes one node, not %q", m.nodes)
+		}
+		p.mode, p.nodes = m.mode, ns
+		n++
+	}
+	if *localalloc {
+		p.mode = mpolLocal
+		n++
+	}
+	if n > 1 {
+		return nil, fmt.Errorf("only one of --interleave, --preferred, --membind and --localalloc may be given")
+	}
+
+	switch {
+	case *cpunodebind != "" && *physcpubind != "":
+		return nil, fmt.Errorf("only one of --cpunodebind and --physcpubind may be given")
+	case *cpunodebind != "":
+		ns, err := parseNodes(*cpunodebind)
+		if err != nil {
+			return nil, err
+		}
+		if p.cpus, err = nodeCPUs(ns); err != nil {
+			return nil, err
+		}
+		if len(p.cpus) == 0 {
+			return nil, fmt.Errorf("nodes %s have no CPUs", *cpunodebind)
+		}
+	case *physcpubind != "":
+		var err error
+		if p.cpus, err = cpuset.ParseList(*physcpubind); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// apply applies p to the calling thread.
+func (p *policy) apply() error {
+	if p.cpus != nil {
+		var set unix.CPUSet
+		for _, c := range p.cpus {
+			if c >= len(set)*64 {
+				return fmt.Errorf("CPU %d is more than numactl supports", c)
+			}
+			set.Set(c)
+		}
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			return fmt.Errorf("sched_setaffinity: %v", err)
+		}
+	}
+	if p.mode >= 0 {
+		if err := setMempolicy(p.mode, p.nodes); err != nil {
+			return fmt.Errorf("set_mempolicy: %v", err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("numactl: ")
+	log.SetFlags(0)
+	flag.CommandLine.SetInterspersed(false)
+	flag.Parse()
+	switch {
+	case *hardware || *show:
+		if flag.NArg() != 0 || (*hardware && *show) {
+			log.Fatal(errUsage)
+		}
+		f := showHardware
+		if *show {
+			f = showPolicy
+		}
+		if err := f(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case flag.NArg() == 0:
+		log.Fatal(errUsage)
+	}
+	p, err := parse()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The policy is set for a thread, which must be the one to exec.
+	runtime.LockOSThread()
+	if err := p.apply(); err != nil {
+		log.Fatal(err)
+	}
+	args := flag.Args()
+	path, err := exec.LookPath(args[0])
+	if err == nil {
+		err = syscall.Exec(path, args, os.Environ())
+	}
+	log.Print(err)
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		os.Exit(127)
+	}
+	os.Exit(126)
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/testutil"
+	"golang.org/x/sys/unix"
+)
+
+func writeNodes(t *testing.T) {
+	t.Helper()
+	nodeDir = t.TempDir()
+	for name, content := range map[string]string{
+		"online":         "0-1\n",
+		"node0/cpulist":  "0-3\n",
+		"node0/meminfo":  "Node 0 MemTotal:        8388608 kB\nNode 0 MemFree:         4194304 kB\n",
+		"node0/distance": "10 21\n",
+		"node1/cpulist":  "4-5,7\n",
+		"node1/meminfo":  "Node 1 MemTotal:        2097152 kB\nNode 1 MemFree:         1048576 kB\n",
+		"node1/distance": "21 10\n",
+		"node2/cpulist":  "\n",
+		"node2/meminfo":  "Node 2 MemTotal: 0 kB\n",
+		"node2/distance": "\n",
+	} {
+		path := filepath.Join(nodeDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestShowHardware(t *testing.T) {
+	defer func(dir string) { nodeDir = dir }(nodeDir)
+	writeNodes(t)
+
+	var out bytes.Buffer
+	if err := showHardware(&out); err != nil {
+		t.Fatalf("showHardware = %v", err)
+	}
+	want := `available: 2 nodes (0-1)
+node 0 cpus: 0 1 2 3
+node 0 size: 8192 MB
+node 0 free: 4096 MB
+node 1 cpus: 4 5 7
+node 1 size: 2048 MB
+node 1 free: 1024 MB
+node distances:
+node    0   1
+  0:   10  21
+  1:   21  10
+`
+	if out.String() != want {
+		t.Errorf("showHardware printed\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	defer func(dir string) { nodeDir = dir }(nodeDir)
+	writeNodes(t)
+
+	reset := func() {
+		*interleave, *preferred, *membind, *cpunodebind, *physcpubind = "", "", "", "", ""
+		*localalloc = false
+	}
+	defer reset()
+	for _, tt := range []struct {
+		desc string
+		set  func()
+		want *policy
+	}{
+		{desc: "none", set: func() {}, want: &policy{mode: -1}},
+		{desc: "membind", set: func() { *membind = "1" }, want: &policy{mode: mpolBind, nodes: []int{1}}},
+		{desc: "interleave all", set: func() { *interleave = "all" }, want: &policy{mode: mpolInterleave, nodes: []int{0, 1}}},
+		{desc: "preferred", set: func() { *preferred = "0" }, want: &policy{mode: mpolPreferred, nodes: []int{0}}},
+		{desc: "localalloc", set: func() { *localalloc = true }, want: &policy{mode: mpolLocal}},
+		{desc: "cpunodebind", set: func() { *cpunodebind = "0-1" }, want: &policy{mode: -1, cpus: []int{0, 1, 2, 3, 4, 5, 7}}},
+		{desc: "physcpubind", set: func() { *physcpubind = "2,6" }, want: &policy{mode: -1, cpus: []int{2, 6}}},
+		{desc: "two memory policies", set: func() { *membind, *localalloc = "0", true }},
+		{desc: "two CPU bindings", set: func() { *cpunodebind, *physcpubind = "0", "0" }},
+		{desc: "two preferred nodes", set: func() { *preferred = "0-1" }},
+		{desc: "node without CPUs", set: func() { *cpunodebind = "2" }},
+		{desc: "bad node", set: func() { *membind = "x" }},
+		{desc: "too many nodes", set: func() { *membind = "1024" }},
+	} {
+		reset()
+		tt.set()
+		got, err := parse()
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: parse = %+v, want an error", tt.desc, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parse = %+v, %v, want %+v", tt.desc, got, err, tt.want)
+		}
+	}
+}
+
+func TestNumactl(t *testing.T) {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		t.Skipf("cannot get the affinity: %v", err)
+	}
+	cpu := 0
+	for !set.IsSet(cpu) {
+		cpu++
+	}
+	out, err := testutil.Command(t, "-l", "-C", strconv.Itoa(cpu), "grep", "Cpus_allowed_list", "/proc/self/status").CombinedOutput()
+	if err != nil {
+		t.Skipf("numactl -l -C %d grep = %v, %s", cpu, err, out)
+	}
+	if f := strings.Fields(string(out)); len(f) != 2 || f[1] != strconv.Itoa(cpu) {
+		t.Errorf("numactl -C %d ran on %q", cpu, out)
+	}
+
+	for _, args := range [][]string{{}, {"-H", "-s"}, {"-s", "true"}, {"-m", "x", "true"}} {
+		if err := testutil.IsExitCode(testutil.Command(t, args...).Run(), 1); err != nil {
+			t.Errorf("numactl %v: %v", args, err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
@@ -92,16 +92,10 @@ func main() {
 	default:
 		log.Fatal("Usage: page [file]")
 	}
-	c, err := t.Raw()
+	err = termios.WithMode(t.Fd(), termios.MakeRaw, func() error {
+		return page(t, in, os.Stdout)
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer func() {
-		if err := t.Set(c); err != nil {
-			log.Printf("Restoring modes failed; sorry (%v)", err)
-		}
-	}()
-	if err := page(t, in, os.Stdout); err != nil {
-		log.Fatal(err)
-	}
 }
@@ -0,0 +1,164 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// paste merges the lines of files.
+//
+// Synopsis:
+//
+//	paste [-s] [-d LIST] [FILE]...
+//
+// Description:
+//
+//	paste prints the first lines of each FILE joined by the delimiters,
+//	then the second lines and so on until every FILE ends. A FILE "-", or
+//	no FILE at all, is stdin. The delimiters in LIST are used in turn and
+//	may be \n, \t, \\ and \0, which means none.
+//
+// Options:
+//
+//	-d: the delimiters (default tab)
+//	-s: paste the lines of each FILE into one line instead
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// delimiters parses LIST into the delimiters it cycles through.
+func delimiters(list string) []string {
+	var d []string
+	for i := 0; i < len(list); i++ {
+		if list[i] != '\\' || i+1 == len(list) {
+			d = append(d, list[i:i+1])
+			continue
+		}
+		i++
+		switch list[i] {
+		case 'n':
+			d = append(d, "\n")
+		case 't':
+			d = append(d, "\t")
+		case '0':
+			d = append(d, "")
+		default:
+			d = append(d, list[i:i+1])
+		}
+	}
+	if len(d) == 0 {
+		d = []string{""}
+	}
+	return d
+}
+
+type input struct {
+	r    *bufio.Reader
+	done bool
+}
+
+// line returns the next line of in without its newline.
+func (in *input) line() (string, error) {
+	if in.done {
+		return "", nil
+	}
+	l, err := in.r.ReadString('\n')
+	if err == io.EOF {
+		in.done = true
+		err = nil
+	}
+	return strings.TrimSuffix(l, "\n"), err
+}
+
+func parallel(w *bufio.Writer, inputs []*input, delims []string) error {
+	for {
+		var line strings.Builder
+		more := false
+		for i, in := range inputs {
+			if i > 0 {
+				line.WriteString(delims[(i-1)%len(delims)])
+			}
+			done := in.done
+			l, err := in.line()
+			if err != nil {
+				return err
+			}
+			// A last line without a newline still counts.
+			if !done && (!in.done || l != "") {
+				more = true
+			}
+			line.WriteString(l)
+		}
+		if !more {
+			return nil
+		}
+		w.WriteString(line.String())
+		w.WriteString("\n")
+	}
+}
+
+func serial(w *bufio.Writer, inputs []*input, delims []string) error {
+	for _, in := range inputs {
+		for i := 0; ; i++ {
+			l, err := in.line()
+			if err != nil {
+				return err
+			}
+			if in.done && l == "" {
+				break
+			}
+			if i > 0 {
+				w.WriteString(delims[(i-1)%len(delims)])
+			}
+			w.WriteString(l)
+		}
+		w.WriteString("\n")
+	}
+	return nil
+}
+
+func run(stdin io.Reader, stdout io.Writer, list string, s bool, files []string) error {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	// Every "-" reads from the same stdin, in turn.
+	var std *input
+	var inputs []*input
+	for _, name := range files {
+		if name == "-" {
+			if std == nil {
+				std = &input{r: bufio.NewReader(stdin)}
+			}
+			inputs = append(inputs, std)
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		inputs = append(inputs, &input{r: bufio.NewReader(f)})
+	}
+	w := bufio.NewWriter(stdout)
+	paste := parallel
+	if s {
+		paste = serial
+	}
+	if err := paste(w, inputs, delimiters(list)); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func main() {
+	d := flag.String("d", "\t", "the delimiters")
+	s := flag.Bool("s", false, "paste the lines of each FILE into one line instead")
+	flag.Parse()
+	if err := run(os.Stdin, os.Stdout, *d, *s, flag.Args()); err != nil {
+		log.Fatalf("paste: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPaste(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	if err := os.WriteFile(a, []byte("1\n2\n3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("x\ny"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name  string
+		d     string
+		s     bool
+		stdin string
+		files []string
+		want  string
+	}{
+		{name: "parallel", d: "\t", files: []string{a, b}, want: "1\tx\n2\ty\n3\t\n"},
+		{name: "delimiters", d: `,\0`, files: []string{a, b, a}, want: "1,x1\n2,y2\n3,3\n"},
+		{name: "serial", d: ",", s: true, files: []string{a, b}, want: "1,2,3\nx,y\n"},
+		{name: "stdin columns", d: " ", stdin: "1\n2\n3\n4\n5\n", files: []string{"-", "-"}, want: "1 2\n3 4\n5 \n"},
+		{name: "newline", d: `\n`, s: true, files: []string{b}, want: "x\ny\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(strings.NewReader(tt.stdin), &out, tt.d, tt.s, tt.files); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("paste = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
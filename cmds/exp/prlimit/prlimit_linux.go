@@ -0,0 +1,142 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// prlimit gets or sets the resource limits of a process.
+//
+// Synopsis:
+//
+//	prlimit [-p PID] [--RESOURCE[=LIMITS]]... [COMMAND [ARG]...]
+//
+// Description:
+//
+//	prlimit prints the limits of the RESOURCEs given without LIMITS, or
+//	all of them if none is given, and sets those given with LIMITS, of the
+//	process PID, or runs COMMAND with them.
+//
+//	LIMITS are SOFT:HARD, where either may be left out to keep it, e.g.
+//	1024: or :4096, or one value for both. A limit may be unlimited.
+//
+//	The resources, with the short options of their ulimit options, are
+//	--as (-v), --core (-c), --cpu (-t), --data (-d), --fsize (-f),
+//	--locks (-x), --memlock (-l), --msgqueue (-q), --nice (-e),
+//	--nofile (-n), --nproc (-u), --rss (-m), --rtprio (-r),
+//	--rttime (-R), --sigpending (-i) and --stack (-s).
+//
+// Options:
+//
+//	-p: act on the running process PID
+//
+// Example:
+//
+//	prlimit --nofile=65536 --core=unlimited: ./provision
+//	prlimit -p 1 --nproc
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	flag "github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/rlimit"
+)
+
+var pid = flag.IntP("pid", "p", 0, "act on the running process `PID`")
+
+var errUsage = errors.New("usage: prlimit [-p PID] [--RESOURCE[=LIMITS]]... [COMMAND [ARG]...]")
+
+// show is the value of the resource flags given without limits.
+const show = "show"
+
+// limits are the values of the resource flags, by resource name.
+var limits = map[string]*string{}
+
+func init() {
+	for _, r := range rlimit.Resources {
+		limits[r.Name] = flag.StringP(r.Name, string(r.Option), "", r.Description)
+		flag.Lookup(r.Name).NoOptDefVal = show
+	}
+}
+
+// prlimit sets the limits given to the resource flags of the process pid,
+// and prints the limits of those given without, or all if none is given and
+// printAll is set.
+func prlimit(out io.Writer, pid int, printAll bool) error {
+	var shown []rlimit.Resource
+	for _, r := range rlimit.Resources {
+		switch v := *limits[r.Name]; v {
+		case "":
+		case show:
+			shown = append(shown, r)
+		default:
+			cur, err := r.Get(pid)
+			if err != nil {
+				return err
+			}
+			l, err := rlimit.ParseLimits(v, cur)
+			if err != nil {
+				return fmt.Errorf("--%s: %v", r.Name, err)
+			}
+			if err := r.Set(pid, l); err != nil {
+				return err
+			}
+			printAll = false
+		}
+	}
+	if len(shown) == 0 && printAll {
+		shown = rlimit.Resources
+	}
+	if len(shown) == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE\tDESCRIPTION\tSOFT\tHARD\tUNITS")
+	for _, r := range shown {
+		l, err := r.Get(pid)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", strings.ToUpper(r.Name), r.Description,
+			rlimit.FormatValue(l.Cur, 1), rlimit.FormatValue(l.Max, 1), r.Units)
+	}
+	return tw.Flush()
+}
+
+func main() {
+	log.SetPrefix("prlimit: ")
+	log.SetFlags(0)
+	flag.CommandLine.SetInterspersed(false)
+	flag.Parse()
+	args := flag.Args()
+	if *pid != 0 && len(args) > 0 || *pid < 0 {
+		log.Fatal(errUsage)
+	}
+	if err := prlimit(os.Stdout, *pid, len(args) == 0); err != nil {
+		log.Fatal(err)
+	}
+	if len(args) == 0 {
+		return
+	}
+
+	// The limits are of the process, and inherited by what it execs.
+	path, err := exec.LookPath(args[0])
+	if err == nil {
+		err = syscall.Exec(path, args, os.Environ())
+	}
+	log.Print(err)
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		os.Exit(127)
+	}
+	os.Exit(126)
+}
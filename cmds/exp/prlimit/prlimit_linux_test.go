@@ -0,0 +1,74 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func TestPrlimit(t *testing.T) {
+	c := exec.Command("sleep", "10")
+	if err := c.Start(); err != nil {
+		t.Skipf("cannot run sleep: %v", err)
+	}
+	defer c.Wait()
+	defer c.Process.Kill()
+	pid := c.Process.Pid
+
+	defer func() { *limits["core"], *limits["nofile"] = "", "" }()
+	var out bytes.Buffer
+	*limits["core"] = "0:"
+	if err := prlimit(&out, pid, true); err != nil || out.Len() != 0 {
+		t.Fatalf("prlimit --core=0: = %q, %v, want nothing printed", out.String(), err)
+	}
+
+	*limits["core"] = show
+	if err := prlimit(&out, pid, true); err != nil {
+		t.Fatalf("prlimit --core = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || strings.Fields(lines[0])[0] != "RESOURCE" || !strings.HasPrefix(lines[1], "CORE") || strings.Fields(lines[1])[5] != "0" {
+		t.Errorf("prlimit --core printed %q, want a soft limit of 0", out.String())
+	}
+
+	out.Reset()
+	*limits["core"] = ""
+	if err := prlimit(&out, pid, true); err != nil || strings.Count(out.String(), "\n") != 17 {
+		t.Errorf("prlimit = %v, printed %q, want all 16 limits", err, out.String())
+	}
+
+	*limits["nofile"] = "2:1"
+	if err := prlimit(&out, pid, true); err == nil {
+		t.Errorf("prlimit --nofile=2:1 succeeded")
+	}
+}
+
+func TestPrlimitCommand(t *testing.T) {
+	out, err := testutil.Command(t, "--nofile=100:200", "grep", "Max open files", "/proc/self/limits").CombinedOutput()
+	if err != nil {
+		t.Fatalf("prlimit --nofile=100:200 grep = %v, %s", err, out)
+	}
+	if f := strings.Fields(string(out)); len(f) < 5 || f[3] != "100" || f[4] != "200" {
+		t.Errorf("prlimit --nofile=100:200 ran with %q", out)
+	}
+
+	for _, args := range [][]string{{"-p", "1", "true"}, {"--nofile=lots"}} {
+		if err := testutil.IsExitCode(testutil.Command(t, args...).Run(), 1); err != nil {
+			t.Errorf("prlimit %v: %v", args, err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
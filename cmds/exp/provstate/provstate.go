@@ -0,0 +1,190 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// provstate keeps the state of a provisioning flow, like its stage, attempt
+// counters and last error, across the reboots of a diskless flow.
+//
+// Synopsis:
+//
+//	provstate [-vars DIR | -dev FILE | -partlabel LABEL] get [KEY]
+//	provstate [-vars DIR | -dev FILE | -partlabel LABEL] set KEY=VALUE...
+//	provstate [-vars DIR | -dev FILE | -partlabel LABEL] del KEY...
+//	provstate [-vars DIR | -dev FILE | -partlabel LABEL] inc KEY
+//	provstate [-vars DIR | -dev FILE | -partlabel LABEL] clear
+//
+// Description:
+//
+//	By default, the state is kept in the EFI variable ProvisioningState.
+//	With -dev or -partlabel, it is kept in a reserved partition instead,
+//	e.g. a small GPT partition of its own, which is overwritten.
+//
+//	get prints the value of KEY, or all KEY=VALUE of the state, sorted. It
+//	fails if KEY is not set.
+//
+//	set sets the keys to the values, and del removes the keys.
+//
+//	inc increments the counter KEY, which is 0 if not set, and prints it,
+//	e.g. to count the attempts at a stage.
+//
+//	clear removes the state.
+//
+//	The state is checked with a CRC: corrupt state is an error, rather than
+//	read as empty, until it is cleared.
+//
+// Options:
+//
+//	-vars:      efivarfs mount point (default /sys/firmware/efi/efivars/)
+//	-dev:       partition, or file, to keep the state in
+//	-partlabel: GPT name of the partition to keep the state in
+//
+// Example:
+//
+//	provstate set stage=flash error=
+//	n=$(provstate inc attempts)
+//	provstate -partlabel provstate get stage
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/provstate"
+)
+
+var (
+	vars      = flag.String("vars", efivarfs.DefaultVarFS, "efivarfs mount point")
+	dev       = flag.String("dev", "", "partition, or file, to keep the state in rather than an EFI variable")
+	partLabel = flag.String("partlabel", "", "GPT name of the partition to keep the state in rather than an EFI variable")
+
+	errUsage = errors.New("usage: provstate get [KEY] | set KEY=VALUE... | del KEY... | inc KEY | clear")
+)
+
+func run(out io.Writer, s *provstate.Store, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd, args := args[0], args[1:]
+	switch {
+	case cmd == "get" && len(args) <= 1:
+		r, err := s.Load()
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			v, ok := r.State[args[0]]
+			if !ok {
+				return fmt.Errorf("%s is not set", args[0])
+			}
+			fmt.Fprintln(out, v)
+			return nil
+		}
+		for _, k := range r.State.Keys() {
+			fmt.Fprintf(out, "%s=%s\n", k, r.State[k])
+		}
+		return nil
+
+	case cmd == "set" && len(args) > 0:
+		_, err := s.Update(func(st provstate.State) error {
+			for _, a := range args {
+				k, v, ok := strings.Cut(a, "=")
+				if !ok || k == "" {
+					return fmt.Errorf("%q is not KEY=VALUE", a)
+				}
+				st[k] = v
+			}
+			return nil
+		})
+		return err
+
+	case cmd == "del" && len(args) > 0:
+		_, err := s.Update(func(st provstate.State) error {
+			for _, k := range args {
+				delete(st, k)
+			}
+			return nil
+		})
+		return err
+
+	case cmd == "inc" && len(args) == 1:
+		var n int
+		_, err := s.Update(func(st provstate.State) error {
+			var err error
+			n, err = st.Inc(args[0])
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, n)
+		return nil
+
+	case cmd == "clear" && len(args) == 0:
+		return s.Clear()
+	}
+	return errUsage
+}
+
+// partition returns the backend of the partition, or file, path.
+func partition(path string) (provstate.Backend, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &provstate.Partition{Dev: f, Size: size}, nil
+}
+
+// backend returns the backend of the flags.
+func backend() (provstate.Backend, error) {
+	switch {
+	case *dev != "" && *partLabel != "":
+		return nil, errors.New("-dev and -partlabel are exclusive")
+	case *dev != "":
+		return partition(*dev)
+	case *partLabel != "":
+		devs, err := block.GetBlockDevices()
+		if err != nil {
+			return nil, err
+		}
+		devs = devs.FilterPartLabel(*partLabel)
+		if len(devs) != 1 {
+			return nil, fmt.Errorf("found %d partitions named %q, want 1", len(devs), *partLabel)
+		}
+		return partition(devs[0].DevicePath())
+	}
+	e, err := efivarfs.NewPath(*vars)
+	if err != nil {
+		return nil, err
+	}
+	return &provstate.EFIVariable{Vars: e}, nil
+}
+
+func main() {
+	log.SetPrefix("provstate: ")
+	log.SetFlags(0)
+	flag.Parse()
+	b, err := backend()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := run(os.Stdout, &provstate.Store{Backend: b}, flag.Args()); err != nil {
+		if err == errUsage {
+			fmt.Fprintln(os.Stderr, err)
+			flag.PrintDefaults()
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
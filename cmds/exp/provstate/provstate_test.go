@@ -0,0 +1,56 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/provstate"
+)
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "part")
+	if err := os.WriteFile(path, make([]byte, 8192), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := partition(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &provstate.Store{Backend: b}
+	for _, tt := range []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: []string{"get"}},
+		{args: []string{"get", "stage"}, wantErr: true},
+		{args: []string{"set", "stage=flash", "error=timeout fetching image"}},
+		{args: []string{"get", "stage"}, want: "flash\n"},
+		{args: []string{"inc", "attempts"}, want: "1\n"},
+		{args: []string{"inc", "attempts"}, want: "2\n"},
+		{args: []string{"inc", "stage"}, wantErr: true},
+		{args: []string{"get"}, want: "attempts=2\nerror=timeout fetching image\nstage=flash\n"},
+		{args: []string{"del", "error"}},
+		{args: []string{"set", "stage"}, wantErr: true},
+		{args: []string{"get"}, want: "attempts=2\nstage=flash\n"},
+		{args: []string{"clear"}},
+		{args: []string{"get"}},
+		{args: []string{"inc"}, wantErr: true},
+		{wantErr: true},
+	} {
+		var out strings.Builder
+		err := run(&out, s, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("provstate %q = %v, want error %t", tt.args, err, tt.wantErr)
+		}
+		if out.String() != tt.want {
+			t.Errorf("provstate %q printed %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+}
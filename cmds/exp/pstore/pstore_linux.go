@@ -0,0 +1,263 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// pstore lists, decodes and uploads persistent storage records.
+//
+// Synopsis:
+//
+//	pstore [-d DIR] [list]
+//	pstore [-d DIR] show [NAME...]
+//	pstore [-d DIR] upload URL
+//	pstore [-d DIR] clear
+//	pstore partition DEV
+//
+// Description:
+//
+//	The kernel stores oops/panic logs and console output of the previous
+//	boot in ramoops or efi-pstore backends, which are exposed through the
+//	pstore file system. pstore mounts it if necessary and decodes the
+//	records, including compressed ones (zlib from older kernels, raw
+//	deflate from 4.19 on).
+//
+//	list prints a line per record. show prints the decoded text of the
+//	given records, or all of them. upload POSTs each record to URL/NAME.
+//	clear removes the records, freeing space in the backend.
+//
+//	partition prints the log that logfwd -part kept on the partition DEV,
+//	which does not go through the pstore file system.
+//
+// Options:
+//
+//	-d: pstore mount point (default /sys/fs/pstore)
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/ulog/persist"
+	"golang.org/x/sys/unix"
+)
+
+var dir = flag.String("d", "/sys/fs/pstore", "pstore mount point")
+
+// record is a single pstore entry, e.g. dmesg-ramoops-0.
+type record struct {
+	Name    string
+	Type    string
+	Backend string
+	ID      string
+	ModTime time.Time
+
+	path       string
+	compressed bool
+}
+
+// parseName splits a pstore file name of the form TYPE-BACKEND-ID[.enc.z].
+func parseName(name string) (record, error) {
+	r := record{Name: name}
+	base := name
+	if strings.HasSuffix(base, ".enc.z") {
+		base = strings.TrimSuffix(base, ".enc.z")
+		r.compressed = true
+	}
+	f := strings.Split(base, "-")
+	if len(f) < 3 {
+		return r, fmt.Errorf("%q is not a pstore record name", name)
+	}
+	r.Type = f[0]
+	r.Backend = strings.Join(f[1:len(f)-1], "-")
+	r.ID = f[len(f)-1]
+	return r, nil
+}
+
+func records(dir string) ([]record, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var recs []record
+	for _, e := range ents {
+		if e.IsDir() {
+			continue
+		}
+		r, err := parseName(e.Name())
+		if err != nil {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			r.ModTime = fi.ModTime()
+		}
+		r.path = filepath.Join(dir, e.Name())
+		recs = append(recs, r)
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Name < recs[j].Name })
+	return recs, nil
+}
+
+// decode returns the text of a record.
+func (r record) decode() ([]byte, error) {
+	b, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+	if !r.compressed {
+		return b, nil
+	}
+	// Kernels before 4.19 wrote zlib streams, later ones compress with
+	// the crypto API's raw deflate.
+	var zr io.ReadCloser
+	zr, err = zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		zr = flate.NewReader(bytes.NewReader(b))
+	}
+	defer zr.Close()
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", r.Name, err)
+	}
+	return text, nil
+}
+
+// ensureMounted mounts pstore at dir unless it is already there.
+func ensureMounted(dir string) error {
+	var s unix.Statfs_t
+	if err := unix.Statfs(dir, &s); err != nil {
+		return err
+	}
+	if s.Type == unix.PSTOREFS_MAGIC {
+		return nil
+	}
+	_, err := mount.Mount("pstore", dir, "pstore", "", 0)
+	return err
+}
+
+func list(w io.Writer, recs []record) {
+	for _, r := range recs {
+		fmt.Fprintf(w, "%-24s %-8s %-10s %s\n", r.Name, r.Type, r.Backend, r.ModTime.Format(time.RFC3339))
+	}
+}
+
+func show(w io.Writer, recs []record, names []string) error {
+	want := make(map[string]bool)
+	for _, n := range names {
+		want[n] = true
+	}
+	for _, r := range recs {
+		if len(want) > 0 && !want[r.Name] {
+			continue
+		}
+		delete(want, r.Name)
+		b, err := r.decode()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "==> %s <==\n%s\n", r.Name, b)
+	}
+	for n := range want {
+		return fmt.Errorf("no such record %q", n)
+	}
+	return nil
+}
+
+func upload(recs []record, base string) error {
+	var errs []string
+	for _, r := range recs {
+		b, err := r.decode()
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		u := strings.TrimSuffix(base, "/") + "/" + r.Name
+		resp, err := http.Post(u, "text/plain", bytes.NewReader(b))
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			errs = append(errs, fmt.Sprintf("%s: %s", u, resp.Status))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func clearRecords(recs []record) error {
+	for _, r := range recs {
+		if err := os.Remove(r.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// showPartition prints the log on the log partition dev.
+func showPartition(w io.Writer, dev string) error {
+	start, b, err := persist.ReadPartition(dev)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "log started %s\n", start.Format(time.RFC3339))
+	_, err = w.Write(b)
+	return err
+}
+
+func run(w io.Writer, dir string, args []string) error {
+	if len(args) > 0 && args[0] == "partition" {
+		if len(args) != 2 {
+			return fmt.Errorf("usage: pstore partition DEV")
+		}
+		return showPartition(w, args[1])
+	}
+	if err := ensureMounted(dir); err != nil {
+		return fmt.Errorf("mounting pstore on %s: %v", dir, err)
+	}
+	recs, err := records(dir)
+	if err != nil {
+		return err
+	}
+
+	cmd := "list"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "list":
+		list(w, recs)
+		return nil
+	case "show":
+		return show(w, recs, args)
+	case "upload":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: pstore upload URL")
+		}
+		return upload(recs, args[0])
+	case "clear":
+		return clearRecords(recs)
+	}
+	return fmt.Errorf("unknown command %q", cmd)
+}
+
+func main() {
+	flag.Parse()
+	if err := run(os.Stdout, *dir, flag.Args()); err != nil {
+		log.Fatalf("pstore: %v", err)
+	}
+}
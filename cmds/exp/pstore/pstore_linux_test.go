@@ -0,0 +1,126 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/ulog/persist"
+)
+
+func writeRecords(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "console-ramoops-0"), []byte("console log"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write([]byte("Oops#1 Part1\nKernel panic"))
+	zw.Close()
+	if err := os.WriteFile(filepath.Join(dir, "dmesg-efi-165003726801001.enc.z"), z.Bytes(), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	// Kernels since 4.19 write raw deflate instead.
+	var f bytes.Buffer
+	fw, _ := flate.NewWriter(&f, flate.DefaultCompression)
+	fw.Write([]byte("Panic#2 Part1\nBUG: unable to handle page fault"))
+	fw.Close()
+	if err := os.WriteFile(filepath.Join(dir, "dmesg-ramoops-1.enc.z"), f.Bytes(), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "junk"), nil, 0o444); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRecords(t *testing.T) {
+	recs, err := records(writeRecords(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("records = %v, want 3 records", recs)
+	}
+	if r := recs[1]; r.Type != "dmesg" || r.Backend != "efi" || r.ID != "165003726801001" || !r.compressed {
+		t.Errorf("record = %+v", r)
+	}
+
+	var out bytes.Buffer
+	if err := show(&out, recs, []string{"dmesg-efi-165003726801001.enc.z"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Kernel panic") || strings.Contains(out.String(), "console log") {
+		t.Errorf("show = %q", out.String())
+	}
+	out.Reset()
+	if err := show(&out, recs, []string{"dmesg-ramoops-1.enc.z"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "unable to handle page fault") {
+		t.Errorf("show(raw deflate record) = %q", out.String())
+	}
+	if err := show(&out, recs, []string{"nope"}); err == nil {
+		t.Errorf("show(nope) succeeded, want error")
+	}
+}
+
+func TestUpload(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string]string)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got[r.URL.Path] = string(b)
+		mu.Unlock()
+	}))
+	defer s.Close()
+
+	recs, err := records(writeRecords(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := upload(recs, s.URL+"/host1/"); err != nil {
+		t.Fatal(err)
+	}
+	if got["/host1/console-ramoops-0"] != "console log" || !strings.Contains(got["/host1/dmesg-efi-165003726801001.enc.z"], "Oops#1") {
+		t.Errorf("uploaded %v", got)
+	}
+}
+
+func TestShowPartition(t *testing.T) {
+	dev := filepath.Join(t.TempDir(), "part")
+	if err := os.WriteFile(dev, make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := run(&out, "", []string{"partition", dev}); err == nil {
+		t.Errorf("pstore partition of a partition without a log succeeded")
+	}
+
+	p, err := persist.OpenPartition(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Write([]byte("logfwd: started\n"))
+	p.Close()
+	if err := run(&out, "", []string{"partition", dev}); err != nil || !strings.HasPrefix(out.String(), "log started ") || !strings.HasSuffix(out.String(), "\nlogfwd: started\n") {
+		t.Errorf("pstore partition = %v, printed %q", err, out.String())
+	}
+	if err := run(&out, "", []string{"partition"}); err == nil {
+		t.Errorf("pstore partition without a device succeeded")
+	}
+}
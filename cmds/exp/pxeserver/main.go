@@ -6,6 +6,13 @@
 //
 // pxeserver can either respond to *all* DHCP requests, or a DHCP request from
 // a specific MAC. In either case, it will supply the same IP in all answers.
+//
+// With -menu-dir, pxeserver also generates a pxelinux config for each client
+// from the templates in that directory, and serves it as pxelinux.cfg/* over
+// TFTP and HTTP, or as an iPXE script at /ipxe?mac=${net0/mac} over HTTP.
+// The templates are named like the files pxelinux looks for, such as
+// 01-aa-bb-cc-dd-ee-ff, C0A800 or default, and can use {{.MAC}}, {{.IP}}
+// and {{.Server}}; see pxe.Menus.
 package main
 
 import (
@@ -16,6 +23,7 @@ import (
 	"math"
 	"net"
 	"net/http"
+	"path"
 	"runtime"
 	"sync"
 	"time"
@@ -24,6 +32,7 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4/server4"
 	"github.com/insomniacslk/dhcp/dhcpv6"
 	"github.com/insomniacslk/dhcp/dhcpv6/server6"
+	"github.com/u-root/u-root/pkg/boot/netboot/pxe"
 	"pack.ag/tftp"
 )
 
@@ -48,6 +57,7 @@ var (
 	tftpPort = flag.Int("tftp-port", 69, "Port to serve TFTP on")
 	httpDir  = flag.String("http-dir", "", "Directory to serve over HTTP")
 	httpPort = flag.Int("http-port", 80, "Port to serve HTTP on")
+	menuDir  = flag.String("menu-dir", "", "Directory of pxelinux config templates to generate menus from")
 )
 
 type dserver4 struct {
@@ -184,6 +194,27 @@ func (s *dserver6) dhcpHandler(conn net.PacketConn, peer net.Addr, m dhcpv6.DHCP
 	log.Printf("DHCPv6 request successfully handled, reply: %v", reply.Summary())
 }
 
+// menuTFTP serves the generated menus of m for pxelinux.cfg/* when m is
+// not nil, and everything else with files.
+func menuTFTP(m *pxe.Menus, files tftp.ReadHandler) tftp.ReadHandler {
+	return tftp.ReadHandlerFunc(func(r tftp.ReadRequest) {
+		if m == nil || path.Base(path.Dir(r.Name())) != "pxelinux.cfg" {
+			files.ServeTFTP(r)
+			return
+		}
+		b, err := m.ConfigFor(r.Name(), r.Addr().IP, "")
+		if err != nil {
+			log.Printf("No menu %q for %v: %v", r.Name(), r.Addr(), err)
+			r.WriteError(tftp.ErrCodeFileNotFound, err.Error())
+			return
+		}
+		r.WriteSize(int64(len(b)))
+		if _, err := r.Write(b); err != nil {
+			log.Printf("Could not send menu %q to %v: %v", r.Name(), r.Addr(), err)
+		}
+	})
+}
+
 func main() {
 	flag.Parse()
 
@@ -200,8 +231,13 @@ func main() {
 		log.Fatal(err)
 	}
 
+	var menus *pxe.Menus
+	if len(*menuDir) != 0 {
+		menus = &pxe.Menus{Dir: *menuDir, Server: *selfIP}
+	}
+
 	var wg sync.WaitGroup
-	if len(*tftpDir) != 0 {
+	if len(*tftpDir) != 0 || menus != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -211,15 +247,27 @@ func main() {
 			}
 
 			log.Println("starting file server")
-			server.ReadHandler(tftp.FileServer(*tftpDir))
+			var files tftp.ReadHandler = tftp.ReadHandlerFunc(func(r tftp.ReadRequest) {
+				r.WriteError(tftp.ErrCodeFileNotFound, fmt.Sprintf("File %q does not exist", r.Name()))
+			})
+			if len(*tftpDir) != 0 {
+				files = tftp.FileServer(*tftpDir)
+			}
+			server.ReadHandler(menuTFTP(menus, files))
 			log.Fatal(server.ListenAndServe())
 		}()
 	}
-	if len(*httpDir) != 0 {
+	if len(*httpDir) != 0 || menus != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			http.Handle("/", http.FileServer(http.Dir(*httpDir)))
+			if len(*httpDir) != 0 {
+				http.Handle("/", http.FileServer(http.Dir(*httpDir)))
+			}
+			if menus != nil {
+				http.Handle("/pxelinux.cfg/", menus)
+				http.Handle("/ipxe", menus)
+			}
 			log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", *httpPort), nil))
 		}()
 	}
@@ -0,0 +1,152 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// rdmsr reads an MSR of CPUs, through /dev/cpu/*/msr.
+//
+// Synopsis:
+//
+//	rdmsr [-p CPUS | -a] [-f HI:LO] [-d] MSR
+//	rdmsr -l
+//
+// Description:
+//
+//	MSR is an address, e.g. 0x1a0, or a name, e.g. IA32_MISC_ENABLE;
+//	-l lists the names. The value is printed in hex, or decimal with -d,
+//	prefixed with the CPU if more than one is read.
+//
+//	The msr module must be loaded.
+//
+// Options:
+//
+//	-p: CPUs to read, e.g. 0-3,7 (default 0)
+//	-a: read all CPUs
+//	-f: print only bits HI to LO, e.g. 15:8
+//	-d: print in decimal
+//	-l: list the names of MSRs
+//
+// Example:
+//
+//	rdmsr -a -f 22:16 MSR_TEMPERATURE_TARGET
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/msr"
+)
+
+var (
+	cpuList = flag.String("p", "0", "CPUs to read, e.g. 0-3,7")
+	all     = flag.Bool("a", false, "read all CPUs")
+	field   = flag.String("f", "", "print only bits HI to LO, e.g. 15:8")
+	decimal = flag.Bool("d", false, "print in decimal")
+	list    = flag.Bool("l", false, "list the names of MSRs")
+)
+
+var errUsage = errors.New("usage: rdmsr [-p CPUS | -a] [-f HI:LO] [-d] MSR")
+
+// parseField parses HI:LO, and returns the shift and mask of the bits.
+func parseField(s string) (uint, uint64, error) {
+	h, l, ok := strings.Cut(s, ":")
+	hi, herr := strconv.ParseUint(h, 10, 8)
+	lo, lerr := strconv.ParseUint(l, 10, 8)
+	if !ok || herr != nil || lerr != nil || hi > 63 || lo > hi {
+		return 0, 0, fmt.Errorf("field %q is not HI:LO, with 63 >= HI >= LO", s)
+	}
+	return uint(lo), ^uint64(0) >> (63 - (hi - lo)), nil
+}
+
+// cpuErrors returns the errors of the CPUs which failed.
+func cpuErrors(cpus msr.CPUs, errs []error) string {
+	var s []string
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if len(errs) == len(cpus) {
+			s = append(s, fmt.Sprintf("CPU %d: %v", cpus[i], err))
+		} else {
+			s = append(s, err.Error())
+		}
+	}
+	return strings.Join(s, "; ")
+}
+
+func run(out io.Writer) error {
+	if *list {
+		names := make([]string, 0, len(msr.Names))
+		for n := range msr.Names {
+			names = append(names, n)
+		}
+		sort.Slice(names, func(i, j int) bool { return msr.Names[names[i]] < msr.Names[names[j]] })
+		for _, n := range names {
+			if _, err := fmt.Fprintf(out, "%#08x %s\n", uint32(msr.Names[n]), n); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if flag.NArg() != 1 {
+		return errUsage
+	}
+	m, err := msr.Lookup(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	shift, mask := uint(0), ^uint64(0)
+	if *field != "" {
+		if shift, mask, err = parseField(*field); err != nil {
+			return err
+		}
+	}
+	var cpus msr.CPUs
+	if *all {
+		cpus, err = msr.AllCPUs()
+	} else {
+		cpus, err = msr.ParseCPUs(*cpuList)
+	}
+	if err != nil {
+		return err
+	}
+
+	vals, errs := m.Read(cpus)
+	if errs != nil {
+		return fmt.Errorf("reading %v: %s", m, cpuErrors(cpus, errs))
+	}
+	format := "%#x\n"
+	if *decimal {
+		format = "%d\n"
+	}
+	for i, v := range vals {
+		if len(cpus) > 1 {
+			if _, err := fmt.Fprintf(out, "%d: ", cpus[i]); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(out, format, v>>shift&mask); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("rdmsr: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
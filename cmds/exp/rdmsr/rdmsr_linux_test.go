@@ -0,0 +1,69 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func TestParseField(t *testing.T) {
+	for _, tt := range []struct {
+		field   string
+		v       uint64
+		want    uint64
+		wantErr bool
+	}{
+		{field: "15:8", v: 0xabcd, want: 0xab},
+		{field: "63:0", v: 0xfedcba9876543210, want: 0xfedcba9876543210},
+		{field: "63:63", v: 1 << 63, want: 1},
+		{field: "22:16", v: 0x640000, want: 0x64},
+		{field: "8:15", wantErr: true},
+		{field: "64:0", wantErr: true},
+		{field: "15", wantErr: true},
+	} {
+		shift, mask, err := parseField(tt.field)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseField(%q) = %v, want error %v", tt.field, err, tt.wantErr)
+			continue
+		}
+		if got := tt.v >> shift & mask; err == nil && got != tt.want {
+			t.Errorf("bits %s of %#x = %#x, want %#x", tt.field, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestRdmsr(t *testing.T) {
+	var stdout bytes.Buffer
+	cmd := testutil.Command(t, "-l")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("rdmsr -l = %v", err)
+	}
+	if !strings.Contains(stdout.String(), "0x000001a0 IA32_MISC_ENABLE\n") {
+		t.Errorf("rdmsr -l printed %q, want IA32_MISC_ENABLE", stdout.String())
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"IA32_NOPE"},
+		{"-f", "1:2", "0x10"},
+		{"-p", "2-1", "0x10"},
+	} {
+		if err := testutil.IsExitCode(testutil.Command(t, args...).Run(), 1); err != nil {
+			t.Errorf("rdmsr %v: %v", args, err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
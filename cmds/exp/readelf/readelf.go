@@ -0,0 +1,291 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// readelf displays information about ELF files.
+//
+// Synopsis:
+//
+//	readelf [-ahlSdn] FILE...
+//
+// Description:
+//
+//	readelf inspects kernels, modules and binaries. Without options, the
+//	file header is printed.
+//
+// Options:
+//
+//	-a: all of the below
+//	-h: file header, including the program interpreter
+//	-l: program headers
+//	-S: section headers
+//	-d: dynamic section and needed libraries
+//	-n: notes, including the GNU build ID
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+type options struct {
+	header   bool
+	programs bool
+	sections bool
+	dynamic  bool
+	notes    bool
+}
+
+var (
+	all  = flag.Bool("a", false, "display all information")
+	opts options
+)
+
+func init() {
+	flag.BoolVar(&opts.header, "h", false, "display the ELF file header")
+	flag.BoolVar(&opts.programs, "l", false, "display the program headers")
+	flag.BoolVar(&opts.sections, "S", false, "display the section headers")
+	flag.BoolVar(&opts.dynamic, "d", false, "display the dynamic section")
+	flag.BoolVar(&opts.notes, "n", false, "display the notes")
+}
+
+func printHeader(w io.Writer, f *elf.File) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "ELF Header:\n")
+	fmt.Fprintf(tw, "  Class:\t%v\n", f.Class)
+	fmt.Fprintf(tw, "  Data:\t%v\n", f.Data)
+	fmt.Fprintf(tw, "  OS/ABI:\t%v\n", f.OSABI)
+	fmt.Fprintf(tw, "  ABI Version:\t%d\n", f.ABIVersion)
+	fmt.Fprintf(tw, "  Type:\t%v\n", f.Type)
+	fmt.Fprintf(tw, "  Machine:\t%v\n", f.Machine)
+	fmt.Fprintf(tw, "  Entry point address:\t%#x\n", f.Entry)
+	fmt.Fprintf(tw, "  Number of program headers:\t%d\n", len(f.Progs))
+	fmt.Fprintf(tw, "  Number of section headers:\t%d\n", len(f.Sections))
+	interp, err := interpreter(f)
+	if err != nil {
+		return err
+	}
+	if interp != "" {
+		fmt.Fprintf(tw, "  Program interpreter:\t%s\n", interp)
+	}
+	return tw.Flush()
+}
+
+// interpreter returns the contents of PT_INTERP, or "" for files without one,
+// such as shared libraries and static binaries.
+func interpreter(f *elf.File) (string, error) {
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_INTERP {
+			continue
+		}
+		b, err := io.ReadAll(p.Open())
+		if err != nil {
+			return "", fmt.Errorf("reading PT_INTERP: %v", err)
+		}
+		return string(bytes.TrimRight(b, "\x00")), nil
+	}
+	return "", nil
+}
+
+func printPrograms(w io.Writer, f *elf.File) error {
+	if len(f.Progs) == 0 {
+		fmt.Fprintf(w, "\nThere are no program headers in this file.\n")
+		return nil
+	}
+	fmt.Fprintf(w, "\nProgram Headers:\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "  Type\tOffset\tVirtAddr\tPhysAddr\tFileSiz\tMemSiz\tFlags\tAlign\n")
+	for _, p := range f.Progs {
+		fmt.Fprintf(tw, "  %v\t%#x\t%#x\t%#x\t%#x\t%#x\t%v\t%#x\n",
+			p.Type, p.Off, p.Vaddr, p.Paddr, p.Filesz, p.Memsz, p.Flags, p.Align)
+	}
+	return tw.Flush()
+}
+
+func printSections(w io.Writer, f *elf.File) error {
+	if len(f.Sections) == 0 {
+		fmt.Fprintf(w, "\nThere are no sections in this file.\n")
+		return nil
+	}
+	fmt.Fprintf(w, "\nSection Headers:\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "  [Nr]\tName\tType\tAddress\tOffset\tSize\tFlags\n")
+	for i, s := range f.Sections {
+		fmt.Fprintf(tw, "  [%2d]\t%s\t%v\t%#x\t%#x\t%#x\t%v\n",
+			i, s.Name, s.Type, s.Addr, s.Offset, s.Size, s.Flags)
+	}
+	return tw.Flush()
+}
+
+func printDynamic(w io.Writer, f *elf.File) error {
+	if f.Section(".dynamic") == nil {
+		fmt.Fprintf(w, "\nThere is no dynamic section in this file.\n")
+		return nil
+	}
+	fmt.Fprintf(w, "\nDynamic section:\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, tag := range []elf.DynTag{elf.DT_NEEDED, elf.DT_SONAME, elf.DT_RPATH, elf.DT_RUNPATH} {
+		vals, err := f.DynString(tag)
+		if err != nil {
+			return err
+		}
+		for _, v := range vals {
+			fmt.Fprintf(tw, "  %v\t[%s]\n", tag, v)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	libs, err := f.ImportedLibraries()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nNeeded libraries:\n")
+	for _, l := range libs {
+		fmt.Fprintf(w, "  %s\n", l)
+	}
+	return nil
+}
+
+// note is a single entry of an SHT_NOTE section.
+type note struct {
+	name string
+	typ  uint32
+	desc []byte
+}
+
+func parseNotes(data []byte, order binary.ByteOrder) ([]note, error) {
+	// Sizes come from the file and may be garbage; keep the arithmetic
+	// in 64 bits so aligning can't wrap around.
+	align := func(n uint32) uint64 { return (uint64(n) + 3) &^ 3 }
+	var notes []note
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return notes, fmt.Errorf("truncated note header")
+		}
+		namesz, descsz, typ := order.Uint32(data), order.Uint32(data[4:]), order.Uint32(data[8:])
+		data = data[12:]
+		if align(namesz) > uint64(len(data)) {
+			return notes, fmt.Errorf("truncated note name")
+		}
+		name := string(bytes.TrimRight(data[:namesz], "\x00"))
+		data = data[align(namesz):]
+		if uint64(descsz) > uint64(len(data)) {
+			return notes, fmt.Errorf("truncated note description")
+		}
+		desc := data[:descsz]
+		// The last note's description need not be padded.
+		if align(descsz) > uint64(len(data)) {
+			data = nil
+		} else {
+			data = data[align(descsz):]
+		}
+		notes = append(notes, note{name: name, typ: typ, desc: desc})
+	}
+	return notes, nil
+}
+
+// ntGNUBuildID is the note type of the GNU build ID.
+const ntGNUBuildID = 3
+
+func printNotes(w io.Writer, f *elf.File) error {
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_NOTE {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return err
+		}
+		notes, err := parseNotes(data, f.ByteOrder)
+		if err != nil {
+			return fmt.Errorf("section %s: %v", s.Name, err)
+		}
+		fmt.Fprintf(w, "\nDisplaying notes found in: %s\n", s.Name)
+		tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+		fmt.Fprintf(tw, "  Owner\tData size\tDescription\n")
+		for _, n := range notes {
+			if n.name == "GNU" && n.typ == ntGNUBuildID {
+				fmt.Fprintf(tw, "  %s\t%#x\tBuild ID: %s\n", n.name, len(n.desc), hex.EncodeToString(n.desc))
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%#x\ttype %#x\n", n.name, len(n.desc), n.typ)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readelf(w io.Writer, o options, name string) error {
+	f, err := elf.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if o.header {
+		if err := printHeader(w, f); err != nil {
+			return err
+		}
+	}
+	if o.programs {
+		if err := printPrograms(w, f); err != nil {
+			return err
+		}
+	}
+	if o.sections {
+		if err := printSections(w, f); err != nil {
+			return err
+		}
+	}
+	if o.dynamic {
+		if err := printDynamic(w, f); err != nil {
+			return err
+		}
+	}
+	if o.notes {
+		if err := printNotes(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func run(w io.Writer, o options, names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("usage: readelf [-ahlSdn] FILE...")
+	}
+	if o == (options{}) {
+		o.header = true
+	}
+	for _, n := range names {
+		if len(names) > 1 {
+			fmt.Fprintf(w, "\nFile: %s\n", n)
+		}
+		if err := readelf(w, o, n); err != nil {
+			return fmt.Errorf("%s: %v", n, err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if *all {
+		opts = options{true, true, true, true, true}
+	}
+	if err := run(os.Stdout, opts, flag.Args()); err != nil {
+		log.Fatalf("readelf: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseNotes(t *testing.T) {
+	var b bytes.Buffer
+	for _, v := range []uint32{4, 3, ntGNUBuildID} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("GNU\x00")
+	b.Write([]byte{0xde, 0xad, 0xbe, 0x00})
+
+	notes, err := parseNotes(b.Bytes(), binary.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].name != "GNU" || notes[0].typ != ntGNUBuildID || !bytes.Equal(notes[0].desc, []byte{0xde, 0xad, 0xbe}) {
+		t.Errorf("parseNotes = %+v", notes)
+	}
+
+	if _, err := parseNotes(b.Bytes()[:14], binary.LittleEndian); err == nil {
+		t.Errorf("parseNotes(truncated) succeeded, want error")
+	}
+
+	// Sizes that wrap around when aligned in 32 bits.
+	for _, sz := range [][2]uint32{{0xfffffffe, 0}, {4, 0xfffffffe}, {0xffffffff, 0xffffffff}} {
+		var b bytes.Buffer
+		for _, v := range []uint32{sz[0], sz[1], ntGNUBuildID} {
+			binary.Write(&b, binary.LittleEndian, v)
+		}
+		b.WriteString("GNU\x00")
+		if _, err := parseNotes(b.Bytes(), binary.LittleEndian); err == nil {
+			t.Errorf("parseNotes(namesz %#x, descsz %#x) succeeded, want error", sz[0], sz[1])
+		}
+	}
+}
+
+func TestInterpreter(t *testing.T) {
+	// Go test binaries are usually static, /bin/sh usually is not. Either
+	// way, the answer must match the program headers and not the host.
+	for _, name := range []string{"/proc/self/exe", "/bin/sh"} {
+		f, err := elf.Open(name)
+		if err != nil {
+			t.Logf("skipping %s: %v", name, err)
+			continue
+		}
+		defer f.Close()
+		want := false
+		for _, p := range f.Progs {
+			want = want || p.Type == elf.PT_INTERP
+		}
+		got, err := interpreter(f)
+		if err != nil {
+			t.Errorf("interpreter(%s) = %v", name, err)
+		}
+		if (got != "") != want {
+			t.Errorf("interpreter(%s) = %q, has PT_INTERP: %v", name, got, want)
+		}
+	}
+}
+
+func TestReadelf(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	var out bytes.Buffer
+	if err := run(&out, options{true, true, true, true, true}, []string{exe}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"ELF Header:", "Program Headers:", "Section Headers:", ".text"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, out.String())
+		}
+	}
+
+	if err := run(&out, options{}, []string{"/dev/null"}); err == nil {
+		t.Errorf("run(/dev/null) succeeded, want error")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// realpath prints resolved absolute paths.
+//
+// Synopsis:
+//
+//	realpath [-e | -m] [-s] [-q] [-z] FILE...
+//
+// Description:
+//
+//	realpath prints the absolute path of each FILE, with every symbolic
+//	link resolved and no ".", ".." or extra slashes. All components but
+//	the last must exist.
+//
+// Options:
+//
+//	-e: all components must exist
+//	-m: components may be missing
+//	-q: do not print errors
+//	-s: do not resolve symbolic links
+//	-z: end each path with NUL, not newline
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/u-root/u-root/pkg/upath"
+)
+
+type options struct {
+	missing upath.Missing
+	strip   bool
+	quiet   bool
+	zero    bool
+}
+
+func realpath(o *options, file string) (string, error) {
+	if !o.strip {
+		return upath.Canonical(file, o.missing)
+	}
+	p, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	if o.missing == upath.AnyMissing {
+		return p, nil
+	}
+	check := p
+	if o.missing == upath.LastMissing {
+		check = filepath.Dir(p)
+	}
+	if _, err := os.Stat(check); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+func run(stdout, stderr io.Writer, o *options, files []string) error {
+	if len(files) == 0 {
+		return errors.New("missing operand")
+	}
+	end := "\n"
+	if o.zero {
+		end = "\x00"
+	}
+	var failed error
+	for _, f := range files {
+		p, err := realpath(o, f)
+		if err != nil {
+			if !o.quiet {
+				fmt.Fprintf(stderr, "realpath: %v\n", err)
+			}
+			failed = errors.New("some paths could not be resolved")
+			continue
+		}
+		fmt.Fprint(stdout, p, end)
+	}
+	return failed
+}
+
+func main() {
+	e := flag.Bool("e", false, "all components must exist")
+	m := flag.Bool("m", false, "components may be missing")
+	o := &options{missing: upath.LastMissing}
+	flag.BoolVar(&o.quiet, "q", false, "do not print errors")
+	flag.BoolVar(&o.strip, "s", false, "do not resolve symbolic links")
+	flag.BoolVar(&o.zero, "z", false, "end each path with NUL, not newline")
+	flag.Parse()
+	switch {
+	case *e && *m:
+		log.Fatalf("realpath: -e and -m cannot be used together")
+	case *e:
+		o.missing = upath.NoneMissing
+	case *m:
+		o.missing = upath.AnyMissing
+	}
+	if err := run(os.Stdout, os.Stderr, o, flag.Args()); err != nil {
+		if o.quiet {
+			os.Exit(1)
+		}
+		log.Fatalf("realpath: %v", err)
+	}
+}
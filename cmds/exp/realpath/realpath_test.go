@@ -0,0 +1,56 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/upath"
+)
+
+func TestRealpath(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("d", filepath.Join(dir, "l")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name    string
+		o       options
+		files   []string
+		want    string
+		wantErr bool
+	}{
+		{name: "resolve", o: options{missing: upath.LastMissing}, files: []string{"l", "l/x"}, want: dir + "/d\n" + dir + "/d/x\n"},
+		{name: "existing", o: options{missing: upath.NoneMissing}, files: []string{"l/x", "l"}, want: dir + "/d\n", wantErr: true},
+		{name: "missing", o: options{missing: upath.AnyMissing}, files: []string{"x/y/../z"}, want: dir + "/x/z\n"},
+		{name: "strip", o: options{missing: upath.LastMissing, strip: true}, files: []string{"l/../l"}, want: dir + "/l\n"},
+		{name: "strip missing dir", o: options{missing: upath.LastMissing, strip: true}, files: []string{"x/y"}, wantErr: true},
+		{name: "zero", o: options{missing: upath.LastMissing, zero: true}, files: []string{"d", "."}, want: dir + "/d\x00" + dir + "\x00"},
+		{name: "none", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := run(&stdout, &stderr, &tt.o, tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("realpath = %v, want error %v", err, tt.wantErr)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("realpath = %q, want %q", stdout.String(), tt.want)
+			}
+		})
+	}
+}
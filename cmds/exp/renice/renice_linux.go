@@ -0,0 +1,125 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// renice changes the scheduling priority of running processes.
+//
+// Synopsis:
+//
+//	renice [-n] PRIORITY [[-p] PID...] [-g PGRP...] [-u USER...]
+//
+// Description:
+//
+//	renice sets the niceness of each process, process group or user's
+//	processes to PRIORITY, which is between -20, the most favorable
+//	scheduling, and 19, the least. Only root may lower it.
+//
+// Options:
+//
+//	-p: the IDs that follow are process IDs (the default)
+//	-g: the IDs that follow are process group IDs
+//	-u: the IDs that follow are user names or IDs
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/u-root/u-root/pkg/nss"
+	"golang.org/x/sys/unix"
+)
+
+var errUsage = errors.New("usage: renice [-n] PRIORITY [[-p] PID...] [-g PGRP...] [-u USER...]")
+
+// target is a process, process group or user to renice.
+type target struct {
+	which int
+	who   int
+	name  string
+}
+
+var kinds = map[int]string{
+	unix.PRIO_PROCESS: "process ID",
+	unix.PRIO_PGRP:    "process group ID",
+	unix.PRIO_USER:    "user ID",
+}
+
+func parse(args []string) (int, []target, error) {
+	if len(args) > 0 && (args[0] == "-n" || args[0] == "--priority") {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return 0, nil, errUsage
+	}
+	prio, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid priority %q", args[0])
+	}
+	which := unix.PRIO_PROCESS
+	var targets []target
+	for _, a := range args[1:] {
+		switch a {
+		case "-p", "--pid":
+			which = unix.PRIO_PROCESS
+			continue
+		case "-g", "--pgrp":
+			which = unix.PRIO_PGRP
+			continue
+		case "-u", "--user":
+			which = unix.PRIO_USER
+			continue
+		}
+		id, err := strconv.Atoi(a)
+		if err != nil && which == unix.PRIO_USER {
+			var u *nss.Passwd
+			if u, err = nss.LookupUser(a); err == nil {
+				id = int(u.UID)
+			}
+		}
+		if err != nil || id < 0 {
+			return 0, nil, fmt.Errorf("invalid %s %q", kinds[which], a)
+		}
+		targets = append(targets, target{which: which, who: id, name: a})
+	}
+	if len(targets) == 0 {
+		return 0, nil, errUsage
+	}
+	return prio, targets, nil
+}
+
+// renice sets the niceness of the targets to prio, and prints the old and
+// new.
+func renice(w io.Writer, prio int, targets []target) error {
+	var failed bool
+	for _, t := range targets {
+		old, err := unix.Getpriority(t.which, t.who)
+		if err == nil {
+			err = unix.Setpriority(t.which, t.who, prio)
+		}
+		if err != nil {
+			log.Printf("renice: failed to set priority for %s (%s): %v", t.name, kinds[t.which], err)
+			failed = true
+			continue
+		}
+		// Getpriority returns 20 - niceness.
+		fmt.Fprintf(w, "%s (%s) old priority %d, new priority %d\n", t.name, kinds[t.which], 20-old, prio)
+	}
+	if failed {
+		return errors.New("some priorities could not be set")
+	}
+	return nil
+}
+
+func main() {
+	prio, targets, err := parse(os.Args[1:])
+	if err != nil {
+		log.Fatalf("renice: %v", err)
+	}
+	if err := renice(os.Stdout, prio, targets); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParse(t *testing.T) {
+	prio, targets, err := parse([]string{"-n", "5", "1", "2", "-g", "3", "-u", "0", "-p", "4"})
+	want := []target{
+		{unix.PRIO_PROCESS, 1, "1"},
+		{unix.PRIO_PROCESS, 2, "2"},
+		{unix.PRIO_PGRP, 3, "3"},
+		{unix.PRIO_USER, 0, "0"},
+		{unix.PRIO_PROCESS, 4, "4"},
+	}
+	if err != nil || prio != 5 || !reflect.DeepEqual(targets, want) {
+		t.Errorf("parse = %d, %v, %v, want 5, %v", prio, targets, err, want)
+	}
+	for _, args := range [][]string{
+		{"5"},
+		{"x", "1"},
+		{"5", "-p"},
+		{"5", "-p", "abc"},
+		{"5", "-u", "no such user"},
+	} {
+		if _, _, err := parse(args); err == nil {
+			t.Errorf("parse(%q) succeeded", args)
+		}
+	}
+}
+
+func TestRenice(t *testing.T) {
+	c := exec.Command("sleep", "10")
+	if err := c.Start(); err != nil {
+		t.Skipf("cannot run sleep: %v", err)
+	}
+	defer c.Wait()
+	defer c.Process.Kill()
+
+	pid := c.Process.Pid
+	var b bytes.Buffer
+	if err := renice(&b, 19, []target{{unix.PRIO_PROCESS, pid, fmt.Sprint(pid)}}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := unix.Getpriority(unix.PRIO_PROCESS, pid); err != nil || 20-p != 19 {
+		t.Errorf("niceness = %d, %v, want 19", 20-p, err)
+	}
+	if want := "new priority 19\n"; !strings.HasSuffix(b.String(), want) {
+		t.Errorf("renice printed %q, want %q at the end", b.String(), want)
+	}
+
+	if err := renice(&b, 0, []target{{unix.PRIO_PROCESS, 1 << 30, "nonexistent"}}); err == nil {
+		t.Errorf("renice of a missing process succeeded")
+	}
+}
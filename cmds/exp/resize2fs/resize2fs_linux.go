@@ -0,0 +1,260 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// resize2fs grows an ext4 file system.
+//
+// Synopsis:
+//
+//	resize2fs DEVICE [SIZE]
+//
+// Description:
+//
+//	The ext4 file system on DEVICE, a block device or an image file, is
+//	grown to SIZE, or to the size of DEVICE, e.g. after growpart grew its
+//	partition. Unlike resize2fs of e2fsprogs, the kernel does the resize:
+//	file systems that are mounted are grown where they are mounted, and
+//	others are mounted on a temporary directory to grow them, and
+//	unmounted. File systems cannot be shrunk.
+//
+//	SIZE is in blocks of the file system, or in 512 byte sectors with an s
+//	suffix, or in KiB, MiB, GiB or TiB with a K, M, G or T suffix.
+//
+// Example:
+//
+//	growpart /dev/nvme0n1 3 && resize2fs /dev/nvme0n1p3
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/mount/loop"
+	"golang.org/x/sys/unix"
+)
+
+// ext4IOCResizeFS is EXT4_IOC_RESIZE_FS, _IOW('f', 16, __u64).
+const ext4IOCResizeFS = 0x40086610
+
+var errUsage = errors.New("usage: resize2fs DEVICE [SIZE]")
+
+// superblock is what resize2fs needs of the superblock of an ext2, ext3 or
+// ext4 file system.
+type superblock struct {
+	blockSize uint64
+	blocks    uint64
+	is64Bit   bool
+}
+
+// readSuperblock reads the superblock of the file system in r.
+func readSuperblock(r io.ReaderAt) (*superblock, error) {
+	b := make([]byte, 1024)
+	if _, err := r.ReadAt(b, 1024); err != nil {
+		return nil, fmt.Errorf("reading the superblock: %v", err)
+	}
+	if binary.LittleEndian.Uint16(b[0x38:]) != 0xef53 {
+		return nil, errors.New("no ext2, ext3 or ext4 file system")
+	}
+	logSize := binary.LittleEndian.Uint32(b[0x18:])
+	if logSize > 6 {
+		return nil, fmt.Errorf("invalid block size 2^%d KiB", logSize)
+	}
+	sb := &superblock{
+		blockSize: 1024 << logSize,
+		blocks:    uint64(binary.LittleEndian.Uint32(b[0x04:])),
+		is64Bit:   binary.LittleEndian.Uint32(b[0x60:])&0x80 != 0,
+	}
+	if sb.is64Bit {
+		sb.blocks |= uint64(binary.LittleEndian.Uint32(b[0x150:])) << 32
+	}
+	return sb, nil
+}
+
+// parseSize returns the number of blocks of blockSize bytes of s: blocks, or
+// sectors with an s suffix, or KiB, MiB, GiB or TiB with a K, M, G or T
+// suffix.
+func parseSize(s string, blockSize uint64) (uint64, error) {
+	unit := blockSize
+	if i := strings.IndexAny(s, "sKMGT"); i >= 0 && i == len(s)-1 {
+		unit = map[byte]uint64{'s': 512, 'K': 1 << 10, 'M': 1 << 20, 'G': 1 << 30, 'T': 1 << 40}[s[i]]
+		s = s[:i]
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit / blockSize, nil
+}
+
+// deviceSize returns the size of the block device or file open in f.
+func deviceSize(f *os.File) (uint64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if fi.Mode().IsRegular() {
+		return uint64(fi.Size()), nil
+	}
+	var size uint64
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), unix.BLKGETSIZE64, uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0, os.NewSyscallError("ioctl(BLKGETSIZE64)", errno)
+	}
+	return size, nil
+}
+
+// findMount returns where the block device rdev is mounted, or "" if it is
+// not.
+func findMount(mounts []*mount.MountInfo, rdev uint64) string {
+	dev := fmt.Sprintf("%d:%d", unix.Major(rdev), unix.Minor(rdev))
+	for _, m := range mounts {
+		if m.Dev == dev {
+			return m.Path
+		}
+	}
+	return ""
+}
+
+// resize has the kernel grow the file system mounted at path to blocks
+// blocks.
+func resize(path string, blocks uint64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), ext4IOCResizeFS, uintptr(unsafe.Pointer(&blocks))); errno != 0 {
+		return os.NewSyscallError("ioctl(EXT4_IOC_RESIZE_FS)", errno)
+	}
+	return nil
+}
+
+// resizeUnmounted mounts the file system on dev, an image file if image, on
+// a temporary directory, grows it to blocks blocks, and unmounts it.
+func resizeUnmounted(dev string, image bool, blocks uint64) (err error) {
+	dir, err := os.MkdirTemp("", "resize2fs-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dir)
+
+	var m mount.Mounter = &mountDev{dev}
+	if image {
+		l, err := loop.New(dev, "ext4", "")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if lerr := l.Free(); err == nil {
+				err = lerr
+			}
+		}()
+		m = l
+	}
+	mp, err := m.Mount(dir, 0)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := mp.Unmount(0); err == nil {
+			err = uerr
+		}
+	}()
+	return resize(dir, blocks)
+}
+
+// mountDev mounts the ext4 file system of a block device.
+type mountDev struct {
+	dev string
+}
+
+func (m *mountDev) DevName() string {
+	return m.dev
+}
+
+func (m *mountDev) Mount(path string, flags uintptr, opts ...func() error) (*mount.MountPoint, error) {
+	return mount.Mount(m.dev, path, "ext4", "", flags, opts...)
+}
+
+func run(w io.Writer, dev string, size string) error {
+	f, err := os.Open(dev)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sb, err := readSuperblock(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", dev, err)
+	}
+	var blocks uint64
+	if size != "" {
+		if blocks, err = parseSize(size, sb.blockSize); err != nil {
+			return err
+		}
+	} else {
+		n, err := deviceSize(f)
+		if err != nil {
+			return err
+		}
+		blocks = n / sb.blockSize
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	k := sb.blockSize / 1024
+	switch {
+	case blocks == sb.blocks:
+		fmt.Fprintf(w, "The filesystem is already %d (%dk) blocks long.  Nothing to do!\n", blocks, k)
+		return nil
+	case blocks < sb.blocks:
+		return fmt.Errorf("%s is %d blocks long: shrinking it to %d blocks is not supported", dev, sb.blocks, blocks)
+	case !sb.is64Bit && blocks > 1<<32-1:
+		return fmt.Errorf("%d blocks is too large for a file system without the 64bit feature", blocks)
+	}
+
+	var mountpoint string
+	if fi.Mode()&os.ModeDevice != 0 {
+		mounts, err := mount.ReadMountInfo()
+		if err != nil {
+			return err
+		}
+		mountpoint = findMount(mounts, uint64(fi.Sys().(*unix.Stat_t).Rdev))
+	}
+	if mountpoint != "" {
+		fmt.Fprintf(w, "Filesystem at %s is mounted on %s; on-line resizing required\n", dev, mountpoint)
+		err = resize(mountpoint, blocks)
+	} else {
+		err = resizeUnmounted(dev, fi.Mode().IsRegular(), blocks)
+	}
+	if err != nil {
+		return fmt.Errorf("resizing %s: %v", dev, err)
+	}
+	fmt.Fprintf(w, "The filesystem on %s is now %d (%dk) blocks long.\n", dev, blocks, k)
+	return nil
+}
+
+func main() {
+	log.SetPrefix("resize2fs: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		log.Fatal(errUsage)
+	}
+	if err := run(os.Stdout, flag.Arg(0), flag.Arg(1)); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount"
+	"golang.org/x/sys/unix"
+)
+
+// writeImage writes an image of size bytes with the superblock of an ext4
+// file system of blocks blocks of 4 KiB.
+func writeImage(t *testing.T, size, blocks uint64, is64Bit bool) string {
+	t.Helper()
+	sb := make([]byte, 1024)
+	binary.LittleEndian.PutUint32(sb[0x04:], uint32(blocks))
+	binary.LittleEndian.PutUint32(sb[0x18:], 2)
+	binary.LittleEndian.PutUint16(sb[0x38:], 0xef53)
+	if is64Bit {
+		binary.LittleEndian.PutUint32(sb[0x60:], 0x80)
+		binary.LittleEndian.PutUint32(sb[0x150:], uint32(blocks>>32))
+	}
+	path := filepath.Join(t.TempDir(), "fs.img")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(int64(size)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(sb, 1024); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadSuperblock(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		blocks  uint64
+		is64Bit bool
+	}{
+		{name: "32 bit", blocks: 1 << 20},
+		{name: "64 bit", blocks: 1<<32 + 5, is64Bit: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(writeImage(t, 1<<20, tt.blocks, tt.is64Bit))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			sb, err := readSuperblock(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (superblock{blockSize: 4096, blocks: tt.blocks, is64Bit: tt.is64Bit}); *sb != want {
+				t.Errorf("readSuperblock = %+v, want %+v", *sb, want)
+			}
+		})
+	}
+
+	if _, err := readSuperblock(bytes.NewReader(make([]byte, 4096))); err == nil {
+		t.Errorf("readSuperblock(zeros) = nil, want error")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    uint64
+		wantErr bool
+	}{
+		{in: "1000", want: 1000},
+		{in: "16s", want: 2},
+		{in: "8K", want: 2},
+		{in: "1M", want: 256},
+		{in: "2G", want: 512 << 10},
+		{in: "1T", want: 256 << 20},
+		{in: "G", wantErr: true},
+		{in: "1.5G", wantErr: true},
+		{in: "10x", wantErr: true},
+	} {
+		got, err := parseSize(tt.in, 4096)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseSize(%q) = %d, %v, want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFindMount(t *testing.T) {
+	mounts := []*mount.MountInfo{
+		{Dev: "0:22", Path: "/"},
+		{Dev: "259:3", Path: "/data"},
+	}
+	if got := findMount(mounts, unix.Mkdev(259, 3)); got != "/data" {
+		t.Errorf("findMount(259:3) = %q, want /data", got)
+	}
+	if got := findMount(mounts, unix.Mkdev(259, 4)); got != "" {
+		t.Errorf("findMount(259:4) = %q, want \"\"", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	img := writeImage(t, 4<<20, 1024, false)
+	var out bytes.Buffer
+	if err := run(&out, img, ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := "The filesystem is already 1024 (4k) blocks long.  Nothing to do!\n"; out.String() != want {
+		t.Errorf("run = %q, want %q", out.String(), want)
+	}
+	if err := run(&out, img, "2M"); err == nil {
+		t.Errorf("run to shrink = nil, want error")
+	}
+	if err := run(&out, img, "20T"); err == nil {
+		t.Errorf("run to more than 2^32 blocks without 64bit = nil, want error")
+	}
+}
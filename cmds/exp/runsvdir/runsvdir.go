@@ -0,0 +1,100 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+// runsvdir supervises a directory of services, like runit's runsvdir.
+//
+// Synopsis:
+//
+//	runsvdir [-s SOCKET] [-scan DURATION] [-stop-timeout DURATION] [DIR]
+//
+// Description:
+//
+//	Every subdirectory of DIR, /etc/service by default, with an executable
+//	run file is a service. run is started in its directory, and restarted a
+//	second after it exits. A service with a down file is not started until
+//	it is brought up with sv.
+//
+//	DIR is scanned every -scan: new services are started, and removed
+//	services are stopped with SIGTERM, and SIGKILL after -stop-timeout.
+//
+//	Services are controlled with sv, over the UNIX socket SOCKET.
+//
+//	On SIGTERM or SIGINT, all services are stopped and runsvdir exits.
+//
+// Example:
+//
+//	mkdir -p /etc/service/sshd
+//	printf '#!/bin/sh\nexec sshd -D\n' > /etc/service/sshd/run
+//	chmod +x /etc/service/sshd/run
+//	runsvdir &
+//	sv status sshd
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/u-root/u-root/pkg/svdir"
+)
+
+var (
+	socket      = flag.String("s", svdir.DefaultSocket, "control socket")
+	scan        = flag.Duration("scan", svdir.DefaultScanInterval, "how often to scan the directory for services")
+	stopTimeout = flag.Duration("stop-timeout", svdir.DefaultStopTimeout, "how long services have to exit after SIGTERM before they are killed")
+)
+
+func run(dir string) error {
+	s := svdir.New(dir)
+	s.ScanInterval = *scan
+	s.StopTimeout = *stopTimeout
+	s.Log = log.Default()
+
+	// A socket left by a runsvdir that died is in the way.
+	if c, err := net.Dial("unix", *socket); err == nil {
+		c.Close()
+		return fmt.Errorf("%s is already served", *socket)
+	}
+	os.Remove(*socket)
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(*socket)
+	defer l.Close()
+	go func() {
+		if err := s.Serve(l); err != nil {
+			log.Print(err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	return s.Run(ctx)
+}
+
+func main() {
+	log.SetPrefix("runsvdir: ")
+	flag.Parse()
+	dir := svdir.DefaultDir
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		dir = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(dir); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// rusage runs a command and reports the resources it used, in JSON.
+//
+// Synopsis:
+//
+//	rusage [-o FILE] COMMAND [ARG]...
+//
+// Description:
+//
+//	rusage runs COMMAND and, when it exits, writes to stderr, or FILE, a
+//	JSON object of its exit status, the wall clock, user and system time
+//	it took, its maximum resident set size, page faults, context switches
+//	and block IO, as wait4 reports them, and its IO counters from
+//	/proc/PID/io, e.g. to profile provisioning steps in CI and in the
+//	field. The resources are of COMMAND and the processes it waited for.
+//
+//	The IO counters are left out if the kernel has no task IO accounting.
+//
+//	rusage exits with the exit status of COMMAND, or 128 plus the number
+//	of the signal that killed it.
+//
+// Options:
+//
+//	-o: write the report to FILE, appending to it
+//
+// Example:
+//
+//	$ rusage -o /var/log/provision.json ./provision
+//	$ rusage gzip -9 initramfs.cpio
+//	{
+//	  "command": ["gzip", "-9", "initramfs.cpio"],
+//	  "exit_code": 0,
+//	  "real_seconds": 1.218,
+//	  "user_seconds": 1.176,
+//	  "system_seconds": 0.04,
+//	  "max_rss_kb": 2932,
+//	  ...
+//	}
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"golang.org/x/sys/unix"
+)
+
+var output = flag.StringP("output", "o", "", "write the report to `FILE`, appending to it")
+
+var errUsage = errors.New("usage: rusage [-o FILE] COMMAND [ARG]...")
+
+// ioCounters are the counters of /proc/PID/io.
+type ioCounters struct {
+	RChar               uint64 `json:"rchar"`
+	WChar               uint64 `json:"wchar"`
+	SyscR               uint64 `json:"syscr"`
+	SyscW               uint64 `json:"syscw"`
+	ReadBytes           uint64 `json:"read_bytes"`
+	WriteBytes          uint64 `json:"write_bytes"`
+	CancelledWriteBytes uint64 `json:"cancelled_write_bytes"`
+}
+
+// parseIO parses the contents of /proc/PID/io.
+func parseIO(b []byte) (*ioCounters, error) {
+	var c ioCounters
+	fields := map[string]*uint64{
+		"rchar":                 &c.RChar,
+		"wchar":                 &c.WChar,
+		"syscr":                 &c.SyscR,
+		"syscw":                 &c.SyscW,
+		"read_bytes":            &c.ReadBytes,
+		"write_bytes":           &c.WriteBytes,
+		"cancelled_write_bytes": &c.CancelledWriteBytes,
+	}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			return nil, fmt.Errorf("bad IO counter line %q", s.Text())
+		}
+		p, ok := fields[k]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("IO counter %s: %v", k, err)
+		}
+		*p = n
+	}
+	return &c, s.Err()
+}
+
+// report is the resources a command used.
+type report struct {
+	Command  []string `json:"command"`
+	ExitCode int      `json:"exit_code"`
+	Signal   string   `json:"signal,omitempty"`
+
+	RealSeconds   float64 `json:"real_seconds"`
+	UserSeconds   float64 `json:"user_seconds"`
+	SystemSeconds float64 `json:"system_seconds"`
+
+	MaxRSSKB                   int64 `json:"max_rss_kb"`
+	MinorFaults                int64 `json:"minor_faults"`
+	MajorFaults                int64 `json:"major_faults"`
+	VoluntaryContextSwitches   int64 `json:"voluntary_context_switches"`
+	InvoluntaryContextSwitches int64 `json:"involuntary_context_switches"`
+	BlockInput                 int64 `json:"block_input"`
+	BlockOutput                int64 `json:"block_output"`
+
+	IO *ioCounters `json:"io,omitempty"`
+}
+
+// measure runs c and reports the resources it used.
+func measure(c *exec.Cmd) (*report, error) {
+	start := time.Now()
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	pid := c.Process.Pid
+
+	// Wait for the command to exit, but leave it a zombie until its IO
+	// counters are read.
+	var info unix.Siginfo
+	for {
+		err := unix.Waitid(unix.P_PID, pid, &info, unix.WEXITED|unix.WNOWAIT, nil)
+		if err == nil {
+			break
+		}
+		if err != unix.EINTR {
+			c.Process.Kill()
+			c.Wait()
+			return nil, fmt.Errorf("waiting for %s: %v", c.Path, err)
+		}
+	}
+	wall := time.Since(start)
+	var ioc *ioCounters
+	if b, err := os.ReadFile(fmt.Sprintf("/proc/%d/io", pid)); err == nil {
+		ioc, _ = parseIO(b)
+	}
+
+	if err := c.Wait(); err != nil {
+		var ee *exec.ExitError
+		if !errors.As(err, &ee) {
+			return nil, err
+		}
+	}
+	r := &report{
+		Command:     c.Args,
+		RealSeconds: wall.Seconds(),
+		IO:          ioc,
+	}
+	ws := c.ProcessState.Sys().(syscall.WaitStatus)
+	switch {
+	case ws.Signaled():
+		r.ExitCode = 128 + int(ws.Signal())
+		r.Signal = unix.SignalName(ws.Signal())
+	default:
+		r.ExitCode = ws.ExitStatus()
+	}
+	if ru, ok := c.ProcessState.SysUsage().(*syscall.Rusage); ok {
+		r.UserSeconds = time.Duration(ru.Utime.Nano()).Seconds()
+		r.SystemSeconds = time.Duration(ru.Stime.Nano()).Seconds()
+		r.MaxRSSKB = int64(ru.Maxrss)
+		r.MinorFaults = int64(ru.Minflt)
+		r.MajorFaults = int64(ru.Majflt)
+		r.VoluntaryContextSwitches = int64(ru.Nvcsw)
+		r.InvoluntaryContextSwitches = int64(ru.Nivcsw)
+		r.BlockInput = int64(ru.Inblock)
+		r.BlockOutput = int64(ru.Oublock)
+	}
+	return r, nil
+}
+
+func writeReport(w io.Writer, r *report) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
+// run runs args and writes the report, and returns the exit status of the
+// command.
+func run(stdin io.Reader, stdout, stderr io.Writer, args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, errUsage
+	}
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = stdin, stdout, stderr
+
+	// Signals from the terminal are for the command, which gets them
+	// too; rusage waits to report how it exited.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGQUIT)
+	defer signal.Stop(sigs)
+
+	r, err := measure(c)
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return 127, err
+	}
+	if err != nil {
+		return 126, err
+	}
+
+	w := stderr
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return r.ExitCode, err
+		}
+		defer f.Close()
+		w = f
+	}
+	return r.ExitCode, writeReport(w, r)
+}
+
+func main() {
+	log.SetPrefix("rusage: ")
+	log.SetFlags(0)
+	flag.CommandLine.SetInterspersed(false)
+	flag.Parse()
+	code, err := run(os.Stdin, os.Stdout, os.Stderr, flag.Args())
+	if err != nil {
+		log.Print(err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func TestParseIO(t *testing.T) {
+	const io = `rchar: 3914
+wchar: 1185
+syscr: 11
+syscw: 5
+read_bytes: 4096
+write_bytes: 8192
+cancelled_write_bytes: 0
+`
+	got, err := parseIO([]byte(io))
+	want := &ioCounters{RChar: 3914, WChar: 1185, SyscR: 11, SyscW: 5, ReadBytes: 4096, WriteBytes: 8192}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseIO = %+v, %v, want %+v", got, err, want)
+	}
+	for _, bad := range []string{"rchar 1\n", "rchar: x\n"} {
+		if _, err := parseIO([]byte(bad)); err == nil {
+			t.Errorf("parseIO(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	for _, tt := range []struct {
+		script string
+		code   int
+		signal string
+	}{
+		{script: "head -c 100000 /dev/zero >/dev/null; exit 3", code: 3},
+		{script: "kill -TERM $$", code: 128 + 15, signal: "SIGTERM"},
+	} {
+		r, err := measure(exec.Command("sh", "-c", tt.script))
+		if err != nil {
+			t.Fatalf("%q: %v", tt.script, err)
+		}
+		if r.ExitCode != tt.code || r.Signal != tt.signal {
+			t.Errorf("%q: exit %d, signal %q, want %d, %q", tt.script, r.ExitCode, r.Signal, tt.code, tt.signal)
+		}
+		if r.RealSeconds <= 0 || r.MaxRSSKB <= 0 {
+			t.Errorf("%q: real %v, max RSS %v, want them positive", tt.script, r.RealSeconds, r.MaxRSSKB)
+		}
+		// Reads of what the shell waited for are counted too.
+		if tt.code == 3 && r.IO != nil && r.IO.RChar < 100000 {
+			t.Errorf("%q: read %d bytes, want at least 100000", tt.script, r.IO.RChar)
+		}
+	}
+
+	if _, err := measure(exec.Command("/nonexistent")); err == nil {
+		t.Errorf("measure of a nonexistent command succeeded")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	f := filepath.Join(t.TempDir(), "report.json")
+	c := testutil.Command(t, "-o", f, "sh", "-c", "echo hi; exit 5")
+	out, err := c.Output()
+	if err := testutil.IsExitCode(err, 5); err != nil {
+		t.Error(err)
+	}
+	if string(out) != "hi\n" {
+		t.Errorf("output is %q, want hi", out)
+	}
+	b, err := os.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r report
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatalf("report %q: %v", b, err)
+	}
+	if want := []string{"sh", "-c", "echo hi; exit 5"}; !reflect.DeepEqual(r.Command, want) || r.ExitCode != 5 {
+		t.Errorf("report is of %q exiting %d, want %q exiting 5", r.Command, r.ExitCode, want)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{args: nil, code: 1},
+		{args: []string{"/nonexistent"}, code: 127},
+	} {
+		if err := testutil.IsExitCode(testutil.Command(t, tt.args...).Run(), tt.code); err != nil {
+			t.Errorf("rusage %q: %v", tt.args, err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// sedutil provisions, locks, unlocks and erases TCG Opal self-encrypting
+// drives.
+//
+// Synopsis:
+//
+//	sedutil [-p PASSWORD] [-lr N] [-user N] [-ro] COMMAND DEVICE
+//
+// Description:
+//
+//	COMMAND is one of:
+//
+//	setup:       take ownership of a new or reverted drive, activate
+//	             its locking SP, and enable locking of range LR, all
+//	             with PASSWORD
+//	lock:        lock range LR
+//	unlock:      unlock range LR, and re-read the partition table
+//	erase:       erase range LR by changing its encryption key
+//	revert:      revert the drive to its factory state with the SID
+//	             PASSWORD, losing all data on it
+//	psid-revert: revert the drive to its factory state with the PSID
+//	             printed on its label, as PASSWORD, losing all data on it
+//
+//	PASSWORD is read from the first line of stdin, if -p is not given.
+//	It is given to the drive as it is: passwords set by sedutil-cli,
+//	which hashes them, do not work.
+//
+// Options:
+//
+//	-p:    password
+//	-lr:   locking range (default 0, the global range)
+//	-user: user to lock or unlock as (default 0, Admin1)
+//	-ro:   unlock read-only
+//
+// Example:
+//
+//	sedutil -p secret setup /dev/nvme0n1
+//	sedutil -p secret unlock /dev/nvme0n1
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/mount/opal"
+)
+
+var (
+	password = flag.String("p", "", "password, read from stdin if not given")
+	lr       = flag.Uint("lr", 0, "locking range")
+	user     = flag.Uint("user", 0, "user to lock or unlock as, 0 for Admin1")
+	readOnly = flag.Bool("ro", false, "unlock read-only")
+)
+
+// drive is the part of an opal.Device sedutil uses.
+type drive interface {
+	TakeOwnership(password []byte) error
+	ActivateLockingSP(password []byte, ranges ...uint8) error
+	SetupRange(password []byte, r opal.Range) error
+	LockUnlock(who opal.User, password []byte, lr uint8, state opal.LockState) error
+	EraseRange(password []byte, lr uint8) error
+	RevertTPer(password []byte) error
+	PSIDRevert(psid []byte) error
+}
+
+// options are the options of a command.
+type options struct {
+	password []byte
+	lr       uint8
+	user     opal.User
+	readOnly bool
+}
+
+// command runs the command cmd on d.
+func command(d drive, cmd string, o *options) error {
+	switch cmd {
+	case "setup":
+		if err := d.TakeOwnership(o.password); err != nil {
+			return err
+		}
+		if err := d.ActivateLockingSP(o.password, o.lr); err != nil {
+			return err
+		}
+		return d.SetupRange(o.password, opal.Range{LR: o.lr, ReadLock: true, WriteLock: true})
+	case "lock":
+		return d.LockUnlock(o.user, o.password, o.lr, opal.Locked)
+	case "unlock":
+		state := opal.ReadWrite
+		if o.readOnly {
+			state = opal.ReadOnly
+		}
+		return d.LockUnlock(o.user, o.password, o.lr, state)
+	case "erase":
+		return d.EraseRange(o.password, o.lr)
+	case "revert":
+		return d.RevertTPer(o.password)
+	case "psid-revert":
+		return d.PSIDRevert(o.password)
+	}
+	return fmt.Errorf("unknown command %q", cmd)
+}
+
+// readPassword reads a password from the first line of r.
+func readPassword(r io.Reader) ([]byte, error) {
+	s, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	s = strings.TrimRight(s, "\r\n")
+	if s == "" {
+		return nil, errors.New("no password")
+	}
+	return []byte(s), nil
+}
+
+func run(cmd, dev string) error {
+	if *lr > 255 || *user > 255 {
+		return fmt.Errorf("locking range %d or user %d is too large", *lr, *user)
+	}
+	o := &options{
+		password: []byte(*password),
+		lr:       uint8(*lr),
+		user:     opal.User(*user),
+		readOnly: *readOnly,
+	}
+	if *password == "" {
+		p, err := readPassword(os.Stdin)
+		if err != nil {
+			return err
+		}
+		o.password = p
+	}
+
+	d, err := opal.Open(dev)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := command(d, cmd, o); err != nil {
+		return err
+	}
+	if cmd != "unlock" {
+		return nil
+	}
+	// The partitions of a locked drive could not be read.
+	bd, err := block.Device(dev)
+	if err != nil {
+		return err
+	}
+	return bd.ReadPartitionTable()
+}
+
+func main() {
+	log.SetPrefix("sedutil: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: sedutil [-p PASSWORD] [-lr N] [-user N] [-ro] setup|lock|unlock|erase|revert|psid-revert DEVICE\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), flag.Arg(1)); err != nil {
+		log.Fatal(err)
+	}
+}
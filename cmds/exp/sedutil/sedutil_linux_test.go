@@ -0,0 +1,107 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/opal"
+)
+
+// fakeDrive records the calls to it.
+type fakeDrive struct {
+	calls []string
+}
+
+func (d *fakeDrive) call(format string, v ...interface{}) error {
+	d.calls = append(d.calls, fmt.Sprintf(format, v...))
+	return nil
+}
+
+func (d *fakeDrive) TakeOwnership(password []byte) error {
+	return d.call("take ownership %s", password)
+}
+
+func (d *fakeDrive) ActivateLockingSP(password []byte, ranges ...uint8) error {
+	return d.call("activate %s %v", password, ranges)
+}
+
+func (d *fakeDrive) SetupRange(password []byte, r opal.Range) error {
+	return d.call("setup %s %+v", password, r)
+}
+
+func (d *fakeDrive) LockUnlock(who opal.User, password []byte, lr uint8, state opal.LockState) error {
+	return d.call("lock unlock %d %s %d %d", who, password, lr, state)
+}
+
+func (d *fakeDrive) EraseRange(password []byte, lr uint8) error {
+	return d.call("erase %s %d", password, lr)
+}
+
+func (d *fakeDrive) RevertTPer(password []byte) error {
+	return d.call("revert %s", password)
+}
+
+func (d *fakeDrive) PSIDRevert(psid []byte) error {
+	return d.call("psid revert %s", psid)
+}
+
+func TestCommand(t *testing.T) {
+	for _, tt := range []struct {
+		cmd     string
+		o       options
+		want    []string
+		wantErr bool
+	}{
+		{
+			cmd: "setup",
+			o:   options{password: []byte("pw")},
+			want: []string{
+				"take ownership pw",
+				"activate pw [0]",
+				"setup pw {LR:0 Start:0 Length:0 ReadLock:true WriteLock:true}",
+			},
+		},
+		{cmd: "lock", o: options{password: []byte("pw"), lr: 1}, want: []string{"lock unlock 0 pw 1 4"}},
+		{cmd: "unlock", o: options{password: []byte("pw"), user: 2}, want: []string{"lock unlock 2 pw 0 2"}},
+		{cmd: "unlock", o: options{password: []byte("pw"), readOnly: true}, want: []string{"lock unlock 0 pw 0 1"}},
+		{cmd: "erase", o: options{password: []byte("pw"), lr: 3}, want: []string{"erase pw 3"}},
+		{cmd: "revert", o: options{password: []byte("pw")}, want: []string{"revert pw"}},
+		{cmd: "psid-revert", o: options{password: []byte("PSID")}, want: []string{"psid revert PSID"}},
+		{cmd: "format", wantErr: true},
+	} {
+		t.Run(tt.cmd, func(t *testing.T) {
+			d := &fakeDrive{}
+			if err := command(d, tt.cmd, &tt.o); (err != nil) != tt.wantErr {
+				t.Fatalf("command = %v, want error %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(d.calls, tt.want) {
+				t.Errorf("calls = %q, want %q", d.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "secret\n", want: "secret"},
+		{in: "secret\r\nmore\n", want: "secret"},
+		{in: "secret", want: "secret"},
+		{in: "\n", wantErr: true},
+		{in: "", wantErr: true},
+	} {
+		got, err := readPassword(strings.NewReader(tt.in))
+		if (err != nil) != tt.wantErr || string(got) != tt.want {
+			t.Errorf("readPassword(%q) = %q, %v, want %q, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// selftest checks that a booted image works, e.g. as a smoke test after
+// every image build and on first boot in the field.
+//
+// Synopsis:
+//
+//	selftest [-c CONFIG] [-f FORMAT] [-o FILE]
+//
+// Description:
+//
+//	selftest runs the checks of CONFIG, by default /etc/selftest.json, one
+//	after another: required commands are in $PATH, network addresses are
+//	reachable, the TPM answers, the clock is sane and block devices are
+//	visible. See package selftest for the config. Without the default
+//	config, only the clock is checked.
+//
+//	The report is in the Test Anything Protocol, or JSON. selftest exits
+//	with status 1 if a check fails.
+//
+// Options:
+//
+//	-c: the config (default /etc/selftest.json)
+//	-f: the report FORMAT, tap or json (default tap)
+//	-o: write the report to FILE instead of stdout
+//
+// Example:
+//
+//	$ selftest
+//	TAP version 13
+//	1..2
+//	ok 1 - command ip
+//	  ---
+//	  detail: "/bbin/ip"
+//	  duration_ms: 0
+//	  ...
+//	not ok 2 - storage nvme*
+//	  ---
+//	  message: "no block device with media matches"
+//	  duration_ms: 0
+//	  ...
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/u-root/u-root/pkg/selftest"
+)
+
+var (
+	config = flag.String("c", selftest.DefaultConfigPath, "the `CONFIG`")
+	format = flag.String("f", "tap", "the report `FORMAT`, tap or json")
+	output = flag.String("o", "", "write the report to `FILE` instead of stdout")
+
+	errUsage  = errors.New("usage: selftest [-c CONFIG] [-f tap|json] [-o FILE]")
+	errFailed = errors.New("a check failed")
+)
+
+// readConfig reads path. The default config may be absent.
+func readConfig(path string) (*selftest.Config, error) {
+	c, err := selftest.ReadConfig(path)
+	if errors.Is(err, os.ErrNotExist) && path == selftest.DefaultConfigPath {
+		return &selftest.DefaultConfig, nil
+	}
+	return c, err
+}
+
+func run(out io.Writer, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	write := selftest.WriteTAP
+	switch *format {
+	case "tap":
+	case "json":
+		write = selftest.WriteJSON
+	default:
+		return fmt.Errorf("unknown format %q: %w", *format, errUsage)
+	}
+	c, err := readConfig(*config)
+	if err != nil {
+		return err
+	}
+	checks, err := c.Checks()
+	if err != nil {
+		return err
+	}
+	results := selftest.Run(context.Background(), checks)
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		err = write(f, results)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	} else if err := write(out, results); err != nil {
+		return err
+	}
+	if !selftest.Passed(results) {
+		return errFailed
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("selftest: ")
+	log.SetFlags(0)
+	flag.Parse()
+	switch err := run(os.Stdout, flag.Args()); {
+	case errors.Is(err, errFailed):
+		os.Exit(1)
+	case err != nil:
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	defer func(c, f, o string) { *config, *format, *output = c, f, o }(*config, *format, *output)
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	if err := os.WriteFile(filepath.Join(dir, "present"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	good := filepath.Join(dir, "good.json")
+	if err := os.WriteFile(good, []byte(`{"commands": ["present"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"commands": ["present", "absent"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		config  string
+		format  string
+		args    []string
+		want    string
+		wantErr error
+	}{
+		{desc: "args", config: good, format: "tap", args: []string{"x"}, wantErr: errUsage},
+		{desc: "format", config: good, format: "xml", wantErr: errUsage},
+		{desc: "no config", config: filepath.Join(dir, "none.json"), format: "tap", wantErr: os.ErrNotExist},
+		{desc: "pass", config: good, format: "tap", want: "TAP version 13\n1..1\nok 1 - command present\n"},
+		{desc: "fail", config: bad, format: "tap", want: "not ok 2 - command absent\n", wantErr: errFailed},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			*config, *format = tt.config, tt.format
+			var out bytes.Buffer
+			err := run(&out, tt.args)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("run = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("run wrote\n%s\nwant it to contain\n%s", out.String(), tt.want)
+			}
+		})
+	}
+
+	// A JSON report to a file.
+	*config, *format, *output = bad, "json", filepath.Join(dir, "report.json")
+	if err := run(&bytes.Buffer{}, nil); err != errFailed {
+		t.Fatalf("run = %v, want %v", err, errFailed)
+	}
+	b, err := os.ReadFile(*output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r struct {
+		OK     bool
+		Passed int
+		Failed int
+	}
+	if err := json.Unmarshal(b, &r); err != nil || r.OK || r.Passed != 1 || r.Failed != 1 {
+		t.Errorf("report %s = %+v, %v, want 1 passed and 1 failed", b, r, err)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// sha1sum prints or checks SHA-1 sums.
+//
+// Synopsis:
+//
+//	sha1sum [OPTIONS] [FILE...]
+//	sha1sum -c [OPTIONS] [MANIFEST...]
+//
+// Description:
+//
+//	Without -c, the sums of the FILEs, or of stdin, are printed as
+//	"SUM  FILE" lines. With -c, the files listed in the MANIFESTs are
+//	checked, and sha1sum exits with 1 if any of them does not match.
+//	Files are hashed in parallel.
+//
+// Options:
+//
+//	-c, --check:      check the files listed in the manifests
+//	--ignore-missing: with -c, do not fail for missing files
+//	--quiet:          with -c, do not print OK files
+//	--status:         with -c, print nothing; the exit status tells
+//	--strict:         with -c, fail for improperly formatted lines
+//	-w, --warn:       with -c, warn about improperly formatted lines
+//	--tag:            print sums in the BSD format
+//	-j N:             hash N files at once
+package main
+
+import (
+	"crypto/sha1"
+
+	"github.com/u-root/u-root/pkg/hashsum"
+)
+
+func main() {
+	hashsum.Main("sha1sum", sha1.New)
+}
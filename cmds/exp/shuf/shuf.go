@@ -0,0 +1,185 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// shuf prints its input lines in random order.
+//
+// Synopsis:
+//
+//	shuf [-n COUNT] [-r] [-z] [-o FILE] [FILE]
+//	shuf [-n COUNT] [-r] [-z] [-o FILE] -e [ARG]...
+//	shuf [-n COUNT] [-r] [-z] [-o FILE] -i LO-HI
+//
+// Description:
+//
+//	shuf prints a random permutation of the lines of FILE, or stdin if it
+//	is missing or "-", of its arguments with -e, or of the numbers from LO
+//	to HI with -i.
+//
+// Options:
+//
+//	-e: shuffle the arguments
+//	-i: shuffle the numbers from LO to HI
+//	-n: print at most COUNT lines
+//	-r: print lines chosen at random with repetition, forever unless -n
+//	    is given
+//	-o: write to FILE rather than stdout
+//	-z: lines end with NUL, not newline
+package main
+
+import (
+	"bufio"
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var errUsage = errors.New("usage: shuf [-n COUNT] [-r] [-z] [-o FILE] [FILE | -e [ARG]... | -i LO-HI]")
+
+type params struct {
+	echo    bool
+	rng     string
+	count   int64
+	repeat  bool
+	output  string
+	zero    bool
+	countOK bool
+}
+
+// lines returns the lines to shuffle.
+func lines(stdin io.Reader, p params, args []string) ([]string, error) {
+	switch {
+	case p.echo:
+		if p.rng != "" {
+			return nil, errUsage
+		}
+		return args, nil
+	case p.rng != "":
+		if len(args) > 0 {
+			return nil, errUsage
+		}
+		lo, hi, ok := strings.Cut(p.rng, "-")
+		l, err1 := strconv.ParseUint(lo, 10, 64)
+		h, err2 := strconv.ParseUint(hi, 10, 64)
+		if !ok || err1 != nil || err2 != nil || l > h+1 || h+1-l > 1<<24 {
+			return nil, fmt.Errorf("invalid input range %q", p.rng)
+		}
+		var out []string
+		for i := l; i <= h && i >= l; i++ {
+			out = append(out, strconv.FormatUint(i, 10))
+		}
+		return out, nil
+	}
+
+	if len(args) > 1 {
+		return nil, errUsage
+	}
+	r := stdin
+	if len(args) == 1 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sep := "\n"
+	if p.zero {
+		sep = "\x00"
+	}
+	s := strings.TrimSuffix(string(b), sep)
+	if s == "" {
+		return nil, nil
+	}
+	return strings.Split(s, sep), nil
+}
+
+func shuf(w io.Writer, r *rand.Rand, p params, l []string) error {
+	end := "\n"
+	if p.zero {
+		end = "\x00"
+	}
+	bw := bufio.NewWriter(w)
+	if p.repeat {
+		if len(l) == 0 {
+			if p.countOK && p.count == 0 {
+				return nil
+			}
+			return errors.New("no lines to repeat")
+		}
+		for i := int64(0); !p.countOK || i < p.count; i++ {
+			if _, err := bw.WriteString(l[r.Intn(len(l))] + end); err != nil {
+				return err
+			}
+		}
+		return bw.Flush()
+	}
+
+	n := len(l)
+	if p.countOK && p.count < int64(n) {
+		n = int(p.count)
+	}
+	// Only the first n need be shuffled.
+	for i := 0; i < n; i++ {
+		j := i + r.Intn(len(l)-i)
+		l[i], l[j] = l[j], l[i]
+		bw.WriteString(l[i] + end)
+	}
+	return bw.Flush()
+}
+
+func newRand() *rand.Rand {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		log.Fatalf("shuf: %v", err)
+	}
+	return rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(b[:]))))
+}
+
+func main() {
+	var p params
+	flag.BoolVar(&p.echo, "e", false, "shuffle the arguments")
+	flag.StringVar(&p.rng, "i", "", "shuffle the numbers from `LO-HI`")
+	flag.Int64Var(&p.count, "n", 0, "print at most `COUNT` lines")
+	flag.BoolVar(&p.repeat, "r", false, "choose lines with repetition")
+	flag.StringVar(&p.output, "o", "", "write to `FILE` rather than stdout")
+	flag.BoolVar(&p.zero, "z", false, "lines end with NUL, not newline")
+	flag.Parse()
+	flag.Visit(func(f *flag.Flag) {
+		p.countOK = p.countOK || f.Name == "n"
+	})
+	if p.count < 0 {
+		log.Fatalf("shuf: invalid line count %d", p.count)
+	}
+
+	l, err := lines(os.Stdin, p, flag.Args())
+	if err != nil {
+		log.Fatalf("shuf: %v", err)
+	}
+	// With -o, the input may be the output, which is only written now.
+	w := os.Stdout
+	if p.output != "" {
+		if w, err = os.Create(p.output); err != nil {
+			log.Fatalf("shuf: %v", err)
+		}
+	}
+	err = shuf(w, newRand(), p, l)
+	if cerr := w.Close(); err == nil && p.output != "" {
+		err = cerr
+	}
+	if err != nil {
+		log.Fatalf("shuf: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		p     params
+		stdin string
+		args  []string
+		want  []string
+	}{
+		{name: "stdin", stdin: "a\nb\nc\n", want: []string{"a", "b", "c"}},
+		{name: "no newline", stdin: "a\nb", args: []string{"-"}, want: []string{"a", "b"}},
+		{name: "empty", stdin: ""},
+		{name: "zero", p: params{zero: true}, stdin: "a b\x00c\nd\x00", want: []string{"a b", "c\nd"}},
+		{name: "echo", p: params{echo: true}, args: []string{"x", "y"}, want: []string{"x", "y"}},
+		{name: "range", p: params{rng: "3-6"}, want: []string{"3", "4", "5", "6"}},
+		{name: "empty range", p: params{rng: "3-2"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lines(strings.NewReader(tt.stdin), tt.p, tt.args)
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+	for _, tt := range []struct {
+		p    params
+		args []string
+	}{
+		{params{rng: "1-"}, nil},
+		{params{rng: "5-1"}, nil},
+		{params{rng: "1-2"}, []string{"x"}},
+		{params{echo: true, rng: "1-2"}, nil},
+		{params{}, []string{"a", "b"}},
+		{params{}, []string{"/no/such/file"}},
+	} {
+		if _, err := lines(nil, tt.p, tt.args); err == nil {
+			t.Errorf("lines(%+v, %q) succeeded", tt.p, tt.args)
+		}
+	}
+}
+
+func TestShuf(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	in := []string{"1", "2", "3", "4", "5", "6", "7", "8"}
+
+	var b bytes.Buffer
+	if err := shuf(&b, r, params{}, append([]string{}, in...)); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, in) {
+		t.Errorf("shuf printed %q, want a permutation of %q", b.String(), in)
+	}
+
+	b.Reset()
+	if err := shuf(&b, r, params{count: 3, countOK: true, zero: true}, append([]string{}, in...)); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(b.String(), "\x00"); n != 3 || strings.Contains(b.String(), "\n") {
+		t.Errorf("shuf -z -n 3 printed %q, want 3 lines", b.String())
+	}
+
+	b.Reset()
+	if err := shuf(&b, r, params{count: 20, countOK: true, repeat: true}, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 40 || strings.Trim(b.String(), "ab\n") != "" {
+		t.Errorf("shuf -r -n 20 printed %q, want 20 lines of a or b", b.String())
+	}
+	if err := shuf(&b, r, params{repeat: true}, nil); err == nil {
+		t.Errorf("shuf -r of nothing succeeded")
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/u-root/u-root/pkg/pty"
+	"github.com/u-root/u-root/pkg/termios"
+)
+
+// address is one end of a relay, e.g. TCP-LISTEN:8023,fork.
+type address struct {
+	typ  string
+	addr string
+	opts map[string]string
+}
+
+// addrTypes are the address types, with the options they take.
+var addrTypes = map[string][]string{
+	"STDIO":       {"raw"},
+	"TCP":         nil,
+	"TCP-LISTEN":  {"fork"},
+	"UDP":         nil,
+	"UDP-LISTEN":  nil,
+	"UNIX":        nil,
+	"UNIX-LISTEN": {"fork"},
+	"PTY":         {"link", "raw"},
+	"FILE":        {"b"},
+}
+
+// parseAddress parses TYPE[:ADDR][,OPT[=VAL]]... A path is a FILE, and - is
+// STDIO. A FILE option bN sets the baud rate, e.g. /dev/ttyS0,b115200.
+func parseAddress(s string) (*address, error) {
+	parts := strings.Split(s, ",")
+	a := &address{opts: map[string]string{}}
+	switch {
+	case parts[0] == "-":
+		a.typ = "STDIO"
+	case strings.HasPrefix(parts[0], "/") || strings.HasPrefix(parts[0], "."):
+		a.typ, a.addr = "FILE", parts[0]
+	default:
+		a.typ, a.addr, _ = strings.Cut(parts[0], ":")
+		a.typ = strings.ToUpper(a.typ)
+	}
+	switch a.typ {
+	case "UNIX-CONNECT":
+		a.typ = "UNIX"
+	case "OPEN":
+		a.typ = "FILE"
+	}
+	opts, ok := addrTypes[a.typ]
+	if !ok {
+		return nil, fmt.Errorf("%q: unknown address type %q", s, a.typ)
+	}
+	if hasAddr := a.typ != "STDIO" && a.typ != "PTY"; hasAddr != (a.addr != "") {
+		if hasAddr {
+			return nil, fmt.Errorf("%q: %s needs an address", s, a.typ)
+		}
+		return nil, fmt.Errorf("%q: %s takes no address", s, a.typ)
+	}
+	for _, o := range parts[1:] {
+		k, v, _ := strings.Cut(o, "=")
+		// Baud rates are b9600 and so on, as in socat.
+		if a.typ == "FILE" && strings.HasPrefix(k, "b") && v == "" {
+			k, v = "b", k[1:]
+			if _, err := strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("%q: invalid baud rate %q", s, v)
+			}
+		}
+		if !contains(opts, k) {
+			return nil, fmt.Errorf("%q: %s has no option %q", s, a.typ, k)
+		}
+		a.opts[k] = v
+	}
+	if a.typ == "TCP-LISTEN" || a.typ == "UDP-LISTEN" {
+		// A port alone listens on all addresses.
+		if !strings.Contains(a.addr, ":") {
+			a.addr = ":" + a.addr
+		}
+	}
+	return a, nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, t := range ss {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// String implements fmt.Stringer.
+func (a *address) String() string {
+	if a.addr == "" {
+		return a.typ
+	}
+	return a.typ + ":" + a.addr
+}
+
+// listens returns whether a waits for a peer to connect.
+func (a *address) listens() bool {
+	return strings.HasSuffix(a.typ, "-LISTEN")
+}
+
+// fork returns whether a serves each peer that connects, not just the first.
+func (a *address) fork() bool {
+	_, ok := a.opts["fork"]
+	return ok
+}
+
+// listener accepts the peers of an address.
+type listener interface {
+	Accept() (io.ReadWriteCloser, error)
+	Close() error
+}
+
+// netListener is a listener of a net.Listener.
+type netListener struct {
+	net.Listener
+}
+
+// Accept implements listener.Accept.
+func (l netListener) Accept() (io.ReadWriteCloser, error) {
+	return l.Listener.Accept()
+}
+
+// listen starts listening on a, which listens.
+func (a *address) listen() (listener, error) {
+	switch a.typ {
+	case "TCP-LISTEN":
+		l, err := net.Listen("tcp", a.addr)
+		return netListener{l}, err
+	case "UNIX-LISTEN":
+		l, err := net.Listen("unix", a.addr)
+		return netListener{l}, err
+	case "UDP-LISTEN":
+		c, err := net.ListenPacket("udp", a.addr)
+		if err != nil {
+			return nil, err
+		}
+		return &udpListener{c: c}, nil
+	}
+	return nil, fmt.Errorf("%v does not listen", a)
+}
+
+// udpListener accepts the first peer to send a datagram.
+type udpListener struct {
+	c net.PacketConn
+}
+
+// Accept implements listener.Accept.
+func (l *udpListener) Accept() (io.ReadWriteCloser, error) {
+	b := make([]byte, 64*1024)
+	n, peer, err := l.c.ReadFrom(b)
+	if err != nil {
+		return nil, err
+	}
+	return &udpPeer{PacketConn: l.c, peer: peer, first: b[:n]}, nil
+}
+
+// Close implements listener.Close. The peer it accepted keeps the
+// connection open.
+func (l *udpListener) Close() error {
+	return nil
+}
+
+// udpPeer exchanges datagrams with one peer, and drops those of others.
+type udpPeer struct {
+	net.PacketConn
+	peer  net.Addr
+	first []byte
+}
+
+// Read implements io.Reader.
+func (p *udpPeer) Read(b []byte) (int, error) {
+	if p.first != nil {
+		n := copy(b, p.first)
+		p.first = nil
+		return n, nil
+	}
+	for {
+		n, from, err := p.ReadFrom(b)
+		if err != nil || from.String() == p.peer.String() {
+			return n, err
+		}
+	}
+}
+
+// RemoteAddr returns the address of the peer.
+func (p *udpPeer) RemoteAddr() net.Addr {
+	return p.peer
+}
+
+// Write implements io.Writer.
+func (p *udpPeer) Write(b []byte) (int, error) {
+	return p.WriteTo(b, p.peer)
+}
+
+// peer returns who c is connected to, for logs.
+func peer(c io.ReadWriteCloser) interface{} {
+	if r, ok := c.(interface{ RemoteAddr() net.Addr }); ok {
+		return r.RemoteAddr()
+	}
+	return c
+}
+
+// open opens a, which does not listen.
+func (a *address) open() (io.ReadWriteCloser, error) {
+	switch a.typ {
+	case "STDIO":
+		return openStdio(a)
+	case "TCP":
+		return net.Dial("tcp", a.addr)
+	case "UDP":
+		return net.Dial("udp", a.addr)
+	case "UNIX":
+		return net.Dial("unix", a.addr)
+	case "PTY":
+		return openPTY(a)
+	case "FILE":
+		return openFile(a)
+	}
+	return nil, fmt.Errorf("%v listens", a)
+}
+
+// stdio is stdin and stdout.
+type stdio struct {
+	restore func() error
+}
+
+func openStdio(a *address) (io.ReadWriteCloser, error) {
+	s := &stdio{restore: func() error { return nil }}
+	if _, ok := a.opts["raw"]; ok && termios.IsTerminal(os.Stdin.Fd()) {
+		r, err := termios.SetMode(os.Stdin.Fd(), termios.MakeRaw)
+		if err != nil {
+			return nil, err
+		}
+		s.restore = r.Restore
+	}
+	return s, nil
+}
+
+// Read implements io.Reader.
+func (s *stdio) Read(b []byte) (int, error) {
+	return os.Stdin.Read(b)
+}
+
+// Write implements io.Writer.
+func (s *stdio) Write(b []byte) (int, error) {
+	return os.Stdout.Write(b)
+}
+
+// Close implements io.Closer. It leaves stdin and stdout open, but restores
+// the terminal mode.
+func (s *stdio) Close() error {
+	return s.restore()
+}
+
+// ptyEnd is the master of a new pseudo terminal. It keeps the slave open, so
+// that reads wait for whoever opens the slave next instead of failing when
+// the last one closes it.
+type ptyEnd struct {
+	*os.File
+	pts  *os.File
+	link string
+}
+
+func openPTY(a *address) (io.ReadWriteCloser, error) {
+	ptm, pts, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+	p := &ptyEnd{File: ptm, pts: pts}
+	if _, ok := a.opts["raw"]; ok {
+		t, err := termios.GetTermios(pts.Fd())
+		if err == nil {
+			err = termios.SetTermios(pts.Fd(), termios.MakeRaw(t))
+		}
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+	if link := a.opts["link"]; link != "" {
+		os.Remove(link)
+		if err := os.Symlink(pts.Name(), link); err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.link = link
+	}
+	log.Printf("PTY is %s", pts.Name())
+	return p, nil
+}
+
+// Close implements io.Closer.
+func (p *ptyEnd) Close() error {
+	if p.link != "" {
+		os.Remove(p.link)
+	}
+	p.pts.Close()
+	return p.File.Close()
+}
+
+// tty is a file that is a terminal, set to raw mode until it is closed.
+type tty struct {
+	*os.File
+	r *termios.Restorer
+}
+
+func openFile(a *address) (io.ReadWriteCloser, error) {
+	f, err := os.OpenFile(a.addr, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	baud, hasBaud := a.opts["b"]
+	if !termios.IsTerminal(f.Fd()) {
+		if hasBaud {
+			f.Close()
+			return nil, fmt.Errorf("%s: baud rate of a file that is not a terminal", a.addr)
+		}
+		return f, nil
+	}
+	// Serial lines carry bytes as they are.
+	t, err := termios.GetTermios(f.Fd())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	raw := termios.MakeRaw(t)
+	if hasBaud {
+		b, _ := strconv.Atoi(baud)
+		if raw, err = termios.MakeSerialBaud(raw, b); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	r, err := termios.SetMode(f.Fd(), func(*termios.Termios) *termios.Termios { return raw })
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &tty{File: f, r: r}, nil
+}
+
+// Close implements io.Closer.
+func (t *tty) Close() error {
+	err := t.r.Restore()
+	if cerr := t.File.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
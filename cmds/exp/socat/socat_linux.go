@@ -0,0 +1,210 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// socat relays data both ways between two addresses, e.g. to bridge a serial
+// console to the network.
+//
+// Synopsis:
+//
+//	socat [-v] [-t TIMEOUT] ADDRESS ADDRESS
+//
+// Description:
+//
+//	An address is TYPE[:ADDR][,OPTION]...:
+//	  STDIO or -            stdin and stdout; option raw sets a terminal
+//	                        to raw mode
+//	  TCP:HOST:PORT         connect to a TCP port
+//	  TCP-LISTEN:[HOST:]PORT
+//	                        wait for a TCP connection
+//	  UDP:HOST:PORT         send datagrams to a UDP port
+//	  UDP-LISTEN:[HOST:]PORT
+//	                        exchange datagrams with the first peer to send one
+//	  UNIX:PATH             connect to a UNIX socket
+//	  UNIX-LISTEN:PATH      wait for a connection on a UNIX socket
+//	  PTY                   a new pseudo terminal; option link=PATH links
+//	                        PATH to it, and raw sets it to raw mode
+//	  FILE:PATH or PATH     a file or device; a terminal, e.g. a serial
+//	                        port, is set to raw mode, and option bBAUD sets
+//	                        its baud rate
+//
+//	With option fork, a TCP-LISTEN or UNIX-LISTEN address serves every
+//	connection, each relayed to a new instance of the other address, instead
+//	of only the first.
+//
+//	When one side reaches end of file, socat waits at most TIMEOUT for the
+//	other before it exits.
+//
+// Options:
+//
+//	-v: log connections
+//	-t: how long to wait for the other side after end of file (default 500ms)
+//
+// Example:
+//
+//	socat TCP-LISTEN:2323,fork /dev/ttyS1,b115200
+//	socat STDIO,raw TCP:10.0.0.1:2323
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"syscall"
+	"time"
+)
+
+var (
+	verbose = flag.Bool("v", false, "log connections")
+	timeout = flag.Duration("t", 500*time.Millisecond, "how long to wait for the other side after end of file")
+	vlog    = func(string, ...interface{}) {}
+)
+
+// closeWriter is implemented by connections that can be closed for writing
+// only, e.g. TCP and UNIX connections.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// relay copies a to b and b to a until one reaches end of file, and then
+// until the other does or timeout passes, and closes them.
+func relay(a, b io.ReadWriteCloser, timeout time.Duration) error {
+	done := make(chan error, 2)
+	cp := func(dst, src io.ReadWriteCloser) {
+		_, err := io.Copy(dst, src)
+		// A pseudo terminal fails with EIO once its other end is
+		// closed, which is its end of file.
+		if errors.Is(err, syscall.EIO) {
+			err = nil
+		}
+		if c, ok := dst.(closeWriter); ok {
+			c.CloseWrite()
+		}
+		done <- err
+	}
+	go cp(a, b)
+	go cp(b, a)
+
+	err := <-done
+	if err == nil {
+		select {
+		case err = <-done:
+		case <-time.After(timeout):
+		}
+	}
+	aerr, berr := a.Close(), b.Close()
+	for _, e := range []error{aerr, berr} {
+		if err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// serve relays the peers of l to new instances of other, one after another or,
+// with fork, all at the same time.
+func serve(l listener, other *address, fork bool) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		vlog("accepted %v", peer(c))
+		o, err := other.open()
+		if err != nil {
+			c.Close()
+			if !fork {
+				return err
+			}
+			log.Print(err)
+			continue
+		}
+		if !fork {
+			return relay(c, o, *timeout)
+		}
+		go func() {
+			if err := relay(c, o, *timeout); err != nil {
+				log.Print(err)
+			}
+			vlog("closed %v", peer(c))
+		}()
+	}
+}
+
+// run relays between the addresses a and b.
+func run(a, b *address) error {
+	if a.fork() && b.fork() {
+		return errors.New("only one address can fork")
+	}
+	// The address that forks, or else the first that listens, serves.
+	if b.fork() || (!a.listens() && b.listens()) {
+		a, b = b, a
+	}
+	if a.listens() {
+		l, err := a.listen()
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		vlog("listening on %v", a)
+		if !b.listens() {
+			return serve(l, b, a.fork())
+		}
+		// Both listen: wait for one peer on each.
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		l2, err := b.listen()
+		if err != nil {
+			c.Close()
+			return err
+		}
+		defer l2.Close()
+		c2, err := l2.Accept()
+		if err != nil {
+			c.Close()
+			return err
+		}
+		return relay(c, c2, *timeout)
+	}
+
+	ca, err := a.open()
+	if err != nil {
+		return err
+	}
+	cb, err := b.open()
+	if err != nil {
+		ca.Close()
+		return err
+	}
+	return relay(ca, cb, *timeout)
+}
+
+func main() {
+	log.SetPrefix("socat: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: socat [-v] [-t TIMEOUT] ADDRESS ADDRESS\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if *verbose {
+		vlog = log.Printf
+	}
+	var addrs [2]*address
+	for i, s := range flag.Args() {
+		a, err := parseAddress(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		addrs[i] = a
+	}
+	if err := run(addrs[0], addrs[1]); err != nil {
+		log.Fatal(err)
+	}
+}
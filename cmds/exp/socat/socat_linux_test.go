@@ -0,0 +1,182 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAddress(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want *address
+	}{
+		{"-", &address{typ: "STDIO", opts: map[string]string{}}},
+		{"stdio,raw", &address{typ: "STDIO", opts: map[string]string{"raw": ""}}},
+		{"TCP:10.0.0.1:23", &address{typ: "TCP", addr: "10.0.0.1:23", opts: map[string]string{}}},
+		{"tcp-listen:2323,fork", &address{typ: "TCP-LISTEN", addr: ":2323", opts: map[string]string{"fork": ""}}},
+		{"UDP-LISTEN:127.0.0.1:514", &address{typ: "UDP-LISTEN", addr: "127.0.0.1:514", opts: map[string]string{}}},
+		{"UNIX-CONNECT:/run/console.sock", &address{typ: "UNIX", addr: "/run/console.sock", opts: map[string]string{}}},
+		{"PTY,link=/dev/vcon,raw", &address{typ: "PTY", opts: map[string]string{"link": "/dev/vcon", "raw": ""}}},
+		{"/dev/ttyS0,b115200", &address{typ: "FILE", addr: "/dev/ttyS0", opts: map[string]string{"b": "115200"}}},
+		{"OPEN:log.txt", &address{typ: "FILE", addr: "log.txt", opts: map[string]string{}}},
+	} {
+		got, err := parseAddress(tt.in)
+		if err != nil {
+			t.Errorf("parseAddress(%q) = %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAddress(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{
+		"SCTP:10.0.0.1:23",
+		"TCP",
+		"PTY:/dev/pts/3",
+		"TCP:10.0.0.1:23,fork",
+		"/dev/ttyS0,bfast",
+		"UDP-LISTEN:514,fork",
+	} {
+		if _, err := parseAddress(bad); err == nil {
+			t.Errorf("parseAddress(%q) = nil, want error", bad)
+		}
+	}
+}
+
+// echo serves UNIX connections on path, and writes back what they send.
+func echo(t *testing.T, path string) {
+	t.Helper()
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+}
+
+func TestServeFork(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "echo.sock")
+	echo(t, sock)
+
+	a, err := parseAddress("TCP-LISTEN:127.0.0.1:0,fork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := a.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	other, err := parseAddress("UNIX:" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serve(l, other, true)
+
+	// Two connections at the same time are relayed each on its own.
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", l.(netListener).Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		conns = append(conns, c)
+	}
+	for i, c := range conns {
+		msg := []string{"first\n", "second\n"}[i]
+		if _, err := io.WriteString(c, msg); err != nil {
+			t.Fatal(err)
+		}
+		c.SetDeadline(time.Now().Add(10 * time.Second))
+		got, err := bufio.NewReader(c).ReadString('\n')
+		if err != nil || got != msg {
+			t.Errorf("connection %d got %q, %v, want %q", i, got, err, msg)
+		}
+	}
+}
+
+func TestRelay(t *testing.T) {
+	a, a2 := net.Pipe()
+	b, b2 := net.Pipe()
+	done := make(chan error)
+	go func() { done <- relay(a, b, time.Second) }()
+
+	go io.WriteString(a2, "ping")
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(b2, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("relayed %q, %v, want %q", buf, err, "ping")
+	}
+	go io.WriteString(b2, "pong")
+	if _, err := io.ReadFull(a2, buf); err != nil || string(buf) != "pong" {
+		t.Fatalf("relayed %q, %v, want %q", buf, err, "pong")
+	}
+
+	// Once one side closes, the relay waits at most the timeout for the
+	// other.
+	a2.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("relay = %v, want nil", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("relay did not return after one side closed")
+	}
+}
+
+func TestPTY(t *testing.T) {
+	link := filepath.Join(t.TempDir(), "console")
+	a, err := parseAddress("PTY,raw,link=" + link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := a.open()
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skipf("No pseudo terminals here: %v", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	f, err := os.OpenFile(link, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := io.WriteString(f, "hello\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 7)
+	if _, err := io.ReadFull(p, buf); err != nil || string(buf) != "hello\r\n" {
+		t.Errorf("read %q, %v, want %q", buf, err, "hello\r\n")
+	}
+
+	p.Close()
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("link %s remains after close", link)
+	}
+}
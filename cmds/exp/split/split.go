@@ -0,0 +1,297 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// split splits a file into pieces.
+//
+// Synopsis:
+//
+//	split [-b SIZE | -l LINES | -n CHUNKS] [-a LEN] [-d | -x]
+//		[-additional-suffix SUFFIX] [-verbose] [FILE [PREFIX]]
+//
+// Description:
+//
+//	FILE, or stdin if it is missing or "-", is written to PREFIXaa,
+//	PREFIXab and so on. PREFIX defaults to "x". Without -b, -l or -n, the
+//	pieces have 1000 lines each. The pieces can be put together again with
+//	cat PREFIX*.
+//
+//	SIZE may have a unit suffix such as K, M or G, or KB, MB or GB for
+//	powers of 1000.
+//
+// Options:
+//
+//	-b: put SIZE bytes in each piece
+//	-l: put LINES lines in each piece
+//	-n: split into CHUNKS pieces of the same size; FILE must be a regular
+//	    file
+//	-a: use suffixes of LEN characters (default 2)
+//	-d: use numeric suffixes
+//	-x: use hexadecimal suffixes
+//	-additional-suffix: append SUFFIX to the file names
+//	-verbose: print the name of each piece before it is written
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/rck/unit"
+)
+
+var errUsage = errors.New("usage: split [-b SIZE | -l LINES | -n CHUNKS] [-a LEN] [-d | -x] [-additional-suffix SUFFIX] [-verbose] [FILE [PREFIX]]")
+
+type params struct {
+	bytes     int64
+	lines     int64
+	chunks    int64
+	suffixLen int
+	numeric   bool
+	hex       bool
+	suffix    string
+	verbose   bool
+}
+
+// names generates the names of the pieces.
+type names struct {
+	prefix, suffix string
+	digits         string
+	// n is the index of each digit of the suffix.
+	n []int
+	// started is set after the first name.
+	started bool
+}
+
+func newNames(p params, prefix string) *names {
+	digits := "abcdefghijklmnopqrstuvwxyz"
+	switch {
+	case p.numeric:
+		digits = "0123456789"
+	case p.hex:
+		digits = "0123456789abcdef"
+	}
+	return &names{prefix: prefix, suffix: p.suffix, digits: digits, n: make([]int, p.suffixLen)}
+}
+
+func (n *names) next() (string, error) {
+	if n.started {
+		i := len(n.n) - 1
+		for ; i >= 0; i-- {
+			if n.n[i]++; n.n[i] < len(n.digits) {
+				break
+			}
+			n.n[i] = 0
+		}
+		if i < 0 {
+			return "", errors.New("output file suffixes exhausted")
+		}
+	}
+	n.started = true
+	b := make([]byte, len(n.n))
+	for i, d := range n.n {
+		b[i] = n.digits[d]
+	}
+	return n.prefix + string(b) + n.suffix, nil
+}
+
+// splitter writes pieces of the input to the files that names names.
+type splitter struct {
+	names   *names
+	verbose io.Writer
+	f       *os.File
+	w       *bufio.Writer
+}
+
+func (s *splitter) open() error {
+	if err := s.close(); err != nil {
+		return err
+	}
+	name, err := s.names.next()
+	if err != nil {
+		return err
+	}
+	if s.verbose != nil {
+		fmt.Fprintf(s.verbose, "creating file '%s'\n", name)
+	}
+	if s.f, err = os.Create(name); err != nil {
+		return err
+	}
+	s.w = bufio.NewWriter(s.f)
+	return nil
+}
+
+func (s *splitter) close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.w.Flush()
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	s.f = nil
+	return err
+}
+
+// splitBytes writes pieces of n bytes.
+func (s *splitter) splitBytes(r io.Reader, n int64) error {
+	buf := make([]byte, 32*1024)
+	for {
+		// Only create a piece once there is data for it.
+		m, err := io.ReadFull(r, buf[:1])
+		if m == 0 {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := s.open(); err != nil {
+			return err
+		}
+		if _, err := s.w.Write(buf[:1]); err != nil {
+			return err
+		}
+		if _, err := io.CopyBuffer(s.w, io.LimitReader(r, n-1), buf); err != nil {
+			return err
+		}
+	}
+}
+
+// splitLines writes pieces of n lines.
+func (s *splitter) splitLines(r io.Reader, n int64) error {
+	br := bufio.NewReader(r)
+	var lines int64
+	for {
+		line, err := br.ReadSlice('\n')
+		if len(line) > 0 {
+			if s.f == nil || lines == n {
+				if err := s.open(); err != nil {
+					return err
+				}
+				lines = 0
+			}
+			if _, err := s.w.Write(line); err != nil {
+				return err
+			}
+			if line[len(line)-1] == '\n' {
+				lines++
+			}
+		}
+		switch err {
+		case nil, bufio.ErrBufferFull:
+		case io.EOF:
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// splitChunks writes n pieces of the same size, the last one taking the
+// remainder. All n pieces are written, even if some are empty.
+func (s *splitter) splitChunks(f *os.File, n int64) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("-n needs a regular file, not %s", f.Name())
+	}
+	size := fi.Size()
+	for i := int64(0); i < n; i++ {
+		if err := s.open(); err != nil {
+			return err
+		}
+		l := size / n
+		if i == n-1 {
+			l = size - l*(n-1)
+		}
+		if _, err := io.CopyN(s.w, f, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func run(stdin io.Reader, stdout io.Writer, p params, args []string) error {
+	if len(args) > 2 || p.suffixLen < 1 || p.numeric && p.hex {
+		return errUsage
+	}
+	modes := 0
+	for _, v := range []int64{p.bytes, p.lines, p.chunks} {
+		if v < 0 {
+			return errUsage
+		}
+		if v > 0 {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("only one of -b, -l and -n may be given")
+	}
+	if modes == 0 {
+		p.lines = 1000
+	}
+
+	prefix := "x"
+	if len(args) == 2 {
+		prefix = args[1]
+	}
+	in := stdin
+	var file *os.File
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in, file = f, f
+	}
+
+	s := &splitter{names: newNames(p, prefix)}
+	if p.verbose {
+		s.verbose = stdout
+	}
+	var err error
+	switch {
+	case p.bytes > 0:
+		err = s.splitBytes(in, p.bytes)
+	case p.chunks > 0:
+		if file == nil {
+			return fmt.Errorf("-n cannot split stdin")
+		}
+		err = s.splitChunks(file, p.chunks)
+	default:
+		err = s.splitLines(in, p.lines)
+	}
+	if cerr := s.close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func main() {
+	var p params
+	size := unit.MustNewUnit(unit.DefaultUnits).MustNewValue(0, unit.None)
+	flag.Var(size, "b", "put `SIZE` bytes in each piece")
+	flag.Int64Var(&p.lines, "l", 0, "put this many lines in each piece")
+	flag.Int64Var(&p.chunks, "n", 0, "split into this many pieces of the same size")
+	flag.IntVar(&p.suffixLen, "a", 2, "length of the suffixes")
+	flag.BoolVar(&p.numeric, "d", false, "use numeric suffixes")
+	flag.BoolVar(&p.hex, "x", false, "use hexadecimal suffixes")
+	flag.StringVar(&p.suffix, "additional-suffix", "", "append this to the file names")
+	flag.BoolVar(&p.verbose, "verbose", false, "print the name of each piece")
+	flag.Parse()
+	if size.IsSet {
+		if p.bytes = size.Value; p.bytes <= 0 {
+			log.Fatalf("split: invalid size %v", size)
+		}
+	}
+	if err := run(os.Stdin, os.Stdout, p, flag.Args()); err != nil {
+		log.Fatalf("split: %v", err)
+	}
+}
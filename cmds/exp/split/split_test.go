@@ -0,0 +1,163 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// pieces returns the contents of the files in dir, by name.
+func pieces(t *testing.T, dir string) map[string]string {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]string{}
+	for _, e := range ents {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[e.Name()] = string(b)
+	}
+	return m
+}
+
+func TestSplit(t *testing.T) {
+	lines := "1\n2\n3\n4\n5"
+	for _, tt := range []struct {
+		name string
+		p    params
+		in   string
+		want map[string]string
+	}{
+		{
+			name: "lines",
+			p:    params{lines: 2},
+			in:   lines,
+			want: map[string]string{"xaa": "1\n2\n", "xab": "3\n4\n", "xac": "5"},
+		},
+		{
+			name: "default",
+			in:   lines,
+			want: map[string]string{"xaa": lines},
+		},
+		{
+			name: "bytes",
+			p:    params{bytes: 4},
+			in:   "0123456789",
+			want: map[string]string{"xaa": "0123", "xab": "4567", "xac": "89"},
+		},
+		{
+			name: "exact bytes",
+			p:    params{bytes: 5},
+			in:   "0123456789",
+			want: map[string]string{"xaa": "01234", "xab": "56789"},
+		},
+		{
+			name: "numeric suffixes",
+			p:    params{lines: 3, suffixLen: 3, numeric: true, suffix: ".txt"},
+			in:   lines,
+			want: map[string]string{"x000.txt": "1\n2\n3\n", "x001.txt": "4\n5"},
+		},
+		{
+			name: "empty",
+			p:    params{bytes: 4},
+			want: map[string]string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.p.suffixLen == 0 {
+				tt.p.suffixLen = 2
+			}
+			err := run(strings.NewReader(tt.in), nil, tt.p, []string{"-", filepath.Join(dir, "x")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := pieces(t, dir)
+			if len(got) != len(tt.want) {
+				t.Errorf("pieces = %q, want %q", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s = %q, want %q", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestChunks(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	if err := os.WriteFile(in, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	p := params{chunks: 3, suffixLen: 1, hex: true, verbose: true}
+	if err := run(nil, &out, p, []string{in, filepath.Join(dir, "p")}); err != nil {
+		t.Fatal(err)
+	}
+	got := pieces(t, dir)
+	for name, want := range map[string]string{"p0": "012", "p1": "345", "p2": "6789"} {
+		if got[name] != want {
+			t.Errorf("%s = %q, want %q", name, got[name], want)
+		}
+	}
+	if n := strings.Count(out.String(), "creating file"); n != 3 {
+		t.Errorf("verbose output = %q, want 3 files", out.String())
+	}
+
+	if err := run(strings.NewReader("x"), nil, p, nil); err == nil {
+		t.Errorf("-n of stdin succeeded")
+	}
+}
+
+func TestSuffixes(t *testing.T) {
+	n := newNames(params{suffixLen: 1, numeric: true}, "x")
+	var got []string
+	for {
+		name, err := n.next()
+		if err != nil {
+			break
+		}
+		got = append(got, name)
+	}
+	if len(got) != 10 || !sort.StringsAreSorted(got) || got[9] != "x9" {
+		t.Errorf("names = %q, want x0 to x9", got)
+	}
+
+	dir := t.TempDir()
+	err := run(strings.NewReader("0123"), nil, params{bytes: 1, suffixLen: 1, numeric: true}, []string{"-", filepath.Join(dir, "x")})
+	if err != nil {
+		t.Errorf("split into 4 of 10 pieces: %v", err)
+	}
+	err = run(strings.NewReader("01234567890"), nil, params{bytes: 1, suffixLen: 1, numeric: true}, []string{"-", filepath.Join(dir, "y")})
+	if err == nil || !strings.Contains(err.Error(), "exhausted") {
+		t.Errorf("split into 11 of 10 pieces = %v, want suffixes exhausted", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, p := range []params{
+		{suffixLen: 0},
+		{suffixLen: 2, numeric: true, hex: true},
+		{suffixLen: 2, lines: -1},
+		{suffixLen: 2, lines: 1, bytes: 1},
+	} {
+		if err := run(nil, nil, p, nil); err == nil {
+			t.Errorf("run(%+v) succeeded", p)
+		}
+	}
+	if err := run(nil, nil, params{suffixLen: 2}, []string{"a", "b", "c"}); err != errUsage {
+		t.Errorf("run with 3 args = %v, want %v", err, errUsage)
+	}
+}
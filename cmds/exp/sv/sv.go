@@ -0,0 +1,64 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+// sv controls the services supervised by runsvdir, like runit's sv.
+//
+// Synopsis:
+//
+//	sv [-s SOCKET] COMMAND [SERVICE]...
+//
+// Description:
+//
+//	The commands are:
+//	  status   print the status of the services, of all without SERVICE
+//	  up       start the services, and restart them when they exit
+//	  down     stop the services, with SIGTERM and then SIGKILL
+//	  once     start the services, but do not restart them
+//	  restart  restart the services with SIGTERM, and bring them up
+//	  pause cont hup alarm interrupt quit 1 2 term kill
+//	           send SIGSTOP, SIGCONT, SIGHUP, SIGALRM, SIGINT, SIGQUIT,
+//	           SIGUSR1, SIGUSR2, SIGTERM or SIGKILL to the services
+//
+//	The status of each service is printed afterwards, e.g.
+//	  run: sshd: (pid 123) 45s
+//	  down: getty: 3s, exit 1, normally up
+//
+//	sv fails if the command failed for any service.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/svdir"
+)
+
+var socket = flag.String("s", svdir.DefaultSocket, "control socket of runsvdir")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: sv [-s SOCKET] COMMAND [SERVICE]...\n\nCommands: %s\n", strings.Join(svdir.Commands(), " "))
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 || (flag.NArg() == 1 && flag.Arg(0) != "status") {
+		usage()
+	}
+	lines, err := svdir.Control(*socket, flag.Arg(0), flag.Args()[1:]...)
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+	if err != nil {
+		log.Fatalf("sv: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// swapoff stops swapping to files and partitions.
+//
+// Synopsis:
+//
+//	swapoff FILE...
+//	swapoff -a
+//
+// Description:
+//
+//	swapoff moves the pages in each FILE back into memory and stops
+//	using it as swap space.
+//
+// Options:
+//
+//	-a: stop swapping to all the swap space in /proc/swaps
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// unescape undoes the octal escapes of /proc/swaps.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func swapoff(path string) error {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	if _, _, e := unix.Syscall(unix.SYS_SWAPOFF, uintptr(unsafe.Pointer(p)), 0, 0); e != 0 {
+		return &os.PathError{Op: "swapoff", Path: path, Err: e}
+	}
+	return nil
+}
+
+// swaps returns the files in r, in the format of /proc/swaps.
+func swaps(r io.Reader) ([]string, error) {
+	var files []string
+	s := bufio.NewScanner(r)
+	s.Scan() // The heading.
+	for s.Scan() {
+		if f := strings.Fields(s.Text()); len(f) > 0 {
+			files = append(files, unescape(f[0]))
+		}
+	}
+	return files, s.Err()
+}
+
+func main() {
+	all := flag.Bool("a", false, "stop swapping to all the swap space in /proc/swaps")
+	flag.Parse()
+
+	files := flag.Args()
+	if *all {
+		f, err := os.Open("/proc/swaps")
+		if err != nil {
+			log.Fatalf("swapoff: %v", err)
+		}
+		files, err = swaps(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("swapoff: %v", err)
+		}
+	} else if len(files) == 0 {
+		log.Fatal(errors.New("usage: swapoff FILE... | swapoff -a"))
+	}
+	failed := false
+	for _, path := range files {
+		if err := swapoff(path); err != nil {
+			log.Printf("swapoff: %v", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSwaps(t *testing.T) {
+	files, err := swaps(strings.NewReader(`Filename				Type		Size		Used		Priority
+/dev/sda2                               partition	8388604		0		-2
+/swap\040file                           file		1048572		0		5
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/dev/sda2", "/swap file"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("swaps = %q, want %q", files, want)
+	}
+}
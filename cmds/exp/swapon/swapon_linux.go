@@ -0,0 +1,109 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// swapon starts swapping to files and partitions.
+//
+// Synopsis:
+//
+//	swapon [-d] [-p PRIORITY] FILE...
+//	swapon -s
+//
+// Description:
+//
+//	swapon starts swapping to each FILE, which must have been set up by
+//	mkswap. Swap space with a higher PRIORITY is used first.
+//
+// Options:
+//
+//	-d: discard freed swap pages
+//	-p: the priority, from 0 to 32767 (default chosen by the kernel)
+//	-s: print the swap space in use
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Flags of swapon(2).
+const (
+	swapFlagPrefer   = 0x8000
+	swapFlagPrioMask = 0x7fff
+	swapFlagDiscard  = 0x10000
+)
+
+// flags returns the swapon(2) flags for priority, which is negative for
+// none, and discard.
+func flags(priority int, discard bool) (uintptr, error) {
+	var f uintptr
+	if priority > swapFlagPrioMask {
+		return 0, fmt.Errorf("invalid priority %d", priority)
+	}
+	if priority >= 0 {
+		f |= swapFlagPrefer | uintptr(priority)&swapFlagPrioMask
+	}
+	if discard {
+		f |= swapFlagDiscard
+	}
+	return f, nil
+}
+
+func swapon(path string, flags uintptr) error {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	if _, _, e := unix.Syscall(unix.SYS_SWAPON, uintptr(unsafe.Pointer(p)), flags, 0); e != 0 {
+		return &os.PathError{Op: "swapon", Path: path, Err: e}
+	}
+	return nil
+}
+
+func summary(w io.Writer) error {
+	f, err := os.Open("/proc/swaps")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func main() {
+	discard := flag.Bool("d", false, "discard freed swap pages")
+	priority := flag.Int("p", -1, "the priority, from 0 to 32767 (default chosen by the kernel)")
+	s := flag.Bool("s", false, "print the swap space in use")
+	flag.Parse()
+
+	if *s {
+		if err := summary(os.Stdout); err != nil {
+			log.Fatalf("swapon: %v", err)
+		}
+		return
+	}
+	if flag.NArg() == 0 {
+		log.Fatal(errors.New("usage: swapon [-d] [-p PRIORITY] FILE... | swapon -s"))
+	}
+	f, err := flags(*priority, *discard)
+	if err != nil {
+		log.Fatalf("swapon: %v", err)
+	}
+	failed := false
+	for _, path := range flag.Args() {
+		if err := swapon(path, f); err != nil {
+			log.Printf("swapon: %v", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestFlags(t *testing.T) {
+	for _, tt := range []struct {
+		priority int
+		discard  bool
+		want     uintptr
+		wantErr  bool
+	}{
+		{priority: -1},
+		{priority: -1, discard: true, want: 0x10000},
+		{priority: 0, want: 0x8000},
+		{priority: 5, want: 0x8005},
+		{priority: 32767, discard: true, want: 0x1ffff},
+		{priority: 32768, wantErr: true},
+	} {
+		got, err := flags(tt.priority, tt.discard)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("flags(%d, %v) = %#x, %v, want %#x, error %v", tt.priority, tt.discard, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// sysinfo prints a summary of the machine.
+//
+// Synopsis:
+//
+//	sysinfo
+//
+// Description:
+//
+//	sysinfo prints the host name and kernel, the machine and its firmware,
+//	the CPU, the memory, the disks, the network interfaces with their MAC
+//	addresses and link state, and the TPMs, as far as /proc and /sys tell.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type sysinfo struct {
+	// root is where /proc and /sys are, for tests.
+	root string
+}
+
+// read returns the trimmed contents of a file, or "" if it cannot be read.
+func (s *sysinfo) read(path ...string) string {
+	b, err := os.ReadFile(filepath.Join(append([]string{s.root}, path...)...))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func (s *sysinfo) dir(path string) []string {
+	entries, err := os.ReadDir(filepath.Join(s.root, path))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// join joins the non-empty strings of s with spaces.
+func join(s ...string) string {
+	var l []string
+	for _, v := range s {
+		if v != "" {
+			l = append(l, v)
+		}
+	}
+	return strings.Join(l, " ")
+}
+
+// size formats a number of bytes.
+func size(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	f := float64(n)
+	i := -1
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", f, units[i])
+}
+
+func plural(n int, what string) string {
+	if n == 1 {
+		return "1 " + what
+	}
+	return fmt.Sprintf("%d %ss", n, what)
+}
+
+func (s *sysinfo) cpu() string {
+	f, err := os.Open(filepath.Join(s.root, "proc/cpuinfo"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	var model string
+	cpus := 0
+	cores := map[string]bool{}
+	var physical string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch strings.TrimSpace(k) {
+		case "processor":
+			cpus++
+		case "model name", "Model", "cpu model":
+			if model == "" {
+				model = v
+			}
+		case "physical id":
+			physical = v
+		case "core id":
+			cores[physical+"/"+v] = true
+		}
+	}
+	if cpus == 0 {
+		return model
+	}
+	desc := plural(cpus, "thread")
+	if len(cores) > 0 {
+		desc = plural(len(cores), "core") + ", " + desc
+	}
+	if model == "" {
+		return desc
+	}
+	return model + ", " + desc
+}
+
+func (s *sysinfo) memory() string {
+	for _, l := range strings.Split(s.read("proc/meminfo"), "\n") {
+		f := strings.Fields(l)
+		if len(f) >= 2 && f[0] == "MemTotal:" {
+			if kib, err := strconv.ParseUint(f[1], 10, 64); err == nil {
+				return size(kib * 1024)
+			}
+		}
+	}
+	return ""
+}
+
+func (s *sysinfo) disks() []string {
+	var l []string
+	for _, d := range s.dir("sys/block") {
+		if strings.HasPrefix(d, "loop") || strings.HasPrefix(d, "ram") {
+			continue
+		}
+		sectors, err := strconv.ParseUint(s.read("sys/block", d, "size"), 10, 64)
+		if err != nil || sectors == 0 {
+			continue
+		}
+		l = append(l, join(d, size(sectors*512), s.read("sys/block", d, "device/vendor"), s.read("sys/block", d, "device/model")))
+	}
+	return l
+}
+
+func (s *sysinfo) nics() []string {
+	var l []string
+	for _, n := range s.dir("sys/class/net") {
+		if n == "lo" {
+			continue
+		}
+		state := s.read("sys/class/net", n, "operstate")
+		speed := ""
+		if v, err := strconv.Atoi(s.read("sys/class/net", n, "speed")); err == nil && v > 0 {
+			speed = fmt.Sprintf("%d Mb/s", v)
+		}
+		l = append(l, join(n, s.read("sys/class/net", n, "address"), state, speed))
+	}
+	return l
+}
+
+func (s *sysinfo) tpms() []string {
+	var l []string
+	for _, t := range s.dir("sys/class/tpm") {
+		version := s.read("sys/class/tpm", t, "tpm_version_major")
+		if version == "" {
+			// Older kernels only have capabilities for TPM 1.2.
+			if s.read("sys/class/tpm", t, "device/caps") != "" {
+				version = "1"
+			}
+		}
+		if version != "" {
+			version = "version " + version
+		}
+		l = append(l, join(t, version))
+	}
+	return l
+}
+
+func (s *sysinfo) print(stdout io.Writer) error {
+	w := tabwriter.NewWriter(stdout, 0, 8, 1, ' ', 0)
+	line := func(name, v string) {
+		if v != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", name, v)
+		}
+	}
+	dmi := func(f string) string { return s.read("sys/class/dmi/id", f) }
+	line("Host", s.read("proc/sys/kernel/hostname"))
+	line("Kernel", s.read("proc/sys/kernel/osrelease"))
+	line("Machine", join(dmi("sys_vendor"), dmi("product_name"), dmi("product_version")))
+	bios := join(dmi("bios_vendor"), dmi("bios_version"))
+	if date := dmi("bios_date"); date != "" {
+		bios = join(bios, "("+date+")")
+	}
+	line("Firmware", bios)
+	if _, err := os.Stat(filepath.Join(s.root, "sys/firmware/efi")); err == nil {
+		line("Boot", "UEFI")
+	}
+	line("CPU", s.cpu())
+	line("Memory", s.memory())
+	for _, d := range s.disks() {
+		line("Disk", d)
+	}
+	for _, n := range s.nics() {
+		line("NIC", n)
+	}
+	tpms := s.tpms()
+	if len(tpms) == 0 {
+		tpms = []string{"none"}
+	}
+	for _, t := range tpms {
+		line("TPM", t)
+	}
+	return w.Flush()
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		log.Fatal("usage: sysinfo")
+	}
+	s := &sysinfo{root: "/"}
+	if err := s.print(os.Stdout); err != nil {
+		log.Fatalf("sysinfo: %v", err)
+	}
+}
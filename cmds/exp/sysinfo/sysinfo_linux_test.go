@@ -0,0 +1,73 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSysinfo(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"proc/sys/kernel/hostname":             "box1\n",
+		"proc/sys/kernel/osrelease":            "5.15.0\n",
+		"proc/cpuinfo":                         "processor\t: 0\nmodel name\t: Example CPU\nphysical id\t: 0\ncore id\t: 0\n\nprocessor\t: 1\nmodel name\t: Example CPU\nphysical id\t: 0\ncore id\t: 0\n\nprocessor\t: 2\nphysical id\t: 0\ncore id\t: 1\n",
+		"proc/meminfo":                         "MemTotal:       16384000 kB\nMemFree:         1000 kB\n",
+		"sys/class/dmi/id/sys_vendor":          "Acme\n",
+		"sys/class/dmi/id/product_name":        "Server 9\n",
+		"sys/class/dmi/id/bios_vendor":         "coreboot\n",
+		"sys/class/dmi/id/bios_version":        "4.17\n",
+		"sys/class/dmi/id/bios_date":           "01/02/2022\n",
+		"sys/block/sda/size":                   "1953525168\n",
+		"sys/block/sda/device/model":           "SSD 1TB\n",
+		"sys/block/loop0/size":                 "100\n",
+		"sys/block/nvme0n1/size":               "0\n",
+		"sys/class/net/lo/address":             "00:00:00:00:00:00\n",
+		"sys/class/net/eth0/address":           "52:54:00:12:34:56\n",
+		"sys/class/net/eth0/operstate":         "up\n",
+		"sys/class/net/eth0/speed":             "1000\n",
+		"sys/class/net/eth1/address":           "52:54:00:12:34:57\n",
+		"sys/class/net/eth1/operstate":         "down\n",
+		"sys/class/net/eth1/speed":             "-1\n",
+		"sys/class/tpm/tpm0/tpm_version_major": "2\n",
+	} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var b bytes.Buffer
+	if err := (&sysinfo{root: root}).print(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `Host:     box1
+Kernel:   5.15.0
+Machine:  Acme Server 9
+Firmware: coreboot 4.17 (01/02/2022)
+CPU:      Example CPU, 2 cores, 3 threads
+Memory:   15.6 GiB
+Disk:     sda 931.5 GiB SSD 1TB
+NIC:      eth0 52:54:00:12:34:56 up 1000 Mb/s
+NIC:      eth1 52:54:00:12:34:57 down
+TPM:      tpm0 version 2
+`
+	if b.String() != want {
+		t.Errorf("sysinfo =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := (&sysinfo{root: t.TempDir()}).print(&b); err != nil {
+		t.Fatal(err)
+	}
+	if want := "TPM: none\n"; b.String() != want {
+		t.Errorf("sysinfo of nothing = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// taskset gets or sets the CPU affinity of processes.
+//
+// Synopsis:
+//
+//	taskset [-a] [-c] MASK COMMAND [ARG]...
+//	taskset [-a] [-c] -p [MASK] PID
+//
+// Description:
+//
+//	taskset runs COMMAND on the CPUs of MASK, or sets the CPUs of the
+//	running process PID, or prints them without MASK.
+//
+//	MASK is hexadecimal, with bit N for CPU N, e.g. 0x5 for CPUs 0 and 2,
+//	or with -c, a list like 0-3,7 or 0-15:2.
+//
+// Options:
+//
+//	-a: act on all the threads of PID, not only the main one
+//	-c: MASK is a list of CPUs
+//	-p: act on the running process PID
+//
+// Example:
+//
+//	taskset -c 2-3 ./benchmark
+//	taskset -pc 0 1234
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"syscall"
+
+	flag "github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/cpuset"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	all  = flag.BoolP("all-tasks", "a", false, "act on all the threads of PID")
+	list = flag.BoolP("cpu-list", "c", false, "MASK is a list of CPUs, e.g. 0-3,7")
+	pid  = flag.BoolP("pid", "p", false, "act on the running process PID")
+)
+
+var errUsage = errors.New("usage: taskset [-a] [-c] MASK COMMAND [ARG]... | [-a] [-c] -p [MASK] PID")
+
+// parseCPUs parses a mask, or a list with -c.
+func parseCPUs(s string, list bool) (*unix.CPUSet, error) {
+	var (
+		ids []int
+		err error
+	)
+	if list {
+		ids, err = cpuset.ParseList(s)
+	} else {
+		ids, err = cpuset.ParseMask(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var set unix.CPUSet
+	for _, id := range ids {
+		if id >= len(set)*64 {
+			return nil, fmt.Errorf("CPU %d is more than taskset supports", id)
+		}
+		set.Set(id)
+	}
+	return &set, nil
+}
+
+// formatCPUs formats set as a mask, or a list with -c.
+func formatCPUs(set *unix.CPUSet, list bool) string {
+	var ids []int
+	for i := 0; i < len(set)*64; i++ {
+		if set.IsSet(i) {
+			ids = append(ids, i)
+		}
+	}
+	if list {
+		return cpuset.FormatList(ids)
+	}
+	return cpuset.FormatMask(ids)
+}
+
+// tasks returns pid, or with -a, all of its threads.
+func tasks(pid int, all bool) ([]int, error) {
+	if !all {
+		return []int{pid}, nil
+	}
+	entries, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "task"))
+	if err != nil {
+		return nil, err
+	}
+	var tids []int
+	for _, e := range entries {
+		if tid, err := strconv.Atoi(e.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	sort.Ints(tids)
+	return tids, nil
+}
+
+// affinity prints, and with a set, sets the affinity of the tasks of pid.
+func affinity(out io.Writer, pid int, set *unix.CPUSet) error {
+	tids, err := tasks(pid, *all)
+	if err != nil {
+		return err
+	}
+	kind := "mask"
+	if *list {
+		kind = "list"
+	}
+	for _, tid := range tids {
+		var cur unix.CPUSet
+		if err := unix.SchedGetaffinity(tid, &cur); err != nil {
+			return fmt.Errorf("failed to get pid %d's affinity: %v", tid, err)
+		}
+		fmt.Fprintf(out, "pid %d's current affinity %s: %s\n", tid, kind, formatCPUs(&cur, *list))
+		if set == nil {
+			continue
+		}
+		if err := unix.SchedSetaffinity(tid, set); err != nil {
+			return fmt.Errorf("failed to set pid %d's affinity: %v", tid, err)
+		}
+		if err := unix.SchedGetaffinity(tid, &cur); err != nil {
+			return fmt.Errorf("failed to get pid %d's affinity: %v", tid, err)
+		}
+		fmt.Fprintf(out, "pid %d's new affinity %s: %s\n", tid, kind, formatCPUs(&cur, *list))
+	}
+	return nil
+}
+
+func run(out io.Writer, args []string) error {
+	if !*pid {
+		return errUsage
+	}
+	var set *unix.CPUSet
+	switch len(args) {
+	case 1:
+	case 2:
+		var err error
+		if set, err = parseCPUs(args[0], *list); err != nil {
+			return err
+		}
+		args = args[1:]
+	default:
+		return errUsage
+	}
+	p, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid PID %q", args[0])
+	}
+	return affinity(out, p, set)
+}
+
+func main() {
+	log.SetPrefix("taskset: ")
+	log.SetFlags(0)
+	flag.CommandLine.SetInterspersed(false)
+	flag.Parse()
+	args := flag.Args()
+	if *pid {
+		if err := run(os.Stdout, args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) < 2 {
+		log.Fatal(errUsage)
+	}
+	set, err := parseCPUs(args[0], *list)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The affinity is set for a thread, which must be the one to exec.
+	runtime.LockOSThread()
+	if err := unix.SchedSetaffinity(0, set); err != nil {
+		log.Fatalf("failed to set the affinity: %v", err)
+	}
+	path, err := exec.LookPath(args[1])
+	if err == nil {
+		err = syscall.Exec(path, args[1:], os.Environ())
+	}
+	log.Print(err)
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		os.Exit(127)
+	}
+	os.Exit(126)
+}
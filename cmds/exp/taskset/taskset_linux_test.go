@@ -0,0 +1,117 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/testutil"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseCPUs(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		list bool
+		mask string
+		cpus string
+	}{
+		{in: "0x5", mask: "5", cpus: "0,2"},
+		{in: "f0", mask: "f0", cpus: "4-7"},
+		{in: "0-3,8", list: true, mask: "10f", cpus: "0-3,8"},
+	} {
+		set, err := parseCPUs(tt.in, tt.list)
+		if err != nil {
+			t.Errorf("parseCPUs(%q, %v) = %v", tt.in, tt.list, err)
+			continue
+		}
+		if m, l := formatCPUs(set, false), formatCPUs(set, true); m != tt.mask || l != tt.cpus {
+			t.Errorf("parseCPUs(%q, %v) = mask %s, list %s, want %s, %s", tt.in, tt.list, m, l, tt.mask, tt.cpus)
+		}
+	}
+	for _, in := range []string{"0", "x", "0-3"} {
+		if _, err := parseCPUs(in, false); err == nil {
+			t.Errorf("parseCPUs(%q) succeeded", in)
+		}
+	}
+	if _, err := parseCPUs("2000", true); err == nil {
+		t.Errorf("parseCPUs of CPU 2000 succeeded")
+	}
+}
+
+// firstCPU returns a CPU the test may run on.
+func firstCPU(t *testing.T) int {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		t.Skipf("cannot get the affinity: %v", err)
+	}
+	for i := 0; ; i++ {
+		if set.IsSet(i) {
+			return i
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	cpu := firstCPU(t)
+	c := exec.Command("sleep", "10")
+	if err := c.Start(); err != nil {
+		t.Skipf("cannot run sleep: %v", err)
+	}
+	defer c.Wait()
+	defer c.Process.Kill()
+	p := strconv.Itoa(c.Process.Pid)
+
+	*pid, *list = true, true
+	defer func() { *pid, *list = false, false }()
+	var out bytes.Buffer
+	if err := run(&out, []string{strconv.Itoa(cpu), p}); err != nil {
+		t.Fatalf("taskset -pc %d %s = %v", cpu, p, err)
+	}
+	if want := fmt.Sprintf("pid %s's new affinity list: %d\n", p, cpu); !strings.HasSuffix(out.String(), want) {
+		t.Errorf("taskset -pc printed %q, want it to end in %q", out.String(), want)
+	}
+	out.Reset()
+	if err := run(&out, []string{p}); err != nil || out.String() != fmt.Sprintf("pid %s's current affinity list: %d\n", p, cpu) {
+		t.Errorf("taskset -pc %s = %q, %v", p, out.String(), err)
+	}
+
+	for _, args := range [][]string{{}, {"1", "2", "3"}, {"x"}, {"0", "x"}} {
+		if err := run(&out, args); err == nil {
+			t.Errorf("taskset -p %v succeeded", args)
+		}
+	}
+}
+
+func TestTaskset(t *testing.T) {
+	cpu := firstCPU(t)
+	c := testutil.Command(t, "-c", strconv.Itoa(cpu), "grep", "Cpus_allowed_list", "/proc/self/status")
+	out, err := c.CombinedOutput()
+	if err != nil {
+		t.Skipf("taskset -c %d grep = %v, %s", cpu, err, out)
+	}
+	if f := strings.Fields(string(out)); len(f) != 2 || f[1] != strconv.Itoa(cpu) {
+		t.Errorf("taskset -c %d ran on %q", cpu, out)
+	}
+
+	if err := testutil.IsExitCode(testutil.Command(t, "1").Run(), 1); err != nil {
+		t.Errorf("taskset 1: %v", err)
+	}
+	if err := testutil.IsExitCode(testutil.Command(t, "1", "/does/not/exist").Run(), 127); err != nil {
+		t.Errorf("taskset 1 /does/not/exist: %v", err)
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
@@ -0,0 +1,590 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// tc shows and changes the queueing disciplines of network devices.
+//
+// Synopsis:
+//
+//	tc qdisc [show] [dev DEV]
+//	tc qdisc {add|replace|change} dev DEV [root|parent MAJ:MIN] [handle MAJ:] QDISC
+//	tc qdisc del dev DEV [root|parent MAJ:MIN] [handle MAJ:] [KIND]
+//
+// Description:
+//
+//	tc is a subset of the iproute2 command of the same name, to test boot
+//	flows under degraded network conditions. QDISC is one of:
+//
+//	fq_codel [limit PACKETS] [flows N] [quantum BYTES] [interval TIME]
+//		[ce_threshold TIME] [memory_limit BYTES] [ecn|noecn]
+//	tbf rate RATE burst BYTES {latency TIME|limit BYTES}
+//	netem [delay TIME [JITTER [CORRELATION]]] [loss [random] PERCENT [CORRELATION]]
+//		[duplicate PERCENT [CORRELATION]] [corrupt PERCENT [CORRELATION]]
+//		[reorder PERCENT [CORRELATION]] [gap DISTANCE] [limit PACKETS]
+//
+//	TIME is a number with a unit of s, ms or us, and is in microseconds
+//	without one. RATE is a number of bits per second with a unit of bit,
+//	kbit, mbit or gbit, or of bytes per second with bps, kbps, mbps or
+//	gbps. BYTES may have a unit of k, m or g for multiples of 1024.
+//	PERCENT may end with %.
+//
+// Example:
+//
+//	tc qdisc add dev eth0 root netem delay 100ms 10ms loss 1%
+//	tc qdisc replace dev eth0 root tbf rate 1mbit burst 32k latency 400ms
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// links looks up links by name.
+type links func(name string) (netlink.Link, error)
+
+// args is a cursor over the arguments of a command.
+type args struct {
+	a []string
+}
+
+func (a *args) more() bool {
+	return len(a.a) > 0
+}
+
+// next returns the next argument, or an error saying what is missing.
+func (a *args) next(what string) (string, error) {
+	if len(a.a) == 0 {
+		return "", fmt.Errorf("missing %s", what)
+	}
+	s := a.a[0]
+	a.a = a.a[1:]
+	return s, nil
+}
+
+// optional consumes the next argument if parse accepts it.
+func (a *args) optional(parse func(string) error) {
+	if a.more() && parse(a.a[0]) == nil {
+		a.a = a.a[1:]
+	}
+}
+
+func (a *args) time(what string) (uint32, error) {
+	s, err := a.next(what)
+	if err != nil {
+		return 0, err
+	}
+	return parseTime(s)
+}
+
+func (a *args) rate(what string) (uint64, error) {
+	s, err := a.next(what)
+	if err != nil {
+		return 0, err
+	}
+	return parseRate(s)
+}
+
+func (a *args) size(what string) (uint32, error) {
+	s, err := a.next(what)
+	if err != nil {
+		return 0, err
+	}
+	return parseSize(s)
+}
+
+func (a *args) count(what string) (uint32, error) {
+	s, err := a.next(what)
+	if err != nil {
+		return 0, err
+	}
+	return parseCount(s)
+}
+
+func (a *args) handle(what string) (uint32, error) {
+	s, err := a.next(what)
+	if err != nil {
+		return 0, err
+	}
+	return parseHandle(s)
+}
+
+// percentWithCorrelation parses PERCENT [CORRELATION].
+func (a *args) percentWithCorrelation(what string, p, corr *float32) error {
+	s, err := a.next(what)
+	if err != nil {
+		return err
+	}
+	if *p, err = parsePercent(s); err != nil {
+		return err
+	}
+	a.optional(func(s string) (err error) {
+		*corr, err = parsePercent(s)
+		return err
+	})
+	return nil
+}
+
+// split splits s into a number and its unit.
+func split(s string) (float64, string, error) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || v < 0 {
+		return 0, "", fmt.Errorf("invalid number %q", s)
+	}
+	return v, strings.ToLower(s[i:]), nil
+}
+
+func fits(v float64, s string) (uint32, error) {
+	if v > math.MaxUint32 {
+		return 0, fmt.Errorf("%q is too large", s)
+	}
+	return uint32(math.Round(v)), nil
+}
+
+// parseTime returns s in microseconds.
+func parseTime(s string) (uint32, error) {
+	v, unit, err := split(s)
+	if err != nil {
+		return 0, err
+	}
+	switch unit {
+	case "s", "sec", "secs":
+		v *= 1e6
+	case "ms", "msec", "msecs":
+		v *= 1e3
+	case "", "us", "usec", "usecs":
+	default:
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return fits(v, s)
+}
+
+// parseRate returns s in bytes per second.
+func parseRate(s string) (uint64, error) {
+	v, unit, err := split(s)
+	if err != nil {
+		return 0, err
+	}
+	units := map[string]float64{
+		"": 1, "bit": 1, "kbit": 1e3, "mbit": 1e6, "gbit": 1e9,
+		"kibit": 1 << 10, "mibit": 1 << 20, "gibit": 1 << 30,
+		"bps": 8, "kbps": 8e3, "mbps": 8e6, "gbps": 8e9,
+		"kibps": 8 << 10, "mibps": 8 << 20, "gibps": 8 << 30,
+	}
+	m, ok := units[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	if r := math.Round(v * m / 8); r >= 1 && r <= math.MaxUint64 {
+		return uint64(r), nil
+	}
+	return 0, fmt.Errorf("invalid rate %q", s)
+}
+
+// parseSize returns s in bytes.
+func parseSize(s string) (uint32, error) {
+	v, unit, err := split(s)
+	if err != nil {
+		return 0, err
+	}
+	units := map[string]float64{
+		"": 1, "b": 1, "k": 1 << 10, "kb": 1 << 10, "m": 1 << 20, "mb": 1 << 20, "g": 1 << 30, "gb": 1 << 30,
+		"kbit": 1 << 7, "mbit": 1 << 17, "gbit": 1 << 27,
+	}
+	m, ok := units[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return fits(v*m, s)
+}
+
+func parseCount(s string) (uint32, error) {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return uint32(v), nil
+}
+
+func parsePercent(s string) (float32, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 32)
+	if err != nil || v < 0 || v > 100 {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return float32(v), nil
+}
+
+// parseHandle parses a handle, MAJ: or MAJ:MIN in hexadecimal.
+func parseHandle(s string) (uint32, error) {
+	maj, min, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid handle %q", s)
+	}
+	major, err := strconv.ParseUint(maj, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid handle %q", s)
+	}
+	var minor uint64
+	if min != "" {
+		if minor, err = strconv.ParseUint(min, 16, 16); err != nil {
+			return 0, fmt.Errorf("invalid handle %q", s)
+		}
+	}
+	return netlink.MakeHandle(uint16(major), uint16(minor)), nil
+}
+
+func parseNetem(a *args, attrs netlink.QdiscAttrs) (netlink.Qdisc, error) {
+	var n netlink.NetemQdiscAttrs
+	for a.more() {
+		w, _ := a.next("")
+		var err error
+		switch w {
+		case "delay", "latency":
+			if n.Latency, err = a.time("delay"); err != nil {
+				break
+			}
+			a.optional(func(s string) (err error) {
+				n.Jitter, err = parseTime(s)
+				return err
+			})
+			if n.Jitter != 0 {
+				a.optional(func(s string) (err error) {
+					n.DelayCorr, err = parsePercent(s)
+					return err
+				})
+			}
+		case "loss", "drop":
+			if a.more() && a.a[0] == "random" {
+				a.next("")
+			}
+			err = a.percentWithCorrelation("loss", &n.Loss, &n.LossCorr)
+		case "duplicate":
+			err = a.percentWithCorrelation("duplicate", &n.Duplicate, &n.DuplicateCorr)
+		case "corrupt":
+			err = a.percentWithCorrelation("corrupt", &n.CorruptProb, &n.CorruptCorr)
+		case "reorder":
+			err = a.percentWithCorrelation("reorder", &n.ReorderProb, &n.ReorderCorr)
+		case "gap":
+			n.Gap, err = a.count("gap")
+		case "limit":
+			n.Limit, err = a.count("limit")
+		default:
+			err = fmt.Errorf("unknown netem argument %q", w)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if n.ReorderProb > 0 && n.Latency == 0 {
+		return nil, errors.New("netem reorder needs a delay")
+	}
+	return netlink.NewNetem(attrs, n), nil
+}
+
+func parseTbf(a *args, attrs netlink.QdiscAttrs) (netlink.Qdisc, error) {
+	t := &netlink.Tbf{QdiscAttrs: attrs}
+	var burst, latency uint32
+	for a.more() {
+		w, _ := a.next("")
+		var err error
+		switch w {
+		case "rate":
+			t.Rate, err = a.rate("rate")
+		case "burst", "buffer", "maxburst":
+			burst, err = a.size("burst")
+		case "latency":
+			latency, err = a.time("latency")
+		case "limit":
+			t.Limit, err = a.size("limit")
+		default:
+			err = fmt.Errorf("unknown tbf argument %q", w)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case t.Rate == 0 || burst == 0:
+		return nil, errors.New("tbf needs a rate and a burst")
+	case (t.Limit == 0) == (latency == 0):
+		return nil, errors.New("tbf needs either a latency or a limit")
+	}
+	if latency != 0 {
+		limit := float64(t.Rate)*float64(latency)/1e6 + float64(burst)
+		if limit > math.MaxUint32 {
+			return nil, errors.New("tbf latency is too large")
+		}
+		t.Limit = uint32(limit)
+	}
+	t.Buffer = netlink.Xmittime(t.Rate, burst)
+	return t, nil
+}
+
+func parseFqCodel(a *args, attrs netlink.QdiscAttrs) (netlink.Qdisc, error) {
+	q := netlink.NewFqCodel(attrs)
+	for a.more() {
+		w, _ := a.next("")
+		var err error
+		switch w {
+		case "limit":
+			q.Limit, err = a.count("limit")
+		case "flows":
+			q.Flows, err = a.count("flows")
+		case "quantum":
+			q.Quantum, err = a.size("quantum")
+		case "interval":
+			q.Interval, err = a.time("interval")
+		case "ce_threshold":
+			q.CEThreshold, err = a.time("ce_threshold")
+		case "memory_limit":
+			q.MemoryLimit, err = a.size("memory_limit")
+		case "ecn":
+			q.ECN = 1
+		case "noecn":
+			q.ECN = 0
+		default:
+			err = fmt.Errorf("unknown fq_codel argument %q", w)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+var qdiscs = map[string]func(*args, netlink.QdiscAttrs) (netlink.Qdisc, error){
+	"fq_codel": parseFqCodel,
+	"tbf":      parseTbf,
+	"netem":    parseNetem,
+}
+
+// qdiscCmd is a parsed qdisc command.
+type qdiscCmd struct {
+	op    string
+	link  netlink.Link
+	qdisc netlink.Qdisc
+}
+
+func parseQdisc(argv []string, l links) (*qdiscCmd, error) {
+	a := &args{a: argv}
+	c := &qdiscCmd{op: "show"}
+	if a.more() {
+		switch w := a.a[0]; w {
+		case "show", "list", "ls":
+			a.next("")
+		case "add", "replace", "change", "del":
+			a.next("")
+			c.op = w
+		case "delete":
+			a.next("")
+			c.op = "del"
+		}
+	}
+
+	attrs := netlink.QdiscAttrs{Parent: netlink.HANDLE_ROOT}
+	for a.more() {
+		w, _ := a.next("")
+		var err error
+		switch {
+		case w == "dev":
+			var name string
+			if name, err = a.next("device name"); err == nil {
+				c.link, err = l(name)
+			}
+		case c.op == "show":
+			err = fmt.Errorf("unknown qdisc show argument %q", w)
+		case w == "root":
+			attrs.Parent = netlink.HANDLE_ROOT
+		case w == "parent":
+			attrs.Parent, err = a.handle("parent")
+		case w == "handle":
+			attrs.Handle, err = a.handle("handle")
+		case qdiscs[w] != nil && c.op != "del":
+			if c.link == nil {
+				return nil, errors.New("missing dev DEV before the qdisc")
+			}
+			attrs.LinkIndex = c.link.Attrs().Index
+			c.qdisc, err = qdiscs[w](a, attrs)
+		case c.op == "del":
+			if c.link == nil {
+				return nil, errors.New("missing dev DEV before the qdisc")
+			}
+			attrs.LinkIndex = c.link.Attrs().Index
+			c.qdisc = &netlink.GenericQdisc{QdiscAttrs: attrs, QdiscType: w}
+			if a.more() {
+				err = fmt.Errorf("unexpected arguments %q", a.a)
+			}
+		default:
+			err = fmt.Errorf("unknown or unsupported qdisc %q", w)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case c.op == "show":
+	case c.link == nil:
+		return nil, errors.New("missing dev DEV")
+	case c.qdisc == nil && c.op == "del":
+		attrs.LinkIndex = c.link.Attrs().Index
+		c.qdisc = &netlink.GenericQdisc{QdiscAttrs: attrs}
+	case c.qdisc == nil:
+		return nil, errors.New("missing qdisc")
+	}
+	return c, nil
+}
+
+// formatTime formats us microseconds like iproute2.
+func formatTime(us float64) string {
+	f := func(v float64, unit string) string {
+		return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64) + unit
+	}
+	switch {
+	case us >= 1e6:
+		return f(us/1e6, "s")
+	case us >= 1e3:
+		return f(us/1e3, "ms")
+	}
+	return f(us, "us")
+}
+
+// formatRate formats a rate of bytes per second like iproute2.
+func formatRate(bytes uint64) string {
+	bits := float64(bytes) * 8
+	f := func(v float64, unit string) string {
+		return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64) + unit
+	}
+	switch {
+	case bits >= 1e9:
+		return f(bits/1e9, "Gbit")
+	case bits >= 1e6:
+		return f(bits/1e6, "Mbit")
+	case bits >= 1e3:
+		return f(bits/1e3, "Kbit")
+	}
+	return f(bits, "bit")
+}
+
+func formatPercent(v uint32) string {
+	return strconv.FormatFloat(math.Round(float64(v)/math.MaxUint32*1e4)/100, 'f', -1, 64) + "%"
+}
+
+// formatQdisc formats q of device dev like iproute2; times are converted
+// from ticks with tick, the number of ticks per microsecond.
+func formatQdisc(q netlink.Qdisc, dev string, tick float64) string {
+	var sb strings.Builder
+	attrs := q.Attrs()
+	major, _ := netlink.MajorMinor(attrs.Handle)
+	fmt.Fprintf(&sb, "qdisc %s %x: dev %s ", q.Type(), major, dev)
+	if attrs.Parent == netlink.HANDLE_ROOT {
+		sb.WriteString("root")
+	} else {
+		fmt.Fprintf(&sb, "parent %s", netlink.HandleStr(attrs.Parent))
+	}
+	if attrs.Refcnt != 0 {
+		fmt.Fprintf(&sb, " refcnt %d", attrs.Refcnt)
+	}
+
+	withCorr := func(name string, v, corr uint32) {
+		if v == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, " %s %s", name, formatPercent(v))
+		if corr != 0 {
+			fmt.Fprintf(&sb, " %s", formatPercent(corr))
+		}
+	}
+	switch q := q.(type) {
+	case *netlink.Netem:
+		fmt.Fprintf(&sb, " limit %d", q.Limit)
+		if q.Latency != 0 {
+			fmt.Fprintf(&sb, " delay %s", formatTime(float64(q.Latency)/tick))
+			if q.Jitter != 0 {
+				fmt.Fprintf(&sb, "  %s", formatTime(float64(q.Jitter)/tick))
+				if q.DelayCorr != 0 {
+					fmt.Fprintf(&sb, " %s", formatPercent(q.DelayCorr))
+				}
+			}
+		}
+		withCorr("loss", q.Loss, q.LossCorr)
+		withCorr("duplicate", q.Duplicate, q.DuplicateCorr)
+		withCorr("reorder", q.ReorderProb, q.ReorderCorr)
+		withCorr("corrupt", q.CorruptProb, q.CorruptCorr)
+		if q.Gap != 0 {
+			fmt.Fprintf(&sb, " gap %d", q.Gap)
+		}
+	case *netlink.Tbf:
+		burst := float64(q.Rate) * float64(q.Buffer) / tick / 1e6
+		fmt.Fprintf(&sb, " rate %s burst %db limit %db", formatRate(q.Rate), uint64(math.Round(burst)), q.Limit)
+	case *netlink.FqCodel:
+		fmt.Fprintf(&sb, " limit %dp flows %d quantum %d", q.Limit, q.Flows, q.Quantum)
+		fmt.Fprintf(&sb, " target %s interval %s", formatTime(float64(q.Target)), formatTime(float64(q.Interval)))
+		if q.ECN != 0 {
+			sb.WriteString(" ecn")
+		}
+	}
+	return sb.String()
+}
+
+func qdisc(w io.Writer, argv []string) error {
+	c, err := parseQdisc(argv, netlink.LinkByName)
+	if err != nil {
+		return err
+	}
+	switch c.op {
+	case "add":
+		return netlink.QdiscAdd(c.qdisc)
+	case "replace":
+		return netlink.QdiscReplace(c.qdisc)
+	case "change":
+		return netlink.QdiscChange(c.qdisc)
+	case "del":
+		return netlink.QdiscDel(c.qdisc)
+	}
+
+	ls := []netlink.Link{c.link}
+	if c.link == nil {
+		if ls, err = netlink.LinkList(); err != nil {
+			return err
+		}
+	}
+	for _, l := range ls {
+		qs, err := netlink.QdiscList(l)
+		if err != nil {
+			return err
+		}
+		for _, q := range qs {
+			if _, err := fmt.Fprintln(w, formatQdisc(q, l.Attrs().Name, netlink.TickInUsec())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func run(w io.Writer, argv []string) error {
+	if len(argv) == 0 || argv[0] != "qdisc" {
+		return errors.New("usage: tc qdisc ...")
+	}
+	return qdisc(w, argv[1:])
+}
+
+func main() {
+	if err := run(os.Stdout, os.Args[1:]); err != nil {
+		log.Fatalf("tc: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func fakeLinks(name string) (netlink.Link, error) {
+	if name == "eth0" {
+		return &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: name, Index: 2}}, nil
+	}
+	return nil, fmt.Errorf("no link %q", name)
+}
+
+func TestParseUnits(t *testing.T) {
+	for _, tt := range []struct {
+		s     string
+		parse func(string) (uint64, error)
+		want  uint64
+		err   bool
+	}{
+		{s: "100ms", parse: u64(parseTime), want: 100000},
+		{s: "1.5s", parse: u64(parseTime), want: 1500000},
+		{s: "250", parse: u64(parseTime), want: 250},
+		{s: "10min", parse: u64(parseTime), err: true},
+		{s: "1mbit", parse: parseRate, want: 125000},
+		{s: "10Gbit", parse: parseRate, want: 1250000000},
+		{s: "64kbps", parse: parseRate, want: 64000},
+		{s: "800", parse: parseRate, want: 100},
+		{s: "0bit", parse: parseRate, err: true},
+		{s: "32k", parse: u64(parseSize), want: 32768},
+		{s: "1mb", parse: u64(parseSize), want: 1 << 20},
+		{s: "1500", parse: u64(parseSize), want: 1500},
+		{s: "-1", parse: u64(parseSize), err: true},
+		{s: "8g", parse: u64(parseSize), err: true},
+		{s: "1:", parse: u64(parseHandle), want: 0x10000},
+		{s: "ffff:a", parse: u64(parseHandle), want: 0xffff000a},
+		{s: "1", parse: u64(parseHandle), err: true},
+	} {
+		got, err := tt.parse(tt.s)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parse(%q) = %d, %v, want %d, error %v", tt.s, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func u64(f func(string) (uint32, error)) func(string) (uint64, error) {
+	return func(s string) (uint64, error) {
+		v, err := f(s)
+		return uint64(v), err
+	}
+}
+
+func TestParseQdisc(t *testing.T) {
+	eth0, _ := fakeLinks("eth0")
+	root := netlink.QdiscAttrs{LinkIndex: 2, Parent: netlink.HANDLE_ROOT}
+	fq := netlink.NewFqCodel(netlink.QdiscAttrs{LinkIndex: 2, Parent: netlink.MakeHandle(1, 2), Handle: netlink.MakeHandle(10, 0)})
+	fq.Limit, fq.Interval, fq.ECN = 1000, 50000, 0
+
+	for _, tt := range []struct {
+		args string
+		want *qdiscCmd
+	}{
+		{args: "", want: &qdiscCmd{op: "show"}},
+		{args: "show dev eth0", want: &qdiscCmd{op: "show", link: eth0}},
+		{
+			args: "add dev eth0 root netem delay 100ms 10ms 25% loss random 1% duplicate 0.5 reorder 2% 50%",
+			want: &qdiscCmd{op: "add", link: eth0, qdisc: netlink.NewNetem(root, netlink.NetemQdiscAttrs{
+				Latency: 100000, Jitter: 10000, DelayCorr: 25, Loss: 1, Duplicate: 0.5, ReorderProb: 2, ReorderCorr: 50,
+			})},
+		},
+		{
+			args: "replace dev eth0 root netem loss 5% limit 100",
+			want: &qdiscCmd{op: "replace", link: eth0, qdisc: netlink.NewNetem(root, netlink.NetemQdiscAttrs{Loss: 5, Limit: 100})},
+		},
+		{
+			args: "change dev eth0 root tbf rate 1mbit burst 32k latency 400ms",
+			want: &qdiscCmd{op: "change", link: eth0, qdisc: &netlink.Tbf{
+				QdiscAttrs: root, Rate: 125000, Limit: 125000*4/10 + 32768, Buffer: netlink.Xmittime(125000, 32768),
+			}},
+		},
+		{
+			args: "add dev eth0 tbf rate 8kbit buffer 1600 limit 3000",
+			want: &qdiscCmd{op: "add", link: eth0, qdisc: &netlink.Tbf{
+				QdiscAttrs: root, Rate: 1000, Limit: 3000, Buffer: netlink.Xmittime(1000, 1600),
+			}},
+		},
+		{
+			args: "add dev eth0 parent 1:2 handle a: fq_codel limit 1000 interval 50ms noecn",
+			want: &qdiscCmd{op: "add", link: eth0, qdisc: fq},
+		},
+		{args: "del dev eth0 root", want: &qdiscCmd{op: "del", link: eth0, qdisc: &netlink.GenericQdisc{QdiscAttrs: root}}},
+		{args: "delete dev eth0 root netem", want: &qdiscCmd{op: "del", link: eth0, qdisc: &netlink.GenericQdisc{QdiscAttrs: root, QdiscType: "netem"}}},
+		{args: "add dev eth0 root"},
+		{args: "add root netem delay 1ms"},
+		{args: "add dev eth1 root netem"},
+		{args: "add dev eth0 root htb"},
+		{args: "add dev eth0 root netem loss 101%"},
+		{args: "add dev eth0 root netem reorder 1%"},
+		{args: "add dev eth0 root netem delay"},
+		{args: "add dev eth0 root tbf rate 1mbit burst 32k"},
+		{args: "add dev eth0 root tbf rate 1mbit burst 32k latency 1ms limit 1000"},
+		{args: "add dev eth0 root tbf burst 32k latency 1ms"},
+		{args: "add dev eth0 root fq_codel target 5ms"},
+		{args: "show root"},
+		{args: "del dev eth0 root netem delay 1ms"},
+	} {
+		got, err := parseQdisc(strings.Fields(tt.args), fakeLinks)
+		if (err != nil) != (tt.want == nil) {
+			t.Errorf("parseQdisc(%q) = %v, want error %v", tt.args, err, tt.want == nil)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseQdisc(%q) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestFormatQdisc(t *testing.T) {
+	// Ticks are microseconds here.
+	attrs := netlink.QdiscAttrs{Handle: netlink.MakeHandle(0x8001, 0), Parent: netlink.HANDLE_ROOT, Refcnt: 2}
+	netem := &netlink.Netem{
+		QdiscAttrs: attrs, Limit: 1000, Latency: 100000, Jitter: 10000,
+		Loss: netlink.Percentage2u32(1), LossCorr: netlink.Percentage2u32(25),
+	}
+	tbf := &netlink.Tbf{QdiscAttrs: attrs, Rate: 125000, Buffer: 262144, Limit: 82768}
+	fq := &netlink.FqCodel{QdiscAttrs: netlink.QdiscAttrs{Handle: 0, Parent: netlink.MakeHandle(1, 2)}, Limit: 10240, Flows: 1024, Quantum: 1514, Target: 5000, Interval: 100000, ECN: 1}
+
+	for _, tt := range []struct {
+		q    netlink.Qdisc
+		want string
+	}{
+		{q: netem, want: "qdisc netem 8001: dev eth0 root refcnt 2 limit 1000 delay 100ms  10ms loss 1% 25%"},
+		{q: tbf, want: "qdisc tbf 8001: dev eth0 root refcnt 2 rate 1Mbit burst 32768b limit 82768b"},
+		{q: fq, want: "qdisc fq_codel 0: dev eth0 parent 1:2 limit 10240p flows 1024 quantum 1514 target 5ms interval 100ms ecn"},
+	} {
+		if got := formatQdisc(tt.q, "eth0", 1); got != tt.want {
+			t.Errorf("formatQdisc = %q, want %q", got, tt.want)
+		}
+	}
+}
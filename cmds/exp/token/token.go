@@ -0,0 +1,133 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// token prints random tokens, e.g. for identifiers, nonces and passwords.
+//
+// Synopsis:
+//
+//	token [-n BYTES] [-e ENCODING] [-C COUNT]
+//
+// Description:
+//
+//	token reads BYTES random bytes from the kernel's cryptographically
+//	secure source, and prints them in ENCODING, which is one of:
+//
+//	hex:       lower case hexadecimal, the default
+//	base64:    standard base64, padded
+//	base64url: URL and file name safe base64, without padding
+//	base32:    standard base32, without padding
+//	alnum:     letters and digits
+//	digits:    decimal digits
+//
+//	alnum and digits tokens are as long as it takes for them to be as
+//	unlikely to be guessed as BYTES random bytes.
+//
+// Options:
+//
+//	-n, --bytes:    the number of random bytes, 16 by default
+//	-e, --encoding: the ENCODING of the tokens
+//	-C, --count:    print COUNT tokens, a line each
+//
+// Example:
+//
+//	$ token
+//	6f1d0b9a3c4e8f2a7b5d1c0e9f3a6b8d
+//	$ token -n 32 -e base64url
+//	$ token -n 4 -e digits
+//	0394857162
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	size     = flag.IntP("bytes", "n", 16, "the number of random `BYTES`")
+	encoding = flag.StringP("encoding", "e", "hex", "the `ENCODING`: hex, base64, base64url, base32, alnum or digits")
+	count    = flag.IntP("count", "C", 1, "print `COUNT` tokens")
+)
+
+var errUsage = errors.New("usage: token [-n BYTES] [-e ENCODING] [-C COUNT]")
+
+// randReader is the source of random bytes. Tests change it.
+var randReader io.Reader = rand.Reader
+
+// encodings encode random bytes.
+var encodings = map[string]func([]byte) string{
+	"hex":       hex.EncodeToString,
+	"base64":    base64.StdEncoding.EncodeToString,
+	"base64url": base64.RawURLEncoding.EncodeToString,
+	"base32":    base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString,
+}
+
+// alphabets are the characters of tokens that are picked a character at a
+// time.
+var alphabets = map[string]string{
+	"alnum":  "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	"digits": "0123456789",
+}
+
+// token returns a token of n random bytes in encoding.
+func token(n int, encoding string) (string, error) {
+	if enc, ok := encodings[encoding]; ok {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(randReader, b); err != nil {
+			return "", err
+		}
+		return enc(b), nil
+	}
+	alphabet, ok := alphabets[encoding]
+	if !ok {
+		return "", fmt.Errorf("unknown encoding %q", encoding)
+	}
+
+	// Each character has log2(len(alphabet)) bits of entropy.
+	chars := int(math.Ceil(float64(8*n) / math.Log2(float64(len(alphabet)))))
+	base := big.NewInt(int64(len(alphabet)))
+	var s strings.Builder
+	for i := 0; i < chars; i++ {
+		c, err := rand.Int(randReader, base)
+		if err != nil {
+			return "", err
+		}
+		s.WriteByte(alphabet[c.Int64()])
+	}
+	return s.String(), nil
+}
+
+func run(out io.Writer, args []string) error {
+	if len(args) != 0 || *size < 1 || *count < 1 {
+		return errUsage
+	}
+	for i := 0; i < *count; i++ {
+		t, err := token(*size, *encoding)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, t)
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("token: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdout, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
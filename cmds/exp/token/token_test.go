@@ -0,0 +1,84 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestToken(t *testing.T) {
+	defer func(r io.Reader) { randReader = r }(randReader)
+	seq := make([]byte, 256)
+	for i := range seq {
+		seq[i] = byte(i)
+	}
+
+	for _, tt := range []struct {
+		n        int
+		encoding string
+		want     string
+	}{
+		{n: 4, encoding: "hex", want: "00010203"},
+		{n: 4, encoding: "base64", want: "AAECAw=="},
+		{n: 5, encoding: "base64url", want: "AAECAwQ"},
+		{n: 5, encoding: "base32", want: "AAAQEAYE"},
+		{n: 1, encoding: "digits", want: "012"},
+		{n: 4, encoding: "alnum", want: "012345"},
+	} {
+		randReader = bytes.NewReader(seq)
+		got, err := token(tt.n, tt.encoding)
+		if err != nil || got != tt.want {
+			t.Errorf("token(%d, %s) = %q, %v, want %q", tt.n, tt.encoding, got, err, tt.want)
+		}
+	}
+
+	randReader = bytes.NewReader(seq)
+	if _, err := token(4, "base58"); err == nil {
+		t.Errorf("token(4, base58) = nil, want an error")
+	}
+	randReader = bytes.NewReader(seq[:2])
+	if _, err := token(4, "hex"); err == nil {
+		t.Errorf("token of a short source = nil, want an error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		size, count int
+		encoding    string
+		args        []string
+		lines, len  int
+		err         error
+	}{
+		{size: 16, count: 1, encoding: "hex", lines: 1, len: 32},
+		{size: 32, count: 3, encoding: "base64url", lines: 3, len: 43},
+		{size: 16, count: 2, encoding: "alnum", lines: 2, len: 22},
+		{size: 0, count: 1, encoding: "hex", err: errUsage},
+		{size: 16, count: 0, encoding: "hex", err: errUsage},
+		{size: 16, count: 1, encoding: "hex", args: []string{"x"}, err: errUsage},
+	} {
+		*size, *count, *encoding = tt.size, tt.count, tt.encoding
+		var out bytes.Buffer
+		if err := run(&out, tt.args); err != tt.err {
+			t.Errorf("%+v: run = %v, want %v", tt, err, tt.err)
+			continue
+		}
+		lines := strings.Fields(out.String())
+		if len(lines) != tt.lines {
+			t.Errorf("%+v: printed %d tokens, want %d", tt, len(lines), tt.lines)
+		}
+		for _, l := range lines {
+			if len(l) != tt.len {
+				t.Errorf("%+v: printed %q, want %d characters", tt, l, tt.len)
+			}
+		}
+		if len(lines) > 1 && lines[0] == lines[1] {
+			t.Errorf("%+v: printed the same token twice", tt)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// turbostat prints the frequency, C-state residency and package power of
+// CPUs at intervals, from their MSRs.
+//
+// Synopsis:
+//
+//	turbostat [-p CPUS] [-i INTERVAL] [-n COUNT] [-s]
+//
+// Description:
+//
+//	Every interval, turbostat prints a row for each CPU, and a summary row,
+//	CPU -, which averages them and adds the package columns:
+//
+//	Avg_MHz: average frequency, over the interval
+//	Busy%:   time not idle, i.e. in C0
+//	Bzy_MHz: average frequency while busy
+//	TSC_MHz: frequency of the time stamp counter
+//	CPU%cN:  time in core C-state N
+//	Pkg%pcN: time in package C-state N
+//	PkgWatt: package power, from RAPL
+//
+//	Columns whose MSRs cannot be read are left out. The package columns are
+//	of the package of the first CPU.
+//
+//	The msr module must be loaded.
+//
+// Options:
+//
+//	-p: CPUs to monitor, e.g. 0-3,7 (default all)
+//	-i: interval (default 5s)
+//	-n: number of intervals to print; 0 prints until interrupted
+//	-s: print only the summary row
+//
+// Example:
+//
+//	turbostat -i 1s -n 10 -s
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/u-root/u-root/pkg/msr"
+)
+
+var (
+	cpuList  = flag.String("p", "", "CPUs to monitor, e.g. 0-3,7 (default all)")
+	interval = flag.Duration("i", 5*time.Second, "interval")
+	count    = flag.Int("n", 0, "number of intervals to print; 0 prints until interrupted")
+	summary  = flag.Bool("s", false, "print only the summary row")
+)
+
+var errUsage = errors.New("usage: turbostat [-p CPUS] [-i INTERVAL] [-n COUNT] [-s]")
+
+var (
+	tsc       = msr.Names["IA32_TIME_STAMP_COUNTER"]
+	aperf     = msr.Names["IA32_APERF"]
+	mperf     = msr.Names["IA32_MPERF"]
+	powerUnit = msr.Names["MSR_RAPL_POWER_UNIT"]
+	pkgEnergy = msr.Names["MSR_PKG_ENERGY_STATUS"]
+)
+
+// counter is a C-state residency counter, which counts at the rate of the
+// TSC.
+type counter struct {
+	name string
+	msr  msr.MSR
+	pkg  bool
+}
+
+var counters = []counter{
+	{name: "CPU%c3", msr: msr.Names["MSR_CORE_C3_RESIDENCY"]},
+	{name: "CPU%c6", msr: msr.Names["MSR_CORE_C6_RESIDENCY"]},
+	{name: "CPU%c7", msr: msr.Names["MSR_CORE_C7_RESIDENCY"]},
+	{name: "Pkg%pc2", msr: msr.Names["MSR_PKG_C2_RESIDENCY"], pkg: true},
+	{name: "Pkg%pc3", msr: msr.Names["MSR_PKG_C3_RESIDENCY"], pkg: true},
+	{name: "Pkg%pc6", msr: msr.Names["MSR_PKG_C6_RESIDENCY"], pkg: true},
+	{name: "Pkg%pc7", msr: msr.Names["MSR_PKG_C7_RESIDENCY"], pkg: true},
+}
+
+// readMSR reads an MSR of CPUs. Tests replace it.
+var readMSR = func(m msr.MSR, cpus msr.CPUs) ([]uint64, []error) {
+	return m.Read(cpus)
+}
+
+// sample is the counters of the CPUs at a time.
+type sample struct {
+	time time.Time
+
+	// vals are the values of the MSRs, one per CPU, or only of the first
+	// CPU for package MSRs. MSRs that cannot be read have none.
+	vals map[msr.MSR][]uint64
+}
+
+// read samples the counters of cpus. The TSC, APERF and MPERF must be
+// readable; the other counters are left out if they are not.
+func read(cpus msr.CPUs, energy bool) (sample, error) {
+	s := sample{time: time.Now(), vals: map[msr.MSR][]uint64{}}
+	for _, m := range []msr.MSR{tsc, aperf, mperf} {
+		v, errs := readMSR(m, cpus)
+		if errs != nil {
+			return s, fmt.Errorf("reading %v: %v", m, errs)
+		}
+		s.vals[m] = v
+	}
+	for _, c := range counters {
+		on := cpus
+		if c.pkg {
+			on = cpus[:1]
+		}
+		if v, errs := readMSR(c.msr, on); errs == nil {
+			s.vals[c.msr] = v
+		}
+	}
+	if energy {
+		if v, errs := readMSR(pkgEnergy, cpus[:1]); errs == nil {
+			s.vals[pkgEnergy] = v
+		}
+	}
+	return s, nil
+}
+
+// energyUnit returns the unit of the RAPL energy counters in joules, or 0
+// if there is no RAPL.
+func energyUnit(cpus msr.CPUs) float64 {
+	v, errs := readMSR(powerUnit, cpus[:1])
+	if errs != nil {
+		return 0
+	}
+	return 1 / float64(uint64(1)<<(v[0]>>8&0x1f))
+}
+
+// report prints the rows of cpus over the interval from a to b. joules is
+// the unit of the energy counter.
+func report(out io.Writer, cpus msr.CPUs, a, b sample, joules float64, summaryOnly bool) error {
+	secs := b.time.Sub(a.time).Seconds()
+	if secs <= 0 {
+		return fmt.Errorf("interval from %v to %v is empty", a.time, b.time)
+	}
+	delta := func(m msr.MSR, i int) float64 {
+		return float64(b.vals[m][i] - a.vals[m][i])
+	}
+	var core, pkg []counter
+	for _, c := range counters {
+		if len(a.vals[c.msr]) == 0 || len(b.vals[c.msr]) == 0 {
+			continue
+		}
+		if c.pkg {
+			pkg = append(pkg, c)
+		} else {
+			core = append(core, c)
+		}
+	}
+
+	header := []string{"CPU", "Avg_MHz", "Busy%", "Bzy_MHz", "TSC_MHz"}
+	for _, c := range core {
+		header = append(header, c.name)
+	}
+	rows := make([][]float64, len(cpus))
+	avg := make([]float64, len(header)-1)
+	for i := range cpus {
+		t, ap, mp := delta(tsc, i), delta(aperf, i), delta(mperf, i)
+		if t == 0 {
+			return fmt.Errorf("TSC of CPU %d did not count", cpus[i])
+		}
+		row := []float64{ap / secs / 1e6, 100 * mp / t, 0, t / secs / 1e6}
+		if mp > 0 {
+			row[2] = t / secs / 1e6 * ap / mp
+		}
+		for _, c := range core {
+			row = append(row, 100*delta(c.msr, i)/t)
+		}
+		for j, v := range row {
+			avg[j] += v / float64(len(cpus))
+		}
+		rows[i] = row
+	}
+
+	// The package columns are only in the summary row.
+	for _, c := range pkg {
+		header = append(header, c.name)
+		avg = append(avg, 100*delta(c.msr, 0)/delta(tsc, 0))
+	}
+	if joules > 0 && len(a.vals[pkgEnergy]) > 0 && len(b.vals[pkgEnergy]) > 0 {
+		// The energy counter is 32 bits, and wraps around.
+		e := uint32(b.vals[pkgEnergy][0] - a.vals[pkgEnergy][0])
+		header = append(header, "PkgWatt")
+		avg = append(avg, float64(e)*joules/secs)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "%s\t\n", strings.Join(header, "\t"))
+	printRow(tw, "-", header[1:], avg)
+	if !summaryOnly {
+		for i, row := range rows {
+			printRow(tw, fmt.Sprint(cpus[i]), header[1:], row)
+		}
+	}
+	return tw.Flush()
+}
+
+// printRow prints the values of a row, frequencies in whole MHz and the
+// others with 2 decimals.
+func printRow(w io.Writer, cpu string, header []string, row []float64) {
+	s := []string{cpu}
+	for i, v := range row {
+		if strings.HasSuffix(header[i], "MHz") {
+			s = append(s, fmt.Sprintf("%.0f", v))
+		} else {
+			s = append(s, fmt.Sprintf("%.2f", v))
+		}
+	}
+	fmt.Fprintf(w, "%s\t\n", strings.Join(s, "\t"))
+}
+
+func run(out io.Writer) error {
+	if flag.NArg() != 0 || *interval <= 0 || *count < 0 {
+		return errUsage
+	}
+	var (
+		cpus msr.CPUs
+		err  error
+	)
+	if *cpuList == "" {
+		cpus, err = msr.AllCPUs()
+	} else {
+		cpus, err = msr.ParseCPUs(*cpuList)
+	}
+	if err != nil {
+		return err
+	}
+	if len(cpus) == 0 {
+		return fmt.Errorf("no CPUs to monitor")
+	}
+
+	joules := energyUnit(cpus)
+	prev, err := read(cpus, joules > 0)
+	if err != nil {
+		return err
+	}
+	for i := 0; *count == 0 || i < *count; i++ {
+		time.Sleep(*interval)
+		cur, err := read(cpus, joules > 0)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if err := report(out, cpus, prev, cur, joules, *summary); err != nil {
+			return err
+		}
+		prev = cur
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("turbostat: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
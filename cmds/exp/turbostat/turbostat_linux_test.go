@@ -0,0 +1,150 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/msr"
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+var (
+	c6    = msr.Names["MSR_CORE_C6_RESIDENCY"]
+	pkgC6 = msr.Names["MSR_PKG_C6_RESIDENCY"]
+)
+
+func TestReport(t *testing.T) {
+	start := time.Unix(1000, 0)
+	a := sample{time: start, vals: map[msr.MSR][]uint64{
+		tsc:       {0, 0},
+		aperf:     {0, 0},
+		mperf:     {0, 0},
+		c6:        {0, 0},
+		pkgC6:     {0},
+		pkgEnergy: {1<<32 - 81920},
+	}}
+	b := sample{time: start.Add(time.Second), vals: map[msr.MSR][]uint64{
+		tsc:       {2e9, 2e9},
+		aperf:     {1e9, 0},
+		mperf:     {5e8, 0},
+		c6:        {1e9, 2e9},
+		pkgC6:     {1e9},
+		pkgEnergy: {81920},
+	}}
+	cpus := msr.CPUs{0, 1}
+	const joules = 1.0 / (1 << 14)
+
+	for _, tt := range []struct {
+		desc    string
+		summary bool
+		want    [][]string
+	}{
+		{
+			desc: "all",
+			want: [][]string{
+				{"CPU", "Avg_MHz", "Busy%", "Bzy_MHz", "TSC_MHz", "CPU%c6", "Pkg%pc6", "PkgWatt"},
+				{"-", "500", "12.50", "2000", "2000", "75.00", "50.00", "10.00"},
+				{"0", "1000", "25.00", "4000", "2000", "50.00"},
+				{"1", "0", "0.00", "0", "2000", "100.00"},
+			},
+		},
+		{
+			desc:    "summary",
+			summary: true,
+			want: [][]string{
+				{"CPU", "Avg_MHz", "Busy%", "Bzy_MHz", "TSC_MHz", "CPU%c6", "Pkg%pc6", "PkgWatt"},
+				{"-", "500", "12.50", "2000", "2000", "75.00", "50.00", "10.00"},
+			},
+		},
+	} {
+		var out bytes.Buffer
+		if err := report(&out, cpus, a, b, joules, tt.summary); err != nil {
+			t.Fatalf("%s: report = %v", tt.desc, err)
+		}
+		var got [][]string
+		for _, l := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			got = append(got, strings.Fields(l))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: report printed\n%s\nwant %q", tt.desc, out.String(), tt.want)
+		}
+	}
+
+	// Without RAPL, there is no power.
+	var out bytes.Buffer
+	if err := report(&out, cpus, a, b, 0, true); err != nil || strings.Contains(out.String(), "PkgWatt") {
+		t.Errorf("report without RAPL = %v, printed %q, want no PkgWatt", err, out.String())
+	}
+	if err := report(&out, cpus, a, a, joules, false); err == nil {
+		t.Errorf("report of an empty interval succeeded")
+	}
+}
+
+func TestRead(t *testing.T) {
+	defer func(f func(msr.MSR, msr.CPUs) ([]uint64, []error)) { readMSR = f }(readMSR)
+	readMSR = func(m msr.MSR, cpus msr.CPUs) ([]uint64, []error) {
+		switch m {
+		case tsc, aperf, mperf, c6:
+			return make([]uint64, len(cpus)), nil
+		case pkgEnergy, pkgC6:
+			if len(cpus) != 1 {
+				t.Errorf("package MSR %v read on CPUs %v, want one", m, cpus)
+			}
+			return []uint64{1}, nil
+		}
+		return nil, []error{errors.New("no such MSR")}
+	}
+
+	s, err := read(msr.CPUs{0, 1}, true)
+	if err != nil {
+		t.Fatalf("read = %v", err)
+	}
+	var got []msr.MSR
+	for m := range s.vals {
+		got = append(got, m)
+	}
+	if len(got) != 6 || s.vals[c6] == nil || s.vals[pkgEnergy] == nil || s.vals[msr.Names["MSR_CORE_C3_RESIDENCY"]] != nil {
+		t.Errorf("read MSRs %v, want TSC, APERF, MPERF, core and package C6 and energy", got)
+	}
+	if s, _ := read(msr.CPUs{0}, false); s.vals[pkgEnergy] != nil {
+		t.Errorf("read the energy counter without RAPL")
+	}
+
+	readMSR = func(m msr.MSR, cpus msr.CPUs) ([]uint64, []error) {
+		return nil, []error{errors.New("no msr module")}
+	}
+	if _, err := read(msr.CPUs{0}, true); err == nil {
+		t.Errorf("read without the TSC succeeded")
+	}
+	if u := energyUnit(msr.CPUs{0}); u != 0 {
+		t.Errorf("energyUnit without RAPL = %v, want 0", u)
+	}
+}
+
+func TestTurbostat(t *testing.T) {
+	for _, args := range [][]string{
+		{"foo"},
+		{"-i", "0"},
+		{"-n", "-1"},
+		{"-p", "2-1"},
+	} {
+		if err := testutil.IsExitCode(testutil.Command(t, args...).Run(), 1); err != nil {
+			t.Errorf("turbostat %v: %v", args, err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// uuidgen prints new UUIDs.
+//
+// Synopsis:
+//
+//	uuidgen [-r | -t] [-C COUNT]
+//	uuidgen -m | -s -n NAMESPACE -N NAME
+//
+// Description:
+//
+//	uuidgen prints a random (version 4) UUID, or a time-based (version 1)
+//	one, or one of the MD5 (version 3) or SHA-1 (version 5) hash of NAME
+//	in NAMESPACE, which are the same for the same NAME.
+//
+//	NAMESPACE is a UUID, or one of @dns, @url, @oid and @x500.
+//
+// Options:
+//
+//	-r, --random:    print random UUIDs, the default
+//	-t, --time:      print time-based UUIDs, of the time and a hardware
+//	                 address of the host
+//	-m, --md5:       print the MD5 UUID of NAME
+//	-s, --sha1:      print the SHA-1 UUID of NAME
+//	-n, --namespace: the NAMESPACE of NAME
+//	-N, --name:      the NAME to hash
+//	-C, --count:     print COUNT UUIDs, a line each
+//
+// Example:
+//
+//	$ uuidgen
+//	5a2a26a6-bbc8-4a3c-8f3e-8c3bd0b1d5a7
+//	$ uuidgen -s -n @dns -N node1.lab.example.com
+//	01bdbf25-3a70-5a0f-a3c6-01f371621f86
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/google/uuid"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	random    = flag.BoolP("random", "r", false, "print random UUIDs")
+	timeBased = flag.BoolP("time", "t", false, "print time-based UUIDs")
+	md5       = flag.BoolP("md5", "m", false, "print the MD5 UUID of NAME")
+	sha1      = flag.BoolP("sha1", "s", false, "print the SHA-1 UUID of NAME")
+	namespace = flag.StringP("namespace", "n", "", "the `NAMESPACE` of NAME: a UUID, @dns, @url, @oid or @x500")
+	name      = flag.StringP("name", "N", "", "the `NAME` to hash")
+	count     = flag.IntP("count", "C", 1, "print `COUNT` UUIDs")
+)
+
+var errUsage = errors.New("usage: uuidgen [-r | -t] [-C COUNT] | -m | -s -n NAMESPACE -N NAME")
+
+var namespaces = map[string]uuid.UUID{
+	"@dns":  uuid.NameSpaceDNS,
+	"@url":  uuid.NameSpaceURL,
+	"@oid":  uuid.NameSpaceOID,
+	"@x500": uuid.NameSpaceX500,
+}
+
+// parseNamespace parses a namespace UUID, or the name of a well-known one.
+func parseNamespace(s string) (uuid.UUID, error) {
+	if u, ok := namespaces[s]; ok {
+		return u, nil
+	}
+	u, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid namespace %q", s)
+	}
+	return u, nil
+}
+
+// generator returns the function that makes the UUIDs the flags ask for.
+func generator() (func() (uuid.UUID, error), error) {
+	kinds := 0
+	for _, b := range []bool{*random, *timeBased, *md5, *sha1} {
+		if b {
+			kinds++
+		}
+	}
+	hashed := *md5 || *sha1
+	if kinds > 1 || *count < 1 || hashed != (*namespace != "" || *name != "") {
+		return nil, errUsage
+	}
+	if !hashed {
+		if *timeBased {
+			return uuid.NewUUID, nil
+		}
+		return uuid.NewRandom, nil
+	}
+
+	if *namespace == "" || *name == "" {
+		return nil, errUsage
+	}
+	ns, err := parseNamespace(*namespace)
+	if err != nil {
+		return nil, err
+	}
+	h := uuid.NewSHA1
+	if *md5 {
+		h = uuid.NewMD5
+	}
+	return func() (uuid.UUID, error) { return h(ns, []byte(*name)), nil }, nil
+}
+
+func run(out io.Writer, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	gen, err := generator()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < *count; i++ {
+		u, err := gen()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, u)
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("uuidgen: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdout, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
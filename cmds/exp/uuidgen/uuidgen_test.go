@@ -0,0 +1,67 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		random, time, md5, sha1 bool
+		namespace, name         string
+		count                   int
+		args                    []string
+		want                    string
+		version                 uuid.Version
+		err                     error
+	}{
+		{count: 3, version: 4},
+		{random: true, count: 1, version: 4},
+		{time: true, count: 2, version: 1},
+		{md5: true, namespace: "@dns", name: "example.com", count: 1, want: "9073926b-929f-31c2-abc9-fad77ae3e8eb\n"},
+		{sha1: true, namespace: "6ba7b810-9dad-11d1-80b4-00c04fd430c8", name: "example.com", count: 1, want: "cfbff0d1-9375-5685-968c-48ce8b15ae17\n"},
+		{sha1: true, namespace: "@dns", count: 1, err: errUsage},
+		{random: true, time: true, count: 1, err: errUsage},
+		{name: "example.com", count: 1, err: errUsage},
+		{count: 0, err: errUsage},
+		{count: 1, args: []string{"x"}, err: errUsage},
+	} {
+		*random, *timeBased, *md5, *sha1 = tt.random, tt.time, tt.md5, tt.sha1
+		*namespace, *name, *count = tt.namespace, tt.name, tt.count
+		var out bytes.Buffer
+		if err := run(&out, tt.args); err != tt.err {
+			t.Errorf("%+v: run = %v, want %v", tt, err, tt.err)
+			continue
+		}
+		if tt.want != "" && out.String() != tt.want {
+			t.Errorf("%+v: printed %q, want %q", tt, out.String(), tt.want)
+		}
+		if tt.version == 0 {
+			continue
+		}
+		lines := strings.Fields(out.String())
+		if len(lines) != tt.count {
+			t.Errorf("%+v: printed %d UUIDs, want %d", tt, len(lines), tt.count)
+		}
+		for _, l := range lines {
+			u, err := uuid.Parse(l)
+			if err != nil || u.Version() != tt.version || u.Variant() != uuid.RFC4122 {
+				t.Errorf("%+v: printed %q, %v, want a version %d UUID", tt, l, err, tt.version)
+			}
+		}
+		if len(lines) == 2 && lines[0] == lines[1] {
+			t.Errorf("%+v: printed the same UUID twice", tt)
+		}
+	}
+
+	if _, err := parseNamespace("@nis"); err == nil {
+		t.Errorf("parseNamespace(@nis) = nil, want an error")
+	}
+}
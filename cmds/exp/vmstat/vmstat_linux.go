@@ -0,0 +1,254 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// vmstat prints statistics of processes, memory, swap, I/O, the system and
+// the CPUs.
+//
+// Synopsis:
+//
+//	vmstat [-S UNIT] [DELAY [COUNT]]
+//
+// Description:
+//
+//	vmstat prints a row of statistics, and with a delay, another one
+//	every delay seconds, count times or until interrupted. The first row
+//	is averaged since boot, the others over the delay:
+//
+//	procs:  r: runnable tasks, b: tasks blocked on I/O
+//	memory: swpd: swap used, free: free, buff: buffers, cache: page cache
+//	swap:   si, so: swapped in and out, per second
+//	io:     bi, bo: KiB read from and written to block devices, per second
+//	system: in: interrupts, cs: context switches, per second
+//	cpu:    us: user, sy: system, id: idle, wa: waiting for I/O,
+//	        st: stolen by the hypervisor, in percent of the CPU time
+//
+// Options:
+//
+//	-S: unit of memory and swap: k (1000), K (1024, default), m (1000000)
+//	    or M (1048576) bytes
+//
+// Example:
+//
+//	vmstat 1 10
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var unit = flag.String("S", "K", "unit of memory and swap: k, K, m or M")
+
+var errUsage = errors.New("usage: vmstat [-S UNIT] [DELAY [COUNT]]")
+
+// procDir is where the statistics are read from. Tests change it.
+var procDir = "/proc"
+
+var units = map[string]uint64{
+	"k": 1000,
+	"K": 1024,
+	"m": 1000 * 1000,
+	"M": 1024 * 1024,
+}
+
+// sample is the statistics at a time. The counters count since boot.
+type sample struct {
+	uptime float64
+
+	running, blocked uint64
+	intr, ctxt       uint64
+	// cpu is the time of the CPUs in user, nice, system, idle, iowait,
+	// irq, softirq and steal, as in /proc/stat.
+	cpu [8]uint64
+
+	// mem is /proc/meminfo, in KiB.
+	mem map[string]uint64
+
+	// vm is /proc/vmstat.
+	vm map[string]uint64
+}
+
+// readFields calls f with the fields of every line of the file name of
+// procDir.
+func readFields(name string, f func([]string) error) error {
+	file, err := os.Open(filepath.Join(procDir, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	s := bufio.NewScanner(file)
+	for s.Scan() {
+		if fields := strings.Fields(s.Text()); len(fields) > 1 {
+			if err := f(fields); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	return s.Err()
+}
+
+// readMap reads a file of procDir of lines of a name and a value, like
+// meminfo and vmstat.
+func readMap(name string) (map[string]uint64, error) {
+	m := map[string]uint64{}
+	err := readFields(name, func(f []string) error {
+		v, err := strconv.ParseUint(f[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		m[strings.TrimSuffix(f[0], ":")] = v
+		return nil
+	})
+	return m, err
+}
+
+func read() (sample, error) {
+	var s sample
+	err := readFields("stat", func(f []string) error {
+		var err error
+		switch f[0] {
+		case "cpu":
+			for i := 1; i < len(f) && i <= len(s.cpu); i++ {
+				if s.cpu[i-1], err = strconv.ParseUint(f[i], 10, 64); err != nil {
+					return err
+				}
+			}
+		case "intr":
+			s.intr, err = strconv.ParseUint(f[1], 10, 64)
+		case "ctxt":
+			s.ctxt, err = strconv.ParseUint(f[1], 10, 64)
+		case "procs_running":
+			s.running, err = strconv.ParseUint(f[1], 10, 64)
+		case "procs_blocked":
+			s.blocked, err = strconv.ParseUint(f[1], 10, 64)
+		}
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+	if s.mem, err = readMap("meminfo"); err != nil {
+		return s, err
+	}
+	if s.vm, err = readMap("vmstat"); err != nil {
+		return s, err
+	}
+	err = readFields("uptime", func(f []string) error {
+		s.uptime, err = strconv.ParseFloat(f[0], 64)
+		return err
+	})
+	return s, err
+}
+
+func header(out io.Writer) {
+	fmt.Fprintln(out, "procs -----------memory---------- ---swap-- -----io---- -system-- ------cpu-----")
+	fmt.Fprintln(out, " r  b   swpd   free   buff  cache   si   so    bi    bo   in   cs us sy id wa st")
+}
+
+// report prints the row of the statistics b, averaged since a. pageSize is
+// in bytes and memUnit is the unit of memory and swap.
+func report(out io.Writer, a, b sample, pageSize, memUnit uint64) error {
+	secs := b.uptime - a.uptime
+	if secs <= 0 {
+		return fmt.Errorf("interval from %v to %v seconds of uptime is empty", a.uptime, b.uptime)
+	}
+	rate := func(d uint64) uint64 {
+		return uint64(float64(d)/secs + 0.5)
+	}
+	mem := func(kib uint64) uint64 {
+		return kib * 1024 / memUnit
+	}
+	swapped := func(name string) uint64 {
+		return rate((b.vm[name] - a.vm[name]) * pageSize / memUnit)
+	}
+
+	var cpu [8]uint64
+	var total uint64
+	for i := range cpu {
+		cpu[i] = b.cpu[i] - a.cpu[i]
+		total += cpu[i]
+	}
+	pct := func(ticks uint64) uint64 {
+		if total == 0 {
+			return 0
+		}
+		return (100*ticks + total/2) / total
+	}
+	_, err := fmt.Fprintf(out, "%2d %2d %6d %6d %6d %6d %4d %4d %5d %5d %4d %4d %2d %2d %2d %2d %2d\n",
+		b.running, b.blocked,
+		mem(b.mem["SwapTotal"]-b.mem["SwapFree"]), mem(b.mem["MemFree"]),
+		mem(b.mem["Buffers"]), mem(b.mem["Cached"]+b.mem["SReclaimable"]),
+		swapped("pswpin"), swapped("pswpout"),
+		rate(b.vm["pgpgin"]-a.vm["pgpgin"]), rate(b.vm["pgpgout"]-a.vm["pgpgout"]),
+		rate(b.intr-a.intr), rate(b.ctxt-a.ctxt),
+		pct(cpu[0]+cpu[1]), pct(cpu[2]+cpu[5]+cpu[6]), pct(cpu[3]), pct(cpu[4]), pct(cpu[7]))
+	return err
+}
+
+func run(out io.Writer) error {
+	memUnit, ok := units[*unit]
+	if !ok || flag.NArg() > 2 {
+		return errUsage
+	}
+	var (
+		delay time.Duration
+		count = 1
+	)
+	if flag.NArg() > 0 {
+		d, err := strconv.ParseUint(flag.Arg(0), 10, 32)
+		if err != nil || d == 0 {
+			return errUsage
+		}
+		delay, count = time.Duration(d)*time.Second, 0
+	}
+	if flag.NArg() > 1 {
+		c, err := strconv.Atoi(flag.Arg(1))
+		if err != nil || c < 1 {
+			return errUsage
+		}
+		count = c
+	}
+
+	cur, err := read()
+	if err != nil {
+		return err
+	}
+	header(out)
+	// The first row is since boot, when all counters were 0.
+	if err := report(out, sample{}, cur, uint64(os.Getpagesize()), memUnit); err != nil {
+		return err
+	}
+	for i := 1; count == 0 || i < count; i++ {
+		time.Sleep(delay)
+		prev := cur
+		if cur, err = read(); err != nil {
+			return err
+		}
+		if err := report(out, prev, cur, uint64(os.Getpagesize()), memUnit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("vmstat: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
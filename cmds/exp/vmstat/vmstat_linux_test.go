@@ -0,0 +1,101 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func writeProc(t *testing.T, files map[string]string) {
+	t.Helper()
+	procDir = t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(procDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	defer func(dir string) { procDir = dir }(procDir)
+	writeProc(t, map[string]string{
+		"stat": `cpu  100 20 30 800 40 5 5 0 0 0
+cpu0 100 20 30 800 40 5 5 0 0 0
+intr 12345 1 2 3
+ctxt 6789
+btime 1646400000
+processes 1000
+procs_running 2
+procs_blocked 1
+`,
+		"meminfo": `MemTotal:        8000000 kB
+MemFree:         4000000 kB
+Buffers:          100000 kB
+Cached:          1000000 kB
+SReclaimable:      50000 kB
+SwapTotal:       2000000 kB
+SwapFree:        1500000 kB
+`,
+		"vmstat": `pgpgin 4000
+pgpgout 8000
+pswpin 10
+pswpout 20
+`,
+		"uptime": "100.00 350.00\n",
+	})
+
+	s, err := read()
+	if err != nil {
+		t.Fatalf("read = %v", err)
+	}
+	if s.uptime != 100 || s.running != 2 || s.blocked != 1 || s.intr != 12345 || s.ctxt != 6789 ||
+		s.cpu != [8]uint64{100, 20, 30, 800, 40, 5, 5, 0} || s.mem["SwapFree"] != 1500000 || s.vm["pswpout"] != 20 {
+		t.Errorf("read = %+v", s)
+	}
+
+	var out bytes.Buffer
+	if err := report(&out, sample{}, s, 4096, 1024); err != nil {
+		t.Fatalf("report = %v", err)
+	}
+	want := []string{"2", "1", "500000", "4000000", "100000", "1050000", "0", "1", "40", "80", "123", "68", "12", "4", "80", "4", "0"}
+	if got := strings.Fields(out.String()); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("report = %q, want %q", got, want)
+	}
+
+	if err := report(&out, s, s, 4096, 1024); err == nil {
+		t.Errorf("report of an empty interval succeeded")
+	}
+
+	os.Remove(filepath.Join(procDir, "vmstat"))
+	if _, err := read(); err == nil {
+		t.Errorf("read without vmstat succeeded")
+	}
+}
+
+func TestVmstat(t *testing.T) {
+	for _, args := range [][]string{
+		{"-S", "G"},
+		{"0"},
+		{"1", "0"},
+		{"1", "2", "3"},
+	} {
+		if err := testutil.IsExitCode(testutil.Command(t, args...).Run(), 1); err != nil {
+			t.Errorf("vmstat %v: %v", args, err)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
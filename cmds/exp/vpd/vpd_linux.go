@@ -0,0 +1,179 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// vpd reads and writes the VPD (Vital Product Data) of coreboot, e.g. of
+// ChromeOS devices: key-value pairs in the RO_VPD and RW_VPD regions of the
+// flash.
+//
+// Synopsis:
+//
+//	vpd [-f FILE] [-i REGION] [-O] [-s KEY=VALUE]... [-d KEY]... [-g KEY] [-l]
+//
+// Description:
+//
+//	Keys are deleted, if -O, all of them, and then set, and the region is
+//	written if any was. Then the value of a key is printed with -g, or all
+//	keys and values with -l, the default, as "KEY"="VALUE" lines.
+//
+//	Without -f, the flash is read, and written, with flashrom. The FMAP
+//	of the flash image locates the region.
+//
+// Options:
+//
+//	-f: flash image to read and write, instead of the flash
+//	-i: region of the VPD (default RO_VPD)
+//	-p: flashrom programmer (default internal)
+//	-O: delete all keys
+//	-s: set KEY to VALUE; may be repeated
+//	-d: delete KEY; may be repeated
+//	-g: print the value of KEY
+//	-l: print all keys and values
+//
+// Example:
+//
+//	vpd -i RW_VPD -s check_enrollment=0 -d block_devmode
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/u-root/u-root/pkg/vpd"
+)
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+var (
+	file       = flag.String("f", "", "flash image to read and write, instead of the flash")
+	region     = flag.String("i", "RO_VPD", "region of the VPD")
+	programmer = flag.String("p", "internal", "flashrom programmer")
+	erase      = flag.Bool("O", false, "delete all keys")
+	get        = flag.String("g", "", "print the value of KEY")
+	list       = flag.Bool("l", false, "print all keys and values")
+	sets       stringList
+	deletes    stringList
+)
+
+func init() {
+	flag.Var(&sets, "s", "set KEY=VALUE; may be repeated")
+	flag.Var(&deletes, "d", "delete KEY; may be repeated")
+}
+
+var errUsage = errors.New("usage: vpd [-f FILE] [-i REGION] [-O] [-s KEY=VALUE]... [-d KEY]... [-g KEY] [-l]")
+
+// flashrom runs flashrom with args. The flash is not to be left half written,
+// so flashrom is not interrupted by Ctrl-C.
+func flashrom(args ...string) error {
+	args = append([]string{"-p", *programmer, "--fmap"}, args...)
+	cmd := exec.Command("flashrom", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	signal.Ignore(os.Interrupt)
+	defer signal.Reset(os.Interrupt)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("flashrom %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return nil
+}
+
+func run(out io.Writer) error {
+	if flag.NArg() != 0 {
+		return errUsage
+	}
+	path := *file
+	if path == "" {
+		dir, err := os.MkdirTemp("", "vpd")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		path = filepath.Join(dir, "flash.bin")
+		if err := flashrom("-i", "FMAP", "-i", *region, "-r", path); err != nil {
+			return err
+		}
+	}
+	image, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	r, err := vpd.ReadImage(image, *region)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *region, err)
+	}
+
+	if *erase {
+		r.Entries = nil
+	}
+	for _, k := range deletes {
+		if !r.Delete(k) {
+			return fmt.Errorf("%s: key %q not found", *region, k)
+		}
+	}
+	for _, s := range sets {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("%q is not KEY=VALUE", s)
+		}
+		r.Set(k, []byte(v))
+	}
+	if *erase || len(deletes) > 0 || len(sets) > 0 {
+		if err := vpd.WriteImage(image, *region, r); err != nil {
+			return fmt.Errorf("%s: %w", *region, err)
+		}
+		if err := os.WriteFile(path, image, 0o644); err != nil {
+			return err
+		}
+		if *file == "" {
+			if err := flashrom("-i", *region, "--noverify-all", "-w", path); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case *get != "":
+		v, ok := r.Get(*get)
+		if !ok {
+			return fmt.Errorf("%s: key %q not found", *region, *get)
+		}
+		_, err := out.Write(v)
+		return err
+	case *list || (!*erase && len(deletes) == 0 && len(sets) == 0):
+		for _, e := range r.Entries {
+			if _, err := fmt.Fprintf(out, "%q=%q\n", e.Key, e.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("vpd: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+// flashImage returns a flash image with an FMAP of RO_VPD and RW_VPD.
+func flashImage() []byte {
+	b := bytes.Repeat([]byte{0xff}, 4096)
+	h := []byte("__FMAP__\x01\x01")
+	h = append(h, make([]byte, 44)...)
+	h = binary.LittleEndian.AppendUint16(h, 2)
+	for i, name := range []string{"RO_VPD", "RW_VPD"} {
+		h = binary.LittleEndian.AppendUint32(h, uint32(1024*(i+1)))
+		h = binary.LittleEndian.AppendUint32(h, 1024)
+		a := make([]byte, 34)
+		copy(a, name)
+		h = append(h, a...)
+	}
+	copy(b, h)
+	return b
+}
+
+func TestVPD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flash.bin")
+	if err := os.WriteFile(path, flashImage(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+		code int
+	}{
+		{name: "empty", args: []string{"-l"}},
+		{name: "set", args: []string{"-i", "RW_VPD", "-s", "serial_number=A12", "-s", "region=us", "-s", "empty="}},
+		{name: "list", args: []string{"-i", "RW_VPD"}, want: "\"serial_number\"=\"A12\"\n\"region\"=\"us\"\n\"empty\"=\"\"\n"},
+		{name: "get", args: []string{"-i", "RW_VPD", "-g", "region"}, want: "us"},
+		{name: "delete and set", args: []string{"-i", "RW_VPD", "-d", "empty", "-s", "region=eu", "-l"}, want: "\"serial_number\"=\"A12\"\n\"region\"=\"eu\"\n"},
+		{name: "other region", args: []string{"-g", "region"}, code: 1},
+		{name: "delete missing key", args: []string{"-i", "RW_VPD", "-d", "empty"}, code: 1},
+		{name: "not KEY=VALUE", args: []string{"-s", "key"}, code: 1},
+		{name: "no region", args: []string{"-i", "RW_LEGACY"}, code: 1},
+		{name: "erase", args: []string{"-i", "RW_VPD", "-O", "-l"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			cmd := testutil.Command(t, append([]string{"-f", path}, tt.args...)...)
+			cmd.Stdout = &stdout
+			err := cmd.Run()
+			if err := testutil.IsExitCode(err, tt.code); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("vpd %v printed %q, want %q", tt.args, stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
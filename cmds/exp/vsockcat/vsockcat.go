@@ -0,0 +1,181 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// vsockcat relays stdin and stdout over an AF_VSOCK connection, for a
+// virtual machine and its host to exchange files and commands without a
+// network.
+//
+// Synopsis:
+//
+//	vsockcat [-v] [-e COMMAND] CID:PORT
+//	vsockcat -l [-k] [-v] [-e COMMAND] PORT
+//
+// Description:
+//
+//	vsockcat connects to PORT of the context ID CID, which may also be
+//	hypervisor, local or host, or with -l waits for a connection on PORT.
+//	It then copies stdin to the connection and the connection to stdout.
+//	When stdin ends, the connection is shut down for writing, and vsockcat
+//	exits once the peer has closed its side.
+//
+//	With -e, COMMAND is run with its stdin and stdout connected to the
+//	connection instead. COMMAND is split into words at spaces; it is not
+//	run by a shell.
+//
+// Options:
+//
+//	-l, --listen:    wait for a connection on PORT of any context ID
+//	-k, --keep-open: with -l, serve connections one after another until
+//	                 interrupted, instead of only the first
+//	-e, --exec:      run COMMAND for each connection
+//	-v, --verbose:   log connections
+//
+// Example:
+//
+//	# In the guest: serve a shell to the host.
+//	vsockcat -l -k -e "gosh" 1024
+//	# On the host, with the guest's CID 3:
+//	vsockcat 3:1024
+//
+//	# Copy a file from the guest to the host.
+//	vsockcat -l 1025 > dmesg.txt      # host
+//	dmesg | vsockcat host:1025         # guest
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"github.com/u-root/u-root/pkg/vsock"
+)
+
+var (
+	listen   = flag.BoolP("listen", "l", false, "wait for a connection on PORT of any context ID")
+	keepOpen = flag.BoolP("keep-open", "k", false, "with -l, serve connections one after another")
+	command  = flag.StringP("exec", "e", "", "run `COMMAND` for each connection")
+	verbose  = flag.BoolP("verbose", "v", false, "log connections")
+
+	errUsage = errors.New("usage: vsockcat [-v] [-e COMMAND] CID:PORT | vsockcat -l [-k] [-v] [-e COMMAND] PORT")
+
+	// dial and listenVsock are replaced in tests, as there is no vsock
+	// loopback without the vsock_loopback module.
+	dial        = vsock.Dial
+	listenVsock = vsock.Listen
+)
+
+// closeWriter is implemented by connections that can be shut down for
+// writing only.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// relay copies in to c and c to out. When in ends, c is shut down for
+// writing, for the peer to read the end of it. relay returns once the peer
+// has closed its side, without waiting for in to end.
+func relay(c net.Conn, in io.Reader, out io.Writer) error {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(c, in)
+		if cw, ok := c.(closeWriter); ok && err == nil {
+			err = cw.CloseWrite()
+		}
+		errc <- err
+	}()
+	_, err := io.Copy(out, c)
+	select {
+	case werr := <-errc:
+		if err == nil {
+			err = werr
+		}
+	default:
+	}
+	return err
+}
+
+// execute runs args with its stdin and stdout connected to c.
+func execute(c net.Conn, args []string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = c, c, os.Stderr
+	return cmd.Run()
+}
+
+// handle relays in and out over c, or runs args for c, and closes c.
+func handle(c net.Conn, in io.Reader, out io.Writer, args []string) error {
+	defer c.Close()
+	if *verbose {
+		log.Printf("connected to %v", c.RemoteAddr())
+	}
+	if len(args) > 0 {
+		return execute(c, args)
+	}
+	return relay(c, in, out)
+}
+
+func run(in io.Reader, out io.Writer, args []string) error {
+	if len(args) != 1 || (*keepOpen && !*listen) {
+		return errUsage
+	}
+	var cmdArgs []string
+	if *command != "" {
+		if cmdArgs = strings.Fields(*command); len(cmdArgs) == 0 {
+			return errUsage
+		}
+	}
+
+	if !*listen {
+		a, err := vsock.ParseAddr(args[0])
+		if err != nil {
+			return err
+		}
+		c, err := dial(context.Background(), *a)
+		if err != nil {
+			return err
+		}
+		return handle(c, in, out, cmdArgs)
+	}
+
+	port, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid vsock port %q", args[0])
+	}
+	l, err := listenVsock(uint32(port))
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	if *verbose {
+		log.Printf("listening on %v", l.Addr())
+	}
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		err = handle(c, in, out, cmdArgs)
+		if !*keepOpen {
+			return err
+		}
+		if err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+func main() {
+	log.SetPrefix("vsockcat: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(os.Stdin, os.Stdout, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
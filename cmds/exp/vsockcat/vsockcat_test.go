@@ -0,0 +1,162 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/vsock"
+)
+
+// unixListener returns a listener on a UNIX socket standing in for vsock.
+func unixListener(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// replyAll accepts a connection on l, reads all it sends and replies with
+// it in upper case.
+func replyAll(l net.Listener) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer c.Close()
+		b, err := io.ReadAll(c)
+		if err == nil {
+			_, err = c.Write(bytes.ToUpper(b))
+		}
+		errc <- err
+	}()
+	return errc
+}
+
+func TestRelay(t *testing.T) {
+	l := unixListener(t)
+	errc := replyAll(l)
+	c, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var out bytes.Buffer
+	if err := relay(c, strings.NewReader("hello"), &out); err != nil {
+		t.Fatalf("relay = %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "HELLO" {
+		t.Errorf("relay wrote %q, want HELLO", out.String())
+	}
+}
+
+func TestRun(t *testing.T) {
+	defer func(d func(context.Context, vsock.Addr) (net.Conn, error), l func(uint32) (net.Listener, error)) {
+		dial, listenVsock = d, l
+		*listen, *keepOpen, *command = false, false, ""
+	}(dial, listenVsock)
+
+	for _, tt := range []struct {
+		desc    string
+		listen  bool
+		keep    bool
+		command string
+		args    []string
+		wantErr error
+	}{
+		{desc: "no address", wantErr: errUsage},
+		{desc: "two addresses", args: []string{"3:1024", "3:1025"}, wantErr: errUsage},
+		{desc: "keep open without listen", keep: true, args: []string{"3:1024"}, wantErr: errUsage},
+		{desc: "empty command", command: " ", args: []string{"3:1024"}, wantErr: errUsage},
+		{desc: "bad address", args: []string{"3"}},
+		{desc: "bad port", listen: true, args: []string{"host:1024"}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			*listen, *keepOpen, *command = tt.listen, tt.keep, tt.command
+			err := run(strings.NewReader(""), io.Discard, tt.args)
+			if err == nil || (tt.wantErr != nil && err != tt.wantErr) {
+				t.Errorf("run(%q) = %v, want %v", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("connect", func(t *testing.T) {
+		*listen, *keepOpen, *command = false, false, ""
+		l := unixListener(t)
+		errc := replyAll(l)
+		var got vsock.Addr
+		dial = func(_ context.Context, a vsock.Addr) (net.Conn, error) {
+			got = a
+			return net.Dial("unix", l.Addr().String())
+		}
+		var out bytes.Buffer
+		if err := run(strings.NewReader("ping"), &out, []string{"host:1024"}); err != nil {
+			t.Fatalf("run = %v", err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+		if want := (vsock.Addr{CID: vsock.CIDHost, Port: 1024}); got != want {
+			t.Errorf("dialed %v, want %v", got, want)
+		}
+		if out.String() != "PING" {
+			t.Errorf("run wrote %q, want PING", out.String())
+		}
+	})
+
+	t.Run("listen and exec", func(t *testing.T) {
+		cat, err := exec.LookPath("cat")
+		if err != nil {
+			t.Skip("no cat")
+		}
+		*listen, *keepOpen, *command = true, false, cat
+		l := unixListener(t)
+		var port uint32
+		listenVsock = func(p uint32) (net.Listener, error) {
+			port = p
+			return l, nil
+		}
+
+		errc := make(chan error, 1)
+		go func() { errc <- run(strings.NewReader(""), io.Discard, []string{"1024"}) }()
+
+		c, err := net.Dial("unix", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if _, err := c.Write([]byte("echo")); err != nil {
+			t.Fatal(err)
+		}
+		c.(*net.UnixConn).CloseWrite()
+		b, err := io.ReadAll(c)
+		if err != nil || string(b) != "echo" {
+			t.Errorf("-e cat replied %q, %v, want echo", b, err)
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("run = %v", err)
+		}
+		if port != 1024 {
+			t.Errorf("listened on port %d, want 1024", port)
+		}
+	})
+}
@@ -0,0 +1,413 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// wg configures WireGuard interfaces.
+//
+// Synopsis:
+//
+//	wg genkey
+//	wg pubkey < PRIVATE
+//	wg genpsk
+//	wg show [IFACE]
+//	wg showconf IFACE
+//	wg set IFACE [listen-port PORT] [fwmark MARK] [private-key FILE]
+//		[peer KEY [remove] [preshared-key FILE] [endpoint HOST:PORT]
+//		[persistent-keepalive SECONDS|off] [allowed-ips CIDR[,CIDR...]]]...
+//	wg setconf IFACE FILE
+//	wg addconf IFACE FILE
+//	wg up CONFIG
+//	wg down CONFIG
+//
+// Description:
+//
+//	genkey and genpsk print a new private or preshared key, and pubkey
+//	prints the public key of the private key read from stdin.
+//
+//	show prints the state of IFACE, or of all WireGuard interfaces, and
+//	showconf prints the configuration of IFACE in the format that setconf
+//	reads. setconf replaces the configuration of IFACE with the one in
+//	FILE, and addconf adds the peers in FILE to it; the wg-quick keys of
+//	FILE, such as Address, are ignored.
+//
+//	up and down work like wg-quick: CONFIG is a file, or a name for
+//	/etc/wireguard/CONFIG.conf, and the interface is named after it. up
+//	creates and configures the interface, assigns its addresses, adds routes
+//	to the allowed IPs of its peers and adds its DNS servers to
+//	/etc/resolv.conf. down removes all of that again. A default route is
+//	put in a table of its own, used by the packets that are not marked with
+//	the interface's firewall mark, so the tunnel's own packets still use the
+//	main table.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/wireguard"
+	"github.com/vishvananda/netlink"
+)
+
+var errUsage = errors.New("usage: wg genkey|pubkey|genpsk|show|showconf|set|setconf|addconf|up|down [ARGS...]")
+
+// configDir holds the configurations that up and down find by name.
+var configDir = "/etc/wireguard"
+
+func readKeyFile(path string) (wireguard.Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return wireguard.Key{}, err
+	}
+	return wireguard.ParseKey(strings.TrimSpace(string(b)))
+}
+
+// parseSet parses the arguments of wg set after the interface name.
+func parseSet(args []string) (*wireguard.Config, error) {
+	c := &wireguard.Config{}
+	var peer *wireguard.PeerConfig
+	for len(args) > 0 {
+		key := args[0]
+		args = args[1:]
+		if key == "remove" {
+			if peer == nil {
+				return nil, fmt.Errorf("remove must follow a peer")
+			}
+			peer.Remove = true
+			continue
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s needs a value", key)
+		}
+		v := args[0]
+		args = args[1:]
+		if key == "peer" {
+			k, err := wireguard.ParseKey(v)
+			if err != nil {
+				return nil, fmt.Errorf("peer: %v", err)
+			}
+			c.Peers = append(c.Peers, wireguard.PeerConfig{PublicKey: k})
+			peer = &c.Peers[len(c.Peers)-1]
+			continue
+		}
+		var err error
+		if peer == nil {
+			err = setDevice(c, key, v)
+		} else {
+			err = setPeer(peer, key, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return c, nil
+}
+
+func setDevice(c *wireguard.Config, key, v string) error {
+	switch key {
+	case "listen-port":
+		p, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return err
+		}
+		port := int(p)
+		c.ListenPort = &port
+	case "fwmark":
+		m := 0
+		if v != "off" {
+			u, err := strconv.ParseUint(v, 0, 32)
+			if err != nil {
+				return err
+			}
+			m = int(u)
+		}
+		c.FirewallMark = &m
+	case "private-key":
+		k, err := readKeyFile(v)
+		if err != nil {
+			return err
+		}
+		c.PrivateKey = &k
+	default:
+		return fmt.Errorf("unknown setting")
+	}
+	return nil
+}
+
+func setPeer(p *wireguard.PeerConfig, key, v string) error {
+	switch key {
+	case "preshared-key":
+		k, err := readKeyFile(v)
+		if err != nil {
+			return err
+		}
+		p.PresharedKey = &k
+	case "endpoint":
+		a, err := net.ResolveUDPAddr("udp", v)
+		if err != nil {
+			return err
+		}
+		p.Endpoint = a
+	case "persistent-keepalive":
+		s := 0
+		if v != "off" {
+			var err error
+			if s, err = strconv.Atoi(v); err != nil {
+				return err
+			}
+		}
+		d := time.Duration(s) * time.Second
+		p.PersistentKeepalive = &d
+	case "allowed-ips":
+		p.ReplaceAllowedIPs = true
+		for _, a := range strings.Split(v, ",") {
+			if a = strings.TrimSpace(a); a == "" {
+				continue
+			}
+			if !strings.Contains(a, "/") {
+				if ip := net.ParseIP(a); ip != nil && ip.To4() != nil {
+					a += "/32"
+				} else {
+					a += "/128"
+				}
+			}
+			_, n, err := net.ParseCIDR(a)
+			if err != nil {
+				return err
+			}
+			p.AllowedIPs = append(p.AllowedIPs, *n)
+		}
+	default:
+		return fmt.Errorf("unknown peer setting")
+	}
+	return nil
+}
+
+func ago(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%v ago", now.Sub(t).Round(time.Second))
+}
+
+// show writes the state of d the way wg show does. The private key is not
+// shown.
+func show(w io.Writer, d *wireguard.Device, now time.Time) {
+	fmt.Fprintf(w, "interface: %s\n", d.Name)
+	if !d.PublicKey.IsZero() {
+		fmt.Fprintf(w, "  public key: %s\n", d.PublicKey)
+	}
+	if !d.PrivateKey.IsZero() {
+		fmt.Fprintf(w, "  private key: (hidden)\n")
+	}
+	if d.ListenPort != 0 {
+		fmt.Fprintf(w, "  listening port: %d\n", d.ListenPort)
+	}
+	if d.FirewallMark != 0 {
+		fmt.Fprintf(w, "  fwmark: %#x\n", d.FirewallMark)
+	}
+	for _, p := range d.Peers {
+		fmt.Fprintf(w, "\npeer: %s\n", p.PublicKey)
+		if !p.PresharedKey.IsZero() {
+			fmt.Fprintf(w, "  preshared key: (hidden)\n")
+		}
+		if p.Endpoint != nil {
+			fmt.Fprintf(w, "  endpoint: %s\n", p.Endpoint)
+		}
+		ips := "(none)"
+		if len(p.AllowedIPs) > 0 {
+			var s []string
+			for _, n := range p.AllowedIPs {
+				s = append(s, n.String())
+			}
+			ips = strings.Join(s, ", ")
+		}
+		fmt.Fprintf(w, "  allowed ips: %s\n", ips)
+		fmt.Fprintf(w, "  latest handshake: %s\n", ago(p.LastHandshake, now))
+		if p.RxBytes != 0 || p.TxBytes != 0 {
+			fmt.Fprintf(w, "  transfer: %d B received, %d B sent\n", p.RxBytes, p.TxBytes)
+		}
+		if p.PersistentKeepalive != 0 {
+			fmt.Fprintf(w, "  persistent keepalive: every %v\n", p.PersistentKeepalive)
+		}
+	}
+}
+
+// interfaces returns the names of the WireGuard interfaces.
+func interfaces() ([]string, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, l := range links {
+		if l.Type() == "wireguard" {
+			names = append(names, l.Attrs().Name)
+		}
+	}
+	return names, nil
+}
+
+// quickConfig reads the wg-quick configuration CONFIG names, and returns the
+// interface name for it.
+func quickConfig(config string) (string, *wireguard.QuickConfig, error) {
+	path := config
+	if !strings.ContainsRune(config, '/') && !strings.HasSuffix(config, ".conf") {
+		path = filepath.Join(configDir, config+".conf")
+	}
+	name := strings.TrimSuffix(filepath.Base(path), ".conf")
+	if len(name) == 0 || len(name) > 15 {
+		return "", nil, fmt.Errorf("%s: interface name %q must have 1 to 15 characters", path, name)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	c, err := wireguard.ParseQuickConfig(f)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return name, c, nil
+}
+
+func readConfig(path string) (*wireguard.Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := wireguard.ParseQuickConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &c.Config, nil
+}
+
+func printKey(w io.Writer, gen func() (wireguard.Key, error)) error {
+	k, err := gen()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, k)
+	return err
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "genkey", "genpsk":
+		if len(args) != 0 {
+			return errUsage
+		}
+		if cmd == "genkey" {
+			return printKey(stdout, wireguard.GenerateKey)
+		}
+		return printKey(stdout, wireguard.GeneratePresharedKey)
+
+	case "pubkey":
+		if len(args) != 0 {
+			return errUsage
+		}
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		k, err := wireguard.ParseKey(strings.TrimSpace(line))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, k.PublicKey())
+		return err
+
+	case "show":
+		if len(args) > 1 {
+			return errUsage
+		}
+		names := args
+		if len(names) == 0 || names[0] == "all" {
+			var err error
+			if names, err = interfaces(); err != nil {
+				return err
+			}
+		}
+		for i, name := range names {
+			d, err := wireguard.Get(name)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				fmt.Fprintln(stdout)
+			}
+			show(stdout, d, time.Now())
+		}
+		return nil
+
+	case "showconf":
+		if len(args) != 1 {
+			return errUsage
+		}
+		d, err := wireguard.Get(args[0])
+		if err != nil {
+			return err
+		}
+		return wireguard.WriteConfig(stdout, d)
+
+	case "set":
+		if len(args) < 1 {
+			return errUsage
+		}
+		c, err := parseSet(args[1:])
+		if err != nil {
+			return err
+		}
+		return wireguard.Configure(args[0], c)
+
+	case "setconf", "addconf":
+		if len(args) != 2 {
+			return errUsage
+		}
+		c, err := readConfig(args[1])
+		if err != nil {
+			return err
+		}
+		if cmd == "addconf" {
+			c.ReplacePeers = false
+			for i := range c.Peers {
+				c.Peers[i].ReplaceAllowedIPs = false
+			}
+		}
+		return wireguard.Configure(args[0], c)
+
+	case "up", "down":
+		if len(args) != 1 {
+			return errUsage
+		}
+		name, c, err := quickConfig(args[0])
+		if err != nil {
+			return err
+		}
+		if cmd == "up" {
+			return wireguard.Up(name, c)
+		}
+		return wireguard.Down(name, c)
+	}
+	return errUsage
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		log.Fatalf("wg: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/wireguard"
+)
+
+const (
+	priv = "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk="
+	pub  = "HIgo9xNzJMWLKASShiTqIybxZ0U3wGLiUeJ1PKf8ykw="
+	peer = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+)
+
+func TestKeys(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"pubkey"}, strings.NewReader(priv+"\n"), &out); err != nil || out.String() != pub+"\n" {
+		t.Errorf("pubkey = %q, %v, want %q", out.String(), err, pub)
+	}
+	out.Reset()
+	if err := run([]string{"genkey"}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wireguard.ParseKey(strings.TrimSpace(out.String())); err != nil {
+		t.Errorf("genkey printed %q: %v", out.String(), err)
+	}
+	if err := run([]string{"genpsk", "x"}, nil, &out); err != errUsage {
+		t.Errorf("genpsk x = %v, want %v", err, errUsage)
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte(priv+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := parseSet(strings.Fields("listen-port 51820 private-key " + keyFile +
+		" peer " + peer + " endpoint 192.0.2.1:51820 persistent-keepalive 25 allowed-ips 10.0.0.0/24,192.0.2.7" +
+		" peer " + pub + " remove"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *c.ListenPort != 51820 || c.PrivateKey.String() != priv || c.ReplacePeers || len(c.Peers) != 2 {
+		t.Fatalf("config = %+v", c)
+	}
+	p := c.Peers[0]
+	if p.Endpoint.String() != "192.0.2.1:51820" || *p.PersistentKeepalive != 25*time.Second || !p.ReplaceAllowedIPs ||
+		len(p.AllowedIPs) != 2 || p.AllowedIPs[1].String() != "192.0.2.7/32" {
+		t.Errorf("peer = %+v", p)
+	}
+	if !c.Peers[1].Remove {
+		t.Errorf("second peer is not removed")
+	}
+
+	for _, bad := range []string{"remove", "listen-port", "listen-port x", "peer bogus", "bogus 1", "peer " + peer + " fwmark 1"} {
+		if _, err := parseSet(strings.Fields(bad)); err == nil {
+			t.Errorf("parseSet(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestShow(t *testing.T) {
+	k, _ := wireguard.ParseKey(priv)
+	pk, _ := wireguard.ParseKey(peer)
+	now := time.Unix(1000, 0)
+	d := &wireguard.Device{
+		Name:       "wg0",
+		PrivateKey: k,
+		PublicKey:  k.PublicKey(),
+		ListenPort: 51820,
+		Peers: []wireguard.Peer{{
+			PublicKey:     pk,
+			Endpoint:      &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 51820},
+			LastHandshake: now.Add(-42 * time.Second),
+			RxBytes:       100,
+			TxBytes:       200,
+		}},
+	}
+	var out bytes.Buffer
+	show(&out, d, now)
+	want := `interface: wg0
+  public key: ` + pub + `
+  private key: (hidden)
+  listening port: 51820
+
+peer: ` + peer + `
+  endpoint: 192.0.2.1:51820
+  allowed ips: (none)
+  latest handshake: 42s ago
+  transfer: 100 B received, 200 B sent
+`
+	if out.String() != want {
+		t.Errorf("show = %q, want %q", out.String(), want)
+	}
+}
+
+func TestQuickConfig(t *testing.T) {
+	configDir = t.TempDir()
+	conf := "[Interface]\nPrivateKey = " + priv + "\n"
+	if err := os.WriteFile(filepath.Join(configDir, "mgmt.conf"), []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, config := range []string{"mgmt", filepath.Join(configDir, "mgmt.conf")} {
+		name, c, err := quickConfig(config)
+		if err != nil || name != "mgmt" || c.PrivateKey.String() != priv {
+			t.Errorf("quickConfig(%q) = %q, %+v, %v", config, name, c, err)
+		}
+	}
+	if _, _, err := quickConfig("a-very-long-interface-name"); err == nil {
+		t.Errorf("quickConfig with a long name succeeded")
+	}
+}
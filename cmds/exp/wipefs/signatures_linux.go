@@ -0,0 +1,104 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// signature is a magic that identifies a filesystem, RAID member, or other
+// format on a device. Erasing it is enough for tools not to find the format.
+type signature struct {
+	name  string
+	usage string
+	magic []byte
+
+	// off returns the offset of the magic on a device of size bytes.
+	off func(size int64) int64
+}
+
+// at returns an offset from the start of the device.
+func at(off int64) func(int64) int64 {
+	return func(int64) int64 { return off }
+}
+
+// fromEnd returns an offset from the end of the device.
+func fromEnd(off int64) func(int64) int64 {
+	return func(size int64) int64 { return size - off }
+}
+
+var (
+	mdMagic   = []byte{0xfc, 0x4e, 0x2b, 0xa9}
+	swapMagic = []byte("SWAPSPACE2")
+)
+
+// signatures are the formats wipefs knows. The offsets of partition tables
+// assume 512 or 4096 byte sectors.
+var signatures = []signature{
+	{name: "ext4", usage: "filesystem", magic: []byte{0x53, 0xef}, off: at(0x438)},
+	{name: "xfs", usage: "filesystem", magic: []byte("XFSB"), off: at(0)},
+	{name: "btrfs", usage: "filesystem", magic: []byte("_BHRfS_M"), off: at(0x10040)},
+	{name: "f2fs", usage: "filesystem", magic: []byte{0x10, 0x20, 0xf5, 0xf2}, off: at(0x400)},
+	{name: "vfat", usage: "filesystem", magic: []byte("FAT32   "), off: at(0x52)},
+	{name: "vfat", usage: "filesystem", magic: []byte("FAT16   "), off: at(0x36)},
+	{name: "vfat", usage: "filesystem", magic: []byte("FAT12   "), off: at(0x36)},
+	{name: "ntfs", usage: "filesystem", magic: []byte("NTFS    "), off: at(3)},
+	{name: "squashfs", usage: "filesystem", magic: []byte("hsqs"), off: at(0)},
+	{name: "erofs", usage: "filesystem", magic: []byte{0xe2, 0xe1, 0xf5, 0xe0}, off: at(0x400)},
+	{name: "iso9660", usage: "filesystem", magic: []byte("CD001"), off: at(0x8001)},
+	{name: "swap", usage: "other", magic: swapMagic, off: at(4096 - 10)},
+	{name: "swap", usage: "other", magic: swapMagic, off: at(16384 - 10)},
+	{name: "swap", usage: "other", magic: swapMagic, off: at(65536 - 10)},
+	{name: "crypto_LUKS", usage: "crypto", magic: []byte("LUKS\xba\xbe"), off: at(0)},
+	// The LUKS2 secondary header is at one of a few offsets.
+	{name: "crypto_LUKS", usage: "crypto", magic: []byte("SKUL\xba\xbe"), off: at(0x4000)},
+	{name: "crypto_LUKS", usage: "crypto", magic: []byte("SKUL\xba\xbe"), off: at(0x8000)},
+	{name: "LVM2_member", usage: "raid", magic: []byte("LABELONE"), off: at(0x000)},
+	{name: "LVM2_member", usage: "raid", magic: []byte("LABELONE"), off: at(0x200)},
+	{name: "LVM2_member", usage: "raid", magic: []byte("LABELONE"), off: at(0x400)},
+	{name: "LVM2_member", usage: "raid", magic: []byte("LABELONE"), off: at(0x600)},
+	// MD superblocks 1.1, 1.2, 1.0 and 0.90.
+	{name: "linux_raid_member", usage: "raid", magic: mdMagic, off: at(0)},
+	{name: "linux_raid_member", usage: "raid", magic: mdMagic, off: at(0x1000)},
+	{name: "linux_raid_member", usage: "raid", magic: mdMagic, off: func(size int64) int64 { return size&^(4096-1) - 8192 }},
+	{name: "linux_raid_member", usage: "raid", magic: mdMagic, off: func(size int64) int64 { return size&^(65536-1) - 65536 }},
+	{name: "gpt", usage: "partition-table", magic: []byte("EFI PART"), off: at(512)},
+	{name: "gpt", usage: "partition-table", magic: []byte("EFI PART"), off: at(4096)},
+	{name: "gpt", usage: "partition-table", magic: []byte("EFI PART"), off: fromEnd(512)},
+	{name: "gpt", usage: "partition-table", magic: []byte("EFI PART"), off: fromEnd(4096)},
+	// Also the boot sector signature of FAT.
+	{name: "dos", usage: "partition-table", magic: []byte{0x55, 0xaa}, off: at(0x1fe)},
+}
+
+// match is a signature found on a device.
+type match struct {
+	*signature
+	off int64
+}
+
+// probe returns the signatures found on r, a device of size bytes, in
+// signatures order.
+func probe(r io.ReaderAt, size int64) ([]match, error) {
+	var found []match
+	for i := range signatures {
+		s := &signatures[i]
+		off := s.off(size)
+		if off < 0 || off+int64(len(s.magic)) > size {
+			continue
+		}
+		b := make([]byte, len(s.magic))
+		if _, err := r.ReadAt(b, off); err != nil {
+			return nil, err
+		}
+		if bytes.Equal(b, s.magic) {
+			found = append(found, match{signature: s, off: off})
+		}
+	}
+	return found, nil
+}
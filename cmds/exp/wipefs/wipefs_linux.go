@@ -0,0 +1,238 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// wipefs lists and erases the signatures of filesystems, RAID members,
+// partition tables and other formats on devices.
+//
+// Synopsis:
+//
+//	wipefs [-a] [-o OFFSET]... [-t TYPES] [-b] [-n] [-f] DEVICE...
+//
+// Description:
+//
+//	Without -a or -o, the signatures found on each DEVICE are listed, with
+//	their offset, type and usage.
+//
+//	Erasing a signature zeroes its magic bytes only, which is enough for
+//	tools not to find the format, and leaves the data. After erasing a
+//	partition table of a block device, the kernel is asked to re-read it.
+//
+// Options:
+//
+//	-a, --all:     erase all signatures
+//	-o, --offset:  erase the signature at OFFSET, e.g. 0x438
+//	-t, --types:   only erase or list these comma separated types, e.g. gpt,dos
+//	-b, --backup:  save each signature to $HOME/wipefs-DEVICE-OFFSET.bak first
+//	-n, --no-act:  do everything but write
+//	-f, --force:   erase even devices that are in use, e.g. mounted
+//
+// Example:
+//
+//	wipefs -a -b /dev/sdb
+//	dd if=$HOME/wipefs-sdb-0x00000200.bak of=/dev/sdb bs=1 seek=512 conv=notrunc
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	flag "github.com/spf13/pflag"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	all     = flag.BoolP("all", "a", false, "erase all signatures")
+	offsets = flag.StringArrayP("offset", "o", nil, "erase the signature at this offset")
+	types   = flag.StringP("types", "t", "", "only erase or list these comma separated types")
+	backup  = flag.BoolP("backup", "b", false, "save each signature to $HOME/wipefs-DEVICE-OFFSET.bak before erasing it")
+	noAct   = flag.BoolP("no-act", "n", false, "do everything but write")
+	force   = flag.BoolP("force", "f", false, "erase even devices that are in use")
+)
+
+// options say which signatures to erase, and how.
+type options struct {
+	all     bool
+	offsets []int64
+	types   map[string]bool
+
+	// backupDir, if not "", is where signatures are saved before they
+	// are erased.
+	backupDir string
+	noAct     bool
+}
+
+// selected returns whether the signature m is listed or erased.
+func (o *options) selected(m match) bool {
+	return len(o.types) == 0 || o.types[m.name]
+}
+
+// erases returns whether the signature m is erased.
+func (o *options) erases(m match) bool {
+	if !o.selected(m) {
+		return false
+	}
+	if o.all {
+		return true
+	}
+	for _, off := range o.offsets {
+		if off == m.off {
+			return true
+		}
+	}
+	return false
+}
+
+// list prints the signatures found on the device name to w.
+func list(w io.Writer, name string, found []match, o *options) {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "DEVICE\tOFFSET\tTYPE\tUSAGE\n")
+	for _, m := range found {
+		if o.selected(m) {
+			fmt.Fprintf(tw, "%s\t%#x\t%s\t%s\n", filepath.Base(name), m.off, m.name, m.usage)
+		}
+	}
+	tw.Flush()
+}
+
+// wipe erases the signatures found on f, the device name, as o says, and
+// prints what it erased to w. It returns whether a partition table was
+// erased.
+func wipe(w io.Writer, f io.WriterAt, name string, found []match, o *options) (bool, error) {
+	erased := make(map[int64]bool)
+	var table bool
+	for _, m := range found {
+		if !o.erases(m) {
+			continue
+		}
+		if o.backupDir != "" {
+			bak := filepath.Join(o.backupDir, fmt.Sprintf("wipefs-%s-0x%08x.bak", filepath.Base(name), m.off))
+			if err := os.WriteFile(bak, m.magic, 0o600); err != nil {
+				return table, err
+			}
+		}
+		if !o.noAct {
+			if _, err := f.WriteAt(make([]byte, len(m.magic)), m.off); err != nil {
+				return table, err
+			}
+		}
+		erased[m.off] = true
+		table = table || m.usage == "partition-table"
+		fmt.Fprintf(w, "%s: %d bytes were erased at offset 0x%08x (%s): % x\n", name, len(m.magic), m.off, m.name, m.magic)
+	}
+	for _, off := range o.offsets {
+		if !erased[off] {
+			return table, fmt.Errorf("%s: no signature at offset %#x", name, off)
+		}
+	}
+	return table, nil
+}
+
+func run(name string, o *options) error {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	isBlock := fi.Mode()&os.ModeDevice != 0 && fi.Mode()&os.ModeCharDevice == 0
+	mode := os.O_RDONLY
+	if (o.all || len(o.offsets) > 0) && !o.noAct {
+		mode = os.O_RDWR
+		// Block devices that are mounted, or otherwise in use, cannot
+		// be opened exclusively.
+		if isBlock && !*force {
+			mode |= unix.O_EXCL
+		}
+	}
+	f, err := os.OpenFile(name, mode, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	found, err := probe(f, size)
+	if err != nil {
+		return err
+	}
+	if !o.all && len(o.offsets) == 0 {
+		list(os.Stdout, name, found, o)
+		return nil
+	}
+	table, err := wipe(os.Stdout, f, name, found, o)
+	if err != nil || o.noAct {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if table && isBlock {
+		if err := unix.IoctlSetInt(int(f.Fd()), unix.BLKRRPART, 0); err != nil {
+			log.Printf("%s: the kernel still uses the old partition table: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// parseOptions returns the options of the flags.
+func parseOptions() (*options, error) {
+	o := &options{all: *all, noAct: *noAct}
+	for _, s := range *offsets {
+		off, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset %q: %v", s, err)
+		}
+		o.offsets = append(o.offsets, off)
+	}
+	if *types != "" {
+		o.types = make(map[string]bool)
+		for _, t := range strings.Split(*types, ",") {
+			o.types[t] = true
+		}
+	}
+	if *backup {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.New("-b needs $HOME to save signatures to")
+		}
+		o.backupDir = home
+	}
+	return o, nil
+}
+
+func main() {
+	log.SetPrefix("wipefs: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: wipefs [-a] [-o OFFSET]... [-t TYPES] [-b] [-n] [-f] DEVICE...\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	o, err := parseOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var failed bool
+	for _, name := range flag.Args() {
+		if err := run(name, o); err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
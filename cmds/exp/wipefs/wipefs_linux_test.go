@@ -0,0 +1,144 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const diskSize = 1 << 20
+
+// disk returns a disk image with a GPT, its protective MBR, and a stray ext4
+// superblock.
+func disk() []byte {
+	d := make([]byte, diskSize)
+	copy(d[0x1fe:], []byte{0x55, 0xaa})
+	copy(d[0x200:], "EFI PART")
+	copy(d[diskSize-512:], "EFI PART")
+	copy(d[0x438:], []byte{0x53, 0xef})
+	return d
+}
+
+func names(found []match) string {
+	var s []string
+	for _, m := range found {
+		s = append(s, m.name)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestProbe(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		disk func() []byte
+		want string
+	}{
+		{name: "gpt", disk: disk, want: "ext4,gpt,gpt,dos"},
+		{name: "empty", disk: func() []byte { return make([]byte, diskSize) }, want: ""},
+		{
+			name: "md 1.0",
+			// Not a multiple of 4 KiB, which the superblock is
+			// aligned to.
+			disk: func() []byte {
+				d := make([]byte, diskSize+100)
+				copy(d[diskSize-8192:], mdMagic)
+				return d
+			},
+			want: "linux_raid_member",
+		},
+		{
+			name: "swap and btrfs",
+			disk: func() []byte {
+				d := make([]byte, diskSize)
+				copy(d[4096-10:], "SWAPSPACE2")
+				copy(d[0x10040:], "_BHRfS_M")
+				return d
+			},
+			want: "btrfs,swap",
+		},
+		{name: "tiny", disk: func() []byte { return []byte("XFS") }, want: ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.disk()
+			found, err := probe(bytes.NewReader(d), int64(len(d)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(found); got != tt.want {
+				t.Errorf("probe = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+type writerAt []byte
+
+func (w writerAt) WriteAt(p []byte, off int64) (int, error) {
+	return copy(w[off:], p), nil
+}
+
+func TestWipe(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		o         options
+		wantLeft  string
+		wantTable bool
+		wantErr   bool
+	}{
+		{name: "all", o: options{all: true}, wantLeft: "", wantTable: true},
+		{name: "types", o: options{all: true, types: map[string]bool{"gpt": true}}, wantLeft: "ext4,dos", wantTable: true},
+		{name: "offset", o: options{offsets: []int64{0x438}}, wantLeft: "gpt,gpt,dos"},
+		{name: "no signature at offset", o: options{offsets: []int64{0x439}}, wantLeft: "ext4,gpt,gpt,dos", wantErr: true},
+		{name: "no act", o: options{all: true, noAct: true}, wantLeft: "ext4,gpt,gpt,dos", wantTable: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := disk()
+			found, err := probe(bytes.NewReader(d), diskSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table, err := wipe(io.Discard, writerAt(d), "/dev/sdb", found, &tt.o)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("wipe = %v, want error %t", err, tt.wantErr)
+			}
+			if table != tt.wantTable {
+				t.Errorf("wipe erased a partition table: %t, want %t", table, tt.wantTable)
+			}
+			left, err := probe(bytes.NewReader(d), diskSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(left); got != tt.wantLeft {
+				t.Errorf("signatures left: %s, want %s", got, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestWipeBackup(t *testing.T) {
+	d := disk()
+	found, err := probe(bytes.NewReader(d), diskSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var out strings.Builder
+	o := &options{offsets: []int64{0x200}, backupDir: dir}
+	if _, err := wipe(&out, writerAt(d), "/dev/sdb", found, o); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/dev/sdb: 8 bytes were erased at offset 0x00000200 (gpt): 45 46 49 20 50 41 52 54\n"; out.String() != want {
+		t.Errorf("wipe printed %q, want %q", out.String(), want)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "wipefs-sdb-0x00000200.bak"))
+	if err != nil || string(b) != "EFI PART" {
+		t.Errorf("backup = %q, %v, want %q", b, err, "EFI PART")
+	}
+}
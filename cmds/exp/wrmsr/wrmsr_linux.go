@@ -0,0 +1,121 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// wrmsr writes an MSR of CPUs, through /dev/cpu/*/msr.
+//
+// Synopsis:
+//
+//	wrmsr [-p CPUS | -a] [-m MASK] MSR VALUE
+//
+// Description:
+//
+//	MSR is an address, e.g. 0x1a0, or a name, e.g. IA32_MISC_ENABLE; rdmsr
+//	-l lists the names.
+//
+//	With -m, only the bits of MASK are written: the MSR is read, and
+//	written only if those bits differ from VALUE's.
+//
+//	The msr module must be loaded.
+//
+// Options:
+//
+//	-p: CPUs to write, e.g. 0-3,7 (default 0)
+//	-a: write all CPUs
+//	-m: write only the bits of MASK
+//
+// Example:
+//
+//	# Disable turbo.
+//	wrmsr -a -m 0x4000000000 IA32_MISC_ENABLE 0x4000000000
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/msr"
+)
+
+var (
+	cpuList = flag.String("p", "0", "CPUs to write, e.g. 0-3,7")
+	all     = flag.Bool("a", false, "write all CPUs")
+	mask    = flag.String("m", "", "write only the bits of MASK")
+)
+
+var errUsage = errors.New("usage: wrmsr [-p CPUS | -a] [-m MASK] MSR VALUE")
+
+// cpuErrors returns the errors of the CPUs which failed.
+func cpuErrors(cpus msr.CPUs, errs []error) string {
+	var s []string
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if len(errs) == len(cpus) {
+			s = append(s, fmt.Sprintf("CPU %d: %v", cpus[i], err))
+		} else {
+			s = append(s, err.Error())
+		}
+	}
+	return strings.Join(s, "; ")
+}
+
+func run() error {
+	if flag.NArg() != 2 {
+		return errUsage
+	}
+	m, err := msr.Lookup(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	v, err := strconv.ParseUint(flag.Arg(1), 0, 64)
+	if err != nil {
+		return fmt.Errorf("value %q: %w", flag.Arg(1), err)
+	}
+	var bits uint64
+	if *mask != "" {
+		if bits, err = strconv.ParseUint(*mask, 0, 64); err != nil {
+			return fmt.Errorf("mask %q: %w", *mask, err)
+		}
+		if v&^bits != 0 {
+			return fmt.Errorf("value %#x has bits outside mask %#x", v, bits)
+		}
+	}
+	var cpus msr.CPUs
+	if *all {
+		cpus, err = msr.AllCPUs()
+	} else {
+		cpus, err = msr.ParseCPUs(*cpuList)
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if *mask != "" {
+		errs = m.TestAndSet(cpus, bits, v)
+	} else {
+		errs = m.Write(cpus, v)
+	}
+	if errs != nil {
+		return fmt.Errorf("writing %v: %s", m, cpuErrors(cpus, errs))
+	}
+	return nil
+}
+
+func main() {
+	log.SetPrefix("wrmsr: ")
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
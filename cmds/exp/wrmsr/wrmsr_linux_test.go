@@ -0,0 +1,43 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/testutil"
+)
+
+func TestWrmsr(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"0x10"}, want: "usage"},
+		{args: []string{"IA32_NOPE", "1"}, want: "not the name or address"},
+		{args: []string{"IA32_MISC_ENABLE", "one"}, want: "value"},
+		{args: []string{"-m", "0x4000000000", "IA32_MISC_ENABLE", "0x1"}, want: "outside mask"},
+		{args: []string{"-p", "x", "IA32_MISC_ENABLE", "0x1"}, want: "cpu range"},
+	} {
+		var stderr bytes.Buffer
+		cmd := testutil.Command(t, tt.args...)
+		cmd.Stderr = &stderr
+		if err := testutil.IsExitCode(cmd.Run(), 1); err != nil {
+			t.Errorf("wrmsr %v: %v", tt.args, err)
+		}
+		if !strings.Contains(stderr.String(), tt.want) {
+			t.Errorf("wrmsr %v printed %q, want %q", tt.args, stderr.String(), tt.want)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	testutil.Run(m, main)
+}
@@ -0,0 +1,318 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// xargs runs a command with arguments read from stdin.
+//
+// Synopsis:
+//
+//	xargs [-0] [-d DELIM] [-I REPLACE] [-n MAX] [-P PROCS] [-r] [-t] [COMMAND [ARG]...]
+//
+// Description:
+//
+//	xargs reads arguments from stdin, separated by blanks or newlines, and
+//	runs COMMAND, echo by default, with ARGs and as many of them as fit.
+//	Quotes and backslashes in the input quote blanks and newlines. With
+//	-0 or -d, the input is split at DELIM instead, without quoting, which
+//	is what find -print0 is for.
+//
+//	With -I, COMMAND is run once for each line of input, with REPLACE in
+//	ARGs replaced by the line.
+//
+//	xargs exits with 0 if every COMMAND succeeds, 123 if one exits with 1
+//	to 125, 124 if one exits with 255, which also stops xargs, 125 if one
+//	is killed by a signal, 126 if COMMAND cannot be run and 127 if it
+//	is not found.
+//
+// Options:
+//
+//	-0: arguments end with NUL
+//	-d: arguments end with DELIM
+//	-I: replace REPLACE in ARGs with each line of input
+//	-n: pass at most MAX arguments to each COMMAND
+//	-P: run up to PROCS COMMANDs at once; 0 runs as many as possible
+//	-r: do not run COMMAND if there are no arguments
+//	-t: print each command to stderr before running it
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// maxChars is the most bytes of arguments passed to a command.
+const maxChars = 128 * 1024
+
+type params struct {
+	delim   string
+	replace string
+	maxArgs int
+	procs   int
+	noEmpty bool
+	trace   bool
+}
+
+// exitError is an error with the exit status of xargs.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// scanner reads arguments.
+type scanner struct {
+	r     *bufio.Reader
+	delim string
+	// line reads lines, for -I.
+	line bool
+}
+
+// next returns the next argument, or io.EOF.
+func (s *scanner) next() (string, error) {
+	switch {
+	case s.delim != "":
+		a, err := s.r.ReadString(s.delim[0])
+		if err == io.EOF && a != "" {
+			return a, nil
+		}
+		return strings.TrimSuffix(a, s.delim), err
+	case s.line:
+		for {
+			a, err := s.r.ReadString('\n')
+			if a = strings.TrimLeft(strings.TrimSuffix(a, "\n"), " \t"); a != "" {
+				return a, nil
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return s.word()
+}
+
+// word reads a blank separated word, with quoting.
+func (s *scanner) word() (string, error) {
+	var b strings.Builder
+	var quote rune
+	started := false
+	for {
+		c, _, err := s.r.ReadRune()
+		if err == io.EOF {
+			if quote != 0 {
+				return "", fmt.Errorf("unmatched %c quote", quote)
+			}
+			if started {
+				return b.String(), nil
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			if c == '\n' {
+				return "", fmt.Errorf("unmatched %c quote", quote)
+			}
+			b.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote, started = c, true
+		case c == '\\':
+			if c, _, err = s.r.ReadRune(); err != nil {
+				return "", errors.New("backslash at the end of the input")
+			}
+			b.WriteRune(c)
+			started = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if started {
+				return b.String(), nil
+			}
+		default:
+			b.WriteRune(c)
+			started = true
+		}
+	}
+}
+
+// commands sends the commands to run for the input of s to c.
+func commands(s *scanner, p params, command []string, c chan<- []string, stop <-chan struct{}) error {
+	defer close(c)
+	send := func(cmd []string) bool {
+		select {
+		case c <- cmd:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+	if p.replace != "" {
+		for {
+			a, err := s.next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			cmd := make([]string, len(command))
+			for i, arg := range command {
+				cmd[i] = strings.ReplaceAll(arg, p.replace, a)
+			}
+			if !send(cmd) {
+				return nil
+			}
+		}
+	}
+
+	var args []string
+	size := 0
+	sent := false
+	for {
+		a, err := s.next()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		full := p.maxArgs > 0 && len(args) == p.maxArgs || size+len(a)+1 > maxChars
+		if len(args) > 0 && (err == io.EOF || full) {
+			if !send(append(command[:len(command):len(command)], args...)) {
+				return nil
+			}
+			args, size, sent = nil, 0, true
+		}
+		if err == io.EOF {
+			if !sent && !p.noEmpty {
+				send(command)
+			}
+			return nil
+		}
+		args = append(args, a)
+		size += len(a) + 1
+	}
+}
+
+// status returns the exit status of xargs for the error of running a
+// command.
+func status(err error) int {
+	var e *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, os.ErrNotExist):
+		return 127
+	case !errors.As(err, &e):
+		return 126
+	case e.ExitCode() == 255:
+		return 124
+	case e.ExitCode() < 0:
+		return 125
+	}
+	return 123
+}
+
+func run(stdin io.Reader, stdout, stderr io.Writer, p params, command []string) error {
+	if len(command) == 0 {
+		command = []string{"echo"}
+	}
+	s := &scanner{r: bufio.NewReader(stdin), delim: p.delim, line: p.replace != ""}
+	procs := p.procs
+	if procs <= 0 {
+		procs = 1 << 16
+	}
+
+	cmds := make(chan []string)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- commands(s, p, command, cmds, stop)
+	}()
+
+	var mu sync.Mutex
+	worst := 0
+	var firstErr error
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, procs)
+	for cmd := range cmds {
+		sem <- struct{}{}
+		select {
+		case <-stop:
+			// Drain the commands that were read before the stop.
+			<-sem
+			continue
+		default:
+		}
+		wg.Add(1)
+		go func(cmd []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if p.trace {
+				mu.Lock()
+				fmt.Fprintln(stderr, strings.Join(cmd, " "))
+				mu.Unlock()
+			}
+			c := exec.Command(cmd[0], cmd[1:]...)
+			c.Stdout, c.Stderr = stdout, stderr
+			err := c.Run()
+			code := status(err)
+			if code == 0 {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			// 123 is the least of the statuses; later ones are worse.
+			if code > worst {
+				worst = code
+				firstErr = fmt.Errorf("%s: %v", cmd[0], err)
+			}
+			if code > 123 {
+				stopOnce.Do(func() { close(stop) })
+			}
+		}(cmd)
+	}
+	wg.Wait()
+
+	if err := <-readErr; err != nil {
+		return &exitError{code: 1, err: err}
+	}
+	if worst != 0 {
+		return &exitError{code: worst, err: firstErr}
+	}
+	return nil
+}
+
+func main() {
+	var p params
+	null := flag.Bool("0", false, "arguments end with NUL")
+	flag.StringVar(&p.delim, "d", "", "arguments end with `DELIM`")
+	flag.StringVar(&p.replace, "I", "", "replace `REPLACE` in the arguments with each line of input")
+	flag.IntVar(&p.maxArgs, "n", 0, "pass at most `MAX` arguments to each command")
+	flag.IntVar(&p.procs, "P", 1, "run up to `PROCS` commands at once")
+	flag.BoolVar(&p.noEmpty, "r", false, "do not run the command if there are no arguments")
+	flag.BoolVar(&p.trace, "t", false, "print each command before running it")
+	flag.Parse()
+	if *null {
+		p.delim = "\x00"
+	}
+	if len(p.delim) > 1 {
+		log.Fatalf("xargs: the delimiter must be a single byte")
+	}
+
+	err := run(os.Stdin, os.Stdout, os.Stderr, p, flag.Args())
+	var e *exitError
+	if errors.As(err, &e) {
+		log.Printf("xargs: %v", e.err)
+		os.Exit(e.code)
+	}
+}
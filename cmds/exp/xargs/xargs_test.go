@@ -0,0 +1,125 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		in    string
+		delim string
+		line  bool
+		want  []string
+	}{
+		{name: "words", in: "a b\tc\n\n  d", want: []string{"a", "b", "c", "d"}},
+		{name: "quotes", in: `'a b' "c 'd'" e\ f g""`, want: []string{"a b", "c 'd'", "e f", "g"}},
+		{name: "empty quotes", in: `'' x`, want: []string{"", "x"}},
+		{name: "null", in: "a b\x00c\n\x00", delim: "\x00", want: []string{"a b", "c\n"}},
+		{name: "null no end", in: "a\x00b", delim: "\x00", want: []string{"a", "b"}},
+		{name: "lines", in: "  a b\n\nc\n", line: true, want: []string{"a b", "c"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &scanner{r: bufio.NewReader(strings.NewReader(tt.in)), delim: tt.delim, line: tt.line}
+			var got []string
+			for {
+				a, err := s.next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, a)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("arguments = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, in := range []string{`'a`, "\"a\nb\"", `a\`} {
+		s := &scanner{r: bufio.NewReader(strings.NewReader(in))}
+		if _, err := s.next(); err == nil || err == io.EOF {
+			t.Errorf("scanning %q = %v, want an error", in, err)
+		}
+	}
+}
+
+// lockedBuffer is a buffer that commands can write to at once.
+type lockedBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.Write(p)
+}
+
+func lines(s string) []string {
+	l := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	sort.Strings(l)
+	return l
+}
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	for _, tt := range []struct {
+		name    string
+		in      string
+		p       params
+		command []string
+		want    []string
+		code    int
+	}{
+		{name: "echo", in: "a b\nc", want: []string{"a b c"}},
+		{name: "empty", want: []string{""}},
+		{name: "max", in: "1 2 3 4 5", p: params{maxArgs: 2}, command: []string{"echo", "x"}, want: []string{"x 1 2", "x 3 4", "x 5"}},
+		{name: "replace", in: "a\nb c\n", p: params{replace: "{}"}, command: []string{"echo", "<{}>", "{}{}"}, want: []string{"<a> aa", "<b c> b cb c"}},
+		{name: "parallel", in: "1 2 3 4 5 6", p: params{maxArgs: 1, procs: 0}, want: []string{"1", "2", "3", "4", "5", "6"}},
+		{name: "failure", in: "0 1 0", p: params{maxArgs: 1, procs: 2}, command: []string{"sh", "-c", "echo $0; exit $0"}, want: []string{"0", "0", "1"}, code: 123},
+		{name: "255 stops", in: "255 0 0", p: params{maxArgs: 1, procs: 1}, command: []string{"sh", "-c", "echo $0; exit $0"}, want: []string{"255"}, code: 124},
+		{name: "not found", in: "a", command: []string{"/no/such/command"}, want: []string{""}, code: 127},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, stderr lockedBuffer
+			err := run(strings.NewReader(tt.in), &out, &stderr, tt.p, tt.command)
+			code := 0
+			var e *exitError
+			if errors.As(err, &e) {
+				code = e.code
+			}
+			if code != tt.code {
+				t.Errorf("exit status = %d (%v), want %d", code, err, tt.code)
+			}
+			if got := lines(out.b.String()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var out, stderr lockedBuffer
+	if err := run(strings.NewReader(""), &out, &stderr, params{noEmpty: true, trace: true}, nil); err != nil || out.b.Len() != 0 || stderr.b.Len() != 0 {
+		t.Errorf("xargs -r with no input ran %q, %q, %v", out.b.String(), stderr.b.String(), err)
+	}
+	if err := run(strings.NewReader("a"), &out, &stderr, params{trace: true}, []string{"true"}); err != nil || stderr.b.String() != "true a\n" {
+		t.Errorf("xargs -t printed %q, %v", stderr.b.String(), err)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/cpio"
+)
+
+// Dumps are tables in the text format of the ACPICA acpidump, which its
+// acpixtract, and ours, extract tables from, e.g.
+//
+//	DSDT @ 0x000000007FFE0040
+//	    0000: 44 53 44 54 5A 1E 00 00 01 0D 42 4F 43 48 53 20  DSDT Z.....BOCHS
+//	    ...
+//
+// Each table is followed by an empty line.
+
+// overrideDir is where Linux looks for tables to override the firmware's
+// with, in the first, uncompressed, archive of the initramfs.
+const overrideDir = "kernel/firmware/acpi"
+
+var dumpHeader = regexp.MustCompile(`^([!-~ ]{4}) @ 0x([0-9A-Fa-f]+)$`)
+
+// WriteDump writes tables to w as a dump.
+func WriteDump(w io.Writer, tabs ...Table) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range tabs {
+		sig := t.Sig()
+		if isRSDP(t) {
+			sig = "RSDP"
+		}
+		fmt.Fprintf(bw, "%s @ 0x%016X\n", sig, t.Address())
+		b := t.Data()
+		for off := 0; off < len(b); off += 16 {
+			line := b[off:]
+			if len(line) > 16 {
+				line = line[:16]
+			}
+			fmt.Fprintf(bw, "%8.4X: ", off)
+			for i := 0; i < 16; i++ {
+				if i < len(line) {
+					fmt.Fprintf(bw, "%02X ", line[i])
+				} else {
+					bw.WriteString("   ")
+				}
+			}
+			bw.WriteByte(' ')
+			for _, c := range line {
+				if c < ' ' || c > '~' {
+					c = '.'
+				}
+				bw.WriteByte(c)
+			}
+			bw.WriteByte('\n')
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// ReadDump reads the tables of a dump. Lines which are not part of a table,
+// such as those before the first table, are skipped.
+func ReadDump(r io.Reader) ([]Table, error) {
+	var (
+		tabs []Table
+		t    *Raw
+	)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimRight(s.Text(), "\r")
+		if m := dumpHeader.FindStringSubmatch(line); m != nil {
+			addr, err := strconv.ParseUint(m[2], 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: table %s address: %v", n, m[1], err)
+			}
+			t = &Raw{addr: int64(addr)}
+			tabs = append(tabs, t)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			t = nil
+			continue
+		}
+		if t == nil {
+			continue
+		}
+
+		off, hex, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: %q is not OFFSET: BYTES", n, line)
+		}
+		o, err := strconv.ParseUint(strings.TrimSpace(off), 16, 32)
+		if err != nil || int(o) != len(t.data) {
+			return nil, fmt.Errorf("line %d: offset %q, want %04X", n, off, len(t.data))
+		}
+		// The bytes are in the first 16 columns of 3 characters, and
+		// the rest of the line is the bytes as text.
+		if len(hex) > 16*3 {
+			hex = hex[:16*3]
+		}
+		for _, f := range strings.Fields(hex) {
+			b, err := strconv.ParseUint(f, 16, 8)
+			if err != nil || len(f) != 2 {
+				return nil, fmt.Errorf("line %d: %q is not a byte", n, f)
+			}
+			t.data = append(t.data, byte(b))
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for _, t := range tabs {
+		if len(t.Data()) < minTableLength {
+			return nil, fmt.Errorf("table %#x is %d bytes, less than %d", t.Address(), len(t.Data()), minTableLength)
+		}
+	}
+	return tabs, nil
+}
+
+func isRSDP(t Table) bool {
+	return bytes.HasPrefix(t.Data(), []byte("RSD PTR "))
+}
+
+// FileNames returns the names, without extension, of the files acpidump and
+// acpixtract write tables to: the signature in lower case, numbered if more
+// than one table has it, e.g. ssdt1 and ssdt2.
+func FileNames(tabs []Table) []string {
+	sig := make([]string, len(tabs))
+	count := map[string]int{}
+	for i, t := range tabs {
+		sig[i] = strings.ToLower(t.Sig())
+		if isRSDP(t) {
+			sig[i] = "rsdp"
+		}
+		count[sig[i]]++
+	}
+	names := make([]string, len(tabs))
+	seen := map[string]int{}
+	for i, s := range sig {
+		names[i] = s
+		if count[s] > 1 {
+			seen[s]++
+			names[i] = fmt.Sprintf("%s%d", s, seen[s])
+		}
+	}
+	return names
+}
+
+// WriteOverride writes tables to w as an uncompressed newc cpio archive of
+// kernel/firmware/acpi/NAME.aml files, named as by FileNames.
+//
+// Linux, if built with CONFIG_ACPI_TABLE_UPGRADE, overrides the firmware's
+// tables with those of such an archive, if it comes first in the initramfs:
+//
+//	cat override.cpio initramfs.cpio.gz > initramfs
+func WriteOverride(w io.Writer, tabs ...Table) error {
+	archiver, err := cpio.Format("newc")
+	if err != nil {
+		return err
+	}
+	rw := archiver.Writer(w)
+	recs := []cpio.Record{
+		cpio.Directory("kernel", 0o755),
+		cpio.Directory("kernel/firmware", 0o755),
+		cpio.Directory(overrideDir, 0o755),
+	}
+	for i, n := range FileNames(tabs) {
+		recs = append(recs, cpio.StaticFile(path.Join(overrideDir, n+".aml"), string(tabs[i].Data()), 0o644))
+	}
+	if err := cpio.WriteRecords(rw, recs); err != nil {
+		return err
+	}
+	return cpio.WriteTrailer(rw)
+}
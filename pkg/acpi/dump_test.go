@@ -0,0 +1,126 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cpio"
+)
+
+func testTables() []Table {
+	hdr := func(sig string, n int) []byte {
+		b := make([]byte, n)
+		copy(b, sig)
+		b[lengthOffset] = byte(n)
+		copy(b[10:], "U-ROOT")
+		return b
+	}
+	return []Table{
+		&Raw{addr: 0xf5a10, data: append([]byte("RSD PTR "), make([]byte, 12)...)},
+		&Raw{addr: 0x7ffe0040, data: hdr("DSDT", 40)},
+		&Raw{addr: 0x7ffe1000, data: hdr("SSDT", 36)},
+		&Raw{addr: 0x7ffe2000, data: hdr("SSDT", 48)},
+	}
+}
+
+func TestDump(t *testing.T) {
+	tabs := testTables()
+	var b bytes.Buffer
+	if err := WriteDump(&b, tabs[1]); err != nil {
+		t.Fatal(err)
+	}
+	want := `DSDT @ 0x000000007FFE0040
+    0000: 44 53 44 54 28 00 00 00 00 00 55 2D 52 4F 4F 54  DSDT(.....U-ROOT
+    0010: 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00  ................
+    0020: 00 00 00 00 00 00 00 00                          ........
+
+`
+	if b.String() != want {
+		t.Errorf("WriteDump = \n%s, want\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	b.WriteString("Intel ACPI Component Architecture\n\n")
+	if err := WriteDump(&b, tabs...); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(strings.SplitN(b.String(), "\n", 4)[2], "RSDP @ 0x00000000000F5A10") {
+		t.Errorf("WriteDump of the RSDP = %q, want RSDP @ its address", b.String())
+	}
+	got, err := ReadDump(&b)
+	if err != nil {
+		t.Fatalf("ReadDump = %v", err)
+	}
+	if !reflect.DeepEqual(got, tabs) {
+		t.Errorf("ReadDump(WriteDump) = %v, want %v", got, tabs)
+	}
+}
+
+func TestReadDumpErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		dump string
+	}{
+		{name: "bad offset", dump: "DSDT @ 0x0\n    0010: 44 53 44 54\n"},
+		{name: "bad byte", dump: "DSDT @ 0x0\n    0000: 44 53 4G 54\n"},
+		{name: "not bytes", dump: "DSDT @ 0x0\nDefinitionBlock\n"},
+		{name: "short", dump: "DSDT @ 0x0\n    0000: 44 53 44 54  DSDT\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tabs, err := ReadDump(strings.NewReader(tt.dump)); err == nil {
+				t.Errorf("ReadDump = %v, want error", tabs)
+			}
+		})
+	}
+}
+
+func TestFileNames(t *testing.T) {
+	got := FileNames(testTables())
+	want := []string{"rsdp", "dsdt", "ssdt1", "ssdt2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FileNames = %q, want %q", got, want)
+	}
+}
+
+func TestWriteOverride(t *testing.T) {
+	tabs := testTables()[1:]
+	var b bytes.Buffer
+	if err := WriteOverride(&b, tabs...); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := cpio.ReadAllRecords(cpio.Newc.Reader(bytes.NewReader(b.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, r := range recs {
+		if r.Mode&cpio.S_IFMT != cpio.S_IFREG {
+			continue
+		}
+		d, err := readAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[r.Name] = d
+	}
+	want := map[string][]byte{
+		"kernel/firmware/acpi/dsdt.aml":  tabs[0].Data(),
+		"kernel/firmware/acpi/ssdt1.aml": tabs[1].Data(),
+		"kernel/firmware/acpi/ssdt2.aml": tabs[2].Data(),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("WriteOverride wrote %q, want %q", files, want)
+	}
+}
+
+func readAll(r cpio.Record) ([]byte, error) {
+	b := make([]byte, r.FileSize)
+	_, err := r.ReadAt(b, 0)
+	return b, err
+}
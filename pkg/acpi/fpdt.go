@@ -0,0 +1,123 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/u-root/u-root/pkg/memio"
+)
+
+// Record types of the FPDT and the FBPT.
+const (
+	fpdtBasicBootPointer = 0x0000
+	fpdtS3Pointer        = 0x0001
+	fbptBasicBoot        = 0x0002
+)
+
+// FPDT is the Firmware Performance Data Table, which points to the tables
+// the firmware records its performance in.
+type FPDT struct {
+	// FBPT is the physical address of the Firmware Basic Boot
+	// Performance Table, or 0 if there is none.
+	FBPT int64
+
+	// S3PT is the physical address of the S3 Performance Table, or 0 if
+	// there is none.
+	S3PT int64
+}
+
+// ParseFPDT parses the FPDT t.
+func ParseFPDT(t Table) (*FPDT, error) {
+	if t.Sig() != "FPDT" {
+		return nil, fmt.Errorf("%q is not an FPDT", t.Sig())
+	}
+	var f FPDT
+	b := t.TableData()
+	for len(b) >= 4 {
+		typ, l := binary.LittleEndian.Uint16(b), int(b[2])
+		if l < 4 || l > len(b) {
+			return nil, fmt.Errorf("FPDT record of type %#x has bad length %d", typ, l)
+		}
+		switch {
+		case typ == fpdtBasicBootPointer && l >= 16:
+			f.FBPT = int64(binary.LittleEndian.Uint64(b[8:]))
+		case typ == fpdtS3Pointer && l >= 12:
+			f.S3PT = int64(binary.LittleEndian.Uint32(b[8:]))
+		}
+		b = b[l:]
+	}
+	return &f, nil
+}
+
+// BootPerformance are the times of the Firmware Basic Boot Performance
+// Record, since reset. Times the firmware did not record are 0.
+type BootPerformance struct {
+	// ResetEnd is when the firmware started to run.
+	ResetEnd time.Duration
+
+	// LoadImage is when the OS loader started to be loaded.
+	LoadImage time.Duration
+
+	// StartImage is when the OS loader started to run.
+	StartImage time.Duration
+
+	// ExitBootServicesEntry is when the OS loader called
+	// ExitBootServices.
+	ExitBootServicesEntry time.Duration
+
+	// ExitBootServicesExit is when ExitBootServices returned.
+	ExitBootServicesExit time.Duration
+}
+
+// ParseFBPT parses the Firmware Basic Boot Performance Table b.
+func ParseFBPT(b []byte) (*BootPerformance, error) {
+	if len(b) < 8 || string(b[:4]) != "FBPT" {
+		return nil, fmt.Errorf("not an FBPT")
+	}
+	if l := binary.LittleEndian.Uint32(b[4:]); int(l) <= len(b) {
+		b = b[:l]
+	}
+	b = b[8:]
+	for len(b) >= 4 {
+		typ, l := binary.LittleEndian.Uint16(b), int(b[2])
+		if l < 4 || l > len(b) {
+			return nil, fmt.Errorf("FBPT record of type %#x has bad length %d", typ, l)
+		}
+		if typ == fbptBasicBoot && l >= 48 {
+			t := func(off int) time.Duration {
+				return time.Duration(binary.LittleEndian.Uint64(b[off:]))
+			}
+			return &BootPerformance{
+				ResetEnd:              t(8),
+				LoadImage:             t(16),
+				StartImage:            t(24),
+				ExitBootServicesEntry: t(32),
+				ExitBootServicesExit:  t(40),
+			}, nil
+		}
+		b = b[l:]
+	}
+	return nil, fmt.Errorf("FBPT has no basic boot performance record")
+}
+
+// ReadFBPT reads the Firmware Basic Boot Performance Table at physical
+// address addr, e.g. FPDT.FBPT, with memio.
+func ReadFBPT(addr int64) (*BootPerformance, error) {
+	var u memio.Uint32
+	if err := memio.Read(addr+4, &u); err != nil {
+		return nil, err
+	}
+	if u < 8 || u > 4096 {
+		return nil, fmt.Errorf("FBPT at %#x has bad length %d", addr, u)
+	}
+	b := memio.ByteSlice(make([]byte, u))
+	if err := memio.Read(addr, &b); err != nil {
+		return nil, err
+	}
+	return ParseFBPT(b)
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// record returns a performance record of type typ, with data following the
+// 4 reserved bytes of the records of the FPDT and the FBPT.
+func record(typ uint16, data ...uint64) []byte {
+	b := make([]byte, 8, 8+8*len(data))
+	binary.LittleEndian.PutUint16(b, typ)
+	b[2], b[3] = byte(8+8*len(data)), 1
+	for _, d := range data {
+		b = binary.LittleEndian.AppendUint64(b, d)
+	}
+	return b
+}
+
+func table(sig string, hdr int, recs ...[]byte) []byte {
+	b := make([]byte, hdr)
+	copy(b, sig)
+	for _, r := range recs {
+		b = append(b, r...)
+	}
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)))
+	return b
+}
+
+func TestParseFPDT(t *testing.T) {
+	s3 := record(fpdtS3Pointer, 0)
+	s3 = s3[:12]
+	s3[2] = 12
+	binary.LittleEndian.PutUint32(s3[8:], 0x7f000000)
+	tabs, err := NewRaw(table("FPDT", headerLength, record(0x1000, 1), record(fpdtBasicBootPointer, 0x7ffe0000), s3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseFPDT(tabs[0])
+	if err != nil || f.FBPT != 0x7ffe0000 || f.S3PT != 0x7f000000 {
+		t.Errorf("ParseFPDT = %+v, %v, want FBPT at 0x7ffe0000 and S3PT at 0x7f000000", f, err)
+	}
+
+	bad := table("FPDT", headerLength, record(fpdtBasicBootPointer, 1))
+	bad[headerLength+2] = 2
+	if tabs, err = NewRaw(bad); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFPDT(tabs[0]); err == nil {
+		t.Errorf("ParseFPDT of a record of length 2 succeeded")
+	}
+	if tabs, err = NewRaw(table("SSDT", headerLength)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFPDT(tabs[0]); err == nil {
+		t.Errorf("ParseFPDT of an SSDT succeeded")
+	}
+}
+
+func TestParseFBPT(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		b    []byte
+		want *BootPerformance
+	}{
+		{
+			name: "basic boot record",
+			b:    table("FBPT", 8, record(0x1000), record(fbptBasicBoot, 1000, 2e9, 25e8, 3e9, 3001e6)),
+			want: &BootPerformance{
+				ResetEnd:              time.Microsecond,
+				LoadImage:             2 * time.Second,
+				StartImage:            2500 * time.Millisecond,
+				ExitBootServicesEntry: 3 * time.Second,
+				ExitBootServicesExit:  3001 * time.Millisecond,
+			},
+		},
+		{name: "no basic boot record", b: table("FBPT", 8, record(0x1000))},
+		{name: "not an FBPT", b: table("S3PT", 8, record(fbptBasicBoot, 1, 2, 3, 4, 5))},
+		{name: "short", b: []byte("FBPT")},
+	} {
+		got, err := ParseFBPT(tt.b)
+		if (err != nil) != (tt.want == nil) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseFBPT = %+v, %v, want %+v", tt.name, got, err, tt.want)
+		}
+	}
+}
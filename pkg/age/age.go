@@ -0,0 +1,280 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package age implements the age file encryption format (age-encryption.org/v1).
+//
+// Only X25519 and scrypt recipients are supported, which is what tooling
+// producing provisioning secrets (disk keys, join tokens) uses in practice.
+// Files are streamed: neither encryption nor decryption holds more than one
+// 64 KiB chunk in memory.
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	intro     = "age-encryption.org/v1\n"
+	fileKeyLn = 16
+	columns   = 64
+)
+
+var b64 = base64.RawStdEncoding
+
+// ErrIncorrectIdentity is returned by Identity.Unwrap when none of the
+// stanzas are addressed to it.
+var ErrIncorrectIdentity = errors.New("incorrect identity for recipient block")
+
+// ErrNoIdentityMatch is returned by Decrypt when no identity could unwrap the
+// file key.
+var ErrNoIdentityMatch = errors.New("no identity matched any of the recipients")
+
+// Stanza is a recipient block in the age header.
+type Stanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+// Recipient wraps a file key for a single recipient.
+type Recipient interface {
+	Wrap(fileKey []byte) ([]*Stanza, error)
+}
+
+// Identity unwraps the file key from the stanzas addressed to it.
+//
+// Unwrap returns ErrIncorrectIdentity if none of the stanzas match.
+type Identity interface {
+	Unwrap(stanzas []*Stanza) ([]byte, error)
+}
+
+func hkdfKey(secret, salt []byte, info string) []byte {
+	k := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), k); err != nil {
+		panic("age: hkdf: " + err.Error())
+	}
+	return k
+}
+
+func (s *Stanza) marshal(w io.Writer) error {
+	line := "-> " + s.Type
+	if len(s.Args) > 0 {
+		line += " " + strings.Join(s.Args, " ")
+	}
+	if _, err := io.WriteString(w, line+"\n"); err != nil {
+		return err
+	}
+	body := b64.EncodeToString(s.Body)
+	for {
+		n := len(body)
+		if n > columns {
+			n = columns
+		}
+		if _, err := io.WriteString(w, body[:n]+"\n"); err != nil {
+			return err
+		}
+		body = body[n:]
+		// A full last line is followed by an empty one.
+		if n < columns {
+			return nil
+		}
+	}
+}
+
+type header struct {
+	stanzas []*Stanza
+	mac     []byte
+}
+
+func (h *header) marshalWithoutMAC(w io.Writer) error {
+	if _, err := io.WriteString(w, intro); err != nil {
+		return err
+	}
+	for _, s := range h.stanzas {
+		if err := s.marshal(w); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "---")
+	return err
+}
+
+func headerMAC(fileKey []byte, h *header) ([]byte, error) {
+	var b bytes.Buffer
+	if err := h.marshalWithoutMAC(&b); err != nil {
+		return nil, err
+	}
+	m := hmac.New(sha256.New, hkdfKey(fileKey, nil, "header"))
+	m.Write(b.Bytes())
+	return m.Sum(nil), nil
+}
+
+// ErrMalformedHeader is returned for headers that do not follow the format.
+type ErrMalformedHeader struct {
+	Reason string
+}
+
+func (e *ErrMalformedHeader) Error() string {
+	return "malformed age header: " + e.Reason
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	l, err := r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			return "", &ErrMalformedHeader{"unexpected end of header"}
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(l, "\n"), nil
+}
+
+func parseHeader(r *bufio.Reader) (*header, error) {
+	l, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if l+"\n" != intro {
+		return nil, &ErrMalformedHeader{fmt.Sprintf("unknown version line %q", l)}
+	}
+	h := &header{}
+	for {
+		l, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(l, "--- ") {
+			mac, err := b64.DecodeString(l[4:])
+			if err != nil {
+				return nil, &ErrMalformedHeader{"bad MAC encoding"}
+			}
+			h.mac = mac
+			return h, nil
+		}
+		if !strings.HasPrefix(l, "-> ") {
+			return nil, &ErrMalformedHeader{fmt.Sprintf("unexpected line %q", l)}
+		}
+		f := strings.Split(l[3:], " ")
+		if len(f) == 0 || f[0] == "" {
+			return nil, &ErrMalformedHeader{"stanza without type"}
+		}
+		s := &Stanza{Type: f[0], Args: f[1:]}
+		for {
+			l, err := readLine(r)
+			if err != nil {
+				return nil, err
+			}
+			b, err := b64.DecodeString(l)
+			if err != nil || len(l) > columns {
+				return nil, &ErrMalformedHeader{"bad stanza body"}
+			}
+			s.Body = append(s.Body, b...)
+			if len(l) < columns {
+				break
+			}
+		}
+		h.stanzas = append(h.stanzas, s)
+	}
+}
+
+// Encrypt returns a WriteCloser that encrypts everything written to it for
+// the given recipients and writes the result to dst. Close must be called to
+// flush the final chunk.
+func Encrypt(dst io.Writer, recipients ...Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	fileKey := make([]byte, fileKeyLn)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+
+	h := &header{}
+	for _, r := range recipients {
+		s, err := r.Wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		h.stanzas = append(h.stanzas, s...)
+	}
+	// ScryptIdentity refuses files with other recipients, so don't create
+	// any.
+	for _, s := range h.stanzas {
+		if s.Type == "scrypt" && len(h.stanzas) != 1 {
+			return nil, errors.New("a passphrase can't be combined with other recipients")
+		}
+	}
+	mac, err := headerMAC(fileKey, h)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.marshalWithoutMAC(dst); err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(dst, " %s\n", b64.EncodeToString(mac)); err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(nonce); err != nil {
+		return nil, err
+	}
+	return newWriter(hkdfKey(fileKey, nonce, "payload"), dst)
+}
+
+// Decrypt parses the header of src, unwraps the file key with the first
+// identity that matches, and returns a reader over the plaintext.
+//
+// Authentication failures of the payload are reported by Read; callers must
+// not act on plaintext until Read returned io.EOF.
+func Decrypt(src io.Reader, identities ...Identity) (io.Reader, error) {
+	br := bufio.NewReader(src)
+	h, err := parseHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileKey []byte
+	for _, id := range identities {
+		fileKey, err = id.Unwrap(h.stanzas)
+		if errors.Is(err, ErrIncorrectIdentity) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	if fileKey == nil {
+		return nil, ErrNoIdentityMatch
+	}
+
+	mac, err := headerMAC(fileKey, h)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, h.mac) {
+		return nil, errors.New("bad header MAC")
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(br, nonce); err != nil {
+		return nil, fmt.Errorf("reading payload nonce: %w", err)
+	}
+	return newReader(hkdfKey(fileKey, nonce, "payload"), br)
+}
@@ -0,0 +1,240 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package age
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBech32(t *testing.T) {
+	// Valid strings from BIP 173.
+	for _, s := range []string{
+		"A12UEL5L",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	} {
+		hrp, data, err := bech32Decode(s)
+		if err != nil {
+			t.Errorf("bech32Decode(%q) = %v", s, err)
+			continue
+		}
+		enc, err := bech32Encode(hrp, data)
+		if err != nil || enc != s {
+			t.Errorf("bech32Encode(%q, %x) = %q, %v, want %q", hrp, data, enc, err, s)
+		}
+	}
+	for _, s := range []string{"a12uel5l2", "A12uEL5L", "1pzry9x0s0muk", "abc1rzg"} {
+		if _, _, err := bech32Decode(s); err == nil {
+			t.Errorf("bech32Decode(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestKeyEncoding(t *testing.T) {
+	id, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id.String(), "AGE-SECRET-KEY-1") || !strings.HasPrefix(id.Recipient().String(), "age1") {
+		t.Fatalf("keys = %s, %s", id, id.Recipient())
+	}
+	id2, err := ParseX25519Identity(id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id2.Recipient().String() != id.Recipient().String() {
+		t.Errorf("parsed identity has recipient %s, want %s", id2.Recipient(), id.Recipient())
+	}
+	if _, err := ParseX25519Recipient(id.String()); err == nil {
+		t.Errorf("ParseX25519Recipient(secret key) succeeded, want error")
+	}
+
+	ids, err := ParseIdentities(strings.NewReader("# created: today\n\n" + id.String() + "\n"))
+	if err != nil || len(ids) != 1 {
+		t.Errorf("ParseIdentities = %v, %v, want 1 identity", ids, err)
+	}
+}
+
+func encrypt(t *testing.T, plain []byte, r ...Recipient) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w, err := Encrypt(&b, r...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func decrypt(enc []byte, ids ...Identity) ([]byte, error) {
+	r, err := Decrypt(bytes.NewReader(enc), ids...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	alice, _ := GenerateX25519Identity()
+	bob, _ := GenerateX25519Identity()
+	eve, _ := GenerateX25519Identity()
+
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 100} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		enc := encrypt(t, plain, alice.Recipient(), bob.Recipient())
+
+		for _, id := range []Identity{alice, bob} {
+			got, err := decrypt(enc, eve, id)
+			if err != nil {
+				t.Fatalf("size %d: %v", size, err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("size %d: plaintext mismatch", size)
+			}
+		}
+		if _, err := decrypt(enc, eve); !errors.Is(err, ErrNoIdentityMatch) {
+			t.Errorf("size %d: decrypt with wrong identity = %v, want %v", size, err, ErrNoIdentityMatch)
+		}
+
+		// Dropping the last chunk or flipping any payload byte must fail.
+		if size > chunkSize {
+			if _, err := decrypt(enc[:len(enc)-(size%chunkSize)-16], alice); !errors.Is(err, errTruncated) {
+				t.Errorf("size %d: decrypt of truncated file = %v, want %v", size, err, errTruncated)
+			}
+		}
+		tampered := append([]byte{}, enc...)
+		tampered[len(tampered)-1] ^= 1
+		if _, err := decrypt(tampered, alice); err == nil {
+			t.Errorf("size %d: tampered file decrypted", size)
+		}
+	}
+}
+
+func TestTruncatedStream(t *testing.T) {
+	id, _ := GenerateX25519Identity()
+	enc := encrypt(t, make([]byte, 2*chunkSize+10), id.Recipient())
+	// Cut at a chunk boundary so the remaining last chunk is not flagged.
+	cut := len(enc) - (10 + 16)
+	if _, err := decrypt(enc[:cut], id); !errors.Is(err, errTruncated) {
+		t.Errorf("decrypt of truncated file = %v, want %v", err, errTruncated)
+	}
+}
+
+func TestEmptyLastChunk(t *testing.T) {
+	id, _ := GenerateX25519Identity()
+	var b bytes.Buffer
+	w, err := Encrypt(&b, id.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Force a full chunk out so that Close seals an empty last one, which
+	// the format forbids.
+	w.Write(make([]byte, chunkSize))
+	if err := w.(*writer).flush(false); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if _, err := decrypt(b.Bytes(), id); !errors.Is(err, errTruncated) {
+		t.Errorf("decrypt with empty last chunk = %v, want %v", err, errTruncated)
+	}
+}
+
+// The files in testdata were created with the reference implementation,
+// filippo.io/age.
+func TestReferenceVectors(t *testing.T) {
+	id, err := ParseX25519Identity("AGE-SECRET-KEY-184JMZMVQH3E6U0PSL869004Y3U2NYV7R30EU99CSEDNPH02YUVFSZW44VU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id.Recipient().String(), "age1cy0su9fwf3gf9mw868g5yut09p6nytfmmnktexz2ya5uqg9vl9sss4euqm"; got != want {
+		t.Errorf("recipient = %s, want %s", got, want)
+	}
+	pass, _ := NewScryptIdentity("u-root")
+
+	for _, tt := range []struct {
+		file string
+		id   Identity
+		want []byte
+	}{
+		{"example.age", id, []byte("Black lives matter.")},
+		{"multichunk.age", id, bytes.Repeat([]byte("0123456789abcdef"), chunkSize/16+4)},
+		{"scrypt.age", pass, []byte("disk key from the reference implementation\n")},
+	} {
+		enc, err := os.ReadFile(filepath.Join("testdata", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decrypt(enc, tt.id)
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got %d bytes, want %q", tt.file, len(got), tt.want)
+		}
+	}
+}
+
+func TestHeaderMAC(t *testing.T) {
+	id, _ := GenerateX25519Identity()
+	enc := encrypt(t, []byte("join token"), id.Recipient())
+	i := bytes.Index(enc, []byte("-> X25519 ")) + len("-> X25519 ")
+	enc = append(append(append([]byte{}, enc[:i]...), []byte("extra ")...), enc[i:]...)
+	if _, err := decrypt(enc, id); err == nil {
+		t.Errorf("file with modified header decrypted")
+	}
+}
+
+func TestScrypt(t *testing.T) {
+	r, err := NewScryptRecipient("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetWorkFactor(10)
+	enc := encrypt(t, []byte("disk key"), r)
+
+	good, _ := NewScryptIdentity("hunter2")
+	got, err := decrypt(enc, good)
+	if err != nil || string(got) != "disk key" {
+		t.Errorf("decrypt = %q, %v, want %q", got, err, "disk key")
+	}
+	bad, _ := NewScryptIdentity("hunter3")
+	if _, err := decrypt(enc, bad); err == nil {
+		t.Errorf("decrypt with wrong passphrase succeeded")
+	}
+
+	id, _ := GenerateX25519Identity()
+	if _, err := Encrypt(io.Discard, r, id.Recipient()); err == nil {
+		t.Errorf("Encrypt to passphrase and X25519 recipient succeeded, want error")
+	}
+}
+
+func TestMalformedHeader(t *testing.T) {
+	id, _ := GenerateX25519Identity()
+	for _, s := range []string{
+		"",
+		"age-encryption.org/v2\n",
+		"age-encryption.org/v1\n-> X25519\n",
+		"age-encryption.org/v1\nbogus\n--- AAAA\n",
+	} {
+		var e *ErrMalformedHeader
+		if _, err := Decrypt(strings.NewReader(s), id); !errors.As(err, &e) {
+			t.Errorf("Decrypt(%q) = %v, want ErrMalformedHeader", s, err)
+		}
+	}
+}
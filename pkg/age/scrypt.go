@@ -0,0 +1,125 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package age
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptLabel = "age-encryption.org/v1/scrypt"
+	saltSize    = 16
+
+	// DefaultWorkFactor is the log2 of the scrypt N parameter used by
+	// NewScryptRecipient, which takes about a second on a desktop.
+	DefaultWorkFactor = 18
+
+	// maxWorkFactor bounds the work done for files we did not create.
+	maxWorkFactor = 22
+)
+
+// ScryptRecipient encrypts a file with a passphrase.
+type ScryptRecipient struct {
+	password   []byte
+	workFactor int
+}
+
+// NewScryptRecipient returns a passphrase recipient.
+func NewScryptRecipient(password string) (*ScryptRecipient, error) {
+	if password == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	return &ScryptRecipient{password: []byte(password), workFactor: DefaultWorkFactor}, nil
+}
+
+// SetWorkFactor sets the log2 of the scrypt N parameter.
+func (r *ScryptRecipient) SetWorkFactor(logN int) {
+	if logN < 1 || logN > 30 {
+		panic("age: invalid scrypt work factor")
+	}
+	r.workFactor = logN
+}
+
+func scryptKey(password, salt []byte, logN int) ([]byte, error) {
+	s := append([]byte(scryptLabel), salt...)
+	return scrypt.Key(password, s, 1<<logN, 8, 1, 32)
+}
+
+// Wrap implements Recipient.
+func (r *ScryptRecipient) Wrap(fileKey []byte) ([]*Stanza, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	k, err := scryptKey(r.password, salt, r.workFactor)
+	if err != nil {
+		return nil, err
+	}
+	body, err := aeadSeal(k, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return []*Stanza{{
+		Type: "scrypt",
+		Args: []string{b64.EncodeToString(salt), strconv.Itoa(r.workFactor)},
+		Body: body,
+	}}, nil
+}
+
+// ScryptIdentity decrypts passphrase-encrypted files.
+type ScryptIdentity struct {
+	password []byte
+}
+
+// NewScryptIdentity returns a passphrase identity.
+func NewScryptIdentity(password string) (*ScryptIdentity, error) {
+	if password == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	return &ScryptIdentity{password: []byte(password)}, nil
+}
+
+// Unwrap implements Identity.
+func (i *ScryptIdentity) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type != "scrypt" {
+			continue
+		}
+		// A passphrase must be the only way to open the file, or the
+		// other recipients could have swapped it out.
+		if len(stanzas) != 1 {
+			return nil, errors.New("scrypt recipient block mixed with other recipients")
+		}
+		if len(s.Args) != 2 {
+			return nil, errors.New("invalid scrypt recipient block")
+		}
+		salt, err := b64.DecodeString(s.Args[0])
+		if err != nil || len(salt) != saltSize || len(s.Body) != wrappedKeySize {
+			return nil, errors.New("invalid scrypt recipient block")
+		}
+		logN, err := strconv.Atoi(s.Args[1])
+		if err != nil || logN < 1 {
+			return nil, errors.New("invalid scrypt work factor")
+		}
+		if logN > maxWorkFactor {
+			return nil, fmt.Errorf("scrypt work factor %d too large", logN)
+		}
+		k, err := scryptKey(i.password, salt, logN)
+		if err != nil {
+			return nil, err
+		}
+		fileKey, err := aeadOpen(k, s.Body)
+		if err != nil {
+			return nil, errors.New("incorrect passphrase")
+		}
+		return fileKey, nil
+	}
+	return nil, ErrIncorrectIdentity
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package age
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The payload is split in chunks that are sealed separately with a nonce
+// made of an 11-byte big-endian counter and a final byte that is 1 only for
+// the last chunk (the STREAM construction).
+const (
+	chunkSize    = 64 << 10
+	encChunkSize = chunkSize + chacha20poly1305.Overhead
+	lastChunk    = 1
+)
+
+func incNonce(n *[chacha20poly1305.NonceSize]byte) {
+	for i := len(n) - 2; i >= 0; i-- {
+		n[i]++
+		if n[i] != 0 {
+			return
+		}
+	}
+	// 2^88 chunks is unreachable in practice.
+	panic("age: stream nonce overflow")
+}
+
+type writer struct {
+	a     cipher.AEAD
+	dst   io.Writer
+	buf   []byte
+	nonce [chacha20poly1305.NonceSize]byte
+	err   error
+}
+
+func newWriter(key []byte, dst io.Writer) (*writer, error) {
+	a, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &writer{a: a, dst: dst, buf: make([]byte, 0, encChunkSize)}, nil
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	total := len(p)
+	for len(p) > 0 {
+		// Only seal a full chunk once more data arrives, since the last
+		// chunk is flagged and we don't know yet whether this is it.
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return total - len(p), err
+			}
+		}
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+	}
+	return total, nil
+}
+
+func (w *writer) flush(last bool) error {
+	if last {
+		w.nonce[len(w.nonce)-1] = lastChunk
+	}
+	out := w.a.Seal(w.buf[:0], w.nonce[:], w.buf, nil)
+	if _, err := w.dst.Write(out); err != nil {
+		w.err = err
+		return err
+	}
+	w.buf = w.buf[:0]
+	if !last {
+		incNonce(&w.nonce)
+	}
+	return nil
+}
+
+// Close seals and writes the last chunk. It does not close the underlying
+// writer.
+func (w *writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	err := w.flush(true)
+	w.err = errors.New("age: write after Close")
+	return err
+}
+
+type reader struct {
+	a     cipher.AEAD
+	src   *bufio.Reader
+	buf   []byte
+	plain []byte
+	nonce [chacha20poly1305.NonceSize]byte
+	err   error
+}
+
+func newReader(key []byte, src io.Reader) (*reader, error) {
+	a, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	br, ok := src.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(src)
+	}
+	return &reader{a: a, src: br, buf: make([]byte, encChunkSize)}, nil
+}
+
+var errTruncated = errors.New("age: truncated or tampered payload")
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(r.plain) > 0 {
+		n := copy(p, r.plain)
+		r.plain = r.plain[n:]
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	if err := r.next(); err != nil {
+		r.err = err
+		if err != io.EOF {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	if n == 0 && r.err != nil {
+		return 0, r.err
+	}
+	return n, nil
+}
+
+// next decrypts the next chunk into r.plain. It returns io.EOF after the last
+// chunk was decrypted successfully.
+func (r *reader) next() error {
+	n, err := io.ReadFull(r.src, r.buf)
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		// A short chunk is always the last one. It may only be empty
+		// if it is also the first.
+		if n < chacha20poly1305.Overhead || (n == chacha20poly1305.Overhead && r.nonce != [chacha20poly1305.NonceSize]byte{}) {
+			return errTruncated
+		}
+		return r.open(n, true)
+	case err != nil:
+		return err
+	}
+	// A full chunk is the last one only if nothing follows.
+	if _, err := r.src.Peek(1); err == io.EOF {
+		return r.open(n, true)
+	} else if err != nil {
+		return err
+	}
+	return r.open(n, false)
+}
+
+func (r *reader) open(n int, last bool) error {
+	if last {
+		r.nonce[len(r.nonce)-1] = lastChunk
+	}
+	plain, err := r.a.Open(r.buf[:0], r.nonce[:], r.buf[:n], nil)
+	if err != nil {
+		return errTruncated
+	}
+	r.plain = plain
+	if last {
+		return io.EOF
+	}
+	incNonce(&r.nonce)
+	return nil
+}
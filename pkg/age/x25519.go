@@ -0,0 +1,194 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package age
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	x25519Label    = "age-encryption.org/v1/X25519"
+	recipientHRP   = "age"
+	secretKeyHRP   = "AGE-SECRET-KEY-"
+	wrappedKeySize = fileKeyLn + chacha20poly1305.Overhead
+)
+
+// X25519Recipient is the public half of an X25519Identity, encoded as
+// "age1...".
+type X25519Recipient struct {
+	pub []byte
+}
+
+// X25519Identity is an X25519 private key, encoded as "AGE-SECRET-KEY-1...".
+type X25519Identity struct {
+	secret, pub []byte
+}
+
+// GenerateX25519Identity returns a new random identity.
+func GenerateX25519Identity() (*X25519Identity, error) {
+	secret := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return newX25519Identity(secret)
+}
+
+func newX25519Identity(secret []byte) (*X25519Identity, error) {
+	pub, err := curve25519.X25519(secret, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	return &X25519Identity{secret: secret, pub: pub}, nil
+}
+
+// ParseX25519Identity parses an "AGE-SECRET-KEY-1..." string.
+func ParseX25519Identity(s string) (*X25519Identity, error) {
+	hrp, k, err := bech32Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed secret key: %v", err)
+	}
+	if hrp != secretKeyHRP {
+		return nil, fmt.Errorf("malformed secret key: unknown type %q", hrp)
+	}
+	if len(k) != curve25519.ScalarSize {
+		return nil, errors.New("malformed secret key: bad length")
+	}
+	return newX25519Identity(k)
+}
+
+// ParseX25519Recipient parses an "age1..." string.
+func ParseX25519Recipient(s string) (*X25519Recipient, error) {
+	hrp, k, err := bech32Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed recipient %q: %v", s, err)
+	}
+	if hrp != recipientHRP {
+		return nil, fmt.Errorf("malformed recipient %q: unknown type %q", s, hrp)
+	}
+	if len(k) != curve25519.PointSize {
+		return nil, fmt.Errorf("malformed recipient %q: bad length", s)
+	}
+	return &X25519Recipient{pub: k}, nil
+}
+
+// Recipient returns the public key of i.
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	return &X25519Recipient{pub: i.pub}
+}
+
+// String returns the "AGE-SECRET-KEY-1..." encoding of i.
+func (i *X25519Identity) String() string {
+	s, _ := bech32Encode(secretKeyHRP, i.secret)
+	return s
+}
+
+// String returns the "age1..." encoding of r.
+func (r *X25519Recipient) String() string {
+	s, _ := bech32Encode(recipientHRP, r.pub)
+	return s
+}
+
+func aeadSeal(key, plain []byte) ([]byte, error) {
+	a, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return a.Seal(nil, make([]byte, chacha20poly1305.NonceSize), plain, nil), nil
+}
+
+func aeadOpen(key, sealed []byte) ([]byte, error) {
+	a, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return a.Open(nil, make([]byte, chacha20poly1305.NonceSize), sealed, nil)
+}
+
+// Wrap implements Recipient.
+func (r *X25519Recipient) Wrap(fileKey []byte) ([]*Stanza, error) {
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(ephemeral); err != nil {
+		return nil, err
+	}
+	share, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(ephemeral, r.pub)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(append([]byte{}, share...), r.pub...)
+	body, err := aeadSeal(hkdfKey(shared, salt, x25519Label), fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return []*Stanza{{
+		Type: "X25519",
+		Args: []string{b64.EncodeToString(share)},
+		Body: body,
+	}}, nil
+}
+
+// Unwrap implements Identity.
+func (i *X25519Identity) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type != "X25519" {
+			continue
+		}
+		if len(s.Args) != 1 {
+			return nil, errors.New("invalid X25519 recipient block")
+		}
+		share, err := b64.DecodeString(s.Args[0])
+		if err != nil || len(share) != curve25519.PointSize || len(s.Body) != wrappedKeySize {
+			return nil, errors.New("invalid X25519 recipient block")
+		}
+		shared, err := curve25519.X25519(i.secret, share)
+		if err != nil {
+			return nil, fmt.Errorf("invalid X25519 recipient: %v", err)
+		}
+		salt := append(append([]byte{}, share...), i.pub...)
+		fileKey, err := aeadOpen(hkdfKey(shared, salt, x25519Label), s.Body)
+		if err != nil {
+			// Addressed to someone else.
+			continue
+		}
+		return fileKey, nil
+	}
+	return nil, ErrIncorrectIdentity
+}
+
+// ParseIdentities reads X25519 identities from an age key file, one per line.
+// Empty lines and lines starting with '#' are ignored.
+func ParseIdentities(r io.Reader) ([]Identity, error) {
+	var ids []Identity
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		id, err := ParseX25519Identity(l)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no identities found")
+	}
+	return ids, nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package basenc encodes and decodes streams of base64, base32 and base16,
+// as the basenc command of coreutils does.
+//
+// Encoders wrap their output in lines. Decoders ignore line breaks, accept
+// data without its final padding, as metadata services and kernel command
+// lines often have it, and can ignore characters not of their alphabet.
+// Neither holds more than a block of data, so any size can be streamed.
+package basenc
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Encoding is an encoding of bytes in blocks of characters.
+type Encoding struct {
+	// Name is the name of the encoding, as in the options of basenc.
+	Name string
+
+	// in bytes encode to a block of out characters.
+	in, out int
+	// padded is whether partial blocks are padded with '='.
+	padded bool
+	// valid holds the characters that may be decoded.
+	valid [256]bool
+
+	encode func(dst, src []byte)
+	decode func(dst, src []byte) (int, error)
+}
+
+func newEncoding(name, alphabet string, in, out int, padded bool, encode func(dst, src []byte), decode func(dst, src []byte) (int, error)) *Encoding {
+	e := &Encoding{Name: name, in: in, out: out, padded: padded, encode: encode, decode: decode}
+	for _, c := range []byte(alphabet) {
+		e.valid[c] = true
+	}
+	e.valid['='] = padded
+	return e
+}
+
+const (
+	upper  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	lower  = "abcdefghijklmnopqrstuvwxyz"
+	digits = "0123456789"
+)
+
+// The encodings.
+var (
+	Base64    = newEncoding("base64", upper+lower+digits+"+/", 3, 4, true, base64.StdEncoding.Encode, base64.StdEncoding.Decode)
+	Base64URL = newEncoding("base64url", upper+lower+digits+"-_", 3, 4, true, base64.URLEncoding.Encode, base64.URLEncoding.Decode)
+	Base32    = newEncoding("base32", upper+"234567", 5, 8, true, base32.StdEncoding.Encode, base32.StdEncoding.Decode)
+	Base32Hex = newEncoding("base32hex", digits+upper[:22], 5, 8, true, base32.HexEncoding.Encode, base32.HexEncoding.Decode)
+	Base16    = newEncoding("base16", digits+"ABCDEF", 1, 2, false, encodeUpperHex, hex.Decode)
+	Hex       = newEncoding("hex", digits+"abcdefABCDEF", 1, 2, false, func(dst, src []byte) { hex.Encode(dst, src) }, hex.Decode)
+)
+
+// Encodings are the encodings, in the order of the options of basenc.
+var Encodings = []*Encoding{Base64, Base64URL, Base32, Base32Hex, Base16, Hex}
+
+func encodeUpperHex(dst, src []byte) {
+	hex.Encode(dst, src)
+	copy(dst, bytes.ToUpper(dst[:2*len(src)]))
+}
+
+// encodedLen returns the number of characters that n bytes encode to,
+// which are at most a partial block.
+func (e *Encoding) encodedLen(n int) int {
+	if e.padded {
+		return (n + e.in - 1) / e.in * e.out
+	}
+	return n / e.in * e.out
+}
+
+type encoder struct {
+	enc   *Encoding
+	w     io.Writer
+	width int
+	col   int
+	// part holds the bytes of a partial block.
+	part []byte
+	err  error
+}
+
+// NewEncoder returns a writer that encodes what is written to it to w in
+// enc, in lines of width characters, or in one line if width is 0. Close
+// writes the last, partial, block, and ends the last line. Nothing is
+// written for no data.
+func NewEncoder(enc *Encoding, w io.Writer, width int) io.WriteCloser {
+	return &encoder{enc: enc, w: w, width: width}
+}
+
+// wrap writes the characters b, breaking lines.
+func (e *encoder) wrap(b []byte) error {
+	for len(b) > 0 {
+		n := len(b)
+		if e.width > 0 && e.width-e.col < n {
+			n = e.width - e.col
+		}
+		if _, err := e.w.Write(b[:n]); err != nil {
+			return err
+		}
+		b = b[n:]
+		e.col += n
+		if e.width > 0 && e.col == e.width {
+			if _, err := e.w.Write([]byte{'\n'}); err != nil {
+				return err
+			}
+			e.col = 0
+		}
+	}
+	return nil
+}
+
+// Write implements io.Writer.
+func (e *encoder) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n := len(p)
+	if len(e.part) > 0 {
+		k := e.enc.in - len(e.part)
+		if k > len(p) {
+			k = len(p)
+		}
+		e.part = append(e.part, p[:k]...)
+		p = p[k:]
+		if len(e.part) < e.enc.in {
+			return n, nil
+		}
+		p = append(e.part, p...)
+		e.part = e.part[:0]
+	}
+	whole := len(p) / e.enc.in * e.enc.in
+	dst := make([]byte, e.enc.encodedLen(whole))
+	e.enc.encode(dst, p[:whole])
+	if e.err = e.wrap(dst); e.err != nil {
+		return 0, e.err
+	}
+	e.part = append(e.part, p[whole:]...)
+	return n, nil
+}
+
+// Close implements io.Closer. It does not close the underlying writer.
+func (e *encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	// Only padded encodings have partial blocks.
+	if len(e.part) > 0 {
+		dst := make([]byte, e.enc.encodedLen(len(e.part)))
+		e.enc.encode(dst, e.part)
+		e.part = e.part[:0]
+		if e.err = e.wrap(dst); e.err != nil {
+			return e.err
+		}
+	}
+	if e.col > 0 {
+		e.col = 0
+		if _, e.err = e.w.Write([]byte{'\n'}); e.err != nil {
+			return e.err
+		}
+	}
+	return nil
+}
+
+type decoder struct {
+	enc     *Encoding
+	r       io.Reader
+	garbage bool
+	// off is the offset in r of the next character read.
+	off int64
+	buf []byte
+	// chars holds the characters of a partial block.
+	chars []byte
+	// out holds decoded bytes that were not read yet.
+	out []byte
+	err error
+}
+
+// NewDecoder returns a reader that decodes r in enc. Line breaks are
+// ignored, as are all characters not of enc if ignoreGarbage is set.
+func NewDecoder(enc *Encoding, r io.Reader, ignoreGarbage bool) io.Reader {
+	return &decoder{enc: enc, r: r, garbage: ignoreGarbage, buf: make([]byte, 32*1024)}
+}
+
+// fill decodes the whole blocks of the next characters of r.
+func (d *decoder) fill() {
+	n, err := d.r.Read(d.buf)
+	for i, c := range d.buf[:n] {
+		if d.enc.valid[c] {
+			d.chars = append(d.chars, c)
+		} else if c != '\n' && c != '\r' && !d.garbage {
+			n, err = i, fmt.Errorf("invalid %s input %q at offset %d", d.enc.Name, c, d.off+int64(i))
+			break
+		}
+	}
+	d.off += int64(n)
+
+	whole := len(d.chars) / d.enc.out * d.enc.out
+	if err == io.EOF && whole < len(d.chars) {
+		if !d.enc.padded {
+			err = fmt.Errorf("truncated %s input", d.enc.Name)
+		} else {
+			// Complete the padding that was left out.
+			for len(d.chars)%d.enc.out != 0 {
+				d.chars = append(d.chars, '=')
+			}
+			whole = len(d.chars)
+		}
+	}
+
+	// Blocks are decoded one at a time, as padded blocks may be followed
+	// by more, e.g. of concatenated files.
+	d.out = make([]byte, 0, whole/d.enc.out*d.enc.in)
+	blk := make([]byte, d.enc.in)
+	for b := 0; b < whole; b += d.enc.out {
+		k, derr := d.enc.decode(blk, d.chars[b:b+d.enc.out])
+		d.out = append(d.out, blk[:k]...)
+		if derr != nil {
+			err = fmt.Errorf("invalid %s input %q", d.enc.Name, d.chars[b:b+d.enc.out])
+			break
+		}
+	}
+	d.chars = append(d.chars[:0], d.chars[whole:]...)
+	d.err = err
+}
+
+// Read implements io.Reader.
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.out) == 0 && d.err == nil {
+		d.fill()
+	}
+	if len(d.out) > 0 {
+		n := copy(p, d.out)
+		d.out = d.out[n:]
+		return n, nil
+	}
+	return 0, d.err
+}
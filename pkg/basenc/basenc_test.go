@@ -0,0 +1,115 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package basenc
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func encode(t *testing.T, enc *Encoding, in string, width int, chunk int) string {
+	t.Helper()
+	var b bytes.Buffer
+	w := NewEncoder(enc, &b, width)
+	for len(in) > 0 {
+		n := chunk
+		if n > len(in) {
+			n = len(in)
+		}
+		if _, err := io.WriteString(w, in[:n]); err != nil {
+			t.Fatal(err)
+		}
+		in = in[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestEncode(t *testing.T) {
+	for _, tt := range []struct {
+		enc   *Encoding
+		in    string
+		width int
+		want  string
+	}{
+		{enc: Base64, in: "", want: ""},
+		{enc: Base64, in: "ab", want: "YWI=\n"},
+		{enc: Base64, in: "hello, world", width: 8, want: "aGVsbG8s\nIHdvcmxk\n"},
+		{enc: Base64, in: "hello, world!", width: 8, want: "aGVsbG8s\nIHdvcmxk\nIQ==\n"},
+		{enc: Base64, in: "\xfb\xff\xfe", want: "+//+\n"},
+		{enc: Base64URL, in: "\xfb\xff\xfe", want: "-__-\n"},
+		{enc: Base32, in: "ab", want: "MFRA====\n"},
+		{enc: Base32Hex, in: "ab", width: 3, want: "C5H\n0==\n==\n"},
+		{enc: Base16, in: "\x01\xab", want: "01AB\n"},
+		{enc: Hex, in: "\x01\xab", want: "01ab\n"},
+	} {
+		for _, chunk := range []int{1, 2, 5, 100} {
+			if got := encode(t, tt.enc, tt.in, tt.width, chunk); got != tt.want {
+				t.Errorf("%s of %q, width %d, in writes of %d: %q, want %q", tt.enc.Name, tt.in, tt.width, chunk, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, tt := range []struct {
+		enc     *Encoding
+		in      string
+		garbage bool
+		want    string
+		err     string
+	}{
+		{enc: Base64, in: "YWI=\n", want: "ab"},
+		{enc: Base64, in: "YWI", want: "ab"},
+		{enc: Base64, in: "YWI=YWI=", want: "abab"},
+		{enc: Base64, in: "aGVsbG8s\r\nIHdvcmxk\nIQ==\n", want: "hello, world!"},
+		{enc: Base64, in: "aGVs bG8s", want: "hel", err: `invalid base64 input ' ' at offset 4`},
+		{enc: Base64, in: "aGVs bG8s", garbage: true, want: "hello,"},
+		{enc: Base64, in: "YW==", want: "a"},
+		{enc: Base64, in: "Y===", want: "", err: `invalid base64 input "Y==="`},
+		{enc: Base64, in: "-__-", err: `invalid base64 input '-' at offset 0`},
+		{enc: Base64URL, in: "-__-", want: "\xfb\xff\xfe"},
+		{enc: Base32, in: "MFRA", want: "ab"},
+		{enc: Base32Hex, in: "C5H0====", want: "ab"},
+		{enc: Base16, in: "01AB", want: "\x01\xab"},
+		{enc: Base16, in: "01ab", want: "\x01", err: `invalid base16 input 'a' at offset 2`},
+		{enc: Hex, in: "01aB\n", want: "\x01\xab"},
+		{enc: Hex, in: "01a", want: "\x01", err: "truncated hex input"},
+	} {
+		for name, r := range map[string]io.Reader{
+			"whole":     strings.NewReader(tt.in),
+			"bytewise":  iotest.OneByteReader(strings.NewReader(tt.in)),
+			"data EOF":  iotest.DataErrReader(strings.NewReader(tt.in)),
+			"half read": iotest.HalfReader(strings.NewReader(tt.in)),
+		} {
+			got, err := io.ReadAll(NewDecoder(tt.enc, r, tt.garbage))
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Errorf("%s of %q, %s: %v, want %q", tt.enc.Name, tt.in, name, err, tt.err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("%s of %q, %s: %q, want %q", tt.enc.Name, tt.in, name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var in bytes.Buffer
+	for i := 0; i < 100000; i++ {
+		in.WriteByte(byte(i * 7))
+	}
+	for _, enc := range Encodings {
+		s := encode(t, enc, in.String(), 76, 4096)
+		got, err := io.ReadAll(NewDecoder(enc, strings.NewReader(s), false))
+		if err != nil || !bytes.Equal(got, in.Bytes()) {
+			t.Errorf("%s: round trip = %d bytes, %v, want the %d bytes", enc.Name, len(got), err, in.Len())
+		}
+	}
+}
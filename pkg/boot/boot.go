@@ -10,6 +10,7 @@ import (
 	"fmt"
 
 	"github.com/u-root/u-root/pkg/boot/kexec"
+	"github.com/u-root/u-root/pkg/cmdline"
 )
 
 // OSImage represents a bootable OS package.
@@ -43,3 +44,13 @@ type OSImage interface {
 func Execute() error {
 	return kexec.Reboot()
 }
+
+// EditCmdlines edits the kernel command lines of images with f, e.g. a
+// cmdline.Policy, given the command line of the running kernel.
+func EditCmdlines(f cmdline.Filter, running *cmdline.CmdLine, images ...OSImage) {
+	for _, img := range images {
+		img.Edit(func(cl string) string {
+			return f.Update(running, cl)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package boot
+
+import (
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+func TestEditCmdlines(t *testing.T) {
+	li := &LinuxImage{Cmdline: "root=/dev/sda1 console=tty0"}
+	mi := &MultibootImage{Cmdline: "vga=normal"}
+	running := &cmdline.CmdLine{Raw: "console=ttyS0,115200 ip=dhcp"}
+	p := &cmdline.Policy{Inherit: []string{"console", "ip"}, Append: "quiet"}
+
+	EditCmdlines(p, running, li, mi)
+	if want := "root=/dev/sda1 quiet console=ttyS0,115200 ip=dhcp"; li.Cmdline != want {
+		t.Errorf("Linux command line = %q, want %q", li.Cmdline, want)
+	}
+	if want := "vga=normal quiet console=ttyS0,115200 ip=dhcp"; mi.Cmdline != want {
+		t.Errorf("multiboot command line = %q, want %q", mi.Cmdline, want)
+	}
+}
@@ -7,10 +7,13 @@
 package bootcmd
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/u-root/u-root/pkg/boot/menu"
+	"github.com/u-root/u-root/pkg/boot/timing"
 	"github.com/u-root/u-root/pkg/mount"
 )
 
@@ -19,7 +22,8 @@ import (
 //
 // mountPool is unmounted before kexecing. noLoad prints the list of entries
 // and exits. If noLoad is false, a boot menu is shown to the user. The
-// user-chosen boot entry will be kexec'd unless noExec is true.
+// user-chosen boot entry will be kexec'd unless noExec is true. The boot
+// timing report is flushed before, see timing.SetOutput.
 func ShowMenuAndBoot(entries []menu.Entry, mountPool *mount.Pool, noLoad, noExec bool) {
 	if noLoad {
 		log.Print("Not loading menu or kernel. Options:")
@@ -41,6 +45,7 @@ func ShowMenuAndBoot(entries []menu.Entry, mountPool *mount.Pool, noLoad, noExec
 	if loadedEntry == nil {
 		log.Fatalf("Nothing to boot.")
 	}
+	flushTiming()
 	if noExec {
 		log.Printf("Chosen menu entry: %s", loadedEntry)
 		os.Exit(0)
@@ -53,3 +58,13 @@ func ShowMenuAndBoot(entries []menu.Entry, mountPool *mount.Pool, noLoad, noExec
 	// Kexec should either return an error or not return.
 	log.Fatalf("Kexec should have returned an error or not returned at all.")
 }
+
+// flushTiming reports the timing of the boot, without delaying it for long
+// if the report cannot be posted.
+func flushTiming() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := timing.Flush(ctx); err != nil {
+		log.Printf("Failed to report boot timing: %v", err)
+	}
+}
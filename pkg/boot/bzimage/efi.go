@@ -0,0 +1,66 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzimage
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// EFI64LoaderSignature is the LoaderSignature of an EFIInfo of a 64-bit EFI.
+var EFI64LoaderSignature = [4]uint8{'E', 'L', '6', '4'}
+
+// EFIInfo is the efi_info of the boot parameters, which tells a kernel
+// booted by EFI where the EFI system table and memory map are.
+type EFIInfo struct {
+	LoaderSignature [4]uint8
+	Systab          uint32
+	MemdescSize     uint32
+	MemdescVersion  uint32
+	Memmap          uint32
+	MemmapSize      uint32
+	SystabHi        uint32
+	MemmapHi        uint32
+}
+
+// Offsets of fields of the boot parameters that LinuxParams lumps into
+// arrays of bytes.
+const (
+	// acpiRSDPAddrOffset is the offset of acpi_rsdp_addr in Apmbiosinfo.
+	acpiRSDPAddrOffset = 0x70 - 0x40
+
+	// efiInfoOffset is the offset of efi_info in Sysdesctable.
+	efiInfoOffset = 0x1c0 - 0xa0
+)
+
+// EFIInfo returns the efi_info of the boot parameters. Its LoaderSignature
+// is zero if the kernel was not booted by EFI.
+func (h *LinuxParams) EFIInfo() EFIInfo {
+	var e EFIInfo
+	// Sysdesctable always holds an EFIInfo.
+	_ = binary.Read(bytes.NewReader(h.Sysdesctable[efiInfoOffset:]), binary.LittleEndian, &e)
+	return e
+}
+
+// SetEFIInfo sets the efi_info of the boot parameters. The zero EFIInfo
+// makes the kernel boot without EFI.
+func (h *LinuxParams) SetEFIInfo(e EFIInfo) {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, e)
+	copy(h.Sysdesctable[efiInfoOffset:], buf.Bytes())
+}
+
+// ACPIRSDPAddr returns the physical address of the ACPI RSDP of the boot
+// parameters, which kernels of boot protocol 2.14 and later use rather than
+// look for it, or 0 if it is not set.
+func (h *LinuxParams) ACPIRSDPAddr() uint64 {
+	return binary.LittleEndian.Uint64(h.Apmbiosinfo[acpiRSDPAddrOffset:])
+}
+
+// SetACPIRSDPAddr sets the physical address of the ACPI RSDP of the boot
+// parameters.
+func (h *LinuxParams) SetACPIRSDPAddr(addr uint64) {
+	binary.LittleEndian.PutUint64(h.Apmbiosinfo[acpiRSDPAddrOffset:], addr)
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzimage
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEFIInfo(t *testing.T) {
+	want := EFIInfo{
+		LoaderSignature: EFI64LoaderSignature,
+		Systab:          0x7f6e2018,
+		MemdescSize:     48,
+		MemdescVersion:  1,
+		Memmap:          0x6e000000,
+		MemmapSize:      0x1230,
+		SystabHi:        1,
+		MemmapHi:        2,
+	}
+	var lp LinuxParams
+	lp.SetEFIInfo(want)
+	lp.SetACPIRSDPAddr(0x7fbfa014)
+	if got := lp.EFIInfo(); got != want {
+		t.Errorf("EFIInfo = %+v, want %+v", got, want)
+	}
+	if got := lp.ACPIRSDPAddr(); got != 0x7fbfa014 {
+		t.Errorf("ACPIRSDPAddr = %#x, want 0x7fbfa014", got)
+	}
+
+	// They are at their offsets in struct boot_params.
+	b, err := lp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b[0x1c0:0x1c8], []byte{'E', 'L', '6', '4', 0x18, 0x20, 0x6e, 0x7f}) {
+		t.Errorf("efi_info = %#x, want EL64 and the system table", b[0x1c0:0x1e0])
+	}
+	if !bytes.Equal(b[0x70:0x78], []byte{0x14, 0xa0, 0xbf, 0x7f, 0, 0, 0, 0}) {
+		t.Errorf("acpi_rsdp_addr = %#x, want 0x7fbfa014", b[0x70:0x78])
+	}
+}
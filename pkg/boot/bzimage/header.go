@@ -42,6 +42,17 @@ const (
 	E820NR  = 0x1e8
 )
 
+// XLoadFlags bits.
+const (
+	// XLFKernel64 is set if the kernel has a 64-bit entry point, and can
+	// be loaded above 4G.
+	XLFKernel64 = 1 << 0
+
+	// XLFCanBeLoadedAbove4G is set if the initrd, command line and boot
+	// parameters can be above 4G.
+	XLFCanBeLoadedAbove4G = 1 << 1
+)
+
 // what's an EDD? No idea.
 /*
  * EDD stuff
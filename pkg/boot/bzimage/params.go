@@ -0,0 +1,44 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzimage
+
+import "encoding/binary"
+
+// Offsets of the upper 32 bits of the initrd and command line in
+// Sysdesctable, as LinuxHeader has them.
+const (
+	extRamdiskImageOffset = 0xc0 - 0xa0
+	extRamdiskSizeOffset  = 0xc4 - 0xa0
+	extCmdLinePtrOffset   = 0xc8 - 0xa0
+)
+
+// Initrd returns the address and size of the initrd of the boot parameters.
+func (h *LinuxParams) Initrd() (addr, size uint64) {
+	addr = uint64(binary.LittleEndian.Uint32(h.Sysdesctable[extRamdiskImageOffset:]))<<32 | uint64(h.Initrdstart)
+	size = uint64(binary.LittleEndian.Uint32(h.Sysdesctable[extRamdiskSizeOffset:]))<<32 | uint64(h.Initrdsize)
+	return addr, size
+}
+
+// SetInitrd sets the address and size of the initrd of the boot parameters.
+// Only kernels which set XLFCanBeLoadedAbove4G use their upper 32 bits.
+func (h *LinuxParams) SetInitrd(addr, size uint64) {
+	h.Initrdstart, h.Initrdsize = uint32(addr), uint32(size)
+	binary.LittleEndian.PutUint32(h.Sysdesctable[extRamdiskImageOffset:], uint32(addr>>32))
+	binary.LittleEndian.PutUint32(h.Sysdesctable[extRamdiskSizeOffset:], uint32(size>>32))
+}
+
+// CmdLinePtr returns the address of the command line of the boot
+// parameters.
+func (h *LinuxParams) CmdLinePtr() uint64 {
+	return uint64(binary.LittleEndian.Uint32(h.Sysdesctable[extCmdLinePtrOffset:]))<<32 | uint64(h.CLPtr)
+}
+
+// SetCmdLinePtr sets the address of the command line of the boot
+// parameters. Only kernels which set XLFCanBeLoadedAbove4G use its upper 32
+// bits.
+func (h *LinuxParams) SetCmdLinePtr(addr uint64) {
+	h.CLPtr = uint32(addr)
+	binary.LittleEndian.PutUint32(h.Sysdesctable[extCmdLinePtrOffset:], uint32(addr>>32))
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzimage
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestLinuxParamsAbove4G(t *testing.T) {
+	var lp LinuxParams
+	lp.SetInitrd(0x1_2340_0000, 0x2_0000_1000)
+	lp.SetCmdLinePtr(0x3_0000_2000)
+	if addr, size := lp.Initrd(); addr != 0x1_2340_0000 || size != 0x2_0000_1000 {
+		t.Errorf("Initrd = %#x, %#x, want 0x123400000, 0x200001000", addr, size)
+	}
+	if got := lp.CmdLinePtr(); got != 0x3_0000_2000 {
+		t.Errorf("CmdLinePtr = %#x, want 0x300002000", got)
+	}
+
+	// The upper bits are where the boot protocol has them.
+	b, err := lp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []struct {
+		name string
+		off  int
+		want uint32
+	}{
+		{"ramdisk_image", 0x218, 0x2340_0000},
+		{"ramdisk_size", 0x21c, 0x1000},
+		{"cmd_line_ptr", 0x228, 0x2000},
+		{"ext_ramdisk_image", 0xc0, 1},
+		{"ext_ramdisk_size", 0xc4, 2},
+		{"ext_cmd_line_ptr", 0xc8, 3},
+	} {
+		if got := binary.LittleEndian.Uint32(b[f.off:]); got != f.want {
+			t.Errorf("%s = %#x, want %#x", f.name, got, f.want)
+		}
+	}
+}
@@ -193,7 +193,7 @@ func lazyOpenModules(mods []module) multiboot.Modules {
 	for _, m := range mods {
 		modules = append(modules, multiboot.Module{
 			Cmdline: m.cmdline,
-			Module:  uio.NewLazyFile(m.path),
+			Module:  uio.NewLazyMmapFile(m.path),
 		})
 	}
 	return modules
@@ -225,7 +225,7 @@ func getBootImage(opts options, device string, partition int, name string) (*boo
 
 	return &boot.MultibootImage{
 		Name:    fmt.Sprintf("%s from %s", opts.title, name),
-		Kernel:  uio.NewLazyFile(opts.kernel),
+		Kernel:  uio.NewLazyMmapFile(opts.kernel),
 		Cmdline: opts.args,
 		Modules: lazyOpenModules(opts.modules),
 	}, nil
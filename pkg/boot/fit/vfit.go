@@ -25,6 +25,7 @@ import (
 	"strings"
 	"unicode"
 
+	"github.com/u-root/u-root/pkg/boot/timing"
 	"github.com/u-root/u-root/pkg/dt"
 	"github.com/u-root/u-root/pkg/vfile"
 	"golang.org/x/crypto/openpgp"
@@ -193,6 +194,7 @@ func parseSignatures(n ...*dt.Node) ([]Signature, error) {
 // If the signature does not exist or does not match the keyring, both the file
 // and a signature error will be returned.
 func (i *Image) ReadSignedImage(image string, ring openpgp.KeyRing) (*bytes.Reader, error) {
+	verified := timing.Begin(timing.Verify)
 	iroot := i.Root.Root().Walk("images").Walk(image)
 	b, err := iroot.Property("data").AsBytes()
 	if err != nil {
@@ -214,10 +216,13 @@ func (i *Image) ReadSignedImage(image string, ring openpgp.KeyRing) (*bytes.Read
 	for _, sig := range sigs {
 		v, err := sig.Verify(b, ring)
 		if err == nil {
+			verified(nil)
 			return v, nil
 		}
 		fmt.Printf("Ignoring failed signature - %s: Failed with %v\n", sig, err)
 	}
 
-	return br, vfile.ErrUnsigned{Path: image, Err: vfile.ErrWrongSigner{i.KeyRing}}
+	err = vfile.ErrUnsigned{Path: image, Err: vfile.ErrWrongSigner{i.KeyRing}}
+	verified(err)
+	return br, err
 }
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"regexp"
 	"sort"
 	"strings"
 )
@@ -61,6 +62,20 @@ func (env *EnvFile) WriteTo(w io.Writer) (int64, error) {
 	return b.WriteTo(w)
 }
 
+// emptyEnvVar is a variable save_env saved as empty, which ParseEnvFile does
+// not accept.
+var emptyEnvVar = regexp.MustCompile(`(?m)^[^#=\n]+=\r?$`)
+
+// ParseSavedEnvFile reads a GRUB environment file as save_env writes it, with
+// the variables that are empty, e.g. next_entry once it was used, left out.
+func ParseSavedEnvFile(r io.Reader) (*EnvFile, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEnvFile(bytes.NewReader(emptyEnvVar.ReplaceAll(b, nil)))
+}
+
 // ParseEnvFile reads a key-value pair GRUB environment file.
 //
 // ParseEnvFile accepts incorrectly padded GRUB env files, as opposed to GRUB.
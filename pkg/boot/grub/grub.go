@@ -11,9 +11,15 @@
 // - https://www.gnu.org/software/grub/manual/grub/html_node/Commands.html
 //
 // See parser.append function for list of commands that are supported.
+//
+// Entries are returned in the order GRUB would try them: the default entry,
+// then the fallback entries, then the rest. The default entry may be the
+// saved_entry or next_entry of the GRUB environment block read by load_env,
+// which is not written back.
 package grub
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -60,6 +66,9 @@ var probeGrubFiles = []string{
 var hexEscape = regexp.MustCompile(`\\x[0-9a-fA-F]{2}`)
 var anyEscape = regexp.MustCompile(`\\.{0,3}`)
 
+// varRef is a reference to a variable, as $name or ${name}.
+var varRef = regexp.MustCompile(`\$(\{[A-Za-z0-9_]+\}|[A-Za-z0-9_]+)`)
+
 // mountFlags are the flags this grub interpreter uses to mount partitions.
 var mountFlags = uintptr(mount.ReadOnly)
 
@@ -146,9 +155,7 @@ func ParseConfigFile(ctx context.Context, s curl.Schemes, configFile string, roo
 	seenLinux := make(map[*boot.LinuxImage]struct{})
 	seenMB := make(map[*boot.MultibootImage]struct{})
 
-	if defaultEntry, ok := p.variables["default"]; ok {
-		p.labelOrder = append([]string{defaultEntry}, p.labelOrder...)
-	}
+	p.labelOrder = append(p.defaultEntries(), p.labelOrder...)
 
 	var images []boot.OSImage
 	if p.blscfgFound {
@@ -183,18 +190,36 @@ type parser struct {
 	W io.Writer
 
 	// parser internals.
-	numEntry int
+
 	// Special variables:
 	//   * default: Default boot option.
+	//   * fallback: Boot options to try if the default fails.
 	//   * root: Root "partition" as a URL.
 	variables map[string]string
 
-	// curEntry is the current entry number as a string.
+	// env is the GRUB environment block read by load_env, if any.
+	env map[string]string
+
+	// prefix is the directory of the config file, where load_env reads
+	// grubenv from.
+	prefix *url.URL
+
+	// menus are the open submenus, the top level menu first, and blocks
+	// are the open { blocks, with the submenu each opens, if any.
+	menus  []*menu
+	blocks []*menu
+
+	// curEntry is the current entry number as a string, e.g. "2>1" for
+	// the second entry of the third submenu.
 	curEntry string
 
 	// curLabel is the last parsed label from a "menuentry".
 	curLabel string
 
+	// curKeys are the names of the current entry default may use: its
+	// number, label, title and id.
+	curKeys []string
+
 	devices   block.BlockDevices
 	mountPool *mount.Pool
 	schemes   curl.Schemes
@@ -224,6 +249,7 @@ func newParser(root *url.URL, devices block.BlockDevices, mountPool *mount.Pool,
 		variables: map[string]string{
 			"root": root.String(),
 		},
+		menus:       []*menu{{}},
 		devices:     devices,
 		mountPool:   mountPool,
 		schemes:     s,
@@ -278,6 +304,11 @@ func (c *parser) appendFile(ctx context.Context, url string) error {
 	if err != nil {
 		return err
 	}
+	if c.prefix == nil {
+		prefix := *u
+		prefix.Path = filepath.Dir(u.Path)
+		c.prefix = &prefix
+	}
 
 	config, err := uio.ReadAll(r)
 	if err != nil {
@@ -293,6 +324,138 @@ func (c *parser) appendFile(ctx context.Context, url string) error {
 	return c.append(ctx, string(config))
 }
 
+// menu is a submenu, or the top level menu.
+type menu struct {
+	// index, title and id name the submenu, e.g. "2", "Advanced options"
+	// and "gnulinux-advanced", with > between nested submenus. They are
+	// empty for the top level menu.
+	index, title, id string
+
+	// entries is the number of entries and submenus so far.
+	entries int
+}
+
+// join joins the name of a submenu and the name of an entry in it, e.g. "2"
+// and "1" into "2>1", as GRUB names the entries of submenus.
+func join(submenu, name string) string {
+	if submenu == "" {
+		return name
+	}
+	return submenu + ">" + name
+}
+
+// menuID returns the id of a menuentry or submenu, given with --id, or with
+// $menuentry_id_option as grub-mkconfig does.
+func menuID(opts []string) string {
+	for i, o := range opts {
+		switch {
+		case (o == "--id" || o == "$menuentry_id_option") && i+1 < len(opts):
+			return opts[i+1]
+		case strings.HasPrefix(o, "--id="):
+			return strings.TrimPrefix(o, "--id=")
+		}
+	}
+	return ""
+}
+
+// closeBlock closes the innermost { block, and the submenu it opened.
+func (c *parser) closeBlock() {
+	if len(c.blocks) == 0 {
+		return
+	}
+	m := c.blocks[len(c.blocks)-1]
+	c.blocks = c.blocks[:len(c.blocks)-1]
+	if m != nil {
+		c.menus = c.menus[:len(c.menus)-1]
+	}
+}
+
+// expand expands the variables in s.
+func (c *parser) expand(s string) string {
+	return varRef.ReplaceAllStringFunc(s, func(ref string) string {
+		return c.variables[strings.Trim(ref, "${}")]
+	})
+}
+
+// loadEnv reads the GRUB environment block into the variables, as load_env
+// does: from grubenv next to the config file, or from the file given with
+// --file, and only the variables given, if any.
+func (c *parser) loadEnv(ctx context.Context, args []string) {
+	fs := pflag.NewFlagSet("grub.load_env", pflag.ContinueOnError)
+	file := fs.StringP("file", "f", "", "")
+	// Ignored flags
+	fs.Bool("skip-sig", false, "ignored")
+	if err := fs.Parse(args); err != nil {
+		log.Printf("Warning: Grub parser could not parse %q", args)
+		return
+	}
+
+	var u *url.URL
+	switch {
+	case *file != "":
+		var err error
+		if u, err = parseURL(*file, c.variables["root"]); err != nil {
+			log.Printf("Warning: Grub parser could not parse %q: %v", args, err)
+			return
+		}
+	case c.prefix != nil:
+		env := *c.prefix
+		env.Path = filepath.Join(env.Path, "grubenv")
+		u = &env
+	default:
+		return
+	}
+	r, err := c.schemes.Fetch(ctx, u)
+	if err != nil {
+		log.Printf("[grub] No environment block: %v", err)
+		return
+	}
+	b, err := uio.ReadAll(r)
+	if err != nil {
+		log.Printf("[grub] Could not read environment block %s: %v", u, err)
+		return
+	}
+	env, err := ParseSavedEnvFile(bytes.NewReader(b))
+	if err != nil {
+		log.Printf("[grub] Could not parse environment block %s: %v", u, err)
+		return
+	}
+
+	only := make(map[string]bool)
+	for _, name := range fs.Args() {
+		only[name] = true
+	}
+	if c.env == nil {
+		c.env = make(map[string]string)
+	}
+	for k, v := range env.Vars {
+		// TODO: We cannot parse grub device syntax.
+		if k == "root" || len(only) > 0 && !only[k] {
+			continue
+		}
+		c.env[k] = v
+		c.variables[k] = v
+	}
+}
+
+// defaultEntries returns the names of the entries GRUB boots first: the
+// next_entry of the environment block, for the one boot grub-mkconfig sets it
+// for, or else default, where "saved" is the saved_entry of the environment
+// block; then the fallback entries.
+func (c *parser) defaultEntries() []string {
+	var names []string
+	if next := c.env["next_entry"]; next != "" {
+		names = append(names, next)
+	} else if def := c.variables["default"]; def == "saved" {
+		if saved := c.env["saved_entry"]; saved != "" {
+			names = append(names, saved)
+		}
+	} else if def != "" {
+		names = append(names, def)
+	}
+	return append(names, strings.Fields(c.variables["fallback"])...)
+}
+
 // CmdlineQuote quotes the command line as grub-core/lib/cmdline.c does
 func cmdlineQuote(args []string) string {
 	q := make([]string, len(args))
@@ -335,6 +498,21 @@ func (c *parser) append(ctx context.Context, config string) error {
 			c.blscfgFound = true
 		}
 
+		// Keep track of { blocks, for which submenu entries are in.
+		switch {
+		case directive == "}":
+			c.closeBlock()
+			continue
+		case kv[len(kv)-1] == "{" && directive != "submenu":
+			c.blocks = append(c.blocks, nil)
+		}
+
+		// load_env usually has no arguments either.
+		if directive == "load_env" {
+			c.loadEnv(ctx, kv[1:])
+			continue
+		}
+
 		// Used by tests (allow no parameters here)
 		if c.W != nil && directive == "echo" {
 			fmt.Fprintf(c.W, "echo:%#v\n", kv[1:])
@@ -456,7 +634,7 @@ func (c *parser) append(ctx context.Context, config string) error {
 				if vals[0] == "root" {
 					continue
 				}
-				c.variables[vals[0]] = vals[1]
+				c.variables[vals[0]] = c.expand(vals[1])
 			}
 
 		case "configfile":
@@ -465,10 +643,29 @@ func (c *parser) append(ctx context.Context, config string) error {
 				return err
 			}
 
+		case "submenu":
+			m := c.menus[len(c.menus)-1]
+			sub := &menu{
+				index: join(m.index, strconv.Itoa(m.entries)),
+				title: join(m.title, arg),
+				id:    join(m.id, menuID(kv[2:])),
+			}
+			m.entries++
+			c.menus = append(c.menus, sub)
+			c.blocks = append(c.blocks, sub)
+
 		case "menuentry":
-			c.curEntry = strconv.Itoa(c.numEntry)
+			m := c.menus[len(c.menus)-1]
+			c.curEntry = join(m.index, strconv.Itoa(m.entries))
 			c.curLabel = arg
-			c.numEntry++
+			m.entries++
+			c.curKeys = []string{c.curEntry, c.curLabel}
+			if m.title != "" {
+				c.curKeys = append(c.curKeys, join(m.title, arg))
+			}
+			if id := menuID(kv[2:]); id != "" {
+				c.curKeys = append(c.curKeys, join(m.id, id))
+			}
 			c.labelOrder = append(c.labelOrder, c.curEntry, c.curLabel)
 
 		case "linux", "linux16", "linuxefi":
@@ -482,8 +679,9 @@ func (c *parser) append(ctx context.Context, config string) error {
 				Kernel:  k,
 				Cmdline: cmdlineQuote(kv[2:]),
 			}
-			c.linuxEntries[c.curEntry] = entry
-			c.linuxEntries[c.curLabel] = entry
+			for _, k := range c.curKeys {
+				c.linuxEntries[k] = entry
+			}
 
 		case "initrd", "initrd16", "initrdefi":
 			if e, ok := c.linuxEntries[c.curEntry]; ok {
@@ -506,8 +704,9 @@ func (c *parser) append(ctx context.Context, config string) error {
 				Kernel:  k,
 				Cmdline: cmdlineQuote(kv[2:]),
 			}
-			c.mbEntries[c.curEntry] = entry
-			c.mbEntries[c.curLabel] = entry
+			for _, k := range c.curKeys {
+				c.mbEntries[k] = entry
+			}
 
 		case "module":
 			// TODO handle --nounzip arguments ? (change parsing)
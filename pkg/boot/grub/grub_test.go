@@ -5,8 +5,15 @@
 package grub
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/u-root/u-root/pkg/mount"
 )
 
 func TestCmdlineQuote(t *testing.T) {
@@ -44,3 +51,122 @@ func TestCmdlineQuote(t *testing.T) {
 		})
 	}
 }
+
+// mkconfig is the default entry logic of grub-mkconfig, followed by entries
+// and a submenu.
+const mkconfig = `if [ -s $prefix/grubenv ]; then
+  load_env
+fi
+if [ "${next_entry}" ] ; then
+   set default="${next_entry}"
+   set next_entry=
+   save_env next_entry
+   set boot_once=true
+else
+   set default="${saved_entry}"
+fi
+if [ x"${feature_menuentry_id}" = xy ]; then
+  menuentry_id_option="--id"
+else
+  menuentry_id_option=""
+fi
+menuentry 'Debian' --class debian $menuentry_id_option 'gnulinux-simple' {
+	linux /vmlinuz-6.1 root=/dev/sda1
+}
+submenu 'Advanced options for Debian' $menuentry_id_option 'gnulinux-advanced' {
+	menuentry 'Debian, with Linux 6.1' $menuentry_id_option 'gnulinux-6.1-advanced' {
+		linux /vmlinuz-6.1 root=/dev/sda1
+	}
+	menuentry 'Debian, with Linux 5.10' $menuentry_id_option 'gnulinux-5.10-advanced' {
+		linux /vmlinuz-5.10 root=/dev/sda1
+	}
+}
+menuentry 'Rescue' --id rescue {
+	linux /vmlinuz-rescue
+}
+`
+
+func TestDefaultEntry(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config string
+		env    string
+		want   []string
+	}{
+		{
+			name:   "no grubenv",
+			config: mkconfig,
+			want:   []string{"Debian", "Debian, with Linux 6.1", "Debian, with Linux 5.10", "Rescue"},
+		},
+		{
+			name:   "saved entry by id",
+			config: mkconfig,
+			env:    "# GRUB Environment Block\nsaved_entry=gnulinux-advanced>gnulinux-5.10-advanced\nnext_entry=\n####",
+			want:   []string{"Debian, with Linux 5.10", "Debian", "Debian, with Linux 6.1", "Rescue"},
+		},
+		{
+			name:   "saved entry by number",
+			config: mkconfig,
+			env:    "saved_entry=2\n",
+			want:   []string{"Rescue", "Debian", "Debian, with Linux 6.1", "Debian, with Linux 5.10"},
+		},
+		{
+			name:   "saved entry by title",
+			config: mkconfig,
+			env:    "saved_entry=Advanced options for Debian>Debian, with Linux 5.10\n",
+			want:   []string{"Debian, with Linux 5.10", "Debian", "Debian, with Linux 6.1", "Rescue"},
+		},
+		{
+			name:   "next entry",
+			config: mkconfig,
+			env:    "saved_entry=1>1\nnext_entry=rescue\n",
+			want:   []string{"Rescue", "Debian", "Debian, with Linux 6.1", "Debian, with Linux 5.10"},
+		},
+		{
+			name:   "unknown saved entry",
+			config: mkconfig,
+			env:    "saved_entry=gone\n",
+			want:   []string{"Debian", "Debian, with Linux 6.1", "Debian, with Linux 5.10", "Rescue"},
+		},
+		{
+			name:   "default and fallback",
+			config: "set default=rescue\nset fallback=\"1>1 0\"\n" + mkconfig[strings.Index(mkconfig, "menuentry"):],
+			want:   []string{"Rescue", "Debian, with Linux 5.10", "Debian", "Debian, with Linux 6.1"},
+		},
+		{
+			name:   "saved",
+			config: "load_env -f /boot/grub/other.env saved_entry\nset default=saved\n" + mkconfig[strings.Index(mkconfig, "menuentry"):],
+			env:    "saved_entry=1>1\n",
+			want:   []string{"Debian, with Linux 5.10", "Debian", "Debian, with Linux 6.1", "Rescue"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			grubDir := filepath.Join(dir, "boot", "grub")
+			if err := os.MkdirAll(grubDir, 0o777); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(grubDir, "grub.cfg"), []byte(tt.config), 0o666); err != nil {
+				t.Fatal(err)
+			}
+			if tt.env != "" {
+				for _, name := range []string{"grubenv", "other.env"} {
+					if err := os.WriteFile(filepath.Join(grubDir, name), []byte(tt.env), 0o666); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			imgs, err := ParseLocalConfig(context.Background(), dir, nil, &mount.Pool{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, img := range imgs {
+				got = append(got, img.Label())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("images = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
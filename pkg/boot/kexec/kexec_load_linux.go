@@ -27,7 +27,15 @@ func Load(entry uintptr, segments Segments, flags uint64) error {
 	if !segments.PhysContains(entry) {
 		return fmt.Errorf("entry point %#v is not contained by any segment", entry)
 	}
-	return rawLoad(entry, segments, flags)
+
+	// A locked down kernel refuses any kexec_load with EPERM.
+	if err := ReadEnforcement().CheckLoad(); err != nil {
+		return err
+	}
+	if err := rawLoad(entry, segments, flags); err != nil {
+		return loadError(err)
+	}
+	return nil
 }
 
 // ErrKexec is returned by Load if the kexec failed. It describes entry point,
@@ -44,6 +52,11 @@ func (e ErrKexec) Error() string {
 	return fmt.Sprintf("kexec_load(entry=%#x, segments=%s, flags %#x) = errno %s", e.Entry, e.Segments, e.Flags, e.Errno)
 }
 
+// Unwrap returns the errno.
+func (e ErrKexec) Unwrap() error {
+	return e.Errno
+}
+
 // rawLoad is a wrapper around kexec_load(2) syscall.
 // Preconditions:
 // - segments must not overlap
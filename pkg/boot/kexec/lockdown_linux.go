@@ -0,0 +1,137 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kexec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Lockdown modes of the kernel, see kernel_lockdown(7).
+const (
+	LockdownNone            = "none"
+	LockdownIntegrity       = "integrity"
+	LockdownConfidentiality = "confidentiality"
+)
+
+var (
+	lockdownPath = "/sys/kernel/security/lockdown"
+	efivarsPath  = "/sys/firmware/efi/efivars"
+)
+
+// Enforcement is what the firmware and kernel enforce about the next kernel.
+type Enforcement struct {
+	// SecureBoot is whether UEFI Secure Boot is enabled.
+	SecureBoot bool
+
+	// Lockdown is the lockdown mode of the kernel, or "" if it is unknown
+	// because securityfs is not mounted or the kernel has no lockdown
+	// support.
+	Lockdown string
+}
+
+// ReadEnforcement returns what the firmware and kernel enforce. What cannot
+// be read is assumed to be off.
+func ReadEnforcement() Enforcement {
+	var e Enforcement
+	// The variable has 4 bytes of attributes, then 1 if Secure Boot is
+	// enabled.
+	if b, err := os.ReadFile(filepath.Join(efivarsPath, "SecureBoot-8be4df61-93ca-11d2-aa0d-00e098032b8c")); err == nil && len(b) == 5 {
+		e.SecureBoot = b[4] == 1
+	}
+	// The current mode is in brackets: "none [integrity] confidentiality".
+	if b, err := os.ReadFile(lockdownPath); err == nil {
+		if _, mode, ok := strings.Cut(string(b), "["); ok {
+			e.Lockdown, _, _ = strings.Cut(mode, "]")
+		}
+	}
+	return e
+}
+
+// LockedDown returns whether the kernel is locked down.
+func (e Enforcement) LockedDown() bool {
+	return e.Lockdown != "" && e.Lockdown != LockdownNone
+}
+
+func (e Enforcement) String() string {
+	sb := "Secure Boot disabled"
+	if e.SecureBoot {
+		sb = "Secure Boot enabled"
+	}
+	switch e.Lockdown {
+	case "":
+		return sb + ", lockdown unknown"
+	case LockdownNone:
+		return sb + ", not locked down"
+	}
+	return fmt.Sprintf("%s, lockdown %s", sb, e.Lockdown)
+}
+
+// ErrEnforced is returned when a kexec is refused because of Secure Boot or
+// kernel lockdown. It says how to boot anyway.
+type ErrEnforced struct {
+	Enforcement
+
+	// Syscall is kexec_load or kexec_file_load.
+	Syscall string
+
+	// Err is the error of the syscall, or nil if the policy refused it
+	// before it was made.
+	Err error
+}
+
+func (e ErrEnforced) Error() string {
+	if e.Syscall == "kexec_file_load" {
+		return fmt.Sprintf("kexec_file_load: %v (%v): the kernel's signature was rejected; "+
+			"sign it, e.g. with sbsign, with a key of the UEFI db or of the kernel's built-in trusted keys", e.Err, e.Enforcement)
+	}
+	reason := "refused"
+	if e.Err != nil {
+		reason = e.Err.Error()
+	}
+	return fmt.Sprintf("kexec_load: %s (%v): a kernel that is locked down only loads signed Linux kernels with kexec_file_load; "+
+		"do not use kexec_load (kexec -c, or LoadSyscall), and sign the kernel with a key the kernel trusts", reason, e.Enforcement)
+}
+
+func (e ErrEnforced) Unwrap() error {
+	return e.Err
+}
+
+// CheckLoad returns an ErrEnforced if the kernel refuses kexec_load.
+func (e Enforcement) CheckLoad() error {
+	if e.LockedDown() {
+		return ErrEnforced{Enforcement: e, Syscall: "kexec_load"}
+	}
+	return nil
+}
+
+// loadError explains a failed kexec_load if Secure Boot or lockdown may be
+// the cause, e.g. when securityfs is not mounted and CheckLoad passed.
+func loadError(err error) error {
+	if !errors.Is(err, unix.EPERM) {
+		return err
+	}
+	if e := ReadEnforcement(); e.SecureBoot || e.LockedDown() {
+		return ErrEnforced{Enforcement: e, Syscall: "kexec_load", Err: err}
+	}
+	return err
+}
+
+// fileLoadError explains a failed kexec_file_load if signature enforcement
+// is the cause.
+func fileLoadError(err error) error {
+	for _, errno := range []syscall.Errno{unix.EKEYREJECTED, unix.ENOKEY, unix.EKEYREVOKED, unix.EKEYEXPIRED, unix.EBADMSG, unix.EPERM} {
+		if errors.Is(err, errno) {
+			return ErrEnforced{Enforcement: ReadEnforcement(), Syscall: "kexec_file_load", Err: err}
+		}
+	}
+	return err
+}
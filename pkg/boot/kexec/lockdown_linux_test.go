@@ -0,0 +1,84 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kexec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func fakeEnforcement(t *testing.T, secureBoot byte, lockdown string) {
+	dir := t.TempDir()
+	oldLockdown, oldEfivars := lockdownPath, efivarsPath
+	t.Cleanup(func() { lockdownPath, efivarsPath = oldLockdown, oldEfivars })
+	lockdownPath, efivarsPath = filepath.Join(dir, "lockdown"), dir
+
+	if secureBoot != 0xff {
+		v := []byte{0x06, 0, 0, 0, secureBoot}
+		if err := os.WriteFile(filepath.Join(dir, "SecureBoot-8be4df61-93ca-11d2-aa0d-00e098032b8c"), v, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lockdown != "" {
+		if err := os.WriteFile(lockdownPath, []byte(lockdown+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadEnforcement(t *testing.T) {
+	for _, tt := range []struct {
+		secureBoot byte
+		lockdown   string
+		want       Enforcement
+		locked     bool
+	}{
+		{secureBoot: 0xff, want: Enforcement{}},
+		{secureBoot: 0, lockdown: "[none] integrity confidentiality", want: Enforcement{Lockdown: LockdownNone}},
+		{secureBoot: 1, lockdown: "none [integrity] confidentiality", want: Enforcement{SecureBoot: true, Lockdown: LockdownIntegrity}, locked: true},
+		{secureBoot: 1, lockdown: "none integrity [confidentiality]", want: Enforcement{SecureBoot: true, Lockdown: LockdownConfidentiality}, locked: true},
+		{secureBoot: 1, want: Enforcement{SecureBoot: true}},
+	} {
+		fakeEnforcement(t, tt.secureBoot, tt.lockdown)
+		got := ReadEnforcement()
+		if got != tt.want {
+			t.Errorf("ReadEnforcement() = %+v, want %+v", got, tt.want)
+		}
+		if err := got.CheckLoad(); (err != nil) != tt.locked {
+			t.Errorf("%v: CheckLoad() = %v, want error %v", got, err, tt.locked)
+		}
+	}
+}
+
+func TestEnforcementErrors(t *testing.T) {
+	fakeEnforcement(t, 1, "[none] integrity confidentiality")
+
+	errno := ErrKexec{Errno: unix.EPERM}
+	var enforced ErrEnforced
+	if err := loadError(errno); !errors.As(err, &enforced) || !errors.Is(err, unix.EPERM) {
+		t.Errorf("loadError(EPERM) = %v, want ErrEnforced wrapping EPERM", err)
+	}
+	if err := loadError(ErrKexec{Errno: unix.EINVAL}); errors.As(err, &enforced) {
+		t.Errorf("loadError(EINVAL) = %v, want no ErrEnforced", err)
+	}
+
+	rejected := fmt.Errorf("kexec_file_load: %w", unix.EKEYREJECTED)
+	if err := fileLoadError(rejected); !errors.As(err, &enforced) || !enforced.SecureBoot || !errors.Is(err, unix.EKEYREJECTED) {
+		t.Errorf("fileLoadError(EKEYREJECTED) = %v, want ErrEnforced wrapping EKEYREJECTED", err)
+	}
+	if err := fileLoadError(fmt.Errorf("kexec_file_load: %w", unix.ENOEXEC)); errors.As(err, &enforced) {
+		t.Errorf("fileLoadError(ENOEXEC) = %v, want no ErrEnforced", err)
+	}
+
+	fakeEnforcement(t, 0, "[none] integrity confidentiality")
+	if err := loadError(errno); errors.As(err, &enforced) {
+		t.Errorf("loadError(EPERM) without Secure Boot or lockdown = %v, want no ErrEnforced", err)
+	}
+}
@@ -197,6 +197,22 @@ func (rs Ranges) FindSpaceIn(sz uint, limit Range) (space Range, err error) {
 	return Range{}, ErrNotEnoughSpace{Size: sz}
 }
 
+// FindSpaceAlignedIn is FindSpaceIn, but returns a space.Start that is a
+// multiple of alignSize, which must be a power of 2.
+func (rs Ranges) FindSpaceAlignedIn(sz, alignSize uint, limit Range) (space Range, err error) {
+	for _, r := range rs {
+		overlap := r.Intersect(limit)
+		if overlap == nil {
+			continue
+		}
+		start := uintptr(align.Up(uint(overlap.Start), alignSize))
+		if start >= overlap.Start && start < overlap.End() && uint(overlap.End()-start) >= sz {
+			return Range{Start: start, Size: sz}, nil
+		}
+	}
+	return Range{}, ErrNotEnoughSpace{Size: sz}
+}
+
 // Sort sorts ranges by their start point.
 func (rs Ranges) Sort() {
 	sort.Slice(rs, func(i, j int) bool {
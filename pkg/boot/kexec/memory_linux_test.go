@@ -578,6 +578,67 @@ func TestFindSpaceIn(t *testing.T) {
 	}
 }
 
+func TestFindSpaceAlignedIn(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		rs    Ranges
+		size  uint
+		align uint
+		limit Range
+		want  Range
+		err   error
+	}{
+		{
+			name: "aligned up in first range",
+			rs: Ranges{
+				Range{Start: 0x100000, Size: 0x800000},
+			},
+			size:  0x200000,
+			align: 0x200000,
+			limit: RangeFromInterval(0, MaxAddr),
+			want:  Range{Start: 0x200000, Size: 0x200000},
+		},
+		{
+			name: "too small once aligned",
+			rs: Ranges{
+				Range{Start: 0x100000, Size: 0x200000},
+				Range{Start: 0x1000000, Size: 0x200000},
+			},
+			size:  0x200000,
+			align: 0x200000,
+			limit: RangeFromInterval(0, MaxAddr),
+			want:  Range{Start: 0x1000000, Size: 0x200000},
+		},
+		{
+			name: "above limit",
+			rs: Ranges{
+				Range{Start: 0, Size: 0x10000000},
+			},
+			size:  0x1000,
+			align: 0x200000,
+			limit: RangeFromInterval(0x1000001, MaxAddr),
+			want:  Range{Start: 0x1200000, Size: 0x1000},
+		},
+		{
+			name: "not below limit",
+			rs: Ranges{
+				Range{Start: 0x100000, Size: 0x10000000},
+			},
+			size:  0x200000,
+			align: 0x200000,
+			limit: RangeFromInterval(0, 0x300000),
+			err:   ErrNotEnoughSpace{Size: 0x200000},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rs.FindSpaceAlignedIn(tt.size, tt.align, tt.limit)
+			if got != tt.want || err != tt.err {
+				t.Errorf("%s.FindSpaceAlignedIn(%#x, %#x, limit = %s) = (%s, %v), want (%s, %v)", tt.rs, tt.size, tt.align, tt.limit, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
 func TestFindSpace(t *testing.T) {
 	for i, tt := range []struct {
 		name string
@@ -14,6 +14,7 @@ import (
 
 	"github.com/u-root/u-root/pkg/boot/kexec"
 	"github.com/u-root/u-root/pkg/boot/linux"
+	"github.com/u-root/u-root/pkg/boot/timing"
 	"github.com/u-root/u-root/pkg/boot/util"
 	"github.com/u-root/u-root/pkg/mount"
 	"github.com/u-root/u-root/pkg/uio"
@@ -187,8 +188,12 @@ func loadLinuxImage(li *LinuxImage, verbose bool) (*LoadedLinuxImage, func(), er
 		return nil, nil, errNilKernel
 	}
 
+	// Kernels and initrds of netboot images are downloaded as they are
+	// copied.
+	downloaded := timing.Begin(timing.Download)
 	k, err := copyToFileIfNotRegular(util.TryGzipFilter(li.Kernel), verbose)
 	if err != nil {
+		downloaded(err)
 		return nil, nil, err
 	}
 
@@ -205,9 +210,11 @@ func loadLinuxImage(li *LinuxImage, verbose bool) (*LoadedLinuxImage, func(), er
 	if li.Initrd != nil {
 		i, err = copyToFileIfNotRegular(li.Initrd, verbose)
 		if err != nil {
+			downloaded(err)
 			return nil, nil, err
 		}
 	}
+	downloaded(nil)
 
 	if verbose {
 		log.Printf("Kernel: %s", k.Name())
@@ -246,8 +253,12 @@ func (li *LinuxImage) Load(verbose bool) error {
 	}
 	defer cleanup()
 
+	loaded := timing.Begin(timing.Kexec)
 	if li.LoadSyscall {
-		return linux.KexecLoad(loadedImage.Kernel, loadedImage.Initrd, loadedImage.Cmdline, loadedImage.KexecOpts)
+		err = linux.KexecLoad(loadedImage.Kernel, loadedImage.Initrd, loadedImage.Cmdline, loadedImage.KexecOpts)
+	} else {
+		err = kexec.FileLoad(loadedImage.Kernel, loadedImage.Initrd, loadedImage.Cmdline)
 	}
-	return kexec.FileLoad(loadedImage.Kernel, loadedImage.Initrd, loadedImage.Cmdline)
+	loaded(err)
+	return err
 }
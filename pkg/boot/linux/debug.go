@@ -4,10 +4,12 @@
 
 package linux
 
+import "github.com/u-root/u-root/pkg/ulog"
+
 var (
 	// Debug is called to print out verbose debug info.
 	//
 	// Set this to appropriate output stream for display
-	// of useful debug info.
-	Debug = func(string, ...interface{}) {}
+	// of useful debug info. By default, it traces with -trace=boot.
+	Debug = ulog.Tracer("boot").Printf
 )
@@ -0,0 +1,210 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package linux
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/acpi"
+	"github.com/u-root/u-root/pkg/boot/bzimage"
+	"github.com/u-root/u-root/pkg/boot/kexec"
+)
+
+// efiDir is where the running kernel describes EFI. Tests change it.
+var efiDir = "/sys/firmware/efi"
+
+// setupDataEFI is the type of the setup_data that holds an efiSetupData.
+const setupDataEFI = 4
+
+// setupDataHeader is struct setup_data, without the data.
+type setupDataHeader struct {
+	Next uint64
+	Type uint32
+	Len  uint32
+}
+
+// efiSetupData is struct efi_setup_data, which tells a kexec'd kernel where
+// the firmware's tables are, since the system table was converted to
+// virtual addresses by the first kernel.
+type efiSetupData struct {
+	FwVendor uint64
+	Runtime  uint64
+	Tables   uint64
+	SMBIOS   uint64
+	_        [8]uint64
+}
+
+// efiMemoryDesc is efi_memory_desc_t.
+type efiMemoryDesc struct {
+	Type      uint32
+	_         uint32
+	PhysAddr  uint64
+	VirtAddr  uint64
+	NumPages  uint64
+	Attribute uint64
+}
+
+// readEFIValue reads a number from a file in efiDir.
+func readEFIValue(name ...string) (uint64, error) {
+	b, err := os.ReadFile(filepath.Join(append([]string{efiDir}, name...)...))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 0, 64)
+}
+
+// readSystab returns the value of key in the systab of efiDir, e.g. SMBIOS,
+// or 0 if it has none.
+func readSystab(key string) (uint64, error) {
+	f, err := os.Open(filepath.Join(efiDir, "systab"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if strings.HasPrefix(s.Text(), key+"=") {
+			return strconv.ParseUint(strings.TrimPrefix(s.Text(), key+"="), 0, 64)
+		}
+	}
+	return 0, s.Err()
+}
+
+// readRuntimeMap reads the EFI memory map of the runtime services, which the
+// running kernel mapped at the virtual addresses the next kernel must use
+// too, from the runtime-map of efiDir.
+func readRuntimeMap() ([]efiMemoryDesc, error) {
+	entries, err := os.ReadDir(filepath.Join(efiDir, "runtime-map"))
+	if err != nil {
+		return nil, err
+	}
+	var idx []int
+	for _, e := range entries {
+		if i, err := strconv.Atoi(e.Name()); err == nil {
+			idx = append(idx, i)
+		}
+	}
+	sort.Ints(idx)
+
+	descs := make([]efiMemoryDesc, 0, len(idx))
+	for _, i := range idx {
+		var (
+			d   efiMemoryDesc
+			typ uint64
+		)
+		dir := filepath.Join("runtime-map", strconv.Itoa(i))
+		for _, f := range []struct {
+			name string
+			v    *uint64
+		}{
+			{"type", &typ},
+			{"phys_addr", &d.PhysAddr},
+			{"virt_addr", &d.VirtAddr},
+			{"num_pages", &d.NumPages},
+			{"attribute", &d.Attribute},
+		} {
+			if *f.v, err = readEFIValue(dir, f.name); err != nil {
+				return nil, fmt.Errorf("reading EFI runtime map entry %d: %w", i, err)
+			}
+		}
+		d.Type = uint32(typ)
+		descs = append(descs, d)
+	}
+	if len(descs) == 0 {
+		return nil, fmt.Errorf("EFI runtime map is empty")
+	}
+	return descs, nil
+}
+
+// setupEFI passes EFI to the next kernel, as the running kernel has it: the
+// system table, the memory map of the runtime services and where the
+// firmware's tables are. Without them, a kexec'd kernel cannot call EFI
+// runtime services, since the first kernel already mapped them.
+//
+// If the running kernel was not booted by EFI, or does not describe its
+// runtime map, the next kernel boots without EFI.
+func setupEFI(kmem *kexec.Memory, lp *bzimage.LinuxParams) error {
+	e := lp.EFIInfo()
+	if e.LoaderSignature != bzimage.EFI64LoaderSignature || e.MemmapSize == 0 {
+		Debug("Running kernel was not booted by 64-bit EFI, booting without EFI")
+		lp.SetEFIInfo(bzimage.EFIInfo{})
+		return nil
+	}
+	descs, err := readRuntimeMap()
+	if err != nil {
+		Debug("No EFI runtime map, booting without EFI: %v", err)
+		lp.SetEFIInfo(bzimage.EFIInfo{})
+		return nil
+	}
+
+	var sd efiSetupData
+	for _, f := range []struct {
+		name string
+		v    *uint64
+	}{
+		{"fw_vendor", &sd.FwVendor},
+		{"runtime", &sd.Runtime},
+		{"config_table", &sd.Tables},
+	} {
+		if *f.v, err = readEFIValue(f.name); err != nil {
+			return fmt.Errorf("reading EFI %s: %w", f.name, err)
+		}
+	}
+	if sd.SMBIOS, err = readSystab("SMBIOS"); err != nil {
+		return fmt.Errorf("reading EFI systab: %w", err)
+	}
+
+	var memmap bytes.Buffer
+	if err := binary.Write(&memmap, binary.LittleEndian, descs); err != nil {
+		return err
+	}
+	r, err := kmem.AddKexecSegment(memmap.Bytes())
+	if err != nil {
+		return fmt.Errorf("add EFI memory map segment: %w", err)
+	}
+	Debug("Added %d entry EFI runtime map at %s", len(descs), r)
+	e.MemdescSize = uint32(binary.Size(efiMemoryDesc{}))
+	e.Memmap, e.MemmapHi = uint32(r.Start), uint32(uint64(r.Start)>>32)
+	e.MemmapSize = uint32(memmap.Len())
+	lp.SetEFIInfo(e)
+
+	var data bytes.Buffer
+	h := setupDataHeader{Type: setupDataEFI, Len: uint32(binary.Size(sd))}
+	if err := binary.Write(&data, binary.LittleEndian, h); err != nil {
+		return err
+	}
+	if err := binary.Write(&data, binary.LittleEndian, sd); err != nil {
+		return err
+	}
+	if r, err = kmem.AddKexecSegment(data.Bytes()); err != nil {
+		return fmt.Errorf("add EFI setup data segment: %w", err)
+	}
+	Debug("Added EFI setup data at %s", r)
+	lp.SetupData = uint64(r.Start)
+	return nil
+}
+
+// setupACPI passes the address of the ACPI RSDP to the next kernel, which
+// otherwise finds it in the EFI system table, or by searching the BIOS
+// areas, neither of which works when booting without EFI on EFI firmware.
+func setupACPI(lp *bzimage.LinuxParams) {
+	if lp.ACPIRSDPAddr() != 0 {
+		return
+	}
+	rsdp, err := acpi.GetRSDP()
+	if err != nil {
+		Debug("No ACPI RSDP to pass on: %v", err)
+		return
+	}
+	lp.SetACPIRSDPAddr(uint64(rsdp.RSDPAddr()))
+}
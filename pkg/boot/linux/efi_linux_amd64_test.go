@@ -0,0 +1,140 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package linux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/boot/bzimage"
+	"github.com/u-root/u-root/pkg/boot/kexec"
+)
+
+// writeEFIDir writes a fake /sys/firmware/efi with the runtime map descs.
+func writeEFIDir(t *testing.T, descs []efiMemoryDesc) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"fw_vendor":    "0x7f6e5000\n",
+		"runtime":      "0x7f6e4000\n",
+		"config_table": "0x7f6e3000\n",
+		"systab":       "ACPI20=0x7fbfa014\nACPI=0x7fbfa000\nSMBIOS=0x7f9e0000\nSMBIOS3=0x7f9df000\n",
+	}
+	for i, d := range descs {
+		entry := filepath.Join("runtime-map", fmt.Sprint(i))
+		files[filepath.Join(entry, "type")] = fmt.Sprintf("0x%x\n", d.Type)
+		files[filepath.Join(entry, "phys_addr")] = fmt.Sprintf("0x%x\n", d.PhysAddr)
+		files[filepath.Join(entry, "virt_addr")] = fmt.Sprintf("0x%x\n", d.VirtAddr)
+		files[filepath.Join(entry, "num_pages")] = fmt.Sprintf("0x%x\n", d.NumPages)
+		files[filepath.Join(entry, "attribute")] = fmt.Sprintf("0x%x\n", d.Attribute)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func newTestMemory() *kexec.Memory {
+	return &kexec.Memory{
+		Phys: kexec.MemoryMap{
+			{Range: kexec.Range{Start: 0x100000, Size: 0x1000000}, Type: kexec.RangeRAM},
+		},
+	}
+}
+
+func TestSetupEFI(t *testing.T) {
+	descs := []efiMemoryDesc{
+		{Type: 5, PhysAddr: 0x7f000000, VirtAddr: 0xfffffffeff000000, NumPages: 0x10, Attribute: 0x800000000000000f},
+		{Type: 6, PhysAddr: 0x7f010000, VirtAddr: 0xfffffffeff010000, NumPages: 0x20, Attribute: 0x800000000000000f},
+	}
+	defer func(dir string) { efiDir = dir }(efiDir)
+	efiDir = writeEFIDir(t, descs)
+
+	booted := bzimage.EFIInfo{
+		LoaderSignature: bzimage.EFI64LoaderSignature,
+		Systab:          0x7f6e2018,
+		MemdescSize:     48,
+		MemdescVersion:  1,
+		Memmap:          0x6e000000,
+		MemmapSize:      48 * 100,
+	}
+
+	lp := &bzimage.LinuxParams{SetupData: 0x12345000}
+	lp.SetEFIInfo(booted)
+	kmem := newTestMemory()
+	if err := setupEFI(kmem, lp); err != nil {
+		t.Fatalf("setupEFI = %v", err)
+	}
+
+	e := lp.EFIInfo()
+	memmap := uint64(e.Memmap) | uint64(e.MemmapHi)<<32
+	if e.LoaderSignature != booted.LoaderSignature || e.Systab != booted.Systab || e.MemdescVersion != 1 ||
+		e.MemdescSize != 40 || e.MemmapSize != 80 || memmap == uint64(booted.Memmap) {
+		t.Errorf("EFIInfo = %+v, want the system table of %+v and 2 40 byte descriptors", e, booted)
+	}
+	var gotDescs [2]efiMemoryDesc
+	b := kmem.Segments.GetPhys(kexec.Range{Start: uintptr(memmap), Size: uint(e.MemmapSize)})
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &gotDescs); err != nil || gotDescs[0] != descs[0] || gotDescs[1] != descs[1] {
+		t.Errorf("EFI memory map = %+v, %v, want %+v", gotDescs, err, descs)
+	}
+
+	var (
+		h  setupDataHeader
+		sd efiSetupData
+	)
+	b = kmem.Segments.GetPhys(kexec.Range{Start: uintptr(lp.SetupData), Size: 16 + 96})
+	r := bytes.NewReader(b)
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil || h != (setupDataHeader{Type: setupDataEFI, Len: 96}) {
+		t.Errorf("setup data header = %+v, %v, want an EFI one", h, err)
+	}
+	want := efiSetupData{FwVendor: 0x7f6e5000, Runtime: 0x7f6e4000, Tables: 0x7f6e3000, SMBIOS: 0x7f9e0000}
+	if err := binary.Read(r, binary.LittleEndian, &sd); err != nil || sd != want {
+		t.Errorf("EFI setup data = %+v, %v, want %+v", sd, err, want)
+	}
+}
+
+func TestSetupEFIWithout(t *testing.T) {
+	defer func(dir string) { efiDir = dir }(efiDir)
+	efiDir = writeEFIDir(t, nil)
+
+	for _, tt := range []struct {
+		desc string
+		efi  bzimage.EFIInfo
+	}{
+		{desc: "BIOS"},
+		{desc: "32-bit EFI", efi: bzimage.EFIInfo{LoaderSignature: [4]uint8{'E', 'L', '3', '2'}, MemmapSize: 48}},
+		{desc: "no runtime map", efi: bzimage.EFIInfo{LoaderSignature: bzimage.EFI64LoaderSignature, MemmapSize: 48}},
+	} {
+		lp := &bzimage.LinuxParams{}
+		lp.SetEFIInfo(tt.efi)
+		kmem := newTestMemory()
+		if err := setupEFI(kmem, lp); err != nil {
+			t.Errorf("%s: setupEFI = %v", tt.desc, err)
+		}
+		if lp.EFIInfo() != (bzimage.EFIInfo{}) || lp.SetupData != 0 || len(kmem.Segments) != 0 {
+			t.Errorf("%s: EFIInfo = %+v, setup data %#x, want none", tt.desc, lp.EFIInfo(), lp.SetupData)
+		}
+	}
+}
+
+func TestSetupACPI(t *testing.T) {
+	lp := &bzimage.LinuxParams{}
+	lp.SetACPIRSDPAddr(0xf0000)
+	// An RSDP the running kernel was given is passed on.
+	setupACPI(lp)
+	if got := lp.ACPIRSDPAddr(); got != 0xf0000 {
+		t.Errorf("ACPIRSDPAddr = %#x, want 0xf0000", got)
+	}
+}
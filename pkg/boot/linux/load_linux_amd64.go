@@ -5,7 +5,7 @@
 package linux
 
 import (
-	"bytes"
+	"debug/elf"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -19,6 +19,8 @@ import (
 
 const (
 	bootParams = "/sys/kernel/boot_params/data"
+
+	above4G = 1 << 32
 )
 
 // KexecLoad loads a bzImage-formated Linux kernel file as the to-be-kexeced
@@ -68,9 +70,6 @@ func KexecLoad(kernel, ramfs *os.File, cmdline string, opts KexecOptions) error
 	if err != nil {
 		return fmt.Errorf("getting ELF from bzImage: %w", err)
 	}
-	kernelEntry := uintptr(kelf.Entry)
-	Debug("kernelEntry: %v", kernelEntry)
-
 	// Prepare segments.
 	kmem = &kexec.Memory{}
 	Debug("Try parsing memory map...")
@@ -91,22 +90,34 @@ func KexecLoad(kernel, ramfs *os.File, cmdline string, opts KexecOptions) error
 	if !relocatableKernel {
 		return errors.New("non-relocateable Kernels are not supported")
 	}
-	if _, err := kmem.LoadElfSegments(bytes.NewReader(bzimg.KernelCode)); err != nil {
-		return fmt.Errorf("loading kernel ELF segments: %w", err)
+	kernelEntry, err := loadKernel(kmem, &bzimg.Header, kelf)
+	if err != nil {
+		return err
 	}
+	Debug("kernelEntry: %v", kernelEntry)
 
-	var ramfsRange kexec.Range
 	if ramfs != nil {
-		ramfsContents, err := ioutil.ReadAll(ramfs)
-		if err != nil {
+		var ramfsContents []byte
+		if opts.MmapRamfs {
+			Debug("Mmap ramfs file to virtual buffer...")
+			var cleanup func() error
+			if ramfsContents, cleanup, err = mmap(ramfs); err != nil {
+				return fmt.Errorf("mmap ramfs: %w", err)
+			}
+			defer func() {
+				if err := cleanup(); err != nil {
+					Debug("Ummap ramfs failed: %v", err)
+				}
+			}()
+		} else if ramfsContents, err = ioutil.ReadAll(ramfs); err != nil {
 			return fmt.Errorf("unable to read initramfs: %w", err)
 		}
-		if ramfsRange, err = kmem.AddKexecSegment(ramfsContents); err != nil {
-			return fmt.Errorf("add initramfs segment: %w", err)
+		ramfsRange, err := loadInitrd(kmem, &bzimg.Header, ramfsContents)
+		if err != nil {
+			return err
 		}
 		Debug("Added %d byte initramfs at %s", len(ramfsContents), ramfsRange)
-		lp.Initrdstart = uint32(ramfsRange.Start)
-		lp.Initrdsize = uint32(ramfsRange.Size)
+		lp.SetInitrd(uint64(ramfsRange.Start), uint64(len(ramfsContents)))
 	}
 
 	Debug("Kernel cmdline to append: %s", cmdline)
@@ -120,9 +131,18 @@ func KexecLoad(kernel, ramfs *os.File, cmdline string, opts KexecOptions) error
 			return fmt.Errorf("add cmdline segment: %v", err)
 		}
 		Debug("Added %d byte of cmdline at %s", len(cmdlineBytes), cmdlineRange)
-		lp.CLPtr = uint32(cmdlineRange.Start)      // 2.02+
-		lp.CmdLineSize = uint32(cmdlineRange.Size) // 2.06+
+		lp.SetCmdLinePtr(uint64(cmdlineRange.Start)) // 2.02+
+		lp.CmdLineSize = uint32(cmdlineRange.Size)   // 2.06+
+	}
+
+	// The setup_data of the running kernel is of no use to the next one.
+	lp.SetupData = 0
+	if opts.NoEFI {
+		lp.SetEFIInfo(bzimage.EFIInfo{})
+	} else if err := setupEFI(kmem, lp); err != nil {
+		return err
 	}
+	setupACPI(lp)
 
 	// The kernel is a bzImage kernel if the protocol >= 2.00 and the 0x01
 	// bit (LOAD_HIGH) in the loadflags field is set.
@@ -178,3 +198,101 @@ func KexecLoad(kernel, ramfs *os.File, cmdline string, opts KexecOptions) error
 	}
 	return nil
 }
+
+// loadKernel loads the ELF segments of the kernel of hdr at their physical
+// addresses or, if those are not free RAM, as low above them as they fit,
+// and returns the entry point of the kernel.
+func loadKernel(kmem *kexec.Memory, hdr *bzimage.LinuxHeader, kelf *elf.File) (uintptr, error) {
+	var (
+		progs      []*elf.Prog
+		start, end uint64
+	)
+	for _, p := range kelf.Progs {
+		if p.Type != elf.PT_LOAD {
+			continue
+		}
+		if len(progs) == 0 || p.Paddr < start {
+			start = p.Paddr
+		}
+		if e := p.Paddr + p.Memsz; e > end {
+			end = e
+		}
+		progs = append(progs, p)
+	}
+	if len(progs) == 0 {
+		return 0, errors.New("kernel ELF has no loadable segments")
+	}
+	kernel := kexec.RangeFromInterval(uintptr(start), uintptr(end))
+	to, err := placeKernel(kmem.AvailableRAM(), hdr, kernel)
+	if err != nil {
+		return 0, err
+	}
+	delta := to.Start - kernel.Start
+	if delta != 0 {
+		Debug("Relocating kernel from %s to %s", kernel, to)
+	}
+
+	for _, p := range progs {
+		var d []byte
+		// The rest of the segment, and all of it if Filesz is 0, is
+		// zeroed by kexec_load.
+		if p.Filesz != 0 {
+			d = make([]byte, p.Filesz)
+			if _, err := p.ReadAt(d, 0); err != nil {
+				return 0, fmt.Errorf("reading kernel ELF segment: %w", err)
+			}
+		}
+		kmem.Segments.Insert(kexec.NewSegment(d, kexec.Range{
+			Start: uintptr(p.Paddr) + delta,
+			Size:  uint(p.Memsz),
+		}))
+	}
+	return uintptr(kelf.Entry) + delta, nil
+}
+
+// placeKernel returns where in ram the kernel of hdr, which is linked to
+// run at kernel, is loaded: there if it is free, or else as low above it as
+// it fits, aligned as the kernel requires, and below 4G unless the kernel
+// can run above it.
+func placeKernel(ram kexec.Ranges, hdr *bzimage.LinuxHeader, kernel kexec.Range) (kexec.Range, error) {
+	for _, r := range ram {
+		if r.IsSupersetOf(kernel) {
+			return kernel, nil
+		}
+	}
+
+	alignment := uint(hdr.Kernelalignment)
+	if alignment == 0 || alignment&(alignment-1) != 0 {
+		return kexec.Range{}, fmt.Errorf("kernel at %s is not free RAM, and it cannot be relocated to an alignment of %#x", kernel, alignment)
+	}
+	limit := kexec.RangeFromInterval(kernel.Start, above4G)
+	if hdr.Protocolversion >= 0x020c && hdr.XLoadFlags&bzimage.XLFKernel64 != 0 {
+		limit = kexec.RangeFromInterval(kernel.Start, kexec.MaxAddr)
+	}
+	r, err := ram.FindSpaceAlignedIn(kernel.Size, alignment, limit)
+	if err != nil {
+		return kexec.Range{}, fmt.Errorf("kernel at %s is not free RAM, and there are no %#x bytes aligned to %#x in %s to relocate it to: %w", kernel, kernel.Size, alignment, limit, err)
+	}
+	return r, nil
+}
+
+// loadInitrd adds initrd to kmem, as low as it fits where the kernel of
+// hdr can address it: below its InitrdAddrMax, or anywhere if it can be
+// loaded above 4G.
+func loadInitrd(kmem *kexec.Memory, hdr *bzimage.LinuxHeader, initrd []byte) (kexec.Range, error) {
+	max := uintptr(bzimage.DefaultInitrdAddrMax)
+	if hdr.Protocolversion >= 0x0203 {
+		max = uintptr(hdr.InitrdAddrMax)
+	}
+	limit := kexec.RangeFromInterval(kexec.M1, max+1)
+	if hdr.Protocolversion >= 0x020c && hdr.XLoadFlags&bzimage.XLFCanBeLoadedAbove4G != 0 {
+		limit = kexec.RangeFromInterval(kexec.M1, kexec.MaxAddr)
+	} else if uint64(len(initrd)) > uint64(limit.Size) {
+		return kexec.Range{}, fmt.Errorf("initrd of %#x bytes does not fit below %#x, the highest address the kernel can load it at", len(initrd), max)
+	}
+	r, err := kmem.AddPhysSegment(initrd, limit)
+	if err != nil {
+		return kexec.Range{}, fmt.Errorf("no %#x bytes of RAM for the initrd in %s: %w", len(initrd), limit, err)
+	}
+	return r, nil
+}
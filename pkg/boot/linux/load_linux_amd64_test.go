@@ -0,0 +1,122 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package linux
+
+import (
+	"testing"
+
+	"github.com/u-root/u-root/pkg/boot/bzimage"
+	"github.com/u-root/u-root/pkg/boot/kexec"
+)
+
+func TestPlaceKernel(t *testing.T) {
+	kernel := kexec.Range{Start: 0x1000000, Size: 0x2000000}
+	hdr := bzimage.LinuxHeader{Protocolversion: 0x20f, Kernelalignment: 0x200000}
+	hdr64 := hdr
+	hdr64.XLoadFlags = bzimage.XLFKernel64
+
+	for _, tt := range []struct {
+		desc    string
+		ram     kexec.Ranges
+		hdr     bzimage.LinuxHeader
+		want    kexec.Range
+		wantErr bool
+	}{
+		{
+			desc: "at its address",
+			ram:  kexec.Ranges{{Start: 0x100000, Size: 0x7ff00000}},
+			hdr:  hdr,
+			want: kernel,
+		},
+		{
+			desc: "relocated above a hole",
+			ram:  kexec.Ranges{{Start: 0x100000, Size: 0x1f00000}, {Start: 0x2100000, Size: 0x7df00000}},
+			hdr:  hdr,
+			want: kexec.Range{Start: 0x2200000, Size: 0x2000000},
+		},
+		{
+			desc: "relocated above 4G",
+			ram:  kexec.Ranges{{Start: 0x100000, Size: 0x1f00000}, {Start: 0x100000000, Size: 0x80000000}},
+			hdr:  hdr64,
+			want: kexec.Range{Start: 0x100000000, Size: 0x2000000},
+		},
+		{
+			desc:    "not above 4G",
+			ram:     kexec.Ranges{{Start: 0x100000, Size: 0x1f00000}, {Start: 0x100000000, Size: 0x80000000}},
+			hdr:     hdr,
+			wantErr: true,
+		},
+		{
+			desc:    "not below its address",
+			ram:     kexec.Ranges{{Start: 0x100000, Size: 0xf00000}, {Start: 0x1100000, Size: 0x1000000}},
+			hdr:     hdr64,
+			wantErr: true,
+		},
+		{
+			desc:    "no alignment",
+			ram:     kexec.Ranges{{Start: 0x2100000, Size: 0x7df00000}},
+			hdr:     bzimage.LinuxHeader{Protocolversion: 0x20f},
+			wantErr: true,
+		},
+	} {
+		got, err := placeKernel(tt.ram, &tt.hdr, kernel)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: placeKernel = %s, %v, want %s, error %v", tt.desc, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadInitrd(t *testing.T) {
+	hdr := bzimage.LinuxHeader{Protocolversion: 0x20f, InitrdAddrMax: 0x7fffffff}
+	hdrHigh := hdr
+	hdrHigh.XLoadFlags = bzimage.XLFCanBeLoadedAbove4G
+	ram := kexec.MemoryMap{
+		{Range: kexec.Range{Start: 0x100000, Size: 0x100000}, Type: kexec.RangeRAM},
+		{Range: kexec.Range{Start: 0x100000000, Size: 0x1000000}, Type: kexec.RangeRAM},
+	}
+	initrd := make([]byte, 0x200000)
+
+	for _, tt := range []struct {
+		desc    string
+		hdr     bzimage.LinuxHeader
+		initrd  []byte
+		want    kexec.Range
+		wantErr bool
+	}{
+		{
+			desc:   "below 4G",
+			hdr:    hdr,
+			initrd: initrd[:0x1000],
+			want:   kexec.Range{Start: 0x100000, Size: 0x1000},
+		},
+		{
+			desc:   "above 4G",
+			hdr:    hdrHigh,
+			initrd: initrd,
+			want:   kexec.Range{Start: 0x100000000, Size: 0x200000},
+		},
+		{
+			desc:    "not above 4G",
+			hdr:     hdr,
+			initrd:  initrd,
+			wantErr: true,
+		},
+		{
+			desc:    "larger than InitrdAddrMax",
+			hdr:     bzimage.LinuxHeader{Protocolversion: 0x20f, InitrdAddrMax: 0x1fffff},
+			initrd:  initrd,
+			wantErr: true,
+		},
+	} {
+		kmem := &kexec.Memory{Phys: append(kexec.MemoryMap{}, ram...)}
+		got, err := loadInitrd(kmem, &tt.hdr, tt.initrd)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: loadInitrd = %s, %v, want %s, error %v", tt.desc, got, err, tt.want, tt.wantErr)
+		}
+		if err == nil && len(kmem.Segments) != 1 {
+			t.Errorf("%s: loadInitrd added %d segments, want 1", tt.desc, len(kmem.Segments))
+		}
+	}
+}
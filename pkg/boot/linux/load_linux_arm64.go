@@ -9,39 +9,17 @@ import (
 	"encoding/binary"
 	"fmt"
 	"os"
-	"syscall"
 
 	"github.com/u-root/u-root/pkg/boot/image"
 	"github.com/u-root/u-root/pkg/boot/kexec"
 	"github.com/u-root/u-root/pkg/dt"
 	"github.com/u-root/u-root/pkg/uio"
-	"golang.org/x/sys/unix"
 )
 
 const (
 	kernelAlignSize = 1 << 21 // 2 MB.
 )
 
-func mmap(f *os.File) (data []byte, ummap func() error, err error) {
-	s, err := f.Stat()
-	if err != nil {
-		return nil, nil, fmt.Errorf("stat error: %w", err)
-	}
-	if s.Size() == 0 {
-		return nil, nil, fmt.Errorf("cannot mmap zero-len file")
-	}
-	d, err := unix.Mmap(int(f.Fd()), 0, int(s.Size()), syscall.PROT_READ, syscall.MAP_PRIVATE)
-	if err != nil {
-		return nil, nil, fmt.Errorf("mmap failed: %w", err)
-	}
-
-	ummap = func() error {
-		return unix.Munmap(d)
-	}
-
-	return d, ummap, nil
-}
-
 // sanitizeFDT cleanups boot param properties from chosen node of the given FDT.
 func sanitizeFDT(fdt *dt.FDT) (*dt.Node, error) {
 	// Clear old entries in case we've already been through kexec to get
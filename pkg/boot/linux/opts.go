@@ -30,4 +30,9 @@ type KexecOptions struct {
 	MmapKernel bool
 	// MmapRamfs indicates if mmap initramfs into virtual memory.
 	MmapRamfs bool
+
+	// NoEFI boots the kernel without EFI, e.g. if it fails to use the EFI
+	// runtime services the running kernel passes on. Only kexec_load on
+	// x86 honors it.
+	NoEFI bool
 }
@@ -221,7 +221,7 @@ func LazyOpenModules(cmds []string) Modules {
 		name := strings.Fields(cmd)[0]
 		modules = append(modules, Module{
 			Cmdline: cmd,
-			Module:  uio.NewLazyFile(name),
+			Module:  uio.NewLazyMmapFile(name),
 		})
 	}
 	return modules
@@ -0,0 +1,240 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pxe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/u-root/u-root/pkg/boot"
+	"github.com/u-root/u-root/pkg/boot/syslinux"
+	"github.com/u-root/u-root/pkg/curl"
+)
+
+// MenuData is what menu templates are executed with.
+type MenuData struct {
+	// MAC is the MAC address of the client, as aa:bb:cc:dd:ee:ff, if it is
+	// known.
+	MAC string
+	// IP is the IP address of the client.
+	IP string
+	// Server is the host, and perhaps port, of the server as the client
+	// reaches it.
+	Server string
+}
+
+// Menus generates pxelinux configs for clients from templates.
+//
+// Dir has a text/template for each config, named like the files pxelinux
+// looks for in pxelinux.cfg: 01-aa-bb-cc-dd-ee-ff for a MAC address, an IP
+// address in hex or a prefix of it, or default. A client gets the first of
+// them that it would look for, executed with its MenuData.
+type Menus struct {
+	Dir string
+	// Server is the host of the server for MenuData, if the request does
+	// not tell.
+	Server string
+}
+
+// ErrNoMenu is returned if there is no menu for a client.
+var ErrNoMenu = errors.New("no menu for this client")
+
+func (m *Menus) data(mac net.HardwareAddr, ip net.IP, server string) *MenuData {
+	d := &MenuData{Server: server}
+	if d.Server == "" {
+		d.Server = m.Server
+	}
+	if mac != nil {
+		d.MAC = mac.String()
+	}
+	if ip != nil {
+		d.IP = ip.String()
+	}
+	return d
+}
+
+func (m *Menus) execute(name string, d *MenuData) ([]byte, error) {
+	t, err := template.ParseFiles(filepath.Join(m.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, d); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Config returns the pxelinux config for the client with mac and ip, which
+// may be nil, and the name of its template.
+func (m *Menus) Config(mac net.HardwareAddr, ip net.IP, server string) (string, []byte, error) {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, name := range probeFiles(mac, ip) {
+		if mac == nil && strings.HasPrefix(name, "01-") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(m.Dir, name)); err != nil {
+			continue
+		}
+		b, err := m.execute(name, m.data(mac, ip, server))
+		return name, b, err
+	}
+	return "", nil, ErrNoMenu
+}
+
+// menuScheme fetches configs for syslinux.ParseConfigFile by executing
+// the templates of a Menus. Nothing else is fetched before boot.
+type menuScheme struct {
+	m    *Menus
+	data *MenuData
+}
+
+func (s *menuScheme) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	dir, name := path.Split(u.Path)
+	if path.Base(dir) != "pxelinux.cfg" {
+		return nil, os.ErrNotExist
+	}
+	b, err := s.m.execute(name, s.data)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+func (s *menuScheme) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	r, err := s.Fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(r, 0, 1<<62), nil
+}
+
+// Images parses the config of the client with mac and ip the way a
+// netbooting client would, into the images it offers.
+func (m *Menus) Images(ctx context.Context, mac net.HardwareAddr, ip net.IP, server string) ([]boot.OSImage, error) {
+	name, _, err := m.Config(mac, ip, server)
+	if err != nil {
+		return nil, err
+	}
+	d := m.data(mac, ip, server)
+	s := &menuScheme{m: m, data: d}
+	root := &url.URL{Scheme: "http", Host: d.Server}
+	return syslinux.ParseConfigFile(ctx, curl.Schemes{"http": s, "tftp": s, "file": s}, path.Join("pxelinux.cfg", name), root, "")
+}
+
+// WriteIPXE writes an iPXE script that offers images, which must be Linux
+// images, in a menu.
+func WriteIPXE(w io.Writer, images []boot.OSImage) error {
+	var linux []*boot.LinuxImage
+	for _, img := range images {
+		li, ok := img.(*boot.LinuxImage)
+		if !ok {
+			return fmt.Errorf("iPXE menus only support Linux images, not %s", img.Label())
+		}
+		if s, ok := li.Kernel.(fmt.Stringer); !ok || s.String() == "" {
+			return fmt.Errorf("%s: the kernel has no URL", img.Label())
+		}
+		linux = append(linux, li)
+	}
+	if len(linux) == 0 {
+		return ErrNoMenu
+	}
+	var b strings.Builder
+	b.WriteString("#!ipxe\n")
+	if len(linux) > 1 {
+		b.WriteString("menu\n")
+		for i, li := range linux {
+			fmt.Fprintf(&b, "item entry%d %s\n", i, li.Label())
+		}
+		b.WriteString("choose --default entry0 target && goto ${target} || exit\n")
+	}
+	for i, li := range linux {
+		if len(linux) > 1 {
+			fmt.Fprintf(&b, ":entry%d\n", i)
+		}
+		fmt.Fprintf(&b, "kernel %s %s\n", li.Kernel.(fmt.Stringer), li.Cmdline)
+		if s, ok := li.Initrd.(fmt.Stringer); ok && li.Initrd != nil {
+			// Several initrds are joined with commas.
+			for _, initrd := range strings.Split(s.String(), ",") {
+				fmt.Fprintf(&b, "initrd %s\n", initrd)
+			}
+		}
+		b.WriteString("boot\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// parseMAC returns the MAC address that a config name is for, or nil.
+func parseMAC(name string) net.HardwareAddr {
+	if !strings.HasPrefix(name, "01-") {
+		return nil
+	}
+	mac, err := net.ParseMAC(strings.ReplaceAll(name[3:], "-", ":"))
+	if err != nil {
+		return nil
+	}
+	return mac
+}
+
+// ConfigFor returns the config for the client at ip that asked for the
+// config file called name, such as pxelinux.cfg/01-aa-bb-cc-dd-ee-ff.
+func (m *Menus) ConfigFor(name string, ip net.IP, server string) ([]byte, error) {
+	_, b, err := m.Config(parseMAC(path.Base(name)), ip, server)
+	return b, err
+}
+
+// ServeHTTP serves pxelinux configs under /pxelinux.cfg/ and, for iPXE
+// clients, scripts at /ipxe?mac=${net0/mac}&ip=${net0/ip}.
+func (m *Menus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(r.URL.Query().Get("ip"))
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && ip == nil {
+		ip = net.ParseIP(host)
+	}
+	var b []byte
+	var err error
+	switch {
+	case path.Base(path.Dir(r.URL.Path)) == "pxelinux.cfg":
+		b, err = m.ConfigFor(r.URL.Path, ip, r.Host)
+	case path.Base(r.URL.Path) == "ipxe":
+		var mac net.HardwareAddr
+		if s := r.URL.Query().Get("mac"); s != "" {
+			if mac, err = net.ParseMAC(s); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		var images []boot.OSImage
+		if images, err = m.Images(r.Context(), mac, ip, r.Host); err == nil {
+			var buf bytes.Buffer
+			err = WriteIPXE(&buf, images)
+			b = buf.Bytes()
+		}
+	default:
+		err = ErrNoMenu
+	}
+	switch {
+	case errors.Is(err, ErrNoMenu):
+		http.NotFound(w, r)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(b)
+	}
+}
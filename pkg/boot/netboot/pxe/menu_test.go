@@ -0,0 +1,155 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pxe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func menus(t *testing.T) *Menus {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"default": `DEFAULT linux
+LABEL linux
+  KERNEL http://{{.Server}}/vmlinuz
+  INITRD http://{{.Server}}/a.cpio,http://{{.Server}}/b.cpio
+  APPEND console=ttyS0 ip={{.IP}}
+`,
+		"01-aa-bb-cc-dd-ee-ff": `INCLUDE pxelinux.cfg/common
+LABEL rescue
+  MENU LABEL Rescue {{.MAC}}
+  KERNEL /rescue/vmlinuz
+  APPEND rescue
+`,
+		"common": `LABEL install
+  KERNEL tftp://{{.Server}}/install/vmlinuz
+  APPEND mac={{.MAC}}
+`,
+		"C0A800": "LABEL lab\n  KERNEL /lab\n",
+		"broken": "{{.Nothing}",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Menus{Dir: dir, Server: "10.0.0.1"}
+}
+
+var mac = net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+
+func TestMenusConfig(t *testing.T) {
+	m := menus(t)
+	for _, tt := range []struct {
+		mac  net.HardwareAddr
+		ip   net.IP
+		want string
+	}{
+		{mac: mac, ip: net.IPv4(192, 168, 0, 2), want: "01-aa-bb-cc-dd-ee-ff"},
+		{mac: net.HardwareAddr{1, 2, 3, 4, 5, 6}, ip: net.IPv4(192, 168, 0, 2), want: "C0A800"},
+		{ip: net.IPv4(10, 0, 0, 2), want: "default"},
+	} {
+		name, _, err := m.Config(tt.mac, tt.ip, "")
+		if err != nil || name != tt.want {
+			t.Errorf("Config(%s, %s) = %q, %v, want %q", tt.mac, tt.ip, name, err, tt.want)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(m.Dir, "default")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Config(nil, net.IPv4(10, 0, 0, 2), ""); !errors.Is(err, ErrNoMenu) {
+		t.Errorf("Config without default = %v, want %v", err, ErrNoMenu)
+	}
+}
+
+func TestMenusIPXE(t *testing.T) {
+	m := menus(t)
+	for _, tt := range []struct {
+		name string
+		mac  net.HardwareAddr
+		want string
+	}{
+		{
+			name: "one",
+			want: `#!ipxe
+kernel http://10.0.0.1/vmlinuz console=ttyS0 ip=10.0.0.2
+initrd http://10.0.0.1/a.cpio
+initrd http://10.0.0.1/b.cpio
+boot
+`,
+		},
+		{
+			name: "menu",
+			mac:  mac,
+			want: `#!ipxe
+menu
+item entry0 install
+item entry1 Rescue aa:bb:cc:dd:ee:ff
+choose --default entry0 target && goto ${target} || exit
+:entry0
+kernel tftp://10.0.0.1/install/vmlinuz mac=aa:bb:cc:dd:ee:ff
+boot
+:entry1
+kernel http://10.0.0.1/rescue/vmlinuz rescue
+boot
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := m.Images(context.Background(), tt.mac, net.IPv4(10, 0, 0, 2), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := WriteIPXE(&b, images); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("WriteIPXE =\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMenusServeHTTP(t *testing.T) {
+	s := httptest.NewServer(menus(t))
+	defer s.Close()
+	for _, tt := range []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{path: "/pxelinux.cfg/01-01-02-03-04-05-06", status: http.StatusOK, want: "DEFAULT linux\nLABEL linux\n  KERNEL http://" + s.Listener.Addr().String() + "/vmlinuz\n  INITRD http://" + s.Listener.Addr().String() + "/a.cpio,http://" + s.Listener.Addr().String() + "/b.cpio\n  APPEND console=ttyS0 ip=127.0.0.1\n"},
+		{path: "/pxelinux.cfg/01-aa-bb-cc-dd-ee-ff", status: http.StatusOK},
+		{path: "/ipxe?mac=aa:bb:cc:dd:ee:ff&ip=192.168.0.9", status: http.StatusOK},
+		{path: "/ipxe?mac=nope", status: http.StatusBadRequest},
+		{path: "/vmlinuz", status: http.StatusNotFound},
+	} {
+		resp, err := http.Get(s.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s = %s, want %d", tt.path, resp.Status, tt.status)
+		}
+		if tt.want != "" && string(b) != tt.want {
+			t.Errorf("GET %s =\n%s\nwant\n%s", tt.path, b, tt.want)
+		}
+	}
+}
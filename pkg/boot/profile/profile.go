@@ -0,0 +1,147 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package profile selects boot entries and kernel parameters by the SMBIOS
+// identity of the machine, so that one image boots a fleet of different
+// models with the parameters of each.
+//
+// Profiles are read from a JSON rules file, the first rule whose match
+// applies is used:
+//
+//	[
+//	  {
+//	    "match": {"system-product-name": "PowerEdge R6?0", "baseboard-asset-tag": "rack12-*"},
+//	    "label": "Ubuntu*",
+//	    "remove": ["console"],
+//	    "append": "console=ttyS1,115200n8"
+//	  },
+//	  {
+//	    "match": {"system-manufacturer": "QEMU"},
+//	    "append": "console=ttyS0"
+//	  }
+//	]
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/u-root/u-root/pkg/boot"
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/smbios"
+)
+
+// ErrNoImage is returned by Apply if no image matches the label of a rule.
+var ErrNoImage = errors.New("no boot image matches label")
+
+// Rule is a boot profile and the machines it applies to.
+type Rule struct {
+	// Match maps SMBIOS keywords of dmidecode -s, e.g.
+	// system-product-name, to path.Match patterns of their value. A rule
+	// matches a machine if all of its patterns do, a rule without
+	// patterns matches every machine.
+	Match map[string]string `json:"match"`
+
+	// Label is a path.Match pattern of the labels of the images to boot.
+	// The others are not booted.
+	Label string `json:"label,omitempty"`
+
+	// Remove are the kernel parameters removed from the command line.
+	Remove []string `json:"remove,omitempty"`
+
+	// Append is appended to the kernel command line.
+	Append string `json:"append,omitempty"`
+}
+
+// Fields reads the SMBIOS fields of a machine, *smbios.Info is one.
+type Fields interface {
+	Keyword(keyword string) (string, error)
+}
+
+var _ Fields = &smbios.Info{}
+
+// Parse parses a rules file.
+func Parse(data []byte) ([]Rule, error) {
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for i, r := range rules {
+		for k, pattern := range r.Match {
+			if !smbios.IsKeyword(k) {
+				return nil, fmt.Errorf("rule %d: %q: %w", i, k, smbios.ErrUnknownKeyword)
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule %d: %s: %q: %w", i, k, pattern, err)
+			}
+		}
+		if _, err := path.Match(r.Label, ""); err != nil {
+			return nil, fmt.Errorf("rule %d: label %q: %w", i, r.Label, err)
+		}
+	}
+	return rules, nil
+}
+
+// Load reads and parses the rules file at path.
+func Load(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Matches returns whether r applies to the machine. Fields that can not be
+// read do not match.
+func (r *Rule) Matches(f Fields) bool {
+	for k, pattern := range r.Match {
+		v, err := f.Keyword(k)
+		if err != nil {
+			return false
+		}
+		if ok, _ := path.Match(pattern, v); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the first rule that applies to the machine, or nil.
+func Select(rules []Rule, f Fields) *Rule {
+	for i := range rules {
+		if rules[i].Matches(f) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// Apply returns the images r boots, with their command lines edited. If no
+// image matches the label of r, it returns ErrNoImage and changes nothing.
+func (r *Rule) Apply(images []boot.OSImage) ([]boot.OSImage, error) {
+	var selected []boot.OSImage
+	for _, img := range images {
+		if ok, _ := path.Match(r.Label, img.Label()); ok || r.Label == "" {
+			selected = append(selected, img)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("%q: %w", r.Label, ErrNoImage)
+	}
+	boot.EditCmdlines(&cmdline.Policy{Remove: r.Remove, Append: r.Append}, nil, selected...)
+	return selected, nil
+}
+
+// String implements fmt.Stringer.
+func (r *Rule) String() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
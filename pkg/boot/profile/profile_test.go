@@ -0,0 +1,102 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/boot"
+	"github.com/u-root/u-root/pkg/smbios"
+)
+
+type fields map[string]string
+
+func (f fields) Keyword(k string) (string, error) {
+	v, ok := f[k]
+	if !ok {
+		return "", fmt.Errorf("no %s", k)
+	}
+	return v, nil
+}
+
+const rulesFile = `[
+	{"match": {"system-product-name": "PowerEdge R6?0", "baseboard-asset-tag": "rack12-*"}, "label": "Ubuntu*", "remove": ["console"], "append": "console=ttyS1,115200n8"},
+	{"match": {"system-manufacturer": "QEMU"}, "append": "console=ttyS0"},
+	{"match": {}, "label": "rescue"}
+]`
+
+func TestSelect(t *testing.T) {
+	rules, err := Parse([]byte(rulesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		f    fields
+		want int
+	}{
+		{f: fields{"system-product-name": "PowerEdge R640", "baseboard-asset-tag": "rack12-07"}, want: 0},
+		{f: fields{"system-product-name": "PowerEdge R640", "baseboard-asset-tag": "rack13-07"}, want: 2},
+		{f: fields{"system-product-name": "PowerEdge R640", "system-manufacturer": "QEMU"}, want: 1},
+		{f: fields{}, want: 2},
+	} {
+		if got := Select(rules, tt.f); got != &rules[tt.want] {
+			t.Errorf("Select(%v) = %v, want %v", tt.f, got, &rules[tt.want])
+		}
+	}
+	if got := Select(rules[:2], fields{}); got != nil {
+		t.Errorf("Select(no match) = %v, want nil", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		data string
+		err  error
+	}{
+		{data: `[{"match": {"processor-family": "Xeon"}}]`, err: smbios.ErrUnknownKeyword},
+		{data: `[{"match": {"system-uuid": "[a-"}}]`},
+		{data: `[{"label": "["}]`},
+		{data: `{"match": {}}`},
+	} {
+		if _, err := Parse([]byte(tt.data)); err == nil || tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%s) = %v, want error %v", tt.data, err, tt.err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	rules, err := Parse([]byte(rulesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ubuntu := &boot.LinuxImage{Name: "Ubuntu 22.04", Cmdline: "root=/dev/sda1 console=tty0 quiet"}
+	rescue := &boot.LinuxImage{Name: "rescue", Cmdline: "console=tty0"}
+	got, err := rules[0].Apply([]boot.OSImage{ubuntu, rescue})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []boot.OSImage{ubuntu}) {
+		t.Errorf("Apply = %v, want only %v", got, ubuntu)
+	}
+	if want := "root=/dev/sda1 quiet console=ttyS1,115200n8"; ubuntu.Cmdline != want {
+		t.Errorf("Apply made command line %q, want %q", ubuntu.Cmdline, want)
+	}
+	if rescue.Cmdline != "console=tty0" {
+		t.Errorf("Apply changed the command line of an image it does not boot to %q", rescue.Cmdline)
+	}
+
+	if got, err := rules[1].Apply([]boot.OSImage{rescue}); err != nil || len(got) != 1 || rescue.Cmdline != "console=tty0 console=ttyS0" {
+		t.Errorf("Apply without label = %v, %v, command line %q", got, err, rescue.Cmdline)
+	}
+	if _, err := rules[0].Apply([]boot.OSImage{rescue}); !errors.Is(err, ErrNoImage) {
+		t.Errorf("Apply(no match) = %v, want %v", err, ErrNoImage)
+	}
+	if rules[0].Remove[0] != "console" {
+		t.Errorf("Apply changed the rule to %v", rules[0])
+	}
+}
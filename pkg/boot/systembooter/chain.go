@@ -0,0 +1,207 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package systembooter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Duration is a time.Duration that is a string such as "1m30s" in JSON.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %v", err)
+	}
+	t, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(t)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Stage is a way to boot of a Chain: a command, such as fbnetboot, pxeboot
+// or localboot, that does not return if it boots, or a recovery shell.
+type Stage struct {
+	// Name names the stage in logs. It defaults to the command name.
+	Name string `json:"name,omitempty"`
+
+	// Command is the command and its arguments.
+	Command []string `json:"command"`
+
+	// DebugArgs are added to Command if debugging is enabled, e.g. -d.
+	DebugArgs []string `json:"debug_args,omitempty"`
+
+	// Timeout is how long the command may run before it is killed, or 0
+	// for no limit.
+	Timeout Duration `json:"timeout,omitempty"`
+
+	// Attempts is how many times the command is run before moving on to
+	// the next stage. It defaults to 1.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// Chain is a configurable order of ways to boot, e.g. an HTTPS boot API,
+// then PXE, then local disks, then a recovery shell. It is read from a JSON
+// file such as:
+//
+//	{
+//		"rounds": 3,
+//		"interval": "5s",
+//		"stages": [
+//			{"name": "https", "command": ["fbnetboot", "-userclass", "linuxboot"], "debug_args": ["-d"], "timeout": "2m", "attempts": 2},
+//			{"name": "pxe", "command": ["pxeboot"], "timeout": "1m"},
+//			{"name": "local", "command": ["localboot", "-grub"], "debug_args": ["-d"], "timeout": "30s"},
+//			{"name": "recovery", "command": ["gosh"]}
+//		]
+//	}
+type Chain struct {
+	// Stages are tried in order.
+	Stages []Stage `json:"stages"`
+
+	// Rounds is how many times the stages are tried, or 0 for until one
+	// boots.
+	Rounds int `json:"rounds,omitempty"`
+
+	// Interval is how long to wait between rounds.
+	Interval Duration `json:"interval,omitempty"`
+
+	// OnFailure, if set, is called for each stage that returned, with
+	// the error it returned, if any.
+	OnFailure func(s Stage, err error) `json:"-"`
+}
+
+// ErrChainFailed is returned by Chain.Run if no stage booted.
+var ErrChainFailed = errors.New("no stage of the boot chain booted")
+
+// ParseChain parses a Chain from its JSON configuration b.
+func ParseChain(b []byte) (*Chain, error) {
+	var c Chain
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Stages) == 0 {
+		return nil, fmt.Errorf("boot chain has no stages")
+	}
+	if c.Rounds < 0 || c.Interval < 0 {
+		return nil, fmt.Errorf("boot chain has negative rounds or interval")
+	}
+	for i := range c.Stages {
+		s := &c.Stages[i]
+		if len(s.Command) == 0 || s.Command[0] == "" {
+			return nil, fmt.Errorf("stage %d of the boot chain has no command", i)
+		}
+		if s.Name == "" {
+			s.Name = s.Command[0]
+		}
+		if s.Timeout < 0 || s.Attempts < 0 {
+			return nil, fmt.Errorf("stage %s of the boot chain has a negative timeout or attempts", s.Name)
+		}
+		if s.Attempts == 0 {
+			s.Attempts = 1
+		}
+	}
+	return &c, nil
+}
+
+// ReadChainFile reads a Chain from the JSON file path.
+func ReadChainFile(path string) (*Chain, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseChain(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Run tries the stages in order, Rounds times, until one boots, in which
+// case Run does not return. Otherwise, it returns ErrChainFailed, or the
+// error of ctx if it is done.
+func (c *Chain) Run(ctx context.Context, debugEnabled bool) error {
+	for round := 1; c.Rounds == 0 || round <= c.Rounds; round++ {
+		for _, s := range c.Stages {
+			for attempt := 1; attempt <= s.Attempts; attempt++ {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				err := s.run(ctx, debugEnabled, attempt)
+				if c.OnFailure != nil {
+					c.OnFailure(s, err)
+				}
+			}
+		}
+		log.Printf("Boot chain: round %d failed", round)
+		if c.Rounds == 0 || round < c.Rounds {
+			if err := sleep(ctx, time.Duration(c.Interval)); err != nil {
+				return err
+			}
+		}
+	}
+	return ErrChainFailed
+}
+
+// run runs the command of s, and returns its error.
+func (s Stage) run(ctx context.Context, debugEnabled bool, attempt int) error {
+	args := append([]string{}, s.Command...)
+	if debugEnabled {
+		args = append(args, s.DebugArgs...)
+	}
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Timeout))
+		defer cancel()
+		log.Printf("Boot chain: stage %s, attempt %d of %d: running %v, for at most %v", s.Name, attempt, s.Attempts, args, time.Duration(s.Timeout))
+	} else {
+		log.Printf("Boot chain: stage %s, attempt %d of %d: running %v", s.Name, attempt, s.Attempts, args)
+	}
+
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+	took := time.Since(start).Round(time.Millisecond)
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		err = fmt.Errorf("timed out after %v", time.Duration(s.Timeout))
+		log.Printf("Boot chain: stage %s %v", s.Name, err)
+	case err != nil:
+		log.Printf("Boot chain: stage %s failed after %v: %v", s.Name, took, err)
+	default:
+		log.Printf("Boot chain: stage %s returned after %v without booting", s.Name, took)
+	}
+	return err
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package systembooter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseChain(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		config  string
+		want    *Chain
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			config: `{"stages": [{"command": ["pxeboot"]}, {"name": "local", "command": ["localboot", "-grub"], "debug_args": ["-d"], "timeout": "30s", "attempts": 2}], "rounds": 3, "interval": "1m"}`,
+			want: &Chain{
+				Stages: []Stage{
+					{Name: "pxeboot", Command: []string{"pxeboot"}, Attempts: 1},
+					{Name: "local", Command: []string{"localboot", "-grub"}, DebugArgs: []string{"-d"}, Timeout: Duration(30 * time.Second), Attempts: 2},
+				},
+				Rounds:   3,
+				Interval: Duration(time.Minute),
+			},
+		},
+		{name: "no stages", config: `{"stages": []}`, wantErr: "no stages"},
+		{name: "no command", config: `{"stages": [{"name": "pxe"}]}`, wantErr: "no command"},
+		{name: "bad timeout", config: `{"stages": [{"command": ["pxeboot"], "timeout": "soon"}]}`, wantErr: "invalid duration"},
+		{name: "numeric timeout", config: `{"stages": [{"command": ["pxeboot"], "timeout": 30}]}`, wantErr: "must be a string"},
+		{name: "negative attempts", config: `{"stages": [{"command": ["pxeboot"], "attempts": -1}]}`, wantErr: "negative"},
+	} {
+		got, err := ParseChain([]byte(tt.config))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: ParseChain = %v, want an error containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseChain = %+v, %v, want %+v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestChainRun(t *testing.T) {
+	for _, cmd := range []string{"sh", "sleep"} {
+		if _, err := exec.LookPath(cmd); err != nil {
+			t.Skipf("no %s", cmd)
+		}
+	}
+	c, err := ParseChain([]byte(`{
+		"rounds": 2,
+		"stages": [
+			{"name": "flaky", "command": ["sh", "-c", "exit 1"], "attempts": 2},
+			{"name": "slow", "command": ["sleep", "10"], "timeout": "50ms"},
+			{"name": "shell", "command": ["sh", "-c", "exit 0"], "debug_args": ["-d"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	c.OnFailure = func(s Stage, err error) {
+		got = append(got, fmt.Sprintf("%s: %v", s.Name, err))
+	}
+	if err := c.Run(context.Background(), true); !errors.Is(err, ErrChainFailed) {
+		t.Errorf("Run = %v, want ErrChainFailed", err)
+	}
+	round := []string{"flaky: exit status 1", "flaky: exit status 1", "slow: timed out after 50ms", "shell: <nil>"}
+	if want := append(round, round...); !reflect.DeepEqual(got, want) {
+		t.Errorf("stages returned %q, want %q", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Rounds = 0
+	c.OnFailure = func(Stage, error) { cancel() }
+	if err := c.Run(ctx, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run with a canceled context = %v, want context.Canceled", err)
+	}
+}
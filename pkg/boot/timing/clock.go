@@ -0,0 +1,11 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package timing
+
+import "time"
+
+// start is when the program started, which times are since where the time
+// since the kernel booted is not known.
+var start = time.Now()
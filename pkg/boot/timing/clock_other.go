@@ -0,0 +1,16 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package timing
+
+import "time"
+
+// sinceBoot returns the time since the program started, as the time since the
+// kernel booted is not known.
+func sinceBoot() time.Duration {
+	return time.Since(start)
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package timing records how long the phases of a boot take, e.g. DHCP,
+// fetching the boot configuration, downloading the kernel, verifying it and
+// kexec'ing it, and reports them, so that boot time regressions of a fleet can
+// be tracked.
+//
+// Boot packages record phases in Default with Begin. Boot commands choose
+// where the report goes with SetOutput, and bootcmd calls Flush before
+// kexec'ing the chosen kernel.
+package timing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Phases of a boot recorded by u-root's boot packages.
+const (
+	DHCP     = "dhcp"
+	Config   = "config"
+	Download = "download"
+	Verify   = "verify"
+	Kexec    = "kexec"
+)
+
+// Phase is a timed phase of a boot.
+type Phase struct {
+	Name string
+
+	// Start is when the phase began, since the kernel booted.
+	Start time.Duration
+
+	// Duration is how long the phase took.
+	Duration time.Duration
+
+	// Err is the error the phase failed with, if any.
+	Err error
+}
+
+// Report records the phases of a boot. It is safe for concurrent use.
+type Report struct {
+	// now returns the time since the kernel booted.
+	now func() time.Duration
+
+	mu     sync.Mutex
+	phases []Phase
+
+	w   io.Writer
+	url string
+}
+
+// NewReport returns an empty Report.
+func NewReport() *Report {
+	return &Report{now: sinceBoot}
+}
+
+// Begin starts recording a phase, and returns the function to call when the
+// phase ends, with the error it failed with or nil. Phases may overlap or be
+// repeated, e.g. the download of a kernel and of an initrd.
+func (r *Report) Begin(name string) func(error) {
+	start := r.now()
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			p := Phase{Name: name, Start: start, Duration: r.now() - start, Err: err}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.phases = append(r.phases, p)
+		})
+	}
+}
+
+// Phases returns the phases that ended, in the order they ended.
+func (r *Report) Phases() []Phase {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Phase(nil), r.phases...)
+}
+
+// WriteTo writes the report as a table to w, e.g.
+//
+//	PHASE     START   DURATION  ERROR
+//	dhcp      2.104s  1.517s
+//	config    3.621s  0.153s
+//	download  3.801s  4.032s
+//	kexec     7.912s  0.210s
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PHASE\tSTART\tDURATION\tERROR")
+	for _, p := range r.Phases() {
+		var e string
+		if p.Err != nil {
+			e = p.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%.3fs\t%.3fs\t%s\n", p.Name, p.Start.Seconds(), p.Duration.Seconds(), e)
+	}
+	tw.Flush()
+	return b.WriteTo(w)
+}
+
+type jsonPhase struct {
+	Name     string  `json:"name"`
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type jsonReport struct {
+	Hostname string      `json:"hostname,omitempty"`
+	Uptime   float64     `json:"uptime"`
+	Phases   []jsonPhase `json:"phases"`
+}
+
+// MarshalJSON implements json.Marshaler. Times are in seconds, e.g.
+//
+//	{"hostname":"node1","uptime":8.122,"phases":[{"name":"dhcp","start":2.104,"duration":1.517}]}
+func (r *Report) MarshalJSON() ([]byte, error) {
+	j := jsonReport{Uptime: r.now().Seconds(), Phases: []jsonPhase{}}
+	j.Hostname, _ = os.Hostname()
+	for _, p := range r.Phases() {
+		jp := jsonPhase{Name: p.Name, Start: p.Start.Seconds(), Duration: p.Duration.Seconds()}
+		if p.Err != nil {
+			jp.Error = p.Err.Error()
+		}
+		j.Phases = append(j.Phases, jp)
+	}
+	return json.Marshal(j)
+}
+
+// Post POSTs the report as JSON to url with c, or http.DefaultClient if c is
+// nil.
+func (r *Report) Post(ctx context.Context, c *http.Client, url string) error {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("posting the boot timing report to %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// SetOutput sets where Flush reports: written to w if it is not nil, and
+// POSTed to url if it is not empty.
+func (r *Report) SetOutput(w io.Writer, url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w, r.url = w, url
+}
+
+// Flush reports to where SetOutput set, if anywhere.
+func (r *Report) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	w, url := r.w, r.url
+	r.mu.Unlock()
+	if w != nil {
+		if _, err := r.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	if url != "" {
+		return r.Post(ctx, nil, url)
+	}
+	return nil
+}
+
+// Default is the report boot packages record phases in.
+var Default = NewReport()
+
+// Begin starts recording a phase in Default; see Report.Begin.
+func Begin(name string) func(error) {
+	return Default.Begin(name)
+}
+
+// SetOutput sets where Flush reports Default; see Report.SetOutput.
+func SetOutput(w io.Writer, url string) {
+	Default.SetOutput(w, url)
+}
+
+// Flush reports Default; see Report.Flush.
+func Flush(ctx context.Context) error {
+	return Default.Flush(ctx)
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package timing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeReport returns a Report whose clock advances by a second each time it
+// is read.
+func fakeReport() *Report {
+	var t time.Duration
+	return &Report{now: func() time.Duration {
+		t += time.Second
+		return t
+	}}
+}
+
+func TestBegin(t *testing.T) {
+	r := fakeReport()
+	endDHCP := r.Begin(DHCP)
+	endDownload := r.Begin(Download)
+	endDHCP(nil)
+	errFetch := errors.New("404 Not Found")
+	endDownload(errFetch)
+	// Ending a phase twice records it once.
+	endDownload(nil)
+
+	want := []Phase{
+		{Name: DHCP, Start: 1 * time.Second, Duration: 2 * time.Second},
+		{Name: Download, Start: 2 * time.Second, Duration: 2 * time.Second, Err: errFetch},
+	}
+	if got := r.Phases(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Phases = %v, want %v", got, want)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	r := fakeReport()
+	r.Begin(DHCP)(nil)
+	r.Begin(Kexec)(errors.New("operation not permitted"))
+
+	var b bytes.Buffer
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "PHASE  START   DURATION  ERROR\n" +
+		"dhcp   1.000s  1.000s    \n" +
+		"kexec  3.000s  1.000s    operation not permitted\n"
+	if b.String() != want {
+		t.Errorf("WriteTo wrote\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestPost(t *testing.T) {
+	var got jsonReport
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "full", http.StatusInsufficientStorage)
+			return
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer s.Close()
+
+	r := fakeReport()
+	r.Begin(Config)(nil)
+	r.Begin(Verify)(errors.New("no signature"))
+	if err := r.Post(context.Background(), s.Client(), s.URL); err != nil {
+		t.Fatalf("Post = %v", err)
+	}
+	want := []jsonPhase{
+		{Name: Config, Start: 1, Duration: 1},
+		{Name: Verify, Start: 3, Duration: 1, Error: "no signature"},
+	}
+	if got.Uptime != 5 || !reflect.DeepEqual(got.Phases, want) {
+		t.Errorf("Post sent uptime %v and phases %v, want 5 and %v", got.Uptime, got.Phases, want)
+	}
+
+	if err := r.Post(context.Background(), s.Client(), s.URL+"/fail"); err == nil {
+		t.Errorf("Post to a failing server = nil, want error")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	posted := make(chan struct{}, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		posted <- struct{}{}
+	}))
+	defer s.Close()
+
+	r := fakeReport()
+	r.Begin(DHCP)(nil)
+	// Nowhere to report is not an error.
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush = %v", err)
+	}
+
+	var b bytes.Buffer
+	r.SetOutput(&b, s.URL)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush = %v", err)
+	}
+	if !strings.Contains(b.String(), "dhcp") {
+		t.Errorf("Flush wrote %q, want the dhcp phase", b.String())
+	}
+	select {
+	case <-posted:
+	default:
+		t.Errorf("Flush did not post the report")
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"strings"
+)
+
+// Param is a parameter of a kernel command line.
+type Param struct {
+	// Key is the name of the parameter, as written.
+	Key string
+
+	// Value is the value of the parameter, with its quotes removed.
+	Value string
+
+	// HasValue is whether the parameter has a value, even an empty one,
+	// as in "key=", as opposed to "key".
+	HasValue bool
+}
+
+// canonical returns the name of parameter key, with '-' and '_' equivalent.
+func canonical(key string) string {
+	return strings.Replace(key, "-", "_", -1)
+}
+
+// String formats the parameter, quoting its value if it has spaces.
+func (p Param) String() string {
+	if !p.HasValue {
+		return p.Key
+	}
+	if strings.ContainsAny(p.Value, " \t\n") {
+		return p.Key + `="` + p.Value + `"`
+	}
+	return p.Key + "=" + p.Value
+}
+
+// Params is a kernel command line, as its parameters, in order.
+//
+// Unlike CmdLine.AsMap, it keeps duplicate parameters, e.g. several
+// console=, and the order of parameters, so that a command line can be
+// edited and written back. Parameters are found by name with '-' and '_'
+// equivalent.
+type Params []Param
+
+// nextParam splits the first parameter off s, which starts with one, as
+// next_arg of the kernel does: double quotes group spaces, and are removed
+// from around the parameter or its value.
+func nextParam(s string) (Param, string) {
+	quoted := false
+	i, eq := 0, -1
+	for ; i < len(s); i++ {
+		c := s[i]
+		if !quoted && (c == ' ' || c == '\t' || c == '\n') {
+			break
+		}
+		if eq < 0 && c == '=' {
+			eq = i
+		}
+		if c == '"' {
+			quoted = !quoted
+		}
+	}
+	arg, rest := s[:i], s[i:]
+
+	var p Param
+	if eq < 0 {
+		p.Key = strings.Trim(arg, `"`)
+		return p, rest
+	}
+	p.Key = strings.TrimPrefix(arg[:eq], `"`)
+	p.Value, p.HasValue = arg[eq+1:], true
+	if strings.HasPrefix(p.Value, `"`) || strings.HasPrefix(arg, `"`) {
+		p.Value = strings.TrimSuffix(strings.TrimPrefix(p.Value, `"`), `"`)
+	}
+	return p, rest
+}
+
+// ParseParams parses the kernel command line s.
+func ParseParams(s string) Params {
+	var ps Params
+	for {
+		s = strings.TrimLeft(s, " \t\n")
+		if s == "" {
+			return ps
+		}
+		var p Param
+		p, s = nextParam(s)
+		ps = append(ps, p)
+	}
+}
+
+// Params returns the parameters of the command line.
+func (c *CmdLine) Params() Params {
+	return ParseParams(c.Raw)
+}
+
+// String formats the command line.
+func (ps Params) String() string {
+	s := make([]string, len(ps))
+	for i, p := range ps {
+		s[i] = p.String()
+	}
+	return strings.Join(s, " ")
+}
+
+// Has returns whether the command line has parameter key.
+func (ps Params) Has(key string) bool {
+	return len(ps.GetAll(key)) > 0
+}
+
+// Get returns the value of parameter key, the last one if there are several,
+// as most parameters take, and whether the command line has it.
+func (ps Params) Get(key string) (string, bool) {
+	v := ps.GetAll(key)
+	if len(v) == 0 {
+		return "", false
+	}
+	return v[len(v)-1], true
+}
+
+// GetAll returns all the values of parameter key, e.g. of console.
+func (ps Params) GetAll(key string) []string {
+	var v []string
+	key = canonical(key)
+	for _, p := range ps {
+		if canonical(p.Key) == key {
+			v = append(v, p.Value)
+		}
+	}
+	return v
+}
+
+// Set sets parameter key to value, replacing all of its values, in place of
+// the first one, or appending it if the command line does not have it.
+func (ps *Params) Set(key, value string) {
+	ps.set(Param{Key: key, Value: value, HasValue: true})
+}
+
+// SetFlag sets parameter key without a value, as Set does.
+func (ps *Params) SetFlag(key string) {
+	ps.set(Param{Key: key})
+}
+
+func (ps *Params) set(p Param) {
+	key := canonical(p.Key)
+	var out Params
+	found := false
+	for _, q := range *ps {
+		if canonical(q.Key) != key {
+			out = append(out, q)
+		} else if !found {
+			out = append(out, p)
+			found = true
+		}
+	}
+	if !found {
+		out = append(out, p)
+	}
+	*ps = out
+}
+
+// Add appends parameter key with value, keeping those the command line
+// already has, e.g. to add a console.
+func (ps *Params) Add(key, value string) {
+	*ps = append(*ps, Param{Key: key, Value: value, HasValue: true})
+}
+
+// Delete removes all of parameter key, and returns whether there were any.
+func (ps *Params) Delete(key string) bool {
+	key = canonical(key)
+	var out Params
+	for _, p := range *ps {
+		if canonical(p.Key) != key {
+			out = append(out, p)
+		}
+	}
+	deleted := len(out) != len(*ps)
+	*ps = out
+	return deleted
+}
+
+// Module returns the parameters of kernel module name, given as
+// "name.param=value", without the "name." prefix, as insmod takes them.
+func (ps Params) Module(name string) Params {
+	prefix := canonical(name) + "."
+	var m Params
+	for _, p := range ps {
+		if k := canonical(p.Key); strings.HasPrefix(k, prefix) {
+			p.Key = k[len(prefix):]
+			m = append(m, p)
+		}
+	}
+	return m
+}
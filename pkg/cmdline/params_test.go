@@ -0,0 +1,90 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseParams(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want Params
+		out  string
+	}{
+		{in: "", want: nil, out: ""},
+		{in: " ro  quiet\n", want: Params{{Key: "ro"}, {Key: "quiet"}}, out: "ro quiet"},
+		{
+			in:   "console=tty0 console=ttyS0,115200 root= init",
+			want: Params{{"console", "tty0", true}, {"console", "ttyS0,115200", true}, {"root", "", true}, {Key: "init"}},
+			out:  "console=tty0 console=ttyS0,115200 root= init",
+		},
+		{
+			in:   `dyndbg="file foo.c +p" "quoted=a b" a=b=c`,
+			want: Params{{"dyndbg", "file foo.c +p", true}, {"quoted", "a b", true}, {"a", "b=c", true}},
+			out:  `dyndbg="file foo.c +p" quoted="a b" a=b=c`,
+		},
+		{
+			in:   `uroot.uinitargs="-v -d" -- init arg`,
+			want: Params{{"uroot.uinitargs", "-v -d", true}, {Key: "--"}, {Key: "init"}, {Key: "arg"}},
+			out:  `uroot.uinitargs="-v -d" -- init arg`,
+		},
+	} {
+		got := ParseParams(tt.in)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseParams(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+		if s := got.String(); s != tt.out {
+			t.Errorf("ParseParams(%q).String() = %q, want %q", tt.in, s, tt.out)
+		}
+	}
+}
+
+func TestParamsEdit(t *testing.T) {
+	ps := ParseParams("console=tty0 ro net-ifnames=0 console=ttyS0 nvme.io_timeout=4 nvme_core.multipath=N")
+
+	if v, ok := ps.Get("console"); !ok || v != "ttyS0" {
+		t.Errorf("Get(console) = %q, %v, want the last, ttyS0", v, ok)
+	}
+	if v := ps.GetAll("console"); !reflect.DeepEqual(v, []string{"tty0", "ttyS0"}) {
+		t.Errorf("GetAll(console) = %q", v)
+	}
+	if v, ok := ps.Get("net_ifnames"); !ok || v != "0" {
+		t.Errorf("Get(net_ifnames) = %q, %v, want 0 from net-ifnames", v, ok)
+	}
+	if ps.Has("root") || !ps.Has("ro") {
+		t.Errorf("Has(root), Has(ro) = %v, %v, want false, true", ps.Has("root"), ps.Has("ro"))
+	}
+	if m := ps.Module("nvme").String(); m != "io_timeout=4" {
+		t.Errorf("Module(nvme) = %q, want io_timeout=4", m)
+	}
+
+	for _, tt := range []struct {
+		edit func(*Params)
+		want string
+	}{
+		{func(p *Params) { p.Set("console", "hvc0") }, "console=hvc0 ro net-ifnames=0"},
+		{func(p *Params) { p.Set("root", "/dev/sda1") }, "console=tty0 ro net-ifnames=0 console=ttyS0 root=/dev/sda1"},
+		{func(p *Params) { p.Set("net_ifnames", "1") }, "console=tty0 ro net_ifnames=1 console=ttyS0"},
+		{func(p *Params) { p.SetFlag("ro") }, "console=tty0 ro net-ifnames=0 console=ttyS0"},
+		{func(p *Params) { p.Add("console", "hvc0") }, "console=tty0 ro net-ifnames=0 console=ttyS0 console=hvc0"},
+		{func(p *Params) { p.Add("dyndbg", "module nvme +p") }, `console=tty0 ro net-ifnames=0 console=ttyS0 dyndbg="module nvme +p"`},
+		{func(p *Params) { p.Delete("console") }, "ro net-ifnames=0"},
+		{func(p *Params) { p.Delete("quiet") }, "console=tty0 ro net-ifnames=0 console=ttyS0"},
+	} {
+		p := ParseParams("console=tty0 ro net-ifnames=0 console=ttyS0")
+		tt.edit(&p)
+		if got := p.String(); got != tt.want {
+			t.Errorf("edited command line = %q, want %q", got, tt.want)
+		}
+	}
+
+	c := parse(strings.NewReader("a=1 b\n"))
+	if got := c.Params(); !reflect.DeepEqual(got, Params{{"a", "1", true}, {Key: "b"}}) {
+		t.Errorf("CmdLine.Params() = %#v", got)
+	}
+}
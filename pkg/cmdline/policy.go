@@ -0,0 +1,82 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"path"
+	"strings"
+)
+
+// Policy is a kernel commandline Filter that merges the command line of a
+// kernel to boot with that of the running kernel, e.g. for the booted kernel
+// to use the same consoles and network configuration.
+//
+// Parameters are matched by name, with '-' and '_' equivalent, and names may
+// be path.Match patterns, like "rd.*" for all the parameters of dracut.
+type Policy struct {
+	// Inherit are the parameters of the running kernel passed on to the
+	// booted kernel, in the order of the running kernel, replacing those
+	// of the booted kernel. Parameters the running kernel does not have
+	// are left alone.
+	Inherit []string
+
+	// Remove are the parameters removed from the booted kernel, whether
+	// the running kernel has them or not.
+	Remove []string
+
+	// Append is appended to the command line of the booted kernel, before
+	// the inherited parameters.
+	Append string
+}
+
+// SplitList splits a comma separated list of parameter names, as given in
+// command line flags. An empty list gives no names.
+func SplitList(s string) []string {
+	var names []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// match returns whether the canonical name of a parameter matches one of
+// patterns.
+func match(patterns []string, canonicalKey string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.Replace(p, "-", "_", -1), canonicalKey); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Update implements Filter. c is the command line of the running kernel, and
+// may be nil for none.
+func (p *Policy) Update(c *CmdLine, cmdline string) string {
+	var inherited []string
+	present := make(map[string]bool)
+	if c != nil {
+		doParse(c.Raw, func(flag, key, canonicalKey, value, trimmedValue string) {
+			if match(p.Inherit, canonicalKey) {
+				inherited = append(inherited, flag)
+				present[canonicalKey] = true
+			}
+		})
+	}
+
+	var newCl []string
+	doParse(cmdline, func(flag, key, canonicalKey, value, trimmedValue string) {
+		if present[canonicalKey] || match(p.Remove, canonicalKey) {
+			return
+		}
+		newCl = append(newCl, flag)
+	})
+	if p.Append != "" {
+		newCl = append(newCl, p.Append)
+	}
+	return strings.Join(append(newCl, inherited...), " ")
+}
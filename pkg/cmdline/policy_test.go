@@ -0,0 +1,91 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	running := parse(strings.NewReader(`BOOT_IMAGE=/vmlinuz ro console=tty0 console=ttyS0,115200 ` +
+		`ip=dhcp rd.lvm.vg=vg0 rd_NO_LUKS rd.break net-ifnames=0 uroot.initflags="systemd"`))
+	const cl = `root=/dev/sda1 console=ttyS1 ip=none net_ifnames=1 rd.lvm.vg=old quiet`
+
+	for _, tt := range []struct {
+		name    string
+		p       Policy
+		running *CmdLine
+		want    string
+	}{
+		{name: "nothing", want: cl},
+		{
+			name: "consoles",
+			p:    Policy{Inherit: []string{"console"}},
+			want: `root=/dev/sda1 ip=none net_ifnames=1 rd.lvm.vg=old quiet console=tty0 console=ttyS0,115200`,
+		},
+		{
+			name: "patterns",
+			p:    Policy{Inherit: []string{"ip", "rd.*"}},
+			want: `root=/dev/sda1 console=ttyS1 net_ifnames=1 quiet ip=dhcp rd.lvm.vg=vg0 rd.break`,
+		},
+		{
+			name: "dashes",
+			p:    Policy{Inherit: []string{"net_ifnames"}},
+			want: `root=/dev/sda1 console=ttyS1 ip=none rd.lvm.vg=old quiet net-ifnames=0`,
+		},
+		{
+			name: "not running",
+			p:    Policy{Inherit: []string{"quiet", "nomodeset"}},
+			want: cl,
+		},
+		{
+			name: "remove and append",
+			p:    Policy{Inherit: []string{"console"}, Remove: []string{"quiet", "ip"}, Append: "debug"},
+			want: `root=/dev/sda1 net_ifnames=1 rd.lvm.vg=old debug console=tty0 console=ttyS0,115200`,
+		},
+		{
+			name: "quotes",
+			p:    Policy{Inherit: []string{"uroot.*"}},
+			want: cl + ` uroot.initflags="systemd"`,
+		},
+		{
+			name:    "no running kernel",
+			p:       Policy{Inherit: []string{"console"}, Append: "debug"},
+			running: &CmdLine{},
+			want:    cl + " debug",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.running
+			if c == nil {
+				c = running
+			}
+			if got := tt.p.Update(c, cl); got != tt.want {
+				t.Errorf("Update(%q) = %q, want %q", cl, got, tt.want)
+			}
+		})
+	}
+	p := &Policy{Inherit: []string{"console"}}
+	if got := p.Update(nil, "console=ttyS1"); got != "console=ttyS1" {
+		t.Errorf("Update(nil, %q) = %q, want it unchanged", "console=ttyS1", got)
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want []string
+	}{
+		{s: "", want: nil},
+		{s: "console", want: []string{"console"}},
+		{s: "console, ip,,rd.*", want: []string{"console", "ip", "rd.*"}},
+	} {
+		if got := SplitList(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitList(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coreboot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// The cursor of the console is where the next byte is written, and
+	// whether the console overflowed: it is a ring buffer, and the oldest
+	// bytes were overwritten.
+	cursorMask = 1<<28 - 1
+	overflow   = 1 << 31
+
+	// maxConsoleSize bounds the console, which is up to a few MiB.
+	maxConsoleSize = 64 << 20
+)
+
+// Console returns the CBMEM console referenced by the table, oldest bytes
+// first.
+func (t *Table) Console(mem io.ReaderAt) ([]byte, error) {
+	addr, err := t.Pointer(TagCBMEMConsole)
+	if err != nil {
+		return nil, err
+	}
+	return ReadConsole(mem, addr)
+}
+
+// ReadConsole returns the CBMEM console at addr, oldest bytes first.
+func ReadConsole(mem io.ReaderAt, addr int64) ([]byte, error) {
+	var h [8]byte
+	if _, err := mem.ReadAt(h[:], addr); err != nil {
+		return nil, fmt.Errorf("reading CBMEM console header at %#x: %w", addr, err)
+	}
+	size := binary.LittleEndian.Uint32(h[:])
+	cursor := binary.LittleEndian.Uint32(h[4:])
+	if size > maxConsoleSize {
+		return nil, fmt.Errorf("CBMEM console at %#x is %d bytes, more than %d", addr, size, maxConsoleSize)
+	}
+
+	cur := cursor & cursorMask
+	if cursor&overflow == 0 {
+		if cur > size {
+			cur = size
+		}
+		b := make([]byte, cur)
+		if _, err := mem.ReadAt(b, addr+int64(len(h))); err != nil {
+			return nil, fmt.Errorf("reading CBMEM console at %#x: %w", addr, err)
+		}
+		return b, nil
+	}
+
+	b := make([]byte, size)
+	if _, err := mem.ReadAt(b, addr+int64(len(h))); err != nil {
+		return nil, fmt.Errorf("reading CBMEM console at %#x: %w", addr, err)
+	}
+	// The cursor should be in the ring, but if it is not, the start of
+	// the ring is as good a guess as any of where the oldest bytes are.
+	if cur > size {
+		cur = 0
+	}
+	return append(b[cur:], b[:cur]...), nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coreboot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// Mem reads physical memory from a memory device, such as /dev/mem, by
+// mapping it.
+//
+// Memory that coreboot reserves cannot be read(2) from /dev/mem, but it can
+// be mapped.
+type Mem struct {
+	f *os.File
+}
+
+var _ io.ReaderAt = &Mem{}
+
+// NewMem returns a Mem of f, e.g. /dev/mem opened for reading.
+func NewMem(f *os.File) *Mem {
+	return &Mem{f: f}
+}
+
+// ReadAt implements io.ReaderAt.
+func (m *Mem) ReadAt(b []byte, addr int64) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	page := addr &^ int64(os.Getpagesize()-1)
+	mem, err := syscall.Mmap(int(m.f.Fd()), page, int(addr-page)+len(b), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return 0, fmt.Errorf("mapping %d bytes at %#x: %w", len(b), addr, err)
+	}
+	defer syscall.Munmap(mem)
+	return copy(b, mem[addr-page:]), nil
+}
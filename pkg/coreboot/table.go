@@ -0,0 +1,216 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package coreboot reads the coreboot table, which coreboot leaves in memory
+// for its payload, and the CBMEM console, the log of the boot, which it
+// references.
+//
+// Memory is read through an io.ReaderAt of physical addresses, e.g. a Mem of
+// /dev/mem.
+package coreboot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Tags of the records of the coreboot table.
+const (
+	TagMemory       = 0x0001
+	TagMainboard    = 0x0003
+	TagVersion      = 0x0004
+	TagExtraVersion = 0x0005
+	TagBuild        = 0x0006
+	TagSerial       = 0x000f
+	TagForward      = 0x0011
+	TagTimestamps   = 0x0016
+	TagCBMEMConsole = 0x0017
+)
+
+const (
+	signature  = "LBIO"
+	headerSize = 24
+	recordSize = 8
+
+	// maxTableSize bounds the table, which is a few KiB.
+	maxTableSize = 1 << 20
+)
+
+// ErrNotFound is returned when there is no coreboot table.
+var ErrNotFound = errors.New("no coreboot table found")
+
+// Header is the header of the coreboot table.
+type Header struct {
+	Signature      [4]byte
+	HeaderBytes    uint32
+	HeaderChecksum uint32
+	TableBytes     uint32
+	TableChecksum  uint32
+	TableEntries   uint32
+}
+
+// Record is a record of the coreboot table: its tag, and its data, which
+// follows the tag and size of the record.
+type Record struct {
+	Tag  uint32
+	Data []byte
+}
+
+// Table is a coreboot table.
+type Table struct {
+	// Addr is the address of the table. It is the address forwarded to,
+	// if the table found is a forward record.
+	Addr int64
+
+	Header
+	Records []Record
+}
+
+// Record returns the first record with tag.
+func (t *Table) Record(tag uint32) (Record, bool) {
+	for _, r := range t.Records {
+		if r.Tag == tag {
+			return r, true
+		}
+	}
+	return Record{}, false
+}
+
+// StringRecord returns the string of a string record with tag, such as TagVersion
+// or TagBuild.
+func (t *Table) StringRecord(tag uint32) (string, bool) {
+	r, ok := t.Record(tag)
+	if !ok {
+		return "", false
+	}
+	s, _, _ := bytes.Cut(r.Data, []byte{0})
+	return string(s), true
+}
+
+// Pointer returns the 64-bit address of a record with tag which references
+// a CBMEM entry, such as TagCBMEMConsole or TagTimestamps.
+func (t *Table) Pointer(tag uint32) (int64, error) {
+	r, ok := t.Record(tag)
+	if !ok {
+		return 0, fmt.Errorf("no record %#x in the coreboot table", tag)
+	}
+	if len(r.Data) < 8 {
+		return 0, fmt.Errorf("record %#x is %d bytes, want 8", tag, len(r.Data))
+	}
+	return int64(binary.LittleEndian.Uint64(r.Data)), nil
+}
+
+// checksum returns the IP checksum of b, as coreboot computes it.
+func checksum(b []byte) uint32 {
+	var sum uint32
+	for i, c := range b {
+		v := uint32(c)
+		if i&1 != 0 {
+			v <<= 8
+		}
+		sum += v
+		sum = (sum & 0xffff) + sum>>16
+	}
+	return ^sum & 0xffff
+}
+
+// ReadTable reads the coreboot table at addr, and follows it if it is
+// forwarded.
+func ReadTable(mem io.ReaderAt, addr int64) (*Table, error) {
+	// coreboot forwards the table once, from low memory to CBMEM; more
+	// forwards are a loop.
+	for forwards := 0; forwards < 2; forwards++ {
+		t, err := readTable(mem, addr)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := t.Record(TagForward)
+		if !ok {
+			return t, nil
+		}
+		if len(r.Data) < 8 {
+			return nil, fmt.Errorf("forward record at %#x is %d bytes, want 8", addr, len(r.Data))
+		}
+		addr = int64(binary.LittleEndian.Uint64(r.Data))
+	}
+	return nil, fmt.Errorf("coreboot table forwarded more than once, to %#x", addr)
+}
+
+func readTable(mem io.ReaderAt, addr int64) (*Table, error) {
+	hb := make([]byte, headerSize)
+	if _, err := mem.ReadAt(hb, addr); err != nil {
+		return nil, fmt.Errorf("reading coreboot table header at %#x: %w", addr, err)
+	}
+	t := &Table{Addr: addr}
+	if err := binary.Read(bytes.NewReader(hb), binary.LittleEndian, &t.Header); err != nil {
+		return nil, err
+	}
+	if string(t.Signature[:]) != signature {
+		return nil, fmt.Errorf("no coreboot table at %#x: signature is %q", addr, t.Signature)
+	}
+	if t.HeaderBytes != headerSize {
+		return nil, fmt.Errorf("coreboot table header at %#x is %d bytes, want %d", addr, t.HeaderBytes, headerSize)
+	}
+	if checksum(hb) != 0 {
+		return nil, fmt.Errorf("coreboot table header at %#x: bad checksum", addr)
+	}
+	if t.TableBytes > maxTableSize {
+		return nil, fmt.Errorf("coreboot table at %#x is %d bytes, more than %d", addr, t.TableBytes, maxTableSize)
+	}
+
+	b := make([]byte, t.TableBytes)
+	if _, err := mem.ReadAt(b, addr+headerSize); err != nil {
+		return nil, fmt.Errorf("reading coreboot table at %#x: %w", addr, err)
+	}
+	if c := checksum(b); c != t.TableChecksum {
+		return nil, fmt.Errorf("coreboot table at %#x: checksum is %#x, want %#x", addr, c, t.TableChecksum)
+	}
+	for len(b) > 0 {
+		if len(b) < recordSize {
+			return nil, fmt.Errorf("coreboot table at %#x: %d bytes left, less than a record", addr, len(b))
+		}
+		tag := binary.LittleEndian.Uint32(b)
+		size := binary.LittleEndian.Uint32(b[4:])
+		if size < recordSize || int64(size) > int64(len(b)) {
+			return nil, fmt.Errorf("coreboot table at %#x: record %#x of %d bytes, with %d bytes left", addr, tag, size, len(b))
+		}
+		t.Records = append(t.Records, Record{Tag: tag, Data: b[recordSize:size]})
+		b = b[size:]
+	}
+	if len(t.Records) != int(t.TableEntries) {
+		return nil, fmt.Errorf("coreboot table at %#x has %d records, want %d", addr, len(t.Records), t.TableEntries)
+	}
+	return t, nil
+}
+
+// FindTable finds the coreboot table in the places coreboot leaves it: the
+// first 4 KiB of memory, or the 4 KiB at 0xf0000, aligned to 16 bytes.
+func FindTable(mem io.ReaderAt) (*Table, error) {
+	var bad error
+	for _, base := range []int64{0, 0xf0000} {
+		b := make([]byte, 0x1000)
+		if _, err := mem.ReadAt(b, base); err != nil && err != io.EOF {
+			return nil, err
+		}
+		for off := 0; off+len(signature) <= len(b); off += 16 {
+			if string(b[off:off+len(signature)]) != signature {
+				continue
+			}
+			// A signature which does not start a valid table may
+			// be stray data; keep looking.
+			t, err := ReadTable(mem, base+int64(off))
+			if err == nil {
+				return t, nil
+			}
+			bad = err
+		}
+	}
+	if bad != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, bad)
+	}
+	return nil, ErrNotFound
+}
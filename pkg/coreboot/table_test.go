@@ -0,0 +1,139 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coreboot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func record(tag uint32, data []byte) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, tag)
+	b = binary.LittleEndian.AppendUint32(b, uint32(recordSize+len(data)))
+	return append(b, data...)
+}
+
+func pointer(addr uint64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, addr)
+}
+
+// table returns a coreboot table of records.
+func table(records ...[]byte) []byte {
+	b := bytes.Join(records, nil)
+	h := []byte(signature)
+	h = binary.LittleEndian.AppendUint32(h, headerSize)
+	h = binary.LittleEndian.AppendUint32(h, 0)
+	h = binary.LittleEndian.AppendUint32(h, uint32(len(b)))
+	h = binary.LittleEndian.AppendUint32(h, checksum(b))
+	h = binary.LittleEndian.AppendUint32(h, uint32(len(records)))
+	binary.LittleEndian.PutUint32(h[8:], checksum(h))
+	return append(h, b...)
+}
+
+// memory returns 64 KiB of memory with a stray signature at 0, a table at
+// 0x10 forwarded to a table at 0x2000, which references a console at 0x3000.
+func memory(console []byte) []byte {
+	m := make([]byte, 0x10000)
+	copy(m, signature)
+	copy(m[0x10:], table(record(TagForward, pointer(0x2000))))
+	copy(m[0x2000:], table(
+		record(TagVersion, []byte("4.17\x00\x00\x00\x00")),
+		record(TagCBMEMConsole, pointer(0x3000)),
+	))
+	copy(m[0x3000:], console)
+	return m
+}
+
+func console(size, cursor uint32, body string) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, size)
+	b = binary.LittleEndian.AppendUint32(b, cursor)
+	return append(b, body...)
+}
+
+func TestFindTable(t *testing.T) {
+	m := memory(console(16, 5, "hello"))
+	tab, err := FindTable(bytes.NewReader(m))
+	if err != nil {
+		t.Fatalf("FindTable = %v", err)
+	}
+	if tab.Addr != 0x2000 || len(tab.Records) != 2 {
+		t.Errorf("FindTable = table at %#x of %d records, want the forwarded table at 0x2000 of 2", tab.Addr, len(tab.Records))
+	}
+	if v, ok := tab.StringRecord(TagVersion); !ok || v != "4.17" {
+		t.Errorf("StringRecord(TagVersion) = %q, %v, want 4.17", v, ok)
+	}
+	if _, ok := tab.StringRecord(TagBuild); ok {
+		t.Errorf("StringRecord(TagBuild) = true, want false")
+	}
+	c, err := tab.Console(bytes.NewReader(m))
+	if err != nil || string(c) != "hello" {
+		t.Errorf("Console = %q, %v, want hello", c, err)
+	}
+
+	// A table which does not check out is not found.
+	m[0x2000+headerSize+10] ^= 1
+	if _, err := FindTable(bytes.NewReader(m)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindTable with a bad table checksum = %v, want ErrNotFound", err)
+	}
+	if _, err := FindTable(bytes.NewReader(make([]byte, 0x10000))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindTable without a table = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReadTableErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		mem  []byte
+	}{
+		{name: "no signature", mem: make([]byte, 64)},
+		{name: "bad header checksum", mem: func() []byte {
+			b := table(record(TagVersion, []byte("4.17\x00")))
+			b[12]++
+			return b
+		}()},
+		{name: "record overflows", mem: func() []byte {
+			r := record(TagVersion, []byte("4.17\x00"))
+			binary.LittleEndian.PutUint32(r[4:], 64)
+			return table(r)
+		}()},
+		{name: "forward loop", mem: table(record(TagForward, pointer(0)))},
+		{name: "truncated", mem: table(record(TagVersion, []byte("4.17\x00")))[:30]},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tab, err := ReadTable(bytes.NewReader(tt.mem), 0); err == nil {
+				t.Errorf("ReadTable = %+v, want error", tab)
+			}
+		})
+	}
+}
+
+func TestReadConsole(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		console []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", console: console(8, 0, "abcdefgh")},
+		{name: "not wrapped", console: console(8, 3, "abcdefgh"), want: "abc"},
+		{name: "full", console: console(4, 9, "abcd"), want: "abcd"},
+		{name: "wrapped", console: console(8, overflow|3, "IJKdefgh"), want: "defghIJK"},
+		{name: "bad cursor", console: console(4, overflow|10, "abcd"), want: "abcd"},
+		{name: "too large", console: console(maxConsoleSize+1, 0, ""), wantErr: true},
+		{name: "truncated", console: console(8, 6, "abc"), wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadConsole(bytes.NewReader(tt.console), 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadConsole = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Errorf("ReadConsole = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cpio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSymlinks bounds the symlinks followed to open a file, like ELOOP.
+const maxSymlinks = 40
+
+// ReadLinkFS is an fs.FS that can read symlinks, like fs.ReadLinkFS of
+// newer Go. The fs.FS of an Archive is one, and WriteFS archives the
+// symlinks of one.
+type ReadLinkFS interface {
+	fs.FS
+
+	// ReadLink returns the target of the symlink name.
+	ReadLink(name string) (string, error)
+
+	// Lstat returns the info of name, without following a final symlink.
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// archiveFS is an fs.FS of the files of an archive.
+type archiveFS struct {
+	files map[string]Record
+	// dirs maps the directories to the names of their entries, also
+	// those that are only implied by the path of a file.
+	dirs map[string][]string
+}
+
+// FS returns a read-only fs.FS of the files of the archive, without
+// extracting them. Directories need not have a record, a record of a/b/c
+// implies a and a/b. Symlinks are followed, an absolute target is relative
+// to the root of the archive.
+//
+// The fs.FS is of the files in the archive when FS is called.
+func (a *Archive) FS() ReadLinkFS {
+	f := &archiveFS{files: map[string]Record{}, dirs: map[string][]string{".": nil}}
+	for _, name := range a.Order {
+		r := a.Files[name]
+		if !fs.ValidPath(name) {
+			continue
+		}
+		f.files[name] = r
+		if name == "." {
+			continue
+		}
+		for name != "." {
+			dir := path.Dir(name)
+			_, seen := f.dirs[dir]
+			if !contains(f.dirs[dir], path.Base(name)) {
+				f.dirs[dir] = append(f.dirs[dir], path.Base(name))
+			}
+			if seen {
+				break
+			}
+			name = dir
+		}
+	}
+	for _, entries := range f.dirs {
+		sort.Strings(entries)
+	}
+	return f
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lstat returns the info of name, without following a final symlink.
+func (f *archiveFS) lstat(name string) (*fileInfo, bool) {
+	r, ok := f.files[name]
+	_, isDir := f.dirs[name]
+	switch {
+	case ok && (!isDir || r.Mode&S_IFMT == S_IFDIR):
+		return &fileInfo{name: path.Base(name), r: r}, true
+	case isDir:
+		// A directory without a record, or whose record is not one.
+		return &fileInfo{name: path.Base(name), r: Directory(name, 0o755)}, true
+	}
+	return nil, false
+}
+
+// resolve returns the info of name and its name in the archive, following
+// symlinks.
+func (f *archiveFS) resolve(op, name string) (*fileInfo, string, error) {
+	var links int
+	return f.follow(op, name, &links)
+}
+
+// follow is resolve, links counts the symlinks followed so far.
+func (f *archiveFS) follow(op, name string, links *int) (*fileInfo, string, error) {
+	orig := name
+	for ; ; *links++ {
+		fi, err := f.lfollow(op, name, links)
+		if err != nil {
+			return nil, "", &fs.PathError{Op: op, Path: orig, Err: errors.Unwrap(err)}
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			fi.name = path.Base(orig)
+			return fi, fi.path, nil
+		}
+		if *links >= maxSymlinks {
+			return nil, "", &fs.PathError{Op: op, Path: orig, Err: errors.New("too many levels of symbolic links")}
+		}
+		target, err := readAll(fi.r)
+		if err != nil {
+			return nil, "", &fs.PathError{Op: op, Path: orig, Err: err}
+		}
+		if strings.HasPrefix(target, "/") {
+			name = path.Clean(target[1:])
+		} else {
+			name = path.Join(path.Dir(fi.path), target)
+		}
+		if !fs.ValidPath(name) {
+			return nil, "", &fs.PathError{Op: op, Path: orig, Err: fs.ErrNotExist}
+		}
+	}
+}
+
+func readAll(r Record) (string, error) {
+	if r.ReaderAt == nil {
+		return "", nil
+	}
+	b, err := io.ReadAll(io.NewSectionReader(r.ReaderAt, 0, int64(r.FileSize)))
+	return string(b), err
+}
+
+// Open implements fs.FS.
+func (f *archiveFS) Open(name string) (fs.File, error) {
+	fi, name, err := f.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return &dirFile{fi: fi, fs: f, path: name}, nil
+	}
+	var content io.ReaderAt = strings.NewReader("")
+	if fi.r.ReaderAt != nil {
+		content = fi.r.ReaderAt
+	}
+	return &file{SectionReader: io.NewSectionReader(content, 0, int64(fi.r.FileSize)), fi: fi}, nil
+}
+
+// Stat implements fs.StatFS.
+func (f *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	fi, _, err := f.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+// ReadLink implements ReadLinkFS.
+func (f *archiveFS) ReadLink(name string) (string, error) {
+	fi, err := f.lresolve("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if fi.Mode()&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return readAll(fi.r)
+}
+
+// Lstat implements ReadLinkFS.
+func (f *archiveFS) Lstat(name string) (fs.FileInfo, error) {
+	fi, err := f.lresolve("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+// lresolve returns the info of name, following symlinks but a final one.
+func (f *archiveFS) lresolve(op, name string) (*fileInfo, error) {
+	var links int
+	return f.lfollow(op, name, &links)
+}
+
+// lfollow is lresolve, links counts the symlinks followed so far.
+func (f *archiveFS) lfollow(op, name string, links *int) (*fileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	orig := name
+	if dir, base := path.Split(name); dir != "" {
+		parent, _, err := f.follow(op, strings.TrimSuffix(dir, "/"), links)
+		if err != nil || !parent.IsDir() {
+			return nil, &fs.PathError{Op: op, Path: orig, Err: fs.ErrNotExist}
+		}
+		name = path.Join(parent.path, base)
+	}
+	fi, ok := f.lstat(name)
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: orig, Err: fs.ErrNotExist}
+	}
+	fi.path = name
+	return fi, nil
+}
+
+// fileInfo implements fs.FileInfo and fs.DirEntry for a record. Sys returns
+// the Record.
+type fileInfo struct {
+	name string
+	// path is the name of the file in the archive, once symlinks are
+	// resolved.
+	path string
+	r    Record
+}
+
+func (fi *fileInfo) Name() string               { return fi.name }
+func (fi *fileInfo) Size() int64                { return int64(fi.r.FileSize) }
+func (fi *fileInfo) Mode() fs.FileMode          { return modeFromLinux(fi.r.Mode) }
+func (fi *fileInfo) ModTime() time.Time         { return time.Unix(int64(fi.r.MTime), 0) }
+func (fi *fileInfo) IsDir() bool                { return fi.Mode().IsDir() }
+func (fi *fileInfo) Sys() interface{}           { return fi.r }
+func (fi *fileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi *fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+type file struct {
+	*io.SectionReader
+	fi *fileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.fi, nil }
+func (f *file) Close() error               { return nil }
+
+type dirFile struct {
+	fi   *fileInfo
+	fs   *archiveFS
+	path string
+	// off is the number of entries read by ReadDir.
+	off int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.fi, nil }
+func (d *dirFile) Close() error               { return nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: errors.New("is a directory")}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	names := d.fs.dirs[d.path][d.off:]
+	if n > 0 && len(names) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && len(names) > n {
+		names = names[:n]
+	}
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, name := range names {
+		fi, _ := d.fs.lstat(path.Join(d.path, name))
+		entries = append(entries, fi)
+	}
+	d.off += len(names)
+	return entries, nil
+}
+
+// WriteFS writes a record for every file of fsys to w, directories first,
+// in the order of fs.WalkDir. Symlinks are archived if fsys is a
+// ReadLinkFS, files of other types can not be.
+//
+// WriteFS does not write a trailer record. Contents are read when their
+// record is written.
+func WriteFS(w RecordWriter, fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var r Record
+		switch m := info.Mode(); {
+		case m.IsDir():
+			r = Directory(name, uint64(m.Perm()))
+		case m.IsRegular():
+			b, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			r = StaticRecord(b, Info{Name: name, Mode: S_IFREG | uint64(m.Perm())})
+		case m&fs.ModeSymlink != 0:
+			rl, ok := fsys.(ReadLinkFS)
+			if !ok {
+				return fmt.Errorf("%s: can not read symlinks of %T", name, fsys)
+			}
+			target, err := rl.ReadLink(name)
+			if err != nil {
+				return err
+			}
+			r = Symlink(name, target)
+		default:
+			return fmt.Errorf("%s: can not archive files of type %v", name, m.Type())
+		}
+		r.Mode |= linuxModeBits(info.Mode())
+		r.MTime = uint64(info.ModTime().Unix())
+		if err := w.WriteRecord(r); err != nil {
+			return fmt.Errorf("writing %q: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// linuxModeBits returns the setuid, setgid and sticky bits of m.
+func linuxModeBits(m fs.FileMode) uint64 {
+	var bits uint64
+	if m&fs.ModeSetuid != 0 {
+		bits |= S_ISUID
+	}
+	if m&fs.ModeSetgid != 0 {
+		bits |= S_ISGID
+	}
+	if m&fs.ModeSticky != 0 {
+		bits |= S_ISVTX
+	}
+	return bits
+}
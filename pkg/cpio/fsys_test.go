@@ -0,0 +1,132 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cpio
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testArchive() *Archive {
+	return ArchiveFromRecords([]Record{
+		Directory("etc", 0o755),
+		StaticFile("etc/hostname", "box\n", 0o644),
+		// No records for usr and usr/lib.
+		StaticFile("usr/lib/os-release", "ID=uroot\n", 0o644),
+		Symlink("etc/os-release", "../usr/lib/os-release"),
+		Symlink("lib", "/usr/lib"),
+		StaticFile("/init", "#!/bin/sh\n", 0o755),
+		Symlink("loop", "loop"),
+		Symlink("deep", "deep/deep"),
+		Symlink("dangling", "nothing"),
+	})
+}
+
+func TestArchiveFS(t *testing.T) {
+	fsys := testArchive().FS()
+	// fstest does not expect broken symlinks.
+	a := testArchive()
+	delete(a.Files, "loop")
+	delete(a.Files, "deep")
+	delete(a.Files, "dangling")
+	a.Order = a.Order[:len(a.Order)-3]
+	if err := fstest.TestFS(a.FS(), "etc/hostname", "etc/os-release", "usr/lib/os-release", "init"); err != nil {
+		t.Error(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		want string
+		err  error
+	}{
+		{name: "etc/hostname", want: "box\n"},
+		{name: "etc/os-release", want: "ID=uroot\n"},
+		{name: "lib/os-release", want: "ID=uroot\n"},
+		{name: "etc/../init", err: fs.ErrInvalid},
+		{name: "etc/hostname/x", err: fs.ErrNotExist},
+		{name: "dangling", err: fs.ErrNotExist},
+		{name: "loop", err: errors.New("")},
+		{name: "deep", err: errors.New("")},
+		{name: "lib", err: errors.New("")},
+	} {
+		got, err := fs.ReadFile(fsys, tt.name)
+		switch {
+		case tt.err == nil && (err != nil || string(got) != tt.want):
+			t.Errorf("ReadFile(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		case tt.err != nil && err == nil:
+			t.Errorf("ReadFile(%q) = %q, want an error", tt.name, got)
+		case tt.err != nil && tt.err.Error() != "" && !errors.Is(err, tt.err):
+			t.Errorf("ReadFile(%q) = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	if target, err := fsys.ReadLink("lib"); err != nil || target != "/usr/lib" {
+		t.Errorf("ReadLink(lib) = %q, %v, want /usr/lib", target, err)
+	}
+	if _, err := fsys.ReadLink("init"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("ReadLink(init) = %v, want %v", err, fs.ErrInvalid)
+	}
+	fi, err := fs.Stat(fsys, "usr")
+	if err != nil || !fi.IsDir() {
+		t.Errorf("Stat(usr) = %v, %v, want a directory", fi, err)
+	}
+	if fi, err := fs.Stat(fsys, "init"); err != nil || fi.Mode() != 0o755 || fi.Sys().(Record).Name != "init" {
+		t.Errorf("Stat(init) = %v, %v, want mode 0755 and its record", fi, err)
+	}
+}
+
+func TestWriteFS(t *testing.T) {
+	// Through a newc archive and back.
+	var b bytes.Buffer
+	w := Newc.Writer(&b)
+	if err := WriteFS(w, testArchive().FS()); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteTrailer(w); err != nil {
+		t.Fatal(err)
+	}
+	a, err := ArchiveFromReader(Newc.Reader(bytes.NewReader(b.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Directories come first, and those only implied get records.
+	want := []string{"dangling", "deep", "etc", "etc/hostname", "etc/os-release", "init", "lib", "loop", "usr", "usr/lib", "usr/lib/os-release"}
+	if !reflect.DeepEqual(a.Order, want) {
+		t.Errorf("WriteFS wrote %q, want %q", a.Order, want)
+	}
+	for _, r := range []Record{
+		StaticFile("usr/lib/os-release", "ID=uroot\n", 0o644),
+		Symlink("lib", "/usr/lib"),
+		Directory("usr", 0o755),
+	} {
+		if !a.Contains(r) {
+			t.Errorf("WriteFS did not write %v", r)
+		}
+	}
+
+	m := fstest.MapFS{
+		"a":     {Data: []byte("a"), Mode: 0o600},
+		"b/c":   {Data: []byte("c"), Mode: fs.ModeSetuid | 0o755, ModTime: time.Unix(10, 0)},
+		"b/lnk": {Data: []byte("c"), Mode: fs.ModeSymlink},
+	}
+	a = InMemArchive()
+	// Hide the ReadLink method of newer MapFS.
+	if err := WriteFS(a, struct{ fs.FS }{m}); err == nil {
+		t.Errorf("WriteFS of a symlink without ReadLink succeeded")
+	}
+	delete(m, "b/lnk")
+	a = InMemArchive()
+	if err := WriteFS(a, m); err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := a.Get("b/c"); !ok || r.Mode != S_IFREG|S_ISUID|0o755 || r.MTime != 10 {
+		t.Errorf("b/c = %v, want a setuid file with MTime 10", r.Info)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cpio
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// FromTar writes a record to w for every file of the tar archive read from
+// r, keeping modes, owners, modification times and device numbers.
+//
+// Hard links are written as copies of the file they link to: the kernel
+// only links files whose first record says they have several links, which
+// a tar archive does not tell in advance. So FromTar keeps the contents of
+// regular files until it returns.
+//
+// FromTar does not write a trailer record.
+func FromTar(w RecordWriter, r io.Reader) error {
+	tr := tar.NewReader(r)
+	contents := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := Normalize(hdr.Name)
+		if name == "." {
+			continue
+		}
+		info := Info{
+			Name:  name,
+			Mode:  uint64(hdr.Mode) & 0o7777,
+			UID:   uint64(hdr.Uid),
+			GID:   uint64(hdr.Gid),
+			MTime: uint64(hdr.ModTime.Unix()),
+		}
+		var rec Record
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			b, err := io.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("reading %q: %w", hdr.Name, err)
+			}
+			contents[name] = b
+			info.Mode |= S_IFREG
+			rec = StaticRecord(b, info)
+		case tar.TypeLink:
+			b, ok := contents[Normalize(hdr.Linkname)]
+			if !ok {
+				return fmt.Errorf("%q: hard link to %q, which is not a regular file before it", hdr.Name, hdr.Linkname)
+			}
+			contents[name] = b
+			info.Mode |= S_IFREG
+			rec = StaticRecord(b, info)
+		case tar.TypeSymlink:
+			info.Mode = S_IFLNK | 0o777
+			rec = StaticRecord([]byte(hdr.Linkname), info)
+		case tar.TypeDir:
+			info.Mode |= S_IFDIR
+			rec = StaticRecord(nil, info)
+		case tar.TypeChar, tar.TypeBlock:
+			if hdr.Typeflag == tar.TypeChar {
+				info.Mode |= S_IFCHR
+			} else {
+				info.Mode |= S_IFBLK
+			}
+			info.Rmajor, info.Rminor = uint64(hdr.Devmajor), uint64(hdr.Devminor)
+			rec = StaticRecord(nil, info)
+		case tar.TypeFifo:
+			info.Mode |= S_IFIFO
+			rec = StaticRecord(nil, info)
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return fmt.Errorf("%q: tar entry type %q can not be archived", hdr.Name, hdr.Typeflag)
+		}
+		if err := w.WriteRecord(rec); err != nil {
+			return fmt.Errorf("writing %q: %w", name, err)
+		}
+	}
+}
+
+// ToTar writes a tar entry to w for every record read from r, and closes the
+// tar archive. Records of regular files with the same inode number as an
+// earlier one and no contents become hard links, as Recorder writes them.
+func ToTar(w io.Writer, r RecordReader) error {
+	tw := tar.NewWriter(w)
+	inodes := make(map[uint64]string)
+	err := ForEachRecord(r, func(rec Record) error {
+		if rec.Name == Trailer {
+			return nil
+		}
+		hdr := &tar.Header{
+			Name:     rec.Name,
+			Mode:     int64(rec.Mode & 0o7777),
+			Uid:      int(rec.UID),
+			Gid:      int(rec.GID),
+			ModTime:  time.Unix(int64(rec.MTime), 0),
+			Devmajor: int64(rec.Rmajor),
+			Devminor: int64(rec.Rminor),
+		}
+		// Records without a ReaderAt have no contents, whatever their
+		// FileSize says.
+		var size int64
+		if rec.ReaderAt != nil {
+			size = int64(rec.FileSize)
+		}
+		switch rec.Mode & S_IFMT {
+		case S_IFREG:
+			if first, ok := inodes[rec.Ino]; ok && rec.Ino != 0 && size == 0 {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = first
+				break
+			}
+			if rec.Ino != 0 {
+				inodes[rec.Ino] = rec.Name
+			}
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = size
+		case S_IFLNK:
+			target, err := readAll(rec)
+			if err != nil {
+				return fmt.Errorf("reading %q: %w", rec.Name, err)
+			}
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = target
+		case S_IFDIR:
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name = strings.TrimSuffix(rec.Name, "/") + "/"
+		case S_IFCHR:
+			hdr.Typeflag = tar.TypeChar
+		case S_IFBLK:
+			hdr.Typeflag = tar.TypeBlock
+		case S_IFIFO:
+			hdr.Typeflag = tar.TypeFifo
+		default:
+			return fmt.Errorf("%q: file type %#o can not be archived", rec.Name, rec.Mode&S_IFMT)
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("writing %q: %w", rec.Name, err)
+		}
+		if hdr.Size > 0 {
+			if _, err := io.Copy(tw, io.NewSectionReader(rec, 0, size)); err != nil {
+				return fmt.Errorf("writing %q: %w", rec.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
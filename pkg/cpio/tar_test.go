@@ -0,0 +1,125 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cpio
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFromTar(t *testing.T) {
+	mtime := time.Unix(1600000000, 0)
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, f := range []struct {
+		hdr     tar.Header
+		content string
+	}{
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./", Mode: 0o755}},
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./bin/", Mode: 0o755, ModTime: mtime}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./bin/busybox", Mode: 0o4755, Uid: 1, Gid: 2, ModTime: mtime}, content: "ELF"},
+		{hdr: tar.Header{Typeflag: tar.TypeLink, Name: "./bin/sh", Linkname: "./bin/busybox", Mode: 0o4755, ModTime: mtime}},
+		{hdr: tar.Header{Typeflag: tar.TypeSymlink, Name: "/init", Linkname: "bin/sh", Mode: 0o777}},
+		{hdr: tar.Header{Typeflag: tar.TypeChar, Name: "dev/console", Mode: 0o600, Devmajor: 5, Devminor: 1}},
+		{hdr: tar.Header{Typeflag: tar.TypeBlock, Name: "dev/sda", Mode: 0o660, Devmajor: 8}},
+		{hdr: tar.Header{Typeflag: tar.TypeFifo, Name: "run/initctl", Mode: 0o600}},
+	} {
+		f.hdr.Size = int64(len(f.content))
+		if err := tw.WriteHeader(&f.hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, f.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	a := InMemArchive()
+	if err := FromTar(a, bytes.NewReader(b.Bytes())); err != nil {
+		t.Fatalf("FromTar = %v", err)
+	}
+	want := []Record{
+		StaticRecord(nil, Info{Name: "bin", Mode: S_IFDIR | 0o755, MTime: 1600000000}),
+		StaticRecord([]byte("ELF"), Info{Name: "bin/busybox", Mode: S_IFREG | 0o4755, UID: 1, GID: 2, MTime: 1600000000}),
+		StaticRecord([]byte("ELF"), Info{Name: "bin/sh", Mode: S_IFREG | 0o4755, MTime: 1600000000}),
+		Symlink("init", "bin/sh"),
+		StaticRecord(nil, Info{Name: "dev/console", Mode: S_IFCHR | 0o600, Rmajor: 5, Rminor: 1}),
+		StaticRecord(nil, Info{Name: "dev/sda", Mode: S_IFBLK | 0o660, Rmajor: 8}),
+		StaticRecord(nil, Info{Name: "run/initctl", Mode: S_IFIFO | 0o600}),
+	}
+	got, err := ReadAllRecords(a.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !AllEqual(got, want) {
+		t.Errorf("FromTar = %v, want %v", got, want)
+	}
+
+	// And back again.
+	var out bytes.Buffer
+	if err := ToTar(&out, a.Reader()); err != nil {
+		t.Fatalf("ToTar = %v", err)
+	}
+	tr := tar.NewReader(&out)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.Name == "dev/sda" && (hdr.Typeflag != tar.TypeBlock || hdr.Devmajor != 8 || hdr.Mode != 0o660) {
+			t.Errorf("ToTar: dev/sda = %+v, want a block device 8:0", hdr)
+		}
+		if hdr.Name == "bin/busybox" && (hdr.Mode != 0o4755 || hdr.Uid != 1 || hdr.Gid != 2 || !hdr.ModTime.Equal(mtime)) {
+			t.Errorf("ToTar: bin/busybox = %+v, want mode 4755, owner 1:2, time %v", hdr, mtime)
+		}
+	}
+	wantNames := []string{"bin/", "bin/busybox", "bin/sh", "init", "dev/console", "dev/sda", "run/initctl"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("ToTar names = %q, want %q", names, wantNames)
+	}
+}
+
+func TestToTarHardLinks(t *testing.T) {
+	records := []Record{
+		StaticRecord([]byte("ELF"), Info{Name: "bin/busybox", Mode: S_IFREG | 0o755, Ino: 3}),
+		// As Recorder writes the second name of an inode.
+		{Info: Info{Name: "bin/sh", Mode: S_IFREG | 0o755, Ino: 3, FileSize: 3}},
+		// Reproducible archives have no inode numbers.
+		StaticRecord(nil, Info{Name: "empty", Mode: S_IFREG | 0o644}),
+		StaticRecord(nil, Info{Name: "empty2", Mode: S_IFREG | 0o644}),
+		TrailerRecord,
+	}
+	var out bytes.Buffer
+	if err := ToTar(&out, ArchiveFromRecords(records).Reader()); err != nil {
+		t.Fatalf("ToTar = %v", err)
+	}
+	tr := tar.NewReader(&out)
+	var got []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(hdr.Typeflag)+hdr.Name+">"+hdr.Linkname)
+	}
+	want := []string{"0bin/busybox>", "1bin/sh>bin/busybox", "0empty>", "0empty2>"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToTar = %q, want %q", got, want)
+	}
+}
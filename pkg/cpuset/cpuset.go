@@ -0,0 +1,116 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cpuset parses and formats sets of CPUs or NUMA nodes, as lists,
+// e.g. 0-3,7, and as hexadecimal masks, e.g. 8f, the ways Linux and tools
+// like taskset and numactl write them.
+package cpuset
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseList parses a list of numbers and ranges of numbers, e.g.
+// 0-3,7,8-15:2. A range may have a stride after a colon.
+func ParseList(s string) ([]int, error) {
+	var ids []int
+	for _, r := range strings.Split(strings.TrimSpace(s), ",") {
+		if r == "" {
+			continue
+		}
+		stride := 1
+		if i := strings.IndexByte(r, ':'); i >= 0 {
+			n, err := strconv.Atoi(r[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid stride in %q", r)
+			}
+			stride, r = n, r[:i]
+		}
+		lo, hi, isRange := strings.Cut(r, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid number in %q", r)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("invalid range %q", r)
+			}
+		}
+		for i := first; i <= last; i += stride {
+			ids = append(ids, i)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("empty list %q", s)
+	}
+	return normalize(ids), nil
+}
+
+// FormatList formats ids as a list, e.g. 0-3,7.
+func FormatList(ids []int) string {
+	ids = normalize(ids)
+	var parts []string
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(ids[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", ids[i], ids[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseMask parses a hexadecimal mask, with or without 0x, in which bit n
+// is set for n. Commas, as between the 32-bit words of the masks of
+// /proc/PID/status, are ignored.
+func ParseMask(s string) ([]int, error) {
+	h := strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	h = strings.TrimPrefix(strings.TrimPrefix(h, "0x"), "0X")
+	m, ok := new(big.Int).SetString(h, 16)
+	if h == "" || !ok {
+		return nil, fmt.Errorf("invalid mask %q", s)
+	}
+	var ids []int
+	for i := 0; i < m.BitLen(); i++ {
+		if m.Bit(i) == 1 {
+			ids = append(ids, i)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("empty mask %q", s)
+	}
+	return ids, nil
+}
+
+// FormatMask formats ids as a hexadecimal mask, without 0x.
+func FormatMask(ids []int) string {
+	m := new(big.Int)
+	for _, id := range ids {
+		m.SetBit(m, id, 1)
+	}
+	return m.Text(16)
+}
+
+// normalize sorts ids, and removes duplicates.
+func normalize(ids []int) []int {
+	ids = append([]int(nil), ids...)
+	sort.Ints(ids)
+	out := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
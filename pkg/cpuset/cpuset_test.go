@@ -0,0 +1,61 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cpuset
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []int
+		out  string
+	}{
+		{in: "0", want: []int{0}, out: "0"},
+		{in: "0-3,7\n", want: []int{0, 1, 2, 3, 7}, out: "0-3,7"},
+		{in: "8-15:2,1", want: []int{1, 8, 10, 12, 14}, out: "1,8,10,12,14"},
+		{in: "5,3-4,4", want: []int{3, 4, 5}, out: "3-5"},
+	} {
+		got, err := ParseList(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseList(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+		if s := FormatList(got); s != tt.out {
+			t.Errorf("FormatList(%v) = %q, want %q", got, s, tt.out)
+		}
+	}
+	for _, in := range []string{"", "a", "3-1", "-1", "1-", "0-3:0", "0-3:x"} {
+		if got, err := ParseList(in); err == nil {
+			t.Errorf("ParseList(%q) = %v, want an error", in, got)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []int
+		out  string
+	}{
+		{in: "1", want: []int{0}, out: "1"},
+		{in: "0x8f", want: []int{0, 1, 2, 3, 7}, out: "8f"},
+		{in: "00000001,00000000", want: []int{32}, out: "100000000"},
+	} {
+		got, err := ParseMask(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMask(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+		if s := FormatMask(got); s != tt.out {
+			t.Errorf("FormatMask(%v) = %q, want %q", got, s, tt.out)
+		}
+	}
+	for _, in := range []string{"", "0x", "0", "xyz"} {
+		if got, err := ParseMask(in); err == nil {
+			t.Errorf("ParseMask(%q) = %v, want an error", in, got)
+		}
+	}
+}
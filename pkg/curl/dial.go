@@ -0,0 +1,168 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/u-root/u-root/pkg/vsock"
+)
+
+// DialOptions choose the network that connections to servers go through, for
+// machines with more than one network interface, where the default route is
+// not always to the provisioning network.
+type DialOptions struct {
+	// Family is the IP version to connect with, 4 or 6, or 0 for
+	// either. Host names are only resolved to addresses of Family.
+	Family int
+
+	// Interface is the name of the network interface to connect
+	// through, with SO_BINDTODEVICE, whatever the routes say. It is only
+	// supported on Linux, and needs CAP_NET_RAW.
+	Interface string
+
+	// LocalAddr is the source address of connections, one of the
+	// addresses of the interface to connect through.
+	LocalAddr net.IP
+
+	// Timeout, if not 0, is how long to wait to resolve and connect to a
+	// server, and then for each read from it, as with the -timeout of
+	// GNU wget, so that a stalled server fails rather than hangs.
+	Timeout time.Duration
+
+	// UnixSocket is the path of a UNIX domain socket that all connections
+	// are made to instead, as with the --unix-socket of curl, e.g. for
+	// the API of a local agent. The host of URLs is only sent in the Host
+	// header then.
+	UnixSocket string
+
+	// Vsock is the AF_VSOCK address that all connections are made to
+	// instead, e.g. for the metadata service of the host of a virtual
+	// machine. It is only supported on Linux.
+	Vsock *vsock.Addr
+}
+
+// IsZero returns whether o changes nothing.
+func (o DialOptions) IsZero() bool {
+	return o.Family == 0 && o.Interface == "" && o.LocalAddr == nil && o.Timeout == 0 &&
+		o.UnixSocket == "" && o.Vsock == nil
+}
+
+// network returns the network of o for network, e.g. tcp4 for tcp and Family
+// 4.
+func (o DialOptions) network(network string) string {
+	if o.Family != 0 && (network == "tcp" || network == "udp") {
+		return fmt.Sprintf("%s%d", network, o.Family)
+	}
+	return network
+}
+
+// dialer returns a dialer that connects as of o, to be given the networks
+// returned by o.network.
+func (o DialOptions) dialer() (*net.Dialer, error) {
+	if (o.UnixSocket != "" || o.Vsock != nil) && (o.Family != 0 || o.Interface != "" || o.LocalAddr != nil) {
+		return nil, errors.New("connections to a UNIX domain or vsock socket have no IP version, interface or local address")
+	}
+	if o.UnixSocket != "" && o.Vsock != nil {
+		return nil, errors.New("connections cannot be made to both a UNIX domain and a vsock socket")
+	}
+	if o.Family != 0 && o.Family != 4 && o.Family != 6 {
+		return nil, fmt.Errorf("invalid IP version %d: want 4 or 6", o.Family)
+	}
+	d := &net.Dialer{Timeout: o.Timeout}
+	if o.LocalAddr != nil {
+		if v4 := o.LocalAddr.To4() != nil; (o.Family == 4 && !v4) || (o.Family == 6 && v4) {
+			return nil, fmt.Errorf("local address %v is not an IPv%d address", o.LocalAddr, o.Family)
+		}
+		// The port is chosen by the system.
+		d.LocalAddr = &net.TCPAddr{IP: o.LocalAddr}
+	}
+	if o.Interface != "" {
+		if _, err := net.InterfaceByName(o.Interface); err != nil {
+			return nil, fmt.Errorf("interface %q: %w", o.Interface, err)
+		}
+		d.Control = func(_, _ string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) {
+				err = bindToDevice(fd, o.Interface)
+			}); cerr != nil {
+				return cerr
+			}
+			if err != nil {
+				return fmt.Errorf("binding to interface %q: %w", o.Interface, err)
+			}
+			return nil
+		}
+	}
+	return d, nil
+}
+
+// DialerClient returns a copy of c, or of http.DefaultClient if c is nil, that
+// connects to servers, or to its proxy, as of o, e.g. for NewHTTPClient. It
+// keeps the proxy and TLS configuration of c, so it can be given a ProxyClient
+// or a TLSClient, but not the resolver of a ResolverClient: give the client
+// DialerClient returns to ResolverClient instead.
+func DialerClient(c *http.Client, o DialOptions) (*http.Client, error) {
+	d, err := o.dialer()
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		c = http.DefaultClient
+	}
+	t, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var c net.Conn
+		var err error
+		switch {
+		case o.UnixSocket != "":
+			c, err = d.DialContext(ctx, "unix", o.UnixSocket)
+		case o.Vsock != nil:
+			c, err = dialVsock(ctx, d.Timeout, *o.Vsock)
+		default:
+			c, err = d.DialContext(ctx, o.network(network), addr)
+		}
+		if err != nil || o.Timeout == 0 {
+			return c, err
+		}
+		return &timeoutConn{c, o.Timeout}, nil
+	}
+	nc := *c
+	nc.Transport = t
+	return &nc, nil
+}
+
+// timeoutConn is a connection whose reads time out after timeout.
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *timeoutConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+// dialVsock connects to addr, waiting up to timeout, if it is not 0.
+func dialVsock(ctx context.Context, timeout time.Duration, addr vsock.Addr) (net.Conn, error) {
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return vsock.Dial(ctx, addr)
+}
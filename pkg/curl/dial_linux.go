@@ -0,0 +1,13 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import "golang.org/x/sys/unix"
+
+// bindToDevice makes the socket fd send and receive through interface iface
+// only.
+func bindToDevice(fd uintptr, iface string) error {
+	return unix.BindToDevice(int(fd), iface)
+}
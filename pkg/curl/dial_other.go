@@ -0,0 +1,15 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package curl
+
+import "errors"
+
+// bindToDevice is only supported on Linux.
+func bindToDevice(fd uintptr, iface string) error {
+	return errors.New("binding to an interface is not supported")
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDialerClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		io.WriteString(w, host)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	for _, tt := range []struct {
+		name     string
+		o        DialOptions
+		root     bool
+		want     string
+		wantErr  bool
+		fetchErr bool
+	}{
+		{name: "default", want: "127.0.0.1"},
+		{name: "IPv4", o: DialOptions{Family: 4}, want: "127.0.0.1"},
+		{name: "IPv6 to an IPv4 server", o: DialOptions{Family: 6}, fetchErr: true},
+		{name: "IPv5", o: DialOptions{Family: 5}, wantErr: true},
+		{name: "local address", o: DialOptions{LocalAddr: net.ParseIP("127.0.0.2")}, want: "127.0.0.2"},
+		{name: "local address of another family", o: DialOptions{Family: 6, LocalAddr: net.ParseIP("127.0.0.2")}, wantErr: true},
+		{name: "interface", o: DialOptions{Interface: "lo"}, root: true, want: "127.0.0.1"},
+		{name: "no interface", o: DialOptions{Interface: "nosuchif0"}, wantErr: true},
+		{name: "timeout", o: DialOptions{Timeout: time.Minute}, want: "127.0.0.1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.root && os.Getuid() != 0 {
+				t.Skip("binding to an interface needs root")
+			}
+			c, err := DialerClient(srv.Client(), tt.o)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DialerClient = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			r, err := NewHTTPClient(c).FetchWithoutCache(context.Background(), u)
+			if (err != nil) != tt.fetchErr {
+				t.Fatalf("fetch = %v, want error %t", err, tt.fetchErr)
+			}
+			if err != nil {
+				return
+			}
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("connected from %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialerClientTimeout(t *testing.T) {
+	stall := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "7")
+		io.WriteString(w, "vml")
+		w.(http.Flusher).Flush()
+		<-stall
+	}))
+	defer srv.Close()
+	defer close(stall)
+	u, _ := url.Parse(srv.URL)
+
+	c, err := DialerClient(srv.Client(), DialOptions{Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewHTTPClient(c).FetchWithoutCache(context.Background(), u)
+	if err != nil {
+		t.Fatalf("fetch = %v", err)
+	}
+	var nerr net.Error
+	if _, err := io.ReadAll(r); !errors.As(err, &nerr) || !nerr.Timeout() {
+		t.Errorf("reading from a stalled server = %v, want a timeout", err)
+	}
+}
+
+func TestDialerClientResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "vmlinuz")
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	u, _ := url.Parse("http://boot.example:" + port + "/vmlinuz")
+
+	// IPv6 addresses are skipped with Family 4.
+	c, err := DialerClient(nil, DialOptions{Family: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = ResolverClient(c, &StaticResolver{Hosts: map[string][]string{"boot.example": {"::1", "127.0.0.1"}}})
+	r, err := NewHTTPClient(c).FetchWithoutCache(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(r); err != nil || string(b) != "vmlinuz" {
+		t.Errorf("fetched %q, %v, want %q", b, err, "vmlinuz")
+	}
+}
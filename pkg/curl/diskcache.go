@@ -0,0 +1,382 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrDigestMismatch is returned when a fetched file does not have the digest
+// it should have.
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// DiskCache is a cache of fetched files in a directory, which outlives the
+// process, e.g. across boots or wget invocations.
+//
+// Files are stored by the SHA-256 digest of their content, and looked up by
+// their URL, or by their digest if it is known. The file at a URL is assumed
+// not to change: files that do should be given a digest, or a new URL.
+type DiskCache struct {
+	// Dir is the directory of the cache. It is created if needed.
+	Dir string
+
+	// MaxSize, if not 0, is how many bytes of files the cache holds at
+	// most. The least recently used files are removed to stay under it.
+	MaxSize int64
+
+	// Digest, if not nil, returns the hex SHA-256 digest the file at u
+	// must have, or "" if it is not known.
+	Digest func(u *url.URL) string
+}
+
+// The layout of Dir.
+const (
+	// objectsDir holds the files, named by their digest.
+	objectsDir = "objects"
+
+	// urlsDir holds the digest of the file of each URL, named by the
+	// digest of the URL.
+	urlsDir = "urls"
+
+	// tmpDir holds files being fetched, to be renamed into objectsDir.
+	tmpDir = "tmp"
+)
+
+// validDigest returns whether d is a hex SHA-256 digest, which is safe to use
+// as a file name.
+func validDigest(d string) bool {
+	if len(d) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(d)
+	return err == nil
+}
+
+// urlKey returns the name of the index entry of u.
+func urlKey(u *url.URL) string {
+	h := sha256.Sum256([]byte(u.String()))
+	return hex.EncodeToString(h[:])
+}
+
+// digest returns the digest the file at u must have, or "".
+func (c *DiskCache) digest(u *url.URL) (string, error) {
+	if c.Digest == nil {
+		return "", nil
+	}
+	d := strings.ToLower(c.Digest(u))
+	if d != "" && !validDigest(d) {
+		return "", fmt.Errorf("invalid SHA-256 digest %q", d)
+	}
+	return d, nil
+}
+
+// Open returns the cached file of u, or an error satisfying
+// errors.Is(err, os.ErrNotExist) if it is not cached. The file is verified
+// against its digest, and removed from the cache if it is corrupt.
+func (c *DiskCache) Open(u *url.URL) (*os.File, error) {
+	d, err := c.digest(u)
+	if err != nil {
+		return nil, err
+	}
+	if d == "" {
+		b, err := os.ReadFile(filepath.Join(c.Dir, urlsDir, urlKey(u)))
+		if err != nil {
+			return nil, err
+		}
+		if d = string(b); !validDigest(d) {
+			return nil, fmt.Errorf("%w: invalid cache entry for %v", os.ErrNotExist, u.Redacted())
+		}
+	}
+	return c.openObject(d)
+}
+
+// openObject opens the file with digest d, and checks it has it.
+func (c *DiskCache) openObject(d string) (*os.File, error) {
+	name := filepath.Join(c.Dir, objectsDir, d)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != d {
+		f.Close()
+		trace.Trace("corrupt cache file", "file", name, "sha256", got)
+		os.Remove(name)
+		return nil, fmt.Errorf("%w: corrupt cache file %s", os.ErrNotExist, name)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// The modification time is the last use, for eviction.
+	now := time.Now()
+	os.Chtimes(name, now, now)
+	return f, nil
+}
+
+// store moves the fetched file tmp, with digest d, into the cache as the
+// file of the URL with key.
+func (c *DiskCache) store(tmp, d, key string) error {
+	for _, dir := range []string{objectsDir, urlsDir} {
+		if err := os.MkdirAll(filepath.Join(c.Dir, dir), 0o755); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, filepath.Join(c.Dir, objectsDir, d)); err != nil {
+		return err
+	}
+	// The entry is written whole, then renamed, for concurrent readers.
+	f, err := os.CreateTemp(filepath.Join(c.Dir, tmpDir), "url")
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(d)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.Dir, urlsDir, key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return c.evict(d)
+}
+
+// evict removes the least recently used files until the cache holds at most
+// MaxSize bytes, but not the file with digest keep.
+func (c *DiskCache) evict(keep string) error {
+	if c.MaxSize <= 0 {
+		return nil
+	}
+	dir := filepath.Join(c.Dir, objectsDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var (
+		files []os.FileInfo
+		total int64
+	)
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, fi)
+		total += fi.Size()
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, fi := range files {
+		if total <= c.MaxSize {
+			break
+		}
+		if fi.Name() == keep {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, fi.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		trace.Trace("cache evict", "sha256", fi.Name(), "size", fi.Size())
+		total -= fi.Size()
+	}
+	return nil
+}
+
+// diskCacheReader reads a file being fetched, and stores it in the cache once
+// it is read whole.
+type diskCacheReader struct {
+	r    io.Reader
+	c    *DiskCache
+	key  string
+	want string
+	h    hash.Hash
+
+	// tmp is the file being written, or nil if caching failed.
+	tmp *os.File
+
+	// stored is whether the file made it into the cache.
+	stored bool
+	err    error
+}
+
+// newReader returns a reader of r, the file at u, which stores it in
+// c as it is read.
+func (c *DiskCache) newReader(r io.Reader, u *url.URL, want string) *diskCacheReader {
+	dr := &diskCacheReader{r: r, c: c, key: urlKey(u), want: want, h: sha256.New()}
+	dir := filepath.Join(c.Dir, tmpDir)
+	err := os.MkdirAll(dir, 0o755)
+	if err == nil {
+		dr.tmp, err = os.CreateTemp(dir, "fetch")
+	}
+	if err != nil {
+		trace.Trace("cache failed", "url", u, "err", err)
+	}
+	return dr
+}
+
+// Read implements io.Reader. At the end of a file that does not have its
+// digest, it returns ErrDigestMismatch instead of io.EOF.
+func (r *diskCacheReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	if r.tmp != nil {
+		if _, werr := r.tmp.Write(p[:n]); werr != nil {
+			trace.Trace("cache failed", "err", werr)
+			r.discard()
+		}
+	}
+	if err == io.EOF {
+		if ferr := r.finish(); ferr != nil {
+			err = ferr
+		}
+	}
+	if err != nil {
+		r.err = err
+		r.discard()
+	}
+	return n, err
+}
+
+// finish checks the digest of the file, and stores it.
+func (r *diskCacheReader) finish() error {
+	d := hex.EncodeToString(r.h.Sum(nil))
+	if r.want != "" && d != r.want {
+		return fmt.Errorf("%w: got sha256 %s, want %s", ErrDigestMismatch, d, r.want)
+	}
+	if r.tmp == nil {
+		return nil
+	}
+	name := r.tmp.Name()
+	err := r.tmp.Close()
+	r.tmp = nil
+	if err == nil {
+		err = r.c.store(name, d, r.key)
+	}
+	if err != nil {
+		trace.Trace("cache failed", "err", err)
+		os.Remove(name)
+		return nil
+	}
+	r.stored = true
+	return nil
+}
+
+// discard stops caching the file.
+func (r *diskCacheReader) discard() {
+	if r.tmp != nil {
+		r.tmp.Close()
+		os.Remove(r.tmp.Name())
+		r.tmp = nil
+	}
+}
+
+// Size returns the size of the file, if known.
+func (r *diskCacheReader) Size() (int64, error) {
+	if size := sizeOf(r.r); size >= 0 {
+		return size, nil
+	}
+	return 0, errors.New("unknown size")
+}
+
+// Close implements io.Closer.
+func (r *diskCacheReader) Close() error {
+	r.discard()
+	if c, ok := r.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// SchemeWithDiskCache wraps a FileScheme, and keeps the files it fetches in
+// a DiskCache.
+type SchemeWithDiskCache struct {
+	Scheme FileScheme
+	Cache  *DiskCache
+}
+
+// Fetch implements FileScheme.Fetch for disk cache wrapper.
+//
+// The file is fetched whole into the cache, and read from there, rather than
+// from memory.
+func (s *SchemeWithDiskCache) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	want, err := s.Cache.digest(u)
+	if err != nil {
+		return nil, err
+	}
+	if f, err := s.Cache.Open(u); err == nil {
+		trace.Trace("cache hit", "url", u)
+		return f, nil
+	}
+	r, err := s.Scheme.FetchWithoutCache(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	dr := s.Cache.newReader(r, u, want)
+	_, err = io.Copy(io.Discard, dr)
+	dr.Close()
+	if err != nil {
+		return nil, err
+	}
+	if dr.stored {
+		if f, err := s.Cache.Open(u); err == nil {
+			return f, nil
+		}
+	}
+	// The cache is full, read-only or gone: fetch it again, into memory.
+	return s.Scheme.Fetch(ctx, u)
+}
+
+// FetchWithoutCache implements FileScheme.FetchWithoutCache for disk cache
+// wrapper.
+//
+// The file is stored in the cache as it is read.
+func (s *SchemeWithDiskCache) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	want, err := s.Cache.digest(u)
+	if err != nil {
+		return nil, err
+	}
+	if f, err := s.Cache.Open(u); err == nil {
+		trace.Trace("cache hit", "url", u)
+		return f, nil
+	}
+	r, err := s.Scheme.FetchWithoutCache(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return s.Cache.newReader(r, u, want), nil
+}
+
+// WithDiskCache returns schemes that keep the files fetched by the schemes of
+// s in c.
+func (s Schemes) WithDiskCache(c *DiskCache) Schemes {
+	r := make(Schemes, len(s))
+	for scheme, fs := range s {
+		r[scheme] = &SchemeWithDiskCache{Scheme: fs, Cache: c}
+	}
+	return r
+}
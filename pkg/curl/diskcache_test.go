@@ -0,0 +1,143 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func TestDiskCache(t *testing.T) {
+	kernel := strings.Repeat("kernel ", 10)
+	for _, tt := range []struct {
+		name string
+		// fetch reads the file with Fetch or FetchWithoutCache.
+		fetch   func(fs FileScheme, u *url.URL) (string, error)
+		digest  string
+		wantErr error
+	}{
+		{name: "fetch", fetch: fetchAll},
+		{name: "fetch without cache", fetch: fetchWithoutCacheAll},
+		{name: "digest", fetch: fetchAll, digest: sha256Hex(kernel)},
+		{name: "wrong digest", fetch: fetchWithoutCacheAll, digest: sha256Hex("initrd"), wantErr: ErrDigestMismatch},
+		{name: "fetch with wrong digest", fetch: fetchAll, digest: sha256Hex("initrd"), wantErr: ErrDigestMismatch},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMockScheme("http")
+			m.Add("boot", "/vmlinuz", kernel)
+			c := &DiskCache{Dir: t.TempDir()}
+			if tt.digest != "" {
+				c.Digest = func(*url.URL) string { return strings.ToUpper(tt.digest) }
+			}
+			fs := &SchemeWithDiskCache{Scheme: m, Cache: c}
+			u := &url.URL{Scheme: "http", Host: "boot", Path: "/vmlinuz"}
+
+			for i := 0; i < 2; i++ {
+				got, err := tt.fetch(fs, u)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("fetch %d = %v, want %v", i, err, tt.wantErr)
+				}
+				if err == nil && got != kernel {
+					t.Errorf("fetch %d = %q, want %q", i, got, kernel)
+				}
+			}
+			wantCalls := uint(1)
+			if tt.wantErr != nil {
+				wantCalls = 2
+			}
+			if n := m.NumCalled(u); n != wantCalls {
+				t.Errorf("fetched %d times, want %d", n, wantCalls)
+			}
+		})
+	}
+}
+
+func fetchAll(fs FileScheme, u *url.URL) (string, error) {
+	r, err := fs.Fetch(context.Background(), u)
+	if err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(io.NewSectionReader(r, 0, 1<<20))
+	return string(b), err
+}
+
+func fetchWithoutCacheAll(fs FileScheme, u *url.URL) (string, error) {
+	r, err := fs.FetchWithoutCache(context.Background(), u)
+	if err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(r)
+	return string(b), err
+}
+
+func TestDiskCacheCorrupt(t *testing.T) {
+	m := NewMockScheme("http")
+	m.Add("boot", "/vmlinuz", "kernel")
+	c := &DiskCache{Dir: t.TempDir()}
+	fs := &SchemeWithDiskCache{Scheme: m, Cache: c}
+	u := &url.URL{Scheme: "http", Host: "boot", Path: "/vmlinuz"}
+
+	if _, err := fetchWithoutCacheAll(fs, u); err != nil {
+		t.Fatal(err)
+	}
+	obj := filepath.Join(c.Dir, objectsDir, sha256Hex("kernel"))
+	if err := os.WriteFile(obj, []byte("kernal"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Open(u); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Open of corrupt file = %v, want %v", err, os.ErrNotExist)
+	}
+	got, err := fetchAll(fs, u)
+	if err != nil || got != "kernel" {
+		t.Errorf("fetch = %q, %v, want %q", got, err, "kernel")
+	}
+	if n := m.NumCalled(u); n != 2 {
+		t.Errorf("fetched %d times, want 2", n)
+	}
+}
+
+func TestDiskCacheEvict(t *testing.T) {
+	m := NewMockScheme("http")
+	c := &DiskCache{Dir: t.TempDir(), MaxSize: 10}
+	fs := &SchemeWithDiskCache{Scheme: m, Cache: c}
+	files := []string{"aaaa", "bbbb", "cccc"}
+	var urls []*url.URL
+	for i, f := range files {
+		u := &url.URL{Scheme: "http", Host: "boot", Path: "/" + f}
+		m.Add("boot", u.Path, f)
+		urls = append(urls, u)
+		if i == 2 {
+			// Make the second file the least recently used.
+			old := time.Now().Add(-time.Hour)
+			os.Chtimes(filepath.Join(c.Dir, objectsDir, sha256Hex(files[1])), old, old)
+		}
+		if _, err := fetchAll(fs, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, want := range []bool{true, false, true} {
+		f, err := c.Open(urls[i])
+		if got := err == nil; got != want {
+			t.Errorf("%s cached = %t (%v), want %t", files[i], got, err, want)
+		}
+		if f != nil {
+			f.Close()
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// FTPClient implements FileScheme for FTP files, in passive mode. Without a
+// user in the URL, it logs in as anonymous. As in RFC 1738, the path is
+// relative to the directory the user logs in to, and ftp://host/%2Fpub/file
+// is the absolute path /pub/file.
+type FTPClient struct{}
+
+// ftpFile is a file being downloaded from an FTP server.
+type ftpFile struct {
+	data net.Conn
+	ctrl *textproto.Conn
+	size int64
+	err  error
+	once sync.Once
+	done chan struct{}
+}
+
+// Read implements io.Reader. It returns the error of the server, if the
+// transfer failed, instead of io.EOF.
+func (f *ftpFile) Read(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	n, err := f.data.Read(p)
+	if err == io.EOF {
+		// The server says whether the transfer completed.
+		f.data.Close()
+		if _, _, rerr := f.ctrl.ReadResponse(2); rerr != nil {
+			err = rerr
+		}
+		f.err = err
+		f.Close()
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (f *ftpFile) Close() error {
+	f.once.Do(func() {
+		close(f.done)
+		f.data.Close()
+		f.ctrl.Cmd("QUIT")
+		f.ctrl.Close()
+	})
+	return nil
+}
+
+// Size returns the size of the file, if the server told it.
+func (f *ftpFile) Size() (int64, error) {
+	if f.size < 0 {
+		return 0, errors.New("unknown size")
+	}
+	return f.size, nil
+}
+
+// ftpCmd sends a command, and reads its response, which must have code
+// expectCode as in textproto.Conn.ReadResponse.
+func ftpCmd(c *textproto.Conn, expectCode int, format string, args ...interface{}) (int, string, error) {
+	id, err := c.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	c.StartResponse(id)
+	defer c.EndResponse(id)
+	return c.ReadResponse(expectCode)
+}
+
+// ftpLogin logs in with the user and password of u, or as anonymous.
+func ftpLogin(c *textproto.Conn, u *url.URL) error {
+	user, pass := "anonymous", "anonymous@"
+	if u.User != nil {
+		user = u.User.Username()
+		pass, _ = u.User.Password()
+	}
+	code, _, err := ftpCmd(c, 0, "USER %s", user)
+	if err != nil {
+		return err
+	}
+	switch code {
+	case 230:
+		return nil
+	case 331:
+		_, _, err = ftpCmd(c, 230, "PASS %s", pass)
+		return err
+	}
+	return &textproto.Error{Code: code, Msg: "USER failed"}
+}
+
+// ftpPassive opens a passive data connection to host, the server.
+func ftpPassive(ctx context.Context, c *textproto.Conn, host string) (net.Conn, error) {
+	var port string
+	_, msg, err := ftpCmd(c, 229, "EPSV")
+	if err == nil {
+		// Entering Extended Passive Mode (|||PORT|)
+		i, j := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if i < 0 || j < i+4 {
+			return nil, fmt.Errorf("invalid EPSV response %q", msg)
+		}
+		port = msg[i+4 : j]
+	} else {
+		_, msg, err := ftpCmd(c, 227, "PASV")
+		if err != nil {
+			return nil, err
+		}
+		// Entering Passive Mode (H1,H2,H3,H4,P1,P2). Like curl, only
+		// the port is used, and the address is the server's.
+		i, j := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+		if i < 0 || j < i {
+			return nil, fmt.Errorf("invalid PASV response %q", msg)
+		}
+		f := strings.Split(msg[i+1:j], ",")
+		if len(f) != 6 {
+			return nil, fmt.Errorf("invalid PASV response %q", msg)
+		}
+		p1, err1 := strconv.Atoi(f[4])
+		p2, err2 := strconv.Atoi(f[5])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid PASV response %q", msg)
+		}
+		port = strconv.Itoa(p1<<8 | p2)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+}
+
+func ftpFetch(ctx context.Context, u *url.URL) (io.Reader, error) {
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "21"
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	c := textproto.NewConn(conn)
+	setup := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-setup:
+		}
+	}()
+	fail := func(err error) (io.Reader, error) {
+		close(setup)
+		c.Close()
+		return nil, err
+	}
+
+	if _, _, err := c.ReadResponse(220); err != nil {
+		return fail(err)
+	}
+	if err := ftpLogin(c, u); err != nil {
+		return fail(err)
+	}
+	if _, _, err := ftpCmd(c, 200, "TYPE I"); err != nil {
+		return fail(err)
+	}
+	name := strings.TrimPrefix(u.Path, "/")
+	size := int64(-1)
+	if _, msg, err := ftpCmd(c, 213, "SIZE %s", name); err == nil {
+		if n, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64); err == nil {
+			size = n
+		}
+	}
+	data, err := ftpPassive(ctx, c, host)
+	if err != nil {
+		return fail(err)
+	}
+	if _, _, err := ftpCmd(c, 1, "RETR %s", name); err != nil {
+		data.Close()
+		return fail(err)
+	}
+	close(setup)
+
+	f := &ftpFile{data: data, ctrl: c, size: size, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+			data.Close()
+		case <-f.done:
+		}
+	}()
+	return f, nil
+}
+
+// Fetch implements FileScheme.Fetch for FTP.
+func (FTPClient) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	r, err := ftpFetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return uio.NewCachingReader(r), nil
+}
+
+// FetchWithoutCache implements FileScheme.FetchWithoutCache for FTP.
+func (FTPClient) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	return ftpFetch(ctx, u)
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// ftpServer serves files to users with passwords, and to anonymous if it has
+// no password. Without epsv, it only does PASV.
+type ftpServer struct {
+	l     net.Listener
+	files map[string]string
+	users map[string]string
+	epsv  bool
+}
+
+func newFTPServer(t *testing.T, epsv bool) *ftpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &ftpServer{
+		l:     l,
+		files: map[string]string{"pub/bios.bin": "firmware", "/srv/bios.bin": "absolute firmware"},
+		users: map[string]string{"anonymous": "", "admin": "secret"},
+		epsv:  epsv,
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *ftpServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(c, format+"\r\n", args...)
+	}
+	reply("220 ready")
+	var (
+		user   string
+		authed bool
+		pasv   net.Listener
+	)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch {
+		case cmd == "USER":
+			user = arg
+			if s.users[user] == "" {
+				authed = true
+				reply("230 logged in")
+			} else {
+				reply("331 password please")
+			}
+		case cmd == "PASS":
+			if p, ok := s.users[user]; ok && p == arg {
+				authed = true
+				reply("230 logged in")
+			} else {
+				reply("530 login incorrect")
+			}
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		case !authed:
+			reply("530 not logged in")
+		case cmd == "TYPE":
+			reply("200 type set")
+		case cmd == "SIZE":
+			if f, ok := s.files[arg]; ok {
+				reply("213 %d", len(f))
+			} else {
+				reply("550 no such file")
+			}
+		case cmd == "EPSV" || cmd == "PASV":
+			if cmd == "EPSV" && !s.epsv {
+				reply("500 unknown command")
+				continue
+			}
+			if pasv, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply("425 cannot listen")
+				continue
+			}
+			port := pasv.Addr().(*net.TCPAddr).Port
+			if cmd == "EPSV" {
+				reply("229 Entering Extended Passive Mode (|||%d|)", port)
+			} else {
+				// The address is bogus, as behind NAT.
+				reply("227 Entering Passive Mode (192,168,0,1,%d,%d)", port>>8, port&0xff)
+			}
+		case cmd == "RETR":
+			f, ok := s.files[arg]
+			if !ok {
+				reply("550 no such file")
+				continue
+			}
+			reply("150 opening data connection")
+			dc, err := pasv.Accept()
+			pasv.Close()
+			if err != nil {
+				reply("425 no data connection")
+				continue
+			}
+			io.WriteString(dc, f)
+			dc.Close()
+			reply("226 transfer complete")
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestFTPClient(t *testing.T) {
+	for _, epsv := range []bool{true, false} {
+		s := newFTPServer(t, epsv)
+		for _, tt := range []struct {
+			url  string
+			want string
+			code int
+		}{
+			{url: "ftp://%s/pub/bios.bin", want: "firmware"},
+			{url: "ftp://admin:secret@%s/pub/bios.bin", want: "firmware"},
+			{url: "ftp://%s/%%2Fsrv/bios.bin", want: "absolute firmware"},
+			{url: "ftp://admin:wrong@%s/pub/bios.bin", code: 530},
+			{url: "ftp://%s/pub/missing.bin", code: 550},
+		} {
+			u, err := url.Parse(fmt.Sprintf(tt.url, s.l.Addr()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := FTPClient{}.FetchWithoutCache(context.Background(), u)
+			if tt.code != 0 {
+				if terr, ok := err.(*textproto.Error); !ok || terr.Code != tt.code {
+					t.Errorf("fetch %v (EPSV %t) = %v, want code %d", u, epsv, err, tt.code)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("fetch %v (EPSV %t) = %v", u, epsv, err)
+				continue
+			}
+			if size := sizeOf(r); size != int64(len(tt.want)) {
+				t.Errorf("size of %v = %d, want %d", u, size, len(tt.want))
+			}
+			b, err := io.ReadAll(r)
+			if err != nil || string(b) != tt.want {
+				t.Errorf("fetch %v (EPSV %t) = %q, %v, want %q", u, epsv, b, err, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// MirrorError is returned when a file could not be fetched from any mirror.
+type MirrorError struct {
+	// Errs are the errors of the mirrors, in the order they failed.
+	Errs []error
+}
+
+// Error implements error.
+func (m *MirrorError) Error() string {
+	if len(m.Errs) == 0 {
+		return "no mirrors"
+	}
+	s := make([]string, len(m.Errs))
+	for i, err := range m.Errs {
+		s[i] = err.Error()
+	}
+	return "all mirrors failed: " + strings.Join(s, "; ")
+}
+
+// Is implements errors.Is, for the errors of all mirrors.
+func (m *MirrorError) Is(target error) bool {
+	for _, err := range m.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As implements errors.As, for the errors of all mirrors.
+func (m *MirrorError) As(target interface{}) bool {
+	for _, err := range m.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// MirrorScheme is a FileScheme that fetches the path of a URL from the first
+// of several mirrors that has it, e.g. registered as "mirror", so that
+// mirror:///boot/vmlinuz is fetched from the first of
+// http://10.0.0.1/images/boot/vmlinuz and http://10.0.0.2/boot/vmlinuz that
+// works.
+type MirrorScheme struct {
+	// Schemes fetch from the mirrors.
+	Schemes Schemes
+
+	// Mirrors are the base URLs of the mirrors, tried in order unless
+	// Weights are set.
+	Mirrors []*url.URL
+
+	// Weights, if set, are the weights of Mirrors. They are tried in a
+	// random order, and a mirror of twice the weight of another is twice
+	// as likely to be tried first. Mirrors with weight 0 are tried last.
+	Weights []int
+
+	// Race is how many mirrors are tried at the same time. The first
+	// that succeeds is used, and the next mirror is tried when one fails.
+	// 0 is the same as 1: mirrors are tried one after another.
+	Race int
+
+	// rand returns a random number in [0, n), for tests.
+	rand func(n int) int
+}
+
+// order returns the indexes of Mirrors in the order they are tried.
+func (m *MirrorScheme) order() []int {
+	order := make([]int, len(m.Mirrors))
+	for i := range order {
+		order[i] = i
+	}
+	if len(m.Weights) != len(m.Mirrors) {
+		return order
+	}
+	rnd := m.rand
+	if rnd == nil {
+		rnd = rand.Intn
+	}
+	// Pick mirrors one by one, each with a chance of its weight among
+	// the weights of the others left.
+	for i := range order {
+		total := 0
+		for _, j := range order[i:] {
+			total += m.Weights[j]
+		}
+		if total <= 0 {
+			break
+		}
+		r := rnd(total)
+		for k, j := range order[i:] {
+			if r -= m.Weights[j]; r < 0 {
+				order[i], order[i+k] = order[i+k], order[i]
+				break
+			}
+		}
+	}
+	return order
+}
+
+// mirrorReader is the file fetched from a mirror. Closing it cancels the
+// fetch.
+type mirrorReader struct {
+	io.Reader
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer.
+func (r *mirrorReader) Close() error {
+	defer r.cancel()
+	if c, ok := r.Reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// fetch fetches the path of u from the mirrors, and returns the URL it was
+// fetched from.
+func (m *MirrorScheme) fetch(ctx context.Context, u *url.URL) (io.Reader, *url.URL, error) {
+	type result struct {
+		i   int
+		u   *url.URL
+		r   io.Reader
+		err error
+	}
+	order := m.order()
+	// Buffered, so that fetches that lose the race do not block.
+	results := make(chan result, len(order))
+	cancels := make([]context.CancelFunc, len(order))
+	next, running := 0, 0
+	start := func() {
+		i := next
+		mu := m.Mirrors[order[i]].JoinPath(u.Path)
+		fctx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		next++
+		running++
+		go func() {
+			var (
+				r   io.Reader
+				err error
+			)
+			if fs, ok := m.Schemes[mu.Scheme]; !ok {
+				err = ErrNoSuchScheme
+			} else {
+				r, err = fs.FetchWithoutCache(fctx, mu)
+			}
+			results <- result{i, mu, r, err}
+		}()
+	}
+
+	race := m.Race
+	if race < 1 {
+		race = 1
+	}
+	for next < len(order) && running < race {
+		start()
+	}
+	var errs []error
+	for running > 0 {
+		res := <-results
+		running--
+		if res.err == nil {
+			// Stop the fetches that lost the race, and close what
+			// they fetched anyway.
+			for i, cancel := range cancels[:next] {
+				if i != res.i {
+					cancel()
+				}
+			}
+			go func(n int) {
+				for ; n > 0; n-- {
+					if c, ok := (<-results).r.(io.Closer); ok {
+						c.Close()
+					}
+				}
+			}(running)
+			return &mirrorReader{res.r, cancels[res.i]}, res.u, nil
+		}
+		cancels[res.i]()
+		trace.Trace("mirror failed", "url", res.u, "err", res.err)
+		errs = append(errs, fmt.Errorf("%v: %w", res.u, res.err))
+		if next < len(order) && ctx.Err() == nil {
+			start()
+		}
+	}
+	return nil, nil, &MirrorError{Errs: errs}
+}
+
+// Fetch implements FileScheme.Fetch for mirrors.
+func (m *MirrorScheme) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	r, _, err := m.fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return uio.NewCachingReader(r), nil
+}
+
+// FetchWithoutCache implements FileScheme.FetchWithoutCache for mirrors.
+func (m *MirrorScheme) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	r, _, err := m.fetch(ctx, u)
+	return r, err
+}
+
+// FetchFromMirrors fetches the file at path from the first of mirrors, base
+// URLs, that has it, and returns it with the URL it was fetched from. race is
+// how many mirrors are tried at the same time; see MirrorScheme.
+func (s Schemes) FetchFromMirrors(ctx context.Context, mirrors []*url.URL, path string, race int) (FileWithCache, error) {
+	m := &MirrorScheme{Schemes: s, Mirrors: mirrors, Race: race}
+	r, u, err := m.fetch(ctx, &url.URL{Path: path})
+	if err != nil {
+		return nil, err
+	}
+	return &cacheFile{ReaderAt: uio.NewCachingReader(r), url: u}, nil
+}
+
+// ParseMirrors parses a comma separated list of mirror base URLs, each
+// optionally with a weight, e.g.
+//
+//	http://10.0.0.1/images=3,http://10.0.0.2/images=1
+//
+// It returns nil weights if no mirror has one.
+func ParseMirrors(s string) (mirrors []*url.URL, weights []int, err error) {
+	weighted := false
+	for _, m := range strings.Split(s, ",") {
+		w := 1
+		if i := strings.LastIndex(m, "="); i >= 0 && !strings.Contains(m[i:], "/") {
+			var err error
+			if w, err = strconv.Atoi(m[i+1:]); err != nil || w < 0 {
+				return nil, nil, fmt.Errorf("mirror %q: invalid weight", m)
+			}
+			m, weighted = m[:i], true
+		}
+		u, err := url.Parse(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		if u.Scheme == "" {
+			return nil, nil, fmt.Errorf("mirror %q has no scheme", m)
+		}
+		mirrors = append(mirrors, u)
+		weights = append(weights, w)
+	}
+	if !weighted {
+		weights = nil
+	}
+	return mirrors, weights, nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mirror serves content at /images/vmlinuz after delay, or fails with code
+// if it is not 0, and counts its requests.
+type mirror struct {
+	*httptest.Server
+	requests int32
+}
+
+func newMirror(t *testing.T, code int, delay time.Duration, content string) *mirror {
+	m := &mirror{}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&m.requests, 1)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		if code != 0 {
+			w.WriteHeader(code)
+			return
+		}
+		if r.URL.Path != "/images/vmlinuz" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, content)
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mirror) base(t *testing.T) *url.URL {
+	u, err := url.Parse(m.URL + "/images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestMirrorScheme(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		// code, delay and content of each mirror.
+		codes  []int
+		delays []time.Duration
+		race   int
+		want   string
+		// requests is how many requests each mirror gets, or -1 if
+		// the fetch may be canceled before it is sent.
+		requests []int32
+		wantCode int
+	}{
+		{
+			name:     "first",
+			codes:    []int{0, 0},
+			race:     1,
+			want:     "mirror 0",
+			requests: []int32{1, 0},
+		},
+		{
+			name:     "failover",
+			codes:    []int{http.StatusServiceUnavailable, http.StatusNotFound, 0},
+			want:     "mirror 2",
+			requests: []int32{1, 1, 1},
+		},
+		{
+			name:     "all fail",
+			codes:    []int{http.StatusServiceUnavailable, http.StatusNotFound},
+			requests: []int32{1, 1},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "race",
+			codes:    []int{0, 0, 0},
+			delays:   []time.Duration{10 * time.Second, 0, 0},
+			race:     2,
+			want:     "mirror 1",
+			requests: []int32{-1, 1, 0},
+		},
+		{
+			name:     "race failover",
+			codes:    []int{0, http.StatusBadGateway, 0},
+			delays:   []time.Duration{10 * time.Second, 0, 0},
+			race:     2,
+			want:     "mirror 2",
+			requests: []int32{-1, 1, 1},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mirrors []*mirror
+				bases   []*url.URL
+			)
+			for i, code := range tt.codes {
+				var delay time.Duration
+				if tt.delays != nil {
+					delay = tt.delays[i]
+				}
+				m := newMirror(t, code, delay, "mirror "+string(rune('0'+i)))
+				mirrors = append(mirrors, m)
+				bases = append(bases, m.base(t))
+			}
+			schemes := Schemes{"http": DefaultHTTPClient}
+			schemes.Register("mirror", &MirrorScheme{Schemes: schemes, Mirrors: bases, Race: tt.race})
+
+			f, err := schemes.FetchWithoutCache(context.Background(), &url.URL{Scheme: "mirror", Path: "/vmlinuz"})
+			if tt.wantCode != 0 {
+				var herr *HTTPClientCodeError
+				if !errors.As(err, &herr) || herr.HTTPCode != tt.wantCode {
+					t.Fatalf("fetch = %v, want HTTP code %d", err, tt.wantCode)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := io.ReadAll(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != tt.want {
+					t.Errorf("fetched %q, want %q", b, tt.want)
+				}
+			}
+			for i, m := range mirrors {
+				if got := atomic.LoadInt32(&m.requests); tt.requests[i] >= 0 && got != tt.requests[i] {
+					t.Errorf("mirror %d got %d requests, want %d", i, got, tt.requests[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFetchFromMirrors(t *testing.T) {
+	bad := newMirror(t, http.StatusInternalServerError, 0, "")
+	good := newMirror(t, 0, 0, "kernel")
+	f, err := Schemes{"http": DefaultHTTPClient}.FetchFromMirrors(context.Background(), []*url.URL{bad.base(t), good.base(t)}, "vmlinuz", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := good.URL + "/images/vmlinuz"; f.URL().String() != want {
+		t.Errorf("fetched from %v, want %v", f.URL(), want)
+	}
+	b, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<20))
+	if err != nil || string(b) != "kernel" {
+		t.Errorf("fetched %q, %v, want %q", b, err, "kernel")
+	}
+
+	if _, err := (Schemes{}).FetchFromMirrors(context.Background(), []*url.URL{good.base(t)}, "vmlinuz", 1); !errors.Is(err, ErrNoSuchScheme) {
+		t.Errorf("fetch without schemes = %v, want %v", err, ErrNoSuchScheme)
+	}
+}
+
+func TestMirrorOrder(t *testing.T) {
+	mirrors := make([]*url.URL, 4)
+	for _, tt := range []struct {
+		name    string
+		weights []int
+		rands   []int
+		want    []int
+	}{
+		{name: "unweighted", want: []int{0, 1, 2, 3}},
+		// Of weights 1 2 3 4, 5 falls in 3. Of 2 1 4 left, 0 falls in
+		// 2. Of 1 4 left, 4 falls in 4.
+		{name: "weighted", weights: []int{1, 2, 3, 4}, rands: []int{5, 0, 4, 0}, want: []int{2, 1, 3, 0}},
+		{name: "zero weights last", weights: []int{0, 0, 5, 0}, rands: []int{3}, want: []int{2, 1, 0, 3}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MirrorScheme{Mirrors: mirrors, Weights: tt.weights, rand: func(n int) int {
+				r := tt.rands[0]
+				tt.rands = tt.rands[1:]
+				return r
+			}}
+			if got := m.order(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMirrors(t *testing.T) {
+	m, w, err := ParseMirrors("http://10.0.0.1/images=3,https://mirror/boot/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m[0].String() != "http://10.0.0.1/images" || m[1].String() != "https://mirror/boot/" {
+		t.Errorf("mirrors = %v", m)
+	}
+	if !reflect.DeepEqual(w, []int{3, 1}) {
+		t.Errorf("weights = %v, want [3 1]", w)
+	}
+	if _, w, err := ParseMirrors("http://a/,http://b/"); err != nil || w != nil {
+		t.Errorf("unweighted mirrors = %v, %v, want nil weights", w, err)
+	}
+	for _, bad := range []string{"http://a/=x", "http://a/=-1", "10.0.0.1/images"} {
+		if _, _, err := ParseMirrors(bad); err == nil {
+			t.Errorf("ParseMirrors(%q) = nil, want error", bad)
+		}
+	}
+}
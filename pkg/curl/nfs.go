@@ -0,0 +1,121 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/u-root/u-root/pkg/nfs"
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// NFSClient implements FileScheme for files on NFSv3 servers, e.g.
+// nfs://10.0.0.1/srv/boot/vmlinuz, without mounting them. The file is read
+// from the longest export of the server that has it, here /srv/boot.
+type NFSClient struct{}
+
+// splitExport splits p into the longest of exports that has it, and the path
+// in that export.
+func splitExport(exports []string, p string) (export, name string, ok bool) {
+	p = path.Clean("/" + p)
+	for _, e := range exports {
+		e = path.Clean(e)
+		if len(e) <= len(export) && ok {
+			continue
+		}
+		if e == "/" {
+			export, name, ok = e, p, true
+		} else if strings.HasPrefix(p, e+"/") {
+			export, name, ok = e, p[len(e):], true
+		}
+	}
+	return export, name, ok
+}
+
+// nfsFile is a file being read from an NFS server. It unmounts the export
+// when it is closed, read to the end, or when the context of the fetch is
+// done.
+type nfsFile struct {
+	io.Reader
+	c    *nfs.Client
+	size int64
+	once sync.Once
+	done chan struct{}
+}
+
+// Read implements io.Reader.
+func (f *nfsFile) Read(p []byte) (int, error) {
+	n, err := f.Reader.Read(p)
+	if err == io.EOF {
+		f.Close()
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (f *nfsFile) Close() error {
+	f.once.Do(func() { close(f.done) })
+	return f.c.Close()
+}
+
+// Size returns the size of the file.
+func (f *nfsFile) Size() (int64, error) {
+	return f.size, nil
+}
+
+func nfsFetch(ctx context.Context, u *url.URL) (io.Reader, error) {
+	exports, err := nfs.Exports(ctx, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	export, name, ok := splitExport(exports, u.Path)
+	if !ok {
+		return nil, fmt.Errorf("%s is in no export of %s: %w", u.Path, u.Host, os.ErrNotExist)
+	}
+	c, err := nfs.Mount(ctx, u.Host, export)
+	if err != nil {
+		return nil, err
+	}
+	nf, err := c.Open(name)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	f := &nfsFile{
+		Reader: io.NewSectionReader(nf, 0, nf.Size()),
+		c:      c,
+		size:   nf.Size(),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-f.done:
+		}
+	}()
+	return f, nil
+}
+
+// Fetch implements FileScheme.Fetch for NFS.
+func (NFSClient) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	r, err := nfsFetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return uio.NewCachingReader(r), nil
+}
+
+// FetchWithoutCache implements FileScheme.FetchWithoutCache for NFS.
+func (NFSClient) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	return nfsFetch(ctx, u)
+}
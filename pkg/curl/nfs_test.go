@@ -0,0 +1,31 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import "testing"
+
+func TestSplitExport(t *testing.T) {
+	exports := []string{"/srv", "/srv/boot/", "/home"}
+	for _, tt := range []struct {
+		exports    []string
+		path       string
+		export     string
+		name       string
+		wantExport bool
+	}{
+		{exports, "/srv/boot/images/vmlinuz", "/srv/boot", "/images/vmlinuz", true},
+		{exports, "/srv/other/vmlinuz", "/srv", "/other/vmlinuz", true},
+		{exports, "/srv/boot/../initrd", "/srv", "/initrd", true},
+		{exports, "/srvboot/vmlinuz", "", "", false},
+		{exports, "/srv", "", "", false},
+		{[]string{"/", "/srv"}, "/srv/vmlinuz", "/srv", "/vmlinuz", true},
+		{[]string{"/"}, "/boot/vmlinuz", "/", "/boot/vmlinuz", true},
+	} {
+		export, name, ok := splitExport(tt.exports, tt.path)
+		if export != tt.export || name != tt.name || ok != tt.wantExport {
+			t.Errorf("splitExport(%v, %q) = %q, %q, %t, want %q, %q, %t", tt.exports, tt.path, export, name, ok, tt.export, tt.name, tt.wantExport)
+		}
+	}
+}
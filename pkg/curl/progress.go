@@ -0,0 +1,197 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// ProgressFunc is called as a file is read, with the size of the file, or -1
+// if it is not known, and the number of bytes read so far.
+//
+// When the whole file was read, it is called one last time with transferred
+// equal to total, even if the size was not known.
+type ProgressFunc func(total, transferred int64)
+
+// NewProgressReader returns a reader of r that calls fn after every read,
+// with total as the size of r, or -1 if it is not known.
+//
+// The reader closes r when closed, if r is an io.Closer.
+func NewProgressReader(r io.Reader, total int64, fn ProgressFunc) io.Reader {
+	return uio.NewCountingReader(r, total, 0, func(t uio.Transfer) {
+		if t.Done {
+			fn(t.N, t.N)
+		} else {
+			fn(t.Total, t.N)
+		}
+	})
+}
+
+// sizeOf returns the size of the file being fetched by r, or -1 if it is not
+// known.
+func sizeOf(r io.Reader) int64 {
+	switch r := r.(type) {
+	case *os.File:
+		if fi, err := r.Stat(); err == nil && fi.Mode().IsRegular() {
+			return fi.Size()
+		}
+	case interface{ Size() (int64, error) }:
+		// TFTP responses, if the server sent the transfer size, and
+		// NFS files.
+		if size, err := r.Size(); err == nil {
+			return size
+		}
+	case *httpBody:
+		return r.size
+	case *mirrorReader:
+		return sizeOf(r.Reader)
+	}
+	return -1
+}
+
+// httpBody is the body of an HTTP response, with its Content-Length.
+type httpBody struct {
+	io.ReadCloser
+	size int64
+}
+
+// SchemeWithProgress wraps a FileScheme and reports the progress of the
+// files it fetches.
+type SchemeWithProgress struct {
+	Scheme FileScheme
+
+	// Progress returns the function to call as the file at u is
+	// fetched, or nil not to report its progress.
+	Progress func(u *url.URL) ProgressFunc
+}
+
+// Fetch implements FileScheme.Fetch for progress wrapper.
+//
+// The file is fetched as it is read, so progress is reported as it is
+// read.
+func (s *SchemeWithProgress) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	fn := s.Progress(u)
+	if fn == nil {
+		return s.Scheme.Fetch(ctx, u)
+	}
+	r, err := s.Scheme.FetchWithoutCache(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return uio.NewCachingReader(NewProgressReader(r, sizeOf(r), fn)), nil
+}
+
+// FetchWithoutCache implements FileScheme.FetchWithoutCache for progress
+// wrapper.
+func (s *SchemeWithProgress) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	fn := s.Progress(u)
+	r, err := s.Scheme.FetchWithoutCache(ctx, u)
+	if err != nil || fn == nil {
+		return r, err
+	}
+	return NewProgressReader(r, sizeOf(r), fn), nil
+}
+
+// WithProgress returns schemes that report the progress of the files fetched
+// by the schemes of s.
+func (s Schemes) WithProgress(progress func(u *url.URL) ProgressFunc) Schemes {
+	r := make(Schemes, len(s))
+	for scheme, fs := range s {
+		r[scheme] = &SchemeWithProgress{Scheme: fs, Progress: progress}
+	}
+	return r
+}
+
+// progressInterval is how often TextProgress prints.
+const progressInterval = 500 * time.Millisecond
+
+// TextProgress returns a ProgressFunc that prints the progress of the file
+// name to w, e.g.
+//
+//	vmlinuz: 45% 12 MiB of 27 MiB, 4.5 MiB/s, ETA 3s
+//
+// on the same line at most twice a second, and a newline once it is done.
+func TextProgress(w io.Writer, name string) ProgressFunc {
+	return textProgress(w, name, time.Now)
+}
+
+func textProgress(w io.Writer, name string, now func() time.Time) ProgressFunc {
+	var (
+		mu      sync.Mutex
+		start   = now()
+		printed time.Time
+		done    bool
+	)
+	return func(total, transferred int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return
+		}
+		t := now()
+		done = total == transferred
+		if !done && t.Sub(printed) < progressInterval {
+			return
+		}
+		printed = t
+
+		var rate string
+		if d := t.Sub(start).Seconds(); d > 0 {
+			bps := float64(transferred) / d
+			rate = fmt.Sprintf(", %s/s", humanize.IBytes(uint64(bps)))
+			if !done && total > 0 && bps > 0 {
+				eta := time.Duration(float64(total-transferred) / bps * float64(time.Second))
+				rate += fmt.Sprintf(", ETA %v", eta.Round(time.Second))
+			}
+		}
+		// Erase the line, in case it gets shorter.
+		fmt.Fprint(w, "\033[2K\r")
+		if total > 0 {
+			fmt.Fprintf(w, "%s: %d%% %s of %s%s", name, transferred*100/total, humanize.IBytes(uint64(transferred)), humanize.IBytes(uint64(total)), rate)
+		} else {
+			fmt.Fprintf(w, "%s: %s%s", name, humanize.IBytes(uint64(transferred)), rate)
+		}
+		if done {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// SummaryProgress returns a ProgressFunc that prints one line to w once the
+// file name was read, e.g.
+//
+//	vmlinuz: 27 MiB in 6s, 4.5 MiB/s
+func SummaryProgress(w io.Writer, name string) ProgressFunc {
+	return summaryProgress(w, name, time.Now)
+}
+
+func summaryProgress(w io.Writer, name string, now func() time.Time) ProgressFunc {
+	var (
+		once  sync.Once
+		start = now()
+	)
+	return func(total, transferred int64) {
+		if total != transferred {
+			return
+		}
+		once.Do(func() {
+			d := now().Sub(start)
+			var rate string
+			if d > 0 {
+				rate = fmt.Sprintf(", %s/s", humanize.IBytes(uint64(float64(transferred)/d.Seconds())))
+			}
+			fmt.Fprintf(w, "%s: %s in %v%s\n", name, humanize.IBytes(uint64(transferred)), d.Round(time.Millisecond), rate)
+		})
+	}
+}
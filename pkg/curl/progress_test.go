@@ -0,0 +1,175 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+type progress struct {
+	total, transferred int64
+}
+
+func TestProgressReader(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		total int64
+		want  []progress
+	}{
+		{
+			name:  "known size",
+			total: 10,
+			want:  []progress{{10, 4}, {10, 8}, {10, 10}},
+		},
+		{
+			name:  "unknown size",
+			total: -1,
+			want:  []progress{{-1, 4}, {-1, 8}, {-1, 10}, {10, 10}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []progress
+			r := NewProgressReader(iotest.HalfReader(strings.NewReader("0123456789")), tt.total, func(total, transferred int64) {
+				got = append(got, progress{total, transferred})
+			})
+			b := make([]byte, 8)
+			var s []byte
+			for {
+				n, err := r.Read(b)
+				s = append(s, b[:n]...)
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(s) != "0123456789" {
+				t.Errorf("read %q, want %q", s, "0123456789")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("progress = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemeWithProgress(t *testing.T) {
+	content := strings.Repeat("kernel", 1000)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		w.Write([]byte(content))
+	}))
+	defer s.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "initramfs")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []string{s.URL + "/kernel", "file://" + path} {
+		t.Run(u, func(t *testing.T) {
+			var last progress
+			schemes := Schemes{"http": DefaultHTTPClient, "file": &LocalFileClient{}}.WithProgress(func(*url.URL) ProgressFunc {
+				return func(total, transferred int64) {
+					if total != int64(len(content)) {
+						t.Errorf("progress total = %d, want %d", total, len(content))
+					}
+					last = progress{total, transferred}
+				}
+			})
+			pu, err := url.Parse(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := schemes.Fetch(context.Background(), pu)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<20))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != content {
+				t.Errorf("fetched %d bytes, want %d", len(b), len(content))
+			}
+			if want := (progress{int64(len(content)), int64(len(content))}); last != want {
+				t.Errorf("last progress = %v, want %v", last, want)
+			}
+		})
+	}
+}
+
+func TestTextProgress(t *testing.T) {
+	now := time.Unix(1000, 0)
+	clock := func() time.Time { return now }
+	var b strings.Builder
+	p := textProgress(&b, "vmlinuz", clock)
+
+	const mib = 1 << 20
+	for _, tt := range []struct {
+		after              time.Duration
+		total, transferred int64
+		want               string
+	}{
+		{time.Second, 20 * mib, 2 * mib, "vmlinuz: 10% 2.0 MiB of 20 MiB, 2.0 MiB/s, ETA 9s"},
+		// Too soon to print again.
+		{100 * time.Millisecond, 20 * mib, 3 * mib, ""},
+		{time.Second, 20 * mib, 5 * mib, "vmlinuz: 25% 5.0 MiB of 20 MiB, 2.4 MiB/s, ETA 6s"},
+		// Done, printed however soon.
+		{0, 20 * mib, 20 * mib, "vmlinuz: 100% 20 MiB of 20 MiB, 9.5 MiB/s\n"},
+		{time.Second, 20 * mib, 20 * mib, ""},
+	} {
+		now = now.Add(tt.after)
+		b.Reset()
+		p(tt.total, tt.transferred)
+		want := tt.want
+		if want != "" {
+			want = "\033[2K\r" + want
+		}
+		if got := b.String(); got != want {
+			t.Errorf("progress(%d, %d) printed %q, want %q", tt.total, tt.transferred, got, want)
+		}
+	}
+
+	b.Reset()
+	textProgress(&b, "initrd", clock)(-1, 1500)
+	if got, want := b.String(), "\033[2K\rinitrd: 1.5 KiB"; got != want {
+		t.Errorf("progress of unknown size printed %q, want %q", got, want)
+	}
+}
+
+func TestSummaryProgress(t *testing.T) {
+	now := time.Unix(1000, 0)
+	clock := func() time.Time { return now }
+	var b strings.Builder
+	p := summaryProgress(&b, "vmlinuz", clock)
+
+	const mib = 1 << 20
+	now = now.Add(time.Second)
+	p(20*mib, 2*mib)
+	p(-1, 4*mib)
+	if b.Len() != 0 {
+		t.Errorf("progress before the end printed %q, want nothing", b.String())
+	}
+	now = now.Add(3 * time.Second)
+	p(20*mib, 20*mib)
+	p(20*mib, 20*mib)
+	if got, want := b.String(), "vmlinuz: 20 MiB in 4s, 5.0 MiB/s\n"; got != want {
+		t.Errorf("summary printed %q, want %q", got, want)
+	}
+}
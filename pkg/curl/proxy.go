@@ -0,0 +1,51 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ParseProxy parses the address of a proxy for HTTP fetches:
+//
+//	socks5://[USER:PASSWORD@]HOST:PORT
+//	socks5h://[USER:PASSWORD@]HOST:PORT
+//	http://[USER:PASSWORD@]HOST:PORT
+//
+// A bare HOST:PORT is a SOCKS5 proxy. Host names are always resolved by a
+// SOCKS5 proxy, so socks5 and socks5h are the same, and no DNS queries go
+// around a Tor proxy.
+func ParseProxy(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		s = "socks5://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "socks5h":
+		u.Scheme = "socks5"
+	case "socks5", "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		return nil, fmt.Errorf("proxy %q has no port", u.Redacted())
+	}
+	return u, nil
+}
+
+// ProxyClient returns an http.Client that makes all requests through proxy,
+// e.g. for NewHTTPClient. Without it, HTTPClients use the proxies in
+// $HTTP_PROXY, $HTTPS_PROXY and $NO_PROXY, as http.DefaultClient does.
+func ProxyClient(proxy *url.URL) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyURL(proxy)
+	return &http.Client{Transport: t}
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// socks5 serves one SOCKS5 CONNECT on l, with user name and password
+// authentication if user is set. It returns the address that was asked for.
+func socks5(t *testing.T, l net.Listener, user, password string) <-chan string {
+	addr := make(chan string, 1)
+	go func() {
+		defer close(addr)
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		b := make([]byte, 256)
+		read := func(n int) []byte {
+			if _, err := io.ReadFull(c, b[:n]); err != nil {
+				t.Errorf("socks5: %v", err)
+				return nil
+			}
+			return b[:n]
+		}
+
+		// Methods.
+		h := read(2)
+		if h == nil || read(int(h[1])) == nil {
+			return
+		}
+		if user == "" {
+			c.Write([]byte{5, 0})
+		} else {
+			c.Write([]byte{5, 2})
+			h := read(2)
+			if h == nil {
+				return
+			}
+			u := string(read(int(h[1])))
+			pl := read(1)
+			if pl == nil {
+				return
+			}
+			if p := string(read(int(pl[0]))); u != user || p != password {
+				c.Write([]byte{1, 1})
+				return
+			}
+			c.Write([]byte{1, 0})
+		}
+
+		// CONNECT.
+		req := read(4)
+		if req == nil {
+			return
+		}
+		var host string
+		switch req[3] {
+		case 1:
+			host = net.IP(append([]byte(nil), read(4)...)).String()
+		case 3:
+			host = string(read(int(read(1)[0])))
+		case 4:
+			host = net.IP(append([]byte(nil), read(16)...)).String()
+		}
+		port := binary.BigEndian.Uint16(read(2))
+		target := net.JoinHostPort(host, strconv.Itoa(int(port)))
+		addr <- target
+
+		if host == "artifacts.internal" {
+			host = "127.0.0.1"
+		}
+		d, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+		if err != nil {
+			c.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer d.Close()
+		c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		go io.Copy(d, c)
+		io.Copy(c, d)
+	}()
+	return addr
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "initrd")
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	for _, tt := range []struct {
+		name    string
+		user    string
+		proxy   string
+		wantErr bool
+	}{
+		{name: "no auth", proxy: "socks5://%s"},
+		{name: "auth", user: "boot", proxy: "socks5h://boot:secret@%s"},
+		{name: "bare", proxy: "%s"},
+		{name: "bad password", user: "boot", proxy: "socks5://boot:wrong@%s", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			addr := socks5(t, l, tt.user, "secret")
+
+			p, err := ParseProxy(fmt.Sprintf(tt.proxy, l.Addr().String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The proxy resolves the name.
+			u, _ := url.Parse("http://artifacts.internal:" + port + "/initrd")
+			r, err := NewHTTPClient(ProxyClient(p)).FetchWithoutCache(context.Background(), u)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("fetch through %s succeeded, want error", tt.proxy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fetch: %v", err)
+			}
+			if b, _ := io.ReadAll(r); string(b) != "initrd" {
+				t.Errorf("fetch = %q, want %q", b, "initrd")
+			}
+			if got, want := <-addr, "artifacts.internal:"+port; got != want {
+				t.Errorf("proxy was asked for %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "127.0.0.1:9050", want: "socks5://127.0.0.1:9050"},
+		{in: "socks5h://u:p@tor:9050", want: "socks5://u:p@tor:9050"},
+		{in: "http://proxy:3128", want: "http://proxy:3128"},
+		{in: "socks5://tor", err: true},
+		{in: "socks4://tor:1080", err: true},
+	} {
+		u, err := ParseProxy(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseProxy(%q) = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && u.String() != tt.want {
+			t.Errorf("ParseProxy(%q) = %q, want %q", tt.in, u, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// RateLimiter limits the rate at which files are read, e.g. not to starve
+// other traffic on a shared management network. It is a token bucket of
+// bytes, and all readers of one RateLimiter share its rate.
+type RateLimiter struct {
+	rate  float64
+	burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRateLimiter returns a RateLimiter of bytesPerSecond, which must be
+// positive.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	// A tenth of a second of bytes at a time smooths the rate out.
+	burst := int(bytesPerSecond / 10)
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: float64(burst),
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wait takes n bytes out of the bucket, and waits until they are paid for.
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if d == 0 {
+		return nil
+	}
+	return l.sleep(ctx, d)
+}
+
+// Reader returns a reader of r at the rate of l. Waits are cut short when ctx
+// is done.
+//
+// The reader closes r when closed, if r is an io.Closer.
+func (l *RateLimiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &rateReader{r: r, l: l, ctx: ctx}
+}
+
+// rateReader reads at the rate of a RateLimiter.
+type rateReader struct {
+	r   io.Reader
+	l   *RateLimiter
+	ctx context.Context
+}
+
+// Read implements io.Reader.
+func (r *rateReader) Read(p []byte) (int, error) {
+	if len(p) > r.l.burst {
+		p = p[:r.l.burst]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.l.wait(r.ctx, n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+// Size returns the size of the file, if known.
+func (r *rateReader) Size() (int64, error) {
+	if size := sizeOf(r.r); size >= 0 {
+		return size, nil
+	}
+	return 0, errors.New("unknown size")
+}
+
+// Close implements io.Closer.
+func (r *rateReader) Close() error {
+	if c, ok := r.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// rateLimitKey is the context key of the RateLimiter of a fetch.
+type rateLimitKey struct{}
+
+// ContextWithRateLimit returns a context to fetch files at the rate of l with
+// Schemes.Fetch and Schemes.FetchWithoutCache, rather than at the rate of the
+// schemes, if any.
+func ContextWithRateLimit(ctx context.Context, l *RateLimiter) context.Context {
+	return context.WithValue(ctx, rateLimitKey{}, l)
+}
+
+// rateLimitFrom returns the RateLimiter of ctx, if any, and ctx without it,
+// for the schemes not to limit the rate again, e.g. when fetching from
+// mirrors.
+func rateLimitFrom(ctx context.Context) (*RateLimiter, context.Context) {
+	l, _ := ctx.Value(rateLimitKey{}).(*RateLimiter)
+	if l == nil {
+		return nil, ctx
+	}
+	return l, context.WithValue(ctx, rateLimitKey{}, (*RateLimiter)(nil))
+}
+
+// SchemeWithRateLimit wraps a FileScheme, and reads the files it fetches at
+// the rate of Limiter.
+type SchemeWithRateLimit struct {
+	Scheme  FileScheme
+	Limiter *RateLimiter
+}
+
+// Fetch implements FileScheme.Fetch for rate limit wrapper.
+func (s *SchemeWithRateLimit) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	r, err := s.FetchWithoutCache(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return uio.NewCachingReader(r), nil
+}
+
+// FetchWithoutCache implements FileScheme.FetchWithoutCache for rate limit
+// wrapper.
+func (s *SchemeWithRateLimit) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	r, err := s.Scheme.FetchWithoutCache(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	l := s.Limiter
+	if fl, ok := ctx.Value(rateLimitKey{}).(*RateLimiter); ok {
+		// The fetch has its own rate, which Schemes already limits
+		// if fl is nil.
+		if fl == nil {
+			return r, nil
+		}
+		l = fl
+	}
+	return l.Reader(ctx, r), nil
+}
+
+// WithRateLimit returns schemes that read the files fetched by the schemes of
+// s at the rate of l, all together.
+func (s Schemes) WithRateLimit(l *RateLimiter) Schemes {
+	r := make(Schemes, len(s))
+	for scheme, fs := range s {
+		r[scheme] = &SchemeWithRateLimit{Scheme: fs, Limiter: l}
+	}
+	return r
+}
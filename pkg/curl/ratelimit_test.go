@@ -0,0 +1,125 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock is the clock of a RateLimiter, which sleeps instantly.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) limiter(bytesPerSecond int64) *RateLimiter {
+	l := NewRateLimiter(bytesPerSecond)
+	l.now = func() time.Time { return c.t }
+	l.sleep = func(_ context.Context, d time.Duration) error {
+		c.t = c.t.Add(d)
+		return nil
+	}
+	return l
+}
+
+func (c *fakeClock) since(start time.Time) time.Duration {
+	return c.t.Sub(start)
+}
+
+func TestRateLimiter(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		rate    int64
+		readers int
+		size    int
+		want    time.Duration
+	}{
+		// The first tenth of a second of bytes is free.
+		{name: "one", rate: 1000, readers: 1, size: 1000, want: 900 * time.Millisecond},
+		{name: "shared", rate: 1000, readers: 2, size: 1000, want: 1900 * time.Millisecond},
+		{name: "under burst", rate: 10000, readers: 1, size: 1000, want: 0},
+		{name: "slow", rate: 5, readers: 1, size: 10, want: 1800 * time.Millisecond},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeClock{t: time.Unix(0, 0)}
+			start := c.t
+			l := c.limiter(tt.rate)
+			for i := 0; i < tt.readers; i++ {
+				r := l.Reader(context.Background(), strings.NewReader(strings.Repeat("x", tt.size)))
+				n, err := io.Copy(io.Discard, r)
+				if err != nil || n != int64(tt.size) {
+					t.Fatalf("read %d bytes, %v, want %d", n, err, tt.size)
+				}
+			}
+			if d := c.since(start); d < tt.want-time.Millisecond || d > tt.want+time.Millisecond {
+				t.Errorf("read in %v, want %v", d, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := NewRateLimiter(10).Reader(ctx, strings.NewReader(strings.Repeat("x", 100)))
+	if _, err := io.ReadAll(r); err != context.Canceled {
+		t.Errorf("read = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestSchemesRateLimit(t *testing.T) {
+	m := NewMockScheme("tftp")
+	m.Add("boot", "/vmlinuz", strings.Repeat("x", 2000))
+	u := &url.URL{Scheme: "tftp", Host: "boot", Path: "/vmlinuz"}
+
+	for _, tt := range []struct {
+		name    string
+		schemes int64
+		fetch   int64
+		want    time.Duration
+	}{
+		{name: "schemes", schemes: 1000, want: 1900 * time.Millisecond},
+		{name: "fetch", fetch: 1000, want: 1900 * time.Millisecond},
+		// The rate of the fetch is used instead.
+		{name: "both", schemes: 100, fetch: 1000, want: 1900 * time.Millisecond},
+	} {
+		for _, cache := range []bool{true, false} {
+			c := &fakeClock{t: time.Unix(0, 0)}
+			start := c.t
+			s := Schemes{"tftp": m}
+			if tt.schemes != 0 {
+				s = s.WithRateLimit(c.limiter(tt.schemes))
+			}
+			ctx := context.Background()
+			if tt.fetch != 0 {
+				ctx = ContextWithRateLimit(ctx, c.limiter(tt.fetch))
+			}
+			var r io.Reader
+			if cache {
+				f, err := s.Fetch(ctx, u)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r = io.NewSectionReader(f, 0, 1<<20)
+			} else {
+				f, err := s.FetchWithoutCache(ctx, u)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r = f
+			}
+			if _, err := io.Copy(io.Discard, r); err != nil {
+				t.Fatal(err)
+			}
+			if d := c.since(start); d < tt.want-time.Millisecond || d > tt.want+time.Millisecond {
+				t.Errorf("%s (cache %t): read in %v, want %v", tt.name, cache, d, tt.want)
+			}
+		}
+	}
+}
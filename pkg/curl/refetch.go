@@ -0,0 +1,87 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"net/url"
+	"sync"
+)
+
+// Refetcher fetches files again only when they changed since it last fetched
+// them, e.g. for loops that fetch a boot policy or menu every so often, so
+// that unchanged files are neither downloaded nor applied again.
+type Refetcher struct {
+	client *HTTPClient
+
+	mu      sync.Mutex
+	fetched map[string]fetched
+}
+
+// fetched is what a Refetcher keeps of the last version of a file it
+// fetched.
+type fetched struct {
+	v   Validator
+	sum [sha256.Size]byte
+}
+
+// NewRefetcher returns a Refetcher fetching files with c.
+func NewRefetcher(c *HTTPClient) *Refetcher {
+	return &Refetcher{client: c, fetched: map[string]fetched{}}
+}
+
+// same returns whether validators a and b identify the same version of a
+// file: by their ETags if both have one, or else their LastModified.
+func same(a, b Validator) bool {
+	if a.ETag != "" && b.ETag != "" {
+		return a.ETag == b.ETag
+	}
+	return !a.LastModified.IsZero() && a.LastModified.Equal(b.LastModified)
+}
+
+// Fetch fetches the file at u, and returns its contents, or ErrNotModified
+// if it did not change since Fetch last returned it.
+//
+// The server is asked to send the file only if it changed. Servers that do
+// not support conditional requests send it anyway; it is then compared with
+// the last version by its Validator, or its contents if it has none.
+func (r *Refetcher) Fetch(ctx context.Context, u *url.URL) ([]byte, error) {
+	key := u.String()
+	r.mu.Lock()
+	last, ok := r.fetched[key]
+	r.mu.Unlock()
+
+	resp, err := r.client.FetchIfChanged(ctx, u, last.v)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if ok && same(last.v, resp.Validator) {
+		trace.Trace("not modified", "url", u, "etag", resp.Validator.ETag)
+		return nil, ErrNotModified
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	f := fetched{v: resp.Validator, sum: sha256.Sum256(b)}
+	r.mu.Lock()
+	r.fetched[key] = f
+	r.mu.Unlock()
+	if ok && f.sum == last.sum {
+		return nil, ErrNotModified
+	}
+	return b, nil
+}
+
+// Forget makes the next Fetch of u return the file whether it changed or
+// not, e.g. after what was made of it was lost.
+func (r *Refetcher) Forget(u *url.URL) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.fetched, u.String())
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchIfChanged(t *testing.T) {
+	const content = "0123456789"
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(content))
+	}))
+	defer s.Close()
+
+	c := NewHTTPClient(http.DefaultClient)
+	u, _ := url.Parse(s.URL)
+	for _, tt := range []struct {
+		v    Validator
+		want error
+	}{
+		{v: Validator{}},
+		{v: Validator{ETag: `"v0"`}},
+		{v: Validator{ETag: `"v1"`}, want: ErrNotModified},
+		{v: Validator{ETag: `W/"v1"`}, want: ErrNotModified},
+	} {
+		r, err := c.FetchIfChanged(context.Background(), u, tt.v)
+		if err != tt.want {
+			t.Errorf("FetchIfChanged(%+v) = %v, want %v", tt.v, err, tt.want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		b, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil || string(b) != content || r.Validator.ETag != `"v1"` {
+			t.Errorf("FetchIfChanged(%+v) = %q, %v, %+v, want %q with ETag \"v1\"", tt.v, b, err, r.Validator, content)
+		}
+	}
+}
+
+// versionedFile is a file served by a test server, which may ignore conditional
+// requests.
+type versionedFile struct {
+	mu           sync.Mutex
+	content      string
+	etag         string
+	modTime      time.Time
+	conditional  bool
+	sent, served int
+}
+
+func (f *versionedFile) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.served++
+	if !f.conditional {
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+	}
+	if f.etag != "" {
+		w.Header().Set("ETag", f.etag)
+	}
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeContent(cw, r, "file", f.modTime, strings.NewReader(f.content))
+	if cw.n > 0 {
+		f.sent++
+	}
+}
+
+func (f *versionedFile) set(content, etag string, modTime time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.content, f.etag, f.modTime = content, etag, modTime
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n int
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.n += len(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func TestRefetcher(t *testing.T) {
+	t0 := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		desc        string
+		etag        func(v int) string
+		modTime     func(v int) time.Time
+		conditional bool
+		wantSent    int
+	}{
+		{desc: "etag", etag: func(v int) string { return `"` + string(rune('0'+v)) + `"` }, conditional: true, wantSent: 2},
+		{desc: "last modified", modTime: func(v int) time.Time { return t0.Add(time.Duration(v) * time.Hour) }, conditional: true, wantSent: 2},
+		{desc: "etag, not conditional", etag: func(v int) string { return `"` + string(rune('0'+v)) + `"` }, wantSent: 4},
+		{desc: "no validators", wantSent: 4},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			f := &versionedFile{conditional: tt.conditional}
+			version := func(v int) {
+				var etag string
+				var mt time.Time
+				if tt.etag != nil {
+					etag = tt.etag(v)
+				}
+				if tt.modTime != nil {
+					mt = tt.modTime(v)
+				}
+				f.set("version "+string(rune('0'+v)), etag, mt)
+			}
+			s := httptest.NewServer(f)
+			defer s.Close()
+			u, _ := url.Parse(s.URL)
+
+			r := NewRefetcher(NewHTTPClient(http.DefaultClient))
+			for i, step := range []struct {
+				version int
+				forget  bool
+				want    string
+			}{
+				{version: 1, want: "version 1"},
+				{version: 1},
+				{version: 2, want: "version 2"},
+				{version: 2},
+				{version: 2, forget: true, want: "version 2"},
+			} {
+				version(step.version)
+				if step.forget {
+					r.Forget(u)
+				}
+				b, err := r.Fetch(context.Background(), u)
+				if step.want == "" && err != ErrNotModified || step.want != "" && (err != nil || string(b) != step.want) {
+					t.Errorf("fetch %d = %q, %v, want %q", i, b, err, step.want)
+				}
+			}
+			// Forgetting drops the validator, so the server sends
+			// the file again.
+			if want := tt.wantSent + 1; f.sent != want {
+				t.Errorf("server sent the file %d times in %d requests, want %d", f.sent, f.served, want)
+			}
+		})
+	}
+}
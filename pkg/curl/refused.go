@@ -0,0 +1,18 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package curl
+
+import (
+	"errors"
+	"syscall"
+)
+
+// refused returns whether err is a refused connection.
+func refused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,12 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import "strings"
+
+// refused returns whether err is a refused connection.
+func refused(err error) bool {
+	return strings.Contains(err.Error(), "connection refused")
+}
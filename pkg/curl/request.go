@@ -0,0 +1,84 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// RequestOptions customize the requests of an HTTPClient, e.g. for artifact
+// servers that want credentials in a header.
+type RequestOptions struct {
+	// Method is the method of requests, GET if empty.
+	Method string
+
+	// Header is added to the headers of requests. A Host header sets the
+	// host of requests instead.
+	Header http.Header
+
+	// User and Password, if User is set, are sent with basic
+	// authentication.
+	User     string
+	Password string
+
+	// UserAgent, if set, replaces the User-Agent of Go.
+	UserAgent string
+
+	// Body, if not nil, is sent with every request, e.g. with a POST
+	// Method. Signed requests cannot have a body.
+	Body []byte
+}
+
+// WithRequestOptions returns a copy of h that customizes its requests as o
+// says. Files are only fetched in segments, see Segmented, and downloads
+// resumed, see FetchRange, with GET requests.
+func (h HTTPClient) WithRequestOptions(o RequestOptions) *HTTPClient {
+	h.req = o
+	return &h
+}
+
+// isGet returns whether the requests are GETs without a body.
+func (o RequestOptions) isGet() bool {
+	return (o.Method == "" || o.Method == http.MethodGet) && o.Body == nil
+}
+
+// newRequest returns a request for u, customized as o says.
+func (o RequestOptions) newRequest(ctx context.Context, u *url.URL) (*http.Request, error) {
+	method := o.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if o.Body != nil {
+		// Requests are sent again after redirects, with a new reader.
+		body = bytes.NewReader(o.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range o.Header {
+		if http.CanonicalHeaderKey(k) == "Host" {
+			if len(vs) > 0 {
+				req.Host = vs[0]
+			}
+			continue
+		}
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if o.User != "" {
+		req.SetBasicAuth(o.User, o.Password)
+	}
+	if o.UserAgent != "" {
+		req.Header.Set("User-Agent", o.UserAgent)
+	}
+	return req, nil
+}
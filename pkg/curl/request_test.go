@@ -0,0 +1,89 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWithRequestOptions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			return
+		}
+		user, password, _ := r.BasicAuth()
+		b, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s %s:%s %s %q %q", r.Method, r.Host, r.Header.Get("X-Token"), user, password, r.UserAgent(), r.Header.Get("Content-Type"), b)
+	}))
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		name string
+		o    RequestOptions
+		path string
+		want string
+	}{
+		{
+			name: "default",
+			want: `GET HOST  : Go-http-client/1.1 "" ""`,
+		},
+		{
+			name: "headers",
+			o: RequestOptions{
+				Header:    http.Header{"X-Token": {"secret"}, "host": {"artifacts.example.com"}},
+				User:      "user",
+				Password:  "password",
+				UserAgent: "wget",
+			},
+			want: `GET artifacts.example.com secret user:password wget "" ""`,
+		},
+		{
+			name: "post",
+			o: RequestOptions{
+				Method: http.MethodPost,
+				Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:   []byte("a=1&b=2"),
+			},
+			path: "/redirect",
+			want: `POST HOST  : Go-http-client/1.1 "application/x-www-form-urlencoded" "a=1&b=2"`,
+		},
+		{
+			name: "method",
+			o:    RequestOptions{Method: http.MethodPut, Body: []byte{}},
+			want: `PUT HOST  : Go-http-client/1.1 "" ""`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(ts.URL + tt.path)
+			h := NewHTTPClient(nil).WithRequestOptions(tt.o).Segmented(4, 1)
+			h.c = ts.Client()
+			r, err := h.FetchWithoutCache(context.Background(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.ReplaceAll(tt.want, "HOST", u.Host); string(b) != want {
+				t.Errorf("response = %q, want %q", b, want)
+			}
+		})
+	}
+
+	h := NewSignedHTTPClient(ts.Client(), &HMAC{KeyID: "key", Key: []byte("key")}).WithRequestOptions(RequestOptions{Method: http.MethodPost, Body: []byte("a")})
+	u, _ := url.Parse(ts.URL)
+	if _, err := h.FetchWithoutCache(context.Background(), u); err == nil {
+		t.Errorf("signed POST with a body = nil, want error")
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver resolves the host names of the servers to fetch files from. A
+// *net.Resolver is one.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// StaticResolver resolves host names with a map of host names to addresses,
+// like /etc/hosts, and the others with Fallback, or the system's resolver if
+// Fallback is nil.
+type StaticResolver struct {
+	Hosts    map[string][]string
+	Fallback Resolver
+}
+
+// LookupHost implements Resolver.
+func (r *StaticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r.Hosts[strings.ToLower(strings.TrimSuffix(host, "."))]; ok {
+		return addrs, nil
+	}
+	if r.Fallback == nil {
+		return net.DefaultResolver.LookupHost(ctx, host)
+	}
+	return r.Fallback.LookupHost(ctx, host)
+}
+
+// DNSResolver returns a Resolver that sends DNS queries to server, a
+// HOST[:PORT] with port 53 by default, rather than to the servers of
+// /etc/resolv.conf, which may not exist or not be trusted.
+func DNSResolver(server string) Resolver {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// DoHResolver returns a Resolver that sends DNS queries over HTTPS to a
+// DNS-over-HTTPS endpoint, as of RFC 8484, e.g. https://1.1.1.1/dns-query,
+// with c, or http.DefaultClient if c is nil. The host of the endpoint is best
+// an IP address, or resolved by c, not to depend on the system's resolver.
+func DoHResolver(endpoint *url.URL, c *http.Client) Resolver {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: c, endpoint: endpoint.String()}, nil
+		},
+	}
+}
+
+// dohConn is a connection, for net.Resolver, to a DNS-over-HTTPS endpoint. It
+// is not a net.PacketConn, so messages are framed as over TCP, with their
+// length first, and each query written is sent in a POST request.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	endpoint string
+
+	mu       sync.Mutex
+	deadline time.Time
+	resp     bytes.Buffer
+}
+
+// Write implements net.Conn.
+func (c *dohConn) Write(b []byte) (int, error) {
+	if len(b) < 2 || int(b[0])<<8|int(b[1]) != len(b)-2 {
+		return 0, errors.New("DNS query is not one message")
+	}
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+	ctx := c.ctx
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(b[2:]))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("DNS-over-HTTPS endpoint %s: %s", c.endpoint, resp.Status)
+	}
+	// DNS messages are at most 64 KiB.
+	msg, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return 0, err
+	}
+	if len(msg) >= 1<<16 {
+		return 0, fmt.Errorf("DNS-over-HTTPS endpoint %s: response too long", c.endpoint)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resp.WriteByte(byte(len(msg) >> 8))
+	c.resp.WriteByte(byte(len(msg)))
+	c.resp.Write(msg)
+	return len(b), nil
+}
+
+// Read implements net.Conn.
+func (c *dohConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resp.Read(b)
+}
+
+// Close implements net.Conn.
+func (c *dohConn) Close() error {
+	return nil
+}
+
+// LocalAddr implements net.Conn.
+func (c *dohConn) LocalAddr() net.Addr {
+	return nil
+}
+
+// RemoteAddr implements net.Conn.
+func (c *dohConn) RemoteAddr() net.Addr {
+	return nil
+}
+
+// SetDeadline implements net.Conn.
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+// SetReadDeadline implements net.Conn. Responses are read with the query.
+func (c *dohConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+// SetWriteDeadline implements net.Conn.
+func (c *dohConn) SetWriteDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+// ResolverClient returns a copy of c, or of http.DefaultClient if c is nil,
+// that resolves the host names of the servers it connects to with r, e.g. for
+// NewHTTPClient. It keeps the proxy and TLS configuration of c, so it can be
+// given a ProxyClient or a TLSClient; the proxy, not r, resolves the host
+// names of requests through a proxy.
+func ResolverClient(c *http.Client, r Resolver) *http.Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	t, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		for _, a := range addrs {
+			var conn net.Conn
+			if conn, err = dial(ctx, network, net.JoinHostPort(a, port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+	nc := *c
+	nc.Transport = t
+	return &nc
+}
+
+// ResolverOptions configures how the host names of servers are resolved, for
+// early boot environments without /etc/resolv.conf, or with a DHCP resolver
+// that is not trusted.
+type ResolverOptions struct {
+	// Hosts maps host names to addresses, which are used rather than
+	// resolving the names.
+	Hosts map[string][]string
+
+	// Server is the HOST[:PORT] of the DNS server to send queries to,
+	// rather than the servers of /etc/resolv.conf.
+	Server string
+
+	// DoH is the URL of a DNS-over-HTTPS endpoint to send queries to. It
+	// takes precedence over Server. Its host is resolved with Hosts, if
+	// there.
+	DoH string
+}
+
+// IsZero returns whether o changes nothing.
+func (o ResolverOptions) IsZero() bool {
+	return len(o.Hosts) == 0 && o.Server == "" && o.DoH == ""
+}
+
+// Resolver returns the Resolver of o, or nil if o is zero.
+func (o ResolverOptions) Resolver() (Resolver, error) {
+	var r Resolver
+	switch {
+	case o.DoH != "":
+		u, err := url.Parse(o.DoH)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return nil, fmt.Errorf("DNS-over-HTTPS endpoint %q is not an HTTPS URL", o.DoH)
+		}
+		var c *http.Client
+		if len(o.Hosts) > 0 {
+			c = ResolverClient(nil, &StaticResolver{Hosts: o.Hosts})
+		}
+		r = DoHResolver(u, c)
+	case o.Server != "":
+		r = DNSResolver(o.Server)
+	}
+	if len(o.Hosts) > 0 {
+		r = &StaticResolver{Hosts: o.Hosts, Fallback: r}
+	}
+	return r, nil
+}
+
+// ParseHosts parses a comma separated list of HOST=ADDRESS into a map for
+// ResolverOptions.Hosts. A host may be given more than once, for more
+// addresses.
+func ParseHosts(s string) (map[string][]string, error) {
+	hosts := make(map[string][]string)
+	for _, h := range strings.Split(s, ",") {
+		if h == "" {
+			continue
+		}
+		host, addr, ok := strings.Cut(h, "=")
+		if !ok || host == "" || net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("invalid host %q: want HOST=ADDRESS", h)
+		}
+		host = strings.ToLower(host)
+		hosts[host] = append(hosts[host], addr)
+	}
+	return hosts, nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// dnsAnswer answers a DNS query for an A record with ip, and others with no
+// records, without the additional records of the query.
+func dnsAnswer(q []byte, ip net.IP) []byte {
+	// Skip the name of the question, then its type and class.
+	end := 12
+	for end < len(q) && q[end] != 0 {
+		end += int(q[end]) + 1
+	}
+	end += 5
+	if len(q) < end {
+		return nil
+	}
+	r := append([]byte(nil), q[:end]...)
+	// A response, recursion desired and available, with 1 question.
+	binary.BigEndian.PutUint16(r[2:], 0x8180)
+	binary.BigEndian.PutUint16(r[4:], 1)
+	binary.BigEndian.PutUint16(r[6:], 0)
+	binary.BigEndian.PutUint16(r[8:], 0)
+	binary.BigEndian.PutUint16(r[10:], 0)
+	if qtype := binary.BigEndian.Uint16(q[end-4:]); qtype == 1 {
+		binary.BigEndian.PutUint16(r[6:], 1)
+		// The name of the question, IN A, a TTL of 60s, and the address.
+		r = append(r, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		r = append(r, ip.To4()...)
+	}
+	return r
+}
+
+// dohHandler is a DNS-over-HTTPS endpoint that resolves all names to ip.
+func dohHandler(ip net.IP) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "not a DNS query", http.StatusBadRequest)
+			return
+		}
+		q, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(dnsAnswer(q, ip))
+	})
+}
+
+// dnsServer is a DNS server on UDP that resolves all names to ip.
+func dnsServer(t *testing.T, ip net.IP) string {
+	c, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no UDP on localhost: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	go func() {
+		b := make([]byte, 1500)
+		for {
+			n, addr, err := c.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			c.WriteTo(dnsAnswer(b[:n], ip), addr)
+		}
+	}()
+	return c.LocalAddr().String()
+}
+
+func TestResolvers(t *testing.T) {
+	ip := net.IPv4(192, 0, 2, 7)
+	doh := httptest.NewTLSServer(dohHandler(ip))
+	defer doh.Close()
+	dohURL, _ := url.Parse(doh.URL + "/dns-query")
+
+	for _, tt := range []struct {
+		name string
+		r    Resolver
+		host string
+		want []string
+	}{
+		{name: "DNS server", r: DNSResolver(dnsServer(t, ip)), host: "boot.example", want: []string{"192.0.2.7"}},
+		{name: "DoH", r: DoHResolver(dohURL, doh.Client()), host: "boot.example", want: []string{"192.0.2.7"}},
+		{
+			name: "static",
+			r:    &StaticResolver{Hosts: map[string][]string{"boot.example": {"192.0.2.1", "2001:db8::1"}}},
+			host: "Boot.Example.",
+			want: []string{"192.0.2.1", "2001:db8::1"},
+		},
+		{
+			name: "static fallback",
+			r: &StaticResolver{
+				Hosts:    map[string][]string{"boot.example": {"192.0.2.1"}},
+				Fallback: DoHResolver(dohURL, doh.Client()),
+			},
+			host: "mirror.example",
+			want: []string{"192.0.2.7"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.r.LookupHost(context.Background(), tt.host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LookupHost(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolverClient(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "kernel")
+	}))
+	defer s.Close()
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+
+	for _, tt := range []struct {
+		name    string
+		hosts   map[string][]string
+		wantErr bool
+	}{
+		{name: "resolved", hosts: map[string][]string{"boot.example": {"127.0.0.1"}}},
+		// The first address that connects is used.
+		{name: "failover", hosts: map[string][]string{"boot.example": {"127.0.0.2", "127.0.0.1"}}},
+		{name: "no addresses", hosts: map[string][]string{"boot.example": nil}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.name == "failover" {
+				if c, err := net.Dial("tcp", net.JoinHostPort("127.0.0.2", port)); err == nil {
+					c.Close()
+					t.Skip("127.0.0.2 connects")
+				}
+			}
+			c := ResolverClient(nil, &StaticResolver{Hosts: tt.hosts})
+			schemes := DefaultSchemes.WithHTTPClient(NewHTTPClient(c))
+			u, _ := url.Parse("http://boot.example:" + port + "/vmlinuz")
+			r, err := schemes.FetchWithoutCache(context.Background(), u)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchWithoutCache = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if b, err := io.ReadAll(r); err != nil || string(b) != "kernel" {
+				t.Errorf("fetched %q, %v, want %q", b, err, "kernel")
+			}
+		})
+	}
+}
+
+func TestResolverOptions(t *testing.T) {
+	doh := httptest.NewServer(dohHandler(net.IPv4(192, 0, 2, 7)))
+	defer doh.Close()
+	_, port, _ := net.SplitHostPort(doh.Listener.Addr().String())
+
+	if r, err := (ResolverOptions{}).Resolver(); r != nil || err != nil {
+		t.Errorf("Resolver of no options = %v, %v, want nil, nil", r, err)
+	}
+	for _, o := range []ResolverOptions{
+		{DoH: "dns.example/dns-query"},
+		{DoH: "ftp://dns.example/dns-query"},
+	} {
+		if _, err := o.Resolver(); err == nil {
+			t.Errorf("Resolver of %+v = nil, want error", o)
+		}
+	}
+
+	// The host of the endpoint is resolved with Hosts too.
+	o := ResolverOptions{
+		Hosts: map[string][]string{"boot.example": {"192.0.2.1"}, "dns.example": {"127.0.0.1"}},
+		DoH:   "http://dns.example:" + port + "/dns-query",
+	}
+	if o.IsZero() {
+		t.Errorf("%+v is zero", o)
+	}
+	r, err := o.Resolver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for host, want := range map[string]string{"boot.example": "192.0.2.1", "mirror.example": "192.0.2.7"} {
+		got, err := r.LookupHost(context.Background(), host)
+		if err != nil || len(got) != 1 || got[0] != want {
+			t.Errorf("LookupHost(%q) = %v, %v, want [%s]", host, got, err, want)
+		}
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	for _, tt := range []struct {
+		s       string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{s: "", want: map[string][]string{}},
+		{s: "boot.example=192.0.2.1", want: map[string][]string{"boot.example": {"192.0.2.1"}}},
+		{
+			s:    "Boot.example=192.0.2.1,boot.example=2001:db8::1,dns.example=192.0.2.53",
+			want: map[string][]string{"boot.example": {"192.0.2.1", "2001:db8::1"}, "dns.example": {"192.0.2.53"}},
+		},
+		{s: "boot.example", wantErr: true},
+		{s: "boot.example=mirror.example", wantErr: true},
+		{s: "=192.0.2.1", wantErr: true},
+	} {
+		got, err := ParseHosts(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHosts(%q) = %v, want error %t", tt.s, err, tt.wantErr)
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseHosts(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+	if _, err := ParseHosts(strings.Repeat(",", 3)); err != nil {
+		t.Errorf("ParseHosts of empty entries = %v, want nil", err)
+	}
+}
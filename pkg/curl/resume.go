@@ -0,0 +1,246 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotModified is returned by HTTPClient.FetchIfModified,
+// HTTPClient.FetchIfChanged and Refetcher.Fetch when the file was not
+// modified.
+var ErrNotModified = errors.New("not modified")
+
+// Validator identifies a version of a file, so that resuming its download
+// does not mix two versions of it.
+type Validator struct {
+	// ETag is the entity tag of the file, if the server gave one.
+	ETag string
+
+	// LastModified is when the file was last modified, if the server
+	// said.
+	LastModified time.Time
+}
+
+// ifRange returns the If-Range header of v, or "" if v cannot be used in
+// one. Weak entity tags cannot.
+func (v Validator) ifRange() string {
+	if v.ETag != "" && !strings.HasPrefix(v.ETag, "W/") {
+		return v.ETag
+	}
+	if !v.LastModified.IsZero() {
+		return v.LastModified.UTC().Format(http.TimeFormat)
+	}
+	return ""
+}
+
+func validator(h http.Header) Validator {
+	v := Validator{ETag: h.Get("ETag")}
+	if t, err := http.ParseTime(h.Get("Last-Modified")); err == nil {
+		v.LastModified = t
+	}
+	return v
+}
+
+// RangeResponse is the response to HTTPClient.FetchRange.
+type RangeResponse struct {
+	// Body is the contents of the file from Offset on.
+	Body io.ReadCloser
+
+	// Offset is where Body starts in the file: the offset asked for, or
+	// 0 if the server sent the whole file, because it does not support
+	// ranges or the file changed.
+	Offset int64
+
+	// Size is the size of the whole file, or -1 if it is not known.
+	Size int64
+
+	// Validator identifies the version of the file that was sent.
+	Validator Validator
+}
+
+// FetchRange fetches the file at u from offset on, to resume its download.
+//
+// v identifies the version of the file that the first offset bytes were
+// downloaded from, and may be zero. If it does not identify the file on the
+// server anymore, or the server does not support ranges, the whole file is
+// fetched, and the response's Offset is 0.
+func (h HTTPClient) FetchRange(ctx context.Context, u *url.URL, offset int64, v Validator) (*RangeResponse, error) {
+	resp, err := h.get(ctx, u, func(req *http.Request) {
+		if offset <= 0 {
+			return
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if ir := v.ifRange(); ir != "" {
+			req.Header.Set("If-Range", ir)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := &RangeResponse{Body: resp.Body, Size: resp.ContentLength, Validator: validator(resp.Header)}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if offset > 0 {
+			trace.Trace("range ignored", "url", u, "offset", offset)
+		}
+		return r, nil
+
+	case http.StatusPartialContent:
+		start, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err == nil && start != offset {
+			err = fmt.Errorf("server sent range from %d, want %d", start, offset)
+		}
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		// A server that ignores If-Range sends the range anyway.
+		if v.ETag != "" && r.Validator.ETag != "" && v.ETag != r.Validator.ETag {
+			resp.Body.Close()
+			trace.Trace("file changed", "url", u, "etag", v.ETag, "new_etag", r.Validator.ETag)
+			return h.FetchRange(ctx, u, 0, Validator{})
+		}
+		r.Offset, r.Size = offset, size
+		return r, nil
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		// The download is complete if the file is exactly offset
+		// bytes long.
+		if _, size, err := parseContentRange(resp.Header.Get("Content-Range")); err == nil && size == offset {
+			r.Body, r.Offset, r.Size = io.NopCloser(strings.NewReader("")), offset, size
+			return r, nil
+		}
+		return h.FetchRange(ctx, u, 0, Validator{})
+
+	default:
+		resp.Body.Close()
+		return nil, &HTTPClientCodeError{fmt.Errorf("%s", resp.Status), resp.StatusCode}
+	}
+}
+
+// FetchIfModified fetches the whole file at u if it was modified after since,
+// as its Last-Modified says, and returns ErrNotModified otherwise, e.g. to
+// download files again only when they change. The whole file is fetched if
+// since is zero.
+//
+// Servers that do not support If-Modified-Since send the file anyway: the
+// response's Validator tells whether it changed.
+func (h HTTPClient) FetchIfModified(ctx context.Context, u *url.URL, since time.Time) (*RangeResponse, error) {
+	return h.FetchIfChanged(ctx, u, Validator{LastModified: since})
+}
+
+// FetchIfChanged fetches the whole file at u unless v, the Validator of a
+// previous response, still identifies it, and returns ErrNotModified
+// otherwise. Its ETag is sent in If-None-Match, which servers check before
+// If-Modified-Since, its LastModified. The whole file is fetched if v is
+// zero.
+//
+// Servers that do not support conditional requests send the file anyway:
+// the response's Validator tells whether it changed.
+func (h HTTPClient) FetchIfChanged(ctx context.Context, u *url.URL, v Validator) (*RangeResponse, error) {
+	resp, err := h.get(ctx, u, func(req *http.Request) {
+		if v.ETag != "" {
+			req.Header.Set("If-None-Match", v.ETag)
+		}
+		if !v.LastModified.IsZero() {
+			req.Header.Set("If-Modified-Since", v.LastModified.UTC().Format(http.TimeFormat))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return &RangeResponse{Body: resp.Body, Size: resp.ContentLength, Validator: validator(resp.Header)}, nil
+	case http.StatusNotModified:
+		resp.Body.Close()
+		return nil, ErrNotModified
+	default:
+		resp.Body.Close()
+		return nil, &HTTPClientCodeError{fmt.Errorf("%s", resp.Status), resp.StatusCode}
+	}
+}
+
+// Head sends a HEAD request for u, e.g. to check that a file exists without
+// fetching it, and returns the response, or an HTTPClientCodeError if its
+// code is not 2xx. The response has no body.
+func (h HTTPClient) Head(ctx context.Context, u *url.URL) (*http.Response, error) {
+	resp, err := h.get(ctx, u, func(req *http.Request) {
+		req.Method, req.Body, req.GetBody, req.ContentLength = http.MethodHead, nil, nil, 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, &HTTPClientCodeError{fmt.Errorf("%s", resp.Status), resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// get sends a request for u, a GET unless the RequestOptions of h say
+// otherwise, set up by prepare if not nil, and signed.
+func (h HTTPClient) get(ctx context.Context, u *url.URL, prepare func(*http.Request)) (*http.Response, error) {
+	req, err := h.req.newRequest(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+	if h.signer != nil {
+		if h.req.Body != nil {
+			return nil, errors.New("signed requests cannot have a body")
+		}
+		if err := h.signer.Sign(req); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := h.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	trace.Trace("http response", "url", u, "status", resp.StatusCode, "length", resp.ContentLength, "range", resp.Header.Get("Content-Range"))
+	return resp, nil
+}
+
+// parseContentRange parses a Content-Range header, "bytes 100-199/1000" or
+// "bytes */1000", and returns where the range starts and the size of the
+// file, or -1 if it is not known.
+func parseContentRange(s string) (start, size int64, err error) {
+	bad := fmt.Errorf("invalid Content-Range %q", s)
+	rng, total, ok := strings.Cut(strings.TrimPrefix(s, "bytes "), "/")
+	if !ok || !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, bad
+	}
+	size = -1
+	if total != "*" {
+		if size, err = strconv.ParseInt(total, 10, 64); err != nil {
+			return 0, 0, bad
+		}
+	}
+	if rng == "*" {
+		return -1, size, nil
+	}
+	first, _, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, bad
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, bad
+	}
+	return start, size, nil
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchRange(t *testing.T) {
+	const content = "0123456789"
+	modTime := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", modTime, strings.NewReader(content))
+	})
+	mux.HandleFunc("/etag", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(content))
+	})
+	mux.HandleFunc("/ignores-if-range", func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("If-Range")
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(content))
+	})
+	mux.HandleFunc("/no-ranges", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, content)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	c := NewHTTPClient(http.DefaultClient)
+	for _, tt := range []struct {
+		desc       string
+		path       string
+		offset     int64
+		v          Validator
+		wantOffset int64
+		want       string
+	}{
+		{desc: "whole file", path: "/file", want: content},
+		{desc: "resume", path: "/file", offset: 4, v: Validator{LastModified: modTime}, wantOffset: 4, want: content[4:]},
+		{desc: "resume without validator", path: "/file", offset: 4, wantOffset: 4, want: content[4:]},
+		{desc: "changed", path: "/file", offset: 4, v: Validator{LastModified: modTime.Add(-time.Hour)}, want: content},
+		{desc: "complete", path: "/file", offset: 10, v: Validator{LastModified: modTime}, wantOffset: 10},
+		{desc: "longer than file", path: "/file", offset: 12, v: Validator{LastModified: modTime}, want: content},
+		{desc: "etag", path: "/etag", offset: 4, v: Validator{ETag: `"v2"`}, wantOffset: 4, want: content[4:]},
+		{desc: "etag changed", path: "/etag", offset: 4, v: Validator{ETag: `"v1"`}, want: content},
+		{desc: "If-Range ignored", path: "/ignores-if-range", offset: 4, v: Validator{ETag: `"v1"`}, want: content},
+		{desc: "no ranges", path: "/no-ranges", offset: 4, want: content},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			u, _ := url.Parse(s.URL + tt.path)
+			r, err := c.FetchRange(context.Background(), u, tt.offset, tt.v)
+			if err != nil {
+				t.Fatalf("FetchRange = %v", err)
+			}
+			defer r.Body.Close()
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Offset != tt.wantOffset || string(b) != tt.want {
+				t.Errorf("FetchRange = %q at %d, want %q at %d", b, r.Offset, tt.want, tt.wantOffset)
+			}
+			if tt.path == "/file" && !r.Validator.LastModified.Equal(modTime) {
+				t.Errorf("Validator = %+v, want last modified %v", r.Validator, modTime)
+			}
+		})
+	}
+
+	u, _ := url.Parse(s.URL + "/missing")
+	var herr *HTTPClientCodeError
+	if _, err := c.FetchRange(context.Background(), u, 4, Validator{}); !errors.As(err, &herr) || herr.HTTPCode != 404 {
+		t.Errorf("FetchRange(missing) = %v, want HTTP code 404", err)
+	}
+}
+
+func TestFetchIfModified(t *testing.T) {
+	const content = "0123456789"
+	modTime := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "file", modTime, strings.NewReader(content))
+	}))
+	defer s.Close()
+
+	c := NewHTTPClient(http.DefaultClient)
+	u, _ := url.Parse(s.URL + "/file")
+	for _, tt := range []struct {
+		desc  string
+		since time.Time
+		want  error
+	}{
+		{desc: "no local file"},
+		{desc: "older", since: modTime.Add(-time.Hour)},
+		{desc: "same", since: modTime, want: ErrNotModified},
+		{desc: "newer", since: modTime.Add(time.Hour), want: ErrNotModified},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			r, err := c.FetchIfModified(context.Background(), u, tt.since)
+			if err != tt.want {
+				t.Fatalf("FetchIfModified = %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			defer r.Body.Close()
+			b, err := io.ReadAll(r.Body)
+			if err != nil || string(b) != content || !r.Validator.LastModified.Equal(modTime) {
+				t.Errorf("FetchIfModified = %q, %v, %+v, want %q, nil, last modified %v", b, err, r.Validator, content, modTime)
+			}
+		})
+	}
+
+	u, _ = url.Parse(s.URL + "/missing")
+	var herr *HTTPClientCodeError
+	if _, err := c.FetchIfModified(context.Background(), u, modTime); !errors.As(err, &herr) || herr.HTTPCode != 404 {
+		t.Errorf("FetchIfModified(missing) = %v, want HTTP code 404", err)
+	}
+}
+
+func TestHead(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodHead:
+			http.Error(w, "HEAD only", http.StatusMethodNotAllowed)
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		default:
+			w.Header().Set("Content-Length", "1048576")
+		}
+	}))
+	defer s.Close()
+
+	// The method and body of the request options are not sent.
+	c := NewHTTPClient(http.DefaultClient).WithRequestOptions(RequestOptions{Method: http.MethodPost, Body: []byte("a=b")})
+	u, _ := url.Parse(s.URL + "/file")
+	resp, err := c.Head(context.Background(), u)
+	if err != nil || resp.ContentLength != 1<<20 {
+		t.Fatalf("Head = %v, want a response of length %d", err, 1<<20)
+	}
+
+	u, _ = url.Parse(s.URL + "/missing")
+	var herr *HTTPClientCodeError
+	if _, err := c.Head(context.Background(), u); !errors.As(err, &herr) || herr.HTTPCode != 404 {
+		t.Errorf("Head(missing) = %v, want HTTP code 404", err)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	for _, tt := range []struct {
+		in          string
+		start, size int64
+		wantErr     bool
+	}{
+		{in: "bytes 100-199/1000", start: 100, size: 1000},
+		{in: "bytes 100-199/*", start: 100, size: -1},
+		{in: "bytes */1000", start: -1, size: 1000},
+		{in: "", wantErr: true},
+		{in: "items 1-2/3", wantErr: true},
+		{in: "bytes 100/1000", wantErr: true},
+		{in: "bytes x-1/2", wantErr: true},
+	} {
+		start, size, err := parseContentRange(tt.in)
+		if (err != nil) != tt.wantErr || !tt.wantErr && (start != tt.start || size != tt.size) {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v, want %d, %d, error %t", tt.in, start, size, err, tt.start, tt.size, tt.wantErr)
+		}
+	}
+}
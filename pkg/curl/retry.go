@@ -0,0 +1,153 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// RetryOn is a set of classes of errors to retry.
+type RetryOn uint
+
+const (
+	// RetryOnTimeout retries network timeouts, and the HTTP codes 408
+	// Request Timeout, 425 Too Early and 429 Too Many Requests.
+	RetryOnTimeout RetryOn = 1 << iota
+
+	// RetryOnConnect retries errors to connect but refused connections,
+	// e.g. while the network is still being configured, and temporary
+	// network errors.
+	RetryOnConnect
+
+	// RetryOnClientError retries all other HTTP 4xx codes.
+	RetryOnClientError
+
+	// RetryOnServerError retries HTTP 5xx codes, and TFTP errors other
+	// than file not found.
+	RetryOnServerError
+
+	// RetryOnRefused retries refused connections, e.g. to servers that
+	// are still starting.
+	RetryOnRefused
+)
+
+// DefaultRetryOn retries errors that may go away by themselves.
+const DefaultRetryOn = RetryOnTimeout | RetryOnConnect | RetryOnServerError | RetryOnRefused
+
+// RetryPolicy says how often and when to retry fetching a file.
+type RetryPolicy struct {
+	// MaxAttempts is how many times to fetch a file at most. 0 is the
+	// same as 1, i.e. no retries.
+	MaxAttempts int
+
+	// Base is how long to wait before the first retry. The wait doubles
+	// on every retry after that, up to Max.
+	Base time.Duration
+
+	// Max, if not 0, is the longest to wait between retries.
+	Max time.Duration
+
+	// Jitter, between 0 and 1, randomizes each wait by up to this
+	// fraction of it, so that many machines booting at once do not
+	// retry at once.
+	Jitter float64
+
+	// RetryOn are the errors to retry. If 0, DefaultRetryOn.
+	RetryOn RetryOn
+}
+
+// DefaultRetryPolicy is a retry policy for netbooting over flaky links.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Base:        time.Second,
+	Max:         30 * time.Second,
+	Jitter:      0.5,
+	RetryOn:     DefaultRetryOn,
+}
+
+// BackOff returns the backoff of p.
+func (p RetryPolicy) BackOff() backoff.BackOff {
+	if p.MaxAttempts <= 1 {
+		return &backoff.StopBackOff{}
+	}
+	if p.Base <= 0 {
+		return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, uint64(p.MaxAttempts-1))
+	}
+	b := &backoff.ExponentialBackOff{
+		InitialInterval:     p.Base,
+		RandomizationFactor: p.Jitter,
+		Multiplier:          2,
+		MaxInterval:         p.Max,
+		Clock:               backoff.SystemClock,
+	}
+	if b.MaxInterval == 0 {
+		b.MaxInterval = time.Duration(1<<63 - 1)
+	}
+	b.Reset()
+	return backoff.WithMaxRetries(b, uint64(p.MaxAttempts-1))
+}
+
+// DoRetry returns a DoRetry for the errors p retries.
+func (p RetryPolicy) DoRetry() DoRetry {
+	on := p.RetryOn
+	if on == 0 {
+		on = DefaultRetryOn
+	}
+	return func(u *url.URL, err error) bool {
+		return on&classify(u, err) != 0
+	}
+}
+
+// classify returns the class of err, or 0 if it is in none.
+func classify(u *url.URL, err error) RetryOn {
+	var herr *HTTPClientCodeError
+	if errors.As(err, &herr) {
+		switch c := herr.HTTPCode; {
+		case c == 408, c == 425, c == 429:
+			return RetryOnTimeout
+		case c >= 400 && c < 500:
+			return RetryOnClientError
+		case c >= 500:
+			return RetryOnServerError
+		}
+		return 0
+	}
+	var nerr net.Error
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &nerr) && nerr.Timeout():
+		return RetryOnTimeout
+	case refused(err):
+		return RetryOnRefused
+	case RetryConnectErrors(u, err), RetryTemporaryNetworkErrors(u, err):
+		return RetryOnConnect
+	case u.Scheme == "tftp" && RetryTFTP(u, err):
+		return RetryOnServerError
+	}
+	return 0
+}
+
+// WithRetries returns a FileScheme that retries fs according to p.
+func (p RetryPolicy) WithRetries(fs FileScheme) FileScheme {
+	return &SchemeWithRetries{
+		Scheme:  fs,
+		DoRetry: p.DoRetry(),
+		BackOff: p.BackOff(),
+	}
+}
+
+// WithRetries returns schemes that retry the schemes of s according to p.
+func (s Schemes) WithRetries(p RetryPolicy) Schemes {
+	r := make(Schemes, len(s))
+	for scheme, fs := range s {
+		r[scheme] = p.WithRetries(fs)
+	}
+	return r
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+func TestRetryPolicyDoRetry(t *testing.T) {
+	httpURL := &url.URL{Scheme: "http", Host: "example.com"}
+	tftpURL := &url.URL{Scheme: "tftp", Host: "192.168.0.1"}
+	connErr := &os.SyscallError{Syscall: "connect", Err: errRefused}
+	for _, tt := range []struct {
+		u    *url.URL
+		err  error
+		on   RetryOn
+		want bool
+	}{
+		{u: httpURL, err: &HTTPClientCodeError{HTTPCode: 503}, want: true},
+		{u: httpURL, err: &HTTPClientCodeError{HTTPCode: 503}, on: RetryOnTimeout, want: false},
+		{u: httpURL, err: &HTTPClientCodeError{HTTPCode: 429}, on: RetryOnTimeout, want: true},
+		{u: httpURL, err: &HTTPClientCodeError{HTTPCode: 404}, want: false},
+		{u: httpURL, err: &HTTPClientCodeError{HTTPCode: 404}, on: RetryOnClientError, want: true},
+		{u: httpURL, err: fmt.Errorf("get: %w", os.ErrDeadlineExceeded), on: RetryOnTimeout, want: true},
+		{u: httpURL, err: connErr, want: true},
+		{u: httpURL, err: connErr, on: RetryOnServerError, want: false},
+		{u: httpURL, err: connErr, on: RetryOnConnect, want: false},
+		{u: httpURL, err: connErr, on: RetryOnRefused, want: true},
+		{u: httpURL, err: &os.SyscallError{Syscall: "connect", Err: errUnreachable}, on: RetryOnConnect, want: true},
+		{u: tftpURL, err: errors.New("server: FILE_NOT_FOUND"), want: false},
+		{u: tftpURL, err: errors.New("server: ACCESS_VIOLATION"), want: true},
+		{u: httpURL, err: errTest, on: ^RetryOn(0), want: false},
+	} {
+		t.Run(fmt.Sprintf("%v_%d", tt.err, tt.on), func(t *testing.T) {
+			p := RetryPolicy{RetryOn: tt.on}
+			if got := p.DoRetry()(tt.u, tt.err); got != tt.want {
+				t.Errorf("DoRetry(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackOff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, Base: time.Second, Max: 3 * time.Second, Jitter: 0.5}
+	b := p.BackOff()
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		d := b.NextBackOff()
+		if lo, hi := want/2, want*3/2; d < lo || d > hi {
+			t.Errorf("wait %d = %v, want between %v and %v", i, d, lo, hi)
+		}
+	}
+	if d := b.NextBackOff(); d != backoff.Stop {
+		t.Errorf("wait after %d attempts = %v, want Stop", p.MaxAttempts, d)
+	}
+
+	if d := (RetryPolicy{}).BackOff().NextBackOff(); d != backoff.Stop {
+		t.Errorf("zero RetryPolicy waits %v, want Stop", d)
+	}
+}
+
+func TestSchemesWithRetries(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		err       error
+		attempts  int
+		wantCalls uint
+		wantErr   bool
+	}{
+		{name: "server error", err: &HTTPClientCodeError{HTTPCode: 502}, attempts: 3, wantCalls: 3},
+		{name: "too many server errors", err: &HTTPClientCodeError{HTTPCode: 502}, attempts: 2, wantCalls: 2, wantErr: true},
+		{name: "not found", err: &HTTPClientCodeError{HTTPCode: 404}, attempts: 3, wantCalls: 1, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMockScheme("fooftp")
+			m.Add("192.168.0.1", "/foo/pxelinux.cfg/default", "haha")
+			m.SetErr(tt.err, 2)
+			s := Schemes{"fooftp": m}.WithRetries(RetryPolicy{MaxAttempts: tt.attempts})
+
+			_, err := s.Fetch(context.Background(), testURL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Fetch = %v, want error %t", err, tt.wantErr)
+			}
+			if got := m.NumCalled(testURL); got != tt.wantCalls {
+				t.Errorf("Fetch called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package curl
+
+import "syscall"
+
+var errRefused, errUnreachable error = syscall.ECONNREFUSED, syscall.ENETUNREACH
@@ -4,7 +4,22 @@
 
 // Package curl implements routines to fetch files given a URL.
 //
-// curl currently supports HTTP, TFTP, and local files.
+// curl currently supports HTTP, TFTP, NFSv3, FTP, SFTP, and local files.
+// HTTP requests can be signed for authenticated artifact stores; see
+// Signer. They go through the proxies in $HTTP_PROXY, $HTTPS_PROXY and
+// $NO_PROXY, or through an explicit HTTP or SOCKS5 proxy; see ProxyClient.
+// Their host names can be resolved with static addresses, a given DNS
+// server, or DNS-over-HTTPS rather than the system's resolver; see
+// ResolverClient. Their connections can be made over one IP version,
+// through one interface, or from one address; see DialerClient, or to a
+// UNIX domain or vsock socket instead, with DialerClient or http+unix,
+// http+vsock, https+unix and https+vsock URLs; see SocketHTTPClient. They
+// reuse kept-alive connections, see PooledClient, and large files can be
+// fetched with parallel range requests; see HTTPClient.Segmented. Their
+// method, headers, credentials and body can be set; see
+// HTTPClient.WithRequestOptions. Fetched files can be verified against a
+// digest or OpenPGP signatures, in memory or in a temporary file; see
+// FetchVerified and FetchAndVerify.
 package curl
 
 import (
@@ -23,9 +38,13 @@ import (
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/u-root/u-root/pkg/uio"
+	"github.com/u-root/u-root/pkg/ulog"
 	"pack.ag/tftp"
 )
 
+// trace traces fetches, with -trace=curl.
+var trace = ulog.Tracer("curl")
+
 // ErrNoSuchScheme is returned by Schemes.Fetch and
 // Schemes.LazyFetch if there is no registered FileScheme
 // implementation for the given URL scheme.
@@ -69,16 +88,27 @@ var (
 	//
 	// It is not recommended to use this for HTTPS. We recommend creating an
 	// http.Client that accepts only a private pool of certificates.
-	DefaultHTTPClient = NewHTTPClient(http.DefaultClient)
+	DefaultHTTPClient = NewHTTPClient(PooledClient(nil, 0))
 
 	// DefaultTFTPClient is the default TFTP FileScheme.
-	DefaultTFTPClient = NewTFTPClient(tftp.ClientMode(tftp.ModeOctet), tftp.ClientBlocksize(1450), tftp.ClientWindowsize(64))
+	DefaultTFTPClient = NewTFTPClient(TFTPOptions{}.clientOpts()...)
+
+	// DefaultSocketHTTPClient is the default FileScheme for HTTP over
+	// UNIX domain and vsock sockets.
+	DefaultSocketHTTPClient = NewSocketHTTPClient(nil)
 
 	// DefaultSchemes are the schemes supported by default.
 	DefaultSchemes = Schemes{
-		"tftp": DefaultTFTPClient,
-		"http": DefaultHTTPClient,
-		"file": &LocalFileClient{},
+		"tftp":        DefaultTFTPClient,
+		"http":        DefaultHTTPClient,
+		"http+unix":   DefaultSocketHTTPClient,
+		"http+vsock":  DefaultSocketHTTPClient,
+		"https+unix":  DefaultSocketHTTPClient,
+		"https+vsock": DefaultSocketHTTPClient,
+		"file":        &LocalFileClient{},
+		"nfs":         &NFSClient{},
+		"ftp":         &FTPClient{},
+		"sftp":        &SFTPClient{},
 	}
 )
 
@@ -168,13 +198,26 @@ func (f file) String() string {
 // returned.
 //
 // Content is cached in memory as it reads.
+//
+// The file is read at the rate of the RateLimiter of ctx, if any; see
+// ContextWithRateLimit.
 func (s Schemes) Fetch(ctx context.Context, u *url.URL) (FileWithCache, error) {
 	fg, ok := s[u.Scheme]
 	if !ok {
 		return nil, &URLError{URL: u, Err: ErrNoSuchScheme}
 	}
+	trace.Trace("fetch", "url", u)
+	if l, ctx := rateLimitFrom(ctx); l != nil {
+		r, err := fg.FetchWithoutCache(ctx, u)
+		if err != nil {
+			trace.Trace("fetch failed", "url", u, "err", err)
+			return nil, &URLError{URL: u, Err: err}
+		}
+		return &cacheFile{ReaderAt: uio.NewCachingReader(l.Reader(ctx, r)), url: u}, nil
+	}
 	r, err := fg.Fetch(ctx, u)
 	if err != nil {
+		trace.Trace("fetch failed", "url", u, "err", err)
 		return nil, &URLError{URL: u, Err: err}
 	}
 	return &cacheFile{ReaderAt: r, url: u}, nil
@@ -187,10 +230,16 @@ func (s Schemes) FetchWithoutCache(ctx context.Context, u *url.URL) (FileWithout
 	if !ok {
 		return nil, &URLError{URL: u, Err: ErrNoSuchScheme}
 	}
+	trace.Trace("fetch", "url", u, "cache", false)
+	l, ctx := rateLimitFrom(ctx)
 	r, err := fg.FetchWithoutCache(ctx, u)
 	if err != nil {
+		trace.Trace("fetch failed", "url", u, "err", err)
 		return nil, &URLError{URL: u, Err: err}
 	}
+	if l != nil {
+		r = l.Reader(ctx, r)
+	}
 	return &file{Reader: r, url: u}, nil
 }
 
@@ -306,6 +355,7 @@ func (s *SchemeWithRetries) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt,
 	back := backoff.WithContext(s.BackOff, ctx)
 	for d := time.Duration(0); d != backoff.Stop; d = back.NextBackOff() {
 		if d > 0 {
+			trace.Trace("retry", "url", u, "wait", d)
 			time.Sleep(d)
 		}
 
@@ -334,6 +384,7 @@ func (s *SchemeWithRetries) FetchWithoutCache(ctx context.Context, u *url.URL) (
 	back := backoff.WithContext(s.BackOff, ctx)
 	for d := time.Duration(0); d != backoff.Stop; d = back.NextBackOff() {
 		if d > 0 {
+			trace.Trace("retry", "url", u, "wait", d)
 			time.Sleep(d)
 		}
 
@@ -374,7 +425,12 @@ func (h *HTTPClientCodeError) Unwrap() error {
 
 // HTTPClient implements FileScheme for HTTP files.
 type HTTPClient struct {
-	c *http.Client
+	c      *http.Client
+	signer Signer
+	req    RequestOptions
+
+	segments       int
+	segmentMinSize int64
 }
 
 // NewHTTPClient returns a new HTTP FileScheme based on the given http.Client.
@@ -384,25 +440,35 @@ func NewHTTPClient(c *http.Client) *HTTPClient {
 	}
 }
 
-func httpFetch(ctx context.Context, c *http.Client, u *url.URL) (io.Reader, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
-	if err != nil {
-		return nil, err
+// NewSignedHTTPClient returns a new HTTP FileScheme based on the given
+// http.Client that signs each request with s, unless s is nil.
+func NewSignedHTTPClient(c *http.Client, s Signer) *HTTPClient {
+	return &HTTPClient{
+		c:      c,
+		signer: s,
 	}
-	resp, err := c.Do(req)
+}
+
+// fetchWhole fetches the file at u in one request.
+func (h HTTPClient) fetchWhole(ctx context.Context, u *url.URL) (io.Reader, error) {
+	resp, err := h.get(ctx, u, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode != 200 {
+		// Read some of the error page, for the connection to be
+		// reused if it is short.
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
 		return nil, &HTTPClientCodeError{err, resp.StatusCode}
 	}
-	return resp.Body, nil
+	return &httpBody{resp.Body, resp.ContentLength}, nil
 }
 
 // Fetch implements FileScheme.Fetch for HTTP.
 func (h HTTPClient) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
-	r, err := httpFetch(ctx, h.c, u)
+	r, err := h.fetch(ctx, u)
 	if err != nil {
 		return nil, err
 	}
@@ -411,7 +477,7 @@ func (h HTTPClient) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error)
 
 // FetchWithoutCache implements FileScheme.FetchWithoutCache for HTTP.
 func (h HTTPClient) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
-	return httpFetch(ctx, h.c, u)
+	return h.fetch(ctx, u)
 }
 
 // RetryOr returns a DoRetry function that returns true if any one of fn return
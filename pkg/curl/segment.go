@@ -0,0 +1,234 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// DefaultPoolSize is how many idle connections to each server PooledClient
+// keeps alive by default, enough for the requests of segmented fetches.
+const DefaultPoolSize = 16
+
+// DefaultSegmentMinSize is the size of the smallest files fetched in segments
+// by HTTPClient.Segmented by default: smaller files take about as long to
+// fetch as the requests take to go back and forth.
+const DefaultSegmentMinSize = 4 << 20
+
+// PooledClient returns a copy of c, or of http.DefaultClient if c is nil, that
+// keeps up to perHost idle connections to each server alive, or
+// DefaultPoolSize if perHost is 0, for the next fetches to reuse, and speaks
+// HTTP/2 to servers that support it, to make all fetches on one connection.
+// It keeps the proxy, TLS configuration and resolver of c.
+//
+// Connections are only reused once the file fetched on them was read to the
+// end, or closed.
+func PooledClient(c *http.Client, perHost int) *http.Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	if perHost == 0 {
+		perHost = DefaultPoolSize
+	}
+	t, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	t.MaxIdleConnsPerHost = perHost
+	if t.MaxIdleConns != 0 && t.MaxIdleConns < perHost {
+		t.MaxIdleConns = perHost
+	}
+	t.ForceAttemptHTTP2 = true
+	nc := *c
+	nc.Transport = t
+	return &nc
+}
+
+// Segmented returns a copy of h that fetches files of at least minSize bytes,
+// or DefaultSegmentMinSize if minSize is 0, in n segments, with n range
+// requests in parallel, and reassembles them, e.g. from servers that limit
+// the rate of each request. Other files, and the files of servers that do not
+// support ranges, are fetched with one request.
+//
+// The size of a file is asked for with a HEAD request first. Segments are
+// kept in memory until they are read, so a file may take up to its size in
+// memory if it is read slowly.
+func (h HTTPClient) Segmented(n int, minSize int64) *HTTPClient {
+	if minSize == 0 {
+		minSize = DefaultSegmentMinSize
+	}
+	h.segments, h.segmentMinSize = n, minSize
+	return &h
+}
+
+// fetch fetches the file at u, in segments if h is Segmented, its requests
+// are GETs and the file is large enough.
+func (h HTTPClient) fetch(ctx context.Context, u *url.URL) (io.Reader, error) {
+	if h.segments > 1 && h.req.isGet() {
+		if r := h.fetchSegmented(ctx, u); r != nil {
+			return r, nil
+		}
+	}
+	return h.fetchWhole(ctx, u)
+}
+
+// fetchSegmented starts fetching the file at u in segments, or returns nil if
+// it is too small, or its server does not support ranges.
+func (h HTTPClient) fetchSegmented(ctx context.Context, u *url.URL) io.Reader {
+	resp, err := h.get(ctx, u, func(req *http.Request) {
+		req.Method = http.MethodHead
+	})
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	size := resp.ContentLength
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || size < h.segmentMinSize || size < int64(h.segments) {
+		return nil
+	}
+	trace.Trace("segmented fetch", "url", u, "size", size, "segments", h.segments)
+
+	v := validator(resp.Header)
+	ctx, cancel := context.WithCancel(ctx)
+	r := &segmentedReader{size: size, cancel: cancel}
+	for i := 0; i < h.segments; i++ {
+		s := newSegment()
+		r.segs = append(r.segs, s)
+		start, end := size*int64(i)/int64(h.segments), size*int64(i+1)/int64(h.segments)
+		go func() {
+			s.finish(h.fetchSegment(ctx, u, v, start, end, size, s))
+		}()
+	}
+	return r
+}
+
+// fetchSegment fetches the bytes of the file at u from start to end into s. v
+// identifies the version of the file, of size bytes, that the other segments
+// are from.
+func (h HTTPClient) fetchSegment(ctx context.Context, u *url.URL, v Validator, start, end, size int64, s io.Writer) error {
+	resp, err := h.get(ctx, u, func(req *http.Request) {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+		if ir := v.ifRange(); ir != "" {
+			req.Header.Set("If-Range", ir)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		// The file changed since the HEAD request, if the server
+		// sent it all.
+		return &HTTPClientCodeError{fmt.Errorf("fetching bytes %d-%d", start, end-1), resp.StatusCode}
+	}
+	first, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return err
+	}
+	if first != start || total != size {
+		return fmt.Errorf("server sent bytes from %d of %d, want from %d of %d", first, total, start, size)
+	}
+	n, err := io.Copy(s, io.LimitReader(resp.Body, end-start))
+	if err == nil && n != end-start {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// segment is the part of a file that one request of a segmented fetch
+// fetches, buffered until it is read.
+type segment struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  []byte
+	done bool
+	err  error
+}
+
+func newSegment() *segment {
+	s := &segment{}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Write implements io.Writer.
+func (s *segment) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = append(s.buf, p...)
+	s.cond.Broadcast()
+	return len(p), nil
+}
+
+// finish marks the end of the segment, with the error that cut it short, if
+// any.
+func (s *segment) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done, s.err = true, err
+	s.cond.Broadcast()
+}
+
+// Read implements io.Reader. It waits for bytes to read, and returns io.EOF
+// once the segment was all read.
+func (s *segment) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.buf) == 0 && !s.done {
+		s.cond.Wait()
+	}
+	if len(s.buf) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, s.buf)
+	if s.buf = s.buf[n:]; len(s.buf) == 0 {
+		s.buf = nil
+	}
+	return n, nil
+}
+
+// segmentedReader reads the segments of a segmented fetch in order.
+type segmentedReader struct {
+	segs   []*segment
+	size   int64
+	cancel context.CancelFunc
+}
+
+// Read implements io.Reader.
+func (r *segmentedReader) Read(p []byte) (int, error) {
+	for len(r.segs) > 0 {
+		n, err := r.segs[0].Read(p)
+		if err == io.EOF {
+			r.segs = r.segs[1:]
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+	r.cancel()
+	return 0, io.EOF
+}
+
+// Size returns the size of the file.
+func (r *segmentedReader) Size() (int64, error) {
+	return r.size, nil
+}
+
+// Close implements io.Closer. It stops fetching the segments.
+func (r *segmentedReader) Close() error {
+	r.cancel()
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rangeServer serves content with ranges, or not if noRanges, and records the
+// ranges asked for.
+type rangeServer struct {
+	content  []byte
+	etag     func() string
+	noRanges bool
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.mu.Unlock()
+	}
+	if s.etag != nil {
+		w.Header().Set("ETag", s.etag())
+	}
+	if s.noRanges {
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.content)))
+		if r.Method == http.MethodGet {
+			w.Write(s.content)
+		}
+		return
+	}
+	http.ServeContent(w, r, "vmlinuz", time.Time{}, bytes.NewReader(s.content))
+}
+
+func TestSegmented(t *testing.T) {
+	content := make([]byte, 1<<20+3)
+	for i := range content {
+		content[i] = byte(i * 13)
+	}
+	for _, tt := range []struct {
+		name       string
+		s          *rangeServer
+		segments   int
+		minSize    int64
+		wantRanges []string
+		wantErr    bool
+	}{
+		{
+			name:       "segments",
+			s:          &rangeServer{content: content},
+			segments:   4,
+			minSize:    1 << 20,
+			wantRanges: []string{"bytes=0-262143", "bytes=262144-524288", "bytes=524289-786433", "bytes=786434-1048578"},
+		},
+		{
+			name:       "small",
+			s:          &rangeServer{content: content},
+			segments:   4,
+			wantRanges: []string{""},
+		},
+		{
+			name:       "not segmented",
+			s:          &rangeServer{content: content},
+			wantRanges: []string{""},
+		},
+		{
+			name:       "no ranges",
+			s:          &rangeServer{content: content, noRanges: true},
+			segments:   4,
+			minSize:    1,
+			wantRanges: []string{""},
+		},
+		{
+			name: "changed",
+			s: func() *rangeServer {
+				var n int32
+				return &rangeServer{content: content, etag: func() string {
+					// The HEAD request gets the old version.
+					if atomic.AddInt32(&n, 1) == 1 {
+						return `"old"`
+					}
+					return `"new"`
+				}}
+			}(),
+			segments: 2,
+			minSize:  1,
+			wantErr:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.s)
+			defer srv.Close()
+			u, _ := url.Parse(srv.URL + "/vmlinuz")
+
+			h := NewHTTPClient(PooledClient(srv.Client(), 0)).Segmented(tt.segments, tt.minSize)
+			r, err := h.FetchWithoutCache(context.Background(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := sizeOf(r); size != int64(len(content)) {
+				t.Errorf("size = %d, want %d", size, len(content))
+			}
+			b, err := io.ReadAll(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("read = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !bytes.Equal(b, content) {
+				t.Errorf("fetched %d bytes, want the %d bytes of the file", len(b), len(content))
+			}
+			tt.s.mu.Lock()
+			defer tt.s.mu.Unlock()
+			// The segments are requested in parallel, in any order.
+			sort.Strings(tt.s.ranges)
+			if !reflect.DeepEqual(tt.s.ranges, tt.wantRanges) {
+				t.Errorf("ranges %q, want %q", tt.s.ranges, tt.wantRanges)
+			}
+		})
+	}
+}
+
+func TestSegmentedClose(t *testing.T) {
+	srv := httptest.NewServer(&rangeServer{content: make([]byte, 1<<20)})
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL + "/vmlinuz")
+
+	r, err := NewHTTPClient(srv.Client()).Segmented(8, 1).FetchWithoutCache(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(r, make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	// The segments not fetched yet are canceled.
+	if err := r.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPooledClient(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "file")
+	}))
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	schemes := DefaultSchemes.WithHTTPClient(NewHTTPClient(PooledClient(srv.Client(), 0)))
+	for _, path := range []string{"/vmlinuz", "/missing", "/initrd", "/missing", "/cmdline", "/vmlinuz.sig"} {
+		u, _ := url.Parse(srv.URL + path)
+		r, err := schemes.FetchWithoutCache(context.Background(), u)
+		if path == "/missing" {
+			if err == nil {
+				t.Fatalf("fetching %s = nil, want error", path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("fetches made %d connections, want 1", n)
+	}
+}
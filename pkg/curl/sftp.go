@@ -0,0 +1,432 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/u-root/u-root/pkg/uio"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SFTP packet types and status codes (draft-ietf-secsh-filexfer-02).
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpRead    = 5
+	sftpFstat   = 8
+	sftpStatus  = 101
+	sftpHandle  = 102
+	sftpData    = 103
+	sftpAttrs   = 105
+
+	sftpOK               = 0
+	sftpEOF              = 1
+	sftpNoSuchFile       = 2
+	sftpPermissionDenied = 3
+
+	sftpReadFlag = 1
+	sftpAttrSize = 1
+)
+
+// sftpReadSize is how much is read at a time, the most all servers must
+// support.
+const sftpReadSize = 32 << 10
+
+// SFTPError is an error status of an SFTP server.
+type SFTPError struct {
+	Code uint32
+	Msg  string
+}
+
+// Error implements error.
+func (e *SFTPError) Error() string {
+	return fmt.Sprintf("sftp: %s (code %d)", e.Msg, e.Code)
+}
+
+// Is implements errors.Is, for os.ErrNotExist and os.ErrPermission.
+func (e *SFTPError) Is(target error) bool {
+	switch e.Code {
+	case sftpNoSuchFile:
+		return target == os.ErrNotExist
+	case sftpPermissionDenied:
+		return target == os.ErrPermission
+	}
+	return false
+}
+
+// SFTPClient implements FileScheme for files on SFTP servers, e.g.
+// sftp://user@10.0.0.1/srv/boot/vmlinuz. Paths are absolute; ~ is not
+// expanded.
+type SFTPClient struct {
+	// Signers authenticate with public keys. If they and HostKeyCallback
+	// are nil, the keys in ~/.ssh are used.
+	Signers []ssh.Signer
+
+	// HostKeyCallback verifies the keys of servers. If it and Signers are
+	// nil, the keys are verified with the known hosts in ~/.ssh/known_hosts
+	// and /etc/*/ssh_known_hosts.
+	HostKeyCallback ssh.HostKeyCallback
+}
+
+// NewSFTPClient returns an SFTP FileScheme that authenticates with signers
+// and verifies server keys with hostKey.
+func NewSFTPClient(signers []ssh.Signer, hostKey ssh.HostKeyCallback) *SFTPClient {
+	return &SFTPClient{Signers: signers, HostKeyCallback: hostKey}
+}
+
+// defaultSSH returns the keys and known hosts of the user.
+func defaultSSH() ([]ssh.Signer, ssh.HostKeyCallback, error) {
+	home := os.Getenv("HOME")
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		b, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		// Keys with passphrases cannot be used.
+		if s, err := ssh.ParsePrivateKey(b); err == nil {
+			signers = append(signers, s)
+		}
+	}
+	files, _ := filepath.Glob("/etc/*/ssh_known_hosts")
+	if home != "" {
+		files = append(files, filepath.Join(home, ".ssh", "known_hosts"))
+	}
+	var known []string
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			known = append(known, f)
+		}
+	}
+	if len(known) == 0 {
+		return nil, nil, errors.New("sftp: no known hosts to verify the server with")
+	}
+	cb, err := knownhosts.New(known...)
+	return signers, cb, err
+}
+
+// config returns the SSH configuration to fetch u.
+func (s *SFTPClient) config(u *url.URL) (*ssh.ClientConfig, error) {
+	signers, hostKey := s.Signers, s.HostKeyCallback
+	if signers == nil && hostKey == nil {
+		var err error
+		if signers, hostKey, err = defaultSSH(); err != nil {
+			return nil, err
+		}
+	}
+	if hostKey == nil {
+		return nil, errors.New("sftp: no HostKeyCallback")
+	}
+	user := os.Getenv("USER")
+	var auth []ssh.AuthMethod
+	if u.User != nil {
+		user = u.User.Username()
+		if pass, ok := u.User.Password(); ok {
+			auth = append(auth, ssh.Password(pass))
+		}
+	}
+	if user == "" {
+		user = "root"
+	}
+	if len(signers) > 0 {
+		auth = append([]ssh.AuthMethod{ssh.PublicKeys(signers...)}, auth...)
+	}
+	return &ssh.ClientConfig{User: user, Auth: auth, HostKeyCallback: hostKey}, nil
+}
+
+// sftpConn speaks SFTP over an SSH session, one request at a time.
+type sftpConn struct {
+	mu  sync.Mutex
+	w   io.WriteCloser
+	r   io.Reader
+	id  uint32
+	buf []byte
+}
+
+// sftpPacket builds a packet.
+type sftpPacket []byte
+
+func (p sftpPacket) uint32(v uint32) sftpPacket {
+	return binary.BigEndian.AppendUint32(p, v)
+}
+
+func (p sftpPacket) uint64(v uint64) sftpPacket {
+	return binary.BigEndian.AppendUint64(p, v)
+}
+
+func (p sftpPacket) string(s []byte) sftpPacket {
+	return append(p.uint32(uint32(len(s))), s...)
+}
+
+// sftpReply decodes a reply.
+type sftpReply struct {
+	typ byte
+	b   []byte
+	err error
+}
+
+func (r *sftpReply) uint32() uint32 {
+	if len(r.b) < 4 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *sftpReply) uint64() uint64 {
+	return uint64(r.uint32())<<32 | uint64(r.uint32())
+}
+
+func (r *sftpReply) string() []byte {
+	n := r.uint32()
+	if uint32(len(r.b)) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	s := r.b[:n]
+	r.b = r.b[n:]
+	return s
+}
+
+// status returns the error of an SSH_FXP_STATUS reply.
+func (r *sftpReply) status() error {
+	code, msg := r.uint32(), r.string()
+	if r.err != nil {
+		return r.err
+	}
+	if code == sftpOK {
+		return nil
+	}
+	if code == sftpEOF {
+		return io.EOF
+	}
+	return &SFTPError{Code: code, Msg: string(msg)}
+}
+
+// send sends a packet of type typ, and reads the reply.
+func (c *sftpConn) send(typ byte, p sftpPacket, hasID bool) (*sftpReply, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The length is filled in below.
+	out := sftpPacket{0, 0, 0, 0, typ}
+	if hasID {
+		c.id++
+		out = out.uint32(c.id)
+	}
+	out = append(out, p...)
+	binary.BigEndian.PutUint32(out, uint32(len(out)-4))
+	if _, err := c.w.Write(out); err != nil {
+		return nil, err
+	}
+
+	var hdr [5]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	if n < 1 || n > 1<<20 {
+		return nil, fmt.Errorf("sftp: invalid packet length %d", n)
+	}
+	if cap(c.buf) < int(n-1) {
+		c.buf = make([]byte, n-1)
+	}
+	b := c.buf[:n-1]
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return nil, err
+	}
+	r := &sftpReply{typ: hdr[4], b: b}
+	if hasID {
+		if id := r.uint32(); r.err == nil && id != c.id {
+			return nil, fmt.Errorf("sftp: reply %d to request %d", id, c.id)
+		}
+	}
+	return r, r.err
+}
+
+// sftpFile is a file being read from an SFTP server.
+type sftpFile struct {
+	c      *ssh.Client
+	conn   *sftpConn
+	handle []byte
+	off    uint64
+	size   int64
+	eof    bool
+	once   sync.Once
+	done   chan struct{}
+}
+
+// Read implements io.Reader.
+func (f *sftpFile) Read(p []byte) (int, error) {
+	if f.eof {
+		return 0, io.EOF
+	}
+	if len(p) > sftpReadSize {
+		p = p[:sftpReadSize]
+	}
+	r, err := f.conn.send(sftpRead, sftpPacket(nil).string(f.handle).uint64(f.off).uint32(uint32(len(p))), true)
+	if err != nil {
+		return 0, err
+	}
+	switch r.typ {
+	case sftpData:
+		n := copy(p, r.string())
+		f.off += uint64(n)
+		return n, r.err
+	case sftpStatus:
+		err := r.status()
+		if err == nil {
+			err = fmt.Errorf("sftp: read returned no data")
+		}
+		if err == io.EOF {
+			f.eof = true
+			f.Close()
+		}
+		return 0, err
+	}
+	return 0, fmt.Errorf("sftp: unexpected reply %d to read", r.typ)
+}
+
+// Close implements io.Closer.
+func (f *sftpFile) Close() error {
+	f.once.Do(func() {
+		close(f.done)
+		f.conn.send(sftpClose, sftpPacket(nil).string(f.handle), true)
+		f.conn.w.Close()
+		f.c.Close()
+	})
+	return nil
+}
+
+// Size returns the size of the file, if the server told it.
+func (f *sftpFile) Size() (int64, error) {
+	if f.size < 0 {
+		return 0, errors.New("unknown size")
+	}
+	return f.size, nil
+}
+
+func (s *SFTPClient) fetch(ctx context.Context, u *url.URL) (io.Reader, error) {
+	cfg, err := s.config(u)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "22")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	sc, chans, reqs, err := ssh.NewClientConn(conn, host, cfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := ssh.NewClient(sc, chans, reqs)
+	f, err := sftpOpenFile(c, u.Path)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-f.done:
+		}
+	}()
+	return f, nil
+}
+
+// sftpOpenFile starts SFTP on c, and opens the file at name.
+func sftpOpenFile(c *ssh.Client, name string) (*sftpFile, error) {
+	sess, err := c.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	w, err := sess.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := sess.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := sess.RequestSubsystem("sftp"); err != nil {
+		return nil, err
+	}
+	conn := &sftpConn{w: w, r: r}
+
+	reply, err := conn.send(sftpInit, sftpPacket(nil).uint32(3), false)
+	if err != nil {
+		return nil, err
+	}
+	if reply.typ != sftpVersion {
+		return nil, fmt.Errorf("sftp: unexpected reply %d to init", reply.typ)
+	}
+
+	reply, err = conn.send(sftpOpen, sftpPacket(nil).string([]byte(name)).uint32(sftpReadFlag).uint32(0), true)
+	if err != nil {
+		return nil, err
+	}
+	switch reply.typ {
+	case sftpStatus:
+		if err := reply.status(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("sftp: open returned no handle")
+	case sftpHandle:
+	default:
+		return nil, fmt.Errorf("sftp: unexpected reply %d to open", reply.typ)
+	}
+	f := &sftpFile{c: c, conn: conn, handle: append([]byte(nil), reply.string()...), size: -1, done: make(chan struct{})}
+	if reply.err != nil {
+		return nil, reply.err
+	}
+
+	// The size is only for progress; servers need not tell it.
+	if reply, err := conn.send(sftpFstat, sftpPacket(nil).string(f.handle), true); err == nil && reply.typ == sftpAttrs {
+		if flags := reply.uint32(); flags&sftpAttrSize != 0 {
+			if size := reply.uint64(); reply.err == nil {
+				f.size = int64(size)
+			}
+		}
+	}
+	return f, nil
+}
+
+// Fetch implements FileScheme.Fetch for SFTP.
+func (s *SFTPClient) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	r, err := s.fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return uio.NewCachingReader(r), nil
+}
+
+// FetchWithoutCache implements FileScheme.FetchWithoutCache for SFTP.
+func (s *SFTPClient) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	return s.fetch(ctx, u)
+}
@@ -0,0 +1,220 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ssh.NewSignerFromKey(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// serveSFTP serves files over SFTP to clients with the key client or the
+// password "secret".
+func serveSFTP(t *testing.T, host, client ssh.Signer, files map[string][]byte) net.Listener {
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, k ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(k.Marshal(), client.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+		PasswordCallback: func(_ ssh.ConnMetadata, p []byte) (*ssh.Permissions, error) {
+			if string(p) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	}
+	cfg.AddHostKey(host)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(c, cfg)
+				if err != nil {
+					c.Close()
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nc := range chans {
+					ch, reqs, err := nc.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range reqs {
+							ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+							req.Reply(ok, nil)
+							if ok {
+								go sftpServe(ch, files)
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+// sftpServe answers the SFTP requests the client makes.
+func sftpServe(ch ssh.Channel, files map[string][]byte) {
+	defer ch.Close()
+	for {
+		var hdr [5]byte
+		if _, err := io.ReadFull(ch, hdr[:]); err != nil {
+			return
+		}
+		b := make([]byte, binary.BigEndian.Uint32(hdr[:4])-1)
+		if _, err := io.ReadFull(ch, b); err != nil {
+			return
+		}
+		r := &sftpReply{typ: hdr[4], b: b}
+		if r.typ == sftpInit {
+			ch.Write(sftpPacket{0, 0, 0, 5, sftpVersion}.uint32(3))
+			continue
+		}
+		id := r.uint32()
+		var typ byte
+		var p sftpPacket
+		status := func(code uint32) {
+			typ, p = sftpStatus, sftpPacket(nil).uint32(code).string([]byte("status")).string(nil)
+		}
+		switch r.typ {
+		case sftpOpen:
+			name := string(r.string())
+			if _, ok := files[name]; !ok {
+				status(sftpNoSuchFile)
+				break
+			}
+			typ, p = sftpHandle, sftpPacket(nil).string([]byte(name))
+		case sftpFstat:
+			typ, p = sftpAttrs, sftpPacket(nil).uint32(sftpAttrSize).uint64(uint64(len(files[string(r.string())])))
+		case sftpRead:
+			f, off, n := files[string(r.string())], r.uint64(), r.uint32()
+			if off >= uint64(len(f)) {
+				status(sftpEOF)
+				break
+			}
+			// Short reads, as servers may do.
+			if n > 5 {
+				n = 5
+			}
+			end := off + uint64(n)
+			if end > uint64(len(f)) {
+				end = uint64(len(f))
+			}
+			typ, p = sftpData, sftpPacket(nil).string(f[off:end])
+		case sftpClose:
+			status(sftpOK)
+		default:
+			status(8)
+		}
+		out := sftpPacket{0, 0, 0, 0, typ}.uint32(id)
+		out = append(out, p...)
+		binary.BigEndian.PutUint32(out, uint32(len(out)-4))
+		ch.Write(out)
+	}
+}
+
+func TestSFTPClient(t *testing.T) {
+	host, client, other := newSigner(t), newSigner(t), newSigner(t)
+	kernel := []byte(strings.Repeat("kernel ", 10))
+	l := serveSFTP(t, host, client, map[string][]byte{"/srv/boot/vmlinuz": kernel})
+
+	for _, tt := range []struct {
+		name    string
+		client  *SFTPClient
+		url     string
+		fail    bool
+		wantErr error
+	}{
+		{
+			name:   "key",
+			client: NewSFTPClient([]ssh.Signer{client}, ssh.FixedHostKey(host.PublicKey())),
+			url:    "sftp://boot@%s/srv/boot/vmlinuz",
+		},
+		{
+			name:   "password",
+			client: NewSFTPClient(nil, ssh.FixedHostKey(host.PublicKey())),
+			url:    "sftp://boot:secret@%s/srv/boot/vmlinuz",
+		},
+		{
+			name:    "no such file",
+			client:  NewSFTPClient([]ssh.Signer{client}, ssh.FixedHostKey(host.PublicKey())),
+			url:     "sftp://boot@%s/srv/boot/missing",
+			fail:    true,
+			wantErr: os.ErrNotExist,
+		},
+		{
+			name:   "unknown key",
+			client: NewSFTPClient([]ssh.Signer{other}, ssh.FixedHostKey(host.PublicKey())),
+			url:    "sftp://boot@%s/srv/boot/vmlinuz",
+			fail:   true,
+		},
+		{
+			name:   "unknown host",
+			client: NewSFTPClient([]ssh.Signer{client}, ssh.FixedHostKey(other.PublicKey())),
+			url:    "sftp://boot@%s/srv/boot/vmlinuz",
+			fail:   true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(fmt.Sprintf(tt.url, l.Addr()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := tt.client.FetchWithoutCache(context.Background(), u)
+			if tt.fail {
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("fetch = %v, want error %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := sizeOf(r); size != int64(len(kernel)) {
+				t.Errorf("size = %d, want %d", size, len(kernel))
+			}
+			b, err := io.ReadAll(r)
+			if err != nil || !bytes.Equal(b, kernel) {
+				t.Errorf("fetched %q, %v, want %q", b, err, kernel)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Signer authenticates HTTP requests, e.g. to a private artifact store.
+type Signer interface {
+	// Sign adds authentication headers to r, which has no body.
+	Sign(r *http.Request) error
+}
+
+// emptyHash is the SHA-256 of an empty payload.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// SigV4 signs requests with AWS Signature Version 4, as S3 and compatible
+// object stores expect.
+type SigV4 struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is needed for temporary credentials only.
+	SessionToken string
+	Region       string
+	// Service is e.g. "s3".
+	Service string
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// awsEscape escapes s as SigV4 canonical requests require: everything but
+// unreserved characters, and slashes too unless path is set.
+func awsEscape(s string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || path && c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func canonicalQuery(u *url.URL) string {
+	var params []string
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			params = append(params, awsEscape(k, false)+"="+awsEscape(v, false))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// Sign implements Signer.Sign. All headers of r are signed.
+func (s *SigV4) Sign(r *http.Request) error {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	r.Header.Set("X-Amz-Date", amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	if s.SessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": r.URL.Host}
+	if r.Host != "" {
+		headers["host"] = r.Host
+	}
+	for k, vs := range r.Header {
+		var v []string
+		for _, s := range vs {
+			v = append(v, strings.Join(strings.Fields(s), " "))
+		}
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		fmt.Fprintf(&canonHeaders, "%s:%s\n", k, headers[k])
+	}
+	signed := strings.Join(names, ";")
+
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	canon := strings.Join([]string{
+		r.Method,
+		awsEscape(path, true),
+		canonicalQuery(r.URL),
+		canonHeaders.String(),
+		signed,
+		emptyHash,
+	}, "\n")
+	canonHash := sha256.Sum256([]byte(canon))
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(canonHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signed, sig))
+	return nil
+}
+
+// HMAC signs requests with a shared key, for artifact stores behind a
+// simple authenticating proxy.
+//
+// The request gets a Date header and
+//
+//	Authorization: HMAC-SHA256 KeyId=<KeyID>, Signature=<hex>
+//
+// where the signature is the HMAC-SHA256 with Key of
+//
+//	METHOD "\n" HOST "\n" PATH?QUERY "\n" DATE
+//
+// The server should reject dates too far from its own clock.
+type HMAC struct {
+	KeyID string
+	Key   []byte
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// Sign implements Signer.Sign.
+func (s *HMAC) Sign(r *http.Request) error {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	date := now().UTC().Format(http.TimeFormat)
+	r.Header.Set("Date", date)
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	sig := hmacSHA256(s.Key, strings.Join([]string{r.Method, host, r.URL.RequestURI(), date}, "\n"))
+	r.Header.Set("Authorization", fmt.Sprintf("HMAC-SHA256 KeyId=%s, Signature=%s", s.KeyID, hex.EncodeToString(sig)))
+	return nil
+}
+
+// ParseSigner returns the Signer for spec, taking secrets from getenv so
+// that they never show up on a command line:
+//
+//	aws-sigv4[:REGION[:SERVICE]]
+//	    SigV4 with $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and
+//	    $AWS_SESSION_TOKEN. REGION defaults to $AWS_REGION, then
+//	    $AWS_DEFAULT_REGION, then us-east-1, and SERVICE to s3.
+//	hmac[:KEYID]
+//	    HMAC with $CURL_HMAC_KEY. KEYID defaults to $CURL_HMAC_KEY_ID.
+//
+// An empty spec gives a nil Signer.
+func ParseSigner(spec string, getenv func(string) string) (Signer, error) {
+	f := strings.Split(spec, ":")
+	arg := func(i int, def ...string) string {
+		if i < len(f) && f[i] != "" {
+			return f[i]
+		}
+		for _, d := range def {
+			if d != "" {
+				return d
+			}
+		}
+		return ""
+	}
+	switch f[0] {
+	case "":
+		return nil, nil
+	case "aws-sigv4":
+		if len(f) > 3 {
+			return nil, fmt.Errorf("invalid signer %q", spec)
+		}
+		s := &SigV4{
+			AccessKeyID:     getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    getenv("AWS_SESSION_TOKEN"),
+			Region:          arg(1, getenv("AWS_REGION"), getenv("AWS_DEFAULT_REGION"), "us-east-1"),
+			Service:         arg(2, "s3"),
+		}
+		if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			return nil, errors.New("aws-sigv4 needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		return s, nil
+	case "hmac":
+		if len(f) > 2 {
+			return nil, fmt.Errorf("invalid signer %q", spec)
+		}
+		s := &HMAC{KeyID: arg(1, getenv("CURL_HMAC_KEY_ID")), Key: []byte(getenv("CURL_HMAC_KEY"))}
+		if len(s.Key) == 0 {
+			return nil, errors.New("hmac needs CURL_HMAC_KEY")
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown signer %q", f[0])
+}
+
+// SignerFromEnv returns the Signer for $CURL_SIGN, if it is set. See
+// ParseSigner.
+func SignerFromEnv() (Signer, error) {
+	return ParseSigner(os.Getenv("CURL_SIGN"), os.Getenv)
+}
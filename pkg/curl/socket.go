@@ -0,0 +1,131 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/u-root/u-root/pkg/vsock"
+)
+
+// SocketHTTPClient implements FileScheme for HTTP over a UNIX domain socket,
+// http+unix, or AF_VSOCK, http+vsock, and the same with HTTPS, https+unix
+// and https+vsock, e.g. for the APIs of agents and the metadata services of
+// the hosts of virtual machines.
+//
+// The path of http+unix URLs is the path of the socket and the path of the
+// file, separated by a colon, and http+vsock URLs have the vsock address as
+// their host, with port 80, or 443 for HTTPS, by default:
+//
+//	http+unix:///run/agent.sock:/v1/config
+//	http+vsock://host:1024/latest/user-data
+type SocketHTTPClient struct {
+	c *http.Client
+
+	mu sync.Mutex
+	// clients are the clients of each socket.
+	clients map[string]*HTTPClient
+}
+
+// NewSocketHTTPClient returns a new FileScheme for HTTP over UNIX domain and
+// vsock sockets, based on c, or http.DefaultClient if c is nil. Proxies are
+// not used.
+func NewSocketHTTPClient(c *http.Client) *SocketHTTPClient {
+	return &SocketHTTPClient{c: c, clients: map[string]*HTTPClient{}}
+}
+
+// socketURL returns the dial options and HTTP URL of u.
+func socketURL(u *url.URL) (DialOptions, *url.URL, error) {
+	proto, transport, _ := strings.Cut(u.Scheme, "+")
+	if proto != "http" && proto != "https" {
+		return DialOptions{}, nil, fmt.Errorf("%w: %q", ErrNoSuchScheme, u.Scheme)
+	}
+	hu := *u
+	hu.Scheme = proto
+	var o DialOptions
+	switch transport {
+	case "unix":
+		socket, path, ok := strings.Cut(u.Path, ":")
+		if !ok || socket == "" || u.Host != "" {
+			return o, nil, fmt.Errorf("URL %q is not %s+unix:///SOCKET:PATH", u.Redacted(), proto)
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		o.UnixSocket = socket
+		hu.Host, hu.Path, hu.RawPath = "localhost", path, ""
+	case "vsock":
+		host := u.Host
+		if u.Port() == "" {
+			port := "80"
+			if proto == "https" {
+				port = "443"
+			}
+			host += ":" + port
+		}
+		a, err := vsock.ParseAddr(host)
+		if err != nil {
+			return o, nil, err
+		}
+		o.Vsock = a
+	default:
+		return o, nil, fmt.Errorf("%w: %q", ErrNoSuchScheme, u.Scheme)
+	}
+	return o, &hu, nil
+}
+
+// client returns the client of o, which keeps connections to its socket
+// alive.
+func (s *SocketHTTPClient) client(o DialOptions) (*HTTPClient, error) {
+	key := o.UnixSocket
+	if o.Vsock != nil {
+		key = "vsock:" + o.Vsock.String()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h, ok := s.clients[key]; ok {
+		return h, nil
+	}
+	c, err := DialerClient(s.c, o)
+	if err != nil {
+		return nil, err
+	}
+	c.Transport.(*http.Transport).Proxy = nil
+	h := NewHTTPClient(c)
+	s.clients[key] = h
+	return h, nil
+}
+
+// Fetch implements FileScheme.Fetch.
+func (s *SocketHTTPClient) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	o, hu, err := socketURL(u)
+	if err != nil {
+		return nil, err
+	}
+	h, err := s.client(o)
+	if err != nil {
+		return nil, err
+	}
+	return h.Fetch(ctx, hu)
+}
+
+// FetchWithoutCache implements FileScheme.FetchWithoutCache.
+func (s *SocketHTTPClient) FetchWithoutCache(ctx context.Context, u *url.URL) (io.Reader, error) {
+	o, hu, err := socketURL(u)
+	if err != nil {
+		return nil, err
+	}
+	h, err := s.client(o)
+	if err != nil {
+		return nil, err
+	}
+	return h.FetchWithoutCache(ctx, hu)
+}
@@ -0,0 +1,32 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/vsock"
+)
+
+func TestVsockDialError(t *testing.T) {
+	// No one listens on this port of the local context, which refuses
+	// or times out connections, if the kernel has vsock at all.
+	o := DialOptions{Vsock: &vsock.Addr{CID: vsock.CIDLocal, Port: 1<<32 - 2}, Timeout: 100 * time.Millisecond}
+	c, err := DialerClient(nil, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://local/")
+	_, err = NewHTTPClient(c).FetchWithoutCache(context.Background(), u)
+	var oe *net.OpError
+	if !errors.As(err, &oe) || oe.Net != "vsock" {
+		t.Errorf("fetch = %v, want a vsock dial error", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/vsock"
+)
+
+func TestSocketURL(t *testing.T) {
+	for _, tt := range []struct {
+		u       string
+		o       DialOptions
+		want    string
+		wantErr bool
+	}{
+		{u: "http+unix:///run/agent.sock:/v1/config?all=1", o: DialOptions{UnixSocket: "/run/agent.sock"}, want: "http://localhost/v1/config?all=1"},
+		{u: "https+unix:///run/agent.sock:v1", o: DialOptions{UnixSocket: "/run/agent.sock"}, want: "https://localhost/v1"},
+		{u: "http+vsock://host/latest/user-data", o: DialOptions{Vsock: &vsock.Addr{CID: 2, Port: 80}}, want: "http://host/latest/user-data"},
+		{u: "https+vsock://3:8443/", o: DialOptions{Vsock: &vsock.Addr{CID: 3, Port: 8443}}, want: "https://3:8443/"},
+		{u: "http+unix:///run/agent.sock", wantErr: true},
+		{u: "http+unix://agent/run/agent.sock:/", wantErr: true},
+		{u: "http+vsock://guest/", wantErr: true},
+		{u: "ftp+unix:///run/agent.sock:/", wantErr: true},
+		{u: "http+tcp://host/", wantErr: true},
+	} {
+		u, err := url.Parse(tt.u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o, hu, err := socketURL(u)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("socketURL(%s) = %v, want error %t", tt.u, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(o, tt.o) || hu.String() != tt.want {
+			t.Errorf("socketURL(%s) = %+v, %s, want %+v, %s", tt.u, o, hu, tt.o, tt.want)
+		}
+	}
+}
+
+func TestSocketSchemes(t *testing.T) {
+	for _, scheme := range []string{"http+unix", "http+vsock", "https+unix", "https+vsock"} {
+		if DefaultSchemes[scheme] != DefaultSocketHTTPClient {
+			t.Errorf("DefaultSchemes[%q] = %v, want DefaultSocketHTTPClient", scheme, DefaultSchemes[scheme])
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host+r.URL.RequestURI())
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	fetch := func(s FileScheme, rawURL string) (string, error) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := s.FetchWithoutCache(context.Background(), u)
+		if err != nil {
+			return "", err
+		}
+		b, err := io.ReadAll(r)
+		return string(b), err
+	}
+
+	s := NewSocketHTTPClient(nil)
+	for i := 0; i < 2; i++ {
+		if got, err := fetch(s, "http+unix://"+socket+":/v1/config?all=1"); err != nil || got != "localhost/v1/config?all=1" {
+			t.Errorf("fetch = %q, %v, want localhost/v1/config?all=1", got, err)
+		}
+	}
+	if len(s.clients) != 1 {
+		t.Errorf("%d clients, want the 1 of the socket", len(s.clients))
+	}
+
+	c, err := DialerClient(nil, DialOptions{UnixSocket: socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := fetch(NewHTTPClient(c), "http://agent.local/v1"); err != nil || got != "agent.local/v1" {
+		t.Errorf("fetch = %q, %v, want agent.local/v1", got, err)
+	}
+
+	for _, o := range []DialOptions{
+		{UnixSocket: socket, Family: 4},
+		{UnixSocket: socket, Vsock: &vsock.Addr{CID: 2, Port: 80}},
+		{Vsock: &vsock.Addr{CID: 2, Port: 80}, Interface: "lo"},
+	} {
+		if _, err := DialerClient(nil, o); err == nil {
+			t.Errorf("DialerClient(%+v) = nil, want an error", o)
+		}
+	}
+}
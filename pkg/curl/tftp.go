@@ -0,0 +1,131 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pack.ag/tftp"
+)
+
+// These are the TFTP options of DefaultTFTPClient.
+const (
+	// DefaultTFTPBlocksize fits a data packet in an Ethernet frame of
+	// 1500 bytes, not to be fragmented.
+	DefaultTFTPBlocksize = 1450
+
+	// DefaultTFTPWindowsize is the number of data packets the server
+	// sends before waiting for an acknowledgement.
+	DefaultTFTPWindowsize = 64
+)
+
+// TFTPOptions tune TFTP transfers. The server ignores those it does not
+// support, and sends 512 byte blocks one at a time then.
+//
+// Large blocks and windows make fetches of large kernels and initramfs
+// images many times faster than 512 byte blocks, which need a round trip
+// each, but a lost packet costs a whole window, after a timeout: on lossy
+// networks, smaller windows may be faster.
+type TFTPOptions struct {
+	// Blocksize is the size of data packets, negotiated as of RFC 2348,
+	// from 8 to 65464. 0 is DefaultTFTPBlocksize.
+	Blocksize int
+
+	// Windowsize is the number of data packets sent before an
+	// acknowledgement, negotiated as of RFC 7440, from 1 to 65535. 0 is
+	// DefaultTFTPWindowsize.
+	Windowsize int
+
+	// Timeout is how long to wait for a packet before sending the last
+	// one again, negotiated as of RFC 2349, in whole seconds from 1 to
+	// 255. 0 is 1 second.
+	Timeout time.Duration
+
+	// Retransmit is how many times a packet is sent again before the
+	// fetch fails. 0 is 10 times.
+	Retransmit int
+}
+
+// clientOpts returns the options of the TFTP client of o.
+func (o TFTPOptions) clientOpts() []tftp.ClientOpt {
+	opts := []tftp.ClientOpt{
+		tftp.ClientMode(tftp.ModeOctet),
+		tftp.ClientBlocksize(DefaultTFTPBlocksize),
+		tftp.ClientWindowsize(DefaultTFTPWindowsize),
+	}
+	if o.Blocksize != 0 {
+		opts = append(opts, tftp.ClientBlocksize(o.Blocksize))
+	}
+	if o.Windowsize != 0 {
+		opts = append(opts, tftp.ClientWindowsize(o.Windowsize))
+	}
+	if o.Timeout != 0 {
+		// Round up, not to time out sooner than asked to.
+		opts = append(opts, tftp.ClientTimeout(int((o.Timeout+time.Second-1)/time.Second)))
+	}
+	if o.Retransmit != 0 {
+		opts = append(opts, tftp.ClientRetransmit(o.Retransmit))
+	}
+	return opts
+}
+
+// NewTFTPClientWithOptions returns a TFTP client with the options o.
+func NewTFTPClientWithOptions(o TFTPOptions) (FileScheme, error) {
+	opts := o.clientOpts()
+	// Check the options now, rather than at every fetch.
+	if _, err := tftp.NewClient(opts...); err != nil {
+		return nil, fmt.Errorf("invalid TFTP options %+v: %v", o, err)
+	}
+	return NewTFTPClient(opts...), nil
+}
+
+// ParseTFTPOptions parses TFTP options from a comma separated list of
+// NAME=VALUE, e.g. for a command line flag:
+//
+//	blksize=1468,windowsize=16,timeout=2s,retransmit=20
+//
+// An empty spec gives the default options.
+func ParseTFTPOptions(spec string) (TFTPOptions, error) {
+	var o TFTPOptions
+	if spec == "" {
+		return o, nil
+	}
+	for _, opt := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			return o, fmt.Errorf("invalid TFTP option %q: want NAME=VALUE", opt)
+		}
+		var err error
+		switch name {
+		case "blksize":
+			o.Blocksize, err = strconv.Atoi(value)
+		case "windowsize":
+			o.Windowsize, err = strconv.Atoi(value)
+		case "timeout":
+			o.Timeout, err = time.ParseDuration(value)
+		case "retransmit":
+			o.Retransmit, err = strconv.Atoi(value)
+		default:
+			return o, fmt.Errorf("unknown TFTP option %q", name)
+		}
+		if err != nil {
+			return o, fmt.Errorf("invalid TFTP option %q: %v", opt, err)
+		}
+	}
+	return o, nil
+}
+
+// WithTFTPClient returns schemes like s, that fetch tftp:// URLs with t.
+func (s Schemes) WithTFTPClient(t FileScheme) Schemes {
+	r := make(Schemes, len(s))
+	for scheme, fs := range s {
+		r[scheme] = fs
+	}
+	r["tftp"] = t
+	return r
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"pack.ag/tftp"
+)
+
+func TestParseTFTPOptions(t *testing.T) {
+	for _, tt := range []struct {
+		spec    string
+		want    TFTPOptions
+		wantErr bool
+	}{
+		{spec: "", want: TFTPOptions{}},
+		{spec: "blksize=1468", want: TFTPOptions{Blocksize: 1468}},
+		{
+			spec: "blksize=1468,windowsize=16,timeout=2s,retransmit=20",
+			want: TFTPOptions{Blocksize: 1468, Windowsize: 16, Timeout: 2 * time.Second, Retransmit: 20},
+		},
+		{spec: "windowsize", wantErr: true},
+		{spec: "blksize=big", wantErr: true},
+		{spec: "timeout=2", wantErr: true},
+		{spec: "tsize=1", wantErr: true},
+	} {
+		got, err := ParseTFTPOptions(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTFTPOptions(%q) = %v, want error %t", tt.spec, err, tt.wantErr)
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("ParseTFTPOptions(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestNewTFTPClientWithOptions(t *testing.T) {
+	for _, tt := range []struct {
+		o       TFTPOptions
+		wantErr bool
+	}{
+		{o: TFTPOptions{}},
+		{o: TFTPOptions{Blocksize: 65464, Windowsize: 65535, Timeout: 255 * time.Second, Retransmit: 1}},
+		// Rounded up to 1 second.
+		{o: TFTPOptions{Timeout: time.Millisecond}},
+		{o: TFTPOptions{Blocksize: 4}, wantErr: true},
+		{o: TFTPOptions{Blocksize: 65465}, wantErr: true},
+		{o: TFTPOptions{Windowsize: 65536}, wantErr: true},
+		{o: TFTPOptions{Timeout: 256 * time.Second}, wantErr: true},
+		{o: TFTPOptions{Retransmit: -1}, wantErr: true},
+	} {
+		if _, err := NewTFTPClientWithOptions(tt.o); (err != nil) != tt.wantErr {
+			t.Errorf("NewTFTPClientWithOptions(%+v) = %v, want error %t", tt.o, err, tt.wantErr)
+		}
+	}
+}
+
+// tftpServer serves files from a map over TFTP.
+type tftpServer map[string][]byte
+
+// ServeTFTP implements tftp.ReadHandler.
+func (s tftpServer) ServeTFTP(r tftp.ReadRequest) {
+	b, ok := s[r.Name()]
+	if !ok {
+		r.WriteError(tftp.ErrCodeFileNotFound, "not found")
+		return
+	}
+	r.WriteSize(int64(len(b)))
+	if _, err := r.Write(b); err != nil {
+		r.WriteError(tftp.ErrCodeNotDefined, err.Error())
+	}
+}
+
+func TestTFTPFetch(t *testing.T) {
+	kernel := make([]byte, 3<<20+17)
+	for i := range kernel {
+		kernel[i] = byte(i * 7)
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("no UDP on localhost: %v", err)
+	}
+	s, err := tftp.NewServer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ReadHandler(tftpServer{"vmlinuz": kernel})
+	go s.Serve(conn)
+	defer s.Close()
+
+	u := &url.URL{Scheme: "tftp", Host: conn.LocalAddr().String(), Path: "/vmlinuz"}
+	for _, o := range []TFTPOptions{
+		{},
+		{Blocksize: 512, Windowsize: 1},
+		{Blocksize: 8192, Windowsize: 4, Timeout: 2 * time.Second},
+	} {
+		t.Run(fmt.Sprintf("%+v", o), func(t *testing.T) {
+			c, err := NewTFTPClientWithOptions(o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := DefaultSchemes.WithTFTPClient(c)
+			if s["tftp"] != c {
+				t.Errorf("WithTFTPClient did not set the tftp scheme")
+			}
+			r, err := s.FetchWithoutCache(context.Background(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, kernel) {
+				t.Errorf("fetched %d bytes, want the %d bytes of the kernel", len(b), len(kernel))
+			}
+		})
+	}
+	if DefaultSchemes["tftp"] != DefaultTFTPClient {
+		t.Errorf("WithTFTPClient changed DefaultSchemes")
+	}
+}
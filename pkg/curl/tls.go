@@ -0,0 +1,139 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrPinMismatch is returned when no key of a server matches the pinned keys.
+var ErrPinMismatch = errors.New("server key does not match any pinned key")
+
+// TLSOptions configures HTTPS fetches, for provisioning networks with their
+// own CAs and client certificates.
+type TLSOptions struct {
+	// CAFile is a PEM bundle of the CA certificates to trust instead of
+	// the system's.
+	CAFile string
+
+	// CertFile and KeyFile are the PEM client certificate and key to
+	// present to servers that ask for one.
+	CertFile string
+	KeyFile  string
+
+	// Pins are the SHA-256 hashes of the public keys (SubjectPublicKeyInfo)
+	// that servers may have, as sha256//BASE64, like curl's
+	// --pinnedpubkey. A server matches if its certificate, or one of the
+	// intermediates it sends, has a pinned key. Pins do not replace
+	// certificate verification.
+	Pins []string
+}
+
+// IsZero returns whether o changes nothing.
+func (o TLSOptions) IsZero() bool {
+	return o.CAFile == "" && o.CertFile == "" && o.KeyFile == "" && len(o.Pins) == 0
+}
+
+// Config returns the TLS configuration of o.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if o.CAFile != "" {
+		b, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s: no PEM certificates", o.CAFile)
+		}
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		key := o.KeyFile
+		if key == "" {
+			// The key may be in the same file.
+			key = o.CertFile
+		}
+		c, err := tls.LoadX509KeyPair(o.CertFile, key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{c}
+	}
+	if len(o.Pins) > 0 {
+		pins, err := parsePins(o.Pins)
+		if err != nil {
+			return nil, err
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, c := range cs.PeerCertificates {
+				h := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+				for _, p := range pins {
+					if bytes.Equal(h[:], p) {
+						return nil
+					}
+				}
+			}
+			return ErrPinMismatch
+		}
+	}
+	return cfg, nil
+}
+
+// parsePins parses sha256//BASE64 public key pins.
+func parsePins(ss []string) ([][]byte, error) {
+	var pins [][]byte
+	for _, s := range ss {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, "sha256//"))
+		if err != nil || !strings.HasPrefix(s, "sha256//") || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid public key pin %q, want sha256//BASE64", s)
+		}
+		pins = append(pins, b)
+	}
+	return pins, nil
+}
+
+// PinOf returns the pin of the public key of c, for TLSOptions.Pins.
+func PinOf(c *x509.Certificate) string {
+	h := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+	return "sha256//" + base64.StdEncoding.EncodeToString(h[:])
+}
+
+// TLSClient returns a copy of c, or of http.DefaultClient if c is nil, that
+// makes HTTPS requests with cfg, e.g. for NewHTTPClient. It keeps the proxy of
+// c, so it can be given a ProxyClient.
+func TLSClient(c *http.Client, cfg *tls.Config) *http.Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	t, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	t.TLSClientConfig = cfg
+	nc := *c
+	nc.Transport = t
+	return &nc
+}
+
+// WithHTTPClient returns a copy of s that fetches HTTP and HTTPS URLs with h,
+// e.g. an HTTPClient with a TLSClient.
+func (s Schemes) WithHTTPClient(h FileScheme) Schemes {
+	r := make(Schemes, len(s))
+	for scheme, fs := range s {
+		r[scheme] = fs
+	}
+	r["http"], r["https"] = h, h
+	return r
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM writes the PEM blocks, by type, to the file name in dir.
+func writePEM(t *testing.T, dir, name string, blocks map[string][]byte) string {
+	t.Helper()
+	var b []byte
+	for typ, der := range blocks {
+		b = append(b, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})...)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clientCert returns the DER certificate and key of a self-signed client
+// certificate.
+func clientCert(t *testing.T) (cert, key []byte) {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, &k.PublicKey, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err = x509.MarshalPKCS8PrivateKey(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestTLSOptions(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "no client certificate", http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, "kernel")
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	s.StartTLS()
+	defer s.Close()
+
+	dir := t.TempDir()
+	ca := writePEM(t, dir, "ca.pem", map[string][]byte{"CERTIFICATE": s.Certificate().Raw})
+	cert, key := clientCert(t)
+	certFile := writePEM(t, dir, "cert.pem", map[string][]byte{"CERTIFICATE": cert})
+	keyFile := writePEM(t, dir, "key.pem", map[string][]byte{"PRIVATE KEY": key})
+	both := writePEM(t, dir, "both.pem", map[string][]byte{"CERTIFICATE": cert, "PRIVATE KEY": key})
+	pin := PinOf(s.Certificate())
+	otherPin := "sha256//" + "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+	for _, tt := range []struct {
+		name    string
+		opts    TLSOptions
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name:    "untrusted server",
+			opts:    TLSOptions{CertFile: certFile, KeyFile: keyFile},
+			wantErr: true,
+		},
+		{
+			name: "CA and client certificate",
+			opts: TLSOptions{CAFile: ca, CertFile: certFile, KeyFile: keyFile},
+		},
+		{
+			name: "key in the certificate file",
+			opts: TLSOptions{CAFile: ca, CertFile: both},
+		},
+		{
+			name:    "no client certificate",
+			opts:    TLSOptions{CAFile: ca},
+			wantErr: true,
+		},
+		{
+			name: "pinned key",
+			opts: TLSOptions{CAFile: ca, CertFile: certFile, KeyFile: keyFile, Pins: []string{otherPin, pin}},
+		},
+		{
+			name:    "other pinned key",
+			opts:    TLSOptions{CAFile: ca, CertFile: certFile, KeyFile: keyFile, Pins: []string{otherPin}},
+			wantErr: true,
+			errIs:   ErrPinMismatch,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.opts.Config()
+			if err != nil {
+				t.Fatal(err)
+			}
+			schemes := DefaultSchemes.WithHTTPClient(NewHTTPClient(TLSClient(nil, cfg)))
+			u, err := url.Parse(s.URL + "/kernel")
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := schemes.FetchWithoutCache(context.Background(), u)
+			if err == nil {
+				var b []byte
+				b, err = io.ReadAll(r)
+				if err == nil && string(b) != "kernel" {
+					t.Errorf("fetched %q, want %q", b, "kernel")
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetch = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.errIs != nil && !errors.Is(err, tt.errIs) {
+				t.Errorf("fetch = %v, want %v", err, tt.errIs)
+			}
+		})
+	}
+}
+
+func TestTLSOptionsErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []TLSOptions{
+		{CAFile: notPEM},
+		{CAFile: filepath.Join(dir, "missing.pem")},
+		{CertFile: notPEM},
+		{Pins: []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}},
+		{Pins: []string{"sha256//AAAA"}},
+		{Pins: []string{"sha256//not base64"}},
+	} {
+		if _, err := o.Config(); err == nil {
+			t.Errorf("%+v.Config() = nil, want error", o)
+		}
+	}
+	if !(TLSOptions{}).IsZero() {
+		t.Errorf("zero TLSOptions is not IsZero")
+	}
+}
+
+func TestTLSClientKeepsProxy(t *testing.T) {
+	p, err := ParseProxy("socks5://127.0.0.1:1080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &tls.Config{ServerName: "boot"}
+	c := TLSClient(ProxyClient(p), cfg)
+	tr := c.Transport.(*http.Transport)
+	if tr.TLSClientConfig != cfg {
+		t.Errorf("TLSClient did not set the TLS config")
+	}
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	if got, err := tr.Proxy(req); err != nil || got.String() != p.String() {
+		t.Errorf("proxy = %v, %v, want %v", got, err, p)
+	}
+	if http.DefaultTransport.(*http.Transport).TLSClientConfig == cfg {
+		t.Errorf("TLSClient changed http.DefaultTransport")
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/u-root/u-root/pkg/vfile"
+	"golang.org/x/crypto/openpgp"
+)
+
+// ErrNoVerification is returned by FetchVerified and FetchAndVerify if
+// VerifyOpts has nothing to verify a file with.
+var ErrNoVerification = errors.New("no digest or key ring to verify with")
+
+// VerifyOpts are how FetchVerified and FetchAndVerify verify a file. All that
+// are set must pass.
+type VerifyOpts struct {
+	// SHA256 and SHA512 are expected digests of the file.
+	SHA256 []byte
+	SHA512 []byte
+
+	// KeyRing verifies the detached OpenPGP signatures of the file at
+	// SigURL, or at the URL of the file with ".sig" appended.
+	KeyRing openpgp.KeyRing
+	SigURL  *url.URL
+
+	// TempDir is the directory FetchAndVerify puts the file in, or
+	// os.TempDir() if empty.
+	TempDir string
+}
+
+// sigURL returns the URL of the signatures of the file at u.
+func (o *VerifyOpts) sigURL(u *url.URL) *url.URL {
+	if o.SigURL != nil {
+		return o.SigURL
+	}
+	sig := *u
+	sig.Path += ".sig"
+	sig.RawPath = ""
+	return &sig
+}
+
+// FetchVerified calls FetchVerified on DefaultSchemes.
+func FetchVerified(ctx context.Context, u *url.URL, opts VerifyOpts) (FileWithCache, error) {
+	return DefaultSchemes.FetchVerified(ctx, u, opts)
+}
+
+// FetchVerified fetches the file at u into memory, and verifies it as opts
+// says. It fails closed: the file is returned only if it verifies, and opts
+// must have something to verify it with.
+//
+// Verification errors are vfile.ErrInvalidHash or vfile.ErrUnsigned, in a
+// URLError.
+func (s Schemes) FetchVerified(ctx context.Context, u *url.URL, opts VerifyOpts) (FileWithCache, error) {
+	r, f, err := s.fetchVerifying(ctx, u, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer closeReader(f)
+
+	// The verifying readers return the verification error instead of
+	// io.EOF, so nothing unverified is returned.
+	b, err := io.ReadAll(r)
+	if err != nil {
+		trace.Trace("verification failed", "url", u, "err", err)
+		return nil, &URLError{URL: u, Err: err}
+	}
+	return &cacheFile{ReaderAt: bytes.NewReader(b), url: u}, nil
+}
+
+// FetchAndVerify calls FetchAndVerify on DefaultSchemes.
+func FetchAndVerify(ctx context.Context, u *url.URL, opts VerifyOpts) (*VerifiedFile, error) {
+	return DefaultSchemes.FetchAndVerify(ctx, u, opts)
+}
+
+// FetchAndVerify is FetchVerified, but fetches the file into a temporary
+// file in opts.TempDir rather than into memory, for large kernels and
+// initramfs images, and for kexec_file_load, which loads files. The file is
+// removed when it is closed, or if it does not verify.
+func (s Schemes) FetchAndVerify(ctx context.Context, u *url.URL, opts VerifyOpts) (*VerifiedFile, error) {
+	r, f, err := s.fetchVerifying(ctx, u, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer closeReader(f)
+
+	tmp, err := os.CreateTemp(opts.TempDir, "curl-"+path.Base(u.Path)+"-")
+	if err != nil {
+		return nil, &URLError{URL: u, Err: err}
+	}
+	v := &VerifiedFile{f: tmp, url: u}
+	if v.size, err = io.Copy(tmp, r); err != nil {
+		trace.Trace("verification failed", "url", u, "err", err)
+		v.Close()
+		return nil, &URLError{URL: u, Err: err}
+	}
+	return v, nil
+}
+
+// fetchVerifying starts fetching the file at u, and returns a reader of it
+// that returns the verification error of opts instead of io.EOF, and the
+// fetched file, to close.
+func (s Schemes) fetchVerifying(ctx context.Context, u *url.URL, opts VerifyOpts) (io.Reader, FileWithoutCache, error) {
+	if opts.SHA256 == nil && opts.SHA512 == nil && opts.KeyRing == nil {
+		return nil, nil, &URLError{URL: u, Err: ErrNoVerification}
+	}
+
+	var sig []byte
+	if opts.KeyRing != nil {
+		su := opts.sigURL(u)
+		f, err := s.FetchWithoutCache(ctx, su)
+		if err != nil {
+			return nil, nil, &URLError{URL: u, Err: vfile.ErrUnsigned{Path: u.String(), Err: err}}
+		}
+		sig, err = vfile.ReadSignature(f)
+		closeReader(f)
+		if err != nil {
+			return nil, nil, &URLError{URL: u, Err: vfile.ErrUnsigned{Path: u.String(), Err: err}}
+		}
+	}
+
+	f, err := s.FetchWithoutCache(ctx, u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var r io.Reader = f
+	for _, d := range []struct {
+		h    crypto.Hash
+		want []byte
+	}{
+		{crypto.SHA256, opts.SHA256},
+		{crypto.SHA512, opts.SHA512},
+	} {
+		if d.want == nil {
+			continue
+		}
+		if r, err = vfile.NewVerifyingReader(r, u.String(), d.h, d.want); err != nil {
+			closeReader(f)
+			return nil, nil, &URLError{URL: u, Err: err}
+		}
+	}
+	if opts.KeyRing != nil {
+		if r, err = vfile.NewSignedVerifyingReader(opts.KeyRing, r, u.String(), sig); err != nil {
+			closeReader(f)
+			return nil, nil, &URLError{URL: u, Err: err}
+		}
+	}
+	return r, f, nil
+}
+
+// VerifiedFile is a file fetched and verified by FetchAndVerify, kept in a
+// temporary file until it is closed.
+type VerifiedFile struct {
+	f    *os.File
+	url  *url.URL
+	size int64
+}
+
+// ReadAt implements io.ReaderAt.
+func (v *VerifiedFile) ReadAt(p []byte, off int64) (int, error) {
+	return v.f.ReadAt(p, off)
+}
+
+// URL returns the URL the file was fetched from.
+func (v *VerifiedFile) URL() *url.URL {
+	return v.url
+}
+
+// Name returns the path of the temporary file.
+func (v *VerifiedFile) Name() string {
+	return v.f.Name()
+}
+
+// Size returns the size of the file.
+func (v *VerifiedFile) Size() (int64, error) {
+	return v.size, nil
+}
+
+// Close closes and removes the temporary file.
+func (v *VerifiedFile) Close() error {
+	err := v.f.Close()
+	if rerr := os.Remove(v.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Keep closes the file and moves it to path, rather than removing it, e.g. to
+// only write files that verify where they are downloaded to. path must be on
+// the file system of the temporary file, e.g. in TempDir.
+func (v *VerifiedFile) Keep(path string) error {
+	if err := v.f.Close(); err != nil {
+		os.Remove(v.f.Name())
+		return err
+	}
+	if err := os.Rename(v.f.Name(), path); err != nil {
+		os.Remove(v.f.Name())
+		return err
+	}
+	return nil
+}
+
+// closeReader closes the reader of the fetched file f, if it can be closed,
+// e.g. the body of an HTTP response.
+func closeReader(f FileWithoutCache) {
+	var r io.Reader = f
+	if ff, ok := f.(*file); ok {
+		r = ff.Reader
+	}
+	if c, ok := r.(io.Closer); ok {
+		c.Close()
+	}
+}
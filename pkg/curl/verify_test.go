@@ -0,0 +1,151 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/vfile"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestFetchVerified(t *testing.T) {
+	conf := &packet.Config{RSABits: 1024}
+	key, err := openpgp.NewEntity("boot", "", "boot@example.com", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := openpgp.NewEntity("other", "", "other@example.com", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const kernel = "bzImage"
+	var sig bytes.Buffer
+	if err := vfile.DetachSign(&sig, []*openpgp.Entity{key}, []byte(kernel), false, conf); err != nil {
+		t.Fatal(err)
+	}
+	sum256 := sha256.Sum256([]byte(kernel))
+	sum512 := sha512.Sum512([]byte(kernel))
+
+	m := NewMockScheme("http")
+	m.Add("boot", "/vmlinuz", kernel)
+	m.Add("boot", "/vmlinuz.sig", sig.String())
+	m.Add("boot", "/other.sig", "not a signature")
+	s := Schemes{"http": m}
+	u := &url.URL{Scheme: "http", Host: "boot", Path: "/vmlinuz"}
+
+	for _, tt := range []struct {
+		name    string
+		opts    VerifyOpts
+		wantErr interface{}
+	}{
+		{name: "sha256", opts: VerifyOpts{SHA256: sum256[:]}},
+		{name: "sha512", opts: VerifyOpts{SHA512: sum512[:]}},
+		{name: "signature", opts: VerifyOpts{KeyRing: openpgp.EntityList{key}}},
+		{name: "all", opts: VerifyOpts{SHA256: sum256[:], SHA512: sum512[:], KeyRing: openpgp.EntityList{key}}},
+		{name: "wrong sha256", opts: VerifyOpts{SHA256: sum512[:32]}, wantErr: &vfile.ErrInvalidHash{}},
+		{name: "one wrong digest", opts: VerifyOpts{SHA256: sum256[:], SHA512: sum256[:]}, wantErr: &vfile.ErrInvalidHash{}},
+		{name: "wrong key", opts: VerifyOpts{KeyRing: openpgp.EntityList{other}}, wantErr: &vfile.ErrUnsigned{}},
+		{
+			name:    "bad signature",
+			opts:    VerifyOpts{KeyRing: openpgp.EntityList{key}, SigURL: &url.URL{Scheme: "http", Host: "boot", Path: "/other.sig"}},
+			wantErr: &vfile.ErrUnsigned{},
+		},
+		{
+			name:    "no signature",
+			opts:    VerifyOpts{KeyRing: openpgp.EntityList{key}, SigURL: &url.URL{Scheme: "http", Host: "boot", Path: "/none.sig"}},
+			wantErr: &vfile.ErrUnsigned{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := s.FetchVerified(context.Background(), u, tt.opts)
+			if tt.wantErr != nil {
+				if f != nil {
+					t.Errorf("FetchVerified returned a file that did not verify")
+				}
+				if !errors.As(err, tt.wantErr) {
+					t.Errorf("FetchVerified = %v, want %T", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<20))
+			if err != nil || string(b) != kernel {
+				t.Errorf("FetchVerified read %q, %v, want %q", b, err, kernel)
+			}
+		})
+	}
+
+	if _, err := s.FetchVerified(context.Background(), u, VerifyOpts{}); !errors.Is(err, ErrNoVerification) {
+		t.Errorf("FetchVerified without options = %v, want %v", err, ErrNoVerification)
+	}
+}
+
+func TestFetchAndVerify(t *testing.T) {
+	const kernel = "bzImage"
+	sum := sha256.Sum256([]byte(kernel))
+	m := NewMockScheme("http")
+	m.Add("boot", "/vmlinuz", kernel)
+	s := Schemes{"http": m}
+	u := &url.URL{Scheme: "http", Host: "boot", Path: "/vmlinuz"}
+	dir := t.TempDir()
+
+	f, err := s.FetchAndVerify(context.Background(), u, VerifyOpts{SHA256: sum[:], TempDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(f.Name()) != dir {
+		t.Errorf("fetched into %s, want a file in %s", f.Name(), dir)
+	}
+	if size, err := f.Size(); err != nil || size != int64(len(kernel)) {
+		t.Errorf("Size = %d, %v, want %d", size, err, len(kernel))
+	}
+	b, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<20))
+	if err != nil || string(b) != kernel {
+		t.Errorf("FetchAndVerify read %q, %v, want %q", b, err, kernel)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed when closed: %v", f.Name(), err)
+	}
+
+	f, err = s.FetchAndVerify(context.Background(), u, VerifyOpts{SHA256: sum[:], TempDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(t.TempDir(), "vmlinuz")
+	if err := f.Keep(kept); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(kept); err != nil || string(b) != kernel {
+		t.Errorf("kept file = %q, %v, want %q", b, err, kernel)
+	}
+
+	// Files that do not verify are not kept.
+	wrong := sha256.Sum256([]byte("other"))
+	if f, err := s.FetchAndVerify(context.Background(), u, VerifyOpts{SHA256: wrong[:], TempDir: dir}); f != nil || !errors.As(err, &vfile.ErrInvalidHash{}) {
+		t.Errorf("FetchAndVerify = %v, %v, want %T", f, err, vfile.ErrInvalidHash{})
+	}
+	if _, err := s.FetchAndVerify(context.Background(), u, VerifyOpts{TempDir: dir}); !errors.Is(err, ErrNoVerification) {
+		t.Errorf("FetchAndVerify without options = %v, want %v", err, ErrNoVerification)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("files left in %s: %v", dir, files)
+	}
+}
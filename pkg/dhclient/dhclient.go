@@ -23,10 +23,14 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4/nclient4"
 	"github.com/insomniacslk/dhcp/dhcpv6"
 	"github.com/insomniacslk/dhcp/dhcpv6/nclient6"
+	"github.com/u-root/u-root/pkg/ulog"
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
 )
 
+// trace traces DHCP requests, with -trace=dhclient.
+var trace = ulog.Tracer("dhclient")
+
 // isIpv6LinkReady returns true if the interface has a link-local address
 // which is not tentative.
 func isIpv6LinkReady(l netlink.Link) (bool, error) {
@@ -73,7 +77,9 @@ func IfUp(ifname string, linkUpTimeout time.Duration) (netlink.Link, error) {
 	return nil, fmt.Errorf("link %q still down after %v seconds", ifname, linkUpTimeout.Seconds())
 }
 
-// WriteDNSSettings writes the given nameservers, search list, and domain to resolv.conf.
+// WriteDNSSettings writes the given nameservers, search list, and domain to
+// resolv.conf, replacing any other settings. Leases merge their settings with
+// DefaultResolver instead.
 func WriteDNSSettings(ns []net.IP, sl []string, domain string) error {
 	rc := &bytes.Buffer{}
 	if domain != "" {
@@ -190,7 +196,7 @@ func lease4(ctx context.Context, iface netlink.Link, c Config) (Lease, error) {
 	// Prepend modifiers with default options, so they can be overriden.
 	reqmods := append(
 		[]dhcpv4.Modifier{
-			dhcpv4.WithOption(dhcpv4.OptClassIdentifier("PXE UROOT")),
+			dhcpv4.WithOption(dhcpv4.OptClassIdentifier(DefaultVendorClass)),
 			dhcpv4.WithRequestedOptions(dhcpv4.OptionSubnetMask),
 			dhcpv4.WithNetboot,
 		},
@@ -204,13 +210,16 @@ func lease4(ctx context.Context, iface netlink.Link, c Config) (Lease, error) {
 	}
 
 	log.Printf("Attempting to get DHCPv4 lease on %s", iface.Attrs().Name)
+	trace.Trace("request", "iface", iface.Attrs().Name, "proto", "dhcpv4", "timeout", c.Timeout, "retries", c.Retries)
 	lease, err := client.Request(ctx, reqmods...)
 	if err != nil {
+		trace.Trace("request failed", "iface", iface.Attrs().Name, "proto", "dhcpv4", "err", err)
 		return nil, err
 	}
 
 	packet := NewPacket4(iface, lease.ACK)
 	log.Printf("Got DHCPv4 lease on %s: %v", iface.Attrs().Name, lease.ACK.Summary())
+	trace.Trace("lease", "iface", iface.Attrs().Name, "proto", "dhcpv4", "ip", lease.ACK.YourIPAddr, "server", lease.ACK.ServerIPAddr, "bootfile", lease.ACK.BootFileName)
 	return packet, nil
 }
 
@@ -280,10 +289,13 @@ func lease6(ctx context.Context, iface netlink.Link, c Config, linkUpTimeout tim
 		c.Modifiers6...)
 
 	log.Printf("Attempting to get DHCPv6 lease on %s", iface.Attrs().Name)
+	trace.Trace("request", "iface", iface.Attrs().Name, "proto", "dhcpv6", "timeout", c.Timeout, "retries", c.Retries)
 	p, err := client.RapidSolicit(ctx, reqmods...)
 	if err != nil {
+		trace.Trace("request failed", "iface", iface.Attrs().Name, "proto", "dhcpv6", "err", err)
 		return nil, err
 	}
+	trace.Trace("lease", "iface", iface.Attrs().Name, "proto", "dhcpv6")
 
 	packet := NewPacket6(iface, p)
 	log.Printf("Got DHCPv6 lease on %s: %v", iface.Attrs().Name, p.Summary())
@@ -111,7 +111,8 @@ func (p *Packet4) Configure() error {
 	}
 
 	nameServers, searchList, domain := p.GatherDNSSettings()
-	if err := WriteDNSSettings(nameServers, searchList, domain); err != nil {
+	dns := DNSConfig{Nameservers: nameServers, Search: searchList, Domain: domain}
+	if err := DefaultResolver.Set("dhcp4-"+p.iface.Attrs().Name, PriorityDHCP, dns); err != nil {
 		return err
 	}
 
@@ -83,7 +83,7 @@ func (p *Packet6) Configure() error {
 	}
 
 	if ips := p.DNS(); ips != nil {
-		if err := WriteDNSSettings(ips, nil, ""); err != nil {
+		if err := DefaultResolver.Set("dhcp6-"+p.iface.Attrs().Name, PriorityDHCP, DNSConfig{Nameservers: ips}); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,91 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhclient
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// LeaseHostname returns the host name the DHCPv4 server assigned (option 12),
+// or "" if there was none.
+func LeaseHostname(l Lease) string {
+	if m4, _ := l.Message(); m4 != nil {
+		return m4.HostName()
+	}
+	return ""
+}
+
+// ExpandHostname expands ${hostname}, ${mac}, ${ip} and ${iface} in template
+// for the lease l. ${hostname} is the DHCP host name, and ${mac} and ${ip} are
+// written with dashes so that they can be used in a host name.
+//
+// It returns "" without error if template uses ${hostname} and the server sent
+// none.
+func ExpandHostname(template string, l Lease) (string, error) {
+	vars := map[string]string{
+		"hostname": LeaseHostname(l),
+		"iface":    l.Link().Attrs().Name,
+		"mac":      strings.ReplaceAll(l.Link().Attrs().HardwareAddr.String(), ":", "-"),
+	}
+	if m4, m6 := l.Message(); m4 != nil {
+		vars["ip"] = strings.ReplaceAll(m4.YourIPAddr.String(), ".", "-")
+	} else if p, ok := l.(*Packet6); ok && m6 != nil && p.Lease() != nil {
+		vars["ip"] = strings.ReplaceAll(p.Lease().IPv6Addr.String(), ":", "-")
+	}
+
+	var err error
+	missing := false
+	name := os.Expand(template, func(k string) string {
+		v, ok := vars[k]
+		if !ok {
+			err = fmt.Errorf("unknown variable ${%s} in host name template %q", k, template)
+		}
+		if v == "" {
+			missing = true
+		}
+		return v
+	})
+	if err != nil || missing {
+		return "", err
+	}
+	return name, nil
+}
+
+// ValidHostname reports whether name is a valid host name (RFC 1123): dot
+// separated labels of letters, digits and dashes that don't start or end with
+// a dash.
+func ValidHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, l := range strings.Split(name, ".") {
+		if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for _, r := range l {
+			if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SetHostname sets the kernel host name to the first label of name and writes
+// name to /etc/hostname.
+func SetHostname(name string) error {
+	if !ValidHostname(name) {
+		return fmt.Errorf("invalid host name %q", name)
+	}
+	short := strings.SplitN(name, ".", 2)[0]
+	if err := unix.Sethostname([]byte(short)); err != nil {
+		return fmt.Errorf("setting host name: %v", err)
+	}
+	return os.WriteFile("/etc/hostname", []byte(name+"\n"), 0o644)
+}
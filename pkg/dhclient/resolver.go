@@ -0,0 +1,255 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhclient
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Priorities of DNS settings; settings with a lower priority come first in
+// resolv.conf.
+const (
+	PriorityStatic  = 10
+	PriorityCmdline = 20
+	PriorityDHCP    = 30
+)
+
+// MaxNameservers is the number of nameservers libc resolvers use.
+const MaxNameservers = 3
+
+// DNSConfig is the DNS part of a network configuration.
+type DNSConfig struct {
+	Nameservers []net.IP
+	Search      []string
+	Domain      string
+}
+
+func (c DNSConfig) empty() bool {
+	return len(c.Nameservers) == 0 && len(c.Search) == 0 && c.Domain == ""
+}
+
+// ParseResolvConf reads the nameserver, search and domain lines of a
+// resolv.conf file.
+func ParseResolvConf(r io.Reader) (DNSConfig, error) {
+	var c DNSConfig
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 2 || strings.HasPrefix(f[0], "#") || strings.HasPrefix(f[0], ";") {
+			continue
+		}
+		switch f[0] {
+		case "nameserver":
+			// A zone such as fe80::1%eth0 is not parsed.
+			if ip := net.ParseIP(f[1]); ip != nil {
+				c.Nameservers = append(c.Nameservers, ip)
+			}
+		case "search":
+			c.Search = append(c.Search, f[1:]...)
+		case "domain":
+			c.Domain = f[1]
+		}
+	}
+	return c, s.Err()
+}
+
+// render formats c as resolv.conf. The domain and search lines override each
+// other, so the domain is written as the first search domain when there is a
+// search list.
+func (c DNSConfig) render() []byte {
+	var b bytes.Buffer
+	for _, ip := range c.Nameservers {
+		fmt.Fprintf(&b, "nameserver %s\n", ip)
+	}
+	search := c.Search
+	if c.Domain != "" && len(search) > 0 && search[0] != c.Domain {
+		search = append([]string{c.Domain}, search...)
+	}
+	if len(search) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(search, " "))
+	} else if c.Domain != "" {
+		fmt.Fprintf(&b, "domain %s\n", c.Domain)
+	}
+	return b.Bytes()
+}
+
+// Resolver merges the DNS settings of several sources, such as the DHCP
+// leases of each interface, the kernel command line and a static file, into
+// one resolv.conf.
+//
+// Each source is kept as a file in Dir named PRIORITY-SOURCE.conf, so
+// separate processes can update their own sources. An image may ship static
+// settings there, e.g. 10-static.conf.
+type Resolver struct {
+	// Path is the resolv.conf to write.
+	Path string
+
+	// Dir holds the settings of each source.
+	Dir string
+}
+
+// DefaultResolver writes /etc/resolv.conf.
+var DefaultResolver = &Resolver{Path: "/etc/resolv.conf", Dir: "/etc/resolv.conf.d"}
+
+func sourceFile(source string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == os.PathSeparator || r == ' ' {
+			return '_'
+		}
+		return r
+	}, source) + ".conf"
+}
+
+// Set replaces the settings of source and rewrites resolv.conf. Settings
+// without nameservers, search list or domain remove the source.
+func (r *Resolver) Set(source string, priority int, c DNSConfig) error {
+	if priority < 0 || priority > 99 {
+		return fmt.Errorf("priority %d is not in [0, 99]", priority)
+	}
+	if err := r.remove(source); err != nil {
+		return err
+	}
+	if !c.empty() {
+		if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+			return err
+		}
+		name := filepath.Join(r.Dir, fmt.Sprintf("%02d-%s", priority, sourceFile(source)))
+		if err := os.WriteFile(name, c.render(), 0o644); err != nil {
+			return err
+		}
+	}
+	return r.Update()
+}
+
+// Remove removes the settings of source and rewrites resolv.conf.
+func (r *Resolver) Remove(source string) error {
+	if err := r.remove(source); err != nil {
+		return err
+	}
+	return r.Update()
+}
+
+func (r *Resolver) remove(source string) error {
+	old, err := filepath.Glob(filepath.Join(r.Dir, "[0-9][0-9]-"+sourceFile(source)))
+	if err != nil {
+		return err
+	}
+	for _, o := range old {
+		if err := os.Remove(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) sources() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(r.Dir, "[0-9][0-9]-*.conf"))
+	sort.Strings(files)
+	return files, err
+}
+
+// Merged returns the settings of all sources combined. Nameservers and search
+// domains are taken in priority order without duplicates. The domain of a
+// source is added to the search list, since resolv.conf only honors one of
+// domain and search.
+func (r *Resolver) Merged() (DNSConfig, error) {
+	files, err := r.sources()
+	if err != nil {
+		return DNSConfig{}, err
+	}
+
+	var m DNSConfig
+	seenNS := map[string]bool{}
+	seenSearch := map[string]bool{}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return DNSConfig{}, err
+		}
+		c, err := ParseResolvConf(f)
+		f.Close()
+		if err != nil {
+			return DNSConfig{}, fmt.Errorf("%s: %v", name, err)
+		}
+		for _, ip := range c.Nameservers {
+			if !seenNS[ip.String()] {
+				seenNS[ip.String()] = true
+				m.Nameservers = append(m.Nameservers, ip)
+			}
+		}
+		// A source's domain is searched like its search list.
+		search := c.Search
+		if c.Domain != "" {
+			search = append([]string{c.Domain}, search...)
+		}
+		for _, s := range search {
+			if !seenSearch[s] {
+				seenSearch[s] = true
+				m.Search = append(m.Search, s)
+			}
+		}
+	}
+	if len(m.Nameservers) > MaxNameservers {
+		m.Nameservers = m.Nameservers[:MaxNameservers]
+	}
+	return m, nil
+}
+
+// Update rewrites resolv.conf from the settings in Dir. It is replaced
+// atomically, so lookups never see a partial file. If Dir holds no settings,
+// resolv.conf is left alone.
+func (r *Resolver) Update() error {
+	if files, err := r.sources(); err != nil || len(files) == 0 {
+		return err
+	}
+	m, err := r.Merged()
+	if err != nil {
+		return err
+	}
+	tmp := r.Path + ".tmp"
+	if err := os.WriteFile(tmp, m.render(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.Path)
+}
+
+// DNSFromCmdline returns the nameservers of the kernel ip= parameter,
+// ip=client:server:gw:netmask:hostname:device:autoconf:dns0:dns1, and of
+// nameserver= parameters, and the host name of ip=.
+func DNSFromCmdline(cmdline string) (DNSConfig, string) {
+	var c DNSConfig
+	var hostname string
+	for _, f := range strings.Fields(cmdline) {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "ip":
+			p := strings.Split(kv[1], ":")
+			if len(p) > 4 && p[4] != "" {
+				hostname = p[4]
+			}
+			for i := 7; i < len(p) && i <= 8; i++ {
+				if ip := net.ParseIP(p[i]); ip != nil {
+					c.Nameservers = append(c.Nameservers, ip)
+				}
+			}
+		case "nameserver":
+			if ip := net.ParseIP(kv[1]); ip != nil {
+				c.Nameservers = append(c.Nameservers, ip)
+			}
+		}
+	}
+	return c, hostname
+}
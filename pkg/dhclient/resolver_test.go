@@ -0,0 +1,155 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhclient
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/vishvananda/netlink"
+)
+
+func ips(s ...string) []net.IP {
+	var r []net.IP
+	for _, v := range s {
+		r = append(r, net.ParseIP(v))
+	}
+	return r
+}
+
+func TestResolver(t *testing.T) {
+	dir := t.TempDir()
+	r := &Resolver{Path: filepath.Join(dir, "resolv.conf"), Dir: filepath.Join(dir, "resolv.conf.d")}
+
+	// Nothing recorded yet: an existing file is kept.
+	if err := os.WriteFile(r.Path, []byte("nameserver 192.0.2.99\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(r.Path); string(b) != "nameserver 192.0.2.99\n" {
+		t.Errorf("Update without sources wrote %q", b)
+	}
+
+	for _, s := range []struct {
+		source   string
+		priority int
+		c        DNSConfig
+	}{
+		{"dhcp4-eth0", PriorityDHCP, DNSConfig{Nameservers: ips("192.0.2.1", "192.0.2.2"), Search: []string{"lab.example"}, Domain: "eth0.example"}},
+		{"dhcp6-eth0", PriorityDHCP, DNSConfig{Nameservers: ips("2001:db8::1", "192.0.2.1")}},
+		{"cmdline", PriorityCmdline, DNSConfig{Nameservers: ips("192.0.2.53")}},
+		{"static", PriorityStatic, DNSConfig{Search: []string{"corp.example", "lab.example"}}},
+	} {
+		if err := r.Set(s.source, s.priority, s.c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "nameserver 192.0.2.53\nnameserver 192.0.2.1\nnameserver 192.0.2.2\nsearch corp.example lab.example eth0.example\n"
+	if b, _ := os.ReadFile(r.Path); string(b) != want {
+		t.Errorf("resolv.conf = %q, want %q", b, want)
+	}
+
+	// A renewed lease replaces the old settings of its source, and an
+	// empty one removes them.
+	if err := r.Set("dhcp4-eth0", PriorityDHCP, DNSConfig{Nameservers: ips("192.0.2.3")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Set("cmdline", PriorityCmdline, DNSConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := r.Merged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantM := (DNSConfig{Nameservers: ips("192.0.2.3", "2001:db8::1", "192.0.2.1"), Search: []string{"corp.example", "lab.example"}}); !reflect.DeepEqual(m, wantM) {
+		t.Errorf("Merged = %+v, want %+v", m, wantM)
+	}
+
+	if err := r.Remove("static"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Set("x", 100, DNSConfig{}); err == nil {
+		t.Errorf("Set with priority 100 succeeded")
+	}
+	files, _ := filepath.Glob(filepath.Join(r.Dir, "*"))
+	if len(files) != 2 {
+		t.Errorf("source files = %v, want dhcp4-eth0 and dhcp6-eth0", files)
+	}
+}
+
+func TestDNSRender(t *testing.T) {
+	for _, tt := range []struct {
+		c    DNSConfig
+		want string
+	}{
+		{DNSConfig{Domain: "example.com"}, "domain example.com\n"},
+		{DNSConfig{Domain: "example.com", Search: []string{"a.example"}}, "search example.com a.example\n"},
+		{DNSConfig{Nameservers: ips("::1")}, "nameserver ::1\n"},
+	} {
+		if got := string(tt.c.render()); got != tt.want {
+			t.Errorf("render(%+v) = %q, want %q", tt.c, got, tt.want)
+		}
+		c, err := ParseResolvConf(strings.NewReader(tt.want))
+		if err != nil || string(c.render()) != tt.want {
+			t.Errorf("ParseResolvConf(%q) = %+v, %v", tt.want, c, err)
+		}
+	}
+}
+
+func TestDNSFromCmdline(t *testing.T) {
+	c, host := DNSFromCmdline("console=ttyS0 ip=192.0.2.10::192.0.2.1:255.255.255.0:node7:eth0:off:192.0.2.53:192.0.2.54 nameserver=2001:db8::53 nameserver=bogus")
+	if want := ips("192.0.2.53", "192.0.2.54", "2001:db8::53"); !reflect.DeepEqual(c.Nameservers, want) || host != "node7" {
+		t.Errorf("DNSFromCmdline = %v, %q, want %v, node7", c.Nameservers, host, want)
+	}
+	if c, host := DNSFromCmdline("ip=dhcp"); len(c.Nameservers) != 0 || host != "" {
+		t.Errorf("DNSFromCmdline(ip=dhcp) = %v, %q", c, host)
+	}
+}
+
+func TestExpandHostname(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+	link := &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "eth0", HardwareAddr: mac}}
+	named := NewPacket4(link, mustNew(t, dhcpv4.WithYourIP(net.IP{192, 0, 2, 10}), dhcpv4.WithOption(dhcpv4.OptHostName("node7"))))
+	anon := NewPacket4(link, mustNew(t, dhcpv4.WithYourIP(net.IP{192, 0, 2, 11})))
+
+	for _, tt := range []struct {
+		tmpl    string
+		l       Lease
+		want    string
+		wantErr bool
+	}{
+		{tmpl: "${hostname}", l: named, want: "node7"},
+		{tmpl: "${hostname}", l: anon, want: ""},
+		{tmpl: "${hostname}.lab", l: named, want: "node7.lab"},
+		{tmpl: "node-${mac}", l: anon, want: "node-52-54-00-12-34-56"},
+		{tmpl: "${iface}-${ip}", l: anon, want: "eth0-192-0-2-11"},
+		{tmpl: "${serial}", l: anon, wantErr: true},
+	} {
+		got, err := ExpandHostname(tt.tmpl, tt.l)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ExpandHostname(%q) = %q, %v, want %q, error %v", tt.tmpl, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	for name, want := range map[string]bool{
+		"node7":          true,
+		"node7.lab.test": true,
+		"-node":          false,
+		"node_7":         false,
+		"a..b":           false,
+		"":               false,
+	} {
+		if got := ValidHostname(name); got != want {
+			t.Errorf("ValidHostname(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhclient
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// DefaultVendorClass is the vendor class identifier (option 60) that DHCPv4
+// requests are sent with.
+const DefaultVendorClass = "PXE UROOT"
+
+// VendorOptions are the vendor-specific information (option 43) of a lease,
+// decoded into named parameters.
+type VendorOptions map[string]string
+
+// VendorDecoder decodes the vendor-specific information that a server sends
+// to clients of a vendor class.
+type VendorDecoder func(data []byte) (VendorOptions, error)
+
+var (
+	vendorMu       sync.RWMutex
+	vendorDecoders = map[string]VendorDecoder{
+		"PXEClient": DecodePXEOptions,
+	}
+)
+
+// RegisterVendorDecoder registers d for the vendor classes that start with
+// class, e.g. "PXEClient" for "PXEClient:Arch:00000:UNDI:002001". The decoder
+// of the longest matching class is used. A nil d removes the decoder.
+//
+// Sites register decoders for their own classes, often
+// DecodeKeyValueOptions, to pass boot parameters to netboot flows.
+func RegisterVendorDecoder(class string, d VendorDecoder) {
+	vendorMu.Lock()
+	defer vendorMu.Unlock()
+	if d == nil {
+		delete(vendorDecoders, class)
+		return
+	}
+	vendorDecoders[class] = d
+}
+
+var (
+	// ErrNoVendorOptions means there is no vendor-specific information in
+	// the DHCP message.
+	ErrNoVendorOptions = errors.New("no vendor-specific information in DHCP message")
+
+	// ErrNoVendorDecoder means no decoder is registered for the vendor
+	// class.
+	ErrNoVendorDecoder = errors.New("no decoder for vendor class")
+)
+
+// DecodeVendorOptions decodes vendor-specific information data with the
+// decoder registered for class.
+func DecodeVendorOptions(class string, data []byte) (VendorOptions, error) {
+	vendorMu.RLock()
+	var d VendorDecoder
+	n := -1
+	for c, dec := range vendorDecoders {
+		if strings.HasPrefix(class, c) && len(c) > n {
+			d, n = dec, len(c)
+		}
+	}
+	vendorMu.RUnlock()
+	if d == nil {
+		return nil, fmt.Errorf("%w %q", ErrNoVendorDecoder, class)
+	}
+	return d(data)
+}
+
+// VendorOptions decodes the vendor-specific information of the lease. The
+// vendor class is the one the server sent, or DefaultVendorClass.
+func (p *Packet4) VendorOptions() (VendorOptions, error) {
+	data := p.P.Options.Get(dhcpv4.OptionVendorSpecificInformation)
+	if len(data) == 0 {
+		return nil, ErrNoVendorOptions
+	}
+	class := p.P.ClassIdentifier()
+	if class == "" {
+		class = DefaultVendorClass
+	}
+	return DecodeVendorOptions(class, data)
+}
+
+// SubOptions splits encapsulated vendor-specific information into its
+// sub-options, as RFC 2132 section 8.4 describes: a code byte, a length byte
+// and the data. Pad (0) is skipped and parsing stops at End (255).
+func SubOptions(data []byte) (map[uint8][]byte, error) {
+	opts := make(map[uint8][]byte)
+	for len(data) > 0 {
+		code := data[0]
+		if code == 0 {
+			data = data[1:]
+			continue
+		}
+		if code == 255 {
+			break
+		}
+		if len(data) < 2 || len(data) < 2+int(data[1]) {
+			return nil, fmt.Errorf("sub-option %d is truncated", code)
+		}
+		n := int(data[1])
+		// Sub-options that are too long for one are concatenated, as in
+		// RFC 3396.
+		opts[code] = append(opts[code], data[2:2+n]...)
+		data = data[2+n:]
+	}
+	return opts, nil
+}
+
+// DecodeKeyValueOptions decodes sub-options whose data are "key=value"
+// strings, a simple format for site-specific boot parameters.
+func DecodeKeyValueOptions(data []byte) (VendorOptions, error) {
+	subs, err := SubOptions(data)
+	if err != nil {
+		return nil, err
+	}
+	opts := make(VendorOptions)
+	for code, v := range subs {
+		k, val, ok := strings.Cut(string(v), "=")
+		if !ok {
+			return nil, fmt.Errorf("sub-option %d is not key=value: %q", code, v)
+		}
+		opts[k] = val
+	}
+	return opts, nil
+}
+
+// PXE sub-options, from the PXE specification version 2.1.
+const (
+	pxeMTFTPIP            = 1
+	pxeMTFTPClientPort    = 2
+	pxeMTFTPServerPort    = 3
+	pxeMTFTPTimeout       = 4
+	pxeMTFTPDelay         = 5
+	pxeDiscoveryControl   = 6
+	pxeDiscoveryMcastAddr = 7
+	pxeBootServers        = 8
+	pxeBootMenu           = 9
+	pxeMenuPrompt         = 10
+	pxeBootItem           = 71
+)
+
+// DecodePXEOptions decodes the PXE sub-options of PXE servers, which send
+// them to clients of class "PXEClient":
+//
+//	mtftp-ip              multicast TFTP address
+//	mtftp-client-port     multicast TFTP ports
+//	mtftp-server-port
+//	mtftp-timeout         in seconds
+//	mtftp-delay           in seconds
+//	discovery-control     bit field, in decimal
+//	discovery-mcast-addr  an address
+//	boot-servers          TYPE:IP,IP... for each boot server type,
+//	                      separated by spaces
+//	boot-menu             TYPE:DESCRIPTION for each item, separated by
+//	                      newlines
+//	menu-prompt           TIMEOUT:PROMPT
+//	boot-item             TYPE:LAYER
+//
+// Types are in hexadecimal, like 0x8000. Other sub-options are called
+// opt-CODE, with their data in hexadecimal.
+func DecodePXEOptions(data []byte) (VendorOptions, error) {
+	subs, err := SubOptions(data)
+	if err != nil {
+		return nil, err
+	}
+	opts := make(VendorOptions)
+	for code, v := range subs {
+		switch code {
+		case pxeMTFTPIP, pxeDiscoveryMcastAddr:
+			if len(v) != 4 {
+				return nil, fmt.Errorf("PXE sub-option %d: want 4 bytes, got %d", code, len(v))
+			}
+			name := "mtftp-ip"
+			if code == pxeDiscoveryMcastAddr {
+				name = "discovery-mcast-addr"
+			}
+			opts[name] = net.IP(v).String()
+
+		case pxeMTFTPClientPort, pxeMTFTPServerPort:
+			if len(v) != 2 {
+				return nil, fmt.Errorf("PXE sub-option %d: want 2 bytes, got %d", code, len(v))
+			}
+			name := "mtftp-client-port"
+			if code == pxeMTFTPServerPort {
+				name = "mtftp-server-port"
+			}
+			opts[name] = strconv.Itoa(int(binary.BigEndian.Uint16(v)))
+
+		case pxeMTFTPTimeout, pxeMTFTPDelay, pxeDiscoveryControl:
+			if len(v) != 1 {
+				return nil, fmt.Errorf("PXE sub-option %d: want 1 byte, got %d", code, len(v))
+			}
+			name := map[uint8]string{
+				pxeMTFTPTimeout:     "mtftp-timeout",
+				pxeMTFTPDelay:       "mtftp-delay",
+				pxeDiscoveryControl: "discovery-control",
+			}[code]
+			opts[name] = strconv.Itoa(int(v[0]))
+
+		case pxeBootServers:
+			var servers []string
+			for len(v) > 0 {
+				if len(v) < 3 || len(v) < 3+4*int(v[2]) {
+					return nil, errors.New("PXE boot servers are truncated")
+				}
+				n := int(v[2])
+				ips := make([]string, n)
+				for i := range ips {
+					ips[i] = net.IP(v[3+4*i : 7+4*i]).String()
+				}
+				servers = append(servers, fmt.Sprintf("0x%04x:%s", binary.BigEndian.Uint16(v), strings.Join(ips, ",")))
+				v = v[3+4*n:]
+			}
+			opts["boot-servers"] = strings.Join(servers, " ")
+
+		case pxeBootMenu:
+			var items []string
+			for len(v) > 0 {
+				if len(v) < 3 || len(v) < 3+int(v[2]) {
+					return nil, errors.New("PXE boot menu is truncated")
+				}
+				n := int(v[2])
+				items = append(items, fmt.Sprintf("0x%04x:%s", binary.BigEndian.Uint16(v), v[3:3+n]))
+				v = v[3+n:]
+			}
+			opts["boot-menu"] = strings.Join(items, "\n")
+
+		case pxeMenuPrompt:
+			if len(v) < 1 {
+				return nil, errors.New("PXE menu prompt is empty")
+			}
+			opts["menu-prompt"] = fmt.Sprintf("%d:%s", v[0], v[1:])
+
+		case pxeBootItem:
+			if len(v) != 4 {
+				return nil, fmt.Errorf("PXE sub-option %d: want 4 bytes, got %d", code, len(v))
+			}
+			opts["boot-item"] = fmt.Sprintf("0x%04x:%d", binary.BigEndian.Uint16(v), binary.BigEndian.Uint16(v[2:]))
+
+		default:
+			opts[fmt.Sprintf("opt-%d", code)] = hex.EncodeToString(v)
+		}
+	}
+	return opts, nil
+}
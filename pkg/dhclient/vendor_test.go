@@ -0,0 +1,106 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhclient
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+func TestSubOptions(t *testing.T) {
+	got, err := SubOptions([]byte{1, 2, 'a', 'b', 0, 2, 1, 'c', 1, 1, 'd', 255, 9, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint8][]byte{1: []byte("abd"), 2: []byte("c")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SubOptions = %q, want %q", got, want)
+	}
+	if _, err := SubOptions([]byte{1, 5, 'a'}); err == nil {
+		t.Errorf("SubOptions of a truncated option = nil, want error")
+	}
+}
+
+func TestDecodePXEOptions(t *testing.T) {
+	data := []byte{
+		6, 1, 8, // discovery control
+		8, 11, 0x80, 0x00, 2, 10, 0, 0, 1, 10, 0, 0, 2, // boot servers
+		9, 15, 0x80, 0x00, 5, 'L', 'i', 'n', 'u', 'x', 0, 0, 4, 'D', 'i', 's', 'k', // boot menu
+		10, 5, 10, 'B', 'o', 'o', 't', // menu prompt
+		71, 4, 0x80, 0x00, 0, 0, // boot item
+		200, 2, 0xca, 0xfe,
+		255,
+	}
+	got, err := DecodePXEOptions(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VendorOptions{
+		"discovery-control": "8",
+		"boot-servers":      "0x8000:10.0.0.1,10.0.0.2",
+		"boot-menu":         "0x8000:Linux\n0x0000:Disk",
+		"menu-prompt":       "10:Boot",
+		"boot-item":         "0x8000:0",
+		"opt-200":           "cafe",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodePXEOptions = %q, want %q", got, want)
+	}
+
+	for _, bad := range [][]byte{
+		{1, 3, 224, 0, 1},
+		{8, 4, 0x80, 0x00, 2, 10},
+		{9, 4, 0x80, 0x00, 5, 'L'},
+	} {
+		if _, err := DecodePXEOptions(bad); err == nil {
+			t.Errorf("DecodePXEOptions(%v) = nil, want error", bad)
+		}
+	}
+}
+
+func TestVendorOptions(t *testing.T) {
+	RegisterVendorDecoder("acme", DecodeKeyValueOptions)
+	defer RegisterVendorDecoder("acme", nil)
+	subs := append([]byte{1, 14}, "console=ttyS01"...)
+	subs = append(append(subs, 2, 6), "site=3"...)
+
+	for _, tt := range []struct {
+		name  string
+		class string
+		data  []byte
+		want  VendorOptions
+		err   error
+	}{
+		{name: "registered", class: "acme-rack7", data: subs, want: VendorOptions{"console": "ttyS01", "site": "3"}},
+		{name: "pxe", class: "PXEClient", data: []byte{6, 1, 3}, want: VendorOptions{"discovery-control": "3"}},
+		{name: "unknown class", class: "other", data: subs, err: ErrNoVendorDecoder},
+		{name: "default class", data: subs, err: ErrNoVendorDecoder},
+		{name: "none", class: "acme", err: ErrNoVendorOptions},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var mods []dhcpv4.Modifier
+			if tt.class != "" {
+				mods = append(mods, dhcpv4.WithOption(dhcpv4.OptClassIdentifier(tt.class)))
+			}
+			if tt.data != nil {
+				mods = append(mods, dhcpv4.WithOption(dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, tt.data)))
+			}
+			m, err := dhcpv4.New(mods...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewPacket4(nil, m).VendorOptions()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("VendorOptions = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VendorOptions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
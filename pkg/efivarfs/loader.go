@@ -0,0 +1,97 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package efivarfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf16"
+
+	guid "github.com/google/uuid"
+)
+
+// LoaderGUID is the vendor GUID of the variables of the Boot Loader Interface,
+// shared by systemd-boot and the boot loaders compatible with it, like
+// LoaderEntryOneShot.
+var LoaderGUID = guid.MustParse("4a67b082-0a4c-41cf-b6c7-440b29bb8c4f")
+
+// LoaderAttributes are the attributes of the Boot Loader Interface variables
+// set by the OS.
+const LoaderAttributes = AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+
+// ErrBadLoaderString is returned for Boot Loader Interface variables that are
+// not UTF-16 strings.
+var ErrBadLoaderString = errors.New("not a UTF-16 string")
+
+// Loader returns the descriptor of the Boot Loader Interface variable name.
+func Loader(name string) VariableDescriptor {
+	return VariableDescriptor{Name: name, GUID: LoaderGUID}
+}
+
+// decodeLoaderStrings decodes a sequence of NUL terminated UTF-16LE strings,
+// the last of which may not be terminated.
+func decodeLoaderStrings(data []byte) ([]string, error) {
+	if len(data)%2 != 0 {
+		return nil, ErrBadLoaderString
+	}
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	s := strings.TrimSuffix(string(utf16.Decode(u)), "\x00")
+	if s == "" {
+		return nil, nil
+	}
+	return strings.Split(s, "\x00"), nil
+}
+
+// encodeLoaderString encodes s as a NUL terminated UTF-16LE string.
+func encodeLoaderString(s string) []byte {
+	u := utf16.Encode([]rune(s + "\x00"))
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+// ReadLoaderString returns the string value of the Boot Loader Interface
+// variable name, e.g. LoaderEntryOneShot. It returns ErrVarNotExist if the
+// variable is not set.
+func ReadLoaderString(e EFIVar, name string) (string, error) {
+	_, data, err := e.Get(Loader(name))
+	if err != nil {
+		return "", err
+	}
+	s, err := decodeLoaderStrings(data)
+	if err != nil || len(s) == 0 {
+		return "", err
+	}
+	return s[0], nil
+}
+
+// ReadLoaderStrings returns the values of the Boot Loader Interface variable
+// name that is a list of strings, e.g. LoaderEntries.
+func ReadLoaderStrings(e EFIVar, name string) ([]string, error) {
+	_, data, err := e.Get(Loader(name))
+	if err != nil {
+		return nil, err
+	}
+	return decodeLoaderStrings(data)
+}
+
+// WriteLoaderString sets the Boot Loader Interface variable name to value, or
+// removes it if value is empty.
+func WriteLoaderString(e EFIVar, name, value string) error {
+	if value == "" {
+		err := e.Remove(Loader(name))
+		if errors.Is(err, ErrVarNotExist) {
+			return nil
+		}
+		return err
+	}
+	return e.Set(Loader(name), LoaderAttributes, encodeLoaderString(value))
+}
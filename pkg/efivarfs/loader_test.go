@@ -0,0 +1,75 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package efivarfs
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// memVars are variables in memory.
+type memVars map[VariableDescriptor][]byte
+
+func (m memVars) Get(desc VariableDescriptor) (VariableAttributes, []byte, error) {
+	b, ok := m[desc]
+	if !ok {
+		return 0, nil, ErrVarNotExist
+	}
+	return LoaderAttributes, b, nil
+}
+
+func (m memVars) Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	m[desc] = data
+	return nil
+}
+
+func (m memVars) Remove(desc VariableDescriptor) error {
+	if _, ok := m[desc]; !ok {
+		return ErrVarNotExist
+	}
+	delete(m, desc)
+	return nil
+}
+
+func (m memVars) List() ([]VariableDescriptor, error) {
+	return nil, nil
+}
+
+func TestLoaderStrings(t *testing.T) {
+	m := memVars{}
+	if _, err := ReadLoaderString(m, "LoaderEntryOneShot"); !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("ReadLoaderString(unset) = %v, want %v", err, ErrVarNotExist)
+	}
+	if err := WriteLoaderString(m, "LoaderEntryOneShot", "linux-6.1.conf"); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{'l', 0, 'i', 0, 'n', 0, 'u', 0, 'x', 0, '-', 0, '6', 0, '.', 0, '1', 0, '.', 0, 'c', 0, 'o', 0, 'n', 0, 'f', 0, 0, 0}
+	if got := m[Loader("LoaderEntryOneShot")]; !bytes.Equal(got, want) {
+		t.Errorf("LoaderEntryOneShot = % x, want % x", got, want)
+	}
+	if got, err := ReadLoaderString(m, "LoaderEntryOneShot"); err != nil || got != "linux-6.1.conf" {
+		t.Errorf("ReadLoaderString = %q, %v, want %q", got, err, "linux-6.1.conf")
+	}
+	for i := 0; i < 2; i++ {
+		if err := WriteLoaderString(m, "LoaderEntryOneShot", ""); err != nil {
+			t.Errorf("WriteLoaderString(%q) = %v, want nil", "", err)
+		}
+	}
+	if _, ok := m[Loader("LoaderEntryOneShot")]; ok {
+		t.Errorf("LoaderEntryOneShot is still set")
+	}
+
+	// Lists, and strings that are not NUL terminated.
+	m[Loader("LoaderEntries")] = []byte{'a', 0, 0, 0, 0xe9, 0, 0, 0, 'c', 0}
+	if got, err := ReadLoaderStrings(m, "LoaderEntries"); err != nil || !reflect.DeepEqual(got, []string{"a", "é", "c"}) {
+		t.Errorf("ReadLoaderStrings = %q, %v, want [a é c]", got, err)
+	}
+	m[Loader("LoaderEntries")] = []byte{'a', 0, 0}
+	if _, err := ReadLoaderStrings(m, "LoaderEntries"); !errors.Is(err, ErrBadLoaderString) {
+		t.Errorf("ReadLoaderStrings(odd length) = %v, want %v", err, ErrBadLoaderString)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package efivarfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	guid "github.com/google/uuid"
+)
+
+var (
+	// GlobalVariableGUID is the vendor GUID of the variables defined by
+	// the UEFI specification, like PK, KEK and SetupMode.
+	GlobalVariableGUID = guid.MustParse("8be4df61-93ca-11d2-aa0d-00e098032b8c")
+	// ImageSecurityDatabaseGUID is the vendor GUID of db and dbx.
+	ImageSecurityDatabaseGUID = guid.MustParse("d719b2cb-3d3a-4596-a3bc-dad00e67656f")
+
+	// CertX509GUID is the signature type of DER encoded X.509 certificates.
+	CertX509GUID = guid.MustParse("a5c059a1-94e4-4aa7-87b5-ab155c2bf072")
+	// CertSHA256GUID is the signature type of SHA-256 image hashes.
+	CertSHA256GUID = guid.MustParse("c1c41626-504c-4092-aca9-41f936934328")
+	// CertPKCS7GUID is the certificate type of time based authenticated
+	// variable updates.
+	CertPKCS7GUID = guid.MustParse("4aafd29d-68df-49ee-8aa9-347d375665a7")
+
+	// ErrBadSignatureList is returned for malformed EFI signature lists.
+	ErrBadSignatureList = errors.New("bad signature list")
+	// ErrNotKeyDatabase is returned for variables that are not Secure
+	// Boot key databases.
+	ErrNotKeyDatabase = errors.New("not a key database, must be one of PK, KEK, db or dbx")
+)
+
+// KeyDatabases are the Secure Boot key databases, from the top of the hierarchy.
+var KeyDatabases = []string{"PK", "KEK", "db", "dbx"}
+
+// KeyDatabaseAttributes are the attributes of the Secure Boot key databases.
+const KeyDatabaseAttributes = AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess | AttributeTimeBasedAuthenticatedWriteAccess
+
+// KeyDatabase returns the descriptor of the key database name.
+func KeyDatabase(name string) (VariableDescriptor, error) {
+	switch name {
+	case "PK", "KEK":
+		return VariableDescriptor{Name: name, GUID: GlobalVariableGUID}, nil
+	case "db", "dbx":
+		return VariableDescriptor{Name: name, GUID: ImageSecurityDatabaseGUID}, nil
+	}
+	return VariableDescriptor{}, fmt.Errorf("%q: %w", name, ErrNotKeyDatabase)
+}
+
+// SetupMode returns whether the firmware is in setup mode, i.e. has no PK
+// and accepts unsigned writes to the key databases.
+func SetupMode(e EFIVar) (bool, error) {
+	_, data, err := e.Get(VariableDescriptor{Name: "SetupMode", GUID: GlobalVariableGUID})
+	if err != nil {
+		return false, err
+	}
+	return len(data) == 1 && data[0] == 1, nil
+}
+
+// Signature is an entry of a signature list.
+type Signature struct {
+	Owner guid.UUID
+	Data  []byte
+}
+
+// SignatureList is an EFI_SIGNATURE_LIST, the contents of the key databases
+// is a sequence of them. All signatures of a list have the same size.
+type SignatureList struct {
+	Type       guid.UUID
+	Header     []byte
+	Signatures []Signature
+}
+
+// readGUID and appendGUID convert the mixed endian EFI_GUID.
+func readGUID(b []byte) guid.UUID {
+	var u guid.UUID
+	u[0], u[1], u[2], u[3] = b[3], b[2], b[1], b[0]
+	u[4], u[5] = b[5], b[4]
+	u[6], u[7] = b[7], b[6]
+	copy(u[8:], b[8:16])
+	return u
+}
+
+func appendGUID(b []byte, u guid.UUID) []byte {
+	return append(b, u[3], u[2], u[1], u[0], u[5], u[4], u[7], u[6],
+		u[8], u[9], u[10], u[11], u[12], u[13], u[14], u[15])
+}
+
+// ParseSignatureLists parses the contents of a key database.
+func ParseSignatureLists(b []byte) ([]SignatureList, error) {
+	const hdrSize = 28
+	var lists []SignatureList
+	for len(b) > 0 {
+		if len(b) < hdrSize {
+			return nil, fmt.Errorf("%w: %d bytes left for a header", ErrBadSignatureList, len(b))
+		}
+		l := SignatureList{Type: readGUID(b)}
+		listSize := binary.LittleEndian.Uint32(b[16:])
+		headerSize := binary.LittleEndian.Uint32(b[20:])
+		sigSize := binary.LittleEndian.Uint32(b[24:])
+		if listSize < hdrSize || uint64(listSize) > uint64(len(b)) || uint64(headerSize) > uint64(listSize-hdrSize) {
+			return nil, fmt.Errorf("%w: list size %d, header size %d with %d bytes left", ErrBadSignatureList, listSize, headerSize, len(b))
+		}
+		sigs := listSize - hdrSize - headerSize
+		if sigSize < 16 || sigs%sigSize != 0 {
+			return nil, fmt.Errorf("%w: %d bytes are not a multiple of signature size %d", ErrBadSignatureList, sigs, sigSize)
+		}
+		l.Header = b[hdrSize : hdrSize+headerSize]
+		for s := b[hdrSize+headerSize : listSize]; len(s) > 0; s = s[sigSize:] {
+			l.Signatures = append(l.Signatures, Signature{Owner: readGUID(s), Data: s[16:sigSize]})
+		}
+		lists = append(lists, l)
+		b = b[listSize:]
+	}
+	return lists, nil
+}
+
+// MarshalSignatureLists returns the key database contents of lists.
+func MarshalSignatureLists(lists []SignatureList) ([]byte, error) {
+	var b []byte
+	for _, l := range lists {
+		if len(l.Signatures) == 0 {
+			return nil, fmt.Errorf("%w: list of type %v has no signatures", ErrBadSignatureList, l.Type)
+		}
+		sigSize := 16 + len(l.Signatures[0].Data)
+		for _, s := range l.Signatures {
+			if 16+len(s.Data) != sigSize {
+				return nil, fmt.Errorf("%w: signatures of type %v have different sizes", ErrBadSignatureList, l.Type)
+			}
+		}
+		b = appendGUID(b, l.Type)
+		b = binary.LittleEndian.AppendUint32(b, uint32(28+len(l.Header)+sigSize*len(l.Signatures)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(l.Header)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(sigSize))
+		b = append(b, l.Header...)
+		for _, s := range l.Signatures {
+			b = appendGUID(b, s.Owner)
+			b = append(b, s.Data...)
+		}
+	}
+	return b, nil
+}
+
+// A time based authenticated update starts with an
+// EFI_VARIABLE_AUTHENTICATION_2: an EFI_TIME, then a WIN_CERTIFICATE_UEFI_GUID
+// with a PKCS#7 signature of the update.
+const (
+	efiTimeSize      = 16
+	winCertSize      = 8 + 16
+	winCertRevision  = 0x0200
+	winCertTypeGUID  = 0x0ef1
+	authHeaderMinLen = efiTimeSize + winCertSize
+)
+
+// SplitAuthenticated returns the payload of a time based authenticated
+// update, as written by e.g. sign-efi-sig-list, or false if b is not one.
+func SplitAuthenticated(b []byte) ([]byte, bool) {
+	if len(b) < authHeaderMinLen {
+		return nil, false
+	}
+	cert := b[efiTimeSize:]
+	length := binary.LittleEndian.Uint32(cert)
+	if binary.LittleEndian.Uint16(cert[4:]) != winCertRevision ||
+		binary.LittleEndian.Uint16(cert[6:]) != winCertTypeGUID ||
+		readGUID(cert[8:]) != CertPKCS7GUID ||
+		length < winCertSize || uint64(length) > uint64(len(cert)) {
+		return nil, false
+	}
+	return cert[length:], true
+}
+
+// Unsigned returns data as a time based authenticated update at time t
+// without a signature. The firmware only accepts these in setup mode.
+func Unsigned(t time.Time, data []byte) []byte {
+	t = t.UTC()
+	b := binary.LittleEndian.AppendUint16(nil, uint16(t.Year()))
+	b = append(b, byte(t.Month()), byte(t.Day()), byte(t.Hour()), byte(t.Minute()), byte(t.Second()))
+	// Pad1, Nanosecond, TimeZone, Daylight and Pad2 must be 0.
+	b = append(b, make([]byte, efiTimeSize-len(b))...)
+	b = binary.LittleEndian.AppendUint32(b, winCertSize)
+	b = binary.LittleEndian.AppendUint16(b, winCertRevision)
+	b = binary.LittleEndian.AppendUint16(b, winCertTypeGUID)
+	b = appendGUID(b, CertPKCS7GUID)
+	return append(b, data...)
+}
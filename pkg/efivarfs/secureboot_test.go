@@ -0,0 +1,86 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package efivarfs
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	guid "github.com/google/uuid"
+)
+
+func TestSignatureLists(t *testing.T) {
+	lists := []SignatureList{
+		{Type: CertSHA256GUID, Header: []byte{}, Signatures: []Signature{
+			{Owner: fakeGUID, Data: bytes.Repeat([]byte{1}, 32)},
+			{Owner: fakeGUID, Data: bytes.Repeat([]byte{2}, 32)},
+		}},
+		{Type: CertX509GUID, Header: []byte{}, Signatures: []Signature{{Owner: fakeGUID, Data: []byte("cert")}}},
+	}
+	b, err := MarshalSignatureLists(lists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 28+2*48+28+20 {
+		t.Errorf("MarshalSignatureLists is %d bytes, want %d", len(b), 28+2*48+28+20)
+	}
+	// The first three fields of an EFI_GUID are little endian.
+	if want := []byte{0x26, 0x16, 0xc4, 0xc1, 0x4c, 0x50, 0x92, 0x40, 0xac, 0xa9}; !bytes.HasPrefix(b, want) {
+		t.Errorf("MarshalSignatureLists starts with % x, want % x", b[:10], want)
+	}
+	got, err := ParseSignatureLists(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, lists) {
+		t.Errorf("ParseSignatureLists = %+v, want %+v", got, lists)
+	}
+
+	for _, bad := range [][]byte{b[:20], b[:len(b)-1], append(b[:len(b):len(b)], 0)} {
+		if _, err := ParseSignatureLists(bad); !errors.Is(err, ErrBadSignatureList) {
+			t.Errorf("ParseSignatureLists(%d bytes) = %v, want %v", len(bad), err, ErrBadSignatureList)
+		}
+	}
+	mixed := []SignatureList{{Type: CertX509GUID, Signatures: []Signature{{Data: []byte("a")}, {Data: []byte("bc")}}}}
+	if _, err := MarshalSignatureLists(mixed); !errors.Is(err, ErrBadSignatureList) {
+		t.Errorf("MarshalSignatureLists(different sizes) = %v, want %v", err, ErrBadSignatureList)
+	}
+}
+
+func TestAuthenticated(t *testing.T) {
+	data := []byte("signature lists")
+	b := Unsigned(time.Date(2022, 11, 3, 14, 5, 6, 7, time.UTC), data)
+	if want := []byte{0xe6, 0x07, 11, 3, 14, 5, 6, 0, 0, 0, 0, 0, 0, 0, 0, 0, 24, 0, 0, 0, 0, 2, 0xf1, 0x0e}; !bytes.HasPrefix(b, want) {
+		t.Errorf("Unsigned = % x, want prefix % x", b, want)
+	}
+	if got, ok := SplitAuthenticated(b); !ok || !bytes.Equal(got, data) {
+		t.Errorf("SplitAuthenticated(Unsigned(%q)) = %q, %v, want %q, true", data, got, ok, data)
+	}
+	if _, ok := SplitAuthenticated(data); ok {
+		t.Errorf("SplitAuthenticated(%q) = true, want false", data)
+	}
+	long := append([]byte{}, b...)
+	long[efiTimeSize] = 0xff
+	if _, ok := SplitAuthenticated(long); ok {
+		t.Errorf("SplitAuthenticated(certificate longer than update) = true, want false")
+	}
+}
+
+func TestKeyDatabase(t *testing.T) {
+	for _, name := range KeyDatabases {
+		if _, err := KeyDatabase(name); err != nil {
+			t.Errorf("KeyDatabase(%q) = %v", name, err)
+		}
+	}
+	if d, _ := KeyDatabase("db"); d.GUID != guid.MustParse("d719b2cb-3d3a-4596-a3bc-dad00e67656f") {
+		t.Errorf("KeyDatabase(db) has GUID %v", d.GUID)
+	}
+	if _, err := KeyDatabase("MokList"); !errors.Is(err, ErrNotKeyDatabase) {
+		t.Errorf("KeyDatabase(MokList) = %v, want %v", err, ErrNotKeyDatabase)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hashsum
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrSilent is returned for failed checks with --status, which are only
+// reported by the exit status.
+var ErrSilent = errors.New("checks failed")
+
+// Main runs the command name with the arguments of the process, and exits
+// with 1 if it fails.
+func Main(name string, newHash func() hash.Hash) {
+	err := Run(name, newHash, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case err == ErrSilent:
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// Run runs the command name, e.g. sha256sum, with args, hashing with
+// newHash. Without -c, the sums of the files are printed in the manifest
+// format; with -c, the files of the manifests are checked.
+//
+// Options:
+//
+//	-c, --check:      check the files listed in the manifests
+//	--ignore-missing: with -c, do not fail for missing files
+//	--quiet:          with -c, do not print OK files
+//	--status:         with -c, print nothing; the exit status tells
+//	--strict:         with -c, fail for improperly formatted lines
+//	-w, --warn:       with -c, warn about improperly formatted lines
+//	--tag:            print sums in the BSD format
+//	-j N:             hash N files at once
+//	-b, -t:           accepted for compatibility; there is no text mode
+func Run(name string, newHash func() hash.Hash, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	f := flag.NewFlagSet(name, flag.ContinueOnError)
+	f.SetOutput(stderr)
+	var check, tag, ignored bool
+	var o CheckOpts
+	f.BoolVar(&check, "c", false, "check the files listed in the manifests")
+	f.BoolVar(&check, "check", false, "check the files listed in the manifests")
+	f.BoolVar(&o.IgnoreMissing, "ignore-missing", false, "do not fail for missing files")
+	f.BoolVar(&o.Quiet, "quiet", false, "do not print OK files")
+	f.BoolVar(&o.Status, "status", false, "print nothing; the exit status tells")
+	f.BoolVar(&o.Strict, "strict", false, "fail for improperly formatted lines")
+	f.BoolVar(&o.Warn, "w", false, "warn about improperly formatted lines")
+	f.BoolVar(&o.Warn, "warn", false, "warn about improperly formatted lines")
+	f.BoolVar(&tag, "tag", false, "print sums in the BSD format")
+	f.BoolVar(&ignored, "b", false, "read in binary mode (the only mode)")
+	f.BoolVar(&ignored, "binary", false, "read in binary mode (the only mode)")
+	f.BoolVar(&ignored, "t", false, "read in text mode (same as binary)")
+	f.BoolVar(&ignored, "text", false, "read in text mode (same as binary)")
+	h := &Hasher{New: newHash, Stdin: stdin}
+	f.IntVar(&h.Workers, "j", 0, "hash this many files at once (default: the number of CPUs)")
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	files := f.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	if !check {
+		var failed bool
+		algo := strings.ToUpper(strings.TrimSuffix(name, "sum"))
+		if name == "b2sum" {
+			algo = "BLAKE2b"
+		}
+		h.Sum(files, func(r Result) {
+			switch {
+			case r.Err != nil:
+				failed = true
+				fmt.Fprintf(stderr, "%s: %v\n", name, r.Err)
+			case tag:
+				fmt.Fprintf(stdout, "%s (%s) = %x\n", algo, r.Name, r.Sum)
+			default:
+				io.WriteString(stdout, Format(r))
+			}
+		})
+		if failed {
+			return fmt.Errorf("some files could not be read")
+		}
+		return nil
+	}
+
+	return h.CheckFiles(stdout, stderr, files, o)
+}
+
+// CheckFiles checks the files listed in manifests, where "-" is stdin, as
+// Check does. With o.Status, the error of failed checks says nothing else.
+func (h *Hasher) CheckFiles(stdout, stderr io.Writer, manifests []string, o CheckOpts) error {
+	var errs []string
+	for _, m := range manifests {
+		r := h.Stdin
+		if m != "-" {
+			f, err := os.Open(m)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			defer f.Close()
+			r = f
+		}
+		if err := h.Check(stdout, stderr, r, m, o); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	switch {
+	case len(errs) == 0:
+		return nil
+	case o.Status:
+		return ErrSilent
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
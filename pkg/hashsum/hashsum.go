@@ -0,0 +1,283 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hashsum implements the md5sum, sha1sum, sha256sum, sha512sum and
+// b2sum family of commands: hashing files in parallel, and checking them
+// against a manifest of sums, as scripts do with downloaded artifacts.
+package hashsum
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Result is the hash of one file.
+type Result struct {
+	Name string
+	Sum  []byte
+	Err  error
+}
+
+// Hasher hashes files.
+type Hasher struct {
+	// New returns a new hash.
+	New func() hash.Hash
+
+	// Stdin is read for the file "-".
+	Stdin io.Reader
+
+	// Workers is the number of files hashed at once. If it is 0,
+	// runtime.GOMAXPROCS(0) files are.
+	Workers int
+}
+
+func (h *Hasher) hash(name string) ([]byte, error) {
+	r := h.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	s := h.New()
+	if _, err := io.Copy(s, r); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return s.Sum(nil), nil
+}
+
+// Sum hashes names in parallel, and calls f with the results in the order of
+// names as they become available.
+func (h *Hasher) Sum(names []string, f func(Result)) {
+	workers := h.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	results := make([]chan Result, len(names))
+	for i := range results {
+		results[i] = make(chan Result, 1)
+	}
+	next := make(chan int)
+	go func() {
+		for i := range names {
+			next <- i
+		}
+		close(next)
+	}()
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range next {
+				sum, err := h.hash(names[i])
+				results[i] <- Result{Name: names[i], Sum: sum, Err: err}
+			}
+		}()
+	}
+	for _, r := range results {
+		f(<-r)
+	}
+}
+
+// Entry is a line of a manifest.
+type Entry struct {
+	Name string
+	Sum  []byte
+}
+
+// unescape undoes the escaping of file names with a backslash or newline,
+// which are written with a leading backslash.
+func unescape(s string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", false
+		}
+		switch s[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// escape escapes name if it holds a backslash or newline, and reports whether
+// it did.
+func escape(name string) (string, bool) {
+	if !strings.ContainsAny(name, "\\\n") {
+		return name, false
+	}
+	return strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name), true
+}
+
+// ParseLine parses a manifest line, either "SUM  NAME", "SUM *NAME" or
+// "ALGORITHM (NAME) = SUM", for sums of size bytes. "SUM NAME", as written by
+// u-root's md5sum and shasum, is accepted too.
+func ParseLine(line string, size int) (Entry, bool) {
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+	var name, sum string
+	if n := 2 * size; len(line) > n+1 && line[n] == ' ' && isHex(line[:n]) {
+		sum, name = line[:n], line[n+1:]
+		if name[0] == ' ' || name[0] == '*' {
+			name = name[1:]
+		}
+	} else if i, j := strings.Index(line, " ("), strings.LastIndex(line, ") = "); i > 0 && i < j {
+		name, sum = line[i+2:j], line[j+4:]
+	} else {
+		return Entry{}, false
+	}
+	if escaped {
+		var ok bool
+		if name, ok = unescape(name); !ok {
+			return Entry{}, false
+		}
+	}
+	b, err := hex.DecodeString(sum)
+	if err != nil || len(b) != size || name == "" {
+		return Entry{}, false
+	}
+	return Entry{Name: name, Sum: b}, true
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Format formats a manifest line for r.
+func Format(r Result) string {
+	name, escaped := escape(r.Name)
+	prefix := ""
+	if escaped {
+		prefix = "\\"
+	}
+	return fmt.Sprintf("%s%x  %s\n", prefix, r.Sum, name)
+}
+
+// CheckOpts are the options of Check.
+type CheckOpts struct {
+	// IgnoreMissing does not report files that do not exist.
+	IgnoreMissing bool
+
+	// Quiet does not print the files that are OK.
+	Quiet bool
+
+	// Status prints nothing; only the error tells the result.
+	Status bool
+
+	// Strict fails for improperly formatted lines.
+	Strict bool
+
+	// Warn reports improperly formatted lines.
+	Warn bool
+}
+
+// Check checks the files of the manifest read from r, named manifest, and
+// writes a line for each to w, and warnings to stderr. It returns an error
+// if any file does not match or cannot be read.
+func (h *Hasher) Check(w, stderr io.Writer, r io.Reader, manifest string, o CheckOpts) error {
+	if o.Status {
+		w, stderr = io.Discard, io.Discard
+	}
+	size := h.New().Size()
+	var entries []Entry
+	var badLines int
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e, ok := ParseLine(line, size)
+		if !ok {
+			badLines++
+			if o.Warn {
+				fmt.Fprintf(stderr, "%s: %d: improperly formatted checksum line\n", manifest, n)
+			}
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("%s: %v", manifest, err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("%s: no properly formatted checksum lines found", manifest)
+	}
+
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	var failed, unreadable, checked int
+	i := 0
+	h.Sum(names, func(res Result) {
+		e := entries[i]
+		i++
+		if res.Err != nil {
+			if o.IgnoreMissing && os.IsNotExist(res.Err) {
+				return
+			}
+			unreadable++
+			fmt.Fprintf(stderr, "%v\n", res.Err)
+			fmt.Fprintf(w, "%s: FAILED open or read\n", e.Name)
+			return
+		}
+		checked++
+		if !bytes.Equal(res.Sum, e.Sum) {
+			failed++
+			fmt.Fprintf(w, "%s: FAILED\n", e.Name)
+			return
+		}
+		if !o.Quiet {
+			fmt.Fprintf(w, "%s: OK\n", e.Name)
+		}
+	})
+
+	var errs []string
+	if badLines > 0 {
+		errs = append(errs, plural(badLines, "line is", "lines are")+" improperly formatted")
+	}
+	if unreadable > 0 {
+		errs = append(errs, plural(unreadable, "listed file", "listed files")+" could not be read")
+	}
+	if failed > 0 {
+		errs = append(errs, plural(failed, "computed checksum", "computed checksums")+" did NOT match")
+	}
+	if checked == 0 && unreadable == 0 && o.IgnoreMissing {
+		errs = append(errs, "no file was verified")
+	}
+	if len(errs) == 0 || len(errs) == 1 && badLines > 0 && !o.Strict {
+		if len(errs) == 1 {
+			fmt.Fprintf(stderr, "WARNING: %s\n", errs[0])
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: WARNING: %s", manifest, strings.Join(errs, ", "))
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hashsum
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sha256 of "abc\n".
+const abcSum = "edeaaff3f1774ad2888673770c6d64097e391bc362d7d6fb34982ddf0efd18cb"
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseLine(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		name string
+		ok   bool
+	}{
+		{abcSum + "  abc", "abc", true},
+		{abcSum + " *abc", "abc", true},
+		{abcSum + " abc", "abc", true},
+		{abcSum + "  a b  c", "a b  c", true},
+		{"SHA256 (abc) = " + abcSum, "abc", true},
+		{"SHA256 (a (b) c) = " + abcSum, "a (b) c", true},
+		{"\\" + abcSum + "  a\\\\b\\nc", "a\\b\nc", true},
+		{"\\" + abcSum + "  a\\x", "", false},
+		{abcSum[2:] + "  abc", "", false},
+		{abcSum + "  ", "", false},
+		{"SHA256 (abc) = 1234", "", false},
+		{"garbage", "", false},
+	} {
+		e, ok := ParseLine(tt.line, sha256.Size)
+		if ok != tt.ok || ok && (e.Name != tt.name || fmt.Sprintf("%x", e.Sum) != abcSum) {
+			t.Errorf("ParseLine(%q) = %q %x, %v, want %q, %v", tt.line, e.Name, e.Sum, ok, tt.name, tt.ok)
+		}
+	}
+
+	r := Result{Name: "a\\b\nc", Sum: make([]byte, 2)}
+	if got, want := Format(r), "\\0000  a\\\\b\\nc\n"; got != want {
+		t.Errorf("Format(%q) = %q, want %q", r.Name, got, want)
+	}
+}
+
+func TestSum(t *testing.T) {
+	var names []string
+	files := map[string]string{}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("f%02d", i)
+		files[name] = strings.Repeat("x", i)
+		names = append(names, name)
+	}
+	dir := writeFiles(t, files)
+	var paths []string
+	for _, n := range names {
+		paths = append(paths, filepath.Join(dir, n))
+	}
+	paths = append(paths, filepath.Join(dir, "missing"), "-")
+
+	h := &Hasher{New: sha256.New, Stdin: strings.NewReader("abc\n"), Workers: 4}
+	var got []Result
+	h.Sum(paths, func(r Result) { got = append(got, r) })
+	if len(got) != len(paths) {
+		t.Fatalf("got %d results, want %d", len(got), len(paths))
+	}
+	for i, r := range got[:len(names)] {
+		want := sha256.Sum256([]byte(files[names[i]]))
+		if r.Name != paths[i] || r.Err != nil || !bytes.Equal(r.Sum, want[:]) {
+			t.Errorf("result %d = %s %x %v, want %s %x", i, r.Name, r.Sum, r.Err, paths[i], want)
+		}
+	}
+	if !os.IsNotExist(got[len(names)].Err) {
+		t.Errorf("missing file: %v", got[len(names)].Err)
+	}
+	if r := got[len(names)+1]; fmt.Sprintf("%x", r.Sum) != abcSum {
+		t.Errorf("stdin = %x, want %s", r.Sum, abcSum)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"abc": "abc\n", "bad": "bad\n"})
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	good := abcSum + "  abc\n"
+	for _, tt := range []struct {
+		name       string
+		manifest   string
+		o          CheckOpts
+		wantOut    string
+		wantStderr string
+		wantErr    string
+	}{
+		{name: "ok", manifest: good, wantOut: "abc: OK\n"},
+		{name: "quiet", manifest: good, o: CheckOpts{Quiet: true}},
+		{name: "mismatch", manifest: good + abcSum + "  bad\n", wantOut: "abc: OK\nbad: FAILED\n", wantErr: "1 computed checksum did NOT match"},
+		{name: "status", manifest: abcSum + "  bad\n", o: CheckOpts{Status: true}, wantErr: "did NOT match"},
+		{name: "missing", manifest: good + abcSum + "  gone\n", wantOut: "abc: OK\ngone: FAILED open or read\n", wantStderr: "no such file", wantErr: "1 listed file could not be read"},
+		{name: "ignore missing", manifest: good + abcSum + "  gone\n", o: CheckOpts{IgnoreMissing: true}, wantOut: "abc: OK\n"},
+		{name: "only missing", manifest: abcSum + "  gone\n", o: CheckOpts{IgnoreMissing: true}, wantErr: "no file was verified"},
+		{name: "bad line", manifest: good + "junk\n", o: CheckOpts{Warn: true}, wantOut: "abc: OK\n", wantStderr: "MANIFEST: 2: improperly formatted"},
+		{name: "strict", manifest: good + "junk\n", o: CheckOpts{Strict: true}, wantOut: "abc: OK\n", wantErr: "1 line is improperly formatted"},
+		{name: "empty", manifest: "# nothing\n", wantErr: "no properly formatted checksum lines"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, stderr bytes.Buffer
+			h := &Hasher{New: sha256.New}
+			err := h.Check(&out, &stderr, strings.NewReader(tt.manifest), "MANIFEST", tt.o)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Check = %v, want %q", err, tt.wantErr)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) || tt.o.Status && stderr.Len() > 0 {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"abc": "abc\n"})
+	abc := filepath.Join(dir, "abc")
+
+	var out, stderr bytes.Buffer
+	if err := Run("sha256sum", sha256.New, []string{abc}, nil, &out, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	manifest := out.String()
+	if want := abcSum + "  " + abc + "\n"; manifest != want {
+		t.Errorf("sha256sum = %q, want %q", manifest, want)
+	}
+
+	out.Reset()
+	if err := Run("sha256sum", sha256.New, []string{"--tag", abc}, nil, &out, &stderr); err != nil || out.String() != "SHA256 ("+abc+") = "+abcSum+"\n" {
+		t.Errorf("sha256sum --tag = %q, %v", out.String(), err)
+	}
+
+	// Check what was written, read from stdin.
+	out.Reset()
+	if err := Run("sha256sum", sha256.New, []string{"-c"}, strings.NewReader(manifest), &out, &stderr); err != nil || out.String() != abc+": OK\n" {
+		t.Errorf("sha256sum -c = %q, %v", out.String(), err)
+	}
+
+	if err := os.WriteFile(abc, []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := Run("sha256sum", sha256.New, []string{"-c", "--status", "-"}, strings.NewReader(manifest), &out, &stderr); err != ErrSilent || out.Len() != 0 {
+		t.Errorf("sha256sum -c --status = %q, %v, want no output and %v", out.String(), err, ErrSilent)
+	}
+	if err := Run("sha256sum", sha256.New, []string{filepath.Join(dir, "missing")}, nil, &out, &stderr); err == nil {
+		t.Errorf("sha256sum of a missing file succeeded")
+	}
+}
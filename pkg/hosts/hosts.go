@@ -0,0 +1,250 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hosts reads and edits hosts files, such as /etc/hosts.
+//
+// Edits keep the comments, blank lines and order of the lines they do not
+// change, and are idempotent, so that scripts can make them on every boot.
+package hosts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// DefaultPath is the path of the system's hosts file.
+const DefaultPath = "/etc/hosts"
+
+// Entry is a line of a hosts file that maps names to an address.
+type Entry struct {
+	Addr  string
+	Names []string
+}
+
+func (e Entry) String() string {
+	return e.Addr + "\t" + strings.Join(e.Names, " ")
+}
+
+// line is a line of a hosts file. Once its entry is changed, it is
+// formatted from the entry and comment.
+type line struct {
+	raw     string
+	entry   *Entry
+	comment string
+	changed bool
+}
+
+func (l *line) String() string {
+	if !l.changed {
+		return l.raw
+	}
+	s := l.entry.String()
+	if l.comment != "" {
+		s += "\t" + l.comment
+	}
+	return s
+}
+
+// File is a hosts file.
+type File struct {
+	lines []*line
+}
+
+// Parse parses the hosts file b. Lines that are not entries, like those of
+// invalid addresses, are ignored as the resolver does, and kept as they are.
+func Parse(b []byte) *File {
+	f := &File{}
+	if len(b) == 0 {
+		return f
+	}
+	for _, raw := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		l := &line{raw: raw}
+		text, comment := raw, ""
+		if i := strings.IndexByte(raw, '#'); i >= 0 {
+			text, comment = raw[:i], raw[i:]
+		}
+		if fields := strings.Fields(text); len(fields) >= 2 && checkAddr(fields[0]) == nil {
+			l.entry = &Entry{Addr: fields[0], Names: fields[1:]}
+			l.comment = comment
+		}
+		f.lines = append(f.lines, l)
+	}
+	return f
+}
+
+// ReadFile reads the hosts file path. A file that does not exist is empty.
+func ReadFile(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b), nil
+}
+
+// Bytes formats f.
+func (f *File) Bytes() []byte {
+	var b bytes.Buffer
+	for _, l := range f.lines {
+		b.WriteString(l.String())
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// WriteFile writes f to path atomically.
+func (f *File) WriteFile(path string) error {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	return uio.WriteFileAtomic(bytes.NewReader(f.Bytes()), path, uio.AtomicOpts{Mode: mode})
+}
+
+// Entries returns the entries of f, in order.
+func (f *File) Entries() []Entry {
+	var es []Entry
+	for _, l := range f.lines {
+		if l.entry != nil {
+			es = append(es, Entry{Addr: l.entry.Addr, Names: append([]string{}, l.entry.Names...)})
+		}
+	}
+	return es
+}
+
+// Lookup returns the addresses of name, which is not case sensitive.
+func (f *File) Lookup(name string) []string {
+	var addrs []string
+	for _, l := range f.lines {
+		if l.entry != nil && l.entry.index(name) >= 0 {
+			addrs = append(addrs, l.entry.Addr)
+		}
+	}
+	return addrs
+}
+
+// Canonical returns the canonical name of name: the first name of the
+// first entry name is in, or "" if there is none.
+func (f *File) Canonical(name string) string {
+	for _, l := range f.lines {
+		if l.entry != nil && l.entry.index(name) >= 0 {
+			return l.entry.Names[0]
+		}
+	}
+	return ""
+}
+
+func (e *Entry) index(name string) int {
+	for i, n := range e.Names {
+		if strings.EqualFold(n, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Set maps names to addr, and to no other address: the names are removed
+// from the entries of other addresses, and added to the first entry of addr
+// that they are not all in already, or to a new one. It reports whether f
+// changed.
+func (f *File) Set(addr string, names ...string) (bool, error) {
+	if err := checkAddr(addr); err != nil {
+		return false, err
+	}
+	if len(names) == 0 {
+		return false, fmt.Errorf("no names given for %s", addr)
+	}
+	if err := checkNames(names); err != nil {
+		return false, err
+	}
+
+	changed := false
+	var target *line
+	for _, l := range f.lines {
+		if l.entry == nil {
+			continue
+		}
+		if l.entry.Addr == addr && target == nil {
+			target = l
+			continue
+		}
+		if l.remove(names) {
+			changed = true
+		}
+	}
+	f.dropEmpty()
+	if target == nil {
+		f.lines = append(f.lines, &line{entry: &Entry{Addr: addr, Names: append([]string{}, names...)}, changed: true})
+		return true, nil
+	}
+	for _, n := range names {
+		if target.entry.index(n) < 0 {
+			target.entry.Names = append(target.entry.Names, n)
+			target.changed, changed = true, true
+		}
+	}
+	return changed, nil
+}
+
+// Remove removes names from all entries, and entries left without names. It
+// reports whether f changed.
+func (f *File) Remove(names ...string) bool {
+	changed := false
+	for _, l := range f.lines {
+		if l.entry != nil && l.remove(names) {
+			changed = true
+		}
+	}
+	f.dropEmpty()
+	return changed
+}
+
+// remove removes names from the entry of l, and reports whether it had
+// any.
+func (l *line) remove(names []string) bool {
+	removed := false
+	for _, n := range names {
+		if i := l.entry.index(n); i >= 0 {
+			l.entry.Names = append(l.entry.Names[:i], l.entry.Names[i+1:]...)
+			l.changed, removed = true, true
+		}
+	}
+	return removed
+}
+
+// dropEmpty drops the entries without names.
+func (f *File) dropEmpty() {
+	lines := f.lines[:0]
+	for _, l := range f.lines {
+		if l.entry == nil || len(l.entry.Names) > 0 {
+			lines = append(lines, l)
+		}
+	}
+	f.lines = lines
+}
+
+func checkAddr(addr string) error {
+	ip, _, _ := strings.Cut(addr, "%")
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("%q is not an IP address", addr)
+	}
+	return nil
+}
+
+func checkNames(names []string) error {
+	for _, n := range names {
+		if n == "" || strings.ContainsAny(n, " \t\r\n#") {
+			return fmt.Errorf("%q is not a host name", n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hosts
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const hostsFile = `# The hosts of the lab.
+127.0.0.1   localhost
+::1         localhost ip6-localhost  # loopback
+
+10.0.0.2    build.lab build
+garbage
+10.0.0.3    BUILD2.lab
+`
+
+func TestParse(t *testing.T) {
+	f := Parse([]byte(hostsFile))
+	if got := string(f.Bytes()); got != hostsFile {
+		t.Errorf("Bytes = %q, want it unchanged: %q", got, hostsFile)
+	}
+	want := []Entry{
+		{Addr: "127.0.0.1", Names: []string{"localhost"}},
+		{Addr: "::1", Names: []string{"localhost", "ip6-localhost"}},
+		{Addr: "10.0.0.2", Names: []string{"build.lab", "build"}},
+		{Addr: "10.0.0.3", Names: []string{"BUILD2.lab"}},
+	}
+	if got := f.Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Entries = %v, want %v", got, want)
+	}
+	if got, want := f.Lookup("LocalHost"), []string{"127.0.0.1", "::1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup(LocalHost) = %q, want %q", got, want)
+	}
+	for name, want := range map[string]string{"build": "build.lab", "build2.lab": "BUILD2.lab", "test": ""} {
+		if got := f.Canonical(name); got != want {
+			t.Errorf("Canonical(%s) = %q, want %q", name, got, want)
+		}
+	}
+	if got := Parse(nil).Bytes(); len(got) != 0 {
+		t.Errorf("Bytes of an empty file = %q", got)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		edit    func(f *File) (bool, error)
+		changed bool
+		want    string
+	}{
+		{
+			name:    "set new",
+			edit:    func(f *File) (bool, error) { return f.Set("127.0.1.1", "node1.lab", "node1") },
+			changed: true,
+			want:    hostsFile + "127.0.1.1\tnode1.lab node1\n",
+		},
+		{
+			name:    "set existing address",
+			edit:    func(f *File) (bool, error) { return f.Set("10.0.0.2", "ci", "build") },
+			changed: true,
+			want: `# The hosts of the lab.
+127.0.0.1   localhost
+::1         localhost ip6-localhost  # loopback
+
+10.0.0.2	build.lab build ci
+garbage
+10.0.0.3    BUILD2.lab
+`,
+		},
+		{
+			name: "set again",
+			edit: func(f *File) (bool, error) { return f.Set("10.0.0.2", "Build.lab", "build") },
+			want: hostsFile,
+		},
+		{
+			name:    "set moves names",
+			edit:    func(f *File) (bool, error) { return f.Set("10.0.0.3", "build.lab", "build2.lab") },
+			changed: true,
+			want: `# The hosts of the lab.
+127.0.0.1   localhost
+::1         localhost ip6-localhost  # loopback
+
+10.0.0.2	build
+garbage
+10.0.0.3	BUILD2.lab build.lab
+`,
+		},
+		{
+			name:    "remove",
+			edit:    func(f *File) (bool, error) { return f.Remove("ip6-localhost", "build", "build.lab"), nil },
+			changed: true,
+			want: `# The hosts of the lab.
+127.0.0.1   localhost
+::1	localhost	# loopback
+
+garbage
+10.0.0.3    BUILD2.lab
+`,
+		},
+		{
+			name: "remove absent",
+			edit: func(f *File) (bool, error) { return f.Remove("test"), nil },
+			want: hostsFile,
+		},
+	} {
+		f := Parse([]byte(hostsFile))
+		changed, err := tt.edit(f)
+		if err != nil || changed != tt.changed {
+			t.Errorf("%s: changed = %t, %v, want %t", tt.name, changed, err, tt.changed)
+		}
+		if got := string(f.Bytes()); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	f := Parse([]byte(hostsFile))
+	for _, args := range [][]string{{"10.0.0.300", "x"}, {"10.0.0.4"}, {"10.0.0.4", "a b"}, {"10.0.0.4", ""}} {
+		if _, err := f.Set(args[0], args[1:]...); err == nil {
+			t.Errorf("Set(%q) succeeded", args)
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	f, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile of a file that does not exist = %v, want nil", err)
+	}
+	if _, err := f.Set("127.0.0.1", "localhost"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.Lookup("localhost"), []string{"127.0.0.1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup(localhost) = %q, want %q", got, want)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o644 {
+		t.Errorf("Stat = %v, %v, want mode 0644", fi, err)
+	}
+}
@@ -30,6 +30,7 @@ const (
 
 	// Chassis Device Commands
 	BMC_GET_CHASSIS_STATUS Command = 0x01
+	BMC_CHASSIS_IDENTIFY   Command = 0x04
 
 	// SEL device Commands
 	BMC_GET_SEL_INFO Command = 0x40
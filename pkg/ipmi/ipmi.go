@@ -307,6 +307,17 @@ func (i *IPMI) GetChassisStatus() (*ChassisStatus, error) {
 	return &status, nil
 }
 
+// ChassisIdentify turns the chassis identify light on for interval seconds,
+// or off if interval is 0. If force is set, it stays on until turned off.
+func (i *IPMI) ChassisIdentify(interval uint8, force bool) error {
+	data := []byte{interval, 0}
+	if force {
+		data[1] = 1
+	}
+	_, err := i.SendRecv(_IPMI_NETFN_CHASSIS, BMC_CHASSIS_IDENTIFY, data)
+	return err
+}
+
 func (i *IPMI) GetSELInfo() (*SELInfo, error) {
 	data, err := i.SendRecv(_IPMI_NETFN_STORAGE, BMC_GET_SEL_INFO, nil)
 	if err != nil {
@@ -157,6 +157,21 @@ func TestGetLanConfigQemu(t *testing.T) {
 		t.Errorf(`i.GetLanConfig(1, 1) = nil, not %q`, err)
 	}
 }
+func TestChassisIdentifyQemu(t *testing.T) {
+	testutil.SkipIfNotRoot(t)
+	i, err := Open(0)
+	if err != nil {
+		t.Fatalf("Open(0):= i,nil, not nil, %q", err)
+	}
+	defer i.Close()
+
+	if err := i.ChassisIdentify(15, false); err != nil {
+		t.Errorf(`i.ChassisIdentify(15, false) = nil, not %q`, err)
+	}
+	if err := i.ChassisIdentify(0, false); err != nil {
+		t.Errorf(`i.ChassisIdentify(0, false) = nil, not %q`, err)
+	}
+}
 func TestRawCmdQemu(t *testing.T) {
 	testutil.SkipIfNotRoot(t)
 	i, err := Open(0)
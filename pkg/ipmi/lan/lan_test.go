@@ -0,0 +1,364 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBMC is a BMC with one user, which echoes in upper case what is sent to
+// its serial port.
+type fakeBMC struct {
+	t        *testing.T
+	conn     net.PacketConn
+	user     string
+	password string
+
+	// accept is the most characters accepted from a SOL packet, and drop
+	// how many SOL packets to drop before answering.
+	accept int
+	drop   int
+
+	mu        sync.Mutex
+	consoleID uint32
+	rm, rc    []byte
+	role      byte
+	keys      *keys
+	seq       uint32
+	solSeq    byte
+	active    bool
+	breaks    int
+	closed    bool
+}
+
+const (
+	fakeBMCID = 0x0a0b0c0d
+	fakeGUID  = "0123456789abcdef"
+)
+
+func startBMC(t *testing.T, user, password string) *fakeBMC {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	b := &fakeBMC{t: t, conn: conn, user: user, password: password, accept: 255}
+	go b.serve()
+	return b
+}
+
+// state returns whether the session was closed and SOL is active, and how
+// many breaks were sent.
+func (b *fakeBMC) state() (closed, active bool, breaks int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed, b.active, b.breaks
+}
+
+func (b *fakeBMC) addr() string {
+	return b.conn.LocalAddr().String()
+}
+
+func (b *fakeBMC) serve() {
+	buf := make([]byte, maxPacket)
+	for {
+		n, addr, err := b.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		k := b.keys
+		b.mu.Unlock()
+		p, err := unmarshal(buf[:n], k)
+		if err != nil {
+			b.t.Errorf("BMC received invalid packet: %v", err)
+			continue
+		}
+		typ, resp := b.handle(p)
+		if resp == nil {
+			continue
+		}
+		b.reply(addr, typ, resp, k != nil && typ < payloadOpenSessionResponse)
+	}
+}
+
+func (b *fakeBMC) reply(addr net.Addr, typ byte, payload []byte, secured bool) {
+	b.mu.Lock()
+	p := &packet{payloadType: typ, payload: payload}
+	var k *keys
+	if secured {
+		b.seq++
+		p.sessionID, p.seq, k = b.consoleID, b.seq, b.keys
+	}
+	b.mu.Unlock()
+	pkt, err := p.marshal(k)
+	if err != nil {
+		b.t.Error(err)
+		return
+	}
+	b.conn.WriteTo(pkt, addr)
+}
+
+func (b *fakeBMC) handle(p *packet) (byte, []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m := p.payload
+	le := binary.LittleEndian
+	id := le.AppendUint32(nil, fakeBMCID)
+	switch p.payloadType {
+	case payloadOpenSessionRequest:
+		b.consoleID = le.Uint32(m[4:])
+		resp := []byte{m[0], 0, m[1], 0}
+		resp = append(resp, m[4:8]...)
+		resp = append(resp, id...)
+		return payloadOpenSessionResponse, append(resp, m[8:32]...)
+
+	case payloadRAKP1:
+		b.rm, b.role = append([]byte(nil), m[8:24]...), m[24]
+		if string(m[28:28+int(m[27])]) != b.user {
+			return payloadRAKP2, []byte{m[0], 0x0d, 0, 0}
+		}
+		b.rc = []byte("fedcba9876543210")
+		resp := []byte{m[0], 0, 0, 0}
+		resp = le.AppendUint32(resp, b.consoleID)
+		resp = append(resp, b.rc...)
+		resp = append(resp, fakeGUID...)
+		buf := le.AppendUint32(nil, b.consoleID)
+		buf = append(buf, id...)
+		buf = append(buf, b.rm...)
+		buf = append(buf, b.rc...)
+		buf = append(buf, fakeGUID...)
+		buf = append(buf, b.role, byte(len(b.user)))
+		buf = append(buf, b.user...)
+		return payloadRAKP2, append(resp, hmacSHA1([]byte(b.password), buf)...)
+
+	case payloadRAKP3:
+		buf := append([]byte(nil), b.rc...)
+		buf = le.AppendUint32(buf, b.consoleID)
+		buf = append(buf, b.role, byte(len(b.user)))
+		buf = append(buf, b.user...)
+		if !bytes.Equal(hmacSHA1([]byte(b.password), buf), m[8:28]) {
+			return payloadRAKP4, []byte{m[0], 0x0f, 0, 0}
+		}
+		buf = append(append([]byte(nil), b.rm...), b.rc...)
+		buf = append(buf, b.role, byte(len(b.user)))
+		buf = append(buf, b.user...)
+		sik := hmacSHA1([]byte(b.password), buf)
+		resp := []byte{m[0], 0, 0, 0}
+		resp = le.AppendUint32(resp, b.consoleID)
+		resp = append(resp, hmacSHA1(sik, b.rm, id, []byte(fakeGUID))[:integrityLen]...)
+		b.keys = deriveKeys(sik)
+		return payloadRAKP4, resp
+
+	case payloadIPMI:
+		return payloadIPMI, b.command(m)
+
+	case payloadSOL:
+		return payloadSOL, b.sol(m)
+	}
+	return 0, nil
+}
+
+// command returns the response to the IPMI message of a request.
+func (b *fakeBMC) command(m []byte) []byte {
+	if checksum(m[:3]) != 0 || checksum(m[3:]) != 0 {
+		b.t.Errorf("BMC received message with invalid checksum: % x", m)
+		return nil
+	}
+	var data []byte
+	switch m[5] {
+	case byte(cmdSetSessionPrivilegeLevel):
+		data = []byte{0, m[6]}
+	case byte(cmdGetDeviceID):
+		data = []byte{0, 0x20, 0x01}
+	case byte(cmdCloseSession):
+		b.closed = true
+		data = []byte{0}
+	case byte(cmdActivatePayload):
+		if b.active {
+			data = []byte{ccPayloadActive}
+			break
+		}
+		b.active = true
+		port := uint16(b.conn.LocalAddr().(*net.UDPAddr).Port)
+		data = []byte{0, 0, 0, 0, 0, 20, 0, 20, 0}
+		data = binary.LittleEndian.AppendUint16(data, port)
+		data = append(data, 0xff, 0xff)
+	case byte(cmdDeactivatePayload):
+		if !b.active {
+			data = []byte{ccPayloadActive}
+			break
+		}
+		b.active = false
+		data = []byte{0}
+	default:
+		// Invalid command.
+		data = []byte{0xc1}
+	}
+	resp := []byte{consoleAddr, m[1] + 4, 0, bmcAddr, m[4], m[5]}
+	resp[2] = checksum(resp[:2])
+	resp = append(resp, data...)
+	return append(resp, checksum(resp[3:]))
+}
+
+// sol returns the ACK of a SOL packet, with what it echoes.
+func (b *fakeBMC) sol(m []byte) []byte {
+	if m[0] == 0 {
+		// An ACK.
+		return nil
+	}
+	if b.drop > 0 {
+		b.drop--
+		return nil
+	}
+	if m[3]&solBreak != 0 {
+		b.breaks++
+	}
+	data := m[solHeaderLen:]
+	status := byte(0)
+	if len(data) > b.accept {
+		data, status = data[:b.accept], solNACK
+	}
+	b.solSeq = b.solSeq%15 + 1
+	return append([]byte{b.solSeq, m[0], byte(len(data)), status}, bytes.ToUpper(data)...)
+}
+
+func dial(b *fakeBMC, password string) (*Session, error) {
+	return Dial(b.addr(), Config{User: "admin", Password: password, Timeout: 100 * time.Millisecond})
+}
+
+func TestDial(t *testing.T) {
+	b := startBMC(t, "admin", "secret")
+	s, err := dial(b, "secret")
+	if err != nil {
+		t.Fatalf("Dial = %v", err)
+	}
+	if err := s.KeepAlive(); err != nil {
+		t.Errorf("KeepAlive = %v", err)
+	}
+	resp, err := s.SendRecv(netFnApp, 0x7f, nil)
+	var cerr *CompletionCodeError
+	if !errors.As(err, &cerr) || cerr.Code != 0xc1 || !bytes.Equal(resp, []byte{0xc1}) {
+		t.Errorf("SendRecv of an invalid command = % x, %v, want c1, completion code c1", resp, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	if closed, _, _ := b.state(); !closed {
+		t.Errorf("Close did not close the session of the BMC")
+	}
+	if _, err := s.SendRecv(netFnApp, cmdGetDeviceID, nil); err == nil {
+		t.Errorf("SendRecv after Close = nil, want error")
+	}
+}
+
+func TestDialErrors(t *testing.T) {
+	b := startBMC(t, "admin", "secret")
+	if _, err := dial(b, "wrong"); err == nil || !strings.Contains(err.Error(), "wrong password") {
+		t.Errorf("Dial with a wrong password = %v, want wrong password", err)
+	}
+	_, err := Dial(b.addr(), Config{User: "root", Timeout: 100 * time.Millisecond})
+	if want := StatusError(0x0d); !errors.Is(err, want) {
+		t.Errorf("Dial as an unknown user = %v, want %v", err, want)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = Dial(conn.LocalAddr().String(), Config{Timeout: 10 * time.Millisecond, Retries: 1})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("Dial of a silent BMC = %v, want %v", err, ErrTimeout)
+	}
+}
+
+func TestSOL(t *testing.T) {
+	b := startBMC(t, "admin", "secret")
+	// Packets are retried, and split into what the BMC accepts.
+	b.mu.Lock()
+	b.drop, b.accept = 1, 5
+	b.mu.Unlock()
+	s, err := dial(b, "secret")
+	if err != nil {
+		t.Fatalf("Dial = %v", err)
+	}
+	defer s.Close()
+
+	sol, err := s.ActivateSOL()
+	if err != nil {
+		t.Fatalf("ActivateSOL = %v", err)
+	}
+	if _, err := s.ActivateSOL(); !errors.Is(err, ErrSOLActive) {
+		t.Errorf("ActivateSOL when active = %v, want %v", err, ErrSOLActive)
+	}
+
+	const msg = "root\rcat /proc/cmdline\r"
+	if n, err := sol.Write([]byte(msg)); n != len(msg) || err != nil {
+		t.Errorf("Write = %d, %v, want %d, nil", n, err, len(msg))
+	}
+	got := make([]byte, len(msg))
+	if _, err := io.ReadFull(sol, got); err != nil || string(got) != strings.ToUpper(msg) {
+		t.Errorf("Read %q, %v, want %q", got, err, strings.ToUpper(msg))
+	}
+	if err := sol.Break(); err != nil {
+		t.Errorf("Break = %v", err)
+	}
+	if _, _, breaks := b.state(); breaks != 1 {
+		t.Errorf("Break sent %d breaks, want 1", breaks)
+	}
+
+	if err := sol.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	if _, active, _ := b.state(); active {
+		t.Errorf("Close did not deactivate SOL")
+	}
+	if _, err := sol.Read(got); err != ErrClosed {
+		t.Errorf("Read after Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestPacket(t *testing.T) {
+	k := deriveKeys([]byte("session integrity key"))
+	for _, payload := range []string{"", "x", "0123456789abcde", "0123456789abcdef0"} {
+		p := &packet{payloadType: payloadSOL, sessionID: 7, seq: 42, payload: []byte(payload)}
+		b, err := p.marshal(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b)%4 != 0 {
+			t.Errorf("packet of %q is %d bytes, want a multiple of 4", payload, len(b))
+		}
+		got, err := unmarshal(b, k)
+		if err != nil || got.payloadType != p.payloadType || got.sessionID != 7 || got.seq != 42 || string(got.payload) != payload {
+			t.Errorf("unmarshal(marshal(%+v)) = %+v, %v", p, got, err)
+		}
+		if _, err := unmarshal(b, nil); err == nil {
+			t.Errorf("unmarshal of a secured packet without keys = nil, want error")
+		}
+		b[len(b)-integrityLen-3] ^= 1
+		if _, err := unmarshal(b, k); err == nil {
+			t.Errorf("unmarshal of a corrupted packet = nil, want error")
+		}
+	}
+}
+
+func TestParsePrivilege(t *testing.T) {
+	if p, err := ParsePrivilege("operator"); p != Operator || err != nil {
+		t.Errorf("ParsePrivilege(operator) = %v, %v, want %v", p, err, Operator)
+	}
+	if _, err := ParsePrivilege("root"); err == nil {
+		t.Errorf("ParsePrivilege(root) = nil, want error")
+	}
+}
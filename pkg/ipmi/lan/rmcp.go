@@ -0,0 +1,217 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lan
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Payload types of RMCP+ packets.
+const (
+	payloadIPMI                = 0x00
+	payloadSOL                 = 0x01
+	payloadOpenSessionRequest  = 0x10
+	payloadOpenSessionResponse = 0x11
+	payloadRAKP1               = 0x12
+	payloadRAKP2               = 0x13
+	payloadRAKP3               = 0x14
+	payloadRAKP4               = 0x15
+
+	// Bits of the payload type of packets of a session.
+	payloadEncrypted     = 0x80
+	payloadAuthenticated = 0x40
+)
+
+const (
+	// authTypeRMCPPlus is the authentication type of IPMI v2.0 packets.
+	authTypeRMCPPlus = 0x06
+
+	// nextHeader ends the session trailer.
+	nextHeader = 0x07
+
+	// integrityLen is the length of HMAC-SHA1-96 authentication codes.
+	integrityLen = 12
+
+	// maxPacket is the largest packet received.
+	maxPacket = 1024
+)
+
+// rmcpHeader is the RMCP header of IPMI packets: version 1.0, no RMCP ACK,
+// class IPMI.
+var rmcpHeader = []byte{0x06, 0x00, 0xff, 0x07}
+
+var errMalformed = errors.New("malformed RMCP+ packet")
+
+// keys are the keys of an established session, derived from its session
+// integrity key.
+type keys struct {
+	// k1 authenticates packets, and k2 encrypts them.
+	k1, k2 []byte
+}
+
+// hmacSHA1 returns the HMAC-SHA1 of the concatenation of data with key.
+func hmacSHA1(key []byte, data ...[]byte) []byte {
+	h := hmac.New(sha1.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// deriveKeys returns the keys of a session with the session integrity key
+// sik.
+func deriveKeys(sik []byte) *keys {
+	const1 := make([]byte, sha1.Size)
+	const2 := make([]byte, sha1.Size)
+	for i := range const1 {
+		const1[i], const2[i] = 0x01, 0x02
+	}
+	return &keys{
+		k1: hmacSHA1(sik, const1),
+		k2: hmacSHA1(sik, const2)[:aes.BlockSize],
+	}
+}
+
+// packet is an RMCP+ packet.
+type packet struct {
+	// payloadType is the type of the payload, without the encrypted and
+	// authenticated bits.
+	payloadType byte
+
+	// sessionID is the ID of the session of the receiver, 0 outside of
+	// sessions, and seq the sequence number of the packet in the session.
+	sessionID uint32
+	seq       uint32
+
+	payload []byte
+}
+
+// marshal returns the packet, encrypted with AES-CBC-128 and authenticated
+// with HMAC-SHA1-96 with k if k is not nil.
+func (p *packet) marshal(k *keys) ([]byte, error) {
+	b := append([]byte{}, rmcpHeader...)
+	start := len(b)
+	t := p.payloadType
+	payload := p.payload
+	if k != nil {
+		t |= payloadEncrypted | payloadAuthenticated
+		var err error
+		if payload, err = encrypt(k.k2, payload); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, authTypeRMCPPlus, t)
+	b = binary.LittleEndian.AppendUint32(b, p.sessionID)
+	b = binary.LittleEndian.AppendUint32(b, p.seq)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(payload)))
+	b = append(b, payload...)
+	if k == nil {
+		return b, nil
+	}
+
+	// The integrity pad makes what is authenticated, from the
+	// authentication type to the next header, a multiple of 4 bytes.
+	pad := (4 - (len(b)-start+2)%4) % 4
+	for i := 0; i < pad; i++ {
+		b = append(b, 0xff)
+	}
+	b = append(b, byte(pad), nextHeader)
+	return append(b, hmacSHA1(k.k1, b[start:])[:integrityLen]...), nil
+}
+
+// unmarshal parses the packet b, which must be authenticated and encrypted
+// with k if k is not nil, and must not be otherwise.
+func unmarshal(b []byte, k *keys) (*packet, error) {
+	if len(b) < len(rmcpHeader)+12 || b[0] != rmcpHeader[0] || b[3] != rmcpHeader[3] {
+		return nil, errMalformed
+	}
+	b = b[len(rmcpHeader):]
+	if b[0] != authTypeRMCPPlus {
+		return nil, fmt.Errorf("IPMI v1.5 packets are not supported")
+	}
+	p := &packet{
+		payloadType: b[1] &^ (payloadEncrypted | payloadAuthenticated),
+		sessionID:   binary.LittleEndian.Uint32(b[2:]),
+		seq:         binary.LittleEndian.Uint32(b[6:]),
+	}
+	n := int(binary.LittleEndian.Uint16(b[10:]))
+	if len(b) < 12+n {
+		return nil, errMalformed
+	}
+	p.payload = b[12 : 12+n]
+
+	secured := b[1]&(payloadEncrypted|payloadAuthenticated) == payloadEncrypted|payloadAuthenticated
+	if k == nil {
+		if b[1]&(payloadEncrypted|payloadAuthenticated) != 0 {
+			return nil, fmt.Errorf("unexpected secured packet outside of a session")
+		}
+		return p, nil
+	}
+	if !secured {
+		return nil, fmt.Errorf("unsecured packet in a session")
+	}
+
+	trailer := b[12+n:]
+	if len(trailer) < 2+integrityLen {
+		return nil, errMalformed
+	}
+	authed := b[:len(b)-integrityLen]
+	if !hmac.Equal(hmacSHA1(k.k1, authed)[:integrityLen], b[len(b)-integrityLen:]) {
+		return nil, fmt.Errorf("packet fails its integrity check")
+	}
+	var err error
+	if p.payload, err = decrypt(k.k2, p.payload); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// encrypt returns b encrypted with AES-CBC-128 with key, preceded by its
+// initialization vector.
+func encrypt(key, b []byte) ([]byte, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	// The confidentiality pad is 1, 2, 3..., and then its length.
+	pad := (aes.BlockSize - (len(b)+1)%aes.BlockSize) % aes.BlockSize
+	out := make([]byte, aes.BlockSize, aes.BlockSize+len(b)+pad+1)
+	if _, err := rand.Read(out); err != nil {
+		return nil, err
+	}
+	out = append(out, b...)
+	for i := 1; i <= pad; i++ {
+		out = append(out, byte(i))
+	}
+	out = append(out, byte(pad))
+	cipher.NewCBCEncrypter(c, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], out[aes.BlockSize:])
+	return out, nil
+}
+
+// decrypt returns b, preceded by its initialization vector, decrypted with
+// AES-CBC-128 with key.
+func decrypt(key, b []byte) ([]byte, error) {
+	if len(b) < 2*aes.BlockSize || len(b)%aes.BlockSize != 0 {
+		return nil, errMalformed
+	}
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(b)-aes.BlockSize)
+	cipher.NewCBCDecrypter(c, b[:aes.BlockSize]).CryptBlocks(out, b[aes.BlockSize:])
+	pad := int(out[len(out)-1])
+	if pad >= aes.BlockSize {
+		return nil, errMalformed
+	}
+	return out[:len(out)-1-pad], nil
+}
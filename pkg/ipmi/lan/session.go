@@ -0,0 +1,498 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package lan implements IPMI v2.0 over LAN (RMCP+, the lanplus interface of
+// ipmitool) as a remote console of a BMC, e.g. to reach the serial console of
+// another machine with Serial over LAN.
+//
+// Sessions are authenticated with RAKP-HMAC-SHA1, and their packets with
+// HMAC-SHA1-96, and encrypted with AES-CBC-128: cipher suite 3, which BMCs
+// support by default.
+package lan
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ipmi"
+)
+
+// Port is the port of IPMI over LAN.
+const Port = 623
+
+// Addresses of IPMI messages.
+const (
+	bmcAddr     = 0x20
+	consoleAddr = 0x81
+)
+
+const (
+	netFnApp ipmi.NetFn = 0x06
+
+	cmdGetDeviceID              ipmi.Command = 0x01
+	cmdSetSessionPrivilegeLevel ipmi.Command = 0x3b
+	cmdCloseSession             ipmi.Command = 0x3c
+	cmdActivatePayload          ipmi.Command = 0x48
+	cmdDeactivatePayload        ipmi.Command = 0x49
+)
+
+// Algorithms of cipher suite 3.
+const (
+	authRAKPHMACSHA1         = 0x01
+	integrityHMACSHA196      = 0x01
+	confidentialityAESCBC128 = 0x01
+)
+
+var (
+	// ErrTimeout is returned when the BMC does not respond.
+	ErrTimeout = errors.New("no response from the BMC")
+
+	// ErrClosed is returned by the methods of a closed session.
+	ErrClosed = errors.New("session closed")
+)
+
+// Privilege is a privilege level of a session.
+type Privilege byte
+
+// Privilege levels.
+const (
+	Callback      Privilege = 1
+	User          Privilege = 2
+	Operator      Privilege = 3
+	Administrator Privilege = 4
+)
+
+var privileges = map[Privilege]string{
+	Callback:      "CALLBACK",
+	User:          "USER",
+	Operator:      "OPERATOR",
+	Administrator: "ADMINISTRATOR",
+}
+
+func (p Privilege) String() string {
+	if s, ok := privileges[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("Privilege(%d)", byte(p))
+}
+
+// ParsePrivilege returns the privilege level named s, e.g. ADMINISTRATOR, in
+// any case, as named by ipmitool -L.
+func ParsePrivilege(s string) (Privilege, error) {
+	for p, name := range privileges {
+		if strings.EqualFold(s, name) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown privilege level %q", s)
+}
+
+// StatusError is a status code other than 0 of the BMC in setting up a
+// session.
+type StatusError byte
+
+var statusText = map[StatusError]string{
+	0x01: "insufficient resources to create a session",
+	0x02: "invalid session ID",
+	0x03: "invalid payload type",
+	0x04: "invalid authentication algorithm",
+	0x05: "invalid integrity algorithm",
+	0x06: "no matching authentication payload",
+	0x07: "no matching integrity payload",
+	0x08: "inactive session ID",
+	0x09: "invalid role",
+	0x0a: "unauthorized role or privilege level requested",
+	0x0b: "insufficient resources to create a session at the requested role",
+	0x0c: "invalid name length",
+	0x0d: "unauthorized name",
+	0x0e: "unauthorized GUID",
+	0x0f: "invalid integrity check value",
+	0x10: "invalid confidentiality algorithm",
+	0x11: "no cipher suite match with proposed security algorithms",
+	0x12: "illegal or unrecognized parameter",
+}
+
+func (e StatusError) Error() string {
+	if s, ok := statusText[e]; ok {
+		return s
+	}
+	return fmt.Sprintf("status code %#x", byte(e))
+}
+
+// CompletionCodeError is returned by SendRecv when the BMC completes a
+// command with a code other than 0.
+type CompletionCodeError struct {
+	NetFn ipmi.NetFn
+	Cmd   ipmi.Command
+	Code  byte
+}
+
+func (e *CompletionCodeError) Error() string {
+	return fmt.Sprintf("command %#x of network function %#x completed with code %#x", e.Cmd, e.NetFn, e.Code)
+}
+
+// Config configures a session.
+type Config struct {
+	// User and Password authenticate the session. User is at most 16
+	// bytes, and Password 20.
+	User     string
+	Password string
+
+	// Kg is the key of the BMC, if it has one.
+	Kg []byte
+
+	// Privilege is the privilege level of the session, Administrator if
+	// 0.
+	Privilege Privilege
+
+	// Timeout is how long to wait for a response, a second if 0, and
+	// Retries how many times to resend a request then, 3 if 0.
+	Timeout time.Duration
+	Retries int
+}
+
+// Session is an authenticated session with a BMC. It is safe for concurrent
+// use.
+type Session struct {
+	conn net.Conn
+	c    Config
+	tag  byte
+
+	// consoleID and bmcID identify the session to the remote console, us,
+	// and to the BMC.
+	consoleID uint32
+	bmcID     uint32
+	keys      *keys
+
+	mu      sync.Mutex
+	seq     uint32
+	rqSeq   byte
+	pending map[byte]chan []byte
+	sol     *SOL
+
+	// done is closed when the session stops receiving, with err.
+	done chan struct{}
+	err  error
+}
+
+// Dial opens a session with the BMC at addr, a host with an optional port.
+func Dial(addr string, c Config) (*Session, error) {
+	if len(c.User) > 16 {
+		return nil, fmt.Errorf("user name %q is longer than 16 bytes", c.User)
+	}
+	if len(c.Password) > 20 {
+		return nil, fmt.Errorf("password is longer than 20 bytes")
+	}
+	if c.Privilege == 0 {
+		c.Privilege = Administrator
+	}
+	if c.Timeout == 0 {
+		c.Timeout = time.Second
+	}
+	if c.Retries == 0 {
+		c.Retries = 3
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(Port))
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		conn:    conn,
+		c:       c,
+		pending: make(map[byte]chan []byte),
+		done:    make(chan struct{}),
+	}
+	if err := s.open(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go s.receive()
+	if _, err := s.SendRecv(netFnApp, cmdSetSessionPrivilegeLevel, []byte{byte(c.Privilege)}); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("setting the privilege level to %v: %w", c.Privilege, err)
+	}
+	return s, nil
+}
+
+// exchange sends a message of type typ to set up the session, and returns the
+// payload of the response of type want.
+func (s *Session) exchange(typ byte, msg []byte, want byte) ([]byte, error) {
+	b, err := (&packet{payloadType: typ, payload: msg}).marshal(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer s.conn.SetReadDeadline(time.Time{})
+	buf := make([]byte, maxPacket)
+	for i := 0; i <= s.c.Retries; i++ {
+		if _, err := s.conn.Write(b); err != nil {
+			return nil, err
+		}
+		s.conn.SetReadDeadline(time.Now().Add(s.c.Timeout))
+		for {
+			n, err := s.conn.Read(buf)
+			var nerr net.Error
+			if errors.As(err, &nerr) && nerr.Timeout() {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			p, err := unmarshal(buf[:n], nil)
+			if err != nil || p.payloadType != want || len(p.payload) < 2 || p.payload[0] != msg[0] {
+				continue
+			}
+			if p.payload[1] != 0 {
+				return nil, StatusError(p.payload[1])
+			}
+			return append([]byte(nil), p.payload...), nil
+		}
+	}
+	return nil, ErrTimeout
+}
+
+// open authenticates the session with RAKP, and derives its keys.
+func (s *Session) open() error {
+	var id [4]byte
+	for binary.LittleEndian.Uint32(id[:]) == 0 {
+		if _, err := rand.Read(id[:]); err != nil {
+			return err
+		}
+	}
+	s.consoleID = binary.LittleEndian.Uint32(id[:])
+
+	s.tag++
+	req := []byte{s.tag, byte(s.c.Privilege), 0, 0}
+	req = append(req, id[:]...)
+	req = append(req,
+		0x00, 0, 0, 8, authRAKPHMACSHA1, 0, 0, 0,
+		0x01, 0, 0, 8, integrityHMACSHA196, 0, 0, 0,
+		0x02, 0, 0, 8, confidentialityAESCBC128, 0, 0, 0)
+	resp, err := s.exchange(payloadOpenSessionRequest, req, payloadOpenSessionResponse)
+	if err != nil {
+		return fmt.Errorf("opening a session: %w", err)
+	}
+	if len(resp) < 36 || binary.LittleEndian.Uint32(resp[4:]) != s.consoleID {
+		return fmt.Errorf("opening a session: %w", errMalformed)
+	}
+	if resp[16] != authRAKPHMACSHA1 || resp[24] != integrityHMACSHA196 || resp[32] != confidentialityAESCBC128 {
+		return fmt.Errorf("opening a session: the BMC does not support cipher suite 3")
+	}
+	s.bmcID = binary.LittleEndian.Uint32(resp[8:])
+	bmcID := resp[8:12]
+
+	// The role asks for the privilege level with a name-only lookup of
+	// the user.
+	role := []byte{0x10 | byte(s.c.Privilege)}
+	user := append([]byte{byte(len(s.c.User))}, s.c.User...)
+	rm := make([]byte, 16)
+	if _, err := rand.Read(rm); err != nil {
+		return err
+	}
+	s.tag++
+	req = []byte{s.tag, 0, 0, 0}
+	req = append(req, bmcID...)
+	req = append(req, rm...)
+	req = append(req, role[0], 0, 0)
+	req = append(req, user...)
+	resp, err = s.exchange(payloadRAKP1, req, payloadRAKP2)
+	if err != nil {
+		return fmt.Errorf("authenticating as %q: %w", s.c.User, err)
+	}
+	if len(resp) < 60 || binary.LittleEndian.Uint32(resp[4:]) != s.consoleID {
+		return fmt.Errorf("authenticating as %q: %w", s.c.User, errMalformed)
+	}
+	rc, guid := resp[8:24], resp[24:40]
+	kuid := []byte(s.c.Password)
+	if !hmac.Equal(hmacSHA1(kuid, id[:], bmcID, rm, rc, guid, role, user), resp[40:60]) {
+		return fmt.Errorf("authenticating as %q: wrong password", s.c.User)
+	}
+
+	s.tag++
+	req = []byte{s.tag, 0, 0, 0}
+	req = append(req, bmcID...)
+	req = append(req, hmacSHA1(kuid, rc, id[:], role, user)...)
+	resp, err = s.exchange(payloadRAKP3, req, payloadRAKP4)
+	if err != nil {
+		return fmt.Errorf("authenticating as %q: %w", s.c.User, err)
+	}
+	kg := s.c.Kg
+	if kg == nil {
+		kg = kuid
+	}
+	sik := hmacSHA1(kg, rm, rc, role, user)
+	if len(resp) < 8+integrityLen || !hmac.Equal(hmacSHA1(sik, rm, bmcID, guid)[:integrityLen], resp[8:8+integrityLen]) {
+		return fmt.Errorf("authenticating the BMC: wrong integrity check value")
+	}
+	s.keys = deriveKeys(sik)
+	return nil
+}
+
+// receive receives the packets of the session, until it is closed.
+func (s *Session) receive() {
+	buf := make([]byte, maxPacket)
+	for {
+		n, err := s.conn.Read(buf)
+		if errors.Is(err, net.ErrClosed) {
+			s.fail(ErrClosed)
+			return
+		}
+		if err != nil {
+			// E.g. ICMP port unreachable.
+			continue
+		}
+		p, err := unmarshal(buf[:n], s.keys)
+		if err != nil || p.sessionID != s.consoleID {
+			continue
+		}
+		switch p.payloadType {
+		case payloadIPMI:
+			s.deliver(p.payload)
+		case payloadSOL:
+			s.mu.Lock()
+			sol := s.sol
+			s.mu.Unlock()
+			if sol != nil {
+				sol.receive(p.payload)
+			}
+		}
+	}
+}
+
+// fail stops the session with err.
+func (s *Session) fail(err error) {
+	s.mu.Lock()
+	s.err = err
+	sol := s.sol
+	s.mu.Unlock()
+	close(s.done)
+	if sol != nil {
+		sol.fail(err)
+	}
+}
+
+// checksum returns the checksum of IPMI messages of b: what makes their sum
+// 0.
+func checksum(b []byte) byte {
+	var c byte
+	for _, v := range b {
+		c += v
+	}
+	return -c
+}
+
+// request returns the IPMI message of a request to the BMC.
+func request(netfn ipmi.NetFn, cmd ipmi.Command, seq byte, data []byte) []byte {
+	b := []byte{bmcAddr, byte(netfn) << 2, 0, consoleAddr, seq << 2, byte(cmd)}
+	b[2] = checksum(b[:2])
+	b = append(b, data...)
+	return append(b, checksum(b[3:]))
+}
+
+// deliver hands the IPMI message of a response to SendRecv.
+func (s *Session) deliver(b []byte) {
+	// rqAddr, netFn/rqLUN, checksum, rsAddr, rqSeq/rsLUN, cmd, completion
+	// code, data, checksum.
+	if len(b) < 8 || checksum(b[:3]) != 0 || checksum(b[3:]) != 0 {
+		return
+	}
+	s.mu.Lock()
+	ch := s.pending[b[4]>>2]
+	s.mu.Unlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- append([]byte(nil), b...):
+	default:
+		// A response to a retried request.
+	}
+}
+
+// send sends a payload in the session.
+func (s *Session) send(typ byte, payload []byte) error {
+	s.mu.Lock()
+	s.seq++
+	if s.seq == 0 {
+		s.seq = 1
+	}
+	p := &packet{payloadType: typ, sessionID: s.bmcID, seq: s.seq, payload: payload}
+	s.mu.Unlock()
+	b, err := p.marshal(s.keys)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.Write(b)
+	return err
+}
+
+// SendRecv sends a request to the BMC, and returns the response data, which
+// starts with the completion code, as ipmi.IPMI.SendRecv does. Completion
+// codes other than 0 are returned as a *CompletionCodeError too.
+func (s *Session) SendRecv(netfn ipmi.NetFn, cmd ipmi.Command, data []byte) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	s.mu.Lock()
+	seq := s.rqSeq
+	s.rqSeq = (s.rqSeq + 1) & 0x3f
+	s.pending[seq] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, seq)
+		s.mu.Unlock()
+	}()
+
+	msg := request(netfn, cmd, seq, data)
+	for i := 0; i <= s.c.Retries; i++ {
+		if err := s.send(payloadIPMI, msg); err != nil {
+			return nil, err
+		}
+		t := time.NewTimer(s.c.Timeout)
+		select {
+		case r := <-ch:
+			t.Stop()
+			if ipmi.Command(r[5]) != cmd || ipmi.NetFn(r[1]>>2) != netfn|1 {
+				return nil, fmt.Errorf("response to command %#x, want %#x", r[5], cmd)
+			}
+			resp := r[6 : len(r)-1]
+			if resp[0] != 0 {
+				return resp, &CompletionCodeError{NetFn: netfn, Cmd: cmd, Code: resp[0]}
+			}
+			return resp, nil
+		case <-t.C:
+		case <-s.done:
+			t.Stop()
+			return nil, s.err
+		}
+	}
+	return nil, ErrTimeout
+}
+
+// KeepAlive sends a request, Get Device ID, to keep the BMC from closing the
+// session when it is idle, usually after a minute.
+func (s *Session) KeepAlive() error {
+	_, err := s.SendRecv(netFnApp, cmdGetDeviceID, nil)
+	return err
+}
+
+// Close closes the session.
+func (s *Session) Close() error {
+	id := binary.LittleEndian.AppendUint32(nil, s.bmcID)
+	_, err := s.SendRecv(netFnApp, cmdCloseSession, id)
+	if cerr := s.conn.Close(); err == nil {
+		err = cerr
+	}
+	<-s.done
+	return err
+}
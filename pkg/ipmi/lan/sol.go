@@ -0,0 +1,262 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Bits of the operation and status of SOL packets.
+const (
+	solNACK         = 0x40
+	solBreak        = 0x10
+	solDeactivating = 0x10
+)
+
+// Auxiliary data of Activate Payload for SOL: encrypted, authenticated, and
+// serial alerts deferred while SOL is active.
+const solActivate = 0x80 | 0x40 | 0x04
+
+const (
+	// solHeaderLen is the length of the header of SOL packets.
+	solHeaderLen = 4
+
+	// ccPayloadActive is the completion code of Activate Payload when
+	// another session has SOL active, and of Deactivate Payload when none
+	// does.
+	ccPayloadActive = 0x80
+)
+
+// ErrSOLActive is returned by ActivateSOL when another session has SOL
+// active; DeactivateSOL deactivates it.
+var ErrSOLActive = errors.New("SOL is active in another session")
+
+// SOL is the Serial over LAN console of the managed system: what is written
+// is sent to its serial port, and what its serial port sends is read.
+type SOL struct {
+	s *Session
+
+	// max is the most characters in a packet to the BMC.
+	max int
+
+	wmu  sync.Mutex
+	seq  byte
+	acks chan []byte
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  bytes.Buffer
+	err  error
+	// last is the sequence number of the last packet received.
+	last byte
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// ActivateSOL activates SOL in the session.
+func (s *Session) ActivateSOL() (*SOL, error) {
+	r, err := s.SendRecv(netFnApp, cmdActivatePayload, []byte{payloadSOL, 1, solActivate, 0, 0, 0})
+	var cerr *CompletionCodeError
+	if errors.As(err, &cerr) && cerr.Code == ccPayloadActive {
+		return nil, ErrSOLActive
+	}
+	if err != nil {
+		return nil, fmt.Errorf("activating SOL: %w", err)
+	}
+	// Completion code, auxiliary data, inbound and outbound payload
+	// sizes, port and VLAN.
+	if len(r) < 13 {
+		return nil, fmt.Errorf("activating SOL: response too short")
+	}
+	in := int(binary.LittleEndian.Uint16(r[5:]))
+	port := int(binary.LittleEndian.Uint16(r[9:]))
+	if port != s.conn.RemoteAddr().(*net.UDPAddr).Port {
+		s.DeactivateSOL()
+		return nil, fmt.Errorf("SOL on port %d, another than the session's, is not supported", port)
+	}
+	if in <= solHeaderLen {
+		s.DeactivateSOL()
+		return nil, fmt.Errorf("activating SOL: invalid payload size %d", in)
+	}
+	sol := &SOL{
+		s:      s,
+		max:    in - solHeaderLen,
+		acks:   make(chan []byte, 1),
+		closed: make(chan struct{}),
+	}
+	sol.cond = sync.NewCond(&sol.mu)
+	s.mu.Lock()
+	s.sol = sol
+	s.mu.Unlock()
+	return sol, nil
+}
+
+// DeactivateSOL deactivates SOL, whichever session has it active.
+func (s *Session) DeactivateSOL() error {
+	_, err := s.SendRecv(netFnApp, cmdDeactivatePayload, []byte{payloadSOL, 1, 0, 0, 0, 0})
+	var cerr *CompletionCodeError
+	if errors.As(err, &cerr) && cerr.Code == ccPayloadActive {
+		return nil
+	}
+	return err
+}
+
+// receive handles a SOL packet from the BMC.
+func (sol *SOL) receive(b []byte) {
+	if len(b) < solHeaderLen {
+		return
+	}
+	seq, ack, status := b[0]&0x0f, b[1]&0x0f, b[3]
+	if ack != 0 {
+		select {
+		case sol.acks <- append([]byte(nil), b[:solHeaderLen]...):
+		default:
+		}
+	}
+	if seq != 0 {
+		data := b[solHeaderLen:]
+		sol.mu.Lock()
+		// The BMC resends packets whose ACK it missed.
+		if seq != sol.last {
+			sol.last = seq
+			sol.buf.Write(data)
+			sol.cond.Broadcast()
+		}
+		sol.mu.Unlock()
+		sol.s.send(payloadSOL, []byte{0, seq, byte(len(data)), 0})
+	}
+	if status&solDeactivating != 0 {
+		sol.fail(io.EOF)
+	}
+}
+
+// fail makes reads and writes fail with err, or io.EOF once what was received
+// is read if err is io.EOF.
+func (sol *SOL) fail(err error) {
+	sol.closeOnce.Do(func() {
+		sol.mu.Lock()
+		sol.err = err
+		sol.cond.Broadcast()
+		sol.mu.Unlock()
+		close(sol.closed)
+	})
+}
+
+// Read reads what the serial port of the managed system sent.
+func (sol *SOL) Read(p []byte) (int, error) {
+	sol.mu.Lock()
+	defer sol.mu.Unlock()
+	for sol.buf.Len() == 0 && sol.err == nil {
+		sol.cond.Wait()
+	}
+	if sol.buf.Len() > 0 {
+		return sol.buf.Read(p)
+	}
+	return 0, sol.err
+}
+
+// Write sends p to the serial port of the managed system.
+func (sol *SOL) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return sol.write(p, 0)
+}
+
+// Break sends a break to the serial port of the managed system, e.g. for
+// SysRq.
+func (sol *SOL) Break() error {
+	_, err := sol.write(nil, solBreak)
+	return err
+}
+
+// write sends p in packets with the operation op in the first, and returns
+// how much of p the BMC accepted.
+func (sol *SOL) write(p []byte, op byte) (int, error) {
+	sol.wmu.Lock()
+	defer sol.wmu.Unlock()
+	var n, busy int
+	for first := true; first || n < len(p); first = false {
+		chunk := p[n:]
+		if len(chunk) > sol.max {
+			chunk = chunk[:sol.max]
+		}
+		sol.seq = sol.seq%15 + 1
+		accepted, err := sol.send(append([]byte{sol.seq, 0, 0, op}, chunk...), len(chunk))
+		if err != nil {
+			return n, err
+		}
+		op = 0
+		n += accepted
+		if accepted > 0 || len(chunk) == 0 {
+			busy = 0
+			continue
+		}
+		// The BMC could not take any of it, e.g. as the serial port is
+		// busy.
+		if busy++; busy > sol.s.c.Retries {
+			return n, ErrTimeout
+		}
+		time.Sleep(sol.s.c.Timeout / 4)
+	}
+	return n, nil
+}
+
+// send sends the SOL packet pkt, with n characters, until the BMC ACKs it,
+// and returns how many it accepted.
+func (sol *SOL) send(pkt []byte, n int) (int, error) {
+	select {
+	case <-sol.acks:
+	default:
+	}
+	for i := 0; i <= sol.s.c.Retries; i++ {
+		if err := sol.s.send(payloadSOL, pkt); err != nil {
+			return 0, err
+		}
+		t := time.NewTimer(sol.s.c.Timeout)
+	wait:
+		for {
+			select {
+			case a := <-sol.acks:
+				if a[1]&0x0f != pkt[0] {
+					continue
+				}
+				t.Stop()
+				if a[3]&solNACK == 0 {
+					return n, nil
+				}
+				if accepted := int(a[2]); accepted < n {
+					return accepted, nil
+				}
+				return n, nil
+			case <-t.C:
+				break wait
+			case <-sol.closed:
+				t.Stop()
+				return 0, sol.err
+			}
+		}
+	}
+	return 0, ErrTimeout
+}
+
+// Close deactivates SOL. The session stays open.
+func (sol *SOL) Close() error {
+	sol.s.mu.Lock()
+	if sol.s.sol == sol {
+		sol.s.sol = nil
+	}
+	sol.s.mu.Unlock()
+	sol.fail(ErrClosed)
+	return sol.s.DeactivateSOL()
+}
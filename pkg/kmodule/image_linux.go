@@ -0,0 +1,99 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kmodule
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/mount/loop"
+	"golang.org/x/sys/unix"
+)
+
+// ModuleImage is a squashfs or erofs image holding a module tree, mounted
+// read-only so modules can be loaded straight out of it.
+//
+// The image may contain the tree at lib/modules/<release>,
+// usr/lib/modules/<release> or directly at <release>.
+type ModuleImage struct {
+	// Dir is where the image is mounted.
+	Dir string
+
+	loop *loop.Loop
+	mp   *mount.MountPoint
+
+	// tempDir is set if Dir was created by OpenModuleImage.
+	tempDir bool
+}
+
+// OpenModuleImage attaches image to a loop device and mounts it read-only at
+// dir. If dir is empty, a temporary directory is created, which Close
+// removes.
+func OpenModuleImage(image, dir string) (m *ModuleImage, err error) {
+	fstype, _, err := mount.FSFromBlock(image)
+	if err != nil {
+		return nil, err
+	}
+	if fstype != "squashfs" && fstype != "erofs" {
+		return nil, fmt.Errorf("module image %q: unsupported file system %q, want squashfs or erofs", image, fstype)
+	}
+
+	tempDir := dir == ""
+	if tempDir {
+		if dir, err = os.MkdirTemp("", "modules-"); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				os.Remove(dir)
+			}
+		}()
+	}
+
+	l, err := loop.New(image, fstype, "")
+	if err != nil {
+		return nil, fmt.Errorf("module image %q: %v", image, err)
+	}
+	mp, err := l.Mount(dir, unix.MS_RDONLY)
+	if err != nil {
+		l.Free()
+		return nil, fmt.Errorf("module image %q: %v", image, err)
+	}
+	return &ModuleImage{
+		Dir:     dir,
+		loop:    l,
+		mp:      mp,
+		tempDir: tempDir,
+	}, nil
+}
+
+// ProbeOpts returns opts with RootDir pointing into the image.
+func (m *ModuleImage) ProbeOpts(opts ProbeOpts) ProbeOpts {
+	opts.RootDir = m.Dir
+	return opts
+}
+
+// Probe loads the given kernel module and its dependencies out of the image.
+func (m *ModuleImage) Probe(name, modParams string) error {
+	return ProbeOptions(name, modParams, m.ProbeOpts(ProbeOpts{}))
+}
+
+// Close unmounts the image, frees the loop device and removes the mount point
+// if OpenModuleImage created it.
+//
+// Loaded modules stay loaded; the kernel holds its own copy.
+func (m *ModuleImage) Close() error {
+	if err := m.mp.Unmount(0); err != nil {
+		return err
+	}
+	if err := m.loop.Free(); err != nil {
+		return err
+	}
+	if m.tempDir {
+		return os.Remove(m.Dir)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kmodule
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testdata/modules.squashfs holds lib/modules/5.10.0-test with a single
+// module, kernel/test.ko. It is padded to the 64 KiB that mount.FSFromBlock
+// reads.
+
+func TestModuleImage(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Skipping test since we are not root")
+	}
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	img, err := OpenModuleImage("testdata/modules.squashfs", "")
+	if err != nil {
+		t.Skipf("can't mount squashfs image: %v", err)
+	}
+
+	var got []string
+	opts := img.ProbeOpts(ProbeOpts{
+		KVer:           "5.10.0-test",
+		IgnoreProcMods: true,
+		DryRunCB:       func(p string) { got = append(got, p) },
+	})
+	if err := ProbeOptions("test", "", opts); err != nil {
+		t.Error(err)
+	}
+	want := []string{filepath.Join(img.Dir, "lib/modules/5.10.0-test/kernel/test.ko")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %v, want %v", got, want)
+	}
+
+	if err := img.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(img.Dir); !os.IsNotExist(err) {
+		t.Errorf("mount point %s still exists after Close: %v", img.Dir, err)
+	}
+}
+
+func TestModuleImageMountFails(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Skipping test since we are not root")
+	}
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	// Keep the squashfs magic, but nothing else.
+	good, err := os.ReadFile("testdata/modules.squashfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := make([]byte, len(good))
+	copy(bad, good[:4])
+	image := filepath.Join(t.TempDir(), "bad.squashfs")
+	if err := os.WriteFile(image, bad, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if img, err := OpenModuleImage(image, ""); err == nil {
+		img.Close()
+		t.Fatalf("OpenModuleImage(corrupt image) succeeded")
+	}
+	if ents, _ := os.ReadDir(tmp); len(ents) != 0 {
+		t.Errorf("OpenModuleImage left %v behind", ents)
+	}
+}
@@ -168,6 +168,22 @@ func checkBuiltin(moduleDir string, deps depMap) error {
 	return scanner.Err()
 }
 
+// findModuleDir returns the module tree for release rel below root.
+//
+// Module images (see OpenModuleImage) may also hold the tree directly at
+// root/<rel>, so that is tried last.
+func findModuleDir(root, rel string) string {
+	var moduleDir string
+	for _, n := range []string{"/lib/modules", "/usr/lib/modules", "/"} {
+		moduleDir = filepath.Join(root, n, strings.TrimSpace(rel))
+		if _, err := os.Stat(filepath.Join(moduleDir, "modules.dep")); err == nil {
+			return moduleDir
+		}
+	}
+	// Let the caller produce a useful error about the canonical path.
+	return filepath.Join(root, "/lib/modules", strings.TrimSpace(rel))
+}
+
 func genDeps(opts ProbeOpts) (depMap, error) {
 	deps := make(depMap)
 	rel := opts.KVer
@@ -180,14 +196,7 @@ func genDeps(opts ProbeOpts) (depMap, error) {
 		rel = string(u.Release[:bytes.IndexByte(u.Release[:], 0)])
 	}
 
-	var moduleDir string
-	for _, n := range []string{"/lib/modules", "/usr/lib/modules"} {
-		moduleDir = filepath.Join(opts.RootDir, n, strings.TrimSpace(rel))
-		if _, err := os.Stat(moduleDir); err == nil {
-			break
-		}
-	}
-
+	moduleDir := findModuleDir(opts.RootDir, rel)
 	f, err := os.Open(filepath.Join(moduleDir, "modules.dep"))
 	if err != nil {
 		return nil, fmt.Errorf("could not open dependency file: %v", err)
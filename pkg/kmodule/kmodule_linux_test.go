@@ -6,7 +6,10 @@ package kmodule
 
 import (
 	"bytes"
+	"os"
 	"path"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -32,3 +35,31 @@ func TestGenLoadedMods(t *testing.T) {
 		}
 	}
 }
+
+func TestProbeFlatModuleTree(t *testing.T) {
+	// Module images may hold the tree directly at <root>/<release>.
+	root := t.TempDir()
+	dir := filepath.Join(root, "6.6.6-generic")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dep := "kernel/a.ko: kernel/b.ko.xz\nkernel/b.ko.xz:\n"
+	if err := os.WriteFile(filepath.Join(dir, "modules.dep"), []byte(dep), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	opts := ProbeOpts{
+		RootDir:        root,
+		KVer:           "6.6.6-generic",
+		IgnoreProcMods: true,
+		DryRunCB:       func(p string) { got = append(got, p) },
+	}
+	if err := ProbeOptions("a", "", opts); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "kernel/b.ko.xz"), filepath.Join(dir, "kernel/a.ko")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("load order = %v, want %v", got, want)
+	}
+}
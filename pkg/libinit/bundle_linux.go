@@ -0,0 +1,187 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/cpio"
+	"github.com/u-root/u-root/pkg/curl"
+	"github.com/u-root/u-root/pkg/vfile"
+)
+
+// DefaultBundleKeyRing holds the OpenPGP public keys that uinit bundles must
+// be signed with. It is baked into the initramfs when it is built.
+const DefaultBundleKeyRing = "/etc/uinit.keyring"
+
+// DefaultBundleDir is where FetchBundle unpacks a uinit bundle.
+const DefaultBundleDir = "/run/uinit"
+
+// maxBundleSize bounds how much FetchBundle reads into memory.
+const maxBundleSize = 512 << 20
+
+// BundleOpts configures FetchBundle.
+type BundleOpts struct {
+	// URL is where the bundle is. A bundle is either a newc cpio archive
+	// with an executable uinit at its root, or a single executable.
+	URL string
+
+	// SigURL is where the detached OpenPGP signature of the bundle is. It
+	// defaults to URL with ".sig" appended.
+	SigURL string
+
+	// KeyRing is the path of the keyring that the bundle must be signed
+	// with. It defaults to DefaultBundleKeyRing.
+	KeyRing string
+
+	// Dir is where the bundle is unpacked. It defaults to DefaultBundleDir.
+	Dir string
+
+	// Timeout bounds how long FetchBundle keeps retrying the download,
+	// e.g. while the network is still coming up. Zero means a single
+	// attempt.
+	Timeout time.Duration
+
+	// schemes is overridden in tests.
+	schemes curl.Schemes
+}
+
+// BundleOptsFromCmdline reads BundleOpts from the kernel command line:
+//
+//	uroot.uinitbundle=URL         the bundle
+//	uroot.uinitbundlesig=URL      its signature, default URL.sig
+//	uroot.uinitbundletimeout=DUR  how long to retry the download
+//
+// The network must be up by the time init runs, e.g. with ip=, for a
+// bundle to be fetched.
+//
+// It returns false if uroot.uinitbundle is not present.
+func BundleOptsFromCmdline(c *cmdline.CmdLine) (BundleOpts, bool) {
+	u, ok := c.Flag("uroot.uinitbundle")
+	if !ok || u == "" {
+		return BundleOpts{}, false
+	}
+	opts := BundleOpts{URL: u}
+	if s, ok := c.Flag("uroot.uinitbundlesig"); ok {
+		opts.SigURL = s
+	}
+	if s, ok := c.Flag("uroot.uinitbundletimeout"); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			opts.Timeout = d
+		}
+	}
+	return opts, true
+}
+
+func (o *BundleOpts) defaults() {
+	if o.SigURL == "" {
+		o.SigURL = o.URL + ".sig"
+	}
+	if o.KeyRing == "" {
+		o.KeyRing = DefaultBundleKeyRing
+	}
+	if o.Dir == "" {
+		o.Dir = DefaultBundleDir
+	}
+	if o.schemes == nil {
+		o.schemes = curl.Schemes{
+			"http":  curl.DefaultHTTPClient,
+			"https": curl.DefaultHTTPClient,
+			"tftp":  curl.DefaultTFTPClient,
+			"file":  &curl.LocalFileClient{},
+		}
+	}
+}
+
+// fetch reads all of the file at rawURL, retrying until deadline.
+func (o *BundleOpts) fetch(ctx context.Context, rawURL string, deadline time.Time) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var b []byte
+		r, err := o.schemes.FetchWithoutCache(ctx, u)
+		if err == nil {
+			b, err = io.ReadAll(io.LimitReader(r, maxBundleSize+1))
+			if err == nil && len(b) > maxBundleSize {
+				return nil, fmt.Errorf("%s is larger than %d bytes", u, maxBundleSize)
+			}
+		}
+		if err == nil || !time.Now().Before(deadline) {
+			return b, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// unpack puts the uinit of bundle b into dir.
+func unpack(b []byte, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(b, []byte("070701")) {
+		return os.WriteFile(filepath.Join(dir, "uinit"), b, 0o755)
+	}
+	return cpio.ForEachRecord(cpio.Newc.Reader(bytes.NewReader(b)), func(r cpio.Record) error {
+		return cpio.CreateFileInRoot(r, dir, false)
+	})
+}
+
+// FetchBundle downloads the uinit bundle of opts, verifies its signature
+// and unpacks it. It returns the path of the uinit to run.
+//
+// Nothing is unpacked unless the signature is good: a bundle that cannot
+// be verified is an error, and init goes on with the local uinit.
+func FetchBundle(ctx context.Context, opts BundleOpts) (string, error) {
+	opts.defaults()
+	f, err := os.Open(opts.KeyRing)
+	if err != nil {
+		return "", err
+	}
+	ring, err := vfile.ReadKeyRing(f)
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("reading keyring %s: %w", opts.KeyRing, err)
+	}
+
+	deadline := time.Now().Add(opts.Timeout)
+	b, err := opts.fetch(ctx, opts.URL, deadline)
+	if err != nil {
+		return "", err
+	}
+	sig, err := opts.fetch(ctx, opts.SigURL, deadline)
+	if err != nil {
+		return "", err
+	}
+	if _, err := vfile.VerifyDetachedSignature(ring, bytes.NewReader(b), bytes.NewReader(sig)); err != nil {
+		return "", fmt.Errorf("verifying %s: %w", opts.URL, err)
+	}
+
+	if err := unpack(b, opts.Dir); err != nil {
+		return "", fmt.Errorf("unpacking %s: %w", opts.URL, err)
+	}
+	uinit := filepath.Join(opts.Dir, "uinit")
+	fi, err := os.Stat(uinit)
+	if err != nil {
+		return "", fmt.Errorf("bundle %s has no uinit: %w", opts.URL, err)
+	}
+	if !fi.Mode().IsRegular() || fi.Mode().Perm()&0o111 == 0 {
+		return "", fmt.Errorf("the uinit of bundle %s is not an executable file", opts.URL)
+	}
+	return uinit, nil
+}
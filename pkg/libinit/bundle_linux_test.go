@@ -0,0 +1,177 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/cpio"
+	"github.com/u-root/u-root/pkg/curl"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestFetchBundle(t *testing.T) {
+	key, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ring bytes.Buffer
+	if err := key.Serialize(&ring); err != nil {
+		t.Fatal(err)
+	}
+	keyRing := filepath.Join(t.TempDir(), "uinit.keyring")
+	if err := os.WriteFile(keyRing, ring.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sign := func(signer *openpgp.Entity, b []byte) string {
+		var sig bytes.Buffer
+		if err := openpgp.DetachSign(&sig, signer, bytes.NewReader(b), nil); err != nil {
+			t.Fatal(err)
+		}
+		return sig.String()
+	}
+
+	var archive bytes.Buffer
+	if err := cpio.WriteRecords(cpio.Newc.Writer(&archive), []cpio.Record{
+		cpio.Directory("lib", 0o755),
+		cpio.StaticFile("lib/site.conf", "site", 0o644),
+		cpio.StaticFile("uinit", "#!/bin/sh\n", 0o755),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var noUinit bytes.Buffer
+	if err := cpio.WriteRecords(cpio.Newc.Writer(&noUinit), []cpio.Record{
+		cpio.StaticFile("init", "#!/bin/sh\n", 0o755),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	binary := []byte("\x7fELF binary")
+
+	fs := curl.NewMockScheme("http")
+	fs.Add("boot", "/site.cpio", archive.String())
+	fs.Add("boot", "/site.cpio.sig", sign(key, archive.Bytes()))
+	fs.Add("boot", "/uinit", string(binary))
+	fs.Add("boot", "/uinit.sig", sign(key, binary))
+	fs.Add("boot", "/other", string(binary))
+	fs.Add("boot", "/other.sig", sign(other, binary))
+	fs.Add("boot", "/tampered", string(binary)+"!")
+	fs.Add("boot", "/tampered.sig", sign(key, binary))
+	fs.Add("boot", "/nouinit.cpio", noUinit.String())
+	fs.Add("boot", "/nouinit.cpio.sig", sign(key, noUinit.Bytes()))
+	fs.Add("boot", "/detached", archive.String())
+	fs.Add("boot", "/sigs/detached", sign(key, archive.Bytes()))
+	schemes := curl.Schemes{"http": fs}
+
+	for _, tt := range []struct {
+		name    string
+		url     string
+		sigURL  string
+		want    string
+		files   map[string]string
+		wantErr bool
+	}{
+		{name: "cpio", url: "http://boot/site.cpio", want: "#!/bin/sh\n", files: map[string]string{"lib/site.conf": "site"}},
+		{name: "binary", url: "http://boot/uinit", want: string(binary)},
+		{name: "signature url", url: "http://boot/detached", sigURL: "http://boot/sigs/detached", want: "#!/bin/sh\n"},
+		{name: "unknown signer", url: "http://boot/other", wantErr: true},
+		{name: "tampered", url: "http://boot/tampered", wantErr: true},
+		{name: "no uinit", url: "http://boot/nouinit.cpio", wantErr: true},
+		{name: "not found", url: "http://boot/missing", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "uinit")
+			got, err := FetchBundle(context.Background(), BundleOpts{
+				URL:     tt.url,
+				SigURL:  tt.sigURL,
+				KeyRing: keyRing,
+				Dir:     dir,
+				schemes: schemes,
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("FetchBundle = %q, want error", got)
+				}
+				if _, err := os.Stat(filepath.Join(dir, "uinit")); err == nil {
+					t.Errorf("uinit was unpacked from a bad bundle")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchBundle = %v", err)
+			}
+			if want := filepath.Join(dir, "uinit"); got != want {
+				t.Errorf("FetchBundle = %q, want %q", got, want)
+			}
+			b, err := os.ReadFile(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("uinit = %q, want %q", b, tt.want)
+			}
+			for name, want := range tt.files {
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil || string(b) != want {
+					t.Errorf("%s = %q, %v, want %q", name, b, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchBundleNoKeyRing(t *testing.T) {
+	if _, err := FetchBundle(context.Background(), BundleOpts{
+		URL:     "http://boot/uinit",
+		KeyRing: filepath.Join(t.TempDir(), "missing"),
+		schemes: curl.Schemes{"http": curl.NewMockScheme("http")},
+	}); err == nil {
+		t.Errorf("FetchBundle without a keyring: got nil, want error")
+	}
+}
+
+func TestBundleOptsFromCmdline(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args map[string]string
+		want BundleOpts
+		ok   bool
+	}{
+		{name: "absent", args: map[string]string{}},
+		{name: "empty", args: map[string]string{"uroot.uinitbundle": ""}},
+		{
+			name: "url",
+			args: map[string]string{"uroot.uinitbundle": "https://boot/site.cpio"},
+			want: BundleOpts{URL: "https://boot/site.cpio"},
+			ok:   true,
+		},
+		{
+			name: "all",
+			args: map[string]string{
+				"uroot.uinitbundle":        "https://boot/site.cpio",
+				"uroot.uinitbundlesig":     "https://boot/site.asc",
+				"uroot.uinitbundletimeout": "30s",
+			},
+			want: BundleOpts{URL: "https://boot/site.cpio", SigURL: "https://boot/site.asc", Timeout: 30 * time.Second},
+			ok:   true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := BundleOptsFromCmdline(&cmdline.CmdLine{AsMap: tt.args})
+			if ok != tt.ok || got.URL != tt.want.URL || got.SigURL != tt.want.SigURL || got.Timeout != tt.want.Timeout {
+				t.Errorf("BundleOptsFromCmdline = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// DefaultFailureDir is where HandleFailure writes failure reports, on the
+// tmpfs of /run.
+const DefaultFailureDir = "/run/failure"
+
+// DefaultEmergencyPasswordFile holds the SHA-256 of the password of the
+// emergency shell, in hex, as printed by sha256sum. It is baked into the
+// initramfs when it is built.
+const DefaultEmergencyPasswordFile = "/etc/emergency.sha256"
+
+// FailureAction is what HandleFailure does once the failure is reported.
+type FailureAction string
+
+// Failure actions.
+const (
+	// FailureShell runs an emergency shell, after the password of
+	// EmergencyPasswordFile if it exists, and reboots if it is not given
+	// in time.
+	FailureShell FailureAction = "shell"
+
+	// FailureReboot reboots.
+	FailureReboot FailureAction = "reboot"
+
+	// FailurePowerOff powers off.
+	FailurePowerOff FailureAction = "poweroff"
+)
+
+// FailureOpts configures HandleFailure.
+type FailureOpts struct {
+	// Action is what to do after the failure is reported. It defaults to
+	// FailureShell.
+	Action FailureAction
+
+	// Dir is where the failure report is written. It defaults to
+	// DefaultFailureDir.
+	Dir string
+
+	// URL, if set, is where the failure report is POSTed as text/plain.
+	URL string
+
+	// Timeout is how long the emergency shell waits for its password
+	// before rebooting. It defaults to 5 minutes.
+	Timeout time.Duration
+
+	// PasswordFile holds the SHA-256 of the password of the emergency
+	// shell. It defaults to DefaultEmergencyPasswordFile. Without it, the
+	// emergency shell needs no password.
+	PasswordFile string
+
+	// Shells are tried in order for the emergency shell. They default to
+	// /bin/defaultsh and /bin/sh.
+	Shells []string
+}
+
+// FailureOptsFromCmdline reads FailureOpts from the kernel command line:
+//
+//	uroot.onfailure=ACTION         shell, reboot or poweroff
+//	uroot.failurereport=URL        where to POST the failure report
+//	uroot.failuretimeout=DUR       how long the shell waits for its password
+//
+// It returns false if uroot.onfailure is not present.
+func FailureOptsFromCmdline(c *cmdline.CmdLine) (FailureOpts, bool) {
+	a, ok := c.Flag("uroot.onfailure")
+	if !ok || a == "" {
+		return FailureOpts{}, false
+	}
+	opts := FailureOpts{Action: FailureAction(a)}
+	if u, ok := c.Flag("uroot.failurereport"); ok {
+		opts.URL = u
+	}
+	if s, ok := c.Flag("uroot.failuretimeout"); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			opts.Timeout = d
+		}
+	}
+	return opts, true
+}
+
+func (o *FailureOpts) defaults() {
+	if o.Action == "" {
+		o.Action = FailureShell
+	}
+	if o.Dir == "" {
+		o.Dir = DefaultFailureDir
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 5 * time.Minute
+	}
+	if o.PasswordFile == "" {
+		o.PasswordFile = DefaultEmergencyPasswordFile
+	}
+	if len(o.Shells) == 0 {
+		o.Shells = []string{"/bin/defaultsh", "/bin/sh"}
+	}
+}
+
+// LogBuffer keeps the last bytes written to it, e.g. the log of init for a
+// failure report.
+type LogBuffer struct {
+	mu  sync.Mutex
+	b   []byte
+	max int
+}
+
+// NewLogBuffer returns a LogBuffer that keeps the last max bytes.
+func NewLogBuffer(max int) *LogBuffer {
+	return &LogBuffer{max: max}
+}
+
+// Write implements io.Writer.
+func (l *LogBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.b = append(l.b, p...)
+	if len(l.b) > l.max {
+		l.b = append(l.b[:0], l.b[len(l.b)-l.max:]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns a copy of what is kept.
+func (l *LogBuffer) Bytes() []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]byte(nil), l.b...)
+}
+
+// dmesg returns the kernel log.
+func dmesg() ([]byte, error) {
+	n, err := unix.Klogctl(unix.SYSLOG_ACTION_SIZE_BUFFER, nil)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	n, err = unix.Klogctl(unix.SYSLOG_ACTION_READ_ALL, b)
+	if err != nil {
+		return nil, err
+	}
+	return b[:n], nil
+}
+
+// failureReport returns the report of failure cause, with the log of init
+// and the kernel log.
+func failureReport(now time.Time, cause error, initLog, kernelLog []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Boot failure at %s: %v\n", now.UTC().Format(time.RFC3339), cause)
+	if h, err := os.Hostname(); err == nil {
+		fmt.Fprintf(&b, "Host: %s\n", h)
+	}
+	for _, l := range []struct {
+		name string
+		b    []byte
+	}{
+		{"init log", initLog},
+		{"kernel log", kernelLog},
+	} {
+		fmt.Fprintf(&b, "\n===== %s =====\n", l.name)
+		b.Write(l.b)
+		if len(l.b) > 0 && l.b[len(l.b)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+	}
+	return b.Bytes()
+}
+
+// writeReport writes report into dir, and returns its path.
+func writeReport(dir string, now time.Time, report []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("failure-%s.log", now.UTC().Format("20060102T150405Z")))
+	return path, os.WriteFile(path, report, 0o600)
+}
+
+// postReport POSTs report to u.
+func postReport(u string, report []byte) error {
+	c := &http.Client{Timeout: 30 * time.Second}
+	resp, err := c.Post(u, "text/plain; charset=utf-8", bytes.NewReader(report))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %v: %s", u, resp.Status)
+	}
+	return nil
+}
+
+// errPasswordTimeout is returned by authenticate when the password was not
+// given in time.
+var errPasswordTimeout = errors.New("no password given in time")
+
+// authenticate asks for the password whose SHA-256 is in passwordFile on in,
+// up to 3 times, until timeout. If there is no passwordFile, no password is
+// needed.
+func authenticate(in *os.File, out io.Writer, passwordFile string, timeout time.Duration) error {
+	b, err := os.ReadFile(passwordFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return fmt.Errorf("%s is empty", passwordFile)
+	}
+	want, err := hex.DecodeString(fields[0])
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("%s does not hold a SHA-256 in hex", passwordFile)
+	}
+
+	type line struct {
+		s   string
+		err error
+	}
+	lines := make(chan line, 1)
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			var l line
+			if term.IsTerminal(int(in.Fd())) {
+				var p []byte
+				p, l.err = term.ReadPassword(int(in.Fd()))
+				l.s = string(p)
+				fmt.Fprintln(out)
+			} else {
+				l.s, l.err = r.ReadString('\n')
+				l.s = strings.TrimSuffix(l.s, "\n")
+			}
+			lines <- l
+			if l.err != nil {
+				return
+			}
+		}
+	}()
+	deadline := time.After(timeout)
+	for i := 0; i < 3; i++ {
+		fmt.Fprint(out, "Emergency shell password: ")
+		select {
+		case <-deadline:
+			fmt.Fprintln(out)
+			return errPasswordTimeout
+		case l := <-lines:
+			if l.err != nil {
+				return l.err
+			}
+			got := sha256.Sum256([]byte(l.s))
+			if subtle.ConstantTimeCompare(got[:], want) == 1 {
+				return nil
+			}
+			fmt.Fprintln(out, "Wrong password.")
+		}
+	}
+	return errors.New("wrong password")
+}
+
+// HandleFailure handles a failure of init to boot, e.g. because uinit failed:
+// it writes a report of cause, the init log in initLog, which may be nil,
+// and the kernel log into opts.Dir, POSTs it to opts.URL if set, and then
+// does opts.Action.
+//
+// With FailureShell, it returns once the shell exits. If the password of the
+// shell is not given, it reboots. With FailureReboot and FailurePowerOff, it
+// only returns if it cannot reboot or power off.
+func HandleFailure(opts FailureOpts, cause error, initLog *LogBuffer) error {
+	opts.defaults()
+	var l []byte
+	if initLog != nil {
+		l = initLog.Bytes()
+	}
+	k, err := dmesg()
+	if err != nil {
+		log.Printf("Failure: reading the kernel log: %v", err)
+	}
+	now := time.Now()
+	report := failureReport(now, cause, l, k)
+	if path, err := writeReport(opts.Dir, now, report); err != nil {
+		log.Printf("Failure: writing the report: %v", err)
+	} else {
+		log.Printf("Failure: report in %s", path)
+	}
+	if opts.URL != "" {
+		if err := postReport(opts.URL, report); err != nil {
+			log.Printf("Failure: sending the report: %v", err)
+		} else {
+			log.Printf("Failure: report sent to %s", opts.URL)
+		}
+	}
+
+	switch opts.Action {
+	case FailureShell:
+		if err := authenticate(os.Stdin, os.Stdout, opts.PasswordFile, opts.Timeout); err != nil {
+			log.Printf("Failure: emergency shell: %v, rebooting", err)
+			return reboot(unix.LINUX_REBOOT_CMD_RESTART)
+		}
+		for _, sh := range opts.Shells {
+			cmd := Command(sh)
+			if _, err := os.Stat(cmd.Path); err != nil {
+				continue
+			}
+			return cmd.Run()
+		}
+		return errors.New("no emergency shell found")
+	case FailureReboot:
+		return reboot(unix.LINUX_REBOOT_CMD_RESTART)
+	case FailurePowerOff:
+		return reboot(unix.LINUX_REBOOT_CMD_POWER_OFF)
+	}
+	return fmt.Errorf("unknown failure action %q, want shell, reboot or poweroff", opts.Action)
+}
+
+// reboot syncs file systems, and reboots or powers off as cmd says.
+func reboot(cmd int) error {
+	unix.Sync()
+	return unix.Reboot(cmd)
+}
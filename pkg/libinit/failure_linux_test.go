@@ -0,0 +1,143 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+func TestFailureOptsFromCmdline(t *testing.T) {
+	if _, ok := FailureOptsFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{}}); ok {
+		t.Errorf("FailureOptsFromCmdline without uroot.onfailure = true, want false")
+	}
+	opts, ok := FailureOptsFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{
+		"uroot.onfailure":      "reboot",
+		"uroot.failurereport":  "http://192.0.2.1/report",
+		"uroot.failuretimeout": "30s",
+	}})
+	want := FailureOpts{Action: FailureReboot, URL: "http://192.0.2.1/report", Timeout: 30 * time.Second}
+	if !ok || opts.Action != want.Action || opts.URL != want.URL || opts.Timeout != want.Timeout {
+		t.Errorf("FailureOptsFromCmdline = %+v, %v, want %+v, true", opts, ok, want)
+	}
+}
+
+func TestLogBuffer(t *testing.T) {
+	l := NewLogBuffer(8)
+	l.Write([]byte("hello "))
+	l.Write([]byte("world"))
+	if got, want := string(l.Bytes()), "lo world"; got != want {
+		t.Errorf("Bytes = %q, want %q", got, want)
+	}
+}
+
+func TestFailureReport(t *testing.T) {
+	now := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	report := failureReport(now, errors.New("uinit exited with status 1"), []byte("init: starting"), []byte("kernel: booted\n"))
+	for _, want := range []string{
+		"Boot failure at 2022-03-04T05:06:07Z: uinit exited with status 1\n",
+		"\n===== init log =====\ninit: starting\n",
+		"\n===== kernel log =====\nkernel: booted\n",
+	} {
+		if !bytes.Contains(report, []byte(want)) {
+			t.Errorf("failureReport = %q, want it to contain %q", report, want)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "failure")
+	path, err := writeReport(dir, now, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "failure-20220304T050607Z.log"); path != want {
+		t.Errorf("writeReport = %q, want %q", path, want)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, report) {
+		t.Errorf("report file = %q, want %q", b, report)
+	}
+}
+
+func TestPostReport(t *testing.T) {
+	var got []byte
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/report" {
+			http.NotFound(w, r)
+			return
+		}
+		got, _ = io.ReadAll(r.Body)
+	}))
+	defer s.Close()
+
+	if err := postReport(s.URL+"/report", []byte("report")); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "report" {
+		t.Errorf("posted %q, want %q", got, "report")
+	}
+	if err := postReport(s.URL+"/missing", []byte("report")); err == nil {
+		t.Errorf("postReport to a 404 = nil, want error")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	sum := sha256.Sum256([]byte("secret"))
+	passwordFile := filepath.Join(t.TempDir(), "emergency.sha256")
+	if err := os.WriteFile(passwordFile, []byte(hex.EncodeToString(sum[:])+"  -\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name         string
+		passwordFile string
+		input        string
+		close        bool
+		wantErr      bool
+	}{
+		{name: "no password file", passwordFile: filepath.Join(t.TempDir(), "none")},
+		{name: "right password", passwordFile: passwordFile, input: "secret\n"},
+		{name: "right password at last", passwordFile: passwordFile, input: "a\nb\nsecret\n"},
+		{name: "wrong passwords", passwordFile: passwordFile, input: "a\nb\nc\n", wantErr: true},
+		{name: "end of input", passwordFile: passwordFile, input: "a\n", close: true, wantErr: true},
+		{name: "timeout", passwordFile: passwordFile, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			defer w.Close()
+			w.WriteString(tt.input)
+			if tt.close {
+				w.Close()
+			}
+
+			var out strings.Builder
+			err = authenticate(r, &out, tt.passwordFile, 100*time.Millisecond)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("authenticate = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.name == "timeout" && err != errPasswordTimeout {
+				t.Errorf("authenticate = %v, want %v", err, errPasswordTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/machineid"
+)
+
+// MachineIDOptsFromCmdline builds machineid.Opts from the kernel command line.
+//
+//	uroot.machineid=1           use the default sources, smbios,tpm,mac
+//	uroot.machineid=smbios,mac  sources to try, in order
+//
+// It returns false if uroot.machineid is not present.
+func MachineIDOptsFromCmdline(c *cmdline.CmdLine) (machineid.Opts, bool) {
+	v, ok := c.Flag("uroot.machineid")
+	if !ok {
+		return machineid.Opts{}, false
+	}
+	var opts machineid.Opts
+	for _, s := range strings.Split(v, ",") {
+		if s != "" && s != "1" {
+			opts.Sources = append(opts.Sources, machineid.Source(s))
+		}
+	}
+	return opts, true
+}
+
+// SetupMachineID makes sure that machineid.Path holds the machine ID, deriving
+// it with opts if it does not, and exports it as $MACHINE_ID to uinit and
+// everything it runs, for use in boot configs and log forwarding.
+func SetupMachineID(opts machineid.Opts) (machineid.ID, machineid.Source, error) {
+	id, src, err := machineid.Ensure(machineid.Path, opts)
+	if err != nil {
+		return machineid.ID{}, "", err
+	}
+	return id, src, os.Setenv("MACHINE_ID", id.String())
+}
@@ -0,0 +1,26 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/machineid"
+)
+
+func TestMachineIDOptsFromCmdline(t *testing.T) {
+	if _, ok := MachineIDOptsFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{}}); ok {
+		t.Errorf("MachineIDOptsFromCmdline without uroot.machineid = true, want false")
+	}
+	opts, ok := MachineIDOptsFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{"uroot.machineid": "1"}})
+	if !ok || len(opts.Sources) != 0 {
+		t.Errorf("MachineIDOptsFromCmdline(uroot.machineid=1) = %+v, %v, want default sources", opts, ok)
+	}
+	opts, ok = MachineIDOptsFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{"uroot.machineid": "mac,smbios"}})
+	if !ok || len(opts.Sources) != 2 || opts.Sources[0] != machineid.SourceMAC || opts.Sources[1] != machineid.SourceSMBIOS {
+		t.Errorf("MachineIDOptsFromCmdline(uroot.machineid=mac,smbios) = %+v, %v", opts, ok)
+	}
+}
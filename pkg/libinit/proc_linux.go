@@ -5,6 +5,7 @@
 package libinit
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
@@ -69,7 +70,18 @@ func linuxDefault(c *exec.Cmd) {
 //
 // commands must refer to absolute paths at the moment.
 func RunCommands(debug func(string, ...interface{}), commands ...*exec.Cmd) int {
-	var cmdCount int
+	n, _ := RunCommandsStatus(debug, commands...)
+	return n
+}
+
+// RunCommandsStatus is RunCommands, and also returns the error of the last
+// command that was attempted to run: why it failed to start, or its exit
+// status if it failed, or nil.
+func RunCommandsStatus(debug func(string, ...interface{}), commands ...*exec.Cmd) (int, error) {
+	var (
+		cmdCount int
+		lastErr  error
+	)
 	for _, cmd := range commands {
 		if _, err := os.Stat(cmd.Path); os.IsNotExist(err) {
 			debug("%v", err)
@@ -80,14 +92,21 @@ func RunCommands(debug func(string, ...interface{}), commands ...*exec.Cmd) int
 		debug("Trying to run %v", cmd)
 		if err := cmd.Start(); err != nil {
 			log.Printf("Error starting %v: %v", cmd, err)
+			lastErr = fmt.Errorf("starting %v: %w", cmd, err)
 			continue
 		}
+		lastErr = nil
 
 		for {
 			var s unix.WaitStatus
 			var r unix.Rusage
 			if p, err := unix.Wait4(-1, &s, 0, &r); p == cmd.Process.Pid {
 				debug("Shell exited, exit status %d", s.ExitStatus())
+				if s.Signaled() {
+					lastErr = fmt.Errorf("%v killed by %v", cmd, s.Signal())
+				} else if s.ExitStatus() != 0 {
+					lastErr = fmt.Errorf("%v exited with status %d", cmd, s.ExitStatus())
+				}
 				break
 			} else if p != -1 {
 				debug("Reaped PID %d, exit status %d", p, s.ExitStatus())
@@ -100,5 +119,5 @@ func RunCommands(debug func(string, ...interface{}), commands ...*exec.Cmd) int
 			log.Printf("Error releasing process %v: %v", cmd, err)
 		}
 	}
-	return cmdCount
+	return cmdCount, lastErr
 }
@@ -5,6 +5,7 @@
 package libinit
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
@@ -51,7 +52,18 @@ func plan9Default(c *exec.Cmd) {
 //
 // commands must refer to absolute paths at the moment.
 func RunCommands(debug func(string, ...interface{}), commands ...*exec.Cmd) int {
-	var cmdCount int
+	n, _ := RunCommandsStatus(debug, commands...)
+	return n
+}
+
+// RunCommandsStatus is RunCommands, and also returns the error of the last
+// command that was attempted to run: why it failed to start, or its exit
+// status if it failed, or nil.
+func RunCommandsStatus(debug func(string, ...interface{}), commands ...*exec.Cmd) (int, error) {
+	var (
+		cmdCount int
+		lastErr  error
+	)
 	for _, cmd := range commands {
 		if _, err := os.Stat(cmd.Path); os.IsNotExist(err) {
 			debug("%v", err)
@@ -62,8 +74,10 @@ func RunCommands(debug func(string, ...interface{}), commands ...*exec.Cmd) int
 		debug("Trying to run %v", cmd)
 		if err := cmd.Start(); err != nil {
 			log.Printf("Error starting %v: %v", cmd, err)
+			lastErr = fmt.Errorf("starting %v: %w", cmd, err)
 			continue
 		}
+		lastErr = nil
 
 		for {
 			var w syscall.Waitmsg
@@ -73,6 +87,9 @@ func RunCommands(debug func(string, ...interface{}), commands ...*exec.Cmd) int
 			}
 			if w.Pid == cmd.Process.Pid {
 				debug("Shell exited, exit status %v", w)
+				if w.Msg != "" {
+					lastErr = fmt.Errorf("%v exited with %q", cmd, w.Msg)
+				}
 				break
 			}
 			debug("Reaped PID %d, exit status %v", w.Pid, w)
@@ -81,5 +98,5 @@ func RunCommands(debug func(string, ...interface{}), commands ...*exec.Cmd) int
 			log.Printf("Error releasing process %v: %v", cmd, err)
 		}
 	}
-	return cmdCount
+	return cmdCount, lastErr
 }
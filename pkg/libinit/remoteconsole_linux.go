@@ -0,0 +1,255 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/pty"
+	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
+)
+
+// RemoteConsoleOpts configures StartRemoteConsole.
+type RemoteConsoleOpts struct {
+	// URL is where console output is sent:
+	//
+	//	tcp://host:port     a stream, reconnected when it drops
+	//	udp://host:port     datagrams, e.g. to nc -lu
+	//	http(s)://host/path chunks of output POSTed as text/plain
+	URL string
+
+	// Input makes what the remote end of a tcp console sends typed into
+	// the console, like on the console itself.
+	Input bool
+
+	// Retry is how long to wait before reconnecting, and the dial
+	// timeout. It defaults to 5 seconds.
+	Retry time.Duration
+}
+
+const (
+	// remoteConsoleBacklog bounds the output kept for an endpoint that is
+	// down, e.g. until the network is up. Older output is dropped.
+	remoteConsoleBacklog = 64 << 10
+
+	// udpConsoleChunk is the largest datagram sent to a udp endpoint.
+	udpConsoleChunk = 1024
+)
+
+// RemoteConsoleOptsFromCmdline reads RemoteConsoleOpts from the kernel
+// command line:
+//
+//	uroot.remoteconsole=URL       where to mirror the console
+//	uroot.remoteconsoleinput=1    accept input from a tcp endpoint
+//
+// It returns false if uroot.remoteconsole is not present.
+func RemoteConsoleOptsFromCmdline(c *cmdline.CmdLine) (RemoteConsoleOpts, bool) {
+	u, ok := c.Flag("uroot.remoteconsole")
+	if !ok || u == "" {
+		return RemoteConsoleOpts{}, false
+	}
+	opts := RemoteConsoleOpts{URL: u}
+	if s, ok := c.Flag("uroot.remoteconsoleinput"); ok {
+		opts.Input, _ = strconv.ParseBool(s)
+	}
+	return opts, true
+}
+
+// remoteConsole sends console output to an endpoint. Write never blocks on
+// the network, so that a broken endpoint never stalls the console.
+type remoteConsole struct {
+	u     *url.URL
+	retry time.Duration
+	// input is where input from the endpoint goes, nil to ignore it.
+	input io.Writer
+	out   chan []byte
+
+	// conn is the tcp or udp connection, nil while disconnected.
+	conn     net.Conn
+	lastDial time.Time
+	client   *http.Client
+}
+
+func newRemoteConsole(opts RemoteConsoleOpts, input io.Writer) (*remoteConsole, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "tcp", "udp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("remote console %q: no host", opts.URL)
+		}
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("remote console %q: unsupported scheme, want tcp, udp, http or https", opts.URL)
+	}
+	if opts.Input && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("remote console %q: input is only accepted over tcp", opts.URL)
+	}
+	if opts.Retry == 0 {
+		opts.Retry = 5 * time.Second
+	}
+	r := &remoteConsole{
+		u:      u,
+		retry:  opts.Retry,
+		out:    make(chan []byte, 256),
+		client: &http.Client{Timeout: opts.Retry},
+	}
+	if opts.Input {
+		r.input = input
+	}
+	go r.loop()
+	return r, nil
+}
+
+// Write implements io.Writer. It drops b if the endpoint does not keep up.
+func (r *remoteConsole) Write(b []byte) (int, error) {
+	select {
+	case r.out <- append([]byte(nil), b...):
+	default:
+	}
+	return len(b), nil
+}
+
+func (r *remoteConsole) loop() {
+	retry := time.NewTicker(r.retry)
+	defer retry.Stop()
+	var pending []byte
+	for {
+		select {
+		case b := <-r.out:
+			pending = append(pending, b...)
+		case <-retry.C:
+			if len(pending) == 0 {
+				continue
+			}
+		}
+		// Send what piled up meanwhile along.
+		for more := true; more; {
+			select {
+			case b := <-r.out:
+				pending = append(pending, b...)
+			default:
+				more = false
+			}
+		}
+		if len(pending) > remoteConsoleBacklog {
+			pending = pending[len(pending)-remoteConsoleBacklog:]
+		}
+		if err := r.send(pending); err == nil {
+			pending = nil
+		}
+	}
+}
+
+func (r *remoteConsole) send(b []byte) error {
+	if r.u.Scheme == "http" || r.u.Scheme == "https" {
+		resp, err := r.client.Post(r.u.String(), "text/plain; charset=utf-8", bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("POST %v: %s", r.u, resp.Status)
+		}
+		return nil
+	}
+
+	if r.conn == nil {
+		if time.Since(r.lastDial) < r.retry {
+			return fmt.Errorf("%v is down", r.u)
+		}
+		r.lastDial = time.Now()
+		c, err := net.DialTimeout(r.u.Scheme, r.u.Host, r.retry)
+		if err != nil {
+			return err
+		}
+		r.conn = c
+		if r.input != nil {
+			go io.Copy(r.input, c)
+		}
+	}
+	chunk := len(b)
+	if r.u.Scheme == "udp" {
+		chunk = udpConsoleChunk
+	}
+	for len(b) > 0 {
+		n := chunk
+		if n > len(b) {
+			n = len(b)
+		}
+		if _, err := r.conn.Write(b[:n]); err != nil {
+			// A udp endpoint that is not listening yet refuses
+			// datagrams, there is nothing to reconnect.
+			if r.u.Scheme == "tcp" {
+				r.conn.Close()
+				r.conn = nil
+			}
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// StartRemoteConsole mirrors the console to opts.URL.
+//
+// It puts a pseudo terminal between init and the console: it becomes the
+// stdin, stdout and stderr of init, and so of everything init starts after
+// it, and its output is relayed to both the console and the endpoint.
+// Kernel messages are not mirrored, use netconsole= for them.
+func StartRemoteConsole(opts RemoteConsoleOpts) error {
+	ptm, pts, err := pty.Open()
+	if err != nil {
+		return err
+	}
+	r, err := newRemoteConsole(opts, ptm)
+	if err != nil {
+		return err
+	}
+
+	// Keep the console: the line discipline of the pty does the echoing
+	// and line editing now, so the console has to be raw.
+	in, err := unix.Dup(0)
+	if err != nil {
+		return err
+	}
+	out, err := unix.Dup(1)
+	if err != nil {
+		return err
+	}
+	if t, err := termios.GetTermios(uintptr(in)); err == nil {
+		if err := termios.SetTermios(uintptr(in), termios.MakeRaw(t)); err != nil {
+			return fmt.Errorf("making the console raw: %w", err)
+		}
+	}
+	// Serial consoles have no size.
+	if w, err := termios.GetWinSize(uintptr(out)); err == nil && w.Row != 0 {
+		termios.SetWinSize(pts.Fd(), w)
+	}
+
+	for fd := 0; fd <= 2; fd++ {
+		if err := unix.Dup3(int(pts.Fd()), fd, 0); err != nil {
+			return err
+		}
+	}
+	pts.Close()
+
+	console := os.NewFile(uintptr(out), "console")
+	go io.Copy(io.MultiWriter(console, r), ptm)
+	go io.Copy(ptm, os.NewFile(uintptr(in), "console"))
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+func TestRemoteConsoleOptsFromCmdline(t *testing.T) {
+	if _, ok := RemoteConsoleOptsFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{}}); ok {
+		t.Errorf("RemoteConsoleOptsFromCmdline without uroot.remoteconsole = true, want false")
+	}
+	opts, ok := RemoteConsoleOptsFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{
+		"uroot.remoteconsole":      "tcp://192.0.2.1:4000",
+		"uroot.remoteconsoleinput": "1",
+	}})
+	if want := (RemoteConsoleOpts{URL: "tcp://192.0.2.1:4000", Input: true}); !ok || opts != want {
+		t.Errorf("RemoteConsoleOptsFromCmdline = %+v, %v, want %+v, true", opts, ok, want)
+	}
+}
+
+func TestRemoteConsoleBadURL(t *testing.T) {
+	for _, opts := range []RemoteConsoleOpts{
+		{URL: "ssh://192.0.2.1"},
+		{URL: "tcp:///path"},
+		{URL: "udp://192.0.2.1:514", Input: true},
+	} {
+		if _, err := newRemoteConsole(opts, nil); err == nil {
+			t.Errorf("newRemoteConsole(%+v) = nil, want error", opts)
+		}
+	}
+}
+
+func TestRemoteConsoleTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	input, w := io.Pipe()
+	r, err := newRemoteConsole(RemoteConsoleOpts{URL: "tcp://" + l.Addr().String(), Input: true, Retry: 10 * time.Millisecond}, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("login: "))
+	r.Write([]byte("\n"))
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	if got, err := bufio.NewReader(c).ReadString('\n'); err != nil || got != "login: \n" {
+		t.Errorf("endpoint got %q, %v, want %q", got, err, "login: \n")
+	}
+
+	c.Write([]byte("root\n"))
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(input, got); err != nil || string(got) != "root\n" {
+		t.Errorf("console got %q, %v, want %q", got, err, "root\n")
+	}
+}
+
+func TestRemoteConsoleUDP(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	r, err := newRemoteConsole(RemoteConsoleOpts{URL: "udp://" + c.LocalAddr().String()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write(bytes.Repeat([]byte("x"), udpConsoleChunk+1))
+
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	var sizes []int
+	for len(sizes) < 2 {
+		b := make([]byte, 2*udpConsoleChunk)
+		n, _, err := c.ReadFrom(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, n)
+	}
+	if sizes[0] != udpConsoleChunk || sizes[1] != 1 {
+		t.Errorf("endpoint got datagrams of %v bytes, want [%d 1]", sizes, udpConsoleChunk)
+	}
+}
+
+func TestRemoteConsoleHTTP(t *testing.T) {
+	posts := make(chan string, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		posts <- string(b)
+	}))
+	defer s.Close()
+
+	r, err := newRemoteConsole(RemoteConsoleOpts{URL: s.URL + "/console"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("init: Welcome to u-root!\n"))
+	select {
+	case got := <-posts:
+		if got != "init: Welcome to u-root!\n" {
+			t.Errorf("endpoint got %q", got)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("endpoint got nothing")
+	}
+}
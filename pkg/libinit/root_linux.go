@@ -272,12 +272,25 @@ func NewInitModuleLoader() *InitModuleLoader {
 // Useful for modules that need to be loaded for boot (ie a network
 // driver needed for netboot). It skips over blacklisted modules in
 // excludedMods.
+//
+// If uroot.modimage= names a squashfs or erofs module image, the modules
+// listed in .conf files and on the cmdline are resolved and loaded from
+// inside that image instead of the root file system.
 func InstallAllModules() error {
 	loader := NewInitModuleLoader()
 	modulePattern := "/lib/modules/*.ko"
 	if err := InstallModulesFromDir(modulePattern, loader); !errors.Is(err, ErrNoModulesFound) {
 		return err
 	}
+	if image, ok := loader.Cmdline.Flag("uroot.modimage"); ok {
+		img, err := kmodule.OpenModuleImage(image, "")
+		if err != nil {
+			log.Printf("InstallAllModules: can't open module image: %v", err)
+		} else {
+			defer img.Close()
+			loader.Prober = img.Probe
+		}
+	}
 	var allModules []string
 	moduleConfPattern := "/lib/modules-load.d/*.conf"
 	modules, err := GetModulesFromConf(moduleConfPattern)
@@ -0,0 +1,186 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/vfile"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultShareDir is where shares without a directory are mounted, each in
+// the directory named after its tag.
+const DefaultShareDir = "/mnt"
+
+// DefaultShareManifest is the manifest that shares are verified against,
+// relative to their root.
+const DefaultShareManifest = "SHA256SUMS"
+
+// AllShares in ShareMount.Tag stands for every share of the machine.
+const AllShares = "*"
+
+// ShareMount is a virtio-9p or virtio-fs share to mount.
+type ShareMount struct {
+	// Tag is the tag of the share, or AllShares.
+	Tag string
+
+	// Dir is where the share is mounted. It defaults to
+	// DefaultShareDir/Tag.
+	Dir string
+}
+
+// ShareOpts configures MountShares and MountShare.
+type ShareOpts struct {
+	// Shares are the shares to mount.
+	Shares []ShareMount
+
+	// Msize is the largest 9p message size. It defaults to
+	// mount.Default9PMsize.
+	Msize uint32
+
+	// ReadOnly mounts the shares read-only.
+	ReadOnly bool
+
+	// KeyRing, if set, is the path of the keyring that the manifest of
+	// each share must be signed with. Every file listed in the manifest
+	// is verified when the share is mounted, and a share that does not
+	// verify is unmounted again. Files are not verified when they are
+	// read later, and the host may change them in the meantime; use
+	// vfile.OpenFileFromSignedManifest for that.
+	KeyRing string
+
+	// Manifest is the path of the manifest in each share, relative to its
+	// root. It defaults to DefaultShareManifest.
+	Manifest string
+}
+
+// ShareOptsFromCmdline reads ShareOpts from the kernel command line:
+//
+//	uroot.share=TAG[:DIR],...   shares to mount at DIR, by default /mnt/TAG;
+//	                            TAG * mounts all of them
+//	uroot.sharemsize=BYTES      the largest 9p message size
+//	uroot.sharero=1             mount the shares read-only
+//	uroot.sharekeyring=PATH     verify the shares against their signed
+//	                            manifest, with keys from PATH in the initramfs
+//	uroot.sharemanifest=NAME    the manifest, by default SHA256SUMS
+//
+// It returns false if uroot.share is not present.
+func ShareOptsFromCmdline(c *cmdline.CmdLine) (ShareOpts, bool) {
+	v, ok := c.Flag("uroot.share")
+	if !ok || v == "" {
+		return ShareOpts{}, false
+	}
+	var opts ShareOpts
+	for _, s := range strings.Split(v, ",") {
+		if s == "" {
+			continue
+		}
+		tag, dir, _ := strings.Cut(s, ":")
+		opts.Shares = append(opts.Shares, ShareMount{Tag: tag, Dir: dir})
+	}
+	if s, ok := c.Flag("uroot.sharemsize"); ok {
+		if n, err := strconv.ParseUint(s, 0, 32); err == nil {
+			opts.Msize = uint32(n)
+		}
+	}
+	if s, ok := c.Flag("uroot.sharero"); ok {
+		opts.ReadOnly, _ = strconv.ParseBool(s)
+	}
+	opts.KeyRing, _ = c.Flag("uroot.sharekeyring")
+	opts.Manifest, _ = c.Flag("uroot.sharemanifest")
+	return opts, len(opts.Shares) > 0
+}
+
+// Mounts returns the shares of opts, with AllShares replaced by every share
+// of the machine.
+func (opts ShareOpts) Mounts() ([]ShareMount, error) {
+	var mounts []ShareMount
+	for _, m := range opts.Shares {
+		if m.Tag != AllShares {
+			mounts = append(mounts, m)
+			continue
+		}
+		shares, err := mount.Shares()
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range shares {
+			mounts = append(mounts, ShareMount{Tag: s.Tag})
+		}
+	}
+	return mounts, nil
+}
+
+// MountShares mounts the shares of opts, and logs those that cannot be
+// mounted or verified. It returns the mount points of the others.
+func MountShares(opts ShareOpts) []*mount.MountPoint {
+	mounts, err := opts.Mounts()
+	if err != nil {
+		log.Printf("Shares: %v", err)
+		return nil
+	}
+	var mps []*mount.MountPoint
+	for _, m := range mounts {
+		mp, err := MountShare(m, opts)
+		if err != nil {
+			log.Printf("Shares: %v", err)
+			continue
+		}
+		log.Printf("Shares: mounted %s at %s", m.Tag, mp.Path)
+		mps = append(mps, mp)
+	}
+	return mps
+}
+
+// MountShare mounts the share m with opts, and verifies it if opts has a
+// KeyRing.
+func MountShare(m ShareMount, opts ShareOpts) (*mount.MountPoint, error) {
+	s, err := mount.FindShare(m.Tag)
+	if err != nil {
+		return nil, err
+	}
+	dir := m.Dir
+	if dir == "" {
+		dir = filepath.Join(DefaultShareDir, m.Tag)
+	}
+	var flags uintptr
+	if opts.ReadOnly {
+		flags |= unix.MS_RDONLY
+	}
+	mp, err := mount.MountShare(s, dir, opts.Msize, flags)
+	if err != nil {
+		return nil, err
+	}
+	if opts.KeyRing == "" {
+		return mp, nil
+	}
+	if err := verifyShare(dir, opts); err != nil {
+		mp.Unmount(0)
+		return nil, fmt.Errorf("share %s does not verify, unmounted it: %w", m.Tag, err)
+	}
+	return mp, nil
+}
+
+// verifyShare verifies the files of the share mounted at dir against its
+// manifest.
+func verifyShare(dir string, opts ShareOpts) error {
+	ring, err := vfile.GetKeyRing(opts.KeyRing)
+	if err != nil {
+		return err
+	}
+	manifest := opts.Manifest
+	if manifest == "" {
+		manifest = DefaultShareManifest
+	}
+	_, err = vfile.VerifySignedManifest(ring, filepath.Join(dir, manifest))
+	return err
+}
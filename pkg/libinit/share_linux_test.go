@@ -0,0 +1,119 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestShareOptsFromCmdline(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args map[string]string
+		want ShareOpts
+		ok   bool
+	}{
+		{name: "absent", args: map[string]string{}},
+		{name: "empty", args: map[string]string{"uroot.share": ","}},
+		{
+			name: "tags",
+			args: map[string]string{"uroot.share": "tmpdir,kernel:/boot"},
+			want: ShareOpts{Shares: []ShareMount{{Tag: "tmpdir"}, {Tag: "kernel", Dir: "/boot"}}},
+			ok:   true,
+		},
+		{
+			name: "all",
+			args: map[string]string{
+				"uroot.share":         "*",
+				"uroot.sharemsize":    "0x100000",
+				"uroot.sharero":       "1",
+				"uroot.sharekeyring":  "/etc/share.keyring",
+				"uroot.sharemanifest": "SHA512SUMS",
+			},
+			want: ShareOpts{
+				Shares:   []ShareMount{{Tag: AllShares}},
+				Msize:    1 << 20,
+				ReadOnly: true,
+				KeyRing:  "/etc/share.keyring",
+				Manifest: "SHA512SUMS",
+			},
+			ok: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ShareOptsFromCmdline(&cmdline.CmdLine{AsMap: tt.args})
+			if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("ShareOptsFromCmdline = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestVerifyShare(t *testing.T) {
+	key, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	ring := filepath.Join(t.TempDir(), "share.keyring")
+	var b bytes.Buffer
+	if err := key.Serialize(&b); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ring, b.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test.sh"), []byte("echo hi"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	manifest := []byte(fmt.Sprintf("%x  test.sh\n", sha256.Sum256([]byte("echo hi"))))
+	if err := os.WriteFile(filepath.Join(dir, DefaultShareManifest), manifest, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sign := func(name string, content []byte) {
+		var sig bytes.Buffer
+		if err := openpgp.DetachSign(&sig, key, bytes.NewReader(content), nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".sig"), sig.Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := ShareOpts{KeyRing: ring}
+	if err := verifyShare(dir, opts); err == nil {
+		t.Errorf("verifyShare without a signature = nil, want an error")
+	}
+	sign(DefaultShareManifest, manifest)
+	if err := verifyShare(dir, opts); err != nil {
+		t.Errorf("verifyShare = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test.sh"), []byte("rm -rf /"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyShare(dir, opts); err == nil {
+		t.Errorf("verifyShare of a changed file = nil, want an error")
+	}
+
+	// Another manifest in the share.
+	other := []byte(fmt.Sprintf("%x  test.sh\n", sha256.Sum256([]byte("rm -rf /"))))
+	if err := os.WriteFile(filepath.Join(dir, "SUMS"), other, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sign("SUMS", other)
+	opts.Manifest = "SUMS"
+	if err := verifyShare(dir, opts); err != nil {
+		t.Errorf("verifyShare(SUMS) = %v", err)
+	}
+}
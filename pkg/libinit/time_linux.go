@@ -0,0 +1,222 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/ntpdate"
+	"github.com/u-root/u-root/pkg/rtc"
+)
+
+// DefaultTimeFloorFile holds the build time of the initramfs as decimal Unix
+// seconds, as written by the u-root builder. No certificate used at boot can
+// be valid before this time.
+const DefaultTimeFloorFile = "/etc/timestamp"
+
+// TimeSource is the source SyncTime ended up using.
+type TimeSource string
+
+// Time sources, in the order SyncTime tries them.
+const (
+	TimeSourceSystem TimeSource = "system"
+	TimeSourceRTC    TimeSource = "rtc"
+	TimeSourceNTP    TimeSource = "ntp"
+	TimeSourceFloor  TimeSource = "floor"
+)
+
+// TimeSyncOpts configures SyncTime.
+type TimeSyncOpts struct {
+	// Floor is the earliest plausible time. Anything before it is
+	// considered an unset clock. If it is zero, nothing can be said about
+	// the current time, so the sources are always consulted.
+	Floor time.Time
+
+	// Sources is the ordered list of sources to try. The default is
+	// rtc, floor. NTP needs a network, which init does not bring up, so it
+	// must be asked for explicitly.
+	Sources []TimeSource
+
+	// NTPServers are queried in addition to those in ntpdate.DefaultNTPConfig.
+	NTPServers []string
+
+	// NTPTimeout bounds how long SyncTime keeps retrying NTP, e.g. while
+	// the network is still coming up. Zero means a single attempt.
+	NTPTimeout time.Duration
+
+	// SetRTC writes a time obtained via NTP back to the RTC.
+	SetRTC bool
+
+	// The following are overridden in tests.
+	now       func() time.Time
+	setSystem func(time.Time) error
+	readRTC   func() (time.Time, error)
+	ntp       func(servers []string, setRTC bool) error
+}
+
+// ErrImplausibleTime is returned by SyncTime when no source yields a time
+// after the floor.
+var ErrImplausibleTime = errors.New("could not establish a plausible time")
+
+func (o *TimeSyncOpts) defaults() {
+	if len(o.Sources) == 0 {
+		o.Sources = []TimeSource{TimeSourceRTC, TimeSourceFloor}
+	}
+	if o.now == nil {
+		o.now = time.Now
+	}
+	if o.setSystem == nil {
+		o.setSystem = func(t time.Time) error {
+			tv := syscall.NsecToTimeval(t.UnixNano())
+			return syscall.Settimeofday(&tv)
+		}
+	}
+	if o.readRTC == nil {
+		o.readRTC = func() (time.Time, error) {
+			r, err := rtc.OpenRTC()
+			if err != nil {
+				return time.Time{}, err
+			}
+			defer r.Close()
+			return r.Read()
+		}
+	}
+	if o.ntp == nil {
+		o.ntp = func(servers []string, setRTC bool) error {
+			_, _, err := ntpdate.SetTime(servers, ntpdate.DefaultNTPConfig, "", setRTC)
+			return err
+		}
+	}
+}
+
+// SyncTime makes sure the system clock is no earlier than opts.Floor.
+//
+// If there is a floor and the clock is already past it, nothing is changed
+// and TimeSourceSystem is returned. Otherwise the sources are tried in order:
+// the RTC, NTP if requested, and as a last resort the floor itself, which at
+// least gets certificates issued before the image was built to validate.
+// TLS-dependent steps should run only after SyncTime.
+func SyncTime(opts TimeSyncOpts) (TimeSource, error) {
+	opts.defaults()
+	plausible := func(t time.Time) bool { return !t.Before(opts.Floor) }
+
+	if !opts.Floor.IsZero() && plausible(opts.now()) {
+		return TimeSourceSystem, nil
+	}
+
+	var errs []string
+	for _, src := range opts.Sources {
+		switch src {
+		case TimeSourceRTC:
+			t, err := opts.readRTC()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("rtc: %v", err))
+				continue
+			}
+			if !plausible(t) {
+				errs = append(errs, fmt.Sprintf("rtc: %v is before %v", t, opts.Floor))
+				continue
+			}
+			if err := opts.setSystem(t); err != nil {
+				return "", err
+			}
+			return src, nil
+
+		case TimeSourceNTP:
+			deadline := opts.now().Add(opts.NTPTimeout)
+			for {
+				err := opts.ntp(opts.NTPServers, opts.SetRTC)
+				if err == nil && plausible(opts.now()) {
+					return src, nil
+				}
+				if err == nil {
+					err = fmt.Errorf("server time is before %v", opts.Floor)
+				}
+				if !opts.now().Before(deadline) {
+					errs = append(errs, fmt.Sprintf("ntp: %v", err))
+					break
+				}
+				time.Sleep(time.Second)
+			}
+
+		case TimeSourceFloor:
+			if opts.Floor.IsZero() {
+				continue
+			}
+			if err := opts.setSystem(opts.Floor); err != nil {
+				return "", err
+			}
+			return src, nil
+
+		default:
+			errs = append(errs, fmt.Sprintf("unknown time source %q", src))
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrImplausibleTime, strings.Join(errs, "; "))
+}
+
+// TimeFloor returns the build time recorded in path as Unix seconds, or the
+// zero time if there is none.
+func TimeFloor(path string) time.Time {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// TimeSyncOptsFromCmdline builds TimeSyncOpts from the kernel command line.
+//
+//	uroot.timesync=1               use the default sources, rtc,floor
+//	uroot.timesync=rtc,ntp,floor   sources to try, in order
+//	uroot.timefloor=<unix seconds> overrides DefaultTimeFloorFile
+//	uroot.ntpservers=a,b           additional NTP servers
+//	uroot.ntptimeout=30s           how long to retry NTP (default: one try)
+//
+// init calls SyncTime before any network is configured, so ntp only helps
+// if the kernel already brought one up (ip=). Otherwise, run ntpdate from
+// uinit once the network is up.
+//
+// It returns false if uroot.timesync is not present.
+func TimeSyncOptsFromCmdline(c *cmdline.CmdLine) (TimeSyncOpts, bool) {
+	v, ok := c.Flag("uroot.timesync")
+	if !ok {
+		return TimeSyncOpts{}, false
+	}
+	opts := TimeSyncOpts{
+		Floor:  TimeFloor(DefaultTimeFloorFile),
+		SetRTC: true,
+	}
+	for _, s := range strings.Split(v, ",") {
+		if s != "" && s != "1" {
+			opts.Sources = append(opts.Sources, TimeSource(s))
+		}
+	}
+	if f, ok := c.Flag("uroot.timefloor"); ok {
+		if sec, err := strconv.ParseInt(f, 10, 64); err == nil {
+			opts.Floor = time.Unix(sec, 0)
+		}
+	}
+	if s, ok := c.Flag("uroot.ntpservers"); ok {
+		opts.NTPServers = strings.Split(s, ",")
+	}
+	if s, ok := c.Flag("uroot.ntptimeout"); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			opts.NTPTimeout = d
+		}
+	}
+	return opts, true
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libinit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/cmdline"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) set(t time.Time) error {
+	c.t = t
+	return nil
+}
+
+func TestSyncTime(t *testing.T) {
+	floor := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	epoch := time.Unix(0, 0)
+	good := floor.Add(48 * time.Hour)
+
+	for _, tt := range []struct {
+		name    string
+		clock   time.Time
+		rtc     time.Time
+		rtcErr  error
+		ntpTime time.Time
+		ntpErr  error
+		sources []TimeSource
+		want    TimeSource
+		wantT   time.Time
+		err     error
+	}{
+		{name: "already plausible", clock: good, want: TimeSourceSystem, wantT: good},
+		{name: "rtc", clock: epoch, rtc: good, want: TimeSourceRTC, wantT: good},
+		{name: "rtc stale, ntp", clock: epoch, rtc: epoch, ntpTime: good, sources: []TimeSource{TimeSourceRTC, TimeSourceNTP}, want: TimeSourceNTP, wantT: good},
+		{name: "rtc broken, ntp down, floor", clock: epoch, rtcErr: errors.New("no rtc"), ntpErr: errors.New("no net"), sources: []TimeSource{TimeSourceRTC, TimeSourceNTP, TimeSourceFloor}, want: TimeSourceFloor, wantT: floor},
+		{name: "default skips ntp", clock: epoch, rtcErr: errors.New("no rtc"), ntpTime: good, want: TimeSourceFloor, wantT: floor},
+		{name: "no fallback", clock: epoch, rtc: epoch, ntpErr: errors.New("no net"), sources: []TimeSource{TimeSourceRTC, TimeSourceNTP}, err: ErrImplausibleTime},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeClock{t: tt.clock}
+			opts := TimeSyncOpts{
+				Floor:     floor,
+				Sources:   tt.sources,
+				now:       c.now,
+				setSystem: c.set,
+				readRTC:   func() (time.Time, error) { return tt.rtc, tt.rtcErr },
+				ntp: func([]string, bool) error {
+					if tt.ntpErr != nil {
+						return tt.ntpErr
+					}
+					c.t = tt.ntpTime
+					return nil
+				},
+			}
+			got, err := SyncTime(opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("SyncTime = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("SyncTime source = %q, want %q", got, tt.want)
+			}
+			if tt.err == nil && !c.t.Equal(tt.wantT) {
+				t.Errorf("clock = %v, want %v", c.t, tt.wantT)
+			}
+		})
+	}
+}
+
+func TestSyncTimeNoFloor(t *testing.T) {
+	// Without a floor, any clock looks plausible, so the RTC must still
+	// be read.
+	rtc := time.Date(2022, 6, 3, 0, 0, 0, 0, time.UTC)
+	c := &fakeClock{t: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}
+	ntpCalled := false
+	opts := TimeSyncOpts{
+		now:       c.now,
+		setSystem: c.set,
+		readRTC:   func() (time.Time, error) { return rtc, nil },
+		ntp:       func([]string, bool) error { ntpCalled = true; return nil },
+	}
+	got, err := SyncTime(opts)
+	if err != nil || got != TimeSourceRTC || !c.t.Equal(rtc) {
+		t.Errorf("SyncTime = %q, %v, clock %v, want %q, nil, clock %v", got, err, c.t, TimeSourceRTC, rtc)
+	}
+
+	opts.readRTC = func() (time.Time, error) { return time.Time{}, errors.New("no rtc") }
+	if _, err := SyncTime(opts); !errors.Is(err, ErrImplausibleTime) {
+		t.Errorf("SyncTime without RTC or floor = %v, want %v", err, ErrImplausibleTime)
+	}
+	if ntpCalled {
+		t.Errorf("SyncTime used NTP without being asked to")
+	}
+}
+
+func TestTimeSyncOptsFromCmdline(t *testing.T) {
+	if _, ok := TimeSyncOptsFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{}}); ok {
+		t.Errorf("TimeSyncOptsFromCmdline without uroot.timesync = true, want false")
+	}
+	opts, ok := TimeSyncOptsFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{"uroot.timesync": "1"}})
+	if !ok || len(opts.Sources) != 0 || opts.NTPTimeout != 0 {
+		t.Errorf("TimeSyncOptsFromCmdline(uroot.timesync=1) = %+v, %v, want default sources and no NTP retries", opts, ok)
+	}
+	opts, ok = TimeSyncOptsFromCmdline(&cmdline.CmdLine{AsMap: map[string]string{
+		"uroot.timesync":   "ntp,floor",
+		"uroot.timefloor":  "1654041600",
+		"uroot.ntpservers": "10.0.0.1,10.0.0.2",
+		"uroot.ntptimeout": "5s",
+	}})
+	if !ok {
+		t.Fatal("TimeSyncOptsFromCmdline = false, want true")
+	}
+	if len(opts.Sources) != 2 || opts.Sources[0] != TimeSourceNTP || opts.Floor.Unix() != 1654041600 ||
+		len(opts.NTPServers) != 2 || opts.NTPTimeout != 5*time.Second {
+		t.Errorf("TimeSyncOptsFromCmdline = %+v", opts)
+	}
+}
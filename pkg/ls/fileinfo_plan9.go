@@ -79,7 +79,9 @@ func (qs QuotedStringer) FileString(fi FileInfo) string {
 // long format.
 type LongStringer struct {
 	Human bool
-	Name  Stringer
+	// Numeric is ignored, as Plan 9 owners are names.
+	Numeric bool
+	Name    Stringer
 }
 
 // FileString implements Stringer.FileString.
@@ -10,7 +10,6 @@ package ls
 import (
 	"fmt"
 	"os"
-	"os/user"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,6 +17,7 @@ import (
 	"time"
 
 	humanize "github.com/dustin/go-humanize"
+	"github.com/u-root/u-root/pkg/nss"
 	"golang.org/x/sys/unix"
 )
 
@@ -81,10 +81,7 @@ func lookupUserName(id uint32) string {
 	if s, ok := uidCache[id]; ok {
 		return s
 	}
-	s := fmt.Sprint(id)
-	if u, err := user.LookupId(s); err == nil {
-		s = u.Username
-	}
+	s := nss.UserName(id)
 	uidCache[id] = s
 	return s
 }
@@ -94,10 +91,7 @@ func lookupGroupName(id uint32) string {
 	if s, ok := gidCache[id]; ok {
 		return s
 	}
-	s := fmt.Sprint(id)
-	if g, err := user.LookupGroupId(s); err == nil {
-		s = g.Name
-	}
+	s := nss.GroupName(id)
 	gidCache[id] = s
 	return s
 }
@@ -129,7 +123,10 @@ func (qs QuotedStringer) FileString(fi FileInfo) string {
 // long format.
 type LongStringer struct {
 	Human bool
-	Name  Stringer
+	// Numeric prints the uid and gid instead of the names of the owner
+	// and group.
+	Numeric bool
+	Name    Stringer
 }
 
 // FileString implements Stringer.FileString.
@@ -152,10 +149,15 @@ func (ls LongStringer) FileString(fi FileInfo) string {
 		size = strconv.FormatInt(fi.Size, 10)
 	}
 
+	owner, group := lookupUserName(fi.UID), lookupGroupName(fi.GID)
+	if ls.Numeric {
+		owner, group = strconv.FormatUint(uint64(fi.UID), 10), strconv.FormatUint(uint64(fi.GID), 10)
+	}
+
 	s := fmt.Sprintf(pattern,
 		replacer.Replace(fi.Mode.String()),
-		lookupUserName(fi.UID),
-		lookupGroupName(fi.GID),
+		owner,
+		group,
 		unix.Major(fi.Rdev),
 		unix.Minor(fi.Rdev),
 		size,
@@ -8,11 +8,14 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/u-root/u-root/pkg/nss"
 )
 
 const lsregex string = "^([rwxSTstdcb\\-lp?]{10})\\s+(\\d+)?\\s?(\\S+)\\s+(\\S+)\\s+([0-9,]+)?\\s+(\\d+)?(\\D+)?(\\d{1,2}\\D\\d{1,2}\\D\\d{1,2})?(\\D{4})?([\\D|\\d]*)"
@@ -128,3 +131,32 @@ func TestFileInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestLongStringerOwner(t *testing.T) {
+	dir := t.TempDir()
+	uid, gid := os.Getuid(), os.Getgid()
+	nss.PasswdPath = filepath.Join(dir, "passwd")
+	nss.GroupPath = filepath.Join(dir, "group")
+	if err := os.WriteFile(nss.PasswdPath, []byte(fmt.Sprintf("tester:x:%d:%d::/:/bin/sh\n", uid, gid)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(nss.GroupPath, []byte(fmt.Sprintf("testers:x:%d:\n", gid)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uidCache, gidCache = map[uint32]string{}, map[uint32]string{}
+	defer func() { uidCache, gidCache = map[uint32]string{}, map[uint32]string{} }()
+
+	fi := FileInfo{Name: "f", Mode: 0o644, UID: uint32(uid), GID: uint32(gid)}
+	for _, tt := range []struct {
+		numeric bool
+		want    string
+	}{
+		{want: "-rw-r--r--\ttester\ttesters\t0\t"},
+		{numeric: true, want: fmt.Sprintf("-rw-r--r--\t%d\t%d\t0\t", uid, gid)},
+	} {
+		ls := LongStringer{Numeric: tt.numeric, Name: NameStringer{}}
+		if got := ls.FileString(fi); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("FileString with Numeric %t = %q, want it to start with %q", tt.numeric, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package machineid derives a stable identity for a machine and keeps it in
+// /etc/machine-id, in the format of machine-id(5).
+package machineid
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Path is where the machine ID is kept.
+const Path = "/etc/machine-id"
+
+// ID is a machine ID.
+type ID [16]byte
+
+// String returns id as 32 lowercase hexadecimal digits.
+func (id ID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// ErrInvalid is returned for machine IDs that are malformed or all zeroes.
+var ErrInvalid = errors.New("invalid machine ID")
+
+// Parse parses a machine ID of 32 hexadecimal digits.
+func Parse(s string) (ID, error) {
+	var id ID
+	if len(s) != 2*len(id) {
+		return ID{}, fmt.Errorf("%w %q", ErrInvalid, s)
+	}
+	if _, err := hex.Decode(id[:], []byte(s)); err != nil || id == (ID{}) {
+		return ID{}, fmt.Errorf("%w %q", ErrInvalid, s)
+	}
+	return id, nil
+}
+
+// Read reads the machine ID from the file at path.
+func Read(path string) (ID, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ID{}, err
+	}
+	return Parse(strings.TrimSpace(string(b)))
+}
+
+// Write writes id to the file at path, creating its directory if needed.
+//
+// The file is replaced rather than rewritten, since it is read-only.
+func Write(path string, id ID) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := os.WriteFile(tmp, []byte(id.String()+"\n"), 0o444); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// hashID derives an ID from a hardware identifier b that is not itself a
+// UUID, such as a key or a MAC address. The ID is formatted as a version 4
+// UUID, as machine-id(5) recommends, and does not reveal b.
+func hashID(kind string, b []byte) ID {
+	h := sha256.New()
+	h.Write([]byte("u-root machine-id " + kind + "\x00"))
+	h.Write(b)
+	var id ID
+	copy(id[:], h.Sum(nil))
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+// Expand replaces ${machine_id} in s with id, so that the ID can be used in
+// templates such as kernel command lines and log host names. Other variables
+// are left alone.
+func Expand(s string, id ID) string {
+	return strings.ReplaceAll(s, "${machine_id}", id.String())
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package machineid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+// Source is where a machine ID comes from.
+type Source string
+
+// Sources of machine IDs.
+const (
+	// SourceFile is an ID that was already in the machine-id file.
+	SourceFile Source = "file"
+	// SourceSMBIOS is the system UUID of the SMBIOS system information.
+	SourceSMBIOS Source = "smbios"
+	// SourceTPM is derived from the public endorsement key of the TPM.
+	SourceTPM Source = "tpm"
+	// SourceMAC is derived from the lowest permanent MAC address of the
+	// physical network interfaces.
+	SourceMAC Source = "mac"
+)
+
+// DefaultSources are tried in order if Opts has none.
+var DefaultSources = []Source{SourceSMBIOS, SourceTPM, SourceMAC}
+
+// ParseSources parses a comma-separated list of sources, e.g. "smbios,mac".
+func ParseSources(s string) ([]Source, error) {
+	var sources []Source
+	for _, f := range strings.Split(s, ",") {
+		switch src := Source(strings.TrimSpace(f)); src {
+		case SourceSMBIOS, SourceTPM, SourceMAC:
+			sources = append(sources, src)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown machine ID source %q", f)
+		}
+	}
+	return sources, nil
+}
+
+// ErrNoSource is returned by Derive if no source gave an ID.
+var ErrNoSource = errors.New("no source for a machine ID")
+
+// Opts configures Derive.
+type Opts struct {
+	// Sources are tried in order. It defaults to DefaultSources.
+	Sources []Source
+
+	// root and readEK are overridden in tests.
+	root   string
+	readEK func() ([]byte, error)
+}
+
+func (o *Opts) defaults() {
+	if len(o.Sources) == 0 {
+		o.Sources = DefaultSources
+	}
+	if o.root == "" {
+		o.root = "/"
+	}
+	if o.readEK == nil {
+		o.readEK = readEK
+	}
+}
+
+func readEK() ([]byte, error) {
+	t, err := tss.NewTPM()
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	return t.ReadPubEK("")
+}
+
+// bogusUUIDs are system UUIDs that firmware is known to report for every
+// machine.
+var bogusUUIDs = map[uuid.UUID]bool{
+	{}: true,
+	{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}: true,
+	uuid.MustParse("03000200-0400-0500-0006-000700080009"):                                           true,
+}
+
+func (o *Opts) smbios() (ID, error) {
+	b, err := os.ReadFile(filepath.Join(o.root, "sys/class/dmi/id/product_uuid"))
+	if err != nil {
+		return ID{}, err
+	}
+	u, err := uuid.Parse(strings.TrimSpace(string(b)))
+	if err != nil {
+		return ID{}, err
+	}
+	if bogusUUIDs[u] {
+		return ID{}, fmt.Errorf("system UUID %s is not unique", u)
+	}
+	return ID(u), nil
+}
+
+func (o *Opts) tpm() (ID, error) {
+	ek, err := o.readEK()
+	if err != nil {
+		return ID{}, err
+	}
+	return hashID("tpm", ek), nil
+}
+
+// mac uses the lowest address, so that the ID does not change with the order
+// in which interfaces are found. Virtual interfaces, which have no device,
+// and random addresses are skipped.
+func (o *Opts) mac() (ID, error) {
+	dir := filepath.Join(o.root, "sys/class/net")
+	ifaces, err := os.ReadDir(dir)
+	if err != nil {
+		return ID{}, err
+	}
+	var macs []net.HardwareAddr
+	for _, i := range ifaces {
+		p := filepath.Join(dir, i.Name())
+		if _, err := os.Stat(filepath.Join(p, "device")); err != nil {
+			continue
+		}
+		// 0 is a permanent address.
+		if t, err := os.ReadFile(filepath.Join(p, "addr_assign_type")); err == nil && strings.TrimSpace(string(t)) != "0" {
+			continue
+		}
+		a, err := os.ReadFile(filepath.Join(p, "address"))
+		if err != nil {
+			continue
+		}
+		mac, err := net.ParseMAC(strings.TrimSpace(string(a)))
+		if err != nil || len(mac) != 6 || bytes.Equal(mac, make(net.HardwareAddr, 6)) {
+			continue
+		}
+		macs = append(macs, mac)
+	}
+	if len(macs) == 0 {
+		return ID{}, errors.New("no permanent MAC address")
+	}
+	sort.Slice(macs, func(i, j int) bool { return bytes.Compare(macs[i], macs[j]) < 0 })
+	return hashID("mac", macs[0]), nil
+}
+
+// Derive derives the machine ID from the first of opts.Sources that has one.
+// The same hardware always gives the same ID.
+func Derive(opts Opts) (ID, Source, error) {
+	opts.defaults()
+	var errs []string
+	for _, s := range opts.Sources {
+		var id ID
+		var err error
+		switch s {
+		case SourceSMBIOS:
+			id, err = opts.smbios()
+		case SourceTPM:
+			id, err = opts.tpm()
+		case SourceMAC:
+			id, err = opts.mac()
+		default:
+			err = fmt.Errorf("unknown source")
+		}
+		if err == nil {
+			return id, s, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", s, err))
+	}
+	return ID{}, "", fmt.Errorf("%w (%s)", ErrNoSource, strings.Join(errs, "; "))
+}
+
+// Ensure returns the machine ID in the file at path, or else derives one
+// with opts and writes it there.
+func Ensure(path string, opts Opts) (ID, Source, error) {
+	if id, err := Read(path); err == nil {
+		return id, SourceFile, nil
+	}
+	id, src, err := Derive(opts)
+	if err != nil {
+		return ID{}, "", err
+	}
+	if err := Write(path, id); err != nil {
+		return ID{}, "", err
+	}
+	return id, src, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package machineid
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fakeRoot(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if content == "" {
+			if err := os.Mkdir(p, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestDerive(t *testing.T) {
+	nics := map[string]string{
+		// lo and br0 are virtual, and wlan0 has a random address.
+		"sys/class/net/lo/address":             "00:00:00:00:00:00\n",
+		"sys/class/net/br0/address":            "00:00:00:00:00:01\n",
+		"sys/class/net/wlan0/device":           "",
+		"sys/class/net/wlan0/address":          "02:00:00:00:00:02\n",
+		"sys/class/net/wlan0/addr_assign_type": "1\n",
+		"sys/class/net/eth1/device":            "",
+		"sys/class/net/eth1/address":           "52:54:00:12:34:57\n",
+		"sys/class/net/eth1/addr_assign_type":  "0\n",
+		"sys/class/net/eth0/device":            "",
+		"sys/class/net/eth0/address":           "52:54:00:12:34:56\n",
+		"sys/class/net/eth0/addr_assign_type":  "0\n",
+	}
+	withUUID := func(u string) map[string]string {
+		m := map[string]string{"sys/class/dmi/id/product_uuid": u}
+		for k, v := range nics {
+			m[k] = v
+		}
+		return m
+	}
+	ek := []byte("endorsement key")
+	noTPM := errors.New("no TPM")
+
+	for _, tt := range []struct {
+		name    string
+		files   map[string]string
+		sources []Source
+		ekErr   error
+		want    ID
+		src     Source
+		err     error
+	}{
+		{
+			name:  "smbios",
+			files: withUUID("4C4C4544-0039-5710-8052-B4C04F384833\n"),
+			want:  ID{0x4c, 0x4c, 0x45, 0x44, 0x00, 0x39, 0x57, 0x10, 0x80, 0x52, 0xb4, 0xc0, 0x4f, 0x38, 0x48, 0x33},
+			src:   SourceSMBIOS,
+		},
+		{
+			name:  "bogus smbios, tpm",
+			files: withUUID("03000200-0400-0500-0006-000700080009\n"),
+			want:  hashID("tpm", ek),
+			src:   SourceTPM,
+		},
+		{
+			name:  "no smbios, no tpm, mac",
+			files: nics,
+			ekErr: noTPM,
+			want:  hashID("mac", []byte{0x52, 0x54, 0x00, 0x12, 0x34, 0x56}),
+			src:   SourceMAC,
+		},
+		{
+			name:    "mac first",
+			files:   withUUID("4C4C4544-0039-5710-8052-B4C04F384833\n"),
+			sources: []Source{SourceMAC, SourceSMBIOS},
+			want:    hashID("mac", []byte{0x52, 0x54, 0x00, 0x12, 0x34, 0x56}),
+			src:     SourceMAC,
+		},
+		{
+			name:  "nothing",
+			files: map[string]string{"sys/class/dmi/id/product_uuid": "00000000-0000-0000-0000-000000000000\n"},
+			ekErr: noTPM,
+			err:   ErrNoSource,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Opts{
+				Sources: tt.sources,
+				root:    fakeRoot(t, tt.files),
+				readEK:  func() ([]byte, error) { return ek, tt.ekErr },
+			}
+			id, src, err := Derive(opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Derive = %v, want %v", err, tt.err)
+			}
+			if id != tt.want || src != tt.src {
+				t.Errorf("Derive = %s, %s, want %s, %s", id, src, tt.want, tt.src)
+			}
+		})
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	opts := Opts{
+		root:   fakeRoot(t, map[string]string{"sys/class/dmi/id/product_uuid": "4c4c4544-0039-5710-8052-b4c04f384833\n"}),
+		readEK: func() ([]byte, error) { return nil, errors.New("no TPM") },
+	}
+	path := filepath.Join(t.TempDir(), "machine-id")
+	id, src, err := Ensure(path, opts)
+	if err != nil || src != SourceSMBIOS || id.String() != "4c4c4544003957108052b4c04f384833" {
+		t.Fatalf("Ensure = %s, %s, %v", id, src, err)
+	}
+	// An existing ID is kept even if the hardware changes.
+	opts.root = fakeRoot(t, map[string]string{"sys/class/dmi/id/product_uuid": "00112233-4455-6677-8899-aabbccddeeff\n"})
+	id2, src, err := Ensure(path, opts)
+	if err != nil || src != SourceFile || id2 != id {
+		t.Errorf("Ensure = %s, %s, %v, want %s, %s", id2, src, err, id, SourceFile)
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	got, err := ParseSources("mac, tpm")
+	if err != nil || len(got) != 2 || got[0] != SourceMAC || got[1] != SourceTPM {
+		t.Errorf("ParseSources = %v, %v", got, err)
+	}
+	if _, err := ParseSources("smbios,dmi"); err == nil {
+		t.Errorf("ParseSources(smbios,dmi) = nil, want error")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package machineid
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{in: "4c4c4544003957108052b4c04f384833", want: "4c4c4544003957108052b4c04f384833"},
+		{in: "4C4C4544003957108052B4C04F384833", want: "4c4c4544003957108052b4c04f384833"},
+		{in: "00000000000000000000000000000000", err: ErrInvalid},
+		{in: "4c4c4544-0039-5710-8052-b4c04f384833", err: ErrInvalid},
+		{in: "4c4c4544003957108052b4c04f38483", err: ErrInvalid},
+		{in: "zc4c4544003957108052b4c04f384833", err: ErrInvalid},
+	} {
+		id, err := Parse(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && id.String() != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, id, tt.want)
+		}
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "etc", "machine-id")
+	id, _ := Parse("4c4c4544003957108052b4c04f384833")
+	// Twice, to replace the read-only file.
+	for i := 0; i < 2; i++ {
+		if err := Write(path, id); err != nil {
+			t.Fatalf("Write = %v", err)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "4c4c4544003957108052b4c04f384833\n" {
+		t.Errorf("machine-id = %q", b)
+	}
+	got, err := Read(path)
+	if err != nil || got != id {
+		t.Errorf("Read = %s, %v, want %s", got, err, id)
+	}
+}
+
+func TestHashID(t *testing.T) {
+	a := hashID("mac", []byte{0, 1, 2, 3, 4, 5})
+	if a != hashID("mac", []byte{0, 1, 2, 3, 4, 5}) {
+		t.Errorf("hashID is not stable")
+	}
+	if a == hashID("tpm", []byte{0, 1, 2, 3, 4, 5}) {
+		t.Errorf("hashID does not depend on the kind")
+	}
+	if a[6]>>4 != 4 || a[8]>>6 != 2 {
+		t.Errorf("hashID = %s, not a version 4 UUID", a)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	id, _ := Parse("4c4c4544003957108052b4c04f384833")
+	got := Expand("console=ttyS0 id=${machine_id} root=${root}", id)
+	if want := "console=ttyS0 id=4c4c4544003957108052b4c04f384833 root=${root}"; got != want {
+		t.Errorf("Expand = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Addr is the IPv4 multicast DNS group.
+var Addr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Client sends multicast DNS queries and collects the responses.
+type Client struct {
+	conn net.PacketConn
+	addr net.Addr
+
+	// interval is how often queries are repeated to ask for what is
+	// still missing.
+	interval time.Duration
+}
+
+// Close closes the client's socket.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+type srv struct {
+	host string
+	port int
+}
+
+// cache holds the records of one browse, keyed by lower case names since
+// DNS names are case-insensitive.
+type cache struct {
+	// instances are the instance names of the service.
+	instances map[string]string
+	srv       map[string]srv
+	text      map[string][]string
+	addrs     map[string][]net.IP
+}
+
+func newCache() *cache {
+	return &cache{
+		instances: make(map[string]string),
+		srv:       make(map[string]srv),
+		text:      make(map[string][]string),
+		addrs:     make(map[string][]net.IP),
+	}
+}
+
+func (c *cache) add(service string, rs []record) {
+	for _, r := range rs {
+		key := strings.ToLower(r.name)
+		switch r.typ {
+		case typePTR:
+			if key == service {
+				c.instances[strings.ToLower(r.target)] = r.target
+			}
+		case typeSRV:
+			c.srv[key] = srv{host: r.target, port: int(r.port)}
+		case typeTXT:
+			c.text[key] = r.text
+		case typeA, typeAAAA:
+			if !containsIP(c.addrs[key], r.ip) {
+				c.addrs[key] = append(c.addrs[key], r.ip)
+			}
+		}
+	}
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// missing returns the questions to ask for service: its instances, and the
+// records of instances that have not been received yet.
+func (c *cache) missing(service string) []question {
+	qs := []question{{name: service, typ: typePTR}}
+	for key, name := range c.instances {
+		s, ok := c.srv[key]
+		if !ok {
+			qs = append(qs, question{name: name, typ: typeSRV})
+		} else if len(c.addrs[strings.ToLower(s.host)]) == 0 {
+			qs = append(qs, question{name: s.host, typ: typeA}, question{name: s.host, typ: typeAAAA})
+		}
+		if _, ok := c.text[key]; !ok {
+			qs = append(qs, question{name: name, typ: typeTXT})
+		}
+	}
+	return qs
+}
+
+// services returns the instances of service type typ that have an SRV
+// record, sorted by name.
+func (c *cache) services(typ string) []Service {
+	var ss []Service
+	for key, name := range c.instances {
+		s, ok := c.srv[key]
+		if !ok {
+			continue
+		}
+		text := make(map[string]string)
+		for _, t := range c.text[key] {
+			k, v, _ := strings.Cut(t, "=")
+			// Only the first occurrence of a key counts, RFC 6763
+			// section 6.4.
+			if _, ok := text[strings.ToLower(k)]; !ok && k != "" {
+				text[strings.ToLower(k)] = v
+			}
+		}
+		ss = append(ss, Service{
+			Instance: firstLabel(name),
+			Type:     typ,
+			Host:     s.host,
+			Port:     s.port,
+			Addrs:    c.addrs[strings.ToLower(s.host)],
+			Text:     text,
+		})
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].Instance < ss[j].Instance })
+	return ss
+}
+
+// Browse discovers the instances of service, e.g. "_https._tcp", until ctx
+// is done. Queries are repeated for instances whose host or address is
+// still unknown. Text record keys are lower case.
+func (c *Client) Browse(ctx context.Context, service string) ([]Service, error) {
+	typ := strings.TrimSuffix(service, ".")
+	name := strings.ToLower(typ + "." + Domain)
+	cache := newCache()
+
+	send := func() error {
+		msg, err := query(cache.missing(name))
+		if err != nil {
+			return err
+		}
+		_, err = c.conn.WriteTo(msg, c.addr)
+		return err
+	}
+	if err := send(); err != nil {
+		return nil, err
+	}
+	next := time.Now().Add(c.interval)
+
+	buf := make([]byte, 9000)
+	for {
+		deadline := next
+		d, ok := ctx.Deadline()
+		if ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := c.conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		n, _, err := c.conn.ReadFrom(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if ctx.Err() != nil || (ok && !time.Now().Before(d)) {
+				break
+			}
+			if !time.Now().Before(next) {
+				if err := send(); err != nil {
+					return nil, err
+				}
+				next = time.Now().Add(c.interval)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Skip garbage from responders that have bugs.
+		rs, err := parseResponse(buf[:n])
+		if err == nil {
+			cache.add(name, rs)
+		}
+	}
+	return cache.services(typ), nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mdns
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// NewClient returns a client that sends queries on iface, or on the
+// interface of the default multicast route if iface is nil.
+func NewClient(iface *net.Interface) (*Client, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		// Multicast DNS packets are sent with TTL 255, RFC 6762 section 11.
+		if serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MULTICAST_TTL, 255); serr != nil || iface == nil {
+			return
+		}
+		serr = unix.SetsockoptIPMreqn(int(fd), unix.IPPROTO_IP, unix.IP_MULTICAST_IF, &unix.IPMreqn{Ifindex: int32(iface.Index)})
+	}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if serr != nil {
+		conn.Close()
+		return nil, serr
+	}
+	return &Client{conn: conn, addr: Addr, interval: time.Second}, nil
+}
@@ -0,0 +1,320 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mdns discovers services on the local network with multicast DNS
+// (RFC 6762) and DNS-based service discovery (RFC 6763).
+//
+// It is meant for zero-config lab setups, where a boot server announces
+// itself, e.g. as an _https._tcp service with a "path=/boot.ipxe" TXT record,
+// and there is no control over the DHCP server to point clients at it.
+//
+// Only one-shot queries over IPv4 are sent. Responders answer them with
+// unicast, so the client does not need to bind port 5353.
+package mdns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Resource record types.
+const (
+	typeA    = 1
+	typePTR  = 12
+	typeTXT  = 16
+	typeAAAA = 28
+	typeSRV  = 33
+)
+
+const (
+	classIN = 1
+
+	// classMask strips the cache-flush bit of answers and the
+	// unicast-response bit of questions.
+	classMask = 0x7fff
+
+	// classUnicast asks responders to answer with unicast.
+	classUnicast = 0x8000
+
+	// flagResponse is the QR bit of the header.
+	flagResponse = 0x8000
+)
+
+// Domain is the multicast DNS domain.
+const Domain = "local."
+
+// Service is a service instance discovered with DNS-SD.
+type Service struct {
+	// Instance is the user-visible name, e.g. "Lab boot server".
+	Instance string
+
+	// Type is the service type, e.g. "_https._tcp".
+	Type string
+
+	// Host is the target host, e.g. "bootsrv.local.".
+	Host string
+	Port int
+
+	// Addrs are the addresses of Host.
+	Addrs []net.IP
+
+	// Text are the key=value pairs of the TXT record. A key without a value
+	// maps to "".
+	Text map[string]string
+}
+
+// URL returns the URL of the service, e.g.
+// https://10.0.0.2:8443/boot.ipxe for an _https._tcp service with the TXT
+// record "path=/boot.ipxe". The scheme is the service name, and the host is
+// the first IPv4 address, or Host if there are no addresses.
+func (s Service) URL() *url.URL {
+	scheme, _, _ := strings.Cut(strings.TrimPrefix(s.Type, "_"), ".")
+	host := strings.TrimSuffix(s.Host, ".")
+	for i, ip := range s.Addrs {
+		if i == 0 || ip.To4() != nil {
+			host = ip.String()
+		}
+		if ip.To4() != nil {
+			break
+		}
+	}
+	p := s.Text["path"]
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(host, strconv.Itoa(s.Port)),
+		Path:   p,
+	}
+}
+
+func (s Service) String() string {
+	return fmt.Sprintf("%q (%s) at %s", s.Instance, s.Type, s.URL())
+}
+
+// record is a resource record that the client uses.
+type record struct {
+	name string
+	typ  uint16
+
+	// target is the name of PTR and SRV records.
+	target string
+	port   uint16
+	text   []string
+	ip     net.IP
+}
+
+type question struct {
+	name string
+	typ  uint16
+}
+
+var errTruncated = errors.New("truncated DNS message")
+
+// appendName appends name, with labels separated by dots, in wire format.
+// Dots and backslashes in labels are escaped with a backslash.
+func appendName(b []byte, name string) ([]byte, error) {
+	var label []byte
+	flush := func() error {
+		if len(label) == 0 {
+			return fmt.Errorf("empty label in %q", name)
+		}
+		if len(label) > 63 {
+			return fmt.Errorf("label %q is too long", label)
+		}
+		b = append(append(b, byte(len(label))), label...)
+		label = label[:0]
+		return nil
+	}
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c == '\\' && i+1 < len(name):
+			i++
+			label = append(label, name[i])
+		case c == '.':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		default:
+			label = append(label, c)
+		}
+	}
+	if len(label) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, 0), nil
+}
+
+// readName reads the name at off of msg, following compression pointers,
+// and returns it and the offset after it.
+func readName(msg []byte, off int) (string, int, error) {
+	var sb strings.Builder
+	end := -1
+	for hops := 0; ; hops++ {
+		if off >= len(msg) || hops > 64 {
+			return "", 0, errTruncated
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			if sb.Len() == 0 {
+				return ".", end, nil
+			}
+			return sb.String(), end, nil
+
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, errTruncated
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errTruncated
+			}
+			for _, c := range msg[off+1 : off+1+n] {
+				if c == '.' || c == '\\' {
+					sb.WriteByte('\\')
+				}
+				sb.WriteByte(c)
+			}
+			sb.WriteByte('.')
+			off += 1 + n
+		}
+	}
+}
+
+// firstLabel returns the first label of name, unescaped.
+func firstLabel(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '\\':
+			if i++; i < len(name) {
+				sb.WriteByte(name[i])
+			}
+		case '.':
+			return sb.String()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// query returns a query message for qs that asks for unicast responses.
+func query(qs []question) ([]byte, error) {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(qs)))
+	for _, q := range qs {
+		var err error
+		if b, err = appendName(b, q.name); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, q.typ)
+		b = binary.BigEndian.AppendUint16(b, classIN|classUnicast)
+	}
+	return b, nil
+}
+
+// parseResponse returns the records of the answer, authority and additional
+// sections of response msg that the client uses. Other messages and records
+// are skipped.
+func parseResponse(msg []byte) ([]record, error) {
+	if len(msg) < 12 {
+		return nil, errTruncated
+	}
+	if binary.BigEndian.Uint16(msg[2:])&flagResponse == 0 {
+		return nil, nil
+	}
+	qd := int(binary.BigEndian.Uint16(msg[4:]))
+	rrs := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+
+	off := 12
+	for i := 0; i < qd; i++ {
+		_, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if off = next + 4; off > len(msg) {
+			return nil, errTruncated
+		}
+	}
+
+	var records []record
+	for i := 0; i < rrs; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, errTruncated
+		}
+		typ := binary.BigEndian.Uint16(msg[next:])
+		class := binary.BigEndian.Uint16(msg[next+2:]) & classMask
+		n := int(binary.BigEndian.Uint16(msg[next+8:]))
+		data := next + 10
+		if off = data + n; off > len(msg) {
+			return nil, errTruncated
+		}
+		if class != classIN {
+			continue
+		}
+		rdata := msg[data:off]
+
+		r := record{name: name, typ: typ}
+		switch typ {
+		case typeA, typeAAAA:
+			if (typ == typeA && n != net.IPv4len) || (typ == typeAAAA && n != net.IPv6len) {
+				return nil, fmt.Errorf("address record of %q has %d bytes", name, n)
+			}
+			r.ip = net.IP(append([]byte(nil), rdata...))
+
+		case typePTR:
+			if r.target, _, err = readName(msg, data); err != nil {
+				return nil, err
+			}
+
+		case typeSRV:
+			if n < 7 {
+				return nil, errTruncated
+			}
+			r.port = binary.BigEndian.Uint16(rdata[4:])
+			if r.target, _, err = readName(msg, data+6); err != nil {
+				return nil, err
+			}
+
+		case typeTXT:
+			for len(rdata) > 0 {
+				l := int(rdata[0])
+				if 1+l > len(rdata) {
+					return nil, errTruncated
+				}
+				if l > 0 {
+					r.text = append(r.text, string(rdata[1:1+l]))
+				}
+				rdata = rdata[1+l:]
+			}
+
+		default:
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mdns
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// rr is a resource record for fake responses.
+type rr struct {
+	name string
+	typ  uint16
+	data func(b []byte) []byte
+}
+
+func ptr(name, target string) rr {
+	return rr{name, typePTR, func(b []byte) []byte { b, _ = appendName(b, target); return b }}
+}
+
+func srvRR(name, target string, port uint16) rr {
+	return rr{name, typeSRV, func(b []byte) []byte {
+		b = append(b, 0, 0, 0, 0)
+		b = binary.BigEndian.AppendUint16(b, port)
+		b, _ = appendName(b, target)
+		return b
+	}}
+}
+
+func txt(name string, text ...string) rr {
+	return rr{name, typeTXT, func(b []byte) []byte {
+		for _, t := range text {
+			b = append(append(b, byte(len(t))), t...)
+		}
+		return b
+	}}
+}
+
+func a(name string, ip net.IP) rr {
+	return rr{name, typeA, func(b []byte) []byte { return append(b, ip.To4()...) }}
+}
+
+func response(rrs ...rr) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[2:], flagResponse)
+	binary.BigEndian.PutUint16(b[6:], uint16(len(rrs)))
+	for _, r := range rrs {
+		b, _ = appendName(b, r.name)
+		b = binary.BigEndian.AppendUint16(b, r.typ)
+		b = binary.BigEndian.AppendUint16(b, classIN|0x8000)
+		b = append(b, 0, 0, 0, 120, 0, 0)
+		l := len(b)
+		b = r.data(b)
+		binary.BigEndian.PutUint16(b[l-2:], uint16(len(b)-l))
+	}
+	return b
+}
+
+func TestNames(t *testing.T) {
+	for _, name := range []string{"_https._tcp.local.", `Lab\.1 boot\\srv._https._tcp.local.`} {
+		b, err := appendName(nil, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := readName(b, 0)
+		if err != nil || got != name || n != len(b) {
+			t.Errorf("readName(appendName(%q)) = %q, %d, %v", name, got, n, err)
+		}
+	}
+	if got := firstLabel(`Lab\.1 boot\\srv._https._tcp.local.`); got != `Lab.1 boot\srv` {
+		t.Errorf("firstLabel = %q", got)
+	}
+
+	// "boot" followed by a pointer to "local." at offset 0.
+	msg := []byte{5, 'l', 'o', 'c', 'a', 'l', 0, 4, 'b', 'o', 'o', 't', 0xc0, 0}
+	if got, n, err := readName(msg, 7); err != nil || got != "boot.local." || n != len(msg) {
+		t.Errorf("readName with pointer = %q, %d, %v", got, n, err)
+	}
+	// A pointer loop.
+	if _, _, err := readName([]byte{0xc0, 0}, 0); err == nil {
+		t.Errorf("readName of a pointer loop = nil, want error")
+	}
+	if _, err := appendName(nil, "a..b"); err == nil {
+		t.Errorf("appendName(a..b) = nil, want error")
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	msg := response(
+		ptr("_https._tcp.local.", "lab._https._tcp.local."),
+		srvRR("lab._https._tcp.local.", "bootsrv.local.", 8443),
+		txt("lab._https._tcp.local.", "path=/boot.ipxe", "v"),
+		a("bootsrv.local.", net.IPv4(10, 0, 0, 2)),
+	)
+	got, err := parseResponse(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []record{
+		{name: "_https._tcp.local.", typ: typePTR, target: "lab._https._tcp.local."},
+		{name: "lab._https._tcp.local.", typ: typeSRV, target: "bootsrv.local.", port: 8443},
+		{name: "lab._https._tcp.local.", typ: typeTXT, text: []string{"path=/boot.ipxe", "v"}},
+		{name: "bootsrv.local.", typ: typeA, ip: net.IP{10, 0, 0, 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseResponse = %+v, want %+v", got, want)
+	}
+
+	for i := 12; i < len(msg); i++ {
+		if _, err := parseResponse(msg[:i]); err == nil {
+			t.Errorf("parseResponse of %d of %d bytes = nil, want error", i, len(msg))
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	for _, tt := range []struct {
+		s    Service
+		want string
+	}{
+		{
+			s:    Service{Type: "_https._tcp", Host: "bootsrv.local.", Port: 8443, Addrs: []net.IP{net.ParseIP("fe80::1"), net.IPv4(10, 0, 0, 2)}, Text: map[string]string{"path": "/boot.ipxe"}},
+			want: "https://10.0.0.2:8443/boot.ipxe",
+		},
+		{
+			s:    Service{Type: "_http._tcp", Host: "bootsrv.local.", Port: 80, Addrs: []net.IP{net.ParseIP("fd00::2")}},
+			want: "http://[fd00::2]:80/",
+		},
+		{
+			s:    Service{Type: "_tftp._udp", Host: "bootsrv.local.", Port: 69, Text: map[string]string{"path": "pxelinux.0"}},
+			want: "tftp://bootsrv.local:69/pxelinux.0",
+		},
+	} {
+		if got := tt.s.URL().String(); got != tt.want {
+			t.Errorf("URL(%+v) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	responder, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{conn: conn, addr: responder.LocalAddr(), interval: 20 * time.Millisecond}
+	defer c.Close()
+
+	// The responder answers the first query with the instances only, and
+	// the others with what they ask for, like responders whose answers
+	// do not fit one packet.
+	questions := make(chan []question, 10)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := responder.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			qs, err := parseQuestions(buf[:n])
+			if err != nil {
+				t.Errorf("bad query: %v", err)
+				return
+			}
+			select {
+			case questions <- qs:
+			default:
+			}
+			var rrs []rr
+			for _, q := range qs {
+				switch q {
+				case question{"_https._tcp.local.", typePTR}:
+					rrs = append(rrs, ptr(q.name, `Lab\.1._https._tcp.local.`), ptr(q.name, "Other._https._tcp.local."))
+				case question{`Lab\.1._https._tcp.local.`, typeSRV}:
+					rrs = append(rrs, srvRR(q.name, "bootsrv.local.", 8443))
+				case question{`Lab\.1._https._tcp.local.`, typeTXT}:
+					rrs = append(rrs, txt(q.name, "Path=/boot.ipxe", "path=/ignored"))
+				case question{"bootsrv.local.", typeA}:
+					rrs = append(rrs, a(q.name, net.IPv4(10, 0, 0, 2)))
+				}
+			}
+			responder.WriteTo(response(rrs...), from)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	got, err := c.Browse(ctx, "_https._tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Service{{
+		Instance: "Lab.1",
+		Type:     "_https._tcp",
+		Host:     "bootsrv.local.",
+		Port:     8443,
+		Addrs:    []net.IP{{10, 0, 0, 2}},
+		Text:     map[string]string{"path": "/boot.ipxe"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Browse = %+v, want %+v", got, want)
+	}
+	if qs := <-questions; !reflect.DeepEqual(qs, []question{{"_https._tcp.local.", typePTR}}) {
+		t.Errorf("first query = %v, want the PTR only", qs)
+	}
+}
+
+// parseQuestions returns the questions of query msg.
+func parseQuestions(msg []byte) ([]question, error) {
+	var qs []question
+	off := 12
+	for i := 0; i < int(binary.BigEndian.Uint16(msg[4:])); i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(msg) {
+			return nil, errTruncated
+		}
+		if binary.BigEndian.Uint16(msg[next+2:]) != classIN|classUnicast {
+			return nil, errTruncated
+		}
+		qs = append(qs, question{name: name, typ: binary.BigEndian.Uint16(msg[next:])})
+		off = next + 4
+	}
+	return qs, nil
+}
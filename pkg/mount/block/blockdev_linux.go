@@ -119,6 +119,27 @@ func (b *BlockDev) GPTTable() (*gpt.Table, error) {
 	return &table, nil
 }
 
+// WriteGPTTable writes t, as read by GPTTable and modified, e.g. with
+// SetPartAttrs, to the block device, both the primary and the backup GPT.
+//
+// The kernel is not told: call ReadPartitionTable if partitions were added,
+// removed, or moved.
+func (b *BlockDev) WriteGPTTable(t *gpt.Table) error {
+	f, err := os.OpenFile(b.DevicePath(), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if err := WriteGPT(f, t); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 // PhysicalBlockSize returns the physical block size.
 func (b *BlockDev) PhysicalBlockSize() (int, error) {
 	f, err := os.Open(b.DevicePath())
@@ -187,6 +208,23 @@ func (b *BlockDev) ReadPartitionTable() error {
 	return unix.IoctlSetInt(int(f.Fd()), unix.BLKRRPART, 0)
 }
 
+// ResizePartition tells the kernel that partition n, from 1, of the disk open
+// in f is now length bytes long from byte start, e.g. after growing it with
+// GrowGPTPartition while it is mounted, when the partition table cannot be
+// re-read.
+func ResizePartition(f *os.File, n int, start, length int64) error {
+	p := unix.BlkpgPartition{Start: start, Length: length, Pno: int32(n)}
+	arg := unix.BlkpgIoctlArg{
+		Op:      unix.BLKPG_RESIZE_PARTITION,
+		Datalen: int32(unsafe.Sizeof(p)),
+		Data:    (*byte)(unsafe.Pointer(&p)),
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), unix.BLKPG, uintptr(unsafe.Pointer(&arg))); errno != 0 {
+		return os.NewSyscallError("ioctl(BLKPG)", errno)
+	}
+	return nil
+}
+
 // PCIInfo searches sysfs for the PCI vendor and device id.
 // We fill in the PCI struct with just those two elements.
 func (b *BlockDev) PCIInfo() (*pci.PCI, error) {
@@ -0,0 +1,104 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package block
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rekby/gpt"
+)
+
+// ErrNoChange is returned by GrowGPTPartition when the partition already
+// ends where it can.
+var ErrNoChange = errors.New("partition cannot be grown")
+
+// GrowGPTPartition grows partition n, from 1, of the GPT of the disk in rw,
+// with sectors of sectorSize bytes, up to the next partition or to the end of
+// the disk, and moves the backup GPT to the end of the disk, e.g. after an
+// image smaller than the disk was written to it. If n is 0, the partition
+// that ends last is grown.
+//
+// It returns the number of the partition, and the table written, or
+// ErrNoChange. The kernel is not told.
+func GrowGPTPartition(rw io.ReadWriteSeeker, sectorSize uint64, n int) (int, *gpt.Table, error) {
+	size, err := rw.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := rw.Seek(int64(sectorSize), io.SeekStart); err != nil {
+		return 0, nil, err
+	}
+	t, err := gpt.ReadTable(rw, sectorSize)
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading GPT: %v", err)
+	}
+	sectors := uint64(size) / sectorSize
+	nt := t
+	if sectors > t.Header.HeaderCopyStartLBA+1 {
+		nt = t.CreateTableForNewDiskSize(sectors)
+	}
+
+	if n == 0 {
+		for i, p := range nt.Partitions {
+			if !p.IsEmpty() && (n == 0 || p.LastLBA > nt.Partitions[n-1].LastLBA) {
+				n = i + 1
+			}
+		}
+		if n == 0 {
+			return 0, nil, errors.New("no partition to grow")
+		}
+	}
+	if n < 1 || n > len(nt.Partitions) || nt.Partitions[n-1].IsEmpty() {
+		return 0, nil, fmt.Errorf("no partition %d", n)
+	}
+	p := &nt.Partitions[n-1]
+	end := nt.Header.LastUsableLBA
+	for i, q := range nt.Partitions {
+		if i != n-1 && !q.IsEmpty() && q.FirstLBA > p.LastLBA && q.FirstLBA-1 < end {
+			end = q.FirstLBA - 1
+		}
+	}
+	if p.LastLBA >= end {
+		return n, nil, ErrNoChange
+	}
+	p.LastLBA = end
+
+	if err := WriteGPT(rw, &nt); err != nil {
+		return 0, nil, err
+	}
+	if err := growProtectiveMBR(rw, sectors); err != nil {
+		return 0, nil, err
+	}
+	return n, &nt, nil
+}
+
+// growProtectiveMBR makes the protective MBR of the disk of sectors sectors
+// in rw, if it has one, cover the disk.
+func growProtectiveMBR(rw io.ReadWriteSeeker, sectors uint64) error {
+	const entry = 446
+	if _, err := rw.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	mbr := make([]byte, 512)
+	if _, err := io.ReadFull(rw, mbr); err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint16(mbr[510:]) != 0xaa55 || mbr[entry+4] != 0xee {
+		return nil
+	}
+	n := sectors - 1
+	if n > 0xffffffff {
+		n = 0xffffffff
+	}
+	binary.LittleEndian.PutUint32(mbr[entry+12:], uint32(n))
+	if _, err := rw.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := rw.Write(mbr)
+	return err
+}
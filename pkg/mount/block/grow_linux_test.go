@@ -0,0 +1,119 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package block
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rekby/gpt"
+)
+
+// linuxFS is the type GUID of Linux file system partitions.
+var linuxFS = gpt.PartType{0xaf, 0x3d, 0xc6, 0x0f, 0x83, 0x84, 0x72, 0x47, 0x8e, 0x79, 0x3d, 0x69, 0xd8, 0x47, 0x7d, 0xe4}
+
+// writeImage writes a disk image of size bytes with partitions of the given
+// sizes in sectors of 512 bytes, and a protective MBR, to a file.
+func writeImage(t *testing.T, size uint64, parts ...uint64) *os.File {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "disk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	if err := f.Truncate(int64(size)); err != nil {
+		t.Fatal(err)
+	}
+	tbl := gpt.NewTable(size, &gpt.NewTableArgs{SectorSize: 512})
+	first := tbl.Header.FirstUsableLBA
+	for i, n := range parts {
+		tbl.Partitions[i].Type = linuxFS
+		tbl.Partitions[i].FirstLBA = first
+		tbl.Partitions[i].LastLBA = first + n - 1
+		first += n
+	}
+	if err := WriteGPT(f, &tbl); err != nil {
+		t.Fatal(err)
+	}
+	mbr := make([]byte, 512)
+	mbr[446+4], mbr[510], mbr[511] = 0xee, 0x55, 0xaa
+	binary.LittleEndian.PutUint32(mbr[446+12:], uint32(size/512-1))
+	if _, err := f.WriteAt(mbr, 0); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestGrowGPTPartition(t *testing.T) {
+	const size = 4 << 20
+	for _, tt := range []struct {
+		name    string
+		parts   []uint64
+		grownTo uint64
+		n       int
+		wantN   int
+		wantErr error
+	}{
+		{name: "last", parts: []uint64{2048, 1024}, grownTo: 2 * size, wantN: 2},
+		{name: "numbered", parts: []uint64{2048, 1024}, grownTo: 2 * size, n: 2, wantN: 2},
+		{name: "up to the next partition", parts: []uint64{2048, 1024}, grownTo: 2 * size, n: 1, wantErr: ErrNoChange, wantN: 1},
+		{name: "free space on the same disk", parts: []uint64{2048}, grownTo: size, wantN: 1},
+		{name: "already grown", parts: []uint64{2048, size/512 - 2048 - 34 - 33}, grownTo: size, wantErr: ErrNoChange, wantN: 2},
+		{name: "no such partition", parts: []uint64{2048}, grownTo: 2 * size, n: 3, wantErr: errors.New("no partition 3")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := writeImage(t, size, tt.parts...)
+			if err := f.Truncate(int64(tt.grownTo)); err != nil {
+				t.Fatal(err)
+			}
+			n, got, err := GrowGPTPartition(f, 512, tt.n)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() || n != tt.wantN {
+					t.Fatalf("GrowGPTPartition = %d, %v, want %d, %v", n, err, tt.wantN, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || n != tt.wantN {
+				t.Fatalf("GrowGPTPartition = %d, %v, want %d, nil", n, err, tt.wantN)
+			}
+
+			if _, err := f.Seek(512, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			read, err := gpt.ReadTable(f, 512)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sectors := tt.grownTo / 512
+			if read.Header.HeaderCopyStartLBA != sectors-1 {
+				t.Errorf("backup GPT at LBA %d, want %d", read.Header.HeaderCopyStartLBA, sectors-1)
+			}
+			for i := range tt.parts {
+				want := got.Partitions[i].LastLBA
+				if i == n-1 {
+					want = read.Header.LastUsableLBA
+				}
+				if p := read.Partitions[i]; p.LastLBA != want {
+					t.Errorf("partition %d ends at LBA %d, want %d", i+1, p.LastLBA, want)
+				}
+			}
+			mbr := make([]byte, 512)
+			if _, err := f.ReadAt(mbr, 0); err != nil {
+				t.Fatal(err)
+			}
+			if got := binary.LittleEndian.Uint32(mbr[446+12:]); uint64(got) != sectors-1 {
+				t.Errorf("protective MBR covers %d sectors, want %d", got, sectors-1)
+			}
+
+			if _, _, err := GrowGPTPartition(f, 512, tt.n); err != ErrNoChange {
+				t.Errorf("GrowGPTPartition again = %v, want %v", err, ErrNoChange)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package block
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+
+	"github.com/rekby/gpt"
+)
+
+// PartitionAttrs are the attribute bits of a GPT partition entry.
+type PartitionAttrs uint64
+
+const (
+	// AttrRequired marks a partition the platform needs to work.
+	AttrRequired PartitionAttrs = 1 << 0
+
+	// AttrNoBlockIO asks EFI firmware not to expose the partition as a
+	// block device.
+	AttrNoBlockIO PartitionAttrs = 1 << 1
+
+	// AttrLegacyBootable marks a partition bootable by legacy BIOSes.
+	AttrLegacyBootable PartitionAttrs = 1 << 2
+)
+
+// The A/B boot attributes are in the type specific bits 48 to 56, as in
+// ChromeOS kernel partitions.
+const (
+	priorityShift   = 48
+	triesShift      = 52
+	successfulShift = 56
+
+	bootMask = 0x1ff << priorityShift
+
+	// MaxPriority is the highest priority of a partition.
+	MaxPriority = 15
+
+	// MaxTries is the most tries a partition can have.
+	MaxTries = 15
+)
+
+// BootAttrs are the A/B boot attributes of a partition.
+//
+// The bootloader boots the partition with the highest priority that is
+// successful or has tries left, and decrements its tries. The booted system
+// marks its partition successful once it is known to work, or the bootloader
+// gives up on it after its tries.
+type BootAttrs struct {
+	// Priority is between 0, not bootable, and MaxPriority.
+	Priority int
+
+	// Tries is how many more times to try booting the partition, until
+	// it is successful, up to MaxTries.
+	Tries int
+
+	// Successful is whether the partition booted successfully.
+	Successful bool
+}
+
+// Boot returns the A/B boot attributes of a.
+func (a PartitionAttrs) Boot() BootAttrs {
+	return BootAttrs{
+		Priority:   int(a>>priorityShift) & 0xf,
+		Tries:      int(a>>triesShift) & 0xf,
+		Successful: a&(1<<successfulShift) != 0,
+	}
+}
+
+// WithBoot returns a with the A/B boot attributes b.
+func (a PartitionAttrs) WithBoot(b BootAttrs) (PartitionAttrs, error) {
+	if b.Priority < 0 || b.Priority > MaxPriority {
+		return a, fmt.Errorf("priority %d is not between 0 and %d", b.Priority, MaxPriority)
+	}
+	if b.Tries < 0 || b.Tries > MaxTries {
+		return a, fmt.Errorf("tries %d is not between 0 and %d", b.Tries, MaxTries)
+	}
+	a &^= bootMask
+	a |= PartitionAttrs(b.Priority)<<priorityShift | PartitionAttrs(b.Tries)<<triesShift
+	if b.Successful {
+		a |= 1 << successfulShift
+	}
+	return a, nil
+}
+
+// PartAttrs returns the attributes of the partition p.
+func PartAttrs(p gpt.Partition) PartitionAttrs {
+	return PartitionAttrs(binary.LittleEndian.Uint64(p.Flags[:]))
+}
+
+// SetPartAttrs sets the attributes of the partition p to a.
+func SetPartAttrs(p *gpt.Partition, a PartitionAttrs) {
+	binary.LittleEndian.PutUint64(p.Flags[:], uint64(a))
+}
+
+// SetPartName sets the name of the partition p, which is read with p.Name.
+// Names are at most 36 UTF-16 code units long.
+func SetPartName(p *gpt.Partition, name string) error {
+	u := utf16.Encode([]rune(name))
+	if len(u) > len(p.PartNameUTF16)/2 {
+		return fmt.Errorf("partition name %q is longer than %d UTF-16 code units", name, len(p.PartNameUTF16)/2)
+	}
+	p.PartNameUTF16 = [72]byte{}
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(p.PartNameUTF16[2*i:], c)
+	}
+	return nil
+}
+
+// WriteGPT writes the primary GPT t, as read by BlockDev.GPTTable, and its
+// backup at the end of the disk to w. The checksums are recomputed.
+func WriteGPT(w io.WriteSeeker, t *gpt.Table) error {
+	if err := t.Write(w); err != nil {
+		return fmt.Errorf("writing primary GPT: %v", err)
+	}
+	if err := t.CreateOtherSideTable().Write(w); err != nil {
+		return fmt.Errorf("writing backup GPT: %v", err)
+	}
+	return nil
+}
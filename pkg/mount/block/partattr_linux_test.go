@@ -0,0 +1,148 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package block
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rekby/gpt"
+)
+
+func TestBootAttrs(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		attrs   PartitionAttrs
+		boot    BootAttrs
+		want    PartitionAttrs
+		wantErr bool
+	}{
+		{
+			name: "priority",
+			boot: BootAttrs{Priority: 2},
+			want: 0x0002_0000_0000_0000,
+		},
+		{
+			name: "all",
+			boot: BootAttrs{Priority: 15, Tries: 15, Successful: true},
+			want: 0x01ff_0000_0000_0000,
+		},
+		{
+			name:  "keep other bits",
+			attrs: 0x8001_0000_0000_0000 | AttrRequired | AttrLegacyBootable,
+			boot:  BootAttrs{Priority: 1, Tries: 6},
+			want:  0x8061_0000_0000_0000 | AttrRequired | AttrLegacyBootable,
+		},
+		{
+			name:  "clear successful",
+			attrs: 0x0100_0000_0000_0000,
+			boot:  BootAttrs{},
+			want:  0,
+		},
+		{
+			name:    "priority too high",
+			boot:    BootAttrs{Priority: 16},
+			wantErr: true,
+		},
+		{
+			name:    "negative tries",
+			boot:    BootAttrs{Tries: -1},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.attrs.WithBoot(tt.boot)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithBoot(%+v) = %v, want error %t", tt.boot, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("WithBoot(%+v) = %#x, want %#x", tt.boot, got, tt.want)
+			}
+			if b := got.Boot(); b != tt.boot {
+				t.Errorf("Boot() = %+v, want %+v", b, tt.boot)
+			}
+		})
+	}
+}
+
+func TestSetPartName(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "KERN-A"},
+		{name: ""},
+		{name: "système 🚀"},
+		{name: strings.Repeat("a", 36)},
+		{name: strings.Repeat("a", 37), wantErr: true},
+		// Each emoji takes 2 UTF-16 code units.
+		{name: strings.Repeat("🚀", 19), wantErr: true},
+	} {
+		var p gpt.Partition
+		// A longer old name must not show through.
+		if err := SetPartName(&p, strings.Repeat("x", 36)); err != nil {
+			t.Fatal(err)
+		}
+		err := SetPartName(&p, tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SetPartName(%q) = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+		if err == nil && p.Name() != tt.name {
+			t.Errorf("SetPartName(%q) set name %q", tt.name, p.Name())
+		}
+	}
+}
+
+func TestWriteGPT(t *testing.T) {
+	const size = 1 << 20
+	f, err := os.Create(filepath.Join(t.TempDir(), "disk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+
+	table := gpt.NewTable(size, nil)
+	p := &table.Partitions[0]
+	p.Type = gpt.PartType(SystemPartitionGUID)
+	p.Id = gpt.NewGUID()
+	p.FirstLBA, p.LastLBA = table.Header.FirstUsableLBA, table.Header.LastUsableLBA
+	if err := SetPartName(p, "KERN-A"); err != nil {
+		t.Fatal(err)
+	}
+	attrs, err := AttrRequired.WithBoot(BootAttrs{Priority: 1, Tries: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetPartAttrs(p, attrs)
+	if err := WriteGPT(f, &table); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, lba := range []uint64{table.Header.HeaderStartLBA, table.Header.HeaderCopyStartLBA} {
+		if _, err := f.Seek(int64(lba*table.SectorSize), io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		got, err := gpt.ReadTable(f, table.SectorSize)
+		if err != nil {
+			t.Fatalf("reading GPT at LBA %d: %v", lba, err)
+		}
+		if got.Header.HeaderStartLBA != lba {
+			t.Errorf("GPT at LBA %d says it is at %d", lba, got.Header.HeaderStartLBA)
+		}
+		gp := got.Partitions[0]
+		if gp.Name() != "KERN-A" || gp.Id != p.Id || PartAttrs(gp) != attrs {
+			t.Errorf("GPT at LBA %d has partition %q %v %#x, want %q %v %#x", lba, gp.Name(), gp.Id, PartAttrs(gp), "KERN-A", p.Id, attrs)
+		}
+	}
+}
@@ -56,6 +56,7 @@ var (
 	ECRYPTFS    = []byte{0xf1, 0x5f}
 	EFIVARFS    = []byte{0xde, 0x5e, 0x81, 0xe4}
 	EFS         = []byte{0x41, 0x4A, 0x53}
+	EROFS       = []byte{0xe2, 0xe1, 0xf5, 0xe0}
 	// EXFAT seems to be a samsung file system.
 	// EXFAT       = []byte{0x53, 0xef}
 	F2FS      = []byte{0xF2, 0xF5, 0x20, 0x10}
@@ -129,6 +130,7 @@ var magics = []magic{
 	{magic: VFAT, name: "vfat", off: 0},
 	{magic: VVFAT, name: "vfat", off: 0},
 	{magic: XFS, name: "xfs", off: 0},
+	{magic: EROFS, name: "erofs", flags: MS_RDONLY, off: 1024},
 }
 
 var unknownMagics = []magic{
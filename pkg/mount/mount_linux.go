@@ -11,9 +11,13 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/u-root/u-root/pkg/ulog"
 	"golang.org/x/sys/unix"
 )
 
+// trace traces mounts, with -trace=mount.
+var trace = ulog.Tracer("mount")
+
 // Most commonly used mount flags.
 const (
 	MS_RDONLY   = unix.MS_RDONLY
@@ -88,12 +92,14 @@ func Mount(dev, path, fsType, data string, flags uintptr, opts ...func() error)
 	}
 
 	if err := unix.Mount(dev, path, fsType, flags, data); err != nil {
+		trace.Trace("mount failed", "device", dev, "path", path, "fstype", fsType, "flags", fmt.Sprintf("%#x", flags), "data", data, "err", err)
 		return nil, &os.PathError{
 			Op:   "mount",
 			Path: path,
 			Err:  fmt.Errorf("from device %q (fs type %s, flags %#x): %v", dev, fsType, flags, err),
 		}
 	}
+	trace.Trace("mount", "device", dev, "path", path, "fstype", fsType, "flags", fmt.Sprintf("%#x", flags), "data", data)
 	return &MountPoint{
 		Path:   path,
 		Device: dev,
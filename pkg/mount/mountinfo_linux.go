@@ -0,0 +1,142 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MountInfo is a mounted file system, as in /proc/self/mountinfo.
+type MountInfo struct {
+	ID       int
+	ParentID int
+	// Dev is the device number as MAJOR:MINOR.
+	Dev string
+	// Root is the directory of the file system that is mounted.
+	Root string
+	// Path is where it is mounted.
+	Path    string
+	Options string
+	FSType  string
+	Source  string
+	// SuperOptions are the options of the file system itself.
+	SuperOptions string
+
+	// Parent is the mount that Path is on, or nil for the root.
+	Parent *MountInfo
+	// Children are the mounts on this one, in the order they were made.
+	Children []*MountInfo
+}
+
+// unescape undoes the octal escapes of spaces, tabs, newlines and
+// backslashes in mountinfo.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// ParseMountInfo parses mounts in the format of /proc/self/mountinfo, and
+// links them into a tree.
+func ParseMountInfo(r io.Reader) ([]*MountInfo, error) {
+	var mounts []*MountInfo
+	byID := map[int]*MountInfo{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		// Optional fields end with a "-".
+		sep := -1
+		for i := 6; i < len(f); i++ {
+			if f[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || len(f) < sep+4 {
+			return nil, fmt.Errorf("invalid mountinfo line %q", s.Text())
+		}
+		id, err := strconv.Atoi(f[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid mount ID in %q", s.Text())
+		}
+		parent, err := strconv.Atoi(f[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid parent mount ID in %q", s.Text())
+		}
+		m := &MountInfo{
+			ID:           id,
+			ParentID:     parent,
+			Dev:          f[2],
+			Root:         unescape(f[3]),
+			Path:         unescape(f[4]),
+			Options:      f[5],
+			FSType:       f[sep+1],
+			Source:       unescape(f[sep+2]),
+			SuperOptions: f[sep+3],
+		}
+		mounts = append(mounts, m)
+		byID[id] = m
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for _, m := range mounts {
+		if p, ok := byID[m.ParentID]; ok && p != m {
+			m.Parent = p
+			p.Children = append(p.Children, m)
+		}
+	}
+	return mounts, nil
+}
+
+// ReadMountInfo returns the mounts of this process, from
+// /proc/self/mountinfo.
+func ReadMountInfo() ([]*MountInfo, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMountInfo(f)
+}
+
+// Submounts returns m and every mount below it, in an order they can be
+// unmounted in: each mount comes before the one it is on, and later mounts
+// before earlier ones.
+func (m *MountInfo) Submounts() []*MountInfo {
+	var l []*MountInfo
+	for i := len(m.Children) - 1; i >= 0; i-- {
+		l = append(l, m.Children[i].Submounts()...)
+	}
+	return append(l, m)
+}
+
+// FindMount returns the mount that is visible at path, which is the last
+// one made there.
+func FindMount(mounts []*MountInfo, path string) (*MountInfo, error) {
+	for i := len(mounts) - 1; i >= 0; i-- {
+		if mounts[i].Path == path {
+			return mounts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s: not mounted", path)
+}
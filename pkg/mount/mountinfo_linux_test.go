@@ -0,0 +1,72 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const mountinfo = `1 1 0:2 / / rw - rootfs rootfs rw
+22 1 0:20 / /proc rw,relatime shared:5 - proc proc rw
+25 1 0:6 / /dev rw,relatime - devtmpfs devtmpfs rw,mode=755
+26 25 0:24 / /dev/shm rw - tmpfs tmpfs rw
+30 1 8:1 / /mnt\040disk rw master:1 shared:2 - ext4 /dev/sda1 rw
+31 30 8:2 /sub /mnt\040disk/a rw - vfat /dev/sda2 rw
+32 30 0:30 / /mnt\040disk/b rw - tmpfs none rw
+33 31 0:31 / /mnt\040disk/a rw - tmpfs over rw
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := ParseMountInfo(strings.NewReader(mountinfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 8 {
+		t.Fatalf("got %d mounts, want 8", len(mounts))
+	}
+	m := mounts[4]
+	if m.Path != "/mnt disk" || m.FSType != "ext4" || m.Source != "/dev/sda1" || m.Dev != "8:1" || m.Parent != mounts[0] {
+		t.Errorf("mount 30 = %+v", m)
+	}
+	if mounts[0].Parent != nil {
+		t.Errorf("the root has parent %v", mounts[0].Parent)
+	}
+	if mounts[5].Root != "/sub" {
+		t.Errorf("mount 31 has root %q, want /sub", mounts[5].Root)
+	}
+
+	top, err := FindMount(mounts, "/mnt disk/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top.ID != 33 {
+		t.Errorf("FindMount(/mnt disk/a) = %d, want 33", top.ID)
+	}
+	if _, err := FindMount(mounts, "/nowhere"); err == nil {
+		t.Errorf("FindMount(/nowhere) succeeded")
+	}
+
+	var ids []int
+	for _, s := range m.Submounts() {
+		ids = append(ids, s.ID)
+	}
+	if got, want := fmt.Sprint(ids), "[32 33 31 30]"; got != want {
+		t.Errorf("Submounts = %s, want %s", got, want)
+	}
+}
+
+func TestParseMountInfoErrors(t *testing.T) {
+	for _, s := range []string{
+		"1 1 0:2 / / rw rootfs rootfs rw\n",
+		"x 1 0:2 / / rw - rootfs rootfs rw\n",
+		"1 x 0:2 / / rw - rootfs rootfs rw\n",
+	} {
+		if _, err := ParseMountInfo(strings.NewReader(s)); err == nil {
+			t.Errorf("ParseMountInfo(%q) succeeded", s)
+		}
+	}
+}
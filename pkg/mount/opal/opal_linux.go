@@ -0,0 +1,317 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package opal manages TCG Opal self-encrypting drives, e.g. NVMe or SATA
+// SSDs, with the sed-opal ioctls of Linux.
+//
+// Passwords are given to the drive as they are. Tools that hash them first,
+// like sedutil-cli does with the serial number of the drive, set passwords
+// this package cannot use as they were typed.
+package opal
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxKeyLen is OPAL_KEY_MAX, the size of the password buffer. Passwords are
+// at most 255 bytes, as key_len is a byte.
+const maxKeyLen = 256
+
+const maxPasswordLen = maxKeyLen - 1
+
+// maxRanges is OPAL_MAX_LRS, the number of locking ranges.
+const maxRanges = 9
+
+// The structs below are those of include/uapi/linux/sed-opal.h.
+
+type key struct {
+	lr     uint8
+	keyLen uint8
+	_      [6]byte
+	key    [maxKeyLen]byte
+}
+
+type sessionInfo struct {
+	sum uint32
+	who uint32
+	key key
+}
+
+type lockUnlock struct {
+	session sessionInfo
+	lState  uint32
+	_       [4]byte
+}
+
+type lrAct struct {
+	key    key
+	sum    uint32
+	numLRs uint8
+	lr     [maxRanges]uint8
+	_      [2]byte
+}
+
+type userLRSetup struct {
+	rangeStart  uint64
+	rangeLength uint64
+	rle         uint32
+	wle         uint32
+	session     sessionInfo
+}
+
+type newPW struct {
+	session sessionInfo
+	newPW   sessionInfo
+}
+
+// iow is _IOW('p', nr, size). The direction bits differ between
+// architectures, so they are taken from TUNSETIFF, _IOW('T', 202, int).
+func iow(nr, size uintptr) uintptr {
+	const dir = unix.TUNSETIFF - (4<<16 | 'T'<<8 | 202)
+	return dir | size<<16 | 'p'<<8 | nr
+}
+
+var (
+	ioctlLockUnlock    = iow(221, unsafe.Sizeof(lockUnlock{}))
+	ioctlTakeOwnership = iow(222, unsafe.Sizeof(key{}))
+	ioctlActivateLSP   = iow(223, unsafe.Sizeof(lrAct{}))
+	ioctlSetPW         = iow(224, unsafe.Sizeof(newPW{}))
+	ioctlRevertTPer    = iow(226, unsafe.Sizeof(key{}))
+	ioctlLRSetup       = iow(227, unsafe.Sizeof(userLRSetup{}))
+	ioctlSecureEraseLR = iow(231, unsafe.Sizeof(sessionInfo{}))
+	ioctlPSIDRevert    = iow(232, unsafe.Sizeof(key{}))
+)
+
+// User is an authority of the locking SP.
+type User uint32
+
+// Admin1 is the administrator of the locking SP. Users 1 to 9 are User(1) to
+// User(9).
+const Admin1 User = 0
+
+// LockState is the state of a locking range.
+type LockState uint32
+
+// These are the states of locking ranges.
+const (
+	ReadOnly  LockState = 0x01
+	ReadWrite LockState = 0x02
+	Locked    LockState = 0x04
+)
+
+// StatusError is the status of a failed TCG method, e.g. NotAuthorized for a
+// wrong password.
+type StatusError uint32
+
+// These are the statuses of failed TCG methods.
+const (
+	NotAuthorized       StatusError = 0x01
+	SPBusy              StatusError = 0x03
+	SPFailed            StatusError = 0x04
+	SPDisabled          StatusError = 0x05
+	SPFrozen            StatusError = 0x06
+	NoSessionsAvailable StatusError = 0x07
+	UniquenessConflict  StatusError = 0x08
+	InsufficientSpace   StatusError = 0x09
+	InsufficientRows    StatusError = 0x0a
+	InvalidParameter    StatusError = 0x0c
+	TPerMalfunction     StatusError = 0x0f
+	TransactionFailure  StatusError = 0x10
+	ResponseOverflow    StatusError = 0x11
+	AuthorityLockedOut  StatusError = 0x12
+	Failed              StatusError = 0x3f
+)
+
+var statusStrings = map[StatusError]string{
+	NotAuthorized:       "not authorized",
+	SPBusy:              "SP busy",
+	SPFailed:            "SP failed",
+	SPDisabled:          "SP disabled",
+	SPFrozen:            "SP frozen",
+	NoSessionsAvailable: "no sessions available",
+	UniquenessConflict:  "uniqueness conflict",
+	InsufficientSpace:   "insufficient space",
+	InsufficientRows:    "insufficient rows",
+	InvalidParameter:    "invalid parameter",
+	TPerMalfunction:     "TPer malfunction",
+	TransactionFailure:  "transaction failure",
+	ResponseOverflow:    "response overflow",
+	AuthorityLockedOut:  "authority locked out",
+	Failed:              "failed",
+}
+
+func (e StatusError) Error() string {
+	if s, ok := statusStrings[e]; ok {
+		return s
+	}
+	return fmt.Sprintf("TCG status %#x", uint32(e))
+}
+
+func newKey(password []byte, lr uint8) (key, error) {
+	var k key
+	if len(password) == 0 || len(password) > maxPasswordLen {
+		return k, fmt.Errorf("password is %d bytes, want 1 to %d", len(password), maxPasswordLen)
+	}
+	if lr >= maxRanges {
+		return k, fmt.Errorf("locking range %d is not in [0, %d)", lr, maxRanges)
+	}
+	k.lr = lr
+	k.keyLen = uint8(len(password))
+	copy(k.key[:], password)
+	return k, nil
+}
+
+func newSession(who User, password []byte, lr uint8) (sessionInfo, error) {
+	k, err := newKey(password, lr)
+	if err != nil {
+		return sessionInfo{}, err
+	}
+	return sessionInfo{who: uint32(who), key: k}, nil
+}
+
+// Device is a TCG Opal drive.
+type Device struct {
+	f *os.File
+}
+
+// Open opens the Opal drive of the block device name, e.g. /dev/nvme0n1.
+func Open(name string) (*Device, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Device{f: f}, nil
+}
+
+// Close closes the drive.
+func (d *Device) Close() error {
+	return d.f.Close()
+}
+
+// ioctl issues the sed-opal ioctl req. Linux returns the status of failed
+// TCG methods, which are not errnos, as positive values.
+func (d *Device) ioctl(op string, req uintptr, arg unsafe.Pointer) error {
+	r, _, errno := unix.Syscall(unix.SYS_IOCTL, d.f.Fd(), req, uintptr(arg))
+	var err error
+	switch {
+	case errno != 0:
+		err = errno
+	case r != 0:
+		err = StatusError(r)
+	default:
+		return nil
+	}
+	return &os.PathError{Op: op, Path: d.f.Name(), Err: err}
+}
+
+// TakeOwnership sets the password of the SID authority of a drive that is
+// not owned yet, i.e. new or reverted.
+func (d *Device) TakeOwnership(password []byte) error {
+	k, err := newKey(password, 0)
+	if err != nil {
+		return err
+	}
+	return d.ioctl("take ownership", ioctlTakeOwnership, unsafe.Pointer(&k))
+}
+
+// ActivateLockingSP activates the locking SP with the SID password, which
+// becomes the password of Admin1 too, and the given locking ranges.
+func (d *Device) ActivateLockingSP(password []byte, ranges ...uint8) error {
+	if len(ranges) == 0 || len(ranges) > maxRanges {
+		return fmt.Errorf("%d locking ranges, want 1 to %d", len(ranges), maxRanges)
+	}
+	k, err := newKey(password, 0)
+	if err != nil {
+		return err
+	}
+	a := lrAct{key: k, numLRs: uint8(len(ranges))}
+	copy(a.lr[:], ranges)
+	return d.ioctl("activate locking SP", ioctlActivateLSP, unsafe.Pointer(&a))
+}
+
+// Range is the setup of a locking range. Range 0 is the global range, of the
+// sectors not in other ranges, and its Start and Length must be 0.
+type Range struct {
+	LR     uint8
+	Start  uint64
+	Length uint64
+
+	// ReadLock and WriteLock enable locking reads and writes of the range.
+	ReadLock  bool
+	WriteLock bool
+}
+
+// SetupRange sets up a locking range with the Admin1 password.
+func (d *Device) SetupRange(password []byte, r Range) error {
+	s, err := newSession(Admin1, password, r.LR)
+	if err != nil {
+		return err
+	}
+	a := userLRSetup{rangeStart: r.Start, rangeLength: r.Length, session: s}
+	if r.ReadLock {
+		a.rle = 1
+	}
+	if r.WriteLock {
+		a.wle = 1
+	}
+	return d.ioctl("setup locking range", ioctlLRSetup, unsafe.Pointer(&a))
+}
+
+// LockUnlock sets the state of locking range lr, with the password of who.
+func (d *Device) LockUnlock(who User, password []byte, lr uint8, state LockState) error {
+	s, err := newSession(who, password, lr)
+	if err != nil {
+		return err
+	}
+	a := lockUnlock{session: s, lState: uint32(state)}
+	return d.ioctl("lock unlock", ioctlLockUnlock, unsafe.Pointer(&a))
+}
+
+// SetPassword changes the password of who.
+func (d *Device) SetPassword(who User, old, new []byte) error {
+	s, err := newSession(who, old, 0)
+	if err != nil {
+		return err
+	}
+	n, err := newSession(who, new, 0)
+	if err != nil {
+		return err
+	}
+	a := newPW{session: s, newPW: n}
+	return d.ioctl("set password", ioctlSetPW, unsafe.Pointer(&a))
+}
+
+// EraseRange erases locking range lr, with the Admin1 password, by changing
+// its encryption key.
+func (d *Device) EraseRange(password []byte, lr uint8) error {
+	s, err := newSession(Admin1, password, lr)
+	if err != nil {
+		return err
+	}
+	return d.ioctl("erase locking range", ioctlSecureEraseLR, unsafe.Pointer(&s))
+}
+
+// RevertTPer reverts the drive to its factory state, with the SID password.
+// All data on the drive is lost.
+func (d *Device) RevertTPer(password []byte) error {
+	k, err := newKey(password, 0)
+	if err != nil {
+		return err
+	}
+	return d.ioctl("revert TPer", ioctlRevertTPer, unsafe.Pointer(&k))
+}
+
+// PSIDRevert reverts the drive to its factory state, with the PSID printed on
+// its label, e.g. when its passwords are lost. All data on the drive is lost.
+func (d *Device) PSIDRevert(psid []byte) error {
+	k, err := newKey(psid, 0)
+	if err != nil {
+		return err
+	}
+	return d.ioctl("PSID revert", ioctlPSIDRevert, unsafe.Pointer(&k))
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opal
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// TestSizes makes sure the structs are the size of those of Linux, which are
+// part of the ioctl numbers.
+func TestSizes(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{name: "opal_key", got: unsafe.Sizeof(key{}), want: 264},
+		{name: "opal_session_info", got: unsafe.Sizeof(sessionInfo{}), want: 272},
+		{name: "opal_lock_unlock", got: unsafe.Sizeof(lockUnlock{}), want: 280},
+		{name: "opal_lr_act", got: unsafe.Sizeof(lrAct{}), want: 280},
+		{name: "opal_user_lr_setup", got: unsafe.Sizeof(userLRSetup{}), want: 296},
+		{name: "opal_new_pw", got: unsafe.Sizeof(newPW{}), want: 544},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("sizeof(%s) = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestIoctls(t *testing.T) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skipf("no known ioctl numbers on %s", runtime.GOARCH)
+	}
+	for _, tt := range []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{name: "IOC_OPAL_LOCK_UNLOCK", got: ioctlLockUnlock, want: 0x411870dd},
+		{name: "IOC_OPAL_TAKE_OWNERSHIP", got: ioctlTakeOwnership, want: 0x410870de},
+		{name: "IOC_OPAL_PSID_REVERT_TPR", got: ioctlPSIDRevert, want: 0x410870e8},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %#x, want %#x", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		password string
+		lr       uint8
+		wantErr  bool
+	}{
+		{name: "password", password: "secret", lr: 1},
+		{name: "longest", password: strings.Repeat("x", maxPasswordLen)},
+		{name: "empty", wantErr: true},
+		{name: "too long", password: strings.Repeat("x", maxPasswordLen+1), wantErr: true},
+		{name: "no such range", password: "secret", lr: maxRanges, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := newKey([]byte(tt.password), tt.lr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newKey = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if k.lr != tt.lr || string(k.key[:k.keyLen]) != tt.password {
+				t.Errorf("newKey = range %d, password %q, want %d, %q", k.lr, k.key[:k.keyLen], tt.lr, tt.password)
+			}
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	for _, tt := range []struct {
+		err  StatusError
+		want string
+	}{
+		{err: NotAuthorized, want: "not authorized"},
+		{err: AuthorityLockedOut, want: "authority locked out"},
+		{err: 0x20, want: "TCG status 0x20"},
+	} {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("StatusError(%#x) = %q, want %q", uint32(tt.err), got, tt.want)
+		}
+	}
+}
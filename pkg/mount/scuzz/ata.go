@@ -9,6 +9,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // direction is the transfer direction.
@@ -152,5 +153,19 @@ func unpackIdentify(s statusBlock, d dataBlock, w wordBlock) *Info {
 	info.SecurityStatus = DiskSecurityStatus(binary.LittleEndian.Uint16(d[256:258]))
 
 	info.TrustedComputingSupport = w[48]
+
+	info.SecurityEraseTime = eraseTime(binary.LittleEndian.Uint16(d[178:180]))
+	info.EnhancedSecurityEraseTime = eraseTime(binary.LittleEndian.Uint16(d[180:182]))
 	return &info
 }
+
+// eraseTime decodes the time of a security erase, words 89 and 90 of
+// IDENTIFY DEVICE, in units of 2 minutes. The "extended" format, with bit 15
+// set, has 15 bits for the time rather than 8.
+func eraseTime(w uint16) time.Duration {
+	n := w & 0xff
+	if w&0x8000 != 0 {
+		n = w & 0x7fff
+	}
+	return time.Duration(n) * 2 * time.Minute
+}
@@ -6,6 +6,7 @@ package scuzz
 
 import (
 	"testing"
+	"time"
 )
 
 func TestAtaString(t *testing.T) {
@@ -51,3 +52,21 @@ func TestMustLBA(t *testing.T) {
 		t.Errorf("good mustLBA: got %v, want nil", err)
 	}
 }
+
+func TestEraseTime(t *testing.T) {
+	for _, tt := range []struct {
+		w    uint16
+		want time.Duration
+	}{
+		{w: 0, want: 0},
+		{w: 30, want: time.Hour},
+		{w: 0xff, want: 510 * time.Minute},
+		{w: 0x8000 | 600, want: 20 * time.Hour},
+		// Bits 8 to 14 are reserved in the short format.
+		{w: 0x0100 | 30, want: time.Hour},
+	} {
+		if got := eraseTime(tt.w); got != tt.want {
+			t.Errorf("eraseTime(%#04x) = %v, want %v", tt.w, got, tt.want)
+		}
+	}
+}
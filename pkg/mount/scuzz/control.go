@@ -19,6 +19,7 @@ const (
 	securityLocked       DiskSecurityStatus = 0x4
 	securityFrozen       DiskSecurityStatus = 0x8
 	securityCountExpired DiskSecurityStatus = 0x10
+	securityEnhanced     DiskSecurityStatus = 0x20
 	securityLevelMax     DiskSecurityStatus = 0x100
 )
 
@@ -28,6 +29,7 @@ var securityStatusStrings = map[DiskSecurityStatus]string{
 	securityLocked:       "LOCKED",
 	securityFrozen:       "FROZEN",
 	securityCountExpired: "COUNT EXPIRED",
+	securityEnhanced:     "ENHANCED ERASE SUPPORTED",
 	securityLevelMax:     "LEVEL MAX",
 }
 
@@ -39,6 +41,11 @@ type Info struct {
 	SecurityStatus          DiskSecurityStatus
 	TrustedComputingSupport uint16
 
+	// SecurityEraseTime and EnhancedSecurityEraseTime are the times
+	// the disk estimates an erase takes, or 0 if it does not say.
+	SecurityEraseTime         time.Duration
+	EnhancedSecurityEraseTime time.Duration
+
 	Serial           string
 	Model            string
 	FirmwareRevision string
@@ -60,6 +67,20 @@ type Disk interface {
 
 	// Identify returns drive identity information
 	Identify() (*Info, error)
+
+	// SetPassword sets the admin (true) or user (false) password of the
+	// drive, which enables security with a user password.
+	SetPassword(password string, admin bool) error
+
+	// DisablePassword disables security, given the admin (true) or user
+	// (false) password.
+	DisablePassword(password string, admin bool) error
+
+	// SecurityErase erases all user data on the drive, given the admin
+	// (true) or user (false) password, and disables security. An
+	// enhanced erase also erases reallocated sectors, or changes the
+	// encryption key of self-encrypting drives.
+	SecurityErase(password string, admin, enhanced bool) error
 }
 
 // DiskSecurityStatus is information about how the disk is secured.
@@ -92,6 +113,12 @@ func (d DiskSecurityStatus) SecurityCountExpired() bool {
 	return (d & securityCountExpired) != 0
 }
 
+// SecurityEnhancedEraseSupported returns true if the disk supports enhanced
+// security erase.
+func (d DiskSecurityStatus) SecurityEnhancedEraseSupported() bool {
+	return (d & securityEnhanced) != 0
+}
+
 func (d DiskSecurityStatus) String() string {
 	s := "Security Status: "
 	for v, name := range securityStatusStrings {
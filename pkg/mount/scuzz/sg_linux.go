@@ -5,6 +5,8 @@
 package scuzz
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -255,6 +257,123 @@ func (s *SGDisk) Unlock(password string, admin bool) error {
 	return nil
 }
 
+// maxPasswordLen is the length of ATA security passwords, which are padded
+// with zeroes.
+const maxPasswordLen = 32
+
+// securityPacket returns a packet of an ATA security command with a password.
+// Word 0 of the data has the identifier of the password, with bit 0 set for
+// the master password, and words 1 to 16 the password.
+func (s *SGDisk) securityPacket(cmd Cmd, password string, admin bool) (*packet, error) {
+	if len(password) > maxPasswordLen {
+		return nil, fmt.Errorf("password is %d bytes, longer than %d", len(password), maxPasswordLen)
+	}
+	p := s.newPacket(cmd, _SG_DXFER_TO_DEV, lba48)
+	p.genCommandDataBlock()
+	if admin {
+		p.block[0] = 1
+	}
+	copy(p.block[2:], []byte(password))
+	return p, nil
+}
+
+// masterRevision is the master password revision code set with the master
+// password; 0xfffe is the highest valid code, as hdparm sets it.
+const masterRevision = 0xfffe
+
+func (s *SGDisk) setPasswordPacket(password string, admin bool) (*packet, error) {
+	p, err := s.securityPacket(unix.WIN_SECURITY_SET_PASS, password, admin)
+	if err != nil {
+		return nil, err
+	}
+	// The security level, bit 8 of word 0, is left as high, for the
+	// master password to still unlock the drive.
+	if admin {
+		binary.LittleEndian.PutUint16(p.block[34:], masterRevision)
+	}
+	return p, nil
+}
+
+// SetPassword sets the password of Linux SCSI Generic Disks.
+func (s *SGDisk) SetPassword(password string, admin bool) error {
+	p, err := s.setPasswordPacket(password, admin)
+	if err != nil {
+		return err
+	}
+	return s.operate(p)
+}
+
+// DisablePassword disables security of Linux SCSI Generic Disks.
+func (s *SGDisk) DisablePassword(password string, admin bool) error {
+	p, err := s.securityPacket(unix.WIN_SECURITY_DISABLE, password, admin)
+	if err != nil {
+		return err
+	}
+	return s.operate(p)
+}
+
+func (s *SGDisk) erasePreparePacket() *packet {
+	p := s.newPacket(unix.WIN_SECURITY_ERASE_PREPARE, _SG_DXFER_NONE, lba48)
+	p.dataLen = 0
+	p.nsect = 0
+	p.genCommandDataBlock()
+	return p
+}
+
+func (s *SGDisk) eraseUnitPacket(password string, admin, enhanced bool, timeout time.Duration) (*packet, error) {
+	p, err := s.securityPacket(unix.WIN_SECURITY_ERASE_UNIT, password, admin)
+	if err != nil {
+		return nil, err
+	}
+	if enhanced {
+		p.block[0] |= 2
+	}
+	p.timeout = uint32(timeout.Seconds() * 1000)
+	return p, nil
+}
+
+// DefaultEraseTimeout is the timeout of a security erase of a disk that does
+// not estimate how long it takes.
+const DefaultEraseTimeout = 12 * time.Hour
+
+// eraseTimeout returns the timeout of an erase the disk estimates takes
+// estimate, with some slack, but never less than the timeout of s.
+func (s *SGDisk) eraseTimeout(estimate time.Duration) time.Duration {
+	t := DefaultEraseTimeout
+	if estimate != 0 {
+		t = estimate + estimate/2
+	}
+	if t < s.Timeout {
+		t = s.Timeout
+	}
+	return t
+}
+
+// SecurityErase erases Linux SCSI Generic Disks. It waits for as long as the
+// disk estimates the erase takes, with some slack, or DefaultEraseTimeout.
+func (s *SGDisk) SecurityErase(password string, admin, enhanced bool) error {
+	info, err := s.Identify()
+	if err != nil {
+		return err
+	}
+	if enhanced && !info.SecurityStatus.SecurityEnhancedEraseSupported() {
+		return &os.PathError{Op: "security erase", Path: s.f.Name(), Err: errors.New("enhanced erase is not supported")}
+	}
+	estimate := info.SecurityEraseTime
+	if enhanced {
+		estimate = info.EnhancedSecurityEraseTime
+	}
+	p, err := s.eraseUnitPacket(password, admin, enhanced, s.eraseTimeout(estimate))
+	if err != nil {
+		return err
+	}
+	// ERASE UNIT must immediately follow ERASE PREPARE.
+	if err := s.operate(s.erasePreparePacket()); err != nil {
+		return err
+	}
+	return s.operate(p)
+}
+
 func (s *SGDisk) identifyPacket() *packet {
 	p := s.newPacket(unix.WIN_IDENTIFY, _SG_DXFER_FROM_DEV, 0)
 	p.genCommandDataBlock()
@@ -8,7 +8,9 @@
 package scuzz
 
 import (
+	"strings"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -187,3 +189,95 @@ func TestIdentify(t *testing.T) {
 	p := (&SGDisk{dev: 0x40, Timeout: DefaultTimeout}).identifyPacket()
 	check(t, p, want)
 }
+
+func TestSecurityPackets(t *testing.T) {
+	d := &SGDisk{dev: 0x40, Timeout: DefaultTimeout}
+	for _, tt := range []struct {
+		name        string
+		packet      func() (*packet, error)
+		wantCommand commandDataBlock
+		wantBlock   []byte
+		wantDataLen uint32
+		wantTimeout uint32
+	}{
+		{
+			name:        "set user password",
+			packet:      func() (*packet, error) { return d.setPasswordPacket("pass", false) },
+			wantCommand: commandDataBlock{0x85, 0xb, 0x6, 0, 0, 0, 0x1, 0, 0, 0, 0, 0, 0, 0x40, 0xf1, 0},
+			wantBlock:   []byte{0x00, 0x00, 'p', 'a', 's', 's'},
+			wantDataLen: 512,
+			wantTimeout: 15000,
+		},
+		{
+			name:        "set master password",
+			packet:      func() (*packet, error) { return d.setPasswordPacket("pass", true) },
+			wantCommand: commandDataBlock{0x85, 0xb, 0x6, 0, 0, 0, 0x1, 0, 0, 0, 0, 0, 0, 0x40, 0xf1, 0},
+			wantBlock: []byte{
+				0x01, 0x00, 'p', 'a', 's', 's', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+				0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+				0, 0, 0xfe, 0xff,
+			},
+			wantDataLen: 512,
+			wantTimeout: 15000,
+		},
+		{
+			name:        "disable",
+			packet:      func() (*packet, error) { return d.securityPacket(0xf6, "pass", false) },
+			wantCommand: commandDataBlock{0x85, 0xb, 0x6, 0, 0, 0, 0x1, 0, 0, 0, 0, 0, 0, 0x40, 0xf6, 0},
+			wantBlock:   []byte{0x00, 0x00, 'p', 'a', 's', 's'},
+			wantDataLen: 512,
+			wantTimeout: 15000,
+		},
+		{
+			name:        "erase prepare",
+			packet:      func() (*packet, error) { return d.erasePreparePacket(), nil },
+			wantCommand: commandDataBlock{0x85, 0x7, 0x20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x40, 0xf3, 0},
+			wantTimeout: 15000,
+		},
+		{
+			name: "enhanced erase",
+			packet: func() (*packet, error) {
+				return d.eraseUnitPacket("pass", true, true, d.eraseTimeout(time.Hour))
+			},
+			wantCommand: commandDataBlock{0x85, 0xb, 0x6, 0, 0, 0, 0x1, 0, 0, 0, 0, 0, 0, 0x40, 0xf4, 0},
+			wantBlock:   []byte{0x03, 0x00, 'p', 'a', 's', 's'},
+			wantDataLen: 512,
+			wantTimeout: 90 * 60 * 1000,
+		},
+		{
+			name: "erase without estimate",
+			packet: func() (*packet, error) {
+				return d.eraseUnitPacket("pass", false, false, d.eraseTimeout(0))
+			},
+			wantCommand: commandDataBlock{0x85, 0xb, 0x6, 0, 0, 0, 0x1, 0, 0, 0, 0, 0, 0, 0x40, 0xf4, 0},
+			wantBlock:   []byte{0x00, 0x00, 'p', 'a', 's', 's'},
+			wantDataLen: 512,
+			wantTimeout: 12 * 60 * 60 * 1000,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.packet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.command != tt.wantCommand {
+				t.Errorf("command: got % x, want % x", p.command, tt.wantCommand)
+			}
+			var want dataBlock
+			copy(want[:], tt.wantBlock)
+			if p.block != want {
+				t.Errorf("block: got % x, want % x", p.block[:64], want[:64])
+			}
+			if p.dataLen != tt.wantDataLen {
+				t.Errorf("dataLen: got %d, want %d", p.dataLen, tt.wantDataLen)
+			}
+			if p.timeout != tt.wantTimeout {
+				t.Errorf("timeout: got %d, want %d", p.timeout, tt.wantTimeout)
+			}
+		})
+	}
+
+	if _, err := d.setPasswordPacket(strings.Repeat("x", 33), false); err == nil {
+		t.Errorf("setPasswordPacket with a 33 byte password: got nil, want error")
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Default9PMsize is the largest 9p message size that MountShare asks for by
+// default. The kernel lowers it to what the virtio transport supports. The
+// kernel's own default of 8KiB or 128KiB makes large reads slow.
+const Default9PMsize = 512 << 10
+
+// Share is a directory that a hypervisor shares with the machine.
+type Share struct {
+	// Tag names the share, e.g. as qemu's -virtfs mount_tag= sets it. It
+	// is the device to mount.
+	Tag string
+
+	// FSType is 9p for virtio-9p shares, or virtiofs.
+	FSType string
+}
+
+func (s Share) String() string {
+	return fmt.Sprintf("%s (%s)", s.Tag, s.FSType)
+}
+
+// sysfs is where Shares looks for tags, replaced in tests.
+var sysfs = "/sys"
+
+// Shares returns the virtio-9p and virtio-fs shares of the machine, by tag.
+// The 9pnet_virtio and virtiofs drivers must be loaded for their shares to
+// be found.
+func Shares() ([]Share, error) {
+	var shares []Share
+	for _, g := range []struct {
+		pattern string
+		fsType  string
+	}{
+		{"bus/virtio/drivers/9pnet_virtio/virtio*/mount_tag", "9p"},
+		{"fs/virtiofs/*/tag", "virtiofs"},
+	} {
+		paths, err := filepath.Glob(filepath.Join(sysfs, g.pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			b, err := os.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			// 9p tags end with a NUL, virtio-fs tags with a newline.
+			tag := string(bytes.TrimRight(b, "\x00\n"))
+			if tag != "" {
+				shares = append(shares, Share{Tag: tag, FSType: g.fsType})
+			}
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].Tag < shares[j].Tag })
+	return shares, nil
+}
+
+// FindShare returns the share tagged tag.
+func FindShare(tag string) (Share, error) {
+	shares, err := Shares()
+	if err != nil {
+		return Share{}, err
+	}
+	for _, s := range shares {
+		if s.Tag == tag {
+			return s, nil
+		}
+	}
+	return Share{}, fmt.Errorf("no virtio-9p or virtio-fs share is tagged %q", tag)
+}
+
+// shareData returns the mount data of s. msize is the largest 9p message
+// size, or 0 for Default9PMsize; virtio-fs does not have one.
+func shareData(s Share, msize uint32) string {
+	if s.FSType != "9p" {
+		return ""
+	}
+	if msize == 0 {
+		msize = Default9PMsize
+	}
+	return fmt.Sprintf("trans=virtio,version=9P2000.L,msize=%d", msize)
+}
+
+// MountShare mounts s at path, which is created if it does not exist. msize
+// is the largest 9p message size, or 0 for Default9PMsize.
+func MountShare(s Share, path string, msize uint32, flags uintptr) (*MountPoint, error) {
+	return Mount(s.Tag, path, s.FSType, shareData(s, msize), flags, func() error {
+		return os.MkdirAll(path, 0o755)
+	})
+}
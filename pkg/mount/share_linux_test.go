@@ -0,0 +1,59 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestShares(t *testing.T) {
+	defer func(s string) { sysfs = s }(sysfs)
+	sysfs = t.TempDir()
+	for path, tag := range map[string]string{
+		"bus/virtio/drivers/9pnet_virtio/virtio2/mount_tag": "tmpdir\x00",
+		"bus/virtio/drivers/9pnet_virtio/virtio3/mount_tag": "\x00",
+		"fs/virtiofs/0/tag": "myfs\n",
+		"bus/virtio/drivers/9pnet_virtio/virtio1/mount_tag": "kernel\x00",
+	} {
+		path = filepath.Join(sysfs, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(tag), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	shares, err := Shares()
+	want := []Share{{"kernel", "9p"}, {"myfs", "virtiofs"}, {"tmpdir", "9p"}}
+	if err != nil || !reflect.DeepEqual(shares, want) {
+		t.Errorf("Shares() = %v, %v, want %v", shares, err, want)
+	}
+	if s, err := FindShare("myfs"); err != nil || s != want[1] {
+		t.Errorf("FindShare(myfs) = %v, %v, want %v", s, err, want[1])
+	}
+	if s, err := FindShare("nope"); err == nil {
+		t.Errorf("FindShare(nope) = %v, want an error", s)
+	}
+}
+
+func TestShareData(t *testing.T) {
+	for _, tt := range []struct {
+		s     Share
+		msize uint32
+		want  string
+	}{
+		{Share{"tmpdir", "9p"}, 0, "trans=virtio,version=9P2000.L,msize=524288"},
+		{Share{"tmpdir", "9p"}, 8192, "trans=virtio,version=9P2000.L,msize=8192"},
+		{Share{"myfs", "virtiofs"}, 8192, ""},
+	} {
+		if got := shareData(tt.s, tt.msize); got != tt.want {
+			t.Errorf("shareData(%v, %d) = %q, want %q", tt.s, tt.msize, got, tt.want)
+		}
+	}
+}
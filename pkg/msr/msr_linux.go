@@ -20,7 +20,9 @@ import (
 // CPUs is a slice of the various cpus to read or write the MSR to.
 type CPUs []uint64
 
-func parseCPUs(s string) (CPUs, error) {
+// ParseCPUs parses a list of CPUs, as in /sys/devices/system/cpu/present,
+// e.g. 0-3,7.
+func ParseCPUs(s string) (CPUs, error) {
 	cpus := make(CPUs, 0)
 	// We expect the format to be "0-5,7-8..." or we could also get just one cpu.
 	// We're unlikely to get more than one range since we're looking at present cpus,
@@ -79,7 +81,7 @@ func AllCPUs() (CPUs, error) {
 	if err != nil {
 		return nil, err
 	}
-	return parseCPUs(string(v))
+	return ParseCPUs(string(v))
 }
 
 // GlobCPUs allow the user to specify CPUs using a glob as one would in /dev/cpu
@@ -86,7 +86,7 @@ func TestParseCPUs(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			o, err := parseCPUs(test.input)
+			o, err := ParseCPUs(test.input)
 			if e := testutil.CheckError(err, test.errStr); e != nil {
 				t.Error(e)
 			}
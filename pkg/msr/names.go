@@ -0,0 +1,66 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Names are the names of common MSRs, as the Intel SDM spells them.
+var Names = map[string]MSR{
+	"IA32_TIME_STAMP_COUNTER":    0x10,
+	"IA32_PLATFORM_ID":           0x17,
+	"IA32_APIC_BASE":             0x1b,
+	"IA32_FEATURE_CONTROL":       0x3a,
+	"IA32_BIOS_SIGN_ID":          0x8b,
+	"MSR_PLATFORM_INFO":          0xce,
+	"MSR_PKG_CST_CONFIG_CONTROL": 0xe2,
+	"IA32_MPERF":                 0xe7,
+	"IA32_APERF":                 0xe8,
+	"IA32_MTRRCAP":               0xfe,
+	"MSR_FEATURE_CONFIG":         0x13c,
+	"IA32_PERF_STATUS":           0x198,
+	"IA32_PERF_CTL":              0x199,
+	"IA32_CLOCK_MODULATION":      0x19a,
+	"IA32_THERM_STATUS":          0x19c,
+	"IA32_MISC_ENABLE":           0x1a0,
+	"MSR_TEMPERATURE_TARGET":     0x1a2,
+	"MSR_TURBO_RATIO_LIMIT":      0x1ad,
+	"IA32_ENERGY_PERF_BIAS":      0x1b0,
+	"IA32_PACKAGE_THERM_STATUS":  0x1b1,
+	"MSR_PKG_C3_RESIDENCY":       0x3f8,
+	"MSR_PKG_C6_RESIDENCY":       0x3f9,
+	"MSR_PKG_C7_RESIDENCY":       0x3fa,
+	"MSR_CORE_C3_RESIDENCY":      0x3fc,
+	"MSR_CORE_C6_RESIDENCY":      0x3fd,
+	"MSR_CORE_C7_RESIDENCY":      0x3fe,
+	"MSR_RAPL_POWER_UNIT":        0x606,
+	"MSR_PKG_C2_RESIDENCY":       0x60d,
+	"MSR_PKG_POWER_LIMIT":        0x610,
+	"MSR_PKG_ENERGY_STATUS":      0x611,
+	"MSR_PKG_POWER_INFO":         0x614,
+	"MSR_DRAM_POWER_LIMIT":       0x618,
+	"MSR_CONFIG_TDP_CONTROL":     0x64b,
+	"IA32_PM_ENABLE":             0x770,
+	"IA32_HWP_CAPABILITIES":      0x771,
+	"IA32_HWP_REQUEST":           0x774,
+	"IA32_DEBUG_INTERFACE":       0xc80,
+	"IA32_EFER":                  0xc0000080,
+}
+
+// Lookup returns the MSR with name, which is one of Names, in any case, or
+// an address, e.g. 0x1a0.
+func Lookup(name string) (MSR, error) {
+	if m, ok := Names[strings.ToUpper(name)]; ok {
+		return m, nil
+	}
+	m, err := strconv.ParseUint(name, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not the name or address of an MSR", name)
+	}
+	return MSR(m), nil
+}
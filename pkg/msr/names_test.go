@@ -0,0 +1,28 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package msr
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		want    MSR
+		wantErr bool
+	}{
+		{name: "IA32_MISC_ENABLE", want: 0x1a0},
+		{name: "msr_pkg_power_limit", want: 0x610},
+		{name: "0x3a", want: IntelIA32FeatureControl},
+		{name: "58", want: 0x3a},
+		{name: "0xc0000080", want: Names["IA32_EFER"]},
+		{name: "IA32_NOPE", wantErr: true},
+		{name: "0x100000000", wantErr: true},
+	} {
+		m, err := Lookup(tt.name)
+		if (err != nil) != tt.wantErr || m != tt.want {
+			t.Errorf("Lookup(%q) = %v, %v, want %v, error %v", tt.name, m, err, tt.want, tt.wantErr)
+		}
+	}
+}
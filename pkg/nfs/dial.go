@@ -0,0 +1,34 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package nfs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// dialTCP connects to addr. Like mount(8), it connects from a privileged port
+// if it can, as servers may refuse others.
+func dialTCP(ctx context.Context, addr string) (net.Conn, error) {
+	for port := 1023; port >= 512; port-- {
+		d := net.Dialer{LocalAddr: &net.TCPAddr{Port: port}}
+		c, err := d.DialContext(ctx, "tcp", addr)
+		if errors.Is(err, syscall.EADDRINUSE) {
+			continue
+		}
+		if !errors.Is(err, syscall.EACCES) {
+			return c, err
+		}
+		// Not allowed a privileged port.
+		break
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
@@ -0,0 +1,438 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package nfs implements a read-only NFSv3 client (RFC 1813) over TCP, to
+// fetch files from NFS exports without mounting them.
+//
+// To read a file:
+//
+//	c, err := nfs.Mount(ctx, "10.0.0.1", "/srv/boot")
+//	...
+//	defer c.Close()
+//	f, err := c.Open("images/vmlinuz")
+//	...
+//	r := io.NewSectionReader(f, 0, f.Size())
+package nfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error is an NFS or MOUNT status other than OK.
+type Error uint32
+
+// errNames are the names of common errors.
+var errNames = map[Error]string{
+	1:     "not owner",
+	2:     "no such file or directory",
+	5:     "I/O error",
+	6:     "no such device or address",
+	13:    "permission denied",
+	20:    "not a directory",
+	21:    "is a directory",
+	22:    "invalid argument",
+	63:    "file name too long",
+	70:    "stale file handle",
+	10001: "bad file handle",
+	10004: "operation not supported",
+	10006: "server fault",
+	10008: "try again later",
+}
+
+// Error implements error.
+func (e Error) Error() string {
+	if s, ok := errNames[e]; ok {
+		return "nfs: " + s
+	}
+	return fmt.Sprintf("nfs: error %d", uint32(e))
+}
+
+// Is implements errors.Is, for os.ErrNotExist and os.ErrPermission.
+func (e Error) Is(target error) bool {
+	switch e {
+	case 2:
+		return target == os.ErrNotExist
+	case 1, 13:
+		return target == os.ErrPermission
+	}
+	return false
+}
+
+// ErrNotRegular is returned when opening a file that is not a regular file.
+var ErrNotRegular = errors.New("not a regular file")
+
+// File types of fattr3.
+const (
+	typeRegular = 1
+	typeSymlink = 5
+)
+
+// maxHandle is the largest NFSv3 file handle.
+const maxHandle = 64
+
+// maxRead is the most read in a call.
+const maxRead = 64 << 10
+
+// maxLinks is how many symbolic links Open follows.
+const maxLinks = 8
+
+// attr are the attributes of a file used here.
+type attr struct {
+	typ  uint32
+	size uint64
+}
+
+// fattr decodes fattr3.
+func (d *decoder) fattr() attr {
+	var a attr
+	a.typ = d.uint32()
+	// Mode, links, uid and gid.
+	d.skip(16)
+	a.size = d.uint64()
+	// Space used, device, file system and file IDs, and times.
+	d.skip(56)
+	return a
+}
+
+// postOpAttr decodes post_op_attr.
+func (d *decoder) postOpAttr() *attr {
+	if !d.bool() {
+		return nil
+	}
+	a := d.fattr()
+	return &a
+}
+
+// withContext sets the deadline of c to that of ctx, and closes c if ctx is
+// done before the returned function is called.
+func withContext(ctx context.Context, c net.Conn) func() {
+	if dl, ok := ctx.Deadline(); ok {
+		c.SetDeadline(dl)
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		c.SetDeadline(time.Time{})
+	}
+}
+
+// splitServer splits server, a host with an optional port, e.g. 10.0.0.1 or
+// [fe80::1]:2049.
+func splitServer(server string) (host, port string) {
+	if h, p, err := net.SplitHostPort(server); err == nil {
+		return h, p
+	}
+	return strings.Trim(server, "[]"), ""
+}
+
+// dialMount connects to the MOUNT server on host.
+func dialMount(ctx context.Context, host string) (*rpcConn, error) {
+	port, err := getPort(ctx, host, mountProg, mountVers)
+	if err != nil {
+		return nil, err
+	}
+	return dialRPC(ctx, net.JoinHostPort(host, port))
+}
+
+// Exports returns the directories exported by server, a host with an
+// optional NFS port.
+func Exports(ctx context.Context, server string) ([]string, error) {
+	host, _ := splitServer(server)
+	mnt, err := dialMount(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	defer mnt.Close()
+	defer withContext(ctx, mnt.c)()
+
+	d, err := mnt.call(mountProg, mountVers, mountExport, nil)
+	if err != nil {
+		return nil, err
+	}
+	var exports []string
+	for d.bool() {
+		exports = append(exports, d.string(1024))
+		// Groups allowed to mount it.
+		for d.bool() {
+			d.string(255)
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return exports, nil
+}
+
+// Client reads files of an NFS export.
+type Client struct {
+	mnt    *rpcConn
+	nfs    *rpcConn
+	export string
+	root   []byte
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Mount mounts export of server, a host with an optional NFS port. It finds
+// the MOUNT and, without a port, the NFS server with the portmapper.
+func Mount(ctx context.Context, server, export string) (*Client, error) {
+	host, port := splitServer(server)
+	mnt, err := dialMount(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	stop := withContext(ctx, mnt.c)
+	var e encoder
+	e.string(export)
+	d, err := mnt.call(mountProg, mountVers, mountMnt, e.b)
+	if err != nil {
+		stop()
+		mnt.Close()
+		return nil, err
+	}
+	if stat := Error(d.uint32()); d.err == nil && stat != 0 {
+		stop()
+		mnt.Close()
+		return nil, fmt.Errorf("mount %s: %w", export, stat)
+	}
+	root := d.opaque(maxHandle)
+	stop()
+	if d.err != nil {
+		mnt.Close()
+		return nil, d.err
+	}
+
+	c := &Client{mnt: mnt, export: path.Clean(export), root: root}
+	if port == "" {
+		if port, err = getPort(ctx, host, nfsProg, nfsVers); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if c.nfs, err = dialRPC(ctx, net.JoinHostPort(host, port)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close unmounts the export.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		var e encoder
+		e.string(c.export)
+		c.mnt.call(mountProg, mountVers, mountUmnt, e.b)
+		c.closeErr = c.mnt.Close()
+		if c.nfs != nil {
+			if err := c.nfs.Close(); c.closeErr == nil {
+				c.closeErr = err
+			}
+		}
+	})
+	return c.closeErr
+}
+
+// call calls an NFS procedure, and decodes its status.
+func (c *Client) call(proc uint32, args []byte) (*decoder, Error, error) {
+	d, err := c.nfs.call(nfsProg, nfsVers, proc, args)
+	if err != nil {
+		return nil, 0, err
+	}
+	stat := Error(d.uint32())
+	return d, stat, d.err
+}
+
+// lookup looks up name in the directory dir.
+func (c *Client) lookup(dir []byte, name string) ([]byte, *attr, error) {
+	var e encoder
+	e.opaque(dir)
+	e.string(name)
+	d, stat, err := c.call(nfsLookup, e.b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stat != 0 {
+		return nil, nil, stat
+	}
+	fh := d.opaque(maxHandle)
+	a := d.postOpAttr()
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	if a == nil {
+		if a, err = c.getattr(fh); err != nil {
+			return nil, nil, err
+		}
+	}
+	return fh, a, nil
+}
+
+// getattr returns the attributes of fh.
+func (c *Client) getattr(fh []byte) (*attr, error) {
+	var e encoder
+	e.opaque(fh)
+	d, stat, err := c.call(nfsGetattr, e.b)
+	if err != nil {
+		return nil, err
+	}
+	if stat != 0 {
+		return nil, stat
+	}
+	a := d.fattr()
+	return &a, d.err
+}
+
+// readlink returns the target of the symbolic link fh.
+func (c *Client) readlink(fh []byte) (string, error) {
+	var e encoder
+	e.opaque(fh)
+	d, stat, err := c.call(nfsReadlink, e.b)
+	if err != nil {
+		return "", err
+	}
+	if stat != 0 {
+		return "", stat
+	}
+	d.postOpAttr()
+	target := d.string(4096)
+	return target, d.err
+}
+
+// inExport returns the path in the export of p, a path on the server, and
+// whether p is in the export.
+func (c *Client) inExport(p string) (string, bool) {
+	p = path.Clean(p)
+	switch {
+	case c.export == "/":
+		return p, true
+	case p == c.export:
+		return "/", true
+	case strings.HasPrefix(p, c.export+"/"):
+		return p[len(c.export):], true
+	}
+	return "", false
+}
+
+// Open opens the regular file at name in the export, following symbolic
+// links that stay in the export.
+func (c *Client) Open(name string) (*File, error) {
+	p := path.Clean("/" + name)
+	links := 0
+walk:
+	for {
+		fh, a := c.root, (*attr)(nil)
+		parts := strings.Split(strings.TrimPrefix(p, "/"), "/")
+		for i, part := range parts {
+			if part == "" {
+				continue
+			}
+			var err error
+			fh, a, err = c.lookup(fh, part)
+			if err != nil {
+				return nil, &os.PathError{Op: "open", Path: name, Err: err}
+			}
+			if a.typ != typeSymlink {
+				continue
+			}
+			if links++; links > maxLinks {
+				return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("too many levels of symbolic links")}
+			}
+			target, err := c.readlink(fh)
+			if err != nil {
+				return nil, &os.PathError{Op: "open", Path: name, Err: err}
+			}
+			if path.IsAbs(target) {
+				// Absolute links are paths on the server.
+				rel, ok := c.inExport(target)
+				if !ok {
+					return nil, &os.PathError{Op: "open", Path: name, Err: fmt.Errorf("link to %s is outside the export", target)}
+				}
+				target = rel
+			} else {
+				target = path.Join("/", path.Join(parts[:i]...), target)
+			}
+			p = path.Join(target, path.Join(parts[i+1:]...))
+			continue walk
+		}
+		if a == nil {
+			var err error
+			if a, err = c.getattr(fh); err != nil {
+				return nil, &os.PathError{Op: "open", Path: name, Err: err}
+			}
+		}
+		if a.typ != typeRegular {
+			return nil, &os.PathError{Op: "open", Path: name, Err: ErrNotRegular}
+		}
+		return &File{c: c, fh: fh, size: int64(a.size)}, nil
+	}
+}
+
+// File is a file opened with Client.Open.
+type File struct {
+	c    *Client
+	fh   []byte
+	size int64
+}
+
+// Size returns the size of f when it was opened.
+func (f *File) Size() int64 {
+	return f.size
+}
+
+// read reads at most count bytes at off.
+func (f *File) read(off int64, count int) ([]byte, bool, error) {
+	var e encoder
+	e.opaque(f.fh)
+	e.uint64(uint64(off))
+	e.uint32(uint32(count))
+	d, stat, err := f.c.call(nfsRead, e.b)
+	if err != nil {
+		return nil, false, err
+	}
+	if stat != 0 {
+		return nil, false, stat
+	}
+	d.postOpAttr()
+	d.uint32() // count
+	eof := d.bool()
+	data := d.opaque(maxRead)
+	return data, eof, d.err
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		count := len(p) - n
+		if count > maxRead {
+			count = maxRead
+		}
+		data, eof, err := f.read(off+int64(n), count)
+		n += copy(p[n:], data)
+		if err != nil {
+			return n, err
+		}
+		if n < len(p) && (eof || len(data) == 0) {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
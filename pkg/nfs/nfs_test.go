@@ -0,0 +1,304 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// node is a file served by server.
+type node struct {
+	typ    uint32
+	data   []byte
+	target string
+}
+
+// server serves the portmapper, MOUNT and NFS on one port. File handles are
+// paths on the server.
+type server struct {
+	l       net.Listener
+	exports []string
+	files   map[string]*node
+	// maxRead is the most it returns for a read.
+	maxRead int
+}
+
+func newServer(t *testing.T, files map[string]*node) *server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &server{l: l, exports: []string{"/srv/boot", "/home"}, files: files, maxRead: 32 << 10}
+	for _, e := range s.exports {
+		files[e] = &node{typ: 2}
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	old := portmapPort
+	portmapPort = port
+	t.Cleanup(func() { portmapPort = old })
+	return s
+}
+
+func (s *server) port() uint32 {
+	return uint32(s.l.Addr().(*net.TCPAddr).Port)
+}
+
+func fattr(e *encoder, n *node) {
+	e.uint32(n.typ)
+	for i := 0; i < 4; i++ {
+		// Mode, links, uid and gid.
+		e.uint32(0)
+	}
+	e.uint64(uint64(len(n.data)))
+	for i := 0; i < 7; i++ {
+		// Space used, device, file system and file IDs, and times.
+		e.uint64(0)
+	}
+}
+
+func (s *server) serve(c net.Conn) {
+	defer c.Close()
+	for {
+		call, err := readRecord(c)
+		if err != nil {
+			return
+		}
+		d := &decoder{b: call}
+		xid := d.uint32()
+		d.skip(8) // CALL, RPC version
+		prog, _, proc := d.uint32(), d.uint32(), d.uint32()
+		d.uint32() // credential
+		d.opaque(400)
+		d.uint32() // verifier
+		d.opaque(400)
+
+		var e encoder
+		e.uint32(0) // record mark
+		e.uint32(xid)
+		e.uint32(1) // REPLY
+		e.uint32(0) // MSG_ACCEPTED
+		e.uint32(0) // verifier
+		e.uint32(0)
+		e.uint32(0) // SUCCESS
+		s.reply(&e, prog, proc, d)
+		binary.BigEndian.PutUint32(e.b, 1<<31|uint32(len(e.b)-4))
+		if _, err := c.Write(e.b); err != nil {
+			return
+		}
+	}
+}
+
+func (s *server) reply(e *encoder, prog, proc uint32, d *decoder) {
+	switch {
+	case prog == portmapProg && proc == portmapGetPort:
+		e.uint32(s.port())
+
+	case prog == mountProg && proc == mountMnt:
+		dir := d.string(1024)
+		for _, x := range s.exports {
+			if x == dir {
+				e.uint32(0)
+				e.opaque([]byte(dir))
+				e.uint32(1)
+				e.uint32(1) // AUTH_SYS
+				return
+			}
+		}
+		e.uint32(13)
+
+	case prog == mountProg && proc == mountUmnt:
+
+	case prog == mountProg && proc == mountExport:
+		for _, x := range s.exports {
+			e.uint32(1)
+			e.string(x)
+			e.uint32(1)
+			e.string("10.0.0.0/8")
+			e.uint32(0)
+		}
+		e.uint32(0)
+
+	case prog == nfsProg && proc == nfsGetattr:
+		n, ok := s.files[string(d.opaque(maxHandle))]
+		if !ok {
+			e.uint32(70)
+			return
+		}
+		e.uint32(0)
+		fattr(e, n)
+
+	case prog == nfsProg && proc == nfsLookup:
+		p := path.Join(string(d.opaque(maxHandle)), d.string(255))
+		n, ok := s.files[p]
+		if !ok {
+			e.uint32(2)
+			e.uint32(0)
+			return
+		}
+		e.uint32(0)
+		e.opaque([]byte(p))
+		// Attributes of the file only for regular files, so that
+		// others need a GETATTR.
+		if n.typ == typeRegular {
+			e.uint32(1)
+			fattr(e, n)
+		} else {
+			e.uint32(0)
+		}
+		e.uint32(0)
+
+	case prog == nfsProg && proc == nfsReadlink:
+		n := s.files[string(d.opaque(maxHandle))]
+		e.uint32(0)
+		e.uint32(0)
+		e.string(n.target)
+
+	case prog == nfsProg && proc == nfsRead:
+		n := s.files[string(d.opaque(maxHandle))]
+		off, count := d.uint64(), int(d.uint32())
+		if count > s.maxRead {
+			count = s.maxRead
+		}
+		var data []byte
+		if off < uint64(len(n.data)) {
+			data = n.data[off:]
+		}
+		if len(data) > count {
+			data = data[:count]
+		}
+		e.uint32(0)
+		e.uint32(0)
+		e.uint32(uint32(len(data)))
+		if off+uint64(len(data)) >= uint64(len(n.data)) {
+			e.uint32(1)
+		} else {
+			e.uint32(0)
+		}
+		e.opaque(data)
+
+	default:
+		// PROC_UNAVAIL, over the SUCCESS above.
+		binary.BigEndian.PutUint32(e.b[len(e.b)-4:], 3)
+	}
+}
+
+func testFiles() (map[string]*node, []byte) {
+	kernel := bytes.Repeat([]byte("vmlinuz "), 100<<10/8+3)
+	return map[string]*node{
+		"/srv/boot/images":              {typ: 2},
+		"/srv/boot/images/vmlinuz-5.19": {typ: typeRegular, data: kernel},
+		"/srv/boot/vmlinuz":             {typ: typeSymlink, target: "images/vmlinuz-5.19"},
+		"/srv/boot/images/initrd":       {typ: typeRegular, data: []byte("initramfs")},
+		"/srv/boot/initrd":              {typ: typeSymlink, target: "/srv/boot/images/initrd"},
+		"/srv/boot/passwd":              {typ: typeSymlink, target: "/etc/passwd"},
+		"/srv/boot/loop":                {typ: typeSymlink, target: "loop"},
+		"/srv/boot/images/up":           {typ: typeSymlink, target: "../images/initrd"},
+	}, kernel
+}
+
+func TestExports(t *testing.T) {
+	files, _ := testFiles()
+	newServer(t, files)
+	got, err := Exports(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, " ") != "/srv/boot /home" {
+		t.Errorf("Exports = %v, want [/srv/boot /home]", got)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	files, kernel := testFiles()
+	s := newServer(t, files)
+
+	for _, server := range []string{"127.0.0.1", s.l.Addr().String()} {
+		c, err := Mount(context.Background(), server, "/srv/boot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		for _, tt := range []struct {
+			name string
+			want []byte
+			err  error
+		}{
+			{name: "images/vmlinuz-5.19", want: kernel},
+			{name: "/vmlinuz", want: kernel},
+			{name: "initrd", want: []byte("initramfs")},
+			{name: "images/up", want: []byte("initramfs")},
+			{name: "missing", err: os.ErrNotExist},
+			{name: "images", err: ErrNotRegular},
+			{name: "passwd"},
+			{name: "loop"},
+		} {
+			f, err := c.Open(tt.name)
+			if tt.want == nil {
+				if err == nil || tt.err != nil && !errors.Is(err, tt.err) {
+					t.Errorf("Open(%q) = %v, want error %v", tt.name, err, tt.err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("Open(%q) = %v", tt.name, err)
+				continue
+			}
+			if f.Size() != int64(len(tt.want)) {
+				t.Errorf("%s size = %d, want %d", tt.name, f.Size(), len(tt.want))
+			}
+			got, err := io.ReadAll(io.NewSectionReader(f, 0, f.Size()))
+			if err != nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("%s = %d bytes, %v, want %d bytes", tt.name, len(got), err, len(tt.want))
+			}
+		}
+	}
+}
+
+func TestReadAtEOF(t *testing.T) {
+	files, _ := testFiles()
+	newServer(t, files)
+	c, err := Mount(context.Background(), "127.0.0.1", "/srv/boot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	f, err := c.Open("initrd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 8)
+	if n, err := f.ReadAt(b, 5); n != 4 || err != io.EOF || string(b[:n]) != "amfs" {
+		t.Errorf("ReadAt past the end = %d, %v, %q, want 4, EOF, %q", n, err, b[:n], "amfs")
+	}
+}
+
+func TestMountDenied(t *testing.T) {
+	files, _ := testFiles()
+	newServer(t, files)
+	if _, err := Mount(context.Background(), "127.0.0.1", "/etc"); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("Mount(/etc) = %v, want %v", err, os.ErrPermission)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nfs
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+)
+
+// ONC RPC programs and procedures (RFC 1833, RFC 1813).
+const (
+	portmapProg    = 100000
+	portmapVers    = 2
+	portmapGetPort = 3
+
+	mountProg   = 100005
+	mountVers   = 3
+	mountMnt    = 1
+	mountUmnt   = 3
+	mountExport = 5
+
+	nfsProg     = 100003
+	nfsVers     = 3
+	nfsGetattr  = 1
+	nfsLookup   = 3
+	nfsReadlink = 5
+	nfsRead     = 6
+
+	ipprotoTCP = 6
+)
+
+// maxRecord is the largest RPC reply read.
+const maxRecord = 4 << 20
+
+// acceptStats are why a server did not run a call it accepted.
+var acceptStats = map[uint32]string{
+	1: "program unavailable",
+	2: "program version mismatch",
+	3: "procedure unavailable",
+	4: "garbage arguments",
+	5: "system error",
+}
+
+// rpcConn makes ONC RPC calls (RFC 5531) over TCP, one at a time.
+type rpcConn struct {
+	mu  sync.Mutex
+	c   net.Conn
+	xid uint32
+	// cred is the AUTH_SYS credential of calls.
+	cred []byte
+}
+
+// dialRPC connects to an RPC server at addr.
+func dialRPC(ctx context.Context, addr string) (*rpcConn, error) {
+	c, err := dialTCP(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// AUTH_SYS as root with no other groups.
+	host, _ := os.Hostname()
+	if len(host) > 255 {
+		host = host[:255]
+	}
+	var cred encoder
+	cred.uint32(0)
+	cred.string(host)
+	cred.uint32(0)
+	cred.uint32(0)
+	cred.uint32(0)
+	return &rpcConn{c: c, xid: uint32(os.Getpid()) << 16, cred: cred.b}, nil
+}
+
+// call calls proc of version vers of prog with the encoded args, and returns
+// the decoder of the results.
+func (r *rpcConn) call(prog, vers, proc uint32, args []byte) (*decoder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.xid++
+	var e encoder
+	// Record mark, filled in below.
+	e.uint32(0)
+	e.uint32(r.xid)
+	e.uint32(0) // CALL
+	e.uint32(2) // RPC version
+	e.uint32(prog)
+	e.uint32(vers)
+	e.uint32(proc)
+	e.uint32(1) // AUTH_SYS
+	e.opaque(r.cred)
+	e.uint32(0) // AUTH_NONE verifier
+	e.uint32(0)
+	e.b = append(e.b, args...)
+	binary.BigEndian.PutUint32(e.b, 1<<31|uint32(len(e.b)-4))
+	if _, err := r.c.Write(e.b); err != nil {
+		return nil, err
+	}
+
+	reply, err := readRecord(r.c)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{b: reply}
+	if xid, typ := d.uint32(), d.uint32(); d.err == nil && (xid != r.xid || typ != 1) {
+		return nil, fmt.Errorf("rpc: unexpected reply %d of type %d to call %d", xid, typ, r.xid)
+	}
+	if d.uint32() != 0 {
+		// MSG_DENIED: 0 is RPC_MISMATCH, 1 is AUTH_ERROR.
+		if d.uint32() == 1 {
+			return nil, fmt.Errorf("rpc: program %d: authentication error %d", prog, d.uint32())
+		}
+		return nil, fmt.Errorf("rpc: program %d: RPC version mismatch", prog)
+	}
+	d.uint32() // verifier
+	d.opaque(400)
+	if stat := d.uint32(); stat != 0 {
+		if s, ok := acceptStats[stat]; ok {
+			return nil, fmt.Errorf("rpc: program %d version %d procedure %d: %s", prog, vers, proc, s)
+		}
+		return nil, fmt.Errorf("rpc: program %d version %d procedure %d: error %d", prog, vers, proc, stat)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d, nil
+}
+
+// readRecord reads the fragments of a record.
+func readRecord(r io.Reader) ([]byte, error) {
+	var b []byte
+	for {
+		var mark [4]byte
+		if _, err := io.ReadFull(r, mark[:]); err != nil {
+			return nil, err
+		}
+		m := binary.BigEndian.Uint32(mark[:])
+		n := int(m &^ (1 << 31))
+		if len(b)+n > maxRecord {
+			return nil, fmt.Errorf("rpc: reply larger than %d bytes", maxRecord)
+		}
+		frag := make([]byte, n)
+		if _, err := io.ReadFull(r, frag); err != nil {
+			return nil, err
+		}
+		b = append(b, frag...)
+		if m&(1<<31) != 0 {
+			return b, nil
+		}
+	}
+}
+
+// Close closes the connection.
+func (r *rpcConn) Close() error {
+	return r.c.Close()
+}
+
+// portmapPort is the port of the portmapper.
+var portmapPort = "111"
+
+// getPort returns the TCP port of version vers of prog on host.
+func getPort(ctx context.Context, host string, prog, vers uint32) (string, error) {
+	c, err := dialRPC(ctx, net.JoinHostPort(host, portmapPort))
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	var e encoder
+	e.uint32(prog)
+	e.uint32(vers)
+	e.uint32(ipprotoTCP)
+	e.uint32(0)
+	d, err := c.call(portmapProg, portmapVers, portmapGetPort, e.b)
+	if err != nil {
+		return "", err
+	}
+	port := d.uint32()
+	if d.err != nil {
+		return "", d.err
+	}
+	if port == 0 {
+		return "", fmt.Errorf("rpc: program %d version %d is not registered on %s", prog, vers, host)
+	}
+	return fmt.Sprint(port), nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nfs
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// errShort is returned when a reply ends early.
+var errShort = errors.New("short XDR data")
+
+// encoder encodes XDR (RFC 4506).
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.b = binary.BigEndian.AppendUint32(e.b, v)
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.b = binary.BigEndian.AppendUint64(e.b, v)
+}
+
+// opaque encodes variable length opaque data, padded to 4 bytes.
+func (e *encoder) opaque(b []byte) {
+	e.uint32(uint32(len(b)))
+	e.b = append(e.b, b...)
+	for len(e.b)%4 != 0 {
+		e.b = append(e.b, 0)
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.opaque([]byte(s))
+}
+
+// decoder decodes XDR. After the first error, it decodes zeros, and err is
+// set.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+// next returns the next n bytes.
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = errShort
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) uint32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *decoder) uint64() uint64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (d *decoder) bool() bool {
+	return d.uint32() != 0
+}
+
+// skip skips n bytes.
+func (d *decoder) skip(n int) {
+	d.next(n)
+}
+
+// opaque decodes variable length opaque data of at most max bytes.
+func (d *decoder) opaque(max int) []byte {
+	n := d.uint32()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint32(max) {
+		d.err = errors.New("XDR data too long")
+		return nil
+	}
+	b := d.next(int(n+3) &^ 3)
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b[:n]...)
+}
+
+func (d *decoder) string(max int) string {
+	return string(d.opaque(max))
+}
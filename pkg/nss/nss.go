@@ -0,0 +1,236 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package nss looks up users and groups in /etc/passwd and /etc/group, as
+// the files source of the C library's name service switch does.
+//
+// Commands that map names to ids, or ids to names, use it so that they
+// resolve them the same way, with or without cgo and a C library.
+package nss
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The files that users and groups are looked up in. Tests change them.
+var (
+	PasswdPath = "/etc/passwd"
+	GroupPath  = "/etc/group"
+)
+
+// ErrNotFound is returned when a user or group is not in the files.
+var ErrNotFound = errors.New("not found")
+
+// Passwd is an entry of a passwd file.
+type Passwd struct {
+	Name     string
+	Password string
+	UID      uint32
+	GID      uint32
+	Gecos    string
+	Home     string
+	Shell    string
+}
+
+// String formats p as a line of a passwd file.
+func (p Passwd) String() string {
+	return fmt.Sprintf("%s:%s:%d:%d:%s:%s:%s", p.Name, p.Password, p.UID, p.GID, p.Gecos, p.Home, p.Shell)
+}
+
+// Group is an entry of a group file.
+type Group struct {
+	Name     string
+	Password string
+	GID      uint32
+	Members  []string
+}
+
+// String formats g as a line of a group file.
+func (g Group) String() string {
+	return fmt.Sprintf("%s:%s:%d:%s", g.Name, g.Password, g.GID, strings.Join(g.Members, ","))
+}
+
+// parseID parses a uid or gid.
+func parseID(s string) (uint32, error) {
+	n, err := strconv.ParseUint(s, 10, 32)
+	return uint32(n), err
+}
+
+// fields calls fn with the colon separated fields of each line of r that
+// has n of them. Blank lines and comments are skipped.
+func fields(r io.Reader, n int, fn func([]string)) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := s.Text()
+		if strings.TrimSpace(l) == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		if f := strings.Split(l, ":"); len(f) == n {
+			fn(f)
+		}
+	}
+	return s.Err()
+}
+
+// ParsePasswd parses a passwd file. Lines that are not entries, like those
+// of invalid ids, are ignored.
+func ParsePasswd(r io.Reader) ([]Passwd, error) {
+	var users []Passwd
+	err := fields(r, 7, func(f []string) {
+		uid, err := parseID(f[2])
+		if err != nil || f[0] == "" {
+			return
+		}
+		gid, err := parseID(f[3])
+		if err != nil {
+			return
+		}
+		users = append(users, Passwd{Name: f[0], Password: f[1], UID: uid, GID: gid, Gecos: f[4], Home: f[5], Shell: f[6]})
+	})
+	return users, err
+}
+
+// ParseGroup parses a group file. Lines that are not entries, like those of
+// invalid ids, are ignored.
+func ParseGroup(r io.Reader) ([]Group, error) {
+	var groups []Group
+	err := fields(r, 4, func(f []string) {
+		gid, err := parseID(f[2])
+		if err != nil || f[0] == "" {
+			return
+		}
+		var members []string
+		for _, m := range strings.Split(f[3], ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				members = append(members, m)
+			}
+		}
+		groups = append(groups, Group{Name: f[0], Password: f[1], GID: gid, Members: members})
+	})
+	return groups, err
+}
+
+// Users returns the entries of PasswdPath. A file that does not exist has
+// none.
+func Users() ([]Passwd, error) {
+	f, err := os.Open(PasswdPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePasswd(f)
+}
+
+// Groups returns the entries of GroupPath. A file that does not exist has
+// none.
+func Groups() ([]Group, error) {
+	f, err := os.Open(GroupPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseGroup(f)
+}
+
+// lookupUser returns the first user that match accepts.
+func lookupUser(key string, match func(Passwd) bool) (*Passwd, error) {
+	users, err := Users()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if match(u) {
+			return &u, nil
+		}
+	}
+	return nil, fmt.Errorf("user %s: %w", key, ErrNotFound)
+}
+
+// lookupGroup returns the first group that match accepts.
+func lookupGroup(key string, match func(Group) bool) (*Group, error) {
+	groups, err := Groups()
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if match(g) {
+			return &g, nil
+		}
+	}
+	return nil, fmt.Errorf("group %s: %w", key, ErrNotFound)
+}
+
+// LookupUser returns the user named name.
+func LookupUser(name string) (*Passwd, error) {
+	return lookupUser(name, func(u Passwd) bool { return u.Name == name })
+}
+
+// LookupUID returns the first user of uid.
+func LookupUID(uid uint32) (*Passwd, error) {
+	return lookupUser(fmt.Sprint(uid), func(u Passwd) bool { return u.UID == uid })
+}
+
+// LookupGroup returns the group named name.
+func LookupGroup(name string) (*Group, error) {
+	return lookupGroup(name, func(g Group) bool { return g.Name == name })
+}
+
+// LookupGID returns the first group of gid.
+func LookupGID(gid uint32) (*Group, error) {
+	return lookupGroup(fmt.Sprint(gid), func(g Group) bool { return g.GID == gid })
+}
+
+// UserID returns the uid of s, a user name or a uid. As chown does, a name
+// is looked up first, so that users named by numbers are found.
+func UserID(s string) (uint32, error) {
+	u, err := LookupUser(s)
+	if err == nil {
+		return u.UID, nil
+	}
+	if uid, perr := parseID(s); perr == nil {
+		return uid, nil
+	}
+	return 0, err
+}
+
+// GroupID returns the gid of s, a group name or a gid. A name is looked up
+// first, as in UserID.
+func GroupID(s string) (uint32, error) {
+	g, err := LookupGroup(s)
+	if err == nil {
+		return g.GID, nil
+	}
+	if gid, perr := parseID(s); perr == nil {
+		return gid, nil
+	}
+	return 0, err
+}
+
+// UserName returns the name of uid, or uid as a number if it has none.
+func UserName(uid uint32) string {
+	if u, err := LookupUID(uid); err == nil {
+		return u.Name
+	}
+	return fmt.Sprint(uid)
+}
+
+// GroupName returns the name of gid, or gid as a number if it has none.
+func GroupName(gid uint32) string {
+	if g, err := LookupGID(gid); err == nil {
+		return g.Name
+	}
+	return fmt.Sprint(gid)
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nss
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	passwd = `# users
+root:x:0:0:root:/root:/bin/sh
+daemon:x:1:1::/usr/sbin:/usr/sbin/nologin
+
+broken:x:1000
+bad:x:-1:0::/:/bin/false
+1001:x:1002:1002:numbered:/home/1001:/bin/sh
+alias:x:0:0:another root:/root:/bin/sh
+`
+	group = `root:x:0:
+wheel:x:10:root, admin,
+bad:x:ten:root
+staff:x:50:admin
+`
+)
+
+func setup(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	PasswdPath, GroupPath = filepath.Join(dir, "passwd"), filepath.Join(dir, "group")
+	if err := os.WriteFile(PasswdPath, []byte(passwd), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(GroupPath, []byte(group), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	users, err := ParsePasswd(strings.NewReader(passwd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, u := range users {
+		got = append(got, u.String())
+	}
+	want := []string{
+		"root:x:0:0:root:/root:/bin/sh",
+		"daemon:x:1:1::/usr/sbin:/usr/sbin/nologin",
+		"1001:x:1002:1002:numbered:/home/1001:/bin/sh",
+		"alias:x:0:0:another root:/root:/bin/sh",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePasswd = %q, want %q", got, want)
+	}
+
+	groups, err := ParseGroup(strings.NewReader(group))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantGroups := []Group{
+		{Name: "root", Password: "x", GID: 0},
+		{Name: "wheel", Password: "x", GID: 10, Members: []string{"root", "admin"}},
+		{Name: "staff", Password: "x", GID: 50, Members: []string{"admin"}},
+	}
+	if !reflect.DeepEqual(groups, wantGroups) {
+		t.Errorf("ParseGroup = %+v, want %+v", groups, wantGroups)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	setup(t)
+
+	if u, err := LookupUser("daemon"); err != nil || u.UID != 1 || u.Home != "/usr/sbin" {
+		t.Errorf("LookupUser(daemon) = %+v, %v, want uid 1", u, err)
+	}
+	if u, err := LookupUID(0); err != nil || u.Name != "root" {
+		t.Errorf("LookupUID(0) = %+v, %v, want the first user of uid 0, root", u, err)
+	}
+	if g, err := LookupGroup("staff"); err != nil || g.GID != 50 {
+		t.Errorf("LookupGroup(staff) = %+v, %v, want gid 50", g, err)
+	}
+	if g, err := LookupGID(10); err != nil || g.Name != "wheel" {
+		t.Errorf("LookupGID(10) = %+v, %v, want wheel", g, err)
+	}
+	if _, err := LookupUser("nobody"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LookupUser(nobody) = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := LookupGID(99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LookupGID(99) = %v, want %v", err, ErrNotFound)
+	}
+
+	for _, tt := range []struct {
+		s       string
+		want    uint32
+		wantErr bool
+	}{
+		{s: "root", want: 0},
+		{s: "1001", want: 1002},
+		{s: "42", want: 42},
+		{s: "nobody", wantErr: true},
+		{s: "-1", wantErr: true},
+	} {
+		if got, err := UserID(tt.s); got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("UserID(%q) = %d, %v, want %d, error %t", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if got, err := GroupID("wheel"); err != nil || got != 10 {
+		t.Errorf("GroupID(wheel) = %d, %v, want 10", got, err)
+	}
+	if got, err := GroupID("7"); err != nil || got != 7 {
+		t.Errorf("GroupID(7) = %d, %v, want 7", got, err)
+	}
+	if got := UserName(1002); got != "1001" {
+		t.Errorf("UserName(1002) = %q, want 1001", got)
+	}
+	if got := GroupName(51); got != "51" {
+		t.Errorf("GroupName(51) = %q, want 51", got)
+	}
+
+	PasswdPath = filepath.Join(t.TempDir(), "passwd")
+	if users, err := Users(); err != nil || len(users) != 0 {
+		t.Errorf("Users of a missing file = %v, %v, want none", users, err)
+	}
+}
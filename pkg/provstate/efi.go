@@ -0,0 +1,80 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provstate
+
+import (
+	"errors"
+
+	guid "github.com/google/uuid"
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+// GUID is the vendor GUID of the EFI variable of the state.
+var GUID = guid.MustParse("6b9e7c2e-5d1a-4f0b-9a43-2c1f8e0d7a51")
+
+// DefaultVariable is the EFI variable of the state by default.
+var DefaultVariable = efivarfs.VariableDescriptor{Name: "ProvisioningState", GUID: GUID}
+
+// MaxVariableSize is the size of the largest record EFIVariable stores:
+// firmware often has little room for variables.
+const MaxVariableSize = 4096
+
+// attributes keep the variable across reboots, and readable by the OS.
+const attributes = efivarfs.AttributeNonVolatile | efivarfs.AttributeBootserviceAccess | efivarfs.AttributeRuntimeAccess
+
+// EFIVariable stores the state in an EFI variable, which firmware updates
+// atomically.
+type EFIVariable struct {
+	Vars efivarfs.EFIVar
+
+	// Desc is the variable, DefaultVariable if zero.
+	Desc efivarfs.VariableDescriptor
+}
+
+var _ Backend = &EFIVariable{}
+
+func (e *EFIVariable) desc() efivarfs.VariableDescriptor {
+	if e.Desc.Name == "" {
+		return DefaultVariable
+	}
+	return e.Desc
+}
+
+// Load implements Backend.
+func (e *EFIVariable) Load() (*Record, error) {
+	_, b, err := e.Vars.Get(e.desc())
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return nil, ErrNoState
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r Record
+	if err := r.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Save implements Backend.
+func (e *EFIVariable) Save(r *Record) error {
+	b, err := r.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(b) > MaxVariableSize {
+		return ErrTooLarge
+	}
+	return e.Vars.Set(e.desc(), attributes, b)
+}
+
+// Clear implements Backend.
+func (e *EFIVariable) Clear() error {
+	err := e.Vars.Remove(e.desc())
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provstate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+type fakeVars map[efivarfs.VariableDescriptor][]byte
+
+func (f fakeVars) Get(desc efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	b, ok := f[desc]
+	if !ok {
+		return 0, nil, efivarfs.ErrVarNotExist
+	}
+	return attributes, b, nil
+}
+
+func (f fakeVars) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	f[desc] = data
+	return nil
+}
+
+func (f fakeVars) Remove(desc efivarfs.VariableDescriptor) error {
+	if _, ok := f[desc]; !ok {
+		return efivarfs.ErrVarNotExist
+	}
+	delete(f, desc)
+	return nil
+}
+
+func (f fakeVars) List() ([]efivarfs.VariableDescriptor, error) {
+	return nil, nil
+}
+
+func TestEFIVariable(t *testing.T) {
+	vars := fakeVars{}
+	s := &Store{Backend: &EFIVariable{Vars: vars}}
+
+	r, err := s.Load()
+	if err != nil || r.Generation != 0 || len(r.State) != 0 {
+		t.Fatalf("Load with nothing stored = %+v, %v, want an empty state", r, err)
+	}
+	for i := 1; i <= 3; i++ {
+		r, err := s.Update(func(st State) error {
+			st["stage"] = "install"
+			_, err := st.Inc("attempts")
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Generation != uint64(i) {
+			t.Errorf("generation = %d, want %d", r.Generation, i)
+		}
+	}
+	r, err = s.Load()
+	if err != nil || r.State["attempts"] != "3" || r.State["stage"] != "install" {
+		t.Errorf("Load = %+v, %v, want 3 attempts to install", r, err)
+	}
+	if _, ok := vars[DefaultVariable]; !ok {
+		t.Errorf("the state is not in %v", DefaultVariable)
+	}
+
+	// Failed updates store nothing.
+	if _, err := s.Update(func(st State) error { return errors.New("no") }); err == nil {
+		t.Errorf("Update of a failing func = nil, want error")
+	}
+	if _, err := s.Update(func(st State) error { st["error"] = strings.Repeat("x", MaxVariableSize); return nil }); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Update of too large a state = %v, want %v", err, ErrTooLarge)
+	}
+	if r, _ := s.Load(); r.Generation != 3 {
+		t.Errorf("generation after failed updates = %d, want 3", r.Generation)
+	}
+
+	vars[DefaultVariable] = []byte("garbage")
+	if _, err := s.Load(); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Load of garbage = %v, want %v", err, ErrCorrupt)
+	}
+
+	if err := s.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Clear(); err != nil {
+		t.Errorf("Clear with nothing stored = %v, want nil", err)
+	}
+	if len(vars) != 0 {
+		t.Errorf("variables left after Clear: %v", vars)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provstate
+
+import (
+	"errors"
+	"io"
+)
+
+// Device is the block device of a Partition.
+type Device interface {
+	io.ReaderAt
+	io.WriterAt
+	Sync() error
+}
+
+// Partition stores the state in a reserved partition, e.g. a small GPT
+// partition of its own. The partition is split in two slots, and each update
+// overwrites the slot of the older record, so that the newer one survives if
+// the write is cut short.
+type Partition struct {
+	Dev Device
+
+	// Size is the size of the partition.
+	Size int64
+}
+
+var _ Backend = &Partition{}
+
+// slotSize returns the size of each slot.
+func (p *Partition) slotSize() int64 {
+	return p.Size / 2
+}
+
+// slots returns the records in the slots, nil where there is none.
+func (p *Partition) slots() ([2]*Record, error) {
+	var recs [2]*Record
+	b := make([]byte, p.slotSize())
+	for i := range recs {
+		n, err := p.Dev.ReadAt(b, int64(i)*p.slotSize())
+		if err != nil && err != io.EOF {
+			return recs, err
+		}
+		var r Record
+		err = r.UnmarshalBinary(b[:n])
+		var verr ErrVersion
+		switch {
+		case err == nil:
+			recs[i] = &r
+		case errors.As(err, &verr):
+			// Do not overwrite what a newer version wrote.
+			return recs, err
+		}
+	}
+	return recs, nil
+}
+
+// Load implements Backend.
+func (p *Partition) Load() (*Record, error) {
+	recs, err := p.slots()
+	if err != nil {
+		return nil, err
+	}
+	var latest *Record
+	for _, r := range recs {
+		if r != nil && (latest == nil || r.Generation > latest.Generation) {
+			latest = r
+		}
+	}
+	if latest == nil {
+		return nil, ErrNoState
+	}
+	return latest, nil
+}
+
+// Save implements Backend.
+func (p *Partition) Save(r *Record) error {
+	b, err := r.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if int64(len(b)) > p.slotSize() {
+		return ErrTooLarge
+	}
+	recs, err := p.slots()
+	if err != nil {
+		return err
+	}
+	slot := 0
+	switch {
+	case recs[0] == nil:
+	case recs[1] == nil:
+		slot = 1
+	case recs[1].Generation < recs[0].Generation:
+		slot = 1
+	}
+	if _, err := p.Dev.WriteAt(b, int64(slot)*p.slotSize()); err != nil {
+		return err
+	}
+	return p.Dev.Sync()
+}
+
+// Clear implements Backend.
+func (p *Partition) Clear() error {
+	zero := make([]byte, headerSize)
+	for i := int64(0); i < 2; i++ {
+		if _, err := p.Dev.WriteAt(zero, i*p.slotSize()); err != nil {
+			return err
+		}
+	}
+	return p.Dev.Sync()
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provstate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPartition(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "part"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p := &Partition{Dev: f, Size: 8192}
+	s := &Store{Backend: p}
+
+	for i := 1; i <= 3; i++ {
+		if _, err := s.Update(func(st State) error {
+			st["stage"] = strconv.Itoa(i)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := s.Load()
+	if err != nil || r.Generation != 3 || r.State["stage"] != "3" {
+		t.Fatalf("Load = %+v, %v, want stage 3 of generation 3", r, err)
+	}
+
+	// Generation 3 is in the first slot: tear it, as a power loss while
+	// writing it would, and generation 2 is left.
+	if _, err := f.WriteAt([]byte("torn"), 30); err != nil {
+		t.Fatal(err)
+	}
+	r, err = s.Load()
+	if err != nil || r.Generation != 2 || r.State["stage"] != "2" {
+		t.Fatalf("Load after a torn write = %+v, %v, want stage 2 of generation 2", r, err)
+	}
+	// The next update goes over the torn slot.
+	if r, err := s.Update(func(st State) error { st["stage"] = "4"; return nil }); err != nil || r.Generation != 3 {
+		t.Fatalf("Update = %+v, %v, want generation 3", r, err)
+	}
+	if r, err := (&Store{Backend: &Partition{Dev: f, Size: 8192}}).Load(); err != nil || r.State["stage"] != "4" {
+		t.Errorf("Load = %+v, %v, want stage 4", r, err)
+	}
+
+	if _, err := s.Update(func(st State) error { st["error"] = strings.Repeat("x", 4096); return nil }); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Update of too large a state = %v, want %v", err, ErrTooLarge)
+	}
+
+	if err := s.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Load(); !errors.Is(err, ErrNoState) {
+		t.Errorf("Load after Clear = %v, want %v", err, ErrNoState)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package provstate keeps the state of a provisioning flow, like its stage,
+// attempt counters and last error, in an EFI variable or a reserved
+// partition, so that it survives the reboots of a diskless flow.
+//
+// The state is a set of string keys and values, kept in a record with a
+// format version, a generation that counts updates, and a CRC, so that a
+// torn or foreign write is detected rather than read as state.
+package provstate
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// Version is the version of the record format written.
+const Version = 1
+
+// magic starts every record.
+const magic = "UPST"
+
+// headerSize is the size of the header of a record: the magic, the version,
+// reserved flags, the generation, the length of the payload and the CRC.
+const headerSize = 4 + 2 + 2 + 8 + 4 + 4
+
+var (
+	// ErrNoState is returned when no state was stored yet.
+	ErrNoState = errors.New("no provisioning state stored")
+
+	// ErrCorrupt is returned for records that are not records, or whose
+	// CRC does not match.
+	ErrCorrupt = errors.New("provisioning state is corrupt")
+
+	// ErrTooLarge is returned for state too large for its backend.
+	ErrTooLarge = errors.New("provisioning state is too large")
+)
+
+// ErrVersion is returned for records of a newer format than this package
+// reads.
+type ErrVersion struct {
+	Version uint16
+}
+
+func (e ErrVersion) Error() string {
+	return fmt.Sprintf("provisioning state has format version %d, want at most %d", e.Version, Version)
+}
+
+// State is the provisioning state.
+type State map[string]string
+
+// Keys returns the keys of s, sorted.
+func (s State) Keys() []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Inc increments the counter key, which is 0 if not set, and returns its new
+// value.
+func (s State) Inc(key string) (int, error) {
+	var n int
+	if v, ok := s[key]; ok {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil {
+			return 0, fmt.Errorf("%s is not a counter: %q", key, v)
+		}
+	}
+	n++
+	s[key] = strconv.Itoa(n)
+	return n, nil
+}
+
+// Record is a version of the state.
+type Record struct {
+	// Generation is 1 for the first state stored, and increments with
+	// every update.
+	Generation uint64
+
+	State State
+}
+
+// MarshalBinary encodes r.
+func (r *Record) MarshalBinary() ([]byte, error) {
+	payload, err := json.Marshal(r.State)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, headerSize, headerSize+len(payload))
+	copy(b, magic)
+	binary.LittleEndian.PutUint16(b[4:], Version)
+	binary.LittleEndian.PutUint64(b[8:], r.Generation)
+	binary.LittleEndian.PutUint32(b[16:], uint32(len(payload)))
+	b = append(b, payload...)
+	binary.LittleEndian.PutUint32(b[20:], checksum(b))
+	return b, nil
+}
+
+// UnmarshalBinary decodes a record. Bytes after the record are ignored, e.g.
+// the rest of a partition.
+func (r *Record) UnmarshalBinary(b []byte) error {
+	if len(b) < headerSize || string(b[:4]) != magic {
+		return ErrCorrupt
+	}
+	if v := binary.LittleEndian.Uint16(b[4:]); v > Version {
+		return ErrVersion{Version: v}
+	}
+	n := binary.LittleEndian.Uint32(b[16:])
+	if uint64(len(b)-headerSize) < uint64(n) {
+		return ErrCorrupt
+	}
+	b = b[:headerSize+int(n)]
+	if binary.LittleEndian.Uint32(b[20:]) != checksum(b) {
+		return ErrCorrupt
+	}
+	var s State
+	if err := json.Unmarshal(b[headerSize:], &s); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if s == nil {
+		s = State{}
+	}
+	r.Generation, r.State = binary.LittleEndian.Uint64(b[8:]), s
+	return nil
+}
+
+// checksum returns the CRC of record b, but for its own CRC.
+func checksum(b []byte) uint32 {
+	c := crc32.ChecksumIEEE(b[:20])
+	return crc32.Update(c, crc32.IEEETable, b[headerSize:])
+}
+
+// Backend is where records are stored.
+type Backend interface {
+	// Load returns the latest record stored, or ErrNoState.
+	Load() (*Record, error)
+
+	// Save stores r, which must survive if Save is cut short.
+	Save(r *Record) error
+
+	// Clear removes all records.
+	Clear() error
+}
+
+// Store reads and updates the state in a Backend.
+type Store struct {
+	Backend Backend
+}
+
+// Load returns the state, which is empty if none was stored.
+func (s *Store) Load() (*Record, error) {
+	r, err := s.Backend.Load()
+	if errors.Is(err, ErrNoState) {
+		return &Record{State: State{}}, nil
+	}
+	return r, err
+}
+
+// Update calls f with the state, and stores the state f leaves as the next
+// generation, unless f returns an error.
+func (s *Store) Update(f func(State) error) (*Record, error) {
+	r, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := f(r.State); err != nil {
+		return nil, err
+	}
+	r.Generation++
+	if err := s.Backend.Save(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Clear removes the state.
+func (s *Store) Clear() error {
+	return s.Backend.Clear()
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provstate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	r := &Record{Generation: 7, State: State{"stage": "flash", "attempts": "2", "error": "timeout\nfetching image"}}
+	b, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Record
+	// The rest of a slot follows the record.
+	if err := got.UnmarshalBinary(append(b, make([]byte, 100)...)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, r) {
+		t.Errorf("decoded %+v, want %+v", got, r)
+	}
+
+	for _, tt := range []struct {
+		name string
+		edit func(b []byte) []byte
+		want error
+	}{
+		{name: "truncated", edit: func(b []byte) []byte { return b[:len(b)-1] }, want: ErrCorrupt},
+		{name: "flipped bit", edit: func(b []byte) []byte { b[len(b)-2] ^= 1; return b }, want: ErrCorrupt},
+		{name: "other generation", edit: func(b []byte) []byte { b[8]++; return b }, want: ErrCorrupt},
+		{name: "not a record", edit: func(b []byte) []byte { return make([]byte, len(b)) }, want: ErrCorrupt},
+		{name: "newer version", edit: func(b []byte) []byte { b[4] = Version + 1; return b }, want: ErrVersion{Version: Version + 1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var r Record
+			err := r.UnmarshalBinary(tt.edit(append([]byte(nil), b...)))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("UnmarshalBinary = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInc(t *testing.T) {
+	s := State{"stage": "flash"}
+	for want := 1; want <= 3; want++ {
+		if n, err := s.Inc("attempts"); err != nil || n != want {
+			t.Errorf("Inc = %d, %v, want %d", n, err, want)
+		}
+	}
+	if _, err := s.Inc("stage"); err == nil {
+		t.Errorf("Inc of a string = nil, want error")
+	}
+	if got, want := s.Keys(), []string{"attempts", "stage"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys = %q, want %q", got, want)
+	}
+}
@@ -31,18 +31,30 @@ func New() (*Pty, error) {
 		return nil, err
 	}
 
-	ptm, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	ptm, pts, err := Open()
 	if err != nil {
 		return nil, err
 	}
+	return &Pty{Ptm: ptm, Pts: pts, Sname: pts.Name(), Kid: -1, TTY: tty, Restorer: restorer}, nil
+}
+
+// Open opens a new pseudo terminal, for callers that relay its data
+// themselves, e.g. without a controlling terminal of their own.
+func Open() (ptm, pts *os.File, err error) {
+	ptm, err = os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	if err := ptsunlock(ptm); err != nil {
-		return nil, err
+		ptm.Close()
+		return nil, nil, err
 	}
 
 	sname, err := ptsname(ptm)
 	if err != nil {
-		return nil, err
+		ptm.Close()
+		return nil, nil, err
 	}
 
 	// It can take a non-zero time for a pts to appear, it seems.
@@ -55,11 +67,12 @@ func New() (*Pty, error) {
 		}
 	}
 
-	pts, err := os.OpenFile(sname, os.O_RDWR|syscall.O_NOCTTY, 0)
+	pts, err = os.OpenFile(sname, os.O_RDWR|syscall.O_NOCTTY, 0)
 	if err != nil {
-		return nil, err
+		ptm.Close()
+		return nil, nil, err
 	}
-	return &Pty{Ptm: ptm, Pts: pts, Sname: sname, Kid: -1, TTY: tty, Restorer: restorer}, nil
+	return ptm, pts, nil
 }
 
 func ptsname(f *os.File) (string, error) {
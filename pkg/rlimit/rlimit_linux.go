@@ -0,0 +1,150 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rlimit gets, sets and parses the resource limits of processes, for
+// prlimit and ulimit.
+package rlimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Unlimited is the value of a limit without limit.
+const Unlimited = unix.RLIM_INFINITY
+
+// Resource is a resource processes may be limited in.
+type Resource struct {
+	// Name is the name of the resource, as in RLIMIT_NAME, in lower case.
+	Name string
+
+	// Option is the option of ulimit for the resource.
+	Option byte
+
+	// Description describes the limit.
+	Description string
+
+	// Units are what the limit counts.
+	Units string
+
+	// Scale is the number of units per unit ulimit sets and prints,
+	// e.g. 1024 for the limits in bytes, which ulimit has in KiB.
+	Scale uint64
+
+	// Resource is the RLIMIT_ of the resource.
+	Resource int
+}
+
+// Resources are the resources processes may be limited in, sorted by name.
+var Resources = []Resource{
+	{Name: "as", Option: 'v', Description: "address space limit", Units: "bytes", Scale: 1024, Resource: unix.RLIMIT_AS},
+	{Name: "core", Option: 'c', Description: "max core file size", Units: "bytes", Scale: 1024, Resource: unix.RLIMIT_CORE},
+	{Name: "cpu", Option: 't', Description: "CPU time", Units: "seconds", Scale: 1, Resource: unix.RLIMIT_CPU},
+	{Name: "data", Option: 'd', Description: "max data size", Units: "bytes", Scale: 1024, Resource: unix.RLIMIT_DATA},
+	{Name: "fsize", Option: 'f', Description: "max file size", Units: "bytes", Scale: 1024, Resource: unix.RLIMIT_FSIZE},
+	{Name: "locks", Option: 'x', Description: "max number of file locks held", Units: "locks", Scale: 1, Resource: unix.RLIMIT_LOCKS},
+	{Name: "memlock", Option: 'l', Description: "max locked-in-memory address space", Units: "bytes", Scale: 1024, Resource: unix.RLIMIT_MEMLOCK},
+	{Name: "msgqueue", Option: 'q', Description: "max bytes in POSIX mqueues", Units: "bytes", Scale: 1, Resource: unix.RLIMIT_MSGQUEUE},
+	{Name: "nice", Option: 'e', Description: "max nice prio allowed to raise", Units: "", Scale: 1, Resource: unix.RLIMIT_NICE},
+	{Name: "nofile", Option: 'n', Description: "max number of open files", Units: "files", Scale: 1, Resource: unix.RLIMIT_NOFILE},
+	{Name: "nproc", Option: 'u', Description: "max number of processes", Units: "processes", Scale: 1, Resource: unix.RLIMIT_NPROC},
+	{Name: "rss", Option: 'm', Description: "max resident set size", Units: "bytes", Scale: 1024, Resource: unix.RLIMIT_RSS},
+	{Name: "rtprio", Option: 'r', Description: "max real-time priority", Units: "", Scale: 1, Resource: unix.RLIMIT_RTPRIO},
+	{Name: "rttime", Option: 'R', Description: "timeout for real-time tasks", Units: "microsecs", Scale: 1, Resource: unix.RLIMIT_RTTIME},
+	{Name: "sigpending", Option: 'i', Description: "max number of pending signals", Units: "signals", Scale: 1, Resource: unix.RLIMIT_SIGPENDING},
+	{Name: "stack", Option: 's', Description: "max stack size", Units: "bytes", Scale: 1024, Resource: unix.RLIMIT_STACK},
+}
+
+// ByName returns the resource name.
+func ByName(name string) (Resource, bool) {
+	for _, r := range Resources {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
+
+// ByOption returns the resource of the ulimit option o.
+func ByOption(o byte) (Resource, bool) {
+	for _, r := range Resources {
+		if r.Option == o {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
+
+// Get returns the limit of r of the process pid, where 0 is the calling
+// process.
+func (r Resource) Get(pid int) (unix.Rlimit, error) {
+	var l unix.Rlimit
+	if err := unix.Prlimit(pid, r.Resource, nil, &l); err != nil {
+		return l, fmt.Errorf("getting the %s limit: %w", r.Name, err)
+	}
+	return l, nil
+}
+
+// Set sets the limit of r of the process pid, where 0 is the calling
+// process.
+func (r Resource) Set(pid int, l unix.Rlimit) error {
+	if err := unix.Prlimit(pid, r.Resource, &l, nil); err != nil {
+		return fmt.Errorf("setting the %s limit: %w", r.Name, err)
+	}
+	return nil
+}
+
+// ParseValue parses a limit in units of scale, or unlimited or infinity.
+func ParseValue(s string, scale uint64) (uint64, error) {
+	if s == "unlimited" || s == "infinity" {
+		return Unlimited, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit %q", s)
+	}
+	if v > Unlimited/scale {
+		return 0, fmt.Errorf("limit %q is too large", s)
+	}
+	return v * scale, nil
+}
+
+// FormatValue formats a limit in units of scale, or unlimited.
+func FormatValue(v, scale uint64) string {
+	if v == Unlimited {
+		return "unlimited"
+	}
+	return strconv.FormatUint(v/scale, 10)
+}
+
+// ParseLimits parses limits as prlimit takes them, SOFT:HARD, in which
+// either may be left out to keep it as it is in cur, or one value for both.
+func ParseLimits(s string, cur unix.Rlimit) (unix.Rlimit, error) {
+	soft, hard, both := s, s, true
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		soft, hard, both = s[:i], s[i+1:], false
+	}
+	if both && s == "" {
+		return cur, fmt.Errorf("no limits")
+	}
+	l := cur
+	var err error
+	if soft != "" {
+		if l.Cur, err = ParseValue(soft, 1); err != nil {
+			return cur, err
+		}
+	}
+	if hard != "" {
+		if l.Max, err = ParseValue(hard, 1); err != nil {
+			return cur, err
+		}
+	}
+	if l.Cur > l.Max {
+		return cur, fmt.Errorf("the soft limit %s is more than the hard limit %s", FormatValue(l.Cur, 1), FormatValue(l.Max, 1))
+	}
+	return l, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rlimit
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLookup(t *testing.T) {
+	for _, r := range Resources {
+		if got, ok := ByName(r.Name); !ok || got != r {
+			t.Errorf("ByName(%q) = %v, %v, want %v", r.Name, got, ok, r)
+		}
+		if got, ok := ByOption(r.Option); !ok || got != r {
+			t.Errorf("ByOption(%q) = %v, %v, want %v", r.Option, got, ok, r)
+		}
+	}
+	if _, ok := ByName("cores"); ok {
+		t.Errorf("ByName(cores) succeeded")
+	}
+	if _, ok := ByOption('z'); ok {
+		t.Errorf("ByOption(z) succeeded")
+	}
+}
+
+func TestValue(t *testing.T) {
+	for _, tt := range []struct {
+		in    string
+		scale uint64
+		want  uint64
+		out   string
+	}{
+		{in: "unlimited", scale: 1024, want: Unlimited, out: "unlimited"},
+		{in: "infinity", scale: 1, want: Unlimited, out: "unlimited"},
+		{in: "8192", scale: 1024, want: 8 << 20, out: "8192"},
+		{in: "0", scale: 1, want: 0, out: "0"},
+	} {
+		got, err := ParseValue(tt.in, tt.scale)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseValue(%q, %d) = %d, %v, want %d", tt.in, tt.scale, got, err, tt.want)
+		}
+		if s := FormatValue(got, tt.scale); s != tt.out {
+			t.Errorf("FormatValue(%d, %d) = %q, want %q", got, tt.scale, s, tt.out)
+		}
+	}
+	for _, in := range []string{"", "-1", "lots", "18446744073709551615"} {
+		if got, err := ParseValue(in, 1024); err == nil {
+			t.Errorf("ParseValue(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	cur := unix.Rlimit{Cur: 1024, Max: 4096}
+	for _, tt := range []struct {
+		in   string
+		want unix.Rlimit
+		err  bool
+	}{
+		{in: "2048", want: unix.Rlimit{Cur: 2048, Max: 2048}},
+		{in: "2048:", want: unix.Rlimit{Cur: 2048, Max: 4096}},
+		{in: ":2048", want: unix.Rlimit{Cur: 1024, Max: 2048}},
+		{in: "10:unlimited", want: unix.Rlimit{Cur: 10, Max: Unlimited}},
+		{in: "unlimited", want: unix.Rlimit{Cur: Unlimited, Max: Unlimited}},
+		{in: "8192:", err: true},
+		{in: "", err: true},
+		{in: "a:b", err: true},
+	} {
+		got, err := ParseLimits(tt.in, cur)
+		if (err != nil) != tt.err || (!tt.err && got != tt.want) {
+			t.Errorf("ParseLimits(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	r, _ := ByName("core")
+	l, err := r.Get(0)
+	if err != nil {
+		t.Fatalf("Get = %v", err)
+	}
+	defer r.Set(0, l)
+	want := unix.Rlimit{Cur: 0, Max: l.Max}
+	if err := r.Set(0, want); err != nil {
+		t.Fatalf("Set = %v", err)
+	}
+	if got, err := r.Get(0); err != nil || got != want {
+		t.Errorf("Get after Set = %+v, %v, want %+v", got, err, want)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"time"
+)
+
+func commandCheck(name string) Check {
+	return Check{
+		Name: "command " + name,
+		Run: func(context.Context) (string, error) {
+			return exec.LookPath(name)
+		},
+	}
+}
+
+// networkCheck connects to addr, a HOST:PORT or a URL.
+func networkCheck(addr string, timeout time.Duration) Check {
+	return Check{
+		Name: "network " + addr,
+		Run: func(ctx context.Context) (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			if u, err := url.Parse(addr); err == nil && u.Scheme != "" && u.Host != "" {
+				return fetch(ctx, u.String())
+			}
+			var d net.Dialer
+			c, err := d.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return "", err
+			}
+			defer c.Close()
+			return fmt.Sprintf("connected to %v", c.RemoteAddr()), nil
+		},
+	}
+}
+
+// fetch GETs u. Any response counts, even an error status.
+func fetch(ctx context.Context, u string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Status, nil
+}
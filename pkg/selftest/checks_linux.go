@@ -0,0 +1,128 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package selftest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/libinit"
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+// The following are replaced in tests.
+var (
+	timeFloorFile = libinit.DefaultTimeFloorFile
+	now           = time.Now
+	sysClassBlock = "/sys/class/block"
+)
+
+func tpmCheck() Check {
+	return Check{
+		Name: "tpm",
+		Run: func(context.Context) (string, error) {
+			t, err := tss.NewTPM()
+			if err != nil {
+				return "", err
+			}
+			defer t.Close()
+			info, err := t.Info()
+			if err != nil {
+				return "", err
+			}
+			if _, err := t.ReadPCR(0); err != nil {
+				return "", fmt.Errorf("reading PCR 0: %w", err)
+			}
+			version := "1.2"
+			if info.Version == tss.TPMVersion20 {
+				version = "2.0"
+			}
+			return fmt.Sprintf("TPM %s by %v", version, info.Manufacturer), nil
+		},
+	}
+}
+
+func timeCheck(c *TimeConfig) (Check, error) {
+	parse := func(name, s string) (time.Time, error) {
+		if s == "" {
+			return time.Time{}, nil
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s time: %w", name, err)
+		}
+		return t, nil
+	}
+	notBefore, err := parse("not_before", c.NotBefore)
+	if err != nil {
+		return Check{}, err
+	}
+	notAfter, err := parse("not_after", c.NotAfter)
+	if err != nil {
+		return Check{}, err
+	}
+	return Check{
+		Name: "time",
+		Run: func(context.Context) (string, error) {
+			floor := notBefore
+			if floor.IsZero() {
+				if floor = libinit.TimeFloor(timeFloorFile); floor.IsZero() {
+					return "", fmt.Errorf("no build time in %s to check the clock against", timeFloorFile)
+				}
+			}
+			t := now()
+			if t.Before(floor) {
+				return "", fmt.Errorf("clock %v is before %v", t.UTC().Format(time.RFC3339), floor.UTC().Format(time.RFC3339))
+			}
+			if !notAfter.IsZero() && t.After(notAfter) {
+				return "", fmt.Errorf("clock %v is after %v", t.UTC().Format(time.RFC3339), notAfter.UTC().Format(time.RFC3339))
+			}
+			return t.UTC().Format(time.RFC3339), nil
+		},
+	}, nil
+}
+
+// storageCheck looks for block devices whose names match pattern, and that
+// have media, i.e. a non-zero size.
+func storageCheck(pattern string) Check {
+	return Check{
+		Name: "storage " + pattern,
+		Run: func(context.Context) (string, error) {
+			entries, err := os.ReadDir(sysClassBlock)
+			if err != nil {
+				return "", err
+			}
+			var found []string
+			for _, e := range entries {
+				if ok, err := filepath.Match(pattern, e.Name()); err != nil {
+					return "", err
+				} else if !ok {
+					continue
+				}
+				b, err := os.ReadFile(filepath.Join(sysClassBlock, e.Name(), "size"))
+				if err != nil {
+					continue
+				}
+				// The size is in 512-byte sectors, whatever the
+				// sector size of the device.
+				sectors, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+				if err != nil || sectors == 0 {
+					continue
+				}
+				found = append(found, fmt.Sprintf("%s (%d MiB)", e.Name(), sectors>>11))
+			}
+			if len(found) == 0 {
+				return "", errors.New("no block device with media matches")
+			}
+			return strings.Join(found, ", "), nil
+		},
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package selftest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimeCheck(t *testing.T) {
+	defer func(f string, n func() time.Time) { timeFloorFile, now = f, n }(timeFloorFile, now)
+	timeFloorFile = filepath.Join(t.TempDir(), "timestamp")
+	now = func() time.Time { return time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	for _, tt := range []struct {
+		desc  string
+		c     TimeConfig
+		floor string
+		ok    bool
+	}{
+		{desc: "no floor"},
+		{desc: "after build", floor: "1640995200\n", ok: true},
+		{desc: "before build", floor: "1672531200\n"},
+		{desc: "not before", c: TimeConfig{NotBefore: "2022-01-01T00:00:00Z"}, floor: "1672531200", ok: true},
+		{desc: "not after", c: TimeConfig{NotAfter: "2022-05-01T00:00:00Z"}, floor: "1640995200"},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			os.Remove(timeFloorFile)
+			if tt.floor != "" {
+				if err := os.WriteFile(timeFloorFile, []byte(tt.floor), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c, err := timeCheck(&tt.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			detail, err := c.Run(context.Background())
+			if (err == nil) != tt.ok {
+				t.Errorf("time = %q, %v, want ok %v", detail, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestStorageCheck(t *testing.T) {
+	defer func(s string) { sysClassBlock = s }(sysClassBlock)
+	sysClassBlock = t.TempDir()
+	for name, size := range map[string]string{
+		"sda":   "16777216\n",
+		"sdb":   "0\n",
+		"loop0": "0\n",
+	} {
+		if err := os.Mkdir(filepath.Join(sysClassBlock, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(sysClassBlock, name, "size"), []byte(size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		pattern string
+		detail  string
+	}{
+		{pattern: "sd?", detail: "sda (8192 MiB)"},
+		{pattern: "*", detail: "sda (8192 MiB)"},
+		{pattern: "sdb"},
+		{pattern: "nvme*"},
+		{pattern: "["},
+	} {
+		detail, err := storageCheck(tt.pattern).Run(context.Background())
+		if (err == nil) != (tt.detail != "") || detail != tt.detail {
+			t.Errorf("storage %s = %q, %v, want %q", tt.pattern, detail, err, tt.detail)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package selftest
+
+import (
+	"context"
+	"errors"
+)
+
+var errNotSupported = errors.New("not supported on this system")
+
+func unsupported(name string) Check {
+	return Check{
+		Name: name,
+		Run: func(context.Context) (string, error) {
+			return "", errNotSupported
+		},
+	}
+}
+
+func tpmCheck() Check {
+	return unsupported("tpm")
+}
+
+func timeCheck(*TimeConfig) (Check, error) {
+	return unsupported("time"), nil
+}
+
+func storageCheck(pattern string) Check {
+	return unsupported("storage " + pattern)
+}
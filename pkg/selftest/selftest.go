@@ -0,0 +1,229 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package selftest checks that a booted image works, e.g. as a smoke test
+// after every image build and on first boot in the field, and reports the
+// results as JSON or TAP.
+//
+// The checks are read from a JSON config:
+//
+//	{
+//	  "commands": ["ip", "dhclient", "boot"],
+//	  "network": ["10.0.0.1:22", "https://boot.example.com/"],
+//	  "timeout": "10s",
+//	  "tpm": true,
+//	  "time": {"not_before": "2022-06-01T00:00:00Z"},
+//	  "storage": ["nvme*", "sd?"]
+//	}
+package selftest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultConfigPath is where the config of an image is.
+const DefaultConfigPath = "/etc/selftest.json"
+
+// DefaultTimeout bounds each network check.
+const DefaultTimeout = 5 * time.Second
+
+// DefaultConfig only checks that the clock is sane, which makes sense on
+// every machine.
+var DefaultConfig = Config{Time: &TimeConfig{}}
+
+// Config selects the checks to run.
+type Config struct {
+	// Commands must be found in $PATH, one check each.
+	Commands []string `json:"commands,omitempty"`
+
+	// Network are addresses that must be reachable, one check each. A
+	// HOST:PORT is connected to over TCP. A URL is fetched with HTTP GET,
+	// and any response counts.
+	Network []string `json:"network,omitempty"`
+
+	// Timeout bounds each network check, e.g. "10s". It defaults to
+	// DefaultTimeout.
+	Timeout string `json:"timeout,omitempty"`
+
+	// TPM requires a TPM that answers.
+	TPM bool `json:"tpm,omitempty"`
+
+	// Time, if set, requires a sane clock.
+	Time *TimeConfig `json:"time,omitempty"`
+
+	// Storage are path.Match patterns of block device names, e.g. sda or
+	// nvme*, one check each. Each must match a device that has media.
+	Storage []string `json:"storage,omitempty"`
+}
+
+// TimeConfig bounds the clock.
+type TimeConfig struct {
+	// NotBefore is the earliest sane time, in RFC 3339 format. It
+	// defaults to the build time of the image in /etc/timestamp.
+	NotBefore string `json:"not_before,omitempty"`
+
+	// NotAfter is the latest sane time, in RFC 3339 format, if set.
+	NotAfter string `json:"not_after,omitempty"`
+}
+
+// ParseConfig parses a JSON config.
+func ParseConfig(b []byte) (*Config, error) {
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("invalid selftest config: %w", err)
+	}
+	return &c, nil
+}
+
+// ReadConfig reads the config at path.
+func ReadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(b)
+}
+
+// Check is a single check. Run returns what was found, e.g. the path of a
+// command, or why the check failed.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (string, error)
+}
+
+// Checks returns the checks of c, in the order of its fields.
+func (c *Config) Checks() ([]Check, error) {
+	timeout := DefaultTimeout
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		timeout = d
+	}
+	var checks []Check
+	for _, name := range c.Commands {
+		checks = append(checks, commandCheck(name))
+	}
+	for _, addr := range c.Network {
+		checks = append(checks, networkCheck(addr, timeout))
+	}
+	if c.TPM {
+		checks = append(checks, tpmCheck())
+	}
+	if c.Time != nil {
+		ch, err := timeCheck(c.Time)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, ch)
+	}
+	for _, pattern := range c.Storage {
+		checks = append(checks, storageCheck(pattern))
+	}
+	return checks, nil
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	// Duration is how long the check took.
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Run runs checks one after another.
+func Run(ctx context.Context, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		start := time.Now()
+		detail, err := c.Run(ctx)
+		r := Result{Name: c.Name, OK: err == nil, Detail: detail, Duration: time.Since(start)}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// Passed reports whether all results are OK.
+func Passed(results []Result) bool {
+	for _, r := range results {
+		if !r.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// report is the JSON report.
+type report struct {
+	OK      bool     `json:"ok"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	Results []Result `json:"results"`
+}
+
+// WriteJSON writes results as a JSON report.
+func WriteJSON(w io.Writer, results []Result) error {
+	r := report{OK: Passed(results), Results: results}
+	if r.Results == nil {
+		r.Results = []Result{}
+	}
+	for _, res := range results {
+		if res.OK {
+			r.Passed++
+		} else {
+			r.Failed++
+		}
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
+// WriteTAP writes results in the Test Anything Protocol, version 13. The
+// detail of a check, or its error, is a YAML block below its line.
+func WriteTAP(w io.Writer, results []Result) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(results))
+	for i, r := range results {
+		status := "ok"
+		if !r.OK {
+			status = "not ok"
+		}
+		// # starts a directive in TAP.
+		fmt.Fprintf(&b, "%s %d - %s\n", status, i+1, strings.ReplaceAll(r.Name, "#", "\\#"))
+		if r.Detail == "" && r.Error == "" {
+			continue
+		}
+		b.WriteString("  ---\n")
+		if r.Detail != "" {
+			fmt.Fprintf(&b, "  detail: %s\n", yamlString(r.Detail))
+		}
+		if r.Error != "" {
+			fmt.Fprintf(&b, "  message: %s\n", yamlString(r.Error))
+		}
+		fmt.Fprintf(&b, "  duration_ms: %d\n", r.Duration.Milliseconds())
+		b.WriteString("  ...\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// yamlString quotes s for YAML. A JSON string is a YAML string.
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChecks(t *testing.T) {
+	c, err := ParseConfig([]byte(`{
+		"commands": ["ls", "ip"],
+		"network": ["10.0.0.1:22"],
+		"tpm": true,
+		"time": {},
+		"storage": ["sd?"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks, err := c.Checks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, ch := range checks {
+		names = append(names, ch.Name)
+	}
+	want := []string{"command ls", "command ip", "network 10.0.0.1:22", "tpm", "time", "storage sd?"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Checks() = %q, want %q", names, want)
+	}
+
+	for _, bad := range []string{
+		`{"commands": "ls"}`,
+		`{"timeout": "soon"}`,
+		`{"time": {"not_before": "yesterday"}}`,
+	} {
+		c, err := ParseConfig([]byte(bad))
+		if err == nil {
+			_, err = c.Checks()
+		}
+		if err == nil {
+			t.Errorf("config %s: no error", bad)
+		}
+	}
+}
+
+func TestNetworkCheck(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer s.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+
+	for _, tt := range []struct {
+		addr   string
+		detail string
+		ok     bool
+	}{
+		{addr: s.URL, detail: "404 Not Found", ok: true},
+		{addr: s.Listener.Addr().String(), detail: "connected to " + s.Listener.Addr().String(), ok: true},
+		{addr: closed},
+		{addr: "http://" + closed},
+	} {
+		detail, err := networkCheck(tt.addr, time.Second).Run(context.Background())
+		if (err == nil) != tt.ok || detail != tt.detail {
+			t.Errorf("network %s = %q, %v, want %q, ok %v", tt.addr, detail, err, tt.detail, tt.ok)
+		}
+	}
+}
+
+func TestCommandCheck(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if detail, err := commandCheck("ls").Run(context.Background()); err == nil {
+		t.Errorf("command ls with an empty PATH = %q, want an error", detail)
+	}
+}
+
+var testResults = []Result{
+	{Name: "command ls", OK: true, Detail: "/bbin/ls", Duration: time.Millisecond},
+	{Name: "network #1", OK: false, Error: `dial tcp: "refused"`, Duration: 2 * time.Second},
+	{Name: "tpm", OK: true},
+}
+
+func TestWriteTAP(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteTAP(&b, testResults); err != nil {
+		t.Fatal(err)
+	}
+	want := `TAP version 13
+1..3
+ok 1 - command ls
+  ---
+  detail: "/bbin/ls"
+  duration_ms: 1
+  ...
+not ok 2 - network \#1
+  ---
+  message: "dial tcp: \"refused\""
+  duration_ms: 2000
+  ...
+ok 3 - tpm
+`
+	if b.String() != want {
+		t.Errorf("WriteTAP =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteJSON(&b, testResults); err != nil {
+		t.Fatal(err)
+	}
+	var got report
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := report{OK: false, Passed: 2, Failed: 1, Results: testResults}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteJSON = %+v, want %+v", got, want)
+	}
+
+	b.Reset()
+	if err := WriteJSON(&b, nil); err != nil || !strings.Contains(b.String(), `"results": []`) {
+		t.Errorf("WriteJSON(nil) = %s, %v, want empty results", b.String(), err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	errBad := errors.New("bad")
+	results := Run(context.Background(), []Check{
+		{Name: "good", Run: func(context.Context) (string, error) { return "fine", nil }},
+		{Name: "bad", Run: func(context.Context) (string, error) { return "", errBad }},
+	})
+	if len(results) != 2 || !results[0].OK || results[0].Detail != "fine" || results[1].OK || results[1].Error != "bad" {
+		t.Errorf("Run = %+v", results)
+	}
+	if Passed(results) || !Passed(results[:1]) {
+		t.Errorf("Passed is wrong for %+v", results)
+	}
+}
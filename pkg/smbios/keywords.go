@@ -0,0 +1,109 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownKeyword is returned by Keyword for keywords it does not know.
+var ErrUnknownKeyword = errors.New("unknown keyword")
+
+// keywords are the string keywords of dmidecode -s, they name the fields
+// that identify a machine.
+var keywords = map[string]func(*Info) (string, error){
+	"bios-vendor":       biosField(func(b *BIOSInfo) string { return b.Vendor }),
+	"bios-version":      biosField(func(b *BIOSInfo) string { return b.Version }),
+	"bios-release-date": biosField(func(b *BIOSInfo) string { return b.ReleaseDate }),
+
+	"system-manufacturer":  systemField(func(s *SystemInfo) string { return s.Manufacturer }),
+	"system-product-name":  systemField(func(s *SystemInfo) string { return s.ProductName }),
+	"system-version":       systemField(func(s *SystemInfo) string { return s.Version }),
+	"system-serial-number": systemField(func(s *SystemInfo) string { return s.SerialNumber }),
+	"system-uuid":          systemField(func(s *SystemInfo) string { return s.UUID.String() }),
+	"system-sku-number":    systemField(func(s *SystemInfo) string { return s.SKUNumber }),
+	"system-family":        systemField(func(s *SystemInfo) string { return s.Family }),
+
+	"baseboard-manufacturer":  baseboardField(func(b *BaseboardInfo) string { return b.Manufacturer }),
+	"baseboard-product-name":  baseboardField(func(b *BaseboardInfo) string { return b.Product }),
+	"baseboard-version":       baseboardField(func(b *BaseboardInfo) string { return b.Version }),
+	"baseboard-serial-number": baseboardField(func(b *BaseboardInfo) string { return b.SerialNumber }),
+	"baseboard-asset-tag":     baseboardField(func(b *BaseboardInfo) string { return b.AssetTag }),
+
+	"chassis-manufacturer":  chassisField(func(c *ChassisInfo) string { return c.Manufacturer }),
+	"chassis-type":          chassisField(func(c *ChassisInfo) string { return c.Type.String() }),
+	"chassis-version":       chassisField(func(c *ChassisInfo) string { return c.Version }),
+	"chassis-serial-number": chassisField(func(c *ChassisInfo) string { return c.SerialNumber }),
+	"chassis-asset-tag":     chassisField(func(c *ChassisInfo) string { return c.AssetTagNumber }),
+}
+
+func biosField(f func(*BIOSInfo) string) func(*Info) (string, error) {
+	return func(i *Info) (string, error) {
+		b, err := i.GetBIOSInfo()
+		if err != nil {
+			return "", err
+		}
+		return f(b), nil
+	}
+}
+
+func systemField(f func(*SystemInfo) string) func(*Info) (string, error) {
+	return func(i *Info) (string, error) {
+		s, err := i.GetSystemInfo()
+		if err != nil {
+			return "", err
+		}
+		return f(s), nil
+	}
+}
+
+// baseboardField and chassisField use the first table, like dmidecode -s.
+func baseboardField(f func(*BaseboardInfo) string) func(*Info) (string, error) {
+	return func(i *Info) (string, error) {
+		b, err := i.GetBaseboardInfo()
+		if err != nil {
+			return "", err
+		}
+		if len(b) == 0 {
+			return "", fmt.Errorf("no baseboard information")
+		}
+		return f(b[0]), nil
+	}
+}
+
+func chassisField(f func(*ChassisInfo) string) func(*Info) (string, error) {
+	return func(i *Info) (string, error) {
+		c, err := i.GetChassisInfo()
+		if err != nil {
+			return "", err
+		}
+		if len(c) == 0 {
+			return "", fmt.Errorf("no chassis information")
+		}
+		return f(c[0]), nil
+	}
+}
+
+// IsKeyword returns whether Keyword knows keyword.
+func IsKeyword(keyword string) bool {
+	_, ok := keywords[keyword]
+	return ok
+}
+
+// Keyword returns the value of an identifying field, named by the keywords
+// of dmidecode -s, e.g. system-product-name or baseboard-asset-tag.
+func (i *Info) Keyword(keyword string) (string, error) {
+	f, ok := keywords[keyword]
+	if !ok {
+		return "", fmt.Errorf("%q: %w", keyword, ErrUnknownKeyword)
+	}
+	v, err := f(i)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", keyword, err)
+	}
+	return strings.TrimSpace(v), nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKeyword(t *testing.T) {
+	info, err := setupMockData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		keyword string
+		want    string
+	}{
+		{"bios-version", "N2IET92W (1.70 )"},
+		{"system-manufacturer", "LENOVO"},
+		{"system-product-name", "20N2CTO1WW"},
+		{"system-version", "ThinkPad T490"},
+		{"system-uuid", "0677d5cc-25b1-11b2-a85c-c66e0b64b3d5"},
+		{"baseboard-serial-number", "L1HF93508A3"},
+		{"baseboard-asset-tag", "Not Available"},
+		{"chassis-type", "Notebook"},
+		{"chassis-asset-tag", "No Asset Information"},
+	} {
+		if !IsKeyword(tt.keyword) {
+			t.Errorf("IsKeyword(%q) = false, want true", tt.keyword)
+		}
+		got, err := info.Keyword(tt.keyword)
+		if err != nil || got != tt.want {
+			t.Errorf("Keyword(%q) = %q, %v, want %q", tt.keyword, got, err, tt.want)
+		}
+	}
+	if _, err := info.Keyword("processor-family"); !errors.Is(err, ErrUnknownKeyword) {
+		t.Errorf("Keyword(processor-family) = %v, want %v", err, ErrUnknownKeyword)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package svdir
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// signals are the commands that send a signal to a service, as with runit's
+// sv.
+var signals = map[string]syscall.Signal{
+	"pause":     syscall.SIGSTOP,
+	"cont":      syscall.SIGCONT,
+	"hup":       syscall.SIGHUP,
+	"alarm":     syscall.SIGALRM,
+	"interrupt": syscall.SIGINT,
+	"quit":      syscall.SIGQUIT,
+	"1":         syscall.SIGUSR1,
+	"2":         syscall.SIGUSR2,
+	"term":      syscall.SIGTERM,
+	"kill":      syscall.SIGKILL,
+}
+
+// Commands returns the commands of Do, sorted.
+func Commands() []string {
+	cmds := []string{"up", "down", "once", "restart", "status"}
+	for c := range signals {
+		cmds = append(cmds, c)
+	}
+	sort.Strings(cmds)
+	return cmds
+}
+
+// Do runs the command cmd on the service name, and returns its status
+// afterwards. The commands are those of runit's sv:
+//
+//	status:  nothing
+//	up:      start the service, and restart it when it exits
+//	down:    stop the service, with SIGTERM and then SIGKILL
+//	once:    start the service, but do not restart it
+//	restart: restart the service with SIGTERM, and bring it up
+//
+// and pause, cont, hup, alarm, interrupt, quit, 1, 2, term and kill, which send
+// SIGSTOP, SIGCONT, SIGHUP, SIGALRM, SIGINT, SIGQUIT, SIGUSR1, SIGUSR2, SIGTERM
+// and SIGKILL to the service.
+func (s *Supervisor) Do(cmd, name string) (string, error) {
+	sv, err := s.service(name)
+	if err != nil {
+		return "", err
+	}
+	switch cmd {
+	case "status":
+	case "up":
+		sv.setWant(wantUp)
+	case "down":
+		sv.setWant(wantDown)
+	case "once":
+		sv.setWant(wantOnce)
+	case "restart":
+		sv.setWant(wantUp)
+		// It may not run, in which case it starts.
+		sv.signal(syscall.SIGTERM)
+		sv.signal(syscall.SIGCONT)
+	default:
+		sig, ok := signals[cmd]
+		if !ok {
+			return "", fmt.Errorf("unknown command %q", cmd)
+		}
+		if err := sv.signal(sig); err != nil {
+			return "", err
+		}
+	}
+	if cmd != "status" {
+		// Let the service start or stop, so that the status tells
+		// how it went.
+		time.Sleep(100 * time.Millisecond)
+	}
+	return sv.Status(), nil
+}
+
+// Serve serves control connections on l, until l is closed.
+//
+// A connection sends one command line, the command and the names of the
+// services, and gets a line for each service: its status, or "fail: " and an
+// error. Without names, status is of all services.
+func (s *Supervisor) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go s.serveConn(c)
+	}
+}
+
+func (s *Supervisor) serveConn(c net.Conn) {
+	defer c.Close()
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return
+	}
+	f := strings.Fields(line)
+	if len(f) == 0 {
+		fmt.Fprintf(c, "fail: no command\n")
+		return
+	}
+	cmd, names := f[0], f[1:]
+	if len(names) == 0 && cmd == "status" {
+		names = s.Names()
+	}
+	for _, name := range names {
+		st, err := s.Do(cmd, name)
+		if err != nil {
+			st = "fail: " + err.Error()
+		}
+		fmt.Fprintln(c, st)
+	}
+}
+
+// Control sends the command cmd for the services names to the supervisor
+// listening on the socket, and returns the status lines it replied. It fails
+// if the command failed for any service, with the lines anyway.
+func Control(socket, cmd string, names ...string) ([]string, error) {
+	c, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if _, err := fmt.Fprintln(c, strings.Join(append([]string{cmd}, names...), " ")); err != nil {
+		return nil, err
+	}
+	var (
+		lines  []string
+		failed int
+	)
+	sc := bufio.NewScanner(c)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+		if strings.HasPrefix(sc.Text(), "fail: ") {
+			failed++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return lines, err
+	}
+	if failed > 0 {
+		return lines, fmt.Errorf("%s failed for %d of %d services", cmd, failed, len(lines))
+	}
+	return lines, nil
+}
@@ -0,0 +1,395 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+// Package svdir supervises a directory of services, like runit's runsvdir.
+//
+// Every subdirectory of the directory that has an executable run file is a
+// service. run is started in the service directory, and restarted when it
+// exits, unless the service is down. A service with a down file in its
+// directory is down until it is brought up.
+//
+// The directory is scanned periodically: services that appear are started, and
+// services that disappear are stopped, with SIGTERM and then SIGKILL.
+//
+// Services are controlled over a UNIX socket; see Serve and Control.
+package svdir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ulog"
+)
+
+// Defaults of Supervisor.
+const (
+	// DefaultDir is the default service directory.
+	DefaultDir = "/etc/service"
+
+	// DefaultSocket is the default control socket.
+	DefaultSocket = "/run/runsvdir.sock"
+
+	// DefaultScanInterval is how often the directory is scanned.
+	DefaultScanInterval = 5 * time.Second
+
+	// DefaultStopTimeout is how long a service has to exit after
+	// SIGTERM, before it is killed.
+	DefaultStopTimeout = 7 * time.Second
+
+	// RestartDelay is the least time between two starts of a service,
+	// so that services that exit right away do not spin.
+	RestartDelay = time.Second
+)
+
+// ErrNoService is returned for services that are not in the directory.
+var ErrNoService = errors.New("no such service")
+
+// Supervisor supervises the services in Dir.
+type Supervisor struct {
+	// Dir is the service directory.
+	Dir string
+
+	// ScanInterval is how often Dir is scanned for new and removed
+	// services.
+	ScanInterval time.Duration
+
+	// StopTimeout is how long a service has to exit after SIGTERM when
+	// it is stopped, before it is killed.
+	StopTimeout time.Duration
+
+	// Log is where the starts and exits of services are logged.
+	Log ulog.Logger
+
+	mu       sync.Mutex
+	services map[string]*service
+	wg       sync.WaitGroup
+}
+
+// New returns a Supervisor of the services in dir, with the default
+// settings.
+func New(dir string) *Supervisor {
+	return &Supervisor{
+		Dir:          dir,
+		ScanInterval: DefaultScanInterval,
+		StopTimeout:  DefaultStopTimeout,
+		Log:          ulog.Log,
+	}
+}
+
+// Run supervises the services until ctx is done, and then stops them all.
+func (s *Supervisor) Run(ctx context.Context) error {
+	t := time.NewTicker(s.ScanInterval)
+	defer t.Stop()
+	for {
+		if err := s.Scan(); err != nil {
+			s.Log.Printf("svdir: %v", err)
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			s.mu.Lock()
+			for name, sv := range s.services {
+				close(sv.stop)
+				delete(s.services, name)
+			}
+			s.mu.Unlock()
+			s.wg.Wait()
+			return nil
+		}
+	}
+}
+
+// Scan scans the directory once, starting new services and stopping removed
+// ones.
+func (s *Supervisor) Scan() error {
+	names, err := s.list()
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.services == nil {
+		s.services = make(map[string]*service)
+	}
+	for _, name := range names {
+		found[name] = true
+		if _, ok := s.services[name]; ok {
+			continue
+		}
+		sv := s.newService(name)
+		s.services[name] = sv
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			sv.supervise()
+		}()
+	}
+	for name, sv := range s.services {
+		if !found[name] {
+			s.Log.Printf("svdir: %s: removed, stopping", name)
+			close(sv.stop)
+			delete(s.services, name)
+		}
+	}
+	return nil
+}
+
+// list returns the names of the services in the directory.
+func (s *Supervisor) list() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		// Services may be symlinks to directories, as with runit.
+		fi, err := os.Stat(filepath.Join(s.Dir, e.Name(), "run"))
+		if err != nil || !fi.Mode().IsRegular() || fi.Mode()&0o111 == 0 {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+// service returns the service name.
+func (s *Supervisor) service(name string) (*service, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sv, ok := s.services[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrNoService)
+	}
+	return sv, nil
+}
+
+// Names returns the names of the supervised services, sorted.
+func (s *Supervisor) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// want is the state a service is wanted in.
+type want int
+
+const (
+	wantUp want = iota
+	wantDown
+	// wantOnce is up, but not restarted when it exits.
+	wantOnce
+)
+
+// service is a supervised service. Its state is kept by supervise, and
+// changed through its channels.
+type service struct {
+	name, dir   string
+	log         ulog.Logger
+	stopTimeout time.Duration
+
+	// wake tells supervise that want changed.
+	wake chan struct{}
+	// stop tells supervise to stop the service and return.
+	stop chan struct{}
+
+	mu     sync.Mutex
+	want   want
+	cmd    *exec.Cmd
+	since  time.Time
+	status string
+}
+
+func (s *Supervisor) newService(name string) *service {
+	sv := &service{
+		name:        name,
+		dir:         filepath.Join(s.Dir, name),
+		log:         s.Log,
+		stopTimeout: s.StopTimeout,
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		since:       time.Now(),
+	}
+	if _, err := os.Stat(filepath.Join(sv.dir, "down")); err == nil {
+		sv.want = wantDown
+	}
+	return sv
+}
+
+// setWant changes the state the service is wanted in.
+func (sv *service) setWant(w want) {
+	sv.mu.Lock()
+	sv.want = w
+	sv.mu.Unlock()
+	select {
+	case sv.wake <- struct{}{}:
+	default:
+	}
+}
+
+// signal sends sig to the service, if it runs.
+func (sv *service) signal(sig syscall.Signal) error {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if sv.cmd == nil {
+		return fmt.Errorf("%s: not running", sv.name)
+	}
+	return sv.cmd.Process.Signal(sig)
+}
+
+// start starts run, and returns a channel that gets its exit.
+func (sv *service) start() (chan error, error) {
+	cmd := exec.Command(filepath.Join(sv.dir, "run"))
+	cmd.Dir = sv.dir
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	sv.log.Printf("svdir: %s: started, pid %d", sv.name, cmd.Process.Pid)
+	sv.mu.Lock()
+	sv.cmd, sv.since = cmd, time.Now()
+	sv.mu.Unlock()
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	return exited, nil
+}
+
+// supervise starts and restarts the service as wanted, until it is
+// stopped.
+func (sv *service) supervise() {
+	var (
+		// exited is not nil while the service runs.
+		exited chan error
+		// restart is not nil while a start waits for RestartDelay.
+		restart <-chan time.Time
+		// kill is not nil while the service is being stopped.
+		kill       <-chan time.Time
+		lastStart  time.Time
+		stopping   bool
+		terminated bool
+		stop       = sv.stop
+	)
+	for {
+		sv.mu.Lock()
+		w := sv.want
+		sv.mu.Unlock()
+		switch {
+		case stopping && exited == nil:
+			return
+		case exited == nil && w != wantDown && restart == nil && !stopping:
+			if d := time.Until(lastStart.Add(RestartDelay)); d > 0 {
+				restart = time.After(d)
+				break
+			}
+			lastStart = time.Now()
+			var err error
+			if exited, err = sv.start(); err != nil {
+				sv.log.Printf("svdir: %s: %v", sv.name, err)
+				sv.setStatus(err.Error())
+				restart = time.After(RestartDelay)
+			}
+		case exited != nil && (w == wantDown || stopping) && !terminated:
+			sv.signal(syscall.SIGTERM)
+			// Stopped services would not get SIGTERM until they
+			// are continued.
+			sv.signal(syscall.SIGCONT)
+			terminated = true
+			kill = time.After(sv.stopTimeout)
+		}
+
+		select {
+		case <-sv.wake:
+		case <-stop:
+			stopping, stop = true, nil
+		case <-restart:
+			restart = nil
+		case <-kill:
+			sv.log.Printf("svdir: %s: did not exit, killing it", sv.name)
+			sv.signal(syscall.SIGKILL)
+			kill = nil
+		case err := <-exited:
+			status := exitStatus(err)
+			sv.log.Printf("svdir: %s: %s", sv.name, status)
+			sv.mu.Lock()
+			sv.cmd, sv.since, sv.status = nil, time.Now(), status
+			if sv.want == wantOnce {
+				sv.want = wantDown
+			}
+			sv.mu.Unlock()
+			exited, kill, terminated = nil, nil, false
+		}
+	}
+}
+
+// exitStatus describes how run exited, e.g. "exit 1" or "signal: killed".
+func exitStatus(err error) string {
+	var ee *exec.ExitError
+	switch {
+	case err == nil:
+		return "exit 0"
+	case errors.As(err, &ee) && ee.Exited():
+		return fmt.Sprintf("exit %d", ee.ExitCode())
+	}
+	return err.Error()
+}
+
+func (sv *service) setStatus(s string) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	sv.status = s
+}
+
+// Status returns the status of the service, like runit's sv, e.g.
+//
+//	run: getty: (pid 123) 45s
+//	down: sshd: 3s, exit 1, normally up
+func (sv *service) Status() string {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	d := time.Since(sv.since).Truncate(time.Second)
+	var b strings.Builder
+	if sv.cmd != nil {
+		fmt.Fprintf(&b, "run: %s: (pid %d) %s", sv.name, sv.cmd.Process.Pid, d)
+	} else {
+		fmt.Fprintf(&b, "down: %s: %s", sv.name, d)
+		if sv.status != "" {
+			fmt.Fprintf(&b, ", %s", sv.status)
+		}
+	}
+	_, err := os.Stat(filepath.Join(sv.dir, "down"))
+	normallyDown := err == nil
+	switch {
+	case sv.cmd != nil && sv.want == wantDown:
+		b.WriteString(", want down")
+	case sv.cmd == nil && sv.want != wantDown:
+		b.WriteString(", want up")
+	case sv.cmd != nil && normallyDown:
+		b.WriteString(", normally down")
+	case sv.cmd == nil && !normallyDown:
+		b.WriteString(", normally up")
+	}
+	return b.String()
+}
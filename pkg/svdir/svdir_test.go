@@ -0,0 +1,174 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9 && !windows
+// +build !plan9,!windows
+
+package svdir
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ulog/ulogtest"
+)
+
+// addService adds a service running script to dir.
+func addService(t *testing.T, dir, name, script string, down bool) {
+	t.Helper()
+	d := filepath.Join(dir, name)
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if down {
+		if err := os.WriteFile(filepath.Join(d, "down"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(d, "run"), []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor waits for the status of name to start with prefix.
+func waitFor(t *testing.T, s *Supervisor, name, prefix string) string {
+	t.Helper()
+	var st string
+	for i := 0; i < 100; i++ {
+		var err error
+		if st, err = s.Do("status", name); err == nil && strings.HasPrefix(st, prefix) {
+			return st
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("status of %s = %q, want %s...", name, st, prefix)
+	return ""
+}
+
+func TestSupervisor(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	dir := t.TempDir()
+	addService(t, dir, "sleeper", "exec sleep 100", false)
+	addService(t, dir, "counter", "echo x >> count; exec sleep 100", false)
+	addService(t, dir, "lazy", "exec sleep 100", true)
+	// Not services.
+	addService(t, dir, ".hidden", "exec sleep 100", false)
+	if err := os.MkdirAll(filepath.Join(dir, "norun"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(dir)
+	s.ScanInterval = 50 * time.Millisecond
+	s.StopTimeout = time.Second
+	s.Log = &ulogtest.Logger{TB: t}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	sock := filepath.Join(t.TempDir(), "sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go s.Serve(l)
+
+	waitFor(t, s, "sleeper", "run: sleeper: (pid ")
+	waitFor(t, s, "counter", "run: counter: (pid ")
+	if got, want := strings.Join(s.Names(), " "), "counter lazy sleeper"; got != want {
+		t.Errorf("services = %q, want %q", got, want)
+	}
+	if st := waitFor(t, s, "lazy", "down: lazy: "); strings.Contains(st, "normally up") {
+		t.Errorf("status of lazy = %q, want it normally down", st)
+	}
+
+	// Control over the socket.
+	lines, err := Control(sock, "up", "lazy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "run: lazy: ") && !strings.HasSuffix(lines[0], ", want up") {
+		t.Errorf("up lazy = %q, want it running", lines)
+	}
+	waitFor(t, s, "lazy", "run: lazy: ")
+
+	if _, err := Control(sock, "down", "sleeper"); err != nil {
+		t.Fatal(err)
+	}
+	if st := waitFor(t, s, "sleeper", "down: sleeper: "); !strings.Contains(st, "signal: terminated") {
+		t.Errorf("status of sleeper = %q, want it terminated", st)
+	}
+
+	// A killed service is restarted.
+	if _, err := s.Do("kill", "counter"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if b, _ := os.ReadFile(filepath.Join(dir, "counter", "count")); string(b) == "x\nx\n" {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "counter", "count")); string(b) != "x\nx\n" {
+		t.Errorf("counter ran %d times, want 2", strings.Count(string(b), "x"))
+	}
+
+	lines, err = Control(sock, "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 {
+		t.Errorf("status = %q, want all 3 services", lines)
+	}
+	if _, err := Control(sock, "up", "nope"); err == nil {
+		t.Errorf("up nope did not fail")
+	}
+	if _, err := s.Do("up", "nope"); !errors.Is(err, ErrNoService) {
+		t.Errorf("up nope = %v, want %v", err, ErrNoService)
+	}
+	if _, err := s.Do("bogus", "lazy"); err == nil {
+		t.Errorf("bogus command did not fail")
+	}
+
+	// Removed services are stopped.
+	if err := os.RemoveAll(filepath.Join(dir, "lazy")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && len(s.Names()) != 2; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got, want := strings.Join(s.Names(), " "), "counter sleeper"; got != want {
+		t.Errorf("services after removing lazy = %q, want %q", got, want)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	dir := t.TempDir()
+	addService(t, dir, "fail", "exit 3", false)
+	sv := New(dir).newService("fail")
+	exited, err := sv.start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := exitStatus(<-exited), "exit 3"; got != want {
+		t.Errorf("exit status = %q, want %q", got, want)
+	}
+}
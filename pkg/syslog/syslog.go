@@ -0,0 +1,366 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package syslog formats log messages as syslog records and forwards them to
+// remote collectors.
+//
+// Unlike log/syslog, it does not depend on a local syslog daemon, buffers
+// messages while the collector is unreachable, and can also forward to an
+// HTTP endpoint.
+package syslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Facility and severity are combined into a priority as Facility*8+Severity.
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+var severities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "error": 3,
+	"warning": 4, "warn": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// ParsePriority parses a facility.severity pair like "local0.info".
+//
+// A lone severity uses the user facility.
+func ParsePriority(s string) (int, error) {
+	fac, sev := "user", s
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		fac, sev = s[:i], s[i+1:]
+	}
+	f, ok := facilities[fac]
+	if !ok {
+		return 0, fmt.Errorf("unknown facility %q", fac)
+	}
+	v, ok := severities[sev]
+	if !ok {
+		return 0, fmt.Errorf("unknown severity %q", sev)
+	}
+	return f*8 + v, nil
+}
+
+// Message is a single log record.
+type Message struct {
+	Priority int       `json:"priority"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname,omitempty"`
+	Tag      string    `json:"tag,omitempty"`
+	Text     string    `json:"message"`
+}
+
+func nilvalue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// RFC5424 formats m as an RFC 5424 syslog record.
+func (m Message) RFC5424() string {
+	return fmt.Sprintf("<%d>1 %s %s %s - - - %s", m.Priority,
+		m.Time.UTC().Format(time.RFC3339Nano), nilvalue(m.Hostname), nilvalue(m.Tag), m.Text)
+}
+
+// RFC3164 formats m as a traditional BSD syslog record.
+func (m Message) RFC3164() string {
+	tag := m.Tag
+	if tag != "" {
+		tag += ": "
+	}
+	return fmt.Sprintf("<%d>%s %s %s%s", m.Priority, m.Time.Format(time.Stamp), nilvalue(m.Hostname), tag, m.Text)
+}
+
+// ErrNotKmsg is returned by ParseKmsg for malformed records.
+var ErrNotKmsg = errors.New("not a /dev/kmsg record")
+
+// ParseKmsg parses a record read from /dev/kmsg.
+//
+// Records look like "6,339,5140900,-;message". The timestamp is relative to
+// boot, so it is converted using boot, the time the system booted.
+func ParseKmsg(rec string, boot time.Time) (Message, error) {
+	i := strings.IndexByte(rec, ';')
+	if i < 0 {
+		return Message{}, ErrNotKmsg
+	}
+	f := strings.Split(rec[:i], ",")
+	if len(f) < 3 {
+		return Message{}, ErrNotKmsg
+	}
+	pri, err := strconv.Atoi(f[0])
+	if err != nil {
+		return Message{}, ErrNotKmsg
+	}
+	usec, err := strconv.ParseInt(f[2], 10, 64)
+	if err != nil {
+		return Message{}, ErrNotKmsg
+	}
+	text := rec[i+1:]
+	// Continuation lines carry key=value metadata; drop them.
+	if j := strings.IndexByte(text, '\n'); j >= 0 {
+		text = text[:j]
+	}
+	return Message{
+		Priority: pri,
+		Time:     boot.Add(time.Duration(usec) * time.Microsecond),
+		Tag:      "kernel",
+		Text:     text,
+	}, nil
+}
+
+// Forwarder sends messages to a remote collector.
+//
+// Send only queues messages; they are delivered in the background, so a slow
+// or unreachable collector never blocks the caller. Messages that cannot be
+// delivered are kept in a bounded buffer and retried every RetryInterval,
+// oldest first. When the buffer is full, the oldest messages are dropped.
+type Forwarder struct {
+	// Network is "udp", "tcp" or "http". For http, Addr is a URL that
+	// receives a POST of newline-delimited JSON messages.
+	Network string
+	Addr    string
+
+	// BufferSize is the number of undelivered messages kept. Zero means
+	// DefaultBufferSize.
+	BufferSize int
+
+	// RetryInterval is how often undelivered messages are retried. Zero
+	// means DefaultRetryInterval.
+	RetryInterval time.Duration
+
+	// Client is used for http. If nil, a client with a Timeout of
+	// DefaultTimeout is used.
+	Client *http.Client
+
+	// mu protects the queue. It is never held during network I/O.
+	mu      sync.Mutex
+	pending []Message
+	// first is the sequence number of pending[0].
+	first   uint64
+	dropped int
+	closed  bool
+
+	// sendMu serializes delivery and protects conn.
+	sendMu sync.Mutex
+	conn   net.Conn
+
+	start sync.Once
+	kick  chan struct{}
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+// Defaults for Forwarder.
+const (
+	DefaultBufferSize    = 1024
+	DefaultRetryInterval = 5 * time.Second
+	DefaultTimeout       = 5 * time.Second
+)
+
+// ErrClosed is returned by Send after Close.
+var ErrClosed = errors.New("forwarder is closed")
+
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// NewForwarder returns a Forwarder for the given network and address.
+func NewForwarder(network, addr string) *Forwarder {
+	if network == "" {
+		network = "udp"
+	}
+	return &Forwarder{Network: network, Addr: addr}
+}
+
+// Hostname returns the host name to put in records.
+func Hostname() string {
+	h, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return h
+}
+
+// Send queues m for delivery. It does not wait for the collector.
+func (f *Forwarder) Send(m Message) error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return ErrClosed
+	}
+	f.pending = append(f.pending, m)
+	size := f.BufferSize
+	if size == 0 {
+		size = DefaultBufferSize
+	}
+	if n := len(f.pending) - size; n > 0 {
+		f.pending = f.pending[n:]
+		f.first += uint64(n)
+		f.dropped += n
+	}
+	f.mu.Unlock()
+
+	f.start.Do(f.startLoop)
+	select {
+	case f.kick <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (f *Forwarder) startLoop() {
+	f.kick = make(chan struct{}, 1)
+	f.quit = make(chan struct{})
+	f.done = make(chan struct{})
+	interval := f.RetryInterval
+	if interval == 0 {
+		interval = DefaultRetryInterval
+	}
+	go func() {
+		defer close(f.done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-f.quit:
+				return
+			case <-f.kick:
+			case <-t.C:
+			}
+			f.Flush()
+		}
+	}()
+}
+
+// Flush tries to deliver all queued messages now and returns the delivery
+// error, if any.
+func (f *Forwarder) Flush() error {
+	f.sendMu.Lock()
+	defer f.sendMu.Unlock()
+
+	f.mu.Lock()
+	batch := append([]Message(nil), f.pending...)
+	first := f.first
+	f.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var (
+		n   int
+		err error
+	)
+	if f.Network == "http" {
+		if err = f.sendHTTP(batch); err == nil {
+			n = len(batch)
+		}
+	} else {
+		n, err = f.sendStream(batch)
+	}
+
+	// Messages may have been dropped from the front meanwhile.
+	f.mu.Lock()
+	if end := first + uint64(n); end > f.first {
+		d := end - f.first
+		if d > uint64(len(f.pending)) {
+			d = uint64(len(f.pending))
+		}
+		f.pending = f.pending[d:]
+		f.first += d
+	}
+	f.mu.Unlock()
+	return err
+}
+
+// Pending returns the number of queued messages and the number of messages
+// dropped so far because the buffer was full.
+func (f *Forwarder) Pending() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.pending), f.dropped
+}
+
+// sendStream writes msgs over udp or tcp and returns how many were written.
+func (f *Forwarder) sendStream(msgs []Message) (int, error) {
+	for i, m := range msgs {
+		if f.conn == nil {
+			c, err := net.DialTimeout(f.Network, f.Addr, DefaultTimeout)
+			if err != nil {
+				return i, err
+			}
+			f.conn = c
+		}
+		line := m.RFC5424()
+		if f.Network == "tcp" {
+			// RFC 6587 octet counting.
+			line = fmt.Sprintf("%d %s", len(line), line)
+		}
+		f.conn.SetWriteDeadline(time.Now().Add(DefaultTimeout))
+		if _, err := f.conn.Write([]byte(line)); err != nil {
+			f.conn.Close()
+			f.conn = nil
+			return i, err
+		}
+	}
+	return len(msgs), nil
+}
+
+func (f *Forwarder) sendHTTP(msgs []Message) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, m := range msgs {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+	c := f.Client
+	if c == nil {
+		c = defaultClient
+	}
+	resp, err := c.Post(f.Addr, "application/x-ndjson", &b)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", f.Addr, resp.Status)
+	}
+	return nil
+}
+
+// Close stops background delivery, makes a last attempt to deliver queued
+// messages and closes the connection to the collector.
+func (f *Forwarder) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	// Either waits for a concurrent Send to start the loop, or makes sure
+	// none ever does.
+	f.start.Do(func() {})
+	if f.quit != nil {
+		close(f.quit)
+		<-f.done
+	}
+	err := f.Flush()
+	f.sendMu.Lock()
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+	f.sendMu.Unlock()
+	return err
+}
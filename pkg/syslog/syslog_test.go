@@ -0,0 +1,203 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParsePriority(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want int
+		err  bool
+	}{
+		{in: "local0.info", want: 134},
+		{in: "kern.emerg", want: 0},
+		{in: "err", want: 11},
+		{in: "bogus.info", err: true},
+		{in: "user.bogus", err: true},
+	} {
+		got, err := ParsePriority(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParsePriority(%q) = %d, %v, want %d (error %t)", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	m := Message{
+		Priority: 14,
+		Time:     time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC),
+		Hostname: "node1",
+		Tag:      "init",
+		Text:     "hello",
+	}
+	if got, want := m.RFC5424(), "<14>1 2022-03-04T05:06:07Z node1 init - - - hello"; got != want {
+		t.Errorf("RFC5424 = %q, want %q", got, want)
+	}
+	if got, want := m.RFC3164(), "<14>Mar  4 05:06:07 node1 init: hello"; got != want {
+		t.Errorf("RFC3164 = %q, want %q", got, want)
+	}
+}
+
+func TestParseKmsg(t *testing.T) {
+	boot := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	m, err := ParseKmsg("6,339,5140900,-;NET: Registered protocol family 10\n SUBSYSTEM=net", boot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Priority != 6 || m.Text != "NET: Registered protocol family 10" || !m.Time.Equal(boot.Add(5140900*time.Microsecond)) {
+		t.Errorf("ParseKmsg = %+v", m)
+	}
+	if _, err := ParseKmsg("garbage", boot); err != ErrNotKmsg {
+		t.Errorf("ParseKmsg(garbage) = %v, want %v", err, ErrNotKmsg)
+	}
+}
+
+func TestForwarderTCPBuffering(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	// Nothing is listening: messages must be kept.
+	l.Close()
+
+	f := &Forwarder{Network: "tcp", Addr: addr, BufferSize: 2, RetryInterval: time.Hour}
+	for _, s := range []string{"a", "b", "c"} {
+		if err := f.Send(Message{Text: s}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Flush(); err == nil {
+		t.Fatalf("Flush succeeded without a collector")
+	}
+	if pending, dropped := f.Pending(); pending != 2 || dropped != 1 {
+		t.Errorf("Pending = %d, %d, want 2, 1", pending, dropped)
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("could not relisten on %s: %v", addr, err)
+	}
+	defer l.Close()
+	got := make(chan []string, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		var frames []string
+		r := bufio.NewReader(c)
+		for len(frames) < 2 {
+			f, err := readFrame(r)
+			if err != nil {
+				break
+			}
+			frames = append(frames, f)
+		}
+		got <- frames
+	}()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	frames := <-got
+	if len(frames) != 2 || !strings.HasSuffix(frames[0], " b") || !strings.HasSuffix(frames[1], " c") {
+		t.Errorf("collector got %q, want the two newest messages", frames)
+	}
+}
+
+// readFrame reads one octet-counted frame.
+func readFrame(r *bufio.Reader) (string, error) {
+	l, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(l))
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func TestForwarderHTTP(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []Message
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dec := json.NewDecoder(r.Body)
+		for {
+			var m Message
+			if err := dec.Decode(&m); err != nil {
+				break
+			}
+			mu.Lock()
+			got = append(got, m)
+			mu.Unlock()
+		}
+	}))
+	defer s.Close()
+
+	f := NewForwarder("http", s.URL)
+	if err := f.Send(Message{Priority: 3, Text: "boom"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0].Text != "boom" || got[0].Priority != 3 {
+		t.Errorf("collector got %+v", got)
+	}
+	if err := f.Send(Message{Text: "late"}); err != ErrClosed {
+		t.Errorf("Send after Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestForwarderSendDoesNotBlock(t *testing.T) {
+	hang := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer s.Close()
+	defer close(hang)
+
+	f := &Forwarder{Network: "http", Addr: s.URL, Client: &http.Client{Timeout: 100 * time.Millisecond}}
+	defer f.Close()
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if err := f.Send(Message{Text: "line"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Send blocked for %v while the collector hangs", d)
+	}
+	// The client timeout bounds delivery attempts.
+	if err := f.Flush(); err == nil {
+		t.Errorf("Flush to hanging collector succeeded")
+	}
+	if n, _ := f.Pending(); n != 100 {
+		t.Errorf("Pending = %d, want 100", n)
+	}
+}
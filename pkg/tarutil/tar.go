@@ -13,6 +13,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/u-root/u-root/pkg/nss"
 	"github.com/u-root/u-root/pkg/upath"
 )
 
@@ -31,6 +32,15 @@ type Opts struct {
 	// Change to this directory before any operations. This is equivalent
 	// to "tar -C DIR".
 	ChangeDirectory string
+
+	// SameOwner sets the owner and group of extracted files to those of
+	// the archive, which usually only root may do. The names of the
+	// archive are looked up first, and the ids used for those not found.
+	SameOwner bool
+
+	// NumericOwner leaves the owner and group names out of created
+	// archives, and has SameOwner use the ids of the archive only.
+	NumericOwner bool
 }
 
 // passesFilters returns true if the given file passes all filters, false otherwise.
@@ -93,7 +103,7 @@ func ExtractDir(tarFile io.Reader, dir string, opts *Opts) error {
 		if !passesFilters(hdr, opts.Filters) {
 			return nil
 		}
-		return createFileInRoot(hdr, tr, dir)
+		return createFileInRoot(hdr, tr, dir, opts)
 	})
 }
 
@@ -154,6 +164,10 @@ func CreateTar(tarFile io.Writer, files []string, opts *Opts) error {
 				return err
 			}
 			hdr.Name = bcPath
+			hdr.Uname, hdr.Gname = "", ""
+			if !opts.NumericOwner {
+				setOwnerNames(hdr)
+			}
 			if !passesFilters(hdr, opts.Filters) {
 				return nil
 			}
@@ -202,7 +216,35 @@ func CreateTar(tarFile io.Writer, files []string, opts *Opts) error {
 	return nil
 }
 
-func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string) error {
+// setOwnerNames sets the owner and group names of hdr to those of its ids,
+// if they have names.
+func setOwnerNames(hdr *tar.Header) {
+	if u, err := nss.LookupUID(uint32(hdr.Uid)); err == nil {
+		hdr.Uname = u.Name
+	}
+	if g, err := nss.LookupGID(uint32(hdr.Gid)); err == nil {
+		hdr.Gname = g.Name
+	}
+}
+
+// owner returns the uid and gid that the file of hdr is extracted with:
+// those of its owner and group names, unless numeric is set or they are not
+// found, or else its ids.
+func owner(hdr *tar.Header, numeric bool) (int, int) {
+	uid, gid := hdr.Uid, hdr.Gid
+	if numeric {
+		return uid, gid
+	}
+	if u, err := nss.LookupUser(hdr.Uname); err == nil {
+		uid = int(u.UID)
+	}
+	if g, err := nss.LookupGroup(hdr.Gname); err == nil {
+		gid = int(g.GID)
+	}
+	return uid, gid
+}
+
+func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string, opts *Opts) error {
 	fi := hdr.FileInfo()
 	path, err := upath.SafeFilepathJoin(rootDir, hdr.Name)
 	if err != nil {
@@ -247,11 +289,18 @@ func createFileInRoot(hdr *tar.Header, r io.Reader, rootDir string) error {
 		return fmt.Errorf("%q: Unknown type %#o", path, fi.Mode()&os.ModeType)
 	}
 
+	// Changing the owner can clear the setuid and setgid bits, so do it
+	// before setting the mode.
+	if opts.SameOwner {
+		uid, gid := owner(hdr, opts.NumericOwner)
+		if err := os.Lchown(path, uid, gid); err != nil {
+			return err
+		}
+	}
 	if err := os.Chmod(path, fi.Mode()&os.ModePerm); err != nil {
 		return fmt.Errorf("error setting mode %#o on %q: %v",
 			fi.Mode()&os.ModePerm, path, err)
 	}
-	// TODO: also set ownership, etc...
 	return nil
 }
 
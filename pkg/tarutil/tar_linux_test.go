@@ -0,0 +1,85 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tarutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/nss"
+)
+
+func TestOwner(t *testing.T) {
+	dir := t.TempDir()
+	uid, gid := os.Getuid(), os.Getgid()
+	nss.PasswdPath = filepath.Join(dir, "passwd")
+	nss.GroupPath = filepath.Join(dir, "group")
+	if err := os.WriteFile(nss.PasswdPath, []byte(fmt.Sprintf("tester:x:%d:%d::/:/bin/sh\n", uid, gid)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(nss.GroupPath, []byte(fmt.Sprintf("testers:x:%d:\n", gid)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, numeric := range []bool{false, true} {
+		var b bytes.Buffer
+		if err := CreateTar(&b, []string{"test0"}, &Opts{NumericOwner: numeric}); err != nil {
+			t.Fatal(err)
+		}
+		hdr, err := tar.NewReader(&b).Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := [2]string{"tester", "testers"}
+		if numeric {
+			want = [2]string{}
+		}
+		if got := [2]string{hdr.Uname, hdr.Gname}; got != want {
+			t.Errorf("CreateTar with NumericOwner %t: names %q, want %q", numeric, got, want)
+		}
+	}
+
+	// The names of the archive win over its ids, unless they are not
+	// found or the ids are asked for.
+	for _, tt := range []struct {
+		hdr      tar.Header
+		numeric  bool
+		uid, gid int
+	}{
+		{hdr: tar.Header{Uid: 1234, Gid: 5678, Uname: "tester", Gname: "testers"}, uid: uid, gid: gid},
+		{hdr: tar.Header{Uid: 1234, Gid: 5678, Uname: "tester", Gname: "testers"}, numeric: true, uid: 1234, gid: 5678},
+		{hdr: tar.Header{Uid: 1234, Gid: 5678, Uname: "nobody"}, uid: 1234, gid: 5678},
+	} {
+		if uid, gid := owner(&tt.hdr, tt.numeric); uid != tt.uid || gid != tt.gid {
+			t.Errorf("owner(%q:%q, %t) = %d:%d, want %d:%d", tt.hdr.Uname, tt.hdr.Gname, tt.numeric, uid, gid, tt.uid, tt.gid)
+		}
+	}
+
+	// Files may be given to their owner.
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	if err := tw.WriteHeader(&tar.Header{Name: "f", Mode: 0o644, Uid: 1234, Gid: 5678, Uname: "tester", Gname: "testers"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := ExtractDir(&b, out, &Opts{SameOwner: true}); err != nil {
+		t.Fatal(err)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(filepath.Join(out, "f"), &st); err != nil {
+		t.Fatal(err)
+	}
+	if int(st.Uid) != uid || int(st.Gid) != gid {
+		t.Errorf("f is owned by %d:%d, want %d:%d", st.Uid, st.Gid, uid, gid)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package teardown tears the system down before it is powered off or
+// rebooted, so that what was written reaches the disks and the services and
+// the BMC know.
+//
+// Teardown is a list of hooks, run in order, within a timeout: the final
+// reboot syscall happens anyway, but not before whatever, e.g. an imaging
+// flow, is writing had its chance to finish.
+package teardown
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/u-root/u-root/pkg/ipmi"
+	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/svdir"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultDir is the directory of the executables Exec runs.
+const DefaultDir = "/etc/shutdown.d"
+
+// Hook is a step of tearing the system down.
+type Hook struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// HookError is the error of a hook that failed.
+type HookError struct {
+	Hook string
+	Err  error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Hook, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// Run runs the hooks in order, and returns the errors, each a *HookError, of
+// those that failed. A hook failing does not stop the others.
+//
+// The hooks must be done within timeout: the one running then is abandoned,
+// with an error, and those after it are not run. A timeout of 0 is none.
+func Run(ctx context.Context, timeout time.Duration, hooks ...Hook) []error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var errs []error
+	for i, h := range hooks {
+		done := make(chan error, 1)
+		go func(h Hook) { done <- h.Run(ctx) }(h)
+		select {
+		case err := <-done:
+			if err != nil {
+				errs = append(errs, &HookError{Hook: h.Name, Err: err})
+			}
+		case <-ctx.Done():
+			errs = append(errs, &HookError{Hook: h.Name, Err: ctx.Err()})
+			for _, h := range hooks[i+1:] {
+				errs = append(errs, &HookError{Hook: h.Name, Err: fmt.Errorf("not run: %w", ctx.Err())})
+			}
+			return errs
+		}
+	}
+	return errs
+}
+
+var (
+	mu         sync.Mutex
+	registered []Hook
+)
+
+// Register adds hooks to those of Defaults, after those registered before.
+func Register(hooks ...Hook) {
+	mu.Lock()
+	defer mu.Unlock()
+	registered = append(registered, hooks...)
+}
+
+// Defaults returns the hooks to tear down for the operation op, e.g. "reboot":
+// stop the services, run the executables of DefaultDir and the registered
+// hooks, tell the BMC, sync, and unmount the disks.
+func Defaults(op string) []Hook {
+	mu.Lock()
+	defer mu.Unlock()
+	hooks := []Hook{
+		StopServices(svdir.DefaultSocket),
+		Exec(DefaultDir, op),
+	}
+	hooks = append(hooks, registered...)
+	return append(hooks, NotifyBMC(), Sync(), Unmount())
+}
+
+// Sync returns a hook that writes the file systems' caches to the disks.
+func Sync() Hook {
+	return Hook{Name: "sync", Run: func(context.Context) error {
+		unix.Sync()
+		return nil
+	}}
+}
+
+// StopServices returns a hook that brings down the services that run under
+// the supervisor listening on socket. Without a supervisor, it does nothing.
+func StopServices(socket string) Hook {
+	return Hook{Name: "stop services", Run: func(context.Context) error {
+		if _, err := os.Stat(socket); os.IsNotExist(err) {
+			return nil
+		}
+		lines, err := svdir.Control(socket, "status")
+		if err != nil {
+			return err
+		}
+		var names []string
+		for _, l := range lines {
+			// A running service's status is "run: name: ...".
+			if f := strings.SplitN(l, ": ", 3); len(f) == 3 && f[0] == "run" {
+				names = append(names, f[1])
+			}
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		_, err = svdir.Control(socket, "down", names...)
+		return err
+	}}
+}
+
+// Exec returns a hook that runs the executables in dir, in lexical order,
+// with op as argument. It fails if any does, after running all of them.
+// Without dir, it does nothing.
+func Exec(dir, op string) Hook {
+	return Hook{Name: "exec " + dir, Run: func(ctx context.Context) error {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var failed []string
+		for _, e := range entries {
+			fi, err := e.Info()
+			if err != nil || !fi.Mode().IsRegular() || fi.Mode()&0o111 == 0 {
+				continue
+			}
+			c := exec.CommandContext(ctx, filepath.Join(dir, e.Name()), op)
+			c.Stdout, c.Stderr = os.Stdout, os.Stderr
+			if err := c.Run(); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", e.Name(), err))
+			}
+		}
+		if len(failed) > 0 {
+			return errors.New(strings.Join(failed, "; "))
+		}
+		return nil
+	}}
+}
+
+// Standard system event of an OS graceful shutdown, from the IPMI
+// specification's OS Stop/Shutdown sensor type.
+const (
+	selStandardRecord = 0x02
+	selSystemSoftware = 0x41
+	selEvMRev         = 0x04
+	selOSStop         = 0x20
+	selSensorSpecific = 0x6f
+	selOSShutdown     = 0x03
+)
+
+// NotifyBMC returns a hook that logs an OS graceful shutdown in the system
+// event log of the BMC. Without a BMC, it does nothing.
+func NotifyBMC() Hook {
+	return Hook{Name: "notify BMC", Run: func(context.Context) error {
+		i, err := ipmi.Open(0)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer i.Close()
+		e := &ipmi.Event{RecordType: selStandardRecord}
+		e.StandardEvent = ipmi.StandardEvent{
+			Timestamp:    uint32(time.Now().Unix()),
+			GenID:        selSystemSoftware,
+			EvMRev:       selEvMRev,
+			SensorType:   selOSStop,
+			EventTypeDir: selSensorSpecific,
+			EventData:    [3]uint8{selOSShutdown, 0xff, 0xff},
+		}
+		return i.LogSystemEvent(e)
+	}}
+}
+
+// mountsFile lists the mounts. Tests change it.
+var mountsFile = "/proc/self/mounts"
+
+// unescapeMount undoes the octal escapes of spaces, tabs, newlines and
+// backslashes in the fields of mountsFile.
+func unescapeMount(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// diskMounts returns the mount points of the file systems of devices, other
+// than /, innermost first.
+func diskMounts(r io.Reader) ([]string, error) {
+	var paths []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 2 || !strings.HasPrefix(f[0], "/") {
+			continue
+		}
+		if p := unescapeMount(f[1]); p != "/" {
+			paths = append(paths, p)
+		}
+	}
+	// Later mounts may be on earlier ones.
+	for i, j := 0, len(paths)-1; i < j; i, j = i+1, j-1 {
+		paths[i], paths[j] = paths[j], paths[i]
+	}
+	return paths, s.Err()
+}
+
+// Unmount returns a hook that unmounts the file systems of devices, and
+// remounts read-only those it cannot unmount, e.g. because they are busy.
+func Unmount() Hook {
+	return Hook{Name: "unmount", Run: func(context.Context) error {
+		f, err := os.Open(mountsFile)
+		if err != nil {
+			return err
+		}
+		paths, err := diskMounts(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		var failed []string
+		for _, p := range paths {
+			if err := mount.Unmount(p, false, false); err == nil {
+				continue
+			}
+			if err := unix.Mount("", p, "", unix.MS_REMOUNT|unix.MS_RDONLY, ""); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", p, err))
+			}
+		}
+		if len(failed) > 0 {
+			return errors.New(strings.Join(failed, "; "))
+		}
+		return nil
+	}}
+}
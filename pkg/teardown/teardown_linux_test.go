@@ -0,0 +1,117 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package teardown
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var ran []string
+	hook := func(name string, err error) Hook {
+		return Hook{Name: name, Run: func(context.Context) error {
+			ran = append(ran, name)
+			return err
+		}}
+	}
+	errFail := errors.New("fail")
+	errs := Run(context.Background(), 0, hook("a", nil), hook("b", errFail), hook("c", nil))
+	if !reflect.DeepEqual(ran, []string{"a", "b", "c"}) {
+		t.Errorf("Run ran %v, want a, b and c", ran)
+	}
+	var he *HookError
+	if len(errs) != 1 || !errors.As(errs[0], &he) || he.Hook != "b" || !errors.Is(errs[0], errFail) {
+		t.Errorf("Run = %v, want b failing", errs)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	ran := false
+	stuck := Hook{Name: "stuck", Run: func(context.Context) error {
+		select {}
+	}}
+	after := Hook{Name: "after", Run: func(context.Context) error {
+		ran = true
+		return nil
+	}}
+	errs := Run(context.Background(), 10*time.Millisecond, stuck, after)
+	if ran {
+		t.Errorf("Run ran a hook after the timeout")
+	}
+	if len(errs) != 2 || !errors.Is(errs[0], context.DeadlineExceeded) || !errors.Is(errs[1], context.DeadlineExceeded) {
+		t.Errorf("Run = %v, want both hooks to time out", errs)
+	}
+}
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	for name, content := range map[string]string{
+		"10-first":  "#!/bin/sh\necho first $1 >> " + out + "\n",
+		"20-second": "#!/bin/sh\necho second $1 >> " + out + "\nexit 1\n",
+		"30-third":  "#!/bin/sh\necho third $1 >> " + out + "\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Not executable.
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not run"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Exec(dir, "reboot").Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "20-second") {
+		t.Errorf("Exec = %v, want 20-second failing", err)
+	}
+	b, err := os.ReadFile(out)
+	if want := "first reboot\nsecond reboot\nthird reboot\n"; err != nil || string(b) != want {
+		t.Errorf("Exec ran %q, %v, want %q", b, err, want)
+	}
+
+	if err := Exec(filepath.Join(dir, "none"), "reboot").Run(context.Background()); err != nil {
+		t.Errorf("Exec without a directory = %v, want nil", err)
+	}
+}
+
+func TestStopServicesWithout(t *testing.T) {
+	if err := StopServices(filepath.Join(t.TempDir(), "sock")).Run(context.Background()); err != nil {
+		t.Errorf("StopServices without a supervisor = %v, want nil", err)
+	}
+}
+
+func TestDiskMounts(t *testing.T) {
+	const mounts = `/dev/root / ext4 rw,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+devtmpfs /dev devtmpfs rw,nosuid,size=1024k 0 0
+/dev/sda1 /mnt/disk ext4 rw,relatime 0 0
+/dev/sda2 /mnt/disk/my\040image vfat rw,relatime 0 0
+tmpfs /tmp tmpfs rw 0 0
+`
+	got, err := diskMounts(strings.NewReader(mounts))
+	if want := []string{"/mnt/disk/my image", "/mnt/disk"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("diskMounts = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	defer func(r []Hook) { registered = r }(registered)
+	Register(Hook{Name: "image"})
+	var got []string
+	for _, h := range Defaults("halt") {
+		got = append(got, h.Name)
+	}
+	want := []string{"stop services", "exec " + DefaultDir, "image", "notify BMC", "sync", "unmount"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Defaults = %q, want %q", got, want)
+	}
+}
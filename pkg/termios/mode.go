@@ -0,0 +1,99 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package termios
+
+import "sync"
+
+// Restorer restores a terminal to the mode it was in before SetMode.
+type Restorer struct {
+	fd   uintptr
+	term *Termios
+
+	once sync.Once
+	err  error
+}
+
+// SetMode sets the terminal fd to the mode that mode makes of its current
+// one, e.g. MakeRaw or MakeCbreak, and returns a Restorer of the current one.
+//
+// Restore the mode in a defer, which also runs if the caller panics:
+//
+//	r, err := termios.SetMode(fd, termios.MakeRaw)
+//	if err != nil {
+//		return err
+//	}
+//	defer r.Restore()
+//
+// Deferred calls do not run on os.Exit, so neither does log.Fatal.
+func SetMode(fd uintptr, mode func(*Termios) *Termios) (*Restorer, error) {
+	term, err := GetTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+	if err := SetTermios(fd, mode(term)); err != nil {
+		return nil, err
+	}
+	return &Restorer{fd: fd, term: term}, nil
+}
+
+// Restore restores the mode of the terminal. Only the first call restores it,
+// later calls return the same error.
+func (r *Restorer) Restore() error {
+	r.once.Do(func() {
+		r.err = SetTermios(r.fd, r.term)
+	})
+	return r.err
+}
+
+// Termios returns the mode that Restore restores.
+func (r *Restorer) Termios() *Termios {
+	return r.term
+}
+
+// WithMode runs f with the terminal fd in the mode that mode makes of its
+// current one, and restores the current one afterwards, even if f panics.
+func WithMode(fd uintptr, mode func(*Termios) *Termios, f func() error) (err error) {
+	r, err := SetMode(fd, mode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rerr := r.Restore(); err == nil {
+			err = rerr
+		}
+	}()
+	return f()
+}
+
+// IsTerminal returns whether fd is a terminal.
+func IsTerminal(fd uintptr) bool {
+	_, err := GetTermios(fd)
+	return err == nil
+}
+
+// Fd returns the file descriptor of the TTYIO.
+func (t *TTYIO) Fd() uintptr {
+	return t.f.Fd()
+}
+
+// Close closes the TTYIO.
+func (t *TTYIO) Close() error {
+	return t.f.Close()
+}
+
+// SetMode sets the TTYIO to the mode that mode makes of its current one, and
+// returns a Restorer of the current one; see SetMode.
+func (t *TTYIO) SetMode(mode func(*Termios) *Termios) (*Restorer, error) {
+	return SetMode(t.f.Fd(), mode)
+}
+
+// Cbreak sets the tty into cbreak mode, and returns its previous mode.
+func (t *TTYIO) Cbreak() (*Termios, error) {
+	r, err := t.SetMode(MakeCbreak)
+	if err != nil {
+		return nil, err
+	}
+	return r.Termios(), nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package termios_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/pty"
+	"github.com/u-root/u-root/pkg/termios"
+	"golang.org/x/sys/unix"
+)
+
+// openPTS returns the terminal side of a new pseudo terminal.
+func openPTS(t *testing.T) *os.File {
+	t.Helper()
+	ptm, pts, err := pty.Open()
+	if err != nil {
+		t.Skipf("No pseudo terminals here: %v", err)
+	}
+	t.Cleanup(func() {
+		pts.Close()
+		ptm.Close()
+	})
+	return pts
+}
+
+func get(t *testing.T, fd uintptr) *termios.Termios {
+	t.Helper()
+	term, err := termios.GetTermios(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return term
+}
+
+func TestSetMode(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		mode  func(*termios.Termios) *termios.Termios
+		isig  bool
+		opost bool
+	}{
+		{name: "raw", mode: termios.MakeRaw},
+		{name: "cbreak", mode: termios.MakeCbreak, isig: true, opost: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pts := openPTS(t)
+			orig := get(t, pts.Fd())
+			r, err := termios.SetMode(pts.Fd(), tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			term := get(t, pts.Fd())
+			if term.Lflag&(unix.ICANON|unix.ECHO) != 0 {
+				t.Errorf("%s mode is canonical or echoes: lflag %#x", tt.name, term.Lflag)
+			}
+			if got := term.Lflag&unix.ISIG != 0; got != tt.isig {
+				t.Errorf("%s mode sends signals = %t, want %t", tt.name, got, tt.isig)
+			}
+			if got := term.Oflag&unix.OPOST != 0; got != tt.opost {
+				t.Errorf("%s mode processes output = %t, want %t", tt.name, got, tt.opost)
+			}
+			if !reflect.DeepEqual(r.Termios(), orig) {
+				t.Errorf("Restorer.Termios() = %v, want %v", r.Termios(), orig)
+			}
+
+			for i := 0; i < 2; i++ {
+				if err := r.Restore(); err != nil {
+					t.Fatalf("Restore #%d: %v", i+1, err)
+				}
+			}
+			if got := get(t, pts.Fd()); !reflect.DeepEqual(got, orig) {
+				t.Errorf("restored mode = %v, want %v", got, orig)
+			}
+		})
+	}
+}
+
+func TestWithModeRestoresOnPanic(t *testing.T) {
+	pts := openPTS(t)
+	orig := get(t, pts.Fd())
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("WithMode did not panic")
+			}
+		}()
+		termios.WithMode(pts.Fd(), termios.MakeRaw, func() error {
+			if get(t, pts.Fd()).Lflag&unix.ICANON != 0 {
+				t.Errorf("WithMode did not set raw mode")
+			}
+			panic("boom")
+		})
+	}()
+	if got := get(t, pts.Fd()); !reflect.DeepEqual(got, orig) {
+		t.Errorf("mode after panic = %v, want %v", got, orig)
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	pts := openPTS(t)
+	if !termios.IsTerminal(pts.Fd()) {
+		t.Errorf("IsTerminal(pts) = false, want true")
+	}
+	f, err := os.CreateTemp(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if termios.IsTerminal(f.Fd()) {
+		t.Errorf("IsTerminal(file) = true, want false")
+	}
+	if _, err := termios.GetWinSize(f.Fd()); err == nil {
+		t.Errorf("GetWinSize(file) = nil, want error")
+	}
+}
+
+func TestNotifyWinSize(t *testing.T) {
+	pts := openPTS(t)
+	sizes, stop := termios.NotifyWinSize(pts.Fd())
+	var want termios.Winsize
+	want.Row, want.Col = 42, 132
+	if err := termios.SetWinSize(pts.Fd(), &want); err != nil {
+		t.Fatal(err)
+	}
+	// The pseudo terminal is not ours, so its SIGWINCH goes elsewhere.
+	if err := unix.Kill(os.Getpid(), unix.SIGWINCH); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case w := <-sizes:
+		if w.Row != want.Row || w.Col != want.Col {
+			t.Errorf("size = %dx%d, want %dx%d", w.Col, w.Row, want.Col, want.Row)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no size after SIGWINCH")
+	}
+	stop()
+	if _, ok := <-sizes; ok {
+		t.Errorf("sizes is not closed after stop")
+	}
+}
@@ -11,6 +11,8 @@
 // restorer, err := tty.Raw()
 // do things
 // tty.Set(restorer)
+// or, to restore the mode even on panics, use SetMode or WithMode with MakeRaw
+// or MakeCbreak. NotifyWinSize tells when the window size changes.
 package termios
 
 type (
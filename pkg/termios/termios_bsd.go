@@ -76,7 +76,10 @@ func (t *TTYIO) Set(ti *Termios) error {
 // GetWinSize gets window size from an fd.
 func GetWinSize(fd uintptr) (*Winsize, error) {
 	w, err := unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
-	return &Winsize{Winsize: *w}, err
+	if err != nil {
+		return nil, err
+	}
+	return &Winsize{Winsize: *w}, nil
 }
 
 // GetWinSize gets window size from a TTYIO.
@@ -120,6 +123,18 @@ func MakeRaw(term *Termios) *Termios {
 	return &raw
 }
 
+// MakeCbreak modifies Termio state so, if it used for an fd or tty, input is
+// read a character at a time, without echo. Unlike raw mode, Ctrl-C and the
+// other signal characters still send signals, and output is still processed.
+func MakeCbreak(term *Termios) *Termios {
+	cbreak := *term
+	cbreak.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON
+	cbreak.Cc[unix.VMIN] = 1
+	cbreak.Cc[unix.VTIME] = 0
+
+	return &cbreak
+}
+
 // MakeSerialBaud updates the Termios to set the baudrate
 func MakeSerialBaud(term *Termios, baud int) (*Termios, error) {
 	t := *term
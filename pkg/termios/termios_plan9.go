@@ -98,6 +98,12 @@ func MakeRaw(term *Termios) *Termios {
 	return &raw
 }
 
+// MakeCbreak modifies Termio state so, if it used for an fd or tty, it will set it to cbreak mode.
+func MakeCbreak(term *Termios) *Termios {
+	cbreak := *term
+	return &cbreak
+}
+
 // MakeSerialBaud updates the Termios to set the baudrate
 func MakeSerialBaud(term *Termios, baud int) (*Termios, error) {
 	t := *term
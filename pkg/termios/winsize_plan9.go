@@ -0,0 +1,13 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package termios
+
+// NotifyWinSize sends the window size of the terminal fd on the returned
+// channel when it changes, until stop is called. Window sizes do not change on
+// Plan 9, so nothing is sent.
+func NotifyWinSize(fd uintptr) (sizes <-chan *Winsize, stop func()) {
+	c := make(chan *Winsize)
+	return c, func() { close(c) }
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package termios
+
+import (
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+// NotifyWinSize sends the window size of the terminal fd on the returned
+// channel when it changes, i.e. on SIGWINCH, until stop is called. The channel
+// is closed then. Sizes that are not received before the next change are
+// dropped, so the receiver only gets behind by one.
+func NotifyWinSize(fd uintptr) (sizes <-chan *Winsize, stop func()) {
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, unix.SIGWINCH)
+	c := make(chan *Winsize, 1)
+	go func() {
+		defer close(c)
+		for range winch {
+			w, err := GetWinSize(fd)
+			if err != nil {
+				continue
+			}
+			// Replace a size that was not received.
+			select {
+			case <-c:
+			default:
+			}
+			c <- w
+		}
+	}()
+	return c, func() {
+		signal.Stop(winch)
+		close(winch)
+	}
+}
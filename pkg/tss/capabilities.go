@@ -6,6 +6,7 @@ package tss
 
 import (
 	"crypto/sha1"
+	"crypto/x509"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -141,8 +142,35 @@ func readPubEK12(rwc io.ReadWriteCloser, ownerPW string) ([]byte, error) {
 	return ek, nil
 }
 
+// ekTemplateRSA is the default RSA 2048 EK template of the TCG EK Credential
+// Profile, so that the EK is the one that its certificate is for.
+var ekTemplateRSA = tpm2.Public{
+	Type:    tpm2.AlgRSA,
+	NameAlg: tpm2.AlgSHA256,
+	Attributes: tpm2.FlagFixedTPM | tpm2.FlagFixedParent | tpm2.FlagSensitiveDataOrigin |
+		tpm2.FlagAdminWithPolicy | tpm2.FlagRestricted | tpm2.FlagDecrypt,
+	AuthPolicy: []byte{
+		0x83, 0x71, 0x97, 0x67, 0x44, 0x84, 0xB3, 0xF8,
+		0x1A, 0x90, 0xCC, 0x8D, 0x46, 0xA5, 0xD7, 0x24,
+		0xFD, 0x52, 0xD7, 0x6E, 0x06, 0x52, 0x0B, 0x64,
+		0xF2, 0xA1, 0xDA, 0x1B, 0x33, 0x14, 0x69, 0xAA,
+	},
+	RSAParameters: &tpm2.RSAParams{
+		Symmetric:  &tpm2.SymScheme{Alg: tpm2.AlgAES, KeyBits: 128, Mode: tpm2.AlgCFB},
+		KeyBits:    2048,
+		ModulusRaw: make([]byte, 256),
+	},
+}
+
+// readPubEK20 derives the EK from the endorsement hierarchy and returns its
+// public key as DER-encoded PKIX. ownerPW is the endorsement password.
 func readPubEK20(rwc io.ReadWriteCloser, ownerPW string) ([]byte, error) {
-	return nil, fmt.Errorf("not supported by go-tpm for TPM2.0")
+	h, pub, err := tpm2.CreatePrimary(rwc, tpm2.HandleEndorsement, tpm2.PCRSelection{}, ownerPW, "", ekTemplateRSA)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rwc, h)
+	return x509.MarshalPKIXPublicKey(pub)
 }
 
 func resetLockValue12(rwc io.ReadWriteCloser, ownerPW string) (bool, error) {
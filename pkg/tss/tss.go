@@ -220,3 +220,20 @@ func (t *TPM) NVReadValue(index uint32, ownerPassword string, size, offhandle ui
 	}
 	return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
 }
+
+// PCRMeasurer extends SHA-256 digests into the PCRs of a TPM 2.0. It can be
+// given to vfile.SetMeasurer to measure verified files.
+type PCRMeasurer struct {
+	TPM *TPM
+}
+
+// Extend extends the SHA-256 digest into the SHA-256 bank of pcr.
+func (m PCRMeasurer) Extend(pcr int, digest []byte) error {
+	if m.TPM.Version != TPMVersion20 {
+		return fmt.Errorf("unsupported TPM version for SHA-256 measurements: %x", m.TPM.Version)
+	}
+	if len(digest) != sha256.Size {
+		return fmt.Errorf("digest length invalid - need %d, got: %d", sha256.Size, len(digest))
+	}
+	return extendPCR20(m.TPM.RWC, uint32(pcr), digest)
+}
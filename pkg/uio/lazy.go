@@ -84,15 +84,22 @@ func (loa *LazyOpenerAt) String() string {
 	return "unopened mystery file"
 }
 
-// ReadAt implements io.ReaderAt.ReadAt.
-func (loa *LazyOpenerAt) ReadAt(p []byte, off int64) (int, error) {
+// reader returns the underlying io.ReaderAt, which is opened if it was not
+// yet.
+func (loa *LazyOpenerAt) reader() (io.ReaderAt, error) {
 	if loa.r == nil && loa.err == nil {
 		loa.r, loa.err = loa.open()
 	}
-	if loa.err != nil {
-		return 0, loa.err
+	return loa.r, loa.err
+}
+
+// ReadAt implements io.ReaderAt.ReadAt.
+func (loa *LazyOpenerAt) ReadAt(p []byte, off int64) (int, error) {
+	r, err := loa.reader()
+	if err != nil {
+		return 0, err
 	}
-	return loa.r.ReadAt(p, off)
+	return r.ReadAt(p, off)
 }
 
 // Close implements io.Closer.Close.
@@ -0,0 +1,119 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package uio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+var errMmapUnsupported = errors.New("mmap is not supported")
+
+// MmapReaderAt is an io.ReaderAt of a file mapped into memory, read-only.
+//
+// The pages of the file are read from the page cache as they are touched, so
+// a large file, e.g. a kernel or an initramfs, takes no more memory than the
+// page cache already does. Files which cannot be mapped are read into memory
+// instead.
+//
+// Pages not yet read reflect later writes to the file; what is verified must
+// be read from Bytes first, or the file must not be written.
+type MmapReaderAt struct {
+	name   string
+	r      *bytes.Reader
+	b      []byte
+	mapped bool
+
+	mu     sync.Mutex
+	closed bool
+}
+
+var _ io.ReaderAt = &MmapReaderAt{}
+
+// NewMmapFile maps the file at path into memory, or, if it cannot be mapped,
+// e.g. because it is not a regular file, reads it into memory.
+func NewMmapFile(path string) (*MmapReaderAt, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &MmapReaderAt{name: path}
+	if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() && fi.Size() > 0 && int64(int(fi.Size())) == fi.Size() {
+		if b, err := mmap(f, int(fi.Size())); err == nil {
+			m.b, m.mapped = b, true
+		}
+	}
+	if !m.mapped {
+		if m.b, err = io.ReadAll(f); err != nil {
+			return nil, err
+		}
+	}
+	m.r = bytes.NewReader(m.b)
+	return m, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (m *MmapReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+	return m.r.ReadAt(p, off)
+}
+
+// Bytes returns the contents of the file, without copying them. ReadAll
+// returns them too.
+//
+// Callers must not modify them, nor use them once the MmapReaderAt is closed.
+func (m *MmapReaderAt) Bytes() []byte {
+	return m.b
+}
+
+// Len returns the size of the file.
+func (m *MmapReaderAt) Len() int {
+	return len(m.b)
+}
+
+// Mapped returns whether the file is mapped, rather than read into memory.
+func (m *MmapReaderAt) Mapped() bool {
+	return m.mapped
+}
+
+// String implements fmt.Stringer.
+func (m *MmapReaderAt) String() string {
+	return m.name
+}
+
+// Close unmaps the file.
+func (m *MmapReaderAt) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	if m.mapped {
+		return munmap(m.b)
+	}
+	return nil
+}
+
+// NewLazyMmapFile returns a lazy ReaderAt of path, which maps it as
+// NewMmapFile does when first read. ReadAll returns the contents of the file
+// without copying them.
+func NewLazyMmapFile(path string) *LazyOpenerAt {
+	if len(path) == 0 {
+		return nil
+	}
+	return NewLazyOpenerAt(path, func() (io.ReaderAt, error) {
+		return NewMmapFile(path)
+	})
+}
@@ -0,0 +1,19 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build plan9 || windows
+// +build plan9 windows
+
+package uio
+
+import "os"
+
+// mmap is not supported: files are read into memory.
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmap(b []byte) error {
+	return errMmapUnsupported
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package uio
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMmapFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kernel")
+	if err := os.WriteFile(path, []byte("a kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		path   string
+		want   string
+		mapped bool
+	}{
+		{name: "regular", path: path, want: "a kernel", mapped: runtime.GOOS != "plan9" && runtime.GOOS != "windows"},
+		{name: "empty", path: empty},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMmapFile(tt.path)
+			if err != nil {
+				t.Fatalf("NewMmapFile = %v", err)
+			}
+			if m.Mapped() != tt.mapped || string(m.Bytes()) != tt.want || m.Len() != len(tt.want) {
+				t.Errorf("NewMmapFile = %q, mapped %v, want %q, mapped %v", m.Bytes(), m.Mapped(), tt.want, tt.mapped)
+			}
+			b, err := io.ReadAll(Reader(m))
+			if err != nil || string(b) != tt.want {
+				t.Errorf("reading = %q, %v, want %q", b, err, tt.want)
+			}
+			if err := m.Close(); err != nil {
+				t.Errorf("Close = %v", err)
+			}
+			if _, err := m.ReadAt(make([]byte, 1), 0); !errors.Is(err, os.ErrClosed) {
+				t.Errorf("ReadAt after Close = %v, want %v", err, os.ErrClosed)
+			}
+			if err := m.Close(); err != nil {
+				t.Errorf("second Close = %v", err)
+			}
+		})
+	}
+
+	if _, err := NewMmapFile(filepath.Join(dir, "none")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("NewMmapFile of a missing file = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestReadAllLazyMmapFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "initrd")
+	if err := os.WriteFile(path, []byte("an initrd"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewLazyMmapFile(path)
+	defer r.Close()
+	b, err := ReadAll(r)
+	if err != nil || string(b) != "an initrd" {
+		t.Fatalf("ReadAll = %q, %v, want an initrd", b, err)
+	}
+	// ReadAll returns the contents without copying them.
+	if m := r.r.(*MmapReaderAt); &b[0] != &m.Bytes()[0] {
+		t.Errorf("ReadAll copied the contents of the file")
+	}
+
+	if _, err := ReadAll(NewLazyMmapFile(path + ".none")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadAll of a missing file = %v, want %v", err, os.ErrNotExist)
+	}
+	if NewLazyMmapFile("") != nil {
+		t.Errorf("NewLazyMmapFile(\"\") != nil")
+	}
+}
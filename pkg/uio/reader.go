@@ -9,6 +9,7 @@ import (
 	"io"
 	"math"
 	"os"
+	"path/filepath"
 	"reflect"
 )
 
@@ -20,9 +21,17 @@ type inMemReaderAt interface {
 //
 // Callers *must* not modify bytes in the returned byte slice.
 //
-// If r is an in-memory representation, ReadAll will attempt to return a
+// If r is an in-memory representation, such as a bytes.Reader or an
+// MmapReaderAt, or a LazyOpenerAt of one, ReadAll will attempt to return a
 // pointer to those bytes directly.
 func ReadAll(r io.ReaderAt) ([]byte, error) {
+	if loa, ok := r.(*LazyOpenerAt); ok && loa != nil {
+		lr, err := loa.reader()
+		if err != nil {
+			return nil, err
+		}
+		r = lr
+	}
 	if imra, ok := r.(inMemReaderAt); ok {
 		return imra.Bytes(), nil
 	}
@@ -65,3 +74,65 @@ func ReadIntoFile(r io.Reader, p string) error {
 
 	return f.Close()
 }
+
+// AtomicOpts configures WriteFileAtomic.
+type AtomicOpts struct {
+	// Mode is the mode of the file, 0644 if 0. It is not masked by the
+	// umask.
+	Mode os.FileMode
+
+	// SyncDir syncs the directory of the file too, for the file to be
+	// there after a crash, not only whole if it is.
+	SyncDir bool
+}
+
+// WriteFileAtomic reads all from r into the file at path, atomically: into a
+// temporary file in the same directory, which is synced and then renamed to
+// path only if all of r was read. Unlike with ReadIntoFile, readers that fail
+// midway leave the file at path, if any, as it was, instead of truncated.
+//
+// As the file is replaced, path should not be a device or a symlink.
+func WriteFileAtomic(r io.Reader, path string, o AtomicOpts) (err error) {
+	if o.Mode == 0 {
+		o.Mode = 0o644
+	}
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+name+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	if err := f.Chmod(o.Mode); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	if !o.SyncDir {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -5,10 +5,13 @@
 package uio
 
 import (
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func readAndCheck(t *testing.T, want, tmpfileP string) {
@@ -62,3 +65,44 @@ func TestReadIntoFile(t *testing.T) {
 	}
 	readAndCheck(t, want, p)
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "kernel")
+	if err := os.WriteFile(p, []byte("old kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reader that fails midway leaves the file as it was.
+	errNet := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader("new ker"), iotest.ErrReader(errNet))
+	if err := WriteFileAtomic(r, p, AtomicOpts{}); !errors.Is(err, errNet) {
+		t.Errorf("WriteFileAtomic with a failing reader = %v, want %v", err, errNet)
+	}
+	if got, err := os.ReadFile(p); err != nil || string(got) != "old kernel" {
+		t.Errorf("file is %q, %v after a failed write, want %q", got, err, "old kernel")
+	}
+
+	if err := WriteFileAtomic(strings.NewReader("new kernel"), p, AtomicOpts{Mode: 0o600, SyncDir: true}); err != nil {
+		t.Fatalf("WriteFileAtomic = %v", err)
+	}
+	if got, err := os.ReadFile(p); err != nil || string(got) != "new kernel" {
+		t.Errorf("file is %q, %v, want %q", got, err, "new kernel")
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("file mode is %v, want %v", fi.Mode().Perm(), os.FileMode(0o600))
+	}
+
+	// No temporary files are left behind.
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("directory has %v, %v, want only the file", entries, err)
+	}
+
+	if err := WriteFileAtomic(strings.NewReader(""), filepath.Join(dir, "missing", "kernel"), AtomicOpts{}); err == nil {
+		t.Errorf("WriteFileAtomic in a missing directory = nil, want error")
+	}
+}
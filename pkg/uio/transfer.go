@@ -0,0 +1,181 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package uio
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Transfer is the state of a transfer of bytes, e.g. a download or a copy.
+type Transfer struct {
+	// Total is the size of what is transferred, or -1 if it is not known.
+	Total int64
+
+	// N is how many bytes were transferred.
+	N int64
+
+	// Elapsed is how long the transfer has taken, since the first read or
+	// write.
+	Elapsed time.Duration
+
+	// Done is whether the transfer is done.
+	Done bool
+}
+
+// Rate returns the average rate of the transfer, in bytes per second, or 0 if
+// no time elapsed.
+func (t Transfer) Rate() float64 {
+	if t.Elapsed <= 0 {
+		return 0
+	}
+	return float64(t.N) / t.Elapsed.Seconds()
+}
+
+// Remaining returns how long the rest of the transfer should take at the
+// average rate, or -1 if that is not known.
+func (t Transfer) Remaining() time.Duration {
+	r := t.Rate()
+	if t.Total < 0 || r == 0 {
+		return -1
+	}
+	if t.N >= t.Total {
+		return 0
+	}
+	return time.Duration(float64(t.Total-t.N) / r * float64(time.Second))
+}
+
+// TransferFunc is called with the state of a transfer as it progresses.
+type TransferFunc func(Transfer)
+
+// counter accounts for a transfer, and reports it.
+type counter struct {
+	interval time.Duration
+	fn       TransferFunc
+	now      func() time.Time
+
+	mu       sync.Mutex
+	t        Transfer
+	start    time.Time
+	reported time.Time
+}
+
+func newCounter(total int64, interval time.Duration, fn TransferFunc) *counter {
+	return &counter{t: Transfer{Total: total}, interval: interval, fn: fn, now: time.Now}
+}
+
+// add accounts for n more bytes, and whether the transfer is done, and
+// reports the transfer if the interval passed since it was last reported, or
+// as soon as it is done.
+func (c *counter) add(n int, done bool) {
+	c.mu.Lock()
+	now := c.now()
+	if c.start.IsZero() {
+		c.start = now
+	}
+	if c.t.Done {
+		c.mu.Unlock()
+		return
+	}
+	c.t.N += int64(n)
+	c.t.Elapsed = now.Sub(c.start)
+	c.t.Done = done || (c.t.Total >= 0 && c.t.N >= c.t.Total)
+	report := c.fn != nil && (c.t.Done || (n > 0 && (c.reported.IsZero() || now.Sub(c.reported) >= c.interval)))
+	if report {
+		c.reported = now
+	}
+	t := c.t
+	c.mu.Unlock()
+	if report {
+		c.fn(t)
+	}
+}
+
+func (c *counter) transfer() Transfer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.t
+	if !t.Done && !c.start.IsZero() {
+		t.Elapsed = c.now().Sub(c.start)
+	}
+	return t
+}
+
+// CountingReader is a reader that accounts for the bytes read through it. It
+// reports the transfer as it progresses to a TransferFunc, if any.
+type CountingReader struct {
+	r io.Reader
+	c *counter
+}
+
+// NewCountingReader returns a reader of r, of total bytes, or -1 if that is
+// not known, that calls fn, if it is not nil, after reads at most every
+// interval, and once the transfer is done: at io.EOF, or once total bytes
+// were read.
+//
+// The reader closes r when closed, if r is an io.Closer.
+func NewCountingReader(r io.Reader, total int64, interval time.Duration, fn TransferFunc) *CountingReader {
+	return &CountingReader{r: r, c: newCounter(total, interval, fn)}
+}
+
+// Read implements io.Reader.
+func (r *CountingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.c.add(n, err == io.EOF)
+	return n, err
+}
+
+// Close implements io.Closer.
+func (r *CountingReader) Close() error {
+	if c, ok := r.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Transfer returns the state of the transfer. It is safe to call while the
+// reader is read.
+func (r *CountingReader) Transfer() Transfer {
+	return r.c.transfer()
+}
+
+// ProgressWriter is a writer that accounts for the bytes written through it.
+// It reports the transfer as it progresses to a TransferFunc, if any.
+type ProgressWriter struct {
+	w io.Writer
+	c *counter
+}
+
+// NewProgressWriter returns a writer to w, of total bytes, or -1 if that is
+// not known, that calls fn, if it is not nil, after writes at most every
+// interval, and once the transfer is done: when the writer is closed, or once
+// total bytes were written.
+func NewProgressWriter(w io.Writer, total int64, interval time.Duration, fn TransferFunc) *ProgressWriter {
+	return &ProgressWriter{w: w, c: newCounter(total, interval, fn)}
+}
+
+// Write implements io.Writer.
+func (w *ProgressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.c.add(n, false)
+	return n, err
+}
+
+// Close ends the transfer, and closes the underlying writer if it is an
+// io.Closer.
+func (w *ProgressWriter) Close() error {
+	w.c.add(0, true)
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Transfer returns the state of the transfer. It is safe to call while the
+// writer is written.
+func (w *ProgressWriter) Transfer() Transfer {
+	return w.c.transfer()
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package uio
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a clock that advances by a second each time it is read.
+func fakeClock() func() time.Time {
+	t := time.Unix(0, 0)
+	return func() time.Time {
+		t = t.Add(time.Second)
+		return t
+	}
+}
+
+func TestCountingReader(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		total    int64
+		interval time.Duration
+		want     []Transfer
+	}{
+		{
+			name:  "every read",
+			total: 6,
+			want: []Transfer{
+				{Total: 6, N: 2},
+				{Total: 6, N: 4, Elapsed: time.Second},
+				{Total: 6, N: 6, Elapsed: 2 * time.Second, Done: true},
+			},
+		},
+		{
+			name:     "every 2s, unknown size",
+			total:    -1,
+			interval: 2 * time.Second,
+			want: []Transfer{
+				{Total: -1, N: 2},
+				{Total: -1, N: 6, Elapsed: 2 * time.Second},
+				{Total: -1, N: 6, Elapsed: 3 * time.Second, Done: true},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Transfer
+			r := NewCountingReader(strings.NewReader("abcdef"), tt.total, tt.interval, func(t Transfer) {
+				got = append(got, t)
+			})
+			r.c.now = fakeClock()
+			// Read 2 bytes at a time, and then io.EOF, which is
+			// not reported if the transfer is done already.
+			var b []byte
+			for {
+				p := make([]byte, 2)
+				n, err := r.Read(p)
+				b = append(b, p[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(b) != "abcdef" {
+				t.Fatalf("read %q, want abcdef", b)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reported %+v, want %+v", got, tt.want)
+			}
+			if tr := r.Transfer(); !tr.Done || tr.N != 6 {
+				t.Errorf("Transfer = %+v, want 6 bytes, done", tr)
+			}
+		})
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	var (
+		b   bytes.Buffer
+		got []Transfer
+	)
+	w := NewProgressWriter(&b, -1, time.Hour, func(t Transfer) {
+		got = append(got, t)
+	})
+	w.c.now = fakeClock()
+	if tr := w.Transfer(); tr.N != 0 || tr.Elapsed != 0 {
+		t.Errorf("Transfer before writing = %+v, want nothing", tr)
+	}
+	for _, s := range []string{"ab", "cd", "ef"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tr := w.Transfer(); tr.N != 6 || tr.Done || tr.Elapsed != 3*time.Second {
+		t.Errorf("Transfer = %+v, want 6 bytes in 3s, not done", tr)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []Transfer{
+		{Total: -1, N: 2},
+		{Total: -1, N: 6, Elapsed: 4 * time.Second, Done: true},
+	}
+	if !reflect.DeepEqual(got, want) || b.String() != "abcdef" {
+		t.Errorf("wrote %q, reported %+v, want abcdef, %+v", b.String(), got, want)
+	}
+}
+
+func TestTransferRate(t *testing.T) {
+	for _, tt := range []struct {
+		tr        Transfer
+		rate      float64
+		remaining time.Duration
+	}{
+		{tr: Transfer{Total: 100, N: 25, Elapsed: 5 * time.Second}, rate: 5, remaining: 15 * time.Second},
+		{tr: Transfer{Total: -1, N: 25, Elapsed: 5 * time.Second}, rate: 5, remaining: -1},
+		{tr: Transfer{Total: 100}, rate: 0, remaining: -1},
+		{tr: Transfer{Total: 100, N: 100, Elapsed: time.Second, Done: true}, rate: 100, remaining: 0},
+	} {
+		if r, rem := tt.tr.Rate(), tt.tr.Remaining(); r != tt.rate || rem != tt.remaining {
+			t.Errorf("%+v: Rate, Remaining = %v, %v, want %v, %v", tt.tr, r, rem, tt.rate, tt.remaining)
+		}
+	}
+}
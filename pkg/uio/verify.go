@@ -0,0 +1,247 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package uio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// DefaultVerifyBlockSize is the size of the blocks WriteAndVerify compares
+// by default.
+const DefaultVerifyBlockSize = 1 << 20
+
+// directAlign is the alignment of the buffers, offsets and sizes of reads
+// with O_DIRECT: the largest logical block size of common devices.
+const directAlign = 4096
+
+// WriteVerifyOpts configures WriteAndVerify.
+type WriteVerifyOpts struct {
+	// Hash is the hash of the image and of each block, sha256.New by
+	// default.
+	Hash func() hash.Hash
+
+	// BlockSize is the size of the blocks compared, a multiple of 4096,
+	// DefaultVerifyBlockSize by default. Mismatches are reported to the
+	// block.
+	BlockSize int
+
+	// Offset is where on the devices to write the image, a multiple of
+	// 4096 to read it back with O_DIRECT.
+	Offset int64
+
+	// Sparse skips writing the blocks of the image that are all zeros,
+	// for devices known to read as zeros, e.g. after a discard, and for
+	// files, which get holes. They are still read back, so devices that
+	// do not read as zeros fail verification.
+	Sparse bool
+}
+
+// Region is a region of a device.
+type Region struct {
+	Offset int64
+	Length int64
+}
+
+// VerifyError is returned by WriteAndVerify when devices do not read back
+// what was written to them.
+type VerifyError struct {
+	// Mismatches are the regions of each device that differ, at their
+	// offsets on the device, in order, with adjacent blocks merged.
+	Mismatches map[string][]Region
+}
+
+func (e *VerifyError) Error() string {
+	var paths []string
+	for path := range e.Mismatches {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var s []string
+	for _, path := range paths {
+		regions := e.Mismatches[path]
+		var n int64
+		for _, r := range regions {
+			n += r.Length
+		}
+		s = append(s, fmt.Sprintf("%s: %d bytes in %d regions from offset %d", path, n, len(regions), regions[0].Offset))
+	}
+	return "devices do not read back as written: " + strings.Join(s, ", ")
+}
+
+// WriteAndVerify writes the image in r to each device, or file, at paths,
+// hashing it on the way, syncs them, and reads them back, bypassing the page
+// cache with O_DIRECT where it can, to check that each block of each device
+// reads back as written.
+//
+// It returns the hash of the image, and its size. If devices do not read
+// back as written, the error is a *VerifyError.
+func WriteAndVerify(r io.Reader, opts WriteVerifyOpts, paths ...string) ([]byte, int64, error) {
+	if opts.Hash == nil {
+		opts.Hash = sha256.New
+	}
+	if opts.BlockSize == 0 {
+		opts.BlockSize = DefaultVerifyBlockSize
+	}
+	if opts.BlockSize < 0 || opts.BlockSize%directAlign != 0 {
+		return nil, 0, fmt.Errorf("block size %d is not a multiple of %d", opts.BlockSize, directAlign)
+	}
+	if len(paths) == 0 {
+		return nil, 0, errors.New("no device to write to")
+	}
+
+	sum, sums, n, err := writeAll(r, opts, paths)
+	if err != nil {
+		return nil, n, err
+	}
+	verr := &VerifyError{Mismatches: map[string][]Region{}}
+	for _, path := range paths {
+		regions, err := verify(path, opts, sums, n)
+		if err != nil {
+			return sum, n, err
+		}
+		if len(regions) > 0 {
+			verr.Mismatches[path] = regions
+		}
+	}
+	if len(verr.Mismatches) > 0 {
+		return sum, n, verr
+	}
+	return sum, n, nil
+}
+
+// writeAll writes r to the devices at paths, and returns its hash, the hash
+// of each of its blocks, and its size.
+func writeAll(r io.Reader, opts WriteVerifyOpts, paths []string) ([]byte, [][]byte, int64, error) {
+	var (
+		devs []*os.File
+		n    int64
+	)
+	whole := opts.Hash()
+	ws := []io.Writer{whole}
+	for _, path := range paths {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		defer f.Close()
+		if _, err := f.Seek(opts.Offset, io.SeekStart); err != nil {
+			return nil, nil, 0, err
+		}
+		devs = append(devs, f)
+		ws = append(ws, f)
+	}
+	w := io.MultiWriter(ws...)
+	var sums [][]byte
+	buf := make([]byte, opts.BlockSize)
+	for {
+		m, err := io.ReadFull(r, buf)
+		if m > 0 {
+			if opts.Sparse && isZero(buf[:m]) {
+				whole.Write(buf[:m])
+				for _, f := range devs {
+					if _, err := f.Seek(int64(m), io.SeekCurrent); err != nil {
+						return nil, nil, n, err
+					}
+				}
+			} else if _, err := w.Write(buf[:m]); err != nil {
+				return nil, nil, n, err
+			}
+			h := opts.Hash()
+			h.Write(buf[:m])
+			sums = append(sums, h.Sum(nil))
+			n += int64(m)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, n, err
+		}
+	}
+	for _, f := range devs {
+		// Files that end with a hole need to be extended over it.
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() && fi.Size() < opts.Offset+n {
+			if err := f.Truncate(opts.Offset + n); err != nil {
+				return nil, nil, n, err
+			}
+		}
+		if err := f.Sync(); err != nil {
+			return nil, nil, n, err
+		}
+		if err := f.Close(); err != nil {
+			return nil, nil, n, err
+		}
+	}
+	return whole.Sum(nil), sums, n, nil
+}
+
+// verify reads back the n bytes written to the device at path, and returns
+// the regions whose blocks do not have the hashes in sums.
+func verify(path string, opts WriteVerifyOpts, sums [][]byte, n int64) ([]Region, error) {
+	f, err := openDirect(path, opts.Offset%directAlign == 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var regions []Region
+	buf := alignedBuffer(opts.BlockSize)
+	for i, want := range sums {
+		off := int64(i) * int64(opts.BlockSize)
+		size := int(n - off)
+		if size > opts.BlockSize {
+			size = opts.BlockSize
+		}
+		// Reads with O_DIRECT are of whole logical blocks.
+		aligned := (size + directAlign - 1) / directAlign * directAlign
+		m, err := f.ReadAt(buf[:aligned], opts.Offset+off)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		h := opts.Hash()
+		if m >= size {
+			h.Write(buf[:size])
+		}
+		if m >= size && bytes.Equal(h.Sum(nil), want) {
+			continue
+		}
+		if l := len(regions) - 1; l >= 0 && regions[l].Offset+regions[l].Length == opts.Offset+off {
+			regions[l].Length += int64(size)
+		} else {
+			regions = append(regions, Region{Offset: opts.Offset + off, Length: int64(size)})
+		}
+	}
+	return regions, nil
+}
+
+// isZero returns whether b is all zeros.
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// alignedBuffer returns a buffer of n bytes aligned in memory as reads with
+// O_DIRECT need.
+func alignedBuffer(n int) []byte {
+	b := make([]byte, n+directAlign)
+	off := int(uintptr(unsafe.Pointer(&b[0])) & (directAlign - 1))
+	if off != 0 {
+		off = directAlign - off
+	}
+	return b[off : off+n]
+}
@@ -0,0 +1,24 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package uio
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openDirect opens path to read, with O_DIRECT if direct is set and the file
+// system supports it, like block devices do and tmpfs does not.
+func openDirect(path string, direct bool) (*os.File, error) {
+	if direct {
+		f, err := os.OpenFile(path, os.O_RDONLY|unix.O_DIRECT, 0)
+		if !errors.Is(err, unix.EINVAL) {
+			return f, err
+		}
+	}
+	return os.Open(path)
+}
@@ -0,0 +1,15 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package uio
+
+import "os"
+
+// openDirect opens path to read. The page cache is only bypassed on Linux.
+func openDirect(path string, direct bool) (*os.File, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package uio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestWriteAndVerify(t *testing.T) {
+	image := make([]byte, 5*4096+100)
+	rand.New(rand.NewSource(1)).Read(image)
+	want := sha256.Sum256(image)
+
+	dir := t.TempDir()
+	var devs []string
+	for _, name := range []string{"a", "b"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, make([]byte, 64<<10), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		devs = append(devs, p)
+	}
+	sum, n, err := WriteAndVerify(bytes.NewReader(image), WriteVerifyOpts{BlockSize: 8192, Offset: 4096}, devs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sum, want[:]) || n != int64(len(image)) {
+		t.Errorf("WriteAndVerify = %x, %d, want %x, %d", sum, n, want, len(image))
+	}
+	for _, p := range devs {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b[4096:4096+len(image)], image) || len(b) != 64<<10 {
+			t.Errorf("%s does not hold the image at 4096", p)
+		}
+	}
+
+	if _, _, err := WriteAndVerify(bytes.NewReader(image), WriteVerifyOpts{BlockSize: 1000}, devs...); err == nil {
+		t.Errorf("WriteAndVerify with a block size of 1000 = nil, want error")
+	}
+	if _, _, err := WriteAndVerify(bytes.NewReader(image), WriteVerifyOpts{}, filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("WriteAndVerify to a missing device = nil, want error")
+	}
+}
+
+func TestWriteAndVerifySparse(t *testing.T) {
+	image := make([]byte, 4*4096)
+	copy(image[4096:], "data")
+	p := filepath.Join(t.TempDir(), "dev")
+	dev := bytes.Repeat([]byte{0xff}, 4*4096)
+	if err := os.WriteFile(p, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A file that ends with a hole is extended over it.
+	if _, _, err := WriteAndVerify(bytes.NewReader(image), WriteVerifyOpts{BlockSize: 4096, Sparse: true}, p); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(p); err != nil || !bytes.Equal(b, image) {
+		t.Errorf("sparse file = %q, %v, want %q", b, err, image)
+	}
+
+	// A device that does not read as zeros fails verification.
+	if err := os.WriteFile(p, dev, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := WriteAndVerify(bytes.NewReader(image), WriteVerifyOpts{BlockSize: 4096, Sparse: true}, p)
+	var verr *VerifyError
+	want := []Region{{Offset: 0, Length: 4096}, {Offset: 2 * 4096, Length: 2 * 4096}}
+	if !errors.As(err, &verr) || !reflect.DeepEqual(verr.Mismatches[p], want) {
+		t.Errorf("WriteAndVerify over ones = %v, want mismatches %v", err, want)
+	}
+}
+
+func TestVerifyMismatches(t *testing.T) {
+	image := make([]byte, 5*4096+100)
+	rand.New(rand.NewSource(2)).Read(image)
+	p := filepath.Join(t.TempDir(), "dev")
+	if err := os.WriteFile(p, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := WriteVerifyOpts{Hash: sha256.New, BlockSize: 4096}
+	_, sums, n, err := writeAll(bytes.NewReader(image), opts, []string{p})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt blocks 1 and 2, and cut the last one short.
+	f, err := os.OpenFile(p, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{^image[4096]}, 4096)
+	f.WriteAt([]byte{^image[3*4096-1]}, 3*4096-1)
+	f.Truncate(5*4096 + 50)
+	f.Close()
+
+	got, err := verify(p, opts, sums, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Region{{Offset: 4096, Length: 2 * 4096}, {Offset: 5 * 4096, Length: 100}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("verify = %v, want %v", got, want)
+	}
+
+	verr := &VerifyError{Mismatches: map[string][]Region{p: got}}
+	if want := "devices do not read back as written: " + p + ": 8292 bytes in 2 regions from offset 4096"; verr.Error() != want {
+		t.Errorf("VerifyError = %q, want %q", verr.Error(), want)
+	}
+}
+
+func TestAlignedBuffer(t *testing.T) {
+	for _, n := range []int{4096, 1 << 20} {
+		b := alignedBuffer(n)
+		if p := uintptr(unsafe.Pointer(&b[0])); len(b) != n || p%directAlign != 0 {
+			t.Errorf("alignedBuffer(%d) has length %d at %#x, want %d aligned on %d", n, len(b), p, n, directAlign)
+		}
+	}
+}
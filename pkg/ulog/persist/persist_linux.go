@@ -0,0 +1,324 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package persist keeps logs across reboots, in efi-pstore records, on a log
+// partition, or in a file, e.g. on the ESP, so that they can be read after a
+// failure that also kept them from being forwarded over the network.
+package persist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	guid "github.com/google/uuid"
+	"github.com/u-root/u-root/pkg/efivarfs"
+	"github.com/u-root/u-root/pkg/mount"
+)
+
+// File is a log file, which every write is synced to.
+type File struct {
+	f  *os.File
+	mp *mount.MountPoint
+}
+
+// OpenFile opens the log file path, to append to it.
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &File{f: f}, nil
+}
+
+// OpenESPFile mounts the EFI system partition dev, and opens the log file
+// path in it, to append to it. Close unmounts it.
+func OpenESPFile(dev, path string) (*File, error) {
+	dir, err := os.MkdirTemp("", "esp")
+	if err != nil {
+		return nil, err
+	}
+	mp, err := mount.Mount(dev, dir, "vfat", "", 0)
+	if err != nil {
+		os.Remove(dir)
+		return nil, err
+	}
+	f, err := OpenFile(filepath.Join(dir, path))
+	if err != nil {
+		mp.Unmount(0)
+		os.Remove(dir)
+		return nil, err
+	}
+	f.mp = mp
+	return f, nil
+}
+
+// Write appends p to the file, and syncs it.
+func (f *File) Write(p []byte) (int, error) {
+	n, err := f.f.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, f.f.Sync()
+}
+
+// Close closes the file, and unmounts the ESP it is on.
+func (f *File) Close() error {
+	err := f.f.Close()
+	if f.mp != nil {
+		if uerr := f.mp.Unmount(0); err == nil {
+			err = uerr
+		}
+		os.Remove(f.mp.Path)
+	}
+	return err
+}
+
+// partitionMagic starts the header of a log partition.
+var partitionMagic = [8]byte{'U', 'R', 'O', 'O', 'T', 'L', 'O', 'G'}
+
+// partitionHeaderSize is the size of the header of a log partition, which
+// the log follows.
+const partitionHeaderSize = 512
+
+// partitionHeader is the header of a log partition.
+type partitionHeader struct {
+	Magic [8]byte
+	// Start is when the log started, in nanoseconds since the epoch.
+	Start int64
+	// Size is the number of bytes written to the log, of which the
+	// partition holds the last.
+	Size uint64
+}
+
+// ErrNoLog is returned by ReadPartition for partitions that have no log.
+var ErrNoLog = errors.New("no log on the partition")
+
+// Partition is a log partition, which holds the end of one log, replaced
+// every time it is opened. Every write is synced to it.
+type Partition struct {
+	mu       sync.Mutex
+	f        *os.File
+	h        partitionHeader
+	capacity uint64
+}
+
+func partitionCapacity(f *os.File) (uint64, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if size <= partitionHeaderSize {
+		return 0, fmt.Errorf("%s is too small for a log, at %d bytes", f.Name(), size)
+	}
+	return uint64(size - partitionHeaderSize), nil
+}
+
+// OpenPartition starts a new log on the partition dev.
+func OpenPartition(dev string) (*Partition, error) {
+	f, err := os.OpenFile(dev, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	c, err := partitionCapacity(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p := &Partition{
+		f:        f,
+		h:        partitionHeader{Magic: partitionMagic, Start: time.Now().UnixNano()},
+		capacity: c,
+	}
+	if err := p.writeHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Partition) writeHeader() error {
+	var b bytes.Buffer
+	if err := binary.Write(&b, binary.LittleEndian, p.h); err != nil {
+		return err
+	}
+	if _, err := p.f.WriteAt(b.Bytes(), 0); err != nil {
+		return err
+	}
+	return p.f.Sync()
+}
+
+// Write appends b to the log, overwriting its start once the partition is
+// full.
+func (p *Partition) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(b)
+	if uint64(len(b)) > p.capacity {
+		p.h.Size += uint64(len(b)) - p.capacity
+		b = b[uint64(len(b))-p.capacity:]
+	}
+	for len(b) > 0 {
+		off := p.h.Size % p.capacity
+		chunk := b
+		if uint64(len(chunk)) > p.capacity-off {
+			chunk = chunk[:p.capacity-off]
+		}
+		if _, err := p.f.WriteAt(chunk, int64(partitionHeaderSize+off)); err != nil {
+			return 0, err
+		}
+		p.h.Size += uint64(len(chunk))
+		b = b[len(chunk):]
+	}
+	if err := p.writeHeader(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Close closes the partition.
+func (p *Partition) Close() error {
+	return p.f.Close()
+}
+
+// ReadPartition returns when the log of the partition dev started, and as
+// much of it as the partition holds.
+func ReadPartition(dev string) (time.Time, []byte, error) {
+	f, err := os.Open(dev)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	defer f.Close()
+	c, err := partitionCapacity(f)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	var h partitionHeader
+	if err := binary.Read(io.NewSectionReader(f, 0, partitionHeaderSize), binary.LittleEndian, &h); err != nil {
+		return time.Time{}, nil, err
+	}
+	if h.Magic != partitionMagic {
+		return time.Time{}, nil, ErrNoLog
+	}
+	data := make([]byte, c)
+	if _, err := f.ReadAt(data, partitionHeaderSize); err != nil {
+		return time.Time{}, nil, err
+	}
+	start := time.Unix(0, h.Start)
+	if h.Size <= c {
+		return start, data[:h.Size], nil
+	}
+	off := h.Size % c
+	return start, append(data[off:], data[:off]...), nil
+}
+
+// CrashGUID is the vendor GUID of the variables of efi-pstore.
+var CrashGUID = guid.MustParse("cfc8fc79-be2e-4ddc-97f0-9f98bfe298a0")
+
+// Defaults of EFIPstore.
+const (
+	// RecordSize is the size of the variables of efi-pstore.
+	RecordSize = 1024
+
+	// DefaultMaxRecords is the number of variables EFIPstore keeps,
+	// since NVRAM is small.
+	DefaultMaxRecords = 16
+)
+
+// EFIPstore writes a log to EFI variables, as the efi-pstore records of the
+// kernel, which the next kernel shows in the pstore file system.
+//
+// Since writing NVRAM is slow and wears it, a record is only written once it
+// is full, and on Flush.
+type EFIPstore struct {
+	// MaxRecords is the number of records to keep: the oldest are removed
+	// to write more.
+	MaxRecords int
+
+	mu      sync.Mutex
+	vars    efivarfs.EFIVar
+	start   int64
+	part    int
+	cur     []byte
+	written []efivarfs.VariableDescriptor
+}
+
+// NewEFIPstore returns an EFIPstore writing to vars.
+func NewEFIPstore(vars efivarfs.EFIVar) *EFIPstore {
+	return &EFIPstore{
+		MaxRecords: DefaultMaxRecords,
+		vars:       vars,
+		start:      time.Now().Unix(),
+		part:       1,
+	}
+}
+
+// desc returns the variable of the current record, named like those of the
+// kernel: dump-type0 for a dmesg record, the part, the count, the time and D
+// for uncompressed.
+func (e *EFIPstore) desc() efivarfs.VariableDescriptor {
+	return efivarfs.VariableDescriptor{
+		Name: fmt.Sprintf("dump-type0-%d-0-%d-D", e.part, e.start),
+		GUID: CrashGUID,
+	}
+}
+
+// writeRecord writes the current record.
+func (e *EFIPstore) writeRecord() error {
+	d := e.desc()
+	if len(e.written) == 0 || e.written[len(e.written)-1] != d {
+		for len(e.written) >= e.MaxRecords && len(e.written) > 0 {
+			if err := e.vars.Remove(e.written[0]); err != nil {
+				return err
+			}
+			e.written = e.written[1:]
+		}
+		e.written = append(e.written, d)
+	}
+	return e.vars.Set(d, efivarfs.AttributeNonVolatile|efivarfs.AttributeBootserviceAccess|efivarfs.AttributeRuntimeAccess, e.cur)
+}
+
+// Write appends b to the log, and writes the records it fills.
+func (e *EFIPstore) Write(b []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := len(b)
+	for len(b) > 0 {
+		k := RecordSize - len(e.cur)
+		if k > len(b) {
+			k = len(b)
+		}
+		e.cur, b = append(e.cur, b[:k]...), b[k:]
+		if len(e.cur) < RecordSize {
+			break
+		}
+		if err := e.writeRecord(); err != nil {
+			return 0, err
+		}
+		e.cur = nil
+		e.part++
+	}
+	return n, nil
+}
+
+// Flush writes the record that is not full yet.
+func (e *EFIPstore) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.cur) == 0 {
+		return nil
+	}
+	return e.writeRecord()
+}
+
+// Close flushes the log.
+func (e *EFIPstore) Close() error {
+	return e.Flush()
+}
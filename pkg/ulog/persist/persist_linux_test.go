@@ -0,0 +1,152 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package persist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	for _, s := range []string{"one\n", "two\n"} {
+		f, err := OpenFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "one\ntwo\n" {
+		t.Errorf("log = %q, %v, want %q", b, err, "one\ntwo\n")
+	}
+}
+
+func TestPartition(t *testing.T) {
+	dev := filepath.Join(t.TempDir(), "part")
+	if err := os.WriteFile(dev, make([]byte, partitionHeaderSize+8), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadPartition(dev); !errors.Is(err, ErrNoLog) {
+		t.Errorf("ReadPartition of an empty partition = %v, want %v", err, ErrNoLog)
+	}
+
+	for _, tt := range []struct {
+		writes []string
+		want   string
+	}{
+		{writes: nil, want: ""},
+		{writes: []string{"abc"}, want: "abc"},
+		{writes: []string{"abcd", "efgh"}, want: "abcdefgh"},
+		{writes: []string{"abcdef", "ghij"}, want: "cdefghij"},
+		{writes: []string{"abc", "defghijklmn", "o"}, want: "hijklmno"},
+	} {
+		before := time.Now()
+		p, err := OpenPartition(dev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range tt.writes {
+			if n, err := p.Write([]byte(w)); err != nil || n != len(w) {
+				t.Fatalf("Write(%q) = %d, %v", w, n, err)
+			}
+		}
+		if err := p.Close(); err != nil {
+			t.Fatal(err)
+		}
+		start, got, err := ReadPartition(dev)
+		if err != nil || string(got) != tt.want || start.Before(before) {
+			t.Errorf("%q: ReadPartition = %v, %q, %v, want %q since %v", tt.writes, start, got, err, tt.want, before)
+		}
+	}
+
+	small := filepath.Join(t.TempDir(), "small")
+	if err := os.WriteFile(small, make([]byte, partitionHeaderSize), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenPartition(small); err == nil {
+		t.Errorf("OpenPartition of a partition without room for a log succeeded")
+	}
+}
+
+// fakeVars are EFI variables in memory.
+type fakeVars map[efivarfs.VariableDescriptor][]byte
+
+func (f fakeVars) Get(desc efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	return 0, f[desc], nil
+}
+
+func (f fakeVars) List() ([]efivarfs.VariableDescriptor, error) {
+	var l []efivarfs.VariableDescriptor
+	for d := range f {
+		l = append(l, d)
+	}
+	return l, nil
+}
+
+func (f fakeVars) Remove(desc efivarfs.VariableDescriptor) error {
+	delete(f, desc)
+	return nil
+}
+
+func (f fakeVars) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	f[desc] = append([]byte(nil), data...)
+	return nil
+}
+
+func TestEFIPstore(t *testing.T) {
+	vars := fakeVars{}
+	e := NewEFIPstore(vars)
+	e.MaxRecords = 2
+	name := func(part int) efivarfs.VariableDescriptor {
+		return efivarfs.VariableDescriptor{Name: fmt.Sprintf("dump-type0-%d-0-%d-D", part, e.start), GUID: CrashGUID}
+	}
+
+	if _, err := e.Write([]byte(strings.Repeat("a", RecordSize-1))); err != nil || len(vars) != 0 {
+		t.Fatalf("Write of less than a record = %v, wrote %d records, want none", err, len(vars))
+	}
+	if _, err := e.Write([]byte("ab")); err != nil || len(vars) != 1 {
+		t.Fatalf("Write filling a record = %v, wrote %d records, want 1", err, len(vars))
+	}
+	if err := e.Flush(); err != nil || len(vars) != 2 || !bytes.Equal(vars[name(2)], []byte("b")) {
+		t.Fatalf("Flush = %v, wrote %d records, want 2", err, len(vars))
+	}
+	if _, err := e.Write([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil || len(vars) != 2 || !bytes.Equal(vars[name(2)], []byte("bc")) {
+		t.Fatalf("Close = %v, record 2 is %q, want %q", err, vars[name(2)], "bc")
+	}
+
+	if _, err := e.Write([]byte(strings.Repeat("d", RecordSize))); err != nil {
+		t.Fatal(err)
+	}
+	if got := vars[name(2)]; len(got) != RecordSize || !strings.HasPrefix(string(got), "bcd") {
+		t.Errorf("record 2 is %d bytes, want %d starting with %q", len(got), RecordSize, "bcd")
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vars[name(1)]; ok || len(vars) != 2 || !bytes.Equal(vars[name(3)], []byte("dd")) {
+		t.Errorf("with 3 records written and MaxRecords 2, there are %d records, want the first removed", len(vars))
+	}
+	for d := range vars {
+		if d.GUID != CrashGUID || !strings.HasPrefix(d.Name, "dump-type0-") || !strings.HasSuffix(d.Name, "-D") {
+			t.Errorf("variable %v is not an efi-pstore dmesg record", d)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ulog
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// TraceEnv is the environment variable that enables tracing, a comma
+// separated list of tracer names like the -trace flag of commands, e.g.
+// UROOT_TRACE=curl,dhclient or UROOT_TRACE=all.
+const TraceEnv = "UROOT_TRACE"
+
+// TraceUsage is the usage of the -trace flag of commands.
+const TraceUsage = "comma separated list of subsystems to trace (e.g. curl,boot,mount,dhclient), or all; also set by $" + TraceEnv
+
+var (
+	traceMu  sync.Mutex
+	traceAll bool
+	traced          = make(map[string]bool)
+	traceLog Logger = log.New(os.Stderr, "", log.Lmicroseconds)
+)
+
+func init() {
+	EnableTrace(os.Getenv(TraceEnv))
+}
+
+// EnableTrace enables the tracers named in the comma separated list names.
+// "all" enables all tracers.
+func EnableTrace(names string) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	for _, name := range strings.Split(names, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case "all":
+			traceAll = true
+		default:
+			traced[name] = true
+		}
+	}
+}
+
+// SetTraceLogger sets where traces go, stderr by default.
+func SetTraceLogger(l Logger) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	traceLog = l
+}
+
+// Tracer prints debug traces of the subsystem it is named after, if it is
+// enabled with EnableTrace. Tracer is a Logger, so it can be given to
+// packages that log.
+type Tracer string
+
+// Enabled returns whether t prints.
+func (t Tracer) Enabled() bool {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	return traceAll || traced[string(t)]
+}
+
+func (t Tracer) logger() Logger {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	return traceLog
+}
+
+// Printf implements Logger.Printf.
+func (t Tracer) Printf(format string, v ...interface{}) {
+	if t.Enabled() {
+		t.logger().Print(string(t) + ": " + fmt.Sprintf(format, v...))
+	}
+}
+
+// Print implements Logger.Print.
+func (t Tracer) Print(v ...interface{}) {
+	if t.Enabled() {
+		t.logger().Print(string(t) + ": " + fmt.Sprint(v...))
+	}
+}
+
+// Trace prints the event with alternating keys and values kv, e.g.
+//
+//	t.Trace("fetch", "url", u, "attempt", 2)
+//
+// prints "curl: fetch url=http://10.0.0.1/kernel attempt=2". Values with
+// spaces are quoted, so traces can be parsed back. The password of a
+// *url.URL value is redacted.
+func (t Tracer) Trace(event string, kv ...interface{}) {
+	if !t.Enabled() {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(string(t))
+	b.WriteString(": ")
+	b.WriteString(event)
+	for i := 0; i < len(kv); i += 2 {
+		var v interface{} = "MISSING"
+		if i+1 < len(kv) {
+			v = kv[i+1]
+		}
+		var s string
+		switch v := v.(type) {
+		case *url.URL:
+			s = v.Redacted()
+		case url.URL:
+			s = v.Redacted()
+		default:
+			s = fmt.Sprint(v)
+		}
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = fmt.Sprintf("%q", s)
+		}
+		fmt.Fprintf(&b, " %v=%s", kv[i], s)
+	}
+	t.logger().Print(b.String())
+}
+
+// TraceFlag is a flag.Value, and a pflag.Value, of the tracers to enable,
+// for the -trace flag of commands:
+//
+//	flag.Var(ulog.TraceFlag{}, "trace", ulog.TraceUsage)
+type TraceFlag struct{}
+
+// String implements flag.Value.String.
+func (TraceFlag) String() string {
+	return ""
+}
+
+// Set implements flag.Value.Set.
+func (TraceFlag) Set(names string) error {
+	EnableTrace(names)
+	return nil
+}
+
+// Type implements pflag.Value.Type.
+func (TraceFlag) Type() string {
+	return "list"
+}
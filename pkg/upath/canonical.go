@@ -0,0 +1,100 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package upath
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Missing says which components of a path may be missing in Canonical.
+type Missing int
+
+const (
+	// NoneMissing requires every component to exist.
+	NoneMissing Missing = iota
+	// LastMissing allows the last component to be missing.
+	LastMissing
+	// AnyMissing allows any component to be missing.
+	AnyMissing
+)
+
+// maxLinks is how many symlinks Canonical follows before it gives up.
+const maxLinks = 40
+
+var errLoop = errors.New("too many levels of symbolic links")
+
+func components(p string) []string {
+	var c []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" && s != "." {
+			c = append(c, s)
+		}
+	}
+	return c
+}
+
+// Canonical returns the absolute path of p with every symlink resolved and
+// no ".", ".." or extra slashes, like readlink -f. Components that do not
+// exist are taken as they are, as far as missing allows.
+func Canonical(p string, missing Missing) (string, error) {
+	if p == "" {
+		return "", &os.PathError{Op: "canonicalize", Path: p, Err: syscall.ENOENT}
+	}
+	if !filepath.IsAbs(p) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(wd, p)
+	}
+	resolved := "/"
+	todo := components(p)
+	links := 0
+	// Once a component is missing, nothing under it can exist.
+	gone := false
+	for len(todo) > 0 {
+		c := todo[0]
+		todo = todo[1:]
+		if c == ".." {
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, c)
+		if gone {
+			resolved = next
+			continue
+		}
+		fi, err := os.Lstat(next)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && (missing == AnyMissing || missing == LastMissing && len(todo) == 0):
+			gone = true
+			resolved = next
+			continue
+		case err != nil:
+			return "", err
+		case fi.Mode()&os.ModeSymlink != 0:
+			if links++; links > maxLinks {
+				return "", &os.PathError{Op: "canonicalize", Path: p, Err: errLoop}
+			}
+			target, err := os.Readlink(next)
+			if err != nil {
+				return "", err
+			}
+			if filepath.IsAbs(target) {
+				resolved = "/"
+			}
+			todo = append(components(target), todo...)
+			continue
+		case !fi.IsDir() && len(todo) > 0 && missing != AnyMissing:
+			return "", &os.PathError{Op: "canonicalize", Path: next, Err: syscall.ENOTDIR}
+		}
+		resolved = next
+	}
+	return resolved, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package upath
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"a/b"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a/f"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"rel":  "a/b",
+		"abs":  filepath.Join(dir, "a"),
+		"up":   "../" + filepath.Base(dir) + "/a/f",
+		"loop": "loop",
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		path    string
+		missing Missing
+		want    string
+		wantErr bool
+	}{
+		{path: "a//./b/", want: "a/b"},
+		{path: "rel/..", want: "a"},
+		{path: "abs/b", want: "a/b"},
+		{path: "up", want: "a/f"},
+		{path: "rel/x", wantErr: true},
+		{path: "rel/x", missing: LastMissing, want: "a/b/x"},
+		{path: "rel/x/y", missing: LastMissing, wantErr: true},
+		{path: "rel/x/y/../z", missing: AnyMissing, want: "a/b/x/z"},
+		{path: "a/f/x", missing: LastMissing, wantErr: true},
+		{path: "loop", missing: AnyMissing, wantErr: true},
+	} {
+		got, err := Canonical(dir+"/"+tt.path, tt.missing)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Canonical(%q, %d) = %q, %v, want error %v", tt.path, tt.missing, got, err, tt.wantErr)
+			continue
+		}
+		if want := filepath.Join(dir, tt.want); err == nil && got != want {
+			t.Errorf("Canonical(%q, %d) = %q, want %q", tt.path, tt.missing, got, want)
+		}
+	}
+}
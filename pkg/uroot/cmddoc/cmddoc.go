@@ -0,0 +1,242 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cmddoc extracts the documentation of a command from its source, and
+// writes it as a man page and shell completions.
+//
+// The documentation is the package comment of the command, and its flags are
+// the calls of flag and pflag functions and FlagSet methods whose names and
+// usages are string literals.
+package cmddoc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flag is a flag of a command.
+type Flag struct {
+	// Name is the name of the flag, without dashes.
+	Name string
+
+	// Short is the one letter shorthand of pflag flags, if any.
+	Short string
+
+	// Usage is the usage message of the flag.
+	Usage string
+
+	// Bool is true if the flag takes no argument.
+	Bool bool
+}
+
+// Command is the documentation of a command.
+type Command struct {
+	// Name is the name of the command.
+	Name string
+
+	// Doc is the package comment of the command.
+	Doc string
+
+	// Flags are the flags of the command, sorted by name.
+	Flags []Flag
+}
+
+// flagArgs gives where the name of a flag is in the arguments of a flag
+// function, and how many arguments the function has.
+type flagArgs struct {
+	name, short, n int
+}
+
+// flagFunc is a function defining a flag of type typ.
+type flagFunc struct {
+	flagArgs
+	typ string
+}
+
+var (
+	plain  = flagArgs{name: 0, short: -1, n: 3} // String(name, value, usage)
+	ptr    = flagArgs{name: 1, short: -1, n: 4} // StringVar(p, name, value, usage)
+	short  = flagArgs{name: 0, short: 1, n: 4}  // StringP(name, short, value, usage)
+	ptrP   = flagArgs{name: 1, short: 2, n: 5}  // StringVarP(p, name, short, value, usage)
+	value  = flagArgs{name: 1, short: -1, n: 3} // Var(value, name, usage)
+	valueP = flagArgs{name: 1, short: 2, n: 4}  // VarP(value, name, short, usage)
+)
+
+// flagTypes are the types of flag functions, e.g. Duration for Duration,
+// DurationVar, DurationP and DurationVarP.
+var flagTypes = []string{
+	"Bool", "Duration", "Float64", "Int", "Int64", "String", "Uint", "Uint64",
+	"Float32", "Int8", "Int16", "Int32", "Uint8", "Uint16", "Uint32",
+	"BoolSlice", "IntSlice", "StringArray", "StringSlice", "Count",
+}
+
+var flagFuncs = func() map[string]flagFunc {
+	m := map[string]flagFunc{"Var": {value, ""}, "VarP": {valueP, ""}}
+	for _, t := range flagTypes {
+		m[t] = flagFunc{plain, t}
+		m[t+"Var"] = flagFunc{ptr, t}
+		m[t+"P"] = flagFunc{short, t}
+		m[t+"VarP"] = flagFunc{ptrP, t}
+	}
+	return m
+}()
+
+// Extract extracts the documentation of the command in the directory dir.
+// The command is named after dir.
+func Extract(dir string) (*Command, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	pkg, ok := pkgs["main"]
+	if !ok {
+		return nil, fmt.Errorf("%s: not a command", dir)
+	}
+
+	c := &Command{Name: filepath.Base(dir)}
+	flags := make(map[string]Flag)
+	// Files are visited in a fixed order, so the doc of the same command
+	// is the same on every build.
+	var names []string
+	for name := range pkg.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := pkg.Files[name]
+		if f.Doc != nil && c.Doc == "" {
+			c.Doc = strings.TrimSpace(f.Doc.Text())
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if fl, ok := flagCall(call); ok {
+					flags[fl.Name] = fl
+				}
+			}
+			return true
+		})
+	}
+	for _, fl := range flags {
+		c.Flags = append(c.Flags, fl)
+	}
+	sort.Slice(c.Flags, func(i, j int) bool { return c.Flags[i].Name < c.Flags[j].Name })
+	return c, nil
+}
+
+// flagCall returns the flag defined by call, if it defines one.
+func flagCall(call *ast.CallExpr) (Flag, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return Flag{}, false
+	}
+	args, ok := flagFuncs[sel.Sel.Name]
+	if !ok || len(call.Args) != args.n {
+		return Flag{}, false
+	}
+	name, ok := stringLit(call.Args[args.name])
+	if !ok || name == "" {
+		return Flag{}, false
+	}
+	usage, ok := stringLit(call.Args[args.n-1])
+	if !ok {
+		return Flag{}, false
+	}
+	fl := Flag{
+		Name:  name,
+		Usage: usage,
+		Bool:  args.typ == "Bool" || args.typ == "Count",
+	}
+	if args.short >= 0 {
+		fl.Short, _ = stringLit(call.Args[args.short])
+	}
+	return fl, true
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// options returns the options of fl, e.g. "-v" and "--verbose" for a pflag
+// flag.
+func (fl Flag) options() []string {
+	if fl.Short != "" {
+		return []string{"-" + fl.Short, "--" + fl.Name}
+	}
+	return []string{"-" + fl.Name}
+}
+
+// troff escapes s for troff, so that lines starting with . or ' are not
+// taken for requests.
+func troff(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteMan writes a man page of c to w.
+func (c *Command) WriteMan(w io.Writer) error {
+	// The first paragraph is the summary.
+	summary, rest, _ := strings.Cut(c.Doc, "\n\n")
+	summary = strings.Join(strings.Fields(summary), " ")
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH %s 1\n", strings.ToUpper(c.Name))
+	fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", c.Name, troff(strings.TrimSuffix(summary, ".")))
+	if rest = strings.TrimSpace(rest); rest != "" {
+		// Doc comments are laid out by hand, so they are not filled.
+		fmt.Fprintf(&b, ".SH DESCRIPTION\n.nf\n%s\n.fi\n", troff(rest))
+	}
+	if len(c.Flags) > 0 {
+		b.WriteString(".SH OPTIONS\n")
+		for _, fl := range c.Flags {
+			opts := fl.options()
+			for i, o := range opts {
+				opts[i] = `\fB` + troff(o) + `\fR`
+			}
+			arg := ""
+			if !fl.Bool {
+				arg = ` \fIvalue\fR`
+			}
+			fmt.Fprintf(&b, ".TP\n%s%s\n%s\n", strings.Join(opts, ", "), arg, troff(fl.Usage))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteBashCompletion writes a bash completion of the options of c to w.
+// Other arguments complete as file names.
+func (c *Command) WriteBashCompletion(w io.Writer) error {
+	var opts []string
+	for _, fl := range c.Flags {
+		opts = append(opts, fl.options()...)
+	}
+	_, err := fmt.Fprintf(w, "complete -o default -W %s %s\n", shellQuote(strings.Join(opts, " ")), shellQuote(c.Name))
+	return err
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
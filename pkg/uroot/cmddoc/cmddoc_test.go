@@ -0,0 +1,84 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmddoc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	c, err := Extract("testdata/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Command{
+		Name: "hello",
+		Doc:  "Hello greets\nthe world.\n\nSynopsis:\n\n\thello [-n NAME] [-v]\n\n.dot lines are escaped.",
+		Flags: []Flag{
+			{Name: "n", Usage: "who to greet"},
+			{Name: "v", Usage: "greet -loudly-", Bool: true},
+			{Name: "wait", Usage: "wait before greeting"},
+		},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Extract = %+v, want %+v", c, want)
+	}
+
+	if _, err := Extract("."); err == nil {
+		t.Errorf("Extract(cmddoc) = nil, want an error for a package that is not a command")
+	}
+}
+
+func TestWriteMan(t *testing.T) {
+	c := &Command{
+		Name: "hello",
+		Doc:  "Hello greets\nthe world.\n\n.dot lines are escaped.",
+		Flags: []Flag{
+			{Name: "n", Usage: "who to greet"},
+			{Name: "verbose", Short: "v", Usage: "greet -loudly-", Bool: true},
+		},
+	}
+	var b strings.Builder
+	if err := c.WriteMan(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `.TH HELLO 1
+.SH NAME
+hello \- Hello greets the world
+.SH DESCRIPTION
+.nf
+\&.dot lines are escaped.
+.fi
+.SH OPTIONS
+.TP
+\fB\-n\fR \fIvalue\fR
+who to greet
+.TP
+\fB\-v\fR, \fB\-\-verbose\fR
+greet \-loudly\-
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteMan = %q, want %q", got, want)
+	}
+}
+
+func TestWriteBashCompletion(t *testing.T) {
+	c := &Command{
+		Name: "hello",
+		Flags: []Flag{
+			{Name: "n"},
+			{Name: "verbose", Short: "v", Bool: true},
+		},
+	}
+	var b strings.Builder
+	if err := c.WriteBashCompletion(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "complete -o default -W '-n -v --verbose' 'hello'\n"; got != want {
+		t.Errorf("WriteBashCompletion = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Hello greets
+// the world.
+//
+// Synopsis:
+//
+//	hello [-n NAME] [-v]
+//
+// .dot lines are escaped.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var (
+	name    = flag.String("n", "world", "who to greet")
+	verbose bool
+	wait    time.Duration
+)
+
+func main() {
+	flag.BoolVar(&verbose, "v", false, "greet -loudly-")
+	fs := flag.NewFlagSet("hello", flag.ExitOnError)
+	fs.DurationVar(&wait, "wait", 0, "wait before greeting")
+	flag.Parse()
+	fmt.Println("hello", *name)
+}
@@ -0,0 +1,9 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "flag"
+
+var testOnly = flag.Bool("test-only", false, "not a flag of hello")
@@ -9,12 +9,14 @@
 package uroot
 
 import (
+	"bytes"
 	"debug/elf"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	gbbgolang "github.com/u-root/gobusybox/src/pkg/golang"
 	"github.com/u-root/u-root/pkg/cpio"
@@ -23,7 +25,9 @@ import (
 	"github.com/u-root/u-root/pkg/uflag"
 	"github.com/u-root/u-root/pkg/ulog"
 	"github.com/u-root/u-root/pkg/uroot/builder"
+	"github.com/u-root/u-root/pkg/uroot/cmddoc"
 	"github.com/u-root/u-root/pkg/uroot/initramfs"
+	"github.com/u-root/u-root/pkg/vfile"
 )
 
 // These constants are used in DefaultRamfs.
@@ -224,6 +228,24 @@ type Opts struct {
 	// Build options for building go binaries. Ultimate this holds all the
 	// args that end up being passed to `go build`.
 	BuildOpts *gbbgolang.BuildOpts
+
+	// BuildTime, if not zero, is recorded in etc/timestamp as decimal Unix
+	// seconds. init uses it as the earliest plausible time when
+	// uroot.timesync is given (see libinit.SyncTime).
+	BuildTime time.Time
+
+	// KeyRing, if not empty, is the path of a PGP key ring to add to the
+	// archive at vfile.DefaultKeyRingPath.
+	KeyRing string
+
+	// Policy, if not empty, is the path of a vfile policy file to add to
+	// the archive at vfile.DefaultPolicyPath.
+	Policy string
+
+	// Docs adds a man page and a bash completion of each command, made
+	// from its doc comment and flags, to usr/share/man/man1 and
+	// usr/share/bash-completion/completions.
+	Docs bool
 }
 
 // CreateInitramfs creates an initramfs built to opts' specifications.
@@ -289,6 +311,19 @@ func CreateInitramfs(logger ulog.Logger, opts Opts) error {
 			return fmt.Errorf("%v: could not add uinit arguments from UinitArgs (-uinitcmd) to initramfs", err)
 		}
 	}
+	if !opts.BuildTime.IsZero() && !archive.Files.Contains("etc/timestamp") {
+		if err := archive.AddRecord(cpio.StaticFile("etc/timestamp", fmt.Sprintf("%d\n", opts.BuildTime.Unix()), 0o444)); err != nil {
+			return fmt.Errorf("%v: could not add build time to initramfs", err)
+		}
+	}
+	if err := opts.addVerification(archive); err != nil {
+		return err
+	}
+	if opts.Docs {
+		if err := opts.addDocs(archive); err != nil {
+			return err
+		}
+	}
 	if err := opts.addSymlinkTo(logger, archive, opts.InitCmd, "init"); err != nil {
 		return fmt.Errorf("%v: specify -initcmd=\"\" to ignore this error and build without an init (or, did you specify a list, and are you missing github.com/u-root/u-root/cmds/core/init?)", err)
 	}
@@ -306,6 +341,65 @@ func CreateInitramfs(logger ulog.Logger, opts Opts) error {
 	return nil
 }
 
+// addVerification adds the key ring and policy of o to the archive, after
+// checking that they parse, so images do not fail to boot verified.
+func (o *Opts) addVerification(archive *initramfs.Opts) error {
+	if o.KeyRing != "" {
+		b, err := os.ReadFile(o.KeyRing)
+		if err != nil {
+			return err
+		}
+		if _, err := vfile.ReadKeyRing(bytes.NewReader(b)); err != nil {
+			return fmt.Errorf("key ring %q: %v", o.KeyRing, err)
+		}
+		if err := archive.AddRecord(cpio.StaticFile(strings.TrimPrefix(vfile.DefaultKeyRingPath, "/"), string(b), 0o444)); err != nil {
+			return fmt.Errorf("%v: could not add key ring to initramfs", err)
+		}
+	}
+	if o.Policy != "" {
+		b, err := os.ReadFile(o.Policy)
+		if err != nil {
+			return err
+		}
+		if _, err := vfile.ParsePolicy(b); err != nil {
+			return fmt.Errorf("policy %q: %v", o.Policy, err)
+		}
+		if err := archive.AddRecord(cpio.StaticFile(strings.TrimPrefix(vfile.DefaultPolicyPath, "/"), string(b), 0o444)); err != nil {
+			return fmt.Errorf("%v: could not add policy to initramfs", err)
+		}
+	}
+	return nil
+}
+
+// addDocs adds the man pages and bash completions of o's commands to the
+// archive.
+func (o *Opts) addDocs(archive *initramfs.Opts) error {
+	for _, cmds := range o.Commands {
+		for _, dir := range cmds.Packages {
+			c, err := cmddoc.Extract(dir)
+			if err != nil {
+				return fmt.Errorf("documenting %s: %v", dir, err)
+			}
+			var man, completion bytes.Buffer
+			if err := c.WriteMan(&man); err != nil {
+				return err
+			}
+			if err := c.WriteBashCompletion(&completion); err != nil {
+				return err
+			}
+			for _, r := range []cpio.Record{
+				cpio.StaticFile(path.Join("usr/share/man/man1", c.Name+".1"), man.String(), 0o444),
+				cpio.StaticFile(path.Join("usr/share/bash-completion/completions", c.Name), completion.String(), 0o444),
+			} {
+				if err := archive.AddRecord(r); err != nil {
+					return fmt.Errorf("%v: could not add documentation of %s to initramfs", err, c.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (o *Opts) addSymlinkTo(logger ulog.Logger, archive *initramfs.Opts, command string, source string) error {
 	if len(command) == 0 {
 		return nil
@@ -12,10 +12,12 @@ import (
 	"reflect"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/u-root/u-root/pkg/cpio"
 	"github.com/u-root/u-root/pkg/golang"
 	"github.com/u-root/u-root/pkg/uroot/builder"
+	"github.com/u-root/u-root/pkg/uroot/initramfs"
 	itest "github.com/u-root/u-root/pkg/uroot/initramfs/test"
 )
 
@@ -184,6 +186,21 @@ func TestCreateInitramfs(t *testing.T) {
 		t.Error(err)
 	}
 
+	keyRingPath := filepath.Join(urootpath, "pkg/vfile/testdata/key0")
+	keyRing, err := os.ReadFile(keyRingPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policyPath := filepath.Join(dir, "policy.json")
+	policy := `{"threshold": 2}`
+	if err := os.WriteFile(policyPath, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	badPolicyPath := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(badPolicyPath, []byte(`{"threshold": -1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
 	// Why doesn't the log package export this as a default?
 	l := log.New(os.Stdout, "", log.LstdFlags)
 
@@ -245,6 +262,44 @@ func TestCreateInitramfs(t *testing.T) {
 				itest.MissingFile{"bbin/bb"},
 			},
 		},
+		{
+			name: "build time",
+			opts: Opts{
+				Env:       golang.Default(),
+				TempDir:   dir,
+				BuildTime: time.Unix(1650000000, 0),
+			},
+			want: "",
+			validators: []itest.ArchiveValidator{
+				itest.HasRecord{cpio.StaticFile("etc/timestamp", "1650000000\n", 0o444)},
+			},
+		},
+		{
+			name: "key ring and policy",
+			opts: Opts{
+				Env:     golang.Default(),
+				TempDir: dir,
+				KeyRing: keyRingPath,
+				Policy:  policyPath,
+			},
+			want: "",
+			validators: []itest.ArchiveValidator{
+				itest.HasRecord{cpio.StaticFile("etc/u-root/keyring.gpg", string(keyRing), 0o444)},
+				itest.HasRecord{cpio.StaticFile("etc/u-root/policy.json", policy, 0o444)},
+			},
+		},
+		{
+			name: "bad policy",
+			opts: Opts{
+				Env:     golang.Default(),
+				TempDir: dir,
+				Policy:  badPolicyPath,
+			},
+			want: fmt.Sprintf("policy %q: policy: negative threshold -1", badPolicyPath),
+			validators: []itest.ArchiveValidator{
+				itest.IsEmpty{},
+			},
+		},
 		{
 			name: "init specified, but not in commands",
 			opts: Opts{
@@ -339,3 +394,30 @@ func TestCreateInitramfs(t *testing.T) {
 		})
 	}
 }
+
+func TestAddDocs(t *testing.T) {
+	o := &Opts{
+		Commands: []Commands{{Packages: []string{"cmddoc/testdata/hello"}}},
+	}
+	archive := &initramfs.Opts{Files: initramfs.NewFiles()}
+	if err := o.addDocs(archive); err != nil {
+		t.Fatal(err)
+	}
+	a := inMemArchive{cpio.InMemArchive()}
+	if err := archive.Files.WriteTo(a); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []itest.ArchiveValidator{
+		itest.HasFile{Path: "usr/share/man/man1/hello.1"},
+		itest.HasContent{Path: "usr/share/bash-completion/completions/hello", Content: "complete -o default -W '-n -v -wait' 'hello'\n"},
+	} {
+		if err := v.Validate(a.Archive); err != nil {
+			t.Error(err)
+		}
+	}
+
+	o.Commands[0].Packages = []string{"cmddoc"}
+	if err := o.addDocs(&initramfs.Opts{Files: initramfs.NewFiles()}); err == nil {
+		t.Errorf("addDocs(library package) = nil, want an error")
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// ErrNothingToVerify is given for a FileSpec with neither a hash nor a key
+// ring.
+var ErrNothingToVerify = errors.New("VerifyAll: no expected hash or keyring given")
+
+// FileSpec is a file for VerifyAll to verify, against a hash, signatures, or
+// both.
+type FileSpec struct {
+	// Path is the file to verify.
+	Path string
+
+	// Hash and WantHash, if WantHash is not empty, are the hash function
+	// and the hash the contents must have, as for OpenHashedFile.
+	Hash     crypto.Hash
+	WantHash []byte
+
+	// KeyRing, if not nil, must have signed the file with detached
+	// signatures in SigPath. If SigPath is empty, they are expected in
+	// Path.sig or Path.asc, as for OpenSignedSigFile.
+	KeyRing openpgp.KeyRing
+	SigPath string
+
+	// Policy is what the signatures must satisfy. The zero Policy accepts
+	// one good signature by any key of KeyRing.
+	Policy Policy
+}
+
+// FileResult is the result of verifying a FileSpec.
+type FileResult struct {
+	// Path is the file.
+	Path string
+
+	// File is the contents of the file, if it could be read. As with
+	// OpenHashedFile, it is set even if the file did not verify.
+	File *File
+
+	// Signatures are those which verified the file, if it was signed.
+	Signatures []*VerificationResult
+
+	// Err is why the file did not verify, or could not be measured.
+	Err error
+}
+
+// ErrVerifyAll is returned by VerifyAll when some files did not verify.
+type ErrVerifyAll struct {
+	// Errs are the errors of the files that did not verify, in the order
+	// of the FileSpecs.
+	Errs []error
+
+	// Files is the number of files verified.
+	Files int
+}
+
+func (e ErrVerifyAll) Error() string {
+	s := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		s[i] = err.Error()
+	}
+	return fmt.Sprintf("%d of %d files did not verify: %s", len(e.Errs), e.Files, strings.Join(s, "; "))
+}
+
+// VerifyAll verifies the files of specs concurrently, with as many workers as
+// there are CPUs, and returns a FileResult for each of them, in the same
+// order.
+//
+// A file that does not verify does not stop the others: the errors of all
+// of them are returned in an ErrVerifyAll. Once ctx is done, files not yet
+// started are not verified, and their error is that of ctx.
+//
+// If there is a Measurer, files that verified are measured in the order of
+// specs once all of them are verified, so that the PCR does not depend on
+// which file was quickest to verify.
+func VerifyAll(ctx context.Context, specs []FileSpec) ([]FileResult, error) {
+	results := make([]FileResult, len(specs))
+	contents := make([][]byte, len(specs))
+
+	workers := runtime.NumCPU()
+	if workers > len(specs) {
+		workers = len(specs)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := ctx.Err(); err != nil {
+					results[i] = FileResult{Path: specs[i].Path, Err: fmt.Errorf("file %q: %w", specs[i].Path, err)}
+					continue
+				}
+				results[i], contents[i] = verifySpec(specs[i])
+			}
+		}()
+	}
+	for i := range specs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var errs []error
+	for i, r := range results {
+		if r.Err == nil {
+			if err := measure(r.Path, contents[i]); err != nil {
+				results[i].Err = err
+			}
+		}
+		if results[i].Err != nil {
+			errs = append(errs, results[i].Err)
+		}
+	}
+	if errs != nil {
+		return results, ErrVerifyAll{Errs: errs, Files: len(specs)}
+	}
+	return results, nil
+}
+
+// verifySpec verifies the file of s, without measuring it, and returns its
+// contents if it verified.
+func verifySpec(s FileSpec) (FileResult, []byte) {
+	r := FileResult{Path: s.Path}
+	if len(s.WantHash) == 0 && s.KeyRing == nil {
+		r.Err = fmt.Errorf("file %q: %w", s.Path, ErrNothingToVerify)
+		return r, nil
+	}
+	read := os.ReadFile
+	if s.KeyRing != nil {
+		read = readSignedFile
+	}
+	content, err := read(s.Path)
+	if err != nil {
+		r.Err = err
+		return r, nil
+	}
+	r.File = &File{
+		Reader:   bytes.NewReader(content),
+		FileName: s.Path,
+	}
+
+	if len(s.WantHash) > 0 {
+		if r.Err = checkHash(s.Path, s.Hash, s.WantHash, content); r.Err != nil {
+			return r, nil
+		}
+	}
+	if s.KeyRing != nil {
+		pathSig := s.SigPath
+		if pathSig == "" {
+			pathSig = sigPath(s.Path)
+		}
+		sig, err := readSignatureFile(pathSig)
+		if err != nil {
+			r.Err = ErrUnsigned{Path: s.Path, Err: err}
+			return r, nil
+		}
+		if r.Signatures, r.Err = s.Policy.Check(s.KeyRing, content, sig); r.Err != nil {
+			r.Err = ErrUnsigned{Path: s.Path, Err: r.Err}
+			return r, nil
+		}
+	}
+	return r, content
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestVerifyAll(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+
+	signed := filepath.Join(dir, "signed")
+	if err := (signedFile{signers: keys[:1], content: "kernel"}).write(signed); err != nil {
+		t.Fatal(err)
+	}
+	hashed := filepath.Join(dir, "hashed")
+	hash, err := writeHashedFile(hashed, "initramfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kernel := sha256.Sum256([]byte("kernel"))
+	ring := openpgp.EntityList(keys)
+
+	for _, tt := range []struct {
+		desc string
+		spec FileSpec
+		// wantErr is whether the file does not verify.
+		wantErr bool
+		// wantSigs is how many signatures verified it.
+		wantSigs int
+	}{
+		{
+			desc: "hash",
+			spec: FileSpec{Path: hashed, Hash: crypto.SHA256, WantHash: hash},
+		},
+		{
+			desc:     "signature",
+			spec:     FileSpec{Path: signed, KeyRing: ring},
+			wantSigs: 1,
+		},
+		{
+			desc:     "hash and signature",
+			spec:     FileSpec{Path: signed, Hash: crypto.SHA256, WantHash: kernel[:], KeyRing: ring, SigPath: signed + ".sig"},
+			wantSigs: 1,
+		},
+		{
+			desc:    "bad hash",
+			spec:    FileSpec{Path: signed, Hash: crypto.SHA256, WantHash: hash, KeyRing: ring},
+			wantErr: true,
+		},
+		{
+			desc:    "unsigned",
+			spec:    FileSpec{Path: hashed, KeyRing: ring},
+			wantErr: true,
+		},
+		{
+			desc:    "policy",
+			spec:    FileSpec{Path: signed, KeyRing: ring, Policy: Policy{Threshold: 2}},
+			wantErr: true,
+		},
+		{
+			desc:    "nothing to verify",
+			spec:    FileSpec{Path: hashed},
+			wantErr: true,
+		},
+	} {
+		results, err := VerifyAll(context.Background(), []FileSpec{tt.spec})
+		if len(results) != 1 {
+			t.Fatalf("%s: VerifyAll = %d results, want 1", tt.desc, len(results))
+		}
+		r := results[0]
+		if (err != nil) != tt.wantErr || (r.Err != nil) != tt.wantErr {
+			t.Errorf("%s: VerifyAll = %v, result %v, want error %v", tt.desc, err, r.Err, tt.wantErr)
+		}
+		if r.Path != tt.spec.Path {
+			t.Errorf("%s: result is of %q, want %q", tt.desc, r.Path, tt.spec.Path)
+		}
+		if errors.Is(r.Err, ErrNothingToVerify) != (tt.desc == "nothing to verify") {
+			t.Errorf("%s: VerifyAll = %v", tt.desc, r.Err)
+		} else if r.File == nil && !errors.Is(r.Err, ErrNothingToVerify) {
+			t.Errorf("%s: VerifyAll returned no file", tt.desc)
+		}
+		if !tt.wantErr && len(r.Signatures) != tt.wantSigs {
+			t.Errorf("%s: %d signatures verified, want %d", tt.desc, len(r.Signatures), tt.wantSigs)
+		}
+	}
+}
+
+func TestVerifyAllErrors(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		specs []FileSpec
+		want  [][]byte
+	)
+	for i := 0; i < 40; i++ {
+		content := fmt.Sprintf("module %d", i)
+		path := filepath.Join(dir, fmt.Sprintf("module%d", i))
+		hash, err := writeHashedFile(path, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Every tenth file does not verify.
+		if i%10 == 3 {
+			hash = make([]byte, len(hash))
+		} else {
+			want = append(want, hash)
+		}
+		specs = append(specs, FileSpec{Path: path, Hash: crypto.SHA256, WantHash: hash})
+	}
+	specs = append(specs, FileSpec{Path: filepath.Join(dir, "none"), Hash: crypto.SHA256, WantHash: want[0]})
+
+	m := &fakeMeasurer{}
+	SetMeasurer(m, 9)
+	defer SetMeasurer(nil, 0)
+
+	results, err := VerifyAll(context.Background(), specs)
+	var e ErrVerifyAll
+	if !errors.As(err, &e) || len(e.Errs) != 5 || e.Files != 41 {
+		t.Fatalf("VerifyAll = %v, want 5 of 41 files to fail", err)
+	}
+	if !errors.Is(e.Errs[4], os.ErrNotExist) || !errors.As(e.Errs[0], &ErrInvalidHash{}) {
+		t.Errorf("VerifyAll errors = %v, want 4 bad hashes and a missing file", e.Errs)
+	}
+	for i, r := range results {
+		if r.Path != specs[i].Path {
+			t.Errorf("result %d is of %q, want %q", i, r.Path, specs[i].Path)
+		}
+	}
+
+	// Only the files that verified are measured, in the order given.
+	if len(m.digests) != len(want) {
+		t.Fatalf("measured %d files, want %d", len(m.digests), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(m.digests[i], want[i]) {
+			t.Errorf("measurement %d = %x, want %x", i, m.digests[i], want[i])
+		}
+	}
+}
+
+func TestVerifyAllCanceled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kernel")
+	hash, err := writeHashedFile(path, "kernel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := VerifyAll(ctx, []FileSpec{{Path: path, Hash: crypto.SHA256, WantHash: hash}})
+	if !errors.As(err, &ErrVerifyAll{}) || !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("VerifyAll = %v, result %v, want %v", err, results[0].Err, context.Canceled)
+	}
+
+	if results, err := VerifyAll(ctx, nil); len(results) != 0 || err != nil {
+		t.Errorf("VerifyAll(nil) = %v, %v, want no results", results, err)
+	}
+}
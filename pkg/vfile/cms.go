@@ -0,0 +1,305 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// ASN.1 structures of CMS (RFC 5652) signed data, as far as detached
+// signatures need them.
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo cmsEncapContentInfo
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsEncapContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional,explicit,tag:0"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type cmsIssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+var cmsDigests = map[string]crypto.Hash{
+	oidSHA256.String(): crypto.SHA256,
+	oidSHA384.String(): crypto.SHA384,
+	oidSHA512.String(): crypto.SHA512,
+}
+
+// ErrNoCertPool is returned when no trusted certificates were given.
+var ErrNoCertPool = errors.New("no trusted certificates given")
+
+// CMSOptions are the trust settings for CMS signatures.
+type CMSOptions struct {
+	// Roots are the trusted certificate authorities.
+	Roots *x509.CertPool
+
+	// Intermediates are certificates to build chains with, in addition to
+	// those in the signature.
+	Intermediates *x509.CertPool
+
+	// KeyUsages are the extended key usages the signer certificate must
+	// allow. If empty, any usage is accepted.
+	KeyUsages []x509.ExtKeyUsage
+
+	// CheckExpiry checks that the certificates are valid now.
+	//
+	// Boot environments often have no reliable clock, so by default the
+	// certificates are checked at the signing time of the signature, or
+	// when the signer certificate became valid if it has none.
+	CheckExpiry bool
+}
+
+// VerifyCMSSignature checks the detached CMS (PKCS#7) signature sig, which
+// may be PEM encoded, of content, and returns the certificate that made it.
+//
+// The signer certificate must chain up to opts.Roots. If the signature does
+// not match content, the error is ErrBadSignature.
+func VerifyCMSSignature(opts CMSOptions, content, sig []byte) (*x509.Certificate, error) {
+	if opts.Roots == nil {
+		return nil, ErrNoCertPool
+	}
+	if block, _ := pem.Decode(sig); block != nil {
+		sig = block.Bytes
+	}
+	var ci cmsContentInfo
+	if _, err := asn1.Unmarshal(sig, &ci); err != nil {
+		return nil, fmt.Errorf("CMS signature: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("CMS signature: content type %v is not signed data", ci.ContentType)
+	}
+	var sd cmsSignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("CMS signature: %w", err)
+	}
+	if len(sd.EncapContentInfo.Content.Bytes) != 0 {
+		return nil, errors.New("CMS signature: not a detached signature")
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("CMS signature: %w", err)
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, errors.New("CMS signature: no signers")
+	}
+
+	var firstErr error
+	for _, si := range sd.SignerInfos {
+		cert, err := si.verify(opts, certs, sd.EncapContentInfo.ContentType, content)
+		if err == nil {
+			return cert, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// verify checks the signature of si, and the chain of its certificate.
+func (si *cmsSignerInfo) verify(opts CMSOptions, certs []*x509.Certificate, contentType asn1.ObjectIdentifier, content []byte) (*x509.Certificate, error) {
+	cert, err := si.signer(certs)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := cmsDigests[si.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("CMS signature: unsupported digest algorithm %v", si.DigestAlgorithm.Algorithm)
+	}
+	hh := h.New()
+	hh.Write(content)
+	digest := hh.Sum(nil)
+
+	signingTime := cert.NotBefore
+	if len(si.SignedAttrs.FullBytes) != 0 {
+		// The signature is of the DER encoding of the attributes as a
+		// SET OF, not of their implicitly tagged encoding here.
+		attrs := append([]byte{}, si.SignedAttrs.FullBytes...)
+		attrs[0] = 0x31
+		var parsed []cmsAttribute
+		if _, err := asn1.UnmarshalWithParams(attrs, &parsed, "set"); err != nil {
+			return nil, fmt.Errorf("CMS signature: %w", err)
+		}
+		var haveDigest bool
+		for _, a := range parsed {
+			if len(a.Values) != 1 {
+				continue
+			}
+			switch {
+			case a.Type.Equal(oidMessageDigest):
+				var md []byte
+				if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &md); err != nil {
+					return nil, fmt.Errorf("CMS signature: %w", err)
+				}
+				if !bytes.Equal(md, digest) {
+					return cert, ErrBadSignature{Err: errors.New("message digest does not match")}
+				}
+				haveDigest = true
+			case a.Type.Equal(oidContentType):
+				var ct asn1.ObjectIdentifier
+				if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &ct); err != nil || !ct.Equal(contentType) {
+					return nil, errors.New("CMS signature: content type attribute does not match")
+				}
+			case a.Type.Equal(oidSigningTime):
+				var t time.Time
+				if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &t); err == nil {
+					signingTime = t
+				}
+			}
+		}
+		if !haveDigest {
+			return nil, errors.New("CMS signature: no message digest attribute")
+		}
+		hh.Reset()
+		hh.Write(attrs)
+		digest = hh.Sum(nil)
+	}
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, h, digest, si.Signature)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, si.Signature) {
+			err = errors.New("ECDSA verification failure")
+		}
+	default:
+		return nil, fmt.Errorf("CMS signature: unsupported public key type %T", pub)
+	}
+	if err != nil {
+		return cert, ErrBadSignature{Err: err}
+	}
+
+	vopts := x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     opts.KeyUsages,
+		CurrentTime:   signingTime,
+	}
+	if opts.Intermediates != nil {
+		vopts.Intermediates = opts.Intermediates.Clone()
+	}
+	if len(vopts.KeyUsages) == 0 {
+		vopts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	if opts.CheckExpiry {
+		vopts.CurrentTime = time.Time{}
+	}
+	for _, c := range certs {
+		if c != cert {
+			vopts.Intermediates.AddCert(c)
+		}
+	}
+	if _, err := cert.Verify(vopts); err != nil {
+		return cert, err
+	}
+	return cert, nil
+}
+
+// signer returns the certificate of certs that si identifies.
+func (si *cmsSignerInfo) signer(certs []*x509.Certificate) (*x509.Certificate, error) {
+	switch {
+	case si.SID.Class == asn1.ClassUniversal && si.SID.Tag == asn1.TagSequence:
+		var ias cmsIssuerAndSerial
+		if _, err := asn1.Unmarshal(si.SID.FullBytes, &ias); err != nil {
+			return nil, fmt.Errorf("CMS signature: %w", err)
+		}
+		for _, c := range certs {
+			if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.Serial) == 0 {
+				return c, nil
+			}
+		}
+	case si.SID.Class == asn1.ClassContextSpecific && si.SID.Tag == 0:
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, si.SID.Bytes) {
+				return c, nil
+			}
+		}
+	}
+	return nil, errors.New("CMS signature: signer certificate not included")
+}
+
+// OpenCMSSignedFile opens a file that is expected to be signed with the
+// detached CMS (PKCS#7) signature in pathSig, and returns the certificate
+// that signed it.
+//
+// WARNING! Unlike many Go functions, this may return both the file and an
+// error.
+//
+// If the signature does not exist or does not verify, both the file and an
+// ErrUnsigned error will be returned, as OpenSignedFile does.
+func OpenCMSSignedFile(opts CMSOptions, path, pathSig string) (*File, *x509.Certificate, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := &File{
+		Reader:   bytes.NewReader(content),
+		FileName: path,
+	}
+
+	sig, err := readSignatureFile(pathSig)
+	if err != nil {
+		return f, nil, ErrUnsigned{Path: path, Err: err}
+	}
+	cert, err := VerifyCMSSignature(opts, content, sig)
+	if err != nil {
+		return f, nil, ErrUnsigned{Path: path, Err: err}
+	}
+	if err := measure(path, content); err != nil {
+		return f, cert, err
+	}
+	return f, cert, nil
+}
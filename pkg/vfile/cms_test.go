@@ -0,0 +1,180 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newCert(t *testing.T, serial int64, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer, notAfter time.Time) *x509.Certificate {
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "vfile test " + big.NewInt(serial).String()},
+		NotBefore:             time.Now().Add(-48 * time.Hour),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// cmsSign makes a detached CMS signature of content, with signed attributes
+// if signingTime is set.
+func cmsSign(t *testing.T, cert *x509.Certificate, key crypto.Signer, content []byte, signingTime time.Time) []byte {
+	mustMarshal := func(v interface{}, params string) []byte {
+		b, err := asn1.MarshalWithParams(v, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	digest := sha256.Sum256(content)
+	toSign := digest[:]
+
+	si := cmsSignerInfo{
+		Version:            1,
+		SID:                asn1.RawValue{FullBytes: mustMarshal(cmsIssuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, Serial: cert.SerialNumber}, "")},
+		DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}},
+	}
+	if !signingTime.IsZero() {
+		attrs := mustMarshal([]cmsAttribute{
+			{Type: oidContentType, Values: []asn1.RawValue{{FullBytes: mustMarshal(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}, "")}}},
+			{Type: oidSigningTime, Values: []asn1.RawValue{{FullBytes: mustMarshal(signingTime.UTC(), "utc")}}},
+			{Type: oidMessageDigest, Values: []asn1.RawValue{{FullBytes: mustMarshal(digest[:], "")}}},
+		}, "set")
+		h := sha256.Sum256(attrs)
+		toSign = h[:]
+		attrs[0] = 0xa0
+		si.SignedAttrs = asn1.RawValue{FullBytes: attrs}
+	}
+	sig, err := key.Sign(rand.Reader, toSign, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	si.Signature = sig
+
+	sd := cmsSignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: cmsEncapContentInfo{ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: cert.Raw},
+		SignerInfos:      []cmsSignerInfo{si},
+	}
+	return mustMarshal(cmsContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{FullBytes: mustMarshal(sd, "explicit,tag:0")},
+	}, "")
+}
+
+func TestOpenCMSSignedFile(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := newCert(t, 1, caKey, nil, nil, time.Now().Add(time.Hour))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaCert := newCert(t, 2, rsaKey, ca, caKey, time.Now().Add(time.Hour))
+	// Expired, but signed while it was valid.
+	ecCert := newCert(t, 3, ecKey, ca, caKey, time.Now().Add(-time.Hour))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(newCert(t, 4, otherKey, nil, nil, time.Now().Add(time.Hour)))
+
+	dir := t.TempDir()
+	content := []byte("initramfs")
+	path := filepath.Join(dir, "initramfs.cpio")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc     string
+		sig      []byte
+		opts     CMSOptions
+		wantCert *x509.Certificate
+		wantErr  interface{}
+	}{
+		{desc: "RSA", sig: cmsSign(t, rsaCert, rsaKey, content, time.Time{}), opts: CMSOptions{Roots: roots}, wantCert: rsaCert},
+		{desc: "ECDSA with attributes", sig: cmsSign(t, ecCert, ecKey, content, time.Now().Add(-2*time.Hour)), opts: CMSOptions{Roots: roots}, wantCert: ecCert},
+		{
+			desc:    "expired",
+			sig:     cmsSign(t, ecCert, ecKey, content, time.Now().Add(-2*time.Hour)),
+			opts:    CMSOptions{Roots: roots, CheckExpiry: true},
+			wantErr: &x509.CertificateInvalidError{},
+		},
+		{desc: "other content", sig: cmsSign(t, rsaCert, rsaKey, []byte("evil"), time.Time{}), opts: CMSOptions{Roots: roots}, wantErr: &ErrBadSignature{}},
+		{
+			desc:    "other content with attributes",
+			sig:     cmsSign(t, ecCert, ecKey, []byte("evil"), time.Now().Add(-2*time.Hour)),
+			opts:    CMSOptions{Roots: roots},
+			wantErr: &ErrBadSignature{},
+		},
+		{desc: "untrusted", sig: cmsSign(t, rsaCert, rsaKey, content, time.Time{}), opts: CMSOptions{Roots: otherRoots}, wantErr: &x509.UnknownAuthorityError{}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if err := os.WriteFile(path+".p7s", tt.sig, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			f, cert, err := OpenCMSSignedFile(tt.opts, path, path+".p7s")
+			if f == nil {
+				t.Fatalf("OpenCMSSignedFile returned no file: %v", err)
+			}
+			if tt.wantErr == nil {
+				if err != nil || cert != nil && !cert.Equal(tt.wantCert) {
+					t.Errorf("OpenCMSSignedFile = %v, %v, want %v", cert, err, tt.wantCert.Subject)
+				}
+				return
+			}
+			if !errors.As(err, &ErrUnsigned{}) || !errors.As(err, tt.wantErr) {
+				t.Errorf("OpenCMSSignedFile = %v, want ErrUnsigned wrapping %T", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, _, err := OpenCMSSignedFile(CMSOptions{}, path, path+".p7s"); !errors.Is(err, ErrNoCertPool) {
+		t.Errorf("OpenCMSSignedFile(no roots) = %v, want %v", err, ErrNoCertPool)
+	}
+}
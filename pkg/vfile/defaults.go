@@ -0,0 +1,100 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// Where the u-root builder puts the key ring and policy given with its
+// -keyring and -policy flags.
+const (
+	DefaultKeyRingPath = "/etc/u-root/keyring.gpg"
+	DefaultPolicyPath  = "/etc/u-root/policy.json"
+)
+
+// policyFile is the JSON form of a Policy, e.g.
+//
+//	{
+//		"threshold": 2,
+//		"fingerprints": ["5C8E2F4A..."],
+//		"max_age": "8760h",
+//		"reject_revoked": true
+//	}
+type policyFile struct {
+	Threshold     int      `json:"threshold"`
+	Fingerprints  []string `json:"fingerprints"`
+	MaxAge        string   `json:"max_age"`
+	RejectRevoked bool     `json:"reject_revoked"`
+}
+
+// ParsePolicy parses a JSON policy file. Fingerprints are hexadecimal, and
+// the maximum age is in the format of time.ParseDuration.
+func ParsePolicy(b []byte) (Policy, error) {
+	var pf policyFile
+	if err := json.Unmarshal(b, &pf); err != nil {
+		return Policy{}, fmt.Errorf("policy: %w", err)
+	}
+	if pf.Threshold < 0 {
+		return Policy{}, fmt.Errorf("policy: negative threshold %d", pf.Threshold)
+	}
+	p := Policy{
+		Threshold:     pf.Threshold,
+		RejectRevoked: pf.RejectRevoked,
+	}
+	for _, s := range pf.Fingerprints {
+		b, err := hex.DecodeString(s)
+		if err != nil || len(b) != 20 {
+			return Policy{}, fmt.Errorf("policy: invalid fingerprint %q", s)
+		}
+		var fp [20]byte
+		copy(fp[:], b)
+		p.Fingerprints = append(p.Fingerprints, fp)
+	}
+	if pf.MaxAge != "" {
+		d, err := time.ParseDuration(pf.MaxAge)
+		if err != nil {
+			return Policy{}, fmt.Errorf("policy: %w", err)
+		}
+		p.MaxAge = d
+	}
+	return p, nil
+}
+
+// ReadPolicyFile reads the JSON policy file path, see ParsePolicy.
+func ReadPolicyFile(path string) (Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	return ParsePolicy(b)
+}
+
+// DefaultKeyRing reads the key ring at DefaultKeyRingPath.
+func DefaultKeyRing() (openpgp.EntityList, error) {
+	f, err := os.Open(DefaultKeyRingPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadKeyRing(f)
+}
+
+// DefaultPolicy reads the policy at DefaultPolicyPath. If there is none, it
+// returns the zero Policy.
+func DefaultPolicy() (Policy, error) {
+	p, err := ReadPolicyFile(DefaultPolicyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return Policy{}, nil
+	}
+	return p, err
+}
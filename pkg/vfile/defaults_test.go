@@ -0,0 +1,42 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	fp := [20]byte{0x5c, 0x8e, 19: 0x01}
+	for _, tt := range []struct {
+		desc    string
+		in      string
+		want    Policy
+		wantErr bool
+	}{
+		{desc: "empty", in: "{}"},
+		{
+			desc: "all",
+			in:   `{"threshold": 2, "fingerprints": ["5C8E000000000000000000000000000000000001"], "max_age": "720h", "reject_revoked": true}`,
+			want: Policy{Threshold: 2, Fingerprints: [][20]byte{fp}, MaxAge: 720 * time.Hour, RejectRevoked: true},
+		},
+		{desc: "not JSON", in: "threshold=2", wantErr: true},
+		{desc: "negative threshold", in: `{"threshold": -1}`, wantErr: true},
+		{desc: "short fingerprint", in: `{"fingerprints": ["5C8E"]}`, wantErr: true},
+		{desc: "max age", in: `{"max_age": "a year"}`, wantErr: true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := ParsePolicy([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePolicy = %v, want error %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePolicy = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	guid "github.com/google/uuid"
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+// ShimLockGUID is the vendor GUID of the shim MOK variables.
+var ShimLockGUID = guid.MustParse("605dab50-e046-4300-abb6-3dd810dd8b23")
+
+// EFIKeyRing holds the certificates and hashes enrolled in the UEFI
+// Secure Boot databases and shim's machine owner key lists.
+type EFIKeyRing struct {
+	// Certificates are the trusted certificates, from db and MokList.
+	Certificates []*x509.Certificate
+
+	// Hashes are the trusted SHA-256 hashes of images.
+	Hashes [][]byte
+
+	// RevokedCertificates are the forbidden certificates, from dbx and
+	// MokListX.
+	RevokedCertificates []*x509.Certificate
+
+	// RevokedHashes are the forbidden SHA-256 hashes of images or
+	// certificates.
+	RevokedHashes [][]byte
+}
+
+// add adds the signature lists b to the trusted or revoked entries of r.
+// Types other than X.509 certificates and SHA-256 hashes are skipped.
+func (r *EFIKeyRing) add(b []byte, revoked bool) error {
+	lists, err := efivarfs.ParseSignatureLists(b)
+	if err != nil {
+		return err
+	}
+	for _, l := range lists {
+		for _, s := range l.Signatures {
+			switch l.Type {
+			case efivarfs.CertX509GUID:
+				c, err := x509.ParseCertificate(s.Data)
+				if err != nil {
+					return err
+				}
+				if revoked {
+					r.RevokedCertificates = append(r.RevokedCertificates, c)
+				} else {
+					r.Certificates = append(r.Certificates, c)
+				}
+			case efivarfs.CertSHA256GUID:
+				if revoked {
+					r.RevokedHashes = append(r.RevokedHashes, s.Data)
+				} else {
+					r.Hashes = append(r.Hashes, s.Data)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GetKeyRingFromBytes returns the key ring of the EFI signature lists
+// trusted, e.g. the contents of db, and revoked, e.g. the contents of dbx.
+// Either may be nil.
+func GetKeyRingFromBytes(trusted, revoked []byte) (*EFIKeyRing, error) {
+	r := &EFIKeyRing{}
+	if err := r.add(trusted, false); err != nil {
+		return nil, fmt.Errorf("trusted keys: %w", err)
+	}
+	if err := r.add(revoked, true); err != nil {
+		return nil, fmt.Errorf("revoked keys: %w", err)
+	}
+	return r, nil
+}
+
+// efiKeyVars are the variables GetKeyRingFromEFIVars reads. The MOK lists
+// are only readable after ExitBootServices through the runtime copies shim
+// makes.
+var efiKeyVars = []struct {
+	desc    efivarfs.VariableDescriptor
+	revoked bool
+}{
+	{desc: efivarfs.VariableDescriptor{Name: "db", GUID: efivarfs.ImageSecurityDatabaseGUID}},
+	{desc: efivarfs.VariableDescriptor{Name: "dbx", GUID: efivarfs.ImageSecurityDatabaseGUID}, revoked: true},
+	{desc: efivarfs.VariableDescriptor{Name: "MokListRT", GUID: ShimLockGUID}},
+	{desc: efivarfs.VariableDescriptor{Name: "MokListXRT", GUID: ShimLockGUID}, revoked: true},
+}
+
+// GetKeyRingFromEFIVars returns the key ring of the certificates and hashes
+// enrolled in db, dbx and shim's MokList and MokListX. Variables that do
+// not exist are skipped.
+func GetKeyRingFromEFIVars(e efivarfs.EFIVar) (*EFIKeyRing, error) {
+	r := &EFIKeyRing{}
+	for _, v := range efiKeyVars {
+		_, b, err := e.Get(v.desc)
+		if errors.Is(err, efivarfs.ErrVarNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", v.desc.Name, err)
+		}
+		if err := r.add(b, v.revoked); err != nil {
+			return nil, fmt.Errorf("%s: %w", v.desc.Name, err)
+		}
+	}
+	return r, nil
+}
+
+// Revoked reports whether c is forbidden, by itself or by its hash.
+func (r *EFIKeyRing) Revoked(c *x509.Certificate) bool {
+	for _, rc := range r.RevokedCertificates {
+		if rc.Equal(c) {
+			return true
+		}
+	}
+	h := sha256.Sum256(c.Raw)
+	for _, rh := range r.RevokedHashes {
+		if bytes.Equal(rh, h[:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// CertPool returns a pool of the trusted certificates that are not revoked.
+func (r *EFIKeyRing) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, c := range r.Certificates {
+		if !r.Revoked(c) {
+			pool.AddCert(c)
+		}
+	}
+	return pool
+}
+
+// CMSOptions returns options to verify CMS signatures that chain to the
+// trusted certificates.
+func (r *EFIKeyRing) CMSOptions() CMSOptions {
+	return CMSOptions{Roots: r.CertPool()}
+}
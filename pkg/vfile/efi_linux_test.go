@@ -0,0 +1,98 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+// fakeVars holds EFI variables in memory.
+type fakeVars map[efivarfs.VariableDescriptor][]byte
+
+func (f fakeVars) Get(desc efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	b, ok := f[desc]
+	if !ok {
+		return 0, nil, efivarfs.ErrVarNotExist
+	}
+	return efivarfs.KeyDatabaseAttributes, b, nil
+}
+
+func (f fakeVars) List() ([]efivarfs.VariableDescriptor, error) {
+	var l []efivarfs.VariableDescriptor
+	for d := range f {
+		l = append(l, d)
+	}
+	return l, nil
+}
+
+func (f fakeVars) Remove(desc efivarfs.VariableDescriptor) error {
+	delete(f, desc)
+	return nil
+}
+
+func (f fakeVars) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	f[desc] = data
+	return nil
+}
+
+func TestGetKeyRingFromEFIVars(t *testing.T) {
+	var certs []*x509.Certificate
+	for i := 0; i < 3; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, newCert(t, int64(i+1), key, nil, nil, time.Now().Add(time.Hour)))
+	}
+	lists := func(t *testing.T, certs []*x509.Certificate, hashes ...[]byte) []byte {
+		var l []efivarfs.SignatureList
+		for _, c := range certs {
+			l = append(l, efivarfs.SignatureList{Type: efivarfs.CertX509GUID, Signatures: []efivarfs.Signature{{Owner: ShimLockGUID, Data: c.Raw}}})
+		}
+		for _, h := range hashes {
+			l = append(l, efivarfs.SignatureList{Type: efivarfs.CertSHA256GUID, Signatures: []efivarfs.Signature{{Owner: ShimLockGUID, Data: h}}})
+		}
+		b, err := efivarfs.MarshalSignatureLists(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	revokedHash := sha256.Sum256(certs[1].Raw)
+
+	vars := fakeVars{
+		{Name: "db", GUID: efivarfs.ImageSecurityDatabaseGUID}:  lists(t, certs[:2]),
+		{Name: "dbx", GUID: efivarfs.ImageSecurityDatabaseGUID}: lists(t, nil, revokedHash[:]),
+		{Name: "MokListRT", GUID: ShimLockGUID}:                 lists(t, certs[2:]),
+	}
+	r, err := GetKeyRingFromEFIVars(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Certificates) != 3 || len(r.RevokedHashes) != 1 {
+		t.Fatalf("GetKeyRingFromEFIVars = %d certificates and %d revoked hashes, want 3 and 1", len(r.Certificates), len(r.RevokedHashes))
+	}
+	for i, want := range []bool{false, true, false} {
+		if got := r.Revoked(certs[i]); got != want {
+			t.Errorf("Revoked(cert %d) = %v, want %v", i, got, want)
+		}
+		opts := x509.VerifyOptions{Roots: r.CertPool()}
+		if _, err := certs[i].Verify(opts); (err == nil) == want {
+			t.Errorf("cert %d verifies with the pool: %v, want %v", i, err == nil, !want)
+		}
+	}
+
+	if _, err := GetKeyRingFromBytes([]byte("garbage"), nil); err == nil {
+		t.Errorf("GetKeyRingFromBytes(garbage) succeeded")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha1" // for crypto.SHA1
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// IMAXattr is the extended attribute IMA appraisal signatures are kept in.
+const IMAXattr = "security.ima"
+
+// Types of security.ima values, from the kernel's enum evm_ima_xattr_type.
+const (
+	imaXattrDigest    = 0x01
+	imaXattrDigSig    = 0x03
+	imaXattrDigestNG  = 0x04
+	imaSignatureV2    = 2
+	imaSigV2HeaderLen = 9
+)
+
+// imaHashes maps the kernel's enum hash_algo to hashes.
+var imaHashes = map[byte]crypto.Hash{
+	2: crypto.SHA1,
+	4: crypto.SHA256,
+	5: crypto.SHA384,
+	6: crypto.SHA512,
+	7: crypto.SHA224,
+}
+
+// ErrUnknownIMAKey is returned for an IMA signature made by a key that was
+// not given.
+type ErrUnknownIMAKey struct {
+	// KeyID is the key identifier of the signature, the last 4 bytes of
+	// the subject key identifier of the signer certificate.
+	KeyID uint32
+}
+
+func (e ErrUnknownIMAKey) Error() string {
+	return fmt.Sprintf("signed by unknown key %08x", e.KeyID)
+}
+
+// imaKeyID returns the IMA key identifier of c.
+//
+// Like the kernel, this is the end of the subject key identifier. If c has
+// none, it is computed as evmctl does, from the SHA-1 hash of the public key.
+func imaKeyID(c *x509.Certificate) (uint32, error) {
+	skid := c.SubjectKeyId
+	if len(skid) < 4 {
+		var spki struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}
+		if _, err := asn1.Unmarshal(c.RawSubjectPublicKeyInfo, &spki); err != nil {
+			return 0, err
+		}
+		h := crypto.SHA1.New()
+		h.Write(spki.PublicKey.RightAlign())
+		skid = h.Sum(nil)
+	}
+	return binary.BigEndian.Uint32(skid[len(skid)-4:]), nil
+}
+
+// VerifyIMASignature checks the IMA appraisal signature xattr, the value of
+// the security.ima extended attribute, of content against certs, and
+// returns the certificate that made it.
+//
+// Only version 2 signatures, which sign the file hash with RSA or ECDSA,
+// are supported. File hashes without a signature are rejected, as they are
+// only protected by EVM, which is not checked.
+//
+// If the signature was made by none of certs, the error is
+// ErrUnknownIMAKey. If it does not match content, the error is
+// ErrBadSignature.
+func VerifyIMASignature(certs []*x509.Certificate, content, xattr []byte) (*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, ErrNoCertPool
+	}
+	if len(xattr) == 0 {
+		return nil, errors.New("IMA signature: empty")
+	}
+	switch xattr[0] {
+	case imaXattrDigSig:
+	case imaXattrDigest, imaXattrDigestNG:
+		return nil, errors.New("IMA signature: security.ima holds a hash, not a signature")
+	default:
+		return nil, fmt.Errorf("IMA signature: unsupported type %#x", xattr[0])
+	}
+	if len(xattr) < imaSigV2HeaderLen {
+		return nil, errors.New("IMA signature: too short")
+	}
+	if xattr[1] != imaSignatureV2 {
+		return nil, fmt.Errorf("IMA signature: unsupported version %d", xattr[1])
+	}
+	h, ok := imaHashes[xattr[2]]
+	if !ok || !h.Available() {
+		return nil, fmt.Errorf("IMA signature: unsupported hash algorithm %d", xattr[2])
+	}
+	keyID := binary.BigEndian.Uint32(xattr[3:7])
+	sig := xattr[imaSigV2HeaderLen:]
+	if int(binary.BigEndian.Uint16(xattr[7:9])) != len(sig) {
+		return nil, errors.New("IMA signature: wrong signature size")
+	}
+
+	var cert *x509.Certificate
+	for _, c := range certs {
+		if id, err := imaKeyID(c); err == nil && id == keyID {
+			cert = c
+			break
+		}
+	}
+	if cert == nil {
+		return nil, ErrUnknownIMAKey{KeyID: keyID}
+	}
+
+	hh := h.New()
+	hh.Write(content)
+	digest := hh.Sum(nil)
+	var err error
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, h, digest, sig)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, sig) {
+			err = errors.New("ECDSA verification failure")
+		}
+	default:
+		return nil, fmt.Errorf("IMA signature: unsupported public key type %T", pub)
+	}
+	if err != nil {
+		return cert, ErrBadSignature{Err: err}
+	}
+	return cert, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// fgetxattr returns the value of the extended attribute attr of f.
+func fgetxattr(f *os.File, attr string) ([]byte, error) {
+	fd := int(f.Fd())
+	for {
+		sz, err := unix.Fgetxattr(fd, attr, nil)
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: f.Name(), Err: err}
+		}
+		b := make([]byte, sz)
+		n, err := unix.Fgetxattr(fd, attr, b)
+		if err == unix.ERANGE {
+			// It grew in between.
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: f.Name(), Err: err}
+		}
+		return b[:n], nil
+	}
+}
+
+// OpenIMASignedFile opens a file that is expected to have an IMA appraisal
+// signature by one of certs in its security.ima extended attribute, and
+// returns the certificate that signed it.
+//
+// WARNING! Unlike many Go functions, this may return both the file and an
+// error.
+//
+// If the signature does not exist or does not verify, both the file and an
+// ErrUnsigned error will be returned, as OpenSignedFile does.
+func OpenIMASignedFile(certs []*x509.Certificate, path string) (*File, *x509.Certificate, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fh.Close()
+
+	// The contents and the signature are read from the same open file,
+	// so that they cannot be of different files.
+	content, err := io.ReadAll(fh)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := &File{
+		Reader:   bytes.NewReader(content),
+		FileName: path,
+	}
+
+	sig, err := fgetxattr(fh, IMAXattr)
+	if err != nil {
+		return f, nil, ErrUnsigned{Path: path, Err: err}
+	}
+	cert, err := VerifyIMASignature(certs, content, sig)
+	if err != nil {
+		return f, nil, ErrUnsigned{Path: path, Err: err}
+	}
+	if err := measure(path, content); err != nil {
+		return f, cert, err
+	}
+	return f, cert, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestOpenIMASignedFile(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := newCert(t, 1, key, nil, nil, time.Now().Add(time.Hour))
+	certs := []*x509.Certificate{cert}
+
+	dir := t.TempDir()
+	signed := filepath.Join(dir, "signed")
+	if err := os.WriteFile(signed, []byte("init"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Setting security.* attributes needs CAP_SYS_ADMIN.
+	if err := unix.Setxattr(signed, IMAXattr, imaSign(t, cert, key, 4, []byte("init")), 0); err != nil {
+		t.Skipf("cannot set %s: %v", IMAXattr, err)
+	}
+	unsigned := filepath.Join(dir, "unsigned")
+	if err := os.WriteFile(unsigned, []byte("init"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, c, err := OpenIMASignedFile(certs, signed)
+	if err != nil || f == nil || !c.Equal(cert) {
+		t.Errorf("OpenIMASignedFile(signed) = %v, %v, %v, want file, %v, nil", f, c, err, cert.Subject)
+	}
+	f, _, err = OpenIMASignedFile(certs, unsigned)
+	if f == nil || !errors.As(err, &ErrUnsigned{}) || !errors.Is(err, unix.ENODATA) {
+		t.Errorf("OpenIMASignedFile(unsigned) = %v, %v, want file, ErrUnsigned wrapping ENODATA", f, err)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// imaSign makes a security.ima signature of content, as evmctl ima_sign does.
+func imaSign(t *testing.T, cert *x509.Certificate, key crypto.Signer, algo byte, content []byte) []byte {
+	h := imaHashes[algo]
+	hh := h.New()
+	hh.Write(content)
+	sig, err := key.Sign(rand.Reader, hh.Sum(nil), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID, err := imaKeyID(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := []byte{imaXattrDigSig, imaSignatureV2, algo}
+	x = binary.BigEndian.AppendUint32(x, keyID)
+	x = binary.BigEndian.AppendUint16(x, uint16(len(sig)))
+	return append(x, sig...)
+}
+
+func TestVerifyIMASignature(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The CA certificate has a subject key identifier, the leaf has none.
+	rsaCert := newCert(t, 1, rsaKey, nil, nil, time.Now().Add(time.Hour))
+	ecCert := newCert(t, 2, ecKey, rsaCert, rsaKey, time.Now().Add(time.Hour))
+	certs := []*x509.Certificate{rsaCert, ecCert}
+	content := []byte("/sbin/init")
+
+	badLen := imaSign(t, rsaCert, rsaKey, 4, content)
+	badLen = badLen[:len(badLen)-1]
+
+	for _, tt := range []struct {
+		desc     string
+		certs    []*x509.Certificate
+		xattr    []byte
+		wantCert *x509.Certificate
+		wantErr  interface{}
+	}{
+		{desc: "RSA SHA-256", certs: certs, xattr: imaSign(t, rsaCert, rsaKey, 4, content), wantCert: rsaCert},
+		{desc: "RSA SHA-1", certs: certs, xattr: imaSign(t, rsaCert, rsaKey, 2, content), wantCert: rsaCert},
+		{desc: "ECDSA SHA-512", certs: certs, xattr: imaSign(t, ecCert, ecKey, 6, content), wantCert: ecCert},
+		{desc: "other content", certs: certs, xattr: imaSign(t, ecCert, ecKey, 4, []byte("evil")), wantErr: &ErrBadSignature{}},
+		{desc: "unknown key", certs: certs[:1], xattr: imaSign(t, ecCert, ecKey, 4, content), wantErr: &ErrUnknownIMAKey{}},
+		{desc: "hash only", certs: certs, xattr: append([]byte{imaXattrDigestNG, 4}, make([]byte, 32)...)},
+		{desc: "wrong size", certs: certs, xattr: badLen},
+		{desc: "empty", certs: certs, xattr: nil},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			cert, err := VerifyIMASignature(tt.certs, content, tt.xattr)
+			if tt.wantCert != nil {
+				if err != nil || !cert.Equal(tt.wantCert) {
+					t.Errorf("VerifyIMASignature = %v, %v, want %v", cert, err, tt.wantCert.Subject)
+				}
+				return
+			}
+			if err == nil || tt.wantErr != nil && !errors.As(err, tt.wantErr) {
+				t.Errorf("VerifyIMASignature = %v, want %T", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := VerifyIMASignature(nil, content, imaSign(t, rsaCert, rsaKey, 4, content)); !errors.Is(err, ErrNoCertPool) {
+		t.Errorf("VerifyIMASignature(no certs) = %v, want %v", err, ErrNoCertPool)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// Limits bound the resources spent parsing signatures and key rings, which
+// may come from untrusted places, e.g. be fetched over the network. A limit
+// of 0 means no limit.
+type Limits struct {
+	// MaxSignatureSize is the size of the largest signature file, in
+	// bytes.
+	MaxSignatureSize int64
+
+	// MaxSignatures is the number of signatures a detached signature file
+	// may hold.
+	MaxSignatures int
+
+	// MaxKeyRingSize is the size of the largest key ring, in bytes.
+	MaxKeyRingSize int64
+
+	// MaxKeys is the number of keys, primary keys and subkeys, a key ring
+	// may hold.
+	MaxKeys int
+
+	// MaxSignaturePacketSize is the size of the largest signature packet,
+	// header included, in bytes.
+	MaxSignaturePacketSize int
+
+	// MaxFileSize is the size of the largest file whose OpenPGP signature
+	// is checked, in bytes. Larger files are not read.
+	MaxFileSize int64
+
+	// Strict rejects OpenPGP signatures that parse but are not to be
+	// trusted at boot: v3 signatures, signatures with a weak digest such
+	// as SHA-1, and signatures with critical subpackets other than those
+	// of creation time, expiration time and issuer. The error is
+	// ErrStrict.
+	Strict bool
+}
+
+// DefaultLimits are the limits until SetLimits is called.
+var DefaultLimits = Limits{
+	MaxSignatureSize: 1 << 20,
+	MaxSignatures:    64,
+	MaxKeyRingSize:   16 << 20,
+	MaxKeys:          4096,
+}
+
+// StrictLimits are limits for verifying boot files, whose signatures may
+// have been made to attack the parser.
+var StrictLimits = Limits{
+	MaxSignatureSize:       64 << 10,
+	MaxSignatures:          16,
+	MaxKeyRingSize:         1 << 20,
+	MaxKeys:                256,
+	MaxSignaturePacketSize: 4096,
+	MaxFileSize:            1 << 30,
+	Strict:                 true,
+}
+
+// ErrLimitExceeded is returned when a signature or key ring exceeds the
+// Limits.
+type ErrLimitExceeded struct {
+	// Limit is the name of the field of Limits that was exceeded.
+	Limit string
+
+	// Max is its value.
+	Max int64
+}
+
+func (e ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("%s of %d exceeded", e.Limit, e.Max)
+}
+
+var (
+	limitsMu sync.Mutex
+	limits   = DefaultLimits
+)
+
+// SetLimits sets the limits of parsing signatures and key rings for all
+// functions of this package.
+func SetLimits(l Limits) {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	limits = l
+}
+
+func getLimits() Limits {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	return limits
+}
+
+// readAtMost reads all of r, unless it is more than max bytes.
+func readAtMost(r io.Reader, max int64, limit string) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > max {
+		return nil, ErrLimitExceeded{Limit: limit, Max: max}
+	}
+	return b, nil
+}
+
+// ReadSignature reads a signature of at most MaxSignatureSize from r.
+func ReadSignature(r io.Reader) ([]byte, error) {
+	return readAtMost(r, getLimits().MaxSignatureSize, "MaxSignatureSize")
+}
+
+// readSignatureFile reads the signature file path, which must not be larger
+// than MaxSignatureSize.
+func readSignatureFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSignature(f)
+}
+
+// readSignedFile reads the file path, whose signature is to be checked. If
+// it is larger than MaxFileSize, the error is ErrUnsigned.
+func readSignedFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := readAtMost(f, getLimits().MaxFileSize, "MaxFileSize")
+	if errors.As(err, &ErrLimitExceeded{}) {
+		return nil, ErrUnsigned{Path: path, Err: err}
+	}
+	return b, err
+}
+
+// checkSignatureCount checks that n signatures are within MaxSignatures.
+func checkSignatureCount(n int) error {
+	if max := getLimits().MaxSignatures; max > 0 && n > max {
+		return ErrLimitExceeded{Limit: "MaxSignatures", Max: int64(max)}
+	}
+	return nil
+}
+
+// checkKeyCount counts the keys of the key ring b, and checks that they
+// are within MaxKeys before the key ring is parsed into entities.
+func checkKeyCount(b []byte) error {
+	max := getLimits().MaxKeys
+	if max <= 0 {
+		return nil
+	}
+	var keys int
+	packets := packet.NewReader(bytes.NewReader(b))
+	for {
+		p, err := packets.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// Let the parser report it.
+			return nil
+		}
+		switch p.(type) {
+		case *packet.PublicKey, *packet.PrivateKey, *packet.PublicKeyV3:
+			if keys++; keys > max {
+				return ErrLimitExceeded{Limit: "MaxKeys", Max: int64(max)}
+			}
+		}
+	}
+}
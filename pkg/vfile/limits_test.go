@@ -0,0 +1,78 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestLimits(t *testing.T) {
+	keys := readKeys(t)
+	var ring bytes.Buffer
+	for _, k := range keys {
+		if err := k.Serialize(&ring); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	content := []byte("hello")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var sig bytes.Buffer
+	if err := DetachSign(&sig, keys, content, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".sig", sig.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetLimits(DefaultLimits)
+	for _, tt := range []struct {
+		desc      string
+		limits    Limits
+		wantRing  string
+		wantCheck string
+	}{
+		{desc: "defaults", limits: DefaultLimits},
+		{desc: "none", limits: Limits{}},
+		{desc: "key ring size", limits: Limits{MaxKeyRingSize: 100}, wantRing: "MaxKeyRingSize"},
+		{desc: "keys", limits: Limits{MaxKeys: 1}, wantRing: "MaxKeys"},
+		{desc: "signature size", limits: Limits{MaxSignatureSize: int64(sig.Len() - 1)}, wantCheck: "MaxSignatureSize"},
+		{desc: "signatures", limits: Limits{MaxSignatures: 1}, wantCheck: "MaxSignatures"},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			SetLimits(tt.limits)
+
+			var want ErrLimitExceeded
+			el, err := ReadKeyRing(bytes.NewReader(ring.Bytes()))
+			if tt.wantRing != "" {
+				if !errors.As(err, &want) || want.Limit != tt.wantRing {
+					t.Errorf("ReadKeyRing = %v, want %s exceeded", err, tt.wantRing)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadKeyRing = %v, want nil", err)
+			}
+
+			_, _, err = VerifySignedFile(openpgp.EntityList(el), path, path+".sig")
+			switch {
+			case tt.wantCheck == "" && err != nil:
+				t.Errorf("VerifySignedFile = %v, want nil", err)
+			case tt.wantCheck != "" && (!errors.As(err, &ErrUnsigned{}) || !errors.As(err, &want) || want.Limit != tt.wantCheck):
+				t.Errorf("VerifySignedFile = %v, want ErrUnsigned wrapping %s exceeded", err, tt.wantCheck)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/hashsum"
+	"golang.org/x/crypto/openpgp"
+)
+
+// ErrNotInManifest is returned for a file that a manifest has no sum for.
+var ErrNotInManifest = errors.New("file not in manifest")
+
+// ErrOutsideManifestDir is returned by VerifySignedManifest for a name in a
+// manifest that is not below the manifest's directory.
+var ErrOutsideManifestDir = errors.New("file not below the manifest's directory")
+
+// manifestHashes are the hashes of manifest sums, by size. They are those of
+// SHA256SUMS, SHA384SUMS and SHA512SUMS files.
+var manifestHashes = []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512}
+
+// manifestSum returns the sum and hash of filePath in manifest, whose names
+// are relative to manifestDir.
+func manifestSum(manifest []byte, manifestDir, filePath string) (crypto.Hash, []byte, error) {
+	rel, err := filepath.Rel(manifestDir, filePath)
+	if err != nil {
+		return 0, nil, err
+	}
+	s := bufio.NewScanner(bytes.NewReader(manifest))
+	for s.Scan() {
+		h, e, ok := parseManifestLine(s.Text())
+		if ok && filepath.Clean(e.Name) == rel {
+			return h, e.Sum, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, nil, err
+	}
+	return 0, nil, ErrNotInManifest
+}
+
+// parseManifestLine parses a line of a manifest, and returns the hash of its
+// sum.
+func parseManifestLine(line string) (crypto.Hash, hashsum.Entry, bool) {
+	for _, h := range manifestHashes {
+		if e, ok := hashsum.ParseLine(line, h.Size()); ok {
+			return h, e, true
+		}
+	}
+	return 0, hashsum.Entry{}, false
+}
+
+// OpenFileFromManifest opens filePath and verifies it against its sum in the
+// coreutils-style manifest at manifestPath, e.g. a SHA256SUMS file. Names in
+// the manifest are relative to its directory.
+//
+// manifestPath must be an already trusted path. Use
+// OpenFileFromSignedManifest for a manifest that came with the files.
+//
+// WARNING! Unlike many Go functions, this may return both the file and an
+// error in case the sum does not match the contents.
+func OpenFileFromManifest(manifestPath, filePath string) (*File, error) {
+	manifest, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return openFromManifest(manifest, manifestPath, filePath)
+}
+
+// OpenFileFromSignedManifest is OpenFileFromManifest for a manifest that is
+// clear-signed, or has a detached signature in manifestPath.sig or
+// manifestPath.asc, by a key in keyring.
+//
+// WARNING! Unlike many Go functions, this may return both the file and an
+// error.
+//
+// If the manifest is not signed, both the file and an ErrUnsigned error for
+// the manifest are returned.
+func OpenFileFromSignedManifest(keyring openpgp.KeyRing, manifestPath, filePath string) (*File, error) {
+	manifest, err := readSignedManifest(keyring, manifestPath)
+	if _, ok := err.(ErrUnsigned); ok {
+		f, ferr := readFile(filePath)
+		if ferr != nil {
+			return nil, ferr
+		}
+		return f, err
+	} else if err != nil {
+		return nil, err
+	}
+	return openFromManifest(manifest, manifestPath, filePath)
+}
+
+// readSignedManifest reads the manifest at manifestPath, and checks that it
+// is signed by a key in keyring. If it is not, the error is ErrUnsigned.
+func readSignedManifest(keyring openpgp.KeyRing, manifestPath string) ([]byte, error) {
+	manifest, err := readSignedFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case keyring == nil:
+		err = ErrNoKeyRing
+	case IsClearSigned(manifest):
+		manifest, _, err = VerifyClearSigned(keyring, manifest)
+	default:
+		var sig []byte
+		if sig, err = readSignatureFile(sigPath(manifestPath)); err == nil {
+			_, err = checkSignatures(keyring, manifest, sig)
+		}
+	}
+	if err != nil {
+		return nil, ErrUnsigned{Path: manifestPath, Err: err}
+	}
+	return manifest, nil
+}
+
+// VerifySignedManifest verifies every file listed in the manifest at
+// manifestPath, which must be signed as for OpenFileFromSignedManifest, e.g.
+// to check a directory shared by a hypervisor before anything in it is used.
+// Files are read in chunks rather than into memory. It returns the paths of
+// the files verified, up to the first that is not.
+//
+// Files that are not listed in the manifest are not checked, and a file may
+// change after it was verified.
+func VerifySignedManifest(keyring openpgp.KeyRing, manifestPath string) ([]string, error) {
+	manifest, err := readSignedManifest(keyring, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(manifestPath)
+	var verified []string
+	s := bufio.NewScanner(bytes.NewReader(manifest))
+	for s.Scan() {
+		h, e, ok := parseManifestLine(s.Text())
+		if !ok {
+			continue
+		}
+		name := filepath.Clean(e.Name)
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return verified, ErrInvalidHash{Path: e.Name, Err: ErrOutsideManifestDir}
+		}
+		path := filepath.Join(dir, name)
+		r, err := OpenHashedReader(path, h, e.Sum, false)
+		if err != nil {
+			return verified, err
+		}
+		_, err = io.Copy(io.Discard, r)
+		if cerr := r.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return verified, err
+		}
+		verified = append(verified, path)
+	}
+	return verified, s.Err()
+}
+
+func openFromManifest(manifest []byte, manifestPath, filePath string) (*File, error) {
+	h, sum, err := manifestSum(manifest, filepath.Dir(manifestPath), filePath)
+	if err != nil {
+		f, ferr := readFile(filePath)
+		if ferr != nil {
+			return nil, ferr
+		}
+		return f, ErrInvalidHash{Path: filePath, Err: err}
+	}
+	return OpenHashedFile(filePath, h, sum)
+}
+
+// readFile reads path into a File without verifying it.
+func readFile(path string) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		Reader:   bytes.NewReader(content),
+		FileName: path,
+	}, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+)
+
+func TestOpenFileFromManifest(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "boot"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"boot/vmlinuz":   "kernel",
+		"initramfs.cpio": "initramfs",
+		"evil":           "evil",
+		"unlisted":       "unlisted",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kernel := sha256.Sum256([]byte("kernel"))
+	initramfs := sha512.Sum512([]byte("initramfs"))
+	manifest := fmt.Sprintf("%x  boot/vmlinuz\n%x *initramfs.cpio\n%x  evil\n", kernel, initramfs, kernel)
+
+	plain := filepath.Join(dir, "SHA256SUMS")
+	if err := os.WriteFile(plain, []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var cs bytes.Buffer
+	w, err := clearsign.Encode(&cs, keys[0].PrivateKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(manifest))
+	w.Close()
+	clear := filepath.Join(dir, "SHA256SUMS.clear")
+	if err := os.WriteFile(clear, cs.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	detached := filepath.Join(dir, "SUMS")
+	if err := (signedFile{signers: keys[1:], content: manifest}).write(detached); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, manifest := range []string{plain, clear, detached} {
+		for _, tt := range []struct {
+			name     string
+			mismatch bool
+			unlisted bool
+		}{
+			{name: "boot/vmlinuz"},
+			{name: "initramfs.cpio"},
+			{name: "evil", mismatch: true},
+			{name: "unlisted", unlisted: true},
+		} {
+			path := filepath.Join(dir, tt.name)
+			var f *File
+			var err error
+			if manifest == plain {
+				f, err = OpenFileFromManifest(manifest, path)
+			} else {
+				f, err = OpenFileFromSignedManifest(openpgp.EntityList(keys), manifest, path)
+			}
+			if f == nil {
+				t.Fatalf("%s: no file for %s: %v", manifest, tt.name, err)
+			}
+			switch {
+			case tt.mismatch:
+				if !errors.As(err, &ErrHashMismatch{}) {
+					t.Errorf("%s: %s: %v, want a hash mismatch", manifest, tt.name, err)
+				}
+			case tt.unlisted:
+				if !errors.Is(err, ErrNotInManifest) {
+					t.Errorf("%s: %s: %v, want %v", manifest, tt.name, err, ErrNotInManifest)
+				}
+			case err != nil:
+				t.Errorf("%s: %s: %v", manifest, tt.name, err)
+			}
+		}
+	}
+
+	// A manifest signed by an unknown key verifies nothing.
+	_, err = OpenFileFromSignedManifest(openpgp.EntityList{keys[0]}, detached, filepath.Join(dir, "boot/vmlinuz"))
+	if !errors.As(err, &ErrUnsigned{}) {
+		t.Errorf("OpenFileFromSignedManifest(wrong key) = %v, want ErrUnsigned", err)
+	}
+}
+
+func TestVerifySignedManifest(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "boot"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"boot/vmlinuz":   "kernel",
+		"initramfs.cpio": "initramfs",
+		"unlisted":       "unlisted",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kernel := sha256.Sum256([]byte("kernel"))
+	initramfs := sha512.Sum512([]byte("initramfs"))
+
+	for _, tt := range []struct {
+		desc     string
+		manifest string
+		signers  []*openpgp.Entity
+		want     []string
+		wantErr  error
+	}{
+		{
+			desc:     "good",
+			manifest: fmt.Sprintf("%x  boot/vmlinuz\n%x *initramfs.cpio\n", kernel, initramfs),
+			signers:  keys[:1],
+			want:     []string{"boot/vmlinuz", "initramfs.cpio"},
+		},
+		{
+			desc:     "mismatch",
+			manifest: fmt.Sprintf("%x  boot/vmlinuz\n%x  unlisted\n", kernel, kernel),
+			signers:  keys[:1],
+			want:     []string{"boot/vmlinuz"},
+			wantErr:  ErrHashMismatch{},
+		},
+		{
+			desc:     "missing",
+			manifest: fmt.Sprintf("%x  missing\n", kernel),
+			signers:  keys[:1],
+			wantErr:  os.ErrNotExist,
+		},
+		{
+			desc:     "outside",
+			manifest: fmt.Sprintf("%x  ../vmlinuz\n", kernel),
+			signers:  keys[:1],
+			wantErr:  ErrOutsideManifestDir,
+		},
+		{
+			desc:     "unsigned",
+			manifest: fmt.Sprintf("%x  boot/vmlinuz\n", kernel),
+			wantErr:  ErrUnsigned{},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			path := filepath.Join(dir, "SHA256SUMS")
+			os.Remove(path)
+			os.Remove(path + ".sig")
+			if err := (signedFile{signers: tt.signers, content: tt.manifest}).write(path); err != nil {
+				t.Fatal(err)
+			}
+			got, err := VerifySignedManifest(openpgp.EntityList(keys[:1]), path)
+			var want []string
+			for _, name := range tt.want {
+				want = append(want, filepath.Join(dir, name))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("VerifySignedManifest = %v, want %v", got, want)
+			}
+			switch e := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Errorf("VerifySignedManifest = %v", err)
+				}
+			case ErrHashMismatch:
+				if !errors.As(err, &e) {
+					t.Errorf("VerifySignedManifest = %v, want a hash mismatch", err)
+				}
+			case ErrUnsigned:
+				if !errors.As(err, &e) {
+					t.Errorf("VerifySignedManifest = %v, want ErrUnsigned", err)
+				}
+			default:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("VerifySignedManifest = %v, want %v", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
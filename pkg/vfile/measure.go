@@ -0,0 +1,94 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// Measurer extends digests into a TPM PCR, for measured boot.
+type Measurer interface {
+	// Extend extends the SHA-256 digest into pcr.
+	Extend(pcr int, digest []byte) error
+}
+
+// ErrNotMeasured is returned for a file that verified, but could not be
+// measured.
+type ErrNotMeasured struct {
+	// Path is the file that could not be measured.
+	Path string
+
+	// Err is the error of the Measurer.
+	Err error
+}
+
+func (e ErrNotMeasured) Error() string {
+	return fmt.Sprintf("file %q could not be measured: %v", e.Path, e.Err)
+}
+
+func (e ErrNotMeasured) Unwrap() error {
+	return e.Err
+}
+
+var (
+	measureMu  sync.Mutex
+	measurer   Measurer
+	measurePCR int
+)
+
+// SetMeasurer makes the functions that verify a file and return a File,
+// e.g. OpenSignedFile and OpenHashedFile, extend the SHA-256 digest of
+// every file that verified into pcr with m before returning it. If that
+// fails, the File is returned with an ErrNotMeasured error.
+//
+// VerifyingReaders created afterwards extend the digest of their data once
+// it verified, and if that fails, Read returns ErrNotMeasured instead of
+// io.EOF.
+//
+// A nil m turns measuring off, which is the default.
+func SetMeasurer(m Measurer, pcr int) {
+	measureMu.Lock()
+	defer measureMu.Unlock()
+	measurer, measurePCR = m, pcr
+}
+
+// measure measures the contents of a verified file, if there is a Measurer.
+func measure(path string, content []byte) error {
+	m := newMeasurement()
+	if m == nil {
+		return nil
+	}
+	m.Write(content)
+	return m.extend(path)
+}
+
+// measurement hashes data written to it, to measure it with the Measurer
+// there was when it was created.
+type measurement struct {
+	hash.Hash
+	m   Measurer
+	pcr int
+}
+
+// newMeasurement returns a measurement, or nil if there is no Measurer.
+func newMeasurement() *measurement {
+	measureMu.Lock()
+	defer measureMu.Unlock()
+	if measurer == nil {
+		return nil
+	}
+	return &measurement{Hash: sha256.New(), m: measurer, pcr: measurePCR}
+}
+
+// extend measures the data written, which is that of the file path.
+func (m *measurement) extend(path string) error {
+	if err := m.m.Extend(m.pcr, m.Sum(nil)); err != nil {
+		return ErrNotMeasured{Path: path, Err: err}
+	}
+	return nil
+}
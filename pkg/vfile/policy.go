@@ -0,0 +1,261 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// Policy says which signatures a file must have to be considered signed.
+//
+// The zero Policy accepts one good signature by any key of the key ring, as
+// OpenSignedFile does.
+type Policy struct {
+	// Threshold is how many distinct keys of the key ring must have
+	// signed. Signatures by subkeys count for their primary key. 0 is the
+	// same as 1.
+	Threshold int
+
+	// Fingerprints, if not empty, are the only keys whose signatures
+	// count. A primary key's fingerprint allows its subkeys too.
+	Fingerprints [][20]byte
+
+	// MaxAge, if not 0, is how old a signature may be. Signatures made
+	// more than MaxClockSkew after Now are rejected too, as their age
+	// cannot be told.
+	MaxAge time.Duration
+
+	// RejectRevoked does not count signatures by revoked keys or subkeys.
+	// openpgp already ignores revoked keys, but not subkeys revoked
+	// without a reason.
+	RejectRevoked bool
+
+	// Now returns the time to check signatures at. If nil, time.Now is
+	// used.
+	Now func() time.Time
+}
+
+// MaxClockSkew is how far in the future a Policy with a MaxAge accepts the
+// creation time of a signature, for the clocks of the signer and the
+// verifier to differ.
+const MaxClockSkew = 5 * time.Minute
+
+// ErrPolicy is returned when the signatures of a file do not satisfy a
+// Policy.
+type ErrPolicy struct {
+	// Signers is how many distinct keys signed acceptably.
+	Signers int
+
+	// Threshold is how many were required.
+	Threshold int
+
+	// Rejected are the reasons signatures did not count.
+	Rejected []error
+}
+
+func (e ErrPolicy) Error() string {
+	s := fmt.Sprintf("signed by %d of the %d keys required", e.Signers, e.Threshold)
+	if len(e.Rejected) > 0 {
+		s += fmt.Sprintf(", rejected: %v", e.Rejected)
+	}
+	return s
+}
+
+// Unwrap returns the first reason a signature was rejected.
+func (e ErrPolicy) Unwrap() error {
+	if len(e.Rejected) == 0 {
+		return nil
+	}
+	return e.Rejected[0]
+}
+
+// ErrSignatureExpired is returned for a signature that expired or is older
+// than a Policy allows.
+type ErrSignatureExpired struct {
+	// KeyID is the ID of the key that made the signature.
+	KeyID uint64
+
+	// Expiry is when the signature expired.
+	Expiry time.Time
+}
+
+func (e ErrSignatureExpired) Error() string {
+	return fmt.Sprintf("signature by key %X expired at %v", e.KeyID, e.Expiry.UTC().Format(time.RFC3339))
+}
+
+// ErrSignatureInFuture is returned for a signature created after now, as a
+// Policy with a MaxAge sees it.
+type ErrSignatureInFuture struct {
+	// KeyID is the ID of the key that made the signature.
+	KeyID uint64
+
+	// Created is when the signature was created.
+	Created time.Time
+}
+
+func (e ErrSignatureInFuture) Error() string {
+	return fmt.Sprintf("signature by key %X was created in the future, at %v", e.KeyID, e.Created.UTC().Format(time.RFC3339))
+}
+
+// ErrKeyRevoked is returned for a signature made by a revoked key.
+type ErrKeyRevoked struct {
+	// Fingerprint is the fingerprint of the revoked key.
+	Fingerprint [20]byte
+}
+
+func (e ErrKeyRevoked) Error() string {
+	return fmt.Sprintf("key %X is revoked", e.Fingerprint)
+}
+
+// ErrKeyNotAllowed is returned for a signature made by a key of the key
+// ring that a Policy does not allow.
+type ErrKeyNotAllowed struct {
+	// Fingerprint is the fingerprint of the key.
+	Fingerprint [20]byte
+}
+
+func (e ErrKeyNotAllowed) Error() string {
+	return fmt.Sprintf("key %X is not allowed by the policy", e.Fingerprint)
+}
+
+// Check checks the detached signatures sig of content against keyring, and
+// returns the signatures that count.
+//
+// If fewer than p.Threshold distinct keys signed acceptably, the error is
+// ErrPolicy.
+func (p Policy) Check(keyring openpgp.KeyRing, content, sig []byte) ([]*VerificationResult, error) {
+	if keyring == nil {
+		return nil, ErrNoKeyRing
+	}
+	sig, err := dearmor(sig)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := splitPackets(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := p.Threshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	now := time.Now
+	if p.Now != nil {
+		now = p.Now
+	}
+
+	var (
+		good     []*VerificationResult
+		rejected []error
+		signers  = map[[20]byte]bool{}
+	)
+	for i, b := range packets {
+		r, err := checkSignature(keyring, content, b)
+		if err == nil {
+			r.Index, r.Signatures = i, len(packets)
+			err = p.accept(keyring, r, b, now())
+		}
+		if err != nil {
+			rejected = append(rejected, err)
+			continue
+		}
+		primary := r.Signer.PrimaryKey.Fingerprint
+		if signers[primary] {
+			continue
+		}
+		signers[primary] = true
+		good = append(good, r)
+	}
+	if len(good) < threshold {
+		return good, ErrPolicy{Signers: len(good), Threshold: threshold, Rejected: rejected}
+	}
+	return good, nil
+}
+
+// accept checks the good signature r, whose packet is b, against p.
+func (p Policy) accept(keyring openpgp.KeyRing, r *VerificationResult, b []byte, now time.Time) error {
+	primary := r.Signer.PrimaryKey.Fingerprint
+	if len(p.Fingerprints) > 0 {
+		allowed := false
+		for _, fp := range p.Fingerprints {
+			if fp == primary || fp == r.Fingerprint {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return ErrKeyNotAllowed{Fingerprint: r.Fingerprint}
+		}
+	}
+
+	if p.RejectRevoked {
+		if len(r.Signer.Revocations) > 0 {
+			return ErrKeyRevoked{Fingerprint: primary}
+		}
+		for _, sk := range r.Signer.Subkeys {
+			if sk.PublicKey.Fingerprint == r.Fingerprint && sk.Sig != nil && sk.Sig.SigType == packet.SigTypeSubkeyRevocation {
+				return ErrKeyRevoked{Fingerprint: r.Fingerprint}
+			}
+		}
+	}
+
+	if p.MaxAge != 0 {
+		if expiry := r.CreationTime.Add(p.MaxAge); now.After(expiry) {
+			return ErrSignatureExpired{KeyID: r.KeyID, Expiry: expiry}
+		}
+		if r.CreationTime.After(now.Add(MaxClockSkew)) {
+			return ErrSignatureInFuture{KeyID: r.KeyID, Created: r.CreationTime}
+		}
+	}
+	pkt, err := packet.NewReader(bytes.NewReader(b)).Next()
+	if err != nil {
+		return err
+	}
+	if s, ok := pkt.(*packet.Signature); ok && s.SigLifetimeSecs != nil && *s.SigLifetimeSecs != 0 {
+		if expiry := s.CreationTime.Add(time.Duration(*s.SigLifetimeSecs) * time.Second); now.After(expiry) {
+			return ErrSignatureExpired{KeyID: r.KeyID, Expiry: expiry}
+		}
+	}
+	return nil
+}
+
+// OpenSignedFileWithPolicy opens a file that is expected to be signed as
+// policy requires, with the signatures in pathSig, and returns the
+// signatures that count.
+//
+// WARNING! Unlike many Go functions, this may return both the file and an
+// error.
+//
+// If the signatures do not satisfy policy, both the file and an ErrUnsigned
+// error wrapping ErrPolicy are returned.
+func OpenSignedFileWithPolicy(keyring openpgp.KeyRing, path, pathSig string, policy Policy) (*File, []*VerificationResult, error) {
+	content, err := readSignedFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := &File{
+		Reader:   bytes.NewReader(content),
+		FileName: path,
+	}
+
+	sig, err := readSignatureFile(pathSig)
+	if err != nil {
+		return f, nil, ErrUnsigned{Path: path, Err: err}
+	}
+	rs, err := policy.Check(keyring, content, sig)
+	if err != nil {
+		return f, rs, ErrUnsigned{Path: path, Err: err}
+	}
+	if err := measure(path, content); err != nil {
+		return f, rs, err
+	}
+	return f, rs, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func TestOpenSignedFileWithPolicy(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+
+	both := filepath.Join(dir, "both")
+	if err := (signedFile{signers: keys, content: "foo"}).write(both); err != nil {
+		t.Fatal(err)
+	}
+	// The same key twice is still one signer.
+	twice := filepath.Join(dir, "twice")
+	if err := (signedFile{signers: []*openpgp.Entity{keys[0], keys[0]}, content: "foo"}).write(twice); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked := *keys[1]
+	revoked.Revocations = []*packet.Signature{{SigType: packet.SigTypeKeyRevocation}}
+	future := func() time.Time { return time.Now().Add(24 * time.Hour) }
+	past := func() time.Time { return time.Now().Add(-time.Hour) }
+	skewed := func() time.Time { return time.Now().Add(-MaxClockSkew / 2) }
+
+	for _, tt := range []struct {
+		desc    string
+		path    string
+		keyring openpgp.EntityList
+		policy  Policy
+		want    int
+		wantErr interface{}
+	}{
+		{desc: "2 of 2", path: both, keyring: keys, policy: Policy{Threshold: 2}, want: 2},
+		{desc: "any", path: both, keyring: keys, want: 2},
+		{desc: "2 of 1", path: both, keyring: keys[:1], policy: Policy{Threshold: 2}, want: 1, wantErr: &ErrPolicy{}},
+		{desc: "same key twice", path: twice, keyring: keys, policy: Policy{Threshold: 2}, want: 1, wantErr: &ErrPolicy{}},
+		{
+			desc:    "allowed keys",
+			path:    both,
+			keyring: keys,
+			policy:  Policy{Fingerprints: [][20]byte{keys[1].PrimaryKey.Fingerprint}, Threshold: 2},
+			want:    1,
+			wantErr: &ErrKeyNotAllowed{},
+		},
+		{desc: "too old", path: both, keyring: keys, policy: Policy{MaxAge: time.Hour, Now: future}, wantErr: &ErrSignatureExpired{}},
+		{desc: "not too old", path: both, keyring: keys, policy: Policy{MaxAge: 48 * time.Hour, Now: future}, want: 2},
+		{desc: "from the future", path: both, keyring: keys, policy: Policy{MaxAge: 48 * time.Hour, Now: past}, wantErr: &ErrSignatureInFuture{}},
+		{desc: "within clock skew", path: both, keyring: keys, policy: Policy{MaxAge: 48 * time.Hour, Now: skewed}, want: 2},
+		{desc: "future without MaxAge", path: both, keyring: keys, policy: Policy{Now: past}, want: 2},
+		{
+			desc:    "revoked",
+			path:    both,
+			keyring: openpgp.EntityList{keys[0], &revoked},
+			policy:  Policy{Threshold: 2, RejectRevoked: true},
+			want:    1,
+			wantErr: &ErrPolicy{},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			f, rs, err := OpenSignedFileWithPolicy(tt.keyring, tt.path, tt.path+".sig", tt.policy)
+			if f == nil {
+				t.Fatalf("OpenSignedFileWithPolicy returned no file: %v", err)
+			}
+			if len(rs) != tt.want {
+				t.Errorf("OpenSignedFileWithPolicy returned %d signatures, want %d", len(rs), tt.want)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("OpenSignedFileWithPolicy = %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &ErrUnsigned{}) || !errors.As(err, tt.wantErr) {
+				t.Errorf("OpenSignedFileWithPolicy = %v, want ErrUnsigned wrapping %T", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"hash"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+	gpgerror "golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// ErrNotVerified is returned by VerifyingReader.Close if not all of the data
+// was read, so it could not be verified.
+var ErrNotVerified = errors.New("closed before all data was read")
+
+// VerifyingReader verifies data while it is read, rather than reading all of
+// it into memory first like OpenSignedFile and OpenHashedFile. This keeps
+// memory use flat for large kernels and initramfs images.
+//
+// WARNING! Data returned by Read is not verified until Read returns io.EOF.
+// If the data does not verify, Read returns the verification error instead
+// of io.EOF. Nothing read must be acted on before that.
+//
+// If there is a Measurer, see SetMeasurer, data that verified is measured
+// before Read returns io.EOF.
+type VerifyingReader struct {
+	r io.Reader
+	c io.Closer
+
+	// v is nil once the data has been verified.
+	v verifier
+	// m, if not nil, measures the data once it verified.
+	m      *measurement
+	name   string
+	result *VerificationResult
+	err    error
+	done   bool
+}
+
+// verifier checks data written to it.
+type verifier interface {
+	io.Writer
+
+	// verify checks the data written.
+	verify() (*VerificationResult, error)
+
+	// wrap wraps a verification error for the file name.
+	wrap(name string, err error) error
+}
+
+// NewVerifyingReader returns a VerifyingReader reading r, which came from
+// the file name, and hashing it with h. At EOF, the hash must match wantHash.
+func NewVerifyingReader(r io.Reader, name string, h crypto.Hash, wantHash []byte) (*VerifyingReader, error) {
+	v, err := newHashVerifier(h, wantHash)
+	if err != nil {
+		return nil, ErrInvalidHash{Path: name, Err: err}
+	}
+	return &VerifyingReader{r: r, v: v, m: newMeasurement(), name: name}, nil
+}
+
+// NewSignedVerifyingReader returns a VerifyingReader reading r, which came
+// from the file name. At EOF, one of the detached signatures in sig must be
+// a signature of the data by a key in keyring. Signature errors are
+// ErrUnsigned, as with OpenSignedFile.
+func NewSignedVerifyingReader(keyring openpgp.KeyRing, r io.Reader, name string, sig []byte) (*VerifyingReader, error) {
+	if keyring == nil {
+		return nil, ErrUnsigned{Path: name, Err: ErrNoKeyRing}
+	}
+	v, err := newSigVerifier(keyring, sig)
+	if err != nil {
+		return nil, ErrUnsigned{Path: name, Err: err}
+	}
+	return &VerifyingReader{r: r, v: v, m: newMeasurement(), name: name}, nil
+}
+
+// OpenHashedReader opens path for reading while its contents are checked
+// against wantHash, computed with h.
+//
+// If eager is set, the file is read into memory and checked before
+// OpenHashedReader returns, as OpenHashedFile does. Like OpenHashedFile, it
+// then returns both the reader and the error if the hash does not match.
+func OpenHashedReader(path string, h crypto.Hash, wantHash []byte, eager bool) (*VerifyingReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewVerifyingReader(f, path, h, wantHash)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.c = f
+	if eager {
+		return r, r.readAll()
+	}
+	return r, nil
+}
+
+// OpenSignedReader opens path for reading while its contents are checked
+// against the detached signatures in pathSig.
+//
+// If eager is set, the file is read into memory and checked before
+// OpenSignedReader returns, as OpenSignedFile does. Like OpenSignedFile, it
+// then returns both the reader and the error if the file is unsigned.
+func OpenSignedReader(keyring openpgp.KeyRing, path, pathSig string, eager bool) (*VerifyingReader, error) {
+	sig, err := readSignatureFile(pathSig)
+	if err != nil {
+		return nil, ErrUnsigned{Path: path, Err: err}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewSignedVerifyingReader(keyring, f, path, sig)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.c = f
+	if eager {
+		return r, r.readAll()
+	}
+	return r, nil
+}
+
+// readAll reads and verifies all the data, and keeps it to be read from
+// memory.
+func (r *VerifyingReader) readAll() error {
+	rd := r.r
+	if v, ok := r.v.(*sigVerifier); ok && v.max > 0 {
+		// Do not read more than the signature may be checked of.
+		rd = io.LimitReader(rd, v.max+1)
+	}
+	content, err := io.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	if err := r.write(content); err != nil {
+		r.done, r.err, r.v = true, r.v.wrap(r.name, err), nil
+		return r.err
+	}
+	r.finish()
+	r.r = bytes.NewReader(content)
+	return r.err
+}
+
+// write writes data read to the verifier, and the measurement.
+func (r *VerifyingReader) write(p []byte) error {
+	if _, err := r.v.Write(p); err != nil {
+		return err
+	}
+	if r.m != nil {
+		r.m.Write(p)
+	}
+	return nil
+}
+
+// finish checks the data written to the verifier, and measures it if it
+// verified.
+func (r *VerifyingReader) finish() {
+	res, err := r.v.verify()
+	switch {
+	case err != nil:
+		r.err = r.v.wrap(r.name, err)
+	case r.m != nil:
+		r.err = r.m.extend(r.name)
+	}
+	r.result = res
+	r.v = nil
+}
+
+// Name returns the file name.
+func (r *VerifyingReader) Name() string {
+	return r.name
+}
+
+// Read implements io.Reader.
+func (r *VerifyingReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, r.terminal()
+	}
+	n, err := r.r.Read(p)
+	if r.v != nil {
+		if werr := r.write(p[:n]); werr != nil {
+			r.done, r.err, r.v = true, r.v.wrap(r.name, werr), nil
+			return n, r.err
+		}
+	}
+	switch {
+	case err == io.EOF:
+		r.done = true
+		if r.v != nil {
+			r.finish()
+		}
+		return n, r.terminal()
+	case err != nil:
+		r.done, r.err = true, err
+	}
+	return n, err
+}
+
+func (r *VerifyingReader) terminal() error {
+	if r.err != nil {
+		return r.err
+	}
+	return io.EOF
+}
+
+// Verified reports whether all of the data was read and verified.
+func (r *VerifyingReader) Verified() bool {
+	return r.done && r.err == nil
+}
+
+// Result returns the signature that verified the data, or nil if the data
+// was not checked against signatures or has not been verified yet.
+func (r *VerifyingReader) Result() *VerificationResult {
+	if !r.Verified() {
+		return nil
+	}
+	return r.result
+}
+
+// Close closes the underlying file, if there is one. It returns the
+// verification error, or ErrNotVerified if not all of the data was read.
+func (r *VerifyingReader) Close() error {
+	var err error
+	if r.c != nil {
+		err = r.c.Close()
+	}
+	switch {
+	case r.v != nil:
+		return r.v.wrap(r.name, ErrNotVerified)
+	case r.err != nil:
+		return r.err
+	}
+	return err
+}
+
+type hashVerifier struct {
+	hash.Hash
+	want []byte
+}
+
+func newHashVerifier(h crypto.Hash, wantHash []byte) (*hashVerifier, error) {
+	if len(wantHash) == 0 {
+		return nil, ErrNoExpectedHash
+	}
+	if !h.Available() {
+		return nil, ErrHashUnavailable
+	}
+	return &hashVerifier{Hash: h.New(), want: wantHash}, nil
+}
+
+func (v *hashVerifier) verify() (*VerificationResult, error) {
+	got := v.Sum(nil)
+	if subtle.ConstantTimeCompare(v.want, got) == 0 {
+		return nil, ErrHashMismatch{Got: got, Want: v.want}
+	}
+	return nil, nil
+}
+
+func (v *hashVerifier) wrap(name string, err error) error {
+	return ErrInvalidHash{Path: name, Err: err}
+}
+
+// pendingSig is a signature whose signed data is being hashed.
+type pendingSig struct {
+	Signature
+	p packet.Packet
+	h hash.Hash
+	// err is set if the signature cannot be checked.
+	err error
+}
+
+// sigVerifier checks the detached signatures of a signature file while the
+// data is written. The first one made by a key in the key ring that matches
+// verifies the data.
+type sigVerifier struct {
+	keyring openpgp.KeyRing
+	sigs    []*pendingSig
+
+	// n bytes were written, of at most max.
+	n, max int64
+}
+
+func newSigVerifier(keyring openpgp.KeyRing, sig []byte) (*sigVerifier, error) {
+	sig, err := dearmor(sig)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := splitPackets(sig)
+	if err != nil {
+		return nil, err
+	}
+	v := &sigVerifier{keyring: keyring, max: getLimits().MaxFileSize}
+	for _, b := range packets {
+		s, err := signatureInfo(b)
+		if err != nil {
+			return nil, err
+		}
+		ps := &pendingSig{Signature: *s}
+		ps.p, err = packet.NewReader(bytes.NewReader(b)).Next()
+		if err != nil {
+			return nil, err
+		}
+		var (
+			h       crypto.Hash
+			sigType packet.SignatureType
+		)
+		switch p := ps.p.(type) {
+		case *packet.Signature:
+			h, sigType = p.Hash, p.SigType
+		case *packet.SignatureV3:
+			h, sigType = p.Hash, p.SigType
+		}
+		switch {
+		case sigType != packet.SigTypeBinary:
+			ps.err = gpgerror.UnsupportedError("only binary signatures can be checked while reading")
+		case !h.Available():
+			ps.err = gpgerror.UnsupportedError("hash function " + h.String())
+		default:
+			ps.h = h.New()
+		}
+		v.sigs = append(v.sigs, ps)
+	}
+	return v, nil
+}
+
+func (v *sigVerifier) Write(p []byte) (int, error) {
+	if v.n += int64(len(p)); v.max > 0 && v.n > v.max {
+		return 0, ErrLimitExceeded{Limit: "MaxFileSize", Max: v.max}
+	}
+	for _, s := range v.sigs {
+		if s.h != nil {
+			s.h.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// verify returns the first signature that matches. If none does, the error
+// is that of the first signature made by a key in the key ring, or
+// errors.ErrUnknownIssuer if there is none.
+func (v *sigVerifier) verify() (*VerificationResult, error) {
+	var firstErr error
+	for i, s := range v.sigs {
+		err := s.err
+		if err == nil {
+			err = v.check(s)
+		}
+		if err != nil {
+			if firstErr == nil && !errors.Is(err, gpgerror.ErrUnknownIssuer) {
+				firstErr = err
+			}
+			continue
+		}
+		return &VerificationResult{Signature: s.Signature, Fingerprint: v.fingerprint(s), Index: i, Signatures: len(v.sigs)}, nil
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, gpgerror.ErrUnknownIssuer
+}
+
+// check checks s against the keys with its key ID, as
+// openpgp.CheckDetachedSignature does, and sets its Signer.
+func (v *sigVerifier) check(s *pendingSig) error {
+	keys := v.keyring.KeysByIdUsage(s.KeyID, packet.KeyFlagSign)
+	if len(keys) == 0 {
+		return gpgerror.ErrUnknownIssuer
+	}
+	var err error
+	for _, k := range keys {
+		switch p := s.p.(type) {
+		case *packet.Signature:
+			err = k.PublicKey.VerifySignature(s.h, p)
+		case *packet.SignatureV3:
+			err = k.PublicKey.VerifySignatureV3(s.h, p)
+		}
+		if err == nil {
+			s.Signer = k.Entity
+			return nil
+		}
+	}
+	return err
+}
+
+func (v *sigVerifier) fingerprint(s *pendingSig) [20]byte {
+	for _, k := range v.keyring.KeysById(s.KeyID) {
+		if k.Entity == s.Signer {
+			return k.PublicKey.Fingerprint
+		}
+	}
+	return [20]byte{}
+}
+
+func (v *sigVerifier) wrap(name string, err error) error {
+	return ErrUnsigned{Path: name, Err: err}
+}
@@ -0,0 +1,179 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestVerifyingReader(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+
+	content := bytes.Repeat([]byte("kernel"), 100000)
+	path := filepath.Join(dir, "bzImage")
+	if err := (signedFile{signers: keys, content: string(content)}).write(path); err != nil {
+		t.Fatal(err)
+	}
+	good := sha256.Sum256(content)
+	bad := sha256.Sum256([]byte("initramfs"))
+
+	for _, tt := range []struct {
+		desc    string
+		open    func(eager bool) (*VerifyingReader, error)
+		wantErr bool
+		// wantSigner is the key that verified the file, if signed.
+		wantSigner *openpgp.Entity
+	}{
+		{
+			desc: "good hash",
+			open: func(eager bool) (*VerifyingReader, error) {
+				return OpenHashedReader(path, crypto.SHA256, good[:], eager)
+			},
+		},
+		{
+			desc: "bad hash",
+			open: func(eager bool) (*VerifyingReader, error) {
+				return OpenHashedReader(path, crypto.SHA256, bad[:], eager)
+			},
+			wantErr: true,
+		},
+		{
+			desc: "second signature",
+			open: func(eager bool) (*VerifyingReader, error) {
+				return OpenSignedReader(openpgp.EntityList{keys[1]}, path, path+".sig", eager)
+			},
+			wantSigner: keys[1],
+		},
+		{
+			desc: "wrong signer",
+			open: func(eager bool) (*VerifyingReader, error) {
+				return OpenSignedReader(openpgp.EntityList{readDSAKey(t)}, path, path+".sig", eager)
+			},
+			wantErr: true,
+		},
+	} {
+		for _, eager := range []bool{false, true} {
+			r, err := tt.open(eager)
+			if r == nil {
+				t.Fatalf("%s (eager %v): no reader: %v", tt.desc, eager, err)
+			}
+			if eager && (err != nil) != tt.wantErr {
+				t.Errorf("%s: eager open error = %v, want error %v", tt.desc, err, tt.wantErr)
+			}
+			got, err := io.ReadAll(r)
+			if !bytes.Equal(got, content) {
+				t.Errorf("%s (eager %v): read %d bytes, want %d", tt.desc, eager, len(got), len(content))
+			}
+			if (err != nil) != tt.wantErr || (r.Close() != nil) != tt.wantErr || r.Verified() == tt.wantErr {
+				t.Errorf("%s (eager %v): read error = %v, want error %v", tt.desc, eager, err, tt.wantErr)
+			}
+			var unsigned ErrUnsigned
+			var invalid ErrInvalidHash
+			if tt.wantErr && !errors.As(err, &unsigned) && !errors.As(err, &invalid) {
+				t.Errorf("%s (eager %v): error %v is neither ErrUnsigned nor ErrInvalidHash", tt.desc, eager, err)
+			}
+			if res := r.Result(); tt.wantSigner != nil && (res == nil || res.Signer != tt.wantSigner || res.Index != 1) {
+				t.Errorf("%s (eager %v): result %v, want signature 2 by %v", tt.desc, eager, res, tt.wantSigner)
+			}
+		}
+	}
+
+	// Closing early cannot verify the rest.
+	r, err := OpenHashedReader(path, crypto.SHA256, good[:], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); !errors.Is(err, ErrNotVerified) {
+		t.Errorf("Close() = %v, want %v", err, ErrNotVerified)
+	}
+}
+
+func TestVerifyingReaderMeasure(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("initramfs"), 10000)
+	path := filepath.Join(dir, "initramfs.cpio")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	good := sha256.Sum256(content)
+	bad := sha256.Sum256([]byte("kernel"))
+
+	m := &fakeMeasurer{}
+	SetMeasurer(m, 9)
+	defer SetMeasurer(nil, 0)
+
+	for _, eager := range []bool{false, true} {
+		r, err := OpenHashedReader(path, crypto.SHA256, good[:], eager)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+		// Data that does not verify is not measured.
+		r, err = OpenHashedReader(path, crypto.SHA256, bad[:], eager)
+		if r == nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(r)
+		r.Close()
+	}
+	if len(m.digests) != 2 || !bytes.Equal(m.digests[0], good[:]) || !bytes.Equal(m.digests[1], good[:]) || m.pcr != 9 {
+		t.Errorf("measured %x into PCR %d, want 2 times %x into PCR 9", m.digests, m.pcr, good)
+	}
+
+	m.err = errors.New("no TPM")
+	r, err := OpenHashedReader(path, crypto.SHA256, good[:], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); !errors.As(err, &ErrNotMeasured{}) {
+		t.Errorf("reading when the measurement fails = %v, want ErrNotMeasured", err)
+	}
+	if err := r.Close(); !errors.As(err, &ErrNotMeasured{}) {
+		t.Errorf("Close() = %v, want ErrNotMeasured", err)
+	}
+
+	// The Measurer is that of when the reader was created.
+	SetMeasurer(nil, 0)
+	m.err, m.digests = nil, nil
+	r, err = OpenHashedReader(path, crypto.SHA256, good[:], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetMeasurer(m, 9)
+	io.ReadAll(r)
+	r.Close()
+	if len(m.digests) != 0 {
+		t.Errorf("reader created without a Measurer measured %x", m.digests)
+	}
+}
+
+func readDSAKey(t *testing.T) *openpgp.Entity {
+	f, err := os.Open(filepath.Join("testdata", "dsakey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	el, err := ReadKeyRing(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return el[0]
+}
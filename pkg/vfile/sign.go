@@ -0,0 +1,83 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// ErrNoSigners is returned when there are no keys to sign with.
+var ErrNoSigners = errors.New("no signing keys given")
+
+// DetachSign writes detached signatures of content by each of signers to w.
+//
+// The signatures are concatenated, as OpenSignedFile expects for files
+// signed by several keys. If armored is set, they are written as one ASCII
+// armored block, as in a .asc file. signers must have decrypted private
+// keys. config may be nil for defaults.
+func DetachSign(w io.Writer, signers []*openpgp.Entity, content []byte, armored bool, config *packet.Config) error {
+	if len(signers) == 0 {
+		return ErrNoSigners
+	}
+	var sigs bytes.Buffer
+	for _, s := range signers {
+		if err := openpgp.DetachSign(&sigs, s, bytes.NewReader(content), config); err != nil {
+			return fmt.Errorf("signing with key %X: %w", s.PrimaryKey.Fingerprint, err)
+		}
+	}
+	if !armored {
+		_, err := w.Write(sigs.Bytes())
+		return err
+	}
+	aw, err := armor.Encode(w, openpgp.SignatureType, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := aw.Write(sigs.Bytes()); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// SignFileWithEntity writes a detached signature of path by signer to
+// pathSig, which OpenSignedFile(keyring, path, pathSig) verifies.
+func SignFileWithEntity(signer *openpgp.Entity, path, pathSig string) error {
+	return signFile([]*openpgp.Entity{signer}, path, pathSig, false, nil)
+}
+
+// DetachSignFile writes detached signatures of path by each of signers to
+// path.sig, where OpenSignedSigFile looks for them.
+//
+// The file is read once, and the signatures are only written if all signers
+// succeeded.
+func DetachSignFile(signers []*openpgp.Entity, path string, config *packet.Config) error {
+	return signFile(signers, path, path+".sig", false, config)
+}
+
+// DetachSignFileArmored is DetachSignFile, but writes an ASCII armored
+// signature to path.asc.
+func DetachSignFileArmored(signers []*openpgp.Entity, path string, config *packet.Config) error {
+	return signFile(signers, path, path+".asc", true, config)
+}
+
+func signFile(signers []*openpgp.Entity, path, pathSig string, armored bool, config *packet.Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var sig bytes.Buffer
+	if err := DetachSign(&sig, signers, content, armored, config); err != nil {
+		return err
+	}
+	return os.WriteFile(pathSig, sig.Bytes(), 0o644)
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestDetachSignFile(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+
+	for _, tt := range []struct {
+		desc    string
+		sign    func(path string) error
+		signers []*openpgp.Entity
+	}{
+		{
+			desc:    "one signer",
+			sign:    func(path string) error { return SignFileWithEntity(keys[1], path, path+".sig") },
+			signers: keys[1:],
+		},
+		{
+			desc:    "two signers",
+			sign:    func(path string) error { return DetachSignFile(keys, path, nil) },
+			signers: keys,
+		},
+		{
+			desc:    "armored",
+			sign:    func(path string) error { return DetachSignFileArmored(keys, path, nil) },
+			signers: keys,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			path := filepath.Join(dir, tt.desc)
+			if err := os.WriteFile(path, []byte("bzImage"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.sign(path); err != nil {
+				t.Fatalf("signing = %v", err)
+			}
+			// Each signature verifies on its own.
+			for i, k := range tt.signers {
+				_, r, err := VerifySignedSigFile(openpgp.EntityList{k}, path)
+				if err != nil {
+					t.Fatalf("VerifySignedSigFile(key %d) = %v", i, err)
+				}
+				if r.Index != i || r.Signatures != len(tt.signers) {
+					t.Errorf("VerifySignedSigFile(key %d) = signature %d of %d, want %d of %d", i, r.Index, r.Signatures, i, len(tt.signers))
+				}
+			}
+
+			// And not for other content.
+			if err := os.WriteFile(path, []byte("evil"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := OpenSignedSigFile(openpgp.EntityList(tt.signers), path); !errors.As(err, &ErrUnsigned{}) {
+				t.Errorf("OpenSignedSigFile(changed file) = %v, want ErrUnsigned", err)
+			}
+		})
+	}
+
+	path := filepath.Join(dir, "unsigned")
+	if err := os.WriteFile(path, []byte("bzImage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := DetachSignFile(nil, path, nil); !errors.Is(err, ErrNoSigners) {
+		t.Errorf("DetachSignFile(no signers) = %v, want %v", err, ErrNoSigners)
+	}
+	public := &openpgp.Entity{PrimaryKey: keys[0].PrimaryKey, Identities: keys[0].Identities}
+	if err := DetachSignFile([]*openpgp.Entity{keys[1], public}, path, nil); err == nil {
+		t.Errorf("DetachSignFile(public key) = nil, want an error")
+	}
+	if _, err := os.Stat(path + ".sig"); !os.IsNotExist(err) {
+		t.Errorf("DetachSignFile wrote a signature after failing: %v", err)
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	gpgerror "golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// Signature describes an OpenPGP signature that was checked.
+type Signature struct {
+	// KeyID is the ID of the key that made the signature.
+	KeyID uint64
+
+	// CreationTime is when the signature was made.
+	CreationTime time.Time
+
+	// Signer is the entity of the key ring that made the signature, or nil
+	// if the key is not in the key ring.
+	Signer *openpgp.Entity
+}
+
+// ErrBadSignature is returned for a signature made by a key in the key ring
+// that does not match the signed data.
+type ErrBadSignature struct {
+	// Err is the error of the signature check.
+	Err error
+}
+
+func (e ErrBadSignature) Error() string {
+	return fmt.Sprintf("bad signature: %v", e.Err)
+}
+
+func (e ErrBadSignature) Unwrap() error {
+	return e.Err
+}
+
+const (
+	armorPrefix     = "-----BEGIN PGP "
+	armorEndPrefix  = "-----END PGP "
+	clearSignPrefix = "-----BEGIN PGP SIGNED MESSAGE-----"
+)
+
+// IsClearSigned reports whether b is a clear-signed message.
+func IsClearSigned(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(b, " \t\r\n"), []byte(clearSignPrefix))
+}
+
+// dearmor returns the body of b if it is ASCII armored, and b otherwise.
+//
+// If b holds several armored blocks, e.g. concatenated .asc key files, their
+// bodies are concatenated. Text between the blocks is ignored.
+func dearmor(b []byte) ([]byte, error) {
+	rest := bytes.TrimLeft(b, " \t\r\n")
+	if !bytes.HasPrefix(rest, []byte(armorPrefix)) {
+		return b, nil
+	}
+	var body []byte
+	for {
+		i := bytes.Index(rest, []byte(armorPrefix))
+		if i < 0 {
+			return body, nil
+		}
+		rest = rest[i:]
+		// The block ends with its END line.
+		n := len(rest)
+		if end := bytes.Index(rest, []byte(armorEndPrefix)); end >= 0 {
+			if nl := bytes.IndexByte(rest[end:], '\n'); nl >= 0 {
+				n = end + nl + 1
+			}
+		}
+		block, err := armor.Decode(bytes.NewReader(rest[:n]))
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, b...)
+		rest = rest[n:]
+	}
+}
+
+// IsDetachedSignature reports whether b, which may be ASCII armored, holds
+// only a signature, as opposed to a signed message.
+func IsDetachedSignature(b []byte) bool {
+	body, err := dearmor(b)
+	if err != nil {
+		return false
+	}
+	p, err := packet.NewReader(bytes.NewReader(body)).Next()
+	if err != nil {
+		return false
+	}
+	switch p.(type) {
+	case *packet.Signature, *packet.SignatureV3:
+		return true
+	}
+	return false
+}
+
+// ReadKeyRing reads a binary or ASCII armored key ring.
+//
+// Unlike openpgp.ReadKeyRing, bare public keys without user IDs or
+// self-signatures are accepted, since small signing-only key files are
+// often exported that way.
+func ReadKeyRing(r io.Reader) (openpgp.EntityList, error) {
+	b, err := readAtMost(r, getLimits().MaxKeyRingSize, "MaxKeyRingSize")
+	if err != nil {
+		return nil, err
+	}
+	if b, err = dearmor(b); err != nil {
+		return nil, err
+	}
+	if err := checkKeyCount(b); err != nil {
+		return nil, err
+	}
+	if el, err := openpgp.ReadKeyRing(bytes.NewReader(b)); err == nil {
+		return el, nil
+	}
+
+	var el openpgp.EntityList
+	packets := packet.NewReader(bytes.NewReader(b))
+	for {
+		p, err := packets.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pk, ok := p.(*packet.PublicKey); ok && !pk.IsSubkey {
+			el = append(el, &openpgp.Entity{PrimaryKey: pk, Identities: map[string]*openpgp.Identity{}})
+		}
+	}
+	if len(el) == 0 {
+		return nil, gpgerror.StructuralError("no public keys found")
+	}
+	return el, nil
+}
+
+// signatureInfo returns the key ID and creation time of the first signature
+// packet in sig.
+func signatureInfo(sig []byte) (*Signature, error) {
+	p, err := packet.NewReader(bytes.NewReader(sig)).Next()
+	if err != nil {
+		return nil, err
+	}
+	switch s := p.(type) {
+	case *packet.Signature:
+		if s.IssuerKeyId == nil {
+			return nil, gpgerror.StructuralError("signature without issuer")
+		}
+		return &Signature{KeyID: *s.IssuerKeyId, CreationTime: s.CreationTime}, nil
+	case *packet.SignatureV3:
+		return &Signature{KeyID: s.IssuerKeyId, CreationTime: s.CreationTime}, nil
+	}
+	return nil, gpgerror.StructuralError("expected a signature packet")
+}
+
+// checkError converts the errors of openpgp signature checks. openpgp does
+// not return the signer of a bad signature, so it is looked up in ring.
+func checkError(ring openpgp.KeyRing, s *Signature, err error) error {
+	var sigErr gpgerror.SignatureError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gpgerror.ErrUnknownIssuer):
+		return ErrWrongSigner{ring}
+	case errors.As(err, &sigErr):
+		if keys := ring.KeysById(s.KeyID); s.Signer == nil && len(keys) > 0 {
+			s.Signer = keys[0].Entity
+		}
+		return ErrBadSignature{Err: err}
+	}
+	return err
+}
+
+// VerifyDetachedSignature checks the signature sig, which may be ASCII
+// armored, of content against ring.
+//
+// If the signature was made by a key that is not in ring, the returned
+// Signature has no Signer and the error is ErrWrongSigner. If it does not
+// match content, the error is ErrBadSignature.
+func VerifyDetachedSignature(ring openpgp.KeyRing, content, sig io.Reader) (*Signature, error) {
+	if ring == nil {
+		return nil, ErrNoKeyRing
+	}
+	b, err := ReadSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = dearmor(b); err != nil {
+		return nil, err
+	}
+	if _, err := splitPackets(b); err != nil {
+		return nil, err
+	}
+	s, err := signatureInfo(b)
+	if err != nil {
+		return nil, err
+	}
+	s.Signer, err = openpgp.CheckDetachedSignature(ring, content, bytes.NewReader(b))
+	return s, checkError(ring, s, err)
+}
+
+// VerifyClearSigned checks the clear-signed message msg against ring, and
+// returns the signed text. Errors are those of VerifyDetachedSignature.
+func VerifyClearSigned(ring openpgp.KeyRing, msg []byte) ([]byte, *Signature, error) {
+	block, _ := clearsign.Decode(msg)
+	if block == nil {
+		return nil, nil, gpgerror.StructuralError("no clear-signed message found")
+	}
+	if ring == nil {
+		return nil, nil, ErrNoKeyRing
+	}
+	sig, err := readAtMost(block.ArmoredSignature.Body, getLimits().MaxSignatureSize, "MaxSignatureSize")
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := splitPackets(sig); err != nil {
+		return nil, nil, err
+	}
+	s, err := signatureInfo(sig)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.Signer, err = openpgp.CheckDetachedSignature(ring, bytes.NewReader(block.Bytes), bytes.NewReader(sig))
+	return block.Plaintext, s, checkError(ring, s, err)
+}
+
+// VerifySignedMessage checks the signed message msg, which may be ASCII
+// armored, against ring, and returns its contents. Errors are those of
+// VerifyDetachedSignature.
+func VerifySignedMessage(ring openpgp.KeyRing, msg io.Reader) ([]byte, *Signature, error) {
+	if ring == nil {
+		return nil, nil, ErrNoKeyRing
+	}
+	b, err := io.ReadAll(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b, err = dearmor(b); err != nil {
+		return nil, nil, err
+	}
+	md, err := openpgp.ReadMessage(bytes.NewReader(b), ring, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !md.IsSigned {
+		return nil, nil, gpgerror.StructuralError("message is not signed")
+	}
+	// The signature follows the data, so it is only checked once all of
+	// the data has been read.
+	content, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &Signature{KeyID: md.SignedByKeyId}
+	if md.SignedBy == nil {
+		return content, s, ErrWrongSigner{ring}
+	}
+	s.Signer = md.SignedBy.Entity
+	switch {
+	case md.Signature != nil:
+		s.CreationTime = md.Signature.CreationTime
+	case md.SignatureV3 != nil:
+		s.CreationTime = md.SignatureV3.CreationTime
+	}
+	if err := checkError(ring, s, md.SignatureError); err != nil {
+		return content, s, err
+	}
+	return content, s, checkStrictMessage(md)
+}
+
+// splitPackets splits b into its OpenPGP packets, headers included.
+func splitPackets(b []byte) ([][]byte, error) {
+	var packets [][]byte
+	for len(b) > 0 {
+		hdr, n, err := packetLen(b)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSignaturePacket(b[:n], hdr); err != nil {
+			return nil, err
+		}
+		packets = append(packets, b[:n])
+		b = b[n:]
+	}
+	if err := checkSignatureCount(len(packets)); err != nil {
+		return nil, err
+	}
+	return packets, nil
+}
+
+// packetLen returns the length of the header of the first packet of b, and
+// of the whole packet, see RFC 4880 section 4.2. Partial and indeterminate
+// lengths, which signatures do not use, are not supported.
+func packetLen(b []byte) (int, int, error) {
+	short := gpgerror.StructuralError("short packet")
+	if b[0]&0x80 == 0 {
+		return 0, 0, gpgerror.StructuralError("tag byte does not have MSB set")
+	}
+	if len(b) < 2 {
+		return 0, 0, short
+	}
+	// The body length is kept as a uint64 until it is known to fit in b,
+	// a four byte length does not fit in an int on 32-bit systems.
+	var hdr int
+	var body uint64
+	if b[0]&0x40 == 0 {
+		// Old format: the low bits of the tag are the size of the length.
+		switch b[0] & 3 {
+		case 0:
+			hdr, body = 2, uint64(b[1])
+		case 1:
+			if hdr = 3; len(b) >= hdr {
+				body = uint64(binary.BigEndian.Uint16(b[1:]))
+			}
+		case 2:
+			if hdr = 5; len(b) >= hdr {
+				body = uint64(binary.BigEndian.Uint32(b[1:]))
+			}
+		default:
+			return 0, 0, gpgerror.UnsupportedError("indeterminate packet length")
+		}
+	} else {
+		switch {
+		case b[1] < 192:
+			hdr, body = 2, uint64(b[1])
+		case b[1] < 224:
+			if hdr = 3; len(b) >= hdr {
+				body = (uint64(b[1])-192)<<8 + uint64(b[2]) + 192
+			}
+		case b[1] == 255:
+			if hdr = 6; len(b) >= hdr {
+				body = uint64(binary.BigEndian.Uint32(b[2:]))
+			}
+		default:
+			return 0, 0, gpgerror.UnsupportedError("partial packet length")
+		}
+	}
+	if len(b) < hdr || uint64(len(b)-hdr) < body {
+		return 0, 0, short
+	}
+	return hdr, hdr + int(body), nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+func readKeys(t *testing.T) []*openpgp.Entity {
+	var keys []*openpgp.Entity
+	for _, k := range []string{"key0", "key1"} {
+		b, err := os.ReadFile(filepath.Join("testdata", k))
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(b)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestReadKeyRing(t *testing.T) {
+	keys := readKeys(t)
+
+	var full, armored, bare bytes.Buffer
+	if err := keys[0].Serialize(&full); err != nil {
+		t.Fatal(err)
+	}
+	w, err := armor.Encode(&armored, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(full.Bytes())
+	w.Close()
+	if err := keys[0].PrimaryKey.Serialize(&bare); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, b := range map[string][]byte{"binary": full.Bytes(), "armored": armored.Bytes(), "bare": bare.Bytes()} {
+		el, err := ReadKeyRing(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(el) != 1 || el[0].PrimaryKey.KeyId != keys[0].PrimaryKey.KeyId {
+			t.Errorf("%s: ReadKeyRing = %v, want key %X", name, el, keys[0].PrimaryKey.KeyId)
+		}
+	}
+	if _, err := ReadKeyRing(strings.NewReader("not a key")); err == nil {
+		t.Errorf("ReadKeyRing of garbage succeeded")
+	}
+}
+
+func TestVerifySignatures(t *testing.T) {
+	keys := readKeys(t)
+	ring := openpgp.EntityList{keys[0]}
+	content := "kernel image\n"
+
+	sign := func(signer *openpgp.Entity, armored bool) []byte {
+		var b bytes.Buffer
+		var err error
+		if armored {
+			err = openpgp.ArmoredDetachSign(&b, signer, strings.NewReader(content), nil)
+		} else {
+			err = openpgp.DetachSign(&b, signer, strings.NewReader(content), nil)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+
+	for _, tt := range []struct {
+		name    string
+		sig     []byte
+		content string
+		ring    openpgp.KeyRing
+		signer  bool
+		wantErr interface{}
+	}{
+		{name: "good", sig: sign(keys[0], false), content: content, ring: ring, signer: true},
+		{name: "good armored", sig: sign(keys[0], true), content: content, ring: ring, signer: true},
+		{name: "bad", sig: sign(keys[0], true), content: "other\n", ring: ring, signer: true, wantErr: &ErrBadSignature{}},
+		{name: "unknown key", sig: sign(keys[1], false), content: content, ring: ring, wantErr: &ErrWrongSigner{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsDetachedSignature(tt.sig) {
+				t.Errorf("IsDetachedSignature = false")
+			}
+			s, err := VerifyDetachedSignature(tt.ring, strings.NewReader(tt.content), bytes.NewReader(tt.sig))
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.As(err, tt.wantErr) {
+				t.Fatalf("VerifyDetachedSignature = %v, want %T", err, tt.wantErr)
+			}
+			if (s.Signer != nil) != tt.signer || s.CreationTime.IsZero() {
+				t.Errorf("Signature = %+v, want signer %v", s, tt.signer)
+			}
+		})
+	}
+
+	if _, err := VerifyDetachedSignature(nil, strings.NewReader(content), bytes.NewReader(sign(keys[0], false))); err != ErrNoKeyRing {
+		t.Errorf("VerifyDetachedSignature(nil ring) = %v, want %v", err, ErrNoKeyRing)
+	}
+
+	// Clear-signed messages, e.g. Debian InRelease files.
+	var cs bytes.Buffer
+	w, err := clearsign.Encode(&cs, keys[0].PrivateKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	w.Close()
+	if !IsClearSigned(cs.Bytes()) || IsDetachedSignature(cs.Bytes()) {
+		t.Errorf("clear-signed message not recognized")
+	}
+	if text, s, err := VerifyClearSigned(ring, cs.Bytes()); err != nil || string(text) != content || s.Signer != keys[0] {
+		t.Errorf("VerifyClearSigned = %q, %+v, %v", text, s, err)
+	}
+	tampered := bytes.Replace(cs.Bytes(), []byte("kernel"), []byte("evil"), 1)
+	if _, _, err := VerifyClearSigned(ring, tampered); !errors.As(err, &ErrBadSignature{}) {
+		t.Errorf("VerifyClearSigned(tampered) = %v, want a bad signature", err)
+	}
+
+	// Signed messages, as made by gpg --sign.
+	// The test keys prefer RIPEMD160, which is not compiled in.
+	for _, id := range keys[0].Identities {
+		id.SelfSignature.PreferredHash = []uint8{8} // SHA256
+	}
+	var sm bytes.Buffer
+	w, err = openpgp.Sign(&sm, keys[0], nil, &packet.Config{DefaultHash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	w.Close()
+	if IsDetachedSignature(sm.Bytes()) {
+		t.Errorf("signed message is taken for a detached signature")
+	}
+	if b, s, err := VerifySignedMessage(ring, bytes.NewReader(sm.Bytes())); err != nil || string(b) != content || s.Signer != keys[0] {
+		t.Errorf("VerifySignedMessage = %q, %+v, %v", b, s, err)
+	}
+	if _, s, err := VerifySignedMessage(openpgp.EntityList{keys[1]}, bytes.NewReader(sm.Bytes())); !errors.As(err, &ErrWrongSigner{}) || s.KeyID != keys[0].PrimaryKey.KeyId {
+		t.Errorf("VerifySignedMessage(other ring) = %+v, %v, want a wrong signer", s, err)
+	}
+}
+
+func TestArmoredKeyRingsAndSignatures(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+
+	armored := func(e *openpgp.Entity) []byte {
+		var b bytes.Buffer
+		w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Serialize(w); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+		return b.Bytes()
+	}
+	var binary bytes.Buffer
+	for _, k := range keys {
+		if err := k.Serialize(&binary); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two .asc files concatenated, as cat key0.asc key1.asc makes.
+	both := append(append(armored(keys[0]), "\n"...), armored(keys[1])...)
+
+	for name, b := range map[string][]byte{"binary": binary.Bytes(), "armored": both} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		ring, err := GetKeyRing(path)
+		if err != nil {
+			t.Fatalf("GetKeyRing(%s) = %v", name, err)
+		}
+		el := ring.(openpgp.EntityList)
+		if len(el) != 2 || el[0].PrimaryKey.KeyId != keys[0].PrimaryKey.KeyId || el[1].PrimaryKey.KeyId != keys[1].PrimaryKey.KeyId {
+			t.Errorf("GetKeyRing(%s) = %v, want key0 and key1", name, el)
+		}
+	}
+
+	// Without a .sig, the armored .asc signature is used.
+	path := filepath.Join(dir, "vmlinuz")
+	if err := os.WriteFile(path, []byte("kernel"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, keys[1], strings.NewReader("kernel"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".asc", sig.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, r, err := VerifySignedSigFile(openpgp.EntityList(keys), path); err != nil || r.Signer != keys[1] {
+		t.Errorf("VerifySignedSigFile with .asc = %v, %v, want signed by key1", r, err)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Signify and minisign key and signature files hold a comment line and a
+// base64 line. The base64 data starts with the algorithm and a key number:
+//
+//	public key: "Ed" | key number (8) | Ed25519 public key (32)
+//	signature:  "Ed" or "ED" | key number (8) | Ed25519 signature (64)
+//
+// "ED" is minisign's prehashed mode, which signs the BLAKE2b-512 hash of
+// the file. minisign signatures also have a trusted comment line, and a
+// signature of the signature and the trusted comment.
+const (
+	signifyAlg      = "Ed"
+	minisignHashAlg = "ED"
+
+	untrustedPrefix = "untrusted comment: "
+	trustedPrefix   = "trusted comment: "
+)
+
+// ErrUnknownSignifyKey is returned for a signify or minisign signature made
+// by a key that was not given.
+type ErrUnknownSignifyKey struct {
+	// KeyNum is the key number of the signature.
+	KeyNum [8]byte
+}
+
+func (e ErrUnknownSignifyKey) Error() string {
+	return fmt.Sprintf("signed by unknown key %X", e.KeyNum)
+}
+
+// SignifyPublicKey is an Ed25519 public key of OpenBSD signify or minisign.
+type SignifyPublicKey struct {
+	// KeyNum is the key number, which signatures refer to.
+	KeyNum [8]byte
+
+	// Key is the public key.
+	Key ed25519.PublicKey
+}
+
+// SignifySignature is a signify or minisign signature.
+type SignifySignature struct {
+	// KeyNum is the key number of the key that made the signature.
+	KeyNum [8]byte
+
+	// Prehashed is set for minisign signatures of the BLAKE2b-512 hash of
+	// the file.
+	Prehashed bool
+
+	// TrustedComment is the minisign trusted comment, which is signed
+	// along with the signature.
+	TrustedComment string
+
+	sig       []byte
+	globalSig []byte
+}
+
+// readBase64Line returns the first line of s that is not a comment, decoded.
+func readBase64Line(s *bufio.Scanner) ([]byte, error) {
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, untrustedPrefix) {
+			continue
+		}
+		return base64.StdEncoding.DecodeString(line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// ParseSignifyPublicKey parses a signify or minisign public key file.
+func ParseSignifyPublicKey(b []byte) (*SignifyPublicKey, error) {
+	d, err := readBase64Line(bufio.NewScanner(bytes.NewReader(b)))
+	if err != nil {
+		return nil, fmt.Errorf("signify public key: %w", err)
+	}
+	if len(d) != 2+8+ed25519.PublicKeySize || string(d[:2]) != signifyAlg {
+		return nil, errors.New("signify public key: not an Ed25519 key")
+	}
+	k := &SignifyPublicKey{Key: ed25519.PublicKey(d[10:])}
+	copy(k.KeyNum[:], d[2:10])
+	return k, nil
+}
+
+// GetSignifyPublicKey reads a signify or minisign public key file.
+//
+// keyPath must be an already trusted path, e.g. keys are included in the initramfs.
+func GetSignifyPublicKey(keyPath string) (*SignifyPublicKey, error) {
+	b, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read pub key: %v", err)
+	}
+	return ParseSignifyPublicKey(b)
+}
+
+// ParseSignifySignature parses a signify or minisign signature file.
+func ParseSignifySignature(b []byte) (*SignifySignature, error) {
+	s := bufio.NewScanner(bytes.NewReader(b))
+	d, err := readBase64Line(s)
+	if err != nil {
+		return nil, fmt.Errorf("signify signature: %w", err)
+	}
+	if len(d) != 2+8+ed25519.SignatureSize {
+		return nil, errors.New("signify signature: wrong size")
+	}
+	sig := &SignifySignature{sig: d[10:]}
+	switch string(d[:2]) {
+	case signifyAlg:
+	case minisignHashAlg:
+		sig.Prehashed = true
+	default:
+		return nil, fmt.Errorf("signify signature: unknown algorithm %q", d[:2])
+	}
+	copy(sig.KeyNum[:], d[2:10])
+
+	// minisign adds a trusted comment and the global signature.
+	if !s.Scan() {
+		return sig, s.Err()
+	}
+	line := s.Text()
+	if !strings.HasPrefix(line, trustedPrefix) {
+		return nil, errors.New("signify signature: expected a trusted comment")
+	}
+	sig.TrustedComment = strings.TrimPrefix(line, trustedPrefix)
+	if sig.globalSig, err = readBase64Line(s); err != nil {
+		return nil, fmt.Errorf("signify signature: %w", err)
+	}
+	if len(sig.globalSig) != ed25519.SignatureSize {
+		return nil, errors.New("signify signature: wrong trusted comment signature size")
+	}
+	return sig, nil
+}
+
+// Verify checks the signature of content against keys, and returns the key
+// that made it.
+//
+// If the signature was made by none of keys, the error is
+// ErrUnknownSignifyKey. If it does not match content or its trusted
+// comment, the error is ErrBadSignature.
+func (s *SignifySignature) Verify(keys []*SignifyPublicKey, content []byte) (*SignifyPublicKey, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeyRing
+	}
+	var key *SignifyPublicKey
+	for _, k := range keys {
+		if k.KeyNum == s.KeyNum {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return nil, ErrUnknownSignifyKey{KeyNum: s.KeyNum}
+	}
+	if s.Prehashed {
+		h := blake2b.Sum512(content)
+		content = h[:]
+	}
+	if !ed25519.Verify(key.Key, content, s.sig) {
+		return key, ErrBadSignature{Err: errors.New("Ed25519 signature does not match")}
+	}
+	if s.globalSig != nil && !ed25519.Verify(key.Key, append(append([]byte{}, s.sig...), s.TrustedComment...), s.globalSig) {
+		return key, ErrBadSignature{Err: errors.New("trusted comment signature does not match")}
+	}
+	return key, nil
+}
+
+// OpenSignedSignifyFile opens a file that is expected to be signed by one of
+// keys with signify or minisign, with the signature in pathSig.
+//
+// WARNING! Unlike many Go functions, this may return both the file and an
+// error.
+//
+// If the signature does not exist or does not match the keys, both the file
+// and an ErrUnsigned error will be returned, as OpenSignedFile does.
+func OpenSignedSignifyFile(keys []*SignifyPublicKey, path, pathSig string) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{
+		Reader:   bytes.NewReader(content),
+		FileName: path,
+	}
+
+	b, err := readSignatureFile(pathSig)
+	if err != nil {
+		return f, ErrUnsigned{Path: path, Err: err}
+	}
+	sig, err := ParseSignifySignature(b)
+	if err != nil {
+		return f, ErrUnsigned{Path: path, Err: err}
+	}
+	if _, err := sig.Verify(keys, content); err != nil {
+		return f, ErrUnsigned{Path: path, Err: err}
+	}
+	if err := measure(path, content); err != nil {
+		return f, err
+	}
+	return f, nil
+}
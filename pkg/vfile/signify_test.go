@@ -0,0 +1,113 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+type signifyKey struct {
+	num  string
+	priv ed25519.PrivateKey
+}
+
+func newSignifyKey(t *testing.T, num string) *signifyKey {
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signifyKey{num: num, priv: priv}
+}
+
+func (k *signifyKey) public() string {
+	d := append([]byte("Ed"+k.num), k.priv.Public().(ed25519.PublicKey)...)
+	return "untrusted comment: signify public key\n" + base64.StdEncoding.EncodeToString(d) + "\n"
+}
+
+// sign makes a signify signature, or a minisign one if comment is set.
+func (k *signifyKey) sign(content []byte, prehash bool, comment string) string {
+	alg := "Ed"
+	if prehash {
+		h := blake2b.Sum512(content)
+		content, alg = h[:], "ED"
+	}
+	sig := ed25519.Sign(k.priv, content)
+	s := "untrusted comment: verify with key.pub\n" + base64.StdEncoding.EncodeToString(append([]byte(alg+k.num), sig...)) + "\n"
+	if comment != "" {
+		global := ed25519.Sign(k.priv, append(sig, comment...))
+		s += fmt.Sprintf("trusted comment: %s\n%s\n", comment, base64.StdEncoding.EncodeToString(global))
+	}
+	return s
+}
+
+func TestOpenSignedSignifyFile(t *testing.T) {
+	dir := t.TempDir()
+	k0, k1 := newSignifyKey(t, "key0num0"), newSignifyKey(t, "key1num1")
+	var keys []*SignifyPublicKey
+	for _, k := range []*signifyKey{k0, k1} {
+		pk, err := ParseSignifyPublicKey([]byte(k.public()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, pk)
+	}
+
+	content := []byte("bzImage")
+	path := filepath.Join(dir, "bzImage")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	good := k1.sign(content, false, "")
+	for _, tt := range []struct {
+		desc    string
+		sig     string
+		keys    []*SignifyPublicKey
+		wantErr interface{}
+	}{
+		{desc: "signify", sig: good, keys: keys},
+		{desc: "minisign", sig: k0.sign(content, false, "timestamp:1 file:bzImage"), keys: keys},
+		{desc: "minisign prehashed", sig: k1.sign(content, true, "timestamp:1 file:bzImage"), keys: keys},
+		{desc: "other content", sig: k0.sign([]byte("evil"), true, ""), keys: keys, wantErr: &ErrBadSignature{}},
+		{
+			desc:    "trusted comment changed",
+			sig:     strings.Replace(k0.sign(content, true, "timestamp:1"), "timestamp:1", "timestamp:2", 1),
+			keys:    keys,
+			wantErr: &ErrBadSignature{},
+		},
+		{desc: "unknown key", sig: good, keys: keys[:1], wantErr: &ErrUnknownSignifyKey{}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if err := os.WriteFile(path+".sig", []byte(tt.sig), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			f, err := OpenSignedSignifyFile(tt.keys, path, path+".sig")
+			if f == nil {
+				t.Fatalf("OpenSignedSignifyFile returned no file: %v", err)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("OpenSignedSignifyFile = %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &ErrUnsigned{}) || !errors.As(err, tt.wantErr) {
+				t.Errorf("OpenSignedSignifyFile = %v, want ErrUnsigned wrapping %T", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := OpenSignedSignifyFile(nil, path, path+".sig"); !errors.Is(err, ErrNoKeyRing) {
+		t.Errorf("OpenSignedSignifyFile(no keys) = %v, want %v", err, ErrNoKeyRing)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"crypto"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/openpgp"
+	gpgerror "golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/openpgp/s2k"
+)
+
+// ErrStrict is returned for a signature that Limits.Strict rejects.
+type ErrStrict struct {
+	// Reason is why the signature was rejected.
+	Reason string
+}
+
+func (e ErrStrict) Error() string {
+	return fmt.Sprintf("signature rejected by strict mode: %s", e.Reason)
+}
+
+// strictHashes are the digests strict mode accepts.
+var strictHashes = map[crypto.Hash]bool{
+	crypto.SHA224: true,
+	crypto.SHA256: true,
+	crypto.SHA384: true,
+	crypto.SHA512: true,
+}
+
+// strictCritical are the subpacket types, see RFC 4880 section 5.2.3.1,
+// strict mode accepts marked critical: those whose meaning is checked.
+var strictCritical = map[byte]bool{
+	2:  true, // signature creation time
+	3:  true, // signature expiration time
+	16: true, // issuer
+}
+
+// packetTagSignature is the tag of signature packets.
+const packetTagSignature = 2
+
+// checkSignaturePacket checks the signature packet p, whose header is hdr
+// bytes long, against the Limits before it is parsed.
+func checkSignaturePacket(p []byte, hdr int) error {
+	l := getLimits()
+	if max := l.MaxSignaturePacketSize; max > 0 && len(p) > max {
+		return ErrLimitExceeded{Limit: "MaxSignaturePacketSize", Max: int64(max)}
+	}
+	if !l.Strict {
+		return nil
+	}
+	tag := p[0] & 0x3f
+	if p[0]&0x40 == 0 {
+		tag = (p[0] >> 2) & 0xf
+	}
+	if tag != packetTagSignature {
+		return ErrStrict{Reason: fmt.Sprintf("packet of type %d is not a signature", tag)}
+	}
+	return checkStrictSignature(p[hdr:])
+}
+
+// checkStrictSignature checks the body of a signature packet, see RFC 4880
+// section 5.2.3.
+func checkStrictSignature(b []byte) error {
+	if len(b) == 0 {
+		return gpgerror.StructuralError("empty signature packet")
+	}
+	if b[0] != 4 {
+		return ErrStrict{Reason: fmt.Sprintf("version %d signature", b[0])}
+	}
+	if len(b) < 6 {
+		return gpgerror.StructuralError("short signature packet")
+	}
+	if err := checkStrictHash(b[3]); err != nil {
+		return err
+	}
+	b = b[4:]
+	for _, area := range []string{"hashed", "unhashed"} {
+		if len(b) < 2 {
+			return gpgerror.StructuralError("short signature packet")
+		}
+		n := int(binary.BigEndian.Uint16(b))
+		if len(b)-2 < n {
+			return gpgerror.StructuralError("short signature subpackets")
+		}
+		if err := checkStrictSubpackets(b[2:2+n], area); err != nil {
+			return err
+		}
+		b = b[2+n:]
+	}
+	return nil
+}
+
+// checkStrictHash checks the OpenPGP hash algorithm id.
+func checkStrictHash(id byte) error {
+	h, ok := s2k.HashIdToHash(id)
+	if !ok {
+		return ErrStrict{Reason: fmt.Sprintf("unknown digest %d", id)}
+	}
+	if !strictHashes[h] {
+		return ErrStrict{Reason: fmt.Sprintf("weak digest %v", h)}
+	}
+	return nil
+}
+
+// checkStrictSubpackets checks the subpackets b of a signature's area.
+func checkStrictSubpackets(b []byte, area string) error {
+	short := gpgerror.StructuralError("short signature subpacket")
+	for len(b) > 0 {
+		// As in packetLen, n is a uint64 until it is known to fit in b.
+		var hdr int
+		var n uint64
+		switch {
+		case b[0] < 192:
+			hdr, n = 1, uint64(b[0])
+		case b[0] < 255:
+			if len(b) < 2 {
+				return short
+			}
+			hdr, n = 2, (uint64(b[0])-192)<<8+uint64(b[1])+192
+		default:
+			if len(b) < 5 {
+				return short
+			}
+			hdr, n = 5, uint64(binary.BigEndian.Uint32(b[1:]))
+		}
+		if n == 0 {
+			return gpgerror.StructuralError("zero length signature subpacket")
+		}
+		if uint64(len(b)-hdr) < n {
+			return short
+		}
+		typ := b[hdr]
+		if critical := typ&0x80 != 0; critical && !strictCritical[typ&0x7f] {
+			return ErrStrict{Reason: fmt.Sprintf("critical subpacket of type %d in the %s area", typ&0x7f, area)}
+		}
+		b = b[hdr+int(n):]
+	}
+	return nil
+}
+
+// checkStrictMessage checks the signature of a signed message as far as
+// openpgp keeps it: its subpackets are checked by openpgp alone.
+func checkStrictMessage(md *openpgp.MessageDetails) error {
+	if !getLimits().Strict {
+		return nil
+	}
+	switch {
+	case md.SignatureV3 != nil:
+		return ErrStrict{Reason: "version 3 signature"}
+	case md.Signature != nil && !strictHashes[md.Signature.Hash]:
+		return ErrStrict{Reason: fmt.Sprintf("weak digest %v", md.Signature.Hash)}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// sigPacket returns a signature packet of version v with the digest hash,
+// and the subpacket areas hashed and unhashed. Its signature is not valid.
+func sigPacket(v, hash byte, hashed, unhashed []byte) []byte {
+	body := []byte{v, 0, 1, hash, 0, byte(len(hashed))}
+	body = append(body, hashed...)
+	body = append(body, 0, byte(len(unhashed)))
+	body = append(body, unhashed...)
+	body = append(body, 0, 0)
+	return append([]byte{0xc0 | packetTagSignature, byte(len(body))}, body...)
+}
+
+func TestCheckSignaturePacket(t *testing.T) {
+	const (
+		sha1   = 2
+		sha256 = 8
+	)
+	var (
+		created        = []byte{5, 2, 0, 0, 0, 1}
+		issuer         = []byte{9, 16, 1, 2, 3, 4, 5, 6, 7, 8}
+		criticalIssuer = []byte{9, 0x80 | 16, 1, 2, 3, 4, 5, 6, 7, 8}
+		notation       = []byte{2, 0x80 | 20, 0}
+	)
+	cat := func(b ...[]byte) []byte { return bytes.Join(b, nil) }
+
+	defer SetLimits(DefaultLimits)
+	for _, tt := range []struct {
+		name   string
+		p      []byte
+		limits Limits
+		want   string
+	}{
+		{name: "good", p: sigPacket(4, sha256, created, issuer), limits: StrictLimits},
+		{name: "critical issuer", p: sigPacket(4, sha256, created, criticalIssuer), limits: StrictLimits},
+		{name: "sha1", p: sigPacket(4, sha1, created, issuer), limits: StrictLimits, want: "strict"},
+		{name: "sha1 not strict", p: sigPacket(4, sha1, created, issuer), limits: DefaultLimits},
+		{name: "unknown digest", p: sigPacket(4, 99, created, issuer), limits: StrictLimits, want: "strict"},
+		{name: "v3", p: sigPacket(3, sha256, nil, nil), limits: StrictLimits, want: "strict"},
+		{name: "hashed critical notation", p: sigPacket(4, sha256, cat(created, notation), issuer), limits: StrictLimits, want: "strict"},
+		{name: "unhashed critical notation", p: sigPacket(4, sha256, created, cat(issuer, notation)), limits: StrictLimits, want: "strict"},
+		{name: "truncated subpacket", p: sigPacket(4, sha256, []byte{9, 16, 1}, nil), limits: StrictLimits, want: "structural"},
+		{name: "zero length subpacket", p: sigPacket(4, sha256, []byte{0}, nil), limits: StrictLimits, want: "structural"},
+		{name: "huge subpacket", p: sigPacket(4, sha256, []byte{255, 0xff, 0xff, 0xff, 0xff, 2}, nil), limits: StrictLimits, want: "structural"},
+		{name: "subpacket over 2^31", p: sigPacket(4, sha256, []byte{255, 0x80, 0, 0, 0, 2}, nil), limits: StrictLimits, want: "structural"},
+		{name: "not a signature", p: []byte{0xc0 | 11, 1, 0}, limits: StrictLimits, want: "strict"},
+		{name: "too large", p: sigPacket(4, sha256, created, issuer), limits: Limits{MaxSignaturePacketSize: 8}, want: "limit"},
+	} {
+		SetLimits(tt.limits)
+		packets, err := splitPackets(tt.p)
+		var got string
+		switch {
+		case err == nil:
+			if len(packets) != 1 {
+				t.Errorf("%s: splitPackets = %d packets, want 1", tt.name, len(packets))
+			}
+		case errors.As(err, &ErrStrict{}):
+			got = "strict"
+		case errors.As(err, &ErrLimitExceeded{}):
+			got = "limit"
+		default:
+			got = "structural"
+		}
+		if got != tt.want {
+			t.Errorf("%s: splitPackets = %v, want a %q error", tt.name, err, tt.want)
+		}
+	}
+}
+
+func FuzzStrictSplitPackets(f *testing.F) {
+	f.Add(sigPacket(4, 8, []byte{5, 2, 0, 0, 0, 1}, []byte{9, 16, 1, 2, 3, 4, 5, 6, 7, 8}))
+	f.Add(sigPacket(4, 8, []byte{2, 0x80 | 20, 0}, nil))
+	f.Add(sigPacket(4, 8, []byte{255, 0xff, 0xff, 0xff, 0xff, 2}, nil))
+	f.Add(sigPacket(4, 8, []byte{192, 0, 16}, nil))
+
+	defer SetLimits(DefaultLimits)
+	SetLimits(StrictLimits)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) > 4096 {
+			return
+		}
+		splitPackets(data)
+	})
+}
+
+func TestStrictLimits(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+	content := []byte("vmlinuz")
+	for _, f := range []struct {
+		name string
+		hash crypto.Hash
+	}{
+		{name: "sha256", hash: crypto.SHA256},
+		{name: "sha1", hash: crypto.SHA1},
+	} {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := DetachSignFile(keys[:1], path, &packet.Config{DefaultHash: f.hash}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ring := openpgp.EntityList(keys[:1])
+
+	defer SetLimits(DefaultLimits)
+	SetLimits(StrictLimits)
+	sha256, sha1 := filepath.Join(dir, "sha256"), filepath.Join(dir, "sha1")
+	if _, _, err := VerifySignedFile(ring, sha256, sha256+".sig"); err != nil {
+		t.Errorf("VerifySignedFile of a SHA-256 signature = %v, want nil", err)
+	}
+	if _, _, err := VerifySignedFile(ring, sha1, sha1+".sig"); !errors.As(err, &ErrUnsigned{}) || !errors.As(err, &ErrStrict{}) {
+		t.Errorf("VerifySignedFile of a SHA-1 signature = %v, want ErrUnsigned wrapping ErrStrict", err)
+	}
+	if _, err := OpenSignedReader(ring, sha1, sha1+".sig", false); !errors.As(err, &ErrStrict{}) {
+		t.Errorf("OpenSignedReader of a SHA-1 signature = %v, want ErrStrict", err)
+	}
+
+	l := StrictLimits
+	l.MaxFileSize = int64(len(content) - 1)
+	SetLimits(l)
+	var le ErrLimitExceeded
+	if f, _, err := VerifySignedFile(ring, sha256, sha256+".sig"); f != nil || !errors.As(err, &ErrUnsigned{}) || !errors.As(err, &le) || le.Limit != "MaxFileSize" {
+		t.Errorf("VerifySignedFile of a too large file = %v, %v, want no file and ErrUnsigned wrapping MaxFileSize exceeded", f, err)
+	}
+	for _, eager := range []bool{false, true} {
+		r, err := OpenSignedReader(ring, sha256, sha256+".sig", eager)
+		if err == nil {
+			_, err = io.ReadAll(r)
+			r.Close()
+		}
+		if !errors.As(err, &ErrUnsigned{}) || !errors.As(err, &le) || le.Limit != "MaxFileSize" {
+			t.Errorf("OpenSignedReader(eager %t) of a too large file = %v, want ErrUnsigned wrapping MaxFileSize exceeded", eager, err)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// ErrVerityParams is returned for fs-verity or dm-verity parameters the
+// kernel does not support.
+var ErrVerityParams = errors.New("invalid verity parameters")
+
+// merkleTree computes the root hash of a Merkle tree of the blocks of some
+// data, of the kind both fs-verity and dm-verity use: the data blocks are
+// hashed, their hashes are packed into tree blocks that are hashed in turn,
+// until one hash is left.
+type merkleTree struct {
+	h hash.Hash
+	// prefix and suffix are hashed before and after every block.
+	prefix, suffix []byte
+
+	blockSize int
+	// slot is the space of a hash in a tree block, perBlock the number of
+	// hashes in one.
+	slot, perBlock int
+}
+
+func (t *merkleTree) hashBlock(b []byte) []byte {
+	t.h.Reset()
+	t.h.Write(t.prefix)
+	t.h.Write(b)
+	t.h.Write(t.suffix)
+	return t.h.Sum(nil)
+}
+
+// root returns the root hash of the tree of the blocks of r, the last of
+// which is padded with zeros, and the number of bytes read. It returns nil
+// if r is empty.
+func (t *merkleTree) root(r io.Reader, dataBlockSize int) ([]byte, int64, error) {
+	var hashes [][]byte
+	var size int64
+	b := make([]byte, dataBlockSize)
+	for {
+		n, err := io.ReadFull(r, b)
+		if n > 0 {
+			for i := n; i < len(b); i++ {
+				b[i] = 0
+			}
+			hashes = append(hashes, t.hashBlock(b))
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, size, err
+		}
+	}
+	if len(hashes) == 0 {
+		return nil, 0, nil
+	}
+	for len(hashes) > 1 {
+		var next [][]byte
+		for i := 0; i < len(hashes); i += t.perBlock {
+			block := make([]byte, t.blockSize)
+			for j := 0; j < t.perBlock && i+j < len(hashes); j++ {
+				copy(block[j*t.slot:], hashes[i+j])
+			}
+			next = append(next, t.hashBlock(block))
+		}
+		hashes = next
+	}
+	return hashes[0], size, nil
+}
+
+func isPowerOf2(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+func log2(n int) int {
+	var l int
+	for n > 1 {
+		n >>= 1
+		l++
+	}
+	return l
+}
+
+// FSVerity are the parameters of the fs-verity Merkle tree of a file, see
+// https://www.kernel.org/doc/html/latest/filesystems/fsverity.html.
+type FSVerity struct {
+	// Hash is crypto.SHA256, the default, or crypto.SHA512.
+	Hash crypto.Hash
+
+	// BlockSize is the size of the data and tree blocks, a power of 2
+	// from 1024 to 65536. It defaults to 4096.
+	BlockSize int
+
+	// Salt is hashed before every block, at most 32 bytes.
+	Salt []byte
+}
+
+// fsVerityAlgs are the fs-verity numbers of hash functions.
+var fsVerityAlgs = map[crypto.Hash]uint8{
+	crypto.SHA256: 1,
+	crypto.SHA512: 2,
+}
+
+func (v FSVerity) withDefaults() (FSVerity, error) {
+	if v.Hash == 0 {
+		v.Hash = crypto.SHA256
+	}
+	if v.BlockSize == 0 {
+		v.BlockSize = 4096
+	}
+	if _, ok := fsVerityAlgs[v.Hash]; !ok {
+		return v, fmt.Errorf("%w: fs-verity does not support %v", ErrVerityParams, v.Hash)
+	}
+	if !isPowerOf2(v.BlockSize) || v.BlockSize < 1024 || v.BlockSize > 65536 {
+		return v, fmt.Errorf("%w: fs-verity block size %d", ErrVerityParams, v.BlockSize)
+	}
+	if len(v.Salt) > 32 {
+		return v, fmt.Errorf("%w: fs-verity salt of %d bytes, at most 32", ErrVerityParams, len(v.Salt))
+	}
+	return v, nil
+}
+
+// Digest returns the fs-verity file digest of the contents of r: the hash
+// of the fs-verity descriptor, which holds the root hash of the Merkle tree.
+// It is what the kernel measures for a file with fs-verity enabled.
+func (v FSVerity) Digest(r io.Reader) ([]byte, error) {
+	v, err := v.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	h := v.Hash.New()
+	// The salt is padded to the block size of the hash function.
+	prefix := v.Salt
+	if len(prefix) > 0 && len(prefix)%h.BlockSize() != 0 {
+		prefix = append(append([]byte(nil), prefix...), make([]byte, h.BlockSize()-len(prefix)%h.BlockSize())...)
+	}
+	t := &merkleTree{
+		h:         h,
+		prefix:    prefix,
+		blockSize: v.BlockSize,
+		slot:      h.Size(),
+		perBlock:  v.BlockSize / h.Size(),
+	}
+	root, size, err := t.root(r, v.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// struct fsverity_descriptor. The root hash of an empty file is
+	// zero.
+	desc := make([]byte, 256)
+	desc[0] = 1
+	desc[1] = fsVerityAlgs[v.Hash]
+	desc[2] = uint8(log2(v.BlockSize))
+	desc[3] = uint8(len(v.Salt))
+	binary.LittleEndian.PutUint64(desc[8:], uint64(size))
+	copy(desc[16:80], root)
+	copy(desc[80:112], v.Salt)
+	h.Reset()
+	h.Write(desc)
+	return h.Sum(nil), nil
+}
+
+// OpenFSVerityFile opens path and verifies whether its fs-verity digest
+// with the parameters v is want, e.g. before fs-verity is enabled for it
+// with the digest signed.
+//
+// WARNING! Unlike many Go functions, this may return both the file and an
+// error in case the expected digest does not match the contents.
+func OpenFSVerityFile(path string, v FSVerity, want []byte) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{
+		Reader:   bytes.NewReader(content),
+		FileName: path,
+	}
+	if len(want) == 0 {
+		return f, ErrInvalidHash{Path: path, Err: ErrNoExpectedHash}
+	}
+	got, err := v.Digest(bytes.NewReader(content))
+	if err != nil {
+		return f, ErrInvalidHash{Path: path, Err: err}
+	}
+	if !bytes.Equal(got, want) {
+		return f, ErrInvalidHash{Path: path, Err: ErrHashMismatch{Got: got, Want: want}}
+	}
+	if err := measure(path, content); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// DMVerity are the parameters of the dm-verity hash tree of a block device,
+// see https://docs.kernel.org/admin-guide/device-mapper/verity.html.
+type DMVerity struct {
+	// Version is the hash type: 1, the default of veritysetup, hashes the
+	// salt before every block, 0, of Chrome OS, after it.
+	Version int
+
+	// Hash is the hash function, crypto.SHA256 by default.
+	Hash crypto.Hash
+
+	// DataBlockSize and HashBlockSize are the sizes of the blocks of the
+	// data and the hash tree, 4096 by default.
+	DataBlockSize int
+	HashBlockSize int
+
+	// DataBlocks is the number of data blocks covered by the tree. If 0,
+	// all data read is, and its size must be a multiple of DataBlockSize.
+	DataBlocks uint64
+
+	// Salt is hashed with every block.
+	Salt []byte
+}
+
+// dmVerityAlgs are the kernel names of hash functions.
+var dmVerityAlgs = map[crypto.Hash]string{
+	crypto.SHA1:   "sha1",
+	crypto.SHA256: "sha256",
+	crypto.SHA384: "sha384",
+	crypto.SHA512: "sha512",
+}
+
+func (v DMVerity) withDefaults() (DMVerity, error) {
+	if v.Hash == 0 {
+		v.Hash = crypto.SHA256
+	}
+	if v.DataBlockSize == 0 {
+		v.DataBlockSize = 4096
+	}
+	if v.HashBlockSize == 0 {
+		v.HashBlockSize = 4096
+	}
+	if _, ok := dmVerityAlgs[v.Hash]; !ok || !v.Hash.Available() {
+		return v, fmt.Errorf("%w: dm-verity hash %v is not available", ErrVerityParams, v.Hash)
+	}
+	if v.Version != 0 && v.Version != 1 {
+		return v, fmt.Errorf("%w: dm-verity hash type %d", ErrVerityParams, v.Version)
+	}
+	for _, s := range []int{v.DataBlockSize, v.HashBlockSize} {
+		if !isPowerOf2(s) || s < 512 || s > os.Getpagesize() && s > 4096 {
+			return v, fmt.Errorf("%w: dm-verity block size %d", ErrVerityParams, s)
+		}
+	}
+	if v.HashBlockSize < v.Hash.Size() {
+		return v, fmt.Errorf("%w: dm-verity hash block size %d is smaller than a hash", ErrVerityParams, v.HashBlockSize)
+	}
+	if len(v.Salt) > 256 {
+		return v, fmt.Errorf("%w: dm-verity salt of %d bytes, at most 256", ErrVerityParams, len(v.Salt))
+	}
+	return v, nil
+}
+
+// RootHash returns the root hash of the dm-verity hash tree of the image
+// read from r.
+func (v DMVerity) RootHash(r io.Reader) ([]byte, error) {
+	v, err := v.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	h := v.Hash.New()
+	// A tree block holds a power of 2 of hashes. Version 1 spreads them
+	// over the block, version 0 packs them at its start.
+	t := &merkleTree{
+		h:         h,
+		blockSize: v.HashBlockSize,
+		slot:      h.Size(),
+		perBlock:  1 << log2(v.HashBlockSize/h.Size()),
+	}
+	if v.Version == 1 {
+		t.prefix = v.Salt
+		t.slot = v.HashBlockSize / t.perBlock
+	} else {
+		t.suffix = v.Salt
+	}
+	if v.DataBlocks != 0 {
+		r = io.LimitReader(r, int64(v.DataBlocks)*int64(v.DataBlockSize))
+	}
+	root, size, err := t.root(r, v.DataBlockSize)
+	if err != nil {
+		return nil, err
+	}
+	want := int64(v.DataBlocks) * int64(v.DataBlockSize)
+	switch {
+	case v.DataBlocks != 0 && size != want:
+		return nil, fmt.Errorf("dm-verity image of %d bytes, want %d blocks of %d", size, v.DataBlocks, v.DataBlockSize)
+	case size%int64(v.DataBlockSize) != 0:
+		return nil, fmt.Errorf("dm-verity image of %d bytes is not in blocks of %d", size, v.DataBlockSize)
+	case root == nil:
+		return nil, fmt.Errorf("dm-verity image is empty")
+	}
+	return root, nil
+}
+
+// VerifyDMVerity verifies whether the dm-verity root hash of the image read
+// from r is want. A mismatch is an ErrInvalidHash, like those of
+// OpenHashedFile.
+func VerifyDMVerity(r io.Reader, v DMVerity, want []byte) error {
+	if len(want) == 0 {
+		return ErrInvalidHash{Err: ErrNoExpectedHash}
+	}
+	got, err := v.RootHash(r)
+	if err != nil {
+		return ErrInvalidHash{Err: err}
+	}
+	if !bytes.Equal(got, want) {
+		return ErrInvalidHash{Err: ErrHashMismatch{Got: got, Want: want}}
+	}
+	return nil
+}
+
+// Table returns the device-mapper table of a verity target for the data
+// device dataDev, by default of v.DataBlocks, and the tree on hashDev
+// starting at hash block hashStart, e.g. for dmsetup create --table. The
+// tree of a hash device with a superblock starts at block 1.
+func (v DMVerity) Table(dataDev, hashDev string, hashStart uint64, root []byte) (string, error) {
+	v, err := v.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	if v.DataBlocks == 0 {
+		return "", fmt.Errorf("%w: the number of data blocks is not known", ErrVerityParams)
+	}
+	salt := "-"
+	if len(v.Salt) > 0 {
+		salt = hex.EncodeToString(v.Salt)
+	}
+	return fmt.Sprintf("0 %d verity %d %s %s %d %d %d %d %s %x %s",
+		v.DataBlocks*uint64(v.DataBlockSize)/512, v.Version, dataDev, hashDev,
+		v.DataBlockSize, v.HashBlockSize, v.DataBlocks, hashStart, dmVerityAlgs[v.Hash], root, salt), nil
+}
+
+// ErrNoVeritySuperblock is returned by ReadDMVeritySuperblock if there is
+// none.
+var ErrNoVeritySuperblock = errors.New("no dm-verity superblock")
+
+// ReadDMVeritySuperblock reads the parameters of a dm-verity hash device
+// from the superblock veritysetup format writes at its start.
+func ReadDMVeritySuperblock(r io.ReaderAt) (*DMVerity, error) {
+	// struct verity_sb of cryptsetup.
+	sb := make([]byte, 512)
+	if _, err := r.ReadAt(sb, 0); err != nil {
+		return nil, err
+	}
+	if string(sb[:8]) != "verity\x00\x00" {
+		return nil, ErrNoVeritySuperblock
+	}
+	if version := binary.LittleEndian.Uint32(sb[8:]); version != 1 {
+		return nil, fmt.Errorf("dm-verity superblock version %d is not supported", version)
+	}
+	v := &DMVerity{
+		Version:       int(binary.LittleEndian.Uint32(sb[12:])),
+		DataBlockSize: int(binary.LittleEndian.Uint32(sb[64:])),
+		HashBlockSize: int(binary.LittleEndian.Uint32(sb[68:])),
+		DataBlocks:    binary.LittleEndian.Uint64(sb[72:]),
+	}
+	alg := string(bytes.TrimRight(sb[32:64], "\x00"))
+	for h, name := range dmVerityAlgs {
+		if name == alg {
+			v.Hash = h
+		}
+	}
+	if v.Hash == 0 {
+		return nil, fmt.Errorf("%w: dm-verity hash %q", ErrVerityParams, alg)
+	}
+	saltSize := int(binary.LittleEndian.Uint16(sb[80:]))
+	if saltSize > 256 {
+		return nil, fmt.Errorf("%w: dm-verity salt of %d bytes", ErrVerityParams, saltSize)
+	}
+	v.Salt = append([]byte(nil), sb[88:88+saltSize]...)
+	if _, err := v.withDefaults(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,256 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vfile
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func sum256(b ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range b {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// block pads b with zeros to size.
+func block(b []byte, size int) []byte {
+	return append(append([]byte(nil), b...), make([]byte, size-len(b))...)
+}
+
+func TestFSVerityDigest(t *testing.T) {
+	// Three data blocks of 1024 bytes, the last one short: one tree block.
+	data := bytes.Repeat([]byte("a"), 2500)
+	level0 := bytes.Join([][]byte{
+		sum256(data[:1024]),
+		sum256(data[1024:2048]),
+		sum256(block(data[2048:], 1024)),
+	}, nil)
+	desc := make([]byte, 256)
+	desc[0], desc[1], desc[2] = 1, 1, 10
+	binary.LittleEndian.PutUint64(desc[8:], uint64(len(data)))
+	copy(desc[16:], sum256(block(level0, 1024)))
+
+	for _, tt := range []struct {
+		desc    string
+		v       FSVerity
+		data    []byte
+		want    []byte
+		wantErr error
+	}{
+		{
+			// From fsverity-utils.
+			desc: "empty file",
+			want: mustHex(t, "3d248ca542a24fc62d1c43b916eae5016878e2533c88238480b26128a1f1af95"),
+		},
+		{desc: "one tree block", v: FSVerity{BlockSize: 1024}, data: data, want: sum256(desc)},
+		{desc: "SHA-1", v: FSVerity{Hash: crypto.SHA1}, wantErr: ErrVerityParams},
+		{desc: "block size", v: FSVerity{BlockSize: 512}, wantErr: ErrVerityParams},
+		{desc: "salt", v: FSVerity{Salt: make([]byte, 33)}, wantErr: ErrVerityParams},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := tt.v.Digest(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.wantErr) || !bytes.Equal(got, tt.want) {
+				t.Errorf("Digest = %x, %v, want %x, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFSVerityTree(t *testing.T) {
+	// 33 blocks of 1024 bytes take two tree blocks of 32 SHA-256 hashes,
+	// and a root block. The salt is padded to 64 bytes.
+	salt := []byte("salt")
+	prefix := block(salt, 64)
+	data := make([]byte, 33*1024)
+	for i := range data {
+		data[i] = byte(i / 1024)
+	}
+	var level0 [][]byte
+	for i := 0; i < 33; i++ {
+		level0 = append(level0, sum256(prefix, data[i*1024:(i+1)*1024]))
+	}
+	level1 := bytes.Join([][]byte{
+		sum256(prefix, bytes.Join(level0[:32], nil)),
+		sum256(prefix, block(level0[32], 1024)),
+	}, nil)
+	root := sum256(prefix, block(level1, 1024))
+
+	desc := make([]byte, 256)
+	desc[0], desc[1], desc[2], desc[3] = 1, 1, 10, byte(len(salt))
+	binary.LittleEndian.PutUint64(desc[8:], uint64(len(data)))
+	copy(desc[16:], root)
+	copy(desc[80:], salt)
+	want := sum256(desc)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m := &fakeMeasurer{}
+	SetMeasurer(m, 9)
+	defer SetMeasurer(nil, 0)
+
+	v := FSVerity{BlockSize: 1024, Salt: salt}
+	if _, err := OpenFSVerityFile(path, v, want); err != nil {
+		t.Errorf("OpenFSVerityFile = %v, want nil", err)
+	}
+	f, err := OpenFSVerityFile(path, FSVerity{}, want)
+	if f == nil || !errors.As(err, &ErrHashMismatch{}) {
+		t.Errorf("OpenFSVerityFile(other parameters) = %v, want file and ErrHashMismatch", err)
+	}
+	// Only the file that verified is measured, by the digest of its
+	// contents.
+	if digest := sha256.Sum256(data); len(m.digests) != 1 || !bytes.Equal(m.digests[0], digest[:]) {
+		t.Errorf("OpenFSVerityFile measured %x, want %x", m.digests, digest)
+	}
+
+	m.err = errors.New("no TPM")
+	if f, err := OpenFSVerityFile(path, v, want); f == nil || !errors.As(err, &ErrNotMeasured{}) {
+		t.Errorf("OpenFSVerityFile = %v, want a file and ErrNotMeasured", err)
+	}
+}
+
+func TestDMVerityRootHash(t *testing.T) {
+	salt := []byte("salt")
+	data := append(bytes.Repeat([]byte{1}, 4096), bytes.Repeat([]byte{2}, 4096)...)
+
+	for _, tt := range []struct {
+		desc string
+		v    DMVerity
+		data []byte
+		want []byte
+	}{
+		{
+			desc: "one block",
+			v:    DMVerity{Version: 1, Salt: salt},
+			data: data[:4096],
+			want: sum256(salt, data[:4096]),
+		},
+		{
+			desc: "version 1",
+			v:    DMVerity{Version: 1, Salt: salt},
+			data: data,
+			want: sum256(salt, block(append(sum256(salt, data[:4096]), sum256(salt, data[4096:])...), 4096)),
+		},
+		{
+			desc: "data blocks",
+			v:    DMVerity{Version: 1, Salt: salt, DataBlocks: 1},
+			data: data,
+			want: sum256(salt, data[:4096]),
+		},
+		{
+			desc: "version 0 SHA-1",
+			v:    DMVerity{Hash: crypto.SHA1, Salt: salt, DataBlockSize: 512, HashBlockSize: 512},
+			data: data[:1024],
+			want: func() []byte {
+				h0 := sha1.Sum(append(append([]byte(nil), data[:512]...), salt...))
+				h1 := sha1.Sum(append(append([]byte(nil), data[512:1024]...), salt...))
+				root := sha1.Sum(append(block(append(h0[:], h1[:]...), 512), salt...))
+				return root[:]
+			}(),
+		},
+		{
+			desc: "version 1 SHA-1",
+			v:    DMVerity{Version: 1, Hash: crypto.SHA1, DataBlockSize: 512, HashBlockSize: 512},
+			data: data[:1024],
+			want: func() []byte {
+				// SHA-1 hashes take 32 bytes.
+				h0 := sha1.Sum(data[:512])
+				h1 := sha1.Sum(data[512:1024])
+				root := sha1.Sum(block(append(block(h0[:], 32), h1[:]...), 512))
+				return root[:]
+			}(),
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := tt.v.RootHash(bytes.NewReader(tt.data))
+			if err != nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("RootHash = %x, %v, want %x", got, err, tt.want)
+			}
+			if err := VerifyDMVerity(bytes.NewReader(tt.data), tt.v, tt.want); err != nil {
+				t.Errorf("VerifyDMVerity = %v, want nil", err)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		desc string
+		v    DMVerity
+		data []byte
+	}{
+		{desc: "partial block", data: data[:4000]},
+		{desc: "short image", v: DMVerity{DataBlocks: 3}, data: data},
+		{desc: "empty", data: nil},
+		{desc: "hash type", v: DMVerity{Version: 2}, data: data},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got, err := tt.v.RootHash(bytes.NewReader(tt.data)); err == nil {
+				t.Errorf("RootHash = %x, want an error", got)
+			}
+		})
+	}
+
+	err := VerifyDMVerity(bytes.NewReader(data), DMVerity{}, make([]byte, 32))
+	if !errors.As(err, &ErrInvalidHash{}) || !errors.As(err, &ErrHashMismatch{}) {
+		t.Errorf("VerifyDMVerity(wrong hash) = %v, want ErrInvalidHash wrapping ErrHashMismatch", err)
+	}
+}
+
+func TestDMVeritySuperblock(t *testing.T) {
+	sb := make([]byte, 4096)
+	copy(sb, "verity\x00\x00")
+	binary.LittleEndian.PutUint32(sb[8:], 1)
+	binary.LittleEndian.PutUint32(sb[12:], 1)
+	copy(sb[32:], "sha256")
+	binary.LittleEndian.PutUint32(sb[64:], 4096)
+	binary.LittleEndian.PutUint32(sb[68:], 4096)
+	binary.LittleEndian.PutUint64(sb[72:], 256)
+	binary.LittleEndian.PutUint16(sb[80:], 4)
+	copy(sb[88:], "salt")
+
+	v, err := ReadDMVeritySuperblock(bytes.NewReader(sb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DMVerity{Version: 1, Hash: crypto.SHA256, DataBlockSize: 4096, HashBlockSize: 4096, DataBlocks: 256, Salt: []byte("salt")}
+	if v.Version != want.Version || v.Hash != want.Hash || v.DataBlockSize != want.DataBlockSize ||
+		v.HashBlockSize != want.HashBlockSize || v.DataBlocks != want.DataBlocks || !bytes.Equal(v.Salt, want.Salt) {
+		t.Errorf("ReadDMVeritySuperblock = %+v, want %+v", v, want)
+	}
+
+	table, err := v.Table("/dev/sda1", "/dev/sda2", 1, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTable := "0 2048 verity 1 /dev/sda1 /dev/sda2 4096 4096 256 1 sha256 " + strings.Repeat("00", 32) + " 73616c74"
+	if table != wantTable {
+		t.Errorf("Table = %q, want %q", table, wantTable)
+	}
+
+	if _, err := ReadDMVeritySuperblock(bytes.NewReader(make([]byte, 512))); !errors.Is(err, ErrNoVeritySuperblock) {
+		t.Errorf("ReadDMVeritySuperblock(zeros) = %v, want %v", err, ErrNoVeritySuperblock)
+	}
+}
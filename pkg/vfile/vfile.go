@@ -9,17 +9,20 @@ package vfile
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/sha512"
+	_ "crypto/sha256" // for crypto.SHA256
+	_ "crypto/sha512" // for crypto.SHA384 and crypto.SHA512
 	"crypto/subtle"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
 	"os"
+	"time"
 
 	"golang.org/x/crypto/openpgp"
+	gpgerror "golang.org/x/crypto/openpgp/errors"
 	"golang.org/x/crypto/openpgp/packet"
 )
 
@@ -56,7 +59,8 @@ func (e ErrWrongSigner) Error() string {
 	return fmt.Sprintf("signed by a key not present in keyring %s", e.KeyRing)
 }
 
-// GetKeyRing returns an OpenPGP KeyRing loaded from the specified path.
+// GetKeyRing returns an OpenPGP KeyRing loaded from the specified path, which
+// may be binary or ASCII armored.
 //
 // keyPath must be an already trusted path, e.g. keys are included in the initramfs.
 func GetKeyRing(keyPath string) (openpgp.KeyRing, error) {
@@ -66,7 +70,7 @@ func GetKeyRing(keyPath string) (openpgp.KeyRing, error) {
 	}
 	defer key.Close()
 
-	ring, err := openpgp.ReadKeyRing(key)
+	ring, err := ReadKeyRing(key)
 	if err != nil {
 		return nil, fmt.Errorf("could not read pub key: %v", err)
 	}
@@ -109,8 +113,27 @@ func GetRSAKeysFromRing(ring openpgp.KeyRing) ([]*rsa.PublicKey, error) {
 // OpenSignedSigFile calls OpenSignedFile expecting the signature to be in path.sig.
 //
 // E.g. if path is /foo/bar, the signature is expected to be in /foo/bar.sig.
+// If there is none, the ASCII armored signature /foo/bar.asc is used.
 func OpenSignedSigFile(keyring openpgp.KeyRing, path string) (*File, error) {
-	return OpenSignedFile(keyring, path, fmt.Sprintf("%s.sig", path))
+	return OpenSignedFile(keyring, path, sigPath(path))
+}
+
+// VerifySignedSigFile calls VerifySignedFile expecting the signature to be
+// in path.sig or path.asc, as OpenSignedSigFile does.
+func VerifySignedSigFile(keyring openpgp.KeyRing, path string) (*File, *VerificationResult, error) {
+	return VerifySignedFile(keyring, path, sigPath(path))
+}
+
+// sigPath returns path.sig, or path.asc if only that exists.
+func sigPath(path string) string {
+	sig := fmt.Sprintf("%s.sig", path)
+	if _, err := os.Stat(sig); os.IsNotExist(err) {
+		asc := fmt.Sprintf("%s.asc", path)
+		if _, err := os.Stat(asc); err == nil {
+			return asc
+		}
+	}
+	return sig
 }
 
 // File encapsulates a bytes.Reader with the file contents and its name.
@@ -125,6 +148,30 @@ func (f *File) Name() string {
 	return f.FileName
 }
 
+// VerificationResult describes the signature that verified a file, e.g. for
+// audit logs or to retire a key once nothing is signed by it anymore.
+type VerificationResult struct {
+	// Signature is the signature that verified the file. Its Signer is
+	// the entity of the key ring.
+	Signature
+
+	// Fingerprint is the fingerprint of the key that made the signature,
+	// which is a subkey of Signer if it was made by one.
+	Fingerprint [20]byte
+
+	// Index is which of the signatures in the signature file verified the
+	// file, counting from 0.
+	Index int
+
+	// Signatures is the number of signatures in the signature file.
+	Signatures int
+}
+
+func (r *VerificationResult) String() string {
+	return fmt.Sprintf("signature %d of %d by key %X (fingerprint %X), made %v",
+		r.Index+1, r.Signatures, r.KeyID, r.Fingerprint, r.CreationTime.UTC().Format(time.RFC3339))
+}
+
 // OpenSignedFile opens a file that is expected to be signed.
 //
 // WARNING! Unlike many Go functions, this may return both the file and an
@@ -135,29 +182,157 @@ func (f *File) Name() string {
 // If the signature does not exist or does not match the keyring, both the file
 // and a signature error will be returned.
 func OpenSignedFile(keyring openpgp.KeyRing, path, pathSig string) (*File, error) {
-	content, err := os.ReadFile(path)
+	f, _, err := VerifySignedFile(keyring, path, pathSig)
+	return f, err
+}
+
+// VerifySignedFile is OpenSignedFile, and also returns which signature
+// verified the file if it is signed.
+//
+// pathSig may hold several detached signatures, e.g. by an old and a new
+// key while keys are rolled over. The file is signed if any of them made by
+// a key in keyring matches.
+func VerifySignedFile(keyring openpgp.KeyRing, path, pathSig string) (*File, *VerificationResult, error) {
+	content, err := readSignedFile(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	f := &File{
 		Reader:   bytes.NewReader(content),
 		FileName: path,
 	}
 
-	signaturef, err := os.Open(pathSig)
+	sig, err := readSignatureFile(pathSig)
 	if err != nil {
-		return f, ErrUnsigned{Path: path, Err: err}
+		return f, nil, ErrUnsigned{Path: path, Err: err}
+	}
+	if keyring == nil {
+		return f, nil, ErrUnsigned{Path: path, Err: ErrNoKeyRing}
+	}
+	r, err := checkSignatures(keyring, content, sig)
+	if err != nil {
+		return f, nil, ErrUnsigned{Path: path, Err: err}
+	}
+	if err := measure(path, content); err != nil {
+		return f, r, err
 	}
-	defer signaturef.Close()
+	return f, r, nil
+}
+
+// OpenSignedInlineFile opens a clear-signed file, or a signed message as
+// gpg --sign makes, and returns just the signed contents.
+//
+// WARNING! Unlike many Go functions, this may return both the file and an
+// error.
+//
+// If the signature does not match the keyring, both the contents and a
+// signature error are returned. If the contents cannot be found, only the
+// error is returned.
+func OpenSignedInlineFile(keyring openpgp.KeyRing, path string) (*File, error) {
+	f, _, err := VerifySignedInlineFile(keyring, path)
+	return f, err
+}
 
+// VerifySignedInlineFile is OpenSignedInlineFile, and also returns the
+// signature that verified the file if it is signed.
+func VerifySignedInlineFile(keyring openpgp.KeyRing, path string) (*File, *VerificationResult, error) {
+	msg, err := readSignedFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
 	if keyring == nil {
-		return f, ErrUnsigned{Path: path, Err: ErrNoKeyRing}
-	} else if signer, err := openpgp.CheckDetachedSignature(keyring, bytes.NewReader(content), signaturef); err != nil {
-		return f, ErrUnsigned{Path: path, Err: err}
-	} else if signer == nil {
-		return f, ErrUnsigned{Path: path, Err: ErrWrongSigner{keyring}}
+		return nil, nil, ErrUnsigned{Path: path, Err: ErrNoKeyRing}
 	}
-	return f, nil
+
+	var (
+		content []byte
+		s       *Signature
+	)
+	if IsClearSigned(msg) {
+		content, s, err = VerifyClearSigned(keyring, msg)
+	} else {
+		content, s, err = VerifySignedMessage(keyring, bytes.NewReader(msg))
+	}
+	if content == nil {
+		return nil, nil, ErrUnsigned{Path: path, Err: err}
+	}
+	f := &File{
+		Reader:   bytes.NewReader(content),
+		FileName: path,
+	}
+	if err != nil {
+		return f, nil, ErrUnsigned{Path: path, Err: err}
+	}
+	r := &VerificationResult{Signature: *s, Signatures: 1}
+	for _, k := range keyring.KeysById(s.KeyID) {
+		if k.Entity == s.Signer {
+			r.Fingerprint = k.PublicKey.Fingerprint
+			break
+		}
+	}
+	if err := measure(path, content); err != nil {
+		return f, r, err
+	}
+	return f, r, nil
+}
+
+// checkSignatures checks the detached signatures sig of content against
+// keyring, and returns the first that matches. If none does, the error is
+// that of the first signature made by a key in keyring, or
+// errors.ErrUnknownIssuer if there is none.
+func checkSignatures(keyring openpgp.KeyRing, content, sig []byte) (*VerificationResult, error) {
+	sig, err := dearmor(sig)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := splitPackets(sig)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for i, p := range packets {
+		if _, err := signatureInfo(p); err != nil {
+			return nil, err
+		}
+		r, err := checkSignature(keyring, content, p)
+		if err != nil {
+			if firstErr == nil && !errors.Is(err, gpgerror.ErrUnknownIssuer) {
+				firstErr = err
+			}
+			continue
+		}
+		r.Index, r.Signatures = i, len(packets)
+		return r, nil
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, gpgerror.ErrUnknownIssuer
+}
+
+// checkSignature checks the single signature packet p of content against
+// keyring.
+func checkSignature(keyring openpgp.KeyRing, content, p []byte) (*VerificationResult, error) {
+	s, err := signatureInfo(p)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := openpgp.CheckDetachedSignature(keyring, bytes.NewReader(content), bytes.NewReader(p))
+	if err == nil && signer == nil {
+		err = ErrWrongSigner{keyring}
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &VerificationResult{Signature: *s, Signatures: 1}
+	r.Signer = signer
+	for _, k := range keyring.KeysById(s.KeyID) {
+		if k.Entity == signer {
+			r.Fingerprint = k.PublicKey.Fingerprint
+			break
+		}
+	}
+	return r, nil
 }
 
 // ErrInvalidHash is returned when hash verification failed.
@@ -198,7 +373,18 @@ var ErrNoExpectedHash = errors.New("OpenHashedFile: no expected hash given")
 //
 // If the contents match, the contents are returned with no error.
 func OpenHashedFile256(path string, wantSHA256Hash []byte) (*File, error) {
-	return openHashedFile(path, wantSHA256Hash, sha256.New())
+	return OpenHashedFile(path, crypto.SHA256, wantSHA256Hash)
+}
+
+// OpenHashedFile384 opens path and verifies whether its contents match the
+// given sha384 hash.
+//
+// WARNING! Unlike many Go functions, this may return both the file and an
+// error in case the expected hash does not match the contents.
+//
+// If the contents match, the contents are returned with no error.
+func OpenHashedFile384(path string, wantSHA384Hash []byte) (*File, error) {
+	return OpenHashedFile(path, crypto.SHA384, wantSHA384Hash)
 }
 
 // OpenHashedFile512 opens path and verifies whether its contents match the
@@ -209,10 +395,22 @@ func OpenHashedFile256(path string, wantSHA256Hash []byte) (*File, error) {
 //
 // If the contents match, the contents are returned with no error.
 func OpenHashedFile512(path string, wantSHA512Hash []byte) (*File, error) {
-	return openHashedFile(path, wantSHA512Hash, sha512.New())
+	return OpenHashedFile(path, crypto.SHA512, wantSHA512Hash)
 }
 
-func openHashedFile(path string, wantHash []byte, h hash.Hash) (*File, error) {
+// ErrHashUnavailable is given when the hash function is not linked into the
+// binary.
+var ErrHashUnavailable = errors.New("OpenHashedFile: hash function unavailable")
+
+// OpenHashedFile opens path and verifies whether its contents match the
+// given hash computed with h. SHA-256, SHA-384 and SHA-512 are always
+// available; other functions must be linked into the binary.
+//
+// WARNING! Unlike many Go functions, this may return both the file and an
+// error in case the expected hash does not match the contents.
+//
+// If the contents match, the contents are returned with no error.
+func OpenHashedFile(path string, h crypto.Hash, wantHash []byte) (*File, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -222,24 +420,43 @@ func openHashedFile(path string, wantHash []byte, h hash.Hash) (*File, error) {
 		FileName: path,
 	}
 
+	if err := checkHash(path, h, wantHash, content); err != nil {
+		return f, err
+	}
+	if err := measure(path, content); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// checkHash checks that content of the file at path hashes to wantHash with
+// h.
+func checkHash(path string, h crypto.Hash, wantHash, content []byte) error {
 	if len(wantHash) == 0 {
-		return f, ErrInvalidHash{
+		return ErrInvalidHash{
 			Path: path,
 			Err:  ErrNoExpectedHash,
 		}
 	}
+	if !h.Available() {
+		return ErrInvalidHash{
+			Path: path,
+			Err:  ErrHashUnavailable,
+		}
+	}
 
 	// Hash the file.
-	if _, err := io.Copy(h, bytes.NewReader(content)); err != nil {
-		return f, ErrInvalidHash{
+	hh := h.New()
+	if _, err := io.Copy(hh, bytes.NewReader(content)); err != nil {
+		return ErrInvalidHash{
 			Path: path,
 			Err:  err,
 		}
 	}
 
-	got := h.Sum(nil)
+	got := hh.Sum(nil)
 	if !bytes.Equal(wantHash, got) {
-		return f, ErrInvalidHash{
+		return ErrInvalidHash{
 			Path: path,
 			Err: ErrHashMismatch{
 				Got:  got,
@@ -247,7 +464,7 @@ func openHashedFile(path string, wantHash []byte, h hash.Hash) (*File, error) {
 			},
 		}
 	}
-	return f, nil
+	return nil
 }
 
 // CheckHashedContent verifies a calculated hash against an expected hash array.
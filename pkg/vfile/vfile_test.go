@@ -6,7 +6,11 @@ package vfile
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -19,6 +23,7 @@ import (
 	"time"
 
 	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
 	"golang.org/x/crypto/openpgp/errors"
 	"golang.org/x/crypto/openpgp/packet"
 )
@@ -424,3 +429,301 @@ func TestOpenHashedFile(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenHashedFileAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hashed")
+	if err := os.WriteFile(path, []byte("foo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sum384 := sha512.Sum384([]byte("foo"))
+	sum512 := sha512.Sum512([]byte("foo"))
+
+	for _, tt := range []struct {
+		desc string
+		open func() (*File, error)
+		want error
+	}{
+		{
+			desc: "sha384",
+			open: func() (*File, error) { return OpenHashedFile384(path, sum384[:]) },
+		},
+		{
+			desc: "sha512",
+			open: func() (*File, error) { return OpenHashedFile512(path, sum512[:]) },
+		},
+		{
+			desc: "sha512 via crypto.Hash",
+			open: func() (*File, error) { return OpenHashedFile(path, crypto.SHA512, sum512[:]) },
+		},
+		{
+			desc: "wrong algorithm",
+			open: func() (*File, error) { return OpenHashedFile(path, crypto.SHA384, sum512[:]) },
+			want: ErrInvalidHash{
+				Path: path,
+				Err: ErrHashMismatch{
+					Got:  sum384[:],
+					Want: sum512[:],
+				},
+			},
+		},
+		{
+			desc: "unavailable",
+			open: func() (*File, error) { return OpenHashedFile(path, crypto.MD4, []byte{1}) },
+			want: ErrInvalidHash{
+				Path: path,
+				Err:  ErrHashUnavailable,
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			f, err := tt.open()
+			if !reflect.DeepEqual(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+			content, err := io.ReadAll(f)
+			if err != nil || string(content) != "foo" {
+				t.Errorf("ReadAll = %q, %v, want foo", content, err)
+			}
+		})
+	}
+}
+
+func TestVerifySignedFile(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+
+	both := filepath.Join(dir, "signed_by_both")
+	if err := (signedFile{signers: keys, content: "foo"}).write(both); err != nil {
+		t.Fatal(err)
+	}
+	// A bad signature by key0 does not spoil the good one by key1.
+	badThenGood := filepath.Join(dir, "bad_then_good")
+	if err := os.WriteFile(badThenGood, []byte("foo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var sig bytes.Buffer
+	if err := openpgp.DetachSign(&sig, keys[0], strings.NewReader("bar"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := openpgp.DetachSign(&sig, keys[1], strings.NewReader("foo"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(badThenGood+".sig", sig.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc      string
+		path      string
+		keyring   openpgp.EntityList
+		wantIndex int
+		wantBad   bool
+	}{
+		{desc: "first signature", path: both, keyring: openpgp.EntityList{keys[0]}, wantIndex: 0},
+		{desc: "second signature", path: both, keyring: openpgp.EntityList{keys[1]}, wantIndex: 1},
+		{desc: "both keys", path: both, keyring: keys, wantIndex: 0},
+		{desc: "bad then good", path: badThenGood, keyring: keys, wantIndex: 1},
+		{desc: "only bad", path: badThenGood, keyring: openpgp.EntityList{keys[0]}, wantBad: true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			f, r, err := VerifySignedSigFile(tt.keyring, tt.path)
+			if f == nil {
+				t.Fatalf("VerifySignedSigFile(%q) returned no file", tt.path)
+			}
+			if tt.wantBad {
+				var sigErr errors.SignatureError
+				if r != nil || !stderrors.As(err, &sigErr) {
+					t.Errorf("VerifySignedSigFile(%q) = %v, %v, want a signature error", tt.path, r, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifySignedSigFile(%q) = %v", tt.path, err)
+			}
+			if r.Index != tt.wantIndex || r.Signatures != 2 {
+				t.Errorf("signature %d of %d, want %d of 2", r.Index, r.Signatures, tt.wantIndex)
+			}
+			signer := keys[0]
+			if tt.wantIndex == 1 {
+				signer = keys[1]
+			}
+			if r.Signer != signer {
+				t.Errorf("Signer = %v, want key%d", r.Signer, tt.wantIndex)
+			}
+			// The key ID is the end of the fingerprint.
+			if binary.BigEndian.Uint64(r.Fingerprint[12:]) != r.KeyID || r.CreationTime.IsZero() {
+				t.Errorf("result %v has the wrong fingerprint or no time", r)
+			}
+		})
+	}
+}
+
+func TestSplitPackets(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		in   []byte
+		want []int
+	}{
+		{desc: "new format, one byte length", in: append([]byte{0xc2, 3}, 1, 2, 3), want: []int{5}},
+		{desc: "new format, two byte length", in: append([]byte{0xc2, 192, 8}, make([]byte, 200)...), want: []int{203}},
+		{desc: "new format, five byte length", in: append([]byte{0xc2, 255, 0, 0, 0, 2}, 1, 2), want: []int{8}},
+		{desc: "old format", in: []byte{0x88, 1, 0, 0x89, 0, 2, 1, 2}, want: []int{3, 5}},
+		{desc: "short", in: []byte{0xc2, 3, 1}},
+		{desc: "short header", in: []byte{0x89, 0}},
+		{desc: "partial", in: []byte{0xc2, 230, 0}},
+		{desc: "not a packet", in: []byte{0x02, 0}},
+		{desc: "old format, huge length", in: []byte{0x8a, 0xff, 0xff, 0xff, 0xff, 0}},
+		{desc: "new format, huge length", in: []byte{0xc2, 255, 0xff, 0xff, 0xff, 0xff, 0}},
+		{desc: "old format, length over 2^31", in: []byte{0x8a, 0x80, 0, 0, 0, 0}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			packets, err := splitPackets(tt.in)
+			var got []int
+			for _, p := range packets {
+				got = append(got, len(p))
+			}
+			if !reflect.DeepEqual(got, tt.want) || (err == nil) != (tt.want != nil) {
+				t.Errorf("splitPackets = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzSplitPackets(f *testing.F) {
+	f.Add([]byte{0xc2, 3, 1, 2, 3})
+	f.Add([]byte{0x88, 1, 0, 0x89, 0, 2, 1, 2})
+	f.Add([]byte{0xc2, 255, 0, 0, 0, 2, 1, 2})
+	f.Add([]byte{0x8a, 0xff, 0xff, 0xff, 0xff, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) > 4096 {
+			return
+		}
+		packets, err := splitPackets(data)
+		if err != nil {
+			return
+		}
+		var n int
+		for _, p := range packets {
+			n += len(p)
+		}
+		if n != len(data) {
+			t.Errorf("splitPackets(%x) split %d bytes into packets, want %d", data, n, len(data))
+		}
+	})
+}
+
+func TestOpenSignedInlineFile(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+	content := "console=ttyS0\n"
+
+	var cs bytes.Buffer
+	w, err := clearsign.Encode(&cs, keys[0].PrivateKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	w.Close()
+	clear := filepath.Join(dir, "clearsigned")
+	if err := os.WriteFile(clear, cs.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tampered := filepath.Join(dir, "tampered")
+	if err := os.WriteFile(tampered, bytes.Replace(cs.Bytes(), []byte("ttyS0"), []byte("ttyS1"), 1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The test keys prefer RIPEMD160, which is not compiled in.
+	for _, id := range keys[1].Identities {
+		id.SelfSignature.PreferredHash = []uint8{8} // SHA256
+	}
+	var sm bytes.Buffer
+	w, err = openpgp.Sign(&sm, keys[1], nil, &packet.Config{DefaultHash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	w.Close()
+	inline := filepath.Join(dir, "inline")
+	if err := os.WriteFile(inline, sm.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		path    string
+		keyring openpgp.EntityList
+		signer  *openpgp.Entity
+	}{
+		{path: clear, keyring: keys, signer: keys[0]},
+		{path: inline, keyring: keys, signer: keys[1]},
+		{path: clear, keyring: openpgp.EntityList{keys[1]}},
+		{path: tampered, keyring: keys},
+	} {
+		f, r, err := VerifySignedInlineFile(tt.keyring, tt.path)
+		if f == nil {
+			t.Fatalf("VerifySignedInlineFile(%s) returned no file: %v", tt.path, err)
+		}
+		if got, _ := io.ReadAll(f); !strings.HasPrefix(string(got), "console=ttyS") {
+			t.Errorf("VerifySignedInlineFile(%s) contents = %q", tt.path, got)
+		}
+		var unsigned ErrUnsigned
+		switch {
+		case tt.signer == nil && !stderrors.As(err, &unsigned):
+			t.Errorf("VerifySignedInlineFile(%s) = %v, want ErrUnsigned", tt.path, err)
+		case tt.signer != nil && (err != nil || r.Signer != tt.signer):
+			t.Errorf("VerifySignedInlineFile(%s) = %v, %v, want signed", tt.path, r, err)
+		}
+	}
+}
+
+type fakeMeasurer struct {
+	pcr     int
+	digests [][]byte
+	err     error
+}
+
+func (m *fakeMeasurer) Extend(pcr int, digest []byte) error {
+	m.pcr = pcr
+	m.digests = append(m.digests, digest)
+	return m.err
+}
+
+func TestMeasure(t *testing.T) {
+	keys := readKeys(t)
+	dir := t.TempDir()
+	signed := filepath.Join(dir, "signed")
+	if err := (signedFile{signers: keys[:1], content: "foo"}).write(signed); err != nil {
+		t.Fatal(err)
+	}
+	hashed := filepath.Join(dir, "hashed")
+	hash, err := writeHashedFile(hashed, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256([]byte("foo"))
+
+	m := &fakeMeasurer{}
+	SetMeasurer(m, 9)
+	defer SetMeasurer(nil, 0)
+
+	if _, err := OpenSignedSigFile(openpgp.EntityList(keys), signed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenHashedFile256(hashed, hash); err != nil {
+		t.Fatal(err)
+	}
+	// Files that do not verify are not measured.
+	if _, err := OpenSignedSigFile(openpgp.EntityList{keys[1]}, signed); err == nil {
+		t.Fatal("OpenSignedSigFile with the wrong key succeeded")
+	}
+	if m.pcr != 9 || len(m.digests) != 2 || !bytes.Equal(m.digests[0], want[:]) || !bytes.Equal(m.digests[1], want[:]) {
+		t.Errorf("measured %x into PCR %d, want 2 times %x into PCR 9", m.digests, m.pcr, want)
+	}
+
+	m.err = stderrors.New("no TPM")
+	if f, err := OpenHashedFile256(hashed, hash); f == nil || !stderrors.As(err, &ErrNotMeasured{}) {
+		t.Errorf("OpenHashedFile256 = %v, want a file and ErrNotMeasured", err)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// The FMAP of a flash image names its areas: a header, with the signature, the
+// version, the base address, the size and the name of the flash, and the
+// number of areas, followed by the areas, with their offset, size, name and
+// flags.
+const (
+	fmapSignature = "__FMAP__"
+	fmapHeaderLen = 56
+	fmapAreaLen   = 42
+	fmapNameLen   = 32
+)
+
+// findArea returns the offset and the size of the area named name of the
+// flash image, as its FMAP has them.
+func findArea(image []byte, name string) (int, int, error) {
+	for off := 0; ; off++ {
+		i := bytes.Index(image[off:], []byte(fmapSignature))
+		if i < 0 {
+			return 0, 0, fmt.Errorf("no FMAP in the flash image")
+		}
+		off += i
+		h := image[off:]
+		// The signature may be in code or data too.
+		if len(h) < fmapHeaderLen || h[8] != 1 {
+			continue
+		}
+		n := int(binary.LittleEndian.Uint16(h[54:]))
+		areas := h[fmapHeaderLen:]
+		if len(areas) < n*fmapAreaLen {
+			continue
+		}
+		for j := 0; j < n; j++ {
+			a := areas[j*fmapAreaLen:]
+			if string(bytes.TrimRight(a[8:8+fmapNameLen], "\x00")) != name {
+				continue
+			}
+			aoff := int(binary.LittleEndian.Uint32(a))
+			asize := int(binary.LittleEndian.Uint32(a[4:]))
+			if aoff > len(image) || asize > len(image)-aoff {
+				return 0, 0, fmt.Errorf("area %s at %#x of %#x bytes is outside of the flash image of %#x", name, aoff, asize, len(image))
+			}
+			return aoff, asize, nil
+		}
+		return 0, 0, fmt.Errorf("no area %s in the FMAP", name)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Types of VPD 2.0 entries.
+const (
+	typeTerminator         = 0x00
+	typeString             = 0x01
+	typeInfo               = 0xfe
+	typeImplicitTerminator = 0xff
+)
+
+// infoMagic starts VPD 2.0 regions: the header of the info entry of key
+// "\x01gVpdInfo", whose 4 byte value is the size of the entries that follow
+// it.
+var infoMagic = []byte("\xfe\x09\x01gVpdInfo\x04")
+
+// infoLen is the length of the info entry.
+const infoLen = 16
+
+var errTruncated = errors.New("VPD entry is truncated")
+
+// Entry is a key-value pair of VPD.
+type Entry struct {
+	Key   string
+	Value []byte
+}
+
+// Region is the VPD of a flash region, e.g. RO_VPD or RW_VPD: its entries, in
+// the order they are stored.
+type Region struct {
+	Entries []Entry
+}
+
+// decodeLen decodes a length of VPD, stored 7 bits a byte, most significant
+// first, with the top bit set in all bytes but the last, and returns it and
+// how many bytes it took.
+func decodeLen(b []byte) (int, int, error) {
+	var n int
+	for i, c := range b {
+		if i == 4 {
+			break
+		}
+		n = n<<7 | int(c&0x7f)
+		if c&0x80 == 0 {
+			return n, i + 1, nil
+		}
+	}
+	return 0, 0, errTruncated
+}
+
+// appendLen appends the encoding of the length n to b.
+func appendLen(b []byte, n int) []byte {
+	var l [5]byte
+	i := len(l) - 1
+	l[i] = byte(n & 0x7f)
+	for n >>= 7; n > 0; n >>= 7 {
+		i--
+		l[i] = byte(n&0x7f) | 0x80
+	}
+	return append(b, l[i:]...)
+}
+
+// DecodeRegion decodes the VPD of the flash region b. Regions without the
+// info entry of VPD 2.0 are decoded whole, and erased regions are empty.
+func DecodeRegion(b []byte) (*Region, error) {
+	r := &Region{}
+	switch {
+	case len(b) >= infoLen && bytes.Equal(b[:len(infoMagic)], infoMagic):
+		size := int(binary.LittleEndian.Uint32(b[len(infoMagic):]))
+		if size > len(b)-infoLen {
+			return nil, fmt.Errorf("VPD of %d bytes in a region of %d", size, len(b))
+		}
+		b = b[infoLen : infoLen+size]
+	case len(b) == 0 || b[0] == typeTerminator || b[0] == typeImplicitTerminator:
+		return r, nil
+	}
+
+	for len(b) > 0 && b[0] != typeTerminator && b[0] != typeImplicitTerminator {
+		t := b[0]
+		b = b[1:]
+		var kv [2][]byte
+		for i := range kv {
+			n, l, err := decodeLen(b)
+			if err != nil {
+				return nil, err
+			}
+			if n > len(b)-l {
+				return nil, errTruncated
+			}
+			kv[i], b = b[l:l+n], b[l+n:]
+		}
+		if t == typeString {
+			r.Entries = append(r.Entries, Entry{Key: string(kv[0]), Value: append([]byte(nil), kv[1]...)})
+		}
+	}
+	return r, nil
+}
+
+// Encode returns the region of size bytes that stores the VPD, with the info
+// entry of VPD 2.0, and the rest erased.
+func (r *Region) Encode(size int) ([]byte, error) {
+	var data []byte
+	for _, e := range r.Entries {
+		data = append(data, typeString)
+		data = appendLen(data, len(e.Key))
+		data = append(data, e.Key...)
+		data = appendLen(data, len(e.Value))
+		data = append(data, e.Value...)
+	}
+	data = append(data, typeTerminator)
+	if infoLen+len(data) > size {
+		return nil, fmt.Errorf("VPD of %d bytes does not fit in a region of %d", infoLen+len(data), size)
+	}
+
+	b := bytes.Repeat([]byte{0xff}, size)
+	copy(b, infoMagic)
+	binary.LittleEndian.PutUint32(b[len(infoMagic):], uint32(len(data)))
+	copy(b[infoLen:], data)
+	return b, nil
+}
+
+// Get returns the value of key, and whether it is set.
+func (r *Region) Get(key string) ([]byte, bool) {
+	for _, e := range r.Entries {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set sets key to value, in place if it is set, and last otherwise.
+func (r *Region) Set(key string, value []byte) {
+	for i, e := range r.Entries {
+		if e.Key == key {
+			r.Entries[i].Value = value
+			return
+		}
+	}
+	r.Entries = append(r.Entries, Entry{Key: key, Value: value})
+}
+
+// Delete deletes key, and returns whether it was set.
+func (r *Region) Delete(key string) bool {
+	var deleted bool
+	entries := r.Entries[:0]
+	for _, e := range r.Entries {
+		if e.Key == key {
+			deleted = true
+			continue
+		}
+		entries = append(entries, e)
+	}
+	r.Entries = entries
+	return deleted
+}
+
+// ReadImage decodes the VPD of the region named region, e.g. RO_VPD, of the
+// flash image, which the FMAP of the image locates.
+func ReadImage(image []byte, region string) (*Region, error) {
+	off, size, err := findArea(image, region)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeRegion(image[off : off+size])
+}
+
+// WriteImage encodes r in the region named region of the flash image.
+func WriteImage(image []byte, region string, r *Region) error {
+	off, size, err := findArea(image, region)
+	if err != nil {
+		return err
+	}
+	b, err := r.Encode(size)
+	if err != nil {
+		return err
+	}
+	copy(image[off:], b)
+	return nil
+}
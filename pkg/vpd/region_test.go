@@ -0,0 +1,122 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeRegion(t *testing.T) {
+	long := strings.Repeat("x", 200)
+	for _, tt := range []struct {
+		name    string
+		region  string
+		want    []Entry
+		wantErr bool
+	}{
+		{
+			name:   "VPD 2.0",
+			region: "\xfe\x09\x01gVpdInfo\x04\x13\x00\x00\x00" + "\x01\x06serial\x03A12" + "\x01\x02ab\x00" + "\x00\xff\xff",
+			want:   []Entry{{Key: "serial", Value: []byte("A12")}, {Key: "ab"}},
+		},
+		{
+			name:   "no info",
+			region: "\x01\x03key\x81\x48" + long + "\xff",
+			want:   []Entry{{Key: "key", Value: []byte(long)}},
+		},
+		{name: "erased", region: "\xff\xff\xff\xff"},
+		{name: "empty"},
+		{name: "truncated", region: "\x01\x03key\x05val", wantErr: true},
+		{name: "too long", region: "\xfe\x09\x01gVpdInfo\x04\x20\x00\x00\x00\x00", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := DecodeRegion([]byte(tt.region))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeRegion = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(r.Entries, tt.want) {
+				t.Errorf("DecodeRegion = %q, want %q", r.Entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	r := &Region{}
+	r.Set("serial_number", []byte("A12"))
+	r.Set("region", []byte("us"))
+	r.Set("serial_number", []byte("B34"))
+	if !r.Delete("region") || r.Delete("region") {
+		t.Errorf("Delete does not delete region once")
+	}
+	r.Set("ethernet_mac", []byte(strings.Repeat("0", 130)))
+
+	b, err := r.Encode(256)
+	if err != nil {
+		t.Fatalf("Encode = %v", err)
+	}
+	if len(b) != 256 || b[len(b)-1] != 0xff {
+		t.Errorf("Encode returned %d bytes, ending with %#x, want 256, erased", len(b), b[len(b)-1])
+	}
+	got, err := DecodeRegion(b)
+	if err != nil || !reflect.DeepEqual(got, r) {
+		t.Errorf("DecodeRegion(Encode) = %q, %v, want %q", got, err, r)
+	}
+	if v, ok := got.Get("serial_number"); !ok || string(v) != "B34" {
+		t.Errorf("Get(serial_number) = %q, %v, want B34", v, ok)
+	}
+
+	if _, err := r.Encode(64); err == nil {
+		t.Errorf("Encode in a region too small = nil, want error")
+	}
+}
+
+// image returns a flash image of size bytes with an FMAP at fmap, with the
+// areas, as names and their offsets and sizes.
+func image(size, fmap int, areas ...any) []byte {
+	b := bytes.Repeat([]byte{0xff}, size)
+	h := []byte(fmapSignature + "\x01\x01")
+	h = append(h, make([]byte, 8+4+fmapNameLen)...)
+	h = binary.LittleEndian.AppendUint16(h, uint16(len(areas)/3))
+	for i := 0; i < len(areas); i += 3 {
+		h = binary.LittleEndian.AppendUint32(h, uint32(areas[i+1].(int)))
+		h = binary.LittleEndian.AppendUint32(h, uint32(areas[i+2].(int)))
+		name := make([]byte, fmapNameLen+2)
+		copy(name, areas[i].(string))
+		h = append(h, name...)
+	}
+	copy(b[fmap:], h)
+	return b
+}
+
+func TestImage(t *testing.T) {
+	// A stray signature comes before the FMAP.
+	img := image(4096, 1024, "RO_VPD", 2048, 512, "RW_VPD", 3072, 1024, "BAD", 4000, 512)
+	copy(img, fmapSignature)
+
+	r := &Region{}
+	r.Set("serial_number", []byte("A12"))
+	if err := WriteImage(img, "RW_VPD", r); err != nil {
+		t.Fatalf("WriteImage = %v", err)
+	}
+	if got, err := ReadImage(img, "RW_VPD"); err != nil || !reflect.DeepEqual(got, r) {
+		t.Errorf("ReadImage(RW_VPD) = %q, %v, want %q", got, err, r)
+	}
+	if got, err := ReadImage(img, "RO_VPD"); err != nil || len(got.Entries) != 0 {
+		t.Errorf("ReadImage(RO_VPD) = %q, %v, want no entries", got, err)
+	}
+	for _, name := range []string{"BAD", "RW_LEGACY"} {
+		if _, err := ReadImage(img, name); err == nil {
+			t.Errorf("ReadImage(%s) = nil, want error", name)
+		}
+	}
+	if _, err := ReadImage(make([]byte, 4096), "RO_VPD"); err == nil {
+		t.Errorf("ReadImage of an image without FMAP = nil, want error")
+	}
+}
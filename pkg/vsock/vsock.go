@@ -0,0 +1,75 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vsock connects and listens on AF_VSOCK sockets, which virtual
+// machines and their hosts talk over without a network, as the net package
+// does not support them.
+package vsock
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Well-known context IDs.
+const (
+	CIDHypervisor = 0
+	CIDLocal      = 1
+	CIDHost       = 2
+	// CIDAny is the context ID of listeners on all context IDs.
+	CIDAny = 1<<32 - 1
+)
+
+// PortAny has the system choose the port of a listener.
+const PortAny = 1<<32 - 1
+
+// ErrNotSupported is returned on systems that do not support vsock.
+var ErrNotSupported = errors.New("vsock is not supported")
+
+var cids = map[string]uint32{
+	"hypervisor": CIDHypervisor,
+	"local":      CIDLocal,
+	"host":       CIDHost,
+}
+
+// Addr is an AF_VSOCK address.
+type Addr struct {
+	CID  uint32
+	Port uint32
+}
+
+// ParseAddr parses an address, CID:PORT, e.g. 3:1024. CID may also be
+// hypervisor, local or host.
+func ParseAddr(s string) (*Addr, error) {
+	cid, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vsock address %q: %w", s, err)
+	}
+	a := &Addr{}
+	if c, ok := cids[cid]; ok {
+		a.CID = c
+	} else if n, err := strconv.ParseUint(cid, 10, 32); err == nil {
+		a.CID = uint32(n)
+	} else {
+		return nil, fmt.Errorf("invalid vsock context ID %q", cid)
+	}
+	n, err := strconv.ParseUint(port, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vsock port %q", port)
+	}
+	a.Port = uint32(n)
+	return a, nil
+}
+
+// Network implements net.Addr.Network.
+func (a *Addr) Network() string {
+	return "vsock"
+}
+
+// String implements net.Addr.String.
+func (a *Addr) String() string {
+	return fmt.Sprintf("%d:%d", a.CID, a.Port)
+}
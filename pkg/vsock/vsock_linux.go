@@ -0,0 +1,209 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vsock
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Conn is a connected AF_VSOCK socket. Its file has deadlines, as the
+// socket is nonblocking.
+type Conn struct {
+	*os.File
+	local, remote *Addr
+}
+
+// LocalAddr implements net.Conn.LocalAddr.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr implements net.Conn.RemoteAddr.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// CloseWrite shuts the sending side of c down, for the peer to read the end
+// of what was sent.
+func (c *Conn) CloseWrite() error {
+	rc, err := c.File.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = unix.Shutdown(int(fd), unix.SHUT_WR)
+	}); err != nil {
+		return err
+	}
+	return os.NewSyscallError("shutdown", serr)
+}
+
+// sockAddr returns the address of sa, which is a vsock one.
+func sockAddr(sa unix.Sockaddr) *Addr {
+	if vm, ok := sa.(*unix.SockaddrVM); ok {
+		return &Addr{CID: vm.CID, Port: vm.Port}
+	}
+	return &Addr{}
+}
+
+// newConn returns the connection of the connected socket fd.
+func newConn(fd int, remote *Addr) *Conn {
+	// The file is pollable, as fd is nonblocking.
+	c := &Conn{File: os.NewFile(uintptr(fd), "vsock"), remote: remote}
+	if sa, err := unix.Getsockname(fd); err == nil {
+		c.local = sockAddr(sa)
+	}
+	return c
+}
+
+func socket() (int, error) {
+	fd, err := unix.Socket(unix.AF_VSOCK, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	return fd, os.NewSyscallError("socket", err)
+}
+
+// Dial connects to addr, until ctx is done.
+func Dial(ctx context.Context, addr Addr) (net.Conn, error) {
+	opErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "vsock", Addr: &addr, Err: err}
+	}
+	fd, err := socket()
+	if err != nil {
+		return nil, opErr(err)
+	}
+	err = unix.Connect(fd, &unix.SockaddrVM{CID: addr.CID, Port: addr.Port})
+	if err != nil && err != unix.EINPROGRESS {
+		unix.Close(fd)
+		return nil, opErr(os.NewSyscallError("connect", err))
+	}
+	c := newConn(fd, &addr)
+	if err == unix.EINPROGRESS {
+		if err := waitConnect(ctx, c.File); err != nil {
+			c.Close()
+			return nil, opErr(err)
+		}
+	}
+	return c, nil
+}
+
+// waitConnect waits for the nonblocking connect of f to complete, or ctx to
+// be done.
+func waitConnect(ctx context.Context, f *os.File) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	if d, ok := ctx.Deadline(); ok {
+		f.SetWriteDeadline(d)
+	}
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			// Wake the wait up.
+			f.SetWriteDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	var connErr error
+	err = rc.Write(func(fd uintptr) bool {
+		// The socket is connected once it has a peer, and failed once
+		// it has an error; else it is still connecting.
+		if _, err := unix.Getpeername(int(fd)); err == nil {
+			return true
+		}
+		n, err := unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_ERROR)
+		if err == nil && n != 0 {
+			err = unix.Errno(n)
+		}
+		if err != nil {
+			connErr = os.NewSyscallError("connect", err)
+			return true
+		}
+		return false
+	})
+	close(stop)
+	<-stopped
+	if errors.Is(err, os.ErrDeadlineExceeded) && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	if connErr != nil {
+		return connErr
+	}
+	return f.SetWriteDeadline(time.Time{})
+}
+
+// listener is a listening AF_VSOCK socket.
+type listener struct {
+	f    *os.File
+	addr *Addr
+}
+
+// Listen listens on port of all context IDs, or on a port the system
+// chooses if port is PortAny.
+func Listen(port uint32) (net.Listener, error) {
+	addr := &Addr{CID: CIDAny, Port: port}
+	opErr := func(err error) error {
+		return &net.OpError{Op: "listen", Net: "vsock", Addr: addr, Err: err}
+	}
+	fd, err := socket()
+	if err != nil {
+		return nil, opErr(err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrVM{CID: CIDAny, Port: port}); err != nil {
+		unix.Close(fd)
+		return nil, opErr(os.NewSyscallError("bind", err))
+	}
+	if err := unix.Listen(fd, unix.SOMAXCONN); err != nil {
+		unix.Close(fd)
+		return nil, opErr(os.NewSyscallError("listen", err))
+	}
+	if sa, err := unix.Getsockname(fd); err == nil {
+		addr = sockAddr(sa)
+	}
+	return &listener{f: os.NewFile(uintptr(fd), "vsock"), addr: addr}, nil
+}
+
+// Accept implements net.Listener.Accept.
+func (l *listener) Accept() (net.Conn, error) {
+	rc, err := l.f.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var nfd int
+	var sa unix.Sockaddr
+	var aerr error
+	if err := rc.Read(func(fd uintptr) bool {
+		nfd, sa, aerr = unix.Accept4(int(fd), unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC)
+		return aerr != unix.EAGAIN
+	}); err != nil {
+		return nil, &net.OpError{Op: "accept", Net: "vsock", Addr: l.addr, Err: err}
+	}
+	if aerr != nil {
+		return nil, &net.OpError{Op: "accept", Net: "vsock", Addr: l.addr, Err: os.NewSyscallError("accept4", aerr)}
+	}
+	return newConn(nfd, sockAddr(sa)), nil
+}
+
+// Close implements net.Listener.Close.
+func (l *listener) Close() error {
+	return l.f.Close()
+}
+
+// Addr implements net.Listener.Addr.
+func (l *listener) Addr() net.Addr {
+	return l.addr
+}
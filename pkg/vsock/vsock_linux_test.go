@@ -0,0 +1,74 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vsock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialTimeout(t *testing.T) {
+	// No one listens on this port of the local context, which refuses or
+	// times out connections, if the kernel has vsock at all.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := Dial(ctx, Addr{CID: CIDLocal, Port: PortAny - 1})
+	var oe *net.OpError
+	if !errors.As(err, &oe) || oe.Net != "vsock" || oe.Op != "dial" {
+		t.Errorf("Dial = %v, want a vsock dial error", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Dial took %v, want it to give up after 100ms", d)
+	}
+}
+
+func TestListenDial(t *testing.T) {
+	l, err := Listen(PortAny)
+	if err != nil {
+		t.Skipf("vsock is not supported: %v", err)
+	}
+	defer l.Close()
+	if a := l.Addr().(*Addr); a.Port == PortAny {
+		t.Errorf("listening on %v, want the port the system chose", a)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := Dial(ctx, Addr{CID: CIDLocal, Port: l.Addr().(*Addr).Port})
+	if err != nil {
+		t.Skipf("vsock has no loopback: %v", err)
+	}
+	defer c.Close()
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := io.WriteString(c, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.(*Conn).CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(s)
+	if err != nil || string(b) != "hello" {
+		t.Errorf("read %q, %v, want hello", b, err)
+	}
+
+	// Closing the listener stops Accept.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.Close()
+	}()
+	if _, err := l.Accept(); err == nil {
+		t.Errorf("Accept on a closed listener = nil, want an error")
+	}
+}
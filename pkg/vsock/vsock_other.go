@@ -0,0 +1,23 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package vsock
+
+import (
+	"context"
+	"net"
+)
+
+// Dial connects to addr. It is only supported on Linux.
+func Dial(ctx context.Context, addr Addr) (net.Conn, error) {
+	return nil, &net.OpError{Op: "dial", Net: "vsock", Addr: &addr, Err: ErrNotSupported}
+}
+
+// Listen listens on port of all context IDs. It is only supported on Linux.
+func Listen(port uint32) (net.Listener, error) {
+	return nil, &net.OpError{Op: "listen", Net: "vsock", Addr: &Addr{CID: CIDAny, Port: port}, Err: ErrNotSupported}
+}
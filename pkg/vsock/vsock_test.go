@@ -0,0 +1,34 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vsock
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAddr(t *testing.T) {
+	for _, tt := range []struct {
+		s       string
+		want    *Addr
+		wantErr bool
+	}{
+		{s: "3:1024", want: &Addr{CID: 3, Port: 1024}},
+		{s: "host:80", want: &Addr{CID: CIDHost, Port: 80}},
+		{s: "local:4294967295", want: &Addr{CID: CIDLocal, Port: PortAny}},
+		{s: "3", wantErr: true},
+		{s: "guest:80", wantErr: true},
+		{s: "3:http", wantErr: true},
+		{s: "-1:80", wantErr: true},
+	} {
+		got, err := ParseAddr(tt.s)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAddr(%q) = %v, %v, want %v, error %t", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if s := (&Addr{CID: 3, Port: 1024}).String(); s != "3:1024" {
+		t.Errorf("String = %q, want 3:1024", s)
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wireguard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Device is the state of a WireGuard interface.
+type Device struct {
+	Name         string
+	PrivateKey   Key
+	PublicKey    Key
+	ListenPort   int
+	FirewallMark int
+	Peers        []Peer
+}
+
+// Peer is the state of a peer of a WireGuard interface.
+type Peer struct {
+	PublicKey           Key
+	PresharedKey        Key
+	Endpoint            *net.UDPAddr
+	PersistentKeepalive time.Duration
+	LastHandshake       time.Time
+	RxBytes             uint64
+	TxBytes             uint64
+	AllowedIPs          []net.IPNet
+}
+
+// Config is a change to a WireGuard interface. Nil fields are left alone.
+type Config struct {
+	PrivateKey   *Key
+	ListenPort   *int
+	FirewallMark *int
+
+	// ReplacePeers removes the peers that are not in Peers.
+	ReplacePeers bool
+	Peers        []PeerConfig
+}
+
+// PeerConfig is a change to one peer of a WireGuard interface. Nil fields are
+// left alone.
+type PeerConfig struct {
+	PublicKey Key
+
+	// Remove removes the peer; the other fields are ignored.
+	Remove bool
+
+	// UpdateOnly only changes the peer if it exists already.
+	UpdateOnly bool
+
+	PresharedKey        *Key
+	Endpoint            *net.UDPAddr
+	PersistentKeepalive *time.Duration
+
+	// ReplaceAllowedIPs removes the allowed IPs that are not in AllowedIPs.
+	ReplaceAllowedIPs bool
+	AllowedIPs        []net.IPNet
+}
+
+// QuickConfig is a wg-quick configuration file: a Config for the interface
+// settings and peers, and how to set up the interface.
+type QuickConfig struct {
+	Config
+
+	// Address is assigned to the interface.
+	Address []net.IPNet
+
+	// DNS servers and search domains to use while the interface is up.
+	DNS       []net.IP
+	DNSSearch []string
+
+	// MTU of the interface; 0 is the kernel default.
+	MTU int
+
+	// Table is where routes to the allowed IPs go: "auto" (the main
+	// table, or a table of its own for default routes), "off", or a
+	// table number.
+	Table string
+}
+
+// parseCIDR parses an address with a prefix length, or a bare address as a
+// host. If masked is set, the address is masked to the network.
+func parseCIDR(s string, masked bool) (net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return net.IPNet{}, fmt.Errorf("invalid address %q", s)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	ip, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return net.IPNet{}, err
+	}
+	if masked {
+		return *n, nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return net.IPNet{IP: ip, Mask: n.Mask}, nil
+}
+
+func splitList(v string) []string {
+	var r []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+// ParseQuickConfig reads a wg-quick configuration, with an [Interface]
+// section and any number of [Peer] sections. Endpoints are resolved.
+//
+// PreUp, PostUp, PreDown and PostDown are rejected, since there is no shell
+// to run them with.
+func ParseQuickConfig(r io.Reader) (*QuickConfig, error) {
+	c := &QuickConfig{Config: Config{ReplacePeers: true}, Table: "auto"}
+	var peer *PeerConfig
+	section := ""
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		switch strings.ToLower(line) {
+		case "[interface]":
+			section, peer = "interface", nil
+			continue
+		case "[peer]":
+			section = "peer"
+			c.Peers = append(c.Peers, PeerConfig{ReplaceAllowedIPs: true})
+			peer = &c.Peers[len(c.Peers)-1]
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: %q is not KEY = VALUE", n, line)
+		}
+		key, v := strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		var err error
+		switch section {
+		case "interface":
+			err = c.setInterface(key, v)
+		case "peer":
+			err = peer.set(key, v)
+		default:
+			err = fmt.Errorf("%s outside of a section", kv[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for i, p := range c.Peers {
+		if p.PublicKey.IsZero() {
+			return nil, fmt.Errorf("peer %d has no PublicKey", i+1)
+		}
+	}
+	return c, nil
+}
+
+func (c *QuickConfig) setInterface(key, v string) error {
+	switch key {
+	case "privatekey":
+		k, err := ParseKey(v)
+		if err != nil {
+			return err
+		}
+		c.PrivateKey = &k
+	case "listenport":
+		p, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return fmt.Errorf("ListenPort: %v", err)
+		}
+		port := int(p)
+		c.ListenPort = &port
+	case "fwmark":
+		m := 0
+		if v != "off" {
+			u, err := strconv.ParseUint(v, 0, 32)
+			if err != nil {
+				return fmt.Errorf("FwMark: %v", err)
+			}
+			m = int(u)
+		}
+		c.FirewallMark = &m
+	case "address":
+		for _, a := range splitList(v) {
+			n, err := parseCIDR(a, false)
+			if err != nil {
+				return fmt.Errorf("Address: %v", err)
+			}
+			c.Address = append(c.Address, n)
+		}
+	case "dns":
+		for _, d := range splitList(v) {
+			if ip := net.ParseIP(d); ip != nil {
+				c.DNS = append(c.DNS, ip)
+			} else {
+				c.DNSSearch = append(c.DNSSearch, d)
+			}
+		}
+	case "mtu":
+		m, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("MTU: %v", err)
+		}
+		c.MTU = m
+	case "table":
+		if v != "auto" && v != "off" {
+			if _, err := strconv.ParseUint(v, 10, 32); err != nil {
+				return fmt.Errorf("Table: want auto, off or a number, got %q", v)
+			}
+		}
+		c.Table = v
+	case "saveconfig":
+		// The running configuration is never written back.
+	case "preup", "postup", "predown", "postdown":
+		return fmt.Errorf("%s hooks are not supported", key)
+	default:
+		return fmt.Errorf("unknown Interface key %q", key)
+	}
+	return nil
+}
+
+func (p *PeerConfig) set(key, v string) error {
+	switch key {
+	case "publickey":
+		k, err := ParseKey(v)
+		if err != nil {
+			return err
+		}
+		p.PublicKey = k
+	case "presharedkey":
+		k, err := ParseKey(v)
+		if err != nil {
+			return err
+		}
+		p.PresharedKey = &k
+	case "endpoint":
+		a, err := net.ResolveUDPAddr("udp", v)
+		if err != nil {
+			return fmt.Errorf("Endpoint: %v", err)
+		}
+		p.Endpoint = a
+	case "allowedips":
+		for _, a := range splitList(v) {
+			n, err := parseCIDR(a, true)
+			if err != nil {
+				return fmt.Errorf("AllowedIPs: %v", err)
+			}
+			p.AllowedIPs = append(p.AllowedIPs, n)
+		}
+	case "persistentkeepalive":
+		d := 0
+		if v != "off" {
+			var err error
+			if d, err = strconv.Atoi(v); err != nil || d < 0 || d > 65535 {
+				return fmt.Errorf("PersistentKeepalive: want off or 0-65535 seconds, got %q", v)
+			}
+		}
+		ka := time.Duration(d) * time.Second
+		p.PersistentKeepalive = &ka
+	default:
+		return fmt.Errorf("unknown Peer key %q", key)
+	}
+	return nil
+}
+
+// WriteConfig writes the state of d in the configuration format that
+// ParseQuickConfig reads.
+func WriteConfig(w io.Writer, d *Device) error {
+	var b strings.Builder
+	b.WriteString("[Interface]\n")
+	if !d.PrivateKey.IsZero() {
+		fmt.Fprintf(&b, "PrivateKey = %s\n", d.PrivateKey)
+	}
+	if d.ListenPort != 0 {
+		fmt.Fprintf(&b, "ListenPort = %d\n", d.ListenPort)
+	}
+	if d.FirewallMark != 0 {
+		fmt.Fprintf(&b, "FwMark = %#x\n", d.FirewallMark)
+	}
+	for _, p := range d.Peers {
+		fmt.Fprintf(&b, "\n[Peer]\nPublicKey = %s\n", p.PublicKey)
+		if !p.PresharedKey.IsZero() {
+			fmt.Fprintf(&b, "PresharedKey = %s\n", p.PresharedKey)
+		}
+		if len(p.AllowedIPs) > 0 {
+			var ips []string
+			for _, n := range p.AllowedIPs {
+				ips = append(ips, n.String())
+			}
+			fmt.Fprintf(&b, "AllowedIPs = %s\n", strings.Join(ips, ", "))
+		}
+		if p.Endpoint != nil {
+			fmt.Fprintf(&b, "Endpoint = %s\n", p.Endpoint)
+		}
+		if p.PersistentKeepalive != 0 {
+			fmt.Fprintf(&b, "PersistentKeepalive = %d\n", int(p.PersistentKeepalive/time.Second))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
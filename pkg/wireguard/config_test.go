@@ -0,0 +1,149 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wireguard
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Keys from the WireGuard documentation.
+const (
+	privA = "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk="
+	pubA  = "HIgo9xNzJMWLKASShiTqIybxZ0U3wGLiUeJ1PKf8ykw="
+	pubB  = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+)
+
+func TestKey(t *testing.T) {
+	k, err := ParseKey(privA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := k.PublicKey().String(); got != pubA {
+		t.Errorf("PublicKey = %s, want %s", got, pubA)
+	}
+	for _, bad := range []string{"", "not base64!", "AAAA"} {
+		if _, err := ParseKey(bad); err == nil {
+			t.Errorf("ParseKey(%q) succeeded", bad)
+		}
+	}
+
+	g, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g[0]&7 != 0 || g[31]&128 != 0 || g[31]&64 == 0 {
+		t.Errorf("GenerateKey = %x, not clamped", g)
+	}
+	if p, err := ParseKey(g.String()); err != nil || p != g {
+		t.Errorf("ParseKey(%s) = %v, %v", g, p, err)
+	}
+}
+
+const quickConf = `
+[Interface]
+# The management tunnel.
+PrivateKey = ` + privA + `
+ListenPort = 51820
+Address = 10.0.0.2/24, fd00::2/64
+DNS = 10.0.0.1, mgmt.example
+MTU = 1420
+SaveConfig = true
+
+[Peer]
+PublicKey = ` + pubB + `
+Endpoint = 192.0.2.1:51820
+AllowedIPs = 10.0.0.0/24, 0.0.0.0/0, 192.0.2.7
+PersistentKeepalive = 25
+`
+
+func TestParseQuickConfig(t *testing.T) {
+	c, err := ParseQuickConfig(strings.NewReader(quickConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.PrivateKey == nil || c.PrivateKey.String() != privA {
+		t.Errorf("PrivateKey = %v", c.PrivateKey)
+	}
+	if c.ListenPort == nil || *c.ListenPort != 51820 || c.MTU != 1420 || c.Table != "auto" || !c.ReplacePeers {
+		t.Errorf("interface = %+v", c)
+	}
+	if len(c.Address) != 2 || c.Address[0].String() != "10.0.0.2/24" || c.Address[1].String() != "fd00::2/64" {
+		t.Errorf("Address = %v", c.Address)
+	}
+	if len(c.DNS) != 1 || !c.DNS[0].Equal(net.IPv4(10, 0, 0, 1)) || len(c.DNSSearch) != 1 || c.DNSSearch[0] != "mgmt.example" {
+		t.Errorf("DNS = %v, search %v", c.DNS, c.DNSSearch)
+	}
+	if len(c.Peers) != 1 {
+		t.Fatalf("Peers = %+v", c.Peers)
+	}
+	p := c.Peers[0]
+	if p.PublicKey.String() != pubB || p.Endpoint.String() != "192.0.2.1:51820" || *p.PersistentKeepalive != 25*time.Second || !p.ReplaceAllowedIPs {
+		t.Errorf("peer = %+v", p)
+	}
+	var ips []string
+	for _, n := range p.AllowedIPs {
+		ips = append(ips, n.String())
+	}
+	if got, want := strings.Join(ips, " "), "10.0.0.0/24 0.0.0.0/0 192.0.2.7/32"; got != want {
+		t.Errorf("AllowedIPs = %s, want %s", got, want)
+	}
+
+	for _, bad := range []string{
+		"PrivateKey = " + privA,
+		"[Interface]\nPostUp = iptables -F",
+		"[Interface]\nTable = main",
+		"[Interface]\nBogus = 1",
+		"[Peer]\nAllowedIPs = 10.0.0.0/8",
+		"[Peer]\nPublicKey = " + pubB + "\nPersistentKeepalive = 70000",
+		"[Interface]\nListenPort",
+	} {
+		if _, err := ParseQuickConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseQuickConfig(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	priv, _ := ParseKey(privA)
+	peer, _ := ParseKey(pubB)
+	_, n, _ := net.ParseCIDR("10.0.0.0/24")
+	d := &Device{
+		Name:         "wg0",
+		PrivateKey:   priv,
+		ListenPort:   51820,
+		FirewallMark: 0xca6c,
+		Peers: []Peer{{
+			PublicKey:           peer,
+			Endpoint:            &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 51820},
+			PersistentKeepalive: 25 * time.Second,
+			AllowedIPs:          []net.IPNet{*n},
+		}},
+	}
+	var b strings.Builder
+	if err := WriteConfig(&b, d); err != nil {
+		t.Fatal(err)
+	}
+	want := `[Interface]
+PrivateKey = ` + privA + `
+ListenPort = 51820
+FwMark = 0xca6c
+
+[Peer]
+PublicKey = ` + pubB + `
+AllowedIPs = 10.0.0.0/24
+Endpoint = 192.0.2.1:51820
+PersistentKeepalive = 25
+`
+	if b.String() != want {
+		t.Errorf("WriteConfig = %q, want %q", b.String(), want)
+	}
+	c, err := ParseQuickConfig(strings.NewReader(b.String()))
+	if err != nil || *c.FirewallMark != 0xca6c || len(c.Peers) != 1 {
+		t.Errorf("ParseQuickConfig(WriteConfig) = %+v, %v", c, err)
+	}
+}
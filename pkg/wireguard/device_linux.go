@@ -0,0 +1,383 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wireguard
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// The generic netlink interface, from uapi/linux/wireguard.h.
+const (
+	genlName    = "wireguard"
+	genlVersion = 1
+
+	cmdGetDevice = 0
+	cmdSetDevice = 1
+
+	deviceIfname     = 2
+	devicePrivateKey = 3
+	devicePublicKey  = 4
+	deviceFlags      = 5
+	deviceListenPort = 6
+	deviceFwmark     = 7
+	devicePeers      = 8
+
+	deviceReplacePeers = 1
+
+	peerPublicKey       = 1
+	peerPresharedKey    = 2
+	peerFlags           = 3
+	peerEndpoint        = 4
+	peerKeepalive       = 5
+	peerLastHandshake   = 6
+	peerRxBytes         = 7
+	peerTxBytes         = 8
+	peerAllowedIPs      = 9
+	peerProtocolVersion = 10
+
+	peerRemove            = 1
+	peerReplaceAllowedIPs = 2
+	peerUpdateOnly        = 4
+
+	allowedIPFamily = 1
+	allowedIPAddr   = 2
+	allowedIPCIDR   = 3
+
+	nested = int(nl.NLA_F_NESTED)
+
+	sizeofSockaddrIn     = 16
+	sizeofSockaddrIn6    = 28
+	sizeofKernelTimespec = 16
+
+	protocolVersion        = 1
+	maxPersistentKeepalive = 65535 * time.Second
+)
+
+var (
+	// ErrNoDevice is returned for interfaces that are not WireGuard
+	// interfaces.
+	ErrNoDevice = errors.New("no such WireGuard device")
+
+	// ErrNotSupported is returned if the kernel has no WireGuard module.
+	ErrNotSupported = errors.New("WireGuard is not supported by this kernel")
+
+	errBadEndpoint = errors.New("bad endpoint attribute")
+)
+
+func family() (uint16, error) {
+	f, err := netlink.GenlFamilyGet(genlName)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) {
+			return 0, ErrNotSupported
+		}
+		return 0, err
+	}
+	return f.ID, nil
+}
+
+func encodeEndpoint(a *net.UDPAddr) []byte {
+	ne := nl.NativeEndian()
+	if ip4 := a.IP.To4(); ip4 != nil {
+		b := make([]byte, sizeofSockaddrIn)
+		ne.PutUint16(b[0:], unix.AF_INET)
+		binary.BigEndian.PutUint16(b[2:], uint16(a.Port))
+		copy(b[4:], ip4)
+		return b
+	}
+	b := make([]byte, sizeofSockaddrIn6)
+	ne.PutUint16(b[0:], unix.AF_INET6)
+	binary.BigEndian.PutUint16(b[2:], uint16(a.Port))
+	copy(b[8:], a.IP.To16())
+	if a.Zone != "" {
+		if ifc, err := net.InterfaceByName(a.Zone); err == nil {
+			ne.PutUint32(b[24:], uint32(ifc.Index))
+		}
+	}
+	return b
+}
+
+func decodeEndpoint(b []byte) (*net.UDPAddr, error) {
+	if len(b) < 2 {
+		return nil, errBadEndpoint
+	}
+	switch nl.NativeEndian().Uint16(b) {
+	case unix.AF_INET:
+		if len(b) < sizeofSockaddrIn {
+			return nil, errBadEndpoint
+		}
+		return &net.UDPAddr{IP: net.IP(append([]byte{}, b[4:8]...)), Port: int(binary.BigEndian.Uint16(b[2:]))}, nil
+	case unix.AF_INET6:
+		if len(b) < sizeofSockaddrIn6 {
+			return nil, errBadEndpoint
+		}
+		return &net.UDPAddr{IP: net.IP(append([]byte{}, b[8:24]...)), Port: int(binary.BigEndian.Uint16(b[2:]))}, nil
+	}
+	return nil, errBadEndpoint
+}
+
+// encodeConfig returns the attributes of a WG_CMD_SET_DEVICE request.
+func encodeConfig(name string, c *Config) []*nl.RtAttr {
+	attrs := []*nl.RtAttr{nl.NewRtAttr(deviceIfname, nl.ZeroTerminated(name))}
+	if c.PrivateKey != nil {
+		attrs = append(attrs, nl.NewRtAttr(devicePrivateKey, c.PrivateKey[:]))
+	}
+	if c.ListenPort != nil {
+		attrs = append(attrs, nl.NewRtAttr(deviceListenPort, nl.Uint16Attr(uint16(*c.ListenPort))))
+	}
+	if c.FirewallMark != nil {
+		attrs = append(attrs, nl.NewRtAttr(deviceFwmark, nl.Uint32Attr(uint32(*c.FirewallMark))))
+	}
+	if c.ReplacePeers {
+		attrs = append(attrs, nl.NewRtAttr(deviceFlags, nl.Uint32Attr(deviceReplacePeers)))
+	}
+	if len(c.Peers) == 0 {
+		return attrs
+	}
+
+	peers := nl.NewRtAttr(devicePeers|nested, nil)
+	for i := range c.Peers {
+		// The attributes point into the peer, so it must not be a copy
+		// that the next iteration overwrites.
+		p := &c.Peers[i]
+		pa := peers.AddRtAttr(i|nested, nil)
+		pa.AddRtAttr(peerPublicKey, p.PublicKey[:])
+		var flags uint32
+		if p.Remove {
+			flags |= peerRemove
+		}
+		if p.UpdateOnly {
+			flags |= peerUpdateOnly
+		}
+		if p.ReplaceAllowedIPs {
+			flags |= peerReplaceAllowedIPs
+		}
+		if flags != 0 {
+			pa.AddRtAttr(peerFlags, nl.Uint32Attr(flags))
+		}
+		if p.Remove {
+			continue
+		}
+		if p.PresharedKey != nil {
+			pa.AddRtAttr(peerPresharedKey, p.PresharedKey[:])
+		}
+		if p.Endpoint != nil {
+			pa.AddRtAttr(peerEndpoint, encodeEndpoint(p.Endpoint))
+		}
+		if p.PersistentKeepalive != nil {
+			pa.AddRtAttr(peerKeepalive, nl.Uint16Attr(uint16(*p.PersistentKeepalive/time.Second)))
+		}
+		pa.AddRtAttr(peerProtocolVersion, nl.Uint32Attr(protocolVersion))
+		if len(p.AllowedIPs) == 0 {
+			continue
+		}
+		ips := pa.AddRtAttr(peerAllowedIPs|nested, nil)
+		for j, n := range p.AllowedIPs {
+			ia := ips.AddRtAttr(j|nested, nil)
+			ones, _ := n.Mask.Size()
+			if ip4 := n.IP.To4(); ip4 != nil {
+				ia.AddRtAttr(allowedIPFamily, nl.Uint16Attr(unix.AF_INET))
+				ia.AddRtAttr(allowedIPAddr, ip4)
+			} else {
+				ia.AddRtAttr(allowedIPFamily, nl.Uint16Attr(unix.AF_INET6))
+				ia.AddRtAttr(allowedIPAddr, n.IP.To16())
+			}
+			ia.AddRtAttr(allowedIPCIDR, nl.Uint8Attr(uint8(ones)))
+		}
+	}
+	return append(attrs, peers)
+}
+
+func parseAttrs(b []byte) ([]syscall.NetlinkRouteAttr, error) {
+	attrs, err := nl.ParseRouteAttr(b)
+	for i := range attrs {
+		attrs[i].Attr.Type &= nl.NLA_TYPE_MASK
+	}
+	return attrs, err
+}
+
+func decodeAllowedIP(b []byte) (net.IPNet, error) {
+	attrs, err := parseAttrs(b)
+	if err != nil {
+		return net.IPNet{}, err
+	}
+	var ip net.IP
+	var ones, bits int
+	for _, a := range attrs {
+		switch a.Attr.Type {
+		case allowedIPAddr:
+			ip = net.IP(append([]byte{}, a.Value...))
+			bits = 8 * len(a.Value)
+		case allowedIPCIDR:
+			if len(a.Value) > 0 {
+				ones = int(a.Value[0])
+			}
+		}
+	}
+	if ip == nil {
+		return net.IPNet{}, errors.New("allowed IP without address")
+	}
+	return net.IPNet{IP: ip, Mask: net.CIDRMask(ones, bits)}, nil
+}
+
+func decodePeer(b []byte) (Peer, error) {
+	var p Peer
+	attrs, err := parseAttrs(b)
+	if err != nil {
+		return p, err
+	}
+	ne := nl.NativeEndian()
+	for _, a := range attrs {
+		v := a.Value
+		switch a.Attr.Type {
+		case peerPublicKey:
+			copy(p.PublicKey[:], v)
+		case peerPresharedKey:
+			copy(p.PresharedKey[:], v)
+		case peerEndpoint:
+			if p.Endpoint, err = decodeEndpoint(v); err != nil {
+				return p, err
+			}
+		case peerKeepalive:
+			if len(v) >= 2 {
+				p.PersistentKeepalive = time.Duration(ne.Uint16(v)) * time.Second
+			}
+		case peerLastHandshake:
+			if len(v) >= sizeofKernelTimespec {
+				sec, nsec := int64(ne.Uint64(v)), int64(ne.Uint64(v[8:]))
+				if sec != 0 || nsec != 0 {
+					p.LastHandshake = time.Unix(sec, nsec)
+				}
+			}
+		case peerRxBytes:
+			if len(v) >= 8 {
+				p.RxBytes = ne.Uint64(v)
+			}
+		case peerTxBytes:
+			if len(v) >= 8 {
+				p.TxBytes = ne.Uint64(v)
+			}
+		case peerAllowedIPs:
+			ips, err := parseAttrs(v)
+			if err != nil {
+				return p, err
+			}
+			for _, ia := range ips {
+				n, err := decodeAllowedIP(ia.Value)
+				if err != nil {
+					return p, err
+				}
+				p.AllowedIPs = append(p.AllowedIPs, n)
+			}
+		}
+	}
+	return p, nil
+}
+
+// decodeDevice merges the messages of a WG_CMD_GET_DEVICE dump. Large devices
+// are split over several messages, and the allowed IPs of one peer may
+// continue in the next message.
+func decodeDevice(msgs [][]byte) (*Device, error) {
+	d := &Device{}
+	ne := nl.NativeEndian()
+	for _, m := range msgs {
+		if len(m) < nl.SizeofGenlmsg {
+			return nil, errors.New("short generic netlink message")
+		}
+		attrs, err := parseAttrs(m[nl.SizeofGenlmsg:])
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range attrs {
+			v := a.Value
+			switch a.Attr.Type {
+			case deviceIfname:
+				d.Name = nl.BytesToString(v)
+			case devicePrivateKey:
+				copy(d.PrivateKey[:], v)
+			case devicePublicKey:
+				copy(d.PublicKey[:], v)
+			case deviceListenPort:
+				if len(v) >= 2 {
+					d.ListenPort = int(ne.Uint16(v))
+				}
+			case deviceFwmark:
+				if len(v) >= 4 {
+					d.FirewallMark = int(ne.Uint32(v))
+				}
+			case devicePeers:
+				peers, err := parseAttrs(v)
+				if err != nil {
+					return nil, err
+				}
+				for _, pa := range peers {
+					p, err := decodePeer(pa.Value)
+					if err != nil {
+						return nil, err
+					}
+					if n := len(d.Peers); n > 0 && d.Peers[n-1].PublicKey == p.PublicKey {
+						d.Peers[n-1].AllowedIPs = append(d.Peers[n-1].AllowedIPs, p.AllowedIPs...)
+						continue
+					}
+					d.Peers = append(d.Peers, p)
+				}
+			}
+		}
+	}
+	return d, nil
+}
+
+// Get returns the state of the WireGuard interface name.
+func Get(name string) (*Device, error) {
+	fam, err := family()
+	if err != nil {
+		return nil, err
+	}
+	req := nl.NewNetlinkRequest(int(fam), unix.NLM_F_DUMP)
+	req.AddData(&nl.Genlmsg{Command: cmdGetDevice, Version: genlVersion})
+	req.AddData(nl.NewRtAttr(deviceIfname, nl.ZeroTerminated(name)))
+	msgs, err := req.Execute(unix.NETLINK_GENERIC, 0)
+	if errors.Is(err, unix.ENODEV) || errors.Is(err, unix.ENOTSUP) {
+		return nil, fmt.Errorf("%s: %w", name, ErrNoDevice)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return decodeDevice(msgs)
+}
+
+// Configure applies c to the WireGuard interface name.
+func Configure(name string, c *Config) error {
+	for _, p := range c.Peers {
+		if p.PersistentKeepalive != nil && (*p.PersistentKeepalive < 0 || *p.PersistentKeepalive > maxPersistentKeepalive) {
+			return fmt.Errorf("persistent keepalive %v is out of range", *p.PersistentKeepalive)
+		}
+	}
+	fam, err := family()
+	if err != nil {
+		return err
+	}
+	req := nl.NewNetlinkRequest(int(fam), unix.NLM_F_ACK)
+	req.AddData(&nl.Genlmsg{Command: cmdSetDevice, Version: genlVersion})
+	for _, a := range encodeConfig(name, c) {
+		req.AddData(a)
+	}
+	_, err = req.Execute(unix.NETLINK_GENERIC, 0)
+	if errors.Is(err, unix.ENODEV) || errors.Is(err, unix.ENOTSUP) {
+		return fmt.Errorf("%s: %w", name, ErrNoDevice)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wireguard
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink/nl"
+)
+
+// message returns attrs as the payload of a generic netlink message.
+func message(attrs []*nl.RtAttr) []byte {
+	b := (&nl.Genlmsg{Command: cmdGetDevice, Version: genlVersion}).Serialize()
+	for _, a := range attrs {
+		b = append(b, a.Serialize()...)
+	}
+	return b
+}
+
+func TestEncodeDecode(t *testing.T) {
+	c, err := ParseQuickConfig(strings.NewReader(quickConf + `
+[Peer]
+PublicKey = ` + pubA + `
+Endpoint = [2001:db8::1]:4500
+AllowedIPs = fd00::/64
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mark := 0x1234
+	c.FirewallMark = &mark
+
+	d, err := decodeDevice([][]byte{message(encodeConfig("wg0", &c.Config))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "wg0" || d.PrivateKey != *c.PrivateKey || d.ListenPort != 51820 || d.FirewallMark != mark {
+		t.Errorf("device = %+v", d)
+	}
+	if len(d.Peers) != 2 {
+		t.Fatalf("Peers = %+v", d.Peers)
+	}
+	for i, p := range d.Peers {
+		want := c.Peers[i]
+		if p.PublicKey != want.PublicKey || p.Endpoint.String() != want.Endpoint.String() {
+			t.Errorf("peer %d = %+v, want %+v", i, p, want)
+		}
+		if !reflect.DeepEqual(p.AllowedIPs, want.AllowedIPs) {
+			t.Errorf("peer %d AllowedIPs = %v, want %v", i, p.AllowedIPs, want.AllowedIPs)
+		}
+	}
+	if d.Peers[0].PersistentKeepalive != 25*time.Second || d.Peers[1].PersistentKeepalive != 0 {
+		t.Errorf("PersistentKeepalive = %v, %v", d.Peers[0].PersistentKeepalive, d.Peers[1].PersistentKeepalive)
+	}
+}
+
+// The kernel splits the allowed IPs of a peer over several messages.
+func TestDecodeSplitPeer(t *testing.T) {
+	pub, _ := ParseKey(pubB)
+	var msgs [][]byte
+	var want []net.IPNet
+	for _, s := range []string{"10.0.0.0/24", "10.1.0.0/16"} {
+		_, n, _ := net.ParseCIDR(s)
+		want = append(want, *n)
+		msgs = append(msgs, message(encodeConfig("wg0", &Config{Peers: []PeerConfig{{PublicKey: pub, AllowedIPs: []net.IPNet{*n}}}})))
+	}
+	d, err := decodeDevice(msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Peers) != 1 || !reflect.DeepEqual(d.Peers[0].AllowedIPs, want) {
+		t.Errorf("Peers = %+v, want one peer with %v", d.Peers, want)
+	}
+	if _, err := decodeDevice([][]byte{{1}}); err == nil {
+		t.Errorf("decodeDevice of a short message succeeded")
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	for _, tt := range []struct {
+		table     string
+		wantTable []int
+		wantDef   int
+	}{
+		{"auto", []int{254, DefaultTable, 254}, DefaultTable},
+		{"off", nil, 0},
+		{"100", []int{100, 100, 100}, 0},
+	} {
+		c, err := ParseQuickConfig(strings.NewReader(quickConf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Table = tt.table
+		routes, def, err := c.routes(7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tables []int
+		for _, r := range routes {
+			if r.LinkIndex != 7 {
+				t.Errorf("route %v is not through link 7", r)
+			}
+			tables = append(tables, r.Table)
+		}
+		if !reflect.DeepEqual(tables, tt.wantTable) || def != tt.wantDef {
+			t.Errorf("Table %s: routes in %v, default table %d, want %v, %d", tt.table, tables, def, tt.wantTable, tt.wantDef)
+		}
+	}
+}
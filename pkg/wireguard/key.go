@@ -0,0 +1,72 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package wireguard configures WireGuard interfaces through generic netlink,
+// and reads and applies wg-quick style configuration files.
+package wireguard
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// KeyLen is the length of WireGuard keys.
+const KeyLen = 32
+
+// Key is a Curve25519 private or public key, or a preshared key.
+type Key [KeyLen]byte
+
+// GenerateKey returns a new private key.
+func GenerateKey() (Key, error) {
+	var k Key
+	if _, err := rand.Read(k[:]); err != nil {
+		return Key{}, err
+	}
+	// Clamp as Curve25519 does, so that the stored key is the one used.
+	k[0] &= 248
+	k[31] = k[31]&127 | 64
+	return k, nil
+}
+
+// GeneratePresharedKey returns a new random preshared key.
+func GeneratePresharedKey() (Key, error) {
+	var k Key
+	_, err := rand.Read(k[:])
+	return k, err
+}
+
+// ParseKey decodes a base64 encoded key.
+func ParseKey(s string) (Key, error) {
+	var k Key
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return Key{}, fmt.Errorf("invalid key: %v", err)
+	}
+	if len(b) != KeyLen {
+		return Key{}, fmt.Errorf("invalid key: %d bytes, want %d", len(b), KeyLen)
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// PublicKey returns the public key of the private key k.
+func (k Key) PublicKey() Key {
+	var p Key
+	b, _ := curve25519.X25519(k[:], curve25519.Basepoint)
+	copy(p[:], b)
+	return p
+}
+
+// IsZero reports whether k is all zeros, which WireGuard treats as no key.
+func (k Key) IsZero() bool {
+	return k == Key{}
+}
+
+// String returns the base64 encoding of k.
+func (k Key) String() string {
+	return base64.StdEncoding.EncodeToString(k[:])
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/u-root/u-root/pkg/dhclient"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultTable is the routing table, and the firewall mark, that wg-quick uses
+// for default routes through the tunnel.
+const DefaultTable = 51820
+
+func dnsSource(name string) string {
+	return "wg-" + name
+}
+
+// routes returns the routes to the allowed IPs of c through the interface
+// with index, and the table for default routes, or 0 if there are none.
+func (c *QuickConfig) routes(index int) ([]*netlink.Route, int, error) {
+	if c.Table == "off" {
+		return nil, 0, nil
+	}
+	table := unix.RT_TABLE_MAIN
+	if c.Table != "auto" {
+		t, err := strconv.Atoi(c.Table)
+		if err != nil {
+			return nil, 0, fmt.Errorf("table %q: %v", c.Table, err)
+		}
+		table = t
+	}
+	var defTable int
+	var routes []*netlink.Route
+	for _, p := range c.Peers {
+		for i := range p.AllowedIPs {
+			n := p.AllowedIPs[i]
+			t := table
+			// A default route in the main table would also catch the
+			// tunnel's own packets, so it goes into a table that only
+			// unmarked packets look up.
+			if ones, _ := n.Mask.Size(); ones == 0 && c.Table == "auto" {
+				defTable = DefaultTable
+				if c.FirewallMark != nil && *c.FirewallMark != 0 {
+					defTable = *c.FirewallMark
+				}
+				t = defTable
+			}
+			routes = append(routes, &netlink.Route{LinkIndex: index, Dst: &n, Table: t, Scope: netlink.SCOPE_LINK})
+		}
+	}
+	return routes, defTable, nil
+}
+
+// rules returns the policy rules that send unmarked packets to table, and
+// keep the more specific routes of the main table in use.
+func rules(table int) []*netlink.Rule {
+	var r []*netlink.Rule
+	for _, fam := range []int{unix.AF_INET, unix.AF_INET6} {
+		mark := netlink.NewRule()
+		mark.Family = fam
+		mark.Mark = table
+		mark.Invert = true
+		mark.Table = table
+
+		suppress := netlink.NewRule()
+		suppress.Family = fam
+		suppress.Table = unix.RT_TABLE_MAIN
+		suppress.SuppressPrefixlen = 0
+
+		r = append(r, mark, suppress)
+	}
+	return r
+}
+
+// Up creates the WireGuard interface name and configures it from c, as
+// wg-quick up does: the interface gets its addresses and routes to the
+// allowed IPs of its peers, and the DNS settings of c are added to
+// resolv.conf.
+func Up(name string, c *QuickConfig) error {
+	la := netlink.NewLinkAttrs()
+	la.Name = name
+	la.MTU = c.MTU
+	if err := netlink.LinkAdd(&netlink.Wireguard{LinkAttrs: la}); err != nil {
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			return ErrNotSupported
+		}
+		return fmt.Errorf("creating %s: %v", name, err)
+	}
+	if err := up(name, c); err != nil {
+		if l, lerr := netlink.LinkByName(name); lerr == nil {
+			netlink.LinkDel(l)
+		}
+		return err
+	}
+	return nil
+}
+
+func up(name string, c *QuickConfig) error {
+	l, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	routes, table, err := c.routes(l.Attrs().Index)
+	if err != nil {
+		return err
+	}
+	cfg := c.Config
+	if table != 0 && (cfg.FirewallMark == nil || *cfg.FirewallMark == 0) {
+		cfg.FirewallMark = &table
+	}
+	if err := Configure(name, &cfg); err != nil {
+		return err
+	}
+	for i := range c.Address {
+		if err := netlink.AddrAdd(l, &netlink.Addr{IPNet: &c.Address[i]}); err != nil {
+			return fmt.Errorf("adding %v to %s: %v", c.Address[i].String(), name, err)
+		}
+	}
+	if err := netlink.LinkSetUp(l); err != nil {
+		return fmt.Errorf("setting %s up: %v", name, err)
+	}
+	for _, r := range routes {
+		if err := netlink.RouteReplace(r); err != nil {
+			return fmt.Errorf("adding route to %v: %v", r.Dst, err)
+		}
+	}
+	if table != 0 {
+		for _, r := range rules(table) {
+			if err := netlink.RuleAdd(r); err != nil && !errors.Is(err, unix.EEXIST) {
+				return fmt.Errorf("adding rule %v: %v", r, err)
+			}
+		}
+	}
+	if len(c.DNS) > 0 || len(c.DNSSearch) > 0 {
+		dns := dhclient.DNSConfig{Nameservers: c.DNS, Search: c.DNSSearch}
+		if err := dhclient.DefaultResolver.Set(dnsSource(name), dhclient.PriorityStatic, dns); err != nil {
+			return fmt.Errorf("setting DNS: %v", err)
+		}
+	}
+	return nil
+}
+
+// Down removes the WireGuard interface name that Up created from c, with its
+// policy rules and DNS settings. Routes go away with the interface.
+func Down(name string, c *QuickConfig) error {
+	l, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	if _, table, err := c.routes(l.Attrs().Index); err == nil && table != 0 {
+		for _, r := range rules(table) {
+			// The rules may never have been added; that is fine.
+			netlink.RuleDel(r)
+		}
+	}
+	if err := dhclient.DefaultResolver.Remove(dnsSource(name)); err != nil {
+		return fmt.Errorf("removing DNS: %v", err)
+	}
+	if err := netlink.LinkDel(l); err != nil {
+		return fmt.Errorf("deleting %s: %v", name, err)
+	}
+	return nil
+}
@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +25,7 @@ import (
 	"github.com/u-root/u-root/pkg/uroot"
 	"github.com/u-root/u-root/pkg/uroot/builder"
 	"github.com/u-root/u-root/pkg/uroot/initramfs"
+	"github.com/u-root/u-root/pkg/vfile"
 )
 
 // multiFlag is used for flags that support multiple invocations, e.g. -files
@@ -50,6 +52,9 @@ var (
 	statsLabel                              *string
 	shellbang                               *bool
 	tags                                    *string
+	keyRing, policy                         *string
+	docs                                    *bool
+	timestamp                               *string
 	// For the new gobusybox support
 	usegobusybox *bool
 	genDir       *string
@@ -90,6 +95,11 @@ func init() {
 
 	tags = flag.String("tags", "", "Comma separated list of build tags")
 
+	keyRing = flag.String("keyring", "", "PGP key ring to add to the archive at "+vfile.DefaultKeyRingPath+" for verified boot")
+	policy = flag.String("policy", "", "Signature policy file (JSON) to add to the archive at "+vfile.DefaultPolicyPath+" for verified boot")
+	docs = flag.Bool("docs", false, "Add man pages and bash completions of the commands, made from their doc comments and flags")
+	timestamp = flag.String("timestamp", "", "Record this time (Unix seconds, or \"now\") in etc/timestamp as the earliest plausible time at boot. Defaults to $SOURCE_DATE_EPOCH; without either, no time is recorded and builds stay reproducible.")
+
 	// Flags for the gobusybox, which we hope to move to, since it works with modules.
 	genDir = flag.String("gen-dir", "", "Directory to generate source in")
 
@@ -345,6 +355,11 @@ func Main(l ulog.Logger, buildOpts *gbbgolang.BuildOpts) error {
 		})
 	}
 
+	bt, err := buildTime()
+	if err != nil {
+		return err
+	}
+
 	opts := uroot.Opts{
 		Env:             env,
 		Commands:        c,
@@ -357,6 +372,10 @@ func Main(l ulog.Logger, buildOpts *gbbgolang.BuildOpts) error {
 		InitCmd:         initCommand,
 		DefaultShell:    *defaultShell,
 		BuildOpts:       buildOpts,
+		BuildTime:       bt,
+		KeyRing:         *keyRing,
+		Policy:          *policy,
+		Docs:            *docs,
 	}
 	uinitArgs := shlex.Argv(*uinitCmd)
 	if len(uinitArgs) > 0 {
@@ -368,6 +387,27 @@ func Main(l ulog.Logger, buildOpts *gbbgolang.BuildOpts) error {
 	return uroot.CreateInitramfs(l, opts)
 }
 
+// buildTime is the time to record in the image: -timestamp, else
+// SOURCE_DATE_EPOCH. It is zero if neither is set, as recording the current
+// time would make every build differ.
+func buildTime() (time.Time, error) {
+	s := *timestamp
+	if s == "" {
+		s = os.Getenv("SOURCE_DATE_EPOCH")
+	}
+	switch s {
+	case "":
+		return time.Time{}, nil
+	case "now":
+		return time.Now(), nil
+	}
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid build timestamp %q: %w", s, err)
+	}
+	return time.Unix(sec, 0), nil
+}
+
 func validateArg(arg string) bool {
 	// Do the simple thing first: stat the path.
 	// This saves incorrect diagnostics when the